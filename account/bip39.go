@@ -0,0 +1,114 @@
+package account
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// ErrInvalidMnemonic is returned for a mnemonic of unknown words or a wrong
+// checksum.
+var ErrInvalidMnemonic = errors.New("invalid mnemonic")
+
+var bip39Index = func() map[string]int {
+	index := make(map[string]int, len(bip39English))
+	for i, word := range bip39English {
+		index[word] = i
+	}
+	return index
+}()
+
+// NewMnemonic generates a BIP-39 mnemonic of bitSize bits of entropy, which is
+// a multiple of 32 between 128 and 256.
+func NewMnemonic(bitSize int) (string, error) {
+	if err := validateEntropySize(bitSize); err != nil {
+		return "", err
+	}
+	entropy := make([]byte, bitSize/8)
+	if _, err := rand.Read(entropy); err != nil {
+		return "", err
+	}
+	return EntropyToMnemonic(entropy)
+}
+
+// EntropyToMnemonic encodes the entropy with its checksum into the words of
+// 11 bits each.
+func EntropyToMnemonic(entropy []byte) (string, error) {
+	entropyBits := len(entropy) * 8
+	if err := validateEntropySize(entropyBits); err != nil {
+		return "", err
+	}
+	checksum := sha256.Sum256(entropy)
+	data := append(append([]byte{}, entropy...), checksum[0])
+	words := make([]string, (entropyBits+entropyBits/32)/11)
+	for i := range words {
+		index := 0
+		for j := 0; j < 11; j++ {
+			bit := i*11 + j
+			index = index<<1 | int(data[bit/8]>>uint(7-bit%8)&1)
+		}
+		words[i] = bip39English[index]
+	}
+	return strings.Join(words, " "), nil
+}
+
+// MnemonicToEntropy decodes the mnemonic into its entropy, verifying the
+// checksum.
+func MnemonicToEntropy(mnemonic string) ([]byte, error) {
+	words := strings.Fields(mnemonic)
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return nil, ErrInvalidMnemonic
+	}
+	totalBits := len(words) * 11
+	checksumBits := uint(totalBits / 33)
+	entropyBits := totalBits - int(checksumBits)
+	data := make([]byte, (totalBits+7)/8)
+	for i, word := range words {
+		index, ok := bip39Index[word]
+		if !ok {
+			return nil, ErrInvalidMnemonic
+		}
+		for j := 0; j < 11; j++ {
+			if index>>uint(10-j)&1 == 1 {
+				bit := i*11 + j
+				data[bit/8] |= 1 << uint(7-bit%8)
+			}
+		}
+	}
+	entropy := data[:entropyBits/8]
+	checksum := sha256.Sum256(entropy)
+	if data[entropyBits/8]>>(8-checksumBits) != checksum[0]>>(8-checksumBits) {
+		return nil, ErrInvalidMnemonic
+	}
+	return entropy, nil
+}
+
+// IsMnemonicValid reports whether the mnemonic is of known words with a correct
+// checksum.
+func IsMnemonicValid(mnemonic string) bool {
+	_, err := MnemonicToEntropy(mnemonic)
+	return err == nil
+}
+
+// NewSeed derives the seed of 64 bytes of the mnemonic protected by the
+// passphrase. The passphrase is used as is, without the NFKD normalization of
+// BIP-39, which matters only for non ASCII passphrases.
+func NewSeed(mnemonic, passphrase string) ([]byte, error) {
+	if !IsMnemonicValid(mnemonic) {
+		return nil, ErrInvalidMnemonic
+	}
+	normalized := strings.Join(strings.Fields(mnemonic), " ")
+	return pbkdf2.Key([]byte(normalized), []byte("mnemonic"+passphrase), 2048, 64, sha512.New), nil
+}
+
+func validateEntropySize(bitSize int) error {
+	if bitSize < 128 || bitSize > 256 || bitSize%32 != 0 {
+		return fmt.Errorf("invalid entropy size %d, expected a multiple of 32 between 128 and 256", bitSize)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+// Package external delegates the signing of the node to a signer process, so
+// that the keys of the root signer and of the accounts never live in the node.
+//
+// The signer serves SignerAPI over JSON-RPC, usually on an IPC socket, and
+// checks every request against its approval rules before signing it with the
+// keys of its keystore. The node connects to it with ExternalSigner.
+package external
+
+import (
+	"fmt"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/account/keystore"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/rpc"
+	"github.com/QuarkChain/goquarkchain/serialize"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const (
+	// Namespace is the RPC namespace of SignerAPI.
+	Namespace = "account"
+
+	// Version is the version of the protocol between the node and the signer,
+	// the major version must match.
+	Version = "1.0.0"
+)
+
+// SignerAPI is the RPC service of the signer, signing the requests approved by
+// the rules with the unlocked keys of the keystore.
+type SignerAPI struct {
+	ks    *keystore.KeyStore
+	rules *Rules
+}
+
+// NewSignerAPI creates the service signing with the keys of the keystore, which
+// must be unlocked beforehand.
+func NewSignerAPI(ks *keystore.KeyStore, rules *Rules) *SignerAPI {
+	return &SignerAPI{ks: ks, rules: rules}
+}
+
+// APIs returns the RPC service of the signer.
+func (api *SignerAPI) APIs() []rpc.API {
+	return []rpc.API{
+		{
+			Namespace: Namespace,
+			Version:   Version,
+			Service:   api,
+			Public:    false,
+		},
+	}
+}
+
+// Version returns the version of the protocol.
+func (api *SignerAPI) Version() string {
+	return Version
+}
+
+// List returns the addresses of the accounts which can sign.
+func (api *SignerAPI) List() []account.Address {
+	addresses := make([]account.Address, 0)
+	for _, a := range api.ks.Accounts() {
+		if api.ks.IsUnlocked(a.Address.Recipient) {
+			addresses = append(addresses, a.Address)
+		}
+	}
+	return addresses
+}
+
+// SignTransaction signs the RLP of the EVM transaction by the from recipient,
+// the signature in it is ignored, and returns the RLP of the signed transaction.
+func (api *SignerAPI) SignTransaction(from account.Recipient, rawTx hexutil.Bytes) (hexutil.Bytes, error) {
+	tx := new(types.EvmTransaction)
+	if err := rlp.DecodeBytes(rawTx, tx); err != nil {
+		return nil, err
+	}
+	if reason := api.rules.approveTx(tx); reason != "" {
+		log.Warn("Transaction signing denied", "from", from, "nonce", tx.Nonce(), "reason", reason)
+		return nil, fmt.Errorf("request denied: %s", reason)
+	}
+	signed, err := api.ks.SignTx(from, tx)
+	if err != nil {
+		return nil, err
+	}
+	log.Info("Transaction signed", "from", from, "nonce", tx.Nonce(), "to", tx.To(), "value", tx.Value())
+	return rlp.EncodeToBytes(signed)
+}
+
+// SignRootBlock signs the seal hash of the serialized root block header with the
+// key of the root signer, and returns the signature.
+func (api *SignerAPI) SignRootBlock(rawHeader hexutil.Bytes) (hexutil.Bytes, error) {
+	header := new(types.RootBlockHeader)
+	if err := serialize.DeserializeFromBytes(rawHeader, header); err != nil {
+		return nil, err
+	}
+	if reason := api.rules.approveRootBlock(header); reason != "" {
+		log.Warn("Root block signing denied", "number", header.Number, "reason", reason)
+		return nil, fmt.Errorf("request denied: %s", reason)
+	}
+	signature, err := api.ks.SignHash(*api.rules.RootSigner, header.SealHash().Bytes())
+	if err != nil {
+		return nil, err
+	}
+	log.Info("Root block signed", "number", header.Number, "coinbase", header.Coinbase.ToHex())
+	return signature, nil
+}
@@ -0,0 +1,124 @@
+package external
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/rpc"
+	"github.com/QuarkChain/goquarkchain/serialize"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// ExternalSigner is the connection of the node to a signer process.
+type ExternalSigner struct {
+	endpoint string
+	client   *rpc.Client
+}
+
+// NewExternalSigner connects to the signer at the endpoint, an IPC path or an
+// HTTP or WebSocket URL.
+func NewExternalSigner(endpoint string) (*ExternalSigner, error) {
+	client, err := rpc.Dial(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := newExternalSigner(endpoint, client)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return signer, nil
+}
+
+func newExternalSigner(endpoint string, client *rpc.Client) (*ExternalSigner, error) {
+	var version string
+	if err := client.Call(&version, Namespace+"_version"); err != nil {
+		return nil, err
+	}
+	if major(version) != major(Version) {
+		return nil, fmt.Errorf("signer version %s is not compatible with %s", version, Version)
+	}
+	return &ExternalSigner{endpoint: endpoint, client: client}, nil
+}
+
+func major(version string) string {
+	return strings.SplitN(version, ".", 2)[0]
+}
+
+// Endpoint returns the endpoint of the signer.
+func (s *ExternalSigner) Endpoint() string {
+	return s.endpoint
+}
+
+// Accounts returns the addresses of the accounts the signer can sign for.
+func (s *ExternalSigner) Accounts() ([]account.Address, error) {
+	var addresses []account.Address
+	if err := s.client.Call(&addresses, Namespace+"_list"); err != nil {
+		return nil, err
+	}
+	return addresses, nil
+}
+
+// Contains reports whether the signer can sign for the recipient.
+func (s *ExternalSigner) Contains(recipient account.Recipient) bool {
+	addresses, err := s.Accounts()
+	if err != nil {
+		return false
+	}
+	for _, a := range addresses {
+		if a.Recipient == recipient {
+			return true
+		}
+	}
+	return false
+}
+
+// SignTx asks the signer to sign the transaction by the recipient.
+func (s *ExternalSigner) SignTx(recipient account.Recipient, tx *types.EvmTransaction) (*types.EvmTransaction, error) {
+	rawTx, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		return nil, err
+	}
+	var res hexutil.Bytes
+	if err := s.client.Call(&res, Namespace+"_signTransaction", recipient, hexutil.Bytes(rawTx)); err != nil {
+		return nil, err
+	}
+	signed := new(types.EvmTransaction)
+	if err := rlp.DecodeBytes(res, signed); err != nil {
+		return nil, err
+	}
+	sender, err := types.Sender(types.MakeSigner(signed.NetworkId()), signed)
+	if err != nil {
+		return nil, err
+	}
+	if sender != recipient {
+		return nil, fmt.Errorf("signer mismatch: expected %x, got %x", recipient, sender)
+	}
+	return signed, nil
+}
+
+// SignRootBlock asks the signer to sign the seal hash of the root block header,
+// it is the signer of the root blocks to mine.
+func (s *ExternalSigner) SignRootBlock(header *types.RootBlockHeader) ([]byte, error) {
+	rawHeader, err := serialize.SerializeToBytes(header)
+	if err != nil {
+		return nil, err
+	}
+	var signature hexutil.Bytes
+	if err := s.client.Call(&signature, Namespace+"_signRootBlock", hexutil.Bytes(rawHeader)); err != nil {
+		return nil, err
+	}
+	if len(signature) != 65 {
+		return nil, errors.New("invalid signature length")
+	}
+	return signature, nil
+}
+
+// Close disconnects from the signer.
+func (s *ExternalSigner) Close() {
+	s.client.Close()
+}
@@ -0,0 +1,96 @@
+package external
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/account/keystore"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/rpc"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSigner(t *testing.T, rules *Rules) (*keystore.KeyStore, *ExternalSigner, func()) {
+	dir, err := ioutil.TempDir("", "external-signer-test")
+	assert.NoError(t, err)
+	ks := keystore.NewKeyStore(dir, keystore.LightScryptN, keystore.LightScryptP)
+	server := rpc.NewServer()
+	assert.NoError(t, server.RegisterName(Namespace, NewSignerAPI(ks, rules)))
+	signer, err := newExternalSigner("inproc", rpc.DialInProc(server))
+	assert.NoError(t, err)
+	return ks, signer, func() {
+		signer.Close()
+		server.Stop()
+		ks.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestSignTransaction(t *testing.T) {
+	allowed := account.Recipient{0x01}
+	rules := &Rules{AllowedTo: []account.Recipient{allowed}, MaxValue: (*hexutil.Big)(big.NewInt(1000))}
+	ks, signer, cleanup := newTestSigner(t, rules)
+	defer cleanup()
+
+	a, err := ks.NewAccount("foo", nil)
+	assert.NoError(t, err)
+	from := a.Address.Recipient
+	tx := types.NewEvmTransaction(0, allowed, big.NewInt(100), 30000, big.NewInt(1), 0, 0, 3, 0, nil, 35760, 35760)
+
+	// the locked accounts can not sign
+	assert.False(t, signer.Contains(from))
+	_, err = signer.SignTx(from, tx)
+	assert.Error(t, err)
+
+	assert.NoError(t, ks.Unlock(from, "foo"))
+	assert.True(t, signer.Contains(from))
+	signed, err := signer.SignTx(from, tx)
+	assert.NoError(t, err)
+	sender, err := types.Sender(types.MakeSigner(3), signed)
+	assert.NoError(t, err)
+	assert.Equal(t, from, sender)
+
+	denied := []*types.EvmTransaction{
+		types.NewEvmTransaction(0, account.Recipient{0x02}, big.NewInt(100), 30000, big.NewInt(1), 0, 0, 3, 0, nil, 35760, 35760),
+		types.NewEvmTransaction(0, allowed, big.NewInt(1001), 30000, big.NewInt(1), 0, 0, 3, 0, nil, 35760, 35760),
+		types.NewEvmContractCreation(0, big.NewInt(0), 30000, big.NewInt(1), 0, 0, 3, 0, nil, 35760, 35760),
+	}
+	for _, tx := range denied {
+		_, err = signer.SignTx(from, tx)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "request denied")
+		}
+	}
+}
+
+func TestSignRootBlock(t *testing.T) {
+	rules := new(Rules)
+	ks, signer, cleanup := newTestSigner(t, rules)
+	defer cleanup()
+
+	a, err := ks.NewAccount("foo", nil)
+	assert.NoError(t, err)
+	header := &types.RootBlockHeader{Number: 1, Coinbase: account.NewAddress(account.Recipient{0x01}, 0)}
+
+	// root block signing is disabled without a root signer
+	_, err = signer.SignRootBlock(header)
+	assert.Error(t, err)
+
+	rules.RootSigner = &a.Address.Recipient
+	rules.RootBlockCoinbases = []account.Recipient{{0x02}}
+	assert.NoError(t, ks.Unlock(a.Address.Recipient, "foo"))
+	_, err = signer.SignRootBlock(header)
+	assert.Error(t, err)
+
+	header.Coinbase = account.NewAddress(account.Recipient{0x02}, 0)
+	signature, err := signer.SignRootBlock(header)
+	assert.NoError(t, err)
+	pubKey, err := crypto.SigToPub(header.SealHash().Bytes(), signature)
+	assert.NoError(t, err)
+	assert.Equal(t, a.Address.Recipient, crypto.PubkeyToAddress(*pubKey))
+}
@@ -0,0 +1,95 @@
+package external
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Rules are the approval rules of the signer, a request breaking any of them is
+// denied. The zero rules approve every transaction and no root block.
+type Rules struct {
+	// AllowedTo are the recipients the transactions may be sent to, any if
+	// empty.
+	AllowedTo []account.Recipient `json:"allowedTo"`
+
+	// AllowContractCreation approves the transactions creating contracts.
+	AllowContractCreation bool `json:"allowContractCreation"`
+
+	// MaxValue is the maximal value of a transaction, unlimited if nil.
+	MaxValue *hexutil.Big `json:"maxValue"`
+
+	// MaxGasPrice is the maximal gas price of a transaction, unlimited if nil.
+	MaxGasPrice *hexutil.Big `json:"maxGasPrice"`
+
+	// NetworkID is the network of the transactions, any if 0.
+	NetworkID uint32 `json:"networkId"`
+
+	// RootSigner is the account signing the root blocks, no root block is
+	// signed if nil.
+	RootSigner *account.Recipient `json:"rootSigner"`
+
+	// RootBlockCoinbases are the recipients the root blocks signed may pay the
+	// coinbase to, any if empty.
+	RootBlockCoinbases []account.Recipient `json:"rootBlockCoinbases"`
+}
+
+// LoadRules reads the rules from the json file.
+func LoadRules(file string) (*Rules, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	rules := new(Rules)
+	if err := json.Unmarshal(content, rules); err != nil {
+		return nil, fmt.Errorf("invalid rules file %s: %v", file, err)
+	}
+	return rules, nil
+}
+
+// approveTx returns the reason to deny the transaction, or an empty string if
+// it is approved.
+func (r *Rules) approveTx(tx *types.EvmTransaction) string {
+	if r.NetworkID != 0 && tx.NetworkId() != r.NetworkID {
+		return fmt.Sprintf("network id %d is not %d", tx.NetworkId(), r.NetworkID)
+	}
+	if tx.To() == nil {
+		if !r.AllowContractCreation {
+			return "contract creation is not allowed"
+		}
+	} else if len(r.AllowedTo) > 0 && !containsRecipient(r.AllowedTo, *tx.To()) {
+		return fmt.Sprintf("recipient %x is not allowed", *tx.To())
+	}
+	if r.MaxValue != nil && tx.Value().Cmp(r.MaxValue.ToInt()) > 0 {
+		return fmt.Sprintf("value %v exceeds %v", tx.Value(), r.MaxValue.ToInt())
+	}
+	if r.MaxGasPrice != nil && tx.GasPrice().Cmp(r.MaxGasPrice.ToInt()) > 0 {
+		return fmt.Sprintf("gas price %v exceeds %v", tx.GasPrice(), r.MaxGasPrice.ToInt())
+	}
+	return ""
+}
+
+// approveRootBlock returns the reason to deny the root block, or an empty
+// string if it is approved.
+func (r *Rules) approveRootBlock(header *types.RootBlockHeader) string {
+	if r.RootSigner == nil {
+		return "root block signing is not enabled"
+	}
+	if len(r.RootBlockCoinbases) > 0 && !containsRecipient(r.RootBlockCoinbases, header.Coinbase.Recipient) {
+		return fmt.Sprintf("coinbase %x is not allowed", header.Coinbase.Recipient)
+	}
+	return ""
+}
+
+func containsRecipient(recipients []account.Recipient, recipient account.Recipient) bool {
+	for _, r := range recipients {
+		if r == recipient {
+			return true
+		}
+	}
+	return false
+}
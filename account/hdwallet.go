@@ -0,0 +1,228 @@
+package account
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	// HardenedKeyStart is the index of the first hardened child key of BIP-32.
+	HardenedKeyStart uint32 = 0x80000000
+
+	// QuarkChainCoinType is the coin type of QuarkChain in the derivation path.
+	QuarkChainCoinType uint32 = 99999999
+)
+
+// DefaultBaseDerivationPath is the BIP-44 path m/44'/99999999'/0'/0 of the
+// addresses of a wallet, the index of the address is appended to it.
+var DefaultBaseDerivationPath = DerivationPath{
+	HardenedKeyStart + 44, HardenedKeyStart + QuarkChainCoinType, HardenedKeyStart + 0, 0,
+}
+
+// ErrInvalidChildKey is returned in the rare case that the key derived at an
+// index is not valid, the next index should be used then.
+var ErrInvalidChildKey = errors.New("invalid child key, use the next index")
+
+// DerivationPath is the indexes of the child keys from the master key.
+type DerivationPath []uint32
+
+// ParseDerivationPath parses a path like m/44'/99999999'/0'/0/1, where the
+// index followed by ' or h is hardened.
+func ParseDerivationPath(path string) (DerivationPath, error) {
+	components := strings.Split(strings.TrimSpace(path), "/")
+	if len(components) == 0 || components[0] != "m" {
+		return nil, fmt.Errorf("derivation path %q does not start with m", path)
+	}
+	var result DerivationPath
+	for _, component := range components[1:] {
+		offset := uint32(0)
+		if strings.HasSuffix(component, "'") || strings.HasSuffix(component, "h") {
+			offset = HardenedKeyStart
+			component = component[:len(component)-1]
+		}
+		index, err := strconv.ParseUint(component, 10, 31)
+		if err != nil {
+			return nil, fmt.Errorf("invalid component %q of derivation path %q", component, path)
+		}
+		result = append(result, offset+uint32(index))
+	}
+	return result, nil
+}
+
+func (path DerivationPath) String() string {
+	result := "m"
+	for _, index := range path {
+		if index >= HardenedKeyStart {
+			result = fmt.Sprintf("%s/%d'", result, index-HardenedKeyStart)
+		} else {
+			result = fmt.Sprintf("%s/%d", result, index)
+		}
+	}
+	return result
+}
+
+// ExtendedKey is a private key with its chain code, the child keys of which are
+// derived by BIP-32.
+type ExtendedKey struct {
+	key       Key
+	chainCode []byte
+}
+
+// NewMasterKey derives the master key of the seed.
+func NewMasterKey(seed []byte) (*ExtendedKey, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, fmt.Errorf("invalid seed length %d, expected 16 to 64", len(seed))
+	}
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	keyValue := new(big.Int).SetBytes(sum[:32])
+	if keyValue.Sign() == 0 || keyValue.Cmp(crypto.S256().Params().N) >= 0 {
+		return nil, errors.New("invalid master key")
+	}
+	return &ExtendedKey{key: BytesToIdentityKey(sum[:32]), chainCode: sum[32:]}, nil
+}
+
+// Child derives the child key at the index, which is hardened if it is not less
+// than HardenedKeyStart.
+func (Self *ExtendedKey) Child(index uint32) (*ExtendedKey, error) {
+	var data []byte
+	if index >= HardenedKeyStart {
+		data = append([]byte{0x00}, Self.key.Bytes()...)
+	} else {
+		privateKey, err := crypto.ToECDSA(Self.key.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		data = crypto.CompressPubkey(&privateKey.PublicKey)
+	}
+	data = append(data, make([]byte, 4)...)
+	binary.BigEndian.PutUint32(data[len(data)-4:], index)
+
+	mac := hmac.New(sha512.New, Self.chainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+	n := crypto.S256().Params().N
+	tweak := new(big.Int).SetBytes(sum[:32])
+	if tweak.Cmp(n) >= 0 {
+		return nil, ErrInvalidChildKey
+	}
+	keyValue := tweak.Add(tweak, new(big.Int).SetBytes(Self.key.Bytes()))
+	keyValue.Mod(keyValue, n)
+	if keyValue.Sign() == 0 {
+		return nil, ErrInvalidChildKey
+	}
+	return &ExtendedKey{key: BytesToIdentityKey(keyValue.Bytes()), chainCode: sum[32:]}, nil
+}
+
+// Derive derives the key at the path relative to the key.
+func (Self *ExtendedKey) Derive(path DerivationPath) (*ExtendedKey, error) {
+	key := Self
+	for _, index := range path {
+		child, err := key.Child(index)
+		if err != nil {
+			return nil, err
+		}
+		key = child
+	}
+	return key, nil
+}
+
+// Key returns the private key of the extended key.
+func (Self *ExtendedKey) Key() Key {
+	return Self.key
+}
+
+// FullShardKeyStrategy decides the full shard key of the address derived at the
+// index of a wallet.
+type FullShardKeyStrategy func(identity Identity, index uint32) (uint32, error)
+
+// DefaultFullShardKey puts every address on the default full shard key of its
+// recipient.
+func DefaultFullShardKey(identity Identity, index uint32) (uint32, error) {
+	return identity.GetDefaultFullShardKey()
+}
+
+// FixedFullShardKey puts every address on the full shard key.
+func FixedFullShardKey(fullShardKey uint32) FullShardKeyStrategy {
+	return func(Identity, uint32) (uint32, error) {
+		return fullShardKey, nil
+	}
+}
+
+// RoundRobinShards spreads the addresses over the shards of the chain, the
+// address at the index is on shard index % shardSize.
+func RoundRobinShards(chainID, shardSize uint32) (FullShardKeyStrategy, error) {
+	if shardSize == 0 || shardSize&(shardSize-1) != 0 {
+		return nil, fmt.Errorf("shardSize is not right shardSize:%d", shardSize)
+	}
+	return func(_ Identity, index uint32) (uint32, error) {
+		return chainID<<16 | index%shardSize, nil
+	}, nil
+}
+
+// HDWallet derives the identities and the addresses of a mnemonic at the
+// indexes under the base derivation path.
+type HDWallet struct {
+	basePath DerivationPath
+	baseKey  *ExtendedKey
+}
+
+// NewHDWallet creates the wallet of the mnemonic protected by the passphrase,
+// DefaultBaseDerivationPath is used if basePath is nil.
+func NewHDWallet(mnemonic, passphrase string, basePath DerivationPath) (*HDWallet, error) {
+	seed, err := NewSeed(mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	masterKey, err := NewMasterKey(seed)
+	if err != nil {
+		return nil, err
+	}
+	if basePath == nil {
+		basePath = DefaultBaseDerivationPath
+	}
+	baseKey, err := masterKey.Derive(basePath)
+	if err != nil {
+		return nil, err
+	}
+	return &HDWallet{basePath: basePath, baseKey: baseKey}, nil
+}
+
+// Path returns the derivation path of the address at the index.
+func (Self *HDWallet) Path(index uint32) DerivationPath {
+	path := make(DerivationPath, len(Self.basePath), len(Self.basePath)+1)
+	copy(path, Self.basePath)
+	return append(path, index)
+}
+
+// Identity derives the identity at the index.
+func (Self *HDWallet) Identity(index uint32) (Identity, error) {
+	key, err := Self.baseKey.Child(index)
+	if err != nil {
+		return Identity{}, err
+	}
+	return CreatIdentityFromKey(key.Key())
+}
+
+// Address derives the address at the index, on the full shard key decided by
+// the strategy.
+func (Self *HDWallet) Address(index uint32, strategy FullShardKeyStrategy) (Address, error) {
+	identity, err := Self.Identity(index)
+	if err != nil {
+		return Address{}, err
+	}
+	fullShardKey, err := strategy(identity, index)
+	if err != nil {
+		return Address{}, err
+	}
+	return CreatAddressFromIdentity(identity, fullShardKey), nil
+}
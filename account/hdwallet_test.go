@@ -0,0 +1,150 @@
+package account
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// test vectors of BIP-39, with the passphrase "TREZOR"
+var bip39Vectors = []struct {
+	entropy  string
+	mnemonic string
+	seed     string
+}{
+	{
+		entropy:  "00000000000000000000000000000000",
+		mnemonic: "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+		seed:     "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+	},
+	{
+		entropy:  "7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+		mnemonic: "legal winner thank year wave sausage worth useful legal winner thank yellow",
+		seed:     "2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+	},
+	{
+		entropy:  "ffffffffffffffffffffffffffffffff",
+		mnemonic: "zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong",
+		seed:     "ac27495480225222079d7be181583751e86f571027b0497b5b5d11218e0a8a13332572917f0f8e5a589620c6f15b11c61dee327651a14c34e18231052e48c069",
+	},
+	{
+		entropy:  "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+		mnemonic: "zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo vote",
+		seed:     "dd48c104698c30cfe2b6142103248622fb7bb0ff692eebb00089b32d22484e1613912f0a5b694407be899ffd31ed3992c456cdf60f5d4564b8ba3f05a69890ad",
+	},
+	{
+		entropy:  "15da872c95a13dd738fbf50e427583ad61f18fd99f628c417a61cf8343c90419",
+		mnemonic: "beyond stage sleep clip because twist token leaf atom beauty genius food business side grid unable middle armed observe pair crouch tonight away coconut",
+		seed:     "b15509eaa2d09d3efd3e006ef42151b30367dc6e3aa5e44caba3fe4d3e352e65101fbdb86a96776b91946ff06f8eac594dc6ee1d3e82a42dfe1b40fef6bcc3fd",
+	},
+}
+
+func TestMnemonic(t *testing.T) {
+	for _, v := range bip39Vectors {
+		entropy, _ := hex.DecodeString(v.entropy)
+		mnemonic, err := EntropyToMnemonic(entropy)
+		assert.NoError(t, err)
+		assert.Equal(t, v.mnemonic, mnemonic)
+
+		decoded, err := MnemonicToEntropy(v.mnemonic)
+		assert.NoError(t, err)
+		assert.Equal(t, entropy, decoded)
+
+		seed, err := NewSeed(v.mnemonic, "TREZOR")
+		assert.NoError(t, err)
+		assert.Equal(t, v.seed, hex.EncodeToString(seed))
+	}
+
+	for _, mnemonic := range []string{
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon",
+		"legal winner thank year wave sausage worth useful legal winner thank yellow yellow",
+		"letter advice cage absurd amount doctor acoustic avoid letter advice caged above",
+		"jello better achieve collect unaware mountain thought cargo oxygen act hood bridge",
+		"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo voted",
+	} {
+		assert.False(t, IsMnemonicValid(mnemonic), mnemonic)
+	}
+
+	mnemonic, err := NewMnemonic(256)
+	assert.NoError(t, err)
+	assert.True(t, IsMnemonicValid(mnemonic))
+	_, err = NewMnemonic(100)
+	assert.Error(t, err)
+}
+
+// test vector 1 of BIP-32
+func TestExtendedKey(t *testing.T) {
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	master, err := NewMasterKey(seed)
+	assert.NoError(t, err)
+	assert.Equal(t, "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35", hex.EncodeToString(master.Key().Bytes()))
+
+	for _, v := range []struct {
+		path string
+		key  string
+	}{
+		{"m/0'", "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea"},
+		{"m/0'/1", "3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368"},
+		{"m/0h/1/2'", "cbce0d719ecf7431d88e6a89fa1483e02e35092af60c042b1df2ff59fa424dca"},
+		{"m/0'/1/2'/2", "0f479245fb19a38a1954c5c7c0ebab2f9bdfd96a17563ef28a6a4b1a2a764ef4"},
+		{"m/0'/1/2'/2/1000000000", "471b76e389e528d6de6d816857e012c5455051cad6660850e58372a6c3e6e7c8"},
+	} {
+		path, err := ParseDerivationPath(v.path)
+		assert.NoError(t, err)
+		key, err := master.Derive(path)
+		assert.NoError(t, err)
+		assert.Equal(t, v.key, hex.EncodeToString(key.Key().Bytes()), v.path)
+	}
+}
+
+func TestDerivationPath(t *testing.T) {
+	path, err := ParseDerivationPath("m/44'/99999999'/0'/0")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultBaseDerivationPath, path)
+	assert.Equal(t, "m/44'/99999999'/0'/0", path.String())
+
+	for _, invalid := range []string{"", "44'/0", "m/x", "m/2147483648", "m//1"} {
+		_, err := ParseDerivationPath(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestHDWallet(t *testing.T) {
+	mnemonic := bip39Vectors[1].mnemonic
+	wallet, err := NewHDWallet(mnemonic, "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "m/44'/99999999'/0'/0/3", wallet.Path(3).String())
+
+	// the identity is the one of the key at the full path
+	seed, _ := NewSeed(mnemonic, "")
+	master, _ := NewMasterKey(seed)
+	key, err := master.Derive(wallet.Path(3))
+	assert.NoError(t, err)
+	identity, err := wallet.Identity(3)
+	assert.NoError(t, err)
+	assert.Equal(t, key.Key(), identity.GetKey())
+
+	address, err := wallet.Address(3, DefaultFullShardKey)
+	assert.NoError(t, err)
+	defaultFullShardKey, _ := identity.GetDefaultFullShardKey()
+	assert.Equal(t, NewAddress(identity.GetRecipient(), defaultFullShardKey), address)
+
+	address, err = wallet.Address(3, FixedFullShardKey(0x00020001))
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(0x00020001), address.FullShardKey)
+
+	strategy, err := RoundRobinShards(1, 4)
+	assert.NoError(t, err)
+	for index := uint32(0); index < 8; index++ {
+		address, err := wallet.Address(index, strategy)
+		assert.NoError(t, err)
+		fullShardID, _ := address.GetFullShardID(4)
+		assert.Equal(t, uint32(1<<16|4|index%4), fullShardID)
+	}
+	_, err = RoundRobinShards(1, 3)
+	assert.Error(t, err)
+
+	_, err = NewHDWallet("abandon abandon", "", nil)
+	assert.Equal(t, ErrInvalidMnemonic, err)
+}
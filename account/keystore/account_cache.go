@@ -0,0 +1,219 @@
+package keystore
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// rescanInterval is the interval the key directory is scanned at for the key
+// files added or removed by hand.
+const rescanInterval = 2 * time.Second
+
+// accountCache is the live index of the accounts in the key directory.
+type accountCache struct {
+	keydir string
+	mu     sync.Mutex
+	all    []Account // sorted by path
+	byAddr map[account.Recipient][]Account
+	files  map[string]time.Time // modification time of the scanned files
+	quit   chan struct{}
+}
+
+func newAccountCache(keydir string) *accountCache {
+	ac := &accountCache{
+		keydir: keydir,
+		byAddr: make(map[account.Recipient][]Account),
+		files:  make(map[string]time.Time),
+		quit:   make(chan struct{}),
+	}
+	ac.scan()
+	go ac.watch()
+	return ac
+}
+
+func (ac *accountCache) accounts() []Account {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	cpy := make([]Account, len(ac.all))
+	copy(cpy, ac.all)
+	return cpy
+}
+
+func (ac *accountCache) hasAddress(recipient account.Recipient) bool {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	return len(ac.byAddr[recipient]) > 0
+}
+
+// find returns the account of the recipient, ErrAmbiguousAddr is returned if
+// more than one key file holds the key of the recipient.
+func (ac *accountCache) find(recipient account.Recipient) (Account, error) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	matches := ac.byAddr[recipient]
+	switch len(matches) {
+	case 0:
+		return Account{}, ErrNoMatch
+	case 1:
+		return matches[0], nil
+	}
+	return Account{}, ErrAmbiguousAddr
+}
+
+func (ac *accountCache) add(a Account) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	ac.addLocked(a)
+	if fi, err := os.Stat(a.Path); err == nil {
+		ac.files[a.Path] = fi.ModTime()
+	}
+}
+
+func (ac *accountCache) addLocked(a Account) {
+	i := sort.Search(len(ac.all), func(i int) bool { return ac.all[i].Path >= a.Path })
+	if i < len(ac.all) && ac.all[i].Path == a.Path {
+		ac.removeLocked(a.Path)
+	}
+	ac.all = append(ac.all, Account{})
+	copy(ac.all[i+1:], ac.all[i:])
+	ac.all[i] = a
+	ac.byAddr[a.Address.Recipient] = append(ac.byAddr[a.Address.Recipient], a)
+}
+
+func (ac *accountCache) delete(path string) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	ac.removeLocked(path)
+	delete(ac.files, path)
+}
+
+func (ac *accountCache) removeLocked(path string) {
+	for i, a := range ac.all {
+		if a.Path != path {
+			continue
+		}
+		ac.all = append(ac.all[:i], ac.all[i+1:]...)
+		matches := ac.byAddr[a.Address.Recipient]
+		for j := range matches {
+			if matches[j].Path == path {
+				matches = append(matches[:j], matches[j+1:]...)
+				break
+			}
+		}
+		if len(matches) == 0 {
+			delete(ac.byAddr, a.Address.Recipient)
+		} else {
+			ac.byAddr[a.Address.Recipient] = matches
+		}
+		return
+	}
+}
+
+// scan reads the key directory and updates the index with the files added,
+// removed or modified since the last scan.
+func (ac *accountCache) scan() {
+	fis, err := ioutil.ReadDir(ac.keydir)
+	if err != nil && !os.IsNotExist(err) {
+		log.Warn("Failed to read key directory", "dir", ac.keydir, "err", err)
+		return
+	}
+	seen := make(map[string]struct{}, len(fis))
+	for _, fi := range fis {
+		path := filepath.Join(ac.keydir, fi.Name())
+		if skipKeyFile(fi) {
+			continue
+		}
+		seen[path] = struct{}{}
+
+		ac.mu.Lock()
+		modTime, ok := ac.files[path]
+		ac.mu.Unlock()
+		if ok && modTime.Equal(fi.ModTime()) {
+			continue
+		}
+		a, err := readAccount(path)
+		ac.mu.Lock()
+		ac.files[path] = fi.ModTime()
+		ac.removeLocked(path)
+		if err != nil {
+			log.Debug("Failed to read key file", "path", path, "err", err)
+		} else {
+			ac.addLocked(a)
+		}
+		ac.mu.Unlock()
+	}
+
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	for path := range ac.files {
+		if _, ok := seen[path]; !ok {
+			ac.removeLocked(path)
+			delete(ac.files, path)
+		}
+	}
+}
+
+// watch rescans the key directory periodically until the cache is closed.
+func (ac *accountCache) watch() {
+	ticker := time.NewTicker(rescanInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			ac.scan()
+		case <-ac.quit:
+			return
+		}
+	}
+}
+
+func (ac *accountCache) close() {
+	close(ac.quit)
+}
+
+// readAccount reads the address of the key file without decrypting the key.
+func readAccount(path string) (Account, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Account{}, err
+	}
+	k := new(encryptedKeyJSON)
+	if err := json.Unmarshal(data, k); err != nil {
+		return Account{}, err
+	}
+	address, err := k.address()
+	if err != nil {
+		return Account{}, err
+	}
+	if address.recipient == nil {
+		return Account{}, ErrNoMatch
+	}
+	if address.fullShardKey == nil {
+		identity := account.NewIdentity(*address.recipient, account.Key{})
+		fullShardKey, err := identity.GetDefaultFullShardKey()
+		if err != nil {
+			return Account{}, err
+		}
+		address.fullShardKey = &fullShardKey
+	}
+	return Account{Address: account.NewAddress(*address.recipient, *address.fullShardKey), Path: path}, nil
+}
+
+// skipKeyFile ignores the directories, the editor backups and the hidden files,
+// which include the temporary files written by the key store.
+func skipKeyFile(fi os.FileInfo) bool {
+	name := fi.Name()
+	if strings.HasSuffix(name, "~") || strings.HasPrefix(name, ".") {
+		return true
+	}
+	return fi.IsDir() || fi.Mode()&os.ModeType != 0
+}
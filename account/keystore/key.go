@@ -0,0 +1,342 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pborman/uuid"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	keyHeaderKDF = "scrypt"
+	keyCipher    = "aes-128-ctr"
+	keyVersion   = 3
+
+	// StandardScryptN is the N parameter of Scrypt encryption algorithm, using 256MB
+	// memory and taking approximately 1s CPU time on a modern processor.
+	StandardScryptN = 1 << 18
+
+	// StandardScryptP is the P parameter of Scrypt encryption algorithm, using 256MB
+	// memory and taking approximately 1s CPU time on a modern processor.
+	StandardScryptP = 1
+
+	// LightScryptN is the N parameter of Scrypt encryption algorithm, using 4MB
+	// memory and taking approximately 100ms CPU time on a modern processor.
+	LightScryptN = 1 << 12
+
+	// LightScryptP is the P parameter of Scrypt encryption algorithm, using 4MB
+	// memory and taking approximately 100ms CPU time on a modern processor.
+	LightScryptP = 6
+
+	scryptR     = 8
+	scryptDKLen = 32
+)
+
+// Key is a private key with the address it controls, the full shard key of the
+// address is the shard the account is used on by default.
+type Key struct {
+	ID         uuid.UUID
+	Address    account.Address
+	PrivateKey *ecdsa.PrivateKey
+}
+
+// encryptedKeyJSON is the web3 secret storage format, with the full shard key of
+// the address in an additional field.
+type encryptedKeyJSON struct {
+	Address      string     `json:"address"`
+	FullShardKey string     `json:"fullShardKey"`
+	Crypto       cryptoJSON `json:"crypto"`
+	ID           string     `json:"id"`
+	Version      int        `json:"version"`
+}
+
+type cryptoJSON struct {
+	Cipher       string                 `json:"cipher"`
+	CipherText   string                 `json:"ciphertext"`
+	CipherParams cipherParamsJSON       `json:"cipherparams"`
+	KDF          string                 `json:"kdf"`
+	KDFParams    map[string]interface{} `json:"kdfparams"`
+	MAC          string                 `json:"mac"`
+}
+
+type cipherParamsJSON struct {
+	IV string `json:"iv"`
+}
+
+// newKey creates a key of the private key, the address is on the default full
+// shard key of the recipient if fullShardKey is nil.
+func newKey(privateKey *ecdsa.PrivateKey, fullShardKey *uint32) (*Key, error) {
+	identity, err := account.CreatIdentityFromKey(account.BytesToIdentityKey(crypto.FromECDSA(privateKey)))
+	if err != nil {
+		return nil, err
+	}
+	if fullShardKey == nil {
+		defaultFullShardKey, err := identity.GetDefaultFullShardKey()
+		if err != nil {
+			return nil, err
+		}
+		fullShardKey = &defaultFullShardKey
+	}
+	return &Key{
+		ID:         uuid.NewRandom(),
+		Address:    account.CreatAddressFromIdentity(identity, *fullShardKey),
+		PrivateKey: privateKey,
+	}, nil
+}
+
+func newRandomKey(fullShardKey *uint32) (*Key, error) {
+	privateKey, err := ecdsa.GenerateKey(crypto.S256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return newKey(privateKey, fullShardKey)
+}
+
+// EncryptKey encrypts the key with the passphrase into the json key file format,
+// using scrypt with the given parameters.
+func EncryptKey(key *Key, passphrase string, scryptN, scryptP int) ([]byte, error) {
+	salt := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	derivedKey, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, scryptDKLen)
+	if err != nil {
+		return nil, err
+	}
+	iv := make([]byte, aes.BlockSize)
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return nil, err
+	}
+	cipherText, err := aesCTRXOR(derivedKey[:16], crypto.FromECDSA(key.PrivateKey), iv)
+	if err != nil {
+		return nil, err
+	}
+	mac := crypto.Keccak256(derivedKey[16:32], cipherText)
+
+	return json.Marshal(encryptedKeyJSON{
+		Address:      hex.EncodeToString(key.Address.Recipient[:]),
+		FullShardKey: hex.EncodeToString(common.Uint32ToBytes(key.Address.FullShardKey)),
+		Crypto: cryptoJSON{
+			Cipher:       keyCipher,
+			CipherText:   hex.EncodeToString(cipherText),
+			CipherParams: cipherParamsJSON{IV: hex.EncodeToString(iv)},
+			KDF:          keyHeaderKDF,
+			KDFParams: map[string]interface{}{
+				"n":     scryptN,
+				"r":     scryptR,
+				"p":     scryptP,
+				"dklen": scryptDKLen,
+				"salt":  hex.EncodeToString(salt),
+			},
+			MAC: hex.EncodeToString(mac),
+		},
+		ID:      key.ID.String(),
+		Version: keyVersion,
+	})
+}
+
+// DecryptKey decrypts the json key file with the passphrase, both the scrypt and
+// the pbkdf2 key derivation functions are accepted.
+func DecryptKey(keyJSON []byte, passphrase string) (*Key, error) {
+	k := new(encryptedKeyJSON)
+	if err := json.Unmarshal(keyJSON, k); err != nil {
+		return nil, err
+	}
+	if k.Version != keyVersion {
+		return nil, fmt.Errorf("version not supported: %v", k.Version)
+	}
+	if k.Crypto.Cipher != keyCipher {
+		return nil, fmt.Errorf("cipher not supported: %v", k.Crypto.Cipher)
+	}
+	derivedKey, err := deriveKey(k.Crypto, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	cipherText, err := hex.DecodeString(k.Crypto.CipherText)
+	if err != nil {
+		return nil, err
+	}
+	mac, err := hex.DecodeString(k.Crypto.MAC)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(crypto.Keccak256(derivedKey[16:32], cipherText), mac) {
+		return nil, ErrDecrypt
+	}
+	iv, err := hex.DecodeString(k.Crypto.CipherParams.IV)
+	if err != nil {
+		return nil, err
+	}
+	plainText, err := aesCTRXOR(derivedKey[:16], cipherText, iv)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, err := crypto.ToECDSA(plainText)
+	if err != nil {
+		return nil, err
+	}
+
+	address, err := k.address()
+	if err != nil {
+		return nil, err
+	}
+	key, err := newKey(privateKey, address.fullShardKey)
+	if err != nil {
+		return nil, err
+	}
+	if address.recipient != nil && *address.recipient != key.Address.Recipient {
+		return nil, fmt.Errorf("key content mismatch: have address %x, want %x", key.Address.Recipient, *address.recipient)
+	}
+	if k.ID != "" {
+		key.ID = uuid.Parse(k.ID)
+	}
+	return key, nil
+}
+
+func deriveKey(c cryptoJSON, passphrase string) ([]byte, error) {
+	salt, err := hex.DecodeString(getString(c.KDFParams, "salt"))
+	if err != nil {
+		return nil, err
+	}
+	dkLen := getInt(c.KDFParams, "dklen")
+	if dkLen < 32 {
+		return nil, fmt.Errorf("derived key length %d is less than 32", dkLen)
+	}
+	switch c.KDF {
+	case keyHeaderKDF:
+		return scrypt.Key([]byte(passphrase), salt, getInt(c.KDFParams, "n"), getInt(c.KDFParams, "r"),
+			getInt(c.KDFParams, "p"), dkLen)
+	case "pbkdf2":
+		if prf := getString(c.KDFParams, "prf"); prf != "hmac-sha256" {
+			return nil, fmt.Errorf("unsupported PBKDF2 PRF: %s", prf)
+		}
+		return pbkdf2.Key([]byte(passphrase), salt, getInt(c.KDFParams, "c"), dkLen, sha256.New), nil
+	}
+	return nil, fmt.Errorf("unsupported KDF: %s", c.KDF)
+}
+
+// keyAddress is the address stored in plain text in a key file, the fields are
+// nil if they are missing.
+type keyAddress struct {
+	recipient    *account.Recipient
+	fullShardKey *uint32
+}
+
+// address parses the address of the key file, which is either the recipient with
+// the full shard key in its own field, or the full address of 24 bytes written
+// by account.Account.Dump.
+func (k *encryptedKeyJSON) address() (keyAddress, error) {
+	var address keyAddress
+	if k.Address != "" {
+		bytes, err := hex.DecodeString(strings.TrimPrefix(k.Address, "0x"))
+		if err != nil {
+			return address, err
+		}
+		switch len(bytes) {
+		case account.RecipientLength:
+		case account.RecipientLength + account.FullShardKeyLength:
+			fullShardKey := common.BytesToUint32(bytes[account.RecipientLength:])
+			address.fullShardKey = &fullShardKey
+		default:
+			return address, fmt.Errorf("invalid address length %d", len(bytes))
+		}
+		recipient := account.BytesToIdentityRecipient(bytes[:account.RecipientLength])
+		address.recipient = &recipient
+	}
+	if k.FullShardKey != "" {
+		bytes, err := hex.DecodeString(strings.TrimPrefix(k.FullShardKey, "0x"))
+		if err != nil {
+			return address, err
+		}
+		if len(bytes) != account.FullShardKeyLength {
+			return address, fmt.Errorf("invalid full shard key length %d", len(bytes))
+		}
+		fullShardKey := common.BytesToUint32(bytes)
+		address.fullShardKey = &fullShardKey
+	}
+	return address, nil
+}
+
+func getString(params map[string]interface{}, name string) string {
+	s, _ := params[name].(string)
+	return s
+}
+
+func getInt(params map[string]interface{}, name string) int {
+	// json numbers are decoded as float64
+	switch v := params[name].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	}
+	return 0
+}
+
+func aesCTRXOR(key, inText, iv []byte) ([]byte, error) {
+	aesBlock, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	stream := cipher.NewCTR(aesBlock, iv)
+	outText := make([]byte, len(inText))
+	stream.XORKeyStream(outText, inText)
+	return outText, nil
+}
+
+// keyFileName returns the file name of the key file in the format of
+// UTC--<created_at UTC ISO8601>--<recipient hex>.
+func keyFileName(recipient account.Recipient) string {
+	ts := time.Now().UTC()
+	return fmt.Sprintf("UTC--%s--%s", toISO8601(ts), hex.EncodeToString(recipient[:]))
+}
+
+func toISO8601(t time.Time) string {
+	var tz string
+	name, offset := t.Zone()
+	if name == "UTC" {
+		tz = "Z"
+	} else {
+		tz = fmt.Sprintf("%03d00", offset/3600)
+	}
+	return fmt.Sprintf("%04d-%02d-%02dT%02d-%02d-%02d.%09d%s",
+		t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), tz)
+}
+
+// writeKeyFile writes the key file atomically by renaming a hidden temporary file
+// in the same directory, which is skipped by the account cache.
+func writeKeyFile(file string, content []byte) error {
+	const dirPerm = 0700
+	if err := os.MkdirAll(filepath.Dir(file), dirPerm); err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(file), "."+filepath.Base(file)+".tmp")
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	f.Close()
+	return os.Rename(f.Name(), file)
+}
@@ -0,0 +1,312 @@
+// Package keystore manages the encrypted key files of the accounts of a node.
+//
+// The key files are in the web3 secret storage format, with the full shard key
+// of the account in an additional field. The key directory is rescanned in the
+// background, so that the key files copied into or removed from it are picked
+// up without restarting the node.
+package keystore
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	ErrLocked               = errors.New("account is locked")
+	ErrNoMatch              = errors.New("no key for given address or file")
+	ErrAmbiguousAddr        = errors.New("multiple key files exist for the address")
+	ErrDecrypt              = errors.New("could not decrypt key with given passphrase")
+	ErrAccountAlreadyExists = errors.New("account already exists")
+)
+
+// Account is an account whose key is stored in the key directory.
+type Account struct {
+	Address account.Address `json:"address"`
+	Path    string          `json:"path"` // path of the key file
+}
+
+type unlocked struct {
+	*Key
+	abort chan struct{}
+}
+
+// KeyStore manages the key files in the key directory, and the keys unlocked
+// for signing.
+type KeyStore struct {
+	keydir  string
+	scryptN int
+	scryptP int
+	cache   *accountCache
+
+	mu       sync.RWMutex
+	unlocked map[account.Recipient]*unlocked
+}
+
+// NewKeyStore creates a key store of the key directory, the new key files are
+// encrypted with the given scrypt parameters.
+func NewKeyStore(keydir string, scryptN, scryptP int) *KeyStore {
+	keydir, _ = filepath.Abs(keydir)
+	return &KeyStore{
+		keydir:   keydir,
+		scryptN:  scryptN,
+		scryptP:  scryptP,
+		cache:    newAccountCache(keydir),
+		unlocked: make(map[account.Recipient]*unlocked),
+	}
+}
+
+// Accounts returns all the accounts in the key directory, sorted by the path of
+// the key files.
+func (ks *KeyStore) Accounts() []Account {
+	return ks.cache.accounts()
+}
+
+// HasAddress reports whether the key of the recipient is in the key directory.
+func (ks *KeyStore) HasAddress(recipient account.Recipient) bool {
+	return ks.cache.hasAddress(recipient)
+}
+
+// Find returns the account of the recipient.
+func (ks *KeyStore) Find(recipient account.Recipient) (Account, error) {
+	return ks.cache.find(recipient)
+}
+
+// NewAccount generates a key, and stores it encrypted with the passphrase. The
+// account is on the default full shard key of the recipient if fullShardKey is
+// nil.
+func (ks *KeyStore) NewAccount(passphrase string, fullShardKey *uint32) (Account, error) {
+	key, err := newRandomKey(fullShardKey)
+	if err != nil {
+		return Account{}, err
+	}
+	return ks.storeKey(key, passphrase)
+}
+
+// ImportECDSA stores the private key encrypted with the passphrase.
+func (ks *KeyStore) ImportECDSA(privateKey *ecdsa.PrivateKey, passphrase string, fullShardKey *uint32) (Account, error) {
+	key, err := newKey(privateKey, fullShardKey)
+	if err != nil {
+		return Account{}, err
+	}
+	if ks.HasAddress(key.Address.Recipient) {
+		return Account{}, ErrAccountAlreadyExists
+	}
+	return ks.storeKey(key, passphrase)
+}
+
+// Import stores the key of the json key file decrypted with the passphrase,
+// encrypted with the new passphrase.
+func (ks *KeyStore) Import(keyJSON []byte, passphrase, newPassphrase string) (Account, error) {
+	key, err := DecryptKey(keyJSON, passphrase)
+	if err != nil {
+		return Account{}, err
+	}
+	if ks.HasAddress(key.Address.Recipient) {
+		return Account{}, ErrAccountAlreadyExists
+	}
+	return ks.storeKey(key, newPassphrase)
+}
+
+// Export returns the key of the recipient as a json key file encrypted with the
+// new passphrase.
+func (ks *KeyStore) Export(recipient account.Recipient, passphrase, newPassphrase string) ([]byte, error) {
+	_, key, err := ks.getDecryptedKey(recipient, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return EncryptKey(key, newPassphrase, ks.scryptN, ks.scryptP)
+}
+
+// Update changes the passphrase of the key of the recipient.
+func (ks *KeyStore) Update(recipient account.Recipient, passphrase, newPassphrase string) error {
+	a, key, err := ks.getDecryptedKey(recipient, passphrase)
+	if err != nil {
+		return err
+	}
+	keyJSON, err := EncryptKey(key, newPassphrase, ks.scryptN, ks.scryptP)
+	if err != nil {
+		return err
+	}
+	if err := writeKeyFile(a.Path, keyJSON); err != nil {
+		return err
+	}
+	ks.cache.add(a)
+	return nil
+}
+
+// Delete removes the key file of the recipient if the passphrase is correct.
+func (ks *KeyStore) Delete(recipient account.Recipient, passphrase string) error {
+	a, _, err := ks.getDecryptedKey(recipient, passphrase)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(a.Path); err != nil {
+		return err
+	}
+	ks.cache.delete(a.Path)
+	ks.Lock(recipient)
+	return nil
+}
+
+// Unlock unlocks the key of the recipient until the program exits or it is
+// locked.
+func (ks *KeyStore) Unlock(recipient account.Recipient, passphrase string) error {
+	return ks.TimedUnlock(recipient, passphrase, 0)
+}
+
+// TimedUnlock unlocks the key of the recipient for the duration, or until the
+// program exits or it is locked if timeout is 0. The timeout of a key unlocked
+// already is replaced.
+func (ks *KeyStore) TimedUnlock(recipient account.Recipient, passphrase string, timeout time.Duration) error {
+	_, key, err := ks.getDecryptedKey(recipient, passphrase)
+	if err != nil {
+		return err
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if u, ok := ks.unlocked[recipient]; ok && u.abort != nil {
+		close(u.abort)
+	}
+	u := &unlocked{Key: key}
+	if timeout > 0 {
+		u.abort = make(chan struct{})
+		go ks.expire(recipient, u, timeout)
+	}
+	ks.unlocked[recipient] = u
+	return nil
+}
+
+// Lock removes the key of the recipient from memory.
+func (ks *KeyStore) Lock(recipient account.Recipient) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if u, ok := ks.unlocked[recipient]; ok {
+		if u.abort != nil {
+			close(u.abort)
+		}
+		delete(ks.unlocked, recipient)
+	}
+	return nil
+}
+
+// IsUnlocked reports whether the key of the recipient is unlocked.
+func (ks *KeyStore) IsUnlocked(recipient account.Recipient) bool {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	_, ok := ks.unlocked[recipient]
+	return ok
+}
+
+func (ks *KeyStore) expire(recipient account.Recipient, u *unlocked, timeout time.Duration) {
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case <-u.abort:
+	case <-t.C:
+		ks.mu.Lock()
+		// the key may have been unlocked again with another timeout
+		if ks.unlocked[recipient] == u {
+			delete(ks.unlocked, recipient)
+		}
+		ks.mu.Unlock()
+	}
+}
+
+// SignHash signs the hash with the key of the recipient, which must be unlocked.
+func (ks *KeyStore) SignHash(recipient account.Recipient, hash []byte) ([]byte, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	u, ok := ks.unlocked[recipient]
+	if !ok {
+		return nil, ErrLocked
+	}
+	return crypto.Sign(hash, u.PrivateKey)
+}
+
+// SignHashWithPassphrase signs the hash with the key of the recipient decrypted
+// with the passphrase, without unlocking it.
+func (ks *KeyStore) SignHashWithPassphrase(recipient account.Recipient, passphrase string, hash []byte) ([]byte, error) {
+	_, key, err := ks.getDecryptedKey(recipient, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Sign(hash, key.PrivateKey)
+}
+
+// SignTx signs the transaction with the key of the recipient, which must be
+// unlocked.
+func (ks *KeyStore) SignTx(recipient account.Recipient, tx *types.EvmTransaction) (*types.EvmTransaction, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	u, ok := ks.unlocked[recipient]
+	if !ok {
+		return nil, ErrLocked
+	}
+	return types.SignTx(tx, types.MakeSigner(tx.NetworkId()), u.PrivateKey)
+}
+
+// SignTxWithPassphrase signs the transaction with the key of the recipient
+// decrypted with the passphrase, without unlocking it.
+func (ks *KeyStore) SignTxWithPassphrase(recipient account.Recipient, passphrase string,
+	tx *types.EvmTransaction) (*types.EvmTransaction, error) {
+	_, key, err := ks.getDecryptedKey(recipient, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return types.SignTx(tx, types.MakeSigner(tx.NetworkId()), key.PrivateKey)
+}
+
+// Close stops watching the key directory and locks all the keys.
+func (ks *KeyStore) Close() {
+	ks.cache.close()
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	for recipient, u := range ks.unlocked {
+		if u.abort != nil {
+			close(u.abort)
+		}
+		delete(ks.unlocked, recipient)
+	}
+}
+
+func (ks *KeyStore) getDecryptedKey(recipient account.Recipient, passphrase string) (Account, *Key, error) {
+	a, err := ks.cache.find(recipient)
+	if err != nil {
+		return a, nil, err
+	}
+	keyJSON, err := ioutil.ReadFile(a.Path)
+	if err != nil {
+		return a, nil, err
+	}
+	key, err := DecryptKey(keyJSON, passphrase)
+	if err != nil {
+		return a, nil, err
+	}
+	if key.Address.Recipient != recipient {
+		return a, nil, ErrNoMatch
+	}
+	return a, key, nil
+}
+
+func (ks *KeyStore) storeKey(key *Key, passphrase string) (Account, error) {
+	keyJSON, err := EncryptKey(key, passphrase, ks.scryptN, ks.scryptP)
+	if err != nil {
+		return Account{}, err
+	}
+	a := Account{Address: key.Address, Path: filepath.Join(ks.keydir, keyFileName(key.Address.Recipient))}
+	if err := writeKeyFile(a.Path, keyJSON); err != nil {
+		return Account{}, err
+	}
+	ks.cache.add(a)
+	return a, nil
+}
@@ -0,0 +1,161 @@
+package keystore
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func tmpKeyStore(t *testing.T) (string, *KeyStore) {
+	dir, err := ioutil.TempDir("", "keystore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, NewKeyStore(dir, LightScryptN, LightScryptP)
+}
+
+func TestKeyStore(t *testing.T) {
+	dir, ks := tmpKeyStore(t)
+	defer os.RemoveAll(dir)
+	defer ks.Close()
+
+	fullShardKey := uint32(0x00010002)
+	a, err := ks.NewAccount("foo", &fullShardKey)
+	assert.NoError(t, err)
+	assert.Equal(t, fullShardKey, a.Address.FullShardKey)
+	assert.Equal(t, dir, filepath.Dir(a.Path))
+	if stat, err := os.Stat(a.Path); assert.NoError(t, err) {
+		assert.Equal(t, os.FileMode(0600), stat.Mode().Perm())
+	}
+	assert.True(t, ks.HasAddress(a.Address.Recipient))
+	assert.Equal(t, []Account{a}, ks.Accounts())
+
+	assert.Equal(t, ErrDecrypt, ks.Update(a.Address.Recipient, "bar", "baz"))
+	assert.NoError(t, ks.Update(a.Address.Recipient, "foo", "bar"))
+	assert.NoError(t, ks.Delete(a.Address.Recipient, "bar"))
+	assert.False(t, ks.HasAddress(a.Address.Recipient))
+	assert.Empty(t, ks.Accounts())
+	_, err = os.Stat(a.Path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestKeyStoreTimedUnlock(t *testing.T) {
+	dir, ks := tmpKeyStore(t)
+	defer os.RemoveAll(dir)
+	defer ks.Close()
+
+	a, err := ks.NewAccount("foo", nil)
+	assert.NoError(t, err)
+	tx := types.NewEvmTransaction(0, a.Address.Recipient, big.NewInt(1), 21000, big.NewInt(1),
+		a.Address.FullShardKey, a.Address.FullShardKey, 3, 0, nil, 35760, 35760)
+
+	_, err = ks.SignTx(a.Address.Recipient, tx)
+	assert.Equal(t, ErrLocked, err)
+	assert.Equal(t, ErrDecrypt, ks.TimedUnlock(a.Address.Recipient, "bar", 100*time.Millisecond))
+	assert.NoError(t, ks.TimedUnlock(a.Address.Recipient, "foo", 100*time.Millisecond))
+
+	signed, err := ks.SignTx(a.Address.Recipient, tx)
+	assert.NoError(t, err)
+	sender, err := types.Sender(types.MakeSigner(signed.NetworkId()), signed)
+	assert.NoError(t, err)
+	assert.Equal(t, a.Address.Recipient, sender)
+
+	time.Sleep(250 * time.Millisecond)
+	assert.False(t, ks.IsUnlocked(a.Address.Recipient))
+	_, err = ks.SignTx(a.Address.Recipient, tx)
+	assert.Equal(t, ErrLocked, err)
+
+	// unlocking again without timeout cancels the expiry
+	assert.NoError(t, ks.TimedUnlock(a.Address.Recipient, "foo", 100*time.Millisecond))
+	assert.NoError(t, ks.Unlock(a.Address.Recipient, "foo"))
+	time.Sleep(250 * time.Millisecond)
+	assert.True(t, ks.IsUnlocked(a.Address.Recipient))
+	assert.NoError(t, ks.Lock(a.Address.Recipient))
+	assert.False(t, ks.IsUnlocked(a.Address.Recipient))
+}
+
+func TestKeyStoreImportExport(t *testing.T) {
+	dir, ks := tmpKeyStore(t)
+	defer os.RemoveAll(dir)
+	defer ks.Close()
+
+	privateKey, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	a, err := ks.ImportECDSA(privateKey, "foo", nil)
+	assert.NoError(t, err)
+	_, err = ks.ImportECDSA(privateKey, "foo", nil)
+	assert.Equal(t, ErrAccountAlreadyExists, err)
+
+	keyJSON, err := ks.Export(a.Address.Recipient, "foo", "bar")
+	assert.NoError(t, err)
+	key, err := DecryptKey(keyJSON, "bar")
+	assert.NoError(t, err)
+	assert.Equal(t, a.Address, key.Address)
+	assert.Equal(t, privateKey.D, key.PrivateKey.D)
+
+	// the exported file is a web3 key file with the full shard key besides
+	var fields map[string]interface{}
+	assert.NoError(t, json.Unmarshal(keyJSON, &fields))
+	assert.Len(t, fields["address"], 2*account.RecipientLength)
+	assert.Len(t, fields["fullShardKey"], 2*account.FullShardKeyLength)
+
+	dir2, ks2 := tmpKeyStore(t)
+	defer os.RemoveAll(dir2)
+	defer ks2.Close()
+	a2, err := ks2.Import(keyJSON, "bar", "baz")
+	assert.NoError(t, err)
+	assert.Equal(t, a.Address, a2.Address)
+	assert.NoError(t, ks2.Unlock(a2.Address.Recipient, "baz"))
+}
+
+// The key files written by account.Account.Dump are encrypted with pbkdf2, and
+// have the full address of 24 bytes.
+func TestDecryptAccountDump(t *testing.T) {
+	acc, err := account.NewAccountWithoutKey()
+	assert.NoError(t, err)
+	keyJSON, err := acc.Dump("foo", true, false, "")
+	assert.NoError(t, err)
+	key, err := DecryptKey(keyJSON, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, acc.QKCAddress, key.Address)
+	assert.Equal(t, acc.ID, key.ID)
+	assert.Equal(t, acc.PrivateKey(), hex.EncodeToString(crypto.FromECDSA(key.PrivateKey)))
+}
+
+func TestKeyStoreWatch(t *testing.T) {
+	dir, ks := tmpKeyStore(t)
+	defer os.RemoveAll(dir)
+	defer ks.Close()
+
+	key, err := newRandomKey(nil)
+	assert.NoError(t, err)
+	keyJSON, err := EncryptKey(key, "foo", LightScryptN, LightScryptP)
+	assert.NoError(t, err)
+	path := filepath.Join(dir, keyFileName(key.Address.Recipient))
+	assert.NoError(t, ioutil.WriteFile(path, keyJSON, 0600))
+	// hidden files are skipped
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, ".hidden"), keyJSON, 0600))
+
+	waitForAccounts := func(want []Account) {
+		deadline := time.Now().Add(3 * rescanInterval)
+		for time.Now().Before(deadline) && len(ks.Accounts()) != len(want) {
+			time.Sleep(50 * time.Millisecond)
+		}
+		assert.Equal(t, want, ks.Accounts())
+	}
+	waitForAccounts([]Account{{Address: key.Address, Path: path}})
+	assert.NoError(t, ks.Unlock(key.Address.Recipient, "foo"))
+
+	assert.NoError(t, os.Remove(path))
+	waitForAccounts([]Account{})
+}
@@ -0,0 +1,255 @@
+package account
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// TextHash returns the hash of the message signed by personal_sign, which is
+// prefixed as in Ethereum so that the wallets of the dApps sign it in the same
+// way, and can not be a transaction.
+//
+//	keccak256("\x19Ethereum Signed Message:\n" + len(message) + message)
+func TextHash(data []byte) []byte {
+	msg := fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(data), data)
+	return crypto.Keccak256([]byte(msg))
+}
+
+// TypedDataField is a field of a struct type of the typed data.
+type TypedDataField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// TypedData is the structured data signed by EIP-712. The values of the address
+// type are the hex of either a recipient or a QuarkChain address, whose
+// recipient is signed.
+type TypedData struct {
+	Types       map[string][]TypedDataField `json:"types"`
+	PrimaryType string                      `json:"primaryType"`
+	Domain      map[string]interface{}      `json:"domain"`
+	Message     map[string]interface{}      `json:"message"`
+}
+
+// domainType is the struct type of the domain separating the typed data of the
+// dApps.
+const domainType = "EIP712Domain"
+
+var (
+	typedArrayRegexp = regexp.MustCompile(`^(.+)\[([0-9]*)\]$`)
+	typedIntRegexp   = regexp.MustCompile(`^(u?)int([0-9]*)$`)
+	typedBytesRegexp = regexp.MustCompile(`^bytes([0-9]+)$`)
+)
+
+// Hash returns the hash of the typed data signed by EIP-712.
+//
+//	keccak256("\x19\x01" + hashStruct(domain) + hashStruct(message))
+func (td *TypedData) Hash() ([]byte, error) {
+	if _, ok := td.Types[domainType]; !ok {
+		return nil, fmt.Errorf("missing type %s", domainType)
+	}
+	domainHash, err := td.hashStruct(domainType, td.Domain)
+	if err != nil {
+		return nil, fmt.Errorf("domain: %v", err)
+	}
+	messageHash, err := td.hashStruct(td.PrimaryType, td.Message)
+	if err != nil {
+		return nil, fmt.Errorf("message: %v", err)
+	}
+	return crypto.Keccak256([]byte("\x19\x01"), domainHash, messageHash), nil
+}
+
+func (td *TypedData) hashStruct(typ string, data map[string]interface{}) ([]byte, error) {
+	fields, ok := td.Types[typ]
+	if !ok {
+		return nil, fmt.Errorf("unknown type %s", typ)
+	}
+	encoded := [][]byte{td.typeHash(typ)}
+	for _, field := range fields {
+		value, ok := data[field.Name]
+		if !ok {
+			return nil, fmt.Errorf("missing field %s of %s", field.Name, typ)
+		}
+		enc, err := td.encodeValue(field.Type, value)
+		if err != nil {
+			return nil, fmt.Errorf("field %s of %s: %v", field.Name, typ, err)
+		}
+		encoded = append(encoded, enc)
+	}
+	return crypto.Keccak256(encoded...), nil
+}
+
+// typeHash returns the hash of the encoding of the struct type, which is the
+// type followed by the struct types it references sorted by name.
+func (td *TypedData) typeHash(typ string) []byte {
+	deps := make(map[string]bool)
+	td.dependencies(typ, deps)
+	delete(deps, typ)
+	sorted := make([]string, 0, len(deps))
+	for dep := range deps {
+		sorted = append(sorted, dep)
+	}
+	sort.Strings(sorted)
+
+	var buf bytes.Buffer
+	for _, t := range append([]string{typ}, sorted...) {
+		fields := make([]string, len(td.Types[t]))
+		for i, field := range td.Types[t] {
+			fields[i] = field.Type + " " + field.Name
+		}
+		buf.WriteString(t + "(" + strings.Join(fields, ",") + ")")
+	}
+	return crypto.Keccak256(buf.Bytes())
+}
+
+func (td *TypedData) dependencies(typ string, deps map[string]bool) {
+	for m := typedArrayRegexp.FindStringSubmatch(typ); m != nil; m = typedArrayRegexp.FindStringSubmatch(typ) {
+		typ = m[1]
+	}
+	if _, ok := td.Types[typ]; !ok || deps[typ] {
+		return
+	}
+	deps[typ] = true
+	for _, field := range td.Types[typ] {
+		td.dependencies(field.Type, deps)
+	}
+}
+
+// encodeValue returns the 32 bytes encoding the value of the type.
+func (td *TypedData) encodeValue(typ string, value interface{}) ([]byte, error) {
+	if m := typedArrayRegexp.FindStringSubmatch(typ); m != nil {
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%v is not an array", value)
+		}
+		if m[2] != "" {
+			if n, err := strconv.Atoi(m[2]); err != nil || n != len(items) {
+				return nil, fmt.Errorf("array of %d items is not %s", len(items), typ)
+			}
+		}
+		encoded := make([][]byte, len(items))
+		for i, item := range items {
+			enc, err := td.encodeValue(m[1], item)
+			if err != nil {
+				return nil, fmt.Errorf("item %d: %v", i, err)
+			}
+			encoded[i] = enc
+		}
+		return crypto.Keccak256(encoded...), nil
+	}
+	if _, ok := td.Types[typ]; ok {
+		data, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%v is not a struct", value)
+		}
+		return td.hashStruct(typ, data)
+	}
+
+	switch typ {
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%v is not a string", value)
+		}
+		return crypto.Keccak256([]byte(str)), nil
+	case "bytes":
+		b, err := typedBytes(value)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.Keccak256(b), nil
+	case "bool":
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%v is not a bool", value)
+		}
+		if b {
+			return math.PaddedBigBytes(big.NewInt(1), 32), nil
+		}
+		return make([]byte, 32), nil
+	case "address":
+		b, err := typedBytes(value)
+		if err != nil {
+			return nil, err
+		}
+		// a QuarkChain address is the recipient followed by the full shard key
+		if len(b) != RecipientLength && len(b) != RecipientLength+FullShardKeyLength {
+			return nil, fmt.Errorf("invalid address %v", value)
+		}
+		return append(make([]byte, 32-RecipientLength), b[:RecipientLength]...), nil
+	}
+	if m := typedBytesRegexp.FindStringSubmatch(typ); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		if n < 1 || n > 32 {
+			return nil, fmt.Errorf("invalid type %s", typ)
+		}
+		b, err := typedBytes(value)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) != n {
+			return nil, fmt.Errorf("%d bytes are not %s", len(b), typ)
+		}
+		return append(b, make([]byte, 32-n)...), nil
+	}
+	if m := typedIntRegexp.FindStringSubmatch(typ); m != nil {
+		bits := 256
+		if m[2] != "" {
+			bits, _ = strconv.Atoi(m[2])
+		}
+		if bits < 8 || bits > 256 || bits%8 != 0 {
+			return nil, fmt.Errorf("invalid type %s", typ)
+		}
+		n, err := typedInt(value)
+		if err != nil {
+			return nil, err
+		}
+		if m[1] == "u" {
+			if n.Sign() < 0 || n.BitLen() > bits {
+				return nil, fmt.Errorf("%v overflows %s", n, typ)
+			}
+		} else if limit := new(big.Int).Lsh(big.NewInt(1), uint(bits-1)); n.Cmp(limit) >= 0 || n.Cmp(limit.Neg(limit)) < 0 {
+			return nil, fmt.Errorf("%v overflows %s", n, typ)
+		}
+		return math.PaddedBigBytes(math.U256(n), 32), nil
+	}
+	return nil, fmt.Errorf("unknown type %s", typ)
+}
+
+func typedBytes(value interface{}) ([]byte, error) {
+	str, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("%v is not hex", value)
+	}
+	return hexutil.Decode(str)
+}
+
+// typedInt parses the integer given as a JSON number, or a decimal or hex
+// string for the integers too large for JSON.
+func typedInt(value interface{}) (*big.Int, error) {
+	switch v := value.(type) {
+	case float64:
+		n, accuracy := big.NewFloat(v).Int(nil)
+		if accuracy != big.Exact {
+			return nil, fmt.Errorf("%v is not an integer", v)
+		}
+		return n, nil
+	case json.Number:
+		return typedInt(v.String())
+	case string:
+		if n, ok := math.ParseBig256(v); ok {
+			return n, nil
+		}
+	}
+	return nil, fmt.Errorf("%v is not an integer", value)
+}
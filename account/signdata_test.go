@@ -0,0 +1,110 @@
+package account
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTextHash(t *testing.T) {
+	hash := TextHash([]byte("Hello Joe"))
+	assert.Equal(t, "0xa080337ae51c4e064c189e113edd0ba391df9206e2f49db658bb32cf2911730b", hexutil.Encode(hash))
+}
+
+// the example of EIP-712
+const mailTypedData = `{
+	"types": {
+		"EIP712Domain": [
+			{"name": "name", "type": "string"},
+			{"name": "version", "type": "string"},
+			{"name": "chainId", "type": "uint256"},
+			{"name": "verifyingContract", "type": "address"}
+		],
+		"Person": [
+			{"name": "name", "type": "string"},
+			{"name": "wallet", "type": "address"}
+		],
+		"Mail": [
+			{"name": "from", "type": "Person"},
+			{"name": "to", "type": "Person"},
+			{"name": "contents", "type": "string"}
+		]
+	},
+	"primaryType": "Mail",
+	"domain": {
+		"name": "Ether Mail",
+		"version": "1",
+		"chainId": 1,
+		"verifyingContract": "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"
+	},
+	"message": {
+		"from": {"name": "Cow", "wallet": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"},
+		"to": {"name": "Bob", "wallet": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"},
+		"contents": "Hello, Bob!"
+	}
+}`
+
+func TestTypedDataHash(t *testing.T) {
+	var td TypedData
+	assert.NoError(t, json.Unmarshal([]byte(mailTypedData), &td))
+	assert.Equal(t, "0xa0cedeb2dc280ba39b857546d74f5549c3a1d7bdc2dd96bf881f76108e23dac2",
+		hexutil.Encode(td.typeHash("Mail")))
+	hash, err := td.Hash()
+	assert.NoError(t, err)
+	assert.Equal(t, "0xbe609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2", hexutil.Encode(hash))
+
+	// the signature of the example recovers the key of "cow"
+	sig := hexutil.MustDecode("0x4355c47d63924e8a72e509b65029052eb6c299d53a04e167c5775fd466751c9d" +
+		"07299936d304c153f6443dfa05f40ff007d72911b6f72307f996231605b9156201")
+	pubKey, err := crypto.SigToPub(hash, sig)
+	assert.NoError(t, err)
+	assert.Equal(t, crypto.PubkeyToAddress(crypto.ToECDSAUnsafe(crypto.Keccak256([]byte("cow"))).PublicKey),
+		crypto.PubkeyToAddress(*pubKey))
+
+	// the full shard key of a QuarkChain address is not signed
+	td.Message["to"].(map[string]interface{})["wallet"] = "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB00010001"
+	qkcHash, err := td.Hash()
+	assert.NoError(t, err)
+	assert.Equal(t, hash, qkcHash)
+
+	td.Message["contents"] = 1
+	_, err = td.Hash()
+	assert.Error(t, err)
+	delete(td.Message, "contents")
+	_, err = td.Hash()
+	assert.Error(t, err)
+}
+
+func TestTypedDataValues(t *testing.T) {
+	td := &TypedData{Types: map[string][]TypedDataField{}}
+	for _, test := range []struct {
+		typ   string
+		value interface{}
+		ok    bool
+	}{
+		{"uint8", float64(255), true},
+		{"uint8", float64(256), false},
+		{"uint8", float64(-1), false},
+		{"int8", float64(-128), true},
+		{"int8", float64(128), false},
+		{"uint256", "0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", true},
+		{"uint256", "1.5", false},
+		{"uint7", float64(1), false},
+		{"bytes4", "0x01020304", true},
+		{"bytes4", "0x010203", false},
+		{"bytes33", "0x01", false},
+		{"bool", true, true},
+		{"bool", "true", false},
+		{"address", "0x01", false},
+		{"uint8[2]", []interface{}{float64(1), float64(2)}, true},
+		{"uint8[2]", []interface{}{float64(1)}, false},
+		{"uint8[]", []interface{}{}, true},
+		{"Unknown", nil, false},
+	} {
+		_, err := td.encodeValue(test.typ, test.value)
+		assert.Equal(t, test.ok, err == nil, "%s %v: %v", test.typ, test.value, err)
+	}
+}
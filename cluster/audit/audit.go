@@ -0,0 +1,157 @@
+// Package audit records the critical actions of the cluster, the admin API
+// calls, the slave registrations, the deep reorgs, the config reloads and the
+// peer bans, in an append-only log for the analysis after an incident.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// The types of the audited actions.
+const (
+	TypeAdmin  = "admin"  // call of an admin API
+	TypeSlave  = "slave"  // slave registered, down, restarted or moved
+	TypeReorg  = "reorg"  // reorg of the root chain deeper than AUDIT_REORG_DEPTH
+	TypeConfig = "config" // config reload
+	TypeBan    = "ban"    // peer banned or unbanned
+)
+
+// recentEntries is the number of the last entries kept in memory for the
+// queries.
+const recentEntries = 1024
+
+// Entry is an audited action.
+type Entry struct {
+	Time   time.Time              `json:"time"`
+	Type   string                 `json:"type"`
+	Action string                 `json:"action"`
+	Detail map[string]interface{} `json:"detail,omitempty"`
+	Error  string                 `json:"error,omitempty"`
+}
+
+// Log appends the entries to a file as JSON lines and keeps the last ones in
+// memory. A nil Log records nothing.
+type Log struct {
+	mu     sync.Mutex
+	file   *os.File // nil if the entries are kept in memory only
+	recent []Entry  // ring of the last entries
+	next   int      // index of the oldest entry once the ring is full
+}
+
+// Open opens the audit log at path, with the last entries already in the
+// file, or an audit log kept in memory only if path is empty.
+func Open(path string) (*Log, error) {
+	l := &Log{recent: make([]Entry, 0, recentEntries)}
+	if path == "" {
+		return l, nil
+	}
+	cut, err := l.load(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	// end the last line cut by a crash, so that the next entry is readable
+	if cut {
+		if _, err := file.Write([]byte{'\n'}); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	l.file = file
+	return l, nil
+}
+
+// load reads the last entries of the file, skipping the lines which are not
+// entries, and reports whether its last line is cut by a crash.
+func (l *Log) load(path string) (bool, error) {
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(blob))
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		l.add(entry)
+	}
+	return len(blob) > 0 && blob[len(blob)-1] != '\n', scanner.Err()
+}
+
+func (l *Log) add(entry Entry) {
+	if len(l.recent) < recentEntries {
+		l.recent = append(l.recent, entry)
+		return
+	}
+	l.recent[l.next] = entry
+	l.next = (l.next + 1) % recentEntries
+}
+
+// Record records the action of the type with its detail, and the error it
+// failed with if not nil.
+func (l *Log) Record(typ, action string, detail map[string]interface{}, err error) error {
+	if l == nil {
+		return nil
+	}
+	entry := Entry{Time: time.Now(), Type: typ, Action: action, Detail: detail}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	blob, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.add(entry)
+	if l.file == nil {
+		return nil
+	}
+	_, err = l.file.Write(append(blob, '\n'))
+	return err
+}
+
+// Recent returns the last count entries of the type, or of all the types if
+// typ is empty, the oldest first.
+func (l *Log) Recent(count int, typ string) []Entry {
+	entries := make([]Entry, 0)
+	if l == nil {
+		return entries
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := len(l.recent) - 1; i >= 0 && len(entries) < count; i-- {
+		entry := l.recent[(l.next+i)%len(l.recent)]
+		if typ == "" || entry.Type == typ {
+			entries = append(entries, entry)
+		}
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries
+}
+
+// Close closes the file of the audit log.
+func (l *Log) Close() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file != nil {
+		l.file.Close()
+		l.file = nil
+	}
+}
@@ -0,0 +1,78 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecent(t *testing.T) {
+	l, err := Open("")
+	assert.NoError(t, err)
+	defer l.Close()
+
+	assert.Empty(t, l.Recent(10, ""))
+	for i := 0; i < recentEntries+10; i++ {
+		typ := TypeAdmin
+		if i%2 == 1 {
+			typ = TypeBan
+		}
+		assert.NoError(t, l.Record(typ, fmt.Sprintf("action%d", i), nil, nil))
+	}
+
+	entries := l.Recent(3, "")
+	assert.Len(t, entries, 3)
+	for i, entry := range entries {
+		assert.Equal(t, fmt.Sprintf("action%d", recentEntries+7+i), entry.Action)
+	}
+	entries = l.Recent(2, TypeAdmin)
+	assert.Equal(t, "action1030", entries[0].Action)
+	assert.Equal(t, "action1032", entries[1].Action)
+	assert.Len(t, l.Recent(2*recentEntries, ""), recentEntries)
+	assert.Len(t, l.Recent(2*recentEntries, TypeBan), recentEntries/2)
+
+	var nilLog *Log
+	assert.NoError(t, nilLog.Record(TypeAdmin, "addPeer", nil, nil))
+	assert.Empty(t, nilLog.Recent(10, ""))
+}
+
+func TestReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	l, err := Open(path)
+	assert.NoError(t, err)
+	assert.NoError(t, l.Record(TypeConfig, "reload", map[string]interface{}{"changes": 1}, nil))
+	assert.NoError(t, l.Record(TypeAdmin, "banPeer", map[string]interface{}{"node": "ab"}, errors.New("failed")))
+	l.Close()
+
+	// a line cut by a crash is skipped
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	assert.NoError(t, err)
+	file.WriteString(`{"time":"2020-01-01T00:00:00Z","ty`)
+	file.Close()
+
+	l, err = Open(path)
+	assert.NoError(t, err)
+	defer l.Close()
+	entries := l.Recent(10, "")
+	assert.Len(t, entries, 2)
+	assert.Equal(t, TypeConfig, entries[0].Type)
+	assert.Equal(t, float64(1), entries[0].Detail["changes"])
+	assert.Equal(t, "banPeer", entries[1].Action)
+	assert.Equal(t, "failed", entries[1].Error)
+
+	assert.NoError(t, l.Record(TypeSlave, "registered", nil, nil))
+	blob, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, strings.Count(string(blob), "\n"))
+	assert.Len(t, l.Recent(10, ""), 3)
+}
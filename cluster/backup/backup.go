@@ -0,0 +1,391 @@
+// Package backup writes a consistent snapshot of a shard database to a tar
+// archive, and restores a shard database from such an archive, to rebuild a
+// slave faster than syncing it from the peers.
+//
+// The archive holds, in order, a metadata.json entry describing the shard and
+// the head block of the backup, the key-value pairs of the database in db/
+// entries, the frozen blocks of the ancient store in ancient/ entries, and a
+// footer.json entry with the number of the pairs, which tells a truncated
+// archive apart. The archive is gzipped if its file name ends with .gz.
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/core/rawdb"
+	"github.com/QuarkChain/goquarkchain/qkcdb"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// Version is the version of the archive format.
+const Version = 1
+
+const (
+	metadataName  = "metadata.json"
+	footerName    = "footer.json"
+	dbPrefix      = "db/"
+	ancientPrefix = "ancient/"
+
+	// chunkSize is the size the entries of the pairs and the frozen blocks
+	// are cut at, as a tar entry has to be buffered to know its size.
+	chunkSize = 16 * 1024 * 1024
+)
+
+// Metadata describes the shard database backed up in an archive.
+type Metadata struct {
+	Version     uint32      `json:"version"`
+	FullShardID uint32      `json:"fullShardId"`
+	Height      uint64      `json:"height"`     // height of the head block
+	Hash        common.Hash `json:"hash"`       // hash of the head block
+	RootHeight  uint32      `json:"rootHeight"` // height of the root block the shard was at
+	RootHash    common.Hash `json:"rootHash"`
+	Ancients    uint64      `json:"ancients"` // number of the frozen blocks
+	Time        int64       `json:"time"`     // unix time of the backup
+}
+
+type footer struct {
+	Entries uint64 `json:"entries"`
+}
+
+// Backup writes the archive of a snapshot of db to w. The FullShardID of meta
+// has to be set, the other fields are set from the snapshot. The root block
+// is the one of meta if set, e.g. the root tip of the running shard, or else
+// the previous root block of the head block, it has to be known to db.
+func Backup(w io.Writer, db ethdb.Database, meta *Metadata) error {
+	kvdb, ok := rawdb.KeyValueStore(db).(qkcdb.KeyValueStore)
+	if !ok {
+		return errors.New("only support qkcdb now")
+	}
+	snap, err := kvdb.NewSnapshot()
+	if err != nil {
+		return err
+	}
+	defer snap.Release()
+	// the blocks frozen before the snapshot are deleted from it, the ones
+	// frozen since are in both
+	if ancients, ok := db.(rawdb.AncientReader); ok {
+		if meta.Ancients, err = ancients.Ancients(); err != nil {
+			return err
+		}
+	}
+	head := rawdb.ReadMinorBlockHeader(snap, rawdb.ReadHeadBlockHash(snap))
+	if head == nil {
+		return errors.New("head block not found")
+	}
+	if meta.RootHash == (common.Hash{}) {
+		meta.RootHash = head.PrevRootBlockHash
+	}
+	rootHeader := rawdb.ReadRootBlockHeader(snap, meta.RootHash)
+	if rootHeader == nil {
+		return fmt.Errorf("root block %x not found", meta.RootHash)
+	}
+	meta.Version = Version
+	meta.Height, meta.Hash = head.Number, head.Hash()
+	meta.RootHeight = rootHeader.Number
+	meta.Time = time.Now().Unix()
+
+	tw := tar.NewWriter(w)
+	if err := writeJSON(tw, metadataName, meta); err != nil {
+		return err
+	}
+	chunks := &chunkWriter{tw: tw, prefix: dbPrefix}
+	entries := uint64(0)
+	it := snap.NewIterator()
+	defer it.Close()
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		if err := chunks.add(it.Key(), it.Value()); err != nil {
+			return err
+		}
+		entries++
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	if err := chunks.flush(); err != nil {
+		return err
+	}
+	chunks = &chunkWriter{tw: tw, prefix: ancientPrefix}
+	for number := uint64(0); number < meta.Ancients; number++ {
+		hash, header, body, receipts, err := rawdb.ReadAncientBlock(db, number)
+		if err != nil {
+			return fmt.Errorf("failed to read frozen block %d: %v", number, err)
+		}
+		if err := chunks.add(hash, header, body, receipts); err != nil {
+			return err
+		}
+	}
+	if err := chunks.flush(); err != nil {
+		return err
+	}
+	if err := writeJSON(tw, footerName, &footer{Entries: entries}); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// BackupFile writes the archive of a snapshot of db to file, see Backup. The
+// file is only created once the archive is complete.
+func BackupFile(file string, db ethdb.Database, meta *Metadata) (err error) {
+	tmp := file + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		f.Close()
+		if err != nil {
+			os.Remove(tmp)
+		}
+	}()
+	bw := bufio.NewWriter(f)
+	var w io.Writer = bw
+	var zw *gzip.Writer
+	if strings.HasSuffix(file, ".gz") {
+		zw = gzip.NewWriter(bw)
+		w = zw
+	}
+	if err = Backup(w, db, meta); err != nil {
+		return err
+	}
+	if zw != nil {
+		if err = zw.Close(); err != nil {
+			return err
+		}
+	}
+	if err = bw.Flush(); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// Restore writes the pairs and the frozen blocks of the archive read from r to
+// db, which has to be empty, and records the root block of the backup for the
+// shard to resync from. It returns the metadata of the archive.
+func Restore(r io.Reader, db ethdb.Database) (*Metadata, error) {
+	kvdb, ok := rawdb.KeyValueStore(db).(qkcdb.KeyValueStore)
+	if !ok {
+		return nil, errors.New("only support qkcdb now")
+	}
+	it := kvdb.NewIterator()
+	it.SeekToFirst()
+	empty := !it.Valid()
+	it.Close()
+	if !empty {
+		return nil, errors.New("database is not empty")
+	}
+
+	tr := tar.NewReader(r)
+	meta, err := readMetadata(tr)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		batch    = db.NewBatch()
+		entries  uint64
+		ancients uint64
+		end      *footer
+	)
+	for end == nil {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, errors.New("truncated backup archive")
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case strings.HasPrefix(hdr.Name, dbPrefix):
+			err = readChunk(tr, 2, func(items [][]byte) error {
+				entries++
+				if err := batch.Put(items[0], items[1]); err != nil {
+					return err
+				}
+				if batch.ValueSize() >= ethdb.IdealBatchSize {
+					if err := batch.Write(); err != nil {
+						return err
+					}
+					batch = db.NewBatch()
+				}
+				return nil
+			})
+			if err == nil {
+				err = batch.Write()
+				batch = db.NewBatch()
+			}
+		case strings.HasPrefix(hdr.Name, ancientPrefix):
+			err = readChunk(tr, 4, func(items [][]byte) error {
+				if err := rawdb.AppendAncientBlock(db, ancients, items[0], items[1], items[2], items[3]); err != nil {
+					return fmt.Errorf("failed to restore frozen block %d: %v", ancients, err)
+				}
+				ancients++
+				return nil
+			})
+		case hdr.Name == footerName:
+			end = new(footer)
+			err = json.NewDecoder(tr).Decode(end)
+		default:
+			err = fmt.Errorf("unknown backup archive entry %s", hdr.Name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if entries != end.Entries || ancients != meta.Ancients {
+		return nil, fmt.Errorf("backup archive has %d pairs and %d frozen blocks, expected %d and %d",
+			entries, ancients, end.Entries, meta.Ancients)
+	}
+	if ancients > 0 {
+		if err := rawdb.SyncAncients(db); err != nil {
+			return nil, err
+		}
+	}
+	rawdb.WriteRestoredRootHash(db, meta.RootHash)
+	return meta, nil
+}
+
+// RestoreFile restores the archive in file to db, see Restore.
+func RestoreFile(file string, db ethdb.Database) (*Metadata, error) {
+	r, closer, err := openFile(file)
+	if err != nil {
+		return nil, err
+	}
+	defer closer()
+	return Restore(r, db)
+}
+
+// ReadMetadata returns the metadata of the archive in file.
+func ReadMetadata(file string) (*Metadata, error) {
+	r, closer, err := openFile(file)
+	if err != nil {
+		return nil, err
+	}
+	defer closer()
+	return readMetadata(tar.NewReader(r))
+}
+
+func openFile(file string) (io.Reader, func(), error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	var r io.Reader = bufio.NewReader(f)
+	if strings.HasSuffix(file, ".gz") {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		r = zr
+	}
+	return r, func() { f.Close() }, nil
+}
+
+func readMetadata(tr *tar.Reader) (*Metadata, error) {
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("invalid backup archive: %v", err)
+	}
+	if hdr.Name != metadataName {
+		return nil, errors.New("invalid backup archive: metadata missing")
+	}
+	meta := new(Metadata)
+	if err := json.NewDecoder(tr).Decode(meta); err != nil {
+		return nil, fmt.Errorf("invalid backup archive: %v", err)
+	}
+	if meta.Version != Version {
+		return nil, fmt.Errorf("unsupported backup archive version %d", meta.Version)
+	}
+	return meta, nil
+}
+
+func writeJSON(tw *tar.Writer, name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeEntry(tw, name, data)
+}
+
+func writeEntry(tw *tar.Writer, name string, data []byte) error {
+	hdr := &tar.Header{Name: name, Mode: 0600, Size: int64(len(data)), ModTime: time.Now()}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// chunkWriter writes the items added to it, prefixed with their lengths, to
+// the numbered entries of a tar archive cut at chunkSize.
+type chunkWriter struct {
+	tw     *tar.Writer
+	prefix string
+	buf    bytes.Buffer
+	count  int
+}
+
+func (c *chunkWriter) add(items ...[]byte) error {
+	var size [binary.MaxVarintLen64]byte
+	for _, item := range items {
+		c.buf.Write(size[:binary.PutUvarint(size[:], uint64(len(item)))])
+		c.buf.Write(item)
+	}
+	if c.buf.Len() >= chunkSize {
+		return c.flush()
+	}
+	return nil
+}
+
+func (c *chunkWriter) flush() error {
+	if c.buf.Len() == 0 {
+		return nil
+	}
+	if err := writeEntry(c.tw, fmt.Sprintf("%s%06d", c.prefix, c.count), c.buf.Bytes()); err != nil {
+		return err
+	}
+	c.buf.Reset()
+	c.count++
+	return nil
+}
+
+// readChunk reads the groups of n items of a chunk written by chunkWriter.
+func readChunk(r io.Reader, n int, fn func(items [][]byte) error) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	items := make([][]byte, n)
+	for len(data) > 0 {
+		for i := range items {
+			size, read := binary.Uvarint(data)
+			if read <= 0 || uint64(len(data)-read) < size {
+				return errors.New("corrupted backup archive chunk")
+			}
+			items[i] = data[read : read+int(size)]
+			data = data[read+int(size):]
+		}
+		if err := fn(items); err != nil {
+			return err
+		}
+	}
+	return nil
+}
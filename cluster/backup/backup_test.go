@@ -0,0 +1,99 @@
+package backup
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/core/rawdb"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/qkcdb"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestDB(t *testing.T, dir string) ethdb.Database {
+	kvdb, err := qkcdb.NewLDBDatabase(filepath.Join(dir, "db"), true, false)
+	assert.NoError(t, err)
+	db, err := rawdb.NewDatabaseWithFreezer(kvdb, filepath.Join(dir, "ancient"))
+	assert.NoError(t, err)
+	return db
+}
+
+func TestBackupRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	db := newTestDB(t, filepath.Join(dir, "src"))
+	defer db.Close()
+	rootHeader := &types.RootBlockHeader{Number: 5}
+	rawdb.WriteRootBlockHeader(db, rootHeader)
+	blocks := make([]*types.MinorBlock, 3)
+	for i := range blocks {
+		header := &types.MinorBlockHeader{Number: uint64(i), PrevRootBlockHash: rootHeader.Hash()}
+		blocks[i] = types.NewMinorBlockWithHeader(header, &types.MinorBlockMeta{})
+		rawdb.WriteMinorBlock(db, blocks[i])
+		rawdb.WriteCanonicalHash(db, rawdb.ChainTypeMinor, blocks[i].Hash(), uint64(i))
+		rawdb.WriteReceipts(db, blocks[i].Hash(), types.Receipts{{CumulativeGasUsed: uint64(i + 1)}})
+	}
+	rawdb.WriteHeadBlockHash(db, blocks[2].Hash())
+	assert.NoError(t, rawdb.FreezeMinorBlocks(db, 0))
+
+	file := filepath.Join(dir, "shard.tar.gz")
+	assert.NoError(t, BackupFile(file, db, &Metadata{FullShardID: 1}))
+	meta, err := ReadMetadata(file)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(1), meta.FullShardID)
+	assert.Equal(t, uint64(2), meta.Height)
+	assert.Equal(t, blocks[2].Hash(), meta.Hash)
+	assert.Equal(t, uint32(5), meta.RootHeight)
+	assert.Equal(t, rootHeader.Hash(), meta.RootHash)
+	assert.Equal(t, uint64(1), meta.Ancients)
+
+	restored := newTestDB(t, filepath.Join(dir, "dst"))
+	defer restored.Close()
+	_, err = RestoreFile(file, restored)
+	assert.NoError(t, err)
+	for i, block := range blocks {
+		// the first block is restored to the ancient store
+		assert.Equal(t, block.Hash(), rawdb.ReadMinorBlock(restored, block.Hash()).Hash())
+		assert.Equal(t, uint64(i+1), rawdb.ReadReceipts(restored, block.Hash())[0].CumulativeGasUsed)
+	}
+	frozen, err := restored.(rawdb.AncientReader).Ancients()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), frozen)
+	assert.Equal(t, blocks[2].Hash(), rawdb.ReadHeadBlockHash(restored))
+	assert.Equal(t, rootHeader.Hash(), rawdb.ReadRestoredRootHash(restored))
+
+	// only an empty database is restored to
+	_, err = RestoreFile(file, restored)
+	assert.Error(t, err)
+}
+
+func TestRestoreTruncated(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	db := newTestDB(t, filepath.Join(dir, "src"))
+	defer db.Close()
+	rootHeader := &types.RootBlockHeader{Number: 1}
+	rawdb.WriteRootBlockHeader(db, rootHeader)
+	block := types.NewMinorBlockWithHeader(&types.MinorBlockHeader{PrevRootBlockHash: rootHeader.Hash()}, &types.MinorBlockMeta{})
+	rawdb.WriteMinorBlock(db, block)
+	rawdb.WriteHeadBlockHash(db, block.Hash())
+
+	var buf bytes.Buffer
+	assert.NoError(t, Backup(&buf, db, &Metadata{}))
+	// cut the archive before its footer
+	data := buf.Bytes()
+	data = data[:bytes.Index(data, []byte(footerName))]
+
+	restored := newTestDB(t, filepath.Join(dir, "dst"))
+	defer restored.Close()
+	_, err = Restore(bytes.NewReader(data), restored)
+	assert.Error(t, err)
+}
@@ -25,6 +25,12 @@ type ChainConfig struct {
 	DifficultyAdjustmentFactor     uint32      `json:"DIFFICULTY_ADJUSTMENT_FACTOR"`
 	ExtraShardBlocksInRootBlock    uint32      `json:"EXTRA_SHARD_BLOCKS_IN_ROOT_BLOCK"`
 	PoswConfig                     *POSWConfig `json:"POSW_CONFIG"`
+	EvmIstanbulTimeStamp           uint64      `json:"EVM_ISTANBUL_TIMESTAMP"` // Istanbul EVM rules from this block timestamp on, 0 to disable
+	EvmBerlinTimeStamp             uint64      `json:"EVM_BERLIN_TIMESTAMP"`   // Berlin EVM rules, including Istanbul ones, from this block timestamp on, 0 to disable
+	BlockLimitsTimeStamp           uint64      `json:"BLOCK_LIMITS_TIMESTAMP"` // gas limit voted by the miners and the limits below from this block timestamp on, 0 to disable
+	GasLimitTarget                 uint64      `json:"GAS_LIMIT_TARGET"`       // gas limit the local miners vote for, the genesis one if 0
+	BlockSizeLimit                 uint32      `json:"BLOCK_SIZE_LIMIT"`       // bytes of the serialized txs of a block, 0 for no limit
+	BlockTxCountLimit              uint32      `json:"BLOCK_TX_COUNT_LIMIT"`   // txs of a block, 0 for no limit
 }
 
 func NewChainConfig() *ChainConfig {
@@ -11,7 +11,11 @@ import (
 	"github.com/QuarkChain/goquarkchain/account"
 	"github.com/QuarkChain/goquarkchain/common"
 	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/internal/debug"
+	"github.com/QuarkChain/goquarkchain/params"
 	ethcom "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/nat"
 )
 
 var (
@@ -26,7 +30,9 @@ type ClusterConfig struct {
 	PrivateJSONRPCHOST       string            `json:"PRIVATE_JSON_RPC_HOST"`
 	EnableTransactionHistory bool              `json:"ENABLE_TRANSACTION_HISTORY"`
 	DbPathRoot               string            `json:"DB_PATH_ROOT"`
+	DBBackend                string            `json:"DB_BACKEND"` // storage backend of the databases, rocksdb, leveldb or pebble
 	LogLevel                 string            `json:"LOG_LEVEL"`
+	LogModules               map[string]string `json:"LOG_MODULES,omitempty"` // log level of the modules logging apart from LOG_LEVEL, e.g. {"p2p": "debug"}
 	StartSimulatedMining     bool              `json:"START_SIMULATED_MINING"`
 	Clean                    bool              `json:"CLEAN"`
 	GenesisDir               string            `json:"GENESIS_DIR"`
@@ -36,6 +42,34 @@ type ClusterConfig struct {
 	SimpleNetwork            *SimpleNetwork    `json:"SIMPLE_NETWORK,omitempty"`
 	P2P                      *P2PConfig        `json:"P2P,omitempty"`
 	Monitoring               *MonitoringConfig `json:"MONITORING"`
+	State                    *StateConfig      `json:"STATE"`
+	TxPool                   *TxPoolConfig     `json:"TX_POOL"`
+	TxJournal                string            `json:"TX_JOURNAL"`               // local tx journal file of each shard, disabled if empty
+	XShardJournal            string            `json:"XSHARD_JOURNAL"`           // journal file of the cross-shard tx lists received by each shard, disabled if empty
+	TxIndexRetention         uint64            `json:"TX_INDEX_RETENTION"`       // number of latest minor blocks with transactions indexed, 0 for all
+	AncientRootBlocks        uint64            `json:"ANCIENT_ROOT_BLOCKS"`      // freeze minor blocks confirmed by root blocks older than this, 0 to disable
+	ParallelTxWorkers        int               `json:"PARALLEL_TX_WORKERS"`      // workers executing the txs of a minor block in parallel, 0 or 1 to execute serially
+	KeyStoreDir              string            `json:"KEYSTORE_DIR"`             // directory of the encrypted key files, "keystore" in the data directory if empty
+	LightKDF                 bool              `json:"LIGHT_KDF"`                // encrypt the new key files with less memory and CPU at the cost of security
+	ExternalSigner           string            `json:"EXTERNAL_SIGNER"`          // endpoint of the external signer signing the root blocks and txs, disabled if empty
+	RPCTxListLimit           uint32            `json:"RPC_TX_LIST_LIMIT"`        // maximal number of txs returned by a tx listing RPC
+	SlowRPCThreshold         uint32            `json:"SLOW_RPC_THRESHOLD"`       // milliseconds from which the cluster RPC ops between the master and the slaves are logged, 0 to disable
+	SnapSync                 bool              `json:"SNAP_SYNC"`                // bootstrap the shards without database from a snapshot served by another slave
+	AsyncMasterNotify        bool              `json:"ASYNC_MASTER_NOTIFY"`      // send the headers of the new minor blocks to the master in the background, once their cross-shard tx lists are delivered
+	RPCConsensusWorkers      int               `json:"RPC_CONSENSUS_WORKERS"`    // slave ops processing the blocks run at once, 0 for no limit
+	RPCConsensusQueue        int               `json:"RPC_CONSENSUS_QUEUE"`      // slave ops processing the blocks waiting to run, beyond which they fail
+	RPCQueryWorkers          int               `json:"RPC_QUERY_WORKERS"`        // slave ops serving the users and the peers run at once, 0 for no limit
+	RPCQueryQueue            int               `json:"RPC_QUERY_QUEUE"`          // slave ops serving the users and the peers waiting to run, beyond which they fail
+	ShardWorkers             int               `json:"SHARD_WORKERS"`            // workers of a slave running the tasks of its shards, 0 for the number of CPUs
+	ShardWorkersPerShard     int               `json:"SHARD_WORKERS_PER_SHARD"`  // tasks of a shard run at once, 0 for half of the workers
+	ShardTaskQueue           int               `json:"SHARD_TASK_QUEUE"`         // tasks of a shard waiting to run, beyond which they're dropped or fail
+	GRPCReflection           bool              `json:"GRPC_REFLECTION"`          // serve the gRPC reflection service on the cluster RPC endpoints, for tools like grpcurl
+	RPCChunkSize             uint32            `json:"RPC_CHUNK_SIZE"`           // KB beyond which the responses of the cluster RPC ops are sent in chunks, 0 to disable
+	JSONRPCMaxConcurrent     int               `json:"JSON_RPC_MAX_CONCURRENT"`  // calls of the public JSON RPC run at once, 0 for no limit
+	JSONRPCMaxQueued         int               `json:"JSON_RPC_MAX_QUEUED"`      // calls of the public JSON RPC waiting to run, beyond which they're rejected
+	JSONRPCMethodLimits      map[string]int    `json:"JSON_RPC_METHOD_LIMITS"`   // calls of a public JSON RPC method run at once, 0 for no limit but MAX_CONCURRENT, -1 for none
+	JSONRPCTimeout           uint32            `json:"JSON_RPC_TIMEOUT"`         // seconds a call of the public JSON RPC runs before it's cancelled, 0 for no timeout
+	JSONRPCMethodTimeouts    map[string]int    `json:"JSON_RPC_METHOD_TIMEOUTS"` // seconds a call of a public JSON RPC method runs, 0 for JSON_RPC_TIMEOUT, -1 for no timeout
 	CheckDB                  bool
 	CheckDBRBlockFrom        int
 	CheckDBRBlockTo          int
@@ -43,6 +77,39 @@ type ClusterConfig struct {
 	// TODO KafkaSampleLogger
 }
 
+// DefaultJSONRPCMethodLimits returns the default limits of the public JSON RPC
+// methods executing txs or scanning blocks, and of the mining ones, which are
+// not limited.
+func DefaultJSONRPCMethodLimits() map[string]int {
+	return map[string]int{
+		"qkc_call":           32,
+		"eth_call":           32,
+		"qkc_estimateGas":    32,
+		"eth_estimateGas":    32,
+		"qkc_simulateBundle": 8,
+		"qkc_getLogs":        8,
+		"qkc_getRawBlocks":   8,
+		"eth_getLogs":        8,
+		"qkc_getWork":        -1,
+		"qkc_submitWork":     -1,
+	}
+}
+
+// DefaultJSONRPCMethodTimeouts returns the default timeouts of the JSON RPC
+// methods executing txs, shorter than JSON_RPC_TIMEOUT, and of the methods
+// reading or executing many blocks, not cut off.
+func DefaultJSONRPCMethodTimeouts() map[string]int {
+	return map[string]int{
+		"qkc_call":               10,
+		"eth_call":               10,
+		"qkc_estimateGas":        10,
+		"eth_estimateGas":        10,
+		"qkc_simulateBundle":     10,
+		"qkc_getRawBlocks":       -1,
+		"debug_traceBlockByHash": -1,
+	}
+}
+
 func NewClusterConfig() *ClusterConfig {
 	var ret = ClusterConfig{
 		P2PPort:                  DefaultP2PPort,
@@ -52,6 +119,7 @@ func NewClusterConfig() *ClusterConfig {
 		PrivateJSONRPCHOST:       DefaultHost,
 		EnableTransactionHistory: false,
 		DbPathRoot:               "./db",
+		DBBackend:                "rocksdb",
 		LogLevel:                 "info",
 		StartSimulatedMining:     false,
 		Clean:                    false,
@@ -61,6 +129,25 @@ func NewClusterConfig() *ClusterConfig {
 		SimpleNetwork:            NewSimpleNetwork(),
 		P2P:                      NewP2PConfig(),
 		Monitoring:               NewMonitoringConfig(),
+		State:                    NewStateConfig(),
+		TxPool:                   NewTxPoolConfig(),
+		TxJournal:                "transactions.dat",
+		XShardJournal:            "xshard_lists.dat",
+		RPCTxListLimit:           20,
+		SlowRPCThreshold:         500,
+		RPCConsensusWorkers:      0,
+		RPCConsensusQueue:        4096,
+		RPCQueryWorkers:          32,
+		RPCQueryQueue:            1024,
+		ShardWorkers:             0,
+		ShardWorkersPerShard:     0,
+		ShardTaskQueue:           1024,
+		RPCChunkSize:             1024,
+		JSONRPCMaxConcurrent:     256,
+		JSONRPCMaxQueued:         1024,
+		JSONRPCMethodLimits:      DefaultJSONRPCMethodLimits(),
+		JSONRPCTimeout:           20,
+		JSONRPCMethodTimeouts:    DefaultJSONRPCMethodTimeouts(),
 		CheckDB:                  false,
 		CheckDBRBlockFrom:        -1,
 		CheckDBRBlockTo:          0,
@@ -89,6 +176,91 @@ func (c *ClusterConfig) GetSlaveConfig(id string) (*SlaveConfig, error) {
 	return nil, fmt.Errorf("slave %s is not in cluster config", id)
 }
 
+// Validate checks the settings of the cluster config, and that the slaves run
+// all the shards of the genesis.
+func (c *ClusterConfig) Validate() error {
+	if _, err := log.LvlFromString(c.LogLevel); err != nil {
+		return err
+	}
+	if err := debug.ValidateLogModules(c.LogModules); err != nil {
+		return err
+	}
+	if c.Monitoring != nil {
+		if err := c.Monitoring.ValidateMetrics(); err != nil {
+			return err
+		}
+	}
+	if c.Master != nil {
+		if err := c.Master.ValidateEventSinks(); err != nil {
+			return err
+		}
+	}
+	if c.RPCTxListLimit == 0 {
+		return errors.New("RPC tx list limit must be positive")
+	}
+	if err := c.State.Validate(); err != nil {
+		return err
+	}
+	if c.TxPool != nil {
+		if err := c.TxPool.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.P2P != nil {
+		if _, err := nat.Parse(c.P2P.NATMechanism()); err != nil {
+			return fmt.Errorf("invalid NAT %q: %v", c.P2P.NAT, err)
+		}
+		if _, err := c.P2P.GetStaticNodes(); err != nil {
+			return fmt.Errorf("invalid static nodes: %v", err)
+		}
+		if _, err := c.P2P.GetTrustedNodes(); err != nil {
+			return fmt.Errorf("invalid trusted nodes: %v", err)
+		}
+		if _, err := c.P2P.GetBootNodesV5(); err != nil {
+			return fmt.Errorf("invalid V5 boot nodes: %v", err)
+		}
+	}
+	if c.Quarkchain == nil || c.Quarkchain.Root == nil {
+		return errors.New("missing quarkchain config")
+	}
+	if len(c.SlaveList) == 0 {
+		return errors.New("slave config is empty")
+	}
+	ids := make(map[string]bool)
+	endpoints := make(map[string]bool)
+	for _, slave := range c.SlaveList {
+		endpoint := fmt.Sprintf("%s:%d", slave.IP, slave.Port)
+		if ids[slave.ID] {
+			return fmt.Errorf("duplicate slave id %s", slave.ID)
+		}
+		if endpoints[endpoint] {
+			return fmt.Errorf("duplicate slave endpoint %s", endpoint)
+		}
+		ids[slave.ID], endpoints[endpoint] = true, true
+	}
+	for _, id := range c.Quarkchain.GetGenesisShardIds() {
+		covered := false
+		for _, slave := range c.SlaveList {
+			for _, mask := range slave.ChainMaskList {
+				covered = covered || mask.ContainFullShardId(id)
+			}
+		}
+		if !covered {
+			return fmt.Errorf("shard %d is not run by any slave", id)
+		}
+	}
+	for id, chain := range c.Quarkchain.Chains {
+		if chain.GasLimitTarget != 0 && chain.GasLimitTarget < params.MinBlockGasLimit {
+			return fmt.Errorf("gas limit target of chain %d is below %d", id, params.MinBlockGasLimit)
+		}
+	}
+	// the other shards are created by the root block at their genesis root height
+	if len(c.Quarkchain.GetInitializedShardIdsBeforeRootHeight(1)) == 0 {
+		return errors.New("no shard is created at the root genesis")
+	}
+	return nil
+}
+
 type QuarkChainConfig struct {
 	ChainSize                         uint32      `json:"CHAIN_SIZE"`
 	MaxNeighbors                      uint32      `json:"MAX_NEIGHBORS"`
@@ -110,6 +282,8 @@ type QuarkChainConfig struct {
 	LocalFeeRate                      *big.Rat                `json:"-"`
 	RewardCalculateRate               *big.Rat                `json:"-"`
 	BlockRewardDecayFactor            *big.Rat                `json:"-"`
+	GasTokenExchangeRates             map[string]*big.Rat     `json:"-"` // price of gas tokens in genesis token, set by SetGasTokenExchangeRates
+	gasTokenExchangeRates             map[uint64]*big.Rat     // GasTokenExchangeRates by token id
 	chainIdToShardSize                map[uint32]uint32
 	chainIdToShardIds                 map[uint32][]uint32
 	defaultChainTokenID               uint64
@@ -118,6 +292,10 @@ type QuarkChainConfig struct {
 	EnableQkcHashXHeight              uint64      `json:"ENABLE_QKCHASHX_HEIGHT"`
 	DisablePowCheck                   bool        `json:"DISABLE_POW_CHECK"`
 	XShardGasDDOSFixRootHeight        uint64      `json:"XSHARD_GAS_DDOS_FIX_ROOT_HEIGHT"`
+	XShardDepositRefundDepth          uint64      `json:"XSHARD_DEPOSIT_REFUND_DEPTH"`       // refund x-shard deposits not received after this many root blocks, 0 to disable
+	XShardDepositRefundRootHeight     uint64      `json:"XSHARD_DEPOSIT_REFUND_ROOT_HEIGHT"` // x-shard deposits are refunded from this root height, 0 to disable
+	RootHeaviestChainForkHeight       uint64      `json:"ROOT_HEAVIEST_CHAIN_FORK_HEIGHT"`   // root chain uses longest chain rule below this height
+	XShardCallTimeStamp               uint64      `json:"XSHARD_CALL_TIMESTAMP"`             // contracts can send x-shard deposits from this timestamp, 0 to disable
 	MinTXPoolGasPrice                 *big.Int    `json:"MIN_TX_POOL_GAS_PRICE"`
 	MinMiningGasPrice                 *big.Int    `json:"MIN_MINING_GAS_PRICE"`
 	GRPCHost                          string      `json:"-"`
@@ -128,12 +306,13 @@ type QuarkChainConfig struct {
 type QuarkChainConfigAlias QuarkChainConfig
 type jsonConfig struct {
 	QuarkChainConfigAlias
-	GuardianPublicKey                 string         `json:"GUARDIAN_PUBLIC_KEY"`
-	RootSignerPrivateKey              string         `json:"ROOT_SIGNER_PRIVATE_KEY"`
-	Chains                            []*ChainConfig `json:"CHAINS"`
-	RewardTaxRate                     float64        `json:"REWARD_TAX_RATE"`
-	BlockRewardDecayFactor            float64        `json:"BLOCK_REWARD_DECAY_FACTOR"`
-	RootChainPoSWContractBytecodeHash string         `json:"ROOT_CHAIN_POSW_CONTRACT_BYTECODE_HASH"`
+	GuardianPublicKey                 string             `json:"GUARDIAN_PUBLIC_KEY"`
+	RootSignerPrivateKey              string             `json:"ROOT_SIGNER_PRIVATE_KEY"`
+	Chains                            []*ChainConfig     `json:"CHAINS"`
+	RewardTaxRate                     float64            `json:"REWARD_TAX_RATE"`
+	BlockRewardDecayFactor            float64            `json:"BLOCK_REWARD_DECAY_FACTOR"`
+	RootChainPoSWContractBytecodeHash string             `json:"ROOT_CHAIN_POSW_CONTRACT_BYTECODE_HASH"`
+	GasTokenExchangeRates             map[string]float64 `json:"GAS_TOKEN_EXCHANGE_RATES,omitempty"`
 }
 
 func (q *QuarkChainConfig) MarshalJSON() ([]byte, error) {
@@ -144,6 +323,14 @@ func (q *QuarkChainConfig) MarshalJSON() ([]byte, error) {
 	for _, chain := range q.Chains {
 		chains = append(chains, chain)
 	}
+	sort.Slice(chains, func(i, j int) bool { return chains[i].ChainID < chains[j].ChainID })
+	var gasTokenExchangeRates map[string]float64
+	if len(q.GasTokenExchangeRates) > 0 {
+		gasTokenExchangeRates = make(map[string]float64, len(q.GasTokenExchangeRates))
+		for token, rate := range q.GasTokenExchangeRates {
+			gasTokenExchangeRates[token], _ = rate.Float64()
+		}
+	}
 	jConfig := jsonConfig{
 		QuarkChainConfigAlias(*q),
 		hex.EncodeToString(q.GuardianPublicKey),
@@ -152,6 +339,7 @@ func (q *QuarkChainConfig) MarshalJSON() ([]byte, error) {
 		rewardTaxRate,
 		BlockRewardDecayFactor,
 		rootChainPoSWContractBytecodeHash,
+		gasTokenExchangeRates,
 	}
 	return json.Marshal(jConfig)
 }
@@ -187,6 +375,15 @@ func (q *QuarkChainConfig) UnmarshalJSON(input []byte) error {
 	q.RewardCalculateRate = new(big.Rat).Quo(q.RewardTaxRate, q.LocalFeeRate)
 	q.BlockRewardDecayFactor = big.NewRat(int64(jConfig.BlockRewardDecayFactor*float64(denom)), denom)
 	q.RootChainPoSWContractBytecodeHash = ethcom.HexToHash(jConfig.RootChainPoSWContractBytecodeHash)
+	if len(jConfig.GasTokenExchangeRates) > 0 {
+		rates := make(map[string]*big.Rat, len(jConfig.GasTokenExchangeRates))
+		for token, rate := range jConfig.GasTokenExchangeRates {
+			rates[token] = new(big.Rat).SetFloat64(rate)
+		}
+		if err := q.SetGasTokenExchangeRates(rates); err != nil {
+			return err
+		}
+	}
 
 	q.GuardianPublicKey = ethcom.FromHex(jConfig.GuardianPublicKey)
 	q.RootSignerPrivateKey = ethcom.FromHex(jConfig.RootSignerPrivateKey)
@@ -448,6 +645,42 @@ func (q *QuarkChainConfig) IsAllowedTokenID(tokenID uint64) bool {
 	_, ok := q.allowedTokenIds()[tokenID]
 	return ok
 }
+
+// SetGasTokenExchangeRates sets the prices of the gas tokens in genesis token,
+// it returns an error if a token name is invalid or a price isn't positive.
+func (q *QuarkChainConfig) SetGasTokenExchangeRates(rates map[string]*big.Rat) error {
+	byID := make(map[uint64]*big.Rat, len(rates))
+	for token, rate := range rates {
+		tokenID, err := common.TokenIDEncodeWithCheck(token)
+		if err != nil {
+			return fmt.Errorf("invalid GAS_TOKEN_EXCHANGE_RATES: %v", err)
+		}
+		if rate == nil || rate.Sign() <= 0 {
+			return fmt.Errorf("invalid GAS_TOKEN_EXCHANGE_RATES: rate of token %s must be positive", token)
+		}
+		byID[tokenID] = rate
+	}
+	q.GasTokenExchangeRates, q.gasTokenExchangeRates = rates, byID
+	return nil
+}
+
+// GetGasTokenExchangeRate returns the price of tokenID in genesis token, gas
+// tokens without exchange rate are priced at face value.
+func (q *QuarkChainConfig) GetGasTokenExchangeRate(tokenID uint64) *big.Rat {
+	if rate, ok := q.gasTokenExchangeRates[tokenID]; ok && tokenID != q.GetDefaultChainTokenID() {
+		return rate
+	}
+	return big.NewRat(1, 1)
+}
+
+// ConvertGasPrice converts gasPrice paid in tokenID to the gas price in
+// genesis token.
+func (q *QuarkChainConfig) ConvertGasPrice(tokenID uint64, gasPrice *big.Int) *big.Int {
+	rate := q.GetGasTokenExchangeRate(tokenID)
+	price := new(big.Int).Mul(gasPrice, rate.Num())
+	return price.Div(price, rate.Denom())
+}
+
 func (q *QuarkChainConfig) GasLimit(fullShardID uint32) (*big.Int, error) {
 	data, ok := q.shards[fullShardID]
 	if !ok {
@@ -2,17 +2,24 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/QuarkChain/goquarkchain/params"
 	"math/big"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/QuarkChain/goquarkchain/account"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/discv5"
+	"github.com/ethereum/go-ethereum/p2p/enode"
 )
 
 const (
@@ -27,6 +34,11 @@ const (
 	// PoWQkchash is the consensus type running qkchash algorithm.
 	PoWQkchash = "POW_QKCHASH"
 
+	// GCModeArchive keeps the state tries of all minor blocks on disk.
+	GCModeArchive = "archive"
+	// GCModeFull keeps only the recent state tries, older ones are garbage collected.
+	GCModeFull = "full"
+
 	DefaultGrpcPort    uint16 = 38191
 	DefaultP2PPort     uint16 = 38291
 	DefaultPubRpcPort  uint16 = 38391
@@ -34,6 +46,13 @@ const (
 	DefaultWSPort      uint16 = 38590
 	DefaultHost               = "localhost"
 
+	// the slaves listen from DefaultWSPort on
+	DefaultMasterWSPort uint16 = 38589
+
+	// root blocks above the one confirming a minor block for it to be final
+	DefaultConfirmationDepth uint64 = 12
+	DefaultBlockCacheSize    uint64 = 64
+
 	HeartbeatInterval = time.Duration(4 * time.Second)
 )
 
@@ -54,6 +73,7 @@ var (
 type POWConfig struct {
 	TargetBlockTime uint32 `json:"TARGET_BLOCK_TIME"`
 	RemoteMine      bool   `json:"REMOTE_MINE"`
+	MineOnDemand    bool   `json:"MINE_ON_DEMAND,omitempty"` // seal a block at once when there is work for it instead of at the target block time
 }
 
 func NewPOWConfig() *POWConfig {
@@ -131,6 +151,7 @@ type RootConfig struct {
 	// Use Ethereum's number, which is
 	// - 30000 * 3 blocks = 90000 * 15 / 3600 = 375 hours = 375 * 3600 / 60 = 22500
 	MaxStaleRootBlockHeightDiff    uint64          `json:"MAX_STALE_ROOT_BLOCK_HEIGHT_DIFF"`
+	MaxReorgDepth                  uint64          `json:"MAX_REORG_DEPTH"` // root blocks this deep under the tip are final, 0 for no limit
 	ConsensusType                  string          `json:"CONSENSUS_TYPE"`
 	ConsensusConfig                *POWConfig      `json:"CONSENSUS_CONFIG"`
 	Genesis                        *RootGenesis    `json:"GENESIS"`
@@ -204,11 +225,121 @@ type NetWorkId struct {
 type MasterConfig struct {
 	// default 1.0
 	MasterToSlaveConnectRetryDelay float32 `json:"MASTER_TO_SLAVE_CONNECT_RETRY_DELAY"`
+	// seconds to wait for a slave which stopped answering to be restarted
+	// before shutting down the cluster, 0 to shut down at once
+	SlaveRestartTimeout uint32 `json:"SLAVE_RESTART_TIMEOUT"`
+	// localhost port of the pprof HTTP server of the master, 0 to disable
+	PprofPort uint16 `json:"PPROF_PORT,omitempty"`
+	// port of the /health and /ready HTTP endpoints of the master, 0 to disable
+	HealthPort uint16 `json:"HEALTH_PORT,omitempty"`
+	// peers the master needs to be ready
+	ReadyMinPeers uint32 `json:"READY_MIN_PEERS,omitempty"`
+	// file the critical actions of the cluster are appended to, relative to
+	// the data directory, empty to keep them in memory only
+	AuditLog string `json:"AUDIT_LOG"`
+	// root chain reorgs dropping more blocks are audited
+	AuditReorgDepth uint64 `json:"AUDIT_REORG_DEPTH"`
+	// port of the websocket JSON RPC of the master, served with --ws
+	WSPort uint16 `json:"WEBSOCKET_JSON_RPC_PORT,omitempty"`
+	// root blocks above the one confirming a minor block for it to be final
+	ConfirmationDepth uint64 `json:"CONFIRMATION_DEPTH"`
+	// MB of the minor blocks and the receipts returned by the slaves kept by
+	// block hash for the JSON RPCs, 0 to disable
+	BlockCacheSize uint64 `json:"BLOCK_CACHE_SIZE"`
+	// watch the txs submitted through the master until they are confirmed or
+	// dropped, for the txStatus websocket subscription
+	TxWatcher bool `json:"TX_WATCHER"`
+	// URL the status changes of the txs watched are posted to, which also
+	// enables the watcher, empty for none
+	TxWatcherWebhook string `json:"TX_WATCHER_WEBHOOK,omitempty"`
+	// reserve the nonces of the senders on the master, for the txs signed
+	// concurrently, and reuse the ones of the txs dropped
+	NonceManager bool `json:"NONCE_MANAGER"`
+	// HTTP endpoints and Kafka topics the chain events are pushed to
+	EventSinks []*EventSinkConfig `json:"EVENT_SINKS,omitempty"`
+	// hex addresses whose txs in the new blocks of the shards are pushed as
+	// address events
+	EventWatchAddresses []string `json:"EVENT_WATCH_ADDRESSES,omitempty"`
+}
+
+// The sinks of the chain events.
+const (
+	EventSinkHTTP  = "http"
+	EventSinkKafka = "kafka"
+)
+
+// The types of the chain events.
+const (
+	EventRootBlock = "root_block"
+	EventShardTip  = "shard_tip"
+	EventReorg     = "reorg"
+	EventAddress   = "address"
+	// the status changes of the txs watched, posted to TX_WATCHER_WEBHOOK
+	EventTxStatus = "tx_status"
+)
+
+// EventSinkConfig is an HTTP endpoint the chain events are posted to one at a
+// time, or a Kafka topic they are produced to through the Kafka REST proxy.
+type EventSinkConfig struct {
+	Type string `json:"TYPE"` // "http" or "kafka"
+	// URL the events are posted to, or of the Kafka REST proxy, e.g.
+	// http://localhost:8082
+	URL     string            `json:"URL"`
+	Topic   string            `json:"TOPIC,omitempty"`   // Kafka topic
+	Headers map[string]string `json:"HEADERS,omitempty"` // HTTP headers of the requests, e.g. for authentication
+	// types of the events pushed, all of them if empty
+	Events []string `json:"EVENTS,omitempty"`
+	// attempts after the first one before an event is dropped, 10 if 0
+	MaxRetries uint32 `json:"MAX_RETRIES,omitempty"`
+}
+
+// ValidateEventSinks checks the sinks of the chain events and the addresses
+// watched.
+func (m *MasterConfig) ValidateEventSinks() error {
+	for i, sink := range m.EventSinks {
+		if sink == nil {
+			return fmt.Errorf("event sink %d is empty", i)
+		}
+		switch sink.Type {
+		case EventSinkHTTP:
+		case EventSinkKafka:
+			if sink.Topic == "" {
+				return fmt.Errorf("event sink %d has no Kafka topic", i)
+			}
+		default:
+			return fmt.Errorf("unknown type %q of event sink %d, want %s or %s", sink.Type, i, EventSinkHTTP, EventSinkKafka)
+		}
+		u, err := url.Parse(sink.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("invalid URL %q of event sink %d, want an HTTP URL", sink.URL, i)
+		}
+		for _, typ := range sink.Events {
+			switch typ {
+			case EventRootBlock, EventShardTip, EventReorg, EventAddress:
+			default:
+				return fmt.Errorf("unknown event %q of event sink %d", typ, i)
+			}
+		}
+	}
+	_, err := m.GetEventWatchRecipients()
+	return err
+}
+
+// GetEventWatchRecipients returns the recipients of the addresses watched for
+// the address events. An address with a full shard key stands for its
+// recipient.
+func (m *MasterConfig) GetEventWatchRecipients() ([]account.Recipient, error) {
+	return parseRecipients(m.EventWatchAddresses, "event watch")
 }
 
 func NewMasterConfig() *MasterConfig {
 	return &MasterConfig{
 		MasterToSlaveConnectRetryDelay: 1.0,
+		WSPort:                         DefaultMasterWSPort,
+		AuditLog:                       "audit.log",
+		AuditReorgDepth:                1,
+		ConfirmationDepth:              DefaultConfirmationDepth,
+		BlockCacheSize:                 DefaultBlockCacheSize,
 	}
 }
 
@@ -219,8 +350,28 @@ type P2PConfig struct {
 	PrivKey          string  `json:"PRIV_KEY"`
 	MaxPeers         uint64  `json:"MAX_PEERS"`
 	UPnP             bool    `json:"UPNP"`
+	NAT              string  `json:"NAT,omitempty"` // any|none|upnp|pmp|extip:<IP>, upnp if UPNP is set and none otherwise if empty
 	AllowDialInRatio float32 `json:"ALLOW_DIAL_IN_RATIO"`
 	PreferredNodes   string  `json:"PREFERRED_NODES"`
+	StaticNodes      string  `json:"STATIC_NODES,omitempty"`   // comma separated enodes always kept connected
+	TrustedNodes     string  `json:"TRUSTED_NODES,omitempty"`  // comma separated enodes exempt from MAX_PEERS
+	AllowlistOnly    bool    `json:"ALLOWLIST_ONLY,omitempty"` // refuse all peers but the static and trusted ones
+	// p2p bandwidth limits in KB/s of all the peers together and of each peer, 0 for unlimited
+	MaxUploadKBps       uint64 `json:"MAX_UPLOAD_KBPS,omitempty"`
+	MaxDownloadKBps     uint64 `json:"MAX_DOWNLOAD_KBPS,omitempty"`
+	PeerMaxUploadKBps   uint64 `json:"PEER_MAX_UPLOAD_KBPS,omitempty"`
+	PeerMaxDownloadKBps uint64 `json:"PEER_MAX_DOWNLOAD_KBPS,omitempty"`
+	// file the parameters of each peer handshake are appended to, and the handshake thresholds below which peers are refused
+	HandshakeAuditLog     string `json:"HANDSHAKE_AUDIT_LOG,omitempty"`
+	MinProtocolVersion    uint64 `json:"MIN_PROTOCOL_VERSION,omitempty"`     // devp2p base protocol version
+	MinQKCProtocolVersion uint   `json:"MIN_QKC_PROTOCOL_VERSION,omitempty"` // quarkchain protocol version
+	RequireEIP8           bool   `json:"REQUIRE_EIP8,omitempty"`             // refuse the pre-EIP-8 encryption handshake
+	// block and header requests served to each peer per second and at once, 0 for the defaults
+	ServeRequestRate  uint64 `json:"SERVE_REQUEST_RATE,omitempty"`
+	ServeRequestBurst uint64 `json:"SERVE_REQUEST_BURST,omitempty"`
+	// discovery V5 finding the clusters serving the same chains by topic, run along with V4
+	DiscoveryV5 bool   `json:"DISCOVERY_V5,omitempty"`
+	BootNodesV5 string `json:"BOOT_NODES_V5,omitempty"` // comma separated enodes of the V5 boot nodes
 }
 
 func NewP2PConfig() *P2PConfig {
@@ -234,10 +385,65 @@ func NewP2PConfig() *P2PConfig {
 	}
 }
 
+// NATMechanism returns the mechanism mapping the port of the p2p listener on
+// the router, which also finds the external IP of the node. No port is mapped
+// unless NAT or UPNP is set.
+func (s *P2PConfig) NATMechanism() string {
+	switch {
+	case s.NAT != "":
+		return s.NAT
+	case s.UPnP:
+		return "upnp"
+	default:
+		return "none"
+	}
+}
+
 func (s *P2PConfig) GetBootNodes() []string {
 	return strings.Split(s.BootNodes, ",")
 }
 
+// GetStaticNodes returns the static nodes of the config.
+func (s *P2PConfig) GetStaticNodes() ([]*enode.Node, error) {
+	return parseNodes(s.StaticNodes)
+}
+
+// GetTrustedNodes returns the trusted nodes of the config.
+func (s *P2PConfig) GetTrustedNodes() ([]*enode.Node, error) {
+	return parseNodes(s.TrustedNodes)
+}
+
+// GetBootNodesV5 returns the discovery V5 boot nodes of the config.
+func (s *P2PConfig) GetBootNodesV5() ([]*discv5.Node, error) {
+	nodes := make([]*discv5.Node, 0)
+	for _, url := range strings.Split(s.BootNodesV5, ",") {
+		if url = strings.TrimSpace(url); url == "" {
+			continue
+		}
+		node, err := discv5.ParseNode(url)
+		if err != nil {
+			return nil, fmt.Errorf("invalid enode %q: %v", url, err)
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+func parseNodes(urls string) ([]*enode.Node, error) {
+	nodes := make([]*enode.Node, 0)
+	for _, url := range strings.Split(urls, ",") {
+		if url = strings.TrimSpace(url); url == "" {
+			continue
+		}
+		node, err := enode.ParseV4(url)
+		if err != nil {
+			return nil, fmt.Errorf("invalid enode %q: %v", url, err)
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
 type MonitoringConfig struct {
 	NetworkName      string `json:"NETWORK_NAME"`
 	ClusterID        string `json:"CLUSTER_ID"`
@@ -245,8 +451,22 @@ type MonitoringConfig struct {
 	MinerTopic       string `json:"MINER_TOPIC"`        // "qkc_miner"
 	PropagationTopic string `json:"PROPAGATION_TOPIC"`  // "block_propagation"
 	Errors           string `json:"ERRORS"`             // "error"
+	// push reporter of the metrics, "influxdb" or "graphite", disabled if empty
+	MetricsType string `json:"METRICS_TYPE,omitempty"`
+	// URL of the InfluxDB write API, e.g. http://localhost:8086/write?db=quarkchain,
+	// or HOST:PORT of the Graphite plaintext listener
+	MetricsEndpoint string            `json:"METRICS_ENDPOINT,omitempty"`
+	MetricsInterval uint32            `json:"METRICS_INTERVAL,omitempty"` // seconds between the reports
+	MetricsPrefix   string            `json:"METRICS_PREFIX,omitempty"`   // prefix of the Graphite metric names
+	MetricsTags     map[string]string `json:"METRICS_TAGS,omitempty"`     // tags added to the metrics of the cluster and node
 }
 
+// The push reporters of the metrics.
+const (
+	MetricsInfluxDB = "influxdb"
+	MetricsGraphite = "graphite"
+)
+
 func NewMonitoringConfig() *MonitoringConfig {
 	return &MonitoringConfig{
 		NetworkName:      "",
@@ -255,9 +475,237 @@ func NewMonitoringConfig() *MonitoringConfig {
 		MinerTopic:       "qkc_miner",
 		PropagationTopic: "block_propagation",
 		Errors:           "error",
+		MetricsInterval:  10,
+		MetricsPrefix:    "qkc",
+	}
+}
+
+// ValidateMetrics checks the settings of the metrics reporter.
+func (m *MonitoringConfig) ValidateMetrics() error {
+	switch m.MetricsType {
+	case "":
+		return nil
+	case MetricsInfluxDB:
+		u, err := url.Parse(m.MetricsEndpoint)
+		if err != nil {
+			return fmt.Errorf("invalid InfluxDB endpoint: %v", err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("invalid InfluxDB endpoint %q, want an HTTP URL", m.MetricsEndpoint)
+		}
+	case MetricsGraphite:
+		if _, _, err := net.SplitHostPort(m.MetricsEndpoint); err != nil {
+			return fmt.Errorf("invalid Graphite endpoint: %v", err)
+		}
+	default:
+		return fmt.Errorf("unknown metrics type %q, want %s or %s", m.MetricsType, MetricsInfluxDB, MetricsGraphite)
+	}
+	if m.MetricsInterval == 0 {
+		return errors.New("metrics interval must be positive")
+	}
+	return nil
+}
+
+type StateConfig struct {
+	GCMode         string            `json:"GC_MODE"`                  // "archive" or "full"
+	ShardGCModes   map[uint32]string `json:"SHARD_GC_MODES,omitempty"` // GC mode overrides by full shard id
+	TrieCleanCache int               `json:"TRIE_CLEAN_CACHE"`         // MB of memory for caching clean trie nodes
+	TrieDirtyCache int               `json:"TRIE_DIRTY_CACHE"`         // MB of memory for dirty trie nodes before flushing in full mode
+	TrieTimeLimit  uint64            `json:"TRIE_TIME_LIMIT"`          // seconds of processing before a state trie is flushed in full mode
+	Snapshot       bool              `json:"SNAPSHOT"`                 // maintain a flat account/storage snapshot for the state reads
+	Prefetch       bool              `json:"PREFETCH"`                 // read the accounts of the txs of a block ahead of running them
+	// trie cache overrides by full shard id, for the busy shards
+	ShardTrieCaches map[uint32]*TrieCacheConfig `json:"SHARD_TRIE_CACHES,omitempty"`
+	// index the balances of the accounts changed by each block, to read them
+	// at any height without the state
+	BalanceHistory          bool     `json:"BALANCE_HISTORY"`
+	BalanceHistoryAddresses []string `json:"BALANCE_HISTORY_ADDRESSES,omitempty"` // hex recipients indexed, all the accounts if empty, which needs the archive gc mode
+}
+
+func NewStateConfig() *StateConfig {
+	return &StateConfig{
+		GCMode:         GCModeArchive,
+		TrieCleanCache: 128,
+		TrieDirtyCache: 128,
+		TrieTimeLimit:  300,
+		Prefetch:       true,
 	}
 }
 
+// TrieCacheConfig overrides the trie cache sizes of a shard, the sizes left 0
+// are those of the other shards.
+type TrieCacheConfig struct {
+	TrieCleanCache int `json:"TRIE_CLEAN_CACHE,omitempty"`
+	TrieDirtyCache int `json:"TRIE_DIRTY_CACHE,omitempty"`
+}
+
+// GetTrieCaches returns the MB of memory for the clean and the dirty trie
+// nodes of the shard.
+func (s *StateConfig) GetTrieCaches(fullShardID uint32) (clean, dirty int) {
+	clean, dirty = s.TrieCleanCache, s.TrieDirtyCache
+	if cache, ok := s.ShardTrieCaches[fullShardID]; ok && cache != nil {
+		if cache.TrieCleanCache != 0 {
+			clean = cache.TrieCleanCache
+		}
+		if cache.TrieDirtyCache != 0 {
+			dirty = cache.TrieDirtyCache
+		}
+	}
+	return clean, dirty
+}
+
+// GetGCMode returns the GC mode of the shard.
+func (s *StateConfig) GetGCMode(fullShardID uint32) string {
+	if mode, ok := s.ShardGCModes[fullShardID]; ok {
+		return mode
+	}
+	return s.GCMode
+}
+
+// Validate checks all the GC modes are known.
+func (s *StateConfig) Validate() error {
+	if s.GCMode != GCModeArchive && s.GCMode != GCModeFull {
+		return fmt.Errorf("unknown gc mode %q", s.GCMode)
+	}
+	for id, mode := range s.ShardGCModes {
+		if mode != GCModeArchive && mode != GCModeFull {
+			return fmt.Errorf("unknown gc mode %q of shard %d", mode, id)
+		}
+	}
+	if s.TrieCleanCache < 0 || s.TrieDirtyCache < 0 {
+		return errors.New("trie cache sizes can't be negative")
+	}
+	for id, cache := range s.ShardTrieCaches {
+		if cache != nil && (cache.TrieCleanCache < 0 || cache.TrieDirtyCache < 0) {
+			return fmt.Errorf("trie cache sizes of shard %d can't be negative", id)
+		}
+	}
+	if !s.BalanceHistory {
+		return nil
+	}
+	recipients, err := s.GetBalanceHistoryRecipients()
+	if err != nil {
+		return err
+	}
+	if len(recipients) == 0 {
+		// the accounts not changed since the index started are read from the
+		// state of its first block
+		if s.GCMode != GCModeArchive {
+			return errors.New("balance history of all the accounts needs the archive gc mode")
+		}
+		for id, mode := range s.ShardGCModes {
+			if mode != GCModeArchive {
+				return fmt.Errorf("balance history of all the accounts needs the archive gc mode of shard %d", id)
+			}
+		}
+	}
+	return nil
+}
+
+// GetBalanceHistoryRecipients returns the recipients whose balance history is
+// indexed, none for all the accounts. An address with a full shard key stands
+// for its recipient.
+func (s *StateConfig) GetBalanceHistoryRecipients() ([]account.Recipient, error) {
+	return parseRecipients(s.BalanceHistoryAddresses, "balance history")
+}
+
+// parseRecipients returns the recipients of the hex addresses, with or without
+// their full shard key.
+func parseRecipients(addrs []string, what string) ([]account.Recipient, error) {
+	recipients := make([]account.Recipient, 0, len(addrs))
+	for _, addr := range addrs {
+		data, err := hexutil.Decode(addr)
+		if err != nil || (len(data) != account.RecipientLength && len(data) != account.RecipientLength+4) {
+			return nil, fmt.Errorf("invalid %s address %q", what, addr)
+		}
+		recipients = append(recipients, account.BytesToIdentityRecipient(data[:account.RecipientLength]))
+	}
+	return recipients, nil
+}
+
+// TxPoolConfig are the sizes of the tx pool of each shard, and the policies on
+// the txs they accept.
+type TxPoolConfig struct {
+	AccountSlots uint64 `json:"ACCOUNT_SLOTS"` // executable txs guaranteed per account
+	GlobalSlots  uint64 `json:"GLOBAL_SLOTS"`  // executable txs of all the accounts
+	AccountQueue uint64 `json:"ACCOUNT_QUEUE"` // non-executable txs permitted per account
+	GlobalQueue  uint64 `json:"GLOBAL_QUEUE"`  // non-executable txs of all the accounts
+	// policy of the shards without one of their own
+	Policy *TxPolicyConfig `json:"POLICY,omitempty"`
+	// policies of the shards by full shard id, e.g. "0x10001"
+	ShardPolicies map[string]*TxPolicyConfig `json:"SHARD_POLICIES,omitempty"`
+}
+
+// TxPolicyConfig is the policy of a shard on the txs its pool accepts, on top
+// of the consensus rules.
+type TxPolicyConfig struct {
+	// in QKC, the MIN_TX_POOL_GAS_PRICE of the cluster if lower
+	MinGasPrice *big.Int `json:"MIN_GAS_PRICE,omitempty"`
+	// bytes of the serialized tx, 32KB if 0
+	MaxTxSize uint64 `json:"MAX_TX_SIZE,omitempty"`
+	// hex addresses of the senders whose txs are rejected
+	SenderBlacklist []string `json:"SENDER_BLACKLIST,omitempty"`
+	// hex addresses of the only senders whose txs are accepted, if any
+	SenderWhitelist []string `json:"SENDER_WHITELIST,omitempty"`
+	// reject the txs creating contracts
+	DisableContractCreation bool `json:"DISABLE_CONTRACT_CREATION,omitempty"`
+}
+
+// GetSenderLists returns the recipients of the blacklist and of the whitelist
+// of the senders.
+func (p *TxPolicyConfig) GetSenderLists() (blacklist, whitelist []account.Recipient, err error) {
+	if blacklist, err = parseRecipients(p.SenderBlacklist, "sender blacklist"); err != nil {
+		return nil, nil, err
+	}
+	if whitelist, err = parseRecipients(p.SenderWhitelist, "sender whitelist"); err != nil {
+		return nil, nil, err
+	}
+	return blacklist, whitelist, nil
+}
+
+// GetPolicy returns the tx policy of the shard, nil if none.
+func (t *TxPoolConfig) GetPolicy(fullShardID uint32) *TxPolicyConfig {
+	for id, policy := range t.ShardPolicies {
+		if parsed, err := strconv.ParseUint(id, 0, 32); err == nil && uint32(parsed) == fullShardID {
+			return policy
+		}
+	}
+	return t.Policy
+}
+
+func NewTxPoolConfig() *TxPoolConfig {
+	return &TxPoolConfig{
+		AccountSlots: 16,
+		GlobalSlots:  81920,
+		AccountQueue: 64,
+		GlobalQueue:  1024,
+	}
+}
+
+// Validate checks all the sizes are positive.
+func (t *TxPoolConfig) Validate() error {
+	if t.AccountSlots == 0 || t.GlobalSlots == 0 || t.AccountQueue == 0 || t.GlobalQueue == 0 {
+		return errors.New("tx pool sizes must be positive")
+	}
+	if t.Policy != nil {
+		if _, _, err := t.Policy.GetSenderLists(); err != nil {
+			return err
+		}
+	}
+	for id, policy := range t.ShardPolicies {
+		if _, err := strconv.ParseUint(id, 0, 32); err != nil {
+			return fmt.Errorf("invalid full shard id %q of tx policy", id)
+		}
+		if policy == nil {
+			continue
+		}
+		if _, _, err := policy.GetSenderLists(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 type GenesisAddress struct {
 	Address string `json:"address"`
 	PrivKey string `json:"key"`
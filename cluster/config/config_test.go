@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"github.com/QuarkChain/goquarkchain/account"
+	qcom "github.com/QuarkChain/goquarkchain/common"
+	"github.com/QuarkChain/goquarkchain/params"
 	"github.com/ethereum/go-ethereum/common"
 	"io/ioutil"
 	"math/big"
@@ -206,3 +208,404 @@ func loadConfig(file string, cfg *ClusterConfig) error {
 	}
 	return json.Unmarshal(content, cfg)
 }
+
+func TestGasTokenExchangeRates(t *testing.T) {
+	qkcConfig := NewQuarkChainConfig()
+	assert.NoError(t, qkcConfig.SetGasTokenExchangeRates(map[string]*big.Rat{"QI": big.NewRat(1, 2)}))
+	jsonConfig, err := json.Marshal(qkcConfig)
+	assert.NoError(t, err)
+	assert.Contains(t, string(jsonConfig), "\"GAS_TOKEN_EXCHANGE_RATES\":{\"QI\":0.5}")
+
+	var c QuarkChainConfig
+	assert.NoError(t, json.Unmarshal(jsonConfig, &c))
+	assert.Equal(t, big.NewInt(5), c.ConvertGasPrice(qcom.TokenIDEncode("QI"), big.NewInt(10)))
+	assert.Equal(t, big.NewInt(10), c.ConvertGasPrice(c.GetDefaultChainTokenID(), big.NewInt(10)))
+	// tokens without exchange rate are priced at face value
+	assert.Equal(t, big.NewInt(10), c.ConvertGasPrice(qcom.TokenIDEncode("BTC"), big.NewInt(10)))
+
+	// invalid token names and rates are config errors
+	for _, rates := range []string{`{"qi":0.5}`, `{"":0.5}`, `{"TOOLONGTOKENNAME":0.5}`, `{"QI":0}`, `{"QI":-1}`} {
+		err := json.Unmarshal([]byte(`{"GAS_TOKEN_EXCHANGE_RATES":`+rates+`}`), &c)
+		assert.Error(t, err, rates)
+	}
+}
+
+func TestStateConfig(t *testing.T) {
+	clstrConfig := NewClusterConfig()
+	assert.Equal(t, GCModeArchive, clstrConfig.State.GetGCMode(1))
+
+	s := []byte(`{"STATE":{"GC_MODE":"full","SHARD_GC_MODES":{"65537":"archive"},"TRIE_CLEAN_CACHE":256,"TRIE_DIRTY_CACHE":64,"TRIE_TIME_LIMIT":60}}`)
+	assert.NoError(t, json.Unmarshal(s, clstrConfig))
+	assert.NoError(t, clstrConfig.State.Validate())
+	assert.Equal(t, GCModeFull, clstrConfig.State.GetGCMode(1))
+	assert.Equal(t, GCModeArchive, clstrConfig.State.GetGCMode(65537))
+	assert.Equal(t, 256, clstrConfig.State.TrieCleanCache)
+	assert.Equal(t, 64, clstrConfig.State.TrieDirtyCache)
+	assert.Equal(t, uint64(60), clstrConfig.State.TrieTimeLimit)
+	assert.True(t, clstrConfig.State.Prefetch)
+
+	// the busy shards get caches of their own
+	s = []byte(`{"STATE":{"PREFETCH":false,"SHARD_TRIE_CACHES":{"65537":{"TRIE_CLEAN_CACHE":1024}}}}`)
+	assert.NoError(t, json.Unmarshal(s, clstrConfig))
+	assert.NoError(t, clstrConfig.State.Validate())
+	assert.False(t, clstrConfig.State.Prefetch)
+	clean, dirty := clstrConfig.State.GetTrieCaches(65537)
+	assert.Equal(t, 1024, clean)
+	assert.Equal(t, 64, dirty)
+	clean, dirty = clstrConfig.State.GetTrieCaches(1)
+	assert.Equal(t, 256, clean)
+	assert.Equal(t, 64, dirty)
+	clstrConfig.State.ShardTrieCaches[1] = &TrieCacheConfig{TrieDirtyCache: -1}
+	assert.Error(t, clstrConfig.State.Validate())
+	delete(clstrConfig.State.ShardTrieCaches, 1)
+
+	clstrConfig.State.ShardGCModes[1] = "light"
+	assert.Error(t, clstrConfig.State.Validate())
+}
+
+func TestReloadChanges(t *testing.T) {
+	running := NewClusterConfig()
+	running.Quarkchain.Root.CoinbaseAddress = account.NewAddress(account.Recipient{0x01}, 0)
+
+	s := []byte(`{"LOG_LEVEL":"debug","RPC_TX_LIST_LIMIT":50,"SLOW_RPC_THRESHOLD":100,"TX_POOL":{"GLOBAL_SLOTS":4096},
+		"SLAVE_LIST":[{"ID":"S0","HOST":"10.0.0.1","PORT":38000,"CHAIN_MASK_LIST":[4]},
+			{"ID":"S1","HOST":"localhost","PORT":38001,"CHAIN_MASK_LIST":[5]},
+			{"ID":"S2","HOST":"localhost","PORT":38002,"CHAIN_MASK_LIST":[6]},
+			{"ID":"S3","HOST":"localhost","PORT":38003,"CHAIN_MASK_LIST":[7]}]}`)
+	reloaded := NewClusterConfig()
+	assert.NoError(t, json.Unmarshal(s, reloaded))
+	reloaded.Quarkchain.NetworkID = 4
+	reloaded.Quarkchain.Chains[1].CoinbaseAddress = account.NewAddress(account.Recipient{0x02}, 0x00010000)
+	reloaded.Quarkchain.Chains[1].GasLimitTarget = 30000 * 800
+	changes, restart, err := running.ReloadChanges(reloaded)
+	assert.NoError(t, err)
+	settings := make([]string, len(changes))
+	for i, c := range changes {
+		settings[i] = c.Setting
+	}
+	// the empty root coinbase keeps the one in use
+	assert.Equal(t, []string{SettingLogLevel, SettingRPCTxListLimit, SettingSlowRPCThreshold, SettingTxPool,
+		SettingChainCoinbase + ".1", SettingChainGasTarget + ".1", SettingSlaveEndpoint + ".S0"}, settings)
+	assert.Equal(t, "localhost:38000", changes[6].Old)
+	assert.Equal(t, "10.0.0.1:38000", changes[6].New)
+	assert.Equal(t, []string{"QUARKCHAIN.NETWORK_ID"}, restart)
+	setting, key := SplitSetting(changes[4].Setting)
+	assert.Equal(t, SettingChainCoinbase, setting)
+	assert.Equal(t, "1", key)
+	setting, key = SplitSetting(changes[5].Setting)
+	assert.Equal(t, SettingChainGasTarget, setting)
+	assert.Equal(t, "1", key)
+	running.Quarkchain.SetChainGasLimitTarget(1, reloaded.Quarkchain.Chains[1].GasLimitTarget)
+	assert.Equal(t, uint64(30000*800), running.Quarkchain.GetShardConfigByFullShardID(1<<16|2).GasLimitTarget)
+
+	reloaded.Quarkchain.Chains[1].GasLimitTarget = params.MinBlockGasLimit - 1
+	_, _, err = running.ReloadChanges(reloaded)
+	assert.Error(t, err)
+	reloaded.Quarkchain.Chains[1].GasLimitTarget = 0
+	reloaded.LogLevel = "loud"
+	_, _, err = running.ReloadChanges(reloaded)
+	assert.Error(t, err)
+	reloaded.LogLevel = "info"
+	reloaded.TxPool.GlobalQueue = 0
+	_, _, err = running.ReloadChanges(reloaded)
+	assert.Error(t, err)
+}
+
+func TestReloadLogModules(t *testing.T) {
+	running := NewClusterConfig()
+	reloaded := NewClusterConfig()
+	assert.NoError(t, json.Unmarshal([]byte(`{"LOG_MODULES":{"p2p":"debug","sync":"warn"}}`), reloaded))
+	changes, restart, err := running.ReloadChanges(reloaded)
+	assert.NoError(t, err)
+	assert.Empty(t, restart)
+	assert.Equal(t, []ConfigChange{{SettingLogModules, running.LogModules, reloaded.LogModules}}, changes)
+
+	running.LogModules = map[string]string{}
+	changes, _, err = running.ReloadChanges(NewClusterConfig())
+	assert.NoError(t, err)
+	assert.Empty(t, changes)
+
+	reloaded.LogModules["eth"] = "debug"
+	_, _, err = running.ReloadChanges(reloaded)
+	assert.Error(t, err)
+	delete(reloaded.LogModules, "eth")
+	reloaded.LogModules["p2p"] = "loud"
+	_, _, err = running.ReloadChanges(reloaded)
+	assert.Error(t, err)
+}
+
+func TestValidateMetrics(t *testing.T) {
+	cfg := NewMonitoringConfig()
+	assert.NoError(t, cfg.ValidateMetrics())
+	cfg.MetricsType = MetricsInfluxDB
+	cfg.MetricsEndpoint = "localhost:8086"
+	assert.Error(t, cfg.ValidateMetrics())
+	cfg.MetricsEndpoint = "http://localhost:8086/write?db=quarkchain"
+	assert.NoError(t, cfg.ValidateMetrics())
+	cfg.MetricsType = MetricsGraphite
+	assert.Error(t, cfg.ValidateMetrics())
+	cfg.MetricsEndpoint = "localhost:2003"
+	assert.NoError(t, cfg.ValidateMetrics())
+	cfg.MetricsInterval = 0
+	assert.Error(t, cfg.ValidateMetrics())
+	cfg.MetricsInterval = 10
+	cfg.MetricsType = "statsd"
+	assert.Error(t, cfg.ValidateMetrics())
+}
+
+func TestForkCompatible(t *testing.T) {
+	stored := NewQuarkChainConfig()
+	stored.EnableEvmTimeStamp = 1000
+	stored.XShardGasDDOSFixRootHeight = 100
+
+	newcfg := NewQuarkChainConfig()
+	newcfg.EnableEvmTimeStamp = 1000
+	newcfg.XShardGasDDOSFixRootHeight = 100
+	assert.Nil(t, stored.CheckForkCompatible(newcfg, 200))
+
+	// rescheduling forks which are not active yet is allowed
+	newcfg.XShardGasDDOSFixRootHeight = 150
+	assert.Nil(t, stored.CheckForkCompatible(newcfg, 50))
+	// but not if either schedule is already active at the head
+	err := stored.CheckForkCompatible(newcfg, 120)
+	assert.NotNil(t, err)
+	assert.Equal(t, ForkXShardGasDDOSFix, err.Name)
+	assert.Equal(t, uint64(100), err.Stored)
+	assert.Equal(t, uint64(150), err.New)
+	assert.Nil(t, err.FullShardID)
+
+	// the forks activated by timestamp or height are checked by the shards,
+	// at their own heads
+	newcfg.XShardGasDDOSFixRootHeight = 100
+	newcfg.EnableEvmTimeStamp = 500
+	assert.Nil(t, stored.CheckForkCompatible(newcfg, 200))
+	assert.Nil(t, stored.CheckShardForkCompatible(newcfg, 1, 200, 10, 400))
+	err = stored.CheckShardForkCompatible(newcfg, 1, 200, 10, 600)
+	assert.NotNil(t, err)
+	assert.Equal(t, ForkEnableEvm, err.Name)
+	assert.Equal(t, ActivateByTimestamp, err.ActivateBy)
+	assert.Equal(t, uint32(1), *err.FullShardID)
+
+	assert.True(t, newcfg.IsEvmEnabled(500))
+	assert.False(t, newcfg.IsEvmEnabled(499))
+
+	newcfg.EnableEvmTimeStamp = 1000
+	stored.EnableQkcHashXHeight = 5000
+	newcfg.EnableQkcHashXHeight = 4000
+	assert.Nil(t, stored.CheckForkCompatible(newcfg, 4500))
+	assert.Nil(t, stored.CheckShardForkCompatible(newcfg, 1, 4500, 3000, 600))
+	err = stored.CheckShardForkCompatible(newcfg, 1, 10, 4500, 600)
+	assert.NotNil(t, err)
+	assert.Equal(t, ForkQkcHashX, err.Name)
+	assert.Equal(t, uint64(4500), err.Height)
+	newcfg.EnableQkcHashXHeight = 5000
+
+	// the EVM rule sets are scheduled per chain, Berlin implies Istanbul
+	newcfg.Chains[1].EvmBerlinTimeStamp = 3000
+	assert.Nil(t, stored.CheckShardForkCompatible(newcfg, 1<<16, 200, 10, 2000))
+	assert.Nil(t, stored.CheckShardForkCompatible(newcfg, 1, 200, 10, 3000))
+	err = stored.CheckShardForkCompatible(newcfg, 1<<16, 200, 10, 3000)
+	assert.NotNil(t, err)
+	assert.Equal(t, ForkEvmIstanbul, err.Name)
+	assert.Equal(t, uint32(1), *err.ChainID)
+	assert.Equal(t, uint64(NeverActive), err.Stored)
+
+	assert.True(t, newcfg.IsEvmIstanbul(1, 3000))
+	assert.True(t, newcfg.IsEvmBerlin(1, 3000))
+	assert.False(t, newcfg.IsEvmIstanbul(1, 2999))
+	assert.False(t, newcfg.IsEvmIstanbul(0, 3000))
+	newcfg.Chains[1].EvmIstanbulTimeStamp = 2000
+	assert.True(t, newcfg.IsEvmIstanbul(1, 2000))
+	assert.False(t, newcfg.IsEvmBerlin(1, 2999))
+
+	// the x-shard deposit refunds need both the depth and the fork height
+	newcfg.XShardDepositRefundDepth = 10
+	assert.False(t, newcfg.IsXShardDepositRefund(1000))
+	newcfg.XShardDepositRefundRootHeight = 300
+	assert.True(t, newcfg.IsXShardDepositRefund(300))
+	assert.False(t, newcfg.IsXShardDepositRefund(299))
+	err = stored.CheckForkCompatible(newcfg, 300)
+	assert.NotNil(t, err)
+	assert.Equal(t, ForkXShardDepositRefund, err.Name)
+	newcfg.XShardDepositRefundDepth = 0
+	assert.False(t, newcfg.IsXShardDepositRefund(300))
+}
+
+func TestDecodeClusterConfig(t *testing.T) {
+	files := map[string]string{
+		FormatJSON: `{"P2P_PORT": 38292, "LOG_LEVEL": "${LEVEL}", "TX_POOL": {"GLOBAL_SLOTS": 1000},
+			"SLAVE_LIST": [{"ID": "S0", "HOST": "10.0.0.1", "PORT": 38000, "CHAIN_MASK_LIST": [1]}]}`,
+		FormatYAML: `
+P2P_PORT: 38292
+LOG_LEVEL: ${LEVEL}
+TX_POOL:
+  GLOBAL_SLOTS: 1_000
+SLAVE_LIST:
+  - ID: S0
+    HOST: 10.0.0.1
+    PORT: 38000
+    CHAIN_MASK_LIST: [1]
+`,
+		FormatTOML: `
+P2P_PORT = 38292
+LOG_LEVEL = "${LEVEL}"
+
+[TX_POOL]
+GLOBAL_SLOTS = 1_000
+
+[[SLAVE_LIST]]
+ID = "S0"
+HOST = "10.0.0.1"
+PORT = 38000
+CHAIN_MASK_LIST = [1]
+`,
+	}
+	env := []string{
+		"LEVEL=debug",
+		"QKC_JSON_RPC_PORT=8000",
+		"QKC_QUARKCHAIN__NETWORK_ID=7",
+		"QKC_QUARKCHAIN__ROOT__COINBASE_AMOUNT=123456789012345678901234567890",
+		"QKC_slave_list__0__host=10.0.0.2",
+	}
+	amount, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	for format, content := range files {
+		cfg := NewClusterConfig()
+		assert.NoError(t, DecodeClusterConfig([]byte(content), format, env, cfg), format)
+		assert.Equal(t, uint16(38292), cfg.P2PPort, format)
+		assert.Equal(t, uint16(8000), cfg.JSONRPCPort, format)
+		assert.Equal(t, "debug", cfg.LogLevel, format)
+		assert.Equal(t, uint64(1000), cfg.TxPool.GlobalSlots, format)
+		// the quarkchain config missing in the file is the default one
+		assert.Equal(t, uint32(7), cfg.Quarkchain.NetworkID, format)
+		assert.Equal(t, amount, cfg.Quarkchain.Root.CoinbaseAmount, format)
+		assert.Equal(t, NewQuarkChainConfig().ChainSize, cfg.Quarkchain.ChainSize, format)
+		if assert.Len(t, cfg.SlaveList, 1, format) {
+			assert.Equal(t, "10.0.0.2", cfg.SlaveList[0].IP, format)
+			assert.Equal(t, uint32(1), cfg.SlaveList[0].ChainMaskList[0].GetMask(), format)
+		}
+	}
+
+	// JSON files load as before
+	content, err := ioutil.ReadFile("test_config.json")
+	assert.NoError(t, err)
+	cfg, loaded := NewClusterConfig(), NewClusterConfig()
+	assert.NoError(t, json.Unmarshal(content, cfg))
+	assert.NoError(t, LoadClusterConfig("test_config.json", loaded))
+	want, _ := json.Marshal(cfg)
+	have, _ := json.Marshal(loaded)
+	assert.JSONEq(t, string(want), string(have))
+
+	assert.Error(t, DecodeClusterConfig([]byte(files[FormatJSON]), FormatJSON, nil, cfg))
+	assert.Error(t, DecodeClusterConfig([]byte(files[FormatJSON]), FormatJSON,
+		[]string{"LEVEL=info", "QKC_SLAVE_LIST__1__PORT=1"}, cfg))
+	assert.Equal(t, FormatYAML, FileFormat("cluster.yml"))
+	assert.Equal(t, FormatTOML, FileFormat("cluster.TOML"))
+	assert.Equal(t, FormatJSON, FileFormat("cluster.json"))
+}
+
+func TestSetDevMode(t *testing.T) {
+	accounts, err := DevAccounts(DevMnemonic, 3)
+	assert.NoError(t, err)
+	again, err := DevAccounts(DevMnemonic, 3)
+	assert.NoError(t, err)
+	assert.Len(t, accounts, 3)
+	assert.Equal(t, accounts[2].QKCAddress, again[2].QKCAddress)
+	assert.NotEqual(t, accounts[0].QKCAddress.Recipient, accounts[1].QKCAddress.Recipient)
+
+	cfg := NewClusterConfig()
+	assert.Error(t, cfg.SetDevMode(3, 0, accounts))
+	assert.Error(t, cfg.SetDevMode(1, 0, nil))
+	assert.NoError(t, cfg.SetDevMode(2, 0, accounts))
+	assert.NoError(t, cfg.Validate())
+	assert.Len(t, cfg.SlaveList, 2)
+	assert.Equal(t, "", cfg.GenesisDir)
+	assert.True(t, cfg.Quarkchain.Root.ConsensusConfig.MineOnDemand)
+	assert.Equal(t, PoWSimulate, cfg.Quarkchain.Root.ConsensusType)
+	for _, fullShardID := range cfg.Quarkchain.GetGenesisShardIds() {
+		shard := cfg.Quarkchain.GetShardConfigByFullShardID(fullShardID)
+		assert.True(t, shard.ConsensusConfig.MineOnDemand)
+		assert.Len(t, shard.Genesis.Alloc, 3)
+		alloc := shard.Genesis.Alloc[accounts[1].QKCAddress.AddressInShard(fullShardID)]
+		assert.Equal(t, DevBalance, alloc.Balances[cfg.Quarkchain.GenesisToken])
+	}
+
+	assert.NoError(t, cfg.SetDevMode(1, 5, accounts))
+	assert.Len(t, cfg.SlaveList, 1)
+	assert.False(t, cfg.Quarkchain.Root.ConsensusConfig.MineOnDemand)
+	assert.Equal(t, uint32(5), cfg.Quarkchain.Root.ConsensusConfig.TargetBlockTime)
+}
+
+func TestShardActivation(t *testing.T) {
+	cfg := NewClusterConfig()
+	cfg.Quarkchain.Update(2, 2, 10, 1)
+	assert.NoError(t, cfg.Validate())
+	for _, fullShardID := range cfg.Quarkchain.GetGenesisShardIds() {
+		if fullShardID>>16 == 1 {
+			cfg.Quarkchain.GetShardConfigByFullShardID(fullShardID).Genesis.RootHeight = 10
+		}
+	}
+	assert.NoError(t, cfg.Validate())
+	assert.Len(t, cfg.Quarkchain.GetInitializedShardIdsBeforeRootHeight(10), 2)
+	assert.Len(t, cfg.Quarkchain.GetInitializedShardIdsBeforeRootHeight(11), 4)
+
+	for _, fullShardID := range cfg.Quarkchain.GetGenesisShardIds() {
+		cfg.Quarkchain.GetShardConfigByFullShardID(fullShardID).Genesis.RootHeight = 10
+	}
+	assert.Error(t, cfg.Validate())
+}
+
+func TestNATMechanism(t *testing.T) {
+	cfg := NewClusterConfig()
+	// no port is mapped by default, nor with UPNP turned off
+	assert.Equal(t, "none", cfg.P2P.NATMechanism())
+	assert.NoError(t, json.Unmarshal([]byte(`{"UPNP": false}`), cfg.P2P))
+	assert.Equal(t, "none", cfg.P2P.NATMechanism())
+	cfg.P2P.UPnP = true
+	assert.Equal(t, "upnp", cfg.P2P.NATMechanism())
+	cfg.P2P.NAT = "extip:1.2.3.4"
+	assert.Equal(t, "extip:1.2.3.4", cfg.P2P.NATMechanism())
+	cfg.P2P.UPnP = false
+	cfg.P2P.NAT = "any"
+	assert.Equal(t, "any", cfg.P2P.NATMechanism())
+	assert.NoError(t, cfg.Validate())
+
+	cfg.P2P.NAT = "extip:nowhere"
+	assert.Error(t, cfg.Validate())
+	cfg.P2P.NAT = "router"
+	assert.Error(t, cfg.Validate())
+}
+
+func TestStaticTrustedNodes(t *testing.T) {
+	const (
+		node1 = "enode://a979fb575495b8d6db44f750317d0f4622bf4c2aa3365d6af7c284339968eef29b69ad0dce72a4d8db5ebb4968de0e3bec910127f134779fbcb0cb6d3331163c@52.16.188.185:30303"
+		node2 = "enode://3f1d12044546b76342d59d4a05532c14b85aa669704bfe1f864fe079415aa2c02d743e03218e57a33fb94523adb54032871a6c51b2cc5514cb7c7e35b3ed0a99@13.93.211.84:30303"
+	)
+	cfg := NewClusterConfig()
+	nodes, err := cfg.P2P.GetStaticNodes()
+	assert.NoError(t, err)
+	assert.Empty(t, nodes)
+
+	cfg.P2P.StaticNodes = node1 + ", " + node2
+	cfg.P2P.TrustedNodes = node2
+	nodes, err = cfg.P2P.GetStaticNodes()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(nodes))
+	assert.Equal(t, "52.16.188.185", nodes[0].IP().String())
+	nodes, err = cfg.P2P.GetTrustedNodes()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(nodes))
+	assert.NoError(t, cfg.Validate())
+
+	cfg.P2P.TrustedNodes = "enode://nokey@1.2.3.4:30303"
+	assert.Error(t, cfg.Validate())
+
+	cfg.P2P.TrustedNodes = ""
+	cfg.P2P.BootNodesV5 = node1
+	bootNodes, err := cfg.P2P.GetBootNodesV5()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(bootNodes))
+	assert.Equal(t, uint16(30303), bootNodes[0].UDP)
+	assert.NoError(t, cfg.Validate())
+	cfg.P2P.BootNodesV5 = "enode://nokey@1.2.3.4:30303"
+	assert.Error(t, cfg.Validate())
+}
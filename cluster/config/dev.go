@@ -0,0 +1,102 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/params"
+)
+
+// DevMnemonic is the default mnemonic of the prefunded accounts of the dev
+// cluster, the keys of which are public, so they must never hold real funds.
+const DevMnemonic = "test test test test test test test test test test test junk"
+
+// DevBalance is the genesis balance of each prefunded account of the dev
+// cluster in every shard.
+var DevBalance = new(big.Int).Mul(big.NewInt(1000000), params.DenomsValue.Ether)
+
+// DevAccounts derives the count accounts of the mnemonic at the default
+// derivation path, which are on their default full shard keys.
+func DevAccounts(mnemonic string, count int) ([]account.Account, error) {
+	wallet, err := account.NewHDWallet(mnemonic, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	accounts := make([]account.Account, 0, count)
+	for index := uint32(0); len(accounts) < count; index++ {
+		identity, err := wallet.Identity(index)
+		if err == account.ErrInvalidChildKey {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		acc, err := account.NewAccountWithKey(identity.GetKey())
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, acc)
+	}
+	return accounts, nil
+}
+
+// SetDevMode turns the config into a dev cluster of numSlaves local slaves,
+// which mines simulated blocks every period seconds, or on demand if the
+// period is 0, and funds the accounts in every shard at genesis. The chains
+// are reset to the default ones of the chain and shard sizes of the config.
+func (c *ClusterConfig) SetDevMode(numSlaves int, period uint32, accounts []account.Account) error {
+	if numSlaves <= 0 || numSlaves&(numSlaves-1) != 0 {
+		return fmt.Errorf("number of slaves %d is not a power of 2", numSlaves)
+	}
+	if len(accounts) == 0 {
+		return errors.New("dev cluster needs a prefunded account")
+	}
+	shardSize := uint32(1)
+	if chain, ok := c.Quarkchain.Chains[0]; ok {
+		shardSize = chain.ShardSize
+	}
+	blockTime := period
+	if period == 0 {
+		blockTime = 1
+	}
+	c.Quarkchain.Update(c.Quarkchain.ChainSize, shardSize, blockTime, blockTime)
+	c.Quarkchain.SkipRootDifficultyCheck = true
+	c.Quarkchain.SkipMinorDifficultyCheck = true
+	c.Quarkchain.Root.ConsensusConfig.MineOnDemand = period == 0
+
+	fullShardIDs := c.Quarkchain.GetGenesisShardIds()
+	sort.Slice(fullShardIDs, func(i, j int) bool { return fullShardIDs[i] < fullShardIDs[j] })
+	for _, chain := range c.Quarkchain.Chains {
+		chain.ConsensusConfig.MineOnDemand = period == 0
+	}
+	for _, fullShardID := range fullShardIDs {
+		shard := c.Quarkchain.GetShardConfigByFullShardID(fullShardID)
+		shard.ConsensusConfig.MineOnDemand = period == 0
+		shard.CoinbaseAddress = accounts[0].QKCAddress.AddressInShard(fullShardID)
+		if shard.Genesis.Alloc == nil {
+			shard.Genesis.Alloc = make(map[account.Address]Allocation)
+		}
+		for _, acc := range accounts {
+			shard.Genesis.Alloc[acc.QKCAddress.AddressInShard(fullShardID)] = Allocation{
+				Balances: map[string]*big.Int{c.Quarkchain.GenesisToken: new(big.Int).Set(DevBalance)},
+			}
+		}
+	}
+	c.Quarkchain.Root.CoinbaseAddress = accounts[0].QKCAddress.AddressInShard(fullShardIDs[0])
+
+	c.SlaveList = make([]*SlaveConfig, 0, numSlaves)
+	for i := 0; i < numSlaves; i++ {
+		slave := NewDefaultSlaveConfig()
+		slave.Port = slavePort + uint16(i)
+		slave.ID = fmt.Sprintf("S%d", i)
+		slave.ChainMaskList = append(slave.ChainMaskList, types.NewChainMask(uint32(i|numSlaves)))
+		c.SlaveList = append(c.SlaveList, slave)
+	}
+	c.GenesisDir = ""
+	c.EnableTransactionHistory = true
+	return nil
+}
@@ -0,0 +1,356 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/naoina/toml"
+	"github.com/naoina/toml/ast"
+	"gopkg.in/yaml.v2"
+)
+
+// Formats of the cluster config files.
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+)
+
+// EnvOverridePrefix prefixes the environment variables overriding the fields
+// of the cluster config, e.g. QKC_P2P_PORT, or QKC_QUARKCHAIN__NETWORK_ID and
+// QKC_SLAVE_LIST__0__PORT for the nested fields, which are separated by "__".
+const EnvOverridePrefix = "QKC_"
+
+var envRefRegexp = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// FileFormat returns the format of the config file by its extension, files
+// other than YAML and TOML are JSON.
+func FileFormat(file string) string {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".toml":
+		return FormatTOML
+	}
+	return FormatJSON
+}
+
+// LoadClusterConfig loads the cluster config file over the config, see
+// DecodeClusterConfig, with the environment of the process.
+func LoadClusterConfig(file string, cfg *ClusterConfig) error {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return errors.New(file + ", " + err.Error())
+	}
+	if err := DecodeClusterConfig(content, FileFormat(file), os.Environ(), cfg); err != nil {
+		return fmt.Errorf("%s, %v", file, err)
+	}
+	return nil
+}
+
+// DecodeClusterConfig decodes the cluster config in the format over the
+// config. The ${VAR} references in the content are replaced by the variables
+// of the environment, and the QKC_* variables override the fields, whose
+// values are JSON or else strings.
+func DecodeClusterConfig(content []byte, format string, env []string, cfg *ClusterConfig) error {
+	vars := make(map[string]string, len(env))
+	for _, kv := range env {
+		if i := strings.IndexByte(kv, '='); i > 0 {
+			vars[kv[:i]] = kv[i+1:]
+		}
+	}
+	content, err := expandEnvRefs(content, vars)
+	if err != nil {
+		return err
+	}
+
+	var obj map[string]interface{}
+	switch format {
+	case FormatJSON:
+		dec := json.NewDecoder(bytes.NewReader(content))
+		dec.UseNumber()
+		err = dec.Decode(&obj)
+	case FormatYAML:
+		var v yamlValue
+		if err = yaml.Unmarshal(content, &v); err == nil {
+			m, ok := v.value.(map[string]interface{})
+			if !ok && v.value != nil {
+				return errors.New("config is not a YAML mapping")
+			}
+			obj = m
+		}
+	case FormatTOML:
+		var table *ast.Table
+		if table, err = toml.Parse(content); err == nil {
+			obj, err = tomlTable(table)
+		}
+	default:
+		return fmt.Errorf("unknown config format %q", format)
+	}
+	if err != nil {
+		return err
+	}
+	if obj == nil {
+		obj = make(map[string]interface{})
+	}
+	if err := applyEnvOverrides(obj, vars); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, cfg)
+}
+
+func expandEnvRefs(content []byte, vars map[string]string) ([]byte, error) {
+	var missing []string
+	content = envRefRegexp.ReplaceAllFunc(content, func(ref []byte) []byte {
+		name := string(ref[2 : len(ref)-1])
+		value, ok := vars[name]
+		if !ok {
+			missing = append(missing, name)
+		}
+		return []byte(value)
+	})
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("undefined environment variables: %s", strings.Join(missing, ", "))
+	}
+	return content, nil
+}
+
+// applyEnvOverrides sets the fields overridden by the QKC_* variables in the
+// JSON object of the config. The objects missing in the config are filled by
+// the default config, as some of them are replaced as a whole.
+func applyEnvOverrides(obj map[string]interface{}, vars map[string]string) error {
+	names := make([]string, 0)
+	for name := range vars {
+		if strings.HasPrefix(name, EnvOverridePrefix) && len(name) > len(EnvOverridePrefix) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+
+	data, err := json.Marshal(NewClusterConfig())
+	if err != nil {
+		return err
+	}
+	var defaults map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&defaults); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		path := strings.Split(strings.TrimPrefix(name, EnvOverridePrefix), "__")
+		var value interface{}
+		dec := json.NewDecoder(strings.NewReader(vars[name]))
+		dec.UseNumber()
+		if err := dec.Decode(&value); err != nil || dec.More() {
+			value = vars[name]
+		}
+		if err := setPath(obj, defaults, path, value); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// setPath sets the value at the path of the JSON object, matching the keys
+// case-insensitively, the containers missing on the path are copied from the
+// defaults.
+func setPath(node, defaults interface{}, path []string, value interface{}) error {
+	for i, key := range path {
+		last := i == len(path)-1
+		switch n := node.(type) {
+		case map[string]interface{}:
+			key = matchKey(n, key)
+			var def interface{}
+			if d, ok := defaults.(map[string]interface{}); ok {
+				def = d[matchKey(d, key)]
+			}
+			if last {
+				n[key] = value
+				return nil
+			}
+			if _, ok := n[key]; !ok {
+				if def == nil {
+					n[key] = make(map[string]interface{})
+				} else {
+					n[key] = deepCopyJSON(def)
+				}
+			}
+			node, defaults = n[key], def
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(n) {
+				return fmt.Errorf("invalid index %s of %s", key, strings.Join(path[:i], "."))
+			}
+			if last {
+				n[index] = value
+				return nil
+			}
+			var def interface{}
+			if d, ok := defaults.([]interface{}); ok && index < len(d) {
+				def = d[index]
+			}
+			node, defaults = n[index], def
+		default:
+			return fmt.Errorf("%s is not an object or array", strings.Join(path[:i], "."))
+		}
+	}
+	return nil
+}
+
+func matchKey(obj map[string]interface{}, key string) string {
+	if _, ok := obj[key]; ok {
+		return key
+	}
+	for k := range obj {
+		if strings.EqualFold(k, key) {
+			return k
+		}
+	}
+	return key
+}
+
+func deepCopyJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			m[k] = deepCopyJSON(item)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, item := range v {
+			s[i] = deepCopyJSON(item)
+		}
+		return s
+	}
+	return v
+}
+
+// yamlValue decodes a YAML value into its JSON value, keeping the text of the
+// numbers, which may not fit into 64 bits.
+type yamlValue struct {
+	value interface{}
+}
+
+func (y *yamlValue) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var resolved interface{}
+	if err := unmarshal(&resolved); err != nil {
+		return err
+	}
+	switch resolved.(type) {
+	case map[interface{}]interface{}:
+		var values map[string]yamlValue
+		if err := unmarshal(&values); err != nil {
+			return err
+		}
+		obj := make(map[string]interface{}, len(values))
+		for k, v := range values {
+			obj[k] = v.value
+		}
+		y.value = obj
+	case []interface{}:
+		var values []yamlValue
+		if err := unmarshal(&values); err != nil {
+			return err
+		}
+		items := make([]interface{}, len(values))
+		for i, v := range values {
+			items[i] = v.value
+		}
+		y.value = items
+	case int, int64, uint64, float64:
+		var text string
+		if err := unmarshal(&text); err != nil {
+			return err
+		}
+		text = strings.TrimPrefix(strings.Replace(text, "_", "", -1), "+")
+		if json.Valid([]byte(text)) {
+			y.value = json.Number(text)
+		} else {
+			y.value = resolved
+		}
+	default:
+		y.value = resolved
+	}
+	return nil
+}
+
+func tomlTable(table *ast.Table) (map[string]interface{}, error) {
+	obj := make(map[string]interface{}, len(table.Fields))
+	for key, field := range table.Fields {
+		var err error
+		switch f := field.(type) {
+		case *ast.KeyValue:
+			obj[key], err = tomlValue(f.Value)
+		case *ast.Table:
+			obj[key], err = tomlTable(f)
+		case []*ast.Table:
+			items := make([]interface{}, len(f))
+			for i, t := range f {
+				if items[i], err = tomlTable(t); err != nil {
+					break
+				}
+			}
+			obj[key] = items
+		default:
+			err = fmt.Errorf("unknown field %s", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return obj, nil
+}
+
+func tomlValue(value ast.Value) (interface{}, error) {
+	switch v := value.(type) {
+	case *ast.String:
+		return v.Value, nil
+	case *ast.Integer:
+		return tomlNumber(v.Value)
+	case *ast.Float:
+		return tomlNumber(v.Value)
+	case *ast.Boolean:
+		return v.Boolean()
+	case *ast.Datetime:
+		return v.Value, nil
+	case *ast.Array:
+		items := make([]interface{}, len(v.Value))
+		for i, item := range v.Value {
+			var err error
+			if items[i], err = tomlValue(item); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("unknown value %s", value.Source())
+}
+
+func tomlNumber(text string) (interface{}, error) {
+	text = strings.TrimPrefix(text, "+")
+	if !json.Valid([]byte(text)) {
+		return nil, fmt.Errorf("invalid number %s", text)
+	}
+	return json.Number(text), nil
+}
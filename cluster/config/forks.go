@@ -0,0 +1,290 @@
+package config
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	// ForkEnableEvm enables the EVM rule set, the smart contracts and the
+	// precompiled contracts of QuarkChain.
+	ForkEnableEvm = "ENABLE_EVM"
+	// ForkQkcHashX switches the qkchash mining algorithm to qkchashX.
+	ForkQkcHashX = "QKCHASHX"
+	// ForkXShardGasDDOSFix charges the gas of the x-shard deposits from the
+	// root chain separately.
+	ForkXShardGasDDOSFix = "XSHARD_GAS_DDOS_FIX"
+	// ForkRootHeaviestChain switches the root chain fork choice from the longest
+	// chain to the heaviest chain.
+	ForkRootHeaviestChain = "ROOT_HEAVIEST_CHAIN"
+	// ForkXShardCall enables the precompiled contract sending x-shard deposits
+	// from the contracts.
+	ForkXShardCall = "XSHARD_CALL"
+	// ForkXShardDepositRefund returns the x-shard deposits not received after
+	// XSHARD_DEPOSIT_REFUND_DEPTH root blocks to their senders.
+	ForkXShardDepositRefund = "XSHARD_DEPOSIT_REFUND"
+	// ForkEvmIstanbul enables the Istanbul rule set of the EVM on a chain: the
+	// op codes and gas costs of EIP-1344, 1884, 2028 and 2200, the BLAKE2F
+	// precompiled contract of EIP-152 and the bn256 prices of EIP-1108.
+	ForkEvmIstanbul = "EVM_ISTANBUL"
+	// ForkEvmBerlin enables the Berlin rule set of the EVM, which includes the
+	// Istanbul one, on a chain: the access list gas of EIP-2929 and the modexp
+	// price of EIP-2565. The access list txs of EIP-2930 are not part of it as
+	// the txs of QuarkChain have no typed envelope to carry the list.
+	ForkEvmBerlin = "EVM_BERLIN"
+	// ForkBlockLimits lets the miners of a chain vote on its gas limit, and
+	// enforces its block size and tx count limits.
+	ForkBlockLimits = "BLOCK_LIMITS"
+)
+
+// NeverActive is the activation of the forks which are disabled.
+const NeverActive = math.MaxUint64
+
+// ForkActivation tells what a fork is scheduled by.
+type ForkActivation uint8
+
+const (
+	// ActivateByRootHeight activates the fork at a root block height.
+	ActivateByRootHeight ForkActivation = iota
+	// ActivateByHeight activates the fork at the same height of every chain.
+	ActivateByHeight
+	// ActivateByTimestamp activates the fork at a block timestamp.
+	ActivateByTimestamp
+)
+
+func (a ForkActivation) String() string {
+	switch a {
+	case ActivateByRootHeight:
+		return "rootHeight"
+	case ActivateByHeight:
+		return "height"
+	case ActivateByTimestamp:
+		return "timestamp"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(a))
+	}
+}
+
+// Fork is a protocol upgrade of the cluster, or of a single chain if ChainID is
+// set, which is active from its activation height or timestamp on.
+type Fork struct {
+	Name       string
+	ActivateBy ForkActivation
+	Activation uint64
+	ChainID    *uint32
+}
+
+func (f *Fork) String() string {
+	if f.ChainID == nil {
+		return f.Name
+	}
+	return fmt.Sprintf("%s(chain %d)", f.Name, *f.ChainID)
+}
+
+// Active returns whether the fork is active at the given position of the chain.
+func (f *Fork) Active(rootHeight, height, timestamp uint64) bool {
+	switch f.ActivateBy {
+	case ActivateByRootHeight:
+		return rootHeight >= f.Activation
+	case ActivateByHeight:
+		return height >= f.Activation
+	default:
+		return timestamp >= f.Activation
+	}
+}
+
+// ForkSchedule returns the forks of the cluster, followed by the forks of each
+// chain, in a fixed order.
+func (q *QuarkChainConfig) ForkSchedule() []*Fork {
+	forks := []*Fork{
+		{Name: ForkEnableEvm, ActivateBy: ActivateByTimestamp, Activation: q.EnableEvmTimeStamp},
+		{Name: ForkQkcHashX, ActivateBy: ActivateByHeight, Activation: q.EnableQkcHashXHeight},
+		{Name: ForkXShardGasDDOSFix, ActivateBy: ActivateByRootHeight, Activation: q.XShardGasDDOSFixRootHeight},
+		{Name: ForkRootHeaviestChain, ActivateBy: ActivateByRootHeight, Activation: q.RootHeaviestChainForkHeight},
+		{Name: ForkXShardCall, ActivateBy: ActivateByTimestamp, Activation: q.xShardCallActivation()},
+		{Name: ForkXShardDepositRefund, ActivateBy: ActivateByRootHeight, Activation: q.xShardDepositRefundActivation()},
+	}
+	for chainID := uint32(0); chainID < q.ChainSize; chainID++ {
+		chain, ok := q.Chains[chainID]
+		if !ok {
+			continue
+		}
+		id := chainID
+		forks = append(forks,
+			&Fork{Name: ForkEvmIstanbul, ActivateBy: ActivateByTimestamp, Activation: chain.evmIstanbulActivation(), ChainID: &id},
+			&Fork{Name: ForkEvmBerlin, ActivateBy: ActivateByTimestamp, Activation: chain.evmBerlinActivation(), ChainID: &id},
+			&Fork{Name: ForkBlockLimits, ActivateBy: ActivateByTimestamp, Activation: chain.blockLimitsActivation(), ChainID: &id},
+		)
+	}
+	return forks
+}
+
+// IsEvmEnabled returns whether the EVM rule set applies to the block with the timestamp.
+func (q *QuarkChainConfig) IsEvmEnabled(timestamp uint64) bool {
+	return timestamp >= q.EnableEvmTimeStamp
+}
+
+// IsQkcHashX returns whether the blocks at the height are mined with qkchashX.
+func (q *QuarkChainConfig) IsQkcHashX(height uint64) bool {
+	return height >= q.EnableQkcHashXHeight
+}
+
+// IsXShardGasDDOSFixed returns whether the x-shard gas fix applies to the deposits
+// confirmed by the root block at the height.
+func (q *QuarkChainConfig) IsXShardGasDDOSFixed(rootHeight uint64) bool {
+	return rootHeight >= q.XShardGasDDOSFixRootHeight
+}
+
+// IsRootHeaviestChain returns whether the root blocks at the height are chosen by
+// the heaviest chain rule.
+func (q *QuarkChainConfig) IsRootHeaviestChain(rootHeight uint64) bool {
+	return rootHeight >= q.RootHeaviestChainForkHeight
+}
+
+// IsXShardCallEnabled returns whether the contracts executed in the block with
+// the timestamp can send x-shard deposits.
+func (q *QuarkChainConfig) IsXShardCallEnabled(timestamp uint64) bool {
+	return timestamp >= q.xShardCallActivation()
+}
+
+func (q *QuarkChainConfig) xShardCallActivation() uint64 {
+	if q.XShardCallTimeStamp == 0 {
+		return NeverActive
+	}
+	return q.XShardCallTimeStamp
+}
+
+// IsXShardDepositRefund returns whether the x-shard deposits not received after
+// XSHARD_DEPOSIT_REFUND_DEPTH root blocks are refunded by the blocks confirmed
+// by the root block at the height.
+func (q *QuarkChainConfig) IsXShardDepositRefund(rootHeight uint64) bool {
+	return q.XShardDepositRefundDepth != 0 && rootHeight >= q.xShardDepositRefundActivation()
+}
+
+func (q *QuarkChainConfig) xShardDepositRefundActivation() uint64 {
+	if q.XShardDepositRefundRootHeight == 0 {
+		return NeverActive
+	}
+	return q.XShardDepositRefundRootHeight
+}
+
+// IsEvmIstanbul returns whether the Istanbul rule set of the EVM applies to the
+// block of the chain with the timestamp.
+func (q *QuarkChainConfig) IsEvmIstanbul(chainID uint32, timestamp uint64) bool {
+	chain, ok := q.Chains[chainID]
+	return ok && timestamp >= chain.evmIstanbulActivation()
+}
+
+// IsEvmBerlin returns whether the Berlin rule set of the EVM applies to the block
+// of the chain with the timestamp.
+func (q *QuarkChainConfig) IsEvmBerlin(chainID uint32, timestamp uint64) bool {
+	chain, ok := q.Chains[chainID]
+	return ok && timestamp >= chain.evmBerlinActivation()
+}
+
+// IsBlockLimits returns whether the gas limit of the block of the chain with the
+// timestamp is voted by its miner, and its size and tx count are limited.
+func (q *QuarkChainConfig) IsBlockLimits(chainID uint32, timestamp uint64) bool {
+	chain, ok := q.Chains[chainID]
+	return ok && timestamp >= chain.blockLimitsActivation()
+}
+
+func (c *ChainConfig) blockLimitsActivation() uint64 {
+	if c.BlockLimitsTimeStamp == 0 {
+		return NeverActive
+	}
+	return c.BlockLimitsTimeStamp
+}
+
+func (c *ChainConfig) evmBerlinActivation() uint64 {
+	if c.EvmBerlinTimeStamp == 0 {
+		return NeverActive
+	}
+	return c.EvmBerlinTimeStamp
+}
+
+// evmIstanbulActivation returns the timestamp of the Istanbul rule set, which is
+// also activated by the Berlin one.
+func (c *ChainConfig) evmIstanbulActivation() uint64 {
+	activation := uint64(NeverActive)
+	if c.EvmIstanbulTimeStamp != 0 {
+		activation = c.EvmIstanbulTimeStamp
+	}
+	if berlin := c.evmBerlinActivation(); berlin < activation {
+		return berlin
+	}
+	return activation
+}
+
+// ForkCompatError is raised if the fork schedule is changed for a fork which is
+// already active, or would already be active, at the head of the local chain.
+type ForkCompatError struct {
+	Name       string
+	ChainID    *uint32
+	ActivateBy ForkActivation
+	Stored     uint64
+	New        uint64
+	// the shard of the local chain, nil for the root chain, and the root block
+	// height, the height and the timestamp of its head
+	FullShardID *uint32
+	RootHeight  uint64
+	Height      uint64
+	Timestamp   uint64
+}
+
+func (err *ForkCompatError) Error() string {
+	fork := &Fork{Name: err.Name, ChainID: err.ChainID}
+	if err.FullShardID == nil {
+		return fmt.Sprintf("mismatching %s fork in database (have %s %d, want %s %d, head root height %d)",
+			fork, err.ActivateBy, err.Stored, err.ActivateBy, err.New, err.RootHeight)
+	}
+	return fmt.Sprintf("mismatching %s fork in database of shard %d (have %s %d, want %s %d, head root height %d height %d timestamp %d)",
+		fork, *err.FullShardID, err.ActivateBy, err.Stored, err.ActivateBy, err.New, err.RootHeight, err.Height, err.Timestamp)
+}
+
+// CheckForkCompatible checks whether the fork schedule of newcfg can replace the
+// one of q for the root chain whose head is at the height. Only the forks
+// activated by root block height apply to the root chain, the others are checked
+// by the slaves against the heads of their shards, see CheckShardForkCompatible.
+func (q *QuarkChainConfig) CheckForkCompatible(newcfg *QuarkChainConfig, rootHeight uint64) *ForkCompatError {
+	root := func(fork *Fork) bool { return fork.ActivateBy == ActivateByRootHeight }
+	return q.checkForkCompatible(newcfg, root, nil, rootHeight, 0, 0)
+}
+
+// CheckShardForkCompatible checks whether the fork schedule of newcfg can replace
+// the one of q for the shard whose head is at the height and timestamp, and
+// confirms the root block at rootHeight. The forks of the cluster and of the
+// chain of the shard are checked.
+func (q *QuarkChainConfig) CheckShardForkCompatible(newcfg *QuarkChainConfig, fullShardID uint32, rootHeight, height, timestamp uint64) *ForkCompatError {
+	chainID := fullShardID >> 16
+	shard := func(fork *Fork) bool { return fork.ChainID == nil || *fork.ChainID == chainID }
+	return q.checkForkCompatible(newcfg, shard, &fullShardID, rootHeight, height, timestamp)
+}
+
+func (q *QuarkChainConfig) checkForkCompatible(newcfg *QuarkChainConfig, applies func(*Fork) bool, fullShardID *uint32,
+	rootHeight, height, timestamp uint64) *ForkCompatError {
+	stored := make(map[string]*Fork)
+	for _, fork := range q.ForkSchedule() {
+		stored[fork.String()] = fork
+	}
+	for _, fork := range newcfg.ForkSchedule() {
+		old, ok := stored[fork.String()]
+		if !ok || old.Activation == fork.Activation || !applies(fork) {
+			continue
+		}
+		if old.Active(rootHeight, height, timestamp) || fork.Active(rootHeight, height, timestamp) {
+			return &ForkCompatError{
+				Name:        fork.Name,
+				ChainID:     fork.ChainID,
+				ActivateBy:  fork.ActivateBy,
+				Stored:      old.Activation,
+				New:         fork.Activation,
+				FullShardID: fullShardID,
+				RootHeight:  rootHeight,
+				Height:      height,
+				Timestamp:   timestamp,
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,292 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/internal/debug"
+)
+
+// Settings changed by a config reload which take effect without a restart.
+const (
+	SettingLogLevel         = "LOG_LEVEL"
+	SettingLogModules       = "LOG_MODULES"
+	SettingRPCTxListLimit   = "RPC_TX_LIST_LIMIT"
+	SettingSlowRPCThreshold = "SLOW_RPC_THRESHOLD"
+	SettingTxPool           = "TX_POOL"
+	SettingRootCoinbase     = "QUARKCHAIN.ROOT.COINBASE_ADDRESS"
+	SettingChainCoinbase    = "QUARKCHAIN.CHAINS.COINBASE_ADDRESS" // suffixed by the chain id
+	SettingChainGasTarget   = "QUARKCHAIN.CHAINS.GAS_LIMIT_TARGET" // suffixed by the chain id
+	SettingSlaveEndpoint    = "SLAVE_LIST.ENDPOINT"                // suffixed by the slave id
+)
+
+// ConfigChange is a setting changed by a config reload.
+type ConfigChange struct {
+	Setting string      `json:"setting"`
+	Old     interface{} `json:"old"`
+	New     interface{} `json:"new"`
+}
+
+func (c ConfigChange) String() string {
+	return fmt.Sprintf("%s: %v -> %v", c.Setting, c.Old, c.New)
+}
+
+// SplitSetting splits the setting of a change into the setting and the chain
+// id or the slave id it is suffixed by, if any.
+func SplitSetting(setting string) (string, string) {
+	for _, prefix := range []string{SettingChainCoinbase, SettingChainGasTarget, SettingSlaveEndpoint} {
+		if strings.HasPrefix(setting, prefix+".") {
+			return prefix, strings.TrimPrefix(setting, prefix+".")
+		}
+	}
+	return setting, ""
+}
+
+// ReloadChanges validates the reloaded config, and returns the changes of the
+// settings which are safe to apply without restarting the cluster, and the
+// other settings changed, which take effect only after a restart.
+//
+// The settings safe to change are the log levels, the RPC limits, the slow RPC
+// threshold, the sizes of the tx pools, the coinbase addresses, the gas limit
+// targets of the chains, and the endpoints of the slaves. An empty coinbase
+// address keeps the one in use, which may have been set from the keystore.
+func (c *ClusterConfig) ReloadChanges(reloaded *ClusterConfig) ([]ConfigChange, []string, error) {
+	if err := reloaded.Validate(); err != nil {
+		return nil, nil, err
+	}
+	if reloaded.TxPool == nil {
+		return nil, nil, errors.New("missing tx pool config")
+	}
+
+	var changes []ConfigChange
+	if reloaded.LogLevel != c.LogLevel {
+		changes = append(changes, ConfigChange{SettingLogLevel, c.LogLevel, reloaded.LogLevel})
+	}
+	if !reflect.DeepEqual(nonNilLevels(reloaded.LogModules), nonNilLevels(c.LogModules)) {
+		changes = append(changes, ConfigChange{SettingLogModules, c.LogModules, reloaded.LogModules})
+	}
+	if reloaded.RPCTxListLimit != c.RPCTxListLimit {
+		changes = append(changes, ConfigChange{SettingRPCTxListLimit, c.RPCTxListLimit, reloaded.RPCTxListLimit})
+	}
+	if reloaded.SlowRPCThreshold != c.SlowRPCThreshold {
+		changes = append(changes, ConfigChange{SettingSlowRPCThreshold, c.SlowRPCThreshold, reloaded.SlowRPCThreshold})
+	}
+	if c.TxPool == nil || !reflect.DeepEqual(reloaded.TxPool, c.TxPool) {
+		changes = append(changes, ConfigChange{SettingTxPool, c.TxPool, reloaded.TxPool})
+	}
+	old, root := c.Quarkchain.Root.CoinbaseAddress, reloaded.Quarkchain.Root.CoinbaseAddress
+	if !root.IsEmpty() && root != old {
+		changes = append(changes, ConfigChange{SettingRootCoinbase, old.ToHex(), root.ToHex()})
+	}
+	for _, id := range sortedChainIDs(reloaded.Quarkchain.Chains) {
+		chain, ok := c.Quarkchain.Chains[id]
+		if !ok {
+			continue
+		}
+		old, coinbase := chain.CoinbaseAddress, reloaded.Quarkchain.Chains[id].CoinbaseAddress
+		if !coinbase.IsEmpty() && coinbase != old {
+			setting := fmt.Sprintf("%s.%d", SettingChainCoinbase, id)
+			changes = append(changes, ConfigChange{setting, old.ToHex(), coinbase.ToHex()})
+		}
+		if target := reloaded.Quarkchain.Chains[id].GasLimitTarget; target != chain.GasLimitTarget {
+			setting := fmt.Sprintf("%s.%d", SettingChainGasTarget, id)
+			changes = append(changes, ConfigChange{setting, chain.GasLimitTarget, target})
+		}
+	}
+	for _, slave := range reloaded.SlaveList {
+		running, err := c.GetSlaveConfig(slave.ID)
+		if err != nil || !sameChainMasks(running, slave) {
+			continue
+		}
+		old, endpoint := fmt.Sprintf("%s:%d", running.IP, running.Port), fmt.Sprintf("%s:%d", slave.IP, slave.Port)
+		if endpoint != old {
+			changes = append(changes, ConfigChange{SettingSlaveEndpoint + "." + slave.ID, old, endpoint})
+		}
+	}
+
+	restart, err := c.restartChanges(reloaded)
+	if err != nil {
+		return nil, nil, err
+	}
+	return changes, restart, nil
+}
+
+// SetChainCoinbase changes the coinbase address of the chain and its shards.
+func (q *QuarkChainConfig) SetChainCoinbase(chainID uint32, addr account.Address) {
+	if chain, ok := q.Chains[chainID]; ok {
+		chain.CoinbaseAddress = addr
+	}
+	for _, shard := range q.shards {
+		if shard.ChainID == chainID {
+			shard.CoinbaseAddress = addr
+		}
+	}
+}
+
+// SetChainGasLimitTarget changes the gas limit the miners of the chain vote for,
+// which the shards read while creating blocks.
+func (q *QuarkChainConfig) SetChainGasLimitTarget(chainID uint32, target uint64) {
+	if chain, ok := q.Chains[chainID]; ok {
+		atomic.StoreUint64(&chain.GasLimitTarget, target)
+	}
+	for _, shard := range q.shards {
+		if shard.ChainID == chainID {
+			atomic.StoreUint64(&shard.GasLimitTarget, target)
+		}
+	}
+}
+
+// ApplyLogLevel sets the verbosity of the logs of the node to the level.
+func ApplyLogLevel(level string) error {
+	return debug.SetLogLevel(level)
+}
+
+// ApplyLogModules sets the log levels of the modules logging apart from the
+// verbosity of the node.
+func ApplyLogModules(levels map[string]string) error {
+	return debug.SetLogModules(levels)
+}
+
+// SetLogLevel sets the verbosity of the node if module is empty, or else the
+// log level of the module, which logs at the verbosity again if level is
+// empty. The level is recorded in the config, so that a reload restores the
+// level of the config file.
+func (c *ClusterConfig) SetLogLevel(module, level string) error {
+	if module == "" {
+		if err := ApplyLogLevel(level); err != nil {
+			return err
+		}
+		c.LogLevel = level
+		return nil
+	}
+	if err := debug.SetModuleLogLevel(module, level); err != nil {
+		return err
+	}
+	levels := make(map[string]string, len(c.LogModules)+1)
+	for m, l := range c.LogModules {
+		levels[m] = l
+	}
+	if level == "" {
+		delete(levels, module)
+	} else {
+		levels[module] = level
+	}
+	c.LogModules = levels
+	return nil
+}
+
+// restartChanges returns the JSON paths of the settings changed other than the
+// ones reloaded.
+func (c *ClusterConfig) restartChanges(reloaded *ClusterConfig) ([]string, error) {
+	running, err := reloadJSON(c)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := reloadJSON(reloaded)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	diffJSON("", running, updated, &paths)
+	return paths, nil
+}
+
+// reloadJSON returns the JSON object of the config without the settings which
+// are reloaded.
+func reloadJSON(cfg *ClusterConfig) (map[string]interface{}, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, err
+	}
+	delete(obj, SettingLogLevel)
+	delete(obj, SettingLogModules)
+	delete(obj, SettingRPCTxListLimit)
+	delete(obj, SettingSlowRPCThreshold)
+	delete(obj, SettingTxPool)
+	if qkc, ok := obj["QUARKCHAIN"].(map[string]interface{}); ok {
+		if root, ok := qkc["ROOT"].(map[string]interface{}); ok {
+			delete(root, "COINBASE_ADDRESS")
+		}
+		if chains, ok := qkc["CHAINS"].([]interface{}); ok {
+			for _, chain := range chains {
+				if chain, ok := chain.(map[string]interface{}); ok {
+					delete(chain, "COINBASE_ADDRESS")
+					delete(chain, "GAS_LIMIT_TARGET")
+				}
+			}
+		}
+	}
+	if slaves, ok := obj["SLAVE_LIST"].([]interface{}); ok {
+		for _, slave := range slaves {
+			if slave, ok := slave.(map[string]interface{}); ok {
+				delete(slave, "HOST")
+				delete(slave, "PORT")
+			}
+		}
+	}
+	return obj, nil
+}
+
+func diffJSON(path string, a, b interface{}, paths *[]string) {
+	objA, okA := a.(map[string]interface{})
+	objB, okB := b.(map[string]interface{})
+	if !okA || !okB {
+		if !reflect.DeepEqual(a, b) {
+			*paths = append(*paths, path)
+		}
+		return
+	}
+	keys := make(map[string]bool)
+	for key := range objA {
+		keys[key] = true
+	}
+	for key := range objB {
+		keys[key] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+	for _, key := range sorted {
+		diffJSON(strings.TrimPrefix(path+"."+key, "."), objA[key], objB[key], paths)
+	}
+}
+
+func nonNilLevels(levels map[string]string) map[string]string {
+	if levels == nil {
+		return map[string]string{}
+	}
+	return levels
+}
+
+func sortedChainIDs(chains map[uint32]*ChainConfig) []uint32 {
+	ids := make([]uint32, 0, len(chains))
+	for id := range chains {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func sameChainMasks(a, b *SlaveConfig) bool {
+	if len(a.ChainMaskList) != len(b.ChainMaskList) {
+		return false
+	}
+	for i := range a.ChainMaskList {
+		if a.ChainMaskList[i].GetMask() != b.ChainMaskList[i].GetMask() {
+			return false
+		}
+	}
+	return true
+}
@@ -11,6 +11,8 @@ type SlaveConfig struct {
 	Port          uint16             `json:"PORT"` // 38392
 	ID            string             `json:"ID"`
 	WSPort        uint16             `json:"WEBSOCKET_JSON_RPC_PORT"`
+	PprofPort     uint16             `json:"PPROF_PORT,omitempty"`  // localhost port of the pprof HTTP server, 0 to disable
+	HealthPort    uint16             `json:"HEALTH_PORT,omitempty"` // port of the /health and /ready HTTP endpoints, 0 to disable
 	ChainMaskList []*types.ChainMask `json:"-"`
 }
 
@@ -0,0 +1,171 @@
+// Package eventsink pushes the chain events of the cluster, e.g. the new root
+// blocks and shard tips, the reorgs and the txs of the addresses watched, to
+// the HTTP endpoints and the Kafka topics of the back-office systems of the
+// operators, retrying with backoff while they are unreachable.
+package eventsink
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/cluster/config"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	// queueSize is the number of the events waiting for a sink, beyond which
+	// the new events are dropped.
+	queueSize = 1024
+	// defaultMaxRetries is the number of the retries of an event before it is
+	// dropped, if not configured.
+	defaultMaxRetries = 10
+	requestTimeout    = 10 * time.Second
+)
+
+var (
+	// minBackoff and maxBackoff bound the wait before retrying an event, which
+	// doubles after each failure.
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+)
+
+// Event is a chain event, pushed as JSON.
+type Event struct {
+	Type string                 `json:"type"`
+	Time int64                  `json:"timestamp"` // unix seconds
+	Data map[string]interface{} `json:"data"`
+}
+
+// sink delivers an event to an endpoint.
+type sink interface {
+	post(body []byte) error
+	name() string
+}
+
+// worker delivers the events of a type it pushes to its sink, in order.
+type worker struct {
+	sink       sink
+	events     map[string]bool // all the types if nil
+	maxRetries uint32
+	queue      chan []byte
+}
+
+// Dispatcher pushes the events published to each sink they are configured
+// for. A sink failing delays its events only.
+type Dispatcher struct {
+	workers []*worker
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New returns the dispatcher of the sinks configured, or nil if there are
+// none.
+func New(cfgs []*config.EventSinkConfig) (*Dispatcher, error) {
+	if len(cfgs) == 0 {
+		return nil, nil
+	}
+	d := &Dispatcher{quit: make(chan struct{})}
+	for _, cfg := range cfgs {
+		var (
+			s   sink
+			err error
+		)
+		switch cfg.Type {
+		case config.EventSinkKafka:
+			s, err = newKafkaSink(cfg.URL, cfg.Topic, cfg.Headers)
+		default:
+			s, err = newHTTPSink(cfg.URL, cfg.Headers)
+		}
+		if err != nil {
+			return nil, err
+		}
+		w := &worker{sink: s, maxRetries: cfg.MaxRetries, queue: make(chan []byte, queueSize)}
+		if w.maxRetries == 0 {
+			w.maxRetries = defaultMaxRetries
+		}
+		if len(cfg.Events) > 0 {
+			w.events = make(map[string]bool, len(cfg.Events))
+			for _, typ := range cfg.Events {
+				w.events[typ] = true
+			}
+		}
+		d.workers = append(d.workers, w)
+	}
+	return d, nil
+}
+
+// Start delivers the events until the dispatcher is stopped.
+func (d *Dispatcher) Start() {
+	for _, w := range d.workers {
+		d.wg.Add(1)
+		go d.loop(w)
+	}
+}
+
+// Stop stops the delivery, dropping the events not delivered yet.
+func (d *Dispatcher) Stop() {
+	close(d.quit)
+	d.wg.Wait()
+}
+
+// Publish queues the event of the type for the sinks pushing it, without
+// blocking. The event is dropped for a sink whose queue is full.
+func (d *Dispatcher) Publish(typ string, data map[string]interface{}) {
+	body, err := json.Marshal(&Event{Type: typ, Time: time.Now().Unix(), Data: data})
+	if err != nil {
+		log.Error("Failed to encode chain event", "type", typ, "err", err)
+		return
+	}
+	for _, w := range d.workers {
+		if w.events != nil && !w.events[typ] {
+			continue
+		}
+		select {
+		case w.queue <- body:
+		default:
+			log.Warn("Event sink is behind, dropping event", "sink", w.sink.name(), "type", typ)
+		}
+	}
+}
+
+func (d *Dispatcher) loop(w *worker) {
+	defer d.wg.Done()
+	for {
+		select {
+		case body := <-w.queue:
+			d.deliver(w, body)
+		case <-d.quit:
+			if n := len(w.queue); n > 0 {
+				log.Warn("Dropping undelivered chain events", "sink", w.sink.name(), "count", n)
+			}
+			return
+		}
+	}
+}
+
+// deliver posts the event to the sink, retrying with exponential backoff up
+// to the max retries of the sink, or until the dispatcher is stopped.
+func (d *Dispatcher) deliver(w *worker, body []byte) {
+	backoff := minBackoff
+	for attempt := uint32(0); ; attempt++ {
+		err := w.sink.post(body)
+		if err == nil {
+			return
+		}
+		if attempt >= w.maxRetries {
+			log.Error("Dropping chain event after retries", "sink", w.sink.name(), "retries", attempt, "err", err)
+			return
+		}
+		log.Warn("Failed to push chain event, retrying", "sink", w.sink.name(), "in", backoff, "err", err)
+		select {
+		case <-time.After(backoff):
+		case <-d.quit:
+			return
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package eventsink
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/cluster/config"
+	"github.com/stretchr/testify/assert"
+)
+
+type request struct {
+	path        string
+	contentType string
+	auth        string
+	body        []byte
+}
+
+func newServer(t *testing.T, failures int32) (*httptest.Server, chan *request) {
+	requests := make(chan *request, 16)
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= failures {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		assert.NoError(t, err)
+		requests <- &request{r.URL.Path, r.Header.Get("Content-Type"), r.Header.Get("Authorization"), body}
+	}))
+	return server, requests
+}
+
+func receive(t *testing.T, requests chan *request) *request {
+	select {
+	case req := <-requests:
+		return req
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event pushed")
+		return nil
+	}
+}
+
+func TestDispatcher(t *testing.T) {
+	minBackoff = 10 * time.Millisecond
+	defer func() { minBackoff = time.Second }()
+
+	// the first two posts fail and are retried
+	hook, hookRequests := newServer(t, 2)
+	defer hook.Close()
+	proxy, proxyRequests := newServer(t, 0)
+	defer proxy.Close()
+	d, err := New([]*config.EventSinkConfig{
+		{Type: config.EventSinkHTTP, URL: hook.URL + "/events", Headers: map[string]string{"Authorization": "Bearer token"}},
+		{Type: config.EventSinkKafka, URL: proxy.URL, Topic: "qkc_events", Events: []string{config.EventReorg}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Start()
+	defer d.Stop()
+
+	d.Publish(config.EventRootBlock, map[string]interface{}{"height": "0x1"})
+	d.Publish(config.EventReorg, map[string]interface{}{"depth": "0x2"})
+
+	// the webhook gets both events in order, once it is back
+	for _, typ := range []string{config.EventRootBlock, config.EventReorg} {
+		req := receive(t, hookRequests)
+		assert.Equal(t, "/events", req.path)
+		assert.Equal(t, "application/json", req.contentType)
+		assert.Equal(t, "Bearer token", req.auth)
+		var ev Event
+		assert.NoError(t, json.Unmarshal(req.body, &ev))
+		assert.Equal(t, typ, ev.Type)
+		assert.NotZero(t, ev.Time)
+	}
+
+	// the topic gets the reorg only, as a record
+	req := receive(t, proxyRequests)
+	assert.Equal(t, "/topics/qkc_events", req.path)
+	assert.Equal(t, "application/vnd.kafka.json.v2+json", req.contentType)
+	var records struct {
+		Records []struct {
+			Value Event `json:"value"`
+		} `json:"records"`
+	}
+	assert.NoError(t, json.Unmarshal(req.body, &records))
+	if assert.Len(t, records.Records, 1) {
+		assert.Equal(t, config.EventReorg, records.Records[0].Value.Type)
+		assert.Equal(t, "0x2", records.Records[0].Value.Data["depth"])
+	}
+	select {
+	case req := <-proxyRequests:
+		t.Fatalf("unexpected record %s", req.body)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestDispatcherDrop(t *testing.T) {
+	minBackoff = time.Millisecond
+	defer func() { minBackoff = time.Second }()
+
+	hook, requests := newServer(t, 3)
+	defer hook.Close()
+	d, err := New([]*config.EventSinkConfig{{Type: config.EventSinkHTTP, URL: hook.URL, MaxRetries: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Start()
+	defer d.Stop()
+
+	// the first event is dropped after the retries, the second goes through
+	d.Publish(config.EventShardTip, map[string]interface{}{"height": "0x1"})
+	d.Publish(config.EventShardTip, map[string]interface{}{"height": "0x2"})
+	var ev Event
+	assert.NoError(t, json.Unmarshal(receive(t, requests).body, &ev))
+	assert.Equal(t, "0x2", ev.Data["height"])
+}
+
+func TestValidateEventSinks(t *testing.T) {
+	cfg := config.NewMasterConfig()
+	assert.NoError(t, cfg.ValidateEventSinks())
+	cfg.EventSinks = []*config.EventSinkConfig{{Type: config.EventSinkKafka, URL: "http://localhost:8082"}}
+	assert.Error(t, cfg.ValidateEventSinks())
+	cfg.EventSinks[0].Topic = "qkc_events"
+	assert.NoError(t, cfg.ValidateEventSinks())
+	cfg.EventSinks[0].Events = []string{"tx"}
+	assert.Error(t, cfg.ValidateEventSinks())
+	cfg.EventSinks = []*config.EventSinkConfig{{Type: config.EventSinkHTTP, URL: "localhost:8080"}}
+	assert.Error(t, cfg.ValidateEventSinks())
+	cfg.EventSinks = nil
+	cfg.EventWatchAddresses = []string{"0x1234"}
+	assert.Error(t, cfg.ValidateEventSinks())
+}
@@ -0,0 +1,80 @@
+package eventsink
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// httpSink posts each event as a JSON object to the URL.
+type httpSink struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func newHTTPSink(endpoint string, headers map[string]string) (*httpSink, error) {
+	if _, err := url.Parse(endpoint); err != nil {
+		return nil, err
+	}
+	return &httpSink{url: endpoint, headers: headers, client: &http.Client{Timeout: requestTimeout}}, nil
+}
+
+func (s *httpSink) post(body []byte) error {
+	return postJSON(s.client, s.url, "application/json", s.headers, body)
+}
+
+func (s *httpSink) name() string { return s.url }
+
+// kafkaSink produces each event as a JSON record of the topic through the
+// REST proxy of Kafka, e.g. to http://localhost:8082/topics/qkc_events.
+type kafkaSink struct {
+	url     string
+	topic   string
+	headers map[string]string
+	client  *http.Client
+}
+
+func newKafkaSink(proxy, topic string, headers map[string]string) (*kafkaSink, error) {
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/topics/" + url.PathEscape(topic)
+	return &kafkaSink{url: u.String(), topic: topic, headers: headers, client: &http.Client{Timeout: requestTimeout}}, nil
+}
+
+func (s *kafkaSink) post(body []byte) error {
+	var records bytes.Buffer
+	records.WriteString(`{"records":[{"value":`)
+	records.Write(body)
+	records.WriteString(`}]}`)
+	return postJSON(s.client, s.url, "application/vnd.kafka.json.v2+json", s.headers, records.Bytes())
+}
+
+func (s *kafkaSink) name() string { return "kafka:" + s.topic }
+
+func postJSON(client *http.Client, endpoint, contentType string, headers map[string]string, body []byte) error {
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s answered %s: %s", endpoint, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
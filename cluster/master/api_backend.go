@@ -2,23 +2,29 @@ package master
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/account/external"
+	"github.com/QuarkChain/goquarkchain/account/keystore"
 	"github.com/QuarkChain/goquarkchain/cluster/rpc"
 	"github.com/QuarkChain/goquarkchain/consensus"
 	"github.com/QuarkChain/goquarkchain/core"
 	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/internal/debug"
 	"github.com/QuarkChain/goquarkchain/p2p"
 	qrpc "github.com/QuarkChain/goquarkchain/rpc"
 	"github.com/QuarkChain/goquarkchain/serialize"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
-	"golang.org/x/sync/errgroup"
+	"github.com/ethereum/go-ethereum/event"
 	"math/big"
 	"net"
 	"reflect"
+	"sort"
 	"strings"
 )
 
@@ -49,10 +55,12 @@ func (s *QKCMasterBackend) GetPeerInfolist() []rpc.PeerInfoForDisPlay {
 	return result
 }
 
-func (s *QKCMasterBackend) AddTransaction(tx *types.Transaction) error {
+func (s *QKCMasterBackend) AddTransaction(ctx context.Context, tx *types.Transaction) error {
 	evmTx := tx.EvmTx
-	if evmTx.GasPrice().Cmp(s.clusterConfig.Quarkchain.MinTXPoolGasPrice) < 0 {
-		return errors.New(fmt.Sprintf("invalid gasprice: tx min gas price is %d", s.clusterConfig.Quarkchain.MinTXPoolGasPrice.Uint64()))
+	gasPrice := s.clusterConfig.Quarkchain.ConvertGasPrice(evmTx.GasTokenID(), evmTx.GasPrice())
+	if gasPrice.Cmp(s.clusterConfig.Quarkchain.MinTXPoolGasPrice) < 0 {
+		return &core.TxRejectedError{Reason: core.TxRejectGasPrice,
+			Err: fmt.Errorf("invalid gasprice: tx min gas price is %d", s.clusterConfig.Quarkchain.MinTXPoolGasPrice.Uint64())}
 	}
 	fromShardSize, err := s.clusterConfig.Quarkchain.GetShardSizeByChainId(tx.EvmTx.FromChainID())
 	if err != nil {
@@ -66,14 +74,12 @@ func (s *QKCMasterBackend) AddTransaction(tx *types.Transaction) error {
 	if len(slaves) == 0 {
 		return ErrNoBranchConn
 	}
-	var g errgroup.Group
-	for index := range slaves {
-		i := index
-		g.Go(func() error {
-			return slaves[i].AddTransaction(tx)
-		})
-	}
-	err = g.Wait() //TODO?? peer broadcast
+	// not cancelled with the JSON RPC call for the slaves not to end up with
+	// different pools, but logged with its request ID
+	addCtx := qrpc.WithRequestID(context.Background(), qrpc.RequestIDFromContext(ctx))
+	err = fanOutAllSlaves(addCtx, slaves, func(ctx context.Context, conn rpc.ISlaveConn) error {
+		return conn.AddTransaction(ctx, tx)
+	})
 	if err != nil {
 		return err
 	}
@@ -82,10 +88,71 @@ func (s *QKCMasterBackend) AddTransaction(tx *types.Transaction) error {
 		return err
 	}
 	go s.protocolManager.BroadcastTransactions(&rpc.P2PRedirectRequest{Branch: fullShardId, Data: data}, "")
+	if s.txWatcher != nil {
+		s.txWatcher.watch(tx)
+	}
+	if s.nonceManager != nil {
+		s.nonceManager.sent(tx)
+	}
 	return nil
 }
 
-func (s *QKCMasterBackend) ExecuteTransaction(tx *types.Transaction, address *account.Address, height *uint64) ([]byte, error) {
+// ReserveNonces reserves count consecutive nonces of the address in its shard,
+// or fewer to reuse the nonces freed first, if the nonce manager is enabled.
+func (s *QKCMasterBackend) ReserveNonces(ctx context.Context, address *account.Address, count uint64) (*rpc.NonceReservation, error) {
+	if s.nonceManager == nil {
+		return nil, errNonceManagerDisabled
+	}
+	fullShardID, err := s.clusterConfig.Quarkchain.GetFullShardIdByFullShardKey(address.FullShardKey)
+	if err != nil {
+		return nil, err
+	}
+	return s.nonceManager.reserve(ctx, address, fullShardID, count)
+}
+
+// ReleaseNonces frees the nonces of the address reserved from the first one,
+// and returns the number freed.
+func (s *QKCMasterBackend) ReleaseNonces(address *account.Address, from, count uint64) (uint64, error) {
+	if s.nonceManager == nil {
+		return 0, errNonceManagerDisabled
+	}
+	fullShardID, err := s.clusterConfig.Quarkchain.GetFullShardIdByFullShardKey(address.FullShardKey)
+	if err != nil {
+		return 0, err
+	}
+	return s.nonceManager.release(address, fullShardID, from, count)
+}
+
+// GetNonceState returns what the nonce manager knows of the nonces of the
+// address in its shard.
+func (s *QKCMasterBackend) GetNonceState(address *account.Address) (*rpc.NonceState, error) {
+	if s.nonceManager == nil {
+		return nil, errNonceManagerDisabled
+	}
+	fullShardID, err := s.clusterConfig.Quarkchain.GetFullShardIdByFullShardKey(address.FullShardKey)
+	if err != nil {
+		return nil, err
+	}
+	return s.nonceManager.state(address, fullShardID)
+}
+
+// SubscribeTxStatus sends the status changes of the txs submitted through the
+// master to ch, if the tx watcher is enabled.
+func (s *QKCMasterBackend) SubscribeTxStatus(ch chan<- *rpc.TxStatusEvent) (event.Subscription, error) {
+	if s.txWatcher == nil {
+		return nil, errors.New("tx watcher is disabled, see TX_WATCHER")
+	}
+	return s.txWatcher.subscribe(ch), nil
+}
+
+// SubscribeRootChainEvent sends the root blocks added to the canonical chain
+// to ch.
+func (s *QKCMasterBackend) SubscribeRootChainEvent(ch chan<- core.RootChainEvent) event.Subscription {
+	return s.rootBlockChain.SubscribeChainEvent(ch)
+}
+
+func (s *QKCMasterBackend) ExecuteTransaction(ctx context.Context, tx *types.Transaction, address *account.Address, height *uint64,
+	overrides []*rpc.AccountOverride) ([]byte, error) {
 	evmTx := tx.EvmTx
 	fromShardSize, err := s.clusterConfig.Quarkchain.GetShardSizeByChainId(tx.EvmTx.FromChainID())
 	if err != nil {
@@ -98,23 +165,23 @@ func (s *QKCMasterBackend) ExecuteTransaction(tx *types.Transaction, address *ac
 	if len(slaves) == 0 {
 		return nil, ErrNoBranchConn
 	}
-	var g errgroup.Group
-	rspList := make([][]byte, len(slaves))
-	for index := range slaves {
-		i := index
-		g.Go(func() error {
-			rsp, err := slaves[i].ExecuteTransaction(tx, address, height)
-			rspList[i] = rsp
-			return err
-		})
-	}
-	if err := g.Wait(); err != nil {
+	// the slaves running the shard hold the same state, so the result of a
+	// majority of them is enough while the others are down or slow
+	results, err := fanOut(ctx, slaves, fanOutQuorum, 0, func(ctx context.Context, conn rpc.ISlaveConn) (interface{}, error) {
+		return conn.ExecuteTransaction(ctx, tx, address, height, overrides)
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	resultBytes := rspList[0] // before already this len>0
-	for _, res := range rspList {
-		if res != nil && !bytes.Equal(resultBytes, res) {
+	var resultBytes []byte
+	for _, res := range results {
+		if res.err != nil {
+			continue
+		}
+		if rsp := res.result.([]byte); resultBytes == nil {
+			resultBytes = rsp
+		} else if rsp != nil && !bytes.Equal(resultBytes, rsp) {
 			return nil, errors.New("exist more than one result")
 		}
 	}
@@ -122,15 +189,57 @@ func (s *QKCMasterBackend) ExecuteTransaction(tx *types.Transaction, address *ac
 
 }
 
-func (s *QKCMasterBackend) GetMinorBlockByHash(blockHash common.Hash, branch account.Branch, needExtraInfo bool) (*types.MinorBlock, *rpc.PoSWInfo, error) {
+// ExecutePendingTransaction executes the tx on the pending state of its shard,
+// with the txs of the pool applied. The pools of the slaves running the shard
+// may differ, so only one of them executes it.
+func (s *QKCMasterBackend) ExecutePendingTransaction(ctx context.Context, tx *types.Transaction, address *account.Address,
+	overrides []*rpc.AccountOverride) ([]byte, error) {
+	fromShardSize, err := s.clusterConfig.Quarkchain.GetShardSizeByChainId(tx.EvmTx.FromChainID())
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.EvmTx.SetFromShardSize(fromShardSize); err != nil {
+		return nil, errors.New(fmt.Sprintf("Failed to set fromShardSize, fromShardSize: %d, err: %v", fromShardSize, err))
+	}
+	slaveConn := servingConn(s.GetSlaveConnsById(tx.EvmTx.FromFullShardId()), rpc.OpExecutePendingTransaction)
+	if slaveConn == nil {
+		return nil, ErrNoBranchConn
+	}
+	return slaveConn.ExecutePendingTransaction(ctx, tx, address, overrides)
+}
+
+func (s *QKCMasterBackend) CreateAccessList(ctx context.Context, tx *types.Transaction, address *account.Address,
+	height *uint64) (*rpc.CreateAccessListResponse, error) {
+	fromShardSize, err := s.clusterConfig.Quarkchain.GetShardSizeByChainId(tx.EvmTx.FromChainID())
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.EvmTx.SetFromShardSize(fromShardSize); err != nil {
+		return nil, errors.New(fmt.Sprintf("Failed to set fromShardSize, fromShardSize: %d, err: %v", fromShardSize, err))
+	}
+	slaveConn := servingConn(s.GetSlaveConnsById(tx.EvmTx.FromFullShardId()), rpc.OpCreateAccessList)
+	if slaveConn == nil {
+		return nil, ErrNoBranchConn
+	}
+	return slaveConn.CreateAccessList(ctx, tx, address, height)
+}
+
+func (s *QKCMasterBackend) GetMinorBlockByHash(ctx context.Context, blockHash common.Hash, branch account.Branch, needExtraInfo bool) (*types.MinorBlock, *rpc.PoSWInfo, error) {
 	slaveConn := s.GetOneSlaveConnById(branch.Value)
 	if slaveConn == nil {
 		return nil, nil, ErrNoBranchConn
 	}
-	return slaveConn.GetMinorBlockByHash(blockHash, branch, needExtraInfo)
+	if block, posw, ok := s.blockCache.getMinorBlock(blockHash, needExtraInfo); ok && block.Branch() == branch {
+		return block, posw, nil
+	}
+	block, posw, err := slaveConn.GetMinorBlockByHash(ctx, blockHash, branch, needExtraInfo)
+	if err == nil && block != nil && block.Hash() == blockHash {
+		s.blockCache.addMinorBlock(block, posw, needExtraInfo)
+	}
+	return block, posw, err
 }
 
-func (s *QKCMasterBackend) GetMinorBlockByHeight(height *uint64, branch account.Branch, needExtraInfo bool) (*types.MinorBlock, *rpc.PoSWInfo, error) {
+func (s *QKCMasterBackend) GetMinorBlockByHeight(ctx context.Context, height *uint64, branch account.Branch, needExtraInfo bool) (*types.MinorBlock, *rpc.PoSWInfo, error) {
 	slaveConn := s.GetOneSlaveConnById(branch.Value)
 	if slaveConn == nil {
 		return nil, nil, ErrNoBranchConn
@@ -144,26 +253,81 @@ func (s *QKCMasterBackend) GetMinorBlockByHeight(height *uint64, branch account.
 		}
 		height = &shardStats.Height
 	}
-	return slaveConn.GetMinorBlockByHeight(height, branch, needExtraInfo)
+	block, posw, err := slaveConn.GetMinorBlockByHeight(ctx, height, branch, needExtraInfo)
+	// the block of the height may change with a reorg, but not the one of its hash
+	if err == nil {
+		s.blockCache.addMinorBlock(block, posw, needExtraInfo)
+	}
+	return block, posw, err
 }
 
-func (s *QKCMasterBackend) GetTransactionByHash(txHash common.Hash, branch account.Branch) (*types.MinorBlock, uint32, error) {
+func (s *QKCMasterBackend) GetTransactionByHash(ctx context.Context, txHash common.Hash, branch account.Branch) (*types.MinorBlock, uint32, error) {
 	slaveConn := s.GetOneSlaveConnById(branch.Value)
 	if slaveConn == nil {
 		return nil, 0, ErrNoBranchConn
 	}
-	return slaveConn.GetTransactionByHash(txHash, branch)
+	return slaveConn.GetTransactionByHash(ctx, txHash, branch)
 }
 
-func (s *QKCMasterBackend) GetTransactionReceipt(txHash common.Hash, branch account.Branch) (*types.MinorBlock, uint32, *types.Receipt, error) {
+func (s *QKCMasterBackend) GetTransactionReceipt(ctx context.Context, txHash common.Hash, branch account.Branch) (*types.MinorBlock, uint32, *types.Receipt, error) {
 	slaveConn := s.GetOneSlaveConnById(branch.Value)
 	if slaveConn == nil {
 		return nil, 0, nil, ErrNoBranchConn
 	}
-	return slaveConn.GetTransactionReceipt(txHash, branch)
+	return slaveConn.GetTransactionReceipt(ctx, txHash, branch)
 }
 
-func (s *QKCMasterBackend) GetTransactionsByAddress(address *account.Address, start []byte, limit uint32, transferTokenID *uint64) ([]*rpc.TransactionDetail, []byte, error) {
+// GetMinorBlockReceipts returns the receipts of the txs of the minor block.
+func (s *QKCMasterBackend) GetMinorBlockReceipts(ctx context.Context, blockHash common.Hash, branch account.Branch) (types.Receipts, error) {
+	slaveConn := servingConn(s.GetSlaveConnsById(branch.Value), rpc.OpGetMinorBlockReceipts)
+	if slaveConn == nil {
+		return nil, ErrNoBranchConn
+	}
+	if receipts, ok := s.blockCache.getReceipts(blockHash); ok {
+		return receipts, nil
+	}
+	receipts, err := slaveConn.GetMinorBlockReceipts(ctx, blockHash, branch)
+	if err == nil {
+		s.blockCache.addReceipts(blockHash, receipts)
+	}
+	return receipts, err
+}
+
+// ExportBlocks calls fn with the canonical minor blocks of the shard from the
+// height up, as stored by a slave, at most count of them, until fn returns
+// false.
+func (s *QKCMasterBackend) ExportBlocks(ctx context.Context, branch account.Branch, from, count uint64, includeReceipts bool,
+	fn func(*rpc.ExportedBlock) bool) error {
+	slaveConn := servingConn(s.GetSlaveConnsById(branch.Value), rpc.OpExportBlocks)
+	if slaveConn == nil {
+		return ErrNoBranchConn
+	}
+	return slaveConn.ExportBlocks(ctx, branch, from, count, includeReceipts, fn)
+}
+
+// TraceBlock returns the traces of the txs of the minor block, in JSON.
+func (s *QKCMasterBackend) TraceBlock(ctx context.Context, blockHash common.Hash, branch account.Branch,
+	config *rpc.TraceConfig) ([]json.RawMessage, error) {
+	slaveConn := servingConn(s.GetSlaveConnsById(branch.Value), rpc.OpTraceBlock)
+	if slaveConn == nil {
+		return nil, ErrNoBranchConn
+	}
+	return slaveConn.TraceBlock(ctx, blockHash, branch, config)
+}
+
+// SimulateBundle applies the txs, all of the shard of the branch, in order on
+// the state of the minor block at the height, the head if nil, without
+// broadcasting them.
+func (s *QKCMasterBackend) SimulateBundle(ctx context.Context, txs []*types.Transaction, branch account.Branch,
+	height *uint64) ([]*rpc.BundleTxResult, error) {
+	slaveConn := servingConn(s.GetSlaveConnsById(branch.Value), rpc.OpSimulateBundle)
+	if slaveConn == nil {
+		return nil, ErrNoBranchConn
+	}
+	return slaveConn.SimulateBundle(ctx, txs, branch, height)
+}
+
+func (s *QKCMasterBackend) GetTransactionsByAddress(ctx context.Context, address *account.Address, start []byte, limit uint32, transferTokenID *uint64) ([]*rpc.TransactionDetail, []byte, error) {
 	fullShardID, err := s.clusterConfig.Quarkchain.GetFullShardIdByFullShardKey(address.FullShardKey)
 	if err != nil {
 		return nil, nil, err
@@ -172,27 +336,27 @@ func (s *QKCMasterBackend) GetTransactionsByAddress(address *account.Address, st
 	if slaveConn == nil {
 		return nil, nil, ErrNoBranchConn
 	}
-	return slaveConn.GetTransactionsByAddress(address, start, limit, transferTokenID)
+	return slaveConn.GetTransactionsByAddress(ctx, address, start, limit, transferTokenID)
 }
 
-func (s *QKCMasterBackend) GetAllTx(branch account.Branch, start []byte, limit uint32) ([]*rpc.TransactionDetail, []byte, error) {
+func (s *QKCMasterBackend) GetAllTx(ctx context.Context, branch account.Branch, start []byte, limit uint32) ([]*rpc.TransactionDetail, []byte, error) {
 	slaveConn := s.GetOneSlaveConnById(branch.Value)
 	if slaveConn == nil {
 		return nil, nil, ErrNoBranchConn
 	}
-	return slaveConn.GetAllTx(branch, start, limit)
+	return slaveConn.GetAllTx(ctx, branch, start, limit)
 }
 
-func (s *QKCMasterBackend) GetLogs(args *qrpc.FilterQuery) ([]*types.Log, error) {
+func (s *QKCMasterBackend) GetLogs(ctx context.Context, args *qrpc.FilterQuery) ([]*types.Log, error) {
 	// not support earlist and pending
 	slaveConn := s.GetOneSlaveConnById(args.FullShardId)
 	if slaveConn == nil {
 		return nil, ErrNoBranchConn
 	}
-	return slaveConn.GetLogs(args)
+	return slaveConn.GetLogs(ctx, args)
 }
 
-func (s *QKCMasterBackend) EstimateGas(tx *types.Transaction, fromAddress *account.Address) (uint32, error) {
+func (s *QKCMasterBackend) EstimateGas(ctx context.Context, tx *types.Transaction, fromAddress *account.Address) (uint32, error) {
 	evmTx := tx.EvmTx
 	fromShardSize, err := s.clusterConfig.Quarkchain.GetShardSizeByChainId(tx.EvmTx.FromChainID())
 	if err != nil {
@@ -206,17 +370,17 @@ func (s *QKCMasterBackend) EstimateGas(tx *types.Transaction, fromAddress *accou
 		return 0, ErrNoBranchConn
 	}
 	if !evmTx.IsCrossShard() {
-		return slaveConn.EstimateGas(tx, fromAddress)
+		return slaveConn.EstimateGas(ctx, tx, fromAddress)
 	}
 	fAddr := account.Address{Recipient: fromAddress.Recipient, FullShardKey: evmTx.ToFullShardKey()}
-	res, err := slaveConn.EstimateGas(tx, &fAddr)
+	res, err := slaveConn.EstimateGas(ctx, tx, &fAddr)
 	if err != nil {
 		return 0, err
 	}
 	return res + 9000, nil
 }
 
-func (s *QKCMasterBackend) GetStorageAt(address *account.Address, key common.Hash, height *uint64) (common.Hash, error) {
+func (s *QKCMasterBackend) GetStorageAt(ctx context.Context, address *account.Address, key common.Hash, height *uint64) (common.Hash, error) {
 	fullShardID, err := s.clusterConfig.Quarkchain.GetFullShardIdByFullShardKey(address.FullShardKey)
 	if err != nil {
 		return common.Hash{}, err
@@ -225,10 +389,10 @@ func (s *QKCMasterBackend) GetStorageAt(address *account.Address, key common.Has
 	if slaveConn == nil {
 		return common.Hash{}, ErrNoBranchConn
 	}
-	return slaveConn.GetStorageAt(address, key, height)
+	return slaveConn.GetStorageAt(ctx, address, key, height)
 }
 
-func (s *QKCMasterBackend) GetCode(address *account.Address, height *uint64) ([]byte, error) {
+func (s *QKCMasterBackend) GetCode(ctx context.Context, address *account.Address, height *uint64) ([]byte, error) {
 	fullShardID, err := s.clusterConfig.Quarkchain.GetFullShardIdByFullShardKey(address.FullShardKey)
 	if err != nil {
 		return nil, err
@@ -237,19 +401,33 @@ func (s *QKCMasterBackend) GetCode(address *account.Address, height *uint64) ([]
 	if slaveConn == nil {
 		return nil, ErrNoBranchConn
 	}
-	return slaveConn.GetCode(address, height)
+	return slaveConn.GetCode(ctx, address, height)
 }
 
-func (s *QKCMasterBackend) GasPrice(branch account.Branch, tokenID uint64) (uint64, error) {
+// GetBalanceHistory returns the balances of the address at the height from
+// the balance history index of its shard, the head if height is nil.
+func (s *QKCMasterBackend) GetBalanceHistory(ctx context.Context, address *account.Address, height *uint64) (*rpc.GetBalanceHistoryResponse, error) {
+	fullShardID, err := s.clusterConfig.Quarkchain.GetFullShardIdByFullShardKey(address.FullShardKey)
+	if err != nil {
+		return nil, err
+	}
+	slaveConn := servingConn(s.GetSlaveConnsById(fullShardID), rpc.OpGetBalanceHistory)
+	if slaveConn == nil {
+		return nil, ErrNoBranchConn
+	}
+	return slaveConn.GetBalanceHistory(ctx, address, height)
+}
+
+func (s *QKCMasterBackend) GasPrice(ctx context.Context, branch account.Branch, tokenID uint64) (uint64, error) {
 	slaveConn := s.GetOneSlaveConnById(branch.Value)
 	if slaveConn == nil {
 		return 0, ErrNoBranchConn
 	}
-	return slaveConn.GasPrice(branch, tokenID)
+	return slaveConn.GasPrice(ctx, branch, tokenID)
 }
 
 // return root chain work if branch is nil
-func (s *QKCMasterBackend) GetWork(fullShardId *uint32, addr *common.Address) (*consensus.MiningWork, error) {
+func (s *QKCMasterBackend) GetWork(ctx context.Context, fullShardId *uint32, addr *common.Address) (*consensus.MiningWork, error) {
 	coinbaseAddr := &account.Address{}
 	if addr != nil {
 		coinbaseAddr.Recipient = *addr
@@ -268,11 +446,11 @@ func (s *QKCMasterBackend) GetWork(fullShardId *uint32, addr *common.Address) (*
 	if slaveConn == nil {
 		return nil, ErrNoBranchConn
 	}
-	return slaveConn.GetWork(branch, coinbaseAddr)
+	return slaveConn.GetWork(ctx, branch, coinbaseAddr)
 }
 
 // submit root chain work if branch is nil
-func (s *QKCMasterBackend) SubmitWork(fullShardId *uint32, headerHash common.Hash, nonce uint64, mixHash common.Hash, signature *[65]byte) (bool, error) {
+func (s *QKCMasterBackend) SubmitWork(ctx context.Context, fullShardId *uint32, headerHash common.Hash, nonce uint64, mixHash common.Hash, signature *[65]byte) (bool, error) {
 	if fullShardId == nil {
 		return s.miner.SubmitWork(nonce, headerHash, mixHash, signature), nil
 	}
@@ -282,7 +460,148 @@ func (s *QKCMasterBackend) SubmitWork(fullShardId *uint32, headerHash common.Has
 	if slaveConn == nil {
 		return false, ErrNoBranchConn
 	}
-	return slaveConn.SubmitWork(&rpc.SubmitWorkRequest{Branch: branch.Value, HeaderHash: headerHash, Nonce: nonce, MixHash: mixHash})
+	return slaveConn.SubmitWork(ctx, &rpc.SubmitWorkRequest{Branch: branch.Value, HeaderHash: headerHash, Nonce: nonce, MixHash: mixHash})
+}
+
+// GetBlockProfiles returns the profiles of the latest minor blocks of the shard
+// produced by the cluster.
+func (s *QKCMasterBackend) GetBlockProfiles(ctx context.Context, branch account.Branch, limit uint32) ([]*rpc.BlockProfile, error) {
+	slaveConn := servingConn(s.GetSlaveConnsById(branch.Value), rpc.OpGetBlockProfiles)
+	if slaveConn == nil {
+		return nil, ErrNoBranchConn
+	}
+	return slaveConn.GetBlockProfiles(ctx, branch, limit)
+}
+
+// ReplayBlocks re-executes the canonical blocks of the shard from first to
+// last on the slave serving it, and returns their results against the stored
+// values.
+func (s *QKCMasterBackend) ReplayBlocks(ctx context.Context, branch account.Branch, first, last uint64) ([]*rpc.BlockReplayResult, error) {
+	slaveConn := servingConn(s.GetSlaveConnsById(branch.Value), rpc.OpReplayBlocks)
+	if slaveConn == nil {
+		return nil, ErrNoBranchConn
+	}
+	return slaveConn.ReplayBlocks(ctx, branch, first, last)
+}
+
+// BackupShard writes a snapshot of the database of the shard to the archive
+// at path on the host of the slave serving it.
+func (s *QKCMasterBackend) BackupShard(ctx context.Context, branch account.Branch, path string) (*rpc.BackupShardResponse, error) {
+	slaveConn := servingConn(s.GetSlaveConnsById(branch.Value), rpc.OpBackupShard)
+	if slaveConn == nil {
+		return nil, ErrNoBranchConn
+	}
+	return slaveConn.BackupShard(ctx, branch, path)
+}
+
+// ShardMaintenance pauses or resumes the shard, or compacts or reindexes its
+// database while it's paused, on all the slaves running it, lagging or not,
+// and returns their responses by slave.
+func (s *QKCMasterBackend) ShardMaintenance(ctx context.Context, branch account.Branch, action string) (map[string]*rpc.ShardMaintenanceResponse, error) {
+	conns := s.branchToSlaveConns[branch.Value]
+	if len(conns) == 0 {
+		return nil, ErrNoBranchConn
+	}
+	// a shard paused on some of the slaves only would fork
+	if err := allSupporting(conns, rpc.OpShardMaintenance); err != nil {
+		return nil, err
+	}
+	results, err := fanOut(ctx, conns, fanOutAll, 0, func(ctx context.Context, conn rpc.ISlaveConn) (interface{}, error) {
+		return conn.ShardMaintenance(ctx, branch, action)
+	})
+	if err != nil {
+		return nil, err
+	}
+	rsps := make(map[string]*rpc.ShardMaintenanceResponse, len(results))
+	for _, r := range results {
+		rsps[r.conn.GetSlaveID()] = r.result.(*rpc.ShardMaintenanceResponse)
+		// the root blocks the paused shard failed are added again
+		if action == rpc.MaintenanceResume && s.isSlaveLagging(r.conn.GetSlaveID()) {
+			s.retryCatchUpSlave(r.conn)
+		}
+	}
+	return rsps, nil
+}
+
+// GetStateAvailability tells whether the state of the canonical block of the
+// shard at the height is available, or else from which block it can be
+// regenerated.
+func (s *QKCMasterBackend) GetStateAvailability(ctx context.Context, branch account.Branch, number uint64) (*rpc.StateAvailability, error) {
+	slaveConn := servingConn(s.GetSlaveConnsById(branch.Value), rpc.OpGetStateAvailability)
+	if slaveConn == nil {
+		return nil, ErrNoBranchConn
+	}
+	return slaveConn.GetStateAvailability(ctx, branch, number)
+}
+
+// RegenerateState starts writing the missing state of the canonical block of
+// the shard at the height on all the slaves running it, by replaying the blocks
+// from the nearest state available, and returns their progress by slave.
+func (s *QKCMasterBackend) RegenerateState(ctx context.Context, branch account.Branch, number uint64) (map[string]*rpc.RegenerateStateResponse, error) {
+	conns := supporting(s.branchToSlaveConns[branch.Value], rpc.OpRegenerateState)
+	if len(conns) == 0 {
+		return nil, ErrNoBranchConn
+	}
+	results, err := fanOut(ctx, conns, fanOutAll, 0, func(ctx context.Context, conn rpc.ISlaveConn) (interface{}, error) {
+		return conn.RegenerateState(ctx, branch, number)
+	})
+	if err != nil {
+		return nil, err
+	}
+	rsps := make(map[string]*rpc.RegenerateStateResponse, len(results))
+	for _, r := range results {
+		rsps[r.conn.GetSlaveID()] = r.result.(*rpc.RegenerateStateResponse)
+	}
+	return rsps, nil
+}
+
+// return root chain stale blocks if branch is nil
+func (s *QKCMasterBackend) GetStaleBlocks(ctx context.Context, fullShardId *uint32, limit uint32) (uint64, []*rpc.StaleBlock, error) {
+	if fullShardId == nil {
+		total, blocks := s.rootBlockChain.GetStaleBlocks(limit)
+		return total, blocks, nil
+	}
+
+	branch := account.NewBranch(*fullShardId)
+	slaveConn := servingConn(s.GetSlaveConnsById(branch.Value), rpc.OpGetStaleBlocks)
+	if slaveConn == nil {
+		return 0, nil, ErrNoBranchConn
+	}
+	return slaveConn.GetStaleBlocks(ctx, branch, limit)
+}
+
+func (s *QKCMasterBackend) GetUnreceivedXShardDeposits(ctx context.Context, branch account.Branch, limit uint32) ([]*rpc.UnreceivedXShardDeposit, error) {
+	slaveConn := servingConn(s.GetSlaveConnsById(branch.Value), rpc.OpGetUnreceivedXShardDeposits)
+	if slaveConn == nil {
+		return nil, ErrNoBranchConn
+	}
+	return slaveConn.GetUnreceivedXShardDeposits(ctx, branch, limit)
+}
+
+// GetXShardQueues returns the queues of the cross-shard deposits not applied by
+// each shard yet, sorted by branch.
+func (s *QKCMasterBackend) GetXShardQueues(ctx context.Context) ([]*rpc.XShardQueue, error) {
+	conns := supporting(s.GetSlaveConns(), rpc.OpGetXShardQueues)
+	results, err := fanOut(ctx, conns, fanOutAll, 0, func(ctx context.Context, conn rpc.ISlaveConn) (interface{}, error) {
+		return conn.GetXShardQueues(ctx)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get xshard queues: %v", err)
+	}
+	queues := make(map[uint32]*rpc.XShardQueue)
+	for _, res := range results {
+		for _, queue := range res.result.([]*rpc.XShardQueue) {
+			if _, ok := queues[queue.Branch]; !ok {
+				queues[queue.Branch] = queue
+			}
+		}
+	}
+	list := make([]*rpc.XShardQueue, 0, len(queues))
+	for _, queue := range queues {
+		list = append(list, queue)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Branch < list[j].Branch })
+	return list, nil
 }
 
 func (s *QKCMasterBackend) GetRootBlockByNumber(blockNumber *uint64, needExtraInfo bool) (*types.RootBlock, *rpc.PoSWInfo, error) {
@@ -342,13 +661,23 @@ func (s *QKCMasterBackend) GetCurrRootHeader() *types.RootBlockHeader {
 	return s.rootBlockChain.CurrentHeader().(*types.RootBlockHeader)
 }
 
+func (s *QKCMasterBackend) AccountManager() *keystore.KeyStore {
+	return s.accountManager
+}
+
+func (s *QKCMasterBackend) ExternalSigner() *external.ExternalSigner {
+	return s.externalSigner
+}
+
 func (s *QKCMasterBackend) GetDefaultCoinbaseAddress() account.Address {
+	s.coinbaseLock.RLock()
+	defer s.coinbaseLock.RUnlock()
 	return s.clusterConfig.Quarkchain.Root.CoinbaseAddress
 }
 
 // miner api
 func (s *QKCMasterBackend) CreateBlockToMine(addr *account.Address) (types.IBlock, *big.Int, uint64, error) {
-	coinbaseAddr := s.clusterConfig.Quarkchain.Root.CoinbaseAddress
+	coinbaseAddr := s.GetDefaultCoinbaseAddress()
 	if addr != nil {
 		coinbaseAddr = *addr
 	}
@@ -373,21 +702,13 @@ func (s *QKCMasterBackend) AddMinorBlock(branch uint32, mBlock *types.MinorBlock
 	if len(clients) == 0 {
 		return errors.New(fmt.Sprintf("slave is not exist, branch: %d", branch))
 	}
-	var (
-		g errgroup.Group
-	)
 	data, err := serialize.SerializeToBytes(&p2p.NewBlockMinor{Block: mBlock})
 	if err != nil {
 		return err
 	}
-	for _, cli := range clients {
-		cli := cli
-		g.Go(func() error {
-			err := cli.HandleNewMinorBlock(&rpc.P2PRedirectRequest{Branch: branch, Data: data})
-			return err
-		})
-	}
-	return g.Wait()
+	return fanOutAllSlaves(context.Background(), clients, func(ctx context.Context, conn rpc.ISlaveConn) error {
+		return conn.HandleNewMinorBlock(ctx, &rpc.P2PRedirectRequest{Branch: branch, Data: data})
+	})
 }
 
 func (s *QKCMasterBackend) GetTip() uint64 {
@@ -401,6 +722,59 @@ func (s *QKCMasterBackend) GetKadRoutingTable() ([]string, error) {
 	return nil, errors.New("p2p server is not running")
 }
 
+func (s *QKCMasterBackend) P2PServer() *p2p.Server {
+	return s.srvr
+}
+
+// Profile runs the profiling action of debug.Handler.Profile on the master,
+// or on the slave if slaveID is not empty.
+func (s *QKCMasterBackend) Profile(ctx context.Context, slaveID, action, file string, rate int) error {
+	if slaveID == "" {
+		return debug.Handler.Profile(action, file, rate)
+	}
+	for _, slv := range s.GetSlaveConns() {
+		if slv.GetSlaveID() == slaveID {
+			if err := allSupporting([]rpc.ISlaveConn{slv}, rpc.OpProfile); err != nil {
+				return err
+			}
+			return slv.Profile(ctx, action, file, rate)
+		}
+	}
+	return fmt.Errorf("unknown slave %q", slaveID)
+}
+
+// SlowOps returns the last count slow cluster RPC ops of the master and the
+// slaves, or of the process if given, master or a slave ID, the oldest first.
+func (s *QKCMasterBackend) SlowOps(ctx context.Context, process string, count int) ([]*rpc.SlowOp, error) {
+	ops := make([]*rpc.SlowOp, 0)
+	if process == "" || process == "master" {
+		ops = append(ops, s.slowOps.Ops(count)...)
+	}
+	conns := make([]rpc.ISlaveConn, 0)
+	for _, conn := range s.GetSlaveConns() {
+		if process == "" || conn.GetSlaveID() == process {
+			conns = append(conns, conn)
+		}
+	}
+	if process != "" && process != "master" && len(conns) == 0 {
+		return nil, fmt.Errorf("unknown slave %q", process)
+	}
+	results, err := fanOut(ctx, supporting(conns, rpc.OpGetSlowOps), fanOutAll, 0, func(ctx context.Context, conn rpc.ISlaveConn) (interface{}, error) {
+		return conn.GetSlowOps(ctx, count)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get slow ops: %v", err)
+	}
+	for _, res := range results {
+		ops = append(ops, res.result.([]*rpc.SlowOp)...)
+	}
+	sort.SliceStable(ops, func(i, j int) bool { return ops[i].Time < ops[j].Time })
+	if len(ops) > count {
+		ops = ops[len(ops)-count:]
+	}
+	return ops, nil
+}
+
 func (s *QKCMasterBackend) IsSyncing() bool {
 	return s.synchronizer.IsSyncing()
 }
@@ -413,6 +787,63 @@ func (s *QKCMasterBackend) CurrentBlock() *types.RootBlock {
 	return s.rootBlockChain.CurrentBlock()
 }
 
+func (s *QKCMasterBackend) FinalizedRootBlockNumber() uint64 {
+	return s.rootBlockChain.FinalizedNumber()
+}
+
+// ResolveRootBlockNumber returns the height of the root block named by the
+// block number: the tip for latest and confirmed, and for finalized the block
+// CONFIRMATION_DEPTH below the tip, or the last one which can no longer be
+// reorganized if higher.
+func (s *QKCMasterBackend) ResolveRootBlockNumber(blockNr qrpc.BlockNumber) (uint64, error) {
+	tip := s.rootBlockChain.CurrentBlock().NumberU64()
+	switch blockNr {
+	case qrpc.LatestBlockNumber, qrpc.ConfirmedBlockNumber:
+		return tip, nil
+	case qrpc.FinalizedBlockNumber:
+		var number uint64
+		if depth := s.clusterConfig.Master.ConfirmationDepth; tip > depth {
+			number = tip - depth
+		}
+		if finalized := s.rootBlockChain.FinalizedNumber(); finalized > number {
+			number = finalized
+		}
+		return number, nil
+	case qrpc.PendingBlockNumber:
+		return 0, errors.New("pending block not supported")
+	}
+	if blockNr < 0 {
+		return 0, errors.New("invalid block Num")
+	}
+	return uint64(blockNr), nil
+}
+
+// ResolveMinorBlockNumber returns the height of the minor block of the shard
+// named by the block number: the shard tip for latest, and the last block of
+// the shard confirmed by the root block of confirmed or finalized for them.
+func (s *QKCMasterBackend) ResolveMinorBlockNumber(fullShardId uint32, blockNr qrpc.BlockNumber) (uint64, error) {
+	switch blockNr {
+	case qrpc.LatestBlockNumber:
+		return s.GetLastMinorBlockByFullShardID(fullShardId)
+	case qrpc.ConfirmedBlockNumber, qrpc.FinalizedBlockNumber:
+		number, err := s.ResolveRootBlockNumber(blockNr)
+		if err != nil {
+			return 0, err
+		}
+		rBlock, ok := s.rootBlockChain.GetBlockByNumber(number).(*types.RootBlock)
+		if !ok {
+			return 0, fmt.Errorf("root block %d not found", number)
+		}
+		header := s.rootBlockChain.GetLastConfirmedMinorBlockHeader(rBlock.Hash(), fullShardId)
+		if header == nil {
+			return 0, fmt.Errorf("no block of shard %d is confirmed by root block %d", fullShardId, number)
+		}
+		return header.Number, nil
+	}
+	// the heights are given as they are for both chains
+	return s.ResolveRootBlockNumber(blockNr)
+}
+
 func (s *QKCMasterBackend) GetSlavePoolLen() int {
 	return s.ConnCount()
 }
@@ -0,0 +1,110 @@
+package master
+
+import (
+	"fmt"
+
+	"github.com/QuarkChain/goquarkchain/cluster/audit"
+	"github.com/QuarkChain/goquarkchain/cluster/config"
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/cluster/service"
+	"github.com/QuarkChain/goquarkchain/core"
+	"github.com/QuarkChain/goquarkchain/p2p"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// openAuditLog opens the AUDIT_LOG of the master config in the data directory,
+// or keeps the audit in memory if it is not configured or the node has no
+// data directory.
+func openAuditLog(ctx *service.ServiceContext, cfg *config.MasterConfig) (*audit.Log, error) {
+	path := ""
+	if cfg != nil && cfg.AuditLog != "" {
+		path = ctx.ResolvePath(cfg.AuditLog)
+	}
+	return audit.Open(path)
+}
+
+// audit records the action of the cluster in the audit log.
+func (s *QKCMasterBackend) audit(typ, action string, detail map[string]interface{}, err error) {
+	if werr := s.auditLog.Record(typ, action, detail, err); werr != nil {
+		log.Error("Failed to write audit log", "type", typ, "action", action, "err", werr)
+	}
+}
+
+// Audit records the call of an admin API, and the error it failed with.
+func (s *QKCMasterBackend) Audit(action string, detail map[string]interface{}, err error) {
+	s.audit(audit.TypeAdmin, action, detail, err)
+}
+
+// AuditLog returns the last count entries of the audit log of the type, or of
+// all the types if typ is empty, the oldest first.
+func (s *QKCMasterBackend) AuditLog(count int, typ string) []audit.Entry {
+	return s.auditLog.Recent(count, typ)
+}
+
+// auditSlave records the action on the slave of the connection.
+func (s *QKCMasterBackend) auditSlave(action string, conn rpc.ISlaveConn, err error) {
+	masks := make([]uint32, 0, len(conn.GetShardMaskList()))
+	for _, mask := range conn.GetShardMaskList() {
+		masks = append(masks, mask.GetMask())
+	}
+	detail := map[string]interface{}{"slave": conn.GetSlaveID(), "chainMasks": masks}
+	for _, slave := range s.clusterConfig.SlaveList {
+		if slave.ID == conn.GetSlaveID() {
+			detail["endpoint"] = fmt.Sprintf("%s:%d", slave.IP, slave.Port)
+		}
+	}
+	s.audit(audit.TypeSlave, action, detail, err)
+}
+
+// auditLoop records the reorgs of the root chain dropping more than
+// AUDIT_REORG_DEPTH blocks and the bans of the peers until the master stops.
+func (s *QKCMasterBackend) auditLoop() {
+	depth := uint64(0)
+	if s.clusterConfig.Master != nil {
+		depth = s.clusterConfig.Master.AuditReorgDepth
+	}
+	reorgCh := make(chan core.RootChainReorgEvent, 16)
+	reorgSub := s.rootBlockChain.SubscribeReorgEvent(reorgCh)
+	defer reorgSub.Unsubscribe()
+	banCh := make(chan *p2p.PeerBanEvent, 16)
+	if s.srvr != nil {
+		banSub := s.srvr.SubscribeBans(banCh)
+		defer banSub.Unsubscribe()
+	}
+
+	for {
+		select {
+		case ev := <-reorgCh:
+			if ev.Depth <= depth {
+				continue
+			}
+			detail := map[string]interface{}{"depth": ev.Depth, "added": len(ev.NewChain)}
+			if ev.CommonBlock != nil {
+				detail["commonNumber"], detail["commonHash"] = ev.CommonBlock.NumberU64(), ev.CommonBlock.Hash()
+			}
+			if len(ev.OldChain) > 0 {
+				detail["oldTip"] = ev.OldChain[0].Hash()
+			}
+			if len(ev.NewChain) > 0 {
+				detail["newTip"] = ev.NewChain[0].Hash()
+			}
+			s.audit(audit.TypeReorg, "root", detail, nil)
+		case ev := <-banCh:
+			action, detail := "unban", map[string]interface{}{"node": ev.ID.String(), "reason": ev.Reason}
+			if ev.Banned {
+				action = "ban"
+				if !ev.Until.IsZero() {
+					detail["until"] = ev.Until
+				}
+				if ev.Reason == p2p.BanReasonReputation {
+					detail["score"] = ev.Score
+				}
+			}
+			s.audit(audit.TypeBan, action, detail, nil)
+		case <-reorgSub.Err():
+			return
+		case <-s.exitCh:
+			return
+		}
+	}
+}
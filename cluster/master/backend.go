@@ -1,10 +1,16 @@
 package master
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/account/keystore"
+	"github.com/QuarkChain/goquarkchain/account/external"
+	"github.com/QuarkChain/goquarkchain/cluster/audit"
 	"github.com/QuarkChain/goquarkchain/cluster/config"
+	"github.com/QuarkChain/goquarkchain/cluster/eventsink"
+	"github.com/QuarkChain/goquarkchain/cluster/metrics"
 	"github.com/QuarkChain/goquarkchain/cluster/miner"
 	"github.com/QuarkChain/goquarkchain/cluster/rpc"
 	"github.com/QuarkChain/goquarkchain/cluster/service"
@@ -28,10 +34,12 @@ import (
 	"github.com/shirou/gopsutil/cpu"
 	"golang.org/x/sync/errgroup"
 	"gopkg.in/karalabe/cookiejar.v1/collections/deque"
+	"io/ioutil"
 	"math/big"
 	"net"
 	"os"
 	"sort"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -75,6 +83,26 @@ type QKCMasterBackend struct {
 	protocolManager    *ProtocolManager
 	synchronizer       Synchronizer.Synchronizer
 	txCountHistory     *deque.Deque
+	accountManager     *keystore.KeyStore
+	externalSigner     *external.ExternalSigner // nil if no external signer is configured
+	ephemeralKeyDir    string // removed on stop if the node has no data directory
+	coinbaseLock       sync.RWMutex
+	configLoader       func() (*config.ClusterConfig, error)
+	reloadLock         sync.Mutex
+	downSlaves         map[string]bool // slaves not answering the heartbeat
+	downSlavesLock     sync.RWMutex
+	metricsReporter    *metrics.Reporter // nil if the metrics are not reported
+	auditLog           *audit.Log
+	slowOps            *rpc.SlowOpLog
+	blockCache         *blockCache           // nil if disabled
+	txWatcher          *txWatcher            // nil if disabled
+	nonceManager       *nonceManager         // nil if disabled
+	eventSink          *eventsink.Dispatcher // nil without event sinks
+	eventWatch         map[account.Recipient]bool
+	shardTipCh         chan *shardTip
+	totalBalances      *totalBalanceCache
+	resyncLock         sync.Mutex // serializes the resyncs of the restored shards
+	rootAckLock        sync.Mutex // guards the lagging slaves routed to and the root blocks they acked
 	logInfo            string
 	exitCh             chan struct{}
 }
@@ -97,7 +125,9 @@ func New(ctx *service.ServiceContext, cfg *config.ClusterConfig) (*QKCMasterBack
 			logInfo:        "masterServer",
 			shutdown:       ctx.Shutdown,
 			txCountHistory: deque.New(),
+			downSlaves:     make(map[string]bool),
 			exitCh:         make(chan struct{}),
+			slowOps:        rpc.NewSlowOpLog("master"),
 		}
 		err error
 	)
@@ -110,7 +140,9 @@ func New(ctx *service.ServiceContext, cfg *config.ClusterConfig) (*QKCMasterBack
 	}
 
 	chainConfig, genesisHash, genesisErr := core.SetupGenesisRootBlock(mstr.chainDb, mstr.gspc)
-	// TODO check config err
+	if compatErr, ok := genesisErr.(*config.ForkCompatError); ok {
+		return nil, compatErr
+	}
 	if genesisErr != nil {
 		log.Info("Fill in block into chain db.")
 		rawdb.WriteChainConfig(mstr.chainDb, genesisHash, cfg.Quarkchain)
@@ -130,11 +162,112 @@ func New(ctx *service.ServiceContext, cfg *config.ClusterConfig) (*QKCMasterBack
 		return nil, err
 	}
 
+	if mstr.accountManager, mstr.ephemeralKeyDir, err = createKeyStore(ctx, cfg); err != nil {
+		return nil, err
+	}
+	// mine to the first account of the keystore if no coinbase is configured
+	if root := cfg.Quarkchain.Root; root.CoinbaseAddress.IsEmpty() {
+		if accounts := mstr.accountManager.Accounts(); len(accounts) > 0 {
+			root.CoinbaseAddress = accounts[0].Address
+			log.Info("Set root coinbase to the first account of the keystore", "coinbase", root.CoinbaseAddress.ToHex())
+		}
+	}
+
+	if cfg.ExternalSigner != "" {
+		if mstr.externalSigner, err = external.NewExternalSigner(cfg.ExternalSigner); err != nil {
+			return nil, fmt.Errorf("failed to connect to external signer %s: %v", cfg.ExternalSigner, err)
+		}
+		mstr.rootBlockChain.SetRootBlockSigner(mstr.externalSigner.SignRootBlock)
+		log.Info("Sign with external signer", "endpoint", cfg.ExternalSigner)
+	}
+
 	mstr.miner = miner.New(ctx, mstr, mstr.engine)
+	if cfg.Quarkchain.Root.ConsensusConfig.MineOnDemand {
+		mstr.miner.SetOnDemand(mstr.hasWork)
+	}
+
+	if mstr.metricsReporter, err = metrics.NewReporter(cfg.Monitoring, "master", mstr.metricPoints); err != nil {
+		return nil, err
+	}
+	if mstr.auditLog, err = openAuditLog(ctx, cfg.Master); err != nil {
+		return nil, err
+	}
+	mstr.blockCache = newBlockCache(cfg.Master.BlockCacheSize * 1024 * 1024)
+	mstr.totalBalances = newTotalBalanceCache()
+	if cfg.Master.TxWatcher || cfg.Master.TxWatcherWebhook != "" {
+		if mstr.txWatcher, err = newTxWatcher(mstr, cfg.Master.TxWatcherWebhook); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Master.NonceManager {
+		mstr.nonceManager = newNonceManager(mstr)
+	}
+	if mstr.eventSink, err = eventsink.New(cfg.Master.EventSinks); err != nil {
+		return nil, err
+	}
+	if mstr.eventSink != nil {
+		recipients, err := cfg.Master.GetEventWatchRecipients()
+		if err != nil {
+			return nil, err
+		}
+		mstr.eventWatch = make(map[account.Recipient]bool, len(recipients))
+		for _, recipient := range recipients {
+			mstr.eventWatch[recipient] = true
+		}
+		mstr.shardTipCh = make(chan *shardTip, shardTipQueue)
+	}
+	mstr.slowOps.SetThreshold(cfg.SlowRPCThreshold)
+	mstr.slowOps.SetPeers(cfg.SlaveList)
+	rpc.SetChunkSize(cfg.RPCChunkSize)
 
 	return mstr, nil
 }
 
+// hasWork reports whether a shard has minor blocks which are not confirmed by
+// the root tip yet, to be included in a root block mined on demand.
+func (s *QKCMasterBackend) hasWork() bool {
+	confirmed := s.rootBlockChain.GetLatestMinorBlockHeaders(s.rootBlockChain.CurrentBlock().Hash())
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	for fullShardID, stats := range s.branchToShardStats {
+		height := uint64(0)
+		if header, ok := confirmed[fullShardID]; ok {
+			height = header.Number
+		}
+		if stats.Height > height {
+			return true
+		}
+	}
+	return false
+}
+
+// createKeyStore opens the key directory of the accounts, which is a temporary
+// directory if neither the key directory nor the data directory is configured.
+func createKeyStore(ctx *service.ServiceContext, cfg *config.ClusterConfig) (*keystore.KeyStore, string, error) {
+	scryptN, scryptP := keystore.StandardScryptN, keystore.StandardScryptP
+	if cfg.LightKDF {
+		scryptN, scryptP = keystore.LightScryptN, keystore.LightScryptP
+	}
+	var (
+		keydir    = cfg.KeyStoreDir
+		ephemeral string
+		err       error
+	)
+	if keydir == "" {
+		keydir = ctx.ResolvePath("keystore")
+	}
+	if keydir == "" {
+		if keydir, err = ioutil.TempDir("", "qkc-keystore"); err != nil {
+			return nil, "", err
+		}
+		ephemeral = keydir
+	}
+	if err = os.MkdirAll(keydir, 0700); err != nil {
+		return nil, "", err
+	}
+	return keystore.NewKeyStore(keydir, scryptN, scryptP), ephemeral, nil
+}
+
 func createDB(ctx *service.ServiceContext, name string, clean bool, isReadOnly bool) (ethdb.Database, error) {
 	db, err := ctx.OpenDatabase(name, clean, isReadOnly)
 	if err != nil {
@@ -151,7 +284,11 @@ func createConsensusEngine(cfg *config.RootConfig, pubKey []byte, qkcHashXHeight
 	}
 	switch cfg.ConsensusType {
 	case config.PoWSimulate:
-		return simulate.New(&diffCalculator, cfg.ConsensusConfig.RemoteMine, pubKey, uint64(cfg.ConsensusConfig.TargetBlockTime)), nil
+		blockInterval := uint64(cfg.ConsensusConfig.TargetBlockTime)
+		if cfg.ConsensusConfig.MineOnDemand {
+			blockInterval = 0
+		}
+		return simulate.New(&diffCalculator, cfg.ConsensusConfig.RemoteMine, pubKey, blockInterval), nil
 	case config.PoWEthash:
 		return ethash.New(ethash.Config{CachesInMem: 3, CachesOnDisk: 10, CacheDir: "", PowMode: ethash.ModeNormal}, &diffCalculator, cfg.ConsensusConfig.RemoteMine, pubKey), nil
 	case config.PoWQkchash:
@@ -226,7 +363,7 @@ func (s *QKCMasterBackend) CheckDB() {
 			for _, slvConn := range s.GetSlaveConns() {
 				conn, block := slvConn, b.(*types.RootBlock)
 				g.Go(func() error {
-					return conn.CheckMinorBlocksInRoot(block)
+					return conn.CheckMinorBlocksInRoot(context.Background(), block)
 				})
 			}
 		}
@@ -258,6 +395,18 @@ func (s *QKCMasterBackend) APIs() []qrpc.API {
 
 // Stop stop node -> stop qkcMaster
 func (s *QKCMasterBackend) Stop() error {
+	if s.metricsReporter != nil {
+		s.metricsReporter.Stop()
+	}
+	if s.txWatcher != nil {
+		s.txWatcher.stop()
+	}
+	if s.nonceManager != nil {
+		s.nonceManager.stop()
+	}
+	if s.eventSink != nil {
+		s.eventSink.Stop()
+	}
 	s.synchronizer.Close()
 	s.protocolManager.Stop()
 	s.miner.Stop()
@@ -265,11 +414,18 @@ func (s *QKCMasterBackend) Stop() error {
 	s.rootBlockChain.Stop()
 	s.eventMux.Stop()
 	s.chainDb.Close()
+	s.accountManager.Close()
+	if s.externalSigner != nil {
+		s.externalSigner.Close()
+	}
+	if s.ephemeralKeyDir != "" {
+		os.RemoveAll(s.ephemeralKeyDir)
+	}
 	close(s.exitCh)
 	for _, slv := range s.GetSlaveConns() {
-		conn := slv.(*SlaveConnection)
-		conn.client.Close()
+		slv.Close()
 	}
+	s.auditLog.Close()
 	return nil
 }
 
@@ -279,7 +435,7 @@ func (s *QKCMasterBackend) Init(srvr *p2p.Server) error {
 		s.srvr = srvr
 		s.maxPeers = srvr.MaxPeers
 	}
-	err := s.SlaveConnManager.InitConnManager(s.clusterConfig)
+	err := s.SlaveConnManager.InitConnManager(s.clusterConfig, s.slowOps)
 	if err != nil {
 		return err
 	}
@@ -288,20 +444,19 @@ func (s *QKCMasterBackend) Init(srvr *p2p.Server) error {
 	if err := s.initShards(); err != nil {
 		return err
 	}
+	for _, conn := range s.GetSlaveConns() {
+		s.auditSlave("registered", conn, nil)
+	}
 
 	s.Heartbeat()
 	return nil
 }
 
-func (s *QKCMasterBackend) SetMining(mining bool) {
-	var g errgroup.Group
-	for _, slvConn := range s.GetSlaveConns() {
-		conn := slvConn
-		g.Go(func() error {
-			return conn.SetMining(mining)
-		})
-	}
-	if err := g.Wait(); err != nil {
+func (s *QKCMasterBackend) SetMining(ctx context.Context, mining bool) {
+	err := fanOutAllSlaves(ctx, s.GetSlaveConns(), func(ctx context.Context, conn rpc.ISlaveConn) error {
+		return conn.SetMining(ctx, mining)
+	})
+	if err != nil {
 		log.Error("Set slave mining failed", "err", err)
 		return
 	}
@@ -321,7 +476,22 @@ func (s *QKCMasterBackend) Start() error {
 	s.updateShardStatsLoop()
 
 	if s.clusterConfig.Quarkchain.Root.ConsensusConfig.RemoteMine {
-		s.SetMining(true)
+		s.SetMining(context.Background(), true)
+	}
+	if s.metricsReporter != nil {
+		s.metricsReporter.Start()
+	}
+	go s.auditLoop()
+	go s.resyncLoop()
+	if s.txWatcher != nil {
+		s.txWatcher.start()
+	}
+	if s.nonceManager != nil {
+		s.nonceManager.start()
+	}
+	if s.eventSink != nil {
+		s.eventSink.Start()
+		go s.eventLoop()
 	}
 
 	log.Info("Start cluster successful", "slaveSize", s.ConnCount())
@@ -342,16 +512,9 @@ func (s *QKCMasterBackend) getSlaveInfoListFromClusterConfig() []*rpc.SlaveInfo
 }
 
 func (s *QKCMasterBackend) initShards() error {
-	var g errgroup.Group
-	ip, port := s.clusterConfig.Quarkchain.GRPCHost, s.clusterConfig.Quarkchain.GRPCPort
-	for _, client := range s.GetSlaveConns() {
-		client := client
-		g.Go(func() error {
-			err := client.MasterInfo(ip, port, s.rootBlockChain.CurrentBlock())
-			return err
-		})
-	}
-	return g.Wait()
+	return fanOutAllSlaves(context.Background(), s.GetSlaveConns(), func(ctx context.Context, conn rpc.ISlaveConn) error {
+		return s.initSlave(conn)
+	})
 }
 
 func (s *QKCMasterBackend) updateShardStatsLoop() {
@@ -368,19 +531,24 @@ func (s *QKCMasterBackend) updateShardStatsLoop() {
 }
 
 func (s *QKCMasterBackend) broadcastRootBlockToSlaves(block *types.RootBlock) error {
-	var g errgroup.Group
-	for _, client := range s.GetSlaveConns() {
-		client := client
-		g.Go(func() error {
-			err := client.AddRootBlock(block, false)
-			if err != nil {
-				log.Error("broadcastRootBlockToSlaves failed", "slave", client.GetSlaveID(),
-					"block", block.Hash(), "root parent hash", block.ParentHash().Hex(), "height", block.NumberU64(), "err", err)
-			}
+	// the slaves lagging get the root block once caught up
+	return fanOutAllSlaves(context.Background(), s.routedSlaveConns(), func(ctx context.Context, conn rpc.ISlaveConn) error {
+		err := conn.AddRootBlock(ctx, block, false)
+		if err != nil && strings.Contains(err.Error(), rpc.ErrShardPaused.Error()) {
+			// the slave gets the root block again once its shard is resumed
+			log.Warn("Slave deferred root block of paused shard", "slave", conn.GetSlaveID(), "height", block.NumberU64())
+			s.setSlaveLagging(conn.GetSlaveID(), true)
+			return nil
+		}
+		if err != nil {
+			log.Error("broadcastRootBlockToSlaves failed", "slave", conn.GetSlaveID(),
+				"block", block.Hash(), "root parent hash", block.ParentHash().Hex(), "height", block.NumberU64(), "err", err)
+			s.setSlaveLagging(conn.GetSlaveID(), true)
 			return err
-		})
-	}
-	return g.Wait()
+		}
+		s.writeSlaveRootAck(conn.GetSlaveID(), block)
+		return nil
+	})
 }
 
 func (s *QKCMasterBackend) Heartbeat() {
@@ -394,12 +562,25 @@ func (s *QKCMasterBackend) Heartbeat() {
 				timeGap := time.Now()
 				s.ctx.Timestamp = timeGap
 				for _, conn := range s.GetSlaveConns() {
-					normal = conn.HeartBeat()
+					if normal = conn.HeartBeat(); !normal {
+						s.setSlaveDown(conn.GetSlaveID(), true)
+						s.setSlaveLagging(conn.GetSlaveID(), true)
+						s.auditSlave("down", conn, nil)
+						if normal = s.recoverSlave(conn); normal {
+							s.setSlaveDown(conn.GetSlaveID(), false)
+							s.auditSlave("restarted", conn, nil)
+						} else {
+							s.auditSlave("lost", conn, nil)
+						}
+					}
 					if !normal {
-						s.SetMining(false)
+						s.SetMining(context.Background(), false)
 						s.shutdown <- syscall.SIGTERM
 						break
 					}
+					if s.isSlaveLagging(conn.GetSlaveID()) {
+						s.retryCatchUpSlave(conn)
+					}
 				}
 				log.Trace(s.logInfo, "heart beat duration", time.Now().Sub(timeGap).String())
 				time.Sleep(config.HeartbeatInterval)
@@ -408,6 +589,44 @@ func (s *QKCMasterBackend) Heartbeat() {
 	}(true)
 }
 
+// recoverSlave waits for the slave which stopped answering the heartbeat to be
+// restarted within the slave restart timeout, and initializes it again as the
+// cluster starts.
+func (s *QKCMasterBackend) recoverSlave(conn rpc.ISlaveConn) bool {
+	if s.clusterConfig.Master == nil || s.clusterConfig.Master.SlaveRestartTimeout == 0 {
+		return false
+	}
+	timeout := time.Duration(s.clusterConfig.Master.SlaveRestartTimeout) * time.Second
+	log.Warn("Slave is down, waiting for it to restart", "slave", conn.GetSlaveID(), "timeout", timeout)
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(time.Second) {
+		select {
+		case <-s.exitCh:
+			return false
+		default:
+		}
+		slaveID, chainMaskList, err := conn.SendPing()
+		if err == nil {
+			err = checkPing(conn, slaveID, chainMaskList)
+		}
+		if err != nil {
+			continue
+		}
+		if err := s.initSlave(conn); err != nil {
+			log.Error("Failed to initialize restarted slave", "slave", conn.GetSlaveID(), "err", err)
+			return false
+		}
+		if s.miner.IsMining() {
+			if err := conn.SetMining(context.Background(), true); err != nil {
+				log.Error("Failed to set mining of restarted slave", "slave", conn.GetSlaveID(), "err", err)
+			}
+		}
+		log.Info("Slave restarted", "slave", conn.GetSlaveID())
+		go s.resyncLoop()
+		return true
+	}
+	return false
+}
+
 func checkPing(slaveConn rpc.ISlaveConn, id []byte, chainMaskList []*types.ChainMask) error {
 	if slaveConn.GetSlaveID() != string(id) {
 		return errors.New("slaveID is not match")
@@ -426,27 +645,16 @@ func checkPing(slaveConn rpc.ISlaveConn, id []byte, chainMaskList []*types.Chain
 }
 
 func (s *QKCMasterBackend) createRootBlockToMine(address account.Address) (*types.RootBlock, error) {
-	var (
-		g     errgroup.Group
-		conns = s.GetSlaveConns()
-	)
-	rspList := make(chan *rpc.GetUnconfirmedHeadersResponse, s.ConnCount())
-
-	for _, conn := range conns {
-		conn := conn
-		g.Go(func() error {
-			rsp, err := conn.GetUnconfirmedHeaders()
-			rspList <- rsp
-			return err
-		})
-	}
-	if err := g.Wait(); err != nil {
+	results, err := fanOut(context.Background(), s.GetSlaveConns(), fanOutAll, 0, func(ctx context.Context, conn rpc.ISlaveConn) (interface{}, error) {
+		return conn.GetUnconfirmedHeaders(ctx)
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	fullShardIDToHeaderList := make(map[uint32][]*types.MinorBlockHeader, 0)
-	for index := 0; index < len(conns); index++ {
-		resp := <-rspList
+	for _, res := range results {
+		resp := res.result.(*rpc.GetUnconfirmedHeadersResponse)
 		for _, headersInfo := range resp.HeadersInfoList {
 			if _, ok := fullShardIDToHeaderList[headersInfo.Branch]; ok { // to avoid overlap
 				continue // skip it if has added
@@ -482,39 +690,72 @@ func (s *QKCMasterBackend) createRootBlockToMine(address account.Address) (*type
 }
 
 // GetAccountData get account Data for jsonRpc
-func (s *QKCMasterBackend) GetAccountData(address *account.Address, height *uint64) (map[uint32]*rpc.AccountBranchData, error) {
+// GetAccountData returns the data of the account in every shard activated.
+// The shards failed to be read, or whose slaves failed, are returned with
+// their error, unless another slave running them succeeds; an error is only
+// returned if all the shards fail.
+func (s *QKCMasterBackend) GetAccountData(ctx context.Context, address *account.Address, height *uint64) (map[uint32]*rpc.AccountBranchData, error) {
 	var (
-		g     errgroup.Group
-		conns = s.GetSlaveConns()
+		wg      sync.WaitGroup
+		conns   = s.GetSlaveConns()
+		rspList = make([]*rpc.GetAccountDataResponse, len(conns))
+		errList = make([]error, len(conns))
 	)
-	rspList := make(chan *rpc.GetAccountDataResponse, len(conns))
-	for _, conn := range conns {
-		conn := conn
-		g.Go(func() error {
-			rsp, err := conn.GetAccountData(address, height)
-			rspList <- rsp
-			return err
-		})
+	for i, conn := range conns {
+		wg.Add(1)
+		go func(i int, conn rpc.ISlaveConn) {
+			defer wg.Done()
+			rspList[i], errList[i] = conn.GetAccountData(ctx, address, height)
+		}(i, conn)
 	}
-	if err := g.Wait(); err != nil {
-		return nil, err
+	wg.Wait()
+
+	// the shards activated after genesis are only created at their genesis root height
+	activated := s.clusterConfig.Quarkchain.GetInitializedShardIdsBeforeRootHeight(s.rootBlockChain.CurrentBlock().Number() + 1)
+	branchToAccountBranchData := make(map[uint32]*rpc.AccountBranchData, len(activated))
+	add := func(data *rpc.AccountBranchData) {
+		if old, ok := branchToAccountBranchData[data.Branch]; !ok || old.Error != "" {
+			branchToAccountBranchData[data.Branch] = data
+		}
 	}
-
-	branchToAccountBranchData := make(map[uint32]*rpc.AccountBranchData)
-	for index := 0; index < len(conns); index++ {
-		rsp := <-rspList
-		for _, accountBranchData := range rsp.AccountBranchDataList {
-			branchToAccountBranchData[accountBranchData.Branch] = accountBranchData
+	for i, conn := range conns {
+		if errList[i] != nil {
+			log.Warn("Failed to get account data", "slave", conn.GetSlaveID(), "err", errList[i])
+			for _, id := range activated {
+				if conn.HasShard(id) {
+					add(&rpc.AccountBranchData{Branch: id, Error: errList[i].Error()})
+				}
+			}
+			continue
+		}
+		for _, data := range rspList[i].AccountBranchDataList {
+			add(data)
+		}
+	}
+	var (
+		succeeded bool
+		err       = errors.New("no shard activated")
+	)
+	for _, id := range activated {
+		data, ok := branchToAccountBranchData[id]
+		if !ok {
+			data = &rpc.AccountBranchData{Branch: id, Error: "no slave runs the shard"}
+			branchToAccountBranchData[id] = data
+		}
+		if data.Error == "" {
+			succeeded = true
+		} else {
+			err = errors.New(data.Error)
 		}
 	}
-	if len(branchToAccountBranchData) != len(s.clusterConfig.Quarkchain.GetGenesisShardIds()) {
-		return nil, errors.New("len is not match")
+	if !succeeded {
+		return nil, err
 	}
 	return branchToAccountBranchData, nil
 }
 
 // GetPrimaryAccountData get primary account data for jsonRpc
-func (s *QKCMasterBackend) GetPrimaryAccountData(address *account.Address, blockHeight *uint64) (*rpc.AccountBranchData, error) {
+func (s *QKCMasterBackend) GetPrimaryAccountData(ctx context.Context, address *account.Address, blockHeight *uint64) (*rpc.AccountBranchData, error) {
 	fullShardID, err := s.clusterConfig.Quarkchain.GetFullShardIdByFullShardKey(address.FullShardKey)
 	if err != nil {
 		return nil, err
@@ -523,28 +764,40 @@ func (s *QKCMasterBackend) GetPrimaryAccountData(address *account.Address, block
 	if slaveConn == nil {
 		return nil, ErrNoBranchConn
 	}
-	rsp, err := slaveConn.GetAccountData(address, blockHeight)
+	rsp, err := slaveConn.GetAccountData(ctx, address, blockHeight)
 	if err != nil {
 		return nil, err
 	}
 	for _, accountBranchData := range rsp.AccountBranchDataList {
 		if accountBranchData.Branch == fullShardID {
+			if accountBranchData.Error != "" {
+				return nil, errors.New(accountBranchData.Error)
+			}
 			return accountBranchData, nil
 		}
 	}
 	return nil, errors.New("no such data")
 }
 
+// GetPendingAccountData returns the account data of the address on the pending
+// state of its shard, with the txs of the pool applied.
+func (s *QKCMasterBackend) GetPendingAccountData(ctx context.Context, address *account.Address) (*rpc.AccountBranchData, error) {
+	fullShardID, err := s.clusterConfig.Quarkchain.GetFullShardIdByFullShardKey(address.FullShardKey)
+	if err != nil {
+		return nil, err
+	}
+	slaveConn := servingConn(s.GetSlaveConnsById(fullShardID), rpc.OpGetPendingAccountData)
+	if slaveConn == nil {
+		return nil, ErrNoBranchConn
+	}
+	return slaveConn.GetPendingAccountData(ctx, address)
+}
+
 // SendMiningConfigToSlaves send mining config to slaves,used in jsonRpc
 func (s *QKCMasterBackend) SendMiningConfigToSlaves(mining bool) error {
-	var g errgroup.Group
-	for _, conn := range s.GetSlaveConns() {
-		conn := conn
-		g.Go(func() error {
-			return conn.SendMiningConfigToSlaves(s.artificialTxConfig, mining)
-		})
-	}
-	return g.Wait()
+	return fanOutAllSlaves(context.Background(), s.GetSlaveConns(), func(ctx context.Context, conn rpc.ISlaveConn) error {
+		return conn.SendMiningConfigToSlaves(ctx, s.artificialTxConfig, mining)
+	})
 }
 
 // AddRootBlock add root block to all slaves
@@ -573,7 +826,7 @@ func (s *QKCMasterBackend) GetRootChainStakes(coinbase account.Address, lastMino
 	if conn == nil {
 		panic("chain 0 shard 0 missing.")
 	}
-	stakes, signer, err := conn.GetRootChainStakes(coinbase, lastMinor)
+	stakes, signer, err := conn.GetRootChainStakes(context.Background(), coinbase, lastMinor)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -599,15 +852,28 @@ func (s *QKCMasterBackend) SetTargetBlockTime(rootBlockTime *uint32, minorBlockT
 }
 
 // CreateTransactions Create transactions and add to the network for load testing
-func (s *QKCMasterBackend) CreateTransactions(numTxPerShard, xShardPercent uint32, tx *types.Transaction) error {
-	var g errgroup.Group
-	for _, conn := range s.GetSlaveConns() {
-		conn := conn
-		g.Go(func() error {
-			return conn.GenTx(numTxPerShard, xShardPercent, tx)
-		})
+func (s *QKCMasterBackend) CreateTransactions(ctx context.Context, req *rpc.GenTxRequest) error {
+	return fanOutAllSlaves(ctx, s.GetSlaveConns(), func(ctx context.Context, conn rpc.ISlaveConn) error {
+		return conn.GenTx(ctx, req)
+	})
+}
+
+// GetTxBenchmarkReports returns the results of the last tx benchmark of the
+// shards ordered by full shard ID.
+func (s *QKCMasterBackend) GetTxBenchmarkReports(ctx context.Context) ([]*rpc.TxBenchmarkReport, error) {
+	conns := supporting(s.GetSlaveConns(), rpc.OpGetTxBenchmarkReports)
+	results, err := fanOut(ctx, conns, fanOutAll, 0, func(ctx context.Context, conn rpc.ISlaveConn) (interface{}, error) {
+		return conn.GetTxBenchmarkReports(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	reports := make([]*rpc.TxBenchmarkReport, 0)
+	for _, res := range results {
+		reports = append(reports, res.result.([]*rpc.TxBenchmarkReport)...)
 	}
-	return g.Wait()
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Branch < reports[j].Branch })
+	return reports, nil
 }
 
 // UpdateShardStatus update shard status for branchg
@@ -617,6 +883,19 @@ func (s *QKCMasterBackend) UpdateShardStatus(status *rpc.ShardStatus) {
 	s.lock.Unlock()
 }
 
+// GetShardStatuses returns the last status reported for each shard, sorted by
+// full shard id.
+func (s *QKCMasterBackend) GetShardStatuses() []*rpc.ShardStatus {
+	s.lock.RLock()
+	statuses := make([]*rpc.ShardStatus, 0, len(s.branchToShardStats))
+	for _, status := range s.branchToShardStats {
+		statuses = append(statuses, status)
+	}
+	s.lock.RUnlock()
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Branch.Value < statuses[j].Branch.Value })
+	return statuses
+}
+
 func (s *QKCMasterBackend) GetLastMinorBlockByFullShardID(fullShardId uint32) (uint64, error) {
 	s.lock.RLock()
 	defer s.lock.RUnlock()
@@ -631,6 +910,21 @@ func (s *QKCMasterBackend) GetRootHashConfirmingMinorBlock(mBlockID []byte) comm
 	return s.rootBlockChain.GetRootBlockConfirmingMinorBlock(mBlockID)
 }
 
+// GetMinorBlockConfirmation returns the canonical root block confirming the
+// minor block, nil if none does yet, and the number of root blocks above it.
+func (s *QKCMasterBackend) GetMinorBlockConfirmation(mHash common.Hash, fullShardID uint32) (*types.RootBlock, uint64) {
+	rBlock := s.rootBlockChain.GetCanonicalRootBlockConfirmingMinorBlock(mHash, fullShardID)
+	if rBlock == nil {
+		return nil, 0
+	}
+	tip := s.rootBlockChain.CurrentBlock().NumberU64()
+	if tip < rBlock.NumberU64() {
+		// a reorg is in progress
+		return rBlock, 0
+	}
+	return rBlock, tip - rBlock.NumberU64()
+}
+
 // UpdateTxCountHistory update Tx count queue
 func (s *QKCMasterBackend) UpdateTxCountHistory(txCount, xShardTxCount uint32, createTime uint64) {
 	s.lock.Lock()
@@ -0,0 +1,154 @@
+package master
+
+import (
+	"math"
+	"sync"
+
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+// The kinds of the responses of the slaves kept in the block cache.
+const (
+	cacheMinorBlock = "minor_block"
+	cacheReceipts   = "receipts"
+)
+
+type blockCacheKey struct {
+	kind string
+	hash common.Hash
+}
+
+type blockCacheEntry struct {
+	value interface{}
+	size  uint64
+}
+
+// cachedMinorBlock is a minor block with its PoSW info, if it was requested.
+type cachedMinorBlock struct {
+	block   *types.MinorBlock
+	posw    *rpc.PoSWInfo
+	hasPoSW bool
+}
+
+// blockCacheStats are the hits and the misses of the lookups of a kind.
+type blockCacheStats struct {
+	hits   uint64
+	misses uint64
+}
+
+// blockCache keeps the minor blocks and the receipts returned by the slaves
+// by block hash, which never change once the blocks are in a shard, for the
+// explorers querying the same blocks again not to call the slaves each time.
+// The least recently used ones are dropped once they take more than the limit
+// in bytes. The root blocks are cached by the root chain already.
+type blockCache struct {
+	mu    sync.Mutex
+	lru   *simplelru.LRU
+	size  uint64
+	limit uint64
+	stats map[string]*blockCacheStats
+}
+
+// newBlockCache returns a cache of the limit in bytes, nil if 0, which is a
+// valid cache keeping nothing.
+func newBlockCache(limit uint64) *blockCache {
+	if limit == 0 {
+		return nil
+	}
+	c := &blockCache{
+		limit: limit,
+		stats: map[string]*blockCacheStats{cacheMinorBlock: {}, cacheReceipts: {}},
+	}
+	c.lru, _ = simplelru.NewLRU(math.MaxInt32, func(key, value interface{}) {
+		c.size -= value.(*blockCacheEntry).size
+	})
+	return c
+}
+
+// get returns the value of the kind cached for the hash, if any and accepted
+// by accept if not nil.
+func (c *blockCache) get(kind string, hash common.Hash, accept func(value interface{}) bool) (interface{}, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.lru.Get(blockCacheKey{kind, hash})
+	if !ok || accept != nil && !accept(entry.(*blockCacheEntry).value) {
+		c.stats[kind].misses++
+		return nil, false
+	}
+	c.stats[kind].hits++
+	return entry.(*blockCacheEntry).value, true
+}
+
+func (c *blockCache) add(kind string, hash common.Hash, value interface{}, size uint64) {
+	if c == nil || size > c.limit {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := blockCacheKey{kind, hash}
+	c.lru.Remove(key)
+	c.lru.Add(key, &blockCacheEntry{value: value, size: size})
+	c.size += size
+	for c.size > c.limit {
+		c.lru.RemoveOldest()
+	}
+}
+
+// getMinorBlock returns the cached minor block of the hash, with its PoSW
+// info if needed.
+func (c *blockCache) getMinorBlock(hash common.Hash, needExtraInfo bool) (*types.MinorBlock, *rpc.PoSWInfo, bool) {
+	value, ok := c.get(cacheMinorBlock, hash, func(value interface{}) bool {
+		return !needExtraInfo || value.(*cachedMinorBlock).hasPoSW
+	})
+	if !ok {
+		return nil, nil, false
+	}
+	cached := value.(*cachedMinorBlock)
+	return cached.block, cached.posw, true
+}
+
+func (c *blockCache) addMinorBlock(block *types.MinorBlock, posw *rpc.PoSWInfo, hasPoSW bool) {
+	if block == nil {
+		return
+	}
+	c.add(cacheMinorBlock, block.Hash(), &cachedMinorBlock{block: block, posw: posw, hasPoSW: hasPoSW}, uint64(block.Size()))
+}
+
+func (c *blockCache) getReceipts(hash common.Hash) (types.Receipts, bool) {
+	value, ok := c.get(cacheReceipts, hash, nil)
+	if !ok {
+		return nil, false
+	}
+	return value.(types.Receipts), true
+}
+
+func (c *blockCache) addReceipts(hash common.Hash, receipts types.Receipts) {
+	size := uint64(0)
+	for _, receipt := range receipts {
+		size += uint64(receipt.Size())
+	}
+	c.add(cacheReceipts, hash, receipts, size)
+}
+
+// metricFields returns the hits and the misses of each kind, and the entries
+// and the bytes cached.
+func (c *blockCache) metricFields() map[string]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fields := map[string]interface{}{
+		"entries": c.lru.Len(),
+		"bytes":   c.size,
+		"limit":   c.limit,
+	}
+	for kind, stats := range c.stats {
+		fields[kind+"_hits"] = stats.hits
+		fields[kind+"_misses"] = stats.misses
+	}
+	return fields
+}
@@ -0,0 +1,113 @@
+package master
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/p2p"
+	"github.com/QuarkChain/goquarkchain/params"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// txAnnounceInterval is how long the transaction hashes are gathered
+	// before being announced to a peer together.
+	txAnnounceInterval = 100 * time.Millisecond
+
+	// maxPendingTxAnns is the maximum number of transaction hashes waiting to
+	// be announced to a peer, past which new ones are dropped.
+	maxPendingTxAnns = 4096
+)
+
+// broadcastQueue holds the tips and transaction hashes waiting to be sent to
+// a peer. Successive tips of a branch are coalesced into the latest one, and
+// the transaction hashes of a branch are deduplicated and announced in batches.
+type broadcastQueue struct {
+	mu       sync.Mutex
+	tips     map[uint32]*p2p.Tip
+	txAnns   map[uint32][]common.Hash
+	txAnnSet map[common.Hash]struct{}
+	tipReady chan struct{} // signaled when a tip is queued
+}
+
+func newBroadcastQueue() *broadcastQueue {
+	return &broadcastQueue{
+		tips:     make(map[uint32]*p2p.Tip),
+		txAnns:   make(map[uint32][]common.Hash),
+		txAnnSet: make(map[common.Hash]struct{}),
+		tipReady: make(chan struct{}, 1),
+	}
+}
+
+// addTip queues the tip of the branch, replacing the one not sent yet.
+func (q *broadcastQueue) addTip(branch uint32, tip *p2p.Tip) {
+	q.mu.Lock()
+	q.tips[branch] = tip
+	q.mu.Unlock()
+
+	select {
+	case q.tipReady <- struct{}{}:
+	default:
+	}
+}
+
+// takeTips returns the queued tips, the root one first, and empties the queue.
+func (q *broadcastQueue) takeTips() []newTip {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	tips := make([]newTip, 0, len(q.tips))
+	for branch, tip := range q.tips {
+		tips = append(tips, newTip{branch: branch, tip: tip})
+	}
+	sort.Slice(tips, func(i, j int) bool { return tips[i].branch < tips[j].branch })
+	q.tips = make(map[uint32]*p2p.Tip)
+	return tips
+}
+
+// addTxAnns queues the hashes of transactions of the branch to announce, and
+// returns the number of hashes dropped because the queue is full.
+func (q *broadcastQueue) addTxAnns(branch uint32, hashes []common.Hash) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	dropped := 0
+	for _, hash := range hashes {
+		if _, ok := q.txAnnSet[hash]; ok {
+			continue
+		}
+		if len(q.txAnnSet) >= maxPendingTxAnns {
+			dropped++
+			continue
+		}
+		q.txAnnSet[hash] = struct{}{}
+		q.txAnns[branch] = append(q.txAnns[branch], hash)
+	}
+	return dropped
+}
+
+// takeTxAnns returns the queued transaction hashes in announcements of up to
+// params.NEW_TRANSACTION_LIST_LIMIT hashes, and empties the queue.
+func (q *broadcastQueue) takeTxAnns() []newTxAnn {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.txAnnSet) == 0 {
+		return nil
+	}
+	var anns []newTxAnn
+	for branch, hashes := range q.txAnns {
+		for len(hashes) > 0 {
+			n := len(hashes)
+			if n > params.NEW_TRANSACTION_LIST_LIMIT {
+				n = params.NEW_TRANSACTION_LIST_LIMIT
+			}
+			anns = append(anns, newTxAnn{branch: branch, hashes: hashes[:n]})
+			hashes = hashes[n:]
+		}
+	}
+	q.txAnns = make(map[uint32][]common.Hash)
+	q.txAnnSet = make(map[common.Hash]struct{})
+	return anns
+}
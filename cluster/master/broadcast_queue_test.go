@@ -0,0 +1,69 @@
+package master
+
+import (
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/p2p"
+	"github.com/QuarkChain/goquarkchain/params"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroadcastQueueTips(t *testing.T) {
+	q := newBroadcastQueue()
+	tip := func(number uint32) *p2p.Tip {
+		return &p2p.Tip{RootBlockHeader: &types.RootBlockHeader{Number: number}}
+	}
+	q.addTip(2, tip(1))
+	q.addTip(0, tip(1))
+	q.addTip(0, tip(2))
+	q.addTip(0, tip(3))
+	q.addTip(2, tip(4))
+	<-q.tipReady
+	select {
+	case <-q.tipReady:
+		t.Fatal("tips signaled more than once")
+	default:
+	}
+
+	// the successive tips of a branch are coalesced
+	tips := q.takeTips()
+	assert.Equal(t, 2, len(tips))
+	assert.Equal(t, uint32(0), tips[0].branch)
+	assert.Equal(t, uint32(3), tips[0].tip.RootBlockHeader.Number)
+	assert.Equal(t, uint32(2), tips[1].branch)
+	assert.Equal(t, uint32(4), tips[1].tip.RootBlockHeader.Number)
+	assert.Empty(t, q.takeTips())
+}
+
+func TestBroadcastQueueTxAnns(t *testing.T) {
+	q := newBroadcastQueue()
+	assert.Nil(t, q.takeTxAnns())
+
+	hashes := make([]common.Hash, params.NEW_TRANSACTION_LIST_LIMIT+10)
+	for i := range hashes {
+		hashes[i] = common.Hash{byte(i), byte(i >> 8), 1}
+	}
+	assert.Equal(t, 0, q.addTxAnns(1, hashes[:10]))
+	// the hashes already queued are not announced twice
+	assert.Equal(t, 0, q.addTxAnns(1, hashes))
+	assert.Equal(t, 0, q.addTxAnns(3, []common.Hash{{2}}))
+
+	anns := q.takeTxAnns()
+	count := map[uint32]int{}
+	for _, ann := range anns {
+		assert.True(t, len(ann.hashes) <= params.NEW_TRANSACTION_LIST_LIMIT)
+		count[ann.branch] += len(ann.hashes)
+	}
+	assert.Equal(t, 3, len(anns))
+	assert.Equal(t, map[uint32]int{1: len(hashes), 3: 1}, count)
+	assert.Nil(t, q.takeTxAnns())
+
+	// the hashes over the limit are dropped
+	many := make([]common.Hash, maxPendingTxAnns+5)
+	for i := range many {
+		many[i] = common.Hash{byte(i), byte(i >> 8), 2}
+	}
+	assert.Equal(t, 5, q.addTxAnns(1, many))
+}
@@ -0,0 +1,105 @@
+package master
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/serialize"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const (
+	exportRootBlock uint8 = iota
+	exportMinorBlock
+)
+
+// exportRecord is a serialized block in the RLP stream of an exported chain,
+// the minor blocks confirmed by a root block always precede it.
+type exportRecord struct {
+	Kind uint8
+	Data []byte
+}
+
+func writeExportRecord(w io.Writer, kind uint8, block interface{}) error {
+	data, err := serialize.SerializeToBytes(block)
+	if err != nil {
+		return err
+	}
+	return rlp.Encode(w, &exportRecord{Kind: kind, Data: data})
+}
+
+// ExportChain writes the canonical root blocks in [first, last] together with
+// the minor blocks they confirm into w.
+func (s *QKCMasterBackend) ExportChain(w io.Writer, first, last uint64) error {
+	if tip := s.rootBlockChain.CurrentBlock().NumberU64(); last > tip {
+		return fmt.Errorf("export range [%d, %d] exceeds root tip %d", first, last, tip)
+	}
+	if first > last {
+		return fmt.Errorf("invalid export range [%d, %d]", first, last)
+	}
+	for number := first; number <= last; number++ {
+		rBlock, ok := s.rootBlockChain.GetBlockByNumber(number).(*types.RootBlock)
+		if !ok {
+			return fmt.Errorf("root block %d not found", number)
+		}
+		for _, header := range rBlock.MinorBlockHeaders() {
+			mBlock, _, err := s.GetMinorBlockByHash(context.Background(), header.Hash(), header.Branch, false)
+			if err != nil {
+				return fmt.Errorf("failed to get minor block %x: %v", header.Hash(), err)
+			}
+			if err := writeExportRecord(w, exportMinorBlock, mBlock); err != nil {
+				return err
+			}
+		}
+		if err := writeExportRecord(w, exportRootBlock, rBlock); err != nil {
+			return err
+		}
+		if number%1000 == 0 {
+			log.Info("Exporting blocks", "root height", number)
+		}
+	}
+	return nil
+}
+
+// ImportChain adds the blocks exported by ExportChain from r to the cluster,
+// the known blocks are skipped.
+func (s *QKCMasterBackend) ImportChain(r io.Reader) error {
+	stream := rlp.NewStream(r, 0)
+	for n := 0; ; n++ {
+		var record exportRecord
+		if err := stream.Decode(&record); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("at record %d: %v", n, err)
+		}
+		switch record.Kind {
+		case exportMinorBlock:
+			mBlock := new(types.MinorBlock)
+			if err := serialize.DeserializeFromBytes(record.Data, mBlock); err != nil {
+				return fmt.Errorf("at record %d: %v", n, err)
+			}
+			if err := s.AddMinorBlock(mBlock.Branch().Value, mBlock); err != nil {
+				return fmt.Errorf("failed to add minor block %x: %v", mBlock.Hash(), err)
+			}
+		case exportRootBlock:
+			rBlock := new(types.RootBlock)
+			if err := serialize.DeserializeFromBytes(record.Data, rBlock); err != nil {
+				return fmt.Errorf("at record %d: %v", n, err)
+			}
+			if s.rootBlockChain.HasBlock(rBlock.Hash()) {
+				continue
+			}
+			if err := s.AddRootBlock(rBlock); err != nil {
+				return fmt.Errorf("failed to add root block %d: %v", rBlock.NumberU64(), err)
+			}
+			if rBlock.NumberU64()%1000 == 0 {
+				log.Info("Importing blocks", "root height", rBlock.NumberU64())
+			}
+		default:
+			return fmt.Errorf("at record %d: unknown kind %d", n, record.Kind)
+		}
+	}
+}
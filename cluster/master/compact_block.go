@@ -0,0 +1,190 @@
+package master
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/p2p"
+	"github.com/QuarkChain/goquarkchain/serialize"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/pkg/errors"
+)
+
+// maxCompactBlocks is the number of the compact blocks received lately, which
+// are not rebuilt or fetched again when sent by another peer.
+const maxCompactBlocks = 1024
+
+// shortTxID returns the short ID of a transaction in a compact block, the first
+// 8 bytes of its hash. A collision only costs a fetch of the full block, as the
+// rebuilt block is checked against the transaction root of its meta.
+func shortTxID(hash common.Hash) uint64 {
+	return binary.BigEndian.Uint64(hash[:8])
+}
+
+// newCompactBlock returns the compact block of the block, with the
+// transactions for which known returns false prefilled.
+func newCompactBlock(block *types.MinorBlock, known func(hash common.Hash) bool) *p2p.NewCompactBlockMinor {
+	txs := block.Transactions()
+	cb := &p2p.NewCompactBlockMinor{
+		Header:       block.Header(),
+		Meta:         block.Meta(),
+		ShortIDs:     make([]uint64, 0, len(txs)),
+		PrefilledTxs: make([]*p2p.PrefilledTransaction, 0),
+		TrackingData: block.TrackingData(),
+	}
+	for i, tx := range txs {
+		if hash := tx.Hash(); known(hash) {
+			cb.ShortIDs = append(cb.ShortIDs, shortTxID(hash))
+		} else {
+			cb.PrefilledTxs = append(cb.PrefilledTxs, &p2p.PrefilledTransaction{Index: uint32(i), Tx: tx})
+		}
+	}
+	return cb
+}
+
+// validateCompactBlock checks the compact block of the branch is well formed.
+func validateCompactBlock(branch uint32, cb *p2p.NewCompactBlockMinor) error {
+	if cb.Header == nil || cb.Meta == nil {
+		return errors.New("invalid compact block: header or meta is nil")
+	}
+	if cb.Header.Branch.Value != branch {
+		return fmt.Errorf("invalid compact block: branch %d in header, want %d", cb.Header.Branch.Value, branch)
+	}
+	if cb.Header.MetaHash != cb.Meta.Hash() {
+		return fmt.Errorf("invalid compact block %x: meta hash mismatch", cb.Header.Hash())
+	}
+	count := len(cb.ShortIDs) + len(cb.PrefilledTxs)
+	for i, prefilled := range cb.PrefilledTxs {
+		if prefilled.Tx == nil || int(prefilled.Index) >= count ||
+			(i > 0 && prefilled.Index <= cb.PrefilledTxs[i-1].Index) {
+			return fmt.Errorf("invalid compact block %x: bad prefilled transaction %d", cb.Header.Hash(), i)
+		}
+	}
+	return nil
+}
+
+// rebuildCompactBlock fills the compact block with the prefilled transactions
+// and the ones found by lookup. It returns nil if a transaction is missing or
+// the transactions don't match the transaction root of the block.
+func rebuildCompactBlock(cb *p2p.NewCompactBlockMinor, lookup func(ids map[uint64]int) map[uint64]*types.Transaction) *types.MinorBlock {
+	ids := make(map[uint64]int, len(cb.ShortIDs))
+	for _, id := range cb.ShortIDs {
+		ids[id]++
+	}
+	found := lookup(ids)
+	txs := make([]*types.Transaction, 0, len(cb.ShortIDs)+len(cb.PrefilledTxs))
+	next := 0
+	for _, prefilled := range cb.PrefilledTxs {
+		for len(txs) < int(prefilled.Index) {
+			tx := found[cb.ShortIDs[next]]
+			if tx == nil {
+				return nil
+			}
+			txs = append(txs, tx)
+			next++
+		}
+		txs = append(txs, prefilled.Tx)
+	}
+	for ; next < len(cb.ShortIDs); next++ {
+		tx := found[cb.ShortIDs[next]]
+		if tx == nil {
+			return nil
+		}
+		txs = append(txs, tx)
+	}
+	// an empty block is checked too, against the root of no transactions
+	if types.CalculateMerkleRoot(types.Transactions(txs)) != cb.Meta.TxHash {
+		return nil
+	}
+	return types.NewMinorBlockWithHeader(cb.Header, cb.Meta).WithBody(txs, cb.TrackingData)
+}
+
+// lookupCachedTxs returns the transactions broadcast lately of the short IDs,
+// which are those added to the pools of the shards. A short ID shared by two
+// transactions of the block, or by two cached ones, is left out.
+func (pm *ProtocolManager) lookupCachedTxs(ids map[uint64]int) map[uint64]*types.Transaction {
+	found := make(map[uint64]*types.Transaction, len(ids))
+	ambiguous := make(map[uint64]bool)
+	for _, key := range pm.txCache.Keys() {
+		id := shortTxID(key.(common.Hash))
+		if ids[id] == 0 {
+			continue
+		}
+		tx, ok := pm.txCache.Peek(key)
+		if !ok {
+			continue
+		}
+		if _, ok := found[id]; ok || ids[id] > 1 {
+			ambiguous[id] = true
+		}
+		found[id] = tx.(*types.Transaction)
+	}
+	for id := range ambiguous {
+		delete(found, id)
+	}
+	return found
+}
+
+// handleCompactBlock rebuilds the compact block sent by the peer with the
+// transactions broadcast lately, or fetches the full block from the peer if
+// some are missing, and adds it to the shards of the branch.
+func (pm *ProtocolManager) handleCompactBlock(peer *Peer, branch uint32, cb *p2p.NewCompactBlockMinor) error {
+	hash := cb.Header.Hash()
+	if ok, _ := pm.newBlocks.ContainsOrAdd(hash, struct{}{}); ok {
+		return nil
+	}
+	block := rebuildCompactBlock(cb, pm.lookupCachedTxs)
+	if block == nil {
+		var err error
+		if block, err = pm.fetchMinorBlock(peer, branch, hash); err != nil {
+			// the block is fetched from the next peer sending it
+			pm.newBlocks.Remove(hash)
+			log.Debug("Failed to fetch compact block", "peer", peer.id, "branch", branch, "hash", hash, "err", err)
+			return nil
+		}
+		log.Trace("Fetched compact block", "peer", peer.id, "branch", branch, "hash", hash)
+	} else {
+		log.Trace("Rebuilt compact block", "peer", peer.id, "branch", branch, "hash", hash,
+			"txs", len(block.Transactions()), "prefilled", len(cb.PrefilledTxs))
+	}
+
+	hashes := make([]common.Hash, len(block.Transactions()))
+	for i, tx := range block.Transactions() {
+		hashes[i] = tx.Hash()
+	}
+	peer.MarkTransactions(hashes)
+	pm.txFetcher.markSeen(hashes)
+	data, err := serialize.SerializeToBytes(&p2p.NewBlockMinor{Block: block})
+	if err != nil {
+		return err
+	}
+	if err := pm.HandleNewMinorBlock(peer.id, branch, data); err != nil {
+		// the block is accepted from the next peer sending it
+		pm.newBlocks.Remove(hash)
+		return err
+	}
+	return nil
+}
+
+// fetchMinorBlock retrieves the block of the hash from the peer.
+func (pm *ProtocolManager) fetchMinorBlock(peer *Peer, branch uint32, hash common.Hash) (*types.MinorBlock, error) {
+	data, err := serialize.SerializeToBytes(&p2p.GetMinorBlockListRequest{MinorBlockHashList: []common.Hash{hash}})
+	if err != nil {
+		return nil, err
+	}
+	data, err = peer.GetMinorBlockList(&rpc.P2PRedirectRequest{PeerID: peer.id, Branch: branch, Data: data})
+	if err != nil {
+		return nil, err
+	}
+	var resp p2p.GetMinorBlockListResponse
+	if err := serialize.DeserializeFromBytes(data, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.MinorBlockList) != 1 || resp.MinorBlockList[0].Hash() != hash {
+		return nil, fmt.Errorf("block %x not returned", hash)
+	}
+	return resp.MinorBlockList[0], nil
+}
@@ -0,0 +1,168 @@
+package master
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/mocks/mock_master"
+	"github.com/QuarkChain/goquarkchain/p2p"
+	"github.com/QuarkChain/goquarkchain/serialize"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestBlockWithTxs returns a child block of the parent with count dummy
+// transactions starting at the nonce.
+func newTestBlockWithTxs(parent *types.MinorBlock, nonce uint64, count int) *types.MinorBlock {
+	key, _ := crypto.HexToECDSA("45a915e4d060149eb4365960e6a7a45f334393093061116b197e3240065ff2d8")
+	txs := make([]*types.Transaction, count)
+	for i := range txs {
+		txs[i] = newTestTransaction(key, nonce+uint64(i), 100)
+	}
+	block := types.NewMinorBlock(parent.Header(), parent.Meta(), txs, nil, nil)
+	header := block.Header()
+	header.Number++
+	header.ParentHash = parent.Hash()
+	header.MetaHash = block.Meta().Hash()
+	return block.WithSeal(header)
+}
+
+func TestCompactBlock(t *testing.T) {
+	block := newTestBlockWithTxs(generateMinorBlocks(1)[0], 0, 5)
+	txs := block.Transactions()
+	pool := map[uint64]*types.Transaction{}
+	for _, tx := range txs[:4] {
+		pool[shortTxID(tx.Hash())] = tx
+	}
+	lookup := func(ids map[uint64]int) map[uint64]*types.Transaction { return pool }
+
+	// the transactions the peer doesn't know are prefilled
+	cb := newCompactBlock(block, func(hash common.Hash) bool { return hash != txs[1].Hash() && hash != txs[4].Hash() })
+	assert.Len(t, cb.ShortIDs, 3)
+	assert.Len(t, cb.PrefilledTxs, 2)
+	assert.Equal(t, uint32(1), cb.PrefilledTxs[0].Index)
+	assert.Equal(t, uint32(4), cb.PrefilledTxs[1].Index)
+	assert.NoError(t, validateCompactBlock(2, cb))
+	assert.Error(t, validateCompactBlock(3, cb))
+
+	// the block is rebuilt through the serialization
+	data, err := serialize.SerializeToBytes(cb)
+	assert.NoError(t, err)
+	var received p2p.NewCompactBlockMinor
+	assert.NoError(t, serialize.DeserializeFromBytes(data, &received))
+	rebuilt := rebuildCompactBlock(&received, lookup)
+	if assert.NotNil(t, rebuilt) {
+		assert.Equal(t, block.Hash(), rebuilt.Hash())
+		assert.Equal(t, block.TxHash(), types.CalculateMerkleRoot(rebuilt.Transactions()))
+		assert.Equal(t, txs[3].Hash(), rebuilt.Transactions()[3].Hash())
+	}
+
+	// a missing transaction fails the rebuild
+	cb = newCompactBlock(block, func(hash common.Hash) bool { return true })
+	assert.Nil(t, rebuildCompactBlock(cb, lookup))
+
+	// and so does a short ID matching another transaction
+	pool[shortTxID(txs[3].Hash())] = txs[2]
+	pool[shortTxID(txs[4].Hash())] = txs[4]
+	assert.Nil(t, rebuildCompactBlock(cb, lookup))
+	pool[shortTxID(txs[3].Hash())] = txs[3]
+	assert.NotNil(t, rebuildCompactBlock(cb, lookup))
+
+	// an empty block is rebuilt only with the root of no transactions
+	cb = newCompactBlock(block, func(hash common.Hash) bool { return true })
+	cb.ShortIDs = nil
+	assert.Nil(t, rebuildCompactBlock(cb, lookup))
+	cb.Meta.TxHash = types.CalculateMerkleRoot(types.Transactions{})
+	if rebuilt := rebuildCompactBlock(cb, lookup); assert.NotNil(t, rebuilt) {
+		assert.Empty(t, rebuilt.Transactions())
+	}
+
+	// the prefilled transactions must be in order and within the block
+	cb = newCompactBlock(block, func(hash common.Hash) bool { return false })
+	cb.PrefilledTxs[1], cb.PrefilledTxs[2] = cb.PrefilledTxs[2], cb.PrefilledTxs[1]
+	assert.Error(t, validateCompactBlock(2, cb))
+	cb = newCompactBlock(block, func(hash common.Hash) bool { return false })
+	cb.PrefilledTxs[4].Index = 5
+	assert.Error(t, validateCompactBlock(2, cb))
+}
+
+func TestCompactBlockPropagation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	fakeConnMngr := newFakeConnManager(1, ctrl)
+	pm, _ := newTestProtocolManagerMust(t, 15, nil, NewFakeSynchronizer(1), fakeConnMngr)
+	parent := generateMinorBlocks(1)[0]
+	block := newTestBlockWithTxs(parent, 0, 3)
+	txs := block.Transactions()
+
+	peer, err := newTestPeer("peer", qkc3, pm, true)
+	assert.NoError(t, err)
+	defer peer.close()
+	legacy, err := newTestPeer("legacy", qkc2, pm, true)
+	assert.NoError(t, err)
+	defer legacy.close()
+	clientPeer := newTestClientPeer(qkc3, peer.app)
+	waitPeers(t, pm, 2)
+
+	added := make(chan *types.MinorBlock, 1)
+	expectBlock := func() {
+		for _, conn := range fakeConnMngr.GetSlaveConns() {
+			conn.(*mock_master.MockISlaveConn).EXPECT().
+				HandleNewMinorBlock(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, req *rpc.P2PRedirectRequest) error {
+				var newBlock p2p.NewBlockMinor
+				assert.NoError(t, serialize.DeserializeFromBytes(req.Data, &newBlock))
+				added <- newBlock.Block
+				return nil
+			}).Times(1)
+		}
+	}
+	waitBlock := func(want *types.MinorBlock) {
+		select {
+		case got := <-added:
+			assert.Equal(t, want.Hash(), got.Hash())
+			assert.Equal(t, len(want.Transactions()), len(got.Transactions()))
+		case <-time.After(2 * time.Second):
+			t.Fatal("block not added")
+		}
+	}
+
+	// a block is rebuilt with the transactions in the pool and the prefilled ones
+	for _, tx := range txs[:2] {
+		pm.txCache.Add(tx.Hash(), tx)
+	}
+	clientPeer.MarkTransactions([]common.Hash{txs[0].Hash(), txs[1].Hash()})
+	expectBlock()
+	assert.NoError(t, clientPeer.SendNewCompactMinorBlock(2, block))
+	waitBlock(block)
+	assert.True(t, peer.KnowsTransaction(txs[2].Hash()))
+	// and not again when sent by another peer
+	assert.NoError(t, clientPeer.SendNewCompactMinorBlock(2, block))
+
+	// a block with a transaction missing from the pool is fetched in full
+	next := newTestBlockWithTxs(block, 3, 2)
+	clientPeer.MarkTransactions([]common.Hash{next.Transactions()[0].Hash(), next.Transactions()[1].Hash()})
+	expectBlock()
+	assert.NoError(t, clientPeer.SendNewCompactMinorBlock(2, next))
+	req := &p2p.GetMinorBlockListRequest{MinorBlockHashList: []common.Hash{next.Hash()}}
+	qkcMsg, err := ExpectMsg(peer.app, p2p.GetMinorBlockListRequestMsg, p2p.Metadata{Branch: 2}, req)
+	assert.NoError(t, err)
+	resp := &p2p.GetMinorBlockListResponse{MinorBlockList: []*types.MinorBlock{next}}
+	assert.NoError(t, clientPeer.SendResponse(p2p.GetMinorBlockListResponseMsg, p2p.Metadata{Branch: 2}, qkcMsg.RpcID, resp))
+	waitBlock(next)
+
+	// the blocks are sent as compact blocks to the peers taking them
+	data, err := serialize.SerializeToBytes(&p2p.NewBlockMinor{Block: block})
+	assert.NoError(t, err)
+	assert.NoError(t, NewPrivateP2PAPI(pm.peers).BroadcastMinorBlock(&rpc.P2PRedirectRequest{Branch: 2, Data: data}))
+	op, err := readOp(peer)
+	assert.NoError(t, err)
+	assert.Equal(t, p2p.NewCompactBlockMinorMsg, op)
+	op, err = readOp(legacy)
+	assert.NoError(t, err)
+	assert.Equal(t, p2p.NewBlockMinorMsg, op)
+}
@@ -0,0 +1,74 @@
+package master
+
+import (
+	"fmt"
+
+	"github.com/QuarkChain/goquarkchain/cluster/config"
+	qkcom "github.com/QuarkChain/goquarkchain/common"
+	"github.com/ethereum/go-ethereum/p2p/discv5"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// shardsEntry is the ENR entry advertising the chain masks of the slaves of a
+// cluster, i.e. the shards it serves sync requests for.
+type shardsEntry struct {
+	ChainMasks []uint32
+	// Ignore additional fields (for forward compatibility).
+	Rest []rlp.RawValue `rlp:"tail"`
+}
+
+func (e shardsEntry) ENRKey() string { return "qkc" }
+
+func newShardsEntry(cfg *config.ClusterConfig) shardsEntry {
+	var entry shardsEntry
+	for _, slave := range cfg.SlaveList {
+		for _, mask := range slave.ChainMaskList {
+			entry.ChainMasks = append(entry.ChainMasks, mask.GetMask())
+		}
+	}
+	return entry
+}
+
+// servesShards reports whether the node serves a shard the cluster runs, so
+// that it can answer its sync requests. A node which does not advertise its
+// shards is assumed to serve all of them.
+func (e shardsEntry) servesShards(n *enode.Node) bool {
+	var remote shardsEntry
+	if err := n.Load(&remote); err != nil {
+		return enr.IsNotFound(err)
+	}
+	for _, mask := range remote.ChainMasks {
+		for _, local := range e.ChainMasks {
+			if mask != 0 && local != 0 && qkcom.MasksHaveOverlap(mask, local) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// shardTopics returns the discovery V5 topics of the chains the slaves of a
+// cluster serve, the clusters sharing a chain finding each other under its
+// topic.
+func shardTopics(cfg *config.ClusterConfig) []discv5.Topic {
+	var topics []discv5.Topic
+	for chainID := uint32(0); chainID < cfg.Quarkchain.ChainSize; chainID++ {
+		if servesChain(cfg, chainID) {
+			topics = append(topics, discv5.Topic(fmt.Sprintf("qkc-%d-chain-%d", cfg.Quarkchain.NetworkID, chainID)))
+		}
+	}
+	return topics
+}
+
+func servesChain(cfg *config.ClusterConfig, chainID uint32) bool {
+	for _, slave := range cfg.SlaveList {
+		for _, mask := range slave.ChainMaskList {
+			if mask.ContainFullShardId(chainID << 16) {
+				return true
+			}
+		}
+	}
+	return false
+}
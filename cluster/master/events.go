@@ -0,0 +1,265 @@
+package master
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/cluster/config"
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/core"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/internal/encoder"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	// shardTipQueue is the number of the new shard tips waiting for the event
+	// loop, beyond which they are dropped.
+	shardTipQueue = 256
+	// maxEventReorgDepth bounds the blocks walked down from the last tip and
+	// the new tip of a shard to find their common ancestor.
+	maxEventReorgDepth = 64
+)
+
+// shardTip is a minor block header the master got with the status of its
+// shard.
+type shardTip struct {
+	header  *types.MinorBlockHeader
+	txCount uint32
+	height  uint64 // of the shard once the block was added
+	synced  bool   // added by a sync, whose tx count is read from the block
+}
+
+// publishShardTip queues the header of a block added by a shard for the event
+// sinks, without blocking.
+func (s *QKCMasterBackend) publishShardTip(header *types.MinorBlockHeader, txCount uint32, status *rpc.ShardStatus) {
+	if s.eventSink == nil || status == nil {
+		return
+	}
+	s.queueShardTip(&shardTip{header: header, txCount: txCount, height: status.Height})
+}
+
+// publishSyncedShardTips queues the headers of the blocks added in order by a
+// shard sync for the event sinks, without blocking.
+func (s *QKCMasterBackend) publishSyncedShardTips(headers []*types.MinorBlockHeader) {
+	if s.eventSink == nil {
+		return
+	}
+	for _, header := range headers {
+		s.queueShardTip(&shardTip{header: header, height: header.Number, synced: true})
+	}
+}
+
+func (s *QKCMasterBackend) queueShardTip(tip *shardTip) {
+	select {
+	case s.shardTipCh <- tip:
+	default:
+		log.Warn("Chain events are behind, dropping shard tip", "branch", tip.header.Branch.Value, "number", tip.header.Number)
+	}
+}
+
+// eventLoop pushes the new root blocks, the root chain reorgs and the new
+// shard tips, with the shard reorgs and the txs of the addresses watched they
+// bring, to the event sinks until the master stops.
+func (s *QKCMasterBackend) eventLoop() {
+	chainCh := make(chan core.RootChainEvent, 16)
+	chainSub := s.rootBlockChain.SubscribeChainEvent(chainCh)
+	defer chainSub.Unsubscribe()
+	reorgCh := make(chan core.RootChainReorgEvent, 16)
+	reorgSub := s.rootBlockChain.SubscribeReorgEvent(reorgCh)
+	defer reorgSub.Unsubscribe()
+
+	tips := make(map[uint32]*types.MinorBlockHeader)
+	for {
+		select {
+		case ev := <-chainCh:
+			fields, err := encoder.RootBlockEncoder(ev.Block, nil)
+			if err != nil {
+				log.Error("Failed to encode root block event", "number", ev.Block.NumberU64(), "err", err)
+				continue
+			}
+			s.eventSink.Publish(config.EventRootBlock, fields)
+		case ev := <-reorgCh:
+			s.eventSink.Publish(config.EventReorg, rootReorgFields(ev))
+		case tip := <-s.shardTipCh:
+			last := tips[tip.header.Branch.Value]
+			// the headers of the side blocks, and of the same block from each
+			// slave running the shard, are not new tips
+			if tip.header.Number != tip.height || (last != nil && tip.header.Hash() == last.Hash()) {
+				continue
+			}
+			if s.publishShardTipEvents(last, tip) {
+				tips[tip.header.Branch.Value] = tip.header
+			}
+		case <-chainSub.Err():
+			return
+		case <-s.exitCh:
+			return
+		}
+	}
+}
+
+func rootReorgFields(ev core.RootChainReorgEvent) map[string]interface{} {
+	hashes := func(blocks []*types.RootBlock) []common.Hash {
+		list := make([]common.Hash, 0, len(blocks))
+		for _, block := range blocks {
+			list = append(list, block.Hash())
+		}
+		return list
+	}
+	fields := map[string]interface{}{
+		"chain":              "root",
+		"depth":              hexutil.Uint64(ev.Depth),
+		"oldChain":           hashes(ev.OldChain),
+		"newChain":           hashes(ev.NewChain),
+		"droppedMinorBlocks": hexutil.Uint64(len(ev.DroppedHeaders)),
+	}
+	if ev.CommonBlock != nil {
+		fields["commonBlockHash"], fields["commonBlockHeight"] = ev.CommonBlock.Hash(), hexutil.Uint64(ev.CommonBlock.NumberU64())
+	}
+	return fields
+}
+
+// publishShardTipEvents pushes the new tip of the shard, after the reorg of
+// the shard if the tip is not a child of the last one, and the txs of the
+// addresses watched in the blocks added since the last tip. It returns false,
+// pushing nothing, if the tip is an ancestor of the last one, e.g. sent late
+// by a slave behind the others.
+func (s *QKCMasterBackend) publishShardTipEvents(last *types.MinorBlockHeader, tip *shardTip) bool {
+	var added []*types.MinorBlock
+	switch {
+	case last != nil && tip.header.ParentHash != last.Hash():
+		newChain, oldChain, commonBlock, err := s.shardFork(last, tip.header)
+		if err != nil {
+			log.Warn("Failed to find shard blocks added since last tip", "branch", tip.header.Branch.Value,
+				"number", tip.header.Number, "err", err)
+			break
+		}
+		if len(newChain) == 0 {
+			return false
+		}
+		added = newChain
+		if len(oldChain) == 0 {
+			break
+		}
+		fields, err := encoder.MinorChainReorgEncoder(commonBlock, oldChain, newChain, nil)
+		if err != nil {
+			log.Error("Failed to encode shard reorg event", "branch", tip.header.Branch.Value, "err", err)
+			break
+		}
+		fields["chain"], fields["fullShardId"] = "shard", hexutil.Uint64(tip.header.Branch.Value)
+		s.eventSink.Publish(config.EventReorg, fields)
+	case len(s.eventWatch) > 0 || tip.synced:
+		block, err := s.getEventBlock(tip.header.Hash(), tip.header.Branch)
+		if err != nil {
+			log.Warn("Failed to get shard tip for events", "branch", tip.header.Branch.Value,
+				"number", tip.header.Number, "err", err)
+			break
+		}
+		added = []*types.MinorBlock{block}
+	}
+	if tip.synced && len(added) > 0 {
+		tip.txCount = uint32(added[0].Transactions().Len())
+	}
+
+	fields, err := encoder.MinorBlockHeaderEncoder(tip.header)
+	if err != nil {
+		log.Error("Failed to encode shard tip event", "branch", tip.header.Branch.Value, "err", err)
+		return true
+	}
+	fields["txCount"] = hexutil.Uint64(tip.txCount)
+	s.eventSink.Publish(config.EventShardTip, fields)
+
+	if len(s.eventWatch) == 0 {
+		return true
+	}
+	for i := len(added) - 1; i >= 0; i-- {
+		s.publishAddressEvents(added[i])
+	}
+	return true
+}
+
+// shardFork returns the blocks of the new tip and of the last tip down to
+// their common ancestor, excluded, ordered from the tips down, and the common
+// ancestor.
+func (s *QKCMasterBackend) shardFork(last, tip *types.MinorBlockHeader) ([]*types.MinorBlock, []*types.MinorBlock, *types.MinorBlock, error) {
+	newBlock, err := s.getEventBlock(tip.Hash(), tip.Branch)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	oldBlock, err := s.getEventBlock(last.Hash(), last.Branch)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var newChain, oldChain []*types.MinorBlock
+	for newBlock.Hash() != oldBlock.Hash() {
+		if len(newChain) >= maxEventReorgDepth || len(oldChain) >= maxEventReorgDepth {
+			return nil, nil, nil, fmt.Errorf("no common ancestor within %d blocks", maxEventReorgDepth)
+		}
+		if newBlock.NumberU64() == 0 && oldBlock.NumberU64() == 0 {
+			return nil, nil, nil, fmt.Errorf("no common ancestor")
+		}
+		if newBlock.NumberU64() >= oldBlock.NumberU64() {
+			newChain = append(newChain, newBlock)
+			if newBlock, err = s.getEventBlock(newBlock.ParentHash(), tip.Branch); err != nil {
+				return nil, nil, nil, err
+			}
+		} else {
+			oldChain = append(oldChain, oldBlock)
+			if oldBlock, err = s.getEventBlock(oldBlock.ParentHash(), last.Branch); err != nil {
+				return nil, nil, nil, err
+			}
+		}
+	}
+	return newChain, oldChain, newBlock, nil
+}
+
+func (s *QKCMasterBackend) getEventBlock(hash common.Hash, branch account.Branch) (*types.MinorBlock, error) {
+	block, _, err := s.GetMinorBlockByHash(context.Background(), hash, branch, false)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, fmt.Errorf("minor block %x not found", hash)
+	}
+	return block, nil
+}
+
+// publishAddressEvents pushes the txs of the block sent from or to the
+// addresses watched, once for each address.
+func (s *QKCMasterBackend) publishAddressEvents(block *types.MinorBlock) {
+	for i, tx := range block.Transactions() {
+		if tx.TxType != types.EvmTx {
+			continue
+		}
+		evmTx := tx.EvmTx
+		sender, err := types.Sender(types.MakeSigner(evmTx.NetworkId()), evmTx)
+		if err != nil {
+			continue
+		}
+		var directions []string
+		if s.eventWatch[sender] {
+			directions = append(directions, "out")
+		}
+		if to := evmTx.To(); to != nil && s.eventWatch[*to] {
+			directions = append(directions, "in")
+		}
+		for _, direction := range directions {
+			fields, err := encoder.TxEncoder(block, i)
+			if err != nil {
+				log.Error("Failed to encode address event", "tx", tx.Hash().Hex(), "err", err)
+				break
+			}
+			fields["direction"] = direction
+			if direction == "out" {
+				fields["address"] = encoder.DataEncoder(sender.Bytes())
+			} else {
+				fields["address"] = encoder.DataEncoder(evmTx.To().Bytes())
+			}
+			s.eventSink.Publish(config.EventAddress, fields)
+		}
+	}
+}
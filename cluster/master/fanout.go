@@ -0,0 +1,210 @@
+package master
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// fanOutMode decides when a call fanned out to the slaves succeeds.
+type fanOutMode int
+
+const (
+	// fanOutAll needs all the slaves to succeed, the calls left are canceled
+	// on the first failure.
+	fanOutAll fanOutMode = iota
+	// fanOutFirst needs one slave to succeed, the calls left are canceled on
+	// the first success.
+	fanOutFirst
+	// fanOutQuorum needs more than half of the slaves to succeed, the calls
+	// left are canceled once it's decided.
+	fanOutQuorum
+	// fanOutBroadcast needs all the slaves to succeed like fanOutAll, but
+	// waits for all the calls to return, e.g. for a root block not to be
+	// overtaken by the next one on the slaves still adding it.
+	fanOutBroadcast
+)
+
+var errNoSlaveToCall = errors.New("no slave to call")
+
+// SlaveError is the error of a slave in a call fanned out to the slaves.
+type SlaveError struct {
+	SlaveID string
+	Err     error
+}
+
+func (e *SlaveError) Error() string {
+	return fmt.Sprintf("slave %s: %v", e.SlaveID, e.Err)
+}
+
+func (e *SlaveError) Unwrap() error {
+	return e.Err
+}
+
+// SlaveErrors aggregates the errors of the slaves failed in a call fanned out
+// to the slaves, it unwraps to the first one.
+type SlaveErrors []*SlaveError
+
+func (e SlaveErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e SlaveErrors) Unwrap() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e[0]
+}
+
+// slaveResult is the result of the call to a slave, err is context.Canceled
+// if the call was canceled before it returned.
+type slaveResult struct {
+	conn   rpc.ISlaveConn
+	result interface{}
+	err    error
+}
+
+type indexedResult struct {
+	index int
+	*slaveResult
+}
+
+// fanOut calls call with each of the slaves concurrently, at most limit at once
+// if limit is positive, and returns their results in the order of conns once
+// the mode is met or can't be anymore, or once all the calls returned with
+// fanOutBroadcast. The calls still running are canceled
+// through the context passed to them. If the mode is not met, the error
+// returned is the one of ctx if it's done, or else the SlaveErrors of the
+// slaves failed.
+func fanOut(ctx context.Context, conns []rpc.ISlaveConn, mode fanOutMode, limit int,
+	call func(ctx context.Context, conn rpc.ISlaveConn) (interface{}, error)) ([]*slaveResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	need := len(conns)
+	switch mode {
+	case fanOutFirst:
+		need = 1
+	case fanOutQuorum:
+		need = len(conns)/2 + 1
+	}
+	if need > len(conns) {
+		return nil, errNoSlaveToCall
+	}
+
+	callCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var sem chan struct{}
+	if limit > 0 {
+		sem = make(chan struct{}, limit)
+	}
+	// buffered for the calls still running after the return not to block
+	done := make(chan indexedResult, len(conns))
+	for i, conn := range conns {
+		go func(i int, conn rpc.ISlaveConn) {
+			if sem != nil {
+				select {
+				case sem <- struct{}{}:
+					defer func() { <-sem }()
+				case <-callCtx.Done():
+					done <- indexedResult{i, &slaveResult{conn: conn, err: callCtx.Err()}}
+					return
+				}
+			}
+			result, err := call(callCtx, conn)
+			done <- indexedResult{i, &slaveResult{conn: conn, result: result, err: err}}
+		}(i, conn)
+	}
+
+	results := make([]*slaveResult, len(conns))
+	succeeded, failed := 0, 0
+	for succeeded+failed < len(conns) {
+		if mode != fanOutBroadcast && (succeeded >= need || failed > len(conns)-need) {
+			break
+		}
+		r := <-done
+		results[r.index] = r.slaveResult
+		if r.err == nil {
+			succeeded++
+		} else {
+			failed++
+		}
+	}
+	for i, conn := range conns {
+		if results[i] == nil {
+			results[i] = &slaveResult{conn: conn, err: context.Canceled}
+		}
+	}
+	if succeeded >= need {
+		return results, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return results, err
+	}
+	errs := make(SlaveErrors, 0, failed)
+	for _, r := range results {
+		if r.err != nil && r.err != context.Canceled {
+			errs = append(errs, &SlaveError{SlaveID: r.conn.GetSlaveID(), Err: r.err})
+		}
+	}
+	if len(errs) == 0 {
+		return results, context.Canceled
+	}
+	return results, errs
+}
+
+// fanOutAllSlaves calls call with each of the slaves concurrently, waits for
+// all of them and returns the SlaveErrors of the slaves failed, if any.
+func fanOutAllSlaves(ctx context.Context, conns []rpc.ISlaveConn, call func(ctx context.Context, conn rpc.ISlaveConn) error) error {
+	_, err := fanOut(ctx, conns, fanOutBroadcast, 0, func(ctx context.Context, conn rpc.ISlaveConn) (interface{}, error) {
+		return nil, call(ctx, conn)
+	})
+	return err
+}
+
+// supporting returns the slaves of conns serving the op, skipping the ones of
+// an older version which don't during a rolling upgrade. It's meant for the
+// ops the cluster can do without, e.g. the diagnostics.
+func supporting(conns []rpc.ISlaveConn, op uint32) []rpc.ISlaveConn {
+	served := make([]rpc.ISlaveConn, 0, len(conns))
+	for _, conn := range conns {
+		if conn.SupportsOp(op) {
+			served = append(served, conn)
+		} else {
+			log.Debug("Skip the slave not supporting the op", "slave", conn.GetSlaveID(), "op", rpc.OpName(op))
+		}
+	}
+	return served
+}
+
+// servingConn returns the first slave of conns serving the op, or the first
+// one if none does, whose call then fails with an UnsupportedOpError, nil if
+// conns is empty.
+func servingConn(conns []rpc.ISlaveConn, op uint32) rpc.ISlaveConn {
+	if served := supporting(conns, op); len(served) > 0 {
+		return served[0]
+	}
+	if len(conns) > 0 {
+		return conns[0]
+	}
+	return nil
+}
+
+// allSupporting returns an UnsupportedOpError if one of the slaves of conns
+// doesn't serve the op, for the ops which can't run on some of them only.
+func allSupporting(conns []rpc.ISlaveConn, op uint32) error {
+	for _, conn := range conns {
+		if !conn.SupportsOp(op) {
+			return &rpc.UnsupportedOpError{Op: rpc.OpName(op), Target: conn.GetSlaveID()}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package master
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/mocks/mock_master"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFanOut(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	conns := newFakeConnManager(3, ctrl).GetSlaveConns()
+	errSlave := errors.New("slave failed")
+	// the slave S0 fails, S1 succeeds at once and S2 waits to be canceled
+	call := func(ctx context.Context, conn rpc.ISlaveConn) (interface{}, error) {
+		switch conn.GetSlaveID() {
+		case "S0":
+			return nil, errSlave
+		case "S1":
+			return conn.GetSlaveID(), nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(5 * time.Second):
+			return conn.GetSlaveID(), nil
+		}
+	}
+
+	results, err := fanOut(context.Background(), conns, fanOutFirst, 0, call)
+	assert.NoError(t, err)
+	assert.Equal(t, "S1", results[1].result)
+
+	_, err = fanOut(context.Background(), conns, fanOutAll, 0, call)
+	assert.EqualError(t, err, "slave S0: slave failed")
+	assert.True(t, errors.Is(err, errSlave))
+	assert.Equal(t, "S0", err.(SlaveErrors)[0].SlaveID)
+
+	// S2 is canceled once S0 and S1 failed
+	start := time.Now()
+	_, err = fanOut(context.Background(), conns[:1], fanOutQuorum, 0, call)
+	assert.Error(t, err)
+	results, err = fanOut(context.Background(), []rpc.ISlaveConn{conns[0], conns[0], conns[2]}, fanOutQuorum, 0, call)
+	assert.EqualError(t, err, "slave S0: slave failed; slave S0: slave failed")
+	assert.Equal(t, context.Canceled, results[2].err)
+	assert.True(t, time.Since(start) < time.Second)
+
+	_, err = fanOut(context.Background(), nil, fanOutFirst, 0, call)
+	assert.Equal(t, errNoSlaveToCall, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = fanOut(ctx, conns, fanOutAll, 0, call)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestFanOutBroadcast(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	conns := newFakeConnManager(3, ctrl).GetSlaveConns()
+	errSlave := errors.New("slave failed")
+	// S0 fails at once while S1 and S2 are still adding, and not canceled
+	var returned int32
+	err := fanOutAllSlaves(context.Background(), conns, func(ctx context.Context, conn rpc.ISlaveConn) error {
+		if conn.GetSlaveID() == "S0" {
+			return errSlave
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+		}
+		atomic.AddInt32(&returned, 1)
+		if conn.GetSlaveID() == "S2" {
+			return errSlave
+		}
+		return nil
+	})
+	assert.Equal(t, int32(2), atomic.LoadInt32(&returned))
+	assert.EqualError(t, err, "slave S0: slave failed; slave S2: slave failed")
+}
+
+func TestFanOutLimit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	conns := newFakeConnManager(8, ctrl).GetSlaveConns()
+	var running, maxRunning int32
+	results, err := fanOut(context.Background(), conns, fanOutAll, 2, func(ctx context.Context, conn rpc.ISlaveConn) (interface{}, error) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return conn.GetSlaveID(), nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&maxRunning))
+	for i, res := range results {
+		assert.Equal(t, conns[i].GetSlaveID(), res.result)
+	}
+}
+
+func TestSupporting(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	conns := newFakeConnManager(3, ctrl).GetSlaveConns()
+	// S1 runs an older version without the op
+	for i, conn := range conns {
+		conn.(*mock_master.MockISlaveConn).EXPECT().SupportsOp(uint32(rpc.OpGetSlowOps)).Return(i != 1)
+	}
+	served := supporting(conns, rpc.OpGetSlowOps)
+	assert.Equal(t, []rpc.ISlaveConn{conns[0], conns[2]}, served)
+}
+
+func TestServingConn(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	conns := newFakeConnManager(3, ctrl).GetSlaveConns()
+	// S0 runs an older version without the op
+	for i, conn := range conns {
+		conn.(*mock_master.MockISlaveConn).EXPECT().SupportsOp(uint32(rpc.OpTraceBlock)).Return(i != 0).AnyTimes()
+	}
+	assert.Equal(t, conns[1], servingConn(conns, rpc.OpTraceBlock))
+	assert.True(t, rpc.IsUnsupportedOp(allSupporting(conns, rpc.OpTraceBlock)))
+	assert.NoError(t, allSupporting(conns[1:], rpc.OpTraceBlock))
+
+	// the call fails on S0 if no slave serves the op
+	assert.Equal(t, conns[0], servingConn(conns[:1], rpc.OpTraceBlock))
+	assert.Nil(t, servingConn(nil, rpc.OpTraceBlock))
+}
@@ -1,9 +1,11 @@
 package master
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"reflect"
+	"strings"
 	"sync"
 	"time"
 
@@ -14,25 +16,39 @@ import (
 	"github.com/QuarkChain/goquarkchain/core"
 	"github.com/QuarkChain/goquarkchain/core/types"
 	"github.com/QuarkChain/goquarkchain/p2p"
+	"github.com/QuarkChain/goquarkchain/params"
 	"github.com/QuarkChain/goquarkchain/serialize"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	lru "github.com/hashicorp/golang-lru"
 	"github.com/pkg/errors"
-	"golang.org/x/sync/errgroup"
 )
 
 // QKCProtocol details
 const (
 	QKCProtocolName     = "quarkchain"
-	QKCProtocolVersion  = 1
+	QKCProtocolVersion  = qkc3
 	QKCProtocolLength   = 16
 	chainHeadChanSize   = 10
 	forceSyncCycle      = 1000 * time.Second
 	minDesiredPeerCount = 0
 )
 
+// The versions of the quarkchain protocol, of which the highest one supported
+// by both sides is run with a peer.
+const (
+	qkc1 = 1
+	qkc2 = 2 // transactions are announced by hash to most peers
+	qkc3 = 3 // minor blocks are sent as compact blocks
+)
+
+// QKCProtocolVersions are the supported versions of the quarkchain protocol.
+var QKCProtocolVersions = []uint{qkc3, qkc2, qkc1}
+
 // ProtocolManager QKC manager
 type ProtocolManager struct {
 	networkID      uint32
@@ -50,7 +66,10 @@ type ProtocolManager struct {
 	// TODO can be removed ?
 	stats       *qkcsync.BlockSychronizerStats
 	maxPeers    int
-	peers       *peerSet // Set of active peers from which rootDownloader can proceed
+	peers       *peerSet   // Set of active peers from which rootDownloader can proceed
+	txCache     *lru.Cache // Transactions broadcast lately, by hash
+	txFetcher   *txFetcher
+	newBlocks   *lru.Cache // Compact blocks received lately, by hash
 	newPeerCh   chan *Peer
 	quitSync    chan struct{}
 	noMorePeers chan struct{}
@@ -66,6 +85,7 @@ func NewProtocolManager(env config.ClusterConfig, rootBlockChain *core.RootBlock
 		rootBlockChain: rootBlockChain,
 		clusterConfig:  &env,
 		peers:          newPeerSet(),
+		txFetcher:      newTxFetcher(),
 		newPeerCh:      make(chan *Peer),
 		quitSync:       make(chan struct{}),
 		noMorePeers:    make(chan struct{}),
@@ -75,23 +95,40 @@ func NewProtocolManager(env config.ClusterConfig, rootBlockChain *core.RootBlock
 		stats:          &qkcsync.BlockSychronizerStats{},
 		started:        false,
 	}
-	protocol := p2p.Protocol{
-		Name:    QKCProtocolName,
-		Version: QKCProtocolVersion,
-		Length:  QKCProtocolLength,
-		Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
-			peer := newPeer(int(QKCProtocolVersion), p, rw)
-			select {
-			case manager.newPeerCh <- peer:
-				manager.wg.Add(1)
-				defer manager.wg.Done()
-				return manager.handle(peer)
-			case <-manager.quitSync:
-				return p2p.DiscQuitting
-			}
-		},
+	manager.txCache, _ = lru.New(maxTxCache)
+	manager.newBlocks, _ = lru.New(maxCompactBlocks)
+	shards, topics := newShardsEntry(&env), shardTopics(&env)
+	manager.subProtocols = make([]p2p.Protocol, 0, len(QKCProtocolVersions))
+	for _, version := range QKCProtocolVersions {
+		version := version
+		manager.subProtocols = append(manager.subProtocols, p2p.Protocol{
+			Name:       QKCProtocolName,
+			Version:    version,
+			Length:     QKCProtocolLength,
+			Attributes: []enr.Entry{shards},
+			DialFilter: shards.servesShards,
+			Topics:     topics,
+			PeerInfo: func(id enode.ID) interface{} {
+				if p := manager.peers.Peer(fmt.Sprintf("%x", id.Bytes()[:8])); p != nil {
+					if info := p.Info(); info != nil {
+						return info
+					}
+				}
+				return nil
+			},
+			Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+				peer := newPeer(int(version), p, rw, newServeQuota(manager.clusterConfig.P2P))
+				select {
+				case manager.newPeerCh <- peer:
+					manager.wg.Add(1)
+					defer manager.wg.Done()
+					return manager.handle(peer)
+				case <-manager.quitSync:
+					return p2p.DiscQuitting
+				}
+			},
+		})
 	}
-	manager.subProtocols = []p2p.Protocol{protocol}
 	return manager, nil
 }
 
@@ -231,32 +268,87 @@ func (pm *ProtocolManager) handleMsg(peer *Peer) error {
 
 	case qkcMsg.Op == p2p.NewTransactionListMsg:
 		go func() {
-			err = pm.HandleNewTransactionListRequest(peer.id, qkcMsg.RpcID, qkcMsg.MetaData.Branch, qkcMsg.Data)
+			err = pm.handleTransactions(peer, qkcMsg.MetaData.Branch, qkcMsg.Data)
+			if err != nil {
+				peer.handleMsgErr = err
+			}
+		}()
+
+	case qkcMsg.Op == p2p.NewTransactionHashesMsg:
+		var ann p2p.NewTransactionHashes
+		if err := serialize.DeserializeFromBytes(qkcMsg.Data, &ann); err != nil {
+			return err
+		}
+		if len(ann.TransactionHashList) > params.NEW_TRANSACTION_LIST_LIMIT {
+			return fmt.Errorf("too many transaction hashes announced: %d", len(ann.TransactionHashList))
+		}
+		go func() {
+			err := pm.handleTransactionHashes(peer, qkcMsg.MetaData.Branch, ann.TransactionHashList)
 			if err != nil {
 				peer.handleMsgErr = err
 			}
 		}()
 
+	case qkcMsg.Op == p2p.GetTransactionListRequestMsg:
+		var txsReq p2p.GetTransactionListRequest
+		if err := serialize.DeserializeFromBytes(qkcMsg.Data, &txsReq); err != nil {
+			return err
+		}
+		if len(txsReq.TransactionHashList) > params.NEW_TRANSACTION_LIST_LIMIT {
+			return fmt.Errorf("too many transactions requested: %d", len(txsReq.TransactionHashList))
+		}
+		resp := pm.HandleGetTransactionListRequest(&txsReq)
+		return peer.SendResponse(p2p.GetTransactionListResponseMsg, p2p.Metadata{Branch: qkcMsg.MetaData.Branch}, qkcMsg.RpcID, resp)
+
+	case qkcMsg.Op == p2p.GetTransactionListResponseMsg:
+		var txsResp p2p.GetTransactionListResponse
+		if err := serialize.DeserializeFromBytes(qkcMsg.Data, &txsResp); err != nil {
+			return err
+		}
+		if c := peer.getChan(qkcMsg.RpcID); c != nil {
+			c <- txsResp.TransactionList
+		} else {
+			log.Warn(fmt.Sprintf("chan for rpc %d is missing", qkcMsg.RpcID))
+		}
+
 	case qkcMsg.Op == p2p.NewBlockMinorMsg:
 		go func() {
 			err = pm.HandleNewMinorBlock(peer.id, qkcMsg.MetaData.Branch, qkcMsg.Data)
-			if err != nil {
+			// the block is not the peer's fault if the shard is paused, it's
+			// synced once resumed
+			if err != nil && !strings.Contains(err.Error(), rpc.ErrShardPaused.Error()) {
 				peer.handleMsgErr = err
 			}
 		}()
 
-	case qkcMsg.Op == p2p.GetRootBlockHeaderListRequestMsg:
-		var blockHeaderReq p2p.GetRootBlockHeaderListRequest
-		if err := serialize.DeserializeFromBytes(qkcMsg.Data, &blockHeaderReq); err != nil {
+	case qkcMsg.Op == p2p.NewCompactBlockMinorMsg:
+		var cb p2p.NewCompactBlockMinor
+		if err := serialize.DeserializeFromBytes(qkcMsg.Data, &cb); err != nil {
 			return err
 		}
+		if err := validateCompactBlock(qkcMsg.MetaData.Branch, &cb); err != nil {
+			return err
+		}
+		go func() {
+			err := pm.handleCompactBlock(peer, qkcMsg.MetaData.Branch, &cb)
+			if err != nil && !strings.Contains(err.Error(), rpc.ErrShardPaused.Error()) {
+				peer.handleMsgErr = err
+			}
+		}()
 
-		resp, err := pm.HandleGetRootBlockHeaderListRequest(&blockHeaderReq)
-		if err != nil {
+	case qkcMsg.Op == p2p.GetRootBlockHeaderListRequestMsg:
+		var blockHeaderReq p2p.GetRootBlockHeaderListRequest
+		if err := serialize.DeserializeFromBytes(qkcMsg.Data, &blockHeaderReq); err != nil {
 			return err
 		}
 
-		return peer.SendResponse(p2p.GetRootBlockHeaderListResponseMsg, p2p.Metadata{Branch: 0}, qkcMsg.RpcID, resp)
+		return peer.serve(false, func() (p2p.Msg, error) {
+			resp, err := pm.HandleGetRootBlockHeaderListRequest(&blockHeaderReq)
+			if err != nil {
+				return p2p.Msg{}, err
+			}
+			return p2p.MakeMsg(p2p.GetRootBlockHeaderListResponseMsg, qkcMsg.RpcID, p2p.Metadata{Branch: 0}, resp)
+		})
 
 	case qkcMsg.Op == p2p.GetRootBlockHeaderListResponseMsg:
 		var blockHeaderResp p2p.GetRootBlockHeaderListResponse
@@ -275,12 +367,13 @@ func (pm *ProtocolManager) handleMsg(peer *Peer) error {
 			return err
 		}
 
-		resp, err := pm.HandleGetRootBlockListRequest(&rootBlockReq)
-		if err != nil {
-			return err
-		}
-
-		return peer.SendResponse(p2p.GetRootBlockListResponseMsg, p2p.Metadata{Branch: 0}, qkcMsg.RpcID, resp)
+		return peer.serve(false, func() (p2p.Msg, error) {
+			resp, err := pm.HandleGetRootBlockListRequest(&rootBlockReq)
+			if err != nil {
+				return p2p.Msg{}, err
+			}
+			return p2p.MakeMsg(p2p.GetRootBlockListResponseMsg, qkcMsg.RpcID, p2p.Metadata{Branch: 0}, resp)
+		})
 
 	case qkcMsg.Op == p2p.GetRootBlockListResponseMsg:
 		var blockResp p2p.GetRootBlockListResponse
@@ -298,11 +391,13 @@ func (pm *ProtocolManager) handleMsg(peer *Peer) error {
 		if err := serialize.DeserializeFromBytes(qkcMsg.Data, &rBHeadersSkip); err != nil {
 			return err
 		}
-		resp, err := pm.HandleGetRootBlockHeaderListWithSkipRequest(peer.id, qkcMsg.RpcID, &rBHeadersSkip)
-		if err != nil {
-			return err
-		}
-		return peer.SendResponse(p2p.GetRootBlockHeaderListWithSkipResponseMsg, p2p.Metadata{Branch: qkcMsg.MetaData.Branch}, qkcMsg.RpcID, resp)
+		return peer.serve(false, func() (p2p.Msg, error) {
+			resp, err := pm.HandleGetRootBlockHeaderListWithSkipRequest(peer.id, qkcMsg.RpcID, &rBHeadersSkip)
+			if err != nil {
+				return p2p.Msg{}, err
+			}
+			return p2p.MakeMsg(p2p.GetRootBlockHeaderListWithSkipResponseMsg, qkcMsg.RpcID, p2p.Metadata{Branch: qkcMsg.MetaData.Branch}, resp)
+		})
 
 	case qkcMsg.Op == p2p.GetRootBlockHeaderListWithSkipResponseMsg:
 		var minorBlockResp p2p.GetRootBlockHeaderListResponse
@@ -316,17 +411,13 @@ func (pm *ProtocolManager) handleMsg(peer *Peer) error {
 		}
 
 	case qkcMsg.Op == p2p.GetMinorBlockHeaderListRequestMsg:
-		go func() {
+		return peer.serve(true, func() (p2p.Msg, error) {
 			resp, err := pm.HandleGetMinorBlockHeaderListRequest(qkcMsg.MetaData.Branch, qkcMsg.Data)
 			if err != nil {
-				peer.handleMsgErr = err
-			}
-
-			err = peer.SendResponseWithData(p2p.GetMinorBlockHeaderListResponseMsg, p2p.Metadata{Branch: qkcMsg.MetaData.Branch}, qkcMsg.RpcID, resp)
-			if err != nil {
-				peer.handleMsgErr = err
+				return p2p.Msg{}, err
 			}
-		}()
+			return p2p.MakeMsgWithSerializedData(p2p.GetMinorBlockHeaderListResponseMsg, qkcMsg.RpcID, p2p.Metadata{Branch: qkcMsg.MetaData.Branch}, resp)
+		})
 
 	case qkcMsg.Op == p2p.GetMinorBlockHeaderListResponseMsg:
 		if c := peer.getChan(qkcMsg.RpcID); c != nil {
@@ -336,16 +427,13 @@ func (pm *ProtocolManager) handleMsg(peer *Peer) error {
 		}
 
 	case qkcMsg.Op == p2p.GetMinorBlockListRequestMsg:
-		go func() {
+		return peer.serve(true, func() (p2p.Msg, error) {
 			resp, err := pm.HandleGetMinorBlockListRequest(peer.id, qkcMsg.MetaData.Branch, qkcMsg.Data)
 			if err != nil {
-				peer.handleMsgErr = err
-			}
-			err = peer.SendResponseWithData(p2p.GetMinorBlockListResponseMsg, p2p.Metadata{Branch: qkcMsg.MetaData.Branch}, qkcMsg.RpcID, resp)
-			if err != nil {
-				peer.handleMsgErr = err
+				return p2p.Msg{}, err
 			}
-		}()
+			return p2p.MakeMsgWithSerializedData(p2p.GetMinorBlockListResponseMsg, qkcMsg.RpcID, p2p.Metadata{Branch: qkcMsg.MetaData.Branch}, resp)
+		})
 
 	case qkcMsg.Op == p2p.GetMinorBlockListResponseMsg:
 		if c := peer.getChan(qkcMsg.RpcID); c != nil {
@@ -358,16 +446,13 @@ func (pm *ProtocolManager) handleMsg(peer *Peer) error {
 		panic("not implemented")
 
 	case qkcMsg.Op == p2p.GetMinorBlockHeaderListWithSkipRequestMsg:
-		go func() {
+		return peer.serve(true, func() (p2p.Msg, error) {
 			resp, err := pm.HandleGetMinorBlockHeaderListWithSkipRequest(peer.id, qkcMsg.MetaData.Branch, qkcMsg.Data)
 			if err != nil {
-				peer.handleMsgErr = err
-			}
-			err = peer.SendResponseWithData(p2p.GetMinorBlockHeaderListWithSkipResponseMsg, p2p.Metadata{Branch: qkcMsg.MetaData.Branch}, qkcMsg.RpcID, resp)
-			if err != nil {
-				peer.handleMsgErr = err
+				return p2p.Msg{}, err
 			}
-		}()
+			return p2p.MakeMsgWithSerializedData(p2p.GetMinorBlockHeaderListWithSkipResponseMsg, qkcMsg.RpcID, p2p.Metadata{Branch: qkcMsg.MetaData.Branch}, resp)
+		})
 
 	case qkcMsg.Op == p2p.GetMinorBlockHeaderListWithSkipResponseMsg:
 		if c := peer.getChan(qkcMsg.RpcID); c != nil {
@@ -409,21 +494,14 @@ func (pm *ProtocolManager) HandleNewMinorBlock(peerId string, branch uint32, dat
 		return fmt.Errorf("invalid branch %d for peer request %s", branch, peerId)
 	}
 
-	var (
-		g   errgroup.Group
-		req = rpc.P2PRedirectRequest{
-			PeerID: peerId,
-			Branch: branch,
-			Data:   data,
-		}
-	)
-	for _, client := range clients {
-		conn := client
-		g.Go(func() error {
-			return conn.HandleNewMinorBlock(&req)
-		})
+	req := rpc.P2PRedirectRequest{
+		PeerID: peerId,
+		Branch: branch,
+		Data:   data,
 	}
-	return g.Wait()
+	return fanOutAllSlaves(context.Background(), clients, func(ctx context.Context, conn rpc.ISlaveConn) error {
+		return conn.HandleNewMinorBlock(ctx, &req)
+	})
 }
 
 func (pm *ProtocolManager) HandleNewMinorTip(branch uint32, tip *p2p.Tip, peer *Peer) error {
@@ -464,7 +542,7 @@ func (pm *ProtocolManager) HandleNewMinorTip(branch uint32, tip *p2p.Tip, peer *
 			MinorBlockHeaderList: tip.MinorBlockHeaderList,
 			PeerID:               peer.id,
 		}
-		result, err := client.HandleNewTip(req)
+		result, err := client.HandleNewTip(context.Background(), req)
 		if err != nil {
 			return fmt.Errorf("branch %d handle NewTipMsg message failed with error: %v", branch, err.Error())
 		}
@@ -515,10 +593,15 @@ func (pm *ProtocolManager) HandleGetRootBlockListRequest(request *p2p.GetRootBlo
 		RootBlockList: make([]*types.RootBlock, 0, size),
 	}
 
+	var respSize common.StorageSize
 	for _, hash := range request.RootBlockHashList {
+		if respSize >= qkcsync.BlockListSoftResponseLimit {
+			break
+		}
 		block := pm.rootBlockChain.GetBlock(hash)
 		if block != nil {
 			response.RootBlockList = append(response.RootBlockList, block.(*types.RootBlock))
+			respSize += block.(*types.RootBlock).Size()
 		}
 	}
 	return &response, nil
@@ -597,7 +680,7 @@ func (pm *ProtocolManager) HandleNewTransactionListRequest(peerId string, rpcId
 	for _, client := range clients {
 		conn := client
 		go func() {
-			err := conn.AddTransactions(req)
+			err := conn.AddTransactions(context.Background(), req)
 			if err != nil {
 				log.Error("addTransaction err", "peerID", peerId, "branch", branch, "HandleNewTransactionListRequest failed with error: ", err.Error())
 			}
@@ -612,7 +695,7 @@ func (pm *ProtocolManager) HandleGetMinorBlockHeaderListRequest(branch uint32, d
 	if conn == nil {
 		return nil, fmt.Errorf("invalid branch %d", branch)
 	}
-	result, err := conn.GetMinorBlockHeaderList(&rpc.P2PRedirectRequest{Branch: branch, Data: data})
+	result, err := conn.GetMinorBlockHeaderList(context.Background(), &rpc.P2PRedirectRequest{Branch: branch, Data: data})
 	if err != nil {
 		return nil, fmt.Errorf("branch %d HandleGetMinorBlockHeaderListRequest failed with error: %v", branch, err.Error())
 	}
@@ -625,7 +708,7 @@ func (pm *ProtocolManager) HandleGetMinorBlockListRequest(peerId string, branch
 	if conn == nil {
 		return nil, fmt.Errorf("invalid peerID %s for branch %d", peerId, branch)
 	}
-	result, err := conn.GetMinorBlocks(&rpc.P2PRedirectRequest{Branch: branch, PeerID: peerId, Data: data})
+	result, err := conn.GetMinorBlocks(context.Background(), &rpc.P2PRedirectRequest{Branch: branch, PeerID: peerId, Data: data})
 	if err != nil {
 		return nil, fmt.Errorf("branch %d HandleGetMinorBlockListRequest failed with error: %v", branch, err.Error())
 	}
@@ -650,7 +733,7 @@ func (pm *ProtocolManager) HandleGetMinorBlockHeaderListWithSkipRequest(peerId s
 		return nil, fmt.Errorf("invalid peerID %s for branch request %d", peerId, branch)
 	}
 
-	return conn.GetMinorBlockHeaderListWithSkip(&rpc.P2PRedirectRequest{PeerID: peerId, Branch: branch, Data: data})
+	return conn.GetMinorBlockHeaderListWithSkip(context.Background(), &rpc.P2PRedirectRequest{PeerID: peerId, Branch: branch, Data: data})
 }
 
 func (pm *ProtocolManager) tipBroadcastLoop() {
@@ -666,15 +749,6 @@ func (pm *ProtocolManager) tipBroadcastLoop() {
 	}
 }
 
-func (pm *ProtocolManager) BroadcastTransactions(txs *rpc.P2PRedirectRequest, sourcePeerId string) {
-	for _, peer := range pm.peers.Peers() {
-		if peer.id != sourcePeerId {
-			peer.AsyncSendTransactions(txs)
-		}
-	}
-	log.Trace("Announced transaction", "recipients", pm.peers.Len()-1)
-}
-
 // syncer is responsible for periodically synchronising with the network, both
 // downloading hashes and blocks as well as handling the announcement handler.
 func (pm *ProtocolManager) syncer() {
@@ -2,11 +2,13 @@ package master
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"testing"
 	"time"
 
+	"github.com/QuarkChain/goquarkchain/cluster/config"
 	"github.com/QuarkChain/goquarkchain/cluster/rpc"
 	"github.com/QuarkChain/goquarkchain/cluster/sync"
 	"github.com/QuarkChain/goquarkchain/core"
@@ -15,6 +17,10 @@ import (
 	"github.com/QuarkChain/goquarkchain/p2p"
 	"github.com/QuarkChain/goquarkchain/serialize"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/discv5"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
 	"github.com/golang/mock/gomock"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
@@ -291,9 +297,10 @@ func TestGetMinorBlockHeaders(t *testing.T) {
 			BlockHeaderList: tt.expect,
 		})
 		assert.NoError(t, err)
+		// only the slave of the branch is asked
 		for _, conn := range fakeConnMngr.GetSlaveConns() {
-			conn.(*mock_master.MockISlaveConn).EXPECT().GetMinorBlockHeaderList(tt.query).Return(
-				data, nil).Times(1)
+			conn.(*mock_master.MockISlaveConn).EXPECT().GetMinorBlockHeaderList(gomock.Any(), tt.query).Return(
+				data, nil).MaxTimes(1)
 		}
 
 		go handleMsg(clientPeer)
@@ -374,8 +381,8 @@ func TestGetMinorBlocks(t *testing.T) {
 		data, err := serialize.SerializeToBytes(&p2p.GetMinorBlockListResponse{MinorBlockList: tt.expect})
 		assert.NoError(t, err)
 		for _, conn := range fakeConnMngr.GetSlaveConns() {
-			conn.(*mock_master.MockISlaveConn).EXPECT().GetMinorBlocks(gomock.Any()).Return(
-				data, nil).Times(1)
+			conn.(*mock_master.MockISlaveConn).EXPECT().GetMinorBlocks(gomock.Any(), gomock.Any()).Return(
+				data, nil).MaxTimes(1)
 		}
 		var (
 			req = rpc.P2PRedirectRequest{PeerID: "", Branch: 2}
@@ -421,7 +428,7 @@ func TestBroadcastMinorBlock(t *testing.T) {
 
 	for _, conn := range fakeConnMngr.GetSlaveConns() {
 		conn.(*mock_master.MockISlaveConn).EXPECT().
-			HandleNewMinorBlock(gomock.Any()).Return(nil).Times(1)
+			HandleNewMinorBlock(gomock.Any(), gomock.Any()).Return(nil).Times(1)
 	}
 	data, err := serialize.SerializeToBytes(p2p.NewBlockMinor{Block: minorBlock})
 	assert.NoError(t, err)
@@ -431,7 +438,7 @@ func TestBroadcastMinorBlock(t *testing.T) {
 	}
 	for _, conn := range fakeConnMngr.GetSlaveConns() {
 		conn.(*mock_master.MockISlaveConn).EXPECT().
-			HandleNewMinorBlock(gomock.Any()).DoAndReturn(func(request *rpc.P2PRedirectRequest) error {
+			HandleNewMinorBlock(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, request *rpc.P2PRedirectRequest) error {
 			errc <- nil
 			return errors.New("expected error")
 		}).Times(1)
@@ -467,7 +474,7 @@ func TestBroadcastTransactions(t *testing.T) {
 
 	for _, conn := range fakeConnMngr.GetSlaveConns() {
 		conn.(*mock_master.MockISlaveConn).EXPECT().
-			AddTransactions(gomock.Any()).DoAndReturn(func(request *rpc.P2PRedirectRequest) error {
+			AddTransactions(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, request *rpc.P2PRedirectRequest) error {
 			errc <- nil
 			return nil
 		}).AnyTimes()
@@ -496,7 +503,7 @@ func TestBroadcastNewMinorBlockTip(t *testing.T) {
 
 	for _, conn := range fakeConnMngr.GetSlaveConns() {
 		conn.(*mock_master.MockISlaveConn).EXPECT().
-			HandleNewTip(gomock.Any()).Return(true, nil).Times(1)
+			HandleNewTip(gomock.Any(), gomock.Any()).Return(true, nil).Times(1)
 	}
 	err = clientPeer.SendNewTip(2, &p2p.Tip{RootBlockHeader: pm.rootBlockChain.CurrentBlock().Header(),
 		MinorBlockHeaderList: []*types.MinorBlockHeader{minorBlocks[len(minorBlocks)-2].Header()}})
@@ -509,8 +516,8 @@ func TestBroadcastNewMinorBlockTip(t *testing.T) {
 	}
 	for _, conn := range fakeConnMngr.GetSlaveConns() {
 		conn.(*mock_master.MockISlaveConn).EXPECT().
-			HandleNewTip(gomock.Any()).DoAndReturn(
-			func(*rpc.HandleNewTipRequest) (bool, error) {
+			HandleNewTip(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(context.Context, *rpc.HandleNewTipRequest) (bool, error) {
 				errc <- nil
 				return false, errors.New("expected error")
 			}).Times(1)
@@ -690,3 +697,61 @@ func handleMsg(peer *Peer) error {
 	}
 	return nil
 }
+
+func TestDialFilterServesShards(t *testing.T) {
+	node := func(entries ...enr.Entry) *enode.Node {
+		key, _ := crypto.GenerateKey()
+		db, _ := enode.OpenDB("")
+		ln := enode.NewLocalNode(db, key)
+		for _, e := range entries {
+			ln.Set(e)
+		}
+		return ln.Node()
+	}
+	// the cluster runs the shards of the even chains
+	local := shardsEntry{ChainMasks: []uint32{0x2}}
+	// nodes which do not advertise their shards are dialed
+	assert.True(t, local.servesShards(node()))
+	assert.True(t, local.servesShards(node(shardsEntry{ChainMasks: []uint32{0x1}})))
+	assert.True(t, local.servesShards(node(shardsEntry{ChainMasks: []uint32{0x6, 0x7}})))
+	assert.False(t, local.servesShards(node(shardsEntry{ChainMasks: []uint32{0x3}})))
+	assert.False(t, local.servesShards(node(shardsEntry{})))
+}
+
+func TestShardTopics(t *testing.T) {
+	cfg := config.NewClusterConfig()
+	cfg.Quarkchain.NetworkID = 3
+	cfg.Quarkchain.ChainSize = 4
+	// the slaves run the shards of the chains 1 and 3
+	cfg.SlaveList = []*config.SlaveConfig{
+		{ID: "S0", ChainMaskList: []*types.ChainMask{types.NewChainMask(0x3)}},
+		{ID: "S1", ChainMaskList: []*types.ChainMask{types.NewChainMask(0x7)}},
+	}
+	assert.Equal(t, []discv5.Topic{"qkc-3-chain-1", "qkc-3-chain-3"}, shardTopics(cfg))
+}
+
+func TestPeerInfo(t *testing.T) {
+	app, net := p2p.MsgPipe()
+	defer app.Close()
+	peer := newTestClientPeer(int(qkcconfig.P2PProtocolVersion), net)
+	assert.Nil(t, peer.Info())
+
+	rootBlock := types.NewRootBlockWithHeader(&types.RootBlockHeader{Number: 3})
+	peer.SetRootHead(rootBlock.Header())
+	peer.SetMinorHead(3, &p2p.Tip{RootBlockHeader: rootBlock.Header()})
+	peer.SetMinorHead(1, &p2p.Tip{RootBlockHeader: rootBlock.Header()})
+	go func() {
+		if msg, err := app.ReadMsg(); err == nil {
+			msg.Discard()
+		}
+	}()
+	assert.NoError(t, peer.SendNewTip(0, &p2p.Tip{RootBlockHeader: rootBlock.Header()}))
+
+	info := peer.Info()
+	assert.Equal(t, uint32(3), info.RootNumber)
+	assert.Equal(t, rootBlock.Hash(), info.RootHash)
+	assert.Equal(t, []uint32{1, 3}, info.Shards)
+	assert.Equal(t, uint64(1), info.Traffic.EgressMsgs)
+	assert.NotZero(t, info.Traffic.EgressBytes)
+	assert.Zero(t, info.Traffic.IngressMsgs)
+}
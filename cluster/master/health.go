@@ -0,0 +1,61 @@
+package master
+
+import (
+	"github.com/QuarkChain/goquarkchain/cluster/service"
+)
+
+// setSlaveDown records whether the slave stopped answering the heartbeat.
+func (s *QKCMasterBackend) setSlaveDown(id string, down bool) {
+	s.downSlavesLock.Lock()
+	defer s.downSlavesLock.Unlock()
+	if down {
+		s.downSlaves[id] = true
+	} else {
+		delete(s.downSlaves, id)
+	}
+}
+
+// HealthChecks implements service.HealthReporter. The master is alive while
+// its database is available, and ready when all the slaves answer the
+// heartbeat, the root chain is not syncing and it has READY_MIN_PEERS peers.
+func (s *QKCMasterBackend) HealthChecks() []service.HealthCheck {
+	checks := []service.HealthCheck{service.CheckDatabase("db", s.chainDb)}
+
+	s.downSlavesLock.RLock()
+	down := make([]string, 0, len(s.downSlaves))
+	for id := range s.downSlaves {
+		down = append(down, id)
+	}
+	s.downSlavesLock.RUnlock()
+	checks = append(checks, service.HealthCheck{
+		Name: "slaves",
+		OK:   len(down) == 0,
+		Detail: map[string]interface{}{
+			"connected": s.ConnCount() - len(down),
+			"total":     len(s.clusterConfig.SlaveList),
+			"down":      down,
+			"lagging":   s.laggingSlaves(),
+		},
+	})
+
+	syncing := s.synchronizer.IsSyncing()
+	checks = append(checks, service.HealthCheck{
+		Name:   "sync",
+		OK:     !syncing,
+		Detail: map[string]interface{}{"syncing": syncing},
+	})
+
+	var peers, minPeers int
+	if s.protocolManager != nil {
+		peers = s.protocolManager.peers.Len()
+	}
+	if s.clusterConfig.Master != nil {
+		minPeers = int(s.clusterConfig.Master.ReadyMinPeers)
+	}
+	checks = append(checks, service.HealthCheck{
+		Name:   "peers",
+		OK:     peers >= minPeers,
+		Detail: map[string]interface{}{"count": peers, "min": minPeers},
+	})
+	return checks
+}
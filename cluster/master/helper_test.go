@@ -22,6 +22,7 @@ package master
 import (
 	"crypto/ecdsa"
 	"crypto/rand"
+	"fmt"
 	"github.com/QuarkChain/goquarkchain/account"
 	"github.com/QuarkChain/goquarkchain/cluster/config"
 	"github.com/QuarkChain/goquarkchain/cluster/rpc"
@@ -171,7 +172,7 @@ func newTestPeer(name string, version int, pm *ProtocolManager, shake bool) (*te
 	var id enode.ID
 	rand.Read(id[:])
 
-	peer := newPeer(version, p2p.NewPeer(id, name, nil), net)
+	peer := newPeer(version, p2p.NewPeer(id, name, nil), net, newServeQuota(nil))
 
 	// Start the peer on a new thread
 	var err error
@@ -191,7 +192,7 @@ func newTestClientPeer(version int, msgrw p2p.MsgReadWriter) *Peer {
 	var id enode.ID
 	rand.Read(id[:])
 
-	return newPeer(version, p2p.NewPeer(id, "client", nil), msgrw)
+	return newPeer(version, p2p.NewPeer(id, "client", nil), msgrw, newServeQuota(nil))
 }
 
 // handshake simulates a trivial handshake that expects the same state from the
@@ -305,6 +306,7 @@ func newFakeConnManager(n int, ctrl *gomock.Controller) *fakeConnManager {
 	conns := make([]rpc.ISlaveConn, 0, n)
 	for i := 0; i < n; i++ {
 		sc := mock_master.NewMockISlaveConn(ctrl)
+		sc.EXPECT().GetSlaveID().Return(fmt.Sprintf("S%d", i)).AnyTimes()
 		conns = append(conns, sc)
 	}
 
@@ -2,8 +2,10 @@ package master
 
 import (
 	"context"
+	"github.com/QuarkChain/goquarkchain/account"
 	"github.com/QuarkChain/goquarkchain/cluster/rpc"
 	"github.com/QuarkChain/goquarkchain/serialize"
+	"io"
 	"sync"
 )
 
@@ -28,6 +30,8 @@ func (m *MasterServerSideOp) AddMinorBlockHeader(ctx context.Context, req *rpc.R
 	m.master.rootBlockChain.AddValidatedMinorBlockHeader(data.MinorBlockHeader.Hash(), data.CoinbaseAmountMap)
 	m.master.UpdateShardStatus(data.ShardStats)
 	m.master.UpdateTxCountHistory(data.TxCount, data.XShardTxCount, data.MinorBlockHeader.Time)
+	m.master.publishShardTip(data.MinorBlockHeader, data.TxCount, data.ShardStats)
+	m.master.miner.HandleNewWork()
 
 	rsp := new(rpc.AddMinorBlockHeaderResponse)
 	rsp.ArtificialTxConfig = m.master.artificialTxConfig
@@ -50,6 +54,7 @@ func (m *MasterServerSideOp) AddMinorBlockHeaderList(ctx context.Context, req *r
 	for _, header := range gReq.MinorBlockHeaderList {
 		m.master.rootBlockChain.AddValidatedMinorBlockHeader(header.Hash(), header.CoinbaseAmount)
 	}
+	m.master.publishSyncedShardTips(gReq.MinorBlockHeaderList)
 	return &rpc.Response{RpcId: req.RpcId}, nil
 }
 
@@ -73,7 +78,7 @@ func (m *MasterServerSideOp) BroadcastTransactions(ctx context.Context, req *rpc
 	if err := serialize.DeserializeFromBytes(req.Data, broadcastTxsReq); err != nil {
 		return nil, err
 	}
-	m.p2pApi.BroadcastTransactions(broadcastTxsReq, broadcastTxsReq.PeerID)
+	m.master.protocolManager.BroadcastTransactions(broadcastTxsReq, broadcastTxsReq.PeerID)
 	return &rpc.Response{
 		RpcId: req.RpcId,
 	}, nil
@@ -152,3 +157,28 @@ func (m *MasterServerSideOp) GetMinorBlockHeaderList(ctx context.Context, req *r
 		RpcId: req.RpcId,
 	}, nil
 }
+
+// BootstrapShard streams a snapshot of the shard to a new slave, served by one
+// of the slaves running it.
+func (m *MasterServerSideOp) BootstrapShard(req *rpc.Request, stream rpc.MasterServerSideOp_BootstrapShardServer) error {
+	var gReq rpc.GetShardSnapshotRequest
+	if err := serialize.DeserializeFromBytes(req.Data, &gReq); err != nil {
+		return err
+	}
+	r, err := m.master.openShardSnapshot(account.Branch{Value: gReq.Branch}, gReq.RootHash)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w := rpc.NewStreamWriter(stream.Send, req.RpcId)
+	if _, err := io.Copy(w, r); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// ShardBootstrapped resyncs the shards a slave bootstrapped to the root tip.
+func (m *MasterServerSideOp) ShardBootstrapped(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	go m.master.resyncLoop()
+	return &rpc.Response{RpcId: req.RpcId}, nil
+}
@@ -2,8 +2,13 @@ package master
 
 import (
 	"bou.ke/monkey"
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/account/keystore"
+	"github.com/QuarkChain/goquarkchain/cluster/audit"
 	"github.com/QuarkChain/goquarkchain/cluster/config"
 	"github.com/QuarkChain/goquarkchain/cluster/rpc"
 	"github.com/QuarkChain/goquarkchain/cluster/service"
@@ -11,14 +16,21 @@ import (
 	"github.com/QuarkChain/goquarkchain/consensus"
 	"github.com/QuarkChain/goquarkchain/core/rawdb"
 	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/internal/debug"
+	"github.com/QuarkChain/goquarkchain/mocks/mock_master"
 	qrpc "github.com/QuarkChain/goquarkchain/rpc"
 	"github.com/QuarkChain/goquarkchain/serialize"
 	eth "github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
 	"math/big"
+	"os"
+	"sync"
 	"testing"
 	"time"
 )
@@ -34,6 +46,12 @@ type fakeRpcClient struct {
 	chanOP       chan uint32
 	config       *config.ClusterConfig
 	branchs      []*account.Branch
+	restoredTip  *rpc.ShardRootTip // root tip of a shard restored from a backup
+	accountErr   error             // returned by GetAccountData if not nil
+	snapshot     []byte            // streamed by GetShardSnapshot if not nil
+
+	mu              sync.Mutex
+	idempotencyKeys map[uint32][]string // sent along with the calls by op
 }
 
 func NewFakeRPCClient(chanOP chan uint32, target string, shardMaskLst []*types.ChainMask, slaveID string, config *config.ClusterConfig) *fakeRpcClient {
@@ -61,6 +79,21 @@ func (c *fakeRpcClient) GetOpName(op uint32) string {
 
 func (c *fakeRpcClient) Close() {}
 
+func (c *fakeRpcClient) SetCapabilities(hostport string, caps *rpc.Capabilities) {}
+
+func (c *fakeRpcClient) Supports(hostport string, op uint32) bool { return true }
+
+func (c *fakeRpcClient) Stream(hostport string, req *rpc.Request) (io.ReadCloser, error) {
+	if req.Op == rpc.OpGetShardSnapshot && c.snapshot != nil {
+		return ioutil.NopCloser(bytes.NewReader(c.snapshot)), nil
+	}
+	return nil, errors.New("unsupported op")
+}
+
+func (c *fakeRpcClient) StreamContext(ctx context.Context, hostport string, req *rpc.Request) (io.ReadCloser, error) {
+	return c.Stream(hostport, req)
+}
+
 func (c *fakeRpcClient) coverShardID(fullShardID uint32) bool {
 	for _, chainMask := range c.chainMaskLst {
 		if chainMask.ContainFullShardId(fullShardID) {
@@ -71,6 +104,21 @@ func (c *fakeRpcClient) coverShardID(fullShardID uint32) bool {
 
 }
 
+func (c *fakeRpcClient) CallContext(ctx context.Context, hostport string, req *rpc.Request) (*rpc.Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if key := rpc.IdempotencyKeyOf(ctx); key != "" {
+		c.mu.Lock()
+		if c.idempotencyKeys == nil {
+			c.idempotencyKeys = make(map[uint32][]string)
+		}
+		c.idempotencyKeys[req.Op] = append(c.idempotencyKeys[req.Op], key)
+		c.mu.Unlock()
+	}
+	return c.Call(hostport, req)
+}
+
 func (c *fakeRpcClient) Call(hostport string, req *rpc.Request) (*rpc.Response, error) {
 	switch req.Op {
 	case rpc.OpHeartBeat:
@@ -79,9 +127,14 @@ func (c *fakeRpcClient) Call(hostport string, req *rpc.Request) (*rpc.Response,
 		}
 		return nil, nil
 	case rpc.OpPing:
+		ping := new(rpc.Ping)
+		if err := serialize.DeserializeFromBytes(req.Data, ping); err != nil {
+			return nil, err
+		}
 		rsp := new(rpc.Pong)
 		rsp.Id = []byte(c.slaveID)
 		rsp.ChainMaskList = c.chainMaskLst
+		rsp.GenesisHash = ping.GenesisHash
 		data, err := serialize.SerializeToBytes(rsp)
 		if err != nil {
 			return nil, err
@@ -118,6 +171,9 @@ func (c *fakeRpcClient) Call(hostport string, req *rpc.Request) (*rpc.Response,
 		}
 		return &rpc.Response{Data: data}, nil
 	case rpc.OpGetAccountData:
+		if c.accountErr != nil {
+			return nil, c.accountErr
+		}
 		rsp := new(rpc.GetAccountDataResponse)
 		for _, v := range c.branchs {
 			rsp.AccountBranchDataList = append(rsp.AccountBranchDataList, &rpc.AccountBranchData{Branch: v.Value})
@@ -130,6 +186,16 @@ func (c *fakeRpcClient) Call(hostport string, req *rpc.Request) (*rpc.Response,
 	case rpc.OpGetMine:
 		return &rpc.Response{}, nil
 	case rpc.OpAddRootBlock:
+		if c.restoredTip != nil {
+			addReq := new(rpc.AddRootBlockRequest)
+			if err := serialize.DeserializeFromBytes(req.Data, addReq); err != nil {
+				return nil, err
+			}
+			if addReq.RootBlock.ParentHash() != c.restoredTip.Hash {
+				return nil, errors.New("unknown parent root block")
+			}
+			c.restoredTip.Number, c.restoredTip.Hash = addReq.RootBlock.Number(), addReq.RootBlock.Hash()
+		}
 		rsp := new(rpc.AddRootBlockResponse)
 		rsp.Switched = false
 		data, err := serialize.SerializeToBytes(rsp)
@@ -249,6 +315,47 @@ func (c *fakeRpcClient) Call(hostport string, req *rpc.Request) (*rpc.Response,
 			return nil, err
 		}
 		return &rpc.Response{Data: data}, nil
+	case rpc.OpSetLogLevel:
+		levelReq := new(rpc.SetLogLevelRequest)
+		if err := serialize.DeserializeFromBytes(req.Data, levelReq); err != nil {
+			return nil, err
+		}
+		if c.chanOP != nil {
+			c.chanOP <- rpc.OpSetLogLevel
+		}
+		return &rpc.Response{}, nil
+	case rpc.OpGetSlowOps:
+		opsReq := new(rpc.GetSlowOpsRequest)
+		if err := serialize.DeserializeFromBytes(req.Data, opsReq); err != nil {
+			return nil, err
+		}
+		rsp := &rpc.GetSlowOpsResponse{SlowOps: []*rpc.SlowOp{
+			{Time: uint64(len(c.slaveID)), Caller: c.slaveID, Op: "AddMinorBlockHeader", Peer: "master", Latency: 600},
+		}}
+		data, err := serialize.SerializeToBytes(rsp)
+		if err != nil {
+			return nil, err
+		}
+		return &rpc.Response{Data: data}, nil
+	case rpc.OpProfile:
+		profileReq := new(rpc.ProfileRequest)
+		if err := serialize.DeserializeFromBytes(req.Data, profileReq); err != nil {
+			return nil, err
+		}
+		if c.chanOP != nil {
+			c.chanOP <- rpc.OpProfile
+		}
+		return &rpc.Response{}, nil
+	case rpc.OpGetShardRootTips:
+		rsp := new(rpc.GetShardRootTipsResponse)
+		if c.restoredTip != nil {
+			rsp.RootTips = []*rpc.ShardRootTip{c.restoredTip}
+		}
+		data, err := serialize.SerializeToBytes(rsp)
+		if err != nil {
+			return nil, err
+		}
+		return &rpc.Response{Data: data}, nil
 	case rpc.OpSubmitWork:
 		rsp := new(rpc.SubmitWorkResponse)
 		rsp.Success = true
@@ -267,15 +374,6 @@ func initEnv(t *testing.T, chanOp chan uint32) *QKCMasterBackend {
 }
 
 func initEnvWithConsensusType(t *testing.T, chanOp chan uint32, consensusType string, pubKey string) *QKCMasterBackend {
-	monkey.Patch(NewSlaveConn, func(target string, shardMaskLst []*types.ChainMask, slaveID string) *SlaveConnection {
-		client := NewFakeRPCClient(chanOp, target, shardMaskLst, slaveID, config.NewClusterConfig())
-		return &SlaveConnection{
-			target:        target,
-			client:        client,
-			shardMaskList: shardMaskLst,
-			slaveID:       slaveID,
-		}
-	})
 	monkey.Patch(createDB, func(ctx *service.ServiceContext, name string, clean bool, isReadOnly bool) (ethdb.Database, error) {
 		return service.NewQkcMemoryDB(isReadOnly), nil
 	})
@@ -290,11 +388,42 @@ func initEnvWithConsensusType(t *testing.T, chanOp chan uint32, consensusType st
 	if err != nil {
 		panic(err)
 	}
+	master.SetSlaveConnCreator(func(target string, shardMaskLst []*types.ChainMask, slaveID string, genesisHash common.Hash) rpc.ISlaveConn {
+		client := NewFakeRPCClient(chanOp, target, shardMaskLst, slaveID, config.NewClusterConfig())
+		return &SlaveConnection{
+			target:        target,
+			client:        client,
+			shardMaskList: shardMaskLst,
+			slaveID:       slaveID,
+			genesisHash:   genesisHash,
+		}
+	})
 	if err := master.Init(nil); err != nil {
 		assert.NoError(t, err)
 	}
 	return master
 }
+
+// newMockSlaveBackend returns a backend sharing the config, the root chain and
+// the protocol manager of master, with n mock slaves running all the shards.
+func newMockSlaveBackend(master *QKCMasterBackend, ctrl *gomock.Controller, n int) (*QKCMasterBackend, []*mock_master.MockISlaveConn) {
+	backend := &QKCMasterBackend{
+		clusterConfig:   master.clusterConfig,
+		chainDb:         master.chainDb,
+		rootBlockChain:  master.rootBlockChain,
+		protocolManager: master.protocolManager,
+	}
+	backend.branchToSlaveConns = make(map[uint32][]rpc.ISlaveConn)
+	conns := make([]*mock_master.MockISlaveConn, 0, n)
+	for i := 0; i < n; i++ {
+		conn := mock_master.NewMockISlaveConn(ctrl)
+		conn.EXPECT().HasShard(gomock.Any()).Return(true).AnyTimes()
+		conn.EXPECT().GetSlaveID().Return(fmt.Sprintf("S%d", i)).AnyTimes()
+		backend.addSlaveConn(conn, master.clusterConfig.Quarkchain.GetGenesisShardIds())
+		conns = append(conns, conn)
+	}
+	return backend, conns
+}
 func TestMasterBackend_InitCluster(t *testing.T) {
 	initEnv(t, nil)
 }
@@ -378,16 +507,49 @@ func TestGetAccountData(t *testing.T) {
 	assert.NoError(t, err)
 	add1 := account.NewAddress(id1.GetRecipient(), 3)
 	master := initEnv(t, nil)
-	_, err = master.GetAccountData(&add1, nil)
+	_, err = master.GetAccountData(context.Background(), &add1, nil)
 	assert.NoError(t, err)
 }
 
+func TestGetAccountDataPartialFailure(t *testing.T) {
+	id1, err := account.CreatRandomIdentity()
+	assert.NoError(t, err)
+	add1 := account.NewAddress(id1.GetRecipient(), 3)
+	master := initEnv(t, nil)
+	conns := master.GetSlaveConns()
+	failed := conns[0].(*SlaveConnection)
+	failed.client.(*fakeRpcClient).accountErr = errors.New("slave down")
+
+	data, err := master.GetAccountData(context.Background(), &add1, nil)
+	assert.NoError(t, err)
+	errored := 0
+	for _, id := range master.clusterConfig.Quarkchain.GetGenesisShardIds() {
+		coveredByOthers := false
+		for _, conn := range conns[1:] {
+			coveredByOthers = coveredByOthers || conn.HasShard(id)
+		}
+		if failed.HasShard(id) && !coveredByOthers {
+			assert.Equal(t, "slave down", data[id].Error)
+			errored++
+		} else {
+			assert.Empty(t, data[id].Error)
+		}
+	}
+	assert.NotZero(t, errored)
+
+	for _, conn := range conns {
+		conn.(*SlaveConnection).client.(*fakeRpcClient).accountErr = errors.New("slave down")
+	}
+	_, err = master.GetAccountData(context.Background(), &add1, nil)
+	assert.Error(t, err)
+}
+
 func TestGetPrimaryAccountData(t *testing.T) {
 	master := initEnv(t, nil)
 	id1, err := account.CreatRandomIdentity()
 	assert.NoError(t, err)
 	add1 := account.NewAddress(id1.GetRecipient(), 3)
-	_, err = master.GetPrimaryAccountData(&add1, nil)
+	_, err = master.GetPrimaryAccountData(context.Background(), &add1, nil)
 	assert.NoError(t, err)
 }
 
@@ -408,6 +570,162 @@ func TestAddRootBlock(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestRootBlockAcks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	master, conns := newMockSlaveBackend(initEnv(t, nil), ctrl, 2)
+	id1, err := account.CreatRandomIdentity()
+	assert.NoError(t, err)
+	add1 := account.NewAddress(id1.GetRecipient(), 3)
+	genesis := master.rootBlockChain.CurrentBlock()
+	fullShardID := master.clusterConfig.Quarkchain.GetGenesisShardIds()[0]
+	for _, conn := range conns {
+		conn.EXPECT().MasterInfo(gomock.Any(), gomock.Any(), genesis).Return(nil)
+	}
+	assert.NoError(t, master.initShards())
+	assert.Equal(t, genesis.Hash(), rawdb.ReadSlaveRootAck(master.chainDb, "S1"))
+
+	// the slave failed to add the root block is not routed to until caught up
+	addRootBlock := func() *types.RootBlock {
+		block, err := master.rootBlockChain.CreateBlockToMine(nil, &add1, nil)
+		assert.NoError(t, err)
+		_, err = master.rootBlockChain.InsertChain([]types.IBlock{block})
+		assert.NoError(t, err)
+		return block
+	}
+	block1 := addRootBlock()
+	conns[0].EXPECT().AddRootBlock(gomock.Any(), block1, false).Return(nil)
+	conns[1].EXPECT().AddRootBlock(gomock.Any(), block1, false).Return(errors.New("slave restarting"))
+	assert.Error(t, master.broadcastRootBlockToSlaves(block1))
+	assert.Equal(t, []string{"S1"}, master.laggingSlaves())
+	assert.Equal(t, []rpc.ISlaveConn{conns[0]}, master.GetSlaveConnsById(fullShardID))
+	assert.Equal(t, genesis.Hash(), rawdb.ReadSlaveRootAck(master.chainDb, "S1"))
+
+	block2 := addRootBlock()
+	conns[0].EXPECT().AddRootBlock(gomock.Any(), block2, false).Return(nil)
+	assert.NoError(t, master.broadcastRootBlockToSlaves(block2))
+
+	gomock.InOrder(
+		conns[1].EXPECT().AddRootBlock(gomock.Any(), block1, false).Return(nil),
+		conns[1].EXPECT().AddRootBlock(gomock.Any(), block2, false).Return(nil),
+	)
+	master.retryCatchUpSlave(conns[1])
+	assert.Empty(t, master.laggingSlaves())
+	assert.Len(t, master.GetSlaveConnsById(fullShardID), 2)
+	assert.Equal(t, block2.Hash(), rawdb.ReadSlaveRootAck(master.chainDb, "S1"))
+
+	// a slave restarted is initialized from the last root block it added
+	rawdb.WriteSlaveRootAck(master.chainDb, "S0", block1.Hash())
+	gomock.InOrder(
+		conns[0].EXPECT().MasterInfo(gomock.Any(), gomock.Any(), block1).Return(nil),
+		conns[0].EXPECT().AddRootBlock(gomock.Any(), block2, false).Return(nil),
+	)
+	assert.NoError(t, master.initSlave(conns[0]))
+	assert.Empty(t, master.laggingSlaves())
+	assert.Equal(t, block2.Hash(), rawdb.ReadSlaveRootAck(master.chainDb, "S0"))
+
+	// the ack of a lower root block, e.g. by a catch up returning after a
+	// broadcast, doesn't move the stored one backwards
+	master.writeSlaveRootAck("S0", block1)
+	assert.Equal(t, block2.Hash(), rawdb.ReadSlaveRootAck(master.chainDb, "S0"))
+	// but an unknown one is replaced
+	rawdb.WriteSlaveRootAck(master.chainDb, "S0", common.Hash{1})
+	master.writeSlaveRootAck("S0", block1)
+	assert.Equal(t, block1.Hash(), rawdb.ReadSlaveRootAck(master.chainDb, "S0"))
+}
+
+func TestExportImportChain(t *testing.T) {
+	master := initEnv(t, nil)
+	id1, err := account.CreatRandomIdentity()
+	assert.NoError(t, err)
+	add1 := account.NewAddress(id1.GetRecipient(), 3)
+	for i := 0; i < 3; i++ {
+		rootBlock, err := master.rootBlockChain.CreateBlockToMine(nil, &add1, nil)
+		assert.NoError(t, err)
+		assert.NoError(t, master.AddRootBlock(rootBlock))
+	}
+	var buf bytes.Buffer
+	assert.Error(t, master.ExportChain(&buf, 0, 4))
+	assert.NoError(t, master.ExportChain(&buf, 0, 3))
+
+	newMaster := initEnv(t, nil)
+	assert.NoError(t, newMaster.ImportChain(&buf))
+	assert.Equal(t, uint64(3), newMaster.GetTip())
+	assert.Equal(t, master.CurrentBlock().Hash(), newMaster.CurrentBlock().Hash())
+}
+
+func TestResyncShards(t *testing.T) {
+	master := initEnv(t, nil)
+	id1, err := account.CreatRandomIdentity()
+	assert.NoError(t, err)
+	add1 := account.NewAddress(id1.GetRecipient(), 3)
+	for i := 0; i < 3; i++ {
+		rootBlock, err := master.rootBlockChain.CreateBlockToMine(nil, &add1, nil)
+		assert.NoError(t, err)
+		assert.NoError(t, master.AddRootBlock(rootBlock))
+	}
+	assert.NoError(t, master.resyncShards())
+
+	client := master.GetSlaveConns()[0].(*SlaveConnection).client.(*fakeRpcClient)
+	client.restoredTip = &rpc.ShardRootTip{Branch: client.branchs[0].Value, Number: 1, Hash: common.Hash{1}, Restored: true}
+	assert.Error(t, master.resyncShards())
+
+	// the root blocks since the backup are added
+	client.restoredTip.Hash = master.rootBlockChain.GetBlockByNumber(1).Hash()
+	assert.NoError(t, master.resyncShards())
+	assert.Equal(t, uint32(3), client.restoredTip.Number)
+	assert.Equal(t, master.CurrentBlock().Hash(), client.restoredTip.Hash)
+}
+
+func TestOpenShardSnapshot(t *testing.T) {
+	master := initEnv(t, nil)
+	conns := master.GetSlaveConns()
+	branch := *conns[0].(*SlaveConnection).client.(*fakeRpcClient).branchs[0]
+	_, err := master.openShardSnapshot(branch, common.Hash{})
+	assert.Error(t, err)
+
+	// the slaves failing to serve the snapshot are skipped
+	for _, conn := range master.GetSlaveConnsById(branch.Value) {
+		conn.(*SlaveConnection).client.(*fakeRpcClient).snapshot = []byte{}
+	}
+	_, err = master.openShardSnapshot(branch, common.Hash{})
+	assert.Error(t, err)
+	last := master.GetSlaveConnsById(branch.Value)
+	last[len(last)-1].(*SlaveConnection).client.(*fakeRpcClient).snapshot = []byte("snapshot")
+	r, err := master.openShardSnapshot(branch, common.Hash{})
+	assert.NoError(t, err)
+	data, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.NoError(t, r.Close())
+	assert.Equal(t, []byte("snapshot"), data)
+}
+
+func TestIdempotencyKeys(t *testing.T) {
+	master := initEnv(t, nil)
+	id1, err := account.CreatRandomIdentity()
+	assert.NoError(t, err)
+	add1 := account.NewAddress(id1.GetRecipient(), 3)
+	rootBlock, err := master.rootBlockChain.CreateBlockToMine(nil, &add1, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, master.AddRootBlock(rootBlock))
+	// the root block broadcast again is sent with the same key
+	assert.NoError(t, master.broadcastRootBlockToSlaves(rootBlock))
+	conn := master.GetSlaveConns()[0].(*SlaveConnection)
+	client := conn.client.(*fakeRpcClient)
+	key := rootBlock.Hash().Hex()
+	assert.Equal(t, []string{key, key}, client.idempotencyKeys[rpc.OpAddRootBlock])
+
+	// so is a tx added again, and the other txs get keys of their own
+	tx := &types.Transaction{
+		EvmTx:  types.NewEvmTransaction(0, id1.GetRecipient(), new(big.Int), 0, new(big.Int), 2, 2, 1, 0, []byte{}, 0, 0),
+		TxType: types.EvmTx,
+	}
+	assert.NoError(t, conn.AddTransaction(context.Background(), tx))
+	assert.NoError(t, conn.AddTransaction(context.Background(), tx))
+	key = tx.Hash().Hex()
+	assert.Equal(t, []string{key, key}, client.idempotencyKeys[rpc.OpAddTransaction])
+}
+
 func TestSetTargetBlockTime(t *testing.T) {
 	master := initEnv(t, nil)
 	rootBlockTime := uint32(12)
@@ -425,7 +743,7 @@ func TestAddTransaction(t *testing.T) {
 		EvmTx:  evmTx,
 		TxType: types.EvmTx,
 	}
-	err = master.AddTransaction(tx)
+	err = master.AddTransaction(context.Background(), tx)
 	assert.Error(t, err)
 
 	evmTx = types.NewEvmTransaction(0, id1.GetRecipient(), new(big.Int), 0, new(big.Int).SetUint64(1000000000), 2, 2, 1, 0, []byte{}, 0, 0)
@@ -433,7 +751,7 @@ func TestAddTransaction(t *testing.T) {
 		EvmTx:  evmTx,
 		TxType: types.EvmTx,
 	}
-	err = master.AddTransaction(tx)
+	err = master.AddTransaction(context.Background(), tx)
 	assert.NoError(t, err)
 
 	//fromFullShardKey 00040000 -> chainID =4
@@ -443,7 +761,7 @@ func TestAddTransaction(t *testing.T) {
 		EvmTx:  evmTx,
 		TxType: types.EvmTx,
 	}
-	err = master.AddTransaction(tx)
+	err = master.AddTransaction(context.Background(), tx)
 	assert.Error(t, err)
 }
 
@@ -457,19 +775,98 @@ func TestExecuteTransaction(t *testing.T) {
 		EvmTx:  evmTx,
 		TxType: types.EvmTx,
 	}
-	data, err := master.ExecuteTransaction(tx, &add1, nil)
+	data, err := master.ExecuteTransaction(context.Background(), tx, &add1, nil, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, data, []byte("qkc"))
 
+	// the slaves are not called for the caller gone
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = master.ExecuteTransaction(ctx, tx, &add1, nil, nil)
+	assert.Equal(t, context.Canceled, err)
+
 	evmTx = types.NewEvmTransaction(0, id1.GetRecipient(), new(big.Int), 0, new(big.Int), 222222222, 2, 1, 0, []byte{}, 0, 0)
 	tx = &types.Transaction{
 		EvmTx:  evmTx,
 		TxType: types.EvmTx,
 	}
-	_, err = master.ExecuteTransaction(tx, &add1, nil)
+	_, err = master.ExecuteTransaction(context.Background(), tx, &add1, nil, nil)
 	assert.Error(t, err)
 }
 
+func TestAddTransactionFanOut(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	master, conns := newMockSlaveBackend(initEnv(t, nil), ctrl, 2)
+	id1, err := account.CreatRandomIdentity()
+	assert.NoError(t, err)
+	evmTx := types.NewEvmTransaction(0, id1.GetRecipient(), new(big.Int), 0, new(big.Int).SetUint64(1000000000), 2, 2, 1, 0, []byte{}, 0, 0)
+	tx := &types.Transaction{
+		EvmTx:  evmTx,
+		TxType: types.EvmTx,
+	}
+
+	// the tx is added to all the slaves running the shard
+	for _, conn := range conns {
+		conn.EXPECT().AddTransaction(gomock.Any(), tx).Return(nil)
+	}
+	assert.NoError(t, master.AddTransaction(context.Background(), tx))
+
+	// the calls left are canceled on the first failure
+	errAdd := errors.New("known transaction")
+	conns[0].EXPECT().AddTransaction(gomock.Any(), tx).Return(nil).MaxTimes(1)
+	conns[1].EXPECT().AddTransaction(gomock.Any(), tx).Return(errAdd)
+	err = master.AddTransaction(context.Background(), tx)
+	assert.True(t, errors.Is(err, errAdd))
+	assert.EqualError(t, err, "slave S1: known transaction")
+}
+
+func TestExecuteTransactionQuorum(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	master, conns := newMockSlaveBackend(initEnv(t, nil), ctrl, 3)
+	id1, err := account.CreatRandomIdentity()
+	assert.NoError(t, err)
+	add1 := account.NewAddress(id1.GetRecipient(), 3)
+	evmTx := types.NewEvmTransaction(0, id1.GetRecipient(), new(big.Int), 0, new(big.Int), 2, 2, 1, 0, []byte{}, 0, 0)
+	tx := &types.Transaction{
+		EvmTx:  evmTx,
+		TxType: types.EvmTx,
+	}
+	ctx := context.Background()
+
+	for _, conn := range conns {
+		conn.EXPECT().ExecuteTransaction(gomock.Any(), tx, &add1, nil, nil).Return([]byte("qkc"), nil)
+	}
+	data, err := master.ExecuteTransaction(ctx, tx, &add1, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("qkc"), data)
+
+	// a majority of the slaves is enough
+	errExec := errors.New("execution reverted")
+	conns[0].EXPECT().ExecuteTransaction(gomock.Any(), tx, &add1, nil, nil).Return([]byte("qkc"), nil)
+	conns[1].EXPECT().ExecuteTransaction(gomock.Any(), tx, &add1, nil, nil).Return(nil, errExec)
+	conns[2].EXPECT().ExecuteTransaction(gomock.Any(), tx, &add1, nil, nil).Return([]byte("qkc"), nil)
+	data, err = master.ExecuteTransaction(ctx, tx, &add1, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("qkc"), data)
+
+	// and must agree on the result
+	conns[0].EXPECT().ExecuteTransaction(gomock.Any(), tx, &add1, nil, nil).Return([]byte("qkc"), nil)
+	conns[1].EXPECT().ExecuteTransaction(gomock.Any(), tx, &add1, nil, nil).Return(nil, errExec)
+	conns[2].EXPECT().ExecuteTransaction(gomock.Any(), tx, &add1, nil, nil).Return([]byte("eth"), nil)
+	_, err = master.ExecuteTransaction(ctx, tx, &add1, nil, nil)
+	assert.EqualError(t, err, "exist more than one result")
+
+	// the call fails once a majority can't succeed anymore
+	conns[0].EXPECT().ExecuteTransaction(gomock.Any(), tx, &add1, nil, nil).Return([]byte("qkc"), nil).MaxTimes(1)
+	conns[1].EXPECT().ExecuteTransaction(gomock.Any(), tx, &add1, nil, nil).Return(nil, errExec)
+	conns[2].EXPECT().ExecuteTransaction(gomock.Any(), tx, &add1, nil, nil).Return(nil, errExec)
+	_, err = master.ExecuteTransaction(ctx, tx, &add1, nil, nil)
+	assert.True(t, errors.Is(err, errExec))
+	assert.EqualError(t, err, "slave S1: execution reverted; slave S2: execution reverted")
+}
+
 func TestGetMinorBlockByHeight(t *testing.T) {
 	master := initEnv(t, nil)
 	fakeMinorBlock := types.NewMinorBlock(&types.MinorBlockHeader{Version: 111}, &types.MinorBlockMeta{}, nil, nil, nil)
@@ -478,26 +875,68 @@ func TestGetMinorBlockByHeight(t *testing.T) {
 		Height: 0,
 	}
 	master.UpdateShardStatus(&fakeShardStatus)
-	minorBlock, _, err := master.GetMinorBlockByHeight(nil, account.Branch{Value: 2}, false)
+	minorBlock, _, err := master.GetMinorBlockByHeight(context.Background(), nil, account.Branch{Value: 2}, false)
 
 	assert.NoError(t, err)
 	assert.Equal(t, fakeMinorBlock.Hash(), minorBlock.Hash())
 
-	_, _, err = master.GetMinorBlockByHeight(nil, account.Branch{Value: 2222}, false)
+	_, _, err = master.GetMinorBlockByHeight(context.Background(), nil, account.Branch{Value: 2222}, false)
 	assert.Error(t, err)
 }
 
 func TestGetMinorBlockByHash(t *testing.T) {
 	master := initEnv(t, nil)
 	fakeMinorBlock := types.NewMinorBlock(&types.MinorBlockHeader{Version: 111}, &types.MinorBlockMeta{}, nil, nil, nil)
-	minorBlock, _, err := master.GetMinorBlockByHash(common.Hash{}, account.Branch{Value: 2}, false)
+	minorBlock, _, err := master.GetMinorBlockByHash(context.Background(), common.Hash{}, account.Branch{Value: 2}, false)
 	assert.NoError(t, err)
 	assert.Equal(t, fakeMinorBlock.Hash(), minorBlock.Hash())
 
-	_, _, err = master.GetMinorBlockByHash(common.Hash{}, account.Branch{Value: 2222}, false)
+	_, _, err = master.GetMinorBlockByHash(context.Background(), common.Hash{}, account.Branch{Value: 2222}, false)
 	assert.Error(t, err)
 }
 
+func TestBlockCache(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	master, conns := newMockSlaveBackend(initEnv(t, nil), ctrl, 1)
+	branch := account.Branch{Value: 2}
+	block := types.NewMinorBlock(&types.MinorBlockHeader{Branch: branch, Number: 1}, &types.MinorBlockMeta{}, nil, nil, nil)
+	receipts := types.Receipts{&types.Receipt{CumulativeGasUsed: 21000, Logs: []*types.Log{}}}
+	master.blockCache = newBlockCache(uint64(block.Size()) + 1)
+
+	// the slave is called once for the block and once more for its PoSW info
+	conns[0].EXPECT().GetMinorBlockByHash(gomock.Any(), block.Hash(), branch, false).Return(block, nil, nil)
+	conns[0].EXPECT().GetMinorBlockByHash(gomock.Any(), block.Hash(), branch, true).Return(block, &rpc.PoSWInfo{PoswMinedBlocks: 1}, nil)
+	for i := 0; i < 2; i++ {
+		cached, _, err := master.GetMinorBlockByHash(context.Background(), block.Hash(), branch, false)
+		assert.NoError(t, err)
+		assert.Equal(t, block.Hash(), cached.Hash())
+	}
+	for i := 0; i < 2; i++ {
+		_, posw, err := master.GetMinorBlockByHash(context.Background(), block.Hash(), branch, true)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(1), posw.PoswMinedBlocks)
+	}
+
+	// the block is dropped for the receipts not to exceed the limit
+	conns[0].EXPECT().SupportsOp(uint32(rpc.OpGetMinorBlockReceipts)).Return(true).AnyTimes()
+	conns[0].EXPECT().GetMinorBlockReceipts(gomock.Any(), block.Hash(), branch).Return(receipts, nil)
+	for i := 0; i < 2; i++ {
+		cached, err := master.GetMinorBlockReceipts(context.Background(), block.Hash(), branch)
+		assert.NoError(t, err)
+		assert.Equal(t, receipts, cached)
+	}
+	conns[0].EXPECT().GetMinorBlockByHash(gomock.Any(), block.Hash(), branch, false).Return(block, nil, nil)
+	_, _, err := master.GetMinorBlockByHash(context.Background(), block.Hash(), branch, false)
+	assert.NoError(t, err)
+
+	fields := master.blockCache.metricFields()
+	assert.Equal(t, uint64(2), fields["minor_block_hits"])
+	assert.Equal(t, uint64(3), fields["minor_block_misses"])
+	assert.Equal(t, uint64(1), fields["receipts_hits"])
+	assert.Equal(t, 1, fields["entries"])
+}
+
 func TestGetTransactionByHash(t *testing.T) {
 	master := initEnv(t, nil)
 	id1, err := account.CreatRandomIdentity()
@@ -508,7 +947,7 @@ func TestGetTransactionByHash(t *testing.T) {
 		TxType: types.EvmTx,
 	}
 	fakeMinorBlock := types.NewMinorBlock(&types.MinorBlockHeader{Version: 111}, &types.MinorBlockMeta{}, nil, nil, nil)
-	minorBlock, index, err := master.GetTransactionByHash(tx.Hash(), account.Branch{Value: 2})
+	minorBlock, index, err := master.GetTransactionByHash(context.Background(), tx.Hash(), account.Branch{Value: 2})
 	assert.NoError(t, err)
 	assert.Equal(t, index, uint32(1))
 	assert.Equal(t, fakeMinorBlock.Hash(), minorBlock.Hash())
@@ -524,7 +963,7 @@ func TestGetTransactionReceipt(t *testing.T) {
 		TxType: types.EvmTx,
 	}
 	fakeMinorBlock := types.NewMinorBlock(&types.MinorBlockHeader{Version: 111}, &types.MinorBlockMeta{}, nil, nil, nil)
-	MinorBlock, _, rep, err := master.GetTransactionReceipt(tx.Hash(), account.Branch{Value: 2})
+	MinorBlock, _, rep, err := master.GetTransactionReceipt(context.Background(), tx.Hash(), account.Branch{Value: 2})
 	assert.NoError(t, err)
 	assert.Equal(t, MinorBlock.Hash(), fakeMinorBlock.Hash())
 	assert.Equal(t, rep.CumulativeGasUsed, uint64(123))
@@ -535,7 +974,7 @@ func TestGetTransactionsByAddress(t *testing.T) {
 	id1, err := account.CreatRandomIdentity()
 	assert.NoError(t, err)
 	add1 := account.NewAddress(id1.GetRecipient(), 3)
-	res, bytes, err := master.GetTransactionsByAddress(&add1, []byte{}, 0, nil)
+	res, bytes, err := master.GetTransactionsByAddress(context.Background(), &add1, []byte{}, 0, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, bytes, []byte("qkc"))
 	assert.Equal(t, res[0].TxHash, common.BigToHash(new(big.Int).SetUint64(11)))
@@ -546,7 +985,7 @@ func TestGetLogs(t *testing.T) {
 
 	startBlock := qrpc.BlockNumber(0)
 	endBlock := qrpc.BlockNumber(0)
-	logs, err := master.GetLogs(&qrpc.FilterQuery{
+	logs, err := master.GetLogs(context.Background(), &qrpc.FilterQuery{
 		FullShardId: 2,
 		FilterQuery: eth.FilterQuery{
 			FromBlock: big.NewInt(int64(startBlock)),
@@ -567,7 +1006,7 @@ func TestEstimateGas(t *testing.T) {
 		EvmTx:  evmTx,
 		TxType: types.EvmTx,
 	}
-	data, err := master.EstimateGas(tx, &add1)
+	data, err := master.EstimateGas(context.Background(), tx, &add1)
 	assert.NoError(t, err)
 	if !tx.EvmTx.IsCrossShard() {
 		assert.Equal(t, data, uint32(123))
@@ -580,7 +1019,7 @@ func TestEstimateGas(t *testing.T) {
 		EvmTx:  evmTx,
 		TxType: types.EvmTx,
 	}
-	data, err = master.EstimateGas(tx, &add1)
+	data, err = master.EstimateGas(context.Background(), tx, &add1)
 	assert.Error(t, err)
 }
 
@@ -589,7 +1028,7 @@ func TestGetStorageAt(t *testing.T) {
 	id1, err := account.CreatRandomIdentity()
 	assert.NoError(t, err)
 	add1 := account.NewAddress(id1.GetRecipient(), 3)
-	data, err := master.GetStorageAt(&add1, common.Hash{}, nil)
+	data, err := master.GetStorageAt(context.Background(), &add1, common.Hash{}, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, data.Big().Uint64(), uint64(123))
 }
@@ -599,7 +1038,7 @@ func TestGetCode(t *testing.T) {
 	id1, err := account.CreatRandomIdentity()
 	assert.NoError(t, err)
 	add1 := account.NewAddress(id1.GetRecipient(), 3)
-	data, err := master.GetCode(&add1, nil)
+	data, err := master.GetCode(context.Background(), &add1, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, data, []byte("qkc"))
 
@@ -607,7 +1046,7 @@ func TestGetCode(t *testing.T) {
 
 func TestGasPrice(t *testing.T) {
 	master := initEnv(t, nil)
-	data, err := master.GasPrice(account.Branch{Value: 2}, testGenesisTokenID)
+	data, err := master.GasPrice(context.Background(), account.Branch{Value: 2}, testGenesisTokenID)
 	assert.NoError(t, err)
 	assert.Equal(t, data, uint64(123))
 }
@@ -615,7 +1054,7 @@ func TestGasPrice(t *testing.T) {
 func TestGetWork(t *testing.T) {
 	master := initEnv(t, nil)
 	var id uint32 = 2
-	data, err := master.GetWork(&id, nil)
+	data, err := master.GetWork(context.Background(), &id, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, data.Number, uint64(1))
 }
@@ -623,7 +1062,7 @@ func TestGetWork(t *testing.T) {
 func TestSubmitWork(t *testing.T) {
 	master := initEnv(t, nil)
 	var id uint32 = 2
-	data, err := master.SubmitWork(&id, common.Hash{}, 0, common.Hash{}, nil)
+	data, err := master.SubmitWork(context.Background(), &id, common.Hash{}, 0, common.Hash{}, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, data, true)
 }
@@ -650,7 +1089,7 @@ func TestSubmitWorkForRootChain(t *testing.T) {
 	copy(signature[:], sig[:])
 	nonce := findNonce(master.engine, rootBlock.Header(),
 		new(big.Int).Div(rootBlock.Difficulty(), new(big.Int).SetUint64(1000)))
-	data, err := master.SubmitWork(nil, rootBlock.Header().SealHash(), nonce, common.Hash{}, &signature)
+	data, err := master.SubmitWork(context.Background(), nil, rootBlock.Header().SealHash(), nonce, common.Hash{}, &signature)
 	assert.NoError(t, err)
 	assert.Equal(t, true, data)
 }
@@ -663,3 +1102,159 @@ func findNonce(engine consensus.Engine, header *types.RootBlockHeader, difficalt
 		header.Nonce = header.Nonce + 1
 	}
 }
+
+func TestCoinbaseFromKeyStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keystore")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	ks := keystore.NewKeyStore(dir, keystore.LightScryptN, keystore.LightScryptP)
+	a, err := ks.NewAccount("foo", nil)
+	assert.NoError(t, err)
+	ks.Close()
+
+	monkey.Patch(createDB, func(ctx *service.ServiceContext, name string, clean bool, isReadOnly bool) (ethdb.Database, error) {
+		return service.NewQkcMemoryDB(isReadOnly), nil
+	})
+	clusterConfig := config.NewClusterConfig()
+	clusterConfig.Quarkchain.Root.ConsensusType = config.PoWSimulate
+	clusterConfig.KeyStoreDir = dir
+	master, err := New(&service.ServiceContext{}, clusterConfig)
+	assert.NoError(t, err)
+	defer master.accountManager.Close()
+	assert.Equal(t, a.Address, master.GetDefaultCoinbaseAddress())
+	assert.Equal(t, []keystore.Account{a}, master.AccountManager().Accounts())
+}
+
+func TestProfile(t *testing.T) {
+	chanOp := make(chan uint32, 100)
+	master := initEnv(t, chanOp)
+
+	dir, err := ioutil.TempDir("", "profile")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := dir + "/heap.pprof"
+	assert.NoError(t, master.Profile(context.Background(), "", debug.ProfileWriteHeap, file, 0))
+	_, err = os.Stat(file)
+	assert.NoError(t, err)
+	assert.Error(t, master.Profile(context.Background(), "", "foo", "", 0))
+
+	assert.NoError(t, master.Profile(context.Background(), master.clusterConfig.SlaveList[0].ID, debug.ProfileSetBlockRate, "", 0))
+	for relayed := false; !relayed; {
+		select {
+		case op := <-chanOp:
+			relayed = op == rpc.OpProfile
+		case <-time.After(2 * time.Second):
+			t.Fatal("profiling not relayed to the slave")
+		}
+	}
+	assert.Error(t, master.Profile(context.Background(), "S99", debug.ProfileSetBlockRate, "", 0))
+}
+
+func TestSetLogLevel(t *testing.T) {
+	chanOp := make(chan uint32, 100)
+	master := initEnv(t, chanOp)
+	defer debug.SetLogModules(nil)
+
+	assert.NoError(t, master.SetLogLevel(context.Background(), "p2p", "debug"))
+	assert.Equal(t, map[string]string{"p2p": "debug"}, master.clusterConfig.LogModules)
+	assert.Equal(t, map[string]string{"p2p": "debug"}, debug.LogModuleLevels())
+	for relayed := false; !relayed; {
+		select {
+		case op := <-chanOp:
+			relayed = op == rpc.OpSetLogLevel
+		case <-time.After(2 * time.Second):
+			t.Fatal("log level not relayed to the slaves")
+		}
+	}
+	assert.NoError(t, master.SetLogLevel(context.Background(), "p2p", ""))
+	assert.Empty(t, master.clusterConfig.LogModules)
+	assert.Empty(t, debug.LogModuleLevels())
+
+	assert.NoError(t, master.SetLogLevel(context.Background(), "", "warn"))
+	assert.Equal(t, "warn", master.clusterConfig.LogLevel)
+	assert.NoError(t, master.SetLogLevel(context.Background(), "", "info"))
+	assert.Error(t, master.SetLogLevel(context.Background(), "eth", "debug"))
+	assert.Error(t, master.SetLogLevel(context.Background(), "", "loud"))
+}
+
+func TestHealthChecks(t *testing.T) {
+	master := initEnv(t, nil)
+	checks := func() map[string]bool {
+		ok := make(map[string]bool)
+		for _, check := range master.HealthChecks() {
+			ok[check.Name] = check.OK
+		}
+		return ok
+	}
+	assert.Equal(t, map[string]bool{"db": true, "slaves": true, "sync": true, "peers": true}, checks())
+
+	slaveID := master.clusterConfig.SlaveList[0].ID
+	master.setSlaveDown(slaveID, true)
+	master.clusterConfig.Master.ReadyMinPeers = 1
+	assert.Equal(t, map[string]bool{"db": true, "slaves": false, "sync": true, "peers": false}, checks())
+	master.setSlaveDown(slaveID, false)
+	master.clusterConfig.Master.ReadyMinPeers = 0
+	assert.True(t, checks()["slaves"])
+}
+
+func TestMetricPoints(t *testing.T) {
+	master := initEnv(t, nil)
+	master.UpdateShardStatus(&rpc.ShardStatus{Branch: account.NewBranch(1 << 16), Height: 3, Difficulty: big.NewInt(1000)})
+	points := make(map[string]map[string]interface{})
+	for _, point := range master.metricPoints() {
+		points[point.Name] = point.Fields
+		if point.Name == "shard" {
+			assert.Equal(t, map[string]string{"shard": "65536", "chain": "1"}, point.Tags)
+		}
+	}
+	assert.Contains(t, points, "process")
+	assert.Equal(t, uint64(0), points["root"]["height"])
+	assert.Equal(t, uint64(0), points["root"]["stale_blocks"])
+	assert.Equal(t, uint64(3), points["shard"]["height"])
+	assert.Equal(t, float64(1000), points["shard"]["difficulty"])
+}
+
+func TestAuditLog(t *testing.T) {
+	master := initEnv(t, nil)
+	entries := master.AuditLog(10, audit.TypeSlave)
+	assert.Len(t, entries, len(master.clusterConfig.SlaveList))
+	for i, entry := range entries {
+		assert.Equal(t, "registered", entry.Action)
+		assert.Equal(t, master.clusterConfig.SlaveList[i].ID, entry.Detail["slave"])
+	}
+
+	master.SetConfigLoader(func() (*config.ClusterConfig, error) {
+		return nil, errors.New("bad config")
+	})
+	_, err := master.ReloadConfig(context.Background())
+	assert.Error(t, err)
+	master.Audit("admin_banPeer", map[string]interface{}{"node": "ab"}, nil)
+
+	entries = master.AuditLog(2, "")
+	assert.Equal(t, audit.TypeConfig, entries[0].Type)
+	assert.Equal(t, "bad config", entries[0].Error)
+	assert.Equal(t, audit.TypeAdmin, entries[1].Type)
+	assert.Equal(t, "admin_banPeer", entries[1].Action)
+}
+
+func TestSlowOps(t *testing.T) {
+	master := initEnv(t, nil)
+	slaves := master.clusterConfig.SlaveList
+	ops, err := master.SlowOps(context.Background(), "", 10)
+	assert.NoError(t, err)
+	assert.Len(t, ops, len(slaves))
+	assert.Equal(t, "AddMinorBlockHeader", ops[0].Op)
+
+	ops, err = master.SlowOps(context.Background(), slaves[1].ID, 10)
+	assert.NoError(t, err)
+	assert.Len(t, ops, 1)
+	assert.Equal(t, slaves[1].ID, ops[0].Caller)
+	ops, err = master.SlowOps(context.Background(), "", 1)
+	assert.NoError(t, err)
+	assert.Len(t, ops, 1)
+	ops, err = master.SlowOps(context.Background(), "master", 10)
+	assert.NoError(t, err)
+	assert.Empty(t, ops)
+	_, err = master.SlowOps(context.Background(), "S99", 10)
+	assert.Error(t, err)
+}
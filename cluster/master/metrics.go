@@ -0,0 +1,65 @@
+package master
+
+import (
+	"math/big"
+	"strconv"
+
+	"github.com/QuarkChain/goquarkchain/cluster/metrics"
+)
+
+// metricPoints collects the metrics of the master, the root chain, and the
+// shards reported by the slaves, tagged by their full shard id.
+func (s *QKCMasterBackend) metricPoints() []metrics.Point {
+	tip := s.rootBlockChain.CurrentBlock()
+	staleBlocks, _ := s.rootBlockChain.GetStaleBlocks(0)
+	s.downSlavesLock.RLock()
+	down := len(s.downSlaves)
+	s.downSlavesLock.RUnlock()
+	points := []metrics.Point{
+		metrics.ProcessPoint(),
+		metrics.NewPoint("root", nil, map[string]interface{}{
+			"height":       tip.NumberU64(),
+			"difficulty":   bigFloat(tip.Difficulty()),
+			"timestamp":    tip.Time(),
+			"syncing":      s.synchronizer.IsSyncing(),
+			"stale_blocks": staleBlocks,
+		}),
+		metrics.NewPoint("cluster", nil, map[string]interface{}{
+			"peers":       s.protocolManager.peers.Len(),
+			"slaves":      s.ConnCount() - down,
+			"slaves_down": down,
+			"mining":      s.miner.IsMining(),
+		}),
+	}
+	if s.blockCache != nil {
+		points = append(points, metrics.NewPoint("block_cache", nil, s.blockCache.metricFields()))
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	for fullShardID, stats := range s.branchToShardStats {
+		tags := map[string]string{
+			"shard": strconv.FormatUint(uint64(fullShardID), 10),
+			"chain": strconv.FormatUint(uint64(stats.Branch.GetChainID()), 10),
+		}
+		points = append(points, metrics.NewPoint("shard", tags, map[string]interface{}{
+			"height":                stats.Height,
+			"difficulty":            bigFloat(stats.Difficulty),
+			"tx_count_60s":          stats.TxCount60s,
+			"pending_tx_count":      stats.PendingTxCount,
+			"total_tx_count":        stats.TotalTxCount,
+			"block_count_60s":       stats.BlockCount60s,
+			"stale_block_count_60s": stats.StaleBlockCount60s,
+			"last_block_time":       stats.LastBlockTime,
+		}))
+	}
+	return points
+}
+
+func bigFloat(v *big.Int) float64 {
+	if v == nil {
+		return 0
+	}
+	f, _ := new(big.Float).SetInt(v).Float64()
+	return f
+}
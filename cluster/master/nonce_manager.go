@@ -0,0 +1,370 @@
+package master
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+const (
+	// nonceCheckInterval is how often the accounts with nonces reserved or
+	// in flight are checked against their shard.
+	nonceCheckInterval = 2 * time.Second
+	// nonceReservationTTL is the time after which the nonces reserved and not
+	// sent are reserved again.
+	nonceReservationTTL = 10 * time.Minute
+	// maxNonceReservation bounds the nonces reserved at once.
+	maxNonceReservation = 1024
+	// maxNonceAccounts bounds the accounts managed, the ones used least
+	// recently are forgotten beyond it.
+	maxNonceAccounts = 16384
+	// nonceIdleTimeout is the time after which an account with no nonce
+	// reserved or in flight is forgotten.
+	nonceIdleTimeout = time.Hour
+	// nonceDropChecks is the number of the checks in a row finding the tx of
+	// the next nonce of the pending state neither mined nor executable for it
+	// to be looked up, and dropped if it's not in the pool.
+	nonceDropChecks = 2
+)
+
+var errNonceManagerDisabled = errors.New("nonce manager is disabled, see NONCE_MANAGER")
+
+// nonceManagerBackend is the part of the master the nonce manager reads the
+// accounts and the txs with.
+type nonceManagerBackend interface {
+	GetPrimaryAccountData(ctx context.Context, address *account.Address, blockHeight *uint64) (*rpc.AccountBranchData, error)
+	GetPendingAccountData(ctx context.Context, address *account.Address) (*rpc.AccountBranchData, error)
+	GetTransactionByHash(ctx context.Context, txHash common.Hash, branch account.Branch) (*types.MinorBlock, uint32, error)
+}
+
+type nonceKey struct {
+	recipient   account.Recipient
+	fullShardID uint32
+}
+
+// inFlightTx is a tx sent through the master with a nonce managed.
+type inFlightTx struct {
+	hash   common.Hash
+	checks int // in a row at the head of the pending state
+}
+
+// nonceAccount is the nonces of an account in a shard.
+type nonceAccount struct {
+	address   account.Address // in the shard
+	confirmed uint64
+	next      uint64
+	reserved  map[uint64]time.Time // nonce -> when reserved
+	inFlight  map[uint64]*inFlightTx
+	free      []uint64 // sorted, below next
+	used      time.Time
+}
+
+// reserve reserves count consecutive nonces, or fewer to fill the lowest gap
+// of the free nonces first, and returns the first one and their number.
+func (a *nonceAccount) reserve(count uint64, now time.Time) (uint64, uint64) {
+	a.used = now
+	from, n := a.next, count
+	if len(a.free) > 0 {
+		from, n = a.free[0], 1
+		for n < count && n < uint64(len(a.free)) && a.free[n] == from+n {
+			n++
+		}
+		a.free = a.free[n:]
+	} else {
+		a.next += count
+	}
+	for nonce := from; nonce < from+n; nonce++ {
+		a.reserved[nonce] = now
+	}
+	return from, n
+}
+
+func (a *nonceAccount) addFree(nonce uint64) {
+	i := sort.Search(len(a.free), func(i int) bool { return a.free[i] >= nonce })
+	if i < len(a.free) && a.free[i] == nonce {
+		return
+	}
+	a.free = append(a.free, 0)
+	copy(a.free[i+1:], a.free[i:])
+	a.free[i] = nonce
+}
+
+func (a *nonceAccount) removeFree(nonce uint64) {
+	i := sort.Search(len(a.free), func(i int) bool { return a.free[i] >= nonce })
+	if i < len(a.free) && a.free[i] == nonce {
+		a.free = append(a.free[:i], a.free[i+1:]...)
+	}
+}
+
+// sent records the tx of the nonce sent through the master. The nonces
+// skipped by a tx above the ones reserved are freed.
+func (a *nonceAccount) sent(nonce uint64, hash common.Hash, now time.Time) {
+	if nonce < a.confirmed {
+		return
+	}
+	a.used = now
+	delete(a.reserved, nonce)
+	a.removeFree(nonce)
+	for ; a.next < nonce; a.next++ {
+		a.addFree(a.next)
+	}
+	if a.next == nonce {
+		a.next++
+	}
+	a.inFlight[nonce] = &inFlightTx{hash: hash}
+}
+
+// update forgets the nonces used in the latest state, frees the reservations
+// expired, and returns the tx at the head of the pending state if it has been
+// there for nonceDropChecks checks, to be looked up.
+func (a *nonceAccount) update(confirmed, pending uint64, now time.Time) *inFlightTx {
+	a.confirmed = confirmed
+	if a.next < confirmed {
+		a.next = confirmed
+	}
+	for nonce, reserved := range a.reserved {
+		if nonce < confirmed {
+			delete(a.reserved, nonce)
+		} else if now.Sub(reserved) >= nonceReservationTTL {
+			delete(a.reserved, nonce)
+			a.addFree(nonce)
+		}
+	}
+	for nonce := range a.inFlight {
+		if nonce < confirmed {
+			delete(a.inFlight, nonce)
+		}
+	}
+	i := sort.Search(len(a.free), func(i int) bool { return a.free[i] >= confirmed })
+	a.free = a.free[i:]
+
+	// the txs of the nonces at and above the pending one are not executable,
+	// which they stay behind a tx dropped from the pool
+	tx, ok := a.inFlight[pending]
+	if !ok {
+		return nil
+	}
+	if tx.checks++; tx.checks < nonceDropChecks {
+		return nil
+	}
+	return tx
+}
+
+// nonceManager reserves the nonces of the accounts sending many txs through
+// the master, e.g. the withdrawals of an exchange, so that the txs signed
+// concurrently get consecutive nonces. It follows the txs sent with the
+// nonces until they are mined, and frees the nonces of the txs dropped from
+// the pool and of the reservations expired, to be reserved again first, so
+// that the txs above them are not stuck behind a gap.
+type nonceManager struct {
+	backend nonceManagerBackend
+
+	mu       sync.Mutex
+	accounts *simplelru.LRU // nonceKey -> *nonceAccount
+
+	exitCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+func newNonceManager(backend nonceManagerBackend) *nonceManager {
+	accounts, _ := simplelru.NewLRU(maxNonceAccounts, nil)
+	return &nonceManager{backend: backend, accounts: accounts, exitCh: make(chan struct{})}
+}
+
+func (m *nonceManager) start() {
+	m.wg.Add(1)
+	go m.loop()
+}
+
+func (m *nonceManager) stop() {
+	close(m.exitCh)
+	m.wg.Wait()
+}
+
+func (m *nonceManager) account(key nonceKey) *nonceAccount {
+	if value, ok := m.accounts.Get(key); ok {
+		return value.(*nonceAccount)
+	}
+	return nil
+}
+
+// reserve reserves count consecutive nonces of the address in the shard,
+// starting from the nonce of the next tx of the pending state the first time.
+func (m *nonceManager) reserve(ctx context.Context, address *account.Address, fullShardID uint32, count uint64) (*rpc.NonceReservation, error) {
+	if count == 0 || count > maxNonceReservation {
+		return nil, fmt.Errorf("nonces reserved at once must be between 1 and %d", maxNonceReservation)
+	}
+	key := nonceKey{address.Recipient, fullShardID}
+	m.mu.Lock()
+	acc := m.account(key)
+	m.mu.Unlock()
+	if acc == nil {
+		data, err := m.backend.GetPendingAccountData(ctx, address)
+		if err != nil {
+			return nil, err
+		}
+		m.mu.Lock()
+		if acc = m.account(key); acc == nil {
+			acc = &nonceAccount{
+				address:   *address,
+				confirmed: data.TransactionCount,
+				next:      data.TransactionCount,
+				reserved:  make(map[uint64]time.Time),
+				inFlight:  make(map[uint64]*inFlightTx),
+			}
+			m.accounts.Add(key, acc)
+		}
+		m.mu.Unlock()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	from, n := acc.reserve(count, time.Now())
+	return &rpc.NonceReservation{From: from, Count: n}, nil
+}
+
+// release frees the nonces reserved from the first one, to be reserved again,
+// and returns their number.
+func (m *nonceManager) release(address *account.Address, fullShardID uint32, from, count uint64) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	acc := m.account(nonceKey{address.Recipient, fullShardID})
+	if acc == nil {
+		return 0, errors.New("no nonce reserved for the account")
+	}
+	released := uint64(0)
+	for nonce := from; nonce < from+count && nonce < acc.next; nonce++ {
+		if _, ok := acc.reserved[nonce]; ok {
+			delete(acc.reserved, nonce)
+			acc.addFree(nonce)
+			released++
+		}
+	}
+	return released, nil
+}
+
+// state returns the nonces of the address in the shard.
+func (m *nonceManager) state(address *account.Address, fullShardID uint32) (*rpc.NonceState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	acc := m.account(nonceKey{address.Recipient, fullShardID})
+	if acc == nil {
+		return nil, errors.New("no nonce reserved for the account")
+	}
+	state := &rpc.NonceState{
+		Confirmed: acc.confirmed,
+		Next:      acc.next,
+		Reserved:  make([]uint64, 0, len(acc.reserved)),
+		InFlight:  make([]uint64, 0, len(acc.inFlight)),
+		Free:      append([]uint64{}, acc.free...),
+	}
+	for nonce := range acc.reserved {
+		state.Reserved = append(state.Reserved, nonce)
+	}
+	for nonce := range acc.inFlight {
+		state.InFlight = append(state.InFlight, nonce)
+	}
+	sort.Slice(state.Reserved, func(i, j int) bool { return state.Reserved[i] < state.Reserved[j] })
+	sort.Slice(state.InFlight, func(i, j int) bool { return state.InFlight[i] < state.InFlight[j] })
+	return state, nil
+}
+
+// sent records the tx added to the pool of its shard through the master, if
+// the nonces of its sender are managed.
+func (m *nonceManager) sent(tx *types.Transaction) {
+	evmTx := tx.EvmTx
+	sender, err := types.Sender(types.MakeSigner(evmTx.NetworkId()), evmTx)
+	if err != nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if acc := m.account(nonceKey{sender, evmTx.FromFullShardId()}); acc != nil {
+		acc.sent(evmTx.Nonce(), tx.Hash(), time.Now())
+	}
+}
+
+func (m *nonceManager) loop() {
+	defer m.wg.Done()
+	ticker := time.NewTicker(nonceCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.check(time.Now())
+		case <-m.exitCh:
+			return
+		}
+	}
+}
+
+// check updates the accounts with nonces reserved or in flight from the latest
+// and the pending states of their shards, and frees the nonces of the txs
+// dropped from the pools. The idle accounts are forgotten.
+func (m *nonceManager) check(now time.Time) {
+	m.mu.Lock()
+	keys := m.accounts.Keys()
+	m.mu.Unlock()
+	for _, k := range keys {
+		key := k.(nonceKey)
+		m.mu.Lock()
+		value, ok := m.accounts.Peek(key)
+		if !ok {
+			m.mu.Unlock()
+			continue
+		}
+		acc := value.(*nonceAccount)
+		idle := len(acc.reserved) == 0 && len(acc.inFlight) == 0
+		if idle && now.Sub(acc.used) >= nonceIdleTimeout {
+			m.accounts.Remove(key)
+		}
+		address := acc.address
+		m.mu.Unlock()
+		if idle {
+			continue
+		}
+
+		confirmed, err := m.backend.GetPrimaryAccountData(context.Background(), &address, nil)
+		if err != nil {
+			log.Debug("Failed to check managed nonces", "address", address.ToHex(), "err", err)
+			continue
+		}
+		pending, err := m.backend.GetPendingAccountData(context.Background(), &address)
+		if err != nil {
+			log.Debug("Failed to check managed nonces", "address", address.ToHex(), "err", err)
+			continue
+		}
+		m.mu.Lock()
+		head := acc.update(confirmed.TransactionCount, pending.TransactionCount, now)
+		var hash common.Hash
+		if head != nil {
+			hash = head.hash
+		}
+		m.mu.Unlock()
+		if head == nil {
+			continue
+		}
+
+		block, index, err := m.backend.GetTransactionByHash(context.Background(), hash, account.Branch{Value: key.fullShardID})
+		if err != nil || (block != nil && int(index) < len(block.Transactions()) && block.Transactions()[index].Hash() == hash) {
+			continue
+		}
+		m.mu.Lock()
+		nonce := pending.TransactionCount
+		if tx, ok := acc.inFlight[nonce]; ok && tx.hash == hash {
+			delete(acc.inFlight, nonce)
+			acc.addFree(nonce)
+			log.Info("Freed nonce of dropped tx", "address", address.ToHex(), "nonce", nonce, "tx", hash.Hex())
+		}
+		m.mu.Unlock()
+	}
+}
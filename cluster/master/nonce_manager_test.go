@@ -0,0 +1,133 @@
+package master
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeNonceManagerBackend has a single account, whose txs in the pool are in
+// pool.
+type fakeNonceManagerBackend struct {
+	confirmed uint64
+	pending   uint64
+	pool      map[common.Hash]*types.Transaction
+}
+
+func (b *fakeNonceManagerBackend) GetPrimaryAccountData(ctx context.Context, address *account.Address, blockHeight *uint64) (*rpc.AccountBranchData, error) {
+	return &rpc.AccountBranchData{TransactionCount: b.confirmed}, nil
+}
+
+func (b *fakeNonceManagerBackend) GetPendingAccountData(ctx context.Context, address *account.Address) (*rpc.AccountBranchData, error) {
+	return &rpc.AccountBranchData{TransactionCount: b.pending}, nil
+}
+
+func (b *fakeNonceManagerBackend) GetTransactionByHash(ctx context.Context, txHash common.Hash, branch account.Branch) (*types.MinorBlock, uint32, error) {
+	if tx, ok := b.pool[txHash]; ok {
+		return types.NewMinorBlock(&types.MinorBlockHeader{}, &types.MinorBlockMeta{}, []*types.Transaction{tx}, nil, nil), 0, nil
+	}
+	return nil, 0, nil
+}
+
+func TestNonceManager(t *testing.T) {
+	key, _ := crypto.HexToECDSA("45a915e4d060149eb4365960e6a7a45f334393093061116b197e3240065ff2d8")
+	newTx := func(nonce uint64) *types.Transaction {
+		evmTx := types.NewEvmTransaction(nonce, account.Recipient{}, big.NewInt(0), 100000, big.NewInt(0), 0, 0, 1, 0, nil, 0, 0)
+		evmTx, err := types.SignTx(evmTx, types.MakeSigner(evmTx.NetworkId()), key)
+		assert.NoError(t, err)
+		assert.NoError(t, evmTx.SetFromShardSize(1))
+		return &types.Transaction{EvmTx: evmTx, TxType: types.EvmTx}
+	}
+	evmTx := newTx(0).EvmTx
+	sender, err := types.Sender(types.MakeSigner(evmTx.NetworkId()), evmTx)
+	assert.NoError(t, err)
+	address, fullShardID := account.NewAddress(sender, evmTx.FromFullShardKey()), evmTx.FromFullShardId()
+	backend := &fakeNonceManagerBackend{confirmed: 5, pending: 5, pool: make(map[common.Hash]*types.Transaction)}
+	m := newNonceManager(backend)
+
+	_, err = m.reserve(context.Background(), &address, fullShardID, maxNonceReservation+1)
+	assert.Error(t, err)
+	_, err = m.state(&address, fullShardID)
+	assert.Error(t, err)
+
+	// the reservations start from the pending nonce and follow each other
+	reservation, err := m.reserve(context.Background(), &address, fullShardID, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, &rpc.NonceReservation{From: 5, Count: 3}, reservation)
+	reservation, err = m.reserve(context.Background(), &address, fullShardID, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, &rpc.NonceReservation{From: 8, Count: 2}, reservation)
+
+	// the nonces released are reserved again first
+	released, err := m.release(&address, fullShardID, 8, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), released)
+	reservation, err = m.reserve(context.Background(), &address, fullShardID, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, &rpc.NonceReservation{From: 8, Count: 2}, reservation)
+
+	// a tx above the nonces reserved frees the ones it skips
+	txs := []*types.Transaction{newTx(5), newTx(6), newTx(7), newTx(12)}
+	for _, tx := range txs {
+		backend.pool[tx.Hash()] = tx
+		m.sent(tx)
+	}
+	state, err := m.state(&address, fullShardID)
+	assert.NoError(t, err)
+	assert.Equal(t, &rpc.NonceState{Confirmed: 5, Next: 13, Reserved: []uint64{8, 9},
+		InFlight: []uint64{5, 6, 7, 12}, Free: []uint64{10, 11}}, state)
+
+	// tx5 is mined, and tx6 is dropped from the pool, which is found after it
+	// stays at the head of the pending state for two checks
+	now := time.Now()
+	backend.confirmed, backend.pending = 6, 6
+	delete(backend.pool, txs[0].Hash())
+	m.check(now)
+	state, _ = m.state(&address, fullShardID)
+	assert.Equal(t, []uint64{6, 7, 12}, state.InFlight)
+	delete(backend.pool, txs[1].Hash())
+	m.check(now)
+	state, _ = m.state(&address, fullShardID)
+	assert.Equal(t, uint64(6), state.Confirmed)
+	assert.Equal(t, []uint64{7, 12}, state.InFlight)
+	assert.Equal(t, []uint64{6, 10, 11}, state.Free)
+
+	// a tx still in the pool at the head is kept
+	backend.pending = 7
+	m.check(now)
+	m.check(now)
+	state, _ = m.state(&address, fullShardID)
+	assert.Equal(t, []uint64{7, 12}, state.InFlight)
+
+	// the gaps are filled one at a time, and the reservations expire
+	reservation, err = m.reserve(context.Background(), &address, fullShardID, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, &rpc.NonceReservation{From: 6, Count: 1}, reservation)
+	m.check(time.Now().Add(nonceReservationTTL))
+	state, _ = m.state(&address, fullShardID)
+	assert.Empty(t, state.Reserved)
+	assert.Equal(t, []uint64{6, 8, 9, 10, 11}, state.Free)
+	reservation, err = m.reserve(context.Background(), &address, fullShardID, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, &rpc.NonceReservation{From: 6, Count: 1}, reservation)
+	reservation, err = m.reserve(context.Background(), &address, fullShardID, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, &rpc.NonceReservation{From: 8, Count: 4}, reservation)
+
+	// the txs mined are forgotten, then the idle account
+	backend.confirmed, backend.pending = 13, 13
+	m.check(now)
+	state, _ = m.state(&address, fullShardID)
+	assert.Equal(t, &rpc.NonceState{Confirmed: 13, Next: 13, Reserved: []uint64{}, InFlight: []uint64{}, Free: []uint64{}}, state)
+	m.check(time.Now().Add(nonceIdleTimeout))
+	_, err = m.state(&address, fullShardID)
+	assert.Error(t, err)
+}
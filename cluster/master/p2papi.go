@@ -4,6 +4,7 @@ import (
 	"github.com/QuarkChain/goquarkchain/cluster/rpc"
 	"github.com/QuarkChain/goquarkchain/core/types"
 	"github.com/QuarkChain/goquarkchain/p2p"
+	"github.com/QuarkChain/goquarkchain/serialize"
 	"github.com/pkg/errors"
 )
 
@@ -18,22 +19,22 @@ func NewPrivateP2PAPI(peers *peerSet) *PrivateP2PAPI {
 
 //BroadcastMinorBlock will be called when a minor block first time added to a chain
 func (api *PrivateP2PAPI) BroadcastMinorBlock(res *rpc.P2PRedirectRequest) error {
+	var block *types.MinorBlock
 	for _, peer := range api.peers.Peers() {
-		if peer.id != res.PeerID {
-			peer.AsyncSendNewMinorBlock(res)
+		if peer.id == res.PeerID {
+			continue
 		}
-	}
-	return nil
-}
-
-// BroadcastTransactions only be called when run performance test which the txs
-// are created by shard itself, so broadcast to all the peer
-func (api *PrivateP2PAPI) BroadcastTransactions(txsBatch *rpc.P2PRedirectRequest, peerID string) {
-	for _, peer := range api.peers.Peers() {
-		if peer.id != peerID {
-			peer.AsyncSendTransactions(txsBatch)
+		// the block is decoded once for the peers taking compact blocks
+		if block == nil && peer.takesCompactBlocks() {
+			var newBlock p2p.NewBlockMinor
+			if err := serialize.DeserializeFromBytes(res.Data, &newBlock); err != nil {
+				return err
+			}
+			block = newBlock.Block
 		}
+		peer.AsyncSendNewMinorBlock(res, block)
 	}
+	return nil
 }
 
 func (api *PrivateP2PAPI) BroadcastNewTip(branch uint32, rootBlockHeader *types.RootBlockHeader, minorBlockHeaderList []*types.MinorBlockHeader) error {
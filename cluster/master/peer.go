@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"io/ioutil"
 	"math/big"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/QuarkChain/goquarkchain/cluster/rpc"
@@ -16,6 +18,7 @@ import (
 	"github.com/QuarkChain/goquarkchain/p2p"
 	"github.com/QuarkChain/goquarkchain/p2p/nodefilter"
 	"github.com/QuarkChain/goquarkchain/serialize"
+	mapset "github.com/deckarep/golang-set"
 	"github.com/ethereum/go-ethereum/common"
 )
 
@@ -32,14 +35,14 @@ const (
 	// contain a single transaction, or thousands.
 	maxQueuedTxs = 128
 
+	// maxKnownTxs is the maximum transactions hashes to keep in the known list
+	// before starting to randomly evict them.
+	maxKnownTxs = 32768
+
 	// maxQueuedMinorBlocks is the maximum number of block propagations to queue up before
 	// dropping broadcasts.
 	maxQueuedMinorBlocks = 512
 
-	// maxQueuedTips is the maximum number of block announcements to queue up before
-	// dropping broadcasts.
-	maxQueuedTips = 512
-
 	handshakeTimeout = 5 * time.Second
 
 	requestTimeout = 30 * time.Second
@@ -47,7 +50,13 @@ const (
 
 type newMinorBlock struct {
 	branch uint32
-	block  *types.MinorBlock
+	block  *types.MinorBlock // nil if not decoded, then sent in full
+	data   []byte            // serialized NewBlockMinor of the block
+}
+
+type newTxAnn struct {
+	branch uint32
+	hashes []common.Hash
 }
 
 type newTip struct {
@@ -65,33 +74,40 @@ type Peer struct {
 	rpcId uint64
 
 	*p2p.Peer
-	rw p2p.MsgReadWriter
+	rw    p2p.MsgReadWriter
+	meter *meteredMsgReadWriter
 
 	version  int         // Protocol version negotiated
 	forkDrop *time.Timer // Timed connection dropper if forks aren't validated in time
 
-	head *peerHead
+	head       *peerHead
+	knownTxs   mapset.Set  // Set of transaction hashes known to be known by this peer
+	serveQuota *serveQuota // Limits of the block and header requests served to the peer
 
 	lock             sync.RWMutex
 	chanLock         sync.RWMutex
 	queuedTxs        chan *rpc.P2PRedirectRequest // Queue of transactions to broadcast to the peer
-	queuedMinorBlock chan *rpc.P2PRedirectRequest // Queue of blocks to broadcast to the peer
-	queuedTip        chan newTip                  // Queue of Tips to announce to the peer
+	queuedMinorBlock chan *newMinorBlock          // Queue of blocks to broadcast to the peer
+	queuedAnns       *broadcastQueue              // Queue of Tips and transaction hashes to announce to the peer
 	term             chan struct{}                // Termination channel to stop the broadcaster
 	chans            map[uint64]chan interface{}
 	handleMsgErr     error
 }
 
-func newPeer(version int, p *p2p.Peer, rw p2p.MsgReadWriter) *Peer {
+func newPeer(version int, p *p2p.Peer, rw p2p.MsgReadWriter, serveQuota *serveQuota) *Peer {
+	meter := &meteredMsgReadWriter{MsgReadWriter: rw}
 	return &Peer{
 		Peer:             p,
-		rw:               rw,
+		rw:               meter,
+		meter:            meter,
 		version:          version,
 		id:               fmt.Sprintf("%x", p.ID().Bytes()[:8]),
 		head:             &peerHead{nil, make(map[uint32]*p2p.Tip)},
+		knownTxs:         mapset.NewSet(),
+		serveQuota:       serveQuota,
 		queuedTxs:        make(chan *rpc.P2PRedirectRequest, maxQueuedTxs),
-		queuedMinorBlock: make(chan *rpc.P2PRedirectRequest, maxQueuedMinorBlocks),
-		queuedTip:        make(chan newTip, maxQueuedTips),
+		queuedMinorBlock: make(chan *newMinorBlock, maxQueuedMinorBlocks),
+		queuedAnns:       newBroadcastQueue(),
 		term:             make(chan struct{}),
 		chans:            make(map[uint64]chan interface{}),
 		handleMsgErr:     nil,
@@ -102,6 +118,9 @@ func newPeer(version int, p *p2p.Peer, rw p2p.MsgReadWriter) *Peer {
 // and transaction broadcasts into the remote peer. The goal is to have an async
 // writer that does not lock up node internals.
 func (p *Peer) broadcast() {
+	txAnnTicker := time.NewTicker(txAnnounceInterval)
+	defer txAnnTicker.Stop()
+
 	for {
 		select {
 		case nTxs := <-p.queuedTxs:
@@ -112,19 +131,36 @@ func (p *Peer) broadcast() {
 			}
 			p.Log().Trace("Broadcast transactions", "peerID", nTxs.PeerID, "branch", nTxs.Branch)
 
-		case nBlock := <-p.queuedMinorBlock:
-			if err := p.SendNewMinorBlock(nBlock.Branch, nBlock.Data); err != nil {
-				p.Log().Error("Broadcast minor block failed", "branch", nBlock.Branch, "error", err)
-				return
+		case <-txAnnTicker.C:
+			for _, ann := range p.queuedAnns.takeTxAnns() {
+				if err := p.SendTransactionHashes(ann.branch, ann.hashes); err != nil {
+					p.Log().Error("Announce transactions failed", "branch", ann.branch, "error", err)
+					return
+				}
+				p.Log().Trace("Announce transactions", "branch", ann.branch, "count", len(ann.hashes))
 			}
-			p.Log().Trace("Broadcast minor block", "branch", nBlock.Branch)
 
-		case nTip := <-p.queuedTip:
-			if err := p.SendNewTip(nTip.branch, nTip.tip); err != nil {
+		case nBlock := <-p.queuedMinorBlock:
+			var err error
+			if nBlock.block != nil && p.takesCompactBlocks() {
+				err = p.SendNewCompactMinorBlock(nBlock.branch, nBlock.block)
+			} else {
+				err = p.SendNewMinorBlock(nBlock.branch, nBlock.data)
+			}
+			if err != nil {
+				p.Log().Error("Broadcast minor block failed", "branch", nBlock.branch, "error", err)
 				return
 			}
-			if nTip.branch != 0 {
-				p.Log().Trace("Broadcast new tip", "number", nTip.tip.RootBlockHeader.NumberU64(), "branch", nTip.branch)
+			p.Log().Trace("Broadcast minor block", "branch", nBlock.branch)
+
+		case <-p.queuedAnns.tipReady:
+			for _, nTip := range p.queuedAnns.takeTips() {
+				if err := p.SendNewTip(nTip.branch, nTip.tip); err != nil {
+					return
+				}
+				if nTip.branch != 0 {
+					p.Log().Trace("Broadcast new tip", "number", nTip.tip.RootBlockHeader.NumberU64(), "branch", nTip.branch)
+				}
 			}
 
 		case <-p.term:
@@ -192,6 +228,35 @@ func (p *Peer) PeerID() string {
 	return p.id
 }
 
+// announcesTxs reports whether the peer takes the hashes of transactions in
+// place of the transactions.
+func (p *Peer) announcesTxs() bool {
+	return p.version >= qkc2
+}
+
+// takesCompactBlocks reports whether the peer takes compact blocks in place of
+// the minor blocks.
+func (p *Peer) takesCompactBlocks() bool {
+	return p.version >= qkc3
+}
+
+// MarkTransactions marks transactions as known for the peer, ensuring that
+// they will never be propagated to this particular peer.
+func (p *Peer) MarkTransactions(hashes []common.Hash) {
+	// If we reached the memory allowance, drop a previously known transaction hash
+	for p.knownTxs.Cardinality() > maxKnownTxs-len(hashes) && p.knownTxs.Cardinality() > 0 {
+		p.knownTxs.Pop()
+	}
+	for _, hash := range hashes {
+		p.knownTxs.Add(hash)
+	}
+}
+
+// KnowsTransaction reports whether the peer is known to have the transaction.
+func (p *Peer) KnowsTransaction(hash common.Hash) bool {
+	return p.knownTxs.Contains(hash)
+}
+
 // SendTransactions sends transactions to the peer and includes the hashes
 // in its transaction hash set for future reference.
 func (p *Peer) SendTransactions(p2pTxs *rpc.P2PRedirectRequest) error {
@@ -213,6 +278,27 @@ func (p *Peer) AsyncSendTransactions(txs *rpc.P2PRedirectRequest) {
 	}
 }
 
+// SendTransactionHashes announces the hashes of transactions to the peer,
+// which requests the ones it does not have.
+func (p *Peer) SendTransactionHashes(branch uint32, hashes []common.Hash) error {
+	msg, err := p2p.MakeMsg(p2p.NewTransactionHashesMsg, 0, p2p.Metadata{Branch: branch}, &p2p.NewTransactionHashes{TransactionHashList: hashes})
+	if err != nil {
+		return err
+	}
+	return p.rw.WriteMsg(msg)
+}
+
+// AsyncSendTransactionHashes queues the hashes of transactions to announce to
+// the peer with the others queued within txAnnounceInterval. If the peer's
+// announcement queue is full, the hashes are silently dropped.
+func (p *Peer) AsyncSendTransactionHashes(branch uint32, hashes []common.Hash) {
+	if dropped := p.queuedAnns.addTxAnns(branch, hashes); dropped > 0 {
+		p.Log().Debug("Dropping transaction announcement", "branch", branch, "count", dropped)
+	} else {
+		p.Log().Debug("add transaction hashes to announce queue", "branch", branch)
+	}
+}
+
 // SendNewTip announces the head of each shard or root.
 func (p *Peer) SendNewTip(branch uint32, tip *p2p.Tip) error {
 	msg, err := p2p.MakeMsg(p2p.NewTipMsg, 0, p2p.Metadata{Branch: branch}, tip) //NewTipMsg should rpc=0
@@ -223,14 +309,10 @@ func (p *Peer) SendNewTip(branch uint32, tip *p2p.Tip) error {
 }
 
 // AsyncSendNewTip queues the head block for propagation to a remote peer.
-// If the peer's broadcast queue is full, the event is silently dropped.
+// It replaces the tip of the branch queued before if not sent yet.
 func (p *Peer) AsyncSendNewTip(branch uint32, tip *p2p.Tip) {
-	select {
-	case p.queuedTip <- newTip{branch: branch, tip: tip}:
-		p.Log().Debug("Add new tip to broadcast queue", "", tip.RootBlockHeader.NumberU64(), "branch", branch)
-	default:
-		p.Log().Debug("Dropping new tip", "", tip.RootBlockHeader.NumberU64(), "branch", branch)
-	}
+	p.queuedAnns.addTip(branch, tip)
+	p.Log().Debug("Add new tip to broadcast queue", "", tip.RootBlockHeader.NumberU64(), "branch", branch)
 }
 
 // SendNewMinorBlock propagates an entire minor block to a remote peer.
@@ -242,11 +324,27 @@ func (p *Peer) SendNewMinorBlock(branch uint32, data []byte) error {
 	return p.rw.WriteMsg(msg)
 }
 
-// AsyncSendNewMinorBlock queues an entire minor block for propagation to a remote peer. If
-// the peer's broadcast queue is full, the event is silently dropped.
-func (p *Peer) AsyncSendNewMinorBlock(res *rpc.P2PRedirectRequest) {
+// SendNewCompactMinorBlock propagates a minor block to a remote peer as a
+// compact block, with the transactions the peer is not known to have.
+func (p *Peer) SendNewCompactMinorBlock(branch uint32, block *types.MinorBlock) error {
+	msg, err := p2p.MakeMsg(p2p.NewCompactBlockMinorMsg, 0, p2p.Metadata{Branch: branch}, newCompactBlock(block, p.KnowsTransaction))
+	if err != nil {
+		return err
+	}
+	hashes := make([]common.Hash, len(block.Transactions()))
+	for i, tx := range block.Transactions() {
+		hashes[i] = tx.Hash()
+	}
+	p.MarkTransactions(hashes)
+	return p.rw.WriteMsg(msg)
+}
+
+// AsyncSendNewMinorBlock queues a minor block for propagation to a remote peer,
+// as a compact block if the block is given and the peer takes them, or else in
+// full. If the peer's broadcast queue is full, the event is silently dropped.
+func (p *Peer) AsyncSendNewMinorBlock(res *rpc.P2PRedirectRequest, block *types.MinorBlock) {
 	select {
-	case p.queuedMinorBlock <- res:
+	case p.queuedMinorBlock <- &newMinorBlock{branch: res.Branch, block: block, data: res.Data}:
 		p.Log().Debug("add minor block to broadcast queue", "branch", res.Branch)
 	default:
 		p.Log().Debug("Dropping block propagation", "branch", res.Branch)
@@ -407,6 +505,39 @@ func (p *Peer) GetRootBlockList(hashes []common.Hash) ([]*types.RootBlock, error
 	}
 }
 
+func (p *Peer) requestTransactionList(rpcId uint64, branch uint32, hashes []common.Hash) error {
+	data := p2p.GetTransactionListRequest{TransactionHashList: hashes}
+	msg, err := p2p.MakeMsg(p2p.GetTransactionListRequestMsg, rpcId, p2p.Metadata{Branch: branch}, data)
+	if err != nil {
+		return err
+	}
+	return p.rw.WriteMsg(msg)
+}
+
+// GetTransactionList retrieves the announced transactions of the hashes.
+func (p *Peer) GetTransactionList(branch uint32, hashes []common.Hash) ([]*types.Transaction, error) {
+	rpcId, rpcchan := p.getRpcIdWithChan()
+	defer p.deleteChan(rpcId)
+
+	err := p.requestTransactionList(rpcId, branch, hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := time.NewTimer(txFetchTimeout)
+	defer timeout.Stop()
+	select {
+	case obj := <-rpcchan:
+		if ret, ok := obj.([]*types.Transaction); !ok {
+			panic("invalid return result in GetTransactionList")
+		} else {
+			return ret, nil
+		}
+	case <-timeout.C:
+		return nil, fmt.Errorf("peer %v return GetTransactionList disc Read Time out for rpcid %d", p.id, rpcId)
+	}
+}
+
 // TODO does nothing at the moment
 func (p *Peer) GetNewBlockMinor() (*types.MinorBlock, error) {
 	panic("does nothing at the moment")
@@ -541,6 +672,87 @@ func (p *Peer) readStatus(protoVersion, networkId uint32, genesisRootBlockHash c
 	return nil
 }
 
+// PeerInfo is the QuarkChain protocol metadata of a connected peer.
+type PeerInfo struct {
+	Version    int         `json:"version"`
+	RootNumber uint32      `json:"rootNumber"`
+	RootHash   common.Hash `json:"rootHash"`
+	ChainMasks []uint32    `json:"chainMasks,omitempty"` // advertised in the node record, if known
+	Shards     []uint32    `json:"shards"`               // full shard IDs the peer announced tips of
+	Traffic    TrafficInfo `json:"traffic"`
+}
+
+// TrafficInfo counts the messages exchanged with a peer since it connected.
+type TrafficInfo struct {
+	IngressMsgs  uint64 `json:"ingressMsgs"`
+	IngressBytes uint64 `json:"ingressBytes"`
+	EgressMsgs   uint64 `json:"egressMsgs"`
+	EgressBytes  uint64 `json:"egressBytes"`
+}
+
+// Info returns the protocol metadata of the peer, nil until the handshake is
+// done.
+func (p *Peer) Info() *PeerInfo {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	if p.head.rootTip == nil {
+		return nil
+	}
+	info := &PeerInfo{
+		Version:    p.version,
+		RootNumber: p.head.rootTip.Number,
+		RootHash:   p.head.rootTip.Hash(),
+		Shards:     make([]uint32, 0, len(p.head.minorTips)),
+		Traffic:    p.meter.traffic(),
+	}
+	var entry shardsEntry
+	if err := p.Node().Load(&entry); err == nil {
+		info.ChainMasks = entry.ChainMasks
+	}
+	for branch := range p.head.minorTips {
+		info.Shards = append(info.Shards, branch)
+	}
+	sort.Slice(info.Shards, func(i, j int) bool { return info.Shards[i] < info.Shards[j] })
+	return info
+}
+
+// meteredMsgReadWriter counts the messages read and written through it.
+type meteredMsgReadWriter struct {
+	ingressMsgs  uint64 // accessed atomically, 64-bit aligned first
+	ingressBytes uint64
+	egressMsgs   uint64
+	egressBytes  uint64
+	p2p.MsgReadWriter
+}
+
+func (rw *meteredMsgReadWriter) ReadMsg() (p2p.Msg, error) {
+	msg, err := rw.MsgReadWriter.ReadMsg()
+	if err == nil {
+		atomic.AddUint64(&rw.ingressMsgs, 1)
+		atomic.AddUint64(&rw.ingressBytes, uint64(msg.Size))
+	}
+	return msg, err
+}
+
+func (rw *meteredMsgReadWriter) WriteMsg(msg p2p.Msg) error {
+	err := rw.MsgReadWriter.WriteMsg(msg)
+	if err == nil {
+		atomic.AddUint64(&rw.egressMsgs, 1)
+		atomic.AddUint64(&rw.egressBytes, uint64(msg.Size))
+	}
+	return err
+}
+
+func (rw *meteredMsgReadWriter) traffic() TrafficInfo {
+	return TrafficInfo{
+		IngressMsgs:  atomic.LoadUint64(&rw.ingressMsgs),
+		IngressBytes: atomic.LoadUint64(&rw.ingressBytes),
+		EgressMsgs:   atomic.LoadUint64(&rw.egressMsgs),
+		EgressBytes:  atomic.LoadUint64(&rw.egressBytes),
+	}
+}
+
 // String implements fmt.Stringer.
 func (p *Peer) String() string {
 	return fmt.Sprintf("Peer %s [%s]", p.id,
@@ -0,0 +1,171 @@
+package master
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/QuarkChain/goquarkchain/cluster/audit"
+	"github.com/QuarkChain/goquarkchain/cluster/config"
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// SetConfigLoader sets the function loading the cluster config reloaded by
+// ReloadConfig.
+func (s *QKCMasterBackend) SetConfigLoader(loader func() (*config.ClusterConfig, error)) {
+	s.configLoader = loader
+}
+
+// ReloadConfig reloads the cluster config, applies the settings which are safe
+// to change while running, and asks the slaves to reload their configs. It
+// returns the changes applied to the master, the other settings changed take
+// effect after a restart.
+func (s *QKCMasterBackend) ReloadConfig(ctx context.Context) ([]config.ConfigChange, error) {
+	if s.configLoader == nil {
+		return nil, errors.New("config reload is not supported")
+	}
+	cfg, err := s.configLoader()
+	if err != nil {
+		s.audit(audit.TypeConfig, "reload", nil, err)
+		return nil, err
+	}
+	changes, err := s.applyConfig(cfg)
+	s.audit(audit.TypeConfig, "reload", map[string]interface{}{"changes": changes}, err)
+	if err != nil {
+		return changes, err
+	}
+
+	err = fanOutAllSlaves(ctx, supporting(s.GetSlaveConns(), rpc.OpReloadConfig), func(ctx context.Context, conn rpc.ISlaveConn) error {
+		return conn.ReloadConfig(ctx)
+	})
+	if err != nil {
+		return changes, fmt.Errorf("failed to reload config: %v", err)
+	}
+	return changes, nil
+}
+
+// SetLogLevel sets the verbosity of the cluster if module is empty, or else the
+// log level of the module, on the master and the slaves.
+func (s *QKCMasterBackend) SetLogLevel(ctx context.Context, module, level string) error {
+	s.reloadLock.Lock()
+	err := s.clusterConfig.SetLogLevel(module, level)
+	s.reloadLock.Unlock()
+	if err != nil {
+		return err
+	}
+	log.Info("Log level set", "module", module, "level", level)
+
+	err = fanOutAllSlaves(ctx, supporting(s.GetSlaveConns(), rpc.OpSetLogLevel), func(ctx context.Context, conn rpc.ISlaveConn) error {
+		return conn.SetLogLevel(ctx, module, level)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set log level: %v", err)
+	}
+	return nil
+}
+
+// applyConfig applies the changes of the reloaded config to the running one,
+// the slaves at a new endpoint are checked first, and initialized as they
+// have been restarted there.
+func (s *QKCMasterBackend) applyConfig(cfg *config.ClusterConfig) ([]config.ConfigChange, error) {
+	s.reloadLock.Lock()
+	defer s.reloadLock.Unlock()
+
+	running := s.clusterConfig
+	changes, restart, err := running.ReloadChanges(cfg)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range restart {
+		log.Warn("Config change requires a restart", "setting", path)
+	}
+
+	applied := make([]config.ConfigChange, 0, len(changes))
+	for _, change := range changes {
+		setting, key := config.SplitSetting(change.Setting)
+		if setting != config.SettingSlaveEndpoint {
+			continue
+		}
+		err := s.moveSlave(key, change.Old.(string), change.New.(string))
+		s.audit(audit.TypeSlave, "moved", map[string]interface{}{"slave": key, "old": change.Old, "new": change.New}, err)
+		if err != nil {
+			return applied, err
+		}
+		slave, _ := running.GetSlaveConfig(key)
+		reloaded, _ := cfg.GetSlaveConfig(key)
+		slave.IP, slave.Port = reloaded.IP, reloaded.Port
+		applied = append(applied, change)
+		log.Info("Config reloaded", "setting", change.Setting, "old", change.Old, "new", change.New)
+	}
+	s.slowOps.SetPeers(running.SlaveList)
+
+	for _, change := range changes {
+		setting, key := config.SplitSetting(change.Setting)
+		switch setting {
+		case config.SettingLogLevel:
+			if err := config.ApplyLogLevel(cfg.LogLevel); err != nil {
+				return applied, err
+			}
+			running.LogLevel = cfg.LogLevel
+		case config.SettingLogModules:
+			if err := config.ApplyLogModules(cfg.LogModules); err != nil {
+				return applied, err
+			}
+			running.LogModules = cfg.LogModules
+		case config.SettingRPCTxListLimit:
+			atomic.StoreUint32(&running.RPCTxListLimit, cfg.RPCTxListLimit)
+		case config.SettingSlowRPCThreshold:
+			s.slowOps.SetThreshold(cfg.SlowRPCThreshold)
+			running.SlowRPCThreshold = cfg.SlowRPCThreshold
+		case config.SettingTxPool:
+			// the tx pools are resized by the slaves
+			running.TxPool = cfg.TxPool
+		case config.SettingRootCoinbase:
+			s.coinbaseLock.Lock()
+			running.Quarkchain.Root.CoinbaseAddress = cfg.Quarkchain.Root.CoinbaseAddress
+			s.coinbaseLock.Unlock()
+		case config.SettingChainCoinbase:
+			chainID, err := strconv.ParseUint(key, 10, 32)
+			if err != nil {
+				return applied, err
+			}
+			running.Quarkchain.SetChainCoinbase(uint32(chainID), cfg.Quarkchain.Chains[uint32(chainID)].CoinbaseAddress)
+		case config.SettingChainGasTarget:
+			chainID, err := strconv.ParseUint(key, 10, 32)
+			if err != nil {
+				return applied, err
+			}
+			running.Quarkchain.SetChainGasLimitTarget(uint32(chainID), cfg.Quarkchain.Chains[uint32(chainID)].GasLimitTarget)
+		default:
+			continue
+		}
+		applied = append(applied, change)
+		log.Info("Config reloaded", "setting", change.Setting, "old", change.Old, "new", change.New)
+	}
+	return applied, nil
+}
+
+// moveSlave points the connection to the slave to its new endpoint, where it
+// must answer the ping of the master, and sends it the master info.
+func (s *QKCMasterBackend) moveSlave(id, old, target string) error {
+	for _, conn := range s.GetSlaveConns() {
+		if conn.GetSlaveID() != id {
+			continue
+		}
+		conn.SetTarget(target)
+		slaveID, chainMaskList, err := conn.SendPing()
+		if err == nil {
+			err = checkPing(conn, slaveID, chainMaskList)
+		}
+		if err != nil {
+			conn.SetTarget(old)
+			return fmt.Errorf("failed to ping slave %s at %s: %v", id, target, err)
+		}
+		ip, port := s.clusterConfig.Quarkchain.GRPCHost, s.clusterConfig.Quarkchain.GRPCPort
+		return conn.MasterInfo(ip, port, s.rootBlockChain.CurrentBlock())
+	}
+	return fmt.Errorf("slave %s is not connected", id)
+}
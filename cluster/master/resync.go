@@ -0,0 +1,182 @@
+package master
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// resyncInterval is the interval the resync of the restored shards is retried
+// at, e.g. until a peer is connected.
+const resyncInterval = 10 * time.Second
+
+// resyncLoop resyncs the shards restored from a backup or bootstrapped from a
+// snapshot until they are at the root tip.
+func (s *QKCMasterBackend) resyncLoop() {
+	s.resyncLock.Lock()
+	defer s.resyncLock.Unlock()
+	for {
+		err := s.resyncShards()
+		if err == nil {
+			return
+		}
+		log.Warn("Failed to resync restored shards, will retry", "err", err)
+		select {
+		case <-s.exitCh:
+			return
+		case <-time.After(resyncInterval):
+		}
+	}
+}
+
+// resyncShards adds the root blocks since the backup to the shards restored
+// from a backup, once their own minor blocks confirmed by the root blocks are
+// downloaded from a peer and the other shards sent the xshard tx lists of
+// their blocks again. The shards are resynced together, as the restored
+// shards need the xshard tx lists of each other.
+func (s *QKCMasterBackend) resyncShards() error {
+	for {
+		rootTip := s.rootBlockChain.CurrentBlock()
+		var behind []*restoredShard
+		from := rootTip.Number() + 1
+		// the slaves of an older version don't restore their shards
+		for _, conn := range supporting(s.GetSlaveConns(), rpc.OpGetShardRootTips) {
+			tips, err := conn.GetShardRootTips(context.Background())
+			if err != nil {
+				return err
+			}
+			for _, tip := range tips {
+				if !tip.Restored || tip.Number >= rootTip.Number() {
+					continue
+				}
+				block := s.rootBlockChain.GetBlockByNumber(uint64(tip.Number))
+				if block == nil || block.Hash() != tip.Hash {
+					return fmt.Errorf("root block %d of restored shard %d is not canonical", tip.Number, tip.Branch)
+				}
+				behind = append(behind, &restoredShard{conn: conn, tip: tip})
+				if tip.Number+1 < from {
+					from = tip.Number + 1
+				}
+			}
+		}
+		if len(behind) == 0 {
+			return nil
+		}
+		log.Info("Resync restored shards", "shards", len(behind), "from", from, "to", rootTip.Number())
+		for number := from; number <= rootTip.Number(); number++ {
+			rBlock, ok := s.rootBlockChain.GetBlockByNumber(uint64(number)).(*types.RootBlock)
+			if !ok {
+				return fmt.Errorf("root block %d not found", number)
+			}
+			if err := s.resyncRootBlock(rBlock, behind); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// restoredShard is a shard restored from a backup or bootstrapped from a
+// snapshot which is behind the root tip, and the slave running it, as another
+// slave running the shard may not be.
+type restoredShard struct {
+	conn rpc.ISlaveConn
+	tip  *rpc.ShardRootTip
+}
+
+func (s *QKCMasterBackend) resyncRootBlock(rBlock *types.RootBlock, behind []*restoredShard) error {
+	hashLists := make(map[uint32][]common.Hash)
+	for _, header := range rBlock.MinorBlockHeaders() {
+		hashLists[header.Branch.Value] = append(hashLists[header.Branch.Value], header.Hash())
+	}
+	conns := make(map[string]rpc.ISlaveConn)
+	for _, shard := range behind {
+		branch, conn := shard.tip.Branch, shard.conn
+		if shard.tip.Number >= rBlock.Number() {
+			continue
+		}
+		conns[conn.GetSlaveID()] = conn
+		if len(hashLists[branch]) == 0 {
+			continue
+		}
+		// only the blocks the shard lacks are downloaded, and their xshard tx
+		// lists are sent to the other shards
+		peerID := ""
+		if peer := s.protocolManager.peers.BestPeer(); peer != nil {
+			peerID = peer.PeerID()
+		}
+		req := &rpc.AddBlockListForSyncRequest{Branch: branch, PeerId: peerID, MinorBlockHashList: hashLists[branch]}
+		if _, err := conn.AddBlockListForSync(context.Background(), req); err != nil {
+			return err
+		}
+	}
+	for branch, hashList := range hashLists {
+		// the lists of the blocks just downloaded were sent, but not the ones
+		// of the blocks the shard had, which another restored shard may lack
+		if len(behind) == 1 && behind[0].tip.Branch == branch && behind[0].tip.Number < rBlock.Number() {
+			continue
+		}
+		if err := s.resendXshardTxList(account.Branch{Value: branch}, hashList); err != nil {
+			return err
+		}
+	}
+	for _, conn := range conns {
+		if err := conn.AddRootBlock(context.Background(), rBlock, false); err != nil {
+			return err
+		}
+	}
+	for _, shard := range behind {
+		if shard.tip.Number < rBlock.Number() {
+			shard.tip.Number, shard.tip.Hash = rBlock.Number(), rBlock.Hash()
+		}
+	}
+	return nil
+}
+
+// resendXshardTxList asks a slave running the shard to send the xshard tx
+// lists of the blocks again, trying the next one if a slave fails, e.g. as it
+// is still bootstrapping the shard.
+func (s *QKCMasterBackend) resendXshardTxList(branch account.Branch, hashList []common.Hash) error {
+	err := ErrNoBranchConn
+	for _, conn := range supporting(s.GetSlaveConnsById(branch.Value), rpc.OpResendXshardTxList) {
+		if err = conn.ResendXshardTxList(context.Background(), branch, hashList); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// openShardSnapshot returns a snapshot of the shard taken at the root block
+// with rootHash, streamed by the first slave running the shard to serve it,
+// for a new slave to bootstrap the shard from, see shard.WriteSnapshot.
+func (s *QKCMasterBackend) openShardSnapshot(branch account.Branch, rootHash common.Hash) (io.ReadCloser, error) {
+	err := ErrNoBranchConn
+	for _, conn := range supporting(s.GetSlaveConnsById(branch.Value), rpc.OpGetShardSnapshot) {
+		var r io.ReadCloser
+		if r, err = conn.GetShardSnapshot(branch, rootHash); err != nil {
+			continue
+		}
+		// the slave fails before it writes the snapshot, e.g. the new slave
+		// itself, as it doesn't have the shard yet
+		br := bufio.NewReader(r)
+		if _, err = br.Peek(1); err != nil {
+			r.Close()
+			log.Debug("Slave failed to serve shard snapshot", "slave", conn.GetSlaveID(), "shard", branch.Value, "err", err)
+			continue
+		}
+		return &snapshotReader{Reader: br, Closer: r}, nil
+	}
+	return nil, err
+}
+
+type snapshotReader struct {
+	io.Reader
+	io.Closer
+}
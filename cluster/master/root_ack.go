@@ -0,0 +1,129 @@
+package master
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/core/rawdb"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// The master stores the last root block added by each slave, for the slaves
+// which missed root blocks, e.g. down while they were broadcast or restarted
+// with the master, to be sent them before they are routed to again. Otherwise
+// their shards would keep running on an old root block.
+
+// routedSlaveConns returns the slaves not lagging, which the root blocks are
+// broadcast to.
+func (s *QKCMasterBackend) routedSlaveConns() []rpc.ISlaveConn {
+	s.rootAckLock.Lock()
+	defer s.rootAckLock.Unlock()
+	conns := make([]rpc.ISlaveConn, 0, s.ConnCount())
+	for _, conn := range s.GetSlaveConns() {
+		if !s.isSlaveLagging(conn.GetSlaveID()) {
+			conns = append(conns, conn)
+		}
+	}
+	return conns
+}
+
+// writeSlaveRootAck stores the root block as the last one added by the slave,
+// unless a higher canonical one is stored, e.g. by a broadcast which returned
+// before the catch up of the slave did.
+func (s *QKCMasterBackend) writeSlaveRootAck(id string, block *types.RootBlock) {
+	s.rootAckLock.Lock()
+	defer s.rootAckLock.Unlock()
+	s.writeSlaveRootAckLocked(id, block)
+}
+
+func (s *QKCMasterBackend) writeSlaveRootAckLocked(id string, block *types.RootBlock) {
+	if hash := rawdb.ReadSlaveRootAck(s.chainDb, id); hash != (common.Hash{}) {
+		stored := s.rootBlockChain.GetHeader(hash)
+		if stored != nil && stored.NumberU64() >= block.NumberU64() {
+			// a block of a fork given up is replaced
+			if canonical := s.rootBlockChain.GetBlockByNumber(stored.NumberU64()); canonical != nil && canonical.Hash() == hash {
+				return
+			}
+		}
+	}
+	rawdb.WriteSlaveRootAck(s.chainDb, id, block.Hash())
+}
+
+// slaveRootBase returns the last canonical root block added by the slave, or
+// nil if unknown.
+func (s *QKCMasterBackend) slaveRootBase(id string) *types.RootBlock {
+	hash := rawdb.ReadSlaveRootAck(s.chainDb, id)
+	if hash == (common.Hash{}) {
+		return nil
+	}
+	block, _ := s.rootBlockChain.GetBlock(hash).(*types.RootBlock)
+	// back to the canonical chain if the root chain reorganized since
+	for block != nil {
+		if canonical := s.rootBlockChain.GetBlockByNumber(block.NumberU64()); canonical != nil && canonical.Hash() == block.Hash() {
+			return block
+		}
+		block, _ = s.rootBlockChain.GetBlock(block.ParentHash()).(*types.RootBlock)
+	}
+	return nil
+}
+
+// initSlave sends the master info to the slave with the last root block it
+// added, or the root tip if unknown, and then the root blocks it missed.
+func (s *QKCMasterBackend) initSlave(conn rpc.ISlaveConn) error {
+	base := s.slaveRootBase(conn.GetSlaveID())
+	if base == nil {
+		base = s.rootBlockChain.CurrentBlock()
+	} else if base.Hash() != s.rootBlockChain.CurrentBlock().Hash() {
+		log.Warn("Slave missed root blocks", "slave", conn.GetSlaveID(), "from", base.NumberU64()+1)
+		s.setSlaveLagging(conn.GetSlaveID(), true)
+	}
+	ip, port := s.clusterConfig.Quarkchain.GRPCHost, s.clusterConfig.Quarkchain.GRPCPort
+	if err := conn.MasterInfo(ip, port, base); err != nil {
+		return err
+	}
+	return s.catchUpSlave(conn, base.NumberU64())
+}
+
+// catchUpSlave adds the canonical root blocks above the height to the slave up
+// to the root tip, and routes to it again once it's at the tip.
+func (s *QKCMasterBackend) catchUpSlave(conn rpc.ISlaveConn, number uint64) error {
+	for number++; ; number++ {
+		s.rootAckLock.Lock()
+		tip := s.rootBlockChain.CurrentBlock()
+		if number > tip.NumberU64() {
+			s.writeSlaveRootAckLocked(conn.GetSlaveID(), tip)
+			if s.isSlaveLagging(conn.GetSlaveID()) {
+				log.Info("Slave caught up with root tip", "slave", conn.GetSlaveID(), "height", tip.NumberU64())
+				s.setSlaveLagging(conn.GetSlaveID(), false)
+			}
+			s.rootAckLock.Unlock()
+			return nil
+		}
+		s.rootAckLock.Unlock()
+
+		block, ok := s.rootBlockChain.GetBlockByNumber(number).(*types.RootBlock)
+		if !ok {
+			return fmt.Errorf("root block %d not found", number)
+		}
+		if err := conn.AddRootBlock(context.Background(), block, false); err != nil {
+			return err
+		}
+		s.writeSlaveRootAck(conn.GetSlaveID(), block)
+	}
+}
+
+// retryCatchUpSlave sends the lagging slave the root blocks it missed since
+// the last one it added, retried on each heartbeat until it succeeds.
+func (s *QKCMasterBackend) retryCatchUpSlave(conn rpc.ISlaveConn) {
+	base := s.slaveRootBase(conn.GetSlaveID())
+	if base == nil {
+		log.Error("Unknown last root block of lagging slave", "slave", conn.GetSlaveID())
+		return
+	}
+	if err := s.catchUpSlave(conn, base.NumberU64()); err != nil {
+		log.Warn("Failed to catch up lagging slave, will retry", "slave", conn.GetSlaveID(), "from", base.NumberU64()+1, "err", err)
+	}
+}
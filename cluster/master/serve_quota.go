@@ -0,0 +1,145 @@
+package master
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/cluster/config"
+	"github.com/QuarkChain/goquarkchain/p2p"
+)
+
+const (
+	// defaultServeRequestRate is the number of block and header requests
+	// served to a peer per second by default, up to defaultServeRequestBurst
+	// at once, well above what a syncing peer requests.
+	defaultServeRequestRate  = 100
+	defaultServeRequestBurst = 400
+
+	// serveBytesRate is the number of response bytes served to a peer per
+	// second. The responses over it are delayed rather than refused.
+	serveBytesRate = 8 * 1024 * 1024
+
+	// maxPendingServes is the number of requests of a peer being served at
+	// once, over which the peer is too slow to read its responses.
+	maxPendingServes = 16
+)
+
+var (
+	errServeRateExceeded = errors.New("too many block and header requests")
+	errSlowPeer          = errors.New("too many block and header requests pending")
+)
+
+// serveQuota limits the block and header requests served to a peer, so that a
+// peer can't make the node read its whole DB repeatedly.
+type serveQuota struct {
+	rate  float64 // requests served per second
+	burst float64 // requests served at once
+
+	mu       sync.Mutex
+	requests float64 // requests left to serve
+	bytes    float64 // response bytes left to serve, negative if in debt
+	last     time.Time
+	pending  int
+}
+
+// newServeQuota returns the quota of a peer with the request limits of the p2p
+// config, or the default ones if unset.
+func newServeQuota(cfg *config.P2PConfig) *serveQuota {
+	rate, burst := float64(defaultServeRequestRate), float64(defaultServeRequestBurst)
+	if cfg != nil && cfg.ServeRequestRate != 0 {
+		rate = float64(cfg.ServeRequestRate)
+	}
+	if cfg != nil && cfg.ServeRequestBurst != 0 {
+		burst = float64(cfg.ServeRequestBurst)
+	}
+	return &serveQuota{
+		rate:     rate,
+		burst:    burst,
+		requests: burst,
+		bytes:    serveBytesRate,
+		last:     time.Now(),
+	}
+}
+
+// acquire accounts for a new request, which must be released once served.
+func (q *serveQuota) acquire() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	elapsed := now.Sub(q.last).Seconds()
+	q.last = now
+	q.requests += elapsed * q.rate
+	if q.requests > q.burst {
+		q.requests = q.burst
+	}
+	q.bytes += elapsed * serveBytesRate
+	if q.bytes > serveBytesRate {
+		q.bytes = serveBytesRate
+	}
+
+	if q.pending >= maxPendingServes {
+		return errSlowPeer
+	}
+	if q.requests < 1 {
+		return errServeRateExceeded
+	}
+	q.requests--
+	q.pending++
+	return nil
+}
+
+// reserve takes size bytes of response and returns how long to wait before
+// sending it.
+func (q *serveQuota) reserve(size uint32) time.Duration {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.bytes -= float64(size)
+	if q.bytes >= 0 {
+		return 0
+	}
+	return time.Duration(-q.bytes / serveBytesRate * float64(time.Second))
+}
+
+func (q *serveQuota) release() {
+	q.mu.Lock()
+	q.pending--
+	q.mu.Unlock()
+}
+
+// serve sends the response of a block or header request of the peer within
+// its serving quota. It returns an error if the peer makes requests too fast
+// or does not read its responses, or if the response can't be made.
+func (p *Peer) serve(async bool, respond func() (p2p.Msg, error)) error {
+	if err := p.serveQuota.acquire(); err != nil {
+		return err
+	}
+	run := func() error {
+		defer p.serveQuota.release()
+		msg, err := respond()
+		if err != nil {
+			return err
+		}
+		if delay := p.serveQuota.reserve(msg.Size); delay > 0 {
+			timer := time.NewTimer(delay)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+			case <-p.term:
+				return p2p.DiscQuitting
+			}
+		}
+		return p.rw.WriteMsg(msg)
+	}
+	if !async {
+		return run()
+	}
+	go func() {
+		if err := run(); err != nil {
+			p.handleMsgErr = err
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,45 @@
+package master
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/cluster/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServeQuota(t *testing.T) {
+	q := newServeQuota(nil)
+	for i := 0; i < maxPendingServes; i++ {
+		assert.NoError(t, q.acquire())
+	}
+	// the peer does not read its responses
+	assert.Equal(t, errSlowPeer, q.acquire())
+	for i := 0; i < maxPendingServes; i++ {
+		q.release()
+	}
+
+	for i := maxPendingServes; i < defaultServeRequestBurst; i++ {
+		assert.NoError(t, q.acquire())
+		q.release()
+	}
+	// the peer makes requests too fast
+	assert.Equal(t, errServeRateExceeded, q.acquire())
+	q.last = q.last.Add(-time.Second / defaultServeRequestRate)
+	assert.NoError(t, q.acquire())
+	q.release()
+	assert.Equal(t, errServeRateExceeded, q.acquire())
+
+	// the responses over the byte rate are delayed
+	assert.Equal(t, time.Duration(0), q.reserve(serveBytesRate))
+	delay := q.reserve(serveBytesRate / 2)
+	assert.True(t, delay > 400*time.Millisecond && delay <= 500*time.Millisecond, "delay %v", delay)
+
+	// the limits are configurable
+	q = newServeQuota(&config.P2PConfig{ServeRequestRate: 1, ServeRequestBurst: 2})
+	assert.NoError(t, q.acquire())
+	assert.NoError(t, q.acquire())
+	assert.Equal(t, errServeRateExceeded, q.acquire())
+	q.last = q.last.Add(-time.Second)
+	assert.NoError(t, q.acquire())
+}
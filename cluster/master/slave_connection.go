@@ -1,9 +1,15 @@
 package master
 
 import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"math/big"
+	"sort"
 	"sync"
 	"time"
 
@@ -11,30 +17,55 @@ import (
 	"github.com/QuarkChain/goquarkchain/cluster/config"
 	"github.com/QuarkChain/goquarkchain/cluster/rpc"
 	"github.com/QuarkChain/goquarkchain/consensus"
+	"github.com/QuarkChain/goquarkchain/core"
 	"github.com/QuarkChain/goquarkchain/core/types"
 	qrpc "github.com/QuarkChain/goquarkchain/rpc"
 	"github.com/QuarkChain/goquarkchain/serialize"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
 )
 
+// SlaveConnCreator creates the connection of the master to a slave.
+type SlaveConnCreator func(target string, shardMaskList []*types.ChainMask, slaveID string, genesisHash common.Hash) rpc.ISlaveConn
+
 type SlaveConnManager struct {
 	count              int
 	clientPool         []rpc.ISlaveConn
 	branchToSlaveConns map[uint32][]rpc.ISlaveConn
+	newSlaveConn       SlaveConnCreator
+	lagging            map[string]bool // slaves missing root blocks, out of the routing
+	laggingLock        sync.RWMutex
 	logInfo            string
 }
 
-func (s *SlaveConnManager) InitConnManager(cfg *config.ClusterConfig) error {
+// SetSlaveConnCreator replaces NewSlaveConn creating the connections to the
+// slaves, e.g. by mocks in tests. It must be called before InitConnManager.
+func (s *SlaveConnManager) SetSlaveConnCreator(creator SlaveConnCreator) {
+	s.newSlaveConn = creator
+}
+
+// InitConnManager connects to the slaves of cfg, logging the slow ops of the
+// connections created by NewSlaveConn to slowOps.
+func (s *SlaveConnManager) InitConnManager(cfg *config.ClusterConfig, slowOps *rpc.SlowOpLog) error {
 	s.clientPool = make([]rpc.ISlaveConn, 0, len(cfg.SlaveList))
 	s.branchToSlaveConns = make(map[uint32][]rpc.ISlaveConn)
 	s.logInfo = "slave connection manager"
 
+	genesisHash, err := core.NewGenesis(cfg.Quarkchain).ClusterGenesisHash()
+	if err != nil {
+		return err
+	}
+	newSlaveConn := s.newSlaveConn
+	if newSlaveConn == nil {
+		newSlaveConn = func(target string, shardMaskList []*types.ChainMask, slaveID string, genesisHash common.Hash) rpc.ISlaveConn {
+			return NewSlaveConn(target, shardMaskList, slaveID, genesisHash, slowOps)
+		}
+	}
 	fullShardIds := cfg.Quarkchain.GetGenesisShardIds()
 	for _, cfg := range cfg.SlaveList {
 		target := fmt.Sprintf("%s:%d", cfg.IP, cfg.Port)
-		client := NewSlaveConn(target, cfg.ChainMaskList, cfg.ID)
-		s.clientPool = append(s.clientPool, client)
+		client := newSlaveConn(target, cfg.ChainMaskList, cfg.ID, genesisHash)
 
 		id, chainMaskList, err := client.SendPing()
 		if err != nil {
@@ -43,30 +74,89 @@ func (s *SlaveConnManager) InitConnManager(cfg *config.ClusterConfig) error {
 		if err := checkPing(client, id, chainMaskList); err != nil {
 			return err
 		}
-		for _, fullShardID := range fullShardIds {
-			if client.HasShard(fullShardID) {
-				s.branchToSlaveConns[fullShardID] = append(s.branchToSlaveConns[fullShardID], client)
-				log.Info(s.logInfo, "branch:", fullShardID, "is run by slave", client.GetSlaveID())
-			}
-		}
+		s.addSlaveConn(client, fullShardIds)
 	}
-	s.count = len(s.clientPool)
 
 	return nil
 }
 
+// addSlaveConn adds the connection to the pool, and to the branches of the
+// shards it runs among fullShardIds.
+func (s *SlaveConnManager) addSlaveConn(client rpc.ISlaveConn, fullShardIds []uint32) {
+	s.clientPool = append(s.clientPool, client)
+	for _, fullShardID := range fullShardIds {
+		if client.HasShard(fullShardID) {
+			s.branchToSlaveConns[fullShardID] = append(s.branchToSlaveConns[fullShardID], client)
+			log.Info(s.logInfo, "branch:", fullShardID, "is run by slave", client.GetSlaveID())
+		}
+	}
+	s.count = len(s.clientPool)
+}
+
+// GetOneSlaveConnById returns the first slave running the shard which is not
+// lagging, or the first one if all of them are.
 func (c *SlaveConnManager) GetOneSlaveConnById(fullShardId uint32) rpc.ISlaveConn {
-	if conns, ok := c.branchToSlaveConns[fullShardId]; ok {
+	if conns := c.GetSlaveConnsById(fullShardId); len(conns) > 0 {
 		return conns[0]
 	}
 	return nil
 }
 
+// GetSlaveConnsById returns the slaves running the shard which are not
+// lagging, or all of them if they all are.
 func (c *SlaveConnManager) GetSlaveConnsById(fullShardId uint32) []rpc.ISlaveConn {
-	if conns, ok := c.branchToSlaveConns[fullShardId]; ok {
+	conns, ok := c.branchToSlaveConns[fullShardId]
+	if !ok {
+		return nil
+	}
+	c.laggingLock.RLock()
+	defer c.laggingLock.RUnlock()
+	if len(c.lagging) == 0 {
 		return conns
 	}
-	return nil
+	routed := make([]rpc.ISlaveConn, 0, len(conns))
+	for _, conn := range conns {
+		if !c.lagging[conn.GetSlaveID()] {
+			routed = append(routed, conn)
+		}
+	}
+	if len(routed) == 0 {
+		return conns
+	}
+	return routed
+}
+
+// setSlaveLagging records whether the slave is missing root blocks, the slaves
+// lagging are only routed to if all the slaves running a shard are.
+func (c *SlaveConnManager) setSlaveLagging(id string, lagging bool) {
+	c.laggingLock.Lock()
+	defer c.laggingLock.Unlock()
+	if lagging {
+		if c.lagging == nil {
+			c.lagging = make(map[string]bool)
+		}
+		c.lagging[id] = true
+	} else {
+		delete(c.lagging, id)
+	}
+}
+
+func (c *SlaveConnManager) isSlaveLagging(id string) bool {
+	c.laggingLock.RLock()
+	defer c.laggingLock.RUnlock()
+	return c.lagging[id]
+}
+
+// laggingSlaves returns the IDs of the slaves missing root blocks.
+func (c *SlaveConnManager) laggingSlaves() []string {
+	c.laggingLock.RLock()
+	defer c.laggingLock.RUnlock()
+	ids := make([]string, 0, len(c.lagging))
+	for id := range c.lagging {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
 }
 
 func (c *SlaveConnManager) GetSlaveConns() []rpc.ISlaveConn {
@@ -82,18 +172,21 @@ type SlaveConnection struct {
 	shardMaskList []*types.ChainMask
 	client        rpc.Client
 	slaveID       string
+	genesisHash   common.Hash
 	logInfo       string
 	mu            sync.Mutex
+	targetLock    sync.RWMutex
 }
 
 // create slave connection manager
-func NewSlaveConn(target string, shardMaskList []*types.ChainMask, slaveID string) *SlaveConnection {
-	client := rpc.NewClient(rpc.SlaveServer)
+func NewSlaveConn(target string, shardMaskList []*types.ChainMask, slaveID string, genesisHash common.Hash, slowOps *rpc.SlowOpLog) *SlaveConnection {
+	client := rpc.NewClient(rpc.SlaveServer, slowOps)
 	return &SlaveConnection{
 		target:        target,
 		client:        client,
 		shardMaskList: shardMaskList,
 		slaveID:       slaveID,
+		genesisHash:   genesisHash,
 		logInfo:       fmt.Sprintf("%v", slaveID),
 	}
 }
@@ -102,6 +195,24 @@ func (s *SlaveConnection) GetSlaveID() string {
 	return s.slaveID
 }
 
+// Close closes the connection to the slave.
+func (s *SlaveConnection) Close() {
+	s.client.Close()
+}
+
+// SetTarget points the connection to the new endpoint of the slave.
+func (s *SlaveConnection) SetTarget(target string) {
+	s.targetLock.Lock()
+	defer s.targetLock.Unlock()
+	s.target = target
+}
+
+func (s *SlaveConnection) getTarget() string {
+	s.targetLock.RLock()
+	defer s.targetLock.RUnlock()
+	return s.target
+}
+
 func (s *SlaveConnection) GetShardMaskList() []*types.ChainMask {
 	return s.shardMaskList
 }
@@ -119,7 +230,7 @@ func (s *SlaveConnection) HeartBeat() bool {
 	var tryTimes = 3
 	for tryTimes > 0 {
 		req := rpc.Request{Op: rpc.OpHeartBeat, Data: nil}
-		_, err := s.client.Call(s.target, &req)
+		_, err := s.client.CallContext(context.Background(), s.getTarget(), &req)
 		if err != nil {
 			time.Sleep(time.Duration(1) * time.Second)
 			tryTimes -= 1
@@ -142,39 +253,108 @@ func (s *SlaveConnection) MasterInfo(ip string, port uint16, rootTip *types.Root
 	if err != nil {
 		return err
 	}
-	_, err = s.client.Call(s.target, &rpc.Request{Op: rpc.OpMasterInfo, Data: bytes})
+	_, err = s.client.CallContext(context.Background(), s.getTarget(), &rpc.Request{Op: rpc.OpMasterInfo, Data: bytes})
+	return err
+}
+
+// ReloadConfig asks the slave to reload its cluster config.
+func (s *SlaveConnection) ReloadConfig(ctx context.Context) error {
+	_, err := s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpReloadConfig})
+	return err
+}
+
+// SetLogLevel asks the slave to set its verbosity, or the log level of the
+// module if not empty.
+func (s *SlaveConnection) SetLogLevel(ctx context.Context, module, level string) error {
+	bytes, err := serialize.SerializeToBytes(&rpc.SetLogLevelRequest{Module: module, Level: level})
+	if err != nil {
+		return err
+	}
+	_, err = s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpSetLogLevel, Data: bytes})
 	return err
 }
 
+// Profile asks the slave to run the profiling action.
+func (s *SlaveConnection) Profile(ctx context.Context, action, file string, rate int) error {
+	bytes, err := serialize.SerializeToBytes(&rpc.ProfileRequest{Action: action, File: file, Rate: uint32(rate)})
+	if err != nil {
+		return err
+	}
+	_, err = s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpProfile, Data: bytes})
+	return err
+}
+
+// GetSlowOps returns the last count slow cluster RPC ops of the slave.
+func (s *SlaveConnection) GetSlowOps(ctx context.Context, count int) ([]*rpc.SlowOp, error) {
+	bytes, err := serialize.SerializeToBytes(&rpc.GetSlowOpsRequest{Count: uint32(count)})
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpGetSlowOps, Data: bytes})
+	if err != nil {
+		return nil, err
+	}
+	rsp := new(rpc.GetSlowOpsResponse)
+	if err = serialize.DeserializeFromBytes(res.Data, rsp); err != nil {
+		return nil, err
+	}
+	return rsp.SlowOps, nil
+}
+
+// SendPing checks the slave and exchanges the capabilities with it, the ops it
+// doesn't serve are skipped from then on.
 func (s *SlaveConnection) SendPing() ([]byte, []*types.ChainMask, error) {
-	req := new(rpc.Ping)
+	req := &rpc.Ping{GenesisHash: s.genesisHash}
 
-	bytes, err := serialize.SerializeToBytes(req)
+	bytes, err := rpc.SerializeWithCapabilities(req, rpc.LocalCapabilities(rpc.MasterServer))
 	if err != nil {
 		return nil, nil, err
 	}
 
 	request := rpc.Request{Op: rpc.OpPing, Data: bytes}
 
-	rsp, err := s.client.Call(s.target, &request)
+	rsp, err := s.client.CallContext(context.Background(), s.getTarget(), &request)
 	if err != nil {
 		return nil, nil, err
 	}
 	pongMsg := new(rpc.Pong)
-	err = serialize.DeserializeFromBytes(rsp.Data, pongMsg)
+	caps, err := rpc.DeserializeWithCapabilities(rsp.Data, pongMsg)
 	if err != nil {
 		return nil, nil, err
 	}
+	// the slaves of version 0 don't send their genesis hash
+	if caps != nil && pongMsg.GenesisHash != s.genesisHash {
+		return nil, nil, fmt.Errorf("genesis mismatch with slave %s: have %x, want %x", s.slaveID, pongMsg.GenesisHash, s.genesisHash)
+	}
+	s.client.SetCapabilities(s.getTarget(), caps)
+	if version := protocolVersion(caps); version < rpc.ProtocolVersion {
+		log.Warn(s.logInfo, "slave runs an older protocol version", version, "master", rpc.ProtocolVersion)
+	}
 	return pongMsg.Id, pongMsg.ChainMaskList, nil
 }
 
-func (s *SlaveConnection) SendConnectToSlaves(slaveInfoLst []*rpc.SlaveInfo) error {
+// SupportsOp returns whether the slave serves the op, which it may not if it
+// runs an older version.
+func (s *SlaveConnection) SupportsOp(op uint32) bool {
+	return s.client.Supports(s.getTarget(), op)
+}
+
+// protocolVersion returns the protocol version of the capabilities, 0 if the
+// node didn't send them.
+func protocolVersion(caps *rpc.Capabilities) uint32 {
+	if caps == nil {
+		return 0
+	}
+	return caps.ProtocolVersion
+}
+
+func (s *SlaveConnection) SendConnectToSlaves(ctx context.Context, slaveInfoLst []*rpc.SlaveInfo) error {
 	req := rpc.ConnectToSlavesRequest{SlaveInfoList: slaveInfoLst}
 	bytes, err := serialize.SerializeToBytes(req)
 	if err != nil {
 		return err
 	}
-	rsp, err := s.client.Call(s.target, &rpc.Request{Op: rpc.OpConnectToSlaves, Data: bytes})
+	rsp, err := s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpConnectToSlaves, Data: bytes})
 	if err != nil {
 		return err
 	}
@@ -205,7 +385,7 @@ func (s *SlaveConnection) HasShard(fullShardID uint32) bool {
 	return false
 }
 
-func (s *SlaveConnection) AddTransaction(tx *types.Transaction) error {
+func (s *SlaveConnection) AddTransaction(ctx context.Context, tx *types.Transaction) error {
 	var (
 		req = rpc.AddTransactionRequest{Tx: tx}
 	)
@@ -214,7 +394,9 @@ func (s *SlaveConnection) AddTransaction(tx *types.Transaction) error {
 		return err
 	}
 
-	_, err = s.client.Call(s.target, &rpc.Request{Op: rpc.OpAddTransaction, Data: bytes})
+	// the retries of the tx are applied once by the slave
+	ctx = rpc.WithIdempotencyKey(ctx, tx.Hash().Hex())
+	_, err = s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpAddTransaction, Data: bytes})
 	if err != nil {
 		return err
 	}
@@ -222,9 +404,10 @@ func (s *SlaveConnection) AddTransaction(tx *types.Transaction) error {
 
 }
 
-func (s *SlaveConnection) ExecuteTransaction(tx *types.Transaction, fromAddress *account.Address, height *uint64) ([]byte, error) {
+func (s *SlaveConnection) ExecuteTransaction(ctx context.Context, tx *types.Transaction, fromAddress *account.Address, height *uint64,
+	overrides []*rpc.AccountOverride) ([]byte, error) {
 	var (
-		req = rpc.ExecuteTransactionRequest{Tx: tx, FromAddress: fromAddress, BlockHeight: height}
+		req = rpc.ExecuteTransactionRequest{Tx: tx, FromAddress: fromAddress, BlockHeight: height, Overrides: overrides}
 		rsp = new(rpc.ExecuteTransactionResponse)
 		res = new(rpc.Response)
 	)
@@ -233,7 +416,7 @@ func (s *SlaveConnection) ExecuteTransaction(tx *types.Transaction, fromAddress
 	if err != nil {
 		return nil, err
 	}
-	res, err = s.client.Call(s.target, &rpc.Request{Op: rpc.OpExecuteTransaction, Data: bytes})
+	res, err = s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpExecuteTransaction, Data: bytes})
 	if err != nil {
 		return nil, err
 	}
@@ -246,15 +429,36 @@ func (s *SlaveConnection) ExecuteTransaction(tx *types.Transaction, fromAddress
 
 }
 
-func (s *SlaveConnection) GetMinorBlockByHash(blockHash common.Hash, branch account.Branch, needExtraInfo bool) (*types.MinorBlock, *rpc.PoSWInfo, error) {
-	return s.getMinorBlock(blockHash, nil, branch, needExtraInfo)
+func (s *SlaveConnection) CreateAccessList(ctx context.Context, tx *types.Transaction, fromAddress *account.Address,
+	height *uint64) (*rpc.CreateAccessListResponse, error) {
+	var (
+		req = rpc.CreateAccessListRequest{Tx: tx, FromAddress: fromAddress, BlockHeight: height}
+		rsp = new(rpc.CreateAccessListResponse)
+		res = new(rpc.Response)
+	)
+	bytes, err := serialize.SerializeToBytes(req)
+	if err != nil {
+		return nil, err
+	}
+	res, err = s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpCreateAccessList, Data: bytes})
+	if err != nil {
+		return nil, err
+	}
+	if err = serialize.DeserializeFromBytes(res.Data, rsp); err != nil {
+		return nil, err
+	}
+	return rsp, nil
 }
 
-func (s *SlaveConnection) GetMinorBlockByHeight(height *uint64, branch account.Branch, needExtraInfo bool) (*types.MinorBlock, *rpc.PoSWInfo, error) {
-	return s.getMinorBlock(common.Hash{}, height, branch, needExtraInfo)
+func (s *SlaveConnection) GetMinorBlockByHash(ctx context.Context, blockHash common.Hash, branch account.Branch, needExtraInfo bool) (*types.MinorBlock, *rpc.PoSWInfo, error) {
+	return s.getMinorBlock(ctx, blockHash, nil, branch, needExtraInfo)
 }
 
-func (s *SlaveConnection) GetTransactionByHash(txHash common.Hash, branch account.Branch) (*types.MinorBlock, uint32, error) {
+func (s *SlaveConnection) GetMinorBlockByHeight(ctx context.Context, height *uint64, branch account.Branch, needExtraInfo bool) (*types.MinorBlock, *rpc.PoSWInfo, error) {
+	return s.getMinorBlock(ctx, common.Hash{}, height, branch, needExtraInfo)
+}
+
+func (s *SlaveConnection) GetTransactionByHash(ctx context.Context, txHash common.Hash, branch account.Branch) (*types.MinorBlock, uint32, error) {
 	var (
 		req   = rpc.GetTransactionRequest{Branch: branch.Value, TxHash: txHash}
 		trans = rpc.GetTransactionResponse{}
@@ -263,7 +467,7 @@ func (s *SlaveConnection) GetTransactionByHash(txHash common.Hash, branch accoun
 	if err != nil {
 		return nil, 0, err
 	}
-	res, err := s.client.Call(s.target, &rpc.Request{Op: rpc.OpGetTransaction, Data: bytes})
+	res, err := s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpGetTransaction, Data: bytes})
 	if err != nil {
 		return nil, 0, err
 	}
@@ -273,7 +477,7 @@ func (s *SlaveConnection) GetTransactionByHash(txHash common.Hash, branch accoun
 	return trans.MinorBlock, trans.Index, nil
 }
 
-func (s *SlaveConnection) GetTransactionReceipt(txHash common.Hash, branch account.Branch) (*types.MinorBlock, uint32, *types.Receipt, error) {
+func (s *SlaveConnection) GetTransactionReceipt(ctx context.Context, txHash common.Hash, branch account.Branch) (*types.MinorBlock, uint32, *types.Receipt, error) {
 	var (
 		req = rpc.GetTransactionReceiptRequest{Branch: branch.Value, TxHash: txHash}
 		rsp = new(rpc.GetTransactionReceiptResponse)
@@ -282,7 +486,7 @@ func (s *SlaveConnection) GetTransactionReceipt(txHash common.Hash, branch accou
 	if err != nil {
 		return nil, 0, nil, err
 	}
-	res, err := s.client.Call(s.target, &rpc.Request{Op: rpc.OpGetTransactionReceipt, Data: bytes})
+	res, err := s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpGetTransactionReceipt, Data: bytes})
 	if err != nil {
 		return nil, 0, nil, err
 	}
@@ -293,7 +497,126 @@ func (s *SlaveConnection) GetTransactionReceipt(txHash common.Hash, branch accou
 	return rsp.MinorBlock, rsp.Index, rsp.Receipt, nil
 }
 
-func (s *SlaveConnection) GetTransactionsByAddress(address *account.Address, start []byte, limit uint32, transferTokenID *uint64) ([]*rpc.TransactionDetail, []byte, error) {
+func (s *SlaveConnection) GetMinorBlockReceipts(ctx context.Context, blockHash common.Hash, branch account.Branch) (types.Receipts, error) {
+	var (
+		req = rpc.GetMinorBlockReceiptsRequest{Branch: branch.Value, MinorBlockHash: blockHash}
+		rsp = new(rpc.GetMinorBlockReceiptsResponse)
+	)
+	bytes, err := serialize.SerializeToBytes(req)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpGetMinorBlockReceipts, Data: bytes})
+	if err != nil {
+		return nil, err
+	}
+	if err = serialize.DeserializeFromBytes(res.Data, rsp); err != nil {
+		return nil, err
+	}
+	return rsp.Receipts, nil
+}
+
+// TraceBlock returns the traces of the txs of the minor block streamed by the
+// slave, in JSON.
+func (s *SlaveConnection) TraceBlock(ctx context.Context, blockHash common.Hash, branch account.Branch,
+	config *rpc.TraceConfig) ([]json.RawMessage, error) {
+	req := rpc.TraceBlockRequest{Branch: branch.Value, MinorBlockHash: blockHash, Config: *config}
+	bytes, err := serialize.SerializeToBytes(req)
+	if err != nil {
+		return nil, err
+	}
+	r, err := s.client.StreamContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpTraceBlock, Data: bytes})
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	traces := make([]json.RawMessage, 0)
+	dec := json.NewDecoder(r)
+	for {
+		var trace json.RawMessage
+		if err := dec.Decode(&trace); err == io.EOF {
+			return traces, nil
+		} else if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, err
+		}
+		traces = append(traces, trace)
+	}
+}
+
+// ExportBlocks calls fn with the canonical minor blocks streamed by the slave
+// from the height up, at most count of them, until fn returns false.
+func (s *SlaveConnection) ExportBlocks(ctx context.Context, branch account.Branch, from, count uint64, includeReceipts bool,
+	fn func(*rpc.ExportedBlock) bool) error {
+	req := rpc.ExportBlocksRequest{Branch: branch.Value, From: from, Count: count, IncludeReceipts: includeReceipts}
+	bytes, err := serialize.SerializeToBytes(req)
+	if err != nil {
+		return err
+	}
+	r, err := s.client.StreamContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpExportBlocks, Data: bytes})
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	br := bufio.NewReader(r)
+	for {
+		block, err := readExportedBlock(br)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		if !fn(block) {
+			return nil
+		}
+	}
+}
+
+// readExportedBlock reads an ExportedBlock record prefixed by its size.
+func readExportedBlock(r *bufio.Reader) (*rpc.ExportedBlock, error) {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	block := new(rpc.ExportedBlock)
+	if err := serialize.DeserializeFromBytes(data, block); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// SimulateBundle applies the txs in order on the state of the minor block at
+// the height, the head if nil, without keeping them.
+func (s *SlaveConnection) SimulateBundle(ctx context.Context, txs []*types.Transaction, branch account.Branch,
+	height *uint64) ([]*rpc.BundleTxResult, error) {
+	var (
+		req = rpc.SimulateBundleRequest{Branch: branch.Value, Txs: txs, Height: height}
+		rsp = new(rpc.SimulateBundleResponse)
+	)
+	bytes, err := serialize.SerializeToBytes(req)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpSimulateBundle, Data: bytes})
+	if err != nil {
+		return nil, err
+	}
+	if err = serialize.DeserializeFromBytes(res.Data, rsp); err != nil {
+		return nil, err
+	}
+	return rsp.Results, nil
+}
+
+func (s *SlaveConnection) GetTransactionsByAddress(ctx context.Context, address *account.Address, start []byte, limit uint32, transferTokenID *uint64) ([]*rpc.TransactionDetail, []byte, error) {
 	var (
 		req   = rpc.GetTransactionListByAddressRequest{Address: address, TransferTokenID: transferTokenID, Start: start, Limit: limit}
 		trans = rpc.GetTxDetailResponse{}
@@ -305,7 +628,7 @@ func (s *SlaveConnection) GetTransactionsByAddress(address *account.Address, sta
 	if err != nil {
 		return nil, nil, err
 	}
-	res, err = s.client.Call(s.target, &rpc.Request{Op: rpc.OpGetTransactionListByAddress, Data: bytes})
+	res, err = s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpGetTransactionListByAddress, Data: bytes})
 	if err != nil {
 		return nil, nil, err
 	}
@@ -315,7 +638,7 @@ func (s *SlaveConnection) GetTransactionsByAddress(address *account.Address, sta
 	return trans.TxList, trans.Next, nil
 }
 
-func (s *SlaveConnection) GetAllTx(branch account.Branch, start []byte, limit uint32) ([]*rpc.TransactionDetail, []byte, error) {
+func (s *SlaveConnection) GetAllTx(ctx context.Context, branch account.Branch, start []byte, limit uint32) ([]*rpc.TransactionDetail, []byte, error) {
 	var (
 		req     = rpc.GetAllTxRequest{Branch: branch, Start: start, Limit: limit}
 		trans   = rpc.GetTxDetailResponse{}
@@ -327,7 +650,7 @@ func (s *SlaveConnection) GetAllTx(branch account.Branch, start []byte, limit ui
 	if err != nil {
 		return nil, nil, err
 	}
-	res, err = s.client.Call(s.target, &rpc.Request{Op: rpc.OpGetAllTx, Data: reqData})
+	res, err = s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpGetAllTx, Data: reqData})
 	if err != nil {
 		return nil, nil, err
 	}
@@ -337,7 +660,7 @@ func (s *SlaveConnection) GetAllTx(branch account.Branch, start []byte, limit ui
 	return trans.TxList, trans.Next, nil
 }
 
-func (s *SlaveConnection) GetLogs(args *qrpc.FilterQuery) ([]*types.Log, error) {
+func (s *SlaveConnection) GetLogs(ctx context.Context, args *qrpc.FilterQuery) ([]*types.Log, error) {
 	var (
 		rsp = new(rpc.GetLogResponse)
 		res = new(rpc.Response)
@@ -346,7 +669,7 @@ func (s *SlaveConnection) GetLogs(args *qrpc.FilterQuery) ([]*types.Log, error)
 	if err != nil {
 		return nil, err
 	}
-	res, err = s.client.Call(s.target, &rpc.Request{Op: rpc.OpGetLogs, Data: bytes})
+	res, err = s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpGetLogs, Data: bytes})
 	if err != nil {
 		return nil, err
 	}
@@ -355,7 +678,7 @@ func (s *SlaveConnection) GetLogs(args *qrpc.FilterQuery) ([]*types.Log, error)
 
 }
 
-func (s *SlaveConnection) EstimateGas(tx *types.Transaction, fromAddress *account.Address) (uint32, error) {
+func (s *SlaveConnection) EstimateGas(ctx context.Context, tx *types.Transaction, fromAddress *account.Address) (uint32, error) {
 	var (
 		req = rpc.EstimateGasRequest{
 			Tx:          tx,
@@ -368,7 +691,7 @@ func (s *SlaveConnection) EstimateGas(tx *types.Transaction, fromAddress *accoun
 	if err != nil {
 		return 0, err
 	}
-	res, err = s.client.Call(s.target, &rpc.Request{Op: rpc.OpEstimateGas, Data: bytes})
+	res, err = s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpEstimateGas, Data: bytes})
 	if err != nil {
 		return 0, err
 	}
@@ -376,7 +699,7 @@ func (s *SlaveConnection) EstimateGas(tx *types.Transaction, fromAddress *accoun
 	return rsp.Result, err
 }
 
-func (s *SlaveConnection) GetStorageAt(address *account.Address, key common.Hash, height *uint64) (common.Hash, error) {
+func (s *SlaveConnection) GetStorageAt(ctx context.Context, address *account.Address, key common.Hash, height *uint64) (common.Hash, error) {
 	var (
 		req = rpc.GetStorageRequest{
 			Address:     address,
@@ -390,7 +713,7 @@ func (s *SlaveConnection) GetStorageAt(address *account.Address, key common.Hash
 	if err != nil {
 		return common.Hash{}, err
 	}
-	res, err = s.client.Call(s.target, &rpc.Request{Op: rpc.OpGetStorageAt, Data: bytes})
+	res, err = s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpGetStorageAt, Data: bytes})
 	if err != nil {
 		return common.Hash{}, err
 	}
@@ -398,7 +721,7 @@ func (s *SlaveConnection) GetStorageAt(address *account.Address, key common.Hash
 	return rsp.Result, err
 }
 
-func (s *SlaveConnection) GetCode(address *account.Address, height *uint64) ([]byte, error) {
+func (s *SlaveConnection) GetCode(ctx context.Context, address *account.Address, height *uint64) ([]byte, error) {
 	var (
 		req = rpc.GetCodeRequest{
 			Address:     address,
@@ -411,7 +734,7 @@ func (s *SlaveConnection) GetCode(address *account.Address, height *uint64) ([]b
 	if err != nil {
 		return nil, err
 	}
-	res, err = s.client.Call(s.target, &rpc.Request{Op: rpc.OpGetCode, Data: bytes})
+	res, err = s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpGetCode, Data: bytes})
 	if err != nil {
 		return nil, err
 	}
@@ -419,7 +742,7 @@ func (s *SlaveConnection) GetCode(address *account.Address, height *uint64) ([]b
 	return rsp.Result, err
 }
 
-func (s *SlaveConnection) GasPrice(branch account.Branch, tokenID uint64) (uint64, error) {
+func (s *SlaveConnection) GasPrice(ctx context.Context, branch account.Branch, tokenID uint64) (uint64, error) {
 	var (
 		req = rpc.GasPriceRequest{
 			Branch:  branch.Value,
@@ -432,7 +755,7 @@ func (s *SlaveConnection) GasPrice(branch account.Branch, tokenID uint64) (uint6
 	if err != nil {
 		return 0, err
 	}
-	res, err = s.client.Call(s.target, &rpc.Request{Op: rpc.OpGasPrice, Data: bytes})
+	res, err = s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpGasPrice, Data: bytes})
 	if err != nil {
 		return 0, err
 	}
@@ -440,7 +763,7 @@ func (s *SlaveConnection) GasPrice(branch account.Branch, tokenID uint64) (uint6
 	return rsp.Result, err
 }
 
-func (s *SlaveConnection) GetWork(branch account.Branch, coinbaseAddr *account.Address) (*consensus.MiningWork, error) {
+func (s *SlaveConnection) GetWork(ctx context.Context, branch account.Branch, coinbaseAddr *account.Address) (*consensus.MiningWork, error) {
 	var (
 		req = rpc.GetWorkRequest{
 			Branch:       branch.Value,
@@ -452,7 +775,7 @@ func (s *SlaveConnection) GetWork(branch account.Branch, coinbaseAddr *account.A
 	if err != nil {
 		return nil, err
 	}
-	res, err := s.client.Call(s.target, &rpc.Request{Op: rpc.OpGetWork, Data: bytes})
+	res, err := s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpGetWork, Data: bytes})
 	if err != nil {
 		return nil, err
 	}
@@ -462,7 +785,7 @@ func (s *SlaveConnection) GetWork(branch account.Branch, coinbaseAddr *account.A
 	return &rsp, nil
 }
 
-func (s *SlaveConnection) SubmitWork(work *rpc.SubmitWorkRequest) (success bool, err error) {
+func (s *SlaveConnection) SubmitWork(ctx context.Context, work *rpc.SubmitWorkRequest) (success bool, err error) {
 	var (
 		gRes  rpc.SubmitWorkResponse
 		bytes []byte
@@ -472,7 +795,7 @@ func (s *SlaveConnection) SubmitWork(work *rpc.SubmitWorkRequest) (success bool,
 	if err != nil {
 		return
 	}
-	res, err = s.client.Call(s.target, &rpc.Request{Op: rpc.OpSubmitWork, Data: bytes})
+	res, err = s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpSubmitWork, Data: bytes})
 	if err != nil {
 		return
 	}
@@ -482,7 +805,7 @@ func (s *SlaveConnection) SubmitWork(work *rpc.SubmitWorkRequest) (success bool,
 	return gRes.Success, nil
 }
 
-func (s *SlaveConnection) SendMiningConfigToSlaves(artificialTxConfig *rpc.ArtificialTxConfig, mining bool) error {
+func (s *SlaveConnection) SendMiningConfigToSlaves(ctx context.Context, artificialTxConfig *rpc.ArtificialTxConfig, mining bool) error {
 	var (
 		req = rpc.MineRequest{
 			ArtificialTxConfig: artificialTxConfig,
@@ -493,19 +816,19 @@ func (s *SlaveConnection) SendMiningConfigToSlaves(artificialTxConfig *rpc.Artif
 	if err != nil {
 		return err
 	}
-	_, err = s.client.Call(s.target, &rpc.Request{Op: rpc.OpGetMine, Data: bytes})
+	_, err = s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpGetMine, Data: bytes})
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (s *SlaveConnection) GetUnconfirmedHeaders() (*rpc.GetUnconfirmedHeadersResponse, error) {
+func (s *SlaveConnection) GetUnconfirmedHeaders(ctx context.Context) (*rpc.GetUnconfirmedHeadersResponse, error) {
 	var (
 		rsp = new(rpc.GetUnconfirmedHeadersResponse)
 	)
 
-	res, err := s.client.Call(s.target, &rpc.Request{Op: rpc.OpGetUnconfirmedHeaderList})
+	res, err := s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpGetUnconfirmedHeaderList})
 	if err != nil {
 		return nil, err
 	}
@@ -515,7 +838,7 @@ func (s *SlaveConnection) GetUnconfirmedHeaders() (*rpc.GetUnconfirmedHeadersRes
 	return rsp, nil
 }
 
-func (s *SlaveConnection) GetAccountData(address *account.Address, height *uint64) (*rpc.GetAccountDataResponse, error) {
+func (s *SlaveConnection) GetAccountData(ctx context.Context, address *account.Address, height *uint64) (*rpc.GetAccountDataResponse, error) {
 	var (
 		req = rpc.GetAccountDataRequest{
 			Address:     address,
@@ -527,7 +850,7 @@ func (s *SlaveConnection) GetAccountData(address *account.Address, height *uint6
 	if err != nil {
 		return nil, err
 	}
-	res, err := s.client.Call(s.target, &rpc.Request{Op: rpc.OpGetAccountData, Data: bytes})
+	res, err := s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpGetAccountData, Data: bytes})
 	if err != nil {
 		return nil, err
 	}
@@ -537,7 +860,7 @@ func (s *SlaveConnection) GetAccountData(address *account.Address, height *uint6
 	return rsp, nil
 }
 
-func (s *SlaveConnection) AddRootBlock(rootBlock *types.RootBlock, expectSwitch bool) error {
+func (s *SlaveConnection) AddRootBlock(ctx context.Context, rootBlock *types.RootBlock, expectSwitch bool) error {
 	var (
 		req = rpc.AddRootBlockRequest{
 			RootBlock:    rootBlock,
@@ -550,7 +873,10 @@ func (s *SlaveConnection) AddRootBlock(rootBlock *types.RootBlock, expectSwitch
 	if err != nil {
 		return err
 	}
-	res, err = s.client.Call(s.target, &rpc.Request{Op: rpc.OpAddRootBlock, Data: bytes})
+	// the root block sent again, by a retry or another broadcast, is applied
+	// once by the slave
+	ctx = rpc.WithIdempotencyKey(ctx, rootBlock.Hash().Hex())
+	res, err = s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpAddRootBlock, Data: bytes})
 	if err != nil {
 		return err
 	}
@@ -560,79 +886,86 @@ func (s *SlaveConnection) AddRootBlock(rootBlock *types.RootBlock, expectSwitch
 	return nil
 }
 
-func (s *SlaveConnection) GenTx(numTxPerShard, xShardPercent uint32, tx *types.Transaction) error {
-	var (
-		req = rpc.GenTxRequest{
-			NumTxPerShard: numTxPerShard,
-			XShardPercent: xShardPercent,
-			Tx:            tx,
-		}
-	)
+func (s *SlaveConnection) GenTx(ctx context.Context, req *rpc.GenTxRequest) error {
 	bytes, err := serialize.SerializeToBytes(req)
 	if err != nil {
 		return err
 	}
-	_, err = s.client.Call(s.target, &rpc.Request{Op: rpc.OpGenTx, Data: bytes})
+	_, err = s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpGenTx, Data: bytes})
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (s *SlaveConnection) AddTransactions(request *rpc.P2PRedirectRequest) error {
+// GetTxBenchmarkReports returns the results of the last tx benchmark of the
+// shards of the slave.
+func (s *SlaveConnection) GetTxBenchmarkReports(ctx context.Context) ([]*rpc.TxBenchmarkReport, error) {
+	res, err := s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpGetTxBenchmarkReports})
+	if err != nil {
+		return nil, err
+	}
+	rsp := new(rpc.GetTxBenchmarkReportsResponse)
+	if err = serialize.DeserializeFromBytes(res.Data, rsp); err != nil {
+		return nil, err
+	}
+	return rsp.Reports, nil
+}
+
+func (s *SlaveConnection) AddTransactions(ctx context.Context, request *rpc.P2PRedirectRequest) error {
 	bytes, err := serialize.SerializeToBytes(request)
 	if err != nil {
 		return err
 	}
-	_, err = s.client.Call(s.target, &rpc.Request{Op: rpc.OpAddTransactions, Data: bytes})
+	_, err = s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpAddTransactions, Data: bytes})
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (s *SlaveConnection) GetMinorBlocks(request *rpc.P2PRedirectRequest) ([]byte, error) {
+func (s *SlaveConnection) GetMinorBlocks(ctx context.Context, request *rpc.P2PRedirectRequest) ([]byte, error) {
 	bytes, err := serialize.SerializeToBytes(request)
 	if err != nil {
 		return nil, err
 	}
-	res, err := s.client.Call(s.target, &rpc.Request{Op: rpc.OpGetMinorBlockList, Data: bytes})
+	res, err := s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpGetMinorBlockList, Data: bytes})
 	if err != nil {
 		return nil, err
 	}
 	return res.Data, nil
 }
 
-func (s *SlaveConnection) GetMinorBlockHeaderListWithSkip(req *rpc.P2PRedirectRequest) ([]byte, error) {
+func (s *SlaveConnection) GetMinorBlockHeaderListWithSkip(ctx context.Context, req *rpc.P2PRedirectRequest) ([]byte, error) {
 	bytes, err := serialize.SerializeToBytes(req)
 	if err != nil {
 		return nil, err
 	}
-	res, err := s.client.Call(s.target, &rpc.Request{Op: rpc.OpGetMinorBlockHeaderListWithSkip, Data: bytes})
+	res, err := s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpGetMinorBlockHeaderListWithSkip, Data: bytes})
 	if err != nil {
 		return nil, err
 	}
 	return res.Data, nil
 }
 
-func (s *SlaveConnection) GetMinorBlockHeaderList(req *rpc.P2PRedirectRequest) ([]byte, error) {
+func (s *SlaveConnection) GetMinorBlockHeaderList(ctx context.Context, req *rpc.P2PRedirectRequest) ([]byte, error) {
 	bytes, err := serialize.SerializeToBytes(req)
 	if err != nil {
 		return nil, err
 	}
-	res, err := s.client.Call(s.target, &rpc.Request{Op: rpc.OpGetMinorBlockHeaderList, Data: bytes})
+	res, err := s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpGetMinorBlockHeaderList, Data: bytes})
 	if err != nil {
 		return nil, err
 	}
 	return res.Data, nil
 }
 
-func (s *SlaveConnection) HandleNewTip(request *rpc.HandleNewTipRequest) (bool, error) {
+func (s *SlaveConnection) HandleNewTip(ctx context.Context, request *rpc.HandleNewTipRequest) (bool, error) {
 	bytes, err := serialize.SerializeToBytes(request)
 	if err != nil {
 		return false, err
 	}
-	_, err = s.client.Call(s.target, &rpc.Request{Op: rpc.OpHandleNewTip, Data: bytes})
+	_, err = s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpHandleNewTip, Data: bytes})
 	if err != nil {
 		return false, err
 	}
@@ -640,19 +973,20 @@ func (s *SlaveConnection) HandleNewTip(request *rpc.HandleNewTipRequest) (bool,
 	return true, nil
 }
 
-func (s *SlaveConnection) HandleNewMinorBlock(req *rpc.P2PRedirectRequest) error {
+func (s *SlaveConnection) HandleNewMinorBlock(ctx context.Context, req *rpc.P2PRedirectRequest) error {
 	data, err := serialize.SerializeToBytes(req)
 	if err != nil {
 		return err
 	}
-	_, err = s.client.Call(s.target, &rpc.Request{Op: rpc.OpHandleNewMinorBlock, Data: data})
+	ctx = rpc.WithIdempotencyKey(ctx, crypto.Keccak256Hash(req.Data).Hex())
+	_, err = s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpHandleNewMinorBlock, Data: data})
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (s *SlaveConnection) AddBlockListForSync(request *rpc.AddBlockListForSyncRequest) (*rpc.ShardStatus, error) {
+func (s *SlaveConnection) AddBlockListForSync(ctx context.Context, request *rpc.AddBlockListForSyncRequest) (*rpc.ShardStatus, error) {
 	var (
 		shardStatus = new(rpc.ShardStatus)
 		res         = new(rpc.Response)
@@ -661,7 +995,7 @@ func (s *SlaveConnection) AddBlockListForSync(request *rpc.AddBlockListForSyncRe
 	if err != nil {
 		return nil, err
 	}
-	res, err = s.client.Call(s.target, &rpc.Request{Op: rpc.OpAddMinorBlockListForSync, Data: bytes})
+	res, err = s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpAddMinorBlockListForSync, Data: bytes})
 	if err != nil {
 		return nil, err
 	}
@@ -671,26 +1005,307 @@ func (s *SlaveConnection) AddBlockListForSync(request *rpc.AddBlockListForSyncRe
 	return shardStatus, nil
 }
 
-func (s *SlaveConnection) SetMining(mining bool) error {
+func (s *SlaveConnection) SetMining(ctx context.Context, mining bool) error {
 	bytes, err := serialize.SerializeToBytes(mining)
 	if err != nil {
 		return err
 	}
-	_, err = s.client.Call(s.target, &rpc.Request{Op: rpc.OpSetMining, Data: bytes})
+	_, err = s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpSetMining, Data: bytes})
 	return err
 }
 
-func (s *SlaveConnection) CheckMinorBlocksInRoot(rootBlock *types.RootBlock) error {
+func (s *SlaveConnection) CheckMinorBlocksInRoot(ctx context.Context, rootBlock *types.RootBlock) error {
 	bytes, err := serialize.SerializeToBytes(rootBlock)
 	if err != nil {
 		return err
 	}
-	_, err = s.client.Call(s.target, &rpc.Request{Op: rpc.OpCheckMinorBlocksInRoot, Data: bytes})
+	_, err = s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpCheckMinorBlocksInRoot, Data: bytes})
+	return err
+}
+
+func (s *SlaveConnection) GetBlockProfiles(ctx context.Context, branch account.Branch, limit uint32) ([]*rpc.BlockProfile, error) {
+	var (
+		req = rpc.GetBlockProfilesRequest{Branch: branch.Value, Limit: limit}
+		rsp = new(rpc.GetBlockProfilesResponse)
+		res = new(rpc.Response)
+	)
+	bytes, err := serialize.SerializeToBytes(req)
+	if err != nil {
+		return nil, err
+	}
+	res, err = s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpGetBlockProfiles, Data: bytes})
+	if err != nil {
+		return nil, err
+	}
+	if err = serialize.DeserializeFromBytes(res.Data, rsp); err != nil {
+		return nil, err
+	}
+	return rsp.BlockProfiles, nil
+}
+
+// ReplayBlocks re-executes the canonical blocks of the shard from first to
+// last, and returns their results against the stored values.
+func (s *SlaveConnection) ReplayBlocks(ctx context.Context, branch account.Branch, first, last uint64) ([]*rpc.BlockReplayResult, error) {
+	var (
+		req = rpc.ReplayBlocksRequest{Branch: branch.Value, First: first, Last: last}
+		rsp = new(rpc.ReplayBlocksResponse)
+		res = new(rpc.Response)
+	)
+	bytes, err := serialize.SerializeToBytes(req)
+	if err != nil {
+		return nil, err
+	}
+	res, err = s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpReplayBlocks, Data: bytes})
+	if err != nil {
+		return nil, err
+	}
+	if err = serialize.DeserializeFromBytes(res.Data, rsp); err != nil {
+		return nil, err
+	}
+	return rsp.Results, nil
+}
+
+// BackupShard writes a snapshot of the database of the shard to the archive
+// at path on the host of the slave.
+func (s *SlaveConnection) BackupShard(ctx context.Context, branch account.Branch, path string) (*rpc.BackupShardResponse, error) {
+	var (
+		req = rpc.BackupShardRequest{Branch: branch.Value, Path: path}
+		rsp = new(rpc.BackupShardResponse)
+		res = new(rpc.Response)
+	)
+	bytes, err := serialize.SerializeToBytes(req)
+	if err != nil {
+		return nil, err
+	}
+	res, err = s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpBackupShard, Data: bytes})
+	if err != nil {
+		return nil, err
+	}
+	if err = serialize.DeserializeFromBytes(res.Data, rsp); err != nil {
+		return nil, err
+	}
+	return rsp, nil
+}
+
+// GetShardRootTips returns the root blocks the shards of the slave were last
+// updated to.
+func (s *SlaveConnection) GetShardRootTips(ctx context.Context) ([]*rpc.ShardRootTip, error) {
+	rsp := new(rpc.GetShardRootTipsResponse)
+	res, err := s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpGetShardRootTips})
+	if err != nil {
+		return nil, err
+	}
+	if err = serialize.DeserializeFromBytes(res.Data, rsp); err != nil {
+		return nil, err
+	}
+	return rsp.RootTips, nil
+}
+
+// GetXShardQueues returns the queues of the cross-shard deposits not applied by
+// the shards of the slave yet.
+func (s *SlaveConnection) GetXShardQueues(ctx context.Context) ([]*rpc.XShardQueue, error) {
+	rsp := new(rpc.GetXShardQueuesResponse)
+	res, err := s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpGetXShardQueues})
+	if err != nil {
+		return nil, err
+	}
+	if err = serialize.DeserializeFromBytes(res.Data, rsp); err != nil {
+		return nil, err
+	}
+	return rsp.Queues, nil
+}
+
+// ShardMaintenance pauses or resumes the shard, or compacts or reindexes its
+// database while it's paused.
+func (s *SlaveConnection) ShardMaintenance(ctx context.Context, branch account.Branch, action string) (*rpc.ShardMaintenanceResponse, error) {
+	var (
+		req = rpc.ShardMaintenanceRequest{Branch: branch.Value, Action: action}
+		rsp = new(rpc.ShardMaintenanceResponse)
+	)
+	bytes, err := serialize.SerializeToBytes(req)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpShardMaintenance, Data: bytes})
+	if err != nil {
+		return nil, err
+	}
+	if err = serialize.DeserializeFromBytes(res.Data, rsp); err != nil {
+		return nil, err
+	}
+	return rsp, nil
+}
+
+// GetStateAvailability tells whether the state of the canonical block of the
+// shard at the height is available, or else from which block it can be
+// regenerated.
+func (s *SlaveConnection) GetStateAvailability(ctx context.Context, branch account.Branch, number uint64) (*rpc.StateAvailability, error) {
+	var (
+		req = rpc.GetStateAvailabilityRequest{Branch: branch.Value, Number: number}
+		rsp = new(rpc.GetStateAvailabilityResponse)
+	)
+	bytes, err := serialize.SerializeToBytes(req)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpGetStateAvailability, Data: bytes})
+	if err != nil {
+		return nil, err
+	}
+	if err = serialize.DeserializeFromBytes(res.Data, rsp); err != nil {
+		return nil, err
+	}
+	return rsp.Availability, nil
+}
+
+// RegenerateState starts writing the missing state of the canonical block of
+// the shard at the height by replaying the blocks from the nearest state
+// available, and returns its progress.
+func (s *SlaveConnection) RegenerateState(ctx context.Context, branch account.Branch, number uint64) (*rpc.RegenerateStateResponse, error) {
+	var (
+		req = rpc.RegenerateStateRequest{Branch: branch.Value, Number: number}
+		rsp = new(rpc.RegenerateStateResponse)
+	)
+	bytes, err := serialize.SerializeToBytes(req)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpRegenerateState, Data: bytes})
+	if err != nil {
+		return nil, err
+	}
+	if err = serialize.DeserializeFromBytes(res.Data, rsp); err != nil {
+		return nil, err
+	}
+	return rsp, nil
+}
+
+// GetPendingAccountData returns the account data of the address on the pending
+// state of its shard.
+func (s *SlaveConnection) GetPendingAccountData(ctx context.Context, address *account.Address) (*rpc.AccountBranchData, error) {
+	var (
+		req = rpc.GetPendingAccountDataRequest{Address: address}
+		rsp = new(rpc.GetPendingAccountDataResponse)
+	)
+	bytes, err := serialize.SerializeToBytes(req)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpGetPendingAccountData, Data: bytes})
+	if err != nil {
+		return nil, err
+	}
+	if err = serialize.DeserializeFromBytes(res.Data, rsp); err != nil {
+		return nil, err
+	}
+	return rsp.AccountBranchData, nil
+}
+
+// ExecutePendingTransaction executes the tx on the pending state of its shard.
+func (s *SlaveConnection) ExecutePendingTransaction(ctx context.Context, tx *types.Transaction, fromAddress *account.Address,
+	overrides []*rpc.AccountOverride) ([]byte, error) {
+	var (
+		req = rpc.ExecutePendingTransactionRequest{Tx: tx, FromAddress: fromAddress, Overrides: overrides}
+		rsp = new(rpc.ExecuteTransactionResponse)
+	)
+	bytes, err := serialize.SerializeToBytes(req)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpExecutePendingTransaction, Data: bytes})
+	if err != nil {
+		return nil, err
+	}
+	if err = serialize.DeserializeFromBytes(res.Data, rsp); err != nil {
+		return nil, err
+	}
+	return rsp.Result, nil
+}
+
+// ResendXshardTxList has the shard send the xshard tx lists of its blocks
+// with the hashes to the neighbor shards again.
+func (s *SlaveConnection) ResendXshardTxList(ctx context.Context, branch account.Branch, hashList []common.Hash) error {
+	req := rpc.ResendXshardTxListRequest{Branch: branch.Value, MinorBlockHashList: hashList}
+	bytes, err := serialize.SerializeToBytes(req)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpResendXshardTxList, Data: bytes})
 	return err
 }
 
+// GetShardSnapshot streams a snapshot of the database of the shard taken at
+// the root block with rootHash, for a new slave to bootstrap the shard from.
+func (s *SlaveConnection) GetShardSnapshot(branch account.Branch, rootHash common.Hash) (io.ReadCloser, error) {
+	bytes, err := serialize.SerializeToBytes(rpc.GetShardSnapshotRequest{Branch: branch.Value, RootHash: rootHash})
+	if err != nil {
+		return nil, err
+	}
+	return s.client.Stream(s.getTarget(), &rpc.Request{Op: rpc.OpGetShardSnapshot, Data: bytes})
+}
+
+func (s *SlaveConnection) GetBalanceHistory(ctx context.Context, address *account.Address, height *uint64) (*rpc.GetBalanceHistoryResponse, error) {
+	var (
+		req = rpc.GetBalanceHistoryRequest{Address: address, Height: height}
+		rsp = new(rpc.GetBalanceHistoryResponse)
+		res = new(rpc.Response)
+	)
+	bytes, err := serialize.SerializeToBytes(req)
+	if err != nil {
+		return nil, err
+	}
+	res, err = s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpGetBalanceHistory, Data: bytes})
+	if err != nil {
+		return nil, err
+	}
+	if err = serialize.DeserializeFromBytes(res.Data, rsp); err != nil {
+		return nil, err
+	}
+	return rsp, nil
+}
+
+func (s *SlaveConnection) GetStaleBlocks(ctx context.Context, branch account.Branch, limit uint32) (uint64, []*rpc.StaleBlock, error) {
+	var (
+		req = rpc.GetStaleBlocksRequest{Branch: branch.Value, Limit: limit}
+		rsp = new(rpc.GetStaleBlocksResponse)
+		res = new(rpc.Response)
+	)
+	bytes, err := serialize.SerializeToBytes(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	res, err = s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpGetStaleBlocks, Data: bytes})
+	if err != nil {
+		return 0, nil, err
+	}
+	if err = serialize.DeserializeFromBytes(res.Data, rsp); err != nil {
+		return 0, nil, err
+	}
+	return rsp.Total, rsp.StaleBlocks, nil
+}
+
+func (s *SlaveConnection) GetUnreceivedXShardDeposits(ctx context.Context, branch account.Branch, limit uint32) ([]*rpc.UnreceivedXShardDeposit, error) {
+	var (
+		req = rpc.GetUnreceivedXShardDepositsRequest{Branch: branch.Value, Limit: limit}
+		rsp = new(rpc.GetUnreceivedXShardDepositsResponse)
+		res = new(rpc.Response)
+	)
+	bytes, err := serialize.SerializeToBytes(req)
+	if err != nil {
+		return nil, err
+	}
+	res, err = s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpGetUnreceivedXShardDeposits, Data: bytes})
+	if err != nil {
+		return nil, err
+	}
+	if err = serialize.DeserializeFromBytes(res.Data, rsp); err != nil {
+		return nil, err
+	}
+	return rsp.Deposits, nil
+}
+
 // get minor block by hash or by height
-func (s *SlaveConnection) getMinorBlock(hash common.Hash, height *uint64,
+func (s *SlaveConnection) getMinorBlock(ctx context.Context, hash common.Hash, height *uint64,
 	branch account.Branch, needExtraInfo bool) (*types.MinorBlock, *rpc.PoSWInfo, error) {
 	var (
 		req              = rpc.GetMinorBlockRequest{Branch: branch.Value, MinorBlockHash: hash, Height: height, NeedExtraInfo: needExtraInfo}
@@ -701,7 +1316,7 @@ func (s *SlaveConnection) getMinorBlock(hash common.Hash, height *uint64,
 	if err != nil {
 		return nil, nil, err
 	}
-	res, err = s.client.Call(s.target, &rpc.Request{Op: rpc.OpGetMinorBlock, Data: bytes})
+	res, err = s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpGetMinorBlock, Data: bytes})
 	if err != nil {
 		return nil, nil, err
 	}
@@ -711,7 +1326,7 @@ func (s *SlaveConnection) getMinorBlock(hash common.Hash, height *uint64,
 	return minBlockResponse.MinorBlock, minBlockResponse.Extra, nil
 }
 
-func (s *SlaveConnection) GetRootChainStakes(address account.Address, lastMinor common.Hash) (*big.Int,
+func (s *SlaveConnection) GetRootChainStakes(ctx context.Context, address account.Address, lastMinor common.Hash) (*big.Int,
 	*account.Recipient, error) {
 	var (
 		getRootChainStakesRequest  = rpc.GetRootChainStakesRequest{Address: address, MinorBlockHash: lastMinor}
@@ -722,7 +1337,7 @@ func (s *SlaveConnection) GetRootChainStakes(address account.Address, lastMinor
 	if err != nil {
 		return nil, nil, err
 	}
-	res, err = s.client.Call(s.target, &rpc.Request{Op: rpc.OpGetRootChainStakes, Data: bytes})
+	res, err = s.client.CallContext(ctx, s.getTarget(), &rpc.Request{Op: rpc.OpGetRootChainStakes, Data: bytes})
 	if err != nil {
 		return nil, nil, err
 	}
@@ -0,0 +1,126 @@
+package master
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+const (
+	// totalBalanceCacheSize is the number of the accounts whose total
+	// balances are cached.
+	totalBalanceCacheSize = 4096
+	// totalBalanceCacheTTL bounds the time a total balance is served from the
+	// cache, on top of the shards getting no new blocks since it was read.
+	totalBalanceCacheTTL = 10 * time.Second
+)
+
+// cachedTotalBalance is a total balance with the heights of the shards when
+// it was read.
+type cachedTotalBalance struct {
+	balance *rpc.TotalBalance
+	heights map[uint32]uint64
+	time    time.Time
+}
+
+// totalBalanceCache keeps the total balances of the accounts queried lately,
+// until a shard gets a new block.
+type totalBalanceCache struct {
+	mu  sync.Mutex
+	lru *simplelru.LRU // recipient -> *cachedTotalBalance
+}
+
+func newTotalBalanceCache() *totalBalanceCache {
+	lru, _ := simplelru.NewLRU(totalBalanceCacheSize, nil)
+	return &totalBalanceCache{lru: lru}
+}
+
+// get returns the total balance of the recipient read at the heights of the
+// shards, if it's not older than the TTL.
+func (c *totalBalanceCache) get(recipient account.Recipient, heights map[uint32]uint64, now time.Time) (*rpc.TotalBalance, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	value, ok := c.lru.Get(recipient)
+	if !ok {
+		return nil, false
+	}
+	cached := value.(*cachedTotalBalance)
+	if now.Sub(cached.time) >= totalBalanceCacheTTL || !sameHeights(cached.heights, heights) {
+		c.lru.Remove(recipient)
+		return nil, false
+	}
+	return cached.balance, true
+}
+
+func (c *totalBalanceCache) add(recipient account.Recipient, balance *rpc.TotalBalance, heights map[uint32]uint64, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lru.Add(recipient, &cachedTotalBalance{balance: balance, heights: heights, time: now})
+}
+
+func sameHeights(a, b map[uint32]uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for id, height := range a {
+		if other, ok := b[id]; !ok || other != height {
+			return false
+		}
+	}
+	return true
+}
+
+// shardHeights returns the heights of the shards last reported by the slaves.
+func (s *QKCMasterBackend) shardHeights() map[uint32]uint64 {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	heights := make(map[uint32]uint64, len(s.branchToShardStats))
+	for id, status := range s.branchToShardStats {
+		heights[id] = status.Height
+	}
+	return heights
+}
+
+// GetTotalBalance returns the balances of the recipient of the address summed
+// over all the shards activated by token, read from the slaves in parallel,
+// and whether it was served from the cache. The shards which failed to be
+// read are returned with their error and not summed, and such total balances
+// are not cached.
+func (s *QKCMasterBackend) GetTotalBalance(ctx context.Context, address *account.Address) (*rpc.TotalBalance, bool, error) {
+	now, heights := time.Now(), s.shardHeights()
+	if balance, ok := s.totalBalances.get(address.Recipient, heights, now); ok {
+		return balance, true, nil
+	}
+	branchToAccountBranchData, err := s.GetAccountData(ctx, address, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	ids := make([]uint32, 0, len(branchToAccountBranchData))
+	for id := range branchToAccountBranchData {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	total := &rpc.TotalBalance{Balances: types.NewEmptyTokenBalances(), Errors: make(map[uint32]string)}
+	for _, id := range ids {
+		data := branchToAccountBranchData[id]
+		if data.Error != "" {
+			total.Errors[id] = data.Error
+			continue
+		}
+		if data.Balance != nil {
+			total.Balances.Add(data.Balance.GetBalanceMap())
+		}
+		total.Shards = append(total.Shards, id)
+	}
+	if len(total.Errors) == 0 {
+		s.totalBalances.add(address.Recipient, total, heights, now)
+	}
+	return total, false, nil
+}
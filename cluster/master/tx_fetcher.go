@@ -0,0 +1,205 @@
+package master
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/p2p"
+	"github.com/QuarkChain/goquarkchain/serialize"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/pkg/errors"
+)
+
+const (
+	// txFetchTimeout is the time allowed to a peer to return the announced
+	// transactions, after which they are requested from the next peer
+	// announcing them.
+	txFetchTimeout = 5 * time.Second
+
+	// maxTxCache is the number of transactions broadcast lately kept to serve
+	// the requests of the peers they are announced to.
+	maxTxCache = 16384
+
+	// maxSeenTxs is the number of transaction hashes received lately, which
+	// are not requested again when announced.
+	maxSeenTxs = 32768
+)
+
+// txFetcher keeps track of the announced transactions, so that each of them
+// is requested from a single peer at a time, and only if not received yet.
+type txFetcher struct {
+	lock      sync.Mutex
+	seen      *lru.Cache                // hashes of the transactions received
+	requested map[common.Hash]time.Time // deadlines of the pending requests
+}
+
+func newTxFetcher() *txFetcher {
+	seen, _ := lru.New(maxSeenTxs)
+	return &txFetcher{
+		seen:      seen,
+		requested: make(map[common.Hash]time.Time),
+	}
+}
+
+// markSeen records the transactions as received.
+func (f *txFetcher) markSeen(hashes []common.Hash) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for _, hash := range hashes {
+		f.seen.Add(hash, struct{}{})
+		delete(f.requested, hash)
+	}
+}
+
+// schedule returns the announced hashes which are neither received nor being
+// requested from another peer, and marks them as requested.
+func (f *txFetcher) schedule(hashes []common.Hash) []common.Hash {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	now := time.Now()
+	for hash, deadline := range f.requested {
+		if now.After(deadline) {
+			delete(f.requested, hash)
+		}
+	}
+	unknown := make([]common.Hash, 0, len(hashes))
+	for _, hash := range hashes {
+		if _, ok := f.requested[hash]; ok || f.seen.Contains(hash) {
+			continue
+		}
+		f.requested[hash] = now.Add(txFetchTimeout)
+		unknown = append(unknown, hash)
+	}
+	return unknown
+}
+
+// BroadcastTransactions sends the transactions of the branch to the square
+// root of the peers which do not know them, and announces their hashes to the
+// rest, which retrieve them if needed. The peers not supporting announcements
+// are sent the transactions.
+func (pm *ProtocolManager) BroadcastTransactions(txs *rpc.P2PRedirectRequest, sourcePeerId string) {
+	var list p2p.NewTransactionList
+	if err := serialize.DeserializeFromBytes(txs.Data, &list); err != nil {
+		log.Error("Failed to decode transactions to broadcast", "branch", txs.Branch, "err", err)
+		return
+	}
+	hashes := make([]common.Hash, len(list.TransactionList))
+	for i, tx := range list.TransactionList {
+		hashes[i] = tx.Hash()
+		pm.txCache.Add(hashes[i], tx)
+	}
+	pm.txFetcher.markSeen(hashes)
+
+	peers := pm.peers.Peers()
+	direct := int(math.Sqrt(float64(len(peers))))
+	sent, announced := 0, 0
+	for _, peer := range peers {
+		if peer.id == sourcePeerId {
+			peer.MarkTransactions(hashes)
+			continue
+		}
+		unknown := make([]int, 0, len(hashes))
+		for i, hash := range hashes {
+			if !peer.KnowsTransaction(hash) {
+				unknown = append(unknown, i)
+			}
+		}
+		if len(unknown) == 0 {
+			continue
+		}
+		peer.MarkTransactions(hashes)
+		if sent < direct || !peer.announcesTxs() {
+			sent++
+			if len(unknown) == len(hashes) {
+				peer.AsyncSendTransactions(txs)
+				continue
+			}
+			subset := make([]*types.Transaction, len(unknown))
+			for i, idx := range unknown {
+				subset[i] = list.TransactionList[idx]
+			}
+			data, err := serialize.SerializeToBytes(&p2p.NewTransactionList{TransactionList: subset})
+			if err != nil {
+				log.Error("Failed to encode transactions to broadcast", "branch", txs.Branch, "err", err)
+				return
+			}
+			peer.AsyncSendTransactions(&rpc.P2PRedirectRequest{PeerID: txs.PeerID, Branch: txs.Branch, Data: data})
+			continue
+		}
+		announced++
+		subset := make([]common.Hash, len(unknown))
+		for i, idx := range unknown {
+			subset[i] = hashes[idx]
+		}
+		peer.AsyncSendTransactionHashes(txs.Branch, subset)
+	}
+	log.Trace("Broadcast transactions", "branch", txs.Branch, "count", len(hashes), "sent", sent, "announced", announced)
+}
+
+// handleTransactions marks the transactions received from the peer as known,
+// and adds them to the shards of the branch.
+func (pm *ProtocolManager) handleTransactions(peer *Peer, branch uint32, data []byte) error {
+	var list p2p.NewTransactionList
+	if err := serialize.DeserializeFromBytes(data, &list); err != nil {
+		return err
+	}
+	hashes := make([]common.Hash, len(list.TransactionList))
+	for i, tx := range list.TransactionList {
+		hashes[i] = tx.Hash()
+	}
+	peer.MarkTransactions(hashes)
+	pm.txFetcher.markSeen(hashes)
+	return pm.HandleNewTransactionListRequest(peer.id, 0, branch, data)
+}
+
+// handleTransactionHashes retrieves the announced transactions which are not
+// received yet from the peer.
+func (pm *ProtocolManager) handleTransactionHashes(peer *Peer, branch uint32, hashes []common.Hash) error {
+	peer.MarkTransactions(hashes)
+	unknown := pm.txFetcher.schedule(hashes)
+	if len(unknown) == 0 {
+		return nil
+	}
+	txs, err := peer.GetTransactionList(branch, unknown)
+	if err != nil {
+		// the transactions are requested from the next peer announcing them
+		log.Debug("Failed to retrieve announced transactions", "peer", peer.id, "branch", branch, "err", err)
+		return nil
+	}
+	if len(txs) == 0 {
+		return nil
+	}
+	requested := make(map[common.Hash]bool, len(unknown))
+	for _, hash := range unknown {
+		requested[hash] = true
+	}
+	for _, tx := range txs {
+		if !requested[tx.Hash()] {
+			return errors.Errorf("unrequested transaction %x", tx.Hash())
+		}
+	}
+	data, err := serialize.SerializeToBytes(&p2p.NewTransactionList{TransactionList: txs})
+	if err != nil {
+		return err
+	}
+	return pm.handleTransactions(peer, branch, data)
+}
+
+// HandleGetTransactionListRequest returns the requested transactions which
+// are still in the cache of the transactions broadcast lately.
+func (pm *ProtocolManager) HandleGetTransactionListRequest(req *p2p.GetTransactionListRequest) *p2p.GetTransactionListResponse {
+	txs := make([]*types.Transaction, 0, len(req.TransactionHashList))
+	for _, hash := range req.TransactionHashList {
+		if tx, ok := pm.txCache.Get(hash); ok {
+			txs = append(txs, tx.(*types.Transaction))
+		}
+	}
+	return &p2p.GetTransactionListResponse{TransactionList: txs}
+}
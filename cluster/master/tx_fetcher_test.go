@@ -0,0 +1,176 @@
+package master
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/mocks/mock_master"
+	"github.com/QuarkChain/goquarkchain/p2p"
+	"github.com/QuarkChain/goquarkchain/serialize"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// readOp reads the next message sent to the test peer and returns its op.
+func readOp(peer *testPeer) (p2p.P2PCommandOp, error) {
+	type result struct {
+		op  p2p.P2PCommandOp
+		err error
+	}
+	resc := make(chan result, 1)
+	go func() {
+		msg, err := peer.app.ReadMsg()
+		if err != nil {
+			resc <- result{err: err}
+			return
+		}
+		payload, err := ioutil.ReadAll(msg.Payload)
+		if err != nil {
+			resc <- result{err: err}
+			return
+		}
+		qkcMsg, err := p2p.DecodeQKCMsg(payload)
+		resc <- result{qkcMsg.Op, err}
+	}()
+	select {
+	case res := <-resc:
+		return res.op, res.err
+	case <-time.After(time.Second):
+		return 0, p2p.DiscReadTimeout
+	}
+}
+
+func waitPeers(t *testing.T, pm *ProtocolManager, n int) {
+	for i := 0; i < 100 && pm.peers.Len() < n; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if pm.peers.Len() < n {
+		t.Fatalf("%d peers registered, want %d", pm.peers.Len(), n)
+	}
+}
+
+func TestBroadcastTransactionAnnouncements(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	pm, _ := newTestProtocolManagerMust(t, 15, nil, NewFakeSynchronizer(1), newFakeConnManager(1, ctrl))
+	txs, err := newTestTransactionList(10)
+	assert.NoError(t, err)
+
+	legacy, err := newTestPeer("legacy", qkc1, pm, true)
+	assert.NoError(t, err)
+	defer legacy.close()
+	peers := make([]*testPeer, 0, 4)
+	for i := 0; i < 4; i++ {
+		peer, err := newTestPeer(fmt.Sprintf("peer%d", i), qkc2, pm, true)
+		assert.NoError(t, err)
+		defer peer.close()
+		peers = append(peers, peer)
+	}
+	waitPeers(t, pm, 5)
+
+	pm.BroadcastTransactions(txs, "")
+	op, err := readOp(legacy)
+	assert.NoError(t, err)
+	assert.Equal(t, p2p.NewTransactionListMsg, op)
+	sent, announced := 0, 0
+	for _, peer := range peers {
+		op, err := readOp(peer)
+		assert.NoError(t, err)
+		switch op {
+		case p2p.NewTransactionListMsg:
+			sent++
+		case p2p.NewTransactionHashesMsg:
+			announced++
+		default:
+			t.Errorf("unexpected op %v", op)
+		}
+	}
+	// two of the five peers are sent the transactions, one of them the legacy one
+	assert.True(t, sent >= 1 && sent <= 2, "sent to %d peers", sent)
+	assert.Equal(t, 4, sent+announced)
+
+	// the announced transactions are served from the cache
+	var list p2p.NewTransactionList
+	assert.NoError(t, serialize.DeserializeFromBytes(txs.Data, &list))
+	hashes := []common.Hash{list.TransactionList[3].Hash(), common.Hash{1}, list.TransactionList[0].Hash()}
+	resp := pm.HandleGetTransactionListRequest(&p2p.GetTransactionListRequest{TransactionHashList: hashes})
+	assert.Equal(t, 2, len(resp.TransactionList))
+	assert.Equal(t, hashes[0], resp.TransactionList[0].Hash())
+	assert.Equal(t, hashes[2], resp.TransactionList[1].Hash())
+
+	// the peers know the transactions now
+	pm.BroadcastTransactions(txs, "")
+	_, err = readOp(peers[0])
+	assert.Equal(t, p2p.DiscReadTimeout, err)
+}
+
+func TestFetchAnnouncedTransactions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	fakeConnMngr := newFakeConnManager(1, ctrl)
+	pm, _ := newTestProtocolManagerMust(t, 15, nil, NewFakeSynchronizer(1), fakeConnMngr)
+	txs, err := newTestTransactionList(3)
+	assert.NoError(t, err)
+	var list p2p.NewTransactionList
+	assert.NoError(t, serialize.DeserializeFromBytes(txs.Data, &list))
+	hashes := make([]common.Hash, len(list.TransactionList))
+	for i, tx := range list.TransactionList {
+		hashes[i] = tx.Hash()
+	}
+
+	peer, err := newTestPeer("peer", qkc2, pm, true)
+	assert.NoError(t, err)
+	defer peer.close()
+	clientPeer := newTestClientPeer(qkc2, peer.app)
+	waitPeers(t, pm, 1)
+
+	errc := make(chan error, 1)
+	for _, conn := range fakeConnMngr.GetSlaveConns() {
+		conn.(*mock_master.MockISlaveConn).EXPECT().
+			AddTransactions(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, req *rpc.P2PRedirectRequest) error {
+			var added p2p.NewTransactionList
+			if err := serialize.DeserializeFromBytes(req.Data, &added); err != nil {
+				errc <- err
+			} else if len(added.TransactionList) != 2 {
+				errc <- fmt.Errorf("%d transactions added, want 2", len(added.TransactionList))
+			} else {
+				errc <- nil
+			}
+			return nil
+		}).Times(1)
+	}
+
+	// the first transaction is received already
+	pm.txFetcher.markSeen(hashes[:1])
+	assert.NoError(t, clientPeer.SendTransactionHashes(0, hashes))
+	req := &p2p.GetTransactionListRequest{TransactionHashList: hashes[1:]}
+	qkcMsg, err := ExpectMsg(peer.app, p2p.GetTransactionListRequestMsg, p2p.Metadata{Branch: 0}, req)
+	assert.NoError(t, err)
+	// the transactions are not requested again while the request is pending
+	assert.Empty(t, pm.txFetcher.schedule(hashes))
+
+	resp := &p2p.GetTransactionListResponse{TransactionList: list.TransactionList[1:]}
+	assert.NoError(t, clientPeer.SendResponse(p2p.GetTransactionListResponseMsg, p2p.Metadata{Branch: 0}, qkcMsg.RpcID, resp))
+	assert.NoError(t, waitChanTilErrorOrTimeout(errc, 2))
+	assert.True(t, peer.KnowsTransaction(hashes[2]))
+	assert.Empty(t, pm.txFetcher.schedule(hashes))
+}
+
+func TestTxFetcherRequestTimeout(t *testing.T) {
+	f := newTxFetcher()
+	hashes := []common.Hash{{1}, {2}}
+	assert.Equal(t, hashes, f.schedule(hashes))
+	assert.Empty(t, f.schedule(hashes))
+
+	// the transactions are requested again once the request timed out
+	for hash := range f.requested {
+		f.requested[hash] = time.Now().Add(-time.Second)
+	}
+	f.markSeen(hashes[:1])
+	assert.Equal(t, hashes[1:], f.schedule(hashes))
+}
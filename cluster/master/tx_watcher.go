@@ -0,0 +1,305 @@
+package master
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/cluster/config"
+	"github.com/QuarkChain/goquarkchain/cluster/eventsink"
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/core"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/internal/encoder"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+const (
+	// txWatchInterval is how often the txs watched are checked.
+	txWatchInterval = 2 * time.Second
+	// txRecheckInterval is how often a tx is checked while neither its shard
+	// nor the root chain gets new blocks, to find it dropped from the pool.
+	txRecheckInterval = 30 * time.Second
+	// maxWatchedTxs bounds the txs watched, the oldest ones are no longer
+	// watched beyond it.
+	maxWatchedTxs = 16384
+	// txDropMisses is the number of the checks in a row finding a tx neither
+	// in the pool nor in a block for it to be dropped, as a tx may be in
+	// neither while the block including it is added.
+	txDropMisses = 2
+)
+
+// txWatcherBackend is the part of the master the tx watcher looks the txs up
+// with.
+type txWatcherBackend interface {
+	GetTransactionByHash(ctx context.Context, txHash common.Hash, branch account.Branch) (*types.MinorBlock, uint32, error)
+	GetMinorBlockConfirmation(mHash common.Hash, fullShardID uint32) (*types.RootBlock, uint64)
+	GetLastMinorBlockByFullShardID(fullShardId uint32) (uint64, error)
+	CurrentBlock() *types.RootBlock
+	SubscribeRootChainEvent(ch chan<- core.RootChainEvent) event.Subscription
+}
+
+// watchedTx is the last status found of a tx watched.
+type watchedTx struct {
+	fullShardKey uint32
+	fullShardID  uint32
+	status       string
+	blockHash    common.Hash
+	blockHeight  uint64
+	misses       int
+	// the heights of the shard and of the root chain when it was checked last
+	shardHeight uint64
+	rootHeight  uint64
+	checked     time.Time
+}
+
+// txWatcher follows the txs submitted through the master until they are
+// confirmed by a root block or dropped from the pool, and sends an event each
+// time the status of one changes, to the subscribers and to the webhook if
+// any. A tx is looked up in its shard again once the shard gets new blocks,
+// and an included one is checked for its confirmation once a root block is
+// added.
+type txWatcher struct {
+	backend txWatcherBackend
+	webhook *eventsink.Dispatcher // nil without the webhook
+
+	mu      sync.Mutex
+	txs     *simplelru.LRU // tx hash -> *watchedTx
+	checkMu sync.Mutex     // held by check, the watchedTx are updated without mu
+
+	feed   event.Feed
+	scope  event.SubscriptionScope
+	exitCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// newTxWatcher returns the tx watcher posting the events to the webhook URL,
+// retrying them while it fails, if any.
+func newTxWatcher(backend txWatcherBackend, webhook string) (*txWatcher, error) {
+	txs, _ := simplelru.NewLRU(maxWatchedTxs, nil)
+	w := &txWatcher{
+		backend: backend,
+		txs:     txs,
+		exitCh:  make(chan struct{}),
+	}
+	if webhook != "" {
+		var err error
+		w.webhook, err = eventsink.New([]*config.EventSinkConfig{{Type: config.EventSinkHTTP, URL: webhook}})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+func (w *txWatcher) start() {
+	if w.webhook != nil {
+		w.webhook.Start()
+	}
+	// subscribed before returning so no root block added meanwhile is missed
+	chainCh := make(chan core.RootChainEvent, 16)
+	chainSub := w.backend.SubscribeRootChainEvent(chainCh)
+	w.wg.Add(1)
+	go w.loop(chainCh, chainSub)
+}
+
+func (w *txWatcher) stop() {
+	close(w.exitCh)
+	w.wg.Wait()
+	if w.webhook != nil {
+		w.webhook.Stop()
+	}
+	w.scope.Close()
+}
+
+// watch starts watching the tx added to the pool of its shard.
+func (w *txWatcher) watch(tx *types.Transaction) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.txs.Add(tx.Hash(), &watchedTx{
+		fullShardKey: tx.EvmTx.FromFullShardKey(),
+		fullShardID:  tx.EvmTx.FromFullShardId(),
+		status:       rpc.TxStatusPending,
+	})
+}
+
+// subscribe sends the events of the txs watched to ch.
+func (w *txWatcher) subscribe(ch chan<- *rpc.TxStatusEvent) event.Subscription {
+	return w.scope.Track(w.feed.Subscribe(ch))
+}
+
+// loop checks the txs on each tick, and as soon as a root block is added for
+// the included ones to be confirmed without waiting for the tick.
+func (w *txWatcher) loop(chainCh <-chan core.RootChainEvent, chainSub event.Subscription) {
+	defer w.wg.Done()
+	defer chainSub.Unsubscribe()
+	ticker := time.NewTicker(txWatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.check(time.Now())
+		case <-chainCh:
+			// the blocks added at once are checked once
+			for len(chainCh) > 0 {
+				<-chainCh
+			}
+			w.check(time.Now())
+		case <-chainSub.Err():
+			return
+		case <-w.exitCh:
+			return
+		}
+	}
+}
+
+// check looks up the txs whose shard got new blocks since they were checked
+// last, or which were not checked for txRecheckInterval, checks the included
+// ones for their confirmation if the root chain got new blocks, and sends the
+// events of the ones whose status changed. The height of each shard is read
+// once.
+func (w *txWatcher) check(now time.Time) {
+	w.checkMu.Lock()
+	defer w.checkMu.Unlock()
+	w.mu.Lock()
+	keys := w.txs.Keys()
+	w.mu.Unlock()
+	if len(keys) == 0 {
+		return
+	}
+	rootHeight := w.backend.CurrentBlock().NumberU64()
+	shardHeights := make(map[uint32]uint64)
+	failed := make(map[uint32]bool)
+	shardHeight := func(fullShardID uint32) (uint64, bool) {
+		if height, ok := shardHeights[fullShardID]; ok || failed[fullShardID] {
+			return height, ok
+		}
+		height, err := w.backend.GetLastMinorBlockByFullShardID(fullShardID)
+		if err != nil {
+			// its txs are checked on the next tick
+			log.Debug("Failed to read height of watched shard", "shard", fullShardID, "err", err)
+			failed[fullShardID] = true
+			return 0, false
+		}
+		shardHeights[fullShardID] = height
+		return height, true
+	}
+	for _, key := range keys {
+		hash := key.(common.Hash)
+		w.mu.Lock()
+		value, ok := w.txs.Peek(hash)
+		w.mu.Unlock()
+		if !ok {
+			continue
+		}
+		wtx := value.(*watchedTx)
+		height, ok := shardHeight(wtx.fullShardID)
+		if !ok {
+			continue
+		}
+		var (
+			events []*rpc.TxStatusEvent
+			done   bool
+			err    error
+		)
+		switch {
+		case height != wtx.shardHeight || now.Sub(wtx.checked) >= txRecheckInterval:
+			if events, done, err = w.checkTx(hash, wtx); err != nil {
+				log.Debug("Failed to check watched tx", "tx", hash.Hex(), "err", err)
+				continue
+			}
+			wtx.shardHeight, wtx.checked = height, now
+		case rootHeight != wtx.rootHeight && wtx.status == rpc.TxStatusIncluded:
+			// only the root chain got new blocks, the tx is still in the
+			// same block
+			events, done = w.checkConfirmation(hash, wtx)
+		default:
+			continue
+		}
+		wtx.rootHeight = rootHeight
+		if done {
+			w.mu.Lock()
+			w.txs.Remove(hash)
+			w.mu.Unlock()
+		}
+		for _, ev := range events {
+			w.send(ev)
+		}
+	}
+}
+
+// checkTx looks the tx up in its shard and returns the events of the changes
+// of its status, and whether it's no longer watched.
+func (w *txWatcher) checkTx(hash common.Hash, wtx *watchedTx) ([]*rpc.TxStatusEvent, bool, error) {
+	block, index, err := w.backend.GetTransactionByHash(context.Background(), hash, account.Branch{Value: wtx.fullShardID})
+	if err != nil {
+		return nil, false, err
+	}
+	newEvent := func(status string) *rpc.TxStatusEvent {
+		wtx.status = status
+		return &rpc.TxStatusEvent{
+			TxHash:       hash,
+			FullShardKey: wtx.fullShardKey,
+			FullShardID:  wtx.fullShardID,
+			Status:       status,
+			BlockHash:    wtx.blockHash,
+			BlockHeight:  wtx.blockHeight,
+		}
+	}
+	switch {
+	case block == nil || int(index) >= len(block.Transactions()) || block.Transactions()[index].Hash() != hash:
+		if wtx.misses++; wtx.misses < txDropMisses {
+			return nil, false, nil
+		}
+		wtx.blockHash, wtx.blockHeight = common.Hash{}, 0
+		return []*rpc.TxStatusEvent{newEvent(rpc.TxStatusDropped)}, true, nil
+	case block.NumberU64() == 0:
+		// the slaves return a tx in the pool in an empty block
+		wtx.misses = 0
+		if wtx.status != rpc.TxStatusIncluded {
+			return nil, false, nil
+		}
+		wtx.blockHash, wtx.blockHeight = common.Hash{}, 0
+		return []*rpc.TxStatusEvent{newEvent(rpc.TxStatusPending)}, false, nil
+	}
+	wtx.misses = 0
+	var events []*rpc.TxStatusEvent
+	if wtx.status != rpc.TxStatusIncluded || wtx.blockHash != block.Hash() {
+		wtx.blockHash, wtx.blockHeight = block.Hash(), block.NumberU64()
+		events = append(events, newEvent(rpc.TxStatusIncluded))
+	}
+	confirmation, done := w.checkConfirmation(hash, wtx)
+	return append(events, confirmation...), done, nil
+}
+
+// checkConfirmation returns the event of the confirmation of the tx included
+// if a root block confirms its block, and whether it's no longer watched.
+func (w *txWatcher) checkConfirmation(hash common.Hash, wtx *watchedTx) ([]*rpc.TxStatusEvent, bool) {
+	rBlock, _ := w.backend.GetMinorBlockConfirmation(wtx.blockHash, wtx.fullShardID)
+	if rBlock == nil {
+		return nil, false
+	}
+	wtx.status = rpc.TxStatusConfirmed
+	return []*rpc.TxStatusEvent{{
+		TxHash:          hash,
+		FullShardKey:    wtx.fullShardKey,
+		FullShardID:     wtx.fullShardID,
+		Status:          rpc.TxStatusConfirmed,
+		BlockHash:       wtx.blockHash,
+		BlockHeight:     wtx.blockHeight,
+		RootBlockHash:   rBlock.Hash(),
+		RootBlockHeight: rBlock.NumberU64(),
+	}}, true
+}
+
+func (w *txWatcher) send(ev *rpc.TxStatusEvent) {
+	log.Debug("Watched tx status changed", "tx", ev.TxHash.Hex(), "status", ev.Status, "block", ev.BlockHeight)
+	w.feed.Send(ev)
+	if w.webhook != nil {
+		w.webhook.Publish(config.EventTxStatus, encoder.TxStatusEventEncoder(ev))
+	}
+}
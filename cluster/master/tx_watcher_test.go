@@ -0,0 +1,175 @@
+package master
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/cluster/config"
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/core"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeTxWatcherBackend has a single shard, whose blocks include the txs in
+// blocks, and whose confirmed blocks are in confirmed. It counts the lookups
+// of the txs and of the shard height.
+type fakeTxWatcherBackend struct {
+	shardHeight uint64
+	rootHeight  uint64
+	pool        map[common.Hash]*types.Transaction
+	blocks      map[common.Hash]*types.MinorBlock
+	confirmed   map[common.Hash]*types.RootBlock
+	chainFeed   event.Feed
+
+	txLookups     int
+	heightLookups int
+}
+
+func (b *fakeTxWatcherBackend) GetTransactionByHash(ctx context.Context, txHash common.Hash, branch account.Branch) (*types.MinorBlock, uint32, error) {
+	b.txLookups++
+	if block, ok := b.blocks[txHash]; ok {
+		return block, 0, nil
+	}
+	if tx, ok := b.pool[txHash]; ok {
+		return types.NewMinorBlock(&types.MinorBlockHeader{}, &types.MinorBlockMeta{}, []*types.Transaction{tx}, nil, nil), 0, nil
+	}
+	return nil, 0, nil
+}
+
+func (b *fakeTxWatcherBackend) GetMinorBlockConfirmation(mHash common.Hash, fullShardID uint32) (*types.RootBlock, uint64) {
+	rBlock := b.confirmed[mHash]
+	return rBlock, b.rootHeight
+}
+
+func (b *fakeTxWatcherBackend) GetLastMinorBlockByFullShardID(fullShardId uint32) (uint64, error) {
+	b.heightLookups++
+	return b.shardHeight, nil
+}
+
+func (b *fakeTxWatcherBackend) CurrentBlock() *types.RootBlock {
+	return types.NewRootBlockWithHeader(&types.RootBlockHeader{Number: uint32(b.rootHeight)})
+}
+
+func (b *fakeTxWatcherBackend) SubscribeRootChainEvent(ch chan<- core.RootChainEvent) event.Subscription {
+	return b.chainFeed.Subscribe(ch)
+}
+
+// mine includes the tx in a new block of the shard.
+func (b *fakeTxWatcherBackend) mine(tx *types.Transaction) *types.MinorBlock {
+	b.shardHeight++
+	block := types.NewMinorBlock(&types.MinorBlockHeader{Number: b.shardHeight}, &types.MinorBlockMeta{}, []*types.Transaction{tx}, nil, nil)
+	b.blocks[tx.Hash()] = block
+	delete(b.pool, tx.Hash())
+	return block
+}
+
+func receiveTxStatus(t *testing.T, ch <-chan *rpc.TxStatusEvent) *rpc.TxStatusEvent {
+	select {
+	case ev := <-ch:
+		return ev
+	case <-time.After(time.Second):
+		t.Helper()
+		t.Fatal("no tx status event")
+		return nil
+	}
+}
+
+func TestTxWatcher(t *testing.T) {
+	posted := make(chan map[string]interface{}, 16)
+	var posts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first post fails, and is retried
+		if atomic.AddInt32(&posts, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var ev struct {
+			Type string                 `json:"type"`
+			Data map[string]interface{} `json:"data"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&ev))
+		assert.Equal(t, config.EventTxStatus, ev.Type)
+		posted <- ev.Data
+	}))
+	defer server.Close()
+
+	key, _ := crypto.HexToECDSA("45a915e4d060149eb4365960e6a7a45f334393093061116b197e3240065ff2d8")
+	tx1, tx2 := newTestTransaction(key, 0, 0), newTestTransaction(key, 1, 0)
+	backend := &fakeTxWatcherBackend{
+		pool:      map[common.Hash]*types.Transaction{tx1.Hash(): tx1, tx2.Hash(): tx2},
+		blocks:    make(map[common.Hash]*types.MinorBlock),
+		confirmed: make(map[common.Hash]*types.RootBlock),
+	}
+	w, err := newTxWatcher(backend, server.URL)
+	assert.NoError(t, err)
+	w.start()
+	defer w.stop()
+	ch := make(chan *rpc.TxStatusEvent, 16)
+	sub := w.subscribe(ch)
+	defer sub.Unsubscribe()
+	w.watch(tx1)
+	w.watch(tx2)
+
+	// nothing changes while the txs are in the pool, and the height of
+	// their shard is read once
+	now := time.Now()
+	w.check(now)
+	assert.Len(t, ch, 0)
+	assert.Equal(t, 1, backend.heightLookups)
+
+	// tx1 is included, then confirmed
+	block := backend.mine(tx1)
+	w.check(now)
+	ev := receiveTxStatus(t, ch)
+	assert.Equal(t, tx1.Hash(), ev.TxHash)
+	assert.Equal(t, rpc.TxStatusIncluded, ev.Status)
+	assert.Equal(t, block.Hash(), ev.BlockHash)
+	assert.Equal(t, uint64(1), ev.BlockHeight)
+	// a tx is not checked again until new blocks are added
+	w.check(now)
+	assert.Len(t, ch, 0)
+
+	// the confirmation is checked once the root block is added, without
+	// looking the txs up again
+	lookups := backend.txLookups
+	rBlock := types.NewRootBlockWithHeader(&types.RootBlockHeader{Number: 1})
+	backend.confirmed[block.Hash()] = rBlock
+	backend.rootHeight = 1
+	backend.chainFeed.Send(core.RootChainEvent{Block: rBlock, Hash: rBlock.Hash()})
+	ev = receiveTxStatus(t, ch)
+	assert.Equal(t, rpc.TxStatusConfirmed, ev.Status)
+	assert.Equal(t, rBlock.Hash(), ev.RootBlockHash)
+	assert.Equal(t, uint64(1), ev.RootBlockHeight)
+	assert.Equal(t, lookups, backend.txLookups)
+	assert.Equal(t, 1, w.txs.Len())
+
+	// tx2 leaves the pool, and is dropped after the second check missing it
+	delete(backend.pool, tx2.Hash())
+	w.check(now.Add(txRecheckInterval))
+	assert.Len(t, ch, 0)
+	w.check(now.Add(2 * txRecheckInterval))
+	ev = receiveTxStatus(t, ch)
+	assert.Equal(t, tx2.Hash(), ev.TxHash)
+	assert.Equal(t, rpc.TxStatusDropped, ev.Status)
+	assert.Equal(t, 0, w.txs.Len())
+
+	// the webhook gets the same events in order
+	for _, status := range []string{rpc.TxStatusIncluded, rpc.TxStatusConfirmed, rpc.TxStatusDropped} {
+		select {
+		case fields := <-posted:
+			assert.Equal(t, status, fields["status"])
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no %s webhook post", status)
+		}
+	}
+}
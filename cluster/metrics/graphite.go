@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// graphiteEscaper replaces the characters not allowed in the names and the
+// tags of Graphite.
+var graphiteEscaper = strings.NewReplacer(" ", "_", ";", "_", "~", "_", "=", "_", "\n", "_")
+
+// graphiteSink sends the points to the plaintext listener of Graphite, as one
+// tagged metric named <prefix>.<point>.<field> for each numeric field. The
+// string fields are not sent.
+type graphiteSink struct {
+	addr   string
+	prefix string
+}
+
+func newGraphiteSink(addr, prefix string) *graphiteSink {
+	return &graphiteSink{addr: addr, prefix: prefix}
+}
+
+func (s *graphiteSink) write(points []Point) error {
+	var buf bytes.Buffer
+	for _, point := range points {
+		if err := s.writeLines(&buf, point); err != nil {
+			return err
+		}
+	}
+	conn, err := net.DialTimeout("tcp", s.addr, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err = conn.Write(buf.Bytes())
+	return err
+}
+
+func (s *graphiteSink) close() {}
+
+// writeLines writes the numeric fields of the point as the lines
+// "<name>;<tag>=<value>... <value> <timestamp>", with a timestamp in seconds.
+func (s *graphiteSink) writeLines(w *bytes.Buffer, point Point) error {
+	var tags strings.Builder
+	for _, key := range sortedKeys(point.Tags) {
+		if value := point.Tags[key]; value != "" {
+			fmt.Fprintf(&tags, ";%s=%s", graphiteEscaper.Replace(key), graphiteEscaper.Replace(value))
+		}
+	}
+	for _, key := range sortedFields(point.Fields) {
+		if _, ok := point.Fields[key].(string); ok {
+			continue
+		}
+		value, err := floatValue(point.Fields[key])
+		if err != nil {
+			return fmt.Errorf("metric %s field %s: %v", point.Name, key, err)
+		}
+		name := graphiteEscaper.Replace(point.Name + "." + key)
+		if s.prefix != "" {
+			name = s.prefix + "." + name
+		}
+		fmt.Fprintf(w, "%s%s %s %d\n", name, tags.String(), strconv.FormatFloat(value, 'f', -1, 64), point.Time.Unix())
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	measurementEscaper = strings.NewReplacer(`,`, `\,`, ` `, `\ `)
+	keyEscaper         = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `)
+	stringEscaper      = strings.NewReplacer(`"`, `\"`, `\`, `\\`)
+)
+
+// influxSink posts the points to the write API of InfluxDB in the line
+// protocol, e.g. to http://localhost:8086/write?db=quarkchain. The user info of
+// the URL is sent with basic authentication.
+type influxSink struct {
+	url    string
+	user   *url.Userinfo
+	client *http.Client
+}
+
+func newInfluxSink(endpoint string) (*influxSink, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	user := u.User
+	u.User = nil
+	return &influxSink{url: u.String(), user: user, client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+func (s *influxSink) write(points []Point) error {
+	var body bytes.Buffer
+	for _, point := range points {
+		if err := writeLine(&body, point); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest("POST", s.url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if s.user != nil {
+		password, _ := s.user.Password()
+		req.SetBasicAuth(s.user.Username(), password)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("InfluxDB answered %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+func (s *influxSink) close() {}
+
+// writeLine writes the point in the line protocol, with a timestamp in
+// nanoseconds.
+func writeLine(w *bytes.Buffer, point Point) error {
+	w.WriteString(measurementEscaper.Replace(point.Name))
+	for _, key := range sortedKeys(point.Tags) {
+		if value := point.Tags[key]; value != "" {
+			fmt.Fprintf(w, ",%s=%s", keyEscaper.Replace(key), keyEscaper.Replace(value))
+		}
+	}
+	for i, key := range sortedFields(point.Fields) {
+		if i == 0 {
+			w.WriteByte(' ')
+		} else {
+			w.WriteByte(',')
+		}
+		w.WriteString(keyEscaper.Replace(key))
+		w.WriteByte('=')
+		switch v := point.Fields[key].(type) {
+		case string:
+			fmt.Fprintf(w, `"%s"`, stringEscaper.Replace(v))
+		case bool:
+			w.WriteString(strconv.FormatBool(v))
+		case int:
+			fmt.Fprintf(w, "%di", v)
+		case int32:
+			fmt.Fprintf(w, "%di", v)
+		case int64:
+			fmt.Fprintf(w, "%di", v)
+		case uint:
+			writeUint(w, uint64(v))
+		case uint32:
+			fmt.Fprintf(w, "%di", v)
+		case uint64:
+			writeUint(w, v)
+		default:
+			f, err := floatValue(v)
+			if err != nil {
+				return fmt.Errorf("metric %s field %s: %v", point.Name, key, err)
+			}
+			w.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+		}
+	}
+	fmt.Fprintf(w, " %d\n", point.Time.UnixNano())
+	return nil
+}
+
+// writeUint writes an unsigned integer as an integer of InfluxDB, which is
+// signed, or as a float if it overflows.
+func writeUint(w *bytes.Buffer, v uint64) {
+	if v > math.MaxInt64 {
+		w.WriteString(strconv.FormatFloat(float64(v), 'g', -1, 64))
+		return
+	}
+	fmt.Fprintf(w, "%di", v)
+}
@@ -0,0 +1,191 @@
+// Package metrics pushes the metrics of the master and the slaves to the time
+// series databases of the operators, with the InfluxDB line protocol or the
+// Graphite plaintext protocol.
+package metrics
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/cluster/config"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Point is a measurement of the fields of a metric at a time.
+type Point struct {
+	Name   string
+	Tags   map[string]string
+	Fields map[string]interface{} // integers, floats, bools or strings
+	Time   time.Time
+}
+
+// NewPoint returns the point of the metric measured now.
+func NewPoint(name string, tags map[string]string, fields map[string]interface{}) Point {
+	return Point{Name: name, Tags: tags, Fields: fields, Time: time.Now()}
+}
+
+// ProcessPoint returns the point of the runtime metrics of the process.
+func ProcessPoint() Point {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return NewPoint("process", nil, map[string]interface{}{
+		"goroutines": runtime.NumGoroutine(),
+		"heap_alloc": stats.HeapAlloc,
+		"heap_inuse": stats.HeapInuse,
+		"sys":        stats.Sys,
+		"num_gc":     stats.NumGC,
+		"gc_pause":   stats.PauseTotalNs,
+	})
+}
+
+// sink writes the points to a time series database.
+type sink interface {
+	write(points []Point) error
+	close()
+}
+
+// Reporter pushes the points collected to the time series database at each
+// interval, with the tags of the reporter added to them.
+type Reporter struct {
+	collect  func() []Point
+	interval time.Duration
+	tags     map[string]string
+	sink     sink
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewReporter returns the reporter of the points collected configured by the
+// monitoring config, or nil if the metrics are not reported. The node tag is
+// the name of the process, master or the slave ID.
+func NewReporter(cfg *config.MonitoringConfig, node string, collect func() []Point) (*Reporter, error) {
+	if cfg == nil || cfg.MetricsType == "" {
+		return nil, nil
+	}
+	if err := cfg.ValidateMetrics(); err != nil {
+		return nil, err
+	}
+	var (
+		s   sink
+		err error
+	)
+	switch cfg.MetricsType {
+	case config.MetricsInfluxDB:
+		s, err = newInfluxSink(cfg.MetricsEndpoint)
+	case config.MetricsGraphite:
+		s = newGraphiteSink(cfg.MetricsEndpoint, cfg.MetricsPrefix)
+	}
+	if err != nil {
+		return nil, err
+	}
+	tags := map[string]string{"cluster": cfg.ClusterID, "node": node}
+	if cfg.NetworkName != "" {
+		tags["network"] = cfg.NetworkName
+	}
+	for key, value := range cfg.MetricsTags {
+		tags[key] = value
+	}
+	return &Reporter{
+		collect:  collect,
+		interval: time.Duration(cfg.MetricsInterval) * time.Second,
+		tags:     tags,
+		sink:     s,
+		quit:     make(chan struct{}),
+	}, nil
+}
+
+// Start pushes the points until the reporter is stopped.
+func (r *Reporter) Start() {
+	r.wg.Add(1)
+	go r.loop()
+}
+
+// Stop stops the reporter, after pushing the points a last time.
+func (r *Reporter) Stop() {
+	close(r.quit)
+	r.wg.Wait()
+	r.sink.close()
+}
+
+func (r *Reporter) loop() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.report()
+		case <-r.quit:
+			r.report()
+			return
+		}
+	}
+}
+
+// report pushes the points collected with the tags of the reporter.
+func (r *Reporter) report() {
+	points := r.collect()
+	for i := range points {
+		tags := make(map[string]string, len(r.tags)+len(points[i].Tags))
+		for key, value := range r.tags {
+			tags[key] = value
+		}
+		for key, value := range points[i].Tags {
+			tags[key] = value
+		}
+		points[i].Tags = tags
+	}
+	if err := r.sink.write(points); err != nil {
+		log.Warn("Failed to report metrics", "err", err)
+	}
+}
+
+func sortedKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedFields(fields map[string]interface{}) []string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// floatValue returns the numeric value of the field, with bools as 0 or 1.
+func floatValue(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case uint:
+		return float64(v), nil
+	case uint32:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return 0, fmt.Errorf("field of type %T is not numeric", value)
+}
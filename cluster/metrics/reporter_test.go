@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/cluster/config"
+	"github.com/stretchr/testify/assert"
+)
+
+var testTime = time.Unix(1600000000, 5)
+
+func TestWriteLine(t *testing.T) {
+	var buf bytes.Buffer
+	point := Point{
+		Name: "shard state",
+		Tags: map[string]string{"shard": "65537", "cluster": "a,b", "empty": ""},
+		Fields: map[string]interface{}{
+			"height":     uint64(10),
+			"difficulty": 1.5,
+			"syncing":    true,
+			"peers":      3,
+			"name":       `say "hi"`,
+		},
+		Time: testTime,
+	}
+	assert.NoError(t, writeLine(&buf, point))
+	assert.Equal(t, `shard\ state,cluster=a\,b,shard=65537 difficulty=1.5,height=10i,name="say \"hi\"",peers=3i,syncing=true 1600000000000000005`+"\n", buf.String())
+
+	point.Fields = map[string]interface{}{"bad": []int{1}}
+	assert.Error(t, writeLine(&buf, point))
+}
+
+func TestInfluxSink(t *testing.T) {
+	var (
+		body       []byte
+		user, pass string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "quarkchain", r.URL.Query().Get("db"))
+		user, pass, _ = r.BasicAuth()
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	endpoint := strings.Replace(server.URL, "http://", "http://qkc:secret@", 1) + "/write?db=quarkchain"
+	sink, err := newInfluxSink(endpoint)
+	assert.NoError(t, err)
+	point := Point{Name: "root", Fields: map[string]interface{}{"height": 1}, Time: testTime}
+	assert.NoError(t, sink.write([]Point{point}))
+	assert.Equal(t, "root height=1i 1600000000000000005\n", string(body))
+	assert.Equal(t, "qkc", user)
+	assert.Equal(t, "secret", pass)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "database not found", http.StatusNotFound)
+	}))
+	defer failing.Close()
+	sink, err = newInfluxSink(failing.URL + "/write?db=none")
+	assert.NoError(t, err)
+	assert.Error(t, sink.write([]Point{point}))
+}
+
+func TestGraphiteSink(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	lines := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var received []string
+		for scanner := bufio.NewScanner(conn); scanner.Scan(); {
+			received = append(received, scanner.Text())
+		}
+		lines <- received
+	}()
+
+	sink := newGraphiteSink(listener.Addr().String(), "qkc")
+	point := Point{
+		Name:   "shard",
+		Tags:   map[string]string{"shard": "1", "cluster": "my cluster"},
+		Fields: map[string]interface{}{"height": uint64(7), "syncing": false, "name": "skipped"},
+		Time:   testTime,
+	}
+	assert.NoError(t, sink.write([]Point{point}))
+	select {
+	case received := <-lines:
+		assert.Equal(t, []string{
+			"qkc.shard.height;cluster=my_cluster;shard=1 7 1600000000",
+			"qkc.shard.syncing;cluster=my_cluster;shard=1 0 1600000000",
+		}, received)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no metrics received")
+	}
+}
+
+func TestReporter(t *testing.T) {
+	reports := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		reports <- string(body)
+	}))
+	defer server.Close()
+
+	cfg := config.NewMonitoringConfig()
+	reporter, err := NewReporter(cfg, "S0", nil)
+	assert.NoError(t, err)
+	assert.Nil(t, reporter)
+
+	cfg.MetricsType = config.MetricsInfluxDB
+	cfg.MetricsEndpoint = server.URL + "/write?db=quarkchain"
+	cfg.MetricsTags = map[string]string{"region": "eu"}
+	collect := func() []Point {
+		return []Point{NewPoint("shard", map[string]string{"shard": "1"}, map[string]interface{}{"height": 1})}
+	}
+	reporter, err = NewReporter(cfg, "S0", collect)
+	assert.NoError(t, err)
+	reporter.Start()
+	// the points are pushed a last time when the reporter stops
+	reporter.Stop()
+	select {
+	case report := <-reports:
+		assert.True(t, strings.HasPrefix(report, "shard,cluster=127.0.0.1,node=S0,region=eu,shard=1 height=1i "), report)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no metrics reported")
+	}
+
+	cfg.MetricsType = "statsd"
+	_, err = NewReporter(cfg, "S0", collect)
+	assert.Error(t, err)
+}
@@ -41,6 +41,7 @@ type Miner struct {
 	isMining  bool
 	stopCh    chan struct{}
 	logInfo   string
+	hasWork   func() bool
 }
 
 func New(ctx *service.ServiceContext, api MinerAPI, engine consensus.Engine) *Miner {
@@ -91,6 +92,9 @@ func (m *Miner) commit(addr *account.Address) {
 	if !m.allowMining() {
 		return
 	}
+	if m.hasWork != nil && !m.hasWork() {
+		return
+	}
 	m.interrupt()
 	block, diff, optionalDivider, err := m.api.CreateBlockToMine(addr)
 	if err != nil {
@@ -157,6 +161,24 @@ func (m *Miner) SetMining(mining bool) {
 	}
 }
 
+// SetOnDemand makes the miner seal a block only when hasWork reports work for
+// it, HandleNewWork tells the miner about the new work.
+func (m *Miner) SetOnDemand(hasWork func() bool) {
+	m.hasWork = hasWork
+}
+
+// HandleNewWork starts sealing a block of the new work if the miner mines on
+// demand.
+func (m *Miner) HandleNewWork() {
+	if m.hasWork == nil || !m.IsMining() {
+		return
+	}
+	select {
+	case m.startCh <- struct{}{}:
+	default:
+	}
+}
+
 func (m *Miner) GetWork(coinbaseAddr *account.Address) (*consensus.MiningWork, error) {
 	addrForGetWork := m.api.GetDefaultCoinbaseAddress()
 	if coinbaseAddr != nil && !account.IsSameAddress(*coinbaseAddr, m.api.GetDefaultCoinbaseAddress()) {
@@ -0,0 +1,193 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/serialize"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ProtocolVersion is the version of the cluster ops between the master and the
+// slaves, bumped when ops are added or changed. The nodes which don't exchange
+// their capabilities are version 0.
+const ProtocolVersion uint32 = 1
+
+// Capabilities is the protocol version and the ops served by a master or a
+// slave, exchanged in the Ping handshake so that a master can run with slaves
+// of an older version during a rolling upgrade, skipping the ops they don't
+// serve. The ops are named since their codes are local to a version.
+type Capabilities struct {
+	ProtocolVersion uint32   `json:"protocol_version"`
+	Ops             []string `json:"ops" bytesizeofslicelen:"4"`
+}
+
+// LocalCapabilities returns the capabilities of the server of the type run by
+// the process.
+func LocalCapabilities(tp serverType) *Capabilities {
+	apis := masterApis
+	if tp == SlaveServer {
+		apis = slaveApis
+	}
+	ops := make([]string, 0, len(apis))
+	for _, op := range apis {
+		ops = append(ops, op.name)
+	}
+	sort.Strings(ops)
+	return &Capabilities{ProtocolVersion: ProtocolVersion, Ops: ops}
+}
+
+// OpName returns the name of the op of a master or a slave.
+func OpName(op uint32) string {
+	if opType, ok := slaveApis[op]; ok {
+		return opType.name
+	}
+	return masterApis[op].name
+}
+
+// Supports returns whether the op of the name is served.
+func (c *Capabilities) Supports(name string) bool {
+	i := sort.SearchStrings(c.Ops, name)
+	return i < len(c.Ops) && c.Ops[i] == name
+}
+
+// SerializeWithCapabilities serializes the handshake message followed by the
+// capabilities, which the nodes of version 0 ignore.
+func SerializeWithCapabilities(val interface{}, caps *Capabilities) ([]byte, error) {
+	data, err := serialize.SerializeToBytes(val)
+	if err != nil {
+		return nil, err
+	}
+	capsData, err := serialize.SerializeToBytes(caps)
+	if err != nil {
+		return nil, err
+	}
+	return append(data, capsData...), nil
+}
+
+// DeserializeWithCapabilities deserializes the handshake message and returns
+// the capabilities following it, nil if sent by a node of version 0.
+func DeserializeWithCapabilities(data []byte, val interface{}) (*Capabilities, error) {
+	bb := serialize.NewByteBuffer(data)
+	if err := serialize.Deserialize(bb, val); err != nil {
+		if legacy, ok := val.(legacyHandshake); ok {
+			return nil, legacy.deserializeV0(data)
+		}
+		return nil, err
+	}
+	if bb.Remaining() == 0 {
+		return nil, nil
+	}
+	caps := new(Capabilities)
+	if err := serialize.Deserialize(bb, caps); err != nil {
+		return nil, err
+	}
+	sort.Strings(caps.Ops)
+	return caps, nil
+}
+
+// legacyHandshake is a handshake message the nodes of version 0 send in an
+// older format.
+type legacyHandshake interface {
+	deserializeV0(data []byte) error
+}
+
+// handshakeV0 is the Ping and the Pong of the nodes of version 0, without the
+// genesis hash, which is left empty.
+type handshakeV0 struct {
+	Id            []byte             `bytesizeofslicelen:"4"`
+	ChainMaskList []*types.ChainMask `bytesizeofslicelen:"4"`
+}
+
+func deserializeHandshakeV0(data []byte) (*handshakeV0, error) {
+	bb := serialize.NewByteBuffer(data)
+	msg := new(handshakeV0)
+	if err := serialize.Deserialize(bb, msg); err != nil {
+		return nil, err
+	}
+	if bb.Remaining() != 0 {
+		return nil, errors.New("invalid handshake message")
+	}
+	return msg, nil
+}
+
+func (p *Ping) deserializeV0(data []byte) error {
+	msg, err := deserializeHandshakeV0(data)
+	if err != nil {
+		return err
+	}
+	*p = Ping{Id: msg.Id, ChainMaskList: msg.ChainMaskList}
+	return nil
+}
+
+func (p *Pong) deserializeV0(data []byte) error {
+	msg, err := deserializeHandshakeV0(data)
+	if err != nil {
+		return err
+	}
+	*p = Pong{Id: msg.Id, ChainMaskList: msg.ChainMaskList}
+	return nil
+}
+
+// UnsupportedOpError is returned by the calls of the ops the server doesn't
+// serve, as it runs an older version.
+type UnsupportedOpError struct {
+	Op     string
+	Target string
+}
+
+func (e *UnsupportedOpError) Error() string {
+	return fmt.Sprintf("op %s is not supported by %s", e.Op, e.Target)
+}
+
+// IsUnsupportedOp returns whether err is an UnsupportedOpError.
+func IsUnsupportedOp(err error) bool {
+	var opErr *UnsupportedOpError
+	return errors.As(err, &opErr)
+}
+
+// SetCapabilities sets the capabilities of the server at hostport, nil if it
+// is of version 0, in which case the ops it doesn't serve are learnt from
+// their calls.
+func (c *rpcClient) SetCapabilities(hostport string, caps *Capabilities) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if caps == nil {
+		delete(c.caps, hostport)
+	} else {
+		c.caps[hostport] = caps
+	}
+	delete(c.unsupported, hostport)
+}
+
+// Supports returns whether the server at hostport serves the op, true unless
+// known otherwise.
+func (c *rpcClient) Supports(hostport string, op uint32) bool {
+	name := c.funcs[op].name
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.unsupported[hostport][name] {
+		return false
+	}
+	caps, ok := c.caps[hostport]
+	return !ok || caps.Supports(name)
+}
+
+// checkUnimplemented turns the error of a call of an op the server doesn't
+// have into an UnsupportedOpError, and remembers it.
+func (c *rpcClient) checkUnimplemented(hostport string, op uint32, err error) error {
+	if status.Code(err) != codes.Unimplemented {
+		return err
+	}
+	name := c.funcs[op].name
+	c.mu.Lock()
+	if c.unsupported[hostport] == nil {
+		c.unsupported[hostport] = make(map[string]bool)
+	}
+	c.unsupported[hostport][name] = true
+	c.mu.Unlock()
+	return &UnsupportedOpError{Op: name, Target: hostport}
+}
@@ -0,0 +1,105 @@
+package rpc
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/rpc"
+	"github.com/QuarkChain/goquarkchain/serialize"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestCapabilitiesHandshake(t *testing.T) {
+	ping := Ping{Id: []byte("S1"), GenesisHash: common.HexToHash("0x01")}
+	data, err := SerializeWithCapabilities(ping, LocalCapabilities(SlaveServer))
+	if err != nil {
+		t.Fatalf("failed to serialize ping: %v", err)
+	}
+
+	var got Ping
+	caps, err := DeserializeWithCapabilities(data, &got)
+	if err != nil {
+		t.Fatalf("failed to deserialize ping: %v", err)
+	}
+	if got.GenesisHash != ping.GenesisHash || caps == nil || caps.ProtocolVersion != ProtocolVersion {
+		t.Fatalf("handshake mismatch, ping %v caps %v", got, caps)
+	}
+	if !caps.Supports(OpName(OpSimulateBundle)) || caps.Supports(OpName(OpAddMinorBlockHeader)) {
+		t.Fatal("slave ops mismatch")
+	}
+
+	if legacy, err := serialize.SerializeToBytes(ping); err != nil {
+		t.Fatalf("failed to serialize ping: %v", err)
+	} else if caps, err := DeserializeWithCapabilities(legacy, &got); err != nil || caps != nil {
+		t.Fatalf("capabilities without them: %v %v", caps, err)
+	}
+
+	// a node of version 0 reads neither the genesis hash nor the capabilities
+	var old handshakeV0
+	if err := serialize.DeserializeFromBytes(data, &old); err != nil || string(old.Id) != "S1" {
+		t.Fatalf("failed to deserialize ping of version 0: %v", err)
+	}
+	// nor sends them, with the ID "S1" and the chain mask 1
+	legacy := common.FromHex("0x00000002533100000001" + "00000001")
+	var pong Pong
+	caps, err = DeserializeWithCapabilities(legacy, &pong)
+	if err != nil || caps != nil {
+		t.Fatalf("capabilities of version 0: %v %v", caps, err)
+	}
+	if string(pong.Id) != "S1" || len(pong.ChainMaskList) != 1 || pong.ChainMaskList[0].Value != 1 || pong.GenesisHash != (common.Hash{}) {
+		t.Fatalf("pong of version 0 mismatch: %v", pong)
+	}
+	for _, invalid := range [][]byte{legacy[:len(legacy)-1], append(legacy, 0)} {
+		if _, err := DeserializeWithCapabilities(invalid, &pong); err == nil {
+			t.Fatalf("invalid pong %x deserialized", invalid)
+		}
+	}
+}
+
+func TestUnsupportedOps(t *testing.T) {
+	var (
+		apis = []rpc.API{
+			{
+				Namespace: "rpc." + reflect.TypeOf(SlaveServerSideOp{}).Name(),
+				Version:   "3.0",
+				Service:   &SlaveServerSideOp{},
+				Public:    false,
+			},
+		}
+		cfg      = testSlaveConfig(3)
+		hostport = fmt.Sprintf("%s:%d", cfg.IP, cfg.Port)
+	)
+
+	listener, handler, err := StartGRPCServer(hostport, apis, false)
+	if err != nil {
+		t.Fatalf("failed to create grpc server %v", err)
+	}
+	defer handler.Stop()
+	defer listener.Close()
+
+	// the ops missing from the capabilities are not called
+	cli := NewClient(SlaveServer, nil)
+	cli.SetCapabilities(hostport, &Capabilities{ProtocolVersion: ProtocolVersion, Ops: []string{OpName(OpGetLogs)}})
+	if !cli.Supports(hostport, OpGetLogs) || cli.Supports(hostport, OpPing) {
+		t.Fatal("supported ops mismatch")
+	}
+	if _, err := cli.Call(hostport, &Request{Op: OpPing}); !IsUnsupportedOp(err) {
+		t.Fatalf("expected unsupported op error, got %v", err)
+	}
+
+	// without capabilities the ops the server doesn't have are learnt from the calls
+	cli.SetCapabilities(hostport, nil)
+	if !cli.Supports(hostport, OpPing) {
+		t.Fatal("op of a server of version 0 unsupported before the call")
+	}
+	if _, err := cli.Call(hostport, &Request{Op: OpPing}); !IsUnsupportedOp(err) {
+		t.Fatalf("expected unsupported op error, got %v", err)
+	}
+	if cli.Supports(hostport, OpPing) {
+		t.Fatal("unimplemented op still supported")
+	}
+	if _, err := cli.Stream(hostport, &Request{Op: OpPing}); !IsUnsupportedOp(err) {
+		t.Fatalf("expected unsupported op error, got %v", err)
+	}
+}
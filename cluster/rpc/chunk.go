@@ -0,0 +1,184 @@
+package rpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// The responses of the unary ops larger than the chunk size asked for by the
+// client are sent in chunks, for the large ones, e.g. the logs of a big range
+// of blocks, not to exceed the max gRPC message size: the server returns the
+// first chunk with a continuation token in the header, which the client sends
+// back to get the next chunk until the last one, and reassembles the data. The
+// servers which don't chunk ignore the chunk size, and the clients which don't
+// reassemble don't ask for chunks.
+const (
+	chunkSizeKey  = "qkc-chunk-size"
+	chunkTokenKey = "qkc-chunk-token"
+	chunkTotalKey = "qkc-chunk-total"
+
+	// maxChunkSize leaves room in the default max gRPC message size of 4MB
+	// for the other fields of the response
+	maxChunkSize = 4<<20 - 64<<10
+	// chunkTTL is how long the chunks not fetched yet are kept by the server
+	chunkTTL = time.Minute
+)
+
+// chunkSize is the chunk size in bytes asked for by the clients of the process,
+// 0 if they don't ask for chunks.
+var chunkSize int64
+
+// SetChunkSize has the clients of the process ask for the responses larger
+// than size KB to be sent in chunks of it, 0 disables it.
+func SetChunkSize(size uint32) {
+	bytes := int64(size) << 10
+	if bytes > maxChunkSize {
+		bytes = maxChunkSize
+	}
+	atomic.StoreInt64(&chunkSize, bytes)
+}
+
+// pendingChunks is the data of a response not fetched by the client yet.
+type pendingChunks struct {
+	data    []byte
+	expires time.Time
+}
+
+// chunkStore keeps the data of the chunked responses on the server side.
+type chunkStore struct {
+	mu      sync.Mutex
+	pending map[string]*pendingChunks // by continuation token
+}
+
+var chunks = &chunkStore{pending: make(map[string]*pendingChunks)}
+
+// put keeps the data and returns the token to fetch it.
+func (s *chunkStore) put(data []byte) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for t, p := range s.pending {
+		if now.After(p.expires) {
+			delete(s.pending, t)
+		}
+	}
+	s.pending[token] = &pendingChunks{data: data, expires: now.Add(chunkTTL)}
+	return token, nil
+}
+
+// next returns the next chunk of the size of the data of the token, and
+// whether there are more.
+func (s *chunkStore) next(token string, size int) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.pending[token]
+	if !ok {
+		return nil, false, status.Error(codes.NotFound, "unknown or expired continuation token")
+	}
+	if len(p.data) <= size {
+		delete(s.pending, token)
+		return p.data, false, nil
+	}
+	chunk := p.data[:size]
+	p.data, p.expires = p.data[size:], time.Now().Add(chunkTTL)
+	return chunk, true, nil
+}
+
+func firstMD(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// chunkUnaryInterceptor returns the next chunk of a response for a
+// continuation token, or calls the op and returns the first chunk of its
+// response if larger than the chunk size asked for.
+func chunkUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	size, _ := strconv.Atoi(firstMD(md, chunkSizeKey))
+	if token := firstMD(md, chunkTokenKey); token != "" && size > 0 {
+		data, more, err := chunks.next(token, size)
+		if err != nil {
+			return nil, err
+		}
+		if more {
+			if err := grpc.SetHeader(ctx, metadata.Pairs(chunkTokenKey, token)); err != nil {
+				return nil, err
+			}
+		}
+		return &Response{RpcId: req.(*Request).RpcId, Data: data}, nil
+	}
+
+	res, err := idempotentUnaryInterceptor(ctx, req, info, handler)
+	r, ok := res.(*Response)
+	if err != nil || !ok || size <= 0 || len(r.Data) <= size {
+		return res, err
+	}
+	token, err := chunks.put(r.Data[size:])
+	if err != nil {
+		return nil, err
+	}
+	if err := grpc.SetHeader(ctx, metadata.Pairs(chunkTokenKey, token, chunkTotalKey, strconv.Itoa(len(r.Data)))); err != nil {
+		return nil, err
+	}
+	return &Response{RpcId: r.RpcId, Data: r.Data[:size]}, nil
+}
+
+// chunkClientInterceptor asks for the responses larger than the chunk size to
+// be sent in chunks, and fetches and reassembles them.
+func chunkClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	size := atomic.LoadInt64(&chunkSize)
+	request, ok := req.(*Request)
+	if size == 0 || !ok {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx, chunkSizeKey, strconv.FormatInt(size, 10))
+	var header metadata.MD
+	if err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Header(&header))...); err != nil {
+		return err
+	}
+	token := firstMD(header, chunkTokenKey)
+	if token == "" {
+		return nil
+	}
+	res := reply.(*Response)
+	total, err := strconv.Atoi(firstMD(header, chunkTotalKey))
+	if err != nil {
+		return fmt.Errorf("invalid chunked response size: %v", err)
+	}
+	data := make([]byte, 0, total)
+	data = append(data, res.Data...)
+	// the request is not sent again, only its token
+	for token != "" {
+		next, nextHeader := new(Response), metadata.MD{}
+		nextCtx := metadata.AppendToOutgoingContext(ctx, chunkTokenKey, token)
+		if err := invoker(nextCtx, method, &Request{Op: request.Op, RpcId: request.RpcId}, next, cc, append(opts, grpc.Header(&nextHeader))...); err != nil {
+			return err
+		}
+		data = append(data, next.Data...)
+		token = firstMD(nextHeader, chunkTokenKey)
+	}
+	if len(data) != total {
+		return fmt.Errorf("chunked response of %d bytes, expected %d", len(data), total)
+	}
+	res.Data = data
+	return nil
+}
@@ -0,0 +1,83 @@
+package rpc
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/rpc"
+)
+
+func TestChunkedResponse(t *testing.T) {
+	var (
+		apis = []rpc.API{
+			{
+				Namespace: "rpc." + reflect.TypeOf(SlaveServerSideOp{}).Name(),
+				Version:   "3.0",
+				Service:   &SlaveServerSideOp{},
+				Public:    false,
+			},
+		}
+		cfg      = testSlaveConfig(5)
+		hostport = fmt.Sprintf("%s:%d", cfg.IP, cfg.Port)
+	)
+
+	listener, handler, err := StartGRPCServer(hostport, apis, false)
+	if err != nil {
+		t.Fatalf("failed to create grpc server %v", err)
+	}
+	defer handler.Stop()
+	defer listener.Close()
+	defer SetChunkSize(0)
+
+	call := func(size int) ([]byte, error) {
+		cli := NewClient(SlaveServer, nil)
+		defer cli.Close()
+		res, err := cli.Call(hostport, &Request{Op: OpGetMinorBlockList, Data: []byte(strconv.Itoa(size))})
+		if err != nil {
+			return nil, err
+		}
+		return res.Data, nil
+	}
+	check := func(data []byte, size int) {
+		if len(data) != size {
+			t.Fatalf("response of %d bytes, expected %d", len(data), size)
+		}
+		for i := range data {
+			if data[i] != byte(i) {
+				t.Fatalf("response byte %d mismatch", i)
+			}
+		}
+	}
+
+	// a response over the max gRPC message size fails without chunks
+	SetChunkSize(0)
+	size := 5 << 20
+	if _, err := call(size); err == nil {
+		t.Fatal("expected max message size error")
+	}
+
+	SetChunkSize(1024)
+	data, err := call(size)
+	if err != nil {
+		t.Fatalf("failed to call op with chunked response: %v", err)
+	}
+	check(data, size)
+
+	// the chunks are reassembled whatever the size of the last one
+	SetChunkSize(1)
+	for _, size := range []int{0, 1000, 1024, 4096, 10000} {
+		data, err := call(size)
+		if err != nil {
+			t.Fatalf("failed to call op with response of %d bytes: %v", size, err)
+		}
+		check(data, size)
+	}
+	chunks.mu.Lock()
+	pending := len(chunks.pending)
+	chunks.mu.Unlock()
+	if pending != 0 {
+		t.Fatalf("%d chunked responses not fetched", pending)
+	}
+}
@@ -0,0 +1,61 @@
+package rpc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/QuarkChain/goquarkchain/rpc"
+)
+
+// coalescedOps are the idempotent read ops of which the identical calls in
+// flight to a server are coalesced into one, e.g. the ones of the many JSON
+// RPC clients polling the latest block or the gas price. The ops cancelled
+// along with their JSON RPC calls, e.g. ExecuteTransaction or GetLogs, are not
+// coalesced, since a call shared by other clients can't be.
+var coalescedOps = map[string]bool{
+	"GetAccountData":                  true,
+	"GetMinorBlock":                   true,
+	"GetTransaction":                  true,
+	"GetTransactionReceipt":           true,
+	"GetTransactionListByAddress":     true,
+	"GetAllTx":                        true,
+	"GetStorageAt":                    true,
+	"GetCode":                         true,
+	"GasPrice":                        true,
+	"GetRootChainStakes":              true,
+	"GetBalanceHistory":               true,
+	"GetPendingAccountData":           true,
+	"GetMinorBlockReceipts":           true,
+	"GetMinorBlockList":               true,
+	"GetMinorBlockHeaderList":         true,
+	"GetMinorBlockHeaderListWithSkip": true,
+	"GetStateAvailability":            true,
+}
+
+// coalesceKey returns the key of the calls of the op with the request data to
+// the server at hostport.
+func coalesceKey(hostport, name string, data []byte) string {
+	digest := sha256.Sum256(data)
+	return hostport + "/" + name + "/" + hex.EncodeToString(digest[:])
+}
+
+// coalesce calls the op unless an identical call is in flight, in which case
+// it waits for the response of that one, shared by all its callers. The call
+// is not cancelled with ctx, but it is no longer waited for.
+func (c *rpcClient) coalesce(ctx context.Context, hostport string, req *Request) (*Response, error) {
+	key := coalesceKey(hostport, c.funcs[req.Op].name, req.Data)
+	ch := c.flights.DoChan(key, func() (interface{}, error) {
+		// logged with the request ID of the first caller
+		return c.call(rpc.WithRequestID(context.Background(), rpc.RequestIDFromContext(ctx)), hostport, req)
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case r := <-ch:
+		if r.Err != nil {
+			return nil, r.Err
+		}
+		return r.Val.(*Response), nil
+	}
+}
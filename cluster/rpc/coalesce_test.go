@@ -0,0 +1,95 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/rpc"
+)
+
+func TestCoalesce(t *testing.T) {
+	var (
+		service = &SlaveServerSideOp{release: make(chan struct{})}
+		apis    = []rpc.API{
+			{
+				Namespace: "rpc." + reflect.TypeOf(SlaveServerSideOp{}).Name(),
+				Version:   "3.0",
+				Service:   service,
+				Public:    false,
+			},
+		}
+		cfg      = testSlaveConfig(4)
+		hostport = fmt.Sprintf("%s:%d", cfg.IP, cfg.Port)
+	)
+
+	listener, handler, err := StartGRPCServer(hostport, apis, false)
+	if err != nil {
+		t.Fatalf("failed to create grpc server %v", err)
+	}
+	defer handler.Stop()
+	defer listener.Close()
+
+	// the identical calls in flight are served by one server side op, while
+	// the ones of other data are not coalesced with them
+	cli := NewClient(SlaveServer, nil)
+	var (
+		wg   sync.WaitGroup
+		errs = make(chan error, 11)
+	)
+	call := func(ctx context.Context, data string) {
+		defer wg.Done()
+		res, err := cli.CallContext(ctx, hostport, &Request{Op: OpGasPrice, Data: []byte(data)})
+		if err == nil && string(res.Data) != data {
+			err = fmt.Errorf("response %s of request %s", res.Data, data)
+		}
+		errs <- err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	wg.Add(11)
+	for i := 0; i < 8; i++ {
+		go call(context.Background(), "latest")
+	}
+	go call(context.Background(), "other")
+	go call(ctx, "latest")
+	go call(ctx, "latest")
+	for i := 0; atomic.LoadInt32(&service.gasPrice) < 2; i++ {
+		if i == 100 {
+			t.Fatal("server side ops not called")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// a caller gone doesn't cancel the call shared by the others
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	close(service.release)
+	wg.Wait()
+	close(errs)
+	canceled := 0
+	for err := range errs {
+		if err == context.Canceled {
+			canceled++
+		} else if err != nil {
+			t.Fatalf("coalesced call failed: %v", err)
+		}
+	}
+	if canceled != 2 {
+		t.Fatalf("%d calls canceled, expected 2", canceled)
+	}
+	if n := atomic.LoadInt32(&service.gasPrice); n != 2 {
+		t.Fatalf("%d server side ops called, expected 2", n)
+	}
+
+	// the calls done are not coalesced with the next ones
+	if _, err := cli.Call(hostport, &Request{Op: OpGasPrice, Data: []byte("latest")}); err != nil {
+		t.Fatalf("failed to call op: %v", err)
+	}
+	if n := atomic.LoadInt32(&service.gasPrice); n != 3 {
+		t.Fatalf("%d server side ops called, expected 3", n)
+	}
+}
@@ -0,0 +1,51 @@
+// +build faultinject
+
+package rpc
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/internal/fault"
+	"github.com/QuarkChain/goquarkchain/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCallWithFault(t *testing.T) {
+	var (
+		apis = []rpc.API{
+			{
+				Namespace: "rpc." + reflect.TypeOf(MasterServerSideOp{}).Name(),
+				Version:   "3.0",
+				Service:   NewMasterTestOp(),
+				Public:    false,
+			},
+		}
+		cfg      = testSlaveConfig(2)
+		hostport = fmt.Sprintf("%s:%d", cfg.IP, cfg.Port)
+	)
+	listener, handler, err := StartGRPCServer(hostport, apis, false)
+	if err != nil {
+		t.Fatalf("failed to create grpc server %v", err)
+	}
+	defer handler.Stop()
+	defer listener.Close()
+	defer fault.Clear()
+
+	cli := NewClient(MasterServer, nil).(*rpcClient)
+	assert.NoError(t, fault.Set([]fault.Rule{
+		{Layer: fault.LayerRPC, Op: "AddMinorBlockHeader", Peer: "master", Action: fault.ActionDrop, Count: 1},
+		{Layer: fault.LayerRPC, Op: "AddMinorBlockHeader", Peer: hostport, Action: fault.ActionCorrupt, Count: 1},
+	}))
+	_, err = cli.Call(hostport, &Request{Op: OpAddMinorBlockHeader})
+	assert.Equal(t, fault.ErrDropped, err)
+
+	res, err := cli.Call(hostport, &Request{Op: OpAddMinorBlockHeader})
+	assert.NoError(t, err)
+	assert.Equal(t, fault.Corrupt([]byte("AddMinorBlockHeader response"), 0), res.Data)
+
+	res, err = cli.Call(hostport, &Request{Op: OpAddMinorBlockHeader})
+	assert.NoError(t, err)
+	assert.Equal(t, "AddMinorBlockHeader response", string(res.Data))
+}
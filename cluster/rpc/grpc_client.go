@@ -4,12 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"reflect"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/QuarkChain/goquarkchain/internal/fault"
 	"github.com/ethereum/go-ethereum/log"
+	"golang.org/x/sync/singleflight"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/connectivity"
 )
@@ -60,6 +63,33 @@ const (
 	OpSetMining
 	OpAddMinorBlockHeaderList
 	OpCheckMinorBlocksInRoot
+	OpGetStaleBlocks
+	OpGetUnreceivedXShardDeposits
+	OpCreateAccessList
+	OpReloadConfig
+	OpProfile
+	OpSetLogLevel
+	OpGetSlowOps
+	OpGetBlockProfiles
+	OpGetTxBenchmarkReports
+	OpReplayBlocks
+	OpBackupShard
+	OpGetShardRootTips
+	OpResendXshardTxList
+	OpGetShardSnapshot
+	OpGetBalanceHistory
+	OpGetXShardQueues
+	OpShardMaintenance
+	OpGetPendingAccountData
+	OpExecutePendingTransaction
+	OpGetMinorBlockReceipts
+	OpTraceBlock
+	OpSimulateBundle
+	OpGetStateAvailability
+	OpRegenerateState
+	OpExportBlocks
+	OpBootstrapShard
+	OpShardBootstrapped
 
 	MasterServer = serverType(1)
 	SlaveServer  = serverType(0)
@@ -79,6 +109,8 @@ var (
 		OpGetMinorBlockList:               {name: "GetMinorBlockList"},
 		OpGetMinorBlockHeaderList:         {name: "GetMinorBlockHeaderList"},
 		OpGetMinorBlockHeaderListWithSkip: {name: "GetMinorBlockHeaderListWithSkip"},
+		OpBootstrapShard:                  {name: "BootstrapShard"},
+		OpShardBootstrapped:               {name: "ShardBootstrapped"},
 	}
 	// slave apis
 	slaveApis = map[uint32]opType{
@@ -112,6 +144,31 @@ var (
 		OpSetMining:                   {name: "SetMining"},
 		OpCheckMinorBlocksInRoot:      {name: "CheckMinorBlocksInRoot"},
 		OpGetRootChainStakes:          {name: "GetRootChainStakes"},
+		OpGetStaleBlocks:              {name: "GetStaleBlocks"},
+		OpGetUnreceivedXShardDeposits: {name: "GetUnreceivedXShardDeposits"},
+		OpCreateAccessList:            {name: "CreateAccessList"},
+		OpReloadConfig:                {name: "ReloadConfig"},
+		OpProfile:                     {name: "Profile"},
+		OpSetLogLevel:                 {name: "SetLogLevel"},
+		OpGetSlowOps:                  {name: "GetSlowOps"},
+		OpGetBlockProfiles:            {name: "GetBlockProfiles"},
+		OpGetTxBenchmarkReports:       {name: "GetTxBenchmarkReports"},
+		OpReplayBlocks:                {name: "ReplayBlocks"},
+		OpBackupShard:                 {name: "BackupShard"},
+		OpGetShardRootTips:            {name: "GetShardRootTips"},
+		OpResendXshardTxList:          {name: "ResendXshardTxList"},
+		OpGetShardSnapshot:            {name: "GetShardSnapshot"},
+		OpGetBalanceHistory:           {name: "GetBalanceHistory"},
+		OpGetXShardQueues:             {name: "GetXShardQueues"},
+		OpShardMaintenance:            {name: "ShardMaintenance"},
+		OpGetPendingAccountData:       {name: "GetPendingAccountData"},
+		OpExecutePendingTransaction:   {name: "ExecutePendingTransaction"},
+		OpGetMinorBlockReceipts:       {name: "GetMinorBlockReceipts"},
+		OpTraceBlock:                  {name: "TraceBlock"},
+		OpSimulateBundle:              {name: "SimulateBundle"},
+		OpGetStateAvailability:        {name: "GetStateAvailability"},
+		OpRegenerateState:             {name: "RegenerateState"},
+		OpExportBlocks:                {name: "ExportBlocks"},
 		// p2p api
 		OpGetMinorBlockList:               {name: "GetMinorBlockList"},
 		OpGetMinorBlockHeaderList:         {name: "GetMinorBlockHeaderList"},
@@ -134,7 +191,21 @@ type opNode struct {
 // Client wraps the GRPC client.
 type Client interface {
 	Call(hostport string, req *Request) (*Response, error)
+	// CallContext calls the op until ctx is done, the server side op is
+	// canceled along with the call, e.g. once the caller of the master's JSON
+	// RPC has gone. The identical calls of the idempotent read ops in flight
+	// are coalesced into one, which is not canceled.
+	CallContext(ctx context.Context, hostport string, req *Request) (*Response, error)
+	Stream(hostport string, req *Request) (io.ReadCloser, error)
+	// StreamContext calls the streaming op until ctx is done or the reader
+	// returned is closed.
+	StreamContext(ctx context.Context, hostport string, req *Request) (io.ReadCloser, error)
 	GetOpName(uint32) string
+	// SetCapabilities sets the capabilities the server at hostport sent in
+	// the handshake, the calls of the ops it doesn't serve fail at once with
+	// an UnsupportedOpError.
+	SetCapabilities(hostport string, caps *Capabilities)
+	Supports(hostport string, op uint32) bool
 	Close()
 }
 
@@ -142,6 +213,11 @@ type rpcClient struct {
 	connVals map[string]*opNode
 	funcs    map[uint32]opType
 
+	caps        map[string]*Capabilities   // by hostport
+	unsupported map[string]map[string]bool // ops by hostport, learnt from the calls
+	flights     singleflight.Group         // of the coalesced calls
+	slowOps     *SlowOpLog                 // nil if the slow ops are not logged
+
 	mu      sync.RWMutex
 	timeout time.Duration
 	tp      serverType
@@ -154,12 +230,51 @@ func (c *rpcClient) GetOpName(op uint32) string {
 }
 
 func (c *rpcClient) Call(hostport string, req *Request) (*Response, error) {
+	return c.CallContext(context.Background(), hostport, req)
+}
+
+func (c *rpcClient) CallContext(ctx context.Context, hostport string, req *Request) (*Response, error) {
 	_, ok := c.funcs[req.Op]
 	if !ok {
 		return nil, errors.New("invalid op")
 	}
+	if !c.Supports(hostport, req.Op) {
+		return nil, &UnsupportedOpError{Op: c.funcs[req.Op].name, Target: hostport}
+	}
+	if coalescedOps[c.funcs[req.Op].name] {
+		return c.coalesce(ctx, hostport, req)
+	}
+	return c.call(withIdempotencyKey(ctx, c.funcs[req.Op].name), hostport, req)
+}
+
+func (c *rpcClient) call(ctx context.Context, hostport string, req *Request) (*Response, error) {
 	req.RpcId = c.addRpcId()
-	return c.grpcOp(hostport, req)
+	if fault.Enabled {
+		return c.callWithFault(ctx, hostport, req)
+	}
+	start := time.Now()
+	res, err := c.grpcOp(ctx, hostport, req)
+	c.slowOps.observe(ctx, c.tp, hostport, c.funcs[req.Op].name, start, req, res, err)
+	return res, c.checkUnimplemented(hostport, req.Op, err)
+}
+
+// callWithFault calls the op after injecting the fault of the first rule
+// matching it, in the binaries built with the faultinject build tag.
+func (c *rpcClient) callWithFault(ctx context.Context, hostport string, req *Request) (*Response, error) {
+	name := c.funcs[req.Op].name
+	peer := c.slowOps.peer(c.tp, hostport)
+	flt := fault.Inject(fault.LayerRPC, name, peer, hostport)
+	if flt.Action == fault.ActionDrop {
+		return nil, fault.ErrDropped
+	}
+	start := time.Now()
+	time.Sleep(flt.Delay)
+	res, err := c.grpcOp(ctx, hostport, req)
+	if flt.Action == fault.ActionCorrupt && res != nil {
+		res.Data = fault.Corrupt(res.Data, 0)
+	}
+	c.slowOps.observe(ctx, c.tp, hostport, name, start, req, res, err)
+	return res, c.checkUnimplemented(hostport, req.Op, err)
 }
 
 func (c *rpcClient) Close() {
@@ -184,13 +299,14 @@ func (c *rpcClient) getConn(hostport string) (*opNode, error) {
 	return node, nil
 }
 
-func (c *rpcClient) grpcOp(hostport string, req *Request) (*Response, error) {
+func (c *rpcClient) grpcOp(ctx context.Context, hostport string, req *Request) (*Response, error) {
 
 	node, err := c.getConn(hostport)
 	if err != nil {
 		return nil, err
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	// the deadline is sent along to the server side op
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
 	defer cancel()
 
 	var (
@@ -211,7 +327,8 @@ func (c *rpcClient) grpcOp(hostport string, req *Request) (*Response, error) {
 }
 
 func (c *rpcClient) addConn(hostport string) (*opNode, error) {
-	opts := []grpc.DialOption{grpc.WithInsecure()}
+	opts := []grpc.DialOption{grpc.WithInsecure(), grpc.WithUnaryInterceptor(requestIDClientInterceptor),
+		grpc.WithStreamInterceptor(requestIDStreamClientInterceptor)}
 	conn, err := grpc.Dial(hostport, opts...)
 	if err != nil {
 		return nil, err
@@ -238,8 +355,9 @@ func (c *rpcClient) addRpcId() int64 {
 	return atomic.AddInt64(&c.rpcId, 1)
 }
 
-// NewClient returns a new GRPC client wrapper.
-func NewClient(serverType serverType) Client {
+// NewClient returns a new GRPC client wrapper logging its slow ops to slowOps,
+// if not nil.
+func NewClient(serverType serverType, slowOps *SlowOpLog) Client {
 	rpcFuncs := masterApis
 	if serverType == SlaveServer {
 		rpcFuncs = slaveApis
@@ -247,10 +365,13 @@ func NewClient(serverType serverType) Client {
 		return nil
 	}
 	return &rpcClient{
-		connVals: make(map[string]*opNode),
-		funcs:    rpcFuncs,
-		tp:       serverType,
-		timeout:  time.Duration(timeOut) * time.Second,
-		logger:   log.New("rpcclient"),
+		connVals:    make(map[string]*opNode),
+		funcs:       rpcFuncs,
+		caps:        make(map[string]*Capabilities),
+		unsupported: make(map[string]map[string]bool),
+		slowOps:     slowOps,
+		tp:          serverType,
+		timeout:     time.Duration(timeOut) * time.Second,
+		logger:      log.New("rpcclient"),
 	}
 }
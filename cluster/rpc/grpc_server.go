@@ -5,13 +5,16 @@ import (
 	qcom "github.com/QuarkChain/goquarkchain/common"
 	"github.com/QuarkChain/goquarkchain/rpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
 	"net"
 	"reflect"
 	"strings"
 )
 
-func StartGRPCServer(hostport string, apis []rpc.API) (net.Listener, *grpc.Server, error) {
-	handler := grpc.NewServer()
+// StartGRPCServer serves the cluster ops of the apis on the host and port, and
+// the gRPC reflection service describing them if enableReflection is set.
+func StartGRPCServer(hostport string, apis []rpc.API, enableReflection bool) (net.Listener, *grpc.Server, error) {
+	handler := grpc.NewServer(grpc.UnaryInterceptor(requestIDUnaryInterceptor), grpc.StreamInterceptor(requestIDStreamInterceptor))
 	for _, api := range apis {
 		if qcom.IsNil(api.Service) {
 			panic(fmt.Sprintf("%s service is nil", api.Namespace))
@@ -28,6 +31,9 @@ func StartGRPCServer(hostport string, apis []rpc.API) (net.Listener, *grpc.Serve
 			handler.RegisterService(&_SlaveServerSideOp_serviceDesc, api.Service)
 		}
 	}
+	if enableReflection {
+		reflection.Register(handler)
+	}
 	var (
 		listener net.Listener
 		err      error
@@ -0,0 +1,121 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+)
+
+// streamChunkSize is the size of the data sent in each response of a stream,
+// well below the 4MB message limit of gRPC.
+const streamChunkSize = 1024 * 1024
+
+// Stream calls a server streaming op, and returns a reader of the data of its
+// responses, which has to be closed to end the stream. The op has no timeout,
+// as a stream may take long to be read.
+func (c *rpcClient) Stream(hostport string, req *Request) (io.ReadCloser, error) {
+	return c.StreamContext(context.Background(), hostport, req)
+}
+
+func (c *rpcClient) StreamContext(ctx context.Context, hostport string, req *Request) (io.ReadCloser, error) {
+	_, ok := c.funcs[req.Op]
+	if !ok {
+		return nil, errors.New("invalid op")
+	}
+	if !c.Supports(hostport, req.Op) {
+		return nil, &UnsupportedOpError{Op: c.funcs[req.Op].name, Target: hostport}
+	}
+	req.RpcId = c.addRpcId()
+	node, err := c.getConn(hostport)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	val := []reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(req)}
+	rs := node.client.MethodByName(c.funcs[req.Op].name).Call(val)
+	if !rs[1].IsNil() {
+		cancel()
+		return nil, c.checkUnimplemented(hostport, req.Op, rs[1].Interface().(error))
+	}
+	stream, ok := rs[0].Interface().(responseStream)
+	if !ok {
+		cancel()
+		return nil, errors.New("not a streaming op")
+	}
+	checkErr := func(err error) error { return c.checkUnimplemented(hostport, req.Op, err) }
+	return &streamReader{stream: stream, cancel: cancel, checkErr: checkErr}, nil
+}
+
+type responseStream interface {
+	Recv() (*Response, error)
+}
+
+// streamReader reads the data of the responses of a stream.
+type streamReader struct {
+	stream   responseStream
+	cancel   context.CancelFunc
+	checkErr func(error) error // of the op not served, received with the first response
+	buf      []byte
+}
+
+func (r *streamReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		res, err := r.stream.Recv()
+		if err != nil {
+			return 0, r.checkErr(err)
+		}
+		r.buf = res.Data
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (r *streamReader) Close() error {
+	r.cancel()
+	return nil
+}
+
+// StreamWriter sends the data written to it in the responses of a stream, cut
+// at streamChunkSize. Flush sends the data left.
+type StreamWriter struct {
+	send  func(*Response) error
+	rpcId int64
+	buf   []byte
+}
+
+// NewStreamWriter returns a StreamWriter sending the responses with send, e.g.
+// the Send method of the server side of a stream, for the request with rpcId.
+func NewStreamWriter(send func(*Response) error, rpcId int64) *StreamWriter {
+	return &StreamWriter{send: send, rpcId: rpcId, buf: make([]byte, 0, streamChunkSize)}
+}
+
+func (w *StreamWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		written += n
+		if len(w.buf) == cap(w.buf) {
+			if err := w.Flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Flush sends the data written since the last response.
+func (w *StreamWriter) Flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	// the response is encoded by the time send returns, so the buffer is reused
+	if err := w.send(&Response{RpcId: w.rpcId, Data: w.buf}); err != nil {
+		return err
+	}
+	w.buf = w.buf[:0]
+	return nil
+}
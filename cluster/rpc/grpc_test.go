@@ -1,12 +1,18 @@
 package rpc
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io/ioutil"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/QuarkChain/goquarkchain/cluster/config"
 	"github.com/QuarkChain/goquarkchain/rpc"
+	"google.golang.org/grpc"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
 )
 
 func testSlaveConfig(idx uint16) *config.SlaveConfig {
@@ -32,13 +38,13 @@ func TestGRPCAPI(t *testing.T) {
 		hostport = fmt.Sprintf("%s:%d", cfg.IP, cfg.Port)
 	)
 
-	listener, handler, err := StartGRPCServer(hostport, apis)
+	listener, handler, err := StartGRPCServer(hostport, apis, false)
 	if err != nil {
 		t.Fatalf("failed to create grpc server %v", err)
 	}
 
 	// create rpc client and request AddMinorBlockHeader function
-	cli := NewClient(MasterServer).(*rpcClient)
+	cli := NewClient(MasterServer, nil).(*rpcClient)
 	rpcId := cli.rpcId + 1
 	res, err := cli.Call(hostport, &Request{Op: OpAddMinorBlockHeader, Data: []byte(fmt.Sprintf("%s op request", cli.GetOpName(OpAddMinorBlockHeader)))})
 	if err != nil {
@@ -58,3 +64,159 @@ func TestGRPCAPI(t *testing.T) {
 	}
 	handler.Stop()
 }
+
+func TestGRPCCallContext(t *testing.T) {
+	var (
+		service = &SlaveServerSideOp{canceled: make(chan error, 1)}
+		apis    = []rpc.API{
+			{
+				Namespace: "rpc." + reflect.TypeOf(SlaveServerSideOp{}).Name(),
+				Version:   "3.0",
+				Service:   service,
+				Public:    false,
+			},
+		}
+		cfg      = testSlaveConfig(2)
+		hostport = fmt.Sprintf("%s:%d", cfg.IP, cfg.Port)
+	)
+
+	listener, handler, err := StartGRPCServer(hostport, apis, false)
+	if err != nil {
+		t.Fatalf("failed to create grpc server %v", err)
+	}
+	defer handler.Stop()
+	defer listener.Close()
+
+	// the server side op is canceled along with the call
+	cli := NewClient(SlaveServer, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	if _, err := cli.CallContext(ctx, hostport, &Request{Op: OpGetLogs}); err == nil {
+		t.Fatal("expected canceled call error")
+	}
+	select {
+	case err := <-service.canceled:
+		if err != context.Canceled {
+			t.Fatalf("server side op stopped by %v, expected canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server side op not canceled")
+	}
+
+	// so is it once the deadline sent along is exceeded
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := cli.CallContext(ctx, hostport, &Request{Op: OpGetLogs}); err == nil {
+		t.Fatal("expected deadline exceeded error")
+	}
+	select {
+	case err := <-service.canceled:
+		if err == nil {
+			t.Fatal("server side op stopped without error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server side op not canceled")
+	}
+}
+
+func TestGRPCStream(t *testing.T) {
+	var (
+		apis = []rpc.API{
+			{
+				Namespace: "rpc." + reflect.TypeOf(SlaveServerSideOp{}).Name(),
+				Version:   "3.0",
+				Service:   &SlaveServerSideOp{},
+				Public:    false,
+			},
+		}
+		cfg      = testSlaveConfig(1)
+		hostport = fmt.Sprintf("%s:%d", cfg.IP, cfg.Port)
+		data     = bytes.Repeat([]byte("snapshot"), streamChunkSize/4)
+	)
+
+	listener, handler, err := StartGRPCServer(hostport, apis, false)
+	if err != nil {
+		t.Fatalf("failed to create grpc server %v", err)
+	}
+	defer handler.Stop()
+	defer listener.Close()
+
+	// the data is streamed in more responses than a chunk
+	cli := NewClient(SlaveServer, nil)
+	r, err := cli.Stream(hostport, &Request{Op: OpGetShardSnapshot, Data: data})
+	if err != nil {
+		t.Fatalf("failed to call stream op: %v", err)
+	}
+	defer r.Close()
+	read, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("failed to read stream: %v", err)
+	}
+	if !bytes.Equal(read, append(data, data...)) {
+		t.Fatalf("stream data mismatch, read %d bytes, expected %d", len(read), 2*len(data))
+	}
+
+	if _, err := cli.Stream(hostport, &Request{Op: OpGetShardSnapshot + 1000}); err == nil {
+		t.Fatal("expected invalid op error")
+	}
+}
+
+func TestGRPCReflection(t *testing.T) {
+	var (
+		apis = []rpc.API{
+			{
+				Namespace: "rpc." + reflect.TypeOf(SlaveServerSideOp{}).Name(),
+				Version:   "3.0",
+				Service:   &SlaveServerSideOp{},
+				Public:    false,
+			},
+		}
+		cfg      = testSlaveConfig(3)
+		hostport = fmt.Sprintf("%s:%d", cfg.IP, cfg.Port)
+	)
+
+	listener, handler, err := StartGRPCServer(hostport, apis, true)
+	if err != nil {
+		t.Fatalf("failed to create grpc server %v", err)
+	}
+	defer handler.Stop()
+	defer listener.Close()
+
+	conn, err := grpc.Dial(hostport, grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &rpb.ServerReflectionRequest{MessageRequest: &rpb.ServerReflectionRequest_ListServices{}}
+	if err := stream.Send(req); err != nil {
+		t.Fatal(err)
+	}
+	res, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	services := make([]string, 0)
+	for _, svc := range res.GetListServicesResponse().GetService() {
+		services = append(services, svc.GetName())
+	}
+	if !reflect.DeepEqual(services, []string{"grpc.reflection.v1alpha.ServerReflection", "rpc.SlaveServerSideOp"}) {
+		t.Fatalf("unexpected services %v", services)
+	}
+
+	// the ops of the service are described
+	req = &rpb.ServerReflectionRequest{MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{
+		FileContainingSymbol: "rpc.SlaveServerSideOp"}}
+	if err := stream.Send(req); err != nil {
+		t.Fatal(err)
+	}
+	if res, err = stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.GetFileDescriptorResponse().GetFileDescriptorProto()) == 0 {
+		t.Fatalf("no file descriptor of the service: %v", res.GetErrorResponse())
+	}
+}
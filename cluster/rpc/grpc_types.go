@@ -1,6 +1,8 @@
 package rpc
 
 import (
+	"errors"
+
 	"github.com/QuarkChain/goquarkchain/account"
 	"github.com/QuarkChain/goquarkchain/core/types"
 	"github.com/QuarkChain/goquarkchain/p2p"
@@ -14,11 +16,13 @@ import (
 type Ping struct {
 	Id            []byte             `json:"id" bytesizeofslicelen:"4"`
 	ChainMaskList []*types.ChainMask `json:"chain_mask_list" bytesizeofslicelen:"4"`
+	GenesisHash   common.Hash        `json:"genesis_hash"`
 }
 
 type Pong struct {
 	Id            []byte             `json:"id" gencodec:"required" bytesizeofslicelen:"4"`
 	ChainMaskList []*types.ChainMask `json:"chain_mask_list" gencodec:"required" bytesizeofslicelen:"4"`
+	GenesisHash   common.Hash        `json:"genesis_hash" gencodec:"required"`
 }
 
 type SlaveInfo struct {
@@ -76,11 +80,15 @@ type MineRequest struct {
 	Mining             bool                `json:"mining" gencodec:"required"`
 }
 
-// Generate transactions for loadtesting
+// Generate transactions for loadtesting, NumTxPerShard of them at once, or TPS
+// of them each second in each shard for Duration seconds as a benchmark.
 type GenTxRequest struct {
 	NumTxPerShard uint32             `json:"num_tx_per_shard" gencodec:"required"`
 	XShardPercent uint32             `json:"x_shard_percent" gencodec:"required"`
 	Tx            *types.Transaction `json:"tx" gencodec:"required"`
+	TPS           uint32             `json:"tps" gencodec:"required"`
+	Duration      uint32             `json:"duration" gencodec:"required"`
+	Seed          uint64             `json:"seed" gencodec:"required"` // seeds the random txs to repeat them if not 0
 }
 
 // RPCs to lookup data from shards (master -> slaves)
@@ -162,12 +170,41 @@ type ExecuteTransactionRequest struct {
 	Tx          *types.Transaction `json:"tx" gencodec:"required"`
 	FromAddress *account.Address   `json:"from_address" gencodec:"required"`
 	BlockHeight *uint64            `json:"block_height" ser:"nil"`
+	Overrides   []*AccountOverride `json:"overrides"`
+}
+
+// AccountOverride overrides the state of an account on the ephemeral state a
+// transaction is executed on, the nil fields are not overridden.
+type AccountOverride struct {
+	Address  common.Address       `json:"address" gencodec:"required"`
+	Balances *types.TokenBalances `json:"balances" ser:"nil"`
+	Nonce    *uint64              `json:"nonce" ser:"nil"`
+	Code     *[]byte              `json:"code" ser:"nil"`
+	Storage  []*StorageOverride   `json:"storage"`
+}
+
+// StorageOverride sets a storage slot of an overridden account.
+type StorageOverride struct {
+	Key   common.Hash `json:"key" gencodec:"required"`
+	Value common.Hash `json:"value" gencodec:"required"`
 }
 
 type ExecuteTransactionResponse struct {
 	Result []byte `json:"result" gencodec:"required" bytesizeofslicelen:"4"`
 }
 
+type CreateAccessListRequest struct {
+	Tx          *types.Transaction `json:"tx" gencodec:"required"`
+	FromAddress *account.Address   `json:"from_address" gencodec:"required"`
+	BlockHeight *uint64            `json:"block_height" ser:"nil"`
+}
+
+type CreateAccessListResponse struct {
+	AccessList types.AccessList `json:"access_list" gencodec:"required"`
+	GasUsed    uint64           `json:"gas_used" gencodec:"required"`
+	Failed     bool             `json:"failed" gencodec:"required"`
+}
+
 type GetTransactionReceiptRequest struct {
 	TxHash common.Hash `json:"tx_hash" gencodec:"required"`
 	Branch uint32      `json:"branch" gencodec:"required"`
@@ -272,12 +309,23 @@ type AccountBranchData struct {
 	IsContract         bool                 `json:"is_contract" gencodec:"required"`
 	PoswMineableBlocks uint64               `json:"posw_mineable_blocks" gencodec:"required"`
 	MinedBlocks        uint64               `json:"mined_blocks" gencodec:"required"`
+	PendingTxCount     uint64               `json:"pending_tx_count" gencodec:"required"` // txs of the account in the pool now
+	QueuedTxCount      uint64               `json:"queued_tx_count" gencodec:"required"`
+	Error              string               `json:"error" gencodec:"required"` // the data of the shard failed to be read if not empty
 }
 
 type GetAccountDataResponse struct {
 	AccountBranchDataList []*AccountBranchData `json:"account_branch_data_list" gencodec:"required" bytesizeofslicelen:"4"`
 }
 
+// TotalBalance is the balances of an account summed over the shards by token,
+// built by the master from the account data of each shard.
+type TotalBalance struct {
+	Balances *types.TokenBalances
+	Shards   []uint32          // full shard ids of the shards summed
+	Errors   map[uint32]string // the shards failed to be read, not summed
+}
+
 type AddTransactionRequest struct {
 	Tx *types.Transaction `json:"tx" gencodec:"required"`
 }
@@ -417,3 +465,343 @@ type P2PRedirectRequest struct {
 	Branch uint32
 	Data   []byte `json:"data" gencodec:"required" bytesizeofslicelen:"4"` // *p2p.NewTransactionList
 }
+
+// UnreceivedXShardDeposit is a cross-shard deposit confirmed by root chain
+// which is not applied by the target shard yet.
+type UnreceivedXShardDeposit struct {
+	RootBlockHeight uint64                              `json:"root_block_height" gencodec:"required"`
+	Expired         bool                                `json:"expired" gencodec:"required"`
+	Deposit         *types.CrossShardTransactionDeposit `json:"deposit" gencodec:"required"`
+}
+
+// XShardQueue sums up the cross-shard deposits confirmed by root chain which
+// are not applied by the target shard yet.
+type XShardQueue struct {
+	Branch           uint32               `json:"branch" gencodec:"required"`
+	CursorRootHeight uint64               `json:"cursor_root_height" gencodec:"required"` // root block the shard applies the deposits of
+	RootTipHeight    uint64               `json:"root_tip_height" gencodec:"required"`
+	Count            uint32               `json:"count" gencodec:"required"`
+	TotalValue       *types.TokenBalances `json:"total_value" gencodec:"required"`
+	OldestRootHeight uint64               `json:"oldest_root_height" gencodec:"required"` // root block confirming the oldest deposit, 0 if none
+	MissingTxLists   uint32               `json:"missing_tx_lists" gencodec:"required"`   // neighbor blocks confirmed without their xshard tx lists
+}
+
+type GetXShardQueuesResponse struct {
+	Queues []*XShardQueue `json:"queues" gencodec:"required" bytesizeofslicelen:"4"`
+}
+
+type GetUnreceivedXShardDepositsRequest struct {
+	Branch uint32 `json:"branch" gencodec:"required"`
+	Limit  uint32 `json:"limit" gencodec:"required"`
+}
+
+type GetUnreceivedXShardDepositsResponse struct {
+	Deposits []*UnreceivedXShardDeposit `json:"deposits" gencodec:"required" bytesizeofslicelen:"4"`
+}
+
+// StaleBlock describes a block which lost fork choice.
+type StaleBlock struct {
+	Hash       common.Hash     `json:"hash" gencodec:"required"`
+	Height     uint64          `json:"height" gencodec:"required"`
+	Coinbase   account.Address `json:"coinbase" gencodec:"required"`
+	CreateTime uint64          `json:"create_time" gencodec:"required"`
+	DetectTime uint64          `json:"detect_time" gencodec:"required"`
+}
+
+type GetStaleBlocksRequest struct {
+	Branch uint32 `json:"branch" gencodec:"required"`
+	Limit  uint32 `json:"limit" gencodec:"required"`
+}
+
+type GetStaleBlocksResponse struct {
+	Total       uint64        `json:"total" gencodec:"required"`
+	StaleBlocks []*StaleBlock `json:"stale_blocks" gencodec:"required" bytesizeofslicelen:"4"`
+}
+
+// SetLogLevelRequest asks a slave to set its verbosity, or the log level of the
+// module if not empty.
+type SetLogLevelRequest struct {
+	Module string `json:"module" gencodec:"required"`
+	Level  string `json:"level" gencodec:"required"`
+}
+
+// ProfileRequest asks a slave to run a profiling action of debug.Handler.Profile.
+type ProfileRequest struct {
+	Action string `json:"action" gencodec:"required"`
+	File   string `json:"file" gencodec:"required"`
+	Rate   uint32 `json:"rate" gencodec:"required"`
+}
+
+// GetSlowOpsRequest asks a slave for its last slow cluster RPC ops.
+type GetSlowOpsRequest struct {
+	Count uint32 `json:"count" gencodec:"required"`
+}
+
+type GetSlowOpsResponse struct {
+	SlowOps []*SlowOp `json:"slow_ops" gencodec:"required" bytesizeofslicelen:"4"`
+}
+
+// BlockProfile is the time a minor block produced by the node spent in each
+// step of its production, in microseconds.
+type BlockProfile struct {
+	Hash        common.Hash `json:"hash" gencodec:"required"`
+	Height      uint64      `json:"height" gencodec:"required"`
+	TxCount     uint32      `json:"tx_count" gencodec:"required"`
+	GasUsed     uint64      `json:"gas_used" gencodec:"required"`
+	GasLimit    uint64      `json:"gas_limit" gencodec:"required"`
+	Time        uint64      `json:"time" gencodec:"required"`         // unix time in milliseconds the creation started at
+	TxSelection uint64      `json:"tx_selection" gencodec:"required"` // pending txs sorted and picked from the pool
+	Execution   uint64      `json:"execution" gencodec:"required"`    // xshard deposits and txs applied to the state
+	StateRoot   uint64      `json:"state_root" gencodec:"required"`   // state trie hashed for the header
+	SealWait    uint64      `json:"seal_wait" gencodec:"required"`    // from the block created to its sealed block inserted
+	Commit      uint64      `json:"commit" gencodec:"required"`       // sealed block and state trie written to the db
+}
+
+type GetBlockProfilesRequest struct {
+	Branch uint32 `json:"branch" gencodec:"required"`
+	Limit  uint32 `json:"limit" gencodec:"required"`
+}
+
+type GetBlockProfilesResponse struct {
+	BlockProfiles []*BlockProfile `json:"block_profiles" gencodec:"required" bytesizeofslicelen:"4"`
+}
+
+// TxBenchmarkReport is the result of the last tx benchmark of a shard, the
+// durations are in milliseconds.
+type TxBenchmarkReport struct {
+	Branch     uint32 `json:"branch" gencodec:"required"`
+	Running    bool   `json:"running" gencodec:"required"`
+	TPS        uint32 `json:"tps" gencodec:"required"`       // target
+	Start      uint64 `json:"start" gencodec:"required"`     // unix time in milliseconds
+	Elapsed    uint64 `json:"elapsed" gencodec:"required"`   // until now, the end or the last tx confirmed after the end
+	Sent       uint64 `json:"sent" gencodec:"required"`      // txs added to the pool
+	Confirmed  uint64 `json:"confirmed" gencodec:"required"` // txs sent included in the canonical chain
+	LatencyP50 uint64 `json:"latency_p50" gencodec:"required"`
+	LatencyP90 uint64 `json:"latency_p90" gencodec:"required"`
+	LatencyP99 uint64 `json:"latency_p99" gencodec:"required"`
+	LatencyMax uint64 `json:"latency_max" gencodec:"required"`
+}
+
+type GetTxBenchmarkReportsResponse struct {
+	Reports []*TxBenchmarkReport `json:"reports" gencodec:"required" bytesizeofslicelen:"4"`
+}
+
+// BlockReplayResult is the result of a canonical minor block re-executed on
+// the state of its parent, against the values stored with the block.
+type BlockReplayResult struct {
+	Number              uint64      `json:"number" gencodec:"required"`
+	Hash                common.Hash `json:"hash" gencodec:"required"`
+	TxCount             uint32      `json:"tx_count" gencodec:"required"`
+	StateRoot           common.Hash `json:"state_root" gencodec:"required"`
+	ExpectedStateRoot   common.Hash `json:"expected_state_root" gencodec:"required"`
+	ReceiptHash         common.Hash `json:"receipt_hash" gencodec:"required"`
+	ExpectedReceiptHash common.Hash `json:"expected_receipt_hash" gencodec:"required"`
+	GasUsed             uint64      `json:"gas_used" gencodec:"required"`
+	ExpectedGasUsed     uint64      `json:"expected_gas_used" gencodec:"required"`
+	BadReceipts         []uint32    `json:"bad_receipts" gencodec:"required" bytesizeofslicelen:"4"` // indexes of the receipts differing from the stored ones
+	Error               string      `json:"error" gencodec:"required"`                               // the block failed to be re-executed
+}
+
+// Matched reports whether the block was re-executed to the stored values.
+func (r *BlockReplayResult) Matched() bool {
+	return r.Error == "" && r.StateRoot == r.ExpectedStateRoot && r.ReceiptHash == r.ExpectedReceiptHash &&
+		r.GasUsed == r.ExpectedGasUsed && len(r.BadReceipts) == 0
+}
+
+// StateAvailability tells whether the state of a canonical minor block is in
+// the database, and which lower block nearest to it has its state if not.
+type StateAvailability struct {
+	Number           uint64      `json:"number" gencodec:"required"`
+	Hash             common.Hash `json:"hash" gencodec:"required"`
+	StateRoot        common.Hash `json:"state_root" gencodec:"required"`
+	Available        bool        `json:"available" gencodec:"required"`
+	NearestAvailable uint64      `json:"nearest_available" gencodec:"required"` // height of the nearest lower block with its state
+	NearestFound     bool        `json:"nearest_found" gencodec:"required"`     // false if none is within the blocks searched
+}
+
+type GetStateAvailabilityRequest struct {
+	Branch uint32 `json:"branch" gencodec:"required"`
+	Number uint64 `json:"number" gencodec:"required"`
+}
+
+type GetStateAvailabilityResponse struct {
+	Availability *StateAvailability `json:"availability" gencodec:"required"`
+}
+
+type RegenerateStateRequest struct {
+	Branch uint32 `json:"branch" gencodec:"required"`
+	Number uint64 `json:"number" gencodec:"required"`
+}
+
+// RegenerateStateResponse is the progress of the regeneration of the state of
+// the block at Number, replaying the blocks from the state of the block at From.
+type RegenerateStateResponse struct {
+	Number      uint64      `json:"number" gencodec:"required"`
+	From        uint64      `json:"from" gencodec:"required"`
+	Regenerated uint64      `json:"regenerated" gencodec:"required"`
+	StateRoot   common.Hash `json:"state_root" gencodec:"required"`
+	Done        bool        `json:"done" gencodec:"required"`
+	Error       string      `json:"error" gencodec:"required"` // why it failed, if it's done
+}
+
+type ReplayBlocksRequest struct {
+	Branch uint32 `json:"branch" gencodec:"required"`
+	First  uint64 `json:"first" gencodec:"required"`
+	Last   uint64 `json:"last" gencodec:"required"`
+}
+
+type ReplayBlocksResponse struct {
+	Results []*BlockReplayResult `json:"results" gencodec:"required" bytesizeofslicelen:"4"`
+}
+
+type BackupShardRequest struct {
+	Branch uint32 `json:"branch" gencodec:"required"`
+	Path   string `json:"path" gencodec:"required"` // path of the archive on the host of the slave
+}
+
+// BackupShardResponse describes the shard database backed up.
+type BackupShardResponse struct {
+	Height     uint64      `json:"height" gencodec:"required"`
+	Hash       common.Hash `json:"hash" gencodec:"required"`
+	RootHeight uint32      `json:"root_height" gencodec:"required"`
+	RootHash   common.Hash `json:"root_hash" gencodec:"required"`
+	Ancients   uint64      `json:"ancients" gencodec:"required"`
+}
+
+// ShardRootTip is the root block a shard was last updated to.
+type ShardRootTip struct {
+	Branch   uint32      `json:"branch" gencodec:"required"`
+	Number   uint32      `json:"number" gencodec:"required"`
+	Hash     common.Hash `json:"hash" gencodec:"required"`
+	Restored bool        `json:"restored" gencodec:"required"` // the shard was restored from a backup
+}
+
+type GetShardRootTipsResponse struct {
+	RootTips []*ShardRootTip `json:"root_tips" gencodec:"required" bytesizeofslicelen:"4"`
+}
+
+type ResendXshardTxListRequest struct {
+	Branch             uint32        `json:"branch" gencodec:"required"`
+	MinorBlockHashList []common.Hash `json:"minor_block_list" gencodec:"required" bytesizeofslicelen:"4"`
+}
+
+type GetShardSnapshotRequest struct {
+	Branch   uint32      `json:"branch" gencodec:"required"`
+	RootHash common.Hash `json:"root_hash" gencodec:"required"` // root block the snapshot is taken at, the root tip of the master
+}
+
+type GetBalanceHistoryRequest struct {
+	Address *account.Address `json:"address" gencodec:"required"`
+	Height  *uint64          `json:"height" ser:"nil"` // the head if nil
+}
+
+type GetBalanceHistoryResponse struct {
+	Branch        uint32               `json:"branch" gencodec:"required"`
+	Height        uint64               `json:"height" gencodec:"required"`
+	ChangedHeight uint64               `json:"changed_height" gencodec:"required"` // block which changed the balances last
+	Balances      *types.TokenBalances `json:"balances" gencodec:"required"`
+}
+
+// The actions of ShardMaintenance. A paused shard rejects the new minor blocks
+// and the root blocks with ErrShardPaused, to be retried later, the root blocks
+// by the master once it's resumed. The database of the shard is compacted or
+// its tx indexes rewritten only while it's paused.
+const (
+	MaintenancePause   = "pause"
+	MaintenanceResume  = "resume"
+	MaintenanceCompact = "compact"
+	MaintenanceReindex = "reindex"
+)
+
+// ErrShardPaused is the error of the minor blocks added to a paused shard,
+// matched by its message once returned by a slave.
+var ErrShardPaused = errors.New("shard is paused for maintenance, retry later")
+
+type ShardMaintenanceRequest struct {
+	Branch uint32 `json:"branch" gencodec:"required"`
+	Action string `json:"action" gencodec:"required"`
+}
+
+type ShardMaintenanceResponse struct {
+	Paused          bool   `json:"paused" gencodec:"required"`
+	ReindexedBlocks uint64 `json:"reindexed_blocks" gencodec:"required"`
+}
+
+type GetPendingAccountDataRequest struct {
+	Address *account.Address `json:"address" gencodec:"required"`
+}
+
+type GetPendingAccountDataResponse struct {
+	AccountBranchData *AccountBranchData `json:"account_branch_data" gencodec:"required"`
+}
+
+// ExecutePendingTransactionRequest executes the tx on the pending state of the
+// shard, with the txs of the pool applied, the response is an
+// ExecuteTransactionResponse.
+type ExecutePendingTransactionRequest struct {
+	Tx          *types.Transaction `json:"tx" gencodec:"required"`
+	FromAddress *account.Address   `json:"from_address" gencodec:"required"`
+	Overrides   []*AccountOverride `json:"overrides"`
+}
+
+type GetMinorBlockReceiptsRequest struct {
+	Branch         uint32      `json:"branch" gencodec:"required"`
+	MinorBlockHash common.Hash `json:"minor_block_hash" gencodec:"required"`
+}
+
+type GetMinorBlockReceiptsResponse struct {
+	Receipts []*types.Receipt `json:"receipts" gencodec:"required" bytesizeofslicelen:"4"`
+}
+
+// TraceConfig configures the struct logger tracing the txs of a block.
+type TraceConfig struct {
+	DisableMemory  bool   `json:"disableMemory"`
+	DisableStack   bool   `json:"disableStack"`
+	DisableStorage bool   `json:"disableStorage"`
+	Limit          uint32 `json:"limit"` // of the struct logs of each tx, unlimited if 0
+}
+
+// TraceBlockRequest streams the traces of the txs of the minor block back, in
+// JSON, one per line.
+type TraceBlockRequest struct {
+	Branch         uint32      `json:"branch" gencodec:"required"`
+	MinorBlockHash common.Hash `json:"minor_block_hash" gencodec:"required"`
+	Config         TraceConfig `json:"config" gencodec:"required"`
+}
+
+// SimulateBundleRequest applies the txs of the shard in order on the state of
+// the block at the height, the head if nil, without keeping them.
+type SimulateBundleRequest struct {
+	Branch uint32               `json:"branch" gencodec:"required"`
+	Txs    []*types.Transaction `json:"txs" gencodec:"required" bytesizeofslicelen:"4"`
+	Height *uint64              `json:"height" ser:"nil"`
+}
+
+// ExportBlocksRequest streams the canonical minor blocks of the shard from the
+// height up back, at most Count of them, as ExportedBlock records each
+// prefixed by its size in uvarint.
+type ExportBlocksRequest struct {
+	Branch          uint32 `json:"branch" gencodec:"required"`
+	From            uint64 `json:"from" gencodec:"required"`
+	Count           uint64 `json:"count" gencodec:"required"`
+	IncludeReceipts bool   `json:"include_receipts"`
+}
+
+// ExportedBlock is a minor block as stored, serialized, with the RLP of the
+// consensus fields of its receipts if requested.
+type ExportedBlock struct {
+	Number   uint64      `json:"number" gencodec:"required"`
+	Hash     common.Hash `json:"hash" gencodec:"required"`
+	Block    []byte      `json:"block" gencodec:"required" bytesizeofslicelen:"4"`
+	Receipts []byte      `json:"receipts" bytesizeofslicelen:"4"`
+}
+
+// BundleTxResult is the result of a tx of a simulated bundle.
+type BundleTxResult struct {
+	Receipt     *types.Receipt `json:"receipt" gencodec:"required"`
+	ReturnValue []byte         `json:"return_value" gencodec:"required" bytesizeofslicelen:"4"`
+}
+
+type SimulateBundleResponse struct {
+	Results []*BundleTxResult `json:"results" gencodec:"required" bytesizeofslicelen:"4"`
+}
@@ -0,0 +1,158 @@
+package rpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/golang-lru/simplelru"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// The mutating ops are sent with an idempotency key, kept by the retries of a
+// call, so that a server given the same call twice, e.g. retried after a lost
+// response or duplicated by the network, applies it once and returns the same
+// response.
+const (
+	idempotencyKeyMD = "qkc-idempotency-key"
+
+	// replayEntries is the number of the last responses kept by a server
+	replayEntries = 4096
+	// replayTTL is how long the response of a call is returned to its retries
+	replayTTL = 5 * time.Minute
+)
+
+// idempotentOps are the mutating ops sent with an idempotency key.
+var idempotentOps = map[string]bool{
+	"AddRootBlock":        true,
+	"HandleNewMinorBlock": true,
+	"AddTransaction":      true,
+}
+
+type idempotencyKey struct{}
+
+// NewIdempotencyKey returns a random idempotency key.
+func NewIdempotencyKey() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// WithIdempotencyKey returns a copy of ctx with the key sent along with the
+// mutating ops called with it, for the calls with the same key to be applied
+// once by a server. The calls without one get a key of their own.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+// IdempotencyKeyOf returns the idempotency key set to ctx by WithIdempotencyKey.
+func IdempotencyKeyOf(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKey{}).(string)
+	return key
+}
+
+// withIdempotencyKey sends the idempotency key of ctx, or a new one, along with
+// the call of the op if it is mutating.
+func withIdempotencyKey(ctx context.Context, op string) context.Context {
+	if !idempotentOps[op] {
+		return ctx
+	}
+	key := IdempotencyKeyOf(ctx)
+	if key == "" {
+		key = NewIdempotencyKey()
+	}
+	return metadata.AppendToOutgoingContext(ctx, idempotencyKeyMD, key)
+}
+
+// replayEntry is a call in flight or applied, done is closed once res is set.
+type replayEntry struct {
+	done    chan struct{}
+	res     *Response
+	expires time.Time
+}
+
+// replayCache keeps the last responses of the mutating ops by server, method
+// and key.
+type replayCache struct {
+	mu  sync.Mutex
+	lru *simplelru.LRU
+}
+
+var replays = newReplayCache(replayEntries)
+
+func newReplayCache(size int) *replayCache {
+	lru, _ := simplelru.NewLRU(size, nil)
+	return &replayCache{lru: lru}
+}
+
+// begin returns the entry of the call of the key, and whether the call is new,
+// in which case it must be ended with end.
+func (c *replayCache) begin(key string) (*replayEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if value, ok := c.lru.Get(key); ok {
+		entry := value.(*replayEntry)
+		select {
+		case <-entry.done:
+			if time.Now().Before(entry.expires) {
+				return entry, false
+			}
+		default:
+			return entry, false
+		}
+	}
+	entry := &replayEntry{done: make(chan struct{})}
+	c.lru.Add(key, entry)
+	return entry, true
+}
+
+// end sets the response of the call of the key, dropped if it failed for a
+// retry to call the op again.
+func (c *replayCache) end(key string, entry *replayEntry, res *Response, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil || res == nil {
+		if value, ok := c.lru.Peek(key); ok && value == entry {
+			c.lru.Remove(key)
+		}
+	} else {
+		entry.res, entry.expires = res, time.Now().Add(replayTTL)
+	}
+	close(entry.done)
+}
+
+// idempotentUnaryInterceptor calls an op sent with an idempotency key once, and
+// returns its response to the calls with the same key. A call with the key of
+// a call in flight waits for it, and calls the op again if it failed. The calls
+// are deduplicated by server, as the same call is sent to each slave, several
+// of which may run in a process, e.g. in the cluster tests.
+func idempotentUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	key := firstMD(md, idempotencyKeyMD)
+	request, ok := req.(*Request)
+	if key == "" || !ok {
+		return laneUnaryInterceptor(ctx, req, info, handler)
+	}
+	key = fmt.Sprintf("%p%s/%s", info.Server, info.FullMethod, key)
+	for {
+		entry, isNew := replays.begin(key)
+		if isNew {
+			res, err := laneUnaryInterceptor(ctx, req, info, handler)
+			r, _ := res.(*Response)
+			replays.end(key, entry, r, err)
+			return res, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-entry.done:
+		}
+		if entry.res != nil {
+			return &Response{RpcId: request.RpcId, Data: entry.res.Data}, nil
+		}
+	}
+}
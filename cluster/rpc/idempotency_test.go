@@ -0,0 +1,111 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotency(t *testing.T) {
+	var (
+		op   = &SlaveServerSideOp{}
+		apis = []rpc.API{
+			{
+				Namespace: "rpc." + reflect.TypeOf(SlaveServerSideOp{}).Name(),
+				Version:   "3.0",
+				Service:   op,
+				Public:    false,
+			},
+		}
+		cfg      = testSlaveConfig(7)
+		hostport = fmt.Sprintf("%s:%d", cfg.IP, cfg.Port)
+	)
+
+	listener, handler, err := StartGRPCServer(hostport, apis, false)
+	if err != nil {
+		t.Fatalf("failed to create grpc server %v", err)
+	}
+	defer handler.Stop()
+	defer listener.Close()
+
+	cli := NewClient(SlaveServer, nil)
+	defer cli.Close()
+	call := func(ctx context.Context, data string) (string, error) {
+		res, err := cli.CallContext(ctx, hostport, &Request{Op: OpAddRootBlock, Data: []byte(data)})
+		if err != nil {
+			return "", err
+		}
+		return string(res.Data), nil
+	}
+	calls := func() int32 { return atomic.LoadInt32(&op.rootBlock) }
+
+	// the calls without a key are applied each
+	res, err := call(context.Background(), "")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", res)
+	res, err = call(context.Background(), "")
+	assert.NoError(t, err)
+	assert.Equal(t, "2", res)
+
+	// a retry with the same key gets the response of the first call
+	ctx := WithIdempotencyKey(context.Background(), NewIdempotencyKey())
+	res, err = call(ctx, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "3", res)
+	res, err = call(ctx, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "3", res)
+	assert.Equal(t, int32(3), calls())
+
+	// the duplicates of a call in flight wait for it
+	ctx = WithIdempotencyKey(context.Background(), NewIdempotencyKey())
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := call(ctx, "")
+			assert.NoError(t, err)
+			assert.Equal(t, "4", res)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(4), calls())
+
+	// a failed call is applied again by its retry
+	ctx = WithIdempotencyKey(context.Background(), NewIdempotencyKey())
+	_, err = call(ctx, "fail")
+	assert.Error(t, err)
+	_, err = call(ctx, "fail")
+	assert.Error(t, err)
+	assert.Equal(t, int32(6), calls())
+
+	// the same call sent to another server of the process is applied by it too
+	other := &SlaveServerSideOp{}
+	apis[0].Service = other
+	otherCfg := testSlaveConfig(8)
+	otherHostport := fmt.Sprintf("%s:%d", otherCfg.IP, otherCfg.Port)
+	otherListener, otherHandler, err := StartGRPCServer(otherHostport, apis, false)
+	if err != nil {
+		t.Fatalf("failed to create grpc server %v", err)
+	}
+	defer otherHandler.Stop()
+	defer otherListener.Close()
+	ctx = WithIdempotencyKey(context.Background(), NewIdempotencyKey())
+	res, err = call(ctx, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "7", res)
+	otherRes, err := cli.CallContext(ctx, otherHostport, &Request{Op: OpAddRootBlock})
+	assert.NoError(t, err)
+	assert.Equal(t, "1", string(otherRes.Data))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&other.rootBlock))
+
+	// the ops which are not mutating are not sent with a key
+	assert.Equal(t, ctx, withIdempotencyKey(ctx, "GetMinorBlock"))
+}
@@ -1,6 +1,10 @@
 package rpc
 
 import (
+	"context"
+	"encoding/json"
+	"io"
+
 	"github.com/QuarkChain/goquarkchain/account"
 	"github.com/QuarkChain/goquarkchain/consensus"
 	"github.com/QuarkChain/goquarkchain/core/types"
@@ -28,40 +32,68 @@ type ISlaveConn interface {
 	// AddTransactions will add the tx to shard tx pool, and return the tx hash
 	// which have been added to tx pool. so tx which cannot pass verification
 	// or existed in tx pool will not be included in return hash list
-	AddTransactions(request *P2PRedirectRequest) error
-	GetMinorBlockByHash(blockHash common.Hash, branch account.Branch, needExtraInfo bool) (*types.MinorBlock, *PoSWInfo, error)
-	GetMinorBlockByHeight(height *uint64, branch account.Branch, needExtraInfo bool) (*types.MinorBlock, *PoSWInfo, error)
-	GetMinorBlocks(request *P2PRedirectRequest) ([]byte, error)
-	GetMinorBlockHeaderList(req *P2PRedirectRequest) ([]byte, error)
-	GetMinorBlockHeaderListWithSkip(req *P2PRedirectRequest) ([]byte, error)
-	HandleNewTip(request *HandleNewTipRequest) (bool, error)
-	HandleNewMinorBlock(request *P2PRedirectRequest) error
-	AddBlockListForSync(request *AddBlockListForSyncRequest) (*ShardStatus, error)
+	AddTransactions(ctx context.Context, request *P2PRedirectRequest) error
+	GetMinorBlockByHash(ctx context.Context, blockHash common.Hash, branch account.Branch, needExtraInfo bool) (*types.MinorBlock, *PoSWInfo, error)
+	GetMinorBlockByHeight(ctx context.Context, height *uint64, branch account.Branch, needExtraInfo bool) (*types.MinorBlock, *PoSWInfo, error)
+	GetMinorBlocks(ctx context.Context, request *P2PRedirectRequest) ([]byte, error)
+	GetMinorBlockHeaderList(ctx context.Context, req *P2PRedirectRequest) ([]byte, error)
+	GetMinorBlockHeaderListWithSkip(ctx context.Context, req *P2PRedirectRequest) ([]byte, error)
+	HandleNewTip(ctx context.Context, request *HandleNewTipRequest) (bool, error)
+	HandleNewMinorBlock(ctx context.Context, request *P2PRedirectRequest) error
+	AddBlockListForSync(ctx context.Context, request *AddBlockListForSyncRequest) (*ShardStatus, error)
 	GetSlaveID() string
 	GetShardMaskList() []*types.ChainMask
 	MasterInfo(ip string, port uint16, rootTip *types.RootBlock) error
 	HasShard(fullShardID uint32) bool
 	SendPing() ([]byte, []*types.ChainMask, error)
+	SupportsOp(op uint32) bool
 	HeartBeat() bool
-	GetUnconfirmedHeaders() (*GetUnconfirmedHeadersResponse, error)
-	GetAccountData(address *account.Address, height *uint64) (*GetAccountDataResponse, error)
-	AddRootBlock(rootBlock *types.RootBlock, expectSwitch bool) error
-	GenTx(numTxPerShard, xShardPercent uint32, tx *types.Transaction) error
-	SendMiningConfigToSlaves(artificialTxConfig *ArtificialTxConfig, mining bool) error
-	AddTransaction(tx *types.Transaction) error
-	ExecuteTransaction(tx *types.Transaction, fromAddress *account.Address, height *uint64) ([]byte, error)
-	GetTransactionByHash(txHash common.Hash, branch account.Branch) (*types.MinorBlock, uint32, error)
-	GetTransactionReceipt(txHash common.Hash, branch account.Branch) (*types.MinorBlock, uint32, *types.Receipt, error)
-	GetTransactionsByAddress(address *account.Address, start []byte, limit uint32, transferTokenID *uint64) ([]*TransactionDetail, []byte, error)
-	GetAllTx(branch account.Branch, start []byte, limit uint32) ([]*TransactionDetail, []byte, error)
-	GetLogs(args *rpc.FilterQuery) ([]*types.Log, error)
-	EstimateGas(tx *types.Transaction, fromAddress *account.Address) (uint32, error)
-	GetStorageAt(address *account.Address, key common.Hash, height *uint64) (common.Hash, error)
-	GetCode(address *account.Address, height *uint64) ([]byte, error)
-	GasPrice(branch account.Branch, tokenID uint64) (uint64, error)
-	GetWork(branch account.Branch, address *account.Address) (*consensus.MiningWork, error)
-	SubmitWork(work *SubmitWorkRequest) (success bool, err error)
-	SetMining(mining bool) error
-	GetRootChainStakes(address account.Address, lastMinor common.Hash) (*big.Int, *account.Recipient, error)
-	CheckMinorBlocksInRoot(rootBlock *types.RootBlock) error
+	GetUnconfirmedHeaders(ctx context.Context) (*GetUnconfirmedHeadersResponse, error)
+	GetAccountData(ctx context.Context, address *account.Address, height *uint64) (*GetAccountDataResponse, error)
+	AddRootBlock(ctx context.Context, rootBlock *types.RootBlock, expectSwitch bool) error
+	GenTx(ctx context.Context, req *GenTxRequest) error
+	SendMiningConfigToSlaves(ctx context.Context, artificialTxConfig *ArtificialTxConfig, mining bool) error
+	AddTransaction(ctx context.Context, tx *types.Transaction) error
+	ExecuteTransaction(ctx context.Context, tx *types.Transaction, fromAddress *account.Address, height *uint64, overrides []*AccountOverride) ([]byte, error)
+	CreateAccessList(ctx context.Context, tx *types.Transaction, fromAddress *account.Address, height *uint64) (*CreateAccessListResponse, error)
+	GetTransactionByHash(ctx context.Context, txHash common.Hash, branch account.Branch) (*types.MinorBlock, uint32, error)
+	GetTransactionReceipt(ctx context.Context, txHash common.Hash, branch account.Branch) (*types.MinorBlock, uint32, *types.Receipt, error)
+	GetMinorBlockReceipts(ctx context.Context, blockHash common.Hash, branch account.Branch) (types.Receipts, error)
+	TraceBlock(ctx context.Context, blockHash common.Hash, branch account.Branch, config *TraceConfig) ([]json.RawMessage, error)
+	SimulateBundle(ctx context.Context, txs []*types.Transaction, branch account.Branch, height *uint64) ([]*BundleTxResult, error)
+	ExportBlocks(ctx context.Context, branch account.Branch, from, count uint64, includeReceipts bool, fn func(*ExportedBlock) bool) error
+	GetTransactionsByAddress(ctx context.Context, address *account.Address, start []byte, limit uint32, transferTokenID *uint64) ([]*TransactionDetail, []byte, error)
+	GetAllTx(ctx context.Context, branch account.Branch, start []byte, limit uint32) ([]*TransactionDetail, []byte, error)
+	GetLogs(ctx context.Context, args *rpc.FilterQuery) ([]*types.Log, error)
+	EstimateGas(ctx context.Context, tx *types.Transaction, fromAddress *account.Address) (uint32, error)
+	GetStorageAt(ctx context.Context, address *account.Address, key common.Hash, height *uint64) (common.Hash, error)
+	GetCode(ctx context.Context, address *account.Address, height *uint64) ([]byte, error)
+	GasPrice(ctx context.Context, branch account.Branch, tokenID uint64) (uint64, error)
+	GetWork(ctx context.Context, branch account.Branch, address *account.Address) (*consensus.MiningWork, error)
+	SubmitWork(ctx context.Context, work *SubmitWorkRequest) (success bool, err error)
+	SetMining(ctx context.Context, mining bool) error
+	GetRootChainStakes(ctx context.Context, address account.Address, lastMinor common.Hash) (*big.Int, *account.Recipient, error)
+	CheckMinorBlocksInRoot(ctx context.Context, rootBlock *types.RootBlock) error
+	GetStaleBlocks(ctx context.Context, branch account.Branch, limit uint32) (uint64, []*StaleBlock, error)
+	GetUnreceivedXShardDeposits(ctx context.Context, branch account.Branch, limit uint32) ([]*UnreceivedXShardDeposit, error)
+	GetBlockProfiles(ctx context.Context, branch account.Branch, limit uint32) ([]*BlockProfile, error)
+	GetTxBenchmarkReports(ctx context.Context) ([]*TxBenchmarkReport, error)
+	ReplayBlocks(ctx context.Context, branch account.Branch, first, last uint64) ([]*BlockReplayResult, error)
+	BackupShard(ctx context.Context, branch account.Branch, path string) (*BackupShardResponse, error)
+	GetShardRootTips(ctx context.Context) ([]*ShardRootTip, error)
+	ResendXshardTxList(ctx context.Context, branch account.Branch, hashList []common.Hash) error
+	GetShardSnapshot(branch account.Branch, rootHash common.Hash) (io.ReadCloser, error)
+	GetBalanceHistory(ctx context.Context, address *account.Address, height *uint64) (*GetBalanceHistoryResponse, error)
+	GetXShardQueues(ctx context.Context) ([]*XShardQueue, error)
+	ShardMaintenance(ctx context.Context, branch account.Branch, action string) (*ShardMaintenanceResponse, error)
+	GetStateAvailability(ctx context.Context, branch account.Branch, number uint64) (*StateAvailability, error)
+	RegenerateState(ctx context.Context, branch account.Branch, number uint64) (*RegenerateStateResponse, error)
+	GetPendingAccountData(ctx context.Context, address *account.Address) (*AccountBranchData, error)
+	ExecutePendingTransaction(ctx context.Context, tx *types.Transaction, fromAddress *account.Address, overrides []*AccountOverride) ([]byte, error)
+	ReloadConfig(ctx context.Context) error
+	SetLogLevel(ctx context.Context, module, level string) error
+	Profile(ctx context.Context, action, file string, rate int) error
+	GetSlowOps(ctx context.Context, count int) ([]*SlowOp, error)
+	SetTarget(target string)
+	Close()
 }
@@ -0,0 +1,190 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/QuarkChain/goquarkchain/cluster/config"
+	"google.golang.org/grpc"
+)
+
+// The ops served by a slave run in two lanes, each with its own workers and
+// queue: the ops serving the users and the peers run in the query lane, and
+// the ops processing the blocks or driving the cluster, e.g. AddRootBlock,
+// HandleNewMinorBlock, AddXshardTxList and HeartBeat, in the consensus lane,
+// for a storm of queries not to delay them. An op waiting for a worker of its
+// lane while the queue is full fails at once.
+const (
+	LaneConsensus = "consensus"
+	LaneQuery     = "query"
+)
+
+// queryOps are the slave ops run in the query lane.
+var queryOps = map[string]bool{
+	"GetAccountData":                  true,
+	"AddTransaction":                  true,
+	"GetMinorBlock":                   true,
+	"GetTransaction":                  true,
+	"ExecuteTransaction":              true,
+	"GetTransactionReceipt":           true,
+	"GenTx":                           true,
+	"GetTransactionListByAddress":     true,
+	"GetAllTx":                        true,
+	"GetLogs":                         true,
+	"EstimateGas":                     true,
+	"GetStorageAt":                    true,
+	"GetCode":                         true,
+	"GasPrice":                        true,
+	"GetStaleBlocks":                  true,
+	"GetUnreceivedXShardDeposits":     true,
+	"CreateAccessList":                true,
+	"GetBlockProfiles":                true,
+	"GetTxBenchmarkReports":           true,
+	"ReplayBlocks":                    true,
+	"BackupShard":                     true,
+	"GetShardSnapshot":                true,
+	"GetBalanceHistory":               true,
+	"GetXShardQueues":                 true,
+	"GetMinorBlockList":               true,
+	"GetMinorBlockHeaderList":         true,
+	"GetMinorBlockHeaderListWithSkip": true,
+	"AddTransactions":                 true,
+	"GetPendingAccountData":           true,
+	"ExecutePendingTransaction":       true,
+	"GetMinorBlockReceipts":           true,
+	"TraceBlock":                      true,
+	"SimulateBundle":                  true,
+	"GetStateAvailability":            true,
+	"RegenerateState":                 true,
+	"ExportBlocks":                    true,
+	"BootstrapShard":                  true,
+}
+
+// lane limits the ops running at once, and the ones waiting for them.
+type lane struct {
+	name      string
+	workers   chan struct{} // nil if not limited
+	queued    int32         // atomic
+	maxQueued int32
+}
+
+func newLane(name string, workers, queue int) *lane {
+	l := &lane{name: name, maxQueued: int32(queue)}
+	if workers > 0 {
+		l.workers = make(chan struct{}, workers)
+	}
+	return l
+}
+
+// acquire waits for a worker of the lane, until ctx is done or fails at once
+// if the queue is full. release must be called once the op is done.
+func (l *lane) acquire(ctx context.Context) error {
+	if l.workers == nil {
+		return nil
+	}
+	select {
+	case l.workers <- struct{}{}:
+		return nil
+	default:
+	}
+	if atomic.AddInt32(&l.queued, 1) > l.maxQueued {
+		atomic.AddInt32(&l.queued, -1)
+		return fmt.Errorf("%s lane is full", l.name)
+	}
+	defer atomic.AddInt32(&l.queued, -1)
+	select {
+	case l.workers <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *lane) release() {
+	if l.workers != nil {
+		<-l.workers
+	}
+}
+
+// LaneStats is the load of a lane.
+type LaneStats struct {
+	Running int
+	Queued  int
+}
+
+func (l *lane) stats() LaneStats {
+	return LaneStats{Running: len(l.workers), Queued: int(atomic.LoadInt32(&l.queued))}
+}
+
+var (
+	lanesLock sync.RWMutex
+	lanes     = newLanes(config.NewClusterConfig())
+)
+
+func newLanes(cfg *config.ClusterConfig) map[string]*lane {
+	return map[string]*lane{
+		LaneConsensus: newLane(LaneConsensus, cfg.RPCConsensusWorkers, cfg.RPCConsensusQueue),
+		LaneQuery:     newLane(LaneQuery, cfg.RPCQueryWorkers, cfg.RPCQueryQueue),
+	}
+}
+
+// SetLanes limits the workers and the queues of the lanes of the slave ops
+// served by the process to the ones of the config.
+func SetLanes(cfg *config.ClusterConfig) {
+	lanesLock.Lock()
+	defer lanesLock.Unlock()
+	lanes = newLanes(cfg)
+}
+
+// GetLaneStats returns the load of the lanes of the slave ops served by the
+// process.
+func GetLaneStats() map[string]LaneStats {
+	lanesLock.RLock()
+	defer lanesLock.RUnlock()
+	stats := make(map[string]LaneStats, len(lanes))
+	for name, l := range lanes {
+		stats[name] = l.stats()
+	}
+	return stats
+}
+
+// laneOf returns the lane of the gRPC method, nil if not a slave op.
+func laneOf(fullMethod string) *lane {
+	prefix := "/" + _SlaveServerSideOp_serviceDesc.ServiceName + "/"
+	if !strings.HasPrefix(fullMethod, prefix) {
+		return nil
+	}
+	lanesLock.RLock()
+	defer lanesLock.RUnlock()
+	if queryOps[strings.TrimPrefix(fullMethod, prefix)] {
+		return lanes[LaneQuery]
+	}
+	return lanes[LaneConsensus]
+}
+
+func laneUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	l := laneOf(info.FullMethod)
+	if l == nil {
+		return handler(ctx, req)
+	}
+	if err := l.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer l.release()
+	return handler(ctx, req)
+}
+
+func laneStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	l := laneOf(info.FullMethod)
+	if l == nil {
+		return handler(srv, ss)
+	}
+	if err := l.acquire(ss.Context()); err != nil {
+		return err
+	}
+	defer l.release()
+	return handler(srv, ss)
+}
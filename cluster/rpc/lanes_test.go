@@ -0,0 +1,75 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/cluster/config"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+func TestLanes(t *testing.T) {
+	cfg := config.NewClusterConfig()
+	cfg.RPCConsensusWorkers, cfg.RPCConsensusQueue = 1, 0
+	cfg.RPCQueryWorkers, cfg.RPCQueryQueue = 1, 1
+	SetLanes(cfg)
+	defer SetLanes(config.NewClusterConfig())
+
+	var (
+		release = make(chan struct{})
+		started = make(chan string, 4)
+		errc    = make(chan error, 4)
+	)
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		started <- req.(string)
+		<-release
+		return req, nil
+	}
+	call := func(ctx context.Context, op string) {
+		info := &grpc.UnaryServerInfo{FullMethod: "/" + _SlaveServerSideOp_serviceDesc.ServiceName + "/" + op}
+		_, err := laneUnaryInterceptor(ctx, op, info, handler)
+		errc <- err
+	}
+
+	// a query running and one queued don't delay the consensus op
+	go call(context.Background(), "GetLogs")
+	assert.Equal(t, "GetLogs", <-started)
+	go call(context.Background(), "ExecuteTransaction")
+	for GetLaneStats()[LaneQuery].Queued != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	go call(context.Background(), "AddRootBlock")
+	assert.Equal(t, "AddRootBlock", <-started)
+	assert.Equal(t, LaneStats{Running: 1, Queued: 1}, GetLaneStats()[LaneQuery])
+	assert.Equal(t, LaneStats{Running: 1}, GetLaneStats()[LaneConsensus])
+
+	// the ops beyond the queue fail at once, or once canceled while queued
+	call(context.Background(), "GetCode")
+	assert.EqualError(t, <-errc, "query lane is full")
+	call(context.Background(), "HandleNewMinorBlock")
+	assert.EqualError(t, <-errc, "consensus lane is full")
+
+	close(release)
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, <-errc)
+	}
+	assert.Equal(t, "ExecuteTransaction", <-started)
+
+	// the master ops are not limited
+	info := &grpc.UnaryServerInfo{FullMethod: "/" + _MasterServerSideOp_serviceDesc.ServiceName + "/AddMinorBlockHeader"}
+	_, err := laneUnaryInterceptor(context.Background(), "AddMinorBlockHeader", info, handler)
+	assert.NoError(t, err)
+	assert.Equal(t, "AddMinorBlockHeader", <-started)
+
+	release = make(chan struct{})
+	go call(context.Background(), "GetLogs")
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	call(ctx, "GetCode")
+	assert.Equal(t, context.DeadlineExceeded, <-errc)
+	close(release)
+	assert.NoError(t, <-errc)
+}
@@ -0,0 +1,18 @@
+package rpc
+
+// NonceReservation is a run of consecutive nonces of an account in a shard
+// reserved by the nonce manager of the master for the txs of a sender.
+type NonceReservation struct {
+	From  uint64
+	Count uint64
+}
+
+// NonceState is what the nonce manager of the master knows of the nonces of
+// an account in a shard.
+type NonceState struct {
+	Confirmed uint64   // nonce of the next tx of the account in the latest state
+	Next      uint64   // first nonce never reserved
+	Reserved  []uint64 // nonces reserved and not sent yet
+	InFlight  []uint64 // nonces of the txs sent through the master and not mined yet
+	Free      []uint64 // nonces below Next which are to be reserved again, e.g. of the txs dropped
+}
@@ -0,0 +1,75 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/QuarkChain/goquarkchain/rpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// requestIDKey is the gRPC metadata key of the ID of the JSON RPC request an op
+// is called for, for the log lines of the request on the master and on the
+// slaves to be correlated.
+const requestIDKey = "qkc-request-id"
+
+// requestIDUnaryInterceptor puts the request ID sent by the client in the
+// context of the op, and logs the op if it fails.
+func requestIDUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	id := firstMD(md, requestIDKey)
+	if id != "" {
+		ctx = rpc.WithRequestID(ctx, id)
+	}
+	res, err := chunkUnaryInterceptor(ctx, req, info, handler)
+	if err != nil && id != "" {
+		rpc.LoggerFromContext(ctx).Debug("Cluster RPC op failed", "method", info.FullMethod, "err", err)
+	}
+	return res, err
+}
+
+// requestIDStreamInterceptor puts the request ID sent by the client in the
+// context of the stream, and logs the stream if it fails.
+func requestIDStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	md, _ := metadata.FromIncomingContext(ss.Context())
+	id := firstMD(md, requestIDKey)
+	if id == "" {
+		return laneStreamInterceptor(srv, ss, info, handler)
+	}
+	ss = &requestIDServerStream{ServerStream: ss, ctx: rpc.WithRequestID(ss.Context(), id)}
+	err := laneStreamInterceptor(srv, ss, info, handler)
+	if err != nil {
+		rpc.LoggerFromContext(ss.Context()).Debug("Cluster RPC stream failed", "method", info.FullMethod, "err", err)
+	}
+	return err
+}
+
+// requestIDServerStream is a server stream whose context carries a request ID.
+type requestIDServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *requestIDServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// requestIDClientInterceptor sends the request ID of the context along with
+// the op.
+func requestIDClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if id := rpc.RequestIDFromContext(ctx); id != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, requestIDKey, id)
+	}
+	return chunkClientInterceptor(ctx, method, req, reply, cc, invoker, opts...)
+}
+
+// requestIDStreamClientInterceptor sends the request ID of the context along
+// with the stream.
+func requestIDStreamClientInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string,
+	streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	if id := rpc.RequestIDFromContext(ctx); id != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, requestIDKey, id)
+	}
+	return streamer(ctx, desc, cc, method, opts...)
+}
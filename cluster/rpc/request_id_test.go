@@ -0,0 +1,107 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+// RequestIDService calls AddTransaction on a slave for each JSON RPC call and
+// returns what the slave got as the request ID.
+type RequestIDService struct {
+	client   Client
+	hostport string
+}
+
+func (s *RequestIDService) Send(ctx context.Context) (string, error) {
+	res, err := s.client.CallContext(ctx, s.hostport, &Request{Op: OpAddTransaction})
+	if err != nil {
+		return "", err
+	}
+	return string(res.Data), nil
+}
+
+func TestRequestID(t *testing.T) {
+	var (
+		apis = []rpc.API{
+			{
+				Namespace: "rpc." + reflect.TypeOf(SlaveServerSideOp{}).Name(),
+				Version:   "3.0",
+				Service:   &SlaveServerSideOp{},
+				Public:    false,
+			},
+		}
+		cfg      = testSlaveConfig(6)
+		hostport = fmt.Sprintf("%s:%d", cfg.IP, cfg.Port)
+	)
+
+	listener, handler, err := StartGRPCServer(hostport, apis, false)
+	if err != nil {
+		t.Fatalf("failed to create grpc server %v", err)
+	}
+	defer handler.Stop()
+	defer listener.Close()
+
+	cli := NewClient(SlaveServer, nil)
+	defer cli.Close()
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("test", &RequestIDService{client: cli, hostport: hostport}); err != nil {
+		t.Fatal(err)
+	}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	// send returns the request ID of the response and the one the slave got
+	send := func(id string) (string, string) {
+		req, err := http.NewRequest(http.MethodPost, httpServer.URL,
+			strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"test_send","params":[]}`))
+		assert.NoError(t, err)
+		req.Header.Set("content-type", "application/json")
+		if id != "" {
+			req.Header.Set(rpc.RequestIDHeader, id)
+		}
+		res, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		defer res.Body.Close()
+		var result struct {
+			Result string `json:"result"`
+		}
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+		return res.Header.Get(rpc.RequestIDHeader), result.Result
+	}
+
+	// the ID of the client is sent along to the slave
+	got, slave := send("req-42")
+	assert.Equal(t, "req-42", got)
+	assert.Equal(t, "req-42", slave)
+
+	// an ID is generated if there is none or it's invalid
+	for _, id := range []string{"", "bad id!", strings.Repeat("a", 65)} {
+		got, slave := send(id)
+		assert.Len(t, got, 16)
+		assert.Equal(t, got, slave)
+	}
+
+	// and along with the streams
+	r, err := cli.StreamContext(rpc.WithRequestID(context.Background(), "req-43"), hostport, &Request{Op: OpGetShardSnapshot})
+	assert.NoError(t, err)
+	streamed, err := ioutil.ReadAll(r)
+	r.Close()
+	assert.NoError(t, err)
+	assert.Equal(t, "req-43", string(streamed))
+
+	// no ID is sent for the ops not called for a JSON RPC request
+	res, err := cli.Call(hostport, &Request{Op: OpAddTransaction})
+	assert.NoError(t, err)
+	assert.Empty(t, res.Data)
+}
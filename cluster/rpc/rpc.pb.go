@@ -136,44 +136,63 @@ func init() {
 func init() { proto.RegisterFile("rpc.proto", fileDescriptor_77a6da22d6a3feb1) }
 
 var fileDescriptor_77a6da22d6a3feb1 = []byte{
-	// 590 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xdc, 0x96, 0x5b, 0x4f, 0x13, 0x41,
-	0x14, 0xc7, 0x2d, 0x77, 0x8e, 0x05, 0xc3, 0x22, 0xd0, 0xe8, 0x83, 0x84, 0x44, 0x53, 0x51, 0xd1,
-	0x70, 0x27, 0xf1, 0xc1, 0x2d, 0xe0, 0x42, 0x02, 0x4a, 0x76, 0x6b, 0xf0, 0xcd, 0x0c, 0x33, 0x87,
-	0xee, 0xa4, 0xed, 0xcc, 0x3a, 0x73, 0x8a, 0xe5, 0x93, 0xfa, 0x4d, 0x7c, 0x36, 0xdb, 0x92, 0x96,
-	0x4d, 0x24, 0x33, 0x7d, 0xf5, 0xad, 0xcd, 0x9c, 0xdf, 0x9c, 0x33, 0xff, 0x73, 0x5b, 0x98, 0x35,
-	0x19, 0xdf, 0xc8, 0x8c, 0x26, 0x1d, 0x8c, 0x9b, 0x8c, 0xaf, 0x1d, 0xc1, 0x74, 0x8c, 0x3f, 0x3b,
-	0x68, 0x29, 0x98, 0x87, 0x31, 0x9d, 0x55, 0x4a, 0xab, 0xa5, 0xea, 0x5c, 0x3c, 0xa6, 0xb3, 0x60,
-	0x09, 0xa6, 0x4c, 0xc6, 0x7f, 0x48, 0x51, 0x19, 0x5b, 0x2d, 0x55, 0xc7, 0xe3, 0x49, 0x93, 0xf1,
-	0x53, 0x11, 0x04, 0x30, 0x21, 0x18, 0xb1, 0xca, 0xe4, 0x6a, 0xa9, 0x5a, 0x8e, 0x7b, 0xbf, 0xd7,
-	0x76, 0x60, 0x26, 0x46, 0x9b, 0x69, 0x65, 0x71, 0x70, 0x5e, 0x1a, 0x9e, 0x3f, 0x70, 0xd5, 0xe6,
-	0xef, 0x71, 0x08, 0xce, 0x99, 0x25, 0x34, 0x09, 0x9a, 0x1b, 0x34, 0x89, 0x14, 0xf8, 0x35, 0x0b,
-	0xb6, 0x61, 0x31, 0x14, 0xe2, 0x5c, 0x2a, 0x6d, 0x6a, 0x2d, 0xcd, 0x9b, 0x27, 0xc8, 0x04, 0x9a,
-	0xa0, 0xbc, 0x91, 0xc7, 0x7e, 0x17, 0xed, 0xb3, 0xb9, 0xbb, 0x7f, 0x7d, 0xaf, 0x6b, 0x8f, 0x82,
-	0x7d, 0x58, 0xf9, 0x07, 0x75, 0x26, 0x2d, 0xb9, 0xc8, 0x0f, 0xf0, 0xa4, 0x66, 0x34, 0x13, 0x9c,
-	0x59, 0xfa, 0x82, 0xbf, 0xea, 0x32, 0x73, 0x11, 0xbb, 0xb0, 0x34, 0x20, 0xea, 0x86, 0x29, 0xcb,
-	0x38, 0x49, 0xad, 0xac, 0x8b, 0xdb, 0x83, 0xe5, 0xfb, 0x9e, 0x86, 0xc1, 0xba, 0xc0, 0x4d, 0x58,
-	0x88, 0x90, 0x86, 0xf6, 0x3e, 0xcf, 0xda, 0x87, 0x95, 0x02, 0xe3, 0x2f, 0xc8, 0x27, 0x78, 0xf1,
-	0x00, 0x79, 0x29, 0x29, 0x4d, 0x9a, 0x4e, 0x81, 0x36, 0xff, 0x94, 0x61, 0x21, 0x69, 0xb1, 0x1b,
-	0x2c, 0x24, 0x76, 0x1d, 0x66, 0x53, 0x64, 0x86, 0x6a, 0xc8, 0x9c, 0x31, 0xbc, 0x01, 0xe8, 0x97,
-	0xc6, 0xa9, 0xba, 0xd6, 0x2e, 0xe3, 0x97, 0x30, 0x71, 0x21, 0x55, 0xc3, 0x65, 0xf6, 0x0a, 0x26,
-	0x23, 0x54, 0xf5, 0xae, 0xcb, 0xee, 0x1d, 0x94, 0x43, 0x21, 0x62, 0xad, 0xc9, 0x2b, 0x39, 0x07,
-	0x50, 0x89, 0x90, 0xbe, 0x29, 0xae, 0xd5, 0xb5, 0x34, 0x6d, 0x14, 0xfe, 0x4a, 0xbf, 0x87, 0xf9,
-	0x08, 0x29, 0xe4, 0x5c, 0x77, 0x14, 0x1d, 0xe5, 0xad, 0xe2, 0x06, 0x42, 0x21, 0xee, 0xd5, 0x9c,
-	0x0b, 0xd8, 0x80, 0xb9, 0x42, 0x2e, 0xfd, 0x22, 0x1a, 0xc1, 0xc1, 0x16, 0x04, 0xc7, 0x5d, 0xe4,
-	0x1d, 0xc2, 0x11, 0xa0, 0x5d, 0x58, 0x2a, 0x7a, 0x89, 0x91, 0xa3, 0xcc, 0x9c, 0x7a, 0x7d, 0x84,
-	0xe7, 0x45, 0x2e, 0x17, 0xb9, 0x76, 0x1b, 0x0a, 0x61, 0xd0, 0x3a, 0xdb, 0xef, 0x35, 0xcc, 0xe4,
-	0x6a, 0xb7, 0x5a, 0xee, 0x12, 0xa8, 0xc2, 0x74, 0x84, 0x74, 0xa6, 0x1b, 0xce, 0x4b, 0xdf, 0xc2,
-	0xe3, 0x63, 0x4b, 0xb2, 0xcd, 0x08, 0x23, 0x66, 0x3d, 0x4a, 0x2b, 0x42, 0x4a, 0x48, 0x1b, 0xd6,
-	0xc0, 0x90, 0xfc, 0xc2, 0x38, 0xd4, 0x02, 0x7d, 0xde, 0xc6, 0xec, 0x85, 0x91, 0x1c, 0xfd, 0x2e,
-	0xbd, 0xd4, 0xa6, 0xe9, 0xd1, 0x84, 0x49, 0xe7, 0xaa, 0x2d, 0xbd, 0x8c, 0xb7, 0x20, 0x88, 0x90,
-	0xf2, 0xae, 0x39, 0x4c, 0x99, 0x54, 0x09, 0xb1, 0x26, 0x5a, 0x8f, 0xd9, 0x1b, 0x0a, 0xf1, 0xdd,
-	0xa6, 0xcc, 0x88, 0x7a, 0xd7, 0xa7, 0x65, 0x76, 0xe0, 0x69, 0x8d, 0x11, 0x4f, 0x47, 0xc4, 0x0e,
-	0xa0, 0x52, 0x58, 0x0f, 0x39, 0xf3, 0x59, 0x9b, 0xe4, 0x56, 0x71, 0x17, 0xba, 0x0e, 0xb3, 0x49,
-	0xaf, 0x85, 0x3c, 0x46, 0xcc, 0x1e, 0x2c, 0x1f, 0xa6, 0xc8, 0x9b, 0x43, 0x47, 0xf6, 0x54, 0xe5,
-	0x9a, 0xfc, 0x67, 0x13, 0x3e, 0x2f, 0xe4, 0x13, 0xa6, 0x44, 0x0b, 0xfd, 0x36, 0x66, 0x3f, 0xcf,
-	0xa3, 0xec, 0xca, 0x6d, 0x58, 0x1c, 0x38, 0xf0, 0x1e, 0x5f, 0x57, 0x53, 0xbd, 0x6f, 0x9b, 0xad,
-	0xbf, 0x01, 0x00, 0x00, 0xff, 0xff, 0x1c, 0x14, 0x88, 0x73, 0xe8, 0x08, 0x00, 0x00,
+	// 887 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xd4, 0x57, 0xdf, 0x6f, 0x1b, 0x45,
+	0x10, 0xc6, 0x69, 0xd2, 0x36, 0x53, 0x27, 0xd0, 0x6b, 0x93, 0x1a, 0x78, 0x20, 0x8a, 0x04, 0x0a,
+	0xa5, 0x09, 0x21, 0x6e, 0xfa, 0x03, 0xf1, 0x80, 0xed, 0x84, 0x4b, 0xa4, 0x84, 0x86, 0x3b, 0x57,
+	0xed, 0x1b, 0x9a, 0xec, 0x4e, 0xec, 0x95, 0xcf, 0xbb, 0xc7, 0xee, 0xd8, 0x75, 0xfe, 0x39, 0xfe,
+	0x26, 0xfe, 0x04, 0xb4, 0xb6, 0x65, 0xd7, 0x12, 0xd5, 0xae, 0x1f, 0x79, 0xb3, 0x75, 0xf3, 0xdd,
+	0xcc, 0x7c, 0xfb, 0x7d, 0x33, 0xb7, 0xb0, 0x6e, 0x4b, 0x71, 0x50, 0x5a, 0xc3, 0x26, 0xb9, 0x63,
+	0x4b, 0xb1, 0x7b, 0x02, 0xf7, 0x32, 0xfa, 0x6b, 0x40, 0x8e, 0x93, 0x4d, 0x58, 0x31, 0x65, 0xad,
+	0xb2, 0x53, 0xd9, 0xdb, 0xc8, 0x56, 0x4c, 0x99, 0x6c, 0xc1, 0x5d, 0x5b, 0x8a, 0x3f, 0x95, 0xac,
+	0xad, 0xec, 0x54, 0xf6, 0xee, 0x64, 0x6b, 0xb6, 0x14, 0xe7, 0x32, 0x49, 0x60, 0x55, 0x22, 0x63,
+	0x6d, 0x6d, 0xa7, 0xb2, 0x57, 0xcd, 0xc6, 0xbf, 0x77, 0x8f, 0xe1, 0x7e, 0x46, 0xae, 0x34, 0xda,
+	0xd1, 0xec, 0x79, 0x65, 0xfe, 0xfc, 0x13, 0xaf, 0x3a, 0xfa, 0x7b, 0x15, 0x92, 0x4b, 0x74, 0x4c,
+	0x36, 0x27, 0x3b, 0x24, 0x9b, 0x2b, 0x49, 0x6f, 0xca, 0xe4, 0x39, 0x3c, 0x6a, 0x48, 0x79, 0xa9,
+	0xb4, 0xb1, 0xcd, 0xc2, 0x88, 0xde, 0x19, 0xa1, 0x24, 0x9b, 0x54, 0x0f, 0x7c, 0xed, 0xd3, 0x6a,
+	0xbf, 0xda, 0x98, 0xfe, 0x9b, 0x64, 0xdd, 0xfd, 0x2c, 0x79, 0x05, 0x4f, 0xfe, 0x03, 0x75, 0xa1,
+	0x1c, 0x87, 0x90, 0x87, 0xf0, 0x79, 0xd3, 0x1a, 0x94, 0x02, 0x1d, 0xff, 0x4e, 0x1f, 0xda, 0xaa,
+	0x0c, 0x21, 0x5e, 0xc0, 0xd6, 0x0c, 0xd1, 0xb6, 0xa8, 0x1d, 0x0a, 0x56, 0x46, 0xbb, 0x10, 0xee,
+	0x25, 0x6c, 0x7f, 0x9c, 0x69, 0x5e, 0x6c, 0x08, 0x78, 0x04, 0x0f, 0x53, 0xe2, 0x79, 0x7c, 0x4c,
+	0x5b, 0xaf, 0xe0, 0xc9, 0x02, 0x26, 0x9e, 0x90, 0x5f, 0xe1, 0x9b, 0x4f, 0x20, 0xdf, 0x29, 0xee,
+	0xe6, 0xbd, 0x30, 0x41, 0x3f, 0xc1, 0x66, 0xd3, 0x18, 0x76, 0x6c, 0xb1, 0xcc, 0xbb, 0x68, 0x65,
+	0x00, 0x70, 0x58, 0xf1, 0x2d, 0x8e, 0x23, 0x67, 0xb8, 0x92, 0x42, 0xa8, 0xa3, 0x7f, 0xb6, 0xe1,
+	0x61, 0x5e, 0xe0, 0x90, 0x16, 0xf4, 0xf3, 0x14, 0xd6, 0xbb, 0x84, 0x96, 0x9b, 0x84, 0xc1, 0x56,
+	0x7f, 0x00, 0x98, 0x28, 0xf0, 0x5c, 0xdf, 0x98, 0x50, 0xf0, 0xb7, 0xb0, 0x7a, 0xa5, 0x74, 0x27,
+	0x14, 0xf6, 0x1d, 0xac, 0xa5, 0xa4, 0xdb, 0xa3, 0x50, 0xdc, 0x3e, 0x54, 0x1b, 0x52, 0x66, 0xc6,
+	0x70, 0x94, 0x06, 0x5e, 0x43, 0x2d, 0x25, 0x7e, 0xab, 0x85, 0xd1, 0x37, 0xca, 0xf6, 0x49, 0xc6,
+	0x1f, 0xe8, 0x8f, 0xb0, 0x99, 0x12, 0x37, 0x84, 0x30, 0x03, 0xcd, 0x27, 0xde, 0x91, 0x61, 0x40,
+	0x43, 0xca, 0x8f, 0xa4, 0x1d, 0x02, 0x1c, 0xc0, 0xc6, 0x82, 0x64, 0xe2, 0x2a, 0x5a, 0x22, 0x41,
+	0x1d, 0x92, 0xd3, 0x11, 0x89, 0x01, 0xd3, 0x12, 0xa0, 0x17, 0xb0, 0xb5, 0x98, 0x25, 0x23, 0x41,
+	0xaa, 0x0c, 0xf2, 0xf5, 0x0b, 0x7c, 0xbd, 0x88, 0xf3, 0x24, 0x37, 0x6f, 0x1b, 0x52, 0x5a, 0x72,
+	0x41, 0x97, 0x7f, 0x0f, 0xf7, 0x3d, 0xdb, 0x45, 0x11, 0x96, 0xc0, 0x1e, 0xdc, 0x4b, 0x89, 0x2f,
+	0x4c, 0x27, 0xf8, 0xd2, 0x67, 0xf0, 0xe0, 0xd4, 0xb1, 0xea, 0x23, 0x53, 0x8a, 0x2e, 0x42, 0x5a,
+	0x29, 0x71, 0xce, 0xc6, 0x62, 0x87, 0x1a, 0x1c, 0x57, 0x46, 0xcb, 0x48, 0x8a, 0xe9, 0x0d, 0xdd,
+	0x95, 0x55, 0x82, 0xe2, 0x5e, 0xfa, 0xce, 0xd8, 0x5e, 0x84, 0x09, 0xf3, 0xc1, 0x75, 0x5f, 0x45,
+	0x05, 0xd7, 0x21, 0x49, 0x89, 0xbd, 0x6b, 0x5a, 0x5d, 0x54, 0x3a, 0x67, 0xec, 0x91, 0x8b, 0x18,
+	0xf1, 0x0d, 0x29, 0xdf, 0x3b, 0x3f, 0x60, 0xda, 0xa3, 0x18, 0xcb, 0x1c, 0xc3, 0xe3, 0x26, 0xb2,
+	0xe8, 0x2e, 0x09, 0x7b, 0x0d, 0xb5, 0x85, 0x2d, 0xe4, 0x31, 0xbf, 0x19, 0x9b, 0xdf, 0x6a, 0x11,
+	0x82, 0x3e, 0x85, 0xf5, 0x7c, 0x6c, 0xa1, 0x88, 0x11, 0xf3, 0x12, 0xb6, 0x5b, 0x5d, 0x12, 0xbd,
+	0x79, 0x22, 0x77, 0xae, 0x3d, 0x27, 0x71, 0xbe, 0xcb, 0x19, 0x0b, 0x9a, 0xc0, 0xe2, 0xac, 0xf0,
+	0x56, 0x5b, 0xef, 0x9c, 0x21, 0xc9, 0xf7, 0xe3, 0x21, 0x7d, 0x42, 0xa5, 0x71, 0x8a, 0x5d, 0x78,
+	0x0f, 0x7c, 0xd1, 0xb2, 0x84, 0x4c, 0x0d, 0x21, 0xc8, 0xb9, 0x18, 0x06, 0xf7, 0xa1, 0x9a, 0x51,
+	0x61, 0x50, 0xb6, 0xfc, 0x9c, 0xeb, 0x44, 0xa8, 0xec, 0xca, 0x9a, 0x1b, 0x55, 0x50, 0x84, 0x83,
+	0xf2, 0xb1, 0xd7, 0x2e, 0x68, 0x48, 0x45, 0x84, 0x26, 0x3d, 0x51, 0x85, 0xf9, 0xf0, 0xa6, 0x8c,
+	0x69, 0x33, 0xa5, 0xc9, 0x14, 0x9f, 0x16, 0xe3, 0x22, 0x47, 0xd3, 0xa8, 0x49, 0x5a, 0x74, 0xfb,
+	0x68, 0x7b, 0x19, 0x95, 0xc6, 0xb2, 0x8b, 0xa2, 0xa7, 0x2c, 0xf0, 0x36, 0xee, 0xf8, 0x9e, 0xc1,
+	0x83, 0x26, 0x8a, 0xde, 0x20, 0x6a, 0x0b, 0x4f, 0xfb, 0x18, 0x87, 0x7a, 0x31, 0xb5, 0x55, 0xb8,
+	0xf5, 0x3a, 0x24, 0x19, 0x39, 0xd2, 0x4b, 0xb9, 0xa4, 0x3e, 0xcf, 0x93, 0x6b, 0x2c, 0x5d, 0xd7,
+	0x70, 0xd4, 0x07, 0x82, 0x27, 0x19, 0x0b, 0xd4, 0x82, 0xce, 0x94, 0x63, 0x63, 0x6f, 0x23, 0x7c,
+	0x9f, 0x12, 0x4f, 0x34, 0xfb, 0xc7, 0x80, 0x06, 0x14, 0x73, 0x94, 0xe3, 0xe8, 0x4b, 0x54, 0x9a,
+	0x49, 0xfb, 0x5c, 0x71, 0x47, 0x79, 0x45, 0x5a, 0x2a, 0xdd, 0x59, 0x62, 0xc9, 0xfe, 0x0c, 0x5f,
+	0x4e, 0x57, 0xda, 0x14, 0xbb, 0xf4, 0x66, 0x9b, 0xdb, 0x7f, 0xba, 0xd8, 0x22, 0xe4, 0x03, 0x6d,
+	0x8b, 0x82, 0x62, 0x96, 0xf4, 0x61, 0xc5, 0x8f, 0x8b, 0x5c, 0xf5, 0x07, 0x05, 0x32, 0x35, 0x07,
+	0x5a, 0x86, 0x4d, 0x76, 0x0c, 0x8f, 0x27, 0xf3, 0x85, 0xa9, 0x31, 0x44, 0x55, 0xe0, 0xb5, 0x2a,
+	0x14, 0xc7, 0x9c, 0x53, 0x46, 0x1d, 0xd2, 0x64, 0x91, 0x69, 0x8c, 0x0e, 0x0f, 0xb2, 0xea, 0xe9,
+	0xc8, 0x3b, 0x26, 0xca, 0x07, 0x33, 0xf9, 0xfc, 0x7f, 0x3e, 0xa1, 0xf7, 0xa1, 0x7a, 0x86, 0x9e,
+	0xf2, 0xb8, 0x2b, 0xc9, 0x64, 0xc3, 0x2d, 0x73, 0x19, 0x79, 0x0e, 0x8f, 0x66, 0x09, 0xa2, 0x3f,
+	0xdc, 0xae, 0xef, 0x8e, 0x2f, 0x8f, 0xf5, 0x7f, 0x01, 0x00, 0x00, 0xff, 0xff, 0x03, 0x00, 0xf6,
+	0x76, 0x0b, 0x33, 0x49, 0x0e, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -197,6 +216,9 @@ type MasterServerSideOpClient interface {
 	GetMinorBlockList(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
 	GetMinorBlockHeaderList(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
 	GetMinorBlockHeaderListWithSkip(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
+	// snapshots of the shards bootstrapped by the new slaves
+	BootstrapShard(ctx context.Context, in *Request, opts ...grpc.CallOption) (MasterServerSideOp_BootstrapShardClient, error)
+	ShardBootstrapped(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
 }
 
 type masterServerSideOpClient struct {
@@ -279,6 +301,47 @@ func (c *masterServerSideOpClient) GetMinorBlockHeaderListWithSkip(ctx context.C
 	return out, nil
 }
 
+func (c *masterServerSideOpClient) BootstrapShard(ctx context.Context, in *Request, opts ...grpc.CallOption) (MasterServerSideOp_BootstrapShardClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_MasterServerSideOp_serviceDesc.Streams[0], "/rpc.MasterServerSideOp/BootstrapShard", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &masterServerSideOpBootstrapShardClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type MasterServerSideOp_BootstrapShardClient interface {
+	Recv() (*Response, error)
+	grpc.ClientStream
+}
+
+type masterServerSideOpBootstrapShardClient struct {
+	grpc.ClientStream
+}
+
+func (x *masterServerSideOpBootstrapShardClient) Recv() (*Response, error) {
+	m := new(Response)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *masterServerSideOpClient) ShardBootstrapped(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(ctx, "/rpc.MasterServerSideOp/ShardBootstrapped", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // MasterServerSideOpServer is the server API for MasterServerSideOp service.
 type MasterServerSideOpServer interface {
 	AddMinorBlockHeader(context.Context, *Request) (*Response, error)
@@ -290,6 +353,9 @@ type MasterServerSideOpServer interface {
 	GetMinorBlockList(context.Context, *Request) (*Response, error)
 	GetMinorBlockHeaderList(context.Context, *Request) (*Response, error)
 	GetMinorBlockHeaderListWithSkip(context.Context, *Request) (*Response, error)
+	// snapshots of the shards bootstrapped by the new slaves
+	BootstrapShard(*Request, MasterServerSideOp_BootstrapShardServer) error
+	ShardBootstrapped(context.Context, *Request) (*Response, error)
 }
 
 // UnimplementedMasterServerSideOpServer can be embedded to have forward compatible implementations.
@@ -320,6 +386,12 @@ func (*UnimplementedMasterServerSideOpServer) GetMinorBlockHeaderList(ctx contex
 func (*UnimplementedMasterServerSideOpServer) GetMinorBlockHeaderListWithSkip(ctx context.Context, req *Request) (*Response, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetMinorBlockHeaderListWithSkip not implemented")
 }
+func (*UnimplementedMasterServerSideOpServer) BootstrapShard(req *Request, srv MasterServerSideOp_BootstrapShardServer) error {
+	return status.Errorf(codes.Unimplemented, "method BootstrapShard not implemented")
+}
+func (*UnimplementedMasterServerSideOpServer) ShardBootstrapped(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ShardBootstrapped not implemented")
+}
 
 func RegisterMasterServerSideOpServer(s *grpc.Server, srv MasterServerSideOpServer) {
 	s.RegisterService(&_MasterServerSideOp_serviceDesc, srv)
@@ -469,6 +541,45 @@ func _MasterServerSideOp_GetMinorBlockHeaderListWithSkip_Handler(srv interface{}
 	return interceptor(ctx, in, info, handler)
 }
 
+func _MasterServerSideOp_BootstrapShard_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Request)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MasterServerSideOpServer).BootstrapShard(m, &masterServerSideOpBootstrapShardServer{stream})
+}
+
+type MasterServerSideOp_BootstrapShardServer interface {
+	Send(*Response) error
+	grpc.ServerStream
+}
+
+type masterServerSideOpBootstrapShardServer struct {
+	grpc.ServerStream
+}
+
+func (x *masterServerSideOpBootstrapShardServer) Send(m *Response) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _MasterServerSideOp_ShardBootstrapped_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MasterServerSideOpServer).ShardBootstrapped(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.MasterServerSideOp/ShardBootstrapped",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MasterServerSideOpServer).ShardBootstrapped(ctx, req.(*Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _MasterServerSideOp_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "rpc.MasterServerSideOp",
 	HandlerType: (*MasterServerSideOpServer)(nil),
@@ -505,8 +616,18 @@ var _MasterServerSideOp_serviceDesc = grpc.ServiceDesc{
 			MethodName: "GetMinorBlockHeaderListWithSkip",
 			Handler:    _MasterServerSideOp_GetMinorBlockHeaderListWithSkip_Handler,
 		},
+		{
+			MethodName: "ShardBootstrapped",
+			Handler:    _MasterServerSideOp_ShardBootstrapped_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "BootstrapShard",
+			Handler:       _MasterServerSideOp_BootstrapShard_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "rpc.proto",
 }
 
@@ -543,6 +664,31 @@ type SlaveServerSideOpClient interface {
 	AddMinorBlockListForSync(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
 	SetMining(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
 	CheckMinorBlocksInRoot(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
+	GetStaleBlocks(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
+	GetUnreceivedXShardDeposits(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
+	CreateAccessList(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
+	ReloadConfig(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
+	Profile(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
+	SetLogLevel(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
+	GetSlowOps(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
+	GetBlockProfiles(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
+	GetTxBenchmarkReports(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
+	ReplayBlocks(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
+	BackupShard(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
+	GetShardRootTips(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
+	ResendXshardTxList(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
+	GetShardSnapshot(ctx context.Context, in *Request, opts ...grpc.CallOption) (SlaveServerSideOp_GetShardSnapshotClient, error)
+	GetBalanceHistory(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
+	GetXShardQueues(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
+	ShardMaintenance(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
+	GetPendingAccountData(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
+	ExecutePendingTransaction(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
+	GetMinorBlockReceipts(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
+	TraceBlock(ctx context.Context, in *Request, opts ...grpc.CallOption) (SlaveServerSideOp_TraceBlockClient, error)
+	SimulateBundle(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
+	GetStateAvailability(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
+	RegenerateState(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
+	ExportBlocks(ctx context.Context, in *Request, opts ...grpc.CallOption) (SlaveServerSideOp_ExportBlocksClient, error)
 	// p2p apis
 	GetMinorBlockList(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
 	GetMinorBlockHeaderList(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
@@ -803,166 +949,485 @@ func (c *slaveServerSideOpClient) CheckMinorBlocksInRoot(ctx context.Context, in
 	return out, nil
 }
 
-func (c *slaveServerSideOpClient) GetMinorBlockList(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+func (c *slaveServerSideOpClient) GetStaleBlocks(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
 	out := new(Response)
-	err := c.cc.Invoke(ctx, "/rpc.SlaveServerSideOp/GetMinorBlockList", in, out, opts...)
+	err := c.cc.Invoke(ctx, "/rpc.SlaveServerSideOp/GetStaleBlocks", in, out, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *slaveServerSideOpClient) GetMinorBlockHeaderList(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+func (c *slaveServerSideOpClient) GetUnreceivedXShardDeposits(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
 	out := new(Response)
-	err := c.cc.Invoke(ctx, "/rpc.SlaveServerSideOp/GetMinorBlockHeaderList", in, out, opts...)
+	err := c.cc.Invoke(ctx, "/rpc.SlaveServerSideOp/GetUnreceivedXShardDeposits", in, out, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *slaveServerSideOpClient) GetMinorBlockHeaderListWithSkip(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+func (c *slaveServerSideOpClient) CreateAccessList(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
 	out := new(Response)
-	err := c.cc.Invoke(ctx, "/rpc.SlaveServerSideOp/GetMinorBlockHeaderListWithSkip", in, out, opts...)
+	err := c.cc.Invoke(ctx, "/rpc.SlaveServerSideOp/CreateAccessList", in, out, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *slaveServerSideOpClient) HandleNewTip(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+func (c *slaveServerSideOpClient) ReloadConfig(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
 	out := new(Response)
-	err := c.cc.Invoke(ctx, "/rpc.SlaveServerSideOp/HandleNewTip", in, out, opts...)
+	err := c.cc.Invoke(ctx, "/rpc.SlaveServerSideOp/ReloadConfig", in, out, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *slaveServerSideOpClient) AddTransactions(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+func (c *slaveServerSideOpClient) Profile(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
 	out := new(Response)
-	err := c.cc.Invoke(ctx, "/rpc.SlaveServerSideOp/AddTransactions", in, out, opts...)
+	err := c.cc.Invoke(ctx, "/rpc.SlaveServerSideOp/Profile", in, out, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *slaveServerSideOpClient) HandleNewMinorBlock(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+func (c *slaveServerSideOpClient) SetLogLevel(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
 	out := new(Response)
-	err := c.cc.Invoke(ctx, "/rpc.SlaveServerSideOp/HandleNewMinorBlock", in, out, opts...)
+	err := c.cc.Invoke(ctx, "/rpc.SlaveServerSideOp/SetLogLevel", in, out, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-// SlaveServerSideOpServer is the server API for SlaveServerSideOp service.
-type SlaveServerSideOpServer interface {
-	HeartBeat(context.Context, *Request) (*Response, error)
-	MasterInfo(context.Context, *Request) (*Response, error)
-	// APIs for master
-	Ping(context.Context, *Request) (*Response, error)
-	GenTx(context.Context, *Request) (*Response, error)
-	AddRootBlock(context.Context, *Request) (*Response, error)
-	GetUnconfirmedHeaderList(context.Context, *Request) (*Response, error)
-	GetAccountData(context.Context, *Request) (*Response, error)
-	AddTransaction(context.Context, *Request) (*Response, error)
-	GetMinorBlock(context.Context, *Request) (*Response, error)
-	GetTransaction(context.Context, *Request) (*Response, error)
-	ExecuteTransaction(context.Context, *Request) (*Response, error)
-	GetTransactionReceipt(context.Context, *Request) (*Response, error)
-	GetTransactionListByAddress(context.Context, *Request) (*Response, error)
-	GetAllTx(context.Context, *Request) (*Response, error)
-	GetLogs(context.Context, *Request) (*Response, error)
-	EstimateGas(context.Context, *Request) (*Response, error)
-	GetStorageAt(context.Context, *Request) (*Response, error)
-	GetCode(context.Context, *Request) (*Response, error)
-	GasPrice(context.Context, *Request) (*Response, error)
-	GetWork(context.Context, *Request) (*Response, error)
-	SubmitWork(context.Context, *Request) (*Response, error)
-	GetRootChainStakes(context.Context, *Request) (*Response, error)
-	// APIs for neighbor slaves
-	AddXshardTxList(context.Context, *Request) (*Response, error)
-	BatchAddXshardTxList(context.Context, *Request) (*Response, error)
-	AddMinorBlockListForSync(context.Context, *Request) (*Response, error)
-	SetMining(context.Context, *Request) (*Response, error)
-	CheckMinorBlocksInRoot(context.Context, *Request) (*Response, error)
-	// p2p apis
-	GetMinorBlockList(context.Context, *Request) (*Response, error)
-	GetMinorBlockHeaderList(context.Context, *Request) (*Response, error)
-	GetMinorBlockHeaderListWithSkip(context.Context, *Request) (*Response, error)
-	HandleNewTip(context.Context, *Request) (*Response, error)
-	AddTransactions(context.Context, *Request) (*Response, error)
-	HandleNewMinorBlock(context.Context, *Request) (*Response, error)
+func (c *slaveServerSideOpClient) GetSlowOps(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(ctx, "/rpc.SlaveServerSideOp/GetSlowOps", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
-// UnimplementedSlaveServerSideOpServer can be embedded to have forward compatible implementations.
-type UnimplementedSlaveServerSideOpServer struct {
+func (c *slaveServerSideOpClient) GetBlockProfiles(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(ctx, "/rpc.SlaveServerSideOp/GetBlockProfiles", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
-func (*UnimplementedSlaveServerSideOpServer) HeartBeat(ctx context.Context, req *Request) (*Response, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method HeartBeat not implemented")
-}
-func (*UnimplementedSlaveServerSideOpServer) MasterInfo(ctx context.Context, req *Request) (*Response, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method MasterInfo not implemented")
+func (c *slaveServerSideOpClient) GetTxBenchmarkReports(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(ctx, "/rpc.SlaveServerSideOp/GetTxBenchmarkReports", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
-func (*UnimplementedSlaveServerSideOpServer) Ping(ctx context.Context, req *Request) (*Response, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method Ping not implemented")
+
+func (c *slaveServerSideOpClient) ReplayBlocks(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(ctx, "/rpc.SlaveServerSideOp/ReplayBlocks", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
-func (*UnimplementedSlaveServerSideOpServer) GenTx(ctx context.Context, req *Request) (*Response, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method GenTx not implemented")
+
+func (c *slaveServerSideOpClient) BackupShard(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(ctx, "/rpc.SlaveServerSideOp/BackupShard", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
-func (*UnimplementedSlaveServerSideOpServer) AddRootBlock(ctx context.Context, req *Request) (*Response, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method AddRootBlock not implemented")
+
+func (c *slaveServerSideOpClient) GetShardRootTips(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(ctx, "/rpc.SlaveServerSideOp/GetShardRootTips", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
-func (*UnimplementedSlaveServerSideOpServer) GetUnconfirmedHeaderList(ctx context.Context, req *Request) (*Response, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method GetUnconfirmedHeaderList not implemented")
+
+func (c *slaveServerSideOpClient) ResendXshardTxList(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(ctx, "/rpc.SlaveServerSideOp/ResendXshardTxList", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
-func (*UnimplementedSlaveServerSideOpServer) GetAccountData(ctx context.Context, req *Request) (*Response, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method GetAccountData not implemented")
+
+func (c *slaveServerSideOpClient) GetShardSnapshot(ctx context.Context, in *Request, opts ...grpc.CallOption) (SlaveServerSideOp_GetShardSnapshotClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_SlaveServerSideOp_serviceDesc.Streams[0], "/rpc.SlaveServerSideOp/GetShardSnapshot", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &slaveServerSideOpGetShardSnapshotClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
 }
-func (*UnimplementedSlaveServerSideOpServer) AddTransaction(ctx context.Context, req *Request) (*Response, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method AddTransaction not implemented")
+
+type SlaveServerSideOp_GetShardSnapshotClient interface {
+	Recv() (*Response, error)
+	grpc.ClientStream
 }
-func (*UnimplementedSlaveServerSideOpServer) GetMinorBlock(ctx context.Context, req *Request) (*Response, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method GetMinorBlock not implemented")
+
+type slaveServerSideOpGetShardSnapshotClient struct {
+	grpc.ClientStream
 }
-func (*UnimplementedSlaveServerSideOpServer) GetTransaction(ctx context.Context, req *Request) (*Response, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method GetTransaction not implemented")
+
+func (x *slaveServerSideOpGetShardSnapshotClient) Recv() (*Response, error) {
+	m := new(Response)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
 }
-func (*UnimplementedSlaveServerSideOpServer) ExecuteTransaction(ctx context.Context, req *Request) (*Response, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method ExecuteTransaction not implemented")
+
+func (c *slaveServerSideOpClient) GetBalanceHistory(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(ctx, "/rpc.SlaveServerSideOp/GetBalanceHistory", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
-func (*UnimplementedSlaveServerSideOpServer) GetTransactionReceipt(ctx context.Context, req *Request) (*Response, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method GetTransactionReceipt not implemented")
+
+func (c *slaveServerSideOpClient) GetXShardQueues(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(ctx, "/rpc.SlaveServerSideOp/GetXShardQueues", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
-func (*UnimplementedSlaveServerSideOpServer) GetTransactionListByAddress(ctx context.Context, req *Request) (*Response, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method GetTransactionListByAddress not implemented")
+
+func (c *slaveServerSideOpClient) ShardMaintenance(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(ctx, "/rpc.SlaveServerSideOp/ShardMaintenance", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
-func (*UnimplementedSlaveServerSideOpServer) GetAllTx(ctx context.Context, req *Request) (*Response, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method GetAllTx not implemented")
+
+func (c *slaveServerSideOpClient) GetPendingAccountData(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(ctx, "/rpc.SlaveServerSideOp/GetPendingAccountData", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
-func (*UnimplementedSlaveServerSideOpServer) GetLogs(ctx context.Context, req *Request) (*Response, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method GetLogs not implemented")
+
+func (c *slaveServerSideOpClient) ExecutePendingTransaction(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(ctx, "/rpc.SlaveServerSideOp/ExecutePendingTransaction", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
-func (*UnimplementedSlaveServerSideOpServer) EstimateGas(ctx context.Context, req *Request) (*Response, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method EstimateGas not implemented")
+
+func (c *slaveServerSideOpClient) GetMinorBlockReceipts(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(ctx, "/rpc.SlaveServerSideOp/GetMinorBlockReceipts", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
-func (*UnimplementedSlaveServerSideOpServer) GetStorageAt(ctx context.Context, req *Request) (*Response, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method GetStorageAt not implemented")
+
+func (c *slaveServerSideOpClient) TraceBlock(ctx context.Context, in *Request, opts ...grpc.CallOption) (SlaveServerSideOp_TraceBlockClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_SlaveServerSideOp_serviceDesc.Streams[1], "/rpc.SlaveServerSideOp/TraceBlock", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &slaveServerSideOpTraceBlockClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
 }
-func (*UnimplementedSlaveServerSideOpServer) GetCode(ctx context.Context, req *Request) (*Response, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method GetCode not implemented")
+
+type SlaveServerSideOp_TraceBlockClient interface {
+	Recv() (*Response, error)
+	grpc.ClientStream
 }
-func (*UnimplementedSlaveServerSideOpServer) GasPrice(ctx context.Context, req *Request) (*Response, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method GasPrice not implemented")
+
+type slaveServerSideOpTraceBlockClient struct {
+	grpc.ClientStream
 }
-func (*UnimplementedSlaveServerSideOpServer) GetWork(ctx context.Context, req *Request) (*Response, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method GetWork not implemented")
+
+func (x *slaveServerSideOpTraceBlockClient) Recv() (*Response, error) {
+	m := new(Response)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
 }
-func (*UnimplementedSlaveServerSideOpServer) SubmitWork(ctx context.Context, req *Request) (*Response, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method SubmitWork not implemented")
+
+func (c *slaveServerSideOpClient) SimulateBundle(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(ctx, "/rpc.SlaveServerSideOp/SimulateBundle", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *slaveServerSideOpClient) GetStateAvailability(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(ctx, "/rpc.SlaveServerSideOp/GetStateAvailability", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *slaveServerSideOpClient) RegenerateState(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(ctx, "/rpc.SlaveServerSideOp/RegenerateState", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *slaveServerSideOpClient) ExportBlocks(ctx context.Context, in *Request, opts ...grpc.CallOption) (SlaveServerSideOp_ExportBlocksClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_SlaveServerSideOp_serviceDesc.Streams[2], "/rpc.SlaveServerSideOp/ExportBlocks", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &slaveServerSideOpExportBlocksClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type SlaveServerSideOp_ExportBlocksClient interface {
+	Recv() (*Response, error)
+	grpc.ClientStream
+}
+
+type slaveServerSideOpExportBlocksClient struct {
+	grpc.ClientStream
+}
+
+func (x *slaveServerSideOpExportBlocksClient) Recv() (*Response, error) {
+	m := new(Response)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *slaveServerSideOpClient) GetMinorBlockList(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(ctx, "/rpc.SlaveServerSideOp/GetMinorBlockList", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *slaveServerSideOpClient) GetMinorBlockHeaderList(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(ctx, "/rpc.SlaveServerSideOp/GetMinorBlockHeaderList", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *slaveServerSideOpClient) GetMinorBlockHeaderListWithSkip(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(ctx, "/rpc.SlaveServerSideOp/GetMinorBlockHeaderListWithSkip", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *slaveServerSideOpClient) HandleNewTip(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(ctx, "/rpc.SlaveServerSideOp/HandleNewTip", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *slaveServerSideOpClient) AddTransactions(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(ctx, "/rpc.SlaveServerSideOp/AddTransactions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *slaveServerSideOpClient) HandleNewMinorBlock(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(ctx, "/rpc.SlaveServerSideOp/HandleNewMinorBlock", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SlaveServerSideOpServer is the server API for SlaveServerSideOp service.
+type SlaveServerSideOpServer interface {
+	HeartBeat(context.Context, *Request) (*Response, error)
+	MasterInfo(context.Context, *Request) (*Response, error)
+	// APIs for master
+	Ping(context.Context, *Request) (*Response, error)
+	GenTx(context.Context, *Request) (*Response, error)
+	AddRootBlock(context.Context, *Request) (*Response, error)
+	GetUnconfirmedHeaderList(context.Context, *Request) (*Response, error)
+	GetAccountData(context.Context, *Request) (*Response, error)
+	AddTransaction(context.Context, *Request) (*Response, error)
+	GetMinorBlock(context.Context, *Request) (*Response, error)
+	GetTransaction(context.Context, *Request) (*Response, error)
+	ExecuteTransaction(context.Context, *Request) (*Response, error)
+	GetTransactionReceipt(context.Context, *Request) (*Response, error)
+	GetTransactionListByAddress(context.Context, *Request) (*Response, error)
+	GetAllTx(context.Context, *Request) (*Response, error)
+	GetLogs(context.Context, *Request) (*Response, error)
+	EstimateGas(context.Context, *Request) (*Response, error)
+	GetStorageAt(context.Context, *Request) (*Response, error)
+	GetCode(context.Context, *Request) (*Response, error)
+	GasPrice(context.Context, *Request) (*Response, error)
+	GetWork(context.Context, *Request) (*Response, error)
+	SubmitWork(context.Context, *Request) (*Response, error)
+	GetRootChainStakes(context.Context, *Request) (*Response, error)
+	// APIs for neighbor slaves
+	AddXshardTxList(context.Context, *Request) (*Response, error)
+	BatchAddXshardTxList(context.Context, *Request) (*Response, error)
+	AddMinorBlockListForSync(context.Context, *Request) (*Response, error)
+	SetMining(context.Context, *Request) (*Response, error)
+	CheckMinorBlocksInRoot(context.Context, *Request) (*Response, error)
+	GetStaleBlocks(context.Context, *Request) (*Response, error)
+	GetUnreceivedXShardDeposits(context.Context, *Request) (*Response, error)
+	CreateAccessList(context.Context, *Request) (*Response, error)
+	ReloadConfig(context.Context, *Request) (*Response, error)
+	Profile(context.Context, *Request) (*Response, error)
+	SetLogLevel(context.Context, *Request) (*Response, error)
+	GetSlowOps(context.Context, *Request) (*Response, error)
+	GetBlockProfiles(context.Context, *Request) (*Response, error)
+	GetTxBenchmarkReports(context.Context, *Request) (*Response, error)
+	ReplayBlocks(context.Context, *Request) (*Response, error)
+	BackupShard(context.Context, *Request) (*Response, error)
+	GetShardRootTips(context.Context, *Request) (*Response, error)
+	ResendXshardTxList(context.Context, *Request) (*Response, error)
+	GetShardSnapshot(*Request, SlaveServerSideOp_GetShardSnapshotServer) error
+	GetBalanceHistory(context.Context, *Request) (*Response, error)
+	GetXShardQueues(context.Context, *Request) (*Response, error)
+	ShardMaintenance(context.Context, *Request) (*Response, error)
+	GetPendingAccountData(context.Context, *Request) (*Response, error)
+	ExecutePendingTransaction(context.Context, *Request) (*Response, error)
+	GetMinorBlockReceipts(context.Context, *Request) (*Response, error)
+	TraceBlock(*Request, SlaveServerSideOp_TraceBlockServer) error
+	SimulateBundle(context.Context, *Request) (*Response, error)
+	GetStateAvailability(context.Context, *Request) (*Response, error)
+	RegenerateState(context.Context, *Request) (*Response, error)
+	ExportBlocks(*Request, SlaveServerSideOp_ExportBlocksServer) error
+	// p2p apis
+	GetMinorBlockList(context.Context, *Request) (*Response, error)
+	GetMinorBlockHeaderList(context.Context, *Request) (*Response, error)
+	GetMinorBlockHeaderListWithSkip(context.Context, *Request) (*Response, error)
+	HandleNewTip(context.Context, *Request) (*Response, error)
+	AddTransactions(context.Context, *Request) (*Response, error)
+	HandleNewMinorBlock(context.Context, *Request) (*Response, error)
+}
+
+// UnimplementedSlaveServerSideOpServer can be embedded to have forward compatible implementations.
+type UnimplementedSlaveServerSideOpServer struct {
+}
+
+func (*UnimplementedSlaveServerSideOpServer) HeartBeat(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method HeartBeat not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) MasterInfo(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MasterInfo not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) Ping(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Ping not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) GenTx(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GenTx not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) AddRootBlock(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddRootBlock not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) GetUnconfirmedHeaderList(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUnconfirmedHeaderList not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) GetAccountData(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAccountData not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) AddTransaction(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddTransaction not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) GetMinorBlock(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMinorBlock not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) GetTransaction(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTransaction not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) ExecuteTransaction(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExecuteTransaction not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) GetTransactionReceipt(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTransactionReceipt not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) GetTransactionListByAddress(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTransactionListByAddress not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) GetAllTx(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAllTx not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) GetLogs(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLogs not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) EstimateGas(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EstimateGas not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) GetStorageAt(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStorageAt not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) GetCode(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCode not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) GasPrice(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GasPrice not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) GetWork(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetWork not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) SubmitWork(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitWork not implemented")
 }
 func (*UnimplementedSlaveServerSideOpServer) GetRootChainStakes(ctx context.Context, req *Request) (*Response, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetRootChainStakes not implemented")
@@ -982,6 +1447,81 @@ func (*UnimplementedSlaveServerSideOpServer) SetMining(ctx context.Context, req
 func (*UnimplementedSlaveServerSideOpServer) CheckMinorBlocksInRoot(ctx context.Context, req *Request) (*Response, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CheckMinorBlocksInRoot not implemented")
 }
+func (*UnimplementedSlaveServerSideOpServer) GetStaleBlocks(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStaleBlocks not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) GetUnreceivedXShardDeposits(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUnreceivedXShardDeposits not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) CreateAccessList(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateAccessList not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) ReloadConfig(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReloadConfig not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) Profile(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Profile not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) SetLogLevel(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetLogLevel not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) GetSlowOps(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSlowOps not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) GetBlockProfiles(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBlockProfiles not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) GetTxBenchmarkReports(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTxBenchmarkReports not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) ReplayBlocks(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReplayBlocks not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) BackupShard(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BackupShard not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) GetShardRootTips(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetShardRootTips not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) ResendXshardTxList(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ResendXshardTxList not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) GetShardSnapshot(req *Request, srv SlaveServerSideOp_GetShardSnapshotServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetShardSnapshot not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) GetBalanceHistory(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBalanceHistory not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) GetXShardQueues(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetXShardQueues not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) ShardMaintenance(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ShardMaintenance not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) GetPendingAccountData(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPendingAccountData not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) ExecutePendingTransaction(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExecutePendingTransaction not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) GetMinorBlockReceipts(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMinorBlockReceipts not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) TraceBlock(req *Request, srv SlaveServerSideOp_TraceBlockServer) error {
+	return status.Errorf(codes.Unimplemented, "method TraceBlock not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) SimulateBundle(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SimulateBundle not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) GetStateAvailability(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStateAvailability not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) RegenerateState(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegenerateState not implemented")
+}
+func (*UnimplementedSlaveServerSideOpServer) ExportBlocks(req *Request, srv SlaveServerSideOp_ExportBlocksServer) error {
+	return status.Errorf(codes.Unimplemented, "method ExportBlocks not implemented")
+}
 func (*UnimplementedSlaveServerSideOpServer) GetMinorBlockList(ctx context.Context, req *Request) (*Response, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetMinorBlockList not implemented")
 }
@@ -997,500 +1537,959 @@ func (*UnimplementedSlaveServerSideOpServer) HandleNewTip(ctx context.Context, r
 func (*UnimplementedSlaveServerSideOpServer) AddTransactions(ctx context.Context, req *Request) (*Response, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method AddTransactions not implemented")
 }
-func (*UnimplementedSlaveServerSideOpServer) HandleNewMinorBlock(ctx context.Context, req *Request) (*Response, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method HandleNewMinorBlock not implemented")
+func (*UnimplementedSlaveServerSideOpServer) HandleNewMinorBlock(ctx context.Context, req *Request) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method HandleNewMinorBlock not implemented")
+}
+
+func RegisterSlaveServerSideOpServer(s *grpc.Server, srv SlaveServerSideOpServer) {
+	s.RegisterService(&_SlaveServerSideOp_serviceDesc, srv)
+}
+
+func _SlaveServerSideOp_HeartBeat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveServerSideOpServer).HeartBeat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.SlaveServerSideOp/HeartBeat",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveServerSideOpServer).HeartBeat(ctx, req.(*Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlaveServerSideOp_MasterInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveServerSideOpServer).MasterInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.SlaveServerSideOp/MasterInfo",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveServerSideOpServer).MasterInfo(ctx, req.(*Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlaveServerSideOp_Ping_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveServerSideOpServer).Ping(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.SlaveServerSideOp/Ping",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveServerSideOpServer).Ping(ctx, req.(*Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlaveServerSideOp_GenTx_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveServerSideOpServer).GenTx(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.SlaveServerSideOp/GenTx",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveServerSideOpServer).GenTx(ctx, req.(*Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlaveServerSideOp_AddRootBlock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveServerSideOpServer).AddRootBlock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.SlaveServerSideOp/AddRootBlock",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveServerSideOpServer).AddRootBlock(ctx, req.(*Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlaveServerSideOp_GetUnconfirmedHeaderList_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveServerSideOpServer).GetUnconfirmedHeaderList(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.SlaveServerSideOp/GetUnconfirmedHeaderList",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveServerSideOpServer).GetUnconfirmedHeaderList(ctx, req.(*Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlaveServerSideOp_GetAccountData_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveServerSideOpServer).GetAccountData(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.SlaveServerSideOp/GetAccountData",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveServerSideOpServer).GetAccountData(ctx, req.(*Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlaveServerSideOp_AddTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveServerSideOpServer).AddTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.SlaveServerSideOp/AddTransaction",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveServerSideOpServer).AddTransaction(ctx, req.(*Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlaveServerSideOp_GetMinorBlock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveServerSideOpServer).GetMinorBlock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.SlaveServerSideOp/GetMinorBlock",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveServerSideOpServer).GetMinorBlock(ctx, req.(*Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlaveServerSideOp_GetTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveServerSideOpServer).GetTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.SlaveServerSideOp/GetTransaction",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveServerSideOpServer).GetTransaction(ctx, req.(*Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlaveServerSideOp_ExecuteTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveServerSideOpServer).ExecuteTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.SlaveServerSideOp/ExecuteTransaction",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveServerSideOpServer).ExecuteTransaction(ctx, req.(*Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlaveServerSideOp_GetTransactionReceipt_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveServerSideOpServer).GetTransactionReceipt(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.SlaveServerSideOp/GetTransactionReceipt",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveServerSideOpServer).GetTransactionReceipt(ctx, req.(*Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlaveServerSideOp_GetTransactionListByAddress_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveServerSideOpServer).GetTransactionListByAddress(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.SlaveServerSideOp/GetTransactionListByAddress",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveServerSideOpServer).GetTransactionListByAddress(ctx, req.(*Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlaveServerSideOp_GetAllTx_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveServerSideOpServer).GetAllTx(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.SlaveServerSideOp/GetAllTx",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveServerSideOpServer).GetAllTx(ctx, req.(*Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlaveServerSideOp_GetLogs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveServerSideOpServer).GetLogs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.SlaveServerSideOp/GetLogs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveServerSideOpServer).GetLogs(ctx, req.(*Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlaveServerSideOp_EstimateGas_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveServerSideOpServer).EstimateGas(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.SlaveServerSideOp/EstimateGas",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveServerSideOpServer).EstimateGas(ctx, req.(*Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlaveServerSideOp_GetStorageAt_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveServerSideOpServer).GetStorageAt(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.SlaveServerSideOp/GetStorageAt",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveServerSideOpServer).GetStorageAt(ctx, req.(*Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlaveServerSideOp_GetCode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveServerSideOpServer).GetCode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.SlaveServerSideOp/GetCode",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveServerSideOpServer).GetCode(ctx, req.(*Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlaveServerSideOp_GasPrice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveServerSideOpServer).GasPrice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.SlaveServerSideOp/GasPrice",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveServerSideOpServer).GasPrice(ctx, req.(*Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlaveServerSideOp_GetWork_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveServerSideOpServer).GetWork(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.SlaveServerSideOp/GetWork",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveServerSideOpServer).GetWork(ctx, req.(*Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlaveServerSideOp_SubmitWork_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveServerSideOpServer).SubmitWork(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.SlaveServerSideOp/SubmitWork",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveServerSideOpServer).SubmitWork(ctx, req.(*Request))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func RegisterSlaveServerSideOpServer(s *grpc.Server, srv SlaveServerSideOpServer) {
-	s.RegisterService(&_SlaveServerSideOp_serviceDesc, srv)
+func _SlaveServerSideOp_GetRootChainStakes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlaveServerSideOpServer).GetRootChainStakes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.SlaveServerSideOp/GetRootChainStakes",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlaveServerSideOpServer).GetRootChainStakes(ctx, req.(*Request))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func _SlaveServerSideOp_HeartBeat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+func _SlaveServerSideOp_AddXshardTxList_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Request)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(SlaveServerSideOpServer).HeartBeat(ctx, in)
+		return srv.(SlaveServerSideOpServer).AddXshardTxList(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/rpc.SlaveServerSideOp/HeartBeat",
+		FullMethod: "/rpc.SlaveServerSideOp/AddXshardTxList",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(SlaveServerSideOpServer).HeartBeat(ctx, req.(*Request))
+		return srv.(SlaveServerSideOpServer).AddXshardTxList(ctx, req.(*Request))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _SlaveServerSideOp_MasterInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+func _SlaveServerSideOp_BatchAddXshardTxList_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Request)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(SlaveServerSideOpServer).MasterInfo(ctx, in)
+		return srv.(SlaveServerSideOpServer).BatchAddXshardTxList(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/rpc.SlaveServerSideOp/MasterInfo",
+		FullMethod: "/rpc.SlaveServerSideOp/BatchAddXshardTxList",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(SlaveServerSideOpServer).MasterInfo(ctx, req.(*Request))
+		return srv.(SlaveServerSideOpServer).BatchAddXshardTxList(ctx, req.(*Request))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _SlaveServerSideOp_Ping_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+func _SlaveServerSideOp_AddMinorBlockListForSync_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Request)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(SlaveServerSideOpServer).Ping(ctx, in)
+		return srv.(SlaveServerSideOpServer).AddMinorBlockListForSync(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/rpc.SlaveServerSideOp/Ping",
+		FullMethod: "/rpc.SlaveServerSideOp/AddMinorBlockListForSync",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(SlaveServerSideOpServer).Ping(ctx, req.(*Request))
+		return srv.(SlaveServerSideOpServer).AddMinorBlockListForSync(ctx, req.(*Request))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _SlaveServerSideOp_GenTx_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+func _SlaveServerSideOp_SetMining_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Request)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(SlaveServerSideOpServer).GenTx(ctx, in)
+		return srv.(SlaveServerSideOpServer).SetMining(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/rpc.SlaveServerSideOp/GenTx",
+		FullMethod: "/rpc.SlaveServerSideOp/SetMining",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(SlaveServerSideOpServer).GenTx(ctx, req.(*Request))
+		return srv.(SlaveServerSideOpServer).SetMining(ctx, req.(*Request))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _SlaveServerSideOp_AddRootBlock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+func _SlaveServerSideOp_CheckMinorBlocksInRoot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Request)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(SlaveServerSideOpServer).AddRootBlock(ctx, in)
+		return srv.(SlaveServerSideOpServer).CheckMinorBlocksInRoot(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/rpc.SlaveServerSideOp/AddRootBlock",
+		FullMethod: "/rpc.SlaveServerSideOp/CheckMinorBlocksInRoot",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(SlaveServerSideOpServer).AddRootBlock(ctx, req.(*Request))
+		return srv.(SlaveServerSideOpServer).CheckMinorBlocksInRoot(ctx, req.(*Request))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _SlaveServerSideOp_GetUnconfirmedHeaderList_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+func _SlaveServerSideOp_GetStaleBlocks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Request)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(SlaveServerSideOpServer).GetUnconfirmedHeaderList(ctx, in)
+		return srv.(SlaveServerSideOpServer).GetStaleBlocks(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/rpc.SlaveServerSideOp/GetUnconfirmedHeaderList",
+		FullMethod: "/rpc.SlaveServerSideOp/GetStaleBlocks",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(SlaveServerSideOpServer).GetUnconfirmedHeaderList(ctx, req.(*Request))
+		return srv.(SlaveServerSideOpServer).GetStaleBlocks(ctx, req.(*Request))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _SlaveServerSideOp_GetAccountData_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+func _SlaveServerSideOp_GetUnreceivedXShardDeposits_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Request)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(SlaveServerSideOpServer).GetAccountData(ctx, in)
+		return srv.(SlaveServerSideOpServer).GetUnreceivedXShardDeposits(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/rpc.SlaveServerSideOp/GetAccountData",
+		FullMethod: "/rpc.SlaveServerSideOp/GetUnreceivedXShardDeposits",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(SlaveServerSideOpServer).GetAccountData(ctx, req.(*Request))
+		return srv.(SlaveServerSideOpServer).GetUnreceivedXShardDeposits(ctx, req.(*Request))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _SlaveServerSideOp_AddTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+func _SlaveServerSideOp_CreateAccessList_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Request)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(SlaveServerSideOpServer).AddTransaction(ctx, in)
+		return srv.(SlaveServerSideOpServer).CreateAccessList(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/rpc.SlaveServerSideOp/AddTransaction",
+		FullMethod: "/rpc.SlaveServerSideOp/CreateAccessList",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(SlaveServerSideOpServer).AddTransaction(ctx, req.(*Request))
+		return srv.(SlaveServerSideOpServer).CreateAccessList(ctx, req.(*Request))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _SlaveServerSideOp_GetMinorBlock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+func _SlaveServerSideOp_ReloadConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Request)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(SlaveServerSideOpServer).GetMinorBlock(ctx, in)
+		return srv.(SlaveServerSideOpServer).ReloadConfig(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/rpc.SlaveServerSideOp/GetMinorBlock",
+		FullMethod: "/rpc.SlaveServerSideOp/ReloadConfig",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(SlaveServerSideOpServer).GetMinorBlock(ctx, req.(*Request))
+		return srv.(SlaveServerSideOpServer).ReloadConfig(ctx, req.(*Request))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _SlaveServerSideOp_GetTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+func _SlaveServerSideOp_Profile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Request)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(SlaveServerSideOpServer).GetTransaction(ctx, in)
+		return srv.(SlaveServerSideOpServer).Profile(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/rpc.SlaveServerSideOp/GetTransaction",
+		FullMethod: "/rpc.SlaveServerSideOp/Profile",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(SlaveServerSideOpServer).GetTransaction(ctx, req.(*Request))
+		return srv.(SlaveServerSideOpServer).Profile(ctx, req.(*Request))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _SlaveServerSideOp_ExecuteTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+func _SlaveServerSideOp_SetLogLevel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Request)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(SlaveServerSideOpServer).ExecuteTransaction(ctx, in)
+		return srv.(SlaveServerSideOpServer).SetLogLevel(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/rpc.SlaveServerSideOp/ExecuteTransaction",
+		FullMethod: "/rpc.SlaveServerSideOp/SetLogLevel",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(SlaveServerSideOpServer).ExecuteTransaction(ctx, req.(*Request))
+		return srv.(SlaveServerSideOpServer).SetLogLevel(ctx, req.(*Request))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _SlaveServerSideOp_GetTransactionReceipt_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+func _SlaveServerSideOp_GetSlowOps_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Request)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(SlaveServerSideOpServer).GetTransactionReceipt(ctx, in)
+		return srv.(SlaveServerSideOpServer).GetSlowOps(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/rpc.SlaveServerSideOp/GetTransactionReceipt",
+		FullMethod: "/rpc.SlaveServerSideOp/GetSlowOps",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(SlaveServerSideOpServer).GetTransactionReceipt(ctx, req.(*Request))
+		return srv.(SlaveServerSideOpServer).GetSlowOps(ctx, req.(*Request))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _SlaveServerSideOp_GetTransactionListByAddress_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+func _SlaveServerSideOp_GetBlockProfiles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Request)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(SlaveServerSideOpServer).GetTransactionListByAddress(ctx, in)
+		return srv.(SlaveServerSideOpServer).GetBlockProfiles(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/rpc.SlaveServerSideOp/GetTransactionListByAddress",
+		FullMethod: "/rpc.SlaveServerSideOp/GetBlockProfiles",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(SlaveServerSideOpServer).GetTransactionListByAddress(ctx, req.(*Request))
+		return srv.(SlaveServerSideOpServer).GetBlockProfiles(ctx, req.(*Request))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _SlaveServerSideOp_GetAllTx_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+func _SlaveServerSideOp_GetTxBenchmarkReports_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Request)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(SlaveServerSideOpServer).GetAllTx(ctx, in)
+		return srv.(SlaveServerSideOpServer).GetTxBenchmarkReports(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/rpc.SlaveServerSideOp/GetAllTx",
+		FullMethod: "/rpc.SlaveServerSideOp/GetTxBenchmarkReports",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(SlaveServerSideOpServer).GetAllTx(ctx, req.(*Request))
+		return srv.(SlaveServerSideOpServer).GetTxBenchmarkReports(ctx, req.(*Request))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _SlaveServerSideOp_GetLogs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+func _SlaveServerSideOp_ReplayBlocks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Request)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(SlaveServerSideOpServer).GetLogs(ctx, in)
+		return srv.(SlaveServerSideOpServer).ReplayBlocks(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/rpc.SlaveServerSideOp/GetLogs",
+		FullMethod: "/rpc.SlaveServerSideOp/ReplayBlocks",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(SlaveServerSideOpServer).GetLogs(ctx, req.(*Request))
+		return srv.(SlaveServerSideOpServer).ReplayBlocks(ctx, req.(*Request))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _SlaveServerSideOp_EstimateGas_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+func _SlaveServerSideOp_BackupShard_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Request)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(SlaveServerSideOpServer).EstimateGas(ctx, in)
+		return srv.(SlaveServerSideOpServer).BackupShard(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/rpc.SlaveServerSideOp/EstimateGas",
+		FullMethod: "/rpc.SlaveServerSideOp/BackupShard",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(SlaveServerSideOpServer).EstimateGas(ctx, req.(*Request))
+		return srv.(SlaveServerSideOpServer).BackupShard(ctx, req.(*Request))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _SlaveServerSideOp_GetStorageAt_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+func _SlaveServerSideOp_GetShardRootTips_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Request)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(SlaveServerSideOpServer).GetStorageAt(ctx, in)
+		return srv.(SlaveServerSideOpServer).GetShardRootTips(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/rpc.SlaveServerSideOp/GetStorageAt",
+		FullMethod: "/rpc.SlaveServerSideOp/GetShardRootTips",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(SlaveServerSideOpServer).GetStorageAt(ctx, req.(*Request))
+		return srv.(SlaveServerSideOpServer).GetShardRootTips(ctx, req.(*Request))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _SlaveServerSideOp_GetCode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+func _SlaveServerSideOp_ResendXshardTxList_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Request)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(SlaveServerSideOpServer).GetCode(ctx, in)
+		return srv.(SlaveServerSideOpServer).ResendXshardTxList(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/rpc.SlaveServerSideOp/GetCode",
+		FullMethod: "/rpc.SlaveServerSideOp/ResendXshardTxList",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(SlaveServerSideOpServer).GetCode(ctx, req.(*Request))
+		return srv.(SlaveServerSideOpServer).ResendXshardTxList(ctx, req.(*Request))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _SlaveServerSideOp_GasPrice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+func _SlaveServerSideOp_GetShardSnapshot_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Request)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SlaveServerSideOpServer).GetShardSnapshot(m, &slaveServerSideOpGetShardSnapshotServer{stream})
+}
+
+type SlaveServerSideOp_GetShardSnapshotServer interface {
+	Send(*Response) error
+	grpc.ServerStream
+}
+
+type slaveServerSideOpGetShardSnapshotServer struct {
+	grpc.ServerStream
+}
+
+func (x *slaveServerSideOpGetShardSnapshotServer) Send(m *Response) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _SlaveServerSideOp_GetBalanceHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Request)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(SlaveServerSideOpServer).GasPrice(ctx, in)
+		return srv.(SlaveServerSideOpServer).GetBalanceHistory(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/rpc.SlaveServerSideOp/GasPrice",
+		FullMethod: "/rpc.SlaveServerSideOp/GetBalanceHistory",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(SlaveServerSideOpServer).GasPrice(ctx, req.(*Request))
+		return srv.(SlaveServerSideOpServer).GetBalanceHistory(ctx, req.(*Request))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _SlaveServerSideOp_GetWork_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+func _SlaveServerSideOp_GetXShardQueues_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Request)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(SlaveServerSideOpServer).GetWork(ctx, in)
+		return srv.(SlaveServerSideOpServer).GetXShardQueues(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/rpc.SlaveServerSideOp/GetWork",
+		FullMethod: "/rpc.SlaveServerSideOp/GetXShardQueues",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(SlaveServerSideOpServer).GetWork(ctx, req.(*Request))
+		return srv.(SlaveServerSideOpServer).GetXShardQueues(ctx, req.(*Request))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _SlaveServerSideOp_SubmitWork_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+func _SlaveServerSideOp_ShardMaintenance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Request)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(SlaveServerSideOpServer).SubmitWork(ctx, in)
+		return srv.(SlaveServerSideOpServer).ShardMaintenance(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/rpc.SlaveServerSideOp/SubmitWork",
+		FullMethod: "/rpc.SlaveServerSideOp/ShardMaintenance",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(SlaveServerSideOpServer).SubmitWork(ctx, req.(*Request))
+		return srv.(SlaveServerSideOpServer).ShardMaintenance(ctx, req.(*Request))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _SlaveServerSideOp_GetRootChainStakes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+func _SlaveServerSideOp_GetPendingAccountData_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Request)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(SlaveServerSideOpServer).GetRootChainStakes(ctx, in)
+		return srv.(SlaveServerSideOpServer).GetPendingAccountData(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/rpc.SlaveServerSideOp/GetRootChainStakes",
+		FullMethod: "/rpc.SlaveServerSideOp/GetPendingAccountData",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(SlaveServerSideOpServer).GetRootChainStakes(ctx, req.(*Request))
+		return srv.(SlaveServerSideOpServer).GetPendingAccountData(ctx, req.(*Request))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _SlaveServerSideOp_AddXshardTxList_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+func _SlaveServerSideOp_ExecutePendingTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Request)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(SlaveServerSideOpServer).AddXshardTxList(ctx, in)
+		return srv.(SlaveServerSideOpServer).ExecutePendingTransaction(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/rpc.SlaveServerSideOp/AddXshardTxList",
+		FullMethod: "/rpc.SlaveServerSideOp/ExecutePendingTransaction",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(SlaveServerSideOpServer).AddXshardTxList(ctx, req.(*Request))
+		return srv.(SlaveServerSideOpServer).ExecutePendingTransaction(ctx, req.(*Request))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _SlaveServerSideOp_BatchAddXshardTxList_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+func _SlaveServerSideOp_GetMinorBlockReceipts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Request)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(SlaveServerSideOpServer).BatchAddXshardTxList(ctx, in)
+		return srv.(SlaveServerSideOpServer).GetMinorBlockReceipts(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/rpc.SlaveServerSideOp/BatchAddXshardTxList",
+		FullMethod: "/rpc.SlaveServerSideOp/GetMinorBlockReceipts",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(SlaveServerSideOpServer).BatchAddXshardTxList(ctx, req.(*Request))
+		return srv.(SlaveServerSideOpServer).GetMinorBlockReceipts(ctx, req.(*Request))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _SlaveServerSideOp_AddMinorBlockListForSync_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+func _SlaveServerSideOp_TraceBlock_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Request)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SlaveServerSideOpServer).TraceBlock(m, &slaveServerSideOpTraceBlockServer{stream})
+}
+
+type SlaveServerSideOp_TraceBlockServer interface {
+	Send(*Response) error
+	grpc.ServerStream
+}
+
+type slaveServerSideOpTraceBlockServer struct {
+	grpc.ServerStream
+}
+
+func (x *slaveServerSideOpTraceBlockServer) Send(m *Response) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _SlaveServerSideOp_SimulateBundle_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Request)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(SlaveServerSideOpServer).AddMinorBlockListForSync(ctx, in)
+		return srv.(SlaveServerSideOpServer).SimulateBundle(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/rpc.SlaveServerSideOp/AddMinorBlockListForSync",
+		FullMethod: "/rpc.SlaveServerSideOp/SimulateBundle",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(SlaveServerSideOpServer).AddMinorBlockListForSync(ctx, req.(*Request))
+		return srv.(SlaveServerSideOpServer).SimulateBundle(ctx, req.(*Request))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _SlaveServerSideOp_SetMining_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+func _SlaveServerSideOp_GetStateAvailability_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Request)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(SlaveServerSideOpServer).SetMining(ctx, in)
+		return srv.(SlaveServerSideOpServer).GetStateAvailability(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/rpc.SlaveServerSideOp/SetMining",
+		FullMethod: "/rpc.SlaveServerSideOp/GetStateAvailability",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(SlaveServerSideOpServer).SetMining(ctx, req.(*Request))
+		return srv.(SlaveServerSideOpServer).GetStateAvailability(ctx, req.(*Request))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _SlaveServerSideOp_CheckMinorBlocksInRoot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+func _SlaveServerSideOp_RegenerateState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Request)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(SlaveServerSideOpServer).CheckMinorBlocksInRoot(ctx, in)
+		return srv.(SlaveServerSideOpServer).RegenerateState(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/rpc.SlaveServerSideOp/CheckMinorBlocksInRoot",
+		FullMethod: "/rpc.SlaveServerSideOp/RegenerateState",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(SlaveServerSideOpServer).CheckMinorBlocksInRoot(ctx, req.(*Request))
+		return srv.(SlaveServerSideOpServer).RegenerateState(ctx, req.(*Request))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
+func _SlaveServerSideOp_ExportBlocks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Request)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SlaveServerSideOpServer).ExportBlocks(m, &slaveServerSideOpExportBlocksServer{stream})
+}
+
+type SlaveServerSideOp_ExportBlocksServer interface {
+	Send(*Response) error
+	grpc.ServerStream
+}
+
+type slaveServerSideOpExportBlocksServer struct {
+	grpc.ServerStream
+}
+
+func (x *slaveServerSideOpExportBlocksServer) Send(m *Response) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 func _SlaveServerSideOp_GetMinorBlockList_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Request)
 	if err := dec(in); err != nil {
@@ -1711,6 +2710,94 @@ var _SlaveServerSideOp_serviceDesc = grpc.ServiceDesc{
 			MethodName: "CheckMinorBlocksInRoot",
 			Handler:    _SlaveServerSideOp_CheckMinorBlocksInRoot_Handler,
 		},
+		{
+			MethodName: "GetStaleBlocks",
+			Handler:    _SlaveServerSideOp_GetStaleBlocks_Handler,
+		},
+		{
+			MethodName: "GetUnreceivedXShardDeposits",
+			Handler:    _SlaveServerSideOp_GetUnreceivedXShardDeposits_Handler,
+		},
+		{
+			MethodName: "CreateAccessList",
+			Handler:    _SlaveServerSideOp_CreateAccessList_Handler,
+		},
+		{
+			MethodName: "ReloadConfig",
+			Handler:    _SlaveServerSideOp_ReloadConfig_Handler,
+		},
+		{
+			MethodName: "Profile",
+			Handler:    _SlaveServerSideOp_Profile_Handler,
+		},
+		{
+			MethodName: "SetLogLevel",
+			Handler:    _SlaveServerSideOp_SetLogLevel_Handler,
+		},
+		{
+			MethodName: "GetSlowOps",
+			Handler:    _SlaveServerSideOp_GetSlowOps_Handler,
+		},
+		{
+			MethodName: "GetBlockProfiles",
+			Handler:    _SlaveServerSideOp_GetBlockProfiles_Handler,
+		},
+		{
+			MethodName: "GetTxBenchmarkReports",
+			Handler:    _SlaveServerSideOp_GetTxBenchmarkReports_Handler,
+		},
+		{
+			MethodName: "ReplayBlocks",
+			Handler:    _SlaveServerSideOp_ReplayBlocks_Handler,
+		},
+		{
+			MethodName: "BackupShard",
+			Handler:    _SlaveServerSideOp_BackupShard_Handler,
+		},
+		{
+			MethodName: "GetShardRootTips",
+			Handler:    _SlaveServerSideOp_GetShardRootTips_Handler,
+		},
+		{
+			MethodName: "ResendXshardTxList",
+			Handler:    _SlaveServerSideOp_ResendXshardTxList_Handler,
+		},
+		{
+			MethodName: "GetBalanceHistory",
+			Handler:    _SlaveServerSideOp_GetBalanceHistory_Handler,
+		},
+		{
+			MethodName: "GetXShardQueues",
+			Handler:    _SlaveServerSideOp_GetXShardQueues_Handler,
+		},
+		{
+			MethodName: "ShardMaintenance",
+			Handler:    _SlaveServerSideOp_ShardMaintenance_Handler,
+		},
+		{
+			MethodName: "GetPendingAccountData",
+			Handler:    _SlaveServerSideOp_GetPendingAccountData_Handler,
+		},
+		{
+			MethodName: "ExecutePendingTransaction",
+			Handler:    _SlaveServerSideOp_ExecutePendingTransaction_Handler,
+		},
+		{
+			MethodName: "GetMinorBlockReceipts",
+			Handler:    _SlaveServerSideOp_GetMinorBlockReceipts_Handler,
+		},
+		{
+			MethodName: "SimulateBundle",
+			Handler:    _SlaveServerSideOp_SimulateBundle_Handler,
+		},
+		{
+			MethodName: "GetStateAvailability",
+			Handler:    _SlaveServerSideOp_GetStateAvailability_Handler,
+		},
+		{
+			MethodName: "RegenerateState",
+			Handler:    _SlaveServerSideOp_RegenerateState_Handler,
+		},
 		{
 			MethodName: "GetMinorBlockList",
 			Handler:    _SlaveServerSideOp_GetMinorBlockList_Handler,
@@ -1736,6 +2823,22 @@ var _SlaveServerSideOp_serviceDesc = grpc.ServiceDesc{
 			Handler:    _SlaveServerSideOp_HandleNewMinorBlock_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "GetShardSnapshot",
+			Handler:       _SlaveServerSideOp_GetShardSnapshot_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "TraceBlock",
+			Handler:       _SlaveServerSideOp_TraceBlock_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ExportBlocks",
+			Handler:       _SlaveServerSideOp_ExportBlocks_Handler,
+			ServerStreams: true,
+		},
+	},
 	Metadata: "rpc.proto",
 }
@@ -0,0 +1,227 @@
+// Package rpcproto publishes the protocol of the cluster ops between the master
+// and the slaves, for the tools introspecting and calling them, e.g. grpcurl
+// with -proto or a client generated from Source. The Data of the requests and
+// the responses of the ops is serialized by the serialize package.
+package rpcproto
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+
+	_ "github.com/QuarkChain/goquarkchain/cluster/rpc" // registers the file descriptor
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// FileName is the name the .proto file is registered with.
+const FileName = "rpc.proto"
+
+// FileDescriptor returns the descriptor of the .proto file, with the services
+// and the ops of the master and the slaves.
+func FileDescriptor() (*descriptor.FileDescriptorProto, error) {
+	gz := proto.FileDescriptor(FileName)
+	if gz == nil {
+		return nil, errors.New("rpc.proto is not registered")
+	}
+	r, err := gzip.NewReader(bytes.NewReader(gz))
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	fd := new(descriptor.FileDescriptorProto)
+	if err := proto.Unmarshal(b, fd); err != nil {
+		return nil, err
+	}
+	return fd, nil
+}
+
+// Methods returns the full gRPC method names of the ops of the services, e.g.
+// /rpc.SlaveServerSideOp/Ping, by service name.
+func Methods() (map[string][]string, error) {
+	fd, err := FileDescriptor()
+	if err != nil {
+		return nil, err
+	}
+	methods := make(map[string][]string, len(fd.GetService()))
+	for _, svc := range fd.GetService() {
+		name := fd.GetPackage() + "." + svc.GetName()
+		for _, m := range svc.GetMethod() {
+			methods[name] = append(methods[name], "/"+name+"/"+m.GetName())
+		}
+	}
+	return methods, nil
+}
+
+// Source is the .proto file of the cluster ops, kept the same as
+// cluster/rpc/rpc.proto by the tests.
+const Source = `syntax = "proto3";
+
+package rpc;
+
+// master operation
+service MasterServerSideOp {
+    rpc AddMinorBlockHeader (Request) returns (Response) {
+    }
+    rpc AddMinorBlockHeaderList (Request) returns (Response) {
+    }
+    // p2p apis
+    rpc BroadcastNewTip (Request) returns (Response) {
+    }
+    rpc BroadcastTransactions (Request) returns (Response) {
+    }
+    rpc BroadcastNewMinorBlock (Request) returns (Response) {
+    }
+    rpc GetMinorBlockList (Request) returns (Response) {
+    }
+    rpc GetMinorBlockHeaderList (Request) returns (Response) {
+    }
+    rpc GetMinorBlockHeaderListWithSkip (Request) returns (Response) {
+    }
+    // snapshots of the shards bootstrapped by the new slaves
+    rpc BootstrapShard (Request) returns (stream Response) {
+    }
+    rpc ShardBootstrapped (Request) returns (Response) {
+    }
+}
+
+// slave operation
+service SlaveServerSideOp {
+    rpc heartBeat (Request) returns (Response) {
+    }
+    rpc MasterInfo (Request) returns (Response) {
+    }
+    // APIs for master
+    rpc Ping (Request) returns (Response) {
+    }
+    rpc GenTx (Request) returns (Response) {
+    }
+    rpc AddRootBlock (Request) returns (Response) {
+    }
+    rpc GetUnconfirmedHeaderList (Request) returns (Response) {
+    }
+    rpc GetAccountData (Request) returns (Response) {
+    }
+    rpc AddTransaction (Request) returns (Response) {
+    }
+    rpc GetMinorBlock (Request) returns (Response) {
+    }
+    rpc GetTransaction (Request) returns (Response) {
+    }
+    rpc ExecuteTransaction (Request) returns (Response) {
+    }
+    rpc GetTransactionReceipt (Request) returns (Response) {
+    }
+    rpc GetTransactionListByAddress (Request) returns (Response) {
+    }
+    rpc GetAllTx (Request) returns (Response) {
+    }
+    rpc GetLogs (Request) returns (Response) {
+    }
+    rpc EstimateGas (Request) returns (Response) {
+    }
+    rpc GetStorageAt (Request) returns (Response) {
+    }
+    rpc GetCode (Request) returns (Response) {
+    }
+    rpc GasPrice (Request) returns (Response) {
+    }
+    rpc GetWork (Request) returns (Response) {
+    }
+    rpc SubmitWork (Request) returns (Response) {
+    }
+    rpc GetRootChainStakes (Request) returns (Response) {
+    }
+    // APIs for neighbor slaves
+    rpc AddXshardTxList (Request) returns (Response) {
+    }
+    rpc BatchAddXshardTxList (Request) returns (Response) {
+    }
+    rpc AddMinorBlockListForSync (Request) returns (Response) {
+    }
+    rpc SetMining (Request) returns (Response) {
+    }
+    rpc CheckMinorBlocksInRoot (Request) returns (Response) {
+    }
+    rpc GetStaleBlocks (Request) returns (Response) {
+    }
+    rpc GetUnreceivedXShardDeposits (Request) returns (Response) {
+    }
+    rpc CreateAccessList (Request) returns (Response) {
+    }
+    rpc ReloadConfig (Request) returns (Response) {
+    }
+    rpc Profile (Request) returns (Response) {
+    }
+    rpc SetLogLevel (Request) returns (Response) {
+    }
+    rpc GetSlowOps (Request) returns (Response) {
+    }
+    rpc GetBlockProfiles (Request) returns (Response) {
+    }
+    rpc GetTxBenchmarkReports (Request) returns (Response) {
+    }
+    rpc ReplayBlocks (Request) returns (Response) {
+    }
+    rpc BackupShard (Request) returns (Response) {
+    }
+    rpc GetShardRootTips (Request) returns (Response) {
+    }
+    rpc ResendXshardTxList (Request) returns (Response) {
+    }
+    rpc GetShardSnapshot (Request) returns (stream Response) {
+    }
+    rpc GetBalanceHistory (Request) returns (Response) {
+    }
+    rpc GetXShardQueues (Request) returns (Response) {
+    }
+    rpc ShardMaintenance (Request) returns (Response) {
+    }
+    rpc GetPendingAccountData (Request) returns (Response) {
+    }
+    rpc ExecutePendingTransaction (Request) returns (Response) {
+    }
+    rpc GetMinorBlockReceipts (Request) returns (Response) {
+    }
+    rpc TraceBlock (Request) returns (stream Response) {
+    }
+    rpc SimulateBundle (Request) returns (Response) {
+    }
+    rpc GetStateAvailability (Request) returns (Response) {
+    }
+    rpc RegenerateState (Request) returns (Response) {
+    }
+    rpc ExportBlocks (Request) returns (stream Response) {
+    }
+    // p2p apis
+    rpc GetMinorBlockList (Request) returns (Response) {
+    }
+    rpc GetMinorBlockHeaderList (Request) returns (Response) {
+    }
+    rpc GetMinorBlockHeaderListWithSkip (Request) returns (Response) {
+    }
+    rpc HandleNewTip (Request) returns (Response) {
+    }
+    rpc AddTransactions (Request) returns (Response) {
+    }
+    rpc HandleNewMinorBlock (Request) returns (Response) {
+    }
+}
+
+// request data
+message Request {
+    uint32 op = 1;
+    int64 rpc_id = 2;
+    bytes data = 5;
+}
+
+// response data
+message Response {
+    bytes data = 1;
+    int64 rpc_id = 2;
+}
+`
@@ -0,0 +1,28 @@
+package rpcproto
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSource(t *testing.T) {
+	src, err := ioutil.ReadFile("../rpc.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// rpc.proto has CRLF line endings, which a raw string literal drops
+	assert.Equal(t, strings.Replace(string(src), "\r\n", "\n", -1), Source, "Source is out of date with rpc.proto")
+}
+
+func TestMethods(t *testing.T) {
+	methods, err := Methods()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, methods["rpc.MasterServerSideOp"], "/rpc.MasterServerSideOp/AddMinorBlockHeader")
+	assert.Contains(t, methods["rpc.SlaveServerSideOp"], "/rpc.SlaveServerSideOp/Ping")
+	assert.Contains(t, methods["rpc.SlaveServerSideOp"], "/rpc.SlaveServerSideOp/SimulateBundle")
+}
@@ -0,0 +1,140 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/cluster/config"
+	"github.com/QuarkChain/goquarkchain/rpc"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// slowOpEntries is the number of the last slow ops kept in memory.
+const slowOpEntries = 512
+
+// SlowOp is a cluster RPC op which took at least the SLOW_RPC_THRESHOLD.
+type SlowOp struct {
+	Time         uint64 `json:"time" gencodec:"required"`   // unix time in milliseconds the op was called at
+	Caller       string `json:"caller" gencodec:"required"` // master or the ID of the slave calling
+	Op           string `json:"op" gencodec:"required"`
+	Peer         string `json:"peer" gencodec:"required"` // master or the ID of the slave called, or its endpoint
+	RequestSize  uint32 `json:"requestSize" gencodec:"required"`
+	ResponseSize uint32 `json:"responseSize" gencodec:"required"`
+	Latency      uint64 `json:"latencyMs" gencodec:"required"`
+	Error        string `json:"error,omitempty" gencodec:"required"`
+}
+
+// SlowOpLog keeps the last slow ops of a master or a slave in a ring, each
+// of the in-process ones having its own in dev mode.
+type SlowOpLog struct {
+	threshold int64 // atomic, in nanoseconds, 0 if the ops are not timed
+
+	mu     sync.Mutex
+	caller string
+	peers  map[string]string // slave IDs by endpoint
+	ops    []*SlowOp
+	next   int // index of the oldest op once the ring is full
+}
+
+// NewSlowOpLog returns a log of the slow ops of the caller, master or the
+// slave ID, not timing the ops until SetThreshold is called.
+func NewSlowOpLog(caller string) *SlowOpLog {
+	return &SlowOpLog{caller: caller, peers: make(map[string]string)}
+}
+
+// SetThreshold logs the cluster RPC ops taking at least threshold
+// milliseconds, 0 disables it.
+func (l *SlowOpLog) SetThreshold(threshold uint32) {
+	atomic.StoreInt64(&l.threshold, int64(time.Duration(threshold)*time.Millisecond))
+}
+
+// SetPeers names the slaves of the cluster called in the slow ops.
+func (l *SlowOpLog) SetPeers(slaves []*config.SlaveConfig) {
+	peers := make(map[string]string, len(slaves))
+	for _, slave := range slaves {
+		peers[fmt.Sprintf("%s:%d", slave.IP, slave.Port)] = slave.ID
+	}
+	l.mu.Lock()
+	l.peers = peers
+	l.mu.Unlock()
+}
+
+// Ops returns the last count slow ops, the oldest first.
+func (l *SlowOpLog) Ops(count int) []*SlowOp {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if count > len(l.ops) {
+		count = len(l.ops)
+	}
+	ops := make([]*SlowOp, 0, count)
+	for i := len(l.ops) - count; i < len(l.ops); i++ {
+		ops = append(ops, l.ops[(l.next+i)%len(l.ops)])
+	}
+	return ops
+}
+
+// peerName returns master or the ID of the slave at hostport if known, or
+// hostport, with the lock held unless the log is nil.
+func (l *SlowOpLog) peerName(tp serverType, hostport string) string {
+	if tp == MasterServer {
+		return "master"
+	}
+	if l == nil {
+		return hostport
+	}
+	if id, ok := l.peers[hostport]; ok {
+		return id
+	}
+	return hostport
+}
+
+// peer returns the name of the server at hostport in the slow ops.
+func (l *SlowOpLog) peer(tp serverType, hostport string) string {
+	if l != nil {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+	}
+	return l.peerName(tp, hostport)
+}
+
+// observe logs the op called on the server at hostport if it took at least
+// the threshold, a nil log doesn't.
+func (l *SlowOpLog) observe(ctx context.Context, tp serverType, hostport, op string, start time.Time, req *Request, res *Response, err error) {
+	if l == nil {
+		return
+	}
+	threshold := atomic.LoadInt64(&l.threshold)
+	latency := time.Since(start)
+	if threshold == 0 || int64(latency) < threshold {
+		return
+	}
+	slow := &SlowOp{
+		Time:        uint64(start.UnixNano() / int64(time.Millisecond)),
+		Op:          op,
+		RequestSize: uint32(len(req.Data)),
+		Latency:     uint64(latency / time.Millisecond),
+	}
+	if res != nil {
+		slow.ResponseSize = uint32(len(res.Data))
+	}
+	if err != nil {
+		slow.Error = err.Error()
+	}
+
+	l.mu.Lock()
+	slow.Caller = l.caller
+	slow.Peer = l.peerName(tp, hostport)
+	if len(l.ops) < slowOpEntries {
+		l.ops = append(l.ops, slow)
+	} else {
+		l.ops[l.next] = slow
+		l.next = (l.next + 1) % slowOpEntries
+	}
+	l.mu.Unlock()
+
+	rpc.LoggerFromContext(ctx).Warn("Slow cluster RPC", "op", op, "peer", slow.Peer, "request", slow.RequestSize,
+		"response", slow.ResponseSize, "latency", common.PrettyDuration(latency), "err", err)
+}
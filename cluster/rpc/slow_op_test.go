@@ -0,0 +1,69 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/cluster/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlowOps(t *testing.T) {
+	slowOps := NewSlowOpLog("S0")
+	slowOps.SetPeers([]*config.SlaveConfig{testSlaveConfig(0), testSlaveConfig(1)})
+	req := &Request{Op: OpGetMinorBlock, Data: make([]byte, 10)}
+	res := &Response{Data: make([]byte, 100)}
+	slow := time.Now().Add(-time.Second)
+
+	// not timed
+	slowOps.observe(context.Background(), SlaveServer, "127.0.0.1:38001", "GetMinorBlock", slow, req, res, nil)
+	assert.Empty(t, slowOps.Ops(10))
+
+	slowOps.SetThreshold(500)
+	slowOps.observe(context.Background(), SlaveServer, "127.0.0.1:38001", "GetMinorBlock", time.Now(), req, res, nil)
+	assert.Empty(t, slowOps.Ops(10))
+	slowOps.observe(context.Background(), SlaveServer, "127.0.0.1:38001", "GetMinorBlock", slow, req, res, nil)
+	slowOps.observe(context.Background(), MasterServer, "127.0.0.1:38391", "AddMinorBlockHeader", slow, req, nil, errors.New("timeout"))
+	slowOps.observe(context.Background(), SlaveServer, "10.0.0.1:38000", "HandleNewTip", slow, req, res, nil)
+
+	ops := slowOps.Ops(10)
+	assert.Len(t, ops, 3)
+	assert.Equal(t, SlowOp{Time: uint64(slow.UnixNano() / int64(time.Millisecond)), Caller: "S0", Op: "GetMinorBlock",
+		Peer: "S1", RequestSize: 10, ResponseSize: 100, Latency: ops[0].Latency}, *ops[0])
+	assert.True(t, ops[0].Latency >= 1000)
+	assert.Equal(t, "master", ops[1].Peer)
+	assert.Equal(t, uint32(0), ops[1].ResponseSize)
+	assert.Equal(t, "timeout", ops[1].Error)
+	assert.Equal(t, "10.0.0.1:38000", ops[2].Peer)
+
+	for i := 0; i < slowOpEntries; i++ {
+		slowOps.observe(context.Background(), SlaveServer, "127.0.0.1:38001", fmt.Sprintf("Op%d", i), slow, req, res, nil)
+	}
+	ops = slowOps.Ops(2 * slowOpEntries)
+	assert.Len(t, ops, slowOpEntries)
+	assert.Equal(t, "Op0", ops[0].Op)
+	ops = slowOps.Ops(2)
+	assert.Equal(t, fmt.Sprintf("Op%d", slowOpEntries-2), ops[0].Op)
+	assert.Equal(t, fmt.Sprintf("Op%d", slowOpEntries-1), ops[1].Op)
+}
+
+func TestSlowOpLogs(t *testing.T) {
+	req := &Request{Op: OpGetMinorBlock}
+	slow := time.Now().Add(-time.Second)
+	master, slave := NewSlowOpLog("master"), NewSlowOpLog("S0")
+	master.SetThreshold(500)
+	slave.SetThreshold(500)
+
+	master.observe(context.Background(), SlaveServer, "127.0.0.1:38000", "GetMinorBlock", slow, req, nil, nil)
+	assert.Len(t, master.Ops(10), 1)
+	assert.Empty(t, slave.Ops(10))
+
+	// the clients without a log don't time their ops
+	var none *SlowOpLog
+	none.observe(context.Background(), SlaveServer, "127.0.0.1:38000", "GetMinorBlock", slow, req, nil, nil)
+	assert.Equal(t, "127.0.0.1:38000", none.peer(SlaveServer, "127.0.0.1:38000"))
+	assert.Equal(t, "master", none.peer(MasterServer, "127.0.0.1:38391"))
+}
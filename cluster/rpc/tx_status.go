@@ -0,0 +1,32 @@
+package rpc
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// The statuses of the txs submitted through the master, in the events of the
+// tx watcher.
+const (
+	// TxStatusPending is the status of a tx back in the pool after the block
+	// including it was reorganized out.
+	TxStatusPending   = "pending"
+	TxStatusIncluded  = "included"
+	TxStatusConfirmed = "confirmed"
+	TxStatusDropped   = "dropped"
+)
+
+// TxStatusEvent is a change of the status of a tx submitted through the
+// master: included in a minor block of its shard, confirmed by a root block,
+// or dropped from the pool without being included.
+type TxStatusEvent struct {
+	TxHash       common.Hash
+	FullShardKey uint32 // of the sender
+	FullShardID  uint32 // of the shard of the sender
+	Status       string
+	// the block including the tx, if included or confirmed
+	BlockHash   common.Hash
+	BlockHeight uint64
+	// the root block confirming the block, if confirmed
+	RootBlockHash   common.Hash
+	RootBlockHeight uint64
+}
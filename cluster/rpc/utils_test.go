@@ -1,11 +1,17 @@
 package rpc
 
 import (
+	"errors"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 import (
 	"context"
+
+	"github.com/QuarkChain/goquarkchain/rpc"
 )
 
 // MasterServerSideOp juest for test
@@ -65,8 +71,93 @@ func (m *MasterServerSideOp) GetMinorBlockHeaderList(ctx context.Context, req *R
 	}, nil
 }
 
+func (m *MasterServerSideOp) BootstrapShard(req *Request, stream MasterServerSideOp_BootstrapShardServer) error {
+	return nil
+}
+func (m *MasterServerSideOp) ShardBootstrapped(ctx context.Context, req *Request) (*Response, error) {
+	return &Response{
+		RpcId: req.RpcId,
+	}, nil
+}
+
 func (m *MasterServerSideOp) MinorHead(ctx context.Context, req *Request) (*Response, error) {
 	return &Response{
 		RpcId: req.RpcId,
 	}, nil
 }
+
+// SlaveServerSideOp just for test, it streams data of the request size,
+// reports the cancellation of the calls of GetLogs, counts the calls of
+// GasPrice, which wait to be released, returns minor block lists of the
+// size requested, returns the request ID of the calls of AddTransaction, and
+// counts the calls of AddRootBlock, which fail for the data "fail"
+type SlaveServerSideOp struct {
+	UnimplementedSlaveServerSideOpServer
+	canceled  chan error
+	gasPrice  int32 // atomic
+	rootBlock int32 // atomic
+	release   chan struct{}
+}
+
+// GetMinorBlockList returns the bytes of the data repeated the number of
+// times in the request.
+func (s *SlaveServerSideOp) GetMinorBlockList(ctx context.Context, req *Request) (*Response, error) {
+	n, err := strconv.Atoi(string(req.Data))
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	return &Response{RpcId: req.RpcId, Data: data}, nil
+}
+
+func (s *SlaveServerSideOp) AddTransaction(ctx context.Context, req *Request) (*Response, error) {
+	return &Response{RpcId: req.RpcId, Data: []byte(rpc.RequestIDFromContext(ctx))}, nil
+}
+
+func (s *SlaveServerSideOp) AddRootBlock(ctx context.Context, req *Request) (*Response, error) {
+	n := atomic.AddInt32(&s.rootBlock, 1)
+	time.Sleep(20 * time.Millisecond)
+	if string(req.Data) == "fail" {
+		return nil, errors.New("failed to add root block")
+	}
+	return &Response{RpcId: req.RpcId, Data: []byte(strconv.Itoa(int(n)))}, nil
+}
+
+func (s *SlaveServerSideOp) GasPrice(ctx context.Context, req *Request) (*Response, error) {
+	atomic.AddInt32(&s.gasPrice, 1)
+	<-s.release
+	return &Response{RpcId: req.RpcId, Data: req.Data}, nil
+}
+
+func (s *SlaveServerSideOp) GetLogs(ctx context.Context, req *Request) (*Response, error) {
+	select {
+	case <-ctx.Done():
+		s.canceled <- ctx.Err()
+		return nil, ctx.Err()
+	case <-time.After(10 * time.Second):
+		return &Response{RpcId: req.RpcId}, nil
+	}
+}
+
+func (s *SlaveServerSideOp) GetShardSnapshot(req *Request, stream SlaveServerSideOp_GetShardSnapshotServer) error {
+	w := NewStreamWriter(stream.Send, req.RpcId)
+	if len(req.Data) == 0 {
+		// streams the request ID it got
+		if _, err := w.Write([]byte(rpc.RequestIDFromContext(stream.Context()))); err != nil {
+			return err
+		}
+		return w.Flush()
+	}
+	for i := 0; i < len(req.Data); i++ {
+		if _, err := w.Write(req.Data[i : i+1]); err != nil {
+			return err
+		}
+	}
+	if _, err := w.Write(req.Data); err != nil {
+		return err
+	}
+	return w.Flush()
+}
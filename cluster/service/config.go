@@ -23,6 +23,7 @@ const (
 	datadirStaticNodes  = "static-nodes.json"  // Path within the datadir to the static node list
 	datadirTrustedNodes = "trusted-nodes.json" // Path within the datadir to the trusted node list
 	datadirNodeDatabase = "nodes"              // Path within the datadir to store the node infos
+	datadirPeerDatabase = "peers"              // Path within the datadir to store the peer reputations
 )
 
 // Config represents a small collection of configuration values to fine tune the
@@ -45,6 +46,9 @@ type Config struct {
 	// in memory.
 	DataDir string
 
+	// DBBackend is the storage backend of the databases in the data directory.
+	DBBackend string
+
 	// Configuration of peer-to-peer networking.
 	P2P p2p.Config
 
@@ -61,6 +65,10 @@ type Config struct {
 	// exposed.
 	HTTPModules []string `toml:",omitempty"`
 
+	// HealthEndpoint is the address of the HTTP server answering the health
+	// and readiness checks of the services. An empty endpoint disables it.
+	HealthEndpoint string `toml:",omitempty"`
+
 	HTTPPrivModules []string `toml:",omitempty"`
 	HTTPPrivEndpoint string `toml:",omitempty"`
 
@@ -80,12 +88,22 @@ type Config struct {
 
 	WSEndpoint string
 
+	// RPCAdmission bounds the calls of the public HTTP and websocket RPC, nil
+	// for no bound.
+	RPCAdmission *rpc.AdmissionConfig `toml:",omitempty"`
+
+	// RPCTimeouts bounds the time the calls of the public HTTP and websocket
+	// RPC run, nil for no bound.
+	RPCTimeouts *rpc.TimeoutConfig `toml:",omitempty"`
+
 	// Logger is a custom logger to use with the p2p.Server.
 	Logger log.Logger `toml:",omitempty"`
 
 	GRPCModules []string `toml:",omitempty"`
 	// grpc service endpoint
 	GRPCEndpoint string
+	// GRPCReflection serves the gRPC reflection service on the grpc endpoint.
+	GRPCReflection bool
 
 	staticNodesWarning     bool
 	trustedNodesWarning    bool
@@ -125,6 +143,14 @@ func (c *Config) NodeDB() string {
 	return c.ResolvePath(datadirNodeDatabase)
 }
 
+// PeerDB returns the path to the peer reputation database.
+func (c *Config) PeerDB() string {
+	if c.DataDir == "" {
+		return "" // ephemeral
+	}
+	return c.ResolvePath(datadirPeerDatabase)
+}
+
 // NodeName returns the devp2p node identifier.
 func (c *Config) NodeName() string {
 	name := c.name()
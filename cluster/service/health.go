@@ -0,0 +1,137 @@
+package service
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// healthKey is the key read from the databases to check they are available.
+var healthKey = []byte("health")
+
+// HealthCheck is the result of a check of the health of a service.
+type HealthCheck struct {
+	Name   string      `json:"name"`
+	OK     bool        `json:"ok"`
+	Detail interface{} `json:"detail,omitempty"`
+	// Liveness marks the checks failing /health, the other checks fail
+	// /ready only.
+	Liveness bool `json:"-"`
+}
+
+// HealthReporter is implemented by the services checking their health for the
+// health endpoint of the node.
+type HealthReporter interface {
+	HealthChecks() []HealthCheck
+}
+
+// CheckDatabase checks that the database can be read.
+func CheckDatabase(name string, db ethdb.Database) HealthCheck {
+	check := HealthCheck{Name: name, OK: true, Liveness: true}
+	if _, err := db.Has(healthKey); err != nil {
+		check.OK, check.Detail = false, err.Error()
+	}
+	return check
+}
+
+// healthStatus is the body of the responses of the health endpoint.
+type healthStatus struct {
+	Status string        `json:"status"` // "ok" or "unavailable"
+	Checks []HealthCheck `json:"checks"`
+}
+
+// healthHandler serves /health, which fails if a liveness check of the
+// services fails, and /ready, which fails if any check fails or the services
+// are not started yet. The checks are answered as JSON with the status code
+// 200 OK, or 503 Service Unavailable on failure.
+type healthHandler struct {
+	reporters []HealthReporter
+	started   int32 // atomic, set once the services are started
+}
+
+func (h *healthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var liveness bool
+	switch r.URL.Path {
+	case "/health":
+		liveness = true
+	case "/ready":
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	status := healthStatus{Status: "ok", Checks: []HealthCheck{}}
+	if atomic.LoadInt32(&h.started) == 0 {
+		if !liveness {
+			status.Status = "unavailable"
+		}
+		status.Checks = append(status.Checks, HealthCheck{Name: "started", OK: false})
+	} else {
+		for _, reporter := range h.reporters {
+			for _, check := range reporter.HealthChecks() {
+				if liveness && !check.Liveness {
+					continue
+				}
+				if !check.OK {
+					status.Status = "unavailable"
+				}
+				status.Checks = append(status.Checks, check)
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if status.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(status)
+}
+
+// startHealth starts the health endpoint of the services, which is ready once
+// they are started.
+func (n *Node) startHealth(services map[reflect.Type]Service) error {
+	if n.config.HealthEndpoint == "" {
+		return nil
+	}
+	handler := new(healthHandler)
+	for _, service := range services {
+		if reporter, ok := service.(HealthReporter); ok {
+			handler.reporters = append(handler.reporters, reporter)
+		}
+	}
+	listener, err := net.Listen("tcp", n.config.HealthEndpoint)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{
+		Handler:      handler,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 5 * time.Second,
+	}
+	go srv.Serve(listener)
+	n.healthListener = listener
+	n.healthHandler = handler
+	n.log.Info("Health endpoint opened", "url", "http://"+listener.Addr().String())
+	return nil
+}
+
+// setHealthStarted makes the health endpoint run the checks of the services.
+func (n *Node) setHealthStarted() {
+	if n.healthHandler != nil {
+		atomic.StoreInt32(&n.healthHandler.started, 1)
+	}
+}
+
+// stopHealth closes the health endpoint.
+func (n *Node) stopHealth() {
+	if n.healthListener != nil {
+		n.healthListener.Close()
+		n.healthListener = nil
+		n.healthHandler = nil
+		n.log.Info("Health endpoint closed", "url", "http://"+n.config.HealthEndpoint)
+	}
+}
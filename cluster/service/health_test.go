@@ -0,0 +1,115 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// healthService is a service reporting the checks it is given.
+type healthService struct {
+	NoopService
+	checks []HealthCheck
+}
+
+func (s *healthService) HealthChecks() []HealthCheck { return s.checks }
+
+// closedDB is a database failing all the reads.
+type closedDB struct{ ethdb.Database }
+
+func (closedDB) Has(key []byte) (bool, error) { return false, errors.New("closed") }
+
+func TestHealthHandler(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	service := &healthService{checks: []HealthCheck{CheckDatabase("db", db), {Name: "sync", OK: true}}}
+	handler := &healthHandler{reporters: []HealthReporter{service}}
+
+	get := func(path string) (int, healthStatus) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		var status healthStatus
+		if w.Code != http.StatusNotFound {
+			if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+				t.Fatalf("%s: invalid body %q: %v", path, w.Body.String(), err)
+			}
+		}
+		return w.Code, status
+	}
+	tests := []struct {
+		started    bool
+		checks     []HealthCheck
+		health     int
+		ready      int
+		readyCount int
+	}{
+		// the services are starting
+		{false, service.checks, http.StatusOK, http.StatusServiceUnavailable, 1},
+		{true, service.checks, http.StatusOK, http.StatusOK, 2},
+		// the readiness checks fail /ready only
+		{true, []HealthCheck{CheckDatabase("db", db), {Name: "sync"}}, http.StatusOK, http.StatusServiceUnavailable, 2},
+		{true, []HealthCheck{CheckDatabase("db", closedDB{db}), {Name: "sync", OK: true}}, http.StatusServiceUnavailable, http.StatusServiceUnavailable, 2},
+	}
+	for i, test := range tests {
+		handler.started = 0
+		if test.started {
+			handler.started = 1
+		}
+		service.checks = test.checks
+		code, status := get("/health")
+		if code != test.health || (code == http.StatusOK) != (status.Status == "ok") {
+			t.Errorf("test %d: /health answered %d %+v, want %d", i, code, status, test.health)
+		}
+		if test.started && len(status.Checks) != 1 {
+			t.Errorf("test %d: /health ran %d checks, want the liveness check", i, len(status.Checks))
+		}
+		code, status = get("/ready")
+		if code != test.ready || (code == http.StatusOK) != (status.Status == "ok") {
+			t.Errorf("test %d: /ready answered %d %+v, want %d", i, code, status, test.ready)
+		}
+		if len(status.Checks) != test.readyCount {
+			t.Errorf("test %d: /ready ran %d checks, want %d", i, len(status.Checks), test.readyCount)
+		}
+	}
+	if code, _ := get("/metrics"); code != http.StatusNotFound {
+		t.Errorf("unknown path answered %d", code)
+	}
+}
+
+func TestNodeHealthEndpoint(t *testing.T) {
+	cfg := testNodeConfig()
+	cfg.HealthEndpoint = "127.0.0.1:0"
+	stack, err := New(cfg)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	service := &healthService{checks: []HealthCheck{{Name: "sync"}}}
+	if err := stack.Register(func(*ServiceContext) (Service, error) { return service, nil }); err != nil {
+		t.Fatalf("failed to register service: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start node: %v", err)
+	}
+	url := "http://" + stack.healthListener.Addr().String()
+	defer stack.Stop()
+
+	resp, err := http.Get(url + "/ready")
+	if err != nil {
+		t.Fatalf("failed to get readiness: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("/ready answered %d, want %d", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+	resp, err = http.Get(url + "/health")
+	if err != nil {
+		t.Fatalf("failed to get health: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("/health answered %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/QuarkChain/goquarkchain/rpc"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/prometheus/prometheus/util/flock"
 	"google.golang.org/grpc"
 	"net"
@@ -51,6 +52,9 @@ type Node struct {
 	wsListener net.Listener // Websocket RPC listener socket to server API requests
 	wsHandler  *rpc.Server  // Websocket RPC request handler to process the API requests
 
+	healthListener net.Listener   // health listener socket to serve the health checks
+	healthHandler  *healthHandler // health request handler running the checks of the services
+
 	stop chan struct{} // Channel to wait for termination notifications
 	lock sync.RWMutex
 
@@ -99,6 +103,24 @@ func (n *Node) IsMaster() bool {
 	return n.isMaster
 }
 
+// DisableP2P keeps the node out of the p2p network, even if StartP2P is called:
+// the p2p server has no listener, no discovery and dials no peer.
+func (n *Node) DisableP2P() error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.server != nil {
+		return ErrNodeRunning
+	}
+	n.config.P2P.ListenAddr = ""
+	n.config.P2P.NAT = nil
+	n.config.P2P.NoDiscovery = true
+	n.config.P2P.DiscoveryV5 = false
+	n.config.P2P.NoDial = true
+	n.config.P2P.MaxPeers = 0
+	return nil
+}
+
 // Register injects a new service into the node's stack. The service created by
 // the passed constructor must be unique in its type with regard to sibling ones.
 func (n *Node) Register(constructor ServiceConstructor) error {
@@ -131,15 +153,15 @@ func (n *Node) Start() error {
 	n.serverConfig.PrivateKey = n.config.NodeKey()
 	n.serverConfig.Name = n.config.NodeName()
 	n.serverConfig.Logger = n.log
-	if n.serverConfig.StaticNodes == nil {
-		n.serverConfig.StaticNodes = n.config.StaticNodes()
-	}
-	if n.serverConfig.TrustedNodes == nil {
-		n.serverConfig.TrustedNodes = n.config.TrustedNodes()
-	}
+	// The nodes of the data directory add to the configured ones.
+	n.serverConfig.StaticNodes = append(append([]*enode.Node{}, n.config.P2P.StaticNodes...), n.config.StaticNodes()...)
+	n.serverConfig.TrustedNodes = append(append([]*enode.Node{}, n.config.P2P.TrustedNodes...), n.config.TrustedNodes()...)
 	if n.serverConfig.NodeDatabase == "" {
 		n.serverConfig.NodeDatabase = n.config.NodeDB()
 	}
+	if n.serverConfig.PeerDatabase == "" {
+		n.serverConfig.PeerDatabase = n.config.PeerDB()
+	}
 	running := &p2p.Server{Config: n.serverConfig}
 
 	// Otherwise copy and specialize the P2P configuration
@@ -175,6 +197,11 @@ func (n *Node) Start() error {
 	if err := n.startRPC(services); err != nil {
 		return err
 	}
+	// The health endpoint answers while the services start
+	if err := n.startHealth(services); err != nil {
+		n.stopRPC()
+		return err
+	}
 	// Start each of the services
 	var started []reflect.Type
 	for kind, service := range services {
@@ -183,12 +210,14 @@ func (n *Node) Start() error {
 			for _, kind := range started {
 				services[kind].Stop()
 			}
+			n.stopHealth()
 			n.stopRPC()
 			return err
 		}
 		// Mark the service started for potential cleanup
 		started = append(started, kind)
 	}
+	n.setHealthStarted()
 	// Finish initializing the startup
 	n.services = services
 	n.server = running
@@ -242,12 +271,11 @@ func (n *Node) startRPC(services map[reflect.Type]Service) error {
 			n.stopRPC()
 			return err
 		}
-	} else {
-		// start ws service
-		if err := n.startWS(apis, n.config.WSModules, n.config.WSOrigins); err != nil {
-			n.stopWS()
-			return err
-		}
+	}
+	// start ws service
+	if err := n.startWS(apis, n.config.WSModules, n.config.WSOrigins); err != nil {
+		n.stopRPC()
+		return err
 	}
 	// All API endpoints started successfully
 	n.rpcAPIs = apis
@@ -311,7 +339,7 @@ func (n *Node) startGRPC(apis []rpc.API, modules []string) error {
 	}
 
 	apis = n.apiFilter(apis, false, modules)
-	listener, handler, err := qkcrpc.StartGRPCServer(n.config.GRPCEndpoint, apis)
+	listener, handler, err := qkcrpc.StartGRPCServer(n.config.GRPCEndpoint, apis, n.config.GRPCReflection)
 	if err != nil {
 		return err
 	}
@@ -345,6 +373,8 @@ func (n *Node) startWS(apis []rpc.API, modules []string, wsOrigins []string) err
 	if err != nil {
 		return err
 	}
+	handler.SetAdmission(n.config.RPCAdmission)
+	handler.SetTimeouts(n.config.RPCTimeouts)
 	n.log.Info("WebSocket endpoint opened", "url", fmt.Sprintf("ws://%s", listener.Addr()))
 	// All listeners booted successfully
 	n.wsListener = listener
@@ -381,6 +411,8 @@ func (n *Node) startHTTP(apis []rpc.API, modules []string, timeouts rpc.HTTPTime
 	if err != nil {
 		return err
 	}
+	handler.SetAdmission(n.config.RPCAdmission)
+	handler.SetTimeouts(n.config.RPCTimeouts)
 	n.log.Info("public HTTP endpoint opened", "url", fmt.Sprintf("http://%s", n.config.HTTPEndpoint))
 	// All listeners booted successfully
 	n.httpListener = listener
@@ -453,6 +485,7 @@ func (n *Node) Stop() error {
 	}
 
 	// Terminate the API, services and the p2p server.
+	n.stopHealth()
 	n.stopRPC()
 	n.rpcAPIs = nil
 	failure := &StopError{
@@ -68,6 +68,35 @@ func TestNodeLifeCycle(t *testing.T) {
 	}
 }
 
+// Tests that a node with p2p disabled starts a p2p server out of the network.
+func TestNodeDisableP2P(t *testing.T) {
+	conf := testNodeConfig()
+	conf.P2P.ListenAddr = ":0"
+	conf.P2P.MaxPeers = 10
+	stack, err := New(conf)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	if err := stack.DisableP2P(); err != nil {
+		t.Fatalf("failed to disable p2p: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start node: %v", err)
+	}
+	defer stack.Stop()
+	if err := stack.DisableP2P(); err != ErrNodeRunning {
+		t.Fatalf("disable failure mismatch: have %v, want %v", err, ErrNodeRunning)
+	}
+	if err := stack.StartP2P(); err != nil {
+		t.Fatalf("failed to start p2p: %v", err)
+	}
+	srv := stack.Server()
+	if srv.ListenAddr != "" || !srv.NoDiscovery || !srv.NoDial || srv.MaxPeers != 0 {
+		t.Fatalf("p2p server in the network: listen %q, no discovery %v, no dial %v, max peers %d",
+			srv.ListenAddr, srv.NoDiscovery, srv.NoDial, srv.MaxPeers)
+	}
+}
+
 // Tests that if the data dir is already in use, an appropriate error is returned.
 func TestNodeUsedDataDir(t *testing.T) {
 	// Create a temporary folder to use as the data directory
@@ -34,7 +34,7 @@ func (ctx *ServiceContext) OpenDatabase(name string, clean bool, isReadOnly bool
 	if ctx.config == nil || ctx.config.DataDir == "" {
 		return NewQkcMemoryDB(isReadOnly), nil
 	}
-	db, err := qkcdb.NewRDBDatabase(ctx.config.ResolvePath(name), clean, isReadOnly)
+	db, err := qkcdb.NewDatabase(ctx.config.DBBackend, ctx.config.ResolvePath(name), clean, isReadOnly)
 	if err != nil {
 		return nil, err
 	}
@@ -45,6 +45,9 @@ func (ctx *ServiceContext) OpenDatabase(name string, clean bool, isReadOnly bool
 // and if the user actually uses persistent storage. It will return an empty string
 // for emphemeral storage and the user's own input for absolute paths.
 func (ctx *ServiceContext) ResolvePath(path string) string {
+	if ctx.config == nil {
+		return ""
+	}
 	return ctx.config.ResolvePath(path)
 }
 
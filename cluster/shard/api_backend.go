@@ -1,6 +1,7 @@
 package shard
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math/big"
@@ -8,6 +9,7 @@ import (
 
 	"github.com/QuarkChain/goquarkchain/account"
 	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/cluster/service"
 	qsync "github.com/QuarkChain/goquarkchain/cluster/sync"
 	qcom "github.com/QuarkChain/goquarkchain/common"
 	"github.com/QuarkChain/goquarkchain/consensus"
@@ -56,7 +58,16 @@ func (s *ShardBackend) GetAllTx(start []byte, limit uint32) ([]*rpc.TransactionD
 }
 
 func (s *ShardBackend) GenTx(genTxs rpc.GenTxRequest) error {
+	if err := s.txBenchmark.acquire(); err != nil {
+		return err
+	}
+	defer s.txBenchmark.release()
 	log.Info(s.logInfo, "ready to genTx txNumber", genTxs.NumTxPerShard, "XShardPercent", genTxs.XShardPercent)
+	if genTxs.Seed != 0 {
+		for _, generator := range s.txGenerator {
+			generator.seed(genTxs.Seed)
+		}
+	}
 	allTxNumber := genTxs.NumTxPerShard
 	for allTxNumber > 0 {
 		pendingCnt := s.MinorBlockChain.GetPendingCount()
@@ -112,8 +123,8 @@ func (s *ShardBackend) GetLogs(hash common.Hash) ([][]*types.Log, error) {
 	return s.MinorBlockChain.GetLogs(hash), nil
 }
 
-func (s *ShardBackend) GetLogsByFilterQuery(args *qrpc.FilterQuery) ([]*types.Log, error) {
-	return s.MinorBlockChain.GetLogsByFilterQuery(args)
+func (s *ShardBackend) GetLogsByFilterQuery(ctx context.Context, args *qrpc.FilterQuery) ([]*types.Log, error) {
+	return s.MinorBlockChain.GetLogsByFilterQuery(ctx, args)
 }
 
 func (s *ShardBackend) GetReceiptsByHash(hash common.Hash) (types.Receipts, error) {
@@ -128,6 +139,12 @@ func (s *ShardBackend) GetReceiptsByHash(hash common.Hash) (types.Receipts, erro
 // Either recover state from local db or create genesis state based on config
 func (s *ShardBackend) InitFromRootBlock(rBlock *types.RootBlock) error {
 	if rBlock.Number() > s.genesisRootHeight {
+		if restored := s.restoredRootBlock(rBlock); restored != nil {
+			// the root blocks since the backup are added by the master
+			log.Warn(s.logInfo, "init restored shard from root block", restored.Number(), "root tip", rBlock.Number())
+			s.restored = true
+			rBlock = restored
+		}
 		return s.MinorBlockChain.InitFromRootBlock(rBlock)
 	}
 	if rBlock.Number() == s.genesisRootHeight {
@@ -136,10 +153,29 @@ func (s *ShardBackend) InitFromRootBlock(rBlock *types.RootBlock) error {
 	return nil
 }
 
-func (s *ShardBackend) AddRootBlock(rBlock *types.RootBlock) (switched bool, err error) {
+// AddRootBlock adds the root block to the shard, it fails with
+// rpc.ErrShardPaused if the shard is paused, for the master to add it again
+// once resumed.
+func (s *ShardBackend) AddRootBlock(rBlock *types.RootBlock) (bool, error) {
+	if err := s.enterBlockProcessing(); err != nil {
+		return false, err
+	}
+	defer s.exitBlockProcessing()
+	return s.addRootBlock(rBlock)
+}
+
+func (s *ShardBackend) addRootBlock(rBlock *types.RootBlock) (switched bool, err error) {
 	switched = false
+	if s.restored && s.MinorBlockChain.GetRootBlockByHash(rBlock.ParentHash()) == nil {
+		// the shard restored from a backup is still resynced by the master
+		log.Warn(s.logInfo, "skip root block of restored shard", rBlock.Number())
+		return false, nil
+	}
 	if rBlock.Number() > s.genesisRootHeight {
-		switched, err = s.MinorBlockChain.AddRootBlock(rBlock)
+		if switched, err = s.MinorBlockChain.AddRootBlock(rBlock); err == nil {
+			// the deposits confirmed by the root block are work of the miner on demand
+			s.miner.HandleNewWork()
+		}
 	}
 	if rBlock.Number() == s.genesisRootHeight {
 		err = s.initGenesisState(rBlock)
@@ -214,6 +250,10 @@ func (s *ShardBackend) GetMinorBlock(mHash common.Hash, height *uint64) (mBlock
 }
 
 func (s *ShardBackend) NewMinorBlock(peerId string, block *types.MinorBlock) (err error) {
+	if err = s.enterBlockProcessing(); err != nil {
+		return err
+	}
+	defer s.exitBlockProcessing()
 	log.Debug(s.logInfo, "NewMinorBlock height", block.NumberU64(), "hash", block.Hash().String())
 	defer log.Debug(s.logInfo, "NewMinorBlock", "end")
 	// TODO synchronizer.running
@@ -255,18 +295,26 @@ func (s *ShardBackend) NewMinorBlock(peerId string, block *types.MinorBlock) (er
 	}
 
 	s.mBPool.setBlockInPool(block.Header())
-	go func() {
-		if err = s.conn.BroadcastMinorBlock(peerId, block); err != nil {
+	s.schedule(func() {
+		if err := s.conn.BroadcastMinorBlock(peerId, block); err != nil {
 			log.Error("failed to broadcast new minor block", "err", err)
 		}
-	}()
+	})
 
-	return s.AddMinorBlock(block)
+	return s.addMinorBlock(block)
 }
 
 // Returns true if block is successfully added. False on any error.
 // called by 1. local miner (will not run if syncing) 2. SyncTask
 func (s *ShardBackend) AddMinorBlock(block *types.MinorBlock) error {
+	if err := s.enterBlockProcessing(); err != nil {
+		return err
+	}
+	defer s.exitBlockProcessing()
+	return s.addMinorBlock(block)
+}
+
+func (s *ShardBackend) addMinorBlock(block *types.MinorBlock) error {
 	if commitStatus := s.getBlockCommitStatusByHash(block.Hash()); commitStatus == BLOCK_COMMITTED {
 		return nil
 	}
@@ -304,12 +352,17 @@ func (s *ShardBackend) AddMinorBlock(block *types.MinorBlock) error {
 		ShardStats:        status,
 		CoinbaseAmountMap: block.CoinbaseAmount(),
 	}
-	err = s.conn.SendMinorBlockHeaderToMaster(requests)
-	if err != nil {
-		s.setHead(currHead.Number)
-		return err
+	if s.notifier != nil {
+		// the block is committed once the master gets the header
+		s.notifier.notify(requests)
+	} else {
+		err = s.conn.SendMinorBlockHeaderToMaster(requests)
+		if err != nil {
+			s.setHead(currHead.Number)
+			return err
+		}
+		s.MinorBlockChain.CommitMinorBlockByHash(block.Hash())
 	}
-	s.MinorBlockChain.CommitMinorBlockByHash(block.Hash())
 	s.mBPool.delBlockInPool(block.Hash())
 	if s.MinorBlockChain.CurrentBlock().Hash() != currHead.Hash() {
 		go s.miner.HandleNewTip()
@@ -332,6 +385,10 @@ func (s *ShardBackend) AddMinorBlock(block *types.MinorBlock) error {
 // It does NOT notify master because the master should already have the minor header list,
 // and will add them once this function returns successfully.
 func (s *ShardBackend) AddBlockListForSync(blockLst []*types.MinorBlock) (map[common.Hash]*types.TokenBalances, error) {
+	if err := s.enterBlockProcessing(); err != nil {
+		return nil, err
+	}
+	defer s.exitBlockProcessing()
 	blockHashToXShardList := make(map[common.Hash]*XshardListTuple)
 
 	coinbaseAmountList := make(map[common.Hash]*types.TokenBalances, 0)
@@ -415,6 +472,14 @@ func (s *ShardBackend) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.Su
 	return s.MinorBlockChain.SubscribeNewTxsEvent(ch)
 }
 
+func (s *ShardBackend) SubscribeReorgEvent(ch chan<- core.MinorChainReorgEvent) event.Subscription {
+	return s.MinorBlockChain.SubscribeReorgEvent(ch)
+}
+
+func (s *ShardBackend) SubscribeRootReorgEvent(ch chan<- core.RootChainReorgEvent) event.Subscription {
+	return s.MinorBlockChain.SubscribeRootReorgEvent(ch)
+}
+
 func (s *ShardBackend) SubscribeSyncEvent(ch chan<- *qsync.SyncingResult) event.Subscription {
 	return s.synchronizer.SubscribeSyncEvent(ch)
 }
@@ -442,8 +507,13 @@ func (s *ShardBackend) AddTxList(txs []*types.Transaction) error {
 			return err
 		}
 	}
+	s.broadcastTxList(txs)
+	return nil
+}
 
-	go func() {
+// broadcastTxList broadcasts the txs to the peers in the background.
+func (s *ShardBackend) broadcastTxList(txs []*types.Transaction) {
+	s.schedule(func() {
 		span := len(txs) / params.NEW_TRANSACTION_LIST_LIMIT
 		for index := 0; index < span; index++ {
 			if err := s.conn.BroadcastTransactions("", s.branch.Value, txs[index*params.NEW_TRANSACTION_LIST_LIMIT:(index+1)*params.NEW_TRANSACTION_LIST_LIMIT]); err != nil {
@@ -455,21 +525,33 @@ func (s *ShardBackend) AddTxList(txs []*types.Transaction) error {
 				log.Error(s.logInfo, "broadcastTransaction err", err)
 			}
 		}
-	}()
-	return nil
+	})
 }
 
 func (s *ShardBackend) GetDefaultCoinbaseAddress() account.Address {
-	addr := s.Config.CoinbaseAddress
+	addr := s.coinbaseAddress()
 	if !s.branch.IsInBranch(addr.FullShardKey) {
 		addr = addr.AddressInBranch(s.branch)
 	}
 	return addr
 }
 
+// SetCoinbaseAddress changes the coinbase of the blocks mined by the shard.
+func (s *ShardBackend) SetCoinbaseAddress(addr account.Address) {
+	s.coinbaseLock.Lock()
+	defer s.coinbaseLock.Unlock()
+	s.Config.CoinbaseAddress = addr
+}
+
+func (s *ShardBackend) coinbaseAddress() account.Address {
+	s.coinbaseLock.RLock()
+	defer s.coinbaseLock.RUnlock()
+	return s.Config.CoinbaseAddress
+}
+
 // miner api
 func (s *ShardBackend) CreateBlockToMine(addr *account.Address) (types.IBlock, *big.Int, uint64, error) {
-	coinbaseAddress := s.Config.CoinbaseAddress
+	coinbaseAddress := s.coinbaseAddress()
 	if addr != nil {
 		coinbaseAddress = *addr
 	}
@@ -515,3 +597,8 @@ func (s *ShardBackend) GetRootChainStakes(address account.Address, lastMinor com
 	}
 	return s.MinorBlockChain.GetRootChainStakes(address.Recipient, lastMinor)
 }
+
+// CheckDatabase checks that the database of the shard can be read.
+func (s *ShardBackend) CheckDatabase(name string) service.HealthCheck {
+	return service.CheckDatabase(name, s.chainDb)
+}
@@ -0,0 +1,81 @@
+package shard
+
+import (
+	"fmt"
+
+	"github.com/QuarkChain/goquarkchain/cluster/backup"
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/core/rawdb"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Backup writes a snapshot of the database of the shard to the archive at
+// path, to restore the shard from with the db restore command.
+func (s *ShardBackend) Backup(path string) (*rpc.BackupShardResponse, error) {
+	// the root tip is in the database before the snapshot is taken
+	meta := &backup.Metadata{FullShardID: s.branch.Value, RootHash: s.MinorBlockChain.GetRootTip().Hash()}
+	if err := backup.BackupFile(path, s.chainDb, meta); err != nil {
+		return nil, err
+	}
+	log.Info(s.logInfo, "backup shard to", path, "height", meta.Height, "root height", meta.RootHeight)
+	return &rpc.BackupShardResponse{
+		Height:     meta.Height,
+		Hash:       meta.Hash,
+		RootHeight: meta.RootHeight,
+		RootHash:   meta.RootHash,
+		Ancients:   meta.Ancients,
+	}, nil
+}
+
+// Restored reports whether the shard was started from a database restored
+// from a backup, so the master resyncs it to the root tip.
+func (s *ShardBackend) Restored() bool {
+	return s.restored
+}
+
+// restoredRootBlock returns the root block the database of the shard was
+// backed up at if it was restored from a backup and rBlock can't be added, as
+// the root blocks since the backup are unknown, or else nil.
+func (s *ShardBackend) restoredRootBlock(rBlock *types.RootBlock) *types.RootBlock {
+	if s.MinorBlockChain.GetRootBlockByHash(rBlock.Hash()) != nil ||
+		s.MinorBlockChain.GetRootBlockByHash(rBlock.ParentHash()) != nil {
+		return nil
+	}
+	hash := rawdb.ReadRestoredRootHash(s.chainDb)
+	if hash == (common.Hash{}) {
+		return nil
+	}
+	return s.MinorBlockChain.GetRootBlockByHash(hash)
+}
+
+// ResendXshardTxList re-executes the blocks of the shard with the hashes to
+// send their xshard tx lists to the neighbor shards again, as a shard restored
+// from a backup lacks the ones sent since the backup. The states of the
+// parents of the blocks are needed.
+func (s *ShardBackend) ResendXshardTxList(hashList []common.Hash) error {
+	blockHashToXShardList := make(map[common.Hash]*XshardListTuple)
+	for _, hash := range hashList {
+		block := s.MinorBlockChain.GetMinorBlock(hash)
+		if block == nil {
+			return fmt.Errorf(EmptyErrTemplate, "GetMinorBlock", hash.Hex())
+		}
+		if block.NumberU64() == 0 {
+			continue
+		}
+		_, xshardLst, err := s.MinorBlockChain.InsertChainForDeposits([]types.IBlock{block}, true)
+		if err != nil {
+			return err
+		}
+		if len(xshardLst) != 1 {
+			return fmt.Errorf("failed to re-execute block %x", hash)
+		}
+		prevRootHeight := s.MinorBlockChain.GetRootBlockByHash(block.PrevRootBlockHash()).Number()
+		blockHashToXShardList[hash] = &XshardListTuple{XshardTxList: xshardLst[0], PrevRootHeight: prevRootHeight}
+	}
+	if len(blockHashToXShardList) == 0 {
+		return nil
+	}
+	return s.conn.BatchBroadcastXshardTxList(blockHashToXShardList, s.branch)
+}
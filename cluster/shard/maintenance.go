@@ -0,0 +1,98 @@
+package shard
+
+import (
+	"errors"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/core/rawdb"
+	"github.com/QuarkChain/goquarkchain/qkcdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// A shard is paused for maintenance without restarting the slave: once the
+// blocks being added are, the new minor blocks are rejected with
+// rpc.ErrShardPaused, which the master and the synchronizer retry later, the
+// miner stops and so are the root blocks, which the master sends the slave
+// again once the shard is resumed.
+
+var errShardNotPaused = errors.New("shard is not paused")
+
+// enterBlockProcessing fails if the shard is paused, or else holds the pause
+// off until exitBlockProcessing is called.
+func (s *ShardBackend) enterBlockProcessing() error {
+	s.procLock.RLock()
+	if s.paused {
+		s.procLock.RUnlock()
+		return rpc.ErrShardPaused
+	}
+	return nil
+}
+
+func (s *ShardBackend) exitBlockProcessing() {
+	s.procLock.RUnlock()
+}
+
+// IsPaused reports whether the shard is paused for maintenance.
+func (s *ShardBackend) IsPaused() bool {
+	s.pauseLock.Lock()
+	defer s.pauseLock.Unlock()
+	return s.paused
+}
+
+// Pause waits for the blocks being added to the shard and stops the block
+// processing and the miner until Resume is called.
+func (s *ShardBackend) Pause() error {
+	s.pauseLock.Lock()
+	defer s.pauseLock.Unlock()
+	if s.paused {
+		return errors.New("shard is paused already")
+	}
+	s.procLock.Lock()
+	s.paused = true
+	s.procLock.Unlock()
+	s.miner.SetMining(false)
+	log.Warn(s.logInfo, "paused shard at height", s.MinorBlockChain.CurrentBlock().NumberU64())
+	return nil
+}
+
+// Resume starts the block processing and the miner again.
+func (s *ShardBackend) Resume() error {
+	s.pauseLock.Lock()
+	defer s.pauseLock.Unlock()
+	if !s.paused {
+		return errShardNotPaused
+	}
+	s.procLock.Lock()
+	s.paused = false
+	s.procLock.Unlock()
+	s.miner.SetMining(s.mining)
+	log.Warn(s.logInfo, "resumed shard at height", s.MinorBlockChain.CurrentBlock().NumberU64())
+	return nil
+}
+
+// Compact flattens the database of the paused shard.
+func (s *ShardBackend) Compact() error {
+	if !s.IsPaused() {
+		return errShardNotPaused
+	}
+	db, ok := rawdb.KeyValueStore(s.chainDb).(qkcdb.KeyValueStore)
+	if !ok {
+		return errors.New("database of shard can't be compacted")
+	}
+	start := time.Now()
+	if err := db.Compact(nil, nil); err != nil {
+		return err
+	}
+	log.Info(s.logInfo, "compacted database in", time.Since(start))
+	return nil
+}
+
+// Reindex writes the tx indexes of the paused shard again, and returns the
+// number of blocks reindexed.
+func (s *ShardBackend) Reindex() (uint64, error) {
+	if !s.IsPaused() {
+		return 0, errShardNotPaused
+	}
+	return s.MinorBlockChain.ReindexTxs()
+}
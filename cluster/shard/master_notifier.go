@@ -0,0 +1,150 @@
+package shard
+
+import (
+	"sync"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/core/rawdb"
+	"github.com/QuarkChain/goquarkchain/serialize"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	// masterNotifyQueue is the number of the block headers waiting to be sent
+	// to the master, beyond which adding a block waits for the master.
+	masterNotifyQueue = 256
+
+	// masterNotifyRetry is the time after which a header the master failed to
+	// get is sent again.
+	masterNotifyRetry = time.Second
+)
+
+// masterNotifier sends the headers of the blocks added to the shard to the
+// master in the background, once their cross-shard tx lists are delivered to
+// the neighbor slaves, so that adding a block doesn't wait for the master. The
+// headers are sent in order, each until the master gets it, and the block is
+// committed then. The master only puts the headers it got into root blocks, so
+// a late header delays its block's confirmation but doesn't break it. The
+// headers not sent yet are saved, so that the notifier of the next run sends
+// them if the slave stops before the master gets them.
+type masterNotifier struct {
+	send   func(*rpc.AddMinorBlockHeaderRequest) error
+	commit func(req *rpc.AddMinorBlockHeaderRequest)
+	save   func([]*rpc.AddMinorBlockHeaderRequest)
+
+	mu      sync.Mutex
+	pending []*rpc.AddMinorBlockHeaderRequest // headers not sent yet, in order
+
+	queue chan *rpc.AddMinorBlockHeaderRequest
+	quit  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// newMasterNotifier creates a notifier sending the pending headers, left by
+// the previous run, first.
+func newMasterNotifier(send func(*rpc.AddMinorBlockHeaderRequest) error, commit func(*rpc.AddMinorBlockHeaderRequest),
+	save func([]*rpc.AddMinorBlockHeaderRequest), pending []*rpc.AddMinorBlockHeaderRequest) *masterNotifier {
+	n := &masterNotifier{
+		send:    send,
+		commit:  commit,
+		save:    save,
+		pending: pending,
+		queue:   make(chan *rpc.AddMinorBlockHeaderRequest, masterNotifyQueue+len(pending)),
+		quit:    make(chan struct{}),
+	}
+	for _, req := range pending {
+		n.queue <- req
+	}
+	n.wg.Add(1)
+	go n.loop()
+	return n
+}
+
+// notify queues the header to be sent to the master, waiting for room in the
+// queue if it is full.
+func (n *masterNotifier) notify(req *rpc.AddMinorBlockHeaderRequest) {
+	n.mu.Lock()
+	n.pending = append(n.pending, req)
+	n.save(n.pending)
+	n.mu.Unlock()
+
+	select {
+	case n.queue <- req:
+	case <-n.quit:
+	}
+}
+
+func (n *masterNotifier) loop() {
+	defer n.wg.Done()
+	for {
+		select {
+		case req := <-n.queue:
+			for {
+				err := n.send(req)
+				if err == nil {
+					n.commit(req)
+					n.done(req)
+					break
+				}
+				log.Warn("Failed to send minor block header to master", "hash", req.MinorBlockHeader.Hash(),
+					"height", req.MinorBlockHeader.Number, "err", err)
+				select {
+				case <-time.After(masterNotifyRetry):
+				case <-n.quit:
+					return
+				}
+			}
+		case <-n.quit:
+			return
+		}
+	}
+}
+
+// done removes the header the master got from the pending ones.
+func (n *masterNotifier) done(req *rpc.AddMinorBlockHeaderRequest) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for i, r := range n.pending {
+		if r == req {
+			n.pending = append(n.pending[:i:i], n.pending[i+1:]...)
+			break
+		}
+	}
+	n.save(n.pending)
+}
+
+// stop leaves the headers not sent yet saved, whose blocks are left
+// uncommitted until the notifier of the next run sends them.
+func (n *masterNotifier) stop() {
+	close(n.quit)
+	n.wg.Wait()
+}
+
+// masterNotifyQueueData is the encoding of the headers not sent yet.
+type masterNotifyQueueData struct {
+	Requests []*rpc.AddMinorBlockHeaderRequest `bytesizeofslicelen:"4"`
+}
+
+// readMasterNotifyQueue returns the headers the previous run didn't send.
+func readMasterNotifyQueue(db ethdb.Database) []*rpc.AddMinorBlockHeaderRequest {
+	data := rawdb.ReadMasterNotifyQueue(db)
+	if len(data) == 0 {
+		return nil
+	}
+	var queue masterNotifyQueueData
+	if err := serialize.DeserializeFromBytes(data, &queue); err != nil {
+		log.Error("Failed to decode master notify queue", "err", err)
+		return nil
+	}
+	return queue.Requests
+}
+
+func writeMasterNotifyQueue(db ethdb.Database, requests []*rpc.AddMinorBlockHeaderRequest) {
+	data, err := serialize.SerializeToBytes(&masterNotifyQueueData{Requests: requests})
+	if err != nil {
+		log.Crit("Failed to encode master notify queue", "err", err)
+	}
+	rawdb.WriteMasterNotifyQueue(db, data)
+}
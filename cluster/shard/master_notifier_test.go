@@ -0,0 +1,92 @@
+package shard
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMasterNotifier(t *testing.T) {
+	var (
+		sent      = make(chan uint64, 8)
+		committed = make(chan uint64, 8)
+		failures  = 1
+	)
+	send := func(req *rpc.AddMinorBlockHeaderRequest) error {
+		sent <- req.MinorBlockHeader.Number
+		if failures > 0 {
+			failures--
+			return errors.New("master is down")
+		}
+		return nil
+	}
+	commit := func(req *rpc.AddMinorBlockHeaderRequest) {
+		committed <- req.MinorBlockHeader.Number
+	}
+	var saved []*rpc.AddMinorBlockHeaderRequest
+	save := func(requests []*rpc.AddMinorBlockHeaderRequest) {
+		saved = append(saved[:0], requests...)
+	}
+	n := newMasterNotifier(send, commit, save, nil)
+	header := func(number uint64) *rpc.AddMinorBlockHeaderRequest {
+		return &rpc.AddMinorBlockHeaderRequest{MinorBlockHeader: &types.MinorBlockHeader{Number: number}}
+	}
+
+	// the headers are sent in order, the failed one again until the master
+	// gets it, and their blocks are committed then
+	n.notify(header(1))
+	n.notify(header(2))
+	assert.Equal(t, uint64(1), <-sent)
+	select {
+	case number := <-committed:
+		t.Fatalf("block %d committed before the master got it", number)
+	case <-time.After(masterNotifyRetry / 2):
+	}
+	for _, number := range []uint64{1, 2} {
+		assert.Equal(t, number, <-sent)
+		assert.Equal(t, number, <-committed)
+	}
+
+	// the headers not sent are saved on stop, and sent first on restart
+	failures = 1 << 20
+	n.notify(header(3))
+	assert.Equal(t, uint64(3), <-sent)
+	n.stop()
+	assert.Len(t, committed, 0)
+	assert.Len(t, saved, 1)
+
+	failures = 0
+	n = newMasterNotifier(send, commit, save, saved)
+	n.notify(header(4))
+	for _, number := range []uint64{3, 4} {
+		assert.Equal(t, number, <-sent)
+		assert.Equal(t, number, <-committed)
+	}
+	n.stop()
+	assert.Len(t, saved, 0)
+}
+
+func TestMasterNotifyQueueEncoding(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	assert.Len(t, readMasterNotifyQueue(db), 0)
+
+	requests := []*rpc.AddMinorBlockHeaderRequest{{
+		MinorBlockHeader:  &types.MinorBlockHeader{Number: 7},
+		TxCount:           3,
+		CoinbaseAmountMap: types.NewEmptyTokenBalances(),
+		ShardStats:        &rpc.ShardStatus{},
+	}}
+	writeMasterNotifyQueue(db, requests)
+	queue := readMasterNotifyQueue(db)
+	if assert.Len(t, queue, 1) {
+		assert.Equal(t, requests[0].MinorBlockHeader.Hash(), queue[0].MinorBlockHeader.Hash())
+		assert.Equal(t, uint32(3), queue[0].TxCount)
+	}
+	writeMasterNotifyQueue(db, nil)
+	assert.Len(t, readMasterNotifyQueue(db), 0)
+}
@@ -0,0 +1,257 @@
+package shard
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+)
+
+var (
+	errSchedulerStopped = errors.New("shard scheduler stopped")
+	errShardQueueFull   = errors.New("shard task queue is full")
+)
+
+// Scheduler runs the tasks of the shards of a slave, e.g. the processing of
+// their blocks and the broadcasts of their blocks and txs, on a pool of
+// workers shared by the shards. Each worker has its own deque of tasks, the
+// tasks of a shard being queued to the deque of the worker the shard is
+// assigned to. A worker runs the tasks of its deque from the oldest one, and
+// once it has none it can run, steals the newest task it can run from the
+// deque of another worker, so that no worker is idle while tasks wait. At most
+// a number of tasks of a shard run at once, and a shard has at most a number of
+// tasks waiting, so that a busy shard can't starve the others. The tasks of a
+// shard may run out of order.
+type Scheduler struct {
+	deques []*taskDeque
+	wake   chan struct{} // a token per task queued or shard freed, for the idle workers
+	quit   chan struct{}
+	wg     sync.WaitGroup
+
+	mu       sync.Mutex
+	shards   map[uint32]*SchedulerStats
+	stopped  bool
+	perShard int
+	maxQueue int
+}
+
+// SchedulerStats is the load of a shard in the scheduler.
+type SchedulerStats struct {
+	Running int
+	Queued  int
+}
+
+type shardTask struct {
+	fullShardID uint32
+	run         func()
+}
+
+// taskDeque is the deque of the tasks of a worker, the oldest first.
+type taskDeque struct {
+	mu    sync.Mutex
+	tasks []*shardTask
+}
+
+func (d *taskDeque) push(task *shardTask) {
+	d.mu.Lock()
+	d.tasks = append(d.tasks, task)
+	d.mu.Unlock()
+}
+
+// take removes and returns the oldest task, or the newest one if steal is set,
+// which acquire accepts to run.
+func (d *taskDeque) take(steal bool, acquire func(fullShardID uint32) bool) *shardTask {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for j := range d.tasks {
+		i := j
+		if steal {
+			i = len(d.tasks) - 1 - j
+		}
+		task := d.tasks[i]
+		if !acquire(task.fullShardID) {
+			continue
+		}
+		copy(d.tasks[i:], d.tasks[i+1:])
+		d.tasks[len(d.tasks)-1] = nil
+		d.tasks = d.tasks[:len(d.tasks)-1]
+		return task
+	}
+	return nil
+}
+
+func (d *taskDeque) clear() {
+	d.mu.Lock()
+	d.tasks = nil
+	d.mu.Unlock()
+}
+
+// NewScheduler starts a scheduler with the workers, the tasks of a shard run
+// at once and the tasks of a shard waiting to run of the config. Stop must be
+// called to stop the workers.
+func NewScheduler(workers, perShard, maxQueue int) *Scheduler {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if perShard <= 0 {
+		perShard = (workers + 1) / 2
+	}
+	s := &Scheduler{
+		deques:   make([]*taskDeque, workers),
+		wake:     make(chan struct{}, workers),
+		quit:     make(chan struct{}),
+		shards:   make(map[uint32]*SchedulerStats),
+		perShard: perShard,
+		maxQueue: maxQueue,
+	}
+	for i := range s.deques {
+		s.deques[i] = new(taskDeque)
+	}
+	s.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go s.loop(i)
+	}
+	return s
+}
+
+// Go queues the task of the shard, it fails at once if the queue of the shard
+// is full.
+func (s *Scheduler) Go(fullShardID uint32, task func()) error {
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return errSchedulerStopped
+	}
+	st := s.shards[fullShardID]
+	if st == nil {
+		st = new(SchedulerStats)
+		s.shards[fullShardID] = st
+	}
+	if st.Queued >= s.maxQueue {
+		s.mu.Unlock()
+		return errShardQueueFull
+	}
+	st.Queued++
+	s.mu.Unlock()
+
+	s.deques[int(fullShardID%uint32(len(s.deques)))].push(&shardTask{fullShardID: fullShardID, run: task})
+	s.signal()
+	return nil
+}
+
+// Run queues the task of the shard and waits for it to return. It must not be
+// called by a task, which could wait for a worker forever.
+func (s *Scheduler) Run(fullShardID uint32, task func() error) error {
+	errc := make(chan error, 1)
+	if err := s.Go(fullShardID, func() { errc <- task() }); err != nil {
+		return err
+	}
+	select {
+	case err := <-errc:
+		return err
+	case <-s.quit:
+		return errSchedulerStopped
+	}
+}
+
+// signal wakes an idle worker up, if none is woken up already.
+func (s *Scheduler) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// acquire takes a task of the shard out of its queue to run it, unless
+// perShard of its tasks run already.
+func (s *Scheduler) acquire(fullShardID uint32) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.shards[fullShardID]
+	if s.stopped || st == nil || st.Running >= s.perShard {
+		return false
+	}
+	st.Queued--
+	st.Running++
+	return true
+}
+
+// release marks the task of the shard returned.
+func (s *Scheduler) release(fullShardID uint32) {
+	s.mu.Lock()
+	st := s.shards[fullShardID]
+	st.Running--
+	queued := st.Queued > 0
+	if st.Running == 0 && st.Queued == 0 {
+		delete(s.shards, fullShardID)
+	}
+	s.mu.Unlock()
+	if queued {
+		// a task of the shard may have waited for this one
+		s.signal()
+	}
+}
+
+// next returns the next task the worker can run, from its own deque first and
+// then stolen from the others in turn, or nil if none.
+func (s *Scheduler) next(worker int) *shardTask {
+	if task := s.deques[worker].take(false, s.acquire); task != nil {
+		return task
+	}
+	for i := 1; i < len(s.deques); i++ {
+		if task := s.deques[(worker+i)%len(s.deques)].take(true, s.acquire); task != nil {
+			return task
+		}
+	}
+	return nil
+}
+
+func (s *Scheduler) loop(worker int) {
+	defer s.wg.Done()
+	for {
+		task := s.next(worker)
+		if task == nil {
+			select {
+			case <-s.wake:
+				continue
+			case <-s.quit:
+				return
+			}
+		}
+		// the other tasks queued meanwhile are left to the other workers
+		s.signal()
+		task.run()
+		s.release(task.fullShardID)
+	}
+}
+
+// Stats returns the load of the shards having tasks running or queued.
+func (s *Scheduler) Stats() map[uint32]SchedulerStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := make(map[uint32]SchedulerStats, len(s.shards))
+	for id, st := range s.shards {
+		stats[id] = *st
+	}
+	return stats
+}
+
+// Stop drops the tasks queued and waits for the ones running to return.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return
+	}
+	s.stopped = true
+	close(s.quit)
+	for id, st := range s.shards {
+		if st.Queued = 0; st.Running == 0 {
+			delete(s.shards, id)
+		}
+	}
+	s.mu.Unlock()
+	for _, d := range s.deques {
+		d.clear()
+	}
+	s.wg.Wait()
+}
@@ -0,0 +1,80 @@
+package shard
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduler(t *testing.T) {
+	s := NewScheduler(2, 1, 3)
+	defer s.Stop()
+
+	var (
+		release = make(chan struct{})
+		started = make(chan uint32, 8)
+	)
+	task := func(id uint32) func() {
+		return func() {
+			started <- id
+			<-release
+		}
+	}
+
+	// the busy shard 1 runs a task at once, leaving a worker to shard 2
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, s.Go(1, task(1)))
+	}
+	assert.Equal(t, uint32(1), <-started)
+	assert.NoError(t, s.Go(1, task(1)))
+	assert.Equal(t, errShardQueueFull, s.Go(1, task(1)))
+	assert.NoError(t, s.Go(2, task(2)))
+	assert.Equal(t, uint32(2), <-started)
+	assert.Equal(t, map[uint32]SchedulerStats{1: {Running: 1, Queued: 3}, 2: {Running: 1}}, s.Stats())
+
+	close(release)
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, uint32(1), <-started)
+	}
+	assert.NoError(t, s.Run(2, func() error { return nil }))
+	for len(s.Stats()) != 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	s.Stop()
+	assert.Equal(t, errSchedulerStopped, s.Go(1, task(1)))
+}
+
+func TestSchedulerStealing(t *testing.T) {
+	s := NewScheduler(2, 2, 8)
+	defer s.Stop()
+
+	var (
+		release = make(chan struct{})
+		started = make(chan uint32, 8)
+	)
+	task := func(id uint32) func() {
+		return func() {
+			started <- id
+			<-release
+		}
+	}
+
+	// the shards 1 and 3 are queued to the same worker, the other one steals
+	// the task of shard 3 while the task of shard 1 runs
+	assert.NoError(t, s.Go(1, task(1)))
+	assert.Equal(t, uint32(1), <-started)
+	assert.NoError(t, s.Go(3, task(3)))
+	select {
+	case id := <-started:
+		assert.Equal(t, uint32(3), id)
+	case <-time.After(time.Second):
+		t.Fatal("task not stolen")
+	}
+	assert.Equal(t, map[uint32]SchedulerStats{1: {Running: 1}, 3: {Running: 1}}, s.Stats())
+	close(release)
+	for len(s.Stats()) != 0 {
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"github.com/QuarkChain/goquarkchain/account"
 	"github.com/QuarkChain/goquarkchain/consensus/simulate"
 	"math/big"
+	"os"
 	"sync"
+	"time"
 
 	"github.com/QuarkChain/goquarkchain/cluster/config"
 	"github.com/QuarkChain/goquarkchain/cluster/miner"
@@ -53,14 +55,29 @@ type ShardBackend struct {
 
 	mBPool      newBlockPool
 	txGenerator []*TxGenerator
+	txBenchmark txBenchmark
+	restored    bool // restored from a backup, see InitFromRootBlock
 
 	running      bool
 	mu           sync.Mutex
+	coinbaseLock sync.RWMutex
 	eventMux     *event.TypeMux
 	synchronizer synchronizer.Synchronizer
 	logInfo      string
 
 	posw consensus.PoSWCalculator
+
+	newTxsCh  chan core.NewTxsEvent
+	newTxsSub event.Subscription
+
+	scheduler *Scheduler      // runs the broadcasts in the background, nil for a goroutine each
+	notifier  *masterNotifier // sends the block headers to the master in the background, nil to wait for the master
+
+	// the block processing holds procLock, which pausing the shard waits for
+	procLock  sync.RWMutex
+	pauseLock sync.Mutex // guards the fields below, and paused is written holding procLock too
+	paused    bool
+	mining    bool
 }
 
 func New(ctx *service.ServiceContext, rBlock *types.RootBlock, conn ConnManager,
@@ -80,12 +97,32 @@ func New(ctx *service.ServiceContext, rBlock *types.RootBlock, conn ConnManager,
 			eventMux:          ctx.EventMux,
 			logInfo:           fmt.Sprintf("shard:%d", fullshardId),
 			running:           true,
+			newTxsCh:          make(chan core.NewTxsEvent, 16),
 		}
 		err error
 	)
 	shard.maxBlocks = shard.Config.MaxBlocksPerShardInOneRootBlock()
 
-	shard.chainDb, err = createDB(ctx, fmt.Sprintf("shard-%d/db", fullshardId), cfg.Clean, cfg.CheckDB)
+	// nil cache config keeps the state in archive mode
+	var cacheConfig *core.CacheConfig
+	if cfg.State != nil {
+		if err = cfg.State.Validate(); err != nil {
+			return nil, err
+		}
+		cacheConfig = &core.CacheConfig{
+			Disabled:       cfg.State.GetGCMode(fullshardId) == config.GCModeArchive,
+			TrieTimeLimit:  time.Duration(cfg.State.TrieTimeLimit) * time.Second,
+			Snapshot:       cfg.State.Snapshot,
+			Prefetch:       cfg.State.Prefetch,
+			BalanceHistory: cfg.State.BalanceHistory,
+		}
+		cacheConfig.TrieCleanLimit, cacheConfig.TrieDirtyLimit = cfg.State.GetTrieCaches(fullshardId)
+		if cacheConfig.BalanceHistoryRecipients, err = cfg.State.GetBalanceHistoryRecipients(); err != nil {
+			return nil, err
+		}
+	}
+
+	shard.chainDb, err = createDB(ctx, fmt.Sprintf("shard-%d/db", fullshardId), fmt.Sprintf("shard-%d/ancient", fullshardId), cfg.Clean, cfg.CheckDB)
 	if err != nil {
 		return nil, err
 	}
@@ -99,27 +136,89 @@ func New(ctx *service.ServiceContext, rBlock *types.RootBlock, conn ConnManager,
 	}
 
 	chainConfig, genesisHash, genesisErr := core.SetupGenesisMinorBlock(shard.chainDb, shard.gspec, rBlock, fullshardId)
-	// TODO check config err
+	if compatErr, ok := genesisErr.(*config.ForkCompatError); ok {
+		shard.chainDb.Close()
+		return nil, compatErr
+	}
 	if genesisErr != nil {
 		log.Info("Fill in block into chain db.")
 		rawdb.WriteChainConfig(shard.chainDb, genesisHash, cfg.Quarkchain)
 	}
 	log.Debug("Initialised chain configuration", "config", chainConfig)
 
-	shard.MinorBlockChain, err = core.NewMinorBlockChain(shard.chainDb, nil, &params.ChainConfig{}, cfg, shard.engine, vm.Config{}, nil, fullshardId)
+	shard.MinorBlockChain, err = core.NewMinorBlockChain(shard.chainDb, cacheConfig, &params.ChainConfig{}, cfg, shard.engine, vm.Config{}, nil, fullshardId)
 	if err != nil {
 		shard.chainDb.Close()
 		return nil, err
 	}
 	shard.MinorBlockChain.SetBroadcastMinorBlockFunc(shard.AddMinorBlock)
+	if cfg.TxJournal != "" {
+		// resurrect local transactions which were not included before the restart
+		journal := ctx.ResolvePath(fmt.Sprintf("shard-%d/%s", fullshardId, cfg.TxJournal))
+		if err = shard.MinorBlockChain.SetTxPoolJournal(journal); err != nil {
+			log.Warn("Failed to set tx pool journal", "shard", fullshardId, "err", err)
+		}
+	}
+	if cfg.XShardJournal != "" {
+		// restore the cross-shard tx lists received but lost by a crash
+		journal := ctx.ResolvePath(fmt.Sprintf("shard-%d/%s", fullshardId, cfg.XShardJournal))
+		if cfg.Clean && journal != "" {
+			os.Remove(journal)
+		}
+		if err = shard.MinorBlockChain.SetXShardJournal(journal); err != nil {
+			log.Warn("Failed to set cross-shard tx list journal", "shard", fullshardId, "err", err)
+		}
+	}
+	// the headers the previous run didn't send are sent in the background,
+	// so the notifier runs on the restart after ASYNC_MASTER_NOTIFY is turned
+	// off if any is left
+	var pending []*rpc.AddMinorBlockHeaderRequest
+	for _, req := range readMasterNotifyQueue(shard.chainDb) {
+		if !shard.MinorBlockChain.IsMinorBlockCommittedByHash(req.MinorBlockHeader.Hash()) {
+			pending = append(pending, req)
+		}
+	}
+	if cfg.AsyncMasterNotify || len(pending) > 0 {
+		shard.notifier = newMasterNotifier(conn.SendMinorBlockHeaderToMaster, func(req *rpc.AddMinorBlockHeaderRequest) {
+			shard.MinorBlockChain.CommitMinorBlockByHash(req.MinorBlockHeader.Hash())
+		}, func(requests []*rpc.AddMinorBlockHeaderRequest) {
+			writeMasterNotifyQueue(shard.chainDb, requests)
+		}, pending)
+	}
 	shard.synchronizer = synchronizer.NewSynchronizer(shard.MinorBlockChain)
 	shard.posw = consensus.CreatePoSWCalculator(shard.MinorBlockChain, shard.Config.PoswConfig)
 
 	shard.miner = miner.New(ctx, shard, shard.engine)
+	if shard.Config.ConsensusConfig.MineOnDemand {
+		shard.miner.SetOnDemand(shard.hasWork)
+		shard.newTxsSub = shard.MinorBlockChain.SubscribeNewTxsEvent(shard.newTxsCh)
+		go shard.newTxsLoop()
+	}
 
 	return shard, nil
 }
 
+// hasWork reports whether there are pending txs or cross-shard deposits to be
+// included in a block mined on demand.
+func (s *ShardBackend) hasWork() bool {
+	if s.MinorBlockChain.GetPendingCount() > 0 {
+		return true
+	}
+	deposits, err := s.MinorBlockChain.GetUnreceivedXShardDeposits(1)
+	return err == nil && len(deposits) > 0
+}
+
+func (s *ShardBackend) newTxsLoop() {
+	for {
+		select {
+		case <-s.newTxsCh:
+			s.miner.HandleNewWork()
+		case <-s.newTxsSub.Err():
+			return
+		}
+	}
+}
+
 func (s *ShardBackend) IsSyncing () bool {
 	return s.synchronizer.IsSyncing()
 }
@@ -131,25 +230,72 @@ func (s *ShardBackend) Stop() {
 		return
 	}
 	s.running = false
+	s.txBenchmark.stop(nil)
 	s.synchronizer.Close()
+	if s.newTxsSub != nil {
+		s.newTxsSub.Unsubscribe()
+	}
 	s.miner.Stop()
+	if s.notifier != nil {
+		s.notifier.stop()
+	}
 	s.eventMux.Stop()
 	s.engine.Close()
 	s.MinorBlockChain.Stop()
 	s.chainDb.Close()
 }
 
+// SetMining starts or stops the miner, which stays stopped while the shard is
+// paused.
 func (s *ShardBackend) SetMining(mining bool) {
-	s.miner.SetMining(mining)
+	s.pauseLock.Lock()
+	defer s.pauseLock.Unlock()
+	s.mining = mining
+	if !s.paused {
+		s.miner.SetMining(mining)
+	}
+}
+
+// SetScheduler runs the broadcasts of the shard on the scheduler shared by the
+// shards of the slave.
+func (s *ShardBackend) SetScheduler(scheduler *Scheduler) {
+	s.scheduler = scheduler
 }
 
-func createDB(ctx *service.ServiceContext, name string, clean bool, isReadOnly bool) (ethdb.Database, error) {
+// schedule runs the task in the background, dropped if the queue of the shard
+// is full.
+func (s *ShardBackend) schedule(task func()) {
+	if s.scheduler == nil {
+		go task()
+		return
+	}
+	if err := s.scheduler.Go(s.branch.Value, task); err != nil {
+		log.Warn(s.logInfo, "drop background task", err)
+	}
+}
+
+func createDB(ctx *service.ServiceContext, name, ancient string, clean bool, isReadOnly bool) (ethdb.Database, error) {
 	// handlers and caches size should be set in different environment.
 	db, err := ctx.OpenDatabase(name, clean, isReadOnly)
 	if err != nil {
 		return nil, err
 	}
-	return db, nil
+	// memory database has no ancient store
+	if ancient = ctx.ResolvePath(ancient); ancient == "" {
+		return db, nil
+	}
+	if clean {
+		if err := os.RemoveAll(ancient); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	frdb, err := rawdb.NewDatabaseWithFreezer(db, ancient)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return frdb, nil
 }
 
 func createConsensusEngine(qkcHashXHeight uint64, cfg *config.ShardConfig) (consensus.Engine, error) {
@@ -162,7 +308,11 @@ func createConsensusEngine(qkcHashXHeight uint64, cfg *config.ShardConfig) (cons
 	pubKey := []byte{}
 	switch cfg.ConsensusType {
 	case config.PoWSimulate:
-		return simulate.New(&diffCalculator, cfg.ConsensusConfig.RemoteMine, pubKey, uint64(cfg.ConsensusConfig.TargetBlockTime)), nil
+		blockInterval := uint64(cfg.ConsensusConfig.TargetBlockTime)
+		if cfg.ConsensusConfig.MineOnDemand {
+			blockInterval = 0
+		}
+		return simulate.New(&diffCalculator, cfg.ConsensusConfig.RemoteMine, pubKey, blockInterval), nil
 	case config.PoWEthash:
 		return ethash.New(ethash.Config{CachesInMem: 3, CachesOnDisk: 10, CacheDir: "", PowMode: ethash.ModeNormal}, &diffCalculator, cfg.ConsensusConfig.RemoteMine, pubKey), nil
 	case config.PoWQkchash:
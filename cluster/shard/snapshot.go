@@ -0,0 +1,169 @@
+package shard
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/QuarkChain/goquarkchain/cluster/backup"
+	"github.com/QuarkChain/goquarkchain/cluster/config"
+	"github.com/QuarkChain/goquarkchain/cluster/service"
+	"github.com/QuarkChain/goquarkchain/core/rawdb"
+	"github.com/QuarkChain/goquarkchain/core/state"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// WriteSnapshot writes a snapshot of the database of the shard taken at the
+// root block with rootHash to w, as a gzipped backup archive, for a new slave
+// to bootstrap the shard from with Bootstrap.
+func (s *ShardBackend) WriteSnapshot(rootHash common.Hash, w io.Writer) error {
+	if s.MinorBlockChain.GetRootBlockByHash(rootHash) == nil {
+		return fmt.Errorf("root block %x not known to shard %d", rootHash, s.branch.Value)
+	}
+	// the new shard starts from the state of the last block confirmed by the
+	// root block, which may be in memory only
+	if hash := s.MinorBlockChain.ReadLastConfirmedMinorBlockHeaderAtRootBlock(rootHash); hash != (common.Hash{}) {
+		block := s.MinorBlockChain.GetMinorBlock(hash)
+		if block == nil {
+			return fmt.Errorf(EmptyErrTemplate, "GetMinorBlock", hash.Hex())
+		}
+		if err := s.MinorBlockChain.CommitState(block.Root()); err != nil {
+			return err
+		}
+	}
+	zw := gzip.NewWriter(w)
+	meta := &backup.Metadata{FullShardID: s.branch.Value, RootHash: rootHash}
+	if err := backup.Backup(zw, s.chainDb, meta); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	log.Info(s.logInfo, "served snapshot at height", meta.Height, "root height", meta.RootHeight)
+	return nil
+}
+
+// HasDB reports whether the database of the shard fullShardId exists, as a new
+// shard is bootstrapped from a snapshot. The memory database never does.
+func HasDB(ctx *service.ServiceContext, fullShardId uint32) bool {
+	path := ctx.ResolvePath(fmt.Sprintf("shard-%d/db", fullShardId))
+	if path == "" {
+		return true
+	}
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Bootstrap restores the database of the new shard fullShardId from the
+// snapshot read from r, written by WriteSnapshot of another slave, and
+// verifies it against rBlock, the root tip of the master. The database is
+// removed if the snapshot is invalid.
+func Bootstrap(ctx *service.ServiceContext, cfg *config.ClusterConfig, fullShardId uint32, rBlock *types.RootBlock, r io.Reader) (err error) {
+	name, ancient := fmt.Sprintf("shard-%d/db", fullShardId), fmt.Sprintf("shard-%d/ancient", fullShardId)
+	db, err := createDB(ctx, name, ancient, false, false)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		db.Close()
+		if err != nil {
+			os.RemoveAll(ctx.ResolvePath(name))
+			os.RemoveAll(ctx.ResolvePath(ancient))
+		}
+	}()
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	meta, err := backup.Restore(zr, db)
+	if err != nil {
+		return err
+	}
+	genesisRootHeight := cfg.Quarkchain.GetShardConfigByFullShardID(fullShardId).Genesis.RootHeight
+	if err = verifySnapshot(db, meta, fullShardId, genesisRootHeight, rBlock); err != nil {
+		return fmt.Errorf("invalid snapshot: %v", err)
+	}
+	log.Info("Bootstrapped shard from snapshot", "shard", fullShardId, "height", meta.Height, "root height", meta.RootHeight)
+	return nil
+}
+
+// verifySnapshot checks that the root blocks of the snapshot link by their
+// hashes rBlock down to the one confirming the last block of the shard, which
+// has to be in the canonical chain of the snapshot with its whole state.
+func verifySnapshot(db ethdb.Database, meta *backup.Metadata, fullShardId, genesisRootHeight uint32, rBlock *types.RootBlock) error {
+	if meta.FullShardID != fullShardId {
+		return fmt.Errorf("snapshot of shard %d, expected %d", meta.FullShardID, fullShardId)
+	}
+	if meta.RootHash != rBlock.Hash() {
+		return fmt.Errorf("snapshot at root block %x, expected %x", meta.RootHash, rBlock.Hash())
+	}
+	var (
+		hash      = rBlock.Hash()
+		confirmed *types.MinorBlockHeader
+	)
+	for confirmed == nil {
+		block := rawdb.ReadRootBlock(db, hash)
+		if block == nil || block.Hash() != hash {
+			return fmt.Errorf("root block %x missing", hash)
+		}
+		headers := block.MinorBlockHeaders()
+		// a root block without minor headers may have an empty header hash,
+		// e.g. the genesis one
+		if (len(headers) > 0 || block.MinorHeaderHash() != types.EmptyHash) &&
+			types.CalculateMerkleRoot(headers) != block.MinorHeaderHash() {
+			return fmt.Errorf("incorrect minor headers of root block %d", block.Number())
+		}
+		for i := len(headers) - 1; i >= 0; i-- {
+			if headers[i].Branch.Value == fullShardId {
+				confirmed = headers[i]
+				break
+			}
+		}
+		if confirmed == nil && block.Number() <= genesisRootHeight {
+			// no block of the shard is confirmed yet
+			return nil
+		}
+		hash = block.ParentHash()
+	}
+	if rawdb.ReadCanonicalHash(db, rawdb.ChainTypeMinor, confirmed.Number) != confirmed.Hash() {
+		return fmt.Errorf("minor block %d confirmed by root block not canonical", confirmed.Number)
+	}
+	block := rawdb.ReadMinorBlock(db, confirmed.Hash())
+	if block == nil || block.Meta().Hash() != confirmed.MetaHash {
+		return fmt.Errorf("minor block %d confirmed by root block missing", confirmed.Number)
+	}
+	if err := verifyState(db, block.Root()); err != nil {
+		return fmt.Errorf("state of minor block %d invalid: %v", confirmed.Number, err)
+	}
+	return nil
+}
+
+// verifyState walks the whole state with root, the account and storage tries
+// and the contract codes, and checks that every node is in db and matches its
+// hash.
+func verifyState(db ethdb.Database, root common.Hash) error {
+	stateDB, err := state.New(root, state.NewDatabase(db))
+	if err != nil {
+		return err
+	}
+	it := state.NewNodeIterator(stateDB)
+	for it.Next() {
+		if it.Hash == (common.Hash{}) {
+			// embedded in its parent node
+			continue
+		}
+		blob, err := db.Get(it.Hash.Bytes())
+		if err != nil {
+			return fmt.Errorf("node %x missing", it.Hash)
+		}
+		if crypto.Keccak256Hash(blob) != it.Hash {
+			return fmt.Errorf("node %x corrupted", it.Hash)
+		}
+	}
+	return it.Error
+}
@@ -0,0 +1,97 @@
+package shard
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/cluster/backup"
+	qcom "github.com/QuarkChain/goquarkchain/common"
+	"github.com/QuarkChain/goquarkchain/core/rawdb"
+	"github.com/QuarkChain/goquarkchain/core/state"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifySnapshot(t *testing.T) {
+	var (
+		db          = ethdb.NewMemDatabase()
+		fullShardId = uint32(1)
+		meta        = &types.MinorBlockMeta{Root: types.EmptyTrieHash}
+		header      = &types.MinorBlockHeader{Number: 3, Branch: account.Branch{Value: fullShardId}, MetaHash: meta.Hash()}
+		block       = types.NewMinorBlockWithHeader(header, meta)
+		genesis     = types.NewRootBlock(&types.RootBlockHeader{}, nil, nil)
+		confirming  = types.NewRootBlock(&types.RootBlockHeader{Number: 1, ParentHash: genesis.Hash()}, types.MinorBlockHeaders{header}, nil)
+		tip         = types.NewRootBlock(&types.RootBlockHeader{Number: 2, ParentHash: confirming.Hash()}, nil, nil)
+	)
+	for _, rBlock := range []*types.RootBlock{genesis, confirming, tip} {
+		rawdb.WriteRootBlock(db, rBlock)
+	}
+	rawdb.WriteMinorBlock(db, block)
+	snapshot := &backup.Metadata{FullShardID: fullShardId, RootHash: tip.Hash()}
+
+	// the confirmed block is not canonical yet
+	assert.Error(t, verifySnapshot(db, snapshot, fullShardId, 0, tip))
+	rawdb.WriteCanonicalHash(db, rawdb.ChainTypeMinor, block.Hash(), block.NumberU64())
+	assert.NoError(t, verifySnapshot(db, snapshot, fullShardId, 0, tip))
+
+	// the snapshot is taken at the root tip of the master
+	assert.Error(t, verifySnapshot(db, snapshot, fullShardId, 0, confirming))
+	assert.Error(t, verifySnapshot(db, &backup.Metadata{FullShardID: 2, RootHash: tip.Hash()}, fullShardId, 0, tip))
+
+	// no block of the shard is confirmed down to its genesis root block
+	assert.NoError(t, verifySnapshot(db, &backup.Metadata{FullShardID: 2, RootHash: tip.Hash()}, 2, 0, tip))
+
+	// the state of the confirmed block is missing
+	meta = &types.MinorBlockMeta{Root: common.HexToHash("0x01")}
+	header = &types.MinorBlockHeader{Number: 4, Branch: account.Branch{Value: fullShardId}, MetaHash: meta.Hash()}
+	block = types.NewMinorBlockWithHeader(header, meta)
+	rawdb.WriteMinorBlock(db, block)
+	rawdb.WriteCanonicalHash(db, rawdb.ChainTypeMinor, block.Hash(), block.NumberU64())
+	tip = types.NewRootBlock(&types.RootBlockHeader{Number: 3, ParentHash: tip.Hash()}, types.MinorBlockHeaders{header}, nil)
+	rawdb.WriteRootBlock(db, tip)
+	assert.Error(t, verifySnapshot(db, &backup.Metadata{FullShardID: fullShardId, RootHash: tip.Hash()}, fullShardId, 0, tip))
+}
+
+func TestVerifyState(t *testing.T) {
+	var (
+		db        = ethdb.NewMemDatabase()
+		stateDB   = state.NewDatabase(db)
+		code      = []byte{1, 2, 3, 4, 5}
+		contract  = common.BytesToAddress([]byte{1})
+		tokenID   = qcom.TokenIDEncode("QKC")
+		addresses = 32
+	)
+	evmState, err := state.New(common.Hash{}, stateDB)
+	assert.NoError(t, err)
+	for i := 0; i < addresses; i++ {
+		evmState.AddBalance(common.BytesToAddress([]byte{byte(i + 2)}), big.NewInt(int64(i+1)), tokenID)
+	}
+	evmState.SetCode(contract, code)
+	evmState.SetState(contract, common.HexToHash("0x01"), common.HexToHash("0x02"))
+	root, err := evmState.Commit(true)
+	assert.NoError(t, err)
+	assert.NoError(t, stateDB.TrieDB().Commit(root, false))
+	assert.NoError(t, verifyState(db, root))
+
+	// the code does not match its hash
+	codeHash := crypto.Keccak256Hash(code)
+	assert.NoError(t, db.Put(codeHash.Bytes(), []byte{5, 4, 3, 2, 1}))
+	assert.Error(t, verifyState(db, root))
+	assert.NoError(t, db.Put(codeHash.Bytes(), code))
+
+	// a node of the account trie is missing
+	var node []byte
+	for _, key := range db.Keys() {
+		if len(key) == common.HashLength && common.BytesToHash(key) != root && common.BytesToHash(key) != codeHash {
+			node = key
+			break
+		}
+	}
+	assert.NotNil(t, node)
+	assert.NoError(t, db.Delete(node))
+	assert.Error(t, verifyState(db, root))
+}
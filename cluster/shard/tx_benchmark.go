@@ -0,0 +1,287 @@
+package shard
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/core"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	// txBenchmarkInterval is the interval the benchmark adds its txs at.
+	txBenchmarkInterval = 100 * time.Millisecond
+	// maxTxBenchmarkDuration bounds the benchmarks in seconds, as the time
+	// each tx is sent at is kept until it is confirmed.
+	maxTxBenchmarkDuration = 3600
+	// txBenchmarkGrace is how long the txs sent are still waited for once the
+	// benchmark ended.
+	txBenchmarkGrace = time.Minute
+)
+
+var errTxGenerating = errors.New("transactions are being generated")
+
+// txBenchmark tracks the txs sent by the tx generators of the shard until they
+// are confirmed, to report the TPS achieved and the confirmation latencies.
+type txBenchmark struct {
+	mu            sync.Mutex
+	generating    bool // txs are being generated, by GenTx or a benchmark
+	running       bool // txs are sent by the benchmark
+	tps           uint32
+	start         time.Time
+	end           time.Time
+	lastConfirmed time.Time
+	sent          uint64
+	confirmed     uint64
+	pending       map[common.Hash]time.Time
+	latencies     []time.Duration
+	quit          chan struct{}
+}
+
+// acquire marks the txs as being generated, or fails if they already are.
+func (b *txBenchmark) acquire() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.generating {
+		return errTxGenerating
+	}
+	b.generating = true
+	return nil
+}
+
+func (b *txBenchmark) release() {
+	b.mu.Lock()
+	b.generating = false
+	b.mu.Unlock()
+}
+
+// reset starts tracking the txs of a new benchmark.
+func (b *txBenchmark) reset(tps, duration uint32) chan struct{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.quit != nil {
+		// stop waiting for the txs of the last benchmark
+		close(b.quit)
+	}
+	b.running = true
+	b.tps = tps
+	b.start = time.Now()
+	b.end = b.start.Add(time.Duration(duration) * time.Second)
+	b.lastConfirmed = time.Time{}
+	b.sent, b.confirmed = 0, 0
+	b.pending = make(map[common.Hash]time.Time)
+	b.latencies = nil
+	b.quit = make(chan struct{})
+	return b.quit
+}
+
+// stop stops tracking the txs of the benchmark of the quit channel, or of
+// the last benchmark if nil, unless a new benchmark started.
+func (b *txBenchmark) stop(quit chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if quit == nil {
+		quit = b.quit
+	}
+	if quit != nil && quit == b.quit {
+		close(b.quit)
+		b.quit = nil
+	}
+}
+
+// finish marks the end of the sending of the txs of the benchmark of the quit
+// channel, unless it ended already or a new benchmark started.
+func (b *txBenchmark) finish(quit chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.running && quit == b.quit {
+		b.running = false
+		b.generating = false
+	}
+}
+
+func (b *txBenchmark) sentTxs(txs []*types.Transaction) {
+	now := time.Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, tx := range txs {
+		b.pending[tx.Hash()] = now
+	}
+	b.sent += uint64(len(txs))
+}
+
+// confirmedBlock records the txs of the block sent by the benchmark, and
+// returns the number of the txs still waited for.
+func (b *txBenchmark) confirmedBlock(block *types.MinorBlock) int {
+	now := time.Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, tx := range block.Transactions() {
+		hash := tx.Hash()
+		sent, ok := b.pending[hash]
+		if !ok {
+			continue
+		}
+		delete(b.pending, hash)
+		b.latencies = append(b.latencies, now.Sub(sent))
+		b.confirmed++
+		b.lastConfirmed = now
+	}
+	return len(b.pending)
+}
+
+// report returns the result of the last benchmark, or nil if none ran.
+func (b *txBenchmark) report(branch uint32) *rpc.TxBenchmarkReport {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.start.IsZero() {
+		return nil
+	}
+	end := time.Now()
+	if end.After(b.end) {
+		end = b.end
+	}
+	if b.lastConfirmed.After(end) {
+		end = b.lastConfirmed
+	}
+	latencies := make([]time.Duration, len(b.latencies))
+	copy(latencies, b.latencies)
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	percentile := func(p int) uint64 {
+		if len(latencies) == 0 {
+			return 0
+		}
+		return uint64(latencies[(len(latencies)-1)*p/100] / time.Millisecond)
+	}
+	return &rpc.TxBenchmarkReport{
+		Branch:     branch,
+		Running:    b.running,
+		TPS:        b.tps,
+		Start:      uint64(b.start.UnixNano() / int64(time.Millisecond)),
+		Elapsed:    uint64(end.Sub(b.start) / time.Millisecond),
+		Sent:       b.sent,
+		Confirmed:  b.confirmed,
+		LatencyP50: percentile(50),
+		LatencyP90: percentile(90),
+		LatencyP99: percentile(99),
+		LatencyMax: percentile(100),
+	}
+}
+
+// StartTxBenchmark starts sending the txs of the tx generators at the TPS of
+// the request for its duration, and tracks them until they are confirmed.
+func (s *ShardBackend) StartTxBenchmark(req rpc.GenTxRequest) error {
+	if !s.AccountForTPSReady() {
+		return errors.New("account for tps not ready")
+	}
+	if req.Duration == 0 || req.Duration > maxTxBenchmarkDuration {
+		return fmt.Errorf("benchmark duration must be between 1 and %d seconds", maxTxBenchmarkDuration)
+	}
+	if err := s.txBenchmark.acquire(); err != nil {
+		return err
+	}
+	if req.Seed != 0 {
+		for _, generator := range s.txGenerator {
+			generator.seed(req.Seed)
+		}
+	}
+	quit := s.txBenchmark.reset(req.TPS, req.Duration)
+	blockCh := make(chan core.MinorChainEvent, 64)
+	sub := s.MinorBlockChain.SubscribeChainEvent(blockCh)
+	log.Info(s.logInfo, "start tx benchmark tps", req.TPS, "duration", req.Duration, "XShardPercent", req.XShardPercent)
+	go s.runTxBenchmark(req, blockCh, sub.Err(), quit)
+	go func() {
+		<-quit
+		sub.Unsubscribe()
+	}()
+	return nil
+}
+
+func (s *ShardBackend) runTxBenchmark(req rpc.GenTxRequest, blockCh chan core.MinorChainEvent, errCh <-chan error, quit chan struct{}) {
+	var (
+		ticker  = time.NewTicker(txBenchmarkInterval)
+		end     = time.NewTimer(time.Duration(req.Duration) * time.Second)
+		perTick = float64(req.TPS) * txBenchmarkInterval.Seconds()
+		due     float64
+		index   int
+		done    <-chan time.Time
+	)
+	defer ticker.Stop()
+	defer end.Stop()
+	// whichever way it returns, the benchmark ends, finished first as stopping
+	// it forgets the quit channel
+	defer s.txBenchmark.stop(quit)
+	defer s.txBenchmark.finish(quit)
+	for {
+		select {
+		case <-ticker.C:
+			if done != nil {
+				// ticked before the end
+				break
+			}
+			due += perTick
+			count := int(due)
+			due -= float64(count)
+			txs := make([]*types.Transaction, 0, count)
+			for len(txs) < count {
+				tx, err := s.txGenerator[index%len(s.txGenerator)].next(int(req.XShardPercent), req.Tx)
+				index++
+				if err != nil {
+					log.Error(s.logInfo, "tx benchmark err", err)
+					break
+				}
+				txs = append(txs, tx)
+			}
+			if len(txs) > 0 {
+				s.addTxBenchmarkTxs(txs)
+			}
+
+		case <-end.C:
+			ticker.Stop()
+			s.txBenchmark.finish(quit)
+			log.Info(s.logInfo, "finish tx benchmark tps", req.TPS, "duration", req.Duration)
+			done = time.After(txBenchmarkGrace)
+
+		case ev := <-blockCh:
+			if s.txBenchmark.confirmedBlock(ev.Block) == 0 && done != nil {
+				return
+			}
+
+		case <-done:
+			return
+
+		case <-errCh:
+			return
+
+		case <-quit:
+			return
+		}
+	}
+}
+
+// addTxBenchmarkTxs adds the txs to the pool, and tracks the ones accepted.
+func (s *ShardBackend) addTxBenchmarkTxs(txs []*types.Transaction) {
+	errList := s.MinorBlockChain.AddTxList(txs)
+	added := make([]*types.Transaction, 0, len(txs))
+	for i, err := range errList {
+		if err != nil {
+			log.Debug(s.logInfo, "tx benchmark tx rejected", err)
+			continue
+		}
+		added = append(added, txs[i])
+	}
+	s.txBenchmark.sentTxs(added)
+	s.broadcastTxList(added)
+}
+
+// GetTxBenchmarkReport returns the result of the last tx benchmark of the
+// shard, or nil if none ran.
+func (s *ShardBackend) GetTxBenchmarkReport() *rpc.TxBenchmarkReport {
+	return s.txBenchmark.report(s.branch.Value)
+}
@@ -0,0 +1,94 @@
+package shard
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func newBenchmarkTx(nonce uint64) *types.Transaction {
+	evmTx := types.NewEvmTransaction(nonce, common.Address{}, big.NewInt(1), 21000, big.NewInt(1),
+		0, 0, 3, 0, nil, 35760, 35760)
+	return &types.Transaction{TxType: types.EvmTx, EvmTx: evmTx}
+}
+
+func TestTxBenchmark(t *testing.T) {
+	var b txBenchmark
+	assert.Nil(t, b.report(1))
+
+	assert.NoError(t, b.acquire())
+	assert.Equal(t, errTxGenerating, b.acquire())
+	quit := b.reset(10, 60)
+
+	txs := make([]*types.Transaction, 4)
+	for i := range txs {
+		txs[i] = newBenchmarkTx(uint64(i))
+	}
+	b.sentTxs(txs)
+	other := newBenchmarkTx(100)
+	block := types.NewMinorBlock(&types.MinorBlockHeader{}, &types.MinorBlockMeta{},
+		[]*types.Transaction{txs[0], txs[1], other}, nil, nil)
+	assert.Equal(t, 2, b.confirmedBlock(block))
+
+	report := b.report(1)
+	assert.Equal(t, uint32(1), report.Branch)
+	assert.True(t, report.Running)
+	assert.Equal(t, uint32(10), report.TPS)
+	assert.Equal(t, uint64(4), report.Sent)
+	assert.Equal(t, uint64(2), report.Confirmed)
+	assert.True(t, report.LatencyP50 <= report.LatencyMax)
+
+	// a new benchmark waits until the txs are generated
+	b.finish(nil)
+	assert.True(t, b.report(1).Running)
+	b.finish(quit)
+	assert.False(t, b.report(1).Running)
+	block = types.NewMinorBlock(&types.MinorBlockHeader{}, &types.MinorBlockMeta{},
+		[]*types.Transaction{txs[2], txs[3]}, nil, nil)
+	assert.Equal(t, 0, b.confirmedBlock(block))
+	assert.Equal(t, uint64(4), b.report(1).Confirmed)
+
+	assert.NoError(t, b.acquire())
+	newQuit := b.reset(20, 60)
+	// finishing the last benchmark does not finish the new one
+	b.finish(quit)
+	assert.True(t, b.report(1).Running)
+	assert.Equal(t, errTxGenerating, b.acquire())
+	select {
+	case <-quit:
+	case <-time.After(time.Second):
+		t.Fatal("last benchmark not stopped")
+	}
+	// stopping the last benchmark does not stop the new one
+	b.stop(quit)
+	select {
+	case <-newQuit:
+		t.Fatal("new benchmark stopped")
+	default:
+	}
+	b.stop(nil)
+	<-newQuit
+	assert.Equal(t, uint64(0), b.report(1).Sent)
+}
+
+func TestTxBenchmarkLatencies(t *testing.T) {
+	var b txBenchmark
+	b.reset(100, 60)
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		b.latencies = append(b.latencies, time.Duration(100-i)*time.Millisecond)
+	}
+	b.lastConfirmed = start.Add(2 * time.Second)
+
+	report := b.report(0)
+	assert.Equal(t, uint64(50), report.LatencyP50)
+	assert.Equal(t, uint64(90), report.LatencyP90)
+	assert.Equal(t, uint64(99), report.LatencyP99)
+	assert.Equal(t, uint64(100), report.LatencyMax)
+	// the elapsed time runs until the last confirmation
+	assert.True(t, report.Elapsed >= 2000)
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"math/big"
 	"math/rand"
-	"sync"
 	"time"
 
 	"github.com/QuarkChain/goquarkchain/account"
@@ -22,8 +21,9 @@ import (
 type TxGenerator struct {
 	cfg             *config.QuarkChainConfig
 	fullShardId     uint32
+	index           int
 	accounts        []AccountWithPrivateKey
-	once            sync.Once
+	rand            *rand.Rand
 	lenAccounts     int
 	accountIndex    int
 	turn            uint64
@@ -60,8 +60,9 @@ func NewTxGenerator(genesisDir string, fullShardId uint32, cfg *config.QuarkChai
 		tgs[index] = &TxGenerator{
 			cfg:             cfg,
 			fullShardId:     fullShardId,
+			index:           index,
 			accounts:        accounts[index*interval : (index+1)*interval],
-			once:            sync.Once{},
+			rand:            rand.New(rand.NewSource(time.Now().UnixNano() + int64(index))),
 			lenAccounts:     interval,
 			accountIndex:    0,
 			turn:            0,
@@ -80,10 +81,14 @@ func NewTxGenerator(genesisDir string, fullShardId uint32, cfg *config.QuarkChai
 }
 
 func (t *TxGenerator) random(digit int) int {
-	t.once.Do(func() {
-		rand.Seed(time.Now().UnixNano())
-	})
-	return rand.Int() % digit
+	return t.rand.Int() % digit
+}
+
+// seed seeds the random recipients, cross-shard targets and values of the
+// txs, so the generators of the same shard and index repeat them from the
+// same accounts and nonces.
+func (t *TxGenerator) seed(seed uint64) {
+	t.rand = rand.New(rand.NewSource(int64(seed) + int64(t.fullShardId)<<8 + int64(t.index)))
 }
 
 func (t *TxGenerator) sign(evmTx *types.EvmTransaction, key *ecdsa.PrivateKey) (*types.EvmTransaction, error) {
@@ -101,24 +106,20 @@ func (t *TxGenerator) Generate(genTxs rpc.GenTxRequest, addTxList func(txs []*ty
 		index         = 0
 	)
 	// return err if accounts is empty.
-	if t.accounts == nil {
+	if len(t.accounts) == 0 {
 		return fmt.Errorf("accounts is empty, can't create transactions")
 	}
 	if numTx == 0 {
 		return fmt.Errorf("create txs operation, numTx is zero")
 	}
 	log.Info("Start Generating transactions", "tx count", numTx, "cross-shard tx count", xShardPercent)
-	for t.accountIndex < t.lenAccounts {
-		if total >= numTx {
-			break
-		}
-
-		tx, err := t.createTransaction(t.accounts[t.accountIndex].privateKey, t.turn, xShardPercent, genTxs.Tx)
+	for total < numTx {
+		tx, err := t.next(xShardPercent, genTxs.Tx)
 		if err != nil {
 			continue
 		}
 		total++
-		txList[index] = &types.Transaction{TxType: types.EvmTx, EvmTx: tx}
+		txList[index] = tx
 		index++
 
 		if index >= batchScale {
@@ -128,12 +129,6 @@ func (t *TxGenerator) Generate(genTxs rpc.GenTxRequest, addTxList func(txs []*ty
 			index = 0
 			txList = make([]*types.Transaction, batchScale)
 		}
-
-		t.accountIndex++
-		if t.accountIndex == t.lenAccounts {
-			t.turn++
-			t.accountIndex = 0
-		}
 	}
 
 	if len(txList) != 0 {
@@ -146,6 +141,21 @@ func (t *TxGenerator) Generate(genTxs rpc.GenTxRequest, addTxList func(txs []*ty
 	return nil
 }
 
+// next creates the tx of the next account, the accounts taking turns with
+// the turn as the nonce.
+func (t *TxGenerator) next(xShardPercent int, sampleTx *types.Transaction) (*types.Transaction, error) {
+	tx, err := t.createTransaction(t.accounts[t.accountIndex].privateKey, t.turn, xShardPercent, sampleTx)
+	if err != nil {
+		return nil, err
+	}
+	t.accountIndex++
+	if t.accountIndex == t.lenAccounts {
+		t.turn++
+		t.accountIndex = 0
+	}
+	return &types.Transaction{TxType: types.EvmTx, EvmTx: tx}, nil
+}
+
 func (t *TxGenerator) createTransaction(prvKey *ecdsa.PrivateKey, nonce uint64,
 	xShardPercent int, sampleTx *types.Transaction) (*types.EvmTransaction, error) {
 	var (
@@ -176,6 +186,10 @@ func (t *TxGenerator) createTransaction(prvKey *ecdsa.PrivateKey, nonce uint64,
 	if fromFullShardKey != toFullShardKey {
 		gasLimit = params.DefaultCrossShardTxGasLimit.Uint64()
 	}
+	if len(sampleTx.EvmTx.Data()) > 0 && sampleTx.EvmTx.Gas() > gasLimit {
+		// contract calls take the gas of the sample tx
+		gasLimit = sampleTx.EvmTx.Gas()
+	}
 	gasPrice := new(big.Int).SetUint64(1000000000)
 	evmTx := types.NewEvmTransaction(nonce, recipient, value, gasLimit,
 		gasPrice, fromFullShardKey, toFullShardKey, t.cfg.NetworkID, 0, sampleTx.EvmTx.Data(), qkcCommon.TokenIDEncode("QKC"), qkcCommon.TokenIDEncode("QKC"))
@@ -171,6 +171,53 @@ func (api *PublicFilterAPI) Logs(ctx context.Context, crit rpc.FilterQuery, full
 	return rpcSub, nil
 }
 
+// Reorgs creates a subscription that fires for every reorg of the shard with the
+// replaced blocks and the dropped txs, and of the root chain with the replaced
+// root blocks and the minor blocks no longer confirmed, so that the clients can
+// roll back.
+func (api *PublicFilterAPI) Reorgs(ctx context.Context, fullShardId hexutil.Uint) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		reorgs := make(chan interface{}, filters.ReorgChanSize)
+		reorgsSub := api.events.SubscribeReorgs(reorgs, uint32(fullShardId))
+
+		for {
+			select {
+			case reorg := <-reorgs:
+				var (
+					data map[string]interface{}
+					err  error
+				)
+				switch ev := reorg.(type) {
+				case core.MinorChainReorgEvent:
+					data, err = encoder.MinorChainReorgEncoder(ev.CommonBlock, ev.OldChain, ev.NewChain, ev.DroppedTxs)
+				case core.RootChainReorgEvent:
+					data = encoder.RootChainReorgEncoder(ev.CommonBlock, ev.OldChain, ev.NewChain, ev.DroppedHeaders)
+				}
+				if err != nil {
+					log.Error("encode reorg error", "err", err)
+				} else {
+					notifier.Notify(rpcSub.ID, data)
+				}
+			case <-rpcSub.Err():
+				reorgsSub.Unsubscribe()
+				return
+			case <-notifier.Closed():
+				reorgsSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
 func (api *PublicFilterAPI) Syncing(ctx context.Context, fullShardId hexutil.Uint) (*rpc.Subscription, error) {
 	notifier, supported := rpc.NotifierFromContext(ctx)
 	if !supported {
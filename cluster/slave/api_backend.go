@@ -1,22 +1,31 @@
 package slave
 
 import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"math/big"
 
 	"github.com/QuarkChain/goquarkchain/account"
 	"github.com/QuarkChain/goquarkchain/cluster/rpc"
 	"github.com/QuarkChain/goquarkchain/cluster/shard"
 	"github.com/QuarkChain/goquarkchain/cluster/slave/filters"
+	qsync "github.com/QuarkChain/goquarkchain/cluster/sync"
 	qcom "github.com/QuarkChain/goquarkchain/common"
 	"github.com/QuarkChain/goquarkchain/consensus"
+	"github.com/QuarkChain/goquarkchain/core"
 	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/core/vm"
 	"github.com/QuarkChain/goquarkchain/p2p"
 	qrpc "github.com/QuarkChain/goquarkchain/rpc"
+	"github.com/QuarkChain/goquarkchain/serialize"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -43,18 +52,32 @@ func (s *SlaveBackend) GetUnconfirmedHeaderList() ([]*rpc.HeadersInfo, error) {
 }
 
 func (s *SlaveBackend) AddRootBlock(block *types.RootBlock) (switched bool, err error) {
+	s.rootLock.Lock()
+	defer s.rootLock.Unlock()
+	s.setRootTip(block)
 	switched = false
+	paused := false
 	for _, shard := range s.shards {
-		if switched, err = shard.AddRootBlock(block); err != nil {
+		// the other shards add the root block, the master adds it again
+		// once the paused shards are resumed
+		if switched, err = shard.AddRootBlock(block); err == rpc.ErrShardPaused {
+			paused = true
+		} else if err != nil {
 			return false, err
 		}
 	}
+	if paused {
+		return false, rpc.ErrShardPaused
+	}
 	return switched, nil
 }
 
 // Create shards based on GENESIS config and root block height if they have
 // not been created yet.
 func (s *SlaveBackend) CreateShards(rootBlock *types.RootBlock, forceInit bool) (err error) {
+	s.rootLock.Lock()
+	s.setRootTip(rootBlock)
+	s.rootLock.Unlock()
 	fullShardList := s.GetFullShardList()
 	var g errgroup.Group
 	for _, id := range fullShardList {
@@ -70,16 +93,25 @@ func (s *SlaveBackend) CreateShards(rootBlock *types.RootBlock, forceInit bool)
 		g.Go(func() error {
 			shardCfg := s.clstrCfg.Quarkchain.GetShardConfigByFullShardID(id)
 			if rootBlock.Number() >= shardCfg.Genesis.RootHeight {
+				// streaming the database takes long, the shard is created
+				// once it is restored
+				if s.startBootstrap(id, rootBlock) {
+					return nil
+				}
 				shard, err := shard.New(s.ctx, rootBlock, s.connManager, s.clstrCfg, id)
 				if err != nil {
 					log.Error("Failed to create shard", "slave id", s.config.ID, "shard id", shardCfg.ShardID, "err", err)
 					return err
 				}
+				shard.SetScheduler(s.scheduler)
 				s.addShard(id, shard)
 				if err = shard.InitFromRootBlock(rootBlock); err != nil {
 					shard.Stop()
 					return err
 				}
+				if s.isMining() {
+					shard.SetMining(true)
+				}
 			}
 			return nil
 		})
@@ -94,6 +126,14 @@ func (s *SlaveBackend) CreateShards(rootBlock *types.RootBlock, forceInit bool)
 	return nil
 }
 
+// setRootTip sets the root block the shards bootstrapped in the background are
+// created at, unless it is older, e.g. added by the resync of a shard.
+func (s *SlaveBackend) setRootTip(block *types.RootBlock) {
+	if s.rootTip == nil || block.Number() >= s.rootTip.Number() {
+		s.rootTip = block
+	}
+}
+
 func (s *SlaveBackend) AddBlockListForSync(mHashList []common.Hash, peerId string, branch uint32) (*rpc.ShardStatus, error) {
 	shard, ok := s.shards[branch]
 	if !ok {
@@ -127,7 +167,11 @@ func (s *SlaveBackend) AddBlockListForSync(mHashList []common.Hash, peerId strin
 		if len(bList) != hLen {
 			return nil, errors.New("Failed to add minor blocks for syncing root block: length of downloaded block list is incorrect")
 		}
-		if _, err := shard.AddBlockListForSync(bList); err != nil { //TODO?need fix?
+		// processed on the workers shared by the shards
+		if err := s.scheduler.Run(branch, func() error {
+			_, err := shard.AddBlockListForSync(bList)
+			return err
+		}); err != nil { //TODO?need fix?
 			return nil, err
 		}
 		hashList = hashList[hLen:]
@@ -156,7 +200,7 @@ func (s *SlaveBackend) AddTx(tx *types.Transaction) (err error) {
 	return ErrMsg("AddTx")
 }
 
-func (s *SlaveBackend) AddTxList(peerID string, branch uint32, txs []*types.Transaction) error {
+func (s *SlaveBackend) AddTxList(ctx context.Context, peerID string, branch uint32, txs []*types.Transaction) error {
 	if len(txs) == 0 {
 		return nil
 	}
@@ -176,16 +220,19 @@ func (s *SlaveBackend) AddTxList(peerID string, branch uint32, txs []*types.Tran
 			trans = append(trans, txs[idx])
 		}
 	}
-	go func() {
+	if err := s.scheduler.Go(branch, func() {
 		if err := s.connManager.BroadcastTransactions(peerID, branch, trans); err != nil {
-			log.Error(s.logInfo, "failed to boadcasttransactions in AddTxList func", "err", err)
+			qrpc.LoggerFromContext(ctx).Error(s.logInfo, "failed to boadcasttransactions in AddTxList func", "err", err)
 		}
-	}()
+	}); err != nil {
+		qrpc.LoggerFromContext(ctx).Warn(s.logInfo, "failed to schedule the broadcast of transactions", "err", err)
+	}
 
 	return nil
 }
 
-func (s *SlaveBackend) ExecuteTx(tx *types.Transaction, address *account.Address, height *uint64) ([]byte, error) {
+func (s *SlaveBackend) ExecuteTx(ctx context.Context, tx *types.Transaction, address *account.Address, height *uint64,
+	overrides []*rpc.AccountOverride) ([]byte, error) {
 	fromShardSize, err := s.clstrCfg.Quarkchain.GetShardSizeByChainId(tx.EvmTx.FromChainID())
 	if err != nil {
 		return nil, err
@@ -194,45 +241,70 @@ func (s *SlaveBackend) ExecuteTx(tx *types.Transaction, address *account.Address
 		return nil, err
 	}
 	if shard, ok := s.shards[tx.EvmTx.FromFullShardId()]; ok {
-		return shard.MinorBlockChain.ExecuteTx(tx, address, height)
+		return shard.MinorBlockChain.ExecuteTx(ctx, tx, address, height, overrides)
 	}
 	return nil, ErrMsg("ExecuteTx")
 }
 
-func (s *SlaveBackend) GetAccountData(address *account.Address, height *uint64) ([]*rpc.AccountBranchData, error) {
-	var (
-		results = make([]*rpc.AccountBranchData, 0)
-		bt      []byte
-		err     error
-	)
+func (s *SlaveBackend) CreateAccessList(tx *types.Transaction, address *account.Address,
+	height *uint64) (types.AccessList, uint64, bool, error) {
+	fromShardSize, err := s.clstrCfg.Quarkchain.GetShardSizeByChainId(tx.EvmTx.FromChainID())
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if err := tx.EvmTx.SetFromShardSize(fromShardSize); err != nil {
+		return nil, 0, false, err
+	}
+	if shard, ok := s.shards[tx.EvmTx.FromFullShardId()]; ok {
+		return shard.MinorBlockChain.CreateAccessList(tx, address, height)
+	}
+	return nil, 0, false, ErrMsg("CreateAccessList")
+}
+
+// GetAccountData returns the data of the account in each shard, the shards
+// failed to be read are returned with their error.
+func (s *SlaveBackend) GetAccountData(ctx context.Context, address *account.Address, height *uint64) ([]*rpc.AccountBranchData, error) {
+	results := make([]*rpc.AccountBranchData, 0, len(s.shards))
 	for branch, shard := range s.shards {
-		data := rpc.AccountBranchData{
-			Branch: branch,
-		}
-		hash, err := shard.MinorBlockChain.GetHashByHeight(height)
+		data, err := getAccountBranchData(shard.MinorBlockChain, address.Recipient, height)
 		if err != nil {
-			return nil, err
-		}
-		if data.TransactionCount, err = shard.MinorBlockChain.GetTransactionCount(address.Recipient, &hash); err != nil {
-			return nil, err
+			qrpc.LoggerFromContext(ctx).Warn("Failed to get account data", "branch", branch, "height", height, "err", err)
+			data = &rpc.AccountBranchData{Error: err.Error()}
 		}
-		tokenBalances, err := shard.MinorBlockChain.GetBalance(address.Recipient, &hash)
-		if err != nil {
-			return nil, err
-		}
-		data.Balance = tokenBalances.Copy()
-		if bt, err = shard.MinorBlockChain.GetCode(address.Recipient, &hash); err != nil {
-			return nil, err
-		}
-		data.IsContract = len(bt) > 0
-		mineableBlocks, mined, err := shard.MinorBlockChain.GetMiningInfo(address.Recipient, tokenBalances)
-		if err == nil {
-			data.MinedBlocks = mined
-			data.PoswMineableBlocks = mineableBlocks
-		}
-		results = append(results, &data)
+		data.Branch = branch
+		results = append(results, data)
+	}
+	return results, nil
+}
+
+func getAccountBranchData(chain *core.MinorBlockChain, recipient account.Recipient, height *uint64) (*rpc.AccountBranchData, error) {
+	var (
+		data = new(rpc.AccountBranchData)
+		bt   []byte
+	)
+	hash, err := chain.GetHashByHeight(height)
+	if err != nil {
+		return nil, err
+	}
+	if data.TransactionCount, err = chain.GetTransactionCount(recipient, &hash); err != nil {
+		return nil, err
+	}
+	tokenBalances, err := chain.GetBalance(recipient, &hash)
+	if err != nil {
+		return nil, err
+	}
+	data.Balance = tokenBalances.Copy()
+	if bt, err = chain.GetCode(recipient, &hash); err != nil {
+		return nil, err
+	}
+	data.IsContract = len(bt) > 0
+	mineableBlocks, mined, err := chain.GetMiningInfo(recipient, tokenBalances)
+	if err == nil {
+		data.MinedBlocks = mined
+		data.PoswMineableBlocks = mineableBlocks
 	}
-	return results, err
+	data.PendingTxCount, data.QueuedTxCount = chain.GetPoolTxCount(recipient)
+	return data, nil
 }
 
 func (s *SlaveBackend) GetMinorBlock(hash common.Hash, height *uint64, branch uint32) (*types.MinorBlock, error) {
@@ -269,6 +341,86 @@ func (s *SlaveBackend) GetTransactionReceipt(txHash common.Hash, branch uint32)
 	return nil, 0, nil, ErrMsg("GetTransactionReceipt")
 }
 
+// GetMinorBlockReceipts returns the receipts of the txs of the minor block.
+func (s *SlaveBackend) GetMinorBlockReceipts(blockHash common.Hash, branch uint32) (types.Receipts, error) {
+	if shard, ok := s.shards[branch]; ok {
+		if shard.MinorBlockChain.GetMinorBlock(blockHash) == nil {
+			return nil, errors.New("minor block not found")
+		}
+		return shard.MinorBlockChain.GetReceiptsByHash(blockHash), nil
+	}
+	return nil, ErrMsg("GetMinorBlockReceipts")
+}
+
+// TraceBlock writes the traces of the txs of the minor block to w in JSON, one
+// per line, see MinorBlockChain.TraceBlock.
+func (s *SlaveBackend) TraceBlock(ctx context.Context, blockHash common.Hash, branch uint32, config *rpc.TraceConfig, w io.Writer) error {
+	shard, ok := s.shards[branch]
+	if !ok {
+		return ErrMsg("TraceBlock")
+	}
+	logCfg := &vm.LogConfig{
+		DisableMemory:  config.DisableMemory,
+		DisableStack:   config.DisableStack,
+		DisableStorage: config.DisableStorage,
+		Limit:          int(config.Limit),
+	}
+	enc := json.NewEncoder(w)
+	return shard.MinorBlockChain.TraceBlock(ctx, blockHash, logCfg, func(trace *core.TxTrace) error {
+		return enc.Encode(trace)
+	})
+}
+
+// ExportBlocks writes the canonical minor blocks of the shard from the height
+// up to w, at most count of them, as rpc.ExportedBlock records each prefixed
+// by its size in uvarint.
+func (s *SlaveBackend) ExportBlocks(ctx context.Context, branch uint32, from, count uint64, includeReceipts bool, w io.Writer) error {
+	shard, ok := s.shards[branch]
+	if !ok {
+		return ErrMsg("ExportBlocks")
+	}
+	var size [binary.MaxVarintLen64]byte
+	for number := from; number < from+count; number++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		block, ok := shard.MinorBlockChain.GetBlockByNumber(number).(*types.MinorBlock)
+		if !ok || block == nil {
+			return nil
+		}
+		exported := &rpc.ExportedBlock{Number: number, Hash: block.Hash()}
+		var err error
+		if exported.Block, err = serialize.SerializeToBytes(block); err != nil {
+			return err
+		}
+		if includeReceipts {
+			if exported.Receipts, err = rlp.EncodeToBytes(shard.MinorBlockChain.GetReceiptsByHash(block.Hash())); err != nil {
+				return err
+			}
+		}
+		data, err := serialize.SerializeToBytes(exported)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(size[:binary.PutUvarint(size[:], uint64(len(data)))]); err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SimulateBundle applies the txs in order on the state of the minor block at
+// the height, see MinorBlockChain.SimulateBundle.
+func (s *SlaveBackend) SimulateBundle(ctx context.Context, txs []*types.Transaction, branch uint32, height *uint64) ([]*rpc.BundleTxResult, error) {
+	if shard, ok := s.shards[branch]; ok {
+		return shard.MinorBlockChain.SimulateBundle(ctx, txs, height)
+	}
+	return nil, ErrMsg("SimulateBundle")
+}
+
 func (s *SlaveBackend) GetTransactionListByAddress(address *account.Address, transferTokenID *uint64, start []byte, limit uint32) ([]*rpc.TransactionDetail, []byte, error) {
 	branch, err := s.getBranch(address)
 	if err != nil {
@@ -287,20 +439,20 @@ func (s *SlaveBackend) GetAllTx(branch account.Branch, start []byte, limit uint3
 	return nil, nil, ErrMsg("GetAllTx")
 }
 
-func (s *SlaveBackend) GetLogs(args *qrpc.FilterQuery) ([]*types.Log, error) {
+func (s *SlaveBackend) GetLogs(ctx context.Context, args *qrpc.FilterQuery) ([]*types.Log, error) {
 	if shard, ok := s.shards[args.FullShardId]; ok {
-		return shard.GetLogsByFilterQuery(args)
+		return shard.GetLogsByFilterQuery(ctx, args)
 	}
 	return nil, ErrMsg("GetLogs")
 }
 
-func (s *SlaveBackend) EstimateGas(tx *types.Transaction, address *account.Address) (uint32, error) {
+func (s *SlaveBackend) EstimateGas(ctx context.Context, tx *types.Transaction, address *account.Address) (uint32, error) {
 	fullShardId, err := s.clstrCfg.Quarkchain.GetFullShardIdByFullShardKey(address.FullShardKey)
 	if err != nil {
 		return 0, err
 	}
 	if shrd, ok := s.shards[fullShardId]; ok {
-		return shrd.MinorBlockChain.EstimateGas(tx, *address)
+		return shrd.MinorBlockChain.EstimateGas(ctx, tx, *address)
 	}
 	return 0, ErrMsg("EstimateGas")
 }
@@ -376,6 +528,7 @@ func (s *SlaveBackend) GetMinorBlockListByHashList(mHashList []common.Hash, bran
 	}
 	var (
 		minorList = make([]*types.MinorBlock, 0, len(mHashList))
+		size      common.StorageSize
 	)
 
 	if len(mHashList) > 2*MINOR_BLOCK_BATCH_SIZE {
@@ -383,11 +536,15 @@ func (s *SlaveBackend) GetMinorBlockListByHashList(mHashList []common.Hash, bran
 	}
 
 	for _, hash := range mHashList {
+		if size >= qsync.BlockListSoftResponseLimit {
+			break
+		}
 		block, err := shrd.GetMinorBlock(hash, nil)
 		if err != nil {
 			return nil, err
 		}
 		minorList = append(minorList, block)
+		size += block.Size()
 	}
 	return minorList, nil
 }
@@ -490,9 +647,14 @@ func (s *SlaveBackend) HandleNewTip(req *rpc.HandleNewTipRequest) error {
 	return ErrMsg("HandleNewTip")
 }
 
+// NewMinorBlock processes the block of the shard broadcast by the peer on the
+// workers shared by the shards.
 func (s *SlaveBackend) NewMinorBlock(peerId string, block *types.MinorBlock) error {
-	if shard, ok := s.shards[block.Branch().Value]; ok {
-		return shard.NewMinorBlock(peerId, block)
+	branch := block.Branch().Value
+	if shard, ok := s.shards[branch]; ok {
+		return s.scheduler.Run(branch, func() error {
+			return shard.NewMinorBlock(peerId, block)
+		})
 	}
 	return ErrMsg("NewMinorBlock")
 }
@@ -503,6 +665,14 @@ func (s *SlaveBackend) GenTx(genTxs rpc.GenTxRequest) error {
 			return errors.New("account for tps not ready")
 		}
 	}
+	if genTxs.TPS > 0 {
+		for _, shrd := range s.shards {
+			if err := shrd.StartTxBenchmark(genTxs); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
 	for _, shrd := range s.shards {
 		sd := shrd
 		go sd.GenTx(genTxs)
@@ -510,12 +680,34 @@ func (s *SlaveBackend) GenTx(genTxs rpc.GenTxRequest) error {
 	return nil
 }
 
+// GetTxBenchmarkReports returns the results of the last tx benchmark of the
+// shards.
+func (s *SlaveBackend) GetTxBenchmarkReports() []*rpc.TxBenchmarkReport {
+	reports := make([]*rpc.TxBenchmarkReport, 0, len(s.shards))
+	for _, shrd := range s.shards {
+		if report := shrd.GetTxBenchmarkReport(); report != nil {
+			reports = append(reports, report)
+		}
+	}
+	return reports
+}
+
 func (s *SlaveBackend) SetMining(mining bool) {
+	s.lock.Lock()
+	s.mining = mining
+	s.lock.Unlock()
 	for _, shrd := range s.shards {
 		shrd.SetMining(mining)
 	}
 }
 
+// isMining returns whether the shards created from now on shall mine.
+func (s *SlaveBackend) isMining() bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.mining
+}
+
 func (s *SlaveBackend) EventMux() *event.TypeMux {
 	return s.eventMux
 }
@@ -541,6 +733,172 @@ func (s *SlaveBackend) CheckMinorBlocksInRoot(rootBlock *types.RootBlock) error
 	return nil
 }
 
+func (s *SlaveBackend) GetStaleBlocks(branch uint32, limit uint32) (uint64, []*rpc.StaleBlock, error) {
+	if shard, ok := s.shards[branch]; ok {
+		total, blocks := shard.MinorBlockChain.GetStaleBlocks(limit)
+		return total, blocks, nil
+	}
+	return 0, nil, ErrMsg("GetStaleBlocks")
+}
+
+func (s *SlaveBackend) GetBlockProfiles(branch uint32, limit uint32) ([]*rpc.BlockProfile, error) {
+	if shard, ok := s.shards[branch]; ok {
+		return shard.MinorBlockChain.GetBlockProfiles(limit), nil
+	}
+	return nil, ErrMsg("GetBlockProfiles")
+}
+
+func (s *SlaveBackend) ReplayBlocks(branch uint32, first, last uint64) ([]*rpc.BlockReplayResult, error) {
+	if shard, ok := s.shards[branch]; ok {
+		return shard.MinorBlockChain.ReplayBlocks(first, last)
+	}
+	return nil, ErrMsg("ReplayBlocks")
+}
+
+func (s *SlaveBackend) BackupShard(branch uint32, path string) (*rpc.BackupShardResponse, error) {
+	if shard, ok := s.shards[branch]; ok {
+		return shard.Backup(path)
+	}
+	return nil, ErrMsg("BackupShard")
+}
+
+// GetShardRootTips returns the root blocks the shards of the slave were last
+// updated to.
+func (s *SlaveBackend) GetShardRootTips() []*rpc.ShardRootTip {
+	tips := make([]*rpc.ShardRootTip, 0, len(s.shards))
+	for branch, shard := range s.shards {
+		rootTip := shard.MinorBlockChain.GetRootTip()
+		tips = append(tips, &rpc.ShardRootTip{Branch: branch, Number: rootTip.Number, Hash: rootTip.Hash(), Restored: shard.Restored()})
+	}
+	return tips
+}
+
+func (s *SlaveBackend) ResendXshardTxList(branch uint32, hashList []common.Hash) error {
+	if shard, ok := s.shards[branch]; ok {
+		return shard.ResendXshardTxList(hashList)
+	}
+	return ErrMsg("ResendXshardTxList")
+}
+
+// GetXShardQueues returns the queues of the cross-shard deposits not applied by
+// the shards of the slave yet.
+func (s *SlaveBackend) GetXShardQueues() []*rpc.XShardQueue {
+	queues := make([]*rpc.XShardQueue, 0, len(s.shards))
+	for _, shard := range s.shards {
+		queues = append(queues, shard.MinorBlockChain.GetXShardQueue())
+	}
+	return queues
+}
+
+// ShardMaintenance pauses or resumes the shard, or compacts or reindexes its
+// database while it's paused.
+func (s *SlaveBackend) ShardMaintenance(branch uint32, action string) (*rpc.ShardMaintenanceResponse, error) {
+	shard, ok := s.shards[branch]
+	if !ok {
+		return nil, ErrMsg("ShardMaintenance")
+	}
+	var (
+		rsp = new(rpc.ShardMaintenanceResponse)
+		err error
+	)
+	switch action {
+	case rpc.MaintenancePause:
+		err = shard.Pause()
+	case rpc.MaintenanceResume:
+		err = shard.Resume()
+	case rpc.MaintenanceCompact:
+		err = shard.Compact()
+	case rpc.MaintenanceReindex:
+		rsp.ReindexedBlocks, err = shard.Reindex()
+	default:
+		err = fmt.Errorf("unknown maintenance action %q", action)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rsp.Paused = shard.IsPaused()
+	return rsp, nil
+}
+
+// GetStateAvailability tells whether the state of the canonical block of the
+// shard at the height is available, or else from which block it can be
+// regenerated.
+func (s *SlaveBackend) GetStateAvailability(branch uint32, number uint64) (*rpc.StateAvailability, error) {
+	if shard, ok := s.shards[branch]; ok {
+		return shard.MinorBlockChain.StateAvailability(number)
+	}
+	return nil, ErrMsg("GetStateAvailability")
+}
+
+// RegenerateState starts writing the missing state of the canonical block of
+// the shard at the height by replaying the blocks from the nearest state
+// available, and returns its progress.
+func (s *SlaveBackend) RegenerateState(branch uint32, number uint64) (*rpc.RegenerateStateResponse, error) {
+	if shard, ok := s.shards[branch]; ok {
+		return shard.MinorBlockChain.RegenerateState(number)
+	}
+	return nil, ErrMsg("RegenerateState")
+}
+
+// GetPendingAccountData returns the account data of the address on the pending
+// state of its shard, with the txs of the pool applied.
+func (s *SlaveBackend) GetPendingAccountData(address *account.Address) (*rpc.AccountBranchData, error) {
+	branch, err := s.getBranch(address)
+	if err != nil {
+		return nil, err
+	}
+	if shard, ok := s.shards[branch.Value]; ok {
+		return shard.MinorBlockChain.GetPendingAccountData(address.Recipient)
+	}
+	return nil, ErrMsg("GetPendingAccountData")
+}
+
+// ExecutePendingTx executes the tx on the pending state of its shard, with the
+// txs of the pool applied.
+func (s *SlaveBackend) ExecutePendingTx(ctx context.Context, tx *types.Transaction, address *account.Address,
+	overrides []*rpc.AccountOverride) ([]byte, error) {
+	fromShardSize, err := s.clstrCfg.Quarkchain.GetShardSizeByChainId(tx.EvmTx.FromChainID())
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.EvmTx.SetFromShardSize(fromShardSize); err != nil {
+		return nil, err
+	}
+	if shard, ok := s.shards[tx.EvmTx.FromFullShardId()]; ok {
+		return shard.MinorBlockChain.ExecutePendingTx(ctx, tx, address, overrides)
+	}
+	return nil, ErrMsg("ExecutePendingTx")
+}
+
+func (s *SlaveBackend) GetBalanceHistory(address *account.Address, height *uint64) (*rpc.GetBalanceHistoryResponse, error) {
+	branch, err := s.getBranch(address)
+	if err != nil {
+		return nil, err
+	}
+	if shard, ok := s.shards[branch.Value]; ok {
+		balances, number, changed, err := shard.MinorBlockChain.GetBalanceHistory(address.Recipient, height)
+		if err != nil {
+			return nil, err
+		}
+		return &rpc.GetBalanceHistoryResponse{Branch: branch.Value, Height: number, ChangedHeight: changed, Balances: balances}, nil
+	}
+	return nil, ErrMsg("GetBalanceHistory")
+}
+
+func (s *SlaveBackend) WriteShardSnapshot(branch uint32, rootHash common.Hash, w io.Writer) error {
+	if shard, ok := s.shards[branch]; ok {
+		return shard.WriteSnapshot(rootHash, w)
+	}
+	return ErrMsg("WriteShardSnapshot")
+}
+
+func (s *SlaveBackend) GetUnreceivedXShardDeposits(branch uint32, limit uint32) ([]*rpc.UnreceivedXShardDeposit, error) {
+	if shard, ok := s.shards[branch]; ok {
+		return shard.MinorBlockChain.GetUnreceivedXShardDeposits(limit)
+	}
+	return nil, ErrMsg("GetUnreceivedXShardDeposits")
+}
+
 func (s *SlaveBackend) GetRootChainStakes(address account.Address, lastMinor common.Hash) (*big.Int,
 	*account.Recipient, error) {
 	for _, shrd := range s.shards {
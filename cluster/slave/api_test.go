@@ -50,6 +50,46 @@ func TestNewHeads(t *testing.T) {
 	}
 }
 
+func TestReorgs(t *testing.T) {
+	bak, err := newTestBackend()
+	assert.NoError(t, err)
+	defer bak.stop()
+
+	chanReorgs := make(chan map[string]interface{}, 10)
+	err = bak.subscribeEvent("reorgs", chanReorgs)
+	assert.NoError(t, err)
+
+	time.Sleep(500 * time.Millisecond)
+	ev := bak.createReorg(3)
+
+	select {
+	case re := <-chanReorgs:
+		assert.Equal(t, "0x3", re["depth"])
+		assert.Equal(t, ev.CommonBlock.Hash().Hex(), re["commonBlock"].(map[string]interface{})["hash"])
+		oldChain, newChain := re["oldChain"].([]interface{}), re["newChain"].([]interface{})
+		assert.Equal(t, len(ev.OldChain), len(oldChain))
+		assert.Equal(t, len(ev.NewChain), len(newChain))
+		assert.Equal(t, ev.OldChain[0].Hash().Hex(), oldChain[0].(map[string]interface{})["hash"])
+		assert.Equal(t, ev.NewChain[0].Hash().Hex(), newChain[0].(map[string]interface{})["hash"])
+	case <-time.After(10 * time.Second):
+		t.Error("reorg by subscribe is not received")
+	}
+
+	// the reorgs of the root chain too
+	rootEv := bak.createRootReorg()
+	select {
+	case re := <-chanReorgs:
+		assert.Equal(t, "root", re["chain"])
+		assert.Equal(t, "0x1", re["depth"])
+		assert.Equal(t, rootEv.CommonBlock.Hash().Hex(), re["commonBlock"].(map[string]interface{})["hash"])
+		assert.Equal(t, rootEv.OldChain[0].Hash().Hex(), re["oldChain"].([]interface{})[0].(map[string]interface{})["hash"])
+		assert.Equal(t, rootEv.NewChain[0].Hash().Hex(), re["newChain"].([]interface{})[0].(map[string]interface{})["hash"])
+		assert.Len(t, re["droppedMinorBlocks"], 1)
+	case <-time.After(10 * time.Second):
+		t.Error("root reorg by subscribe is not received")
+	}
+}
+
 func TestSyncing(t *testing.T) {
 	bak, err := newTestBackend()
 	assert.NoError(t, err)
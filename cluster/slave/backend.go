@@ -5,12 +5,17 @@ import (
 
 	"github.com/QuarkChain/goquarkchain/account"
 	"github.com/QuarkChain/goquarkchain/cluster/config"
+	"github.com/QuarkChain/goquarkchain/cluster/metrics"
+	qrpc "github.com/QuarkChain/goquarkchain/cluster/rpc"
 	"github.com/QuarkChain/goquarkchain/cluster/service"
 	"github.com/QuarkChain/goquarkchain/cluster/shard"
+	"github.com/QuarkChain/goquarkchain/core"
+	"github.com/QuarkChain/goquarkchain/core/types"
 	"github.com/QuarkChain/goquarkchain/core/vm"
 	"github.com/QuarkChain/goquarkchain/p2p"
 	"github.com/QuarkChain/goquarkchain/params"
 	"github.com/QuarkChain/goquarkchain/rpc"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/event"
 )
 
@@ -18,11 +23,35 @@ type SlaveBackend struct {
 	clstrCfg      *config.ClusterConfig
 	config        *config.SlaveConfig
 	fullShardList []uint32
+	genesisHash   common.Hash
 
 	connManager *ConnManager
+	// runs the background tasks of the shards on workers shared by them
+	scheduler *shard.Scheduler
 
-	lock   sync.RWMutex
+	lock sync.RWMutex
+	// replaced on change rather than modified, as it is read without lock
 	shards map[uint32]*shard.ShardBackend
+	// the new shards bootstrapped from snapshots in the background
+	bootstrapping map[uint32]bool
+	// the shards activated after genesis are created by later root blocks,
+	// so a slave may be initialized by the master without any shard
+	initialized bool
+	mining      bool
+
+	// serializes the root blocks added to the shards with the shards created
+	// in the background at rootTip, the latest root block added
+	rootLock sync.Mutex
+	rootTip  *types.RootBlock
+
+	configLoader func() (*config.ClusterConfig, error)
+	reloadLock   sync.Mutex
+
+	metricsReporter *metrics.Reporter // nil if the metrics are not reported
+	slowOps         *qrpc.SlowOpLog
+	// time of the latest block profile reported by shard, used by the
+	// metrics reporter only
+	reportedProfiles map[uint32]uint64
 
 	ctx      *service.ServiceContext
 	eventMux *event.TypeMux
@@ -31,16 +60,24 @@ type SlaveBackend struct {
 
 func New(ctx *service.ServiceContext, clusterCfg *config.ClusterConfig, cfg *config.SlaveConfig) (*SlaveBackend, error) {
 	slave := &SlaveBackend{
-		config:        cfg,
-		clstrCfg:      clusterCfg,
-		fullShardList: make([]uint32, 0),
-		shards:        make(map[uint32]*shard.ShardBackend),
-		ctx:           ctx,
-		eventMux:      ctx.EventMux,
-		logInfo:       "SlaveBackend",
+		config:           cfg,
+		clstrCfg:         clusterCfg,
+		fullShardList:    make([]uint32, 0),
+		shards:           make(map[uint32]*shard.ShardBackend),
+		bootstrapping:    make(map[uint32]bool),
+		reportedProfiles: make(map[uint32]uint64),
+		slowOps:          qrpc.NewSlowOpLog(cfg.ID),
+		ctx:              ctx,
+		eventMux:         ctx.EventMux,
+		logInfo:          "SlaveBackend",
 	}
 
 	slave.clstrCfg.Quarkchain.SetAllowedToken()
+	genesisHash, err := core.NewGenesis(slave.clstrCfg.Quarkchain).ClusterGenesisHash()
+	if err != nil {
+		return nil, err
+	}
+	slave.genesisHash = genesisHash
 	fullShardIds := slave.clstrCfg.Quarkchain.GetGenesisShardIds()
 	for _, id := range fullShardIds {
 		if !slave.coverShardId(id) {
@@ -51,6 +88,15 @@ func New(ctx *service.ServiceContext, clusterCfg *config.ClusterConfig, cfg *con
 
 	slave.connManager = NewToSlaveConnManager(slave.clstrCfg, slave)
 	slave.setPrecompiledContractsEnableTime(clusterCfg.Quarkchain.EnableEvmTimeStamp)
+	slave.setXShardCallEnableTime(clusterCfg.Quarkchain)
+	if slave.metricsReporter, err = metrics.NewReporter(clusterCfg.Monitoring, cfg.ID, slave.metricPoints); err != nil {
+		return nil, err
+	}
+	slave.slowOps.SetThreshold(clusterCfg.SlowRPCThreshold)
+	slave.slowOps.SetPeers(clusterCfg.SlaveList)
+	qrpc.SetLanes(clusterCfg)
+	qrpc.SetChunkSize(clusterCfg.RPCChunkSize)
+	slave.scheduler = shard.NewScheduler(clusterCfg.ShardWorkers, clusterCfg.ShardWorkersPerShard, clusterCfg.ShardTaskQueue)
 	return slave, nil
 }
 
@@ -62,6 +108,16 @@ func (s *SlaveBackend) setPrecompiledContractsEnableTime(enableEvmTime uint64) {
 	}
 }
 
+// setXShardCallEnableTime enables the cross-shard call precompiled contract from
+// its fork on. The precompiled contracts are enabled after their enable time.
+func (s *SlaveBackend) setXShardCallEnableTime(qkcCfg *config.QuarkChainConfig) {
+	enableTime := uint64(config.NeverActive)
+	if qkcCfg.XShardCallTimeStamp != 0 {
+		enableTime = qkcCfg.XShardCallTimeStamp - 1
+	}
+	vm.PrecompiledContractsByzantium[common.HexToAddress(vm.CrossShardCallAddr)].SetEnableTime(enableTime)
+}
+
 func (s *SlaveBackend) GetFullShardList() []uint32 {
 	return s.fullShardList
 }
@@ -83,10 +139,41 @@ func (s *SlaveBackend) getBranch(address *account.Address) (account.Branch, erro
 	return account.NewBranch(fullShardID), nil
 }
 
-func (s *SlaveBackend) addShard(id uint32, shard *shard.ShardBackend) {
+func (s *SlaveBackend) addShard(id uint32, shrd *shard.ShardBackend) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	shards := make(map[uint32]*shard.ShardBackend, len(s.shards)+1)
+	for fullShardId, other := range s.shards {
+		shards[fullShardId] = other
+	}
+	shards[id] = shrd
+	s.shards = shards
+}
+
+func (s *SlaveBackend) removeShard(id uint32) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
-	s.shards[id] = shard
+	shards := make(map[uint32]*shard.ShardBackend, len(s.shards))
+	for fullShardId, shrd := range s.shards {
+		if fullShardId != id {
+			shards[fullShardId] = shrd
+		}
+	}
+	s.shards = shards
+}
+
+// setInitialized marks the slave as initialized by the master info, after
+// which it answers the heartbeat.
+func (s *SlaveBackend) setInitialized() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.initialized = true
+}
+
+func (s *SlaveBackend) isInitialized() bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.initialized
 }
 
 func (s *SlaveBackend) GetConfig() *config.SlaveConfig {
@@ -121,15 +208,28 @@ func (s *SlaveBackend) APIs() []rpc.API {
 }
 
 func (s *SlaveBackend) Stop() error {
+	if s.metricsReporter != nil {
+		s.metricsReporter.Stop()
+	}
 	s.eventMux.Stop()
-	for target := range s.shards {
-		s.shards[target].Stop()
-		delete(s.shards, target)
+	// the shards bootstrapped in the background are not created from now on
+	s.rootLock.Lock()
+	s.rootTip = nil
+	s.rootLock.Unlock()
+	for _, shrd := range s.shards {
+		shrd.Stop()
 	}
+	s.lock.Lock()
+	s.shards = make(map[uint32]*shard.ShardBackend)
+	s.lock.Unlock()
+	s.scheduler.Stop()
 	s.connManager.Stop()
 	return nil
 }
 
 func (s *SlaveBackend) Init(srvr *p2p.Server) error {
+	if s.metricsReporter != nil {
+		s.metricsReporter.Start()
+	}
 	return nil
 }
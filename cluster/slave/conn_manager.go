@@ -18,6 +18,7 @@ import (
 type masterConn struct {
 	target string
 	client rpc.Client
+	caps   *rpc.Capabilities // sent by the master in the Ping handshake
 }
 
 type ConnManager struct {
@@ -44,7 +45,7 @@ func (s *ConnManager) AddConnectToSlave(info *rpc.SlaveInfo) bool {
 		target = fmt.Sprintf("%s:%d", info.Host, info.Port)
 	)
 
-	conn := NewToSlaveConn(target, string(info.Id), info.ChainMaskList)
+	conn := NewToSlaveConn(target, string(info.Id), info.ChainMaskList, s.slave.genesisHash, s.slave.slowOps)
 	log.Info("slave conn manager, add connect to slave", "add target", target)
 
 	// Tell the remote slave who I am.
@@ -88,12 +89,14 @@ func (s *ConnManager) BatchAddXshardTxList(fullShardId uint32, xshardReqs []*rpc
 	return g.Wait()
 }
 
-// Broadcast x-shard transactions to their recipient shards
+// Broadcast x-shard transactions to their recipient shards, sent directly to
+// the slaves running them, all at once.
 func (s *ConnManager) BroadcastXshardTxList(block *types.MinorBlock,
 	xshardTxList []*types.CrossShardTransactionDeposit, height uint32) error {
 	var (
 		hash      = block.Hash()
 		shardSize = len(s.qkcCfg.GetInitializedShardIdsBeforeRootHeight(height))
+		g         errgroup.Group
 	)
 	xshardTxListRequest, err := s.getBranchToAddXshardTxListRequest(hash, xshardTxList, height)
 	if err != nil {
@@ -104,18 +107,23 @@ func (s *ConnManager) BroadcastXshardTxList(block *types.MinorBlock,
 			if len(request.TxList) != 0 {
 				return fmt.Errorf("there shouldn't be xshard list for non-neighbor shard, actual branch: %d, target branch: %d", block.Branch().Value, branch.Value)
 			}
-			continue
+			delete(xshardTxListRequest, branch)
 		}
+	}
+	for branch, request := range xshardTxListRequest {
 		if shard, ok := s.slave.shards[branch.Value]; ok {
 			shard.MinorBlockChain.AddCrossShardTxListByMinorBlockHash(hash, types.CrossShardTransactionDepositList{TXList: request.TxList})
 		}
-		err := s.AddXshardTxList(branch.GetFullShardID(), request)
-		if err != nil {
-			log.Error("Failed to broadcast xshard transactions", "actual branch", block.Branch().Value, "target branch", branch.Value, "err", err)
+		branch, request := branch, request
+		g.Go(func() error {
+			err := s.AddXshardTxList(branch.GetFullShardID(), request)
+			if err != nil {
+				log.Error("Failed to broadcast xshard transactions", "actual branch", block.Branch().Value, "target branch", branch.Value, "err", err)
+			}
 			return err
-		}
+		})
 	}
-	return nil
+	return g.Wait()
 }
 
 func (s *ConnManager) BatchBroadcastXshardTxList(
@@ -195,6 +203,8 @@ func (s *ConnManager) getBranchToAddXshardTxListRequest(blockHash common.Hash,
 
 // TODO need to check
 func (s *ConnManager) addSlaveConnection(target string, conn *SlaveConn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	fullShardIdList := s.qkcCfg.GetGenesisShardIds()
 	for _, id := range fullShardIdList {
 		if conn.HasShard(id) {
@@ -204,6 +214,34 @@ func (s *ConnManager) addSlaveConnection(target string, conn *SlaveConn) {
 	s.slavesConn[target] = conn
 }
 
+// ModifySlaveTarget points the connection to the slave to its new endpoint,
+// which must answer the ping of the slave.
+func (s *ConnManager) ModifySlaveTarget(id, target string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for old, conn := range s.slavesConn {
+		if conn.id != id {
+			continue
+		}
+		conn.setTarget(target)
+		if !conn.SendPing() {
+			conn.setTarget(old)
+			return fmt.Errorf("failed to ping slave %s at %s", id, target)
+		}
+		delete(s.slavesConn, old)
+		s.slavesConn[target] = conn
+		return nil
+	}
+	return fmt.Errorf("slave %s is not connected", id)
+}
+
+// SlaveConnCount returns the number of slaves connected to.
+func (s *ConnManager) SlaveConnCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.slavesConn)
+}
+
 func NewToSlaveConnManager(cfg *config.ClusterConfig, slave *SlaveBackend) *ConnManager {
 	slaveConnManager := &ConnManager{
 		qkcCfg:              cfg.Quarkchain,
@@ -213,7 +251,7 @@ func NewToSlaveConnManager(cfg *config.ClusterConfig, slave *SlaveBackend) *Conn
 		logInfo:             "ConnManager",
 	}
 	slaveConnManager.masterClient = &masterConn{
-		client: rpc.NewClient(rpc.MasterServer),
+		client: rpc.NewClient(rpc.MasterServer, slave.slowOps),
 	}
 	return slaveConnManager
 }
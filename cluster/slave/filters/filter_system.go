@@ -36,6 +36,10 @@ const (
 	BlocksSubscription
 	// SyncingSubscription queries syncResult when syncing
 	SyncingSubscription
+	// ReorgsSubscription queries the replaced blocks and the dropped txs of
+	// the reorgs of the shard, and the replaced blocks of the reorgs of the
+	// root chain
+	ReorgsSubscription
 	// LastSubscription keeps track of the last index
 	LastIndexSubscription
 )
@@ -52,6 +56,9 @@ const (
 	ChainEvChanSize = 10
 	// syncSize is the size of channel listening to SubscribeSyncEvent.
 	SyncSize = 5
+	// reorgChanSize is the size of channel listening to MinorChainReorgEvent
+	// and RootChainReorgEvent.
+	ReorgChanSize = 10
 )
 
 var (
@@ -75,6 +82,8 @@ type ShardFilter interface {
 	SubscribeChainEvent(ch chan<- core.MinorChainEvent) event.Subscription
 	SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.Subscription
 	SubscribeSyncEvent(ch chan<- *qsync.SyncingResult) event.Subscription
+	SubscribeReorgEvent(ch chan<- core.MinorChainReorgEvent) event.Subscription
+	SubscribeRootReorgEvent(ch chan<- core.RootChainReorgEvent) event.Subscription
 }
 
 type subscription struct {
@@ -87,8 +96,9 @@ type subscription struct {
 	txlistCh    chan []*types.Transaction
 	headersCh   chan *types.MinorBlockHeader
 	syncCh      chan *qsync.SyncingResult
-	installed   chan struct{} // closed when the filter is installed
-	err         chan error    // closed when the filter is uninstalled
+	reorgCh     chan interface{} // core.MinorChainReorgEvent or core.RootChainReorgEvent
+	installed   chan struct{}    // closed when the filter is installed
+	err         chan error       // closed when the filter is uninstalled
 }
 
 // EventSystem creates subscriptions, processes events and broadcasts them to the
@@ -158,6 +168,8 @@ func (sub *Subscription) Unsubscribe() {
 					<-sub.f.txlistCh
 				} else if sub.f.syncCh != nil {
 					<-sub.f.syncCh
+				} else if sub.f.reorgCh != nil {
+					<-sub.f.reorgCh
 				}
 			}
 		}
@@ -180,6 +192,12 @@ func (es *EventSystem) subscribe(sub *subscription) *Subscription {
 // given criteria to the given logs channel. Default value for the from and to
 // block is "latest". If the fromBlock > toBlock an error is returned.
 func (es *EventSystem) SubscribeLogs(crit qrpc.FilterQuery, logs chan core.LoglistEvent) (*Subscription, error) {
+	// the confirmed and finalized blocks are resolved by the master
+	for _, number := range []*big.Int{crit.FromBlock, crit.ToBlock} {
+		if number != nil && number.Sign() < 0 && number.Int64() != qrpc.LatestBlockNumber.Int64() {
+			return nil, errors.New("only the latest block is supported by the log subscriptions")
+		}
+	}
 	shrd, err := es.backend.GetShardFilter(crit.FullShardId)
 	if err != nil {
 		return nil, err
@@ -287,6 +305,23 @@ func (es *EventSystem) SubscribeSyncing(status chan *qsync.SyncingResult, fullSh
 	return es.subscribe(sub)
 }
 
+// SubscribeReorgs creates a subscription that writes the details of the reorgs
+// of the shard and of the root chain, a core.MinorChainReorgEvent or a
+// core.RootChainReorgEvent, so that the clients can roll back the replaced
+// blocks.
+func (es *EventSystem) SubscribeReorgs(reorgs chan interface{}, fullShardId uint32) *Subscription {
+	sub := &subscription{
+		id:          rpc.NewID(),
+		fullShardId: fullShardId,
+		typ:         ReorgsSubscription,
+		created:     time.Now(),
+		reorgCh:     reorgs,
+		installed:   make(chan struct{}),
+		err:         make(chan error),
+	}
+	return es.subscribe(sub)
+}
+
 type filterIndex map[Type]map[rpc.ID]*subscription
 
 // broadcast event to filters that match criteria.
@@ -327,6 +362,11 @@ func (es *EventSystem) broadcast(filters filterIndex, ev interface{}) {
 		for _, f := range filters[SyncingSubscription] {
 			f.syncCh <- e
 		}
+
+	case core.MinorChainReorgEvent, core.RootChainReorgEvent:
+		for _, f := range filters[ReorgsSubscription] {
+			f.reorgCh <- e
+		}
 	}
 }
 
@@ -93,6 +93,36 @@ func (s *subSyncingEvent) getch() error {
 	}
 }
 
+// subReorgEvent receives the reorgs of the shard and of the root chain.
+type subReorgEvent struct {
+	ch      chan core.MinorChainReorgEvent
+	rootCh  chan core.RootChainReorgEvent
+	rootSub event.Subscription
+	subBaseEvent
+}
+
+func (s *subReorgEvent) getch() error {
+	for {
+		select {
+		case ev := <-s.ch:
+			s.broadcast(ev)
+		case ev := <-s.rootCh:
+			s.broadcast(ev)
+		case err := <-s.sub.Err():
+			return err
+		case err := <-s.rootSub.Err():
+			return err
+		default:
+			return nil
+		}
+	}
+}
+
+func (s *subReorgEvent) freech() {
+	s.sub.Unsubscribe()
+	s.rootSub.Unsubscribe()
+}
+
 func (s *subscribe) newSubEvent(shrd ShardFilter, tp Type, broadcast func(interface{})) subackend {
 	switch tp {
 	case LogsSubscription:
@@ -135,6 +165,19 @@ func (s *subscribe) newSubEvent(shrd ShardFilter, tp Type, broadcast func(interf
 				broadcast: broadcast,
 			},
 		}
+	case ReorgsSubscription:
+		reorgCh := make(chan core.MinorChainReorgEvent, ReorgChanSize)
+		sub := shrd.SubscribeReorgEvent(reorgCh)
+		rootReorgCh := make(chan core.RootChainReorgEvent, ReorgChanSize)
+		return &subReorgEvent{
+			ch:      reorgCh,
+			rootCh:  rootReorgCh,
+			rootSub: shrd.SubscribeRootReorgEvent(rootReorgCh),
+			subBaseEvent: subBaseEvent{
+				sub:       sub,
+				broadcast: broadcast,
+			},
+		}
 	}
 	return nil
 }
@@ -0,0 +1,50 @@
+package slave
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/QuarkChain/goquarkchain/cluster/service"
+)
+
+// HealthChecks implements service.HealthReporter. The slave is alive while
+// the databases of its shards are available, and ready when it has been
+// initialized by the master, is connected to the other slaves, and none of
+// its shards is syncing.
+func (s *SlaveBackend) HealthChecks() []service.HealthCheck {
+	s.lock.RLock()
+	initialized := s.initialized
+	ids := make([]uint32, 0, len(s.shards))
+	for id := range s.shards {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	checks := make([]service.HealthCheck, 0, len(ids)+3)
+	syncing := make([]uint32, 0)
+	for _, id := range ids {
+		shrd := s.shards[id]
+		checks = append(checks, shrd.CheckDatabase(fmt.Sprintf("db/%d", id)))
+		if shrd.IsSyncing() {
+			syncing = append(syncing, id)
+		}
+	}
+	s.lock.RUnlock()
+
+	checks = append(checks, service.HealthCheck{
+		Name:   "master",
+		OK:     initialized,
+		Detail: map[string]interface{}{"connected": initialized},
+	})
+	connected, total := s.connManager.SlaveConnCount(), len(s.clstrCfg.SlaveList)-1
+	checks = append(checks, service.HealthCheck{
+		Name:   "slaves",
+		OK:     connected >= total,
+		Detail: map[string]interface{}{"connected": connected, "total": total},
+	})
+	checks = append(checks, service.HealthCheck{
+		Name:   "sync",
+		OK:     len(syncing) == 0,
+		Detail: map[string]interface{}{"syncing": syncing},
+	})
+	return checks
+}
@@ -2,6 +2,7 @@ package slave
 
 import (
 	"errors"
+	"io"
 
 	"github.com/QuarkChain/goquarkchain/cluster/rpc"
 	qcom "github.com/QuarkChain/goquarkchain/common"
@@ -166,4 +167,31 @@ func (s *ConnManager) GetMinorBlockHeaderList(gReq *rpc.GetMinorBlockHeaderListW
 
 func (s *ConnManager) ModifyTarget(target string) {
 	s.masterClient.target = target
+	s.masterClient.client.SetCapabilities(target, s.masterClient.caps)
+}
+
+// SetMasterCapabilities sets the capabilities the master sent in the Ping
+// handshake, the ops it doesn't serve are skipped from then on.
+func (s *ConnManager) SetMasterCapabilities(caps *rpc.Capabilities) {
+	s.masterClient.caps = caps
+	if s.masterClient.target != "" {
+		s.masterClient.client.SetCapabilities(s.masterClient.target, caps)
+	}
+}
+
+// BootstrapShard streams a snapshot of the shard taken at the root block with
+// rootHash, served by the master from another slave running the shard.
+func (s *ConnManager) BootstrapShard(fullShardId uint32, rootHash common.Hash) (io.ReadCloser, error) {
+	data, err := serialize.SerializeToBytes(rpc.GetShardSnapshotRequest{Branch: fullShardId, RootHash: rootHash})
+	if err != nil {
+		return nil, err
+	}
+	return s.masterClient.client.Stream(s.masterClient.target, &rpc.Request{Op: rpc.OpBootstrapShard, Data: data})
+}
+
+// ShardBootstrapped tells the master a shard was bootstrapped from a snapshot,
+// so it resyncs the shard to the root tip.
+func (s *ConnManager) ShardBootstrapped() error {
+	_, err := s.masterClient.client.Call(s.masterClient.target, &rpc.Request{Op: rpc.OpShardBootstrapped})
+	return err
 }
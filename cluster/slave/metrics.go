@@ -0,0 +1,73 @@
+package slave
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/cluster/metrics"
+	"github.com/QuarkChain/goquarkchain/core"
+	"github.com/QuarkChain/goquarkchain/core/types"
+)
+
+// metricPoints collects the metrics of the slave and of its tx sender cache,
+// and the local state of its shards and the profiles of the blocks they
+// produced since the last report, tagged by their full shard id.
+func (s *SlaveBackend) metricPoints() []metrics.Point {
+	hits, misses, entries := types.SenderCacheStats()
+	points := []metrics.Point{metrics.ProcessPoint(), metrics.NewPoint("sender_cache", nil, map[string]interface{}{
+		"hits":    hits,
+		"misses":  misses,
+		"entries": entries,
+	})}
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	tasks := s.scheduler.Stats()
+	for fullShardID, shrd := range s.shards {
+		tags := map[string]string{
+			"shard": strconv.FormatUint(uint64(fullShardID), 10),
+			"chain": strconv.FormatUint(uint64(shrd.Config.ChainID), 10),
+		}
+		staleBlocks, _ := shrd.MinorBlockChain.GetStaleBlocks(0)
+		points = append(points, metrics.NewPoint("slave_shard", tags, map[string]interface{}{
+			"height":           shrd.MinorBlockChain.CurrentBlock().NumberU64(),
+			"stale_blocks":     staleBlocks,
+			"pending_tx_count": shrd.MinorBlockChain.GetPendingCount(),
+			"syncing":          shrd.IsSyncing(),
+			"paused":           shrd.IsPaused(),
+			"running_tasks":    tasks[fullShardID].Running,
+			"queued_tasks":     tasks[fullShardID].Queued,
+		}))
+		points = append(points, s.blockProductionPoints(fullShardID, shrd.MinorBlockChain, tags)...)
+	}
+	return points
+}
+
+// blockProductionPoints returns the points of the profiles of the blocks
+// produced by the shard since the last report, the oldest first.
+func (s *SlaveBackend) blockProductionPoints(fullShardID uint32, chain *core.MinorBlockChain, tags map[string]string) []metrics.Point {
+	profiles := chain.GetBlockProfiles(^uint32(0))
+	points := make([]metrics.Point, 0)
+	for i := len(profiles) - 1; i >= 0; i-- {
+		profile := profiles[i]
+		if profile.Time <= s.reportedProfiles[fullShardID] {
+			continue
+		}
+		point := metrics.NewPoint("block_production", tags, map[string]interface{}{
+			"height":          profile.Height,
+			"tx_count":        profile.TxCount,
+			"gas_used":        profile.GasUsed,
+			"gas_limit":       profile.GasLimit,
+			"tx_selection_us": profile.TxSelection,
+			"execution_us":    profile.Execution,
+			"state_root_us":   profile.StateRoot,
+			"seal_wait_us":    profile.SealWait,
+			"commit_us":       profile.Commit,
+		})
+		point.Time = time.Unix(0, int64(profile.Time)*int64(time.Millisecond))
+		points = append(points, point)
+	}
+	if len(profiles) > 0 {
+		s.reportedProfiles[fullShardID] = profiles[0].Time
+	}
+	return points
+}
@@ -0,0 +1,121 @@
+package slave
+
+import (
+	"errors"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/QuarkChain/goquarkchain/cluster/config"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// SetConfigLoader sets the function loading the cluster config reloaded by
+// ReloadConfig.
+func (s *SlaveBackend) SetConfigLoader(loader func() (*config.ClusterConfig, error)) {
+	s.configLoader = loader
+}
+
+// SetLogLevel sets the verbosity of the slave if module is empty, or else the
+// log level of the module.
+func (s *SlaveBackend) SetLogLevel(module, level string) error {
+	s.reloadLock.Lock()
+	defer s.reloadLock.Unlock()
+	if err := s.clstrCfg.SetLogLevel(module, level); err != nil {
+		return err
+	}
+	log.Info("Log level set", "module", module, "level", level)
+	return nil
+}
+
+// ReloadConfig reloads the cluster config, and applies the settings which are
+// safe to change while running. It returns the changes applied, the other
+// settings changed take effect after a restart, as does a new endpoint of
+// the slave itself.
+func (s *SlaveBackend) ReloadConfig() ([]config.ConfigChange, error) {
+	if s.configLoader == nil {
+		return nil, errors.New("config reload is not supported")
+	}
+	cfg, err := s.configLoader()
+	if err != nil {
+		return nil, err
+	}
+
+	s.reloadLock.Lock()
+	defer s.reloadLock.Unlock()
+
+	running := s.clstrCfg
+	changes, restart, err := running.ReloadChanges(cfg)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range restart {
+		log.Warn("Config change requires a restart", "setting", path)
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	applied := make([]config.ConfigChange, 0, len(changes))
+	for _, change := range changes {
+		setting, key := config.SplitSetting(change.Setting)
+		switch setting {
+		case config.SettingLogLevel:
+			if err := config.ApplyLogLevel(cfg.LogLevel); err != nil {
+				return applied, err
+			}
+			running.LogLevel = cfg.LogLevel
+		case config.SettingLogModules:
+			if err := config.ApplyLogModules(cfg.LogModules); err != nil {
+				return applied, err
+			}
+			running.LogModules = cfg.LogModules
+		case config.SettingRPCTxListLimit:
+			atomic.StoreUint32(&running.RPCTxListLimit, cfg.RPCTxListLimit)
+		case config.SettingSlowRPCThreshold:
+			s.slowOps.SetThreshold(cfg.SlowRPCThreshold)
+			running.SlowRPCThreshold = cfg.SlowRPCThreshold
+		case config.SettingTxPool:
+			for _, shrd := range s.shards {
+				shrd.MinorBlockChain.SetTxPoolLimits(cfg.TxPool)
+			}
+			running.TxPool = cfg.TxPool
+		case config.SettingRootCoinbase:
+			running.Quarkchain.Root.CoinbaseAddress = cfg.Quarkchain.Root.CoinbaseAddress
+		case config.SettingChainCoinbase:
+			chainID, err := strconv.ParseUint(key, 10, 32)
+			if err != nil {
+				return applied, err
+			}
+			coinbase := cfg.Quarkchain.Chains[uint32(chainID)].CoinbaseAddress
+			for _, shrd := range s.shards {
+				if shrd.Config.ChainID == uint32(chainID) {
+					shrd.SetCoinbaseAddress(coinbase)
+				}
+			}
+			running.Quarkchain.SetChainCoinbase(uint32(chainID), coinbase)
+		case config.SettingChainGasTarget:
+			chainID, err := strconv.ParseUint(key, 10, 32)
+			if err != nil {
+				return applied, err
+			}
+			// the shards share the shard configs of the running config
+			running.Quarkchain.SetChainGasLimitTarget(uint32(chainID), cfg.Quarkchain.Chains[uint32(chainID)].GasLimitTarget)
+		case config.SettingSlaveEndpoint:
+			if key == s.config.ID {
+				log.Warn("Config change requires a restart", "setting", change.Setting)
+				continue
+			}
+			if err := s.connManager.ModifySlaveTarget(key, change.New.(string)); err != nil {
+				return applied, err
+			}
+			slave, _ := running.GetSlaveConfig(key)
+			reloaded, _ := cfg.GetSlaveConfig(key)
+			slave.IP, slave.Port = reloaded.IP, reloaded.Port
+			s.slowOps.SetPeers(running.SlaveList)
+		default:
+			continue
+		}
+		applied = append(applied, change)
+		log.Info("Config reloaded", "setting", change.Setting, "old", change.Old, "new", change.New)
+	}
+	return applied, nil
+}
@@ -2,9 +2,12 @@ package slave
 
 import (
 	"fmt"
+	"sync"
+
 	"github.com/QuarkChain/goquarkchain/cluster/rpc"
 	"github.com/QuarkChain/goquarkchain/core/types"
 	"github.com/QuarkChain/goquarkchain/serialize"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/log"
 )
 
@@ -13,38 +16,56 @@ type SlaveConn struct {
 	target        string
 	id            string
 	chainMaskList []*types.ChainMask
+	genesisHash   common.Hash
 	client        rpc.Client
+	targetLock    sync.RWMutex
 }
 
-func NewToSlaveConn(target, id string, chainMaskList []*types.ChainMask) *SlaveConn {
+func NewToSlaveConn(target, id string, chainMaskList []*types.ChainMask, genesisHash common.Hash, slowOps *rpc.SlowOpLog) *SlaveConn {
 	return &SlaveConn{
 		target:        target,
 		id:            id,
 		chainMaskList: chainMaskList,
-		client:        rpc.NewClient(rpc.SlaveServer),
+		genesisHash:   genesisHash,
+		client:        rpc.NewClient(rpc.SlaveServer, slowOps),
 	}
 }
 
+func (s *SlaveConn) setTarget(target string) {
+	s.targetLock.Lock()
+	defer s.targetLock.Unlock()
+	s.target = target
+}
+
+func (s *SlaveConn) getTarget() string {
+	s.targetLock.RLock()
+	defer s.targetLock.RUnlock()
+	return s.target
+}
+
 func (s *SlaveConn) SendPing() bool {
 	var (
-		gReq = rpc.Ping{Id: []byte(s.id), ChainMaskList: s.chainMaskList}
+		gReq = rpc.Ping{Id: []byte(s.id), ChainMaskList: s.chainMaskList, GenesisHash: s.genesisHash}
 		gRes rpc.Pong
 		err  error
 	)
-	data, err := serialize.SerializeToBytes(gReq)
+	data, err := rpc.SerializeWithCapabilities(gReq, rpc.LocalCapabilities(rpc.SlaveServer))
 	if err != nil {
 		log.Error("Can't serialize rpc.Ping when ping to slave", "err", err)
 		return false
 	}
 
-	res, err := s.client.Call(s.target, &rpc.Request{Op: rpc.OpPing, Data: data})
+	res, err := s.client.Call(s.getTarget(), &rpc.Request{Op: rpc.OpPing, Data: data})
 	if err != nil {
-		log.Error("Failed to Ping to slave", "slave endpoint", s.target, "err", err)
+		log.Error("Failed to Ping to slave", "slave endpoint", s.getTarget(), "err", err)
 		return false
 	}
-	if err = serialize.DeserializeFromBytes(res.Data, &gRes); err != nil {
+	caps, err := rpc.DeserializeWithCapabilities(res.Data, &gRes)
+	if err != nil {
 		log.Error("Can't deserialize response data by rpc.Pong", "err", err)
+		return false
 	}
+	s.client.SetCapabilities(s.getTarget(), caps)
 
 	if s.id != string(gRes.Id) {
 		log.Error("Id doesn't match", "target id", s.id, "actual id", string(gRes.Id))
@@ -56,6 +77,12 @@ func (s *SlaveConn) SendPing() bool {
 		return false
 	}
 
+	// the slaves of version 0 don't send their genesis hash
+	if caps != nil && s.genesisHash != gRes.GenesisHash {
+		log.Error("Genesis doesn't match", "target genesis", s.genesisHash, "actual genesis", gRes.GenesisHash)
+		return false
+	}
+
 	return true
 }
 
@@ -67,7 +94,7 @@ func (s *SlaveConn) AddXshardTxList(xshardReq *rpc.AddXshardTxListRequest) error
 	if err != nil {
 		return err
 	}
-	_, err = s.client.Call(s.target, &rpc.Request{Op: rpc.OpAddXshardTxList, Data: bytes})
+	_, err = s.client.Call(s.getTarget(), &rpc.Request{Op: rpc.OpAddXshardTxList, Data: bytes})
 	return err
 }
 
@@ -76,7 +103,7 @@ func (s *SlaveConn) BatchAddXshardTxList(xshardReqs []*rpc.AddXshardTxListReques
 	if err != nil {
 		return err
 	}
-	_, err = s.client.Call(s.target, &rpc.Request{Op: rpc.OpBatchAddXshardTxList, Data: bytes})
+	_, err = s.client.Call(s.getTarget(), &rpc.Request{Op: rpc.OpBatchAddXshardTxList, Data: bytes})
 	return err
 }
 
@@ -11,6 +11,7 @@ import (
 	qcom "github.com/QuarkChain/goquarkchain/common"
 	"github.com/QuarkChain/goquarkchain/consensus"
 	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/internal/debug"
 	"github.com/QuarkChain/goquarkchain/p2p"
 	"github.com/QuarkChain/goquarkchain/params"
 	qrpc "github.com/QuarkChain/goquarkchain/rpc"
@@ -31,7 +32,7 @@ func NewServerSideOp(slave *SlaveBackend) *SlaveServerSideOp {
 
 func (s *SlaveServerSideOp) HeartBeat(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
 	s.slave.ctx.Timestamp = time.Now()
-	if len(s.slave.shards) == 0 {
+	if !s.slave.isInitialized() {
 		return nil, errors.New("shards uninitialized")
 	}
 	return &rpc.Response{}, nil
@@ -56,6 +57,7 @@ func (s *SlaveServerSideOp) MasterInfo(ctx context.Context, req *rpc.Request) (*
 	if err = s.slave.CreateShards(gReq.RootTip, true); err != nil {
 		return nil, err
 	}
+	s.slave.setInitialized()
 
 	//ping with other slaves
 	for _, slv := range s.slave.clstrCfg.SlaveList {
@@ -72,15 +74,28 @@ func (s *SlaveServerSideOp) MasterInfo(ctx context.Context, req *rpc.Request) (*
 
 func (s *SlaveServerSideOp) Ping(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
 	var (
+		gReq     rpc.Ping
 		gRes     rpc.Pong
 		response = &rpc.Response{RpcId: req.RpcId}
 		err      error
 	)
+	caps, err := rpc.DeserializeWithCapabilities(req.Data, &gReq)
+	if err != nil {
+		return nil, err
+	}
+	// the nodes of version 0 don't send their genesis hash
+	if caps != nil && gReq.GenesisHash != s.slave.genesisHash {
+		return nil, fmt.Errorf("genesis mismatch: have %x, want %x", gReq.GenesisHash, s.slave.genesisHash)
+	}
+	// the master pings without an ID, unlike the other slaves
+	if len(gReq.Id) == 0 {
+		s.slave.connManager.SetMasterCapabilities(caps)
+	}
 
-	gRes.Id, gRes.ChainMaskList = []byte(s.slave.config.ID), s.slave.config.ChainMaskList
+	gRes.Id, gRes.ChainMaskList, gRes.GenesisHash = []byte(s.slave.config.ID), s.slave.config.ChainMaskList, s.slave.genesisHash
 	log.Info("slave ping response", "request op", req.Op)
 
-	if response.Data, err = serialize.SerializeToBytes(gRes); err != nil {
+	if response.Data, err = rpc.SerializeWithCapabilities(gRes, rpc.LocalCapabilities(rpc.SlaveServer)); err != nil {
 		return nil, err
 	}
 
@@ -154,7 +169,7 @@ func (s *SlaveServerSideOp) GetAccountData(ctx context.Context, req *rpc.Request
 		return nil, err
 	}
 
-	if gRes.AccountBranchDataList, err = s.slave.GetAccountData(gReq.Address, gReq.BlockHeight); err != nil {
+	if gRes.AccountBranchDataList, err = s.slave.GetAccountData(ctx, gReq.Address, gReq.BlockHeight); err != nil {
 		return nil, err
 	}
 
@@ -240,7 +255,7 @@ func (s *SlaveServerSideOp) ExecuteTransaction(ctx context.Context, req *rpc.Req
 	if err = serialize.DeserializeFromBytes(req.Data, &gReq); err != nil {
 		return nil, err
 	}
-	if gRes.Result, err = s.slave.ExecuteTx(gReq.Tx, gReq.FromAddress, gReq.BlockHeight); err != nil {
+	if gRes.Result, err = s.slave.ExecuteTx(ctx, gReq.Tx, gReq.FromAddress, gReq.BlockHeight, gReq.Overrides); err != nil {
 		return nil, err
 	}
 
@@ -250,6 +265,71 @@ func (s *SlaveServerSideOp) ExecuteTransaction(ctx context.Context, req *rpc.Req
 	return response, nil
 }
 
+func (s *SlaveServerSideOp) CreateAccessList(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	var (
+		gReq     rpc.CreateAccessListRequest
+		gRes     rpc.CreateAccessListResponse
+		response = &rpc.Response{RpcId: req.RpcId}
+		err      error
+	)
+	if err = serialize.DeserializeFromBytes(req.Data, &gReq); err != nil {
+		return nil, err
+	}
+	if gRes.AccessList, gRes.GasUsed, gRes.Failed, err = s.slave.CreateAccessList(gReq.Tx, gReq.FromAddress, gReq.BlockHeight); err != nil {
+		return nil, err
+	}
+	if response.Data, err = serialize.SerializeToBytes(gRes); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (s *SlaveServerSideOp) ReloadConfig(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	if _, err := s.slave.ReloadConfig(); err != nil {
+		return nil, err
+	}
+	return &rpc.Response{RpcId: req.RpcId}, nil
+}
+
+func (s *SlaveServerSideOp) SetLogLevel(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	var gReq rpc.SetLogLevelRequest
+	if err := serialize.DeserializeFromBytes(req.Data, &gReq); err != nil {
+		return nil, err
+	}
+	if err := s.slave.SetLogLevel(gReq.Module, gReq.Level); err != nil {
+		return nil, err
+	}
+	return &rpc.Response{RpcId: req.RpcId}, nil
+}
+
+func (s *SlaveServerSideOp) Profile(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	var gReq rpc.ProfileRequest
+	if err := serialize.DeserializeFromBytes(req.Data, &gReq); err != nil {
+		return nil, err
+	}
+	if err := debug.Handler.Profile(gReq.Action, gReq.File, int(gReq.Rate)); err != nil {
+		return nil, err
+	}
+	return &rpc.Response{RpcId: req.RpcId}, nil
+}
+
+// GetSlowOps returns the last slow cluster RPC ops of the slave.
+func (s *SlaveServerSideOp) GetSlowOps(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	var (
+		gReq     rpc.GetSlowOpsRequest
+		response = &rpc.Response{RpcId: req.RpcId}
+		err      error
+	)
+	if err = serialize.DeserializeFromBytes(req.Data, &gReq); err != nil {
+		return nil, err
+	}
+	gRes := rpc.GetSlowOpsResponse{SlowOps: s.slave.slowOps.Ops(int(gReq.Count))}
+	if response.Data, err = serialize.SerializeToBytes(gRes); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
 func (s *SlaveServerSideOp) GetTransactionReceipt(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
 	var (
 		gReq     rpc.GetTransactionReceiptRequest
@@ -272,6 +352,25 @@ func (s *SlaveServerSideOp) GetTransactionReceipt(ctx context.Context, req *rpc.
 	return response, nil
 }
 
+func (s *SlaveServerSideOp) GetMinorBlockReceipts(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	var (
+		gReq     rpc.GetMinorBlockReceiptsRequest
+		gRes     rpc.GetMinorBlockReceiptsResponse
+		response = &rpc.Response{RpcId: req.RpcId}
+		err      error
+	)
+	if err = serialize.DeserializeFromBytes(req.Data, &gReq); err != nil {
+		return nil, err
+	}
+	if gRes.Receipts, err = s.slave.GetMinorBlockReceipts(gReq.MinorBlockHash, gReq.Branch); err != nil {
+		return nil, err
+	}
+	if response.Data, err = serialize.SerializeToBytes(gRes); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
 func (s *SlaveServerSideOp) GetTransactionListByAddress(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
 	var (
 		gReq     rpc.GetTransactionListByAddressRequest
@@ -325,7 +424,7 @@ func (s *SlaveServerSideOp) GetLogs(ctx context.Context, req *rpc.Request) (*rpc
 		return nil, err
 	}
 
-	if gRes.Logs, err = s.slave.GetLogs(&gReq); err != nil {
+	if gRes.Logs, err = s.slave.GetLogs(ctx, &gReq); err != nil {
 		return nil, err
 	}
 
@@ -347,7 +446,7 @@ func (s *SlaveServerSideOp) EstimateGas(ctx context.Context, req *rpc.Request) (
 		return nil, err
 	}
 
-	if gRes.Result, err = s.slave.EstimateGas(gReq.Tx, gReq.FromAddress); err != nil {
+	if gRes.Result, err = s.slave.EstimateGas(ctx, gReq.Tx, gReq.FromAddress); err != nil {
 		return nil, err
 	}
 
@@ -686,11 +785,11 @@ func (s *SlaveServerSideOp) AddTransactions(ctx context.Context, req *rpc.Reques
 	}
 	addTxList := func(branch uint32, txs []*types.Transaction) error {
 		ts := time.Now()
-		err := s.slave.AddTxList(gReq.PeerID, branch, txs)
+		err := s.slave.AddTxList(ctx, gReq.PeerID, branch, txs)
 		if err != nil {
 			return err
 		}
-		log.Info("AddTxs duration", "t", time.Now().Sub(ts).Seconds(), "time", time.Now().Sub(ts).Nanoseconds(), "len", len(txs))
+		qrpc.LoggerFromContext(ctx).Info("AddTxs duration", "t", time.Now().Sub(ts).Seconds(), "time", time.Now().Sub(ts).Nanoseconds(), "len", len(txs))
 		return nil
 	}
 
@@ -728,7 +827,9 @@ func (s *SlaveServerSideOp) AddTransactions(ctx context.Context, req *rpc.Reques
 	for branch, txs := range txList {
 		branch, txs := branch, txs
 		g.Go(func() error {
-			return addTxList(branch, txs)
+			return s.slave.scheduler.Run(branch, func() error {
+				return addTxList(branch, txs)
+			})
 		})
 	}
 
@@ -781,3 +882,319 @@ func (s *SlaveServerSideOp) CheckMinorBlocksInRoot(ctx context.Context, req *rpc
 	}
 	return response, s.slave.CheckMinorBlocksInRoot(&rootBlock)
 }
+
+func (s *SlaveServerSideOp) GetStaleBlocks(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	var (
+		gReq     rpc.GetStaleBlocksRequest
+		gRes     rpc.GetStaleBlocksResponse
+		response = &rpc.Response{RpcId: req.RpcId}
+		err      error
+	)
+	if err = serialize.DeserializeFromBytes(req.Data, &gReq); err != nil {
+		return nil, err
+	}
+	if gRes.Total, gRes.StaleBlocks, err = s.slave.GetStaleBlocks(gReq.Branch, gReq.Limit); err != nil {
+		return nil, err
+	}
+	if response.Data, err = serialize.SerializeToBytes(gRes); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (s *SlaveServerSideOp) GetBlockProfiles(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	var (
+		gReq     rpc.GetBlockProfilesRequest
+		gRes     rpc.GetBlockProfilesResponse
+		response = &rpc.Response{RpcId: req.RpcId}
+		err      error
+	)
+	if err = serialize.DeserializeFromBytes(req.Data, &gReq); err != nil {
+		return nil, err
+	}
+	if gRes.BlockProfiles, err = s.slave.GetBlockProfiles(gReq.Branch, gReq.Limit); err != nil {
+		return nil, err
+	}
+	if response.Data, err = serialize.SerializeToBytes(gRes); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (s *SlaveServerSideOp) GetTxBenchmarkReports(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	var (
+		gRes     = rpc.GetTxBenchmarkReportsResponse{Reports: s.slave.GetTxBenchmarkReports()}
+		response = &rpc.Response{RpcId: req.RpcId}
+		err      error
+	)
+	if response.Data, err = serialize.SerializeToBytes(gRes); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (s *SlaveServerSideOp) ReplayBlocks(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	var (
+		gReq     rpc.ReplayBlocksRequest
+		gRes     rpc.ReplayBlocksResponse
+		response = &rpc.Response{RpcId: req.RpcId}
+		err      error
+	)
+	if err = serialize.DeserializeFromBytes(req.Data, &gReq); err != nil {
+		return nil, err
+	}
+	if gRes.Results, err = s.slave.ReplayBlocks(gReq.Branch, gReq.First, gReq.Last); err != nil {
+		return nil, err
+	}
+	if response.Data, err = serialize.SerializeToBytes(gRes); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (s *SlaveServerSideOp) BackupShard(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	var (
+		gReq     rpc.BackupShardRequest
+		gRes     *rpc.BackupShardResponse
+		response = &rpc.Response{RpcId: req.RpcId}
+		err      error
+	)
+	if err = serialize.DeserializeFromBytes(req.Data, &gReq); err != nil {
+		return nil, err
+	}
+	if gRes, err = s.slave.BackupShard(gReq.Branch, gReq.Path); err != nil {
+		return nil, err
+	}
+	if response.Data, err = serialize.SerializeToBytes(gRes); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (s *SlaveServerSideOp) GetShardRootTips(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	var (
+		gRes     = rpc.GetShardRootTipsResponse{RootTips: s.slave.GetShardRootTips()}
+		response = &rpc.Response{RpcId: req.RpcId}
+		err      error
+	)
+	if response.Data, err = serialize.SerializeToBytes(gRes); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (s *SlaveServerSideOp) ResendXshardTxList(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	var (
+		gReq     rpc.ResendXshardTxListRequest
+		response = &rpc.Response{RpcId: req.RpcId}
+	)
+	if err := serialize.DeserializeFromBytes(req.Data, &gReq); err != nil {
+		return nil, err
+	}
+	if err := s.slave.ResendXshardTxList(gReq.Branch, gReq.MinorBlockHashList); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (s *SlaveServerSideOp) GetShardSnapshot(req *rpc.Request, stream rpc.SlaveServerSideOp_GetShardSnapshotServer) error {
+	var gReq rpc.GetShardSnapshotRequest
+	if err := serialize.DeserializeFromBytes(req.Data, &gReq); err != nil {
+		return err
+	}
+	w := rpc.NewStreamWriter(stream.Send, req.RpcId)
+	if err := s.slave.WriteShardSnapshot(gReq.Branch, gReq.RootHash, w); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func (s *SlaveServerSideOp) TraceBlock(req *rpc.Request, stream rpc.SlaveServerSideOp_TraceBlockServer) error {
+	var gReq rpc.TraceBlockRequest
+	if err := serialize.DeserializeFromBytes(req.Data, &gReq); err != nil {
+		return err
+	}
+	w := rpc.NewStreamWriter(stream.Send, req.RpcId)
+	if err := s.slave.TraceBlock(stream.Context(), gReq.MinorBlockHash, gReq.Branch, &gReq.Config, w); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func (s *SlaveServerSideOp) ExportBlocks(req *rpc.Request, stream rpc.SlaveServerSideOp_ExportBlocksServer) error {
+	var gReq rpc.ExportBlocksRequest
+	if err := serialize.DeserializeFromBytes(req.Data, &gReq); err != nil {
+		return err
+	}
+	w := rpc.NewStreamWriter(stream.Send, req.RpcId)
+	if err := s.slave.ExportBlocks(stream.Context(), gReq.Branch, gReq.From, gReq.Count, gReq.IncludeReceipts, w); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func (s *SlaveServerSideOp) SimulateBundle(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	var (
+		gReq     rpc.SimulateBundleRequest
+		gRes     rpc.SimulateBundleResponse
+		response = &rpc.Response{RpcId: req.RpcId}
+		err      error
+	)
+	if err = serialize.DeserializeFromBytes(req.Data, &gReq); err != nil {
+		return nil, err
+	}
+	if gRes.Results, err = s.slave.SimulateBundle(ctx, gReq.Txs, gReq.Branch, gReq.Height); err != nil {
+		return nil, err
+	}
+	if response.Data, err = serialize.SerializeToBytes(gRes); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (s *SlaveServerSideOp) GetUnreceivedXShardDeposits(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	var (
+		gReq     rpc.GetUnreceivedXShardDepositsRequest
+		gRes     rpc.GetUnreceivedXShardDepositsResponse
+		response = &rpc.Response{RpcId: req.RpcId}
+		err      error
+	)
+	if err = serialize.DeserializeFromBytes(req.Data, &gReq); err != nil {
+		return nil, err
+	}
+	if gRes.Deposits, err = s.slave.GetUnreceivedXShardDeposits(gReq.Branch, gReq.Limit); err != nil {
+		return nil, err
+	}
+	if response.Data, err = serialize.SerializeToBytes(gRes); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// GetXShardQueues returns the queues of the cross-shard deposits not applied
+// by the shards of the slave yet.
+func (s *SlaveServerSideOp) GetXShardQueues(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	var (
+		gRes     = rpc.GetXShardQueuesResponse{Queues: s.slave.GetXShardQueues()}
+		response = &rpc.Response{RpcId: req.RpcId}
+		err      error
+	)
+	if response.Data, err = serialize.SerializeToBytes(gRes); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (s *SlaveServerSideOp) ShardMaintenance(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	var (
+		gReq     rpc.ShardMaintenanceRequest
+		gRes     *rpc.ShardMaintenanceResponse
+		response = &rpc.Response{RpcId: req.RpcId}
+		err      error
+	)
+	if err = serialize.DeserializeFromBytes(req.Data, &gReq); err != nil {
+		return nil, err
+	}
+	if gRes, err = s.slave.ShardMaintenance(gReq.Branch, gReq.Action); err != nil {
+		return nil, err
+	}
+	if response.Data, err = serialize.SerializeToBytes(gRes); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (s *SlaveServerSideOp) GetStateAvailability(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	var (
+		gReq     rpc.GetStateAvailabilityRequest
+		gRes     rpc.GetStateAvailabilityResponse
+		response = &rpc.Response{RpcId: req.RpcId}
+		err      error
+	)
+	if err = serialize.DeserializeFromBytes(req.Data, &gReq); err != nil {
+		return nil, err
+	}
+	if gRes.Availability, err = s.slave.GetStateAvailability(gReq.Branch, gReq.Number); err != nil {
+		return nil, err
+	}
+	if response.Data, err = serialize.SerializeToBytes(gRes); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (s *SlaveServerSideOp) RegenerateState(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	var (
+		gReq     rpc.RegenerateStateRequest
+		gRes     *rpc.RegenerateStateResponse
+		response = &rpc.Response{RpcId: req.RpcId}
+		err      error
+	)
+	if err = serialize.DeserializeFromBytes(req.Data, &gReq); err != nil {
+		return nil, err
+	}
+	if gRes, err = s.slave.RegenerateState(gReq.Branch, gReq.Number); err != nil {
+		return nil, err
+	}
+	if response.Data, err = serialize.SerializeToBytes(gRes); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (s *SlaveServerSideOp) GetPendingAccountData(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	var (
+		gReq     rpc.GetPendingAccountDataRequest
+		gRes     rpc.GetPendingAccountDataResponse
+		response = &rpc.Response{RpcId: req.RpcId}
+		err      error
+	)
+	if err = serialize.DeserializeFromBytes(req.Data, &gReq); err != nil {
+		return nil, err
+	}
+	if gRes.AccountBranchData, err = s.slave.GetPendingAccountData(gReq.Address); err != nil {
+		return nil, err
+	}
+	if response.Data, err = serialize.SerializeToBytes(gRes); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (s *SlaveServerSideOp) ExecutePendingTransaction(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	var (
+		gReq     rpc.ExecutePendingTransactionRequest
+		gRes     rpc.ExecuteTransactionResponse
+		response = &rpc.Response{RpcId: req.RpcId}
+		err      error
+	)
+	if err = serialize.DeserializeFromBytes(req.Data, &gReq); err != nil {
+		return nil, err
+	}
+	if gRes.Result, err = s.slave.ExecutePendingTx(ctx, gReq.Tx, gReq.FromAddress, gReq.Overrides); err != nil {
+		return nil, err
+	}
+	if response.Data, err = serialize.SerializeToBytes(gRes); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (s *SlaveServerSideOp) GetBalanceHistory(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	var (
+		gReq     rpc.GetBalanceHistoryRequest
+		response = &rpc.Response{RpcId: req.RpcId}
+		err      error
+	)
+	if err = serialize.DeserializeFromBytes(req.Data, &gReq); err != nil {
+		return nil, err
+	}
+	gRes, err := s.slave.GetBalanceHistory(gReq.Address, gReq.Height)
+	if err != nil {
+		return nil, err
+	}
+	if response.Data, err = serialize.SerializeToBytes(gRes); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
@@ -0,0 +1,131 @@
+package slave
+
+import (
+	"errors"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/cluster/shard"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// snapshotRetries and snapshotRetryInterval bound the wait of a new shard for
+// the master to serve its snapshot, as the slaves create their shards at the
+// same time when the cluster starts.
+const (
+	snapshotRetries       = 12
+	snapshotRetryInterval = 5 * time.Second
+)
+
+// needsBootstrap reports whether the new shard id is bootstrapped from a
+// snapshot, i.e. snapshot sync is on, the shard has no database yet and
+// another slave runs it to serve the snapshot.
+func (s *SlaveBackend) needsBootstrap(id uint32) bool {
+	if !s.clstrCfg.SnapSync || s.clstrCfg.Clean || shard.HasDB(s.ctx, id) {
+		return false
+	}
+	for _, slv := range s.clstrCfg.SlaveList {
+		if slv.ID == s.config.ID {
+			continue
+		}
+		for _, mask := range slv.ChainMaskList {
+			if mask.ContainFullShardId(id) {
+				return true
+			}
+		}
+	}
+	log.Warn("No other slave runs the shard to bootstrap it from", "shard", id)
+	return false
+}
+
+// startBootstrap bootstraps the new shard id in the background, unless it is
+// already, and reports whether it does.
+func (s *SlaveBackend) startBootstrap(id uint32, rootBlock *types.RootBlock) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.bootstrapping[id] {
+		return true
+	}
+	if !s.needsBootstrap(id) {
+		return false
+	}
+	s.bootstrapping[id] = true
+	go s.bootstrapShard(id, rootBlock)
+	return true
+}
+
+// bootstrapShard restores the database of the new shard id from a snapshot
+// served by the master, taken at rootBlock, the root tip of the master, then
+// creates the shard at the latest root block, and the master resyncs it from
+// rootBlock. The shard syncs from its genesis if the master serves no valid
+// snapshot.
+func (s *SlaveBackend) bootstrapShard(id uint32, rootBlock *types.RootBlock) {
+	defer func() {
+		s.lock.Lock()
+		delete(s.bootstrapping, id)
+		s.lock.Unlock()
+	}()
+	bootstrapped := false
+	for i := 0; i < snapshotRetries && !bootstrapped; i++ {
+		if i > 0 {
+			time.Sleep(snapshotRetryInterval)
+		}
+		if err := s.fetchShardSnapshot(id, rootBlock); rpc.IsUnsupportedOp(err) {
+			// the master runs an older version
+			log.Warn("Master does not serve snapshots", "shard", id)
+			break
+		} else if err != nil {
+			log.Warn("Failed to bootstrap shard", "shard", id, "err", err)
+			continue
+		}
+		bootstrapped = true
+	}
+	if !bootstrapped {
+		log.Warn("Failed to bootstrap shard, syncing it from the genesis", "shard", id)
+	}
+	if err := s.createShard(id); err != nil {
+		log.Error("Failed to create bootstrapped shard", "slave id", s.config.ID, "shard", id, "err", err)
+		return
+	}
+	if bootstrapped {
+		if err := s.connManager.ShardBootstrapped(); err != nil {
+			log.Error("Failed to resync bootstrapped shard", "shard", id, "err", err)
+		}
+	}
+}
+
+func (s *SlaveBackend) fetchShardSnapshot(id uint32, rootBlock *types.RootBlock) error {
+	log.Info("Bootstrap shard from snapshot", "shard", id, "root height", rootBlock.Number())
+	r, err := s.connManager.BootstrapShard(id, rootBlock.Hash())
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return shard.Bootstrap(s.ctx, s.clstrCfg, id, rootBlock, r)
+}
+
+// createShard creates the shard id at the latest root block added, holding
+// rootLock so no root block is added to the shards meanwhile.
+func (s *SlaveBackend) createShard(id uint32) error {
+	s.rootLock.Lock()
+	defer s.rootLock.Unlock()
+	if s.rootTip == nil {
+		return errors.New("slave stopped")
+	}
+	shrd, err := shard.New(s.ctx, s.rootTip, s.connManager, s.clstrCfg, id)
+	if err != nil {
+		return err
+	}
+	shrd.SetScheduler(s.scheduler)
+	s.addShard(id, shrd)
+	if err := shrd.InitFromRootBlock(s.rootTip); err != nil {
+		shrd.Stop()
+		s.removeShard(id)
+		return err
+	}
+	if s.isMining() {
+		shrd.SetMining(true)
+	}
+	return nil
+}
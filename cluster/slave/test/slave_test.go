@@ -391,11 +391,11 @@ func TestSLaveGRPC(t *testing.T) {
 		},
 	}
 
-	listener, handler, err := grpc.StartGRPCServer(target, apis)
+	listener, handler, err := grpc.StartGRPCServer(target, apis, false)
 	if err != nil {
 		t.Fatalf("failed to create grpc server %v", err)
 	}
-	cli := grpc.NewClient(grpc.SlaveServer)
+	cli := grpc.NewClient(grpc.SlaveServer, nil)
 
 	// all slave gprc funcs test cases
 	testCases := casesAndCheck(t, slave)
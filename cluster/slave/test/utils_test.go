@@ -444,6 +444,22 @@ func (s *SlaveServerSideOp) CheckMinorBlocksInRoot(ctx context.Context, req *rpc
 	return response, nil
 }
 
+func (s *SlaveServerSideOp) GetStaleBlocks(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	var (
+		gReq     rpc.GetStaleBlocksRequest
+		gRes     rpc.GetStaleBlocksResponse
+		response = &rpc.Response{RpcId: req.RpcId}
+		err      error
+	)
+	if err = serialize.DeserializeFromBytes(req.Data, &gReq); err != nil {
+		return nil, err
+	}
+	if response.Data, err = serialize.SerializeToBytes(gRes); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
 // p2p apis.
 func (s *SlaveServerSideOp) GetMinorBlockList(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
 	var (
@@ -566,3 +582,123 @@ func (s *SlaveServerSideOp) GetRootChainStakes(ctx context.Context, req *rpc.Req
 	}
 	return response, nil
 }
+
+func (s *SlaveServerSideOp) CreateAccessList(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	var (
+		gReq     rpc.CreateAccessListRequest
+		gRes     rpc.CreateAccessListResponse
+		response = &rpc.Response{RpcId: req.RpcId}
+		err      error
+	)
+	if err = serialize.DeserializeFromBytes(req.Data, &gReq); err != nil {
+		return nil, err
+	}
+	if response.Data, err = serialize.SerializeToBytes(gRes); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (s *SlaveServerSideOp) ReloadConfig(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	return &rpc.Response{RpcId: req.RpcId}, nil
+}
+
+func (s *SlaveServerSideOp) SetLogLevel(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	return &rpc.Response{RpcId: req.RpcId}, nil
+}
+
+func (s *SlaveServerSideOp) Profile(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	return &rpc.Response{RpcId: req.RpcId}, nil
+}
+
+func (s *SlaveServerSideOp) GetSlowOps(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	return &rpc.Response{RpcId: req.RpcId}, nil
+}
+
+func (s *SlaveServerSideOp) GetBlockProfiles(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	return &rpc.Response{RpcId: req.RpcId}, nil
+}
+
+func (s *SlaveServerSideOp) GetTxBenchmarkReports(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	return &rpc.Response{RpcId: req.RpcId}, nil
+}
+
+func (s *SlaveServerSideOp) ReplayBlocks(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	return &rpc.Response{RpcId: req.RpcId}, nil
+}
+
+func (s *SlaveServerSideOp) BackupShard(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	return &rpc.Response{RpcId: req.RpcId}, nil
+}
+
+func (s *SlaveServerSideOp) GetShardRootTips(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	return &rpc.Response{RpcId: req.RpcId}, nil
+}
+
+func (s *SlaveServerSideOp) ResendXshardTxList(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	return &rpc.Response{RpcId: req.RpcId}, nil
+}
+
+func (s *SlaveServerSideOp) GetShardSnapshot(req *rpc.Request, stream rpc.SlaveServerSideOp_GetShardSnapshotServer) error {
+	return nil
+}
+
+func (s *SlaveServerSideOp) GetBalanceHistory(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	return &rpc.Response{RpcId: req.RpcId}, nil
+}
+
+func (s *SlaveServerSideOp) GetXShardQueues(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	return &rpc.Response{RpcId: req.RpcId}, nil
+}
+
+func (s *SlaveServerSideOp) ShardMaintenance(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	return &rpc.Response{RpcId: req.RpcId}, nil
+}
+
+func (s *SlaveServerSideOp) GetStateAvailability(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	return &rpc.Response{RpcId: req.RpcId}, nil
+}
+
+func (s *SlaveServerSideOp) RegenerateState(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	return &rpc.Response{RpcId: req.RpcId}, nil
+}
+
+func (s *SlaveServerSideOp) GetPendingAccountData(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	return &rpc.Response{RpcId: req.RpcId}, nil
+}
+
+func (s *SlaveServerSideOp) ExecutePendingTransaction(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	return &rpc.Response{RpcId: req.RpcId}, nil
+}
+
+func (s *SlaveServerSideOp) GetMinorBlockReceipts(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	return &rpc.Response{RpcId: req.RpcId}, nil
+}
+
+func (s *SlaveServerSideOp) TraceBlock(req *rpc.Request, stream rpc.SlaveServerSideOp_TraceBlockServer) error {
+	return nil
+}
+
+func (s *SlaveServerSideOp) ExportBlocks(req *rpc.Request, stream rpc.SlaveServerSideOp_ExportBlocksServer) error {
+	return nil
+}
+
+func (s *SlaveServerSideOp) SimulateBundle(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	return &rpc.Response{RpcId: req.RpcId}, nil
+}
+
+func (s *SlaveServerSideOp) GetUnreceivedXShardDeposits(ctx context.Context, req *rpc.Request) (*rpc.Response, error) {
+	var (
+		gReq     rpc.GetUnreceivedXShardDepositsRequest
+		gRes     rpc.GetUnreceivedXShardDepositsResponse
+		response = &rpc.Response{RpcId: req.RpcId}
+		err      error
+	)
+	if err = serialize.DeserializeFromBytes(req.Data, &gReq); err != nil {
+		return nil, err
+	}
+	if response.Data, err = serialize.SerializeToBytes(gRes); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
@@ -34,6 +34,8 @@ type testBackend struct {
 	chainFeed     event.Feed
 	chainHeadFeed event.Feed
 	syncFeed      event.Feed
+	reorgFeed     event.Feed
+	rootReorgFeed event.Feed
 
 	mBlock *types.MinorBlock
 
@@ -92,6 +94,41 @@ func (b *testBackend) creatSyncing(results []*sync.SyncingResult) {
 	}
 }
 
+func (b *testBackend) createReorg(depth int) core.MinorChainReorgEvent {
+	oldChain, _ := core.GenerateMinorBlockChain(params.TestChainConfig, b.config.Quarkchain, b.mGenesis, new(consensus.FakeEngine), b.db, depth, nil)
+	newChain, _ := core.GenerateMinorBlockChain(params.TestChainConfig, b.config.Quarkchain, b.mGenesis, new(consensus.FakeEngine), b.db, depth+1, func(config *config.QuarkChainConfig, i int, gen *core.MinorBlockGen) {
+		gen.SetDifficulty(2)
+	})
+	ev := core.MinorChainReorgEvent{CommonBlock: b.mGenesis, Depth: uint64(depth)}
+	for i := len(oldChain) - 1; i >= 0; i-- {
+		ev.OldChain = append(ev.OldChain, oldChain[i])
+	}
+	for i := len(newChain) - 1; i >= 0; i-- {
+		ev.NewChain = append(ev.NewChain, newChain[i])
+	}
+	b.reorgFeed.Send(ev)
+	return ev
+}
+
+// createRootReorg replaces a root block confirming the genesis of the shard by
+// an empty one.
+func (b *testBackend) createRootReorg() core.RootChainReorgEvent {
+	genesis := core.NewGenesis(b.config.Quarkchain).CreateRootBlock()
+	oldBlock := genesis.Header().CreateBlockToAppend(nil, nil, nil, nil, nil)
+	oldBlock.AddMinorBlockHeader(b.mGenesis.Header())
+	oldBlock.Finalize(nil, nil, common.Hash{})
+	newBlock := genesis.Header().CreateBlockToAppend(nil, nil, nil, nil, nil).Finalize(nil, nil, common.Hash{})
+	ev := core.RootChainReorgEvent{
+		CommonBlock:    genesis,
+		OldChain:       []*types.RootBlock{oldBlock},
+		NewChain:       []*types.RootBlock{newBlock},
+		Depth:          1,
+		DroppedHeaders: types.MinorBlockHeaders{b.mGenesis.Header()},
+	}
+	b.rootReorgFeed.Send(ev)
+	return ev
+}
+
 func (b *testBackend) createTxs(txs []*types.Transaction) {
 	mBlocks, _ := core.GenerateMinorBlockChain(params.TestChainConfig, b.config.Quarkchain, b.mGenesis, new(consensus.FakeEngine), b.db, 1, nil)
 	b.mBlock = mBlocks[0]
@@ -188,3 +225,11 @@ func (b *testBackend) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.Sub
 func (b *testBackend) SubscribeSyncEvent(ch chan<- *sync.SyncingResult) event.Subscription {
 	return b.syncFeed.Subscribe(ch)
 }
+
+func (b *testBackend) SubscribeReorgEvent(ch chan<- core.MinorChainReorgEvent) event.Subscription {
+	return b.reorgFeed.Subscribe(ch)
+}
+
+func (b *testBackend) SubscribeRootReorgEvent(ch chan<- core.RootChainReorgEvent) event.Subscription {
+	return b.rootReorgFeed.Subscribe(ch)
+}
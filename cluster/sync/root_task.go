@@ -1,6 +1,7 @@
 package sync
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math/big"
@@ -249,7 +250,7 @@ func (r *rootChainTask) syncMinorBlocks(
 		}
 		// TODO Support to multiple connections
 		g.Go(func() error {
-			status, err := conns[0].AddBlockListForSync(&rpc.AddBlockListForSyncRequest{Branch: b, PeerId: r.PeerID(), MinorBlockHashList: hashList})
+			status, err := conns[0].AddBlockListForSync(context.Background(), &rpc.AddBlockListForSyncRequest{Branch: b, PeerId: r.PeerID(), MinorBlockHashList: hashList})
 			if err == nil {
 				r.statusChan <- status
 			}
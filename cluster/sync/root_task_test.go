@@ -1,6 +1,7 @@
 package sync
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/QuarkChain/goquarkchain/p2p"
@@ -317,7 +318,7 @@ func TestSyncMinorBlocks(t *testing.T) {
 			}
 		}
 
-		AddBlockListForSyncFunc := func(request *rpc.AddBlockListForSyncRequest) (*rpc.ShardStatus, error) {
+		AddBlockListForSyncFunc := func(ctx context.Context, request *rpc.AddBlockListForSyncRequest) (*rpc.ShardStatus, error) {
 			for _, header := range block.MinorBlockHeaders() {
 				rbc.AddValidatedMinorBlockHeader(header.Hash(), header.CoinbaseAmount)
 			}
@@ -337,7 +338,7 @@ func TestSyncMinorBlocks(t *testing.T) {
 		}
 
 		for _, conn := range shardConns.GetSlaveConns() {
-			conn.(*mock_master.MockISlaveConn).EXPECT().AddBlockListForSync(gomock.Any()).DoAndReturn(AddBlockListForSyncFunc).Times(1)
+			conn.(*mock_master.MockISlaveConn).EXPECT().AddBlockListForSync(gomock.Any(), gomock.Any()).DoAndReturn(AddBlockListForSyncFunc).Times(1)
 		}
 
 		var rt = NewRootChainTask(&mockpeer{name: "chunfeng"}, nil, nil, statusChan, shardConns)
@@ -17,6 +17,9 @@ const (
 	RootBlockBatchSize        = 100
 	MinorBlockHeaderListLimit = 100 //TODO 100 50
 	MinorBlockBatchSize       = 50
+	// BlockListSoftResponseLimit is the size in bytes past which no more blocks
+	// are added to a block list response. The requester asks for the rest again.
+	BlockListSoftResponseLimit = 2 * 1024 * 1024
 )
 
 // Task represents a synchronization task for the synchronizer.
@@ -84,32 +87,25 @@ func (t *task) Run(bc blockchain) error {
 		}
 
 		for len(hashlist) > 0 {
-			var blocks []types.IBlock
-			if len(hashlist) > t.batchSize {
-				blocks, err = t.getBlocks(hashlist[:t.batchSize])
-				if err != nil {
-					log.Error("getBlocks-1", "err", err)
-					return err
-				}
-				if len(blocks) != t.batchSize {
-					return fmt.Errorf("unmatched block length-1, expect: %d, actual: %d", t.batchSize, len(blocks))
-				}
-				hashlist = hashlist[t.batchSize:]
-			} else {
-				blocks, err = t.getBlocks(hashlist)
-				if err != nil {
-					log.Error("getBlocks-2", "err", err)
-					return err
-				}
-				if len(blocks) != len(hashlist) {
-					return fmt.Errorf("unmatched block length-2, expect: %d, actual: %d hash:%v", len(hashlist), len(blocks), hashlist[0].String())
-				}
-				hashlist = nil
+			size := len(hashlist)
+			if size > t.batchSize {
+				size = t.batchSize
 			}
-
+			blocks, err := t.getBlocks(hashlist[:size])
 			if err != nil {
+				log.Error("getBlocks", "err", err)
 				return err
 			}
+			// fewer blocks are sent when they exceed the response size limit
+			if len(blocks) == 0 || len(blocks) > size {
+				return fmt.Errorf("unmatched block length, expect: %d, actual: %d hash:%v", size, len(blocks), hashlist[0].String())
+			}
+			for i, blk := range blocks {
+				if blk.Hash() != hashlist[i] {
+					return fmt.Errorf("unmatched block hash, expect: %v, actual: %v", hashlist[i].String(), blk.Hash().String())
+				}
+			}
+			hashlist = hashlist[len(blocks):]
 
 			counter := 0
 			for _, blk := range blocks {
@@ -0,0 +1,241 @@
+// Modified from go-ethereum under GNU Lesser General Public License
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/cluster/master"
+	"github.com/QuarkChain/goquarkchain/cluster/service"
+	"github.com/QuarkChain/goquarkchain/cmd/utils"
+	"github.com/QuarkChain/goquarkchain/core"
+	"github.com/ethereum/go-ethereum/log"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// replayBatchSize is the number of blocks the replay command re-executes per
+// cluster RPC op.
+const replayBatchSize = 100
+
+var (
+	importCommand = cli.Command{
+		Action:    importChain,
+		Name:      "import",
+		Usage:     "Import a blockchain file",
+		ArgsUsage: "<filename>",
+		Category:  "BLOCKCHAIN COMMANDS",
+		Description: `
+The import command imports the root blocks and the minor blocks confirmed by
+them from an RLP stream file written by the export command. It runs on the
+master service with all the slaves started, and the file is decompressed if
+it ends with .gz.`,
+	}
+	exportCommand = cli.Command{
+		Action:    exportChain,
+		Name:      "export",
+		Usage:     "Export blockchain into file",
+		ArgsUsage: "<filename> [<blockNumFirst> <blockNumLast>]",
+		Category:  "BLOCKCHAIN COMMANDS",
+		Description: `
+Requires a first argument of the file to write to. Optional second and third
+arguments control the first and last root block to write, the minor blocks
+confirmed by the root blocks are written before them. It runs on the master
+service with all the slaves started, and the file is gzip compressed if it
+ends with .gz.`,
+	}
+	replayCommand = cli.Command{
+		Action:    replayChain,
+		Name:      "replay",
+		Usage:     "Re-execute the blocks of a shard and check their results",
+		ArgsUsage: "<fullShardId> [<blockNumFirst> <blockNumLast>]",
+		Category:  "BLOCKCHAIN COMMANDS",
+		Description: `
+The replay command re-executes the canonical minor blocks of the shard in the
+given range, the whole chain by default, on the states of their parents, and
+compares the state roots, the receipts and the gas used to the ones stored
+with the blocks, to detect the corruption of the database or consensus bugs
+after upgrades. Nothing is written to the database. It runs on the master
+service with all the slaves started, and the blocks whose parent state is
+pruned fail to be re-executed.`,
+	}
+	genesisCommand = cli.Command{
+		Name:      "genesis",
+		Usage:     "Genesis block operations",
+		ArgsUsage: "",
+		Category:  "BLOCKCHAIN COMMANDS",
+		Subcommands: []cli.Command{
+			{
+				Action:    genesisHash,
+				Name:      "hash",
+				Usage:     "Print the genesis hashes of the cluster",
+				ArgsUsage: " ",
+				Description: `
+The hash command prints the hash of the root genesis block, the hashes of the
+genesis minor blocks of the shards created with the root genesis block, and the
+cluster genesis hash which the master and the slaves check on connecting. The
+hashes only depend on the cluster config and the genesis allocations, so they
+are computed without any database.`,
+			},
+		},
+	}
+)
+
+// startMaster boots up the master service without p2p for the chain commands,
+// the node neither listens, discovers nor dials peers.
+func startMaster(ctx *cli.Context) (*service.Node, *master.QKCMasterBackend) {
+	stack := makeFullNode(ctx)
+	if !stack.IsMaster() {
+		utils.Fatalf("The chain commands only run on the master service")
+	}
+	if err := stack.DisableP2P(); err != nil {
+		utils.Fatalf("Failed to disable p2p: %v", err)
+	}
+	utils.StartService(stack)
+
+	var mstr *master.QKCMasterBackend
+	if err := stack.Service(&mstr); err != nil {
+		utils.Fatalf("master service not running %v", err)
+	}
+	return stack, mstr
+}
+
+func importChain(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	stack, mstr := startMaster(ctx)
+	defer stack.Stop()
+
+	start := time.Now()
+	if err := utils.ImportChain(mstr, ctx.Args().First()); err != nil {
+		utils.Fatalf("Import error: %v", err)
+	}
+	log.Info("Import done", "root tip", mstr.GetTip(), "elapsed", time.Since(start))
+	return nil
+}
+
+func exportChain(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 && len(ctx.Args()) != 3 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	stack, mstr := startMaster(ctx)
+	defer stack.Stop()
+
+	first, last := uint64(0), mstr.GetTip()
+	if len(ctx.Args()) == 3 {
+		var ferr, lerr error
+		first, ferr = strconv.ParseUint(ctx.Args().Get(1), 10, 64)
+		last, lerr = strconv.ParseUint(ctx.Args().Get(2), 10, 64)
+		if ferr != nil || lerr != nil {
+			utils.Fatalf("Export error in parsing parameters: block number not an integer\n")
+		}
+	}
+	start := time.Now()
+	if err := utils.ExportChain(mstr, ctx.Args().First(), first, last); err != nil {
+		utils.Fatalf("Export error: %v\n", err)
+	}
+	log.Info("Export done", "elapsed", time.Since(start))
+	return nil
+}
+
+func replayChain(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 && len(ctx.Args()) != 3 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	fullShardId, err := strconv.ParseUint(ctx.Args().First(), 0, 32)
+	if err != nil {
+		utils.Fatalf("Replay error in parsing parameters: full shard id not an integer")
+	}
+	first, last := uint64(1), uint64(math.MaxUint64)
+	if len(ctx.Args()) == 3 {
+		var ferr, lerr error
+		first, ferr = strconv.ParseUint(ctx.Args().Get(1), 10, 64)
+		last, lerr = strconv.ParseUint(ctx.Args().Get(2), 10, 64)
+		if ferr != nil || lerr != nil {
+			utils.Fatalf("Replay error in parsing parameters: block number not an integer")
+		}
+	}
+	if first == 0 {
+		// the genesis block has no parent to re-execute it on
+		first = 1
+	}
+	stack, mstr := startMaster(ctx)
+	defer stack.Stop()
+
+	var (
+		branch     = account.Branch{Value: uint32(fullShardId)}
+		start      = time.Now()
+		replayed   int
+		mismatched int
+	)
+	for from := first; from <= last; from += replayBatchSize {
+		to := last
+		if last-from >= replayBatchSize {
+			to = from + replayBatchSize - 1
+		}
+		results, err := mstr.ReplayBlocks(context.Background(), branch, from, to)
+		if err != nil {
+			utils.Fatalf("Replay error: %v", err)
+		}
+		if len(results) == 0 {
+			break
+		}
+		for _, result := range results {
+			if !result.Matched() {
+				mismatched++
+				log.Error("Replayed block mismatched", "number", result.Number, "hash", result.Hash,
+					"stateRoot", result.StateRoot, "expectedStateRoot", result.ExpectedStateRoot,
+					"receiptHash", result.ReceiptHash, "expectedReceiptHash", result.ExpectedReceiptHash,
+					"gasUsed", result.GasUsed, "expectedGasUsed", result.ExpectedGasUsed,
+					"badReceipts", result.BadReceipts, "err", result.Error)
+			}
+		}
+		replayed += len(results)
+		log.Info("Replayed blocks", "fullShardId", branch.Value, "first", from, "last", from+uint64(len(results))-1,
+			"mismatched", mismatched, "elapsed", time.Since(start))
+		if uint64(len(results)) <= to-from {
+			// reached the head of the shard
+			break
+		}
+	}
+	if mismatched != 0 {
+		utils.Fatalf("Replay done: %d of %d blocks mismatched", mismatched, replayed)
+	}
+	log.Info("Replay done", "fullShardId", branch.Value, "blocks", replayed, "elapsed", time.Since(start))
+	return nil
+}
+
+func genesisHash(ctx *cli.Context) error {
+	_, cfg := makeConfigNode(ctx)
+	genesis := core.NewGenesis(cfg.Cluster.Quarkchain)
+
+	rootBlock := genesis.CreateRootBlock()
+	fmt.Printf("root\t%s\n", rootBlock.Hash().Hex())
+
+	fullShardIds := cfg.Cluster.Quarkchain.GetGenesisShardIds()
+	sort.Slice(fullShardIds, func(i, j int) bool { return fullShardIds[i] < fullShardIds[j] })
+	for _, fullShardId := range fullShardIds {
+		rootHeight := cfg.Cluster.Quarkchain.GetGenesisRootHeight(fullShardId)
+		if rootHeight != 0 {
+			fmt.Printf("shard %d\tcreated at root height %d\n", fullShardId, rootHeight)
+			continue
+		}
+		block, err := genesis.CreateMinorBlock(rootBlock, fullShardId, nil)
+		if err != nil {
+			utils.Fatalf("Failed to create genesis block of shard %d: %v", fullShardId, err)
+		}
+		fmt.Printf("shard %d\t%s\n", fullShardId, block.Hash().Hex())
+	}
+
+	hash, err := genesis.ClusterGenesisHash()
+	if err != nil {
+		utils.Fatalf("Failed to compute the cluster genesis hash: %v", err)
+	}
+	fmt.Printf("cluster\t%s\n", hash.Hex())
+	return nil
+}
@@ -2,23 +2,21 @@
 package main
 
 import (
-	"encoding/json"
-	"errors"
 	"fmt"
 	"github.com/QuarkChain/goquarkchain/cluster/config"
 	"github.com/QuarkChain/goquarkchain/cluster/service"
 	"github.com/QuarkChain/goquarkchain/cmd/utils"
+	"github.com/QuarkChain/goquarkchain/internal/debug"
 	"github.com/QuarkChain/goquarkchain/params"
 	"github.com/naoina/toml"
 	"gopkg.in/urfave/cli.v1"
-	"io/ioutil"
 	"reflect"
 	"strconv"
 	"unicode"
 )
 
 var (
-	ClusterConfigFlag = cli.StringFlag{Name: "cluster_config", Usage: "", Value: ""}
+	ClusterConfigFlag = cli.StringFlag{Name: "cluster_config", Usage: "Cluster config file (JSON, YAML or TOML)", Value: ""}
 )
 
 // These settings ensure that TOML keys use the same names as Go struct fields.
@@ -44,17 +42,6 @@ type qkcConfig struct {
 	Cluster config.ClusterConfig
 }
 
-func loadConfig(file string, cfg *config.ClusterConfig) error {
-	var (
-		content []byte
-		err     error
-	)
-	if content, err = ioutil.ReadFile(file); err != nil {
-		return errors.New(file + ", " + err.Error())
-	}
-	return json.Unmarshal(content, cfg)
-}
-
 func defaultNodeConfig() service.Config {
 	cfg := service.DefaultConfig
 	cfg.Name = clientIdentifier
@@ -65,28 +52,20 @@ func defaultNodeConfig() service.Config {
 func makeConfigNode(ctx *cli.Context) (*service.Node, qkcConfig) {
 	// Load defaults.
 	cfg := qkcConfig{
-		Cluster: *config.NewClusterConfig(),
 		Service: defaultNodeConfig(),
 	}
-
-	// Load cluster config file.
-	if file := ctx.GlobalString(ClusterConfigFlag.Name); file != "" {
-		if err := loadConfig(file, &cfg.Cluster); err != nil {
-			utils.Fatalf("%v", err)
-		}
+	cluster, err := loadClusterConfig(ctx)
+	if err != nil {
+		utils.Fatalf("%v", err)
 	}
-	utils.SetClusterConfig(ctx, &cfg.Cluster)
+	cfg.Cluster = *cluster
 
 	ServiceName := ctx.GlobalString(utils.ServiceFlag.Name)
+	healthPort := cfg.Cluster.Master.HealthPort
 	if ServiceName != clientIdentifier {
-		slv, err := cfg.Cluster.GetSlaveConfig(ServiceName)
-		if err != nil {
-			utils.Fatalf("service type error: %v", err)
-		}
-		// set slave name and grpc endpoint
+		slv, _ := cfg.Cluster.GetSlaveConfig(ServiceName)
 		cfg.Service.Name = ServiceName
-		cfg.Cluster.Quarkchain.GRPCHost = slv.IP
-		cfg.Cluster.Quarkchain.GRPCPort = slv.Port
+		healthPort = slv.HealthPort
 
 		// set websocket endpoint
 		if ctx.GlobalBool(utils.WSEnableFlag.Name) {
@@ -100,12 +79,22 @@ func makeConfigNode(ctx *cli.Context) (*service.Node, qkcConfig) {
 			}
 			cfg.Service.WSEndpoint = fmt.Sprintf("%s:%d", ip, port)
 		}
-
-		// load genesis accounts
-		if err := config.UpdateGenesisAlloc(&cfg.Cluster); err != nil {
-			utils.Fatalf("Update genesis alloc err: %v", err)
+	}
+	if ServiceName == clientIdentifier && ctx.GlobalBool(utils.WSEnableFlag.Name) {
+		ip, port := config.DefaultHost, cfg.Cluster.Master.WSPort
+		if ctx.GlobalIsSet(utils.WSRPCHostFlag.Name) {
+			ip = ctx.GlobalString(utils.WSRPCHostFlag.Name)
 		}
+		if ctx.GlobalIsSet(utils.WSRPCPortFlag.Name) {
+			port = uint16(ctx.GlobalInt(utils.WSRPCPortFlag.Name))
+		}
+		cfg.Service.WSEndpoint = fmt.Sprintf("%s:%d", ip, port)
+	}
+	// set health endpoint
+	if ctx.GlobalIsSet(utils.HealthPortFlag.Name) {
+		healthPort = uint16(ctx.GlobalInt(utils.HealthPortFlag.Name))
 	}
+	cfg.Service.HealthEndpoint = healthEndpoint(healthPort)
 	// Load default cluster config.
 	utils.SetNodeConfig(ctx, &cfg.Service, &cfg.Cluster)
 
@@ -117,18 +106,64 @@ func makeConfigNode(ctx *cli.Context) (*service.Node, qkcConfig) {
 	return stack, cfg
 }
 
+// healthEndpoint returns the endpoint of the health checks on all the
+// interfaces, for the load balancers and the orchestrators to reach it.
+func healthEndpoint(port uint16) string {
+	if port == 0 {
+		return ""
+	}
+	return fmt.Sprintf(":%d", port)
+}
+
+// loadClusterConfig loads the cluster config of the service from the config
+// file and the flags, it is called again to reload the config.
+func loadClusterConfig(ctx *cli.Context) (*config.ClusterConfig, error) {
+	cfg := config.NewClusterConfig()
+	if file := ctx.GlobalString(ClusterConfigFlag.Name); file != "" {
+		if err := config.LoadClusterConfig(file, cfg); err != nil {
+			return nil, err
+		}
+	}
+	utils.SetClusterConfig(ctx, cfg)
+
+	if serviceName := ctx.GlobalString(utils.ServiceFlag.Name); serviceName != clientIdentifier {
+		slv, err := cfg.GetSlaveConfig(serviceName)
+		if err != nil {
+			return nil, fmt.Errorf("service type error: %v", err)
+		}
+		// set slave grpc endpoint
+		cfg.Quarkchain.GRPCHost = slv.IP
+		cfg.Quarkchain.GRPCPort = slv.Port
+	}
+	// load genesis accounts, the master needs them too to check the cluster genesis
+	if err := config.UpdateGenesisAlloc(cfg); err != nil {
+		return nil, fmt.Errorf("Update genesis alloc err: %v", err)
+	}
+	return cfg, nil
+}
+
 func makeFullNode(ctx *cli.Context) *service.Node {
 	stack, cfg := makeConfigNode(ctx)
+	if err := config.ApplyLogModules(cfg.Cluster.LogModules); err != nil {
+		utils.Fatalf("Failed to set the log levels of the modules: %v", err)
+	}
 
+	var pprofPort uint16
 	if !stack.IsMaster() {
 		for _, slv := range cfg.Cluster.SlaveList {
 			if cfg.Service.Name == slv.ID {
 				utils.RegisterSlaveService(stack, &cfg.Cluster, slv)
+				pprofPort = slv.PprofPort
 				break
 			}
 		}
 	} else {
 		utils.RegisterMasterService(stack, &cfg.Cluster)
+		pprofPort = cfg.Cluster.Master.PprofPort
+	}
+	// the --pprof flag has started the pprof server already
+	if pprofPort != 0 && !debug.PProfEnabled(ctx) {
+		debug.StartPProf(fmt.Sprintf("%s:%d", config.DefaultHost, pprofPort))
 	}
 
 	return stack
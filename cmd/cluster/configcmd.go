@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/QuarkChain/goquarkchain/cmd/utils"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	configCommand = cli.Command{
+		Name:      "config",
+		Usage:     "Manage the cluster config",
+		ArgsUsage: "",
+		Category:  "MISCELLANEOUS COMMANDS",
+		Description: `
+The cluster config given by --cluster_config is JSON, YAML (.yaml, .yml) or
+TOML (.toml), whose keys are the ones of the JSON config.
+
+The ${VAR} references in the file are replaced by the environment variables,
+and the QKC_* environment variables override any field of the config, with
+"__" separating the nested fields and the indexes of the lists, e.g.
+
+  QKC_P2P_PORT=38291
+  QKC_QUARKCHAIN__NETWORK_ID=3
+  QKC_SLAVE_LIST__0__HOST=10.0.0.1
+
+The values are JSON, or else strings. The flags override both.`,
+		Subcommands: []cli.Command{
+			{
+				Action:    checkConfig,
+				Name:      "check",
+				Usage:     "Validate and print the effective cluster config",
+				ArgsUsage: " ",
+				Description: `
+The check command loads the cluster config as the service given by --service
+does, with the environment overrides and the flags applied, validates it, and
+prints the effective config as JSON.`,
+			},
+		},
+	}
+)
+
+func checkConfig(ctx *cli.Context) error {
+	cfg, err := loadClusterConfig(ctx)
+	if err != nil {
+		utils.Fatalf("Failed to load config: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		utils.Fatalf("Invalid config: %v", err)
+	}
+	out, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		utils.Fatalf("Failed to encode config: %v", err)
+	}
+	fmt.Println(string(out))
+	return nil
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/cluster/backup"
+	"github.com/QuarkChain/goquarkchain/cluster/config"
+	"github.com/QuarkChain/goquarkchain/cmd/utils"
+	"github.com/QuarkChain/goquarkchain/core/rawdb"
+	"github.com/QuarkChain/goquarkchain/qkcdb"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	dryRunFlag = cli.BoolFlag{
+		Name:  "dryrun",
+		Usage: "List the stale shard data without deleting it",
+	}
+
+	dbCommand = cli.Command{
+		Name:      "db",
+		Usage:     "Low level database operations",
+		ArgsUsage: "",
+		Category:  "DATABASE COMMANDS",
+		Description: `
+The db commands operate on the database of the master, or on the databases of
+the shards served by the slave given by --service, while the cluster is down.`,
+		Subcommands: []cli.Command{
+			{
+				Action:    inspectDB,
+				Name:      "inspect",
+				Usage:     "Inspect the storage size of each kind of data",
+				ArgsUsage: " ",
+				Description: `
+The inspect command traverses the databases and prints the number and the total
+size of the entries of each kind of data, and of the ancient store tables.`,
+			},
+			{
+				Action:    compactDB,
+				Name:      "compact",
+				Usage:     "Compact the databases",
+				ArgsUsage: " ",
+				Description: `
+The compact command compacts the whole key range of the databases, reclaiming
+the space of the deleted and overwritten entries.`,
+			},
+			{
+				Action:    freezerStats,
+				Name:      "freezer",
+				Usage:     "Show the ancient store statistics",
+				ArgsUsage: " ",
+				Description: `
+The freezer command prints the number of items and the size on disk of each
+table in the ancient stores of the shards.`,
+			},
+			{
+				Action:    verifyDB,
+				Name:      "verify",
+				Usage:     "Verify the consistency of the canonical blocks",
+				ArgsUsage: "[<blockNumFirst> <blockNumLast>]",
+				Description: `
+The verify command cross checks the headers, bodies and receipts of the canonical
+blocks in the given range, the whole chain by default, and reports the blocks
+whose state is missing, which is expected for the states pruned in full gc mode.`,
+			},
+			{
+				Action:    removeStaleDB,
+				Name:      "removestale",
+				Usage:     "Delete the data of the shards not served by the slave",
+				ArgsUsage: " ",
+				Flags:     []cli.Flag{dryRunFlag},
+				Description: `
+The removestale command deletes the databases and the ancient stores of the
+shards which are not in the cluster config or not served by the slave anymore.`,
+			},
+			{
+				Action:    backupDB,
+				Name:      "backup",
+				Usage:     "Back up the database of a shard to an archive",
+				ArgsUsage: "<fullShardId> <file>",
+				Description: `
+The backup command writes a snapshot of the database and the ancient store of
+the shard, with the height of its head block, to a tar archive, gzipped if the
+file name ends with .gz. The shard of a running slave is backed up by the
+admin_backupShard RPC instead.`,
+			},
+			{
+				Action:    restoreDB,
+				Name:      "restore",
+				Usage:     "Restore the databases of shards from archives",
+				ArgsUsage: "<file> [<file>...]",
+				Description: `
+The restore command replaces the database and the ancient store of the shard of
+each archive written by the backup command or admin_backupShard. Once the slave
+is started, the master resyncs the restored shards to its root tip, with their
+minor blocks since the backup downloaded from a peer.`,
+			},
+		},
+	}
+)
+
+// chainDatabase is the database of the root chain or of a shard chain.
+type chainDatabase struct {
+	name        string
+	isRoot      bool
+	fullShardID uint32
+	db          ethdb.Database
+}
+
+// headNumber returns the number of the head block stored in the database.
+func (c *chainDatabase) headNumber() uint64 {
+	hash := rawdb.ReadHeadBlockHash(c.db)
+	if hash == (common.Hash{}) {
+		utils.Fatalf("%s: head block not found", c.name)
+	}
+	number := rawdb.ReadHeaderNumber(c.db, hash)
+	if number == nil {
+		utils.Fatalf("%s: head block number not found", c.name)
+	}
+	return *number
+}
+
+// shardDirs returns the data directories of the shards found in the instance
+// directory, keyed by the full shard id.
+func shardDirs(cfg *qkcConfig) map[uint32]string {
+	matches, err := filepath.Glob(cfg.Service.ResolvePath("shard-*"))
+	if err != nil {
+		utils.Fatalf("Failed to list shard data: %v", err)
+	}
+	dirs := make(map[uint32]string)
+	for _, dir := range matches {
+		var fullShardID uint32
+		if _, err := fmt.Sscanf(filepath.Base(dir), "shard-%d", &fullShardID); err == nil {
+			dirs[fullShardID] = dir
+		}
+	}
+	return dirs
+}
+
+// servedShard reports whether the shard is in the cluster config and served by
+// the slave.
+func servedShard(cfg *qkcConfig, slv *config.SlaveConfig, fullShardID uint32) bool {
+	if cfg.Cluster.Quarkchain.GetShardConfigByFullShardID(fullShardID) == nil {
+		return false
+	}
+	for _, mask := range slv.ChainMaskList {
+		if mask.ContainFullShardId(fullShardID) {
+			return true
+		}
+	}
+	return false
+}
+
+func openDatabase(cfg *qkcConfig, path string, isReadOnly bool) ethdb.Database {
+	if _, err := os.Stat(path); err != nil {
+		utils.Fatalf("Database %s not found: %v", path, err)
+	}
+	db, err := qkcdb.NewDatabase(cfg.Service.DBBackend, path, false, isReadOnly)
+	if err != nil {
+		utils.Fatalf("Failed to open database %s: %v", path, err)
+	}
+	return db
+}
+
+// openDatabases opens the database of the master, or the databases of the
+// shards served by the slave together with their ancient stores.
+func openDatabases(ctx *cli.Context, isReadOnly bool) []*chainDatabase {
+	stack, cfg := makeConfigNode(ctx)
+	if cfg.Service.DataDir == "" {
+		utils.Fatalf("The db commands require a data directory")
+	}
+	if stack.IsMaster() {
+		return []*chainDatabase{{
+			name:   "master",
+			isRoot: true,
+			db:     openDatabase(&cfg, cfg.Service.ResolvePath("db"), isReadOnly),
+		}}
+	}
+	slv, err := cfg.Cluster.GetSlaveConfig(cfg.Service.Name)
+	if err != nil {
+		utils.Fatalf("service type error: %v", err)
+	}
+	dirs := shardDirs(&cfg)
+	ids := make([]uint32, 0, len(dirs))
+	for id := range dirs {
+		if servedShard(&cfg, slv, id) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	dbs := make([]*chainDatabase, 0, len(ids))
+	for _, id := range ids {
+		db := openDatabase(&cfg, filepath.Join(dirs[id], "db"), isReadOnly)
+		frdb, err := rawdb.NewDatabaseWithFreezer(db, filepath.Join(dirs[id], "ancient"))
+		if err != nil {
+			utils.Fatalf("Failed to open ancient store of shard %d: %v", id, err)
+		}
+		dbs = append(dbs, &chainDatabase{name: fmt.Sprintf("shard-%d", id), fullShardID: id, db: frdb})
+	}
+	return dbs
+}
+
+func closeDatabases(dbs []*chainDatabase) {
+	for _, c := range dbs {
+		c.db.Close()
+	}
+}
+
+func printStats(title string, stats []rawdb.DatabaseStat) {
+	var (
+		count uint64
+		size  common.StorageSize
+	)
+	fmt.Println(title)
+	for _, stat := range stats {
+		fmt.Printf("  %-32s %12d %12s\n", stat.Kind, stat.Count, stat.Size)
+		count, size = count+stat.Count, size+stat.Size
+	}
+	fmt.Printf("  %-32s %12d %12s\n", "Total", count, size)
+}
+
+func inspectDB(ctx *cli.Context) error {
+	dbs := openDatabases(ctx, true)
+	defer closeDatabases(dbs)
+
+	for _, c := range dbs {
+		stats, err := rawdb.InspectDatabase(c.db)
+		if err != nil {
+			utils.Fatalf("Failed to inspect %s: %v", c.name, err)
+		}
+		printStats(c.name, stats)
+		if c.isRoot {
+			continue
+		}
+		if stats, err = rawdb.InspectAncients(c.db); err != nil {
+			utils.Fatalf("Failed to inspect ancient store of %s: %v", c.name, err)
+		}
+		printStats(c.name+" ancient", stats)
+	}
+	return nil
+}
+
+func compactDB(ctx *cli.Context) error {
+	dbs := openDatabases(ctx, false)
+	defer closeDatabases(dbs)
+
+	for _, c := range dbs {
+		start := time.Now()
+		log.Info("Compacting database", "database", c.name)
+		if err := rawdb.KeyValueStore(c.db).(qkcdb.KeyValueStore).Compact(nil, nil); err != nil {
+			utils.Fatalf("Failed to compact %s: %v", c.name, err)
+		}
+		log.Info("Compacted database", "database", c.name, "elapsed", time.Since(start))
+	}
+	return nil
+}
+
+func freezerStats(ctx *cli.Context) error {
+	dbs := openDatabases(ctx, true)
+	defer closeDatabases(dbs)
+
+	for _, c := range dbs {
+		if c.isRoot {
+			utils.Fatalf("The root chain has no ancient store")
+		}
+		stats, err := rawdb.InspectAncients(c.db)
+		if err != nil {
+			utils.Fatalf("Failed to inspect ancient store of %s: %v", c.name, err)
+		}
+		printStats(c.name, stats)
+	}
+	return nil
+}
+
+func verifyDB(ctx *cli.Context) error {
+	if len(ctx.Args()) != 0 && len(ctx.Args()) != 2 {
+		utils.Fatalf("This command requires zero or two arguments.")
+	}
+	var first, last uint64
+	if len(ctx.Args()) == 2 {
+		var ferr, lerr error
+		first, ferr = strconv.ParseUint(ctx.Args().Get(0), 10, 64)
+		last, lerr = strconv.ParseUint(ctx.Args().Get(1), 10, 64)
+		if ferr != nil || lerr != nil {
+			utils.Fatalf("Verify error in parsing parameters: block number not an integer")
+		}
+	}
+	dbs := openDatabases(ctx, true)
+	defer closeDatabases(dbs)
+
+	for _, c := range dbs {
+		from, to := first, last
+		if head := c.headNumber(); len(ctx.Args()) == 0 || to > head {
+			to = head
+		}
+		start := time.Now()
+		if c.isRoot {
+			if err := rawdb.VerifyRootChain(c.db, from, to); err != nil {
+				utils.Fatalf("%s: %v", c.name, err)
+			}
+			log.Info("Verified root chain", "database", c.name, "first", from, "last", to, "elapsed", time.Since(start))
+			continue
+		}
+		missing, err := rawdb.VerifyMinorChain(c.db, from, to)
+		if err != nil {
+			utils.Fatalf("%s: %v", c.name, err)
+		}
+		if len(missing) != 0 {
+			log.Warn("Missing states", "database", c.name, "count", len(missing), "first", missing[0], "last", missing[len(missing)-1])
+		}
+		log.Info("Verified minor chain", "database", c.name, "first", from, "last", to, "elapsed", time.Since(start))
+	}
+	return nil
+}
+
+func removeStaleDB(ctx *cli.Context) error {
+	stack, cfg := makeConfigNode(ctx)
+	if stack.IsMaster() {
+		utils.Fatalf("The master has no shard data")
+	}
+	if cfg.Service.DataDir == "" {
+		utils.Fatalf("The db commands require a data directory")
+	}
+	slv, err := cfg.Cluster.GetSlaveConfig(cfg.Service.Name)
+	if err != nil {
+		utils.Fatalf("service type error: %v", err)
+	}
+	for id, dir := range shardDirs(&cfg) {
+		if servedShard(&cfg, slv, id) {
+			continue
+		}
+		if ctx.Bool(dryRunFlag.Name) {
+			log.Info("Found stale shard data", "fullShardId", id, "path", dir)
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			utils.Fatalf("Failed to remove %s: %v", dir, err)
+		}
+		log.Info("Removed stale shard data", "fullShardId", id, "path", dir)
+	}
+	return nil
+}
+
+func backupDB(ctx *cli.Context) error {
+	if len(ctx.Args()) != 2 {
+		utils.Fatalf("This command requires two arguments.")
+	}
+	id, err := strconv.ParseUint(ctx.Args().Get(0), 0, 32)
+	if err != nil {
+		utils.Fatalf("Invalid full shard id: %v", err)
+	}
+	dbs := openDatabases(ctx, true)
+	defer closeDatabases(dbs)
+
+	for _, c := range dbs {
+		if c.isRoot {
+			utils.Fatalf("The backup command backs up the shards of a slave")
+		}
+		if c.fullShardID != uint32(id) {
+			continue
+		}
+		start := time.Now()
+		meta := &backup.Metadata{FullShardID: c.fullShardID}
+		if err := backup.BackupFile(ctx.Args().Get(1), c.db, meta); err != nil {
+			utils.Fatalf("Failed to back up %s: %v", c.name, err)
+		}
+		log.Info("Backed up database", "database", c.name, "height", meta.Height, "rootHeight", meta.RootHeight,
+			"ancients", meta.Ancients, "elapsed", time.Since(start))
+		return nil
+	}
+	utils.Fatalf("Shard %d not found", id)
+	return nil
+}
+
+func restoreDB(ctx *cli.Context) error {
+	if len(ctx.Args()) == 0 {
+		utils.Fatalf("This command requires at least one argument.")
+	}
+	stack, cfg := makeConfigNode(ctx)
+	if stack.IsMaster() {
+		utils.Fatalf("The master has no shard data")
+	}
+	if cfg.Service.DataDir == "" {
+		utils.Fatalf("The db commands require a data directory")
+	}
+	slv, err := cfg.Cluster.GetSlaveConfig(cfg.Service.Name)
+	if err != nil {
+		utils.Fatalf("service type error: %v", err)
+	}
+	for _, file := range ctx.Args() {
+		meta, err := backup.ReadMetadata(file)
+		if err != nil {
+			utils.Fatalf("Failed to read %s: %v", file, err)
+		}
+		if !servedShard(&cfg, slv, meta.FullShardID) {
+			utils.Fatalf("Shard %d of %s is not served by the slave", meta.FullShardID, file)
+		}
+		start := time.Now()
+		dir := cfg.Service.ResolvePath(fmt.Sprintf("shard-%d", meta.FullShardID))
+		if err := os.RemoveAll(filepath.Join(dir, "ancient")); err != nil {
+			utils.Fatalf("Failed to remove ancient store of shard %d: %v", meta.FullShardID, err)
+		}
+		kvdb, err := qkcdb.NewDatabase(cfg.Service.DBBackend, filepath.Join(dir, "db"), true, false)
+		if err != nil {
+			utils.Fatalf("Failed to create database of shard %d: %v", meta.FullShardID, err)
+		}
+		db, err := rawdb.NewDatabaseWithFreezer(kvdb, filepath.Join(dir, "ancient"))
+		if err != nil {
+			utils.Fatalf("Failed to create ancient store of shard %d: %v", meta.FullShardID, err)
+		}
+		_, err = backup.RestoreFile(file, db)
+		db.Close()
+		if err != nil {
+			utils.Fatalf("Failed to restore %s: %v", file, err)
+		}
+		log.Info("Restored database", "database", fmt.Sprintf("shard-%d", meta.FullShardID), "height", meta.Height,
+			"rootHeight", meta.RootHeight, "elapsed", time.Since(start))
+	}
+	return nil
+}
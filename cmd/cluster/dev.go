@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/cluster/config"
+	"github.com/QuarkChain/goquarkchain/cluster/master"
+	"github.com/QuarkChain/goquarkchain/cluster/service"
+	"github.com/QuarkChain/goquarkchain/cmd/utils"
+	"github.com/ethereum/go-ethereum/log"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// devCluster runs the master and the slaves of a dev cluster in this process
+// until it is interrupted. The databases are kept in memory unless the data
+// directory is given, and the p2p network is not started.
+func devCluster(ctx *cli.Context) error {
+	cfg, accounts, err := loadDevConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	slaves := make([]*service.Node, 0, len(cfg.SlaveList))
+	for _, slv := range cfg.SlaveList {
+		nodeCfg := defaultNodeConfig()
+		nodeCfg.Name = slv.ID
+		nodeCfg.DataDir = cfg.DbPathRoot
+		nodeCfg.DBBackend = cfg.DBBackend
+		nodeCfg.IPCPath = ""
+		nodeCfg.GRPCEndpoint = fmt.Sprintf("%s:%d", slv.IP, slv.Port)
+		nodeCfg.GRPCReflection = cfg.GRPCReflection
+		nodeCfg.HealthEndpoint = healthEndpoint(slv.HealthPort)
+		stack, err := service.New(&nodeCfg)
+		if err != nil {
+			return fmt.Errorf("failed to create slave %s: %v", slv.ID, err)
+		}
+		stack.SetIsMaster(false)
+		utils.RegisterSlaveService(stack, cfg, slv)
+		slaves = append(slaves, stack)
+	}
+	nodeCfg := defaultNodeConfig()
+	nodeCfg.HealthEndpoint = healthEndpoint(cfg.Master.HealthPort)
+	utils.SetNodeConfig(ctx, &nodeCfg, cfg)
+	stack, err := service.New(&nodeCfg)
+	if err != nil {
+		return fmt.Errorf("failed to create master: %v", err)
+	}
+	stack.SetIsMaster(true)
+	utils.RegisterMasterService(stack, cfg)
+
+	// the master connects to the slaves when it starts
+	for _, slave := range slaves {
+		utils.StartService(slave)
+	}
+	utils.StartService(stack)
+	var mstr *master.QKCMasterBackend
+	if err := stack.Service(&mstr); err != nil {
+		utils.Fatalf("master service not running %v", err)
+	}
+	if err := mstr.Start(); err != nil {
+		utils.Fatalf("Failed to init cluster service: %v", err)
+	}
+	mstr.SetMining(context.Background(), true)
+
+	period := ctx.GlobalUint(utils.DevPeriodFlag.Name)
+	log.Info("Dev cluster started", "slaves", len(slaves), "period", period, "datadir", cfg.DbPathRoot)
+	printDevAccounts(ctx.GlobalString(utils.DevMnemonicFlag.Name), cfg.Quarkchain.GenesisToken, accounts)
+
+	stack.Wait()
+	for _, slave := range slaves {
+		// the slaves are stopped by the interrupt too unless the master failed
+		slave.Stop()
+		slave.Wait()
+	}
+	return nil
+}
+
+// loadDevConfig loads the cluster config and turns it into the dev cluster of
+// the flags, returning the prefunded accounts.
+func loadDevConfig(ctx *cli.Context) (*config.ClusterConfig, []account.Account, error) {
+	cfg := config.NewClusterConfig()
+	if file := ctx.GlobalString(ClusterConfigFlag.Name); file != "" {
+		if err := config.LoadClusterConfig(file, cfg); err != nil {
+			return nil, nil, err
+		}
+	}
+	utils.SetClusterConfig(ctx, cfg)
+
+	accounts, err := config.DevAccounts(ctx.GlobalString(utils.DevMnemonicFlag.Name), ctx.GlobalInt(utils.DevAccountsFlag.Name))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to derive dev accounts: %v", err)
+	}
+	period := uint32(ctx.GlobalUint(utils.DevPeriodFlag.Name))
+	if err := cfg.SetDevMode(ctx.GlobalInt(utils.DevSlavesFlag.Name), period, accounts); err != nil {
+		return nil, nil, err
+	}
+
+	cfg.DbPathRoot = ""
+	if ctx.GlobalIsSet(utils.DataDirFlag.Name) {
+		cfg.DbPathRoot = ctx.GlobalString(utils.DataDirFlag.Name)
+	} else {
+		// nowhere to keep the journal of the in-memory databases
+		cfg.TxJournal = ""
+		cfg.XShardJournal = ""
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return cfg, accounts, nil
+}
+
+func printDevAccounts(mnemonic, token string, accounts []account.Account) {
+	fmt.Println()
+	fmt.Println("Prefunded accounts, the keys are public and must never hold real funds")
+	fmt.Printf("mnemonic\t%s\n", mnemonic)
+	fmt.Printf("balance\t%s wei of %s in every shard\n", config.DevBalance, token)
+	for i, acc := range accounts {
+		fmt.Printf("(%d)\t%s\t%s\n", i, acc.Address(), acc.PrivateKey())
+	}
+	fmt.Println()
+}
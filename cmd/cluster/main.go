@@ -2,11 +2,16 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
 	"sort"
 	"strconv"
+	"syscall"
 
+	"github.com/QuarkChain/goquarkchain/cluster/config"
 	"github.com/QuarkChain/goquarkchain/cluster/master"
 	"github.com/QuarkChain/goquarkchain/cluster/service"
 	"github.com/QuarkChain/goquarkchain/cluster/slave"
@@ -14,6 +19,7 @@ import (
 	"github.com/QuarkChain/goquarkchain/internal/debug"
 	"github.com/elastic/gosigar"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/fjl/memsize/memsizeui"
 	"gopkg.in/urfave/cli.v1"
 )
 
@@ -31,12 +37,18 @@ var (
 		utils.ServiceFlag,
 		utils.DataDirFlag,
 		utils.LogLevelFlag,
+		utils.LogModulesFlag,
+		utils.MetricsTypeFlag,
+		utils.MetricsEndpointFlag,
+		utils.MetricsIntervalFlag,
+		utils.MetricsTagsFlag,
 		utils.CleanFlag,
 		utils.CacheFlag,
 		utils.StartSimulatedMiningFlag,
 		utils.GenesisDirFlag,
 		utils.NetworkIdFlag,
 		utils.DbPathRootFlag,
+		utils.DBBackendFlag,
 		utils.P2pFlag,
 		utils.P2pPortFlag,
 		utils.CheckDBFlag,
@@ -45,9 +57,51 @@ var (
 		utils.CheckDBRBlockBatchFlag,
 
 		utils.EnableTransactionHistoryFlag,
+		utils.TxJournalFlag,
+		utils.XShardJournalFlag,
+		utils.TxIndexRetentionFlag,
+		utils.AncientRootBlocksFlag,
+		utils.ParallelTxWorkersFlag,
+		utils.GCModeFlag,
+		utils.TrieCacheFlag,
+		utils.TrieDirtyCacheFlag,
+		utils.NoPrefetchFlag,
+		utils.TrieTimeLimitFlag,
+		utils.SnapshotFlag,
+		utils.BalanceHistoryFlag,
+		utils.BalanceHistoryAddressesFlag,
+		utils.SnapSyncFlag,
+		utils.AsyncMasterNotifyFlag,
+		utils.KeyStoreDirFlag,
+		utils.LightKDFFlag,
+		utils.ExternalSignerFlag,
+		utils.SlaveRestartTimeoutFlag,
+		utils.AuditLogFlag,
+		utils.TxWatcherFlag,
+		utils.TxWatcherWebhookFlag,
+		utils.NonceManagerFlag,
+		utils.DevFlag,
+		utils.DevSlavesFlag,
+		utils.DevPeriodFlag,
+		utils.DevAccountsFlag,
+		utils.DevMnemonicFlag,
 		utils.MaxPeersFlag,
 		utils.BootnodesFlag,
+		utils.DiscoveryV5Flag,
+		utils.BootnodesV5Flag,
+		utils.StaticNodesFlag,
+		utils.TrustedNodesFlag,
+		utils.AllowlistOnlyFlag,
+		utils.MaxUploadFlag,
+		utils.MaxDownloadFlag,
+		utils.PeerMaxUploadFlag,
+		utils.PeerMaxDownloadFlag,
+		utils.HandshakeAuditLogFlag,
+		utils.MinProtocolVersionFlag,
+		utils.MinQKCProtocolVersionFlag,
+		utils.RequireEIP8Flag,
 		utils.UpnpFlag,
+		utils.NATFlag,
 		utils.PrivkeyFlag,
 	}
 
@@ -61,6 +115,8 @@ var (
 		utils.IPCPathFlag,
 		utils.GRPCAddrFlag,
 		utils.GRPCPortFlag,
+		utils.GRPCReflectionFlag,
+		utils.HealthPortFlag,
 		utils.WSEnableFlag,
 		utils.WSRPCHostFlag,
 		utils.WSRPCPortFlag,
@@ -71,7 +127,23 @@ func init() {
 	// Initialize the CLI app and start Geth
 	app.Action = cluster
 	app.HideVersion = true // we have a command to print the version
-	app.Commands = []cli.Command{}
+	app.Commands = []cli.Command{
+		// See chaincmd.go:
+		importCommand,
+		exportCommand,
+		replayCommand,
+		genesisCommand,
+		// See configcmd.go:
+		configCommand,
+		// See dbcmd.go:
+		dbCommand,
+		// See runcmd.go:
+		runCommand,
+		// See signercmd.go:
+		signerCommand,
+		// See walletcmd.go:
+		walletCommand,
+	}
 	sort.Sort(cli.CommandsByName(app.Commands))
 
 	app.Flags = append(app.Flags, debug.Flags...)
@@ -110,6 +182,13 @@ func init() {
 	}
 }
 
+// memsize reports the memory used by the service on the pprof server.
+var memsize memsizeui.Handler
+
+func init() {
+	http.Handle("/memsize/", http.StripPrefix("/memsize", &memsize))
+}
+
 func main() {
 	if err := app.Run(os.Args); err != nil {
 		fmt.Fprintln(os.Stderr, err)
@@ -124,6 +203,9 @@ func cluster(ctx *cli.Context) error {
 	if args := ctx.Args(); len(args) > 0 {
 		return fmt.Errorf("invalid command: %q", args[0])
 	}
+	if ctx.GlobalBool(utils.DevFlag.Name) {
+		return devCluster(ctx)
+	}
 	node := makeFullNode(ctx)
 	startService(ctx, node)
 	node.Wait()
@@ -134,7 +216,7 @@ func cluster(ctx *cli.Context) error {
 // it unlocks any requested accounts, and starts the RPC/IPC interfaces and the
 // miner.
 func startService(ctx *cli.Context, stack *service.Node) {
-	debug.Memsize.Add("service", stack)
+	memsize.Add("service", stack)
 
 	// Start up the node itself
 	utils.StartService(stack)
@@ -155,10 +237,29 @@ func startService(ctx *cli.Context, stack *service.Node) {
 		if err := stack.StartP2P(); err != nil {
 			utils.Fatalf("failed to start p2p", "err", err)
 		}
+		master.SetConfigLoader(func() (*config.ClusterConfig, error) { return loadClusterConfig(ctx) })
+		reloadOnSighup(func() ([]config.ConfigChange, error) { return master.ReloadConfig(context.Background()) })
 	} else {
 		var slave *slave.SlaveBackend
 		if err := stack.Service(&slave); err != nil {
 			utils.Fatalf("slave service not running %v", err)
 		}
+		slave.SetConfigLoader(func() (*config.ClusterConfig, error) { return loadClusterConfig(ctx) })
+		reloadOnSighup(slave.ReloadConfig)
 	}
 }
+
+// reloadOnSighup reloads the cluster config of the service on SIGHUP, the
+// master asks the slaves to reload theirs too.
+func reloadOnSighup(reload func() ([]config.ConfigChange, error)) {
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGHUP)
+	go func() {
+		for range sigc {
+			log.Info("Got SIGHUP, reloading config")
+			if _, err := reload(); err != nil {
+				log.Error("Failed to reload config", "err", err)
+			}
+		}
+	}()
+}
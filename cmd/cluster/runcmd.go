@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/cluster/config"
+	"github.com/QuarkChain/goquarkchain/cmd/utils"
+	"github.com/ethereum/go-ethereum/log"
+	"gopkg.in/urfave/cli.v1"
+)
+
+const (
+	minRestartDelay            = time.Second
+	maxRestartDelay            = 30 * time.Second
+	stableRunTime              = time.Minute      // a slave running this long restarts without delay again
+	slaveStartTimeout          = time.Minute      // for the gRPC endpoint of a started slave to be up
+	slaveStopTimeout           = 10 * time.Second // after which a stopping slave is killed
+	defaultSlaveRestartTimeout = 60
+)
+
+var (
+	slaveLogDirFlag = cli.StringFlag{
+		Name:  "logdir",
+		Usage: "Directory to append the output of each slave to, as <slave ID>.log",
+	}
+
+	runCommand = cli.Command{
+		Action:    runCluster,
+		Name:      "run",
+		Usage:     "Run the master with the slaves of the cluster config as child processes",
+		ArgsUsage: " ",
+		Flags:     []cli.Flag{slaveLogDirFlag},
+		Category:  "MISCELLANEOUS COMMANDS",
+		Description: `
+The run command starts a process for each slave of the cluster config on this
+host, with the same global flags as the master, and runs the master once the
+slaves are up. The output of the slaves is printed line by line prefixed by the
+slave ID, and appended to <logdir>/<slave ID>.log if --logdir is given.
+
+A slave which exits is restarted with a delay doubling from 1s to 30s, and the
+master initializes it again if it is back within --slave_restart_timeout, 60
+seconds unless configured. The slaves are stopped when the master stops.`,
+	}
+)
+
+func runCluster(ctx *cli.Context) error {
+	if ctx.GlobalString(utils.ServiceFlag.Name) != clientIdentifier {
+		utils.Fatalf("The run command runs the master, --%s must not be given", utils.ServiceFlag.Name)
+	}
+	cfg, err := loadClusterConfig(ctx)
+	if err != nil {
+		utils.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.Master.SlaveRestartTimeout == 0 && !ctx.GlobalIsSet(utils.SlaveRestartTimeoutFlag.Name) {
+		ctx.GlobalSet(utils.SlaveRestartTimeoutFlag.Name, strconv.Itoa(defaultSlaveRestartTimeout))
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		utils.Fatalf("Failed to find the executable: %v", err)
+	}
+
+	var (
+		args   = slaveArgs(ctx)
+		output = &syncWriter{w: os.Stderr}
+		slaves = make([]*slaveProcess, 0, len(cfg.SlaveList))
+	)
+	for _, slv := range cfg.SlaveList {
+		proc, err := newSlaveProcess(slv, executable, args, output, ctx.String(slaveLogDirFlag.Name))
+		if err != nil {
+			stopSlaves(slaves)
+			utils.Fatalf("Failed to start slave %s: %v", slv.ID, err)
+		}
+		go proc.run()
+		slaves = append(slaves, proc)
+	}
+	for _, proc := range slaves {
+		if err := proc.waitUp(slaveStartTimeout); err != nil {
+			stopSlaves(slaves)
+			utils.Fatalf("Slave %s is not up: %v", proc.id, err)
+		}
+	}
+
+	// the slaves are not restarted once the process is interrupted
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigc
+		for _, proc := range slaves {
+			proc.quitOnce.Do(func() { close(proc.quit) })
+		}
+	}()
+
+	node := makeFullNode(ctx)
+	startService(ctx, node)
+	node.Wait()
+	stopSlaves(slaves)
+	return nil
+}
+
+// slaveArgs returns the global flags set for this process, which the slaves
+// are started with.
+func slaveArgs(ctx *cli.Context) []string {
+	args := make([]string, 0)
+	for _, name := range ctx.GlobalFlagNames() {
+		// the health port flag is the one of the master, the slaves use their HEALTH_PORT
+		if name == utils.ServiceFlag.Name || name == utils.HealthPortFlag.Name || !ctx.GlobalIsSet(name) {
+			continue
+		}
+		for _, value := range flagValues(ctx.GlobalGeneric(name)) {
+			args = append(args, fmt.Sprintf("--%s=%s", name, value))
+		}
+	}
+	return args
+}
+
+// flagValues returns the values the flag is set to, one per element for the
+// slice flags, which are set by repeating them, and their own string for the
+// other flags.
+func flagValues(value interface{}) []string {
+	switch value := value.(type) {
+	case *cli.StringSlice:
+		return *value
+	case *cli.IntSlice:
+		values := make([]string, 0, len(*value))
+		for _, v := range *value {
+			values = append(values, strconv.Itoa(v))
+		}
+		return values
+	case *cli.Int64Slice:
+		values := make([]string, 0, len(*value))
+		for _, v := range *value {
+			values = append(values, strconv.FormatInt(v, 10))
+		}
+		return values
+	}
+	return []string{fmt.Sprintf("%v", value)}
+}
+
+func stopSlaves(slaves []*slaveProcess) {
+	var wg sync.WaitGroup
+	for _, proc := range slaves {
+		wg.Add(1)
+		go func(proc *slaveProcess) {
+			defer wg.Done()
+			proc.stop()
+		}(proc)
+	}
+	wg.Wait()
+}
+
+// slaveProcess runs a slave as a child process, restarting it with backoff
+// when it exits until it is stopped.
+type slaveProcess struct {
+	id       string
+	endpoint string
+	path     string
+	args     []string
+	lines    *lineWriter
+	output   io.Writer
+
+	lock     sync.Mutex
+	cmd      *exec.Cmd
+	exited   chan struct{} // closed when the current process exits
+	quit     chan struct{}
+	quitOnce sync.Once
+	done     chan struct{}
+}
+
+func newSlaveProcess(slv *config.SlaveConfig, path string, args []string, output *syncWriter, logDir string) (*slaveProcess, error) {
+	lines := &lineWriter{out: output, prefix: []byte(slv.ID + " | ")}
+	var out io.Writer = lines
+	if logDir != "" {
+		if err := os.MkdirAll(logDir, 0700); err != nil {
+			return nil, err
+		}
+		file, err := os.OpenFile(filepath.Join(logDir, slv.ID+".log"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, err
+		}
+		out = io.MultiWriter(out, file)
+	}
+	slaveArgs := make([]string, len(args), len(args)+1)
+	copy(slaveArgs, args)
+	return &slaveProcess{
+		id:       slv.ID,
+		endpoint: fmt.Sprintf("%s:%d", slv.IP, slv.Port),
+		path:     path,
+		args:     append(slaveArgs, fmt.Sprintf("--%s=%s", utils.ServiceFlag.Name, slv.ID)),
+		lines:    lines,
+		output:   out,
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+func (p *slaveProcess) run() {
+	defer close(p.done)
+	delay := minRestartDelay
+	for {
+		started := time.Now()
+		cmd := exec.Command(p.path, p.args...)
+		cmd.Stdout, cmd.Stderr = p.output, p.output
+		exited := make(chan struct{})
+		p.lock.Lock()
+		select {
+		case <-p.quit:
+			// stopped before the restart
+			p.lock.Unlock()
+			return
+		default:
+		}
+		p.cmd, p.exited = cmd, exited
+		err := cmd.Start()
+		p.lock.Unlock()
+		if err == nil {
+			log.Info("Slave started", "slave", p.id, "pid", cmd.Process.Pid)
+			err = cmd.Wait()
+		}
+		close(exited)
+		p.lines.Flush()
+
+		select {
+		case <-p.quit:
+			log.Info("Slave stopped", "slave", p.id, "err", err)
+			return
+		default:
+		}
+		if time.Since(started) > stableRunTime {
+			delay = minRestartDelay
+		}
+		log.Error("Slave exited, restarting", "slave", p.id, "err", err, "delay", delay)
+		select {
+		case <-p.quit:
+			return
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > maxRestartDelay {
+			delay = maxRestartDelay
+		}
+	}
+}
+
+// waitUp waits for the gRPC endpoint of the slave to accept connections.
+func (p *slaveProcess) waitUp(timeout time.Duration) error {
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(500 * time.Millisecond) {
+		select {
+		case <-p.done:
+			return fmt.Errorf("supervisor of slave %s stopped", p.id)
+		default:
+		}
+		if conn, err := net.DialTimeout("tcp", p.endpoint, time.Second); err == nil {
+			conn.Close()
+			return nil
+		}
+	}
+	return fmt.Errorf("timeout after %v waiting for %s", timeout, p.endpoint)
+}
+
+// stop terminates the slave, which is killed if it does not exit in time, and
+// waits for the supervisor to return.
+func (p *slaveProcess) stop() {
+	p.quitOnce.Do(func() { close(p.quit) })
+	p.lock.Lock()
+	cmd, exited := p.cmd, p.exited
+	p.lock.Unlock()
+	if cmd != nil && cmd.Process != nil {
+		cmd.Process.Signal(syscall.SIGTERM)
+		select {
+		case <-exited:
+		case <-time.After(slaveStopTimeout):
+			log.Warn("Killing slave", "slave", p.id)
+			cmd.Process.Kill()
+		}
+	}
+	<-p.done
+}
+
+// syncWriter serializes the writes of the slaves to the output.
+type syncWriter struct {
+	lock sync.Mutex
+	w    io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.w.Write(p)
+}
+
+// lineWriter writes whole lines prefixed by the slave ID, so the lines of
+// different slaves are not mixed.
+type lineWriter struct {
+	out    io.Writer
+	prefix []byte
+	buf    []byte
+}
+
+func (l *lineWriter) Write(p []byte) (int, error) {
+	l.buf = append(l.buf, p...)
+	for {
+		i := bytes.IndexByte(l.buf, '\n')
+		if i < 0 {
+			break
+		}
+		l.writeLine(l.buf[:i+1])
+		l.buf = l.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush writes the incomplete last line.
+func (l *lineWriter) Flush() {
+	if len(l.buf) > 0 {
+		l.writeLine(append(l.buf, '\n'))
+		l.buf = nil
+	}
+}
+
+func (l *lineWriter) writeLine(line []byte) {
+	l.out.Write(append(append(make([]byte, 0, len(l.prefix)+len(line)), l.prefix...), line...))
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/cmd/utils"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/urfave/cli.v1"
+)
+
+func TestSlaveArgs(t *testing.T) {
+	flags := []cli.Flag{
+		utils.ServiceFlag,
+		utils.HealthPortFlag,
+		cli.StringFlag{Name: "name"},
+		cli.BoolFlag{Name: "enabled"},
+		cli.IntFlag{Name: "unset", Value: 3},
+		cli.StringSliceFlag{Name: "nodes"},
+		cli.IntSliceFlag{Name: "ports"},
+		cli.Int64SliceFlag{Name: "ids"},
+	}
+	parse := func(args []string, action func(ctx *cli.Context)) {
+		app := cli.NewApp()
+		app.Flags = flags
+		app.Action = func(ctx *cli.Context) error {
+			action(ctx)
+			return nil
+		}
+		assert.NoError(t, app.Run(append([]string{"cluster"}, args...)))
+	}
+
+	var args []string
+	parse([]string{
+		"--" + utils.ServiceFlag.Name + "=S0", "--" + utils.HealthPortFlag.Name + "=8080",
+		"--name=a b", "--enabled",
+		"--nodes=n1", "--nodes=n2", "--ports=1", "--ports=2", "--ids=10",
+	}, func(ctx *cli.Context) {
+		args = slaveArgs(ctx)
+	})
+	assert.ElementsMatch(t, []string{
+		"--name=a b", "--enabled=true",
+		"--nodes=n1", "--nodes=n2", "--ports=1", "--ports=2", "--ids=10",
+	}, args)
+
+	// the slaves parse the flags to the values of the master
+	parse(args, func(ctx *cli.Context) {
+		assert.Equal(t, "a b", ctx.GlobalString("name"))
+		assert.True(t, ctx.GlobalBool("enabled"))
+		assert.Equal(t, 3, ctx.GlobalInt("unset"))
+		assert.Equal(t, []string{"n1", "n2"}, ctx.GlobalStringSlice("nodes"))
+		assert.Equal(t, []int{1, 2}, ctx.GlobalIntSlice("ports"))
+		assert.Equal(t, []int64{10}, ctx.GlobalInt64Slice("ids"))
+		assert.False(t, ctx.GlobalIsSet(utils.ServiceFlag.Name))
+	})
+}
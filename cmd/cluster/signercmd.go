@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/QuarkChain/goquarkchain/account/external"
+	"github.com/QuarkChain/goquarkchain/account/keystore"
+	"github.com/QuarkChain/goquarkchain/cmd/utils"
+	"github.com/QuarkChain/goquarkchain/rpc"
+	"github.com/ethereum/go-ethereum/log"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	rulesFileFlag = cli.StringFlag{
+		Name:  "rules",
+		Usage: "JSON file of the approval rules",
+	}
+	signerIPCFlag = cli.StringFlag{
+		Name:  "signeripc",
+		Usage: "IPC path of the signer (default = signer.ipc inside the datadir)",
+	}
+
+	signerCommand = cli.Command{
+		Action:    runSigner,
+		Name:      "signer",
+		Usage:     "Run an external signer for a node",
+		ArgsUsage: " ",
+		Category:  "ACCOUNT COMMANDS",
+		Flags: []cli.Flag{
+			rulesFileFlag,
+			signerIPCFlag,
+			passwordFileFlag,
+		},
+		Description: `
+The signer command unlocks the accounts of the keystore with the password, and
+signs the root blocks and the transactions approved by the rules for the nodes
+connected to its IPC path with --signer. The keys never leave the signer.
+
+The rules file may set:
+  allowedTo              recipients the transactions may be sent to
+  allowContractCreation  whether the transactions may create contracts
+  maxValue               maximal value of a transaction, in hex
+  maxGasPrice            maximal gas price of a transaction, in hex
+  networkId              network of the transactions
+  rootSigner             recipient of the account signing the root blocks
+  rootBlockCoinbases     recipients the root blocks may pay the coinbase to
+Without rules every transaction is approved and no root block is signed.`,
+	}
+)
+
+func runSigner(ctx *cli.Context) error {
+	stack, cfg := makeConfigNode(ctx)
+	keydir := cfg.Cluster.KeyStoreDir
+	if keydir == "" {
+		keydir = stack.ResolvePath("keystore")
+	}
+	if keydir == "" {
+		utils.Fatalf("No keystore directory, set --%s or --%s", utils.KeyStoreDirFlag.Name, utils.DataDirFlag.Name)
+	}
+	ipcPath := ctx.String(signerIPCFlag.Name)
+	if ipcPath == "" {
+		ipcPath = stack.ResolvePath("signer.ipc")
+	}
+	if ipcPath == "" {
+		utils.Fatalf("No IPC path, set --%s or --%s", signerIPCFlag.Name, utils.DataDirFlag.Name)
+	}
+
+	rules := new(external.Rules)
+	if file := ctx.String(rulesFileFlag.Name); file != "" {
+		var err error
+		if rules, err = external.LoadRules(file); err != nil {
+			utils.Fatalf("Failed to load rules: %v", err)
+		}
+	}
+	var password string
+	if file := ctx.String(passwordFileFlag.Name); file != "" {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			utils.Fatalf("Failed to read password file: %v", err)
+		}
+		password = strings.TrimRight(strings.SplitN(string(content), "\n", 2)[0], "\r")
+	} else {
+		password = readSecret("Password: ")
+	}
+
+	ks := keystore.NewKeyStore(keydir, keystore.StandardScryptN, keystore.StandardScryptP)
+	defer ks.Close()
+	for _, a := range ks.Accounts() {
+		if err := ks.Unlock(a.Address.Recipient, password); err != nil {
+			log.Warn("Failed to unlock account", "address", a.Address.ToHex(), "err", err)
+			continue
+		}
+		fmt.Printf("%s\tunlocked\n", a.Address.ToHex())
+	}
+	if rules.RootSigner != nil && !ks.IsUnlocked(*rules.RootSigner) {
+		utils.Fatalf("Root signer %x is not unlocked", *rules.RootSigner)
+	}
+
+	listener, server, err := rpc.StartIPCEndpoint(ipcPath, external.NewSignerAPI(ks, rules).APIs())
+	if err != nil {
+		utils.Fatalf("Failed to start signer: %v", err)
+	}
+	defer server.Stop()
+	defer listener.Close()
+	log.Info("Signer started", "ipc", ipcPath)
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+	<-sigc
+	log.Info("Signer stopped")
+	return nil
+}
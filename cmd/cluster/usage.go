@@ -50,21 +50,62 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.DataDirFlag,
 			ClusterConfigFlag,
 			utils.LogLevelFlag,
+			utils.LogModulesFlag,
+			utils.MetricsTypeFlag,
+			utils.MetricsEndpointFlag,
+			utils.MetricsIntervalFlag,
+			utils.MetricsTagsFlag,
 			utils.CleanFlag,
 			utils.CacheFlag,
 			utils.StartSimulatedMiningFlag,
 			utils.GenesisDirFlag,
 			utils.NetworkIdFlag,
 			utils.DbPathRootFlag,
+			utils.DBBackendFlag,
 			utils.GRPCAddrFlag,
 			utils.GRPCPortFlag,
+			utils.GRPCReflectionFlag,
+			utils.HealthPortFlag,
 			utils.EnableTransactionHistoryFlag,
+			utils.TxJournalFlag,
+			utils.XShardJournalFlag,
+			utils.TxIndexRetentionFlag,
+			utils.AncientRootBlocksFlag,
+			utils.ParallelTxWorkersFlag,
+			utils.GCModeFlag,
+			utils.TrieCacheFlag,
+			utils.TrieDirtyCacheFlag,
+			utils.NoPrefetchFlag,
+			utils.TrieTimeLimitFlag,
+			utils.SnapshotFlag,
+			utils.BalanceHistoryFlag,
+			utils.BalanceHistoryAddressesFlag,
+			utils.SnapSyncFlag,
+			utils.AsyncMasterNotifyFlag,
+			utils.KeyStoreDirFlag,
+			utils.LightKDFFlag,
+			utils.ExternalSignerFlag,
+			utils.SlaveRestartTimeoutFlag,
+			utils.AuditLogFlag,
+			utils.TxWatcherFlag,
+			utils.TxWatcherWebhookFlag,
+			utils.NonceManagerFlag,
 			utils.CheckDBFlag,
 			utils.CheckDBRBlockFromFlag,
 			utils.CheckDBRBlockToFlag,
 			utils.CheckDBRBlockBatchFlag,
 		},
 	},
+	{
+		Name: "DEVELOPER CHAIN",
+		Flags: []cli.Flag{
+			utils.DevFlag,
+			utils.DevSlavesFlag,
+			utils.DevPeriodFlag,
+			utils.DevAccountsFlag,
+			utils.DevMnemonicFlag,
+		},
+	},
 	{
 		Name: "P2P",
 		Flags: []cli.Flag{
@@ -72,7 +113,21 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.P2pPortFlag,
 			utils.MaxPeersFlag,
 			utils.BootnodesFlag,
+			utils.DiscoveryV5Flag,
+			utils.BootnodesV5Flag,
+			utils.StaticNodesFlag,
+			utils.TrustedNodesFlag,
+			utils.AllowlistOnlyFlag,
+			utils.MaxUploadFlag,
+			utils.MaxDownloadFlag,
+			utils.PeerMaxUploadFlag,
+			utils.PeerMaxDownloadFlag,
+			utils.HandshakeAuditLogFlag,
+			utils.MinProtocolVersionFlag,
+			utils.MinQKCProtocolVersionFlag,
+			utils.RequireEIP8Flag,
 			utils.UpnpFlag,
+			utils.NATFlag,
 			utils.PrivkeyFlag,
 		},
 	},
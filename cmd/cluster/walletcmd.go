@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/account/keystore"
+	"github.com/QuarkChain/goquarkchain/cmd/utils"
+	"github.com/ethereum/go-ethereum/crypto"
+	"golang.org/x/crypto/ssh/terminal"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	wordsFlag = cli.IntFlag{
+		Name:  "words",
+		Usage: "Number of words of the new mnemonic (12, 15, 18, 21 or 24)",
+		Value: 24,
+	}
+	derivationPathFlag = cli.StringFlag{
+		Name:  "path",
+		Usage: "Base derivation path of the addresses",
+		Value: account.DefaultBaseDerivationPath.String(),
+	}
+	indexFlag = cli.IntFlag{
+		Name:  "index",
+		Usage: "Index of the first address",
+	}
+	countFlag = cli.IntFlag{
+		Name:  "count",
+		Usage: "Number of the addresses",
+		Value: 1,
+	}
+	chainFlag = cli.IntFlag{
+		Name:  "chain",
+		Usage: "Spread the addresses over the shards of the chain (default = the default shard of each address)",
+	}
+	fullShardKeyFlag = cli.StringFlag{
+		Name:  "fullshardkey",
+		Usage: "Put all the addresses on the full shard key, in hex (default = the default shard of each address)",
+	}
+	bip39PassphraseFlag = cli.BoolFlag{
+		Name:  "bip39passphrase",
+		Usage: "Prompt for the BIP-39 passphrase protecting the mnemonic",
+	}
+	passwordFileFlag = cli.StringFlag{
+		Name:  "password",
+		Usage: "Password file to encrypt the imported keys with",
+	}
+
+	walletFlags = []cli.Flag{
+		derivationPathFlag,
+		indexFlag,
+		countFlag,
+		chainFlag,
+		fullShardKeyFlag,
+		bip39PassphraseFlag,
+	}
+
+	walletCommand = cli.Command{
+		Name:      "wallet",
+		Usage:     "Manage the HD wallet of a mnemonic",
+		ArgsUsage: "",
+		Category:  "ACCOUNT COMMANDS",
+		Description: `
+The wallet commands create a BIP-39 mnemonic, and derive the keys of its
+addresses along the BIP-44 path m/44'/99999999'/0'/0/<index> by BIP-32. The
+full shard key of an address is the default one of its recipient, unless the
+addresses are spread over the shards of a chain with --chain, or put on one
+full shard key with --fullshardkey. The mnemonic is read from the terminal, or
+from the first line of the standard input.`,
+		Subcommands: []cli.Command{
+			{
+				Action:    newWallet,
+				Name:      "new",
+				Usage:     "Create a new mnemonic",
+				ArgsUsage: " ",
+				Flags:     append([]cli.Flag{wordsFlag}, walletFlags...),
+				Description: `
+The new command prints a new random mnemonic and the addresses derived from it.
+The mnemonic is the only backup of the keys, write it down and keep it secret.`,
+			},
+			{
+				Action:    restoreWallet,
+				Name:      "restore",
+				Usage:     "Import the keys of a mnemonic into the keystore",
+				ArgsUsage: " ",
+				Flags:     append([]cli.Flag{passwordFileFlag}, walletFlags...),
+				Description: `
+The restore command derives the keys of the addresses from the mnemonic, and
+stores them encrypted with the password in the keystore of --keystore, or of
+the data directory. The keys in the keystore already are skipped.`,
+			},
+			{
+				Action:    deriveWallet,
+				Name:      "derive",
+				Usage:     "Print the addresses of a mnemonic",
+				ArgsUsage: " ",
+				Flags:     walletFlags,
+				Description: `
+The derive command prints the derivation paths and the addresses derived from
+the mnemonic, e.g. to find the addresses of the shards of a chain with --chain.`,
+			},
+		},
+	}
+)
+
+func newWallet(ctx *cli.Context) error {
+	words := ctx.Int(wordsFlag.Name)
+	if words%3 != 0 {
+		utils.Fatalf("Invalid number of words %d", words)
+	}
+	mnemonic, err := account.NewMnemonic(words * 32 / 3)
+	if err != nil {
+		utils.Fatalf("Invalid number of words %d: %v", words, err)
+	}
+	fmt.Printf("mnemonic\t%s\n", mnemonic)
+	printAddresses(ctx, mnemonic)
+	return nil
+}
+
+func restoreWallet(ctx *cli.Context) error {
+	stack, cfg := makeConfigNode(ctx)
+	keydir := cfg.Cluster.KeyStoreDir
+	if keydir == "" {
+		keydir = stack.ResolvePath("keystore")
+	}
+	if keydir == "" {
+		utils.Fatalf("No keystore directory, set --%s or --%s", utils.KeyStoreDirFlag.Name, utils.DataDirFlag.Name)
+	}
+	scryptN, scryptP := keystore.StandardScryptN, keystore.StandardScryptP
+	if cfg.Cluster.LightKDF {
+		scryptN, scryptP = keystore.LightScryptN, keystore.LightScryptP
+	}
+
+	wallet, start, count, strategy := openWallet(ctx, readSecret("Mnemonic: "))
+	var password string
+	if file := ctx.String(passwordFileFlag.Name); file != "" {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			utils.Fatalf("Failed to read password file: %v", err)
+		}
+		password = strings.TrimRight(strings.SplitN(string(content), "\n", 2)[0], "\r")
+	} else {
+		password = readSecret("Password: ")
+		if readSecret("Repeat password: ") != password {
+			utils.Fatalf("Passwords do not match")
+		}
+	}
+
+	ks := keystore.NewKeyStore(keydir, scryptN, scryptP)
+	defer ks.Close()
+	for index := start; index < start+count; index++ {
+		address, err := wallet.Address(index, strategy)
+		if err != nil {
+			utils.Fatalf("Failed to derive address %d: %v", index, err)
+		}
+		identity, _ := wallet.Identity(index)
+		privateKey, err := crypto.ToECDSA(identity.GetKey().Bytes())
+		if err != nil {
+			utils.Fatalf("Failed to derive key %d: %v", index, err)
+		}
+		a, err := ks.ImportECDSA(privateKey, password, &address.FullShardKey)
+		if err == keystore.ErrAccountAlreadyExists {
+			fmt.Printf("%s\t%s\talready imported\n", wallet.Path(index), address.ToHex())
+			continue
+		}
+		if err != nil {
+			utils.Fatalf("Failed to import key %d: %v", index, err)
+		}
+		fmt.Printf("%s\t%s\t%s\n", wallet.Path(index), address.ToHex(), a.Path)
+	}
+	return nil
+}
+
+func deriveWallet(ctx *cli.Context) error {
+	printAddresses(ctx, readSecret("Mnemonic: "))
+	return nil
+}
+
+func printAddresses(ctx *cli.Context, mnemonic string) {
+	wallet, start, count, strategy := openWallet(ctx, mnemonic)
+	for index := start; index < start+count; index++ {
+		address, err := wallet.Address(index, strategy)
+		if err != nil {
+			utils.Fatalf("Failed to derive address %d: %v", index, err)
+		}
+		fmt.Printf("%s\t%s\n", wallet.Path(index), address.ToHex())
+	}
+}
+
+// openWallet creates the wallet of the mnemonic, and returns the range of the
+// indexes and the full shard key strategy of the addresses given by the flags.
+func openWallet(ctx *cli.Context, mnemonic string) (*account.HDWallet, uint32, uint32, account.FullShardKeyStrategy) {
+	basePath, err := account.ParseDerivationPath(ctx.String(derivationPathFlag.Name))
+	if err != nil {
+		utils.Fatalf("%v", err)
+	}
+	var passphrase string
+	if ctx.Bool(bip39PassphraseFlag.Name) {
+		passphrase = readSecret("BIP-39 passphrase: ")
+	}
+	wallet, err := account.NewHDWallet(mnemonic, passphrase, basePath)
+	if err != nil {
+		utils.Fatalf("Failed to open wallet: %v", err)
+	}
+	if ctx.Int(indexFlag.Name) < 0 || ctx.Int(countFlag.Name) < 0 {
+		utils.Fatalf("Invalid index or count")
+	}
+
+	strategy := account.FullShardKeyStrategy(account.DefaultFullShardKey)
+	switch {
+	case ctx.IsSet(fullShardKeyFlag.Name) && ctx.IsSet(chainFlag.Name):
+		utils.Fatalf("Only one of --%s and --%s can be set", fullShardKeyFlag.Name, chainFlag.Name)
+	case ctx.IsSet(fullShardKeyFlag.Name):
+		var fullShardKey uint32
+		if _, err := fmt.Sscanf(strings.TrimPrefix(ctx.String(fullShardKeyFlag.Name), "0x"), "%x", &fullShardKey); err != nil {
+			utils.Fatalf("Invalid full shard key: %v", err)
+		}
+		strategy = account.FixedFullShardKey(fullShardKey)
+	case ctx.IsSet(chainFlag.Name):
+		_, cfg := makeConfigNode(ctx)
+		chainID := uint32(ctx.Int(chainFlag.Name))
+		shardSize, err := cfg.Cluster.Quarkchain.GetShardSizeByChainId(chainID)
+		if err != nil {
+			utils.Fatalf("%v", err)
+		}
+		if strategy, err = account.RoundRobinShards(chainID, shardSize); err != nil {
+			utils.Fatalf("%v", err)
+		}
+	}
+	return wallet, uint32(ctx.Int(indexFlag.Name)), uint32(ctx.Int(countFlag.Name)), strategy
+}
+
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// readSecret reads a line from the terminal without echo, or from the standard
+// input if it is not a terminal.
+func readSecret(prompt string) string {
+	fd := int(os.Stdin.Fd())
+	if terminal.IsTerminal(fd) {
+		fmt.Fprint(os.Stderr, prompt)
+		secret, err := terminal.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			utils.Fatalf("Failed to read %s%v", prompt, err)
+		}
+		return strings.TrimSpace(string(secret))
+	}
+	line, err := stdinReader.ReadString('\n')
+	if err != nil && line == "" {
+		utils.Fatalf("Failed to read %s%v", prompt, err)
+	}
+	return strings.TrimSpace(line)
+}
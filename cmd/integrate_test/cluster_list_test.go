@@ -3,6 +3,7 @@
 package test
 
 import (
+	"context"
 	"github.com/QuarkChain/goquarkchain/account"
 	"github.com/QuarkChain/goquarkchain/cluster/config"
 	"github.com/QuarkChain/goquarkchain/cluster/shard"
@@ -234,7 +235,7 @@ func TestGetPrimaryAccountData(t *testing.T) {
 	assert.Equal(t, accData[fullShardId].TransactionCount, uint64(0))
 
 	tx := createTx(geneAcc.QKCAddress, nil)
-	if err := mstr.AddTransaction(tx); err != nil {
+	if err := mstr.AddTransaction(context.Background(), tx); err != nil {
 		t.Error("failed to add tx", "err", err)
 	}
 
@@ -272,7 +273,7 @@ func TestAddTransaction(t *testing.T) {
 
 	// send tx in shard 0
 	tx0 := createTx(geneAcc.QKCAddress, nil)
-	if err := mstr0.AddTransaction(tx0); err != nil {
+	if err := mstr0.AddTransaction(context.Background(), tx0); err != nil {
 		t.Error("failed to add transaction", "err", err)
 	}
 	assert.Equal(t, retryTrueWithTimeout(func() bool {
@@ -286,7 +287,7 @@ func TestAddTransaction(t *testing.T) {
 	// send the same tx in shard 1
 	addr := geneAcc.QKCAddress.AddressInShard(id1)
 	tx1 := createTx(addr, nil)
-	if err := mstr0.AddTransaction(tx1); err != nil {
+	if err := mstr0.AddTransaction(context.Background(), tx1); err != nil {
 		t.Error("failed to add transaction", "err", err)
 	}
 	assert.Equal(t, retryTrueWithTimeout(func() bool {
@@ -521,7 +522,7 @@ func TestBroadcastCrossShardTransactions(t *testing.T) {
 	clstrList[0].CreateAndInsertBlocks([]uint32{id0, id1})
 
 	tx := createTx(geneAcc.QKCAddress, &toAddr)
-	err := mstr.AddTransaction(tx)
+	err := mstr.AddTransaction(context.Background(), tx)
 	assert.NoError(t, err)
 
 	iB0, _, _, err := shrd0.CreateBlockToMine()
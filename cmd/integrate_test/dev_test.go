@@ -0,0 +1,79 @@
+// +build integrationTest
+
+package test
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/cluster/config"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func devTransfer(t *testing.T, cfg *config.ClusterConfig, from account.Account, fromAddr, to account.Address, nonce uint64, value *big.Int) *types.Transaction {
+	evmTx := types.NewEvmTransaction(nonce, to.Recipient, value, uint64(30000), new(big.Int).SetUint64(1e9+1),
+		fromAddr.FullShardKey, to.FullShardKey, cfg.Quarkchain.NetworkID, 0, []byte{}, testGenesisTokenID, testGenesisTokenID)
+	key := from.Identity.GetKey()
+	prvKey, err := crypto.ToECDSA(key[:])
+	assert.NoError(t, err)
+	evmTx, err = types.SignTx(evmTx, types.MakeSigner(evmTx.NetworkId()), prvKey)
+	assert.NoError(t, err)
+	return &types.Transaction{EvmTx: evmTx, TxType: types.EvmTx}
+}
+
+func TestDevClusterMinesOnDemand(t *testing.T) {
+	accounts, err := config.DevAccounts(config.DevMnemonic, 2)
+	assert.NoError(t, err)
+	cfg := config.NewClusterConfig()
+	cfg.Quarkchain.ChainSize = 2
+	assert.NoError(t, cfg.SetDevMode(2, 0, accounts))
+	assert.NoError(t, cfg.Validate())
+	cfg.Clean = true
+
+	clstr := makeClusterNode(0, cfg, nil)
+	assert.NoError(t, clstr.Start())
+	defer clstr.Stop()
+	mstr := clstr.GetMaster()
+	mstr.SetMining(true)
+
+	fullShardIDs := cfg.Quarkchain.GetGenesisShardIds()
+	fromShard, toShard := fullShardIDs[0], fullShardIDs[0]
+	for _, id := range fullShardIDs {
+		if id>>16 != fromShard>>16 {
+			toShard = id
+		}
+	}
+	from := accounts[0].QKCAddress.AddressInShard(fromShard)
+	value := big.NewInt(1000)
+	balance := func(addr account.Address) *big.Int {
+		data, err := mstr.GetPrimaryAccountData(&addr, nil)
+		if err != nil {
+			return nil
+		}
+		return data.Balance.GetTokenBalance(testGenesisTokenID)
+	}
+
+	// no work, no blocks
+	assert.Equal(t, uint32(0), mstr.GetCurrRootHeader().Number)
+
+	// a transfer in the shard is mined at once
+	to := accounts[1].QKCAddress.AddressInShard(fromShard)
+	assert.NoError(t, mstr.AddTransaction(context.Background(), devTransfer(t, cfg, accounts[0], from, to, 0, value)))
+	expected := new(big.Int).Add(config.DevBalance, value)
+	assert.True(t, retryTrueWithTimeout(func() bool {
+		b := balance(to)
+		return b != nil && b.Cmp(expected) == 0
+	}, 10))
+
+	// a cross-shard transfer is received after the root block confirming it
+	to = accounts[1].QKCAddress.AddressInShard(toShard)
+	assert.NoError(t, mstr.AddTransaction(context.Background(), devTransfer(t, cfg, accounts[0], from, to, 1, value)))
+	assert.True(t, retryTrueWithTimeout(func() bool {
+		b := balance(to)
+		return b != nil && b.Cmp(expected) == 0
+	}, 20))
+}
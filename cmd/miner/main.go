@@ -2,13 +2,11 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"github.com/QuarkChain/goquarkchain/cluster/config"
 	"github.com/QuarkChain/goquarkchain/consensus/ethash"
-	"io/ioutil"
 	"log"
 	"math/big"
 	"strconv"
@@ -258,17 +256,6 @@ func submitWorkRPC(shardID *uint32, work consensus.MiningWork, res consensus.Min
 	return nil
 }
 
-func loadConfig(file string, cfg *config.ClusterConfig) error {
-	var (
-		content []byte
-		err     error
-	)
-	if content, err = ioutil.ReadFile(file); err != nil {
-		return errors.New(file + ", " + err.Error())
-	}
-	return json.Unmarshal(content, cfg)
-}
-
 func createMiner(consensusType string, diffCalculator *consensus.EthDifficultyCalculator, qkcHashXHeight uint64) consensus.PoW {
 	pubKey := []byte{}
 	switch consensusType {
@@ -303,7 +290,7 @@ func main() {
 		abortCh      = make(chan struct{})
 	)
 
-	err = loadConfig(*clusterConfig, &cfg)
+	err = config.LoadClusterConfig(*clusterConfig, &cfg)
 	if err != nil {
 		log.Fatal("ERROR: invalid config path: ", err)
 	}
@@ -7,6 +7,7 @@ import (
 	"github.com/QuarkChain/goquarkchain/account"
 	"github.com/QuarkChain/goquarkchain/common/hexutil"
 	"github.com/QuarkChain/goquarkchain/internal/qkcapi"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/shirou/gopsutil/mem"
 	"github.com/ybbus/jsonrpc"
 	"math/big"
@@ -197,17 +198,96 @@ func queryBalance(client jsonrpc.RPCClient, addr, token string) {
 	fmt.Println(msg)
 }
 
+// benchmark starts a tx benchmark of the cluster and prints its progress
+// until all the txs sent are confirmed, or the cluster stops waiting for them
+// a minute after the end.
+func benchmark(client jsonrpc.RPCClient, interval *uint, args qkcapi.CreateTxArgs) {
+	deadline := time.Now().Add(time.Duration(*args.Duration)*time.Second + time.Minute)
+	response, err := client.Call("startBenchmark", []interface{}{args})
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	if response.Error != nil {
+		fmt.Println(response.Error.Error())
+		return
+	}
+	titles := []string{"Timestamp\t", "Running", "Target", "TPS", "Sent", "Conf.TX"}
+	fmt.Println(strings.Join(titles, "\t"))
+	intv := time.Duration(*interval)
+	ticker := time.NewTicker(intv * time.Second)
+	var res map[string]interface{}
+	for range ticker.C {
+		response, err := client.Call("getBenchmarkReport")
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+		if response.Error != nil {
+			fmt.Println(response.Error.Error())
+			return
+		}
+		res = response.Result.(map[string]interface{})
+		tps, _ := res["tps"].(json.Number).Float64()
+		msg := time.Now().Format("2006-01-02 15:04:05")
+		msg += fmt.Sprintf("\t%t\t%s\t%2.2f\t%s\t%s", res["running"], res["targetTps"], tps, res["sent"], res["confirmed"])
+		fmt.Println(msg)
+		if res["running"] == false && (res["sent"] == res["confirmed"] || time.Now().After(deadline)) {
+			break
+		}
+	}
+
+	fmt.Println("============================")
+	fmt.Println("QuarkChain Benchmark Report")
+	fmt.Println("============================")
+	titles = []string{"Shard", "Target", "TPS", "Sent", "Conf.TX", "P50(ms)", "P90(ms)", "P99(ms)", "Max(ms)"}
+	fmt.Println(strings.Join(titles, "\t"))
+	for _, p := range res["shards"].([]interface{}) {
+		shard := p.(map[string]interface{})
+		tps, _ := shard["tps"].(json.Number).Float64()
+		fmt.Printf("%s/%s\t%s\t%2.2f\t%s\t%s\t%s\t%s\t%s\t%s\n", shard["chainId"], shard["shardId"], shard["targetTps"], tps,
+			shard["sent"], shard["confirmed"], shard["latencyP50Ms"], shard["latencyP90Ms"], shard["latencyP99Ms"], shard["latencyMaxMs"])
+	}
+	tps, _ := res["tps"].(json.Number).Float64()
+	fmt.Printf("Total\t%s\t%2.2f\t%s\t%s\n", res["targetTps"], tps, res["sent"], res["confirmed"])
+}
+
 func main() {
 
 	ip := flag.String("ip", "localhost", "Cluster IP")
 	interval := flag.Uint("i", 10, "Query interval in second")
 	address := flag.String("a", "", "Query account balance if a QKC address is provided")
 	token := flag.String("t", "QKC", "Query account balance for a specific token")
+	tps := flag.Uint("tps", 0, "Run a tx benchmark at the TPS of the cluster if provided")
+	duration := flag.Uint("duration", 60, "Duration of the benchmark in seconds")
+	xShardPercent := flag.Uint("xshard", 0, "Percentage of the cross-shard txs of the benchmark")
+	to := flag.String("to", "", "Contract called by the txs of the benchmark, random transfers if empty")
+	data := flag.String("data", "", "Hex call data of the txs of the benchmark calling the contract")
+	gas := flag.Uint64("gas", 100000, "Gas of the txs of the benchmark calling the contract")
+	seed := flag.Uint64("seed", 0, "Seed of the random txs of the benchmark, to repeat them")
 	flag.Parse()
 	privateEndPoint := jsonrpc.NewClient(fmt.Sprintf("http://%s:38491", *ip))
 	publicEndPoint := jsonrpc.NewClient(fmt.Sprintf("http://%s:38391", *ip))
 	fmt.Println(basic(privateEndPoint, *ip))
-	if len(*address) > 0 {
+	if *tps > 0 {
+		tpsValue, durationValue, xShardValue := uint32(*tps), uint32(*duration), uint32(*xShardPercent)
+		args := qkcapi.CreateTxArgs{TPS: &tpsValue, Duration: &durationValue, XShardPrecent: &xShardValue, Seed: seed}
+		if len(*to) > 0 {
+			callData, err := hexutil.Decode(*data)
+			if len(*data) == 0 {
+				callData, err = []byte{}, nil
+			}
+			if err != nil || !common.IsHexAddress(*to) {
+				fmt.Printf("Err: invalid contract call %s %s\n", *to, *data)
+				return
+			}
+			contract := common.HexToAddress(*to)
+			args.To = &contract
+			args.Data = (*hexutil.Bytes)(&callData)
+			args.Gas = (*hexutil.Big)(new(big.Int).SetUint64(*gas))
+		}
+		benchmark(privateEndPoint, interval, args)
+	} else if len(*address) > 0 {
 		queryAddress(publicEndPoint, interval, address, token)
 	} else {
 		queryStats(privateEndPoint, interval)
@@ -2,7 +2,9 @@
 package utils
 
 import (
+	"compress/gzip"
 	"fmt"
+	"github.com/QuarkChain/goquarkchain/cluster/master"
 	"github.com/QuarkChain/goquarkchain/cluster/service"
 	"github.com/QuarkChain/goquarkchain/internal/debug"
 	"github.com/ethereum/go-ethereum/log"
@@ -10,6 +12,7 @@ import (
 	"os"
 	"os/signal"
 	"runtime"
+	"strings"
 	"syscall"
 )
 
@@ -53,3 +56,61 @@ func StartService(stack *service.Node) {
 		debug.LoudPanic("boom")
 	}()
 }
+
+// ImportChain imports the blocks exported by ExportChain from the file into
+// the cluster, the file is decompressed if it ends with ".gz".
+func ImportChain(m *master.QKCMasterBackend, fn string) error {
+	log.Info("Importing blockchain", "file", fn)
+	fh, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var reader io.Reader = fh
+	if strings.HasSuffix(fn, ".gz") {
+		if reader, err = gzip.NewReader(reader); err != nil {
+			return err
+		}
+	}
+	if err := m.ImportChain(reader); err != nil {
+		return err
+	}
+	log.Info("Imported blockchain", "file", fn)
+	return nil
+}
+
+// ExportChain exports the canonical root blocks in [first, last] and the minor
+// blocks they confirm into the file, gzip compressed if it ends with ".gz".
+func ExportChain(m *master.QKCMasterBackend, fn string, first, last uint64) error {
+	log.Info("Exporting blockchain", "file", fn, "first", first, "last", last)
+	fh, err := os.OpenFile(fn, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var (
+		writer   io.Writer = fh
+		gzWriter *gzip.Writer
+	)
+	if strings.HasSuffix(fn, ".gz") {
+		gzWriter = gzip.NewWriter(writer)
+		defer gzWriter.Close()
+		writer = gzWriter
+	}
+	if err := m.ExportChain(writer, first, last); err != nil {
+		return err
+	}
+	// the gzip footer is written on close, without it the export is truncated
+	if gzWriter != nil {
+		if err := gzWriter.Close(); err != nil {
+			return err
+		}
+	}
+	if err := fh.Close(); err != nil {
+		return err
+	}
+	log.Info("Exported blockchain", "file", fn)
+	return nil
+}
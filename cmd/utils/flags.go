@@ -7,12 +7,15 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/QuarkChain/goquarkchain/cluster/config"
 	"github.com/QuarkChain/goquarkchain/cluster/master"
 	"github.com/QuarkChain/goquarkchain/cluster/service"
+	"github.com/QuarkChain/goquarkchain/internal/debug"
 	"github.com/QuarkChain/goquarkchain/p2p"
 	"github.com/QuarkChain/goquarkchain/params"
+	"github.com/QuarkChain/goquarkchain/rpc"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/nat"
@@ -82,6 +85,26 @@ var (
 		Name:  "log_level",
 		Usage: "log level",
 	}
+	LogModulesFlag = cli.StringFlag{
+		Name:  "log_modules",
+		Usage: "log level of the modules rpc, sync, p2p, core and miner: comma-separated list of <module>=<level> (e.g. p2p=debug,sync=warn)",
+	}
+	MetricsTypeFlag = cli.StringFlag{
+		Name:  "metrics_type",
+		Usage: "push the metrics to influxdb or graphite",
+	}
+	MetricsEndpointFlag = cli.StringFlag{
+		Name:  "metrics_endpoint",
+		Usage: "URL of the InfluxDB write API (e.g. http://localhost:8086/write?db=quarkchain), or HOST:PORT of the Graphite plaintext listener",
+	}
+	MetricsIntervalFlag = cli.UintFlag{
+		Name:  "metrics_interval",
+		Usage: "seconds between the metrics reports",
+	}
+	MetricsTagsFlag = cli.StringFlag{
+		Name:  "metrics_tags",
+		Usage: "tags added to the metrics: comma-separated list of <tag>=<value> (e.g. region=eu,env=prod)",
+	}
 	CleanFlag = cli.BoolFlag{
 		Name:  "clean",
 		Usage: "clean database ?",
@@ -102,6 +125,10 @@ var (
 		Name:  "db_path_root",
 		Usage: "Data directory for the databases and keystore",
 	}
+	DBBackendFlag = cli.StringFlag{
+		Name:  "db_backend",
+		Usage: "Storage backend of the databases (rocksdb, leveldb, pebble)",
+	}
 	P2pFlag = cli.BoolFlag{
 		Name:  "p2p",
 		Usage: "enables new p2p module",
@@ -118,6 +145,50 @@ var (
 		Name:  "bootnodes",
 		Usage: "comma separated encodes in the format: enode://PUBKEY@IP:PORT",
 	}
+	StaticNodesFlag = cli.StringFlag{
+		Name:  "staticnodes",
+		Usage: "comma separated enodes always kept connected, in the format: enode://PUBKEY@IP:PORT",
+	}
+	TrustedNodesFlag = cli.StringFlag{
+		Name:  "trustednodes",
+		Usage: "comma separated enodes allowed to connect above the max peers, in the format: enode://PUBKEY@IP:PORT",
+	}
+	AllowlistOnlyFlag = cli.BoolFlag{
+		Name:  "allowlist_only",
+		Usage: "refuse the connections of all peers but the static and trusted nodes",
+	}
+	MaxUploadFlag = cli.Uint64Flag{
+		Name:  "max_upload_kbps",
+		Usage: "maximum upload rate in KB/s to all the peers together, 0 for unlimited",
+	}
+	MaxDownloadFlag = cli.Uint64Flag{
+		Name:  "max_download_kbps",
+		Usage: "maximum download rate in KB/s from all the peers together, 0 for unlimited",
+	}
+	PeerMaxUploadFlag = cli.Uint64Flag{
+		Name:  "peer_max_upload_kbps",
+		Usage: "maximum upload rate in KB/s to each peer, 0 for unlimited",
+	}
+	PeerMaxDownloadFlag = cli.Uint64Flag{
+		Name:  "peer_max_download_kbps",
+		Usage: "maximum download rate in KB/s from each peer, 0 for unlimited",
+	}
+	HandshakeAuditLogFlag = cli.StringFlag{
+		Name:  "handshake_audit_log",
+		Usage: "file the parameters of each p2p handshake are appended to as JSON lines",
+	}
+	MinProtocolVersionFlag = cli.Uint64Flag{
+		Name:  "min_protocol_version",
+		Usage: "refuse the peers running a devp2p base protocol version below this one",
+	}
+	MinQKCProtocolVersionFlag = cli.UintFlag{
+		Name:  "min_qkc_protocol_version",
+		Usage: "refuse the peers not offering the quarkchain protocol at this version or above",
+	}
+	RequireEIP8Flag = cli.BoolFlag{
+		Name:  "require_eip8",
+		Usage: "refuse the peers using the pre-EIP-8 encryption handshake",
+	}
 	UpnpFlag = cli.BoolFlag{
 		Name:  "upnp",
 		Usage: "if true,automatically runs a upnp service that sets port mapping on upnp-enabled devices",
@@ -131,6 +202,130 @@ var (
 		Usage: "svrvice type,if has eight slaves,fill like(S0,S2,...S7)",
 		Value: "master",
 	}
+	TxJournalFlag = cli.StringFlag{
+		Name:  "tx_journal",
+		Usage: "Disk journal for local transaction to survive slave restarts, disabled if empty",
+		Value: "transactions.dat",
+	}
+	XShardJournalFlag = cli.StringFlag{
+		Name:  "xshard_journal",
+		Usage: "Disk journal for the cross-shard tx lists received by the shards to survive slave crashes, disabled if empty",
+		Value: "xshard_lists.dat",
+	}
+	TxIndexRetentionFlag = cli.Uint64Flag{
+		Name:  "tx_index_retention",
+		Usage: "Number of latest minor blocks whose transactions are indexed (0 = entire chain)",
+		Value: 0,
+	}
+	AncientRootBlocksFlag = cli.Uint64Flag{
+		Name:  "ancient_root_blocks",
+		Usage: "Move minor blocks confirmed by root blocks older than this into the ancient store (0 = disabled)",
+		Value: 0,
+	}
+	GCModeFlag = cli.StringFlag{
+		Name:  "gcmode",
+		Usage: `State garbage collection mode of all shards ("full", "archive")`,
+		Value: "archive",
+	}
+	TrieCacheFlag = cli.IntFlag{
+		Name:  "trie_cache",
+		Usage: "Megabytes of memory allocated to trie caching of each shard",
+		Value: 128,
+	}
+	TrieDirtyCacheFlag = cli.IntFlag{
+		Name:  "trie_dirty_cache",
+		Usage: "Megabytes of memory for the dirty trie nodes of each shard before they are flushed in full gc mode",
+		Value: 128,
+	}
+	NoPrefetchFlag = cli.BoolFlag{
+		Name:  "no_prefetch",
+		Usage: "Don't read the accounts of the txs of a block ahead of running them",
+	}
+	TrieTimeLimitFlag = cli.Uint64Flag{
+		Name:  "trie_time_limit",
+		Usage: "Seconds of block processing before the in-memory state trie is flushed in full gc mode",
+		Value: 300,
+	}
+	ParallelTxWorkersFlag = cli.IntFlag{
+		Name:  "parallel_tx_workers",
+		Usage: "Number of workers executing the non-conflicting transactions of a minor block in parallel (0 = serial)",
+		Value: 0,
+	}
+	SnapshotFlag = cli.BoolFlag{
+		Name:  "snapshot",
+		Usage: "Maintain a flat snapshot of the shard states for faster state reads",
+	}
+	BalanceHistoryFlag = cli.BoolFlag{
+		Name:  "balance_history",
+		Usage: "Index the balances changed by each block to read them at any height, of all the accounts unless --balance_history_addresses is set",
+	}
+	BalanceHistoryAddressesFlag = cli.StringFlag{
+		Name:  "balance_history_addresses",
+		Usage: "comma separated hex addresses whose balance history is indexed",
+	}
+	SnapSyncFlag = cli.BoolFlag{
+		Name:  "snap_sync",
+		Usage: "Bootstrap the new shards of a slave from a snapshot served by another slave running them",
+	}
+	AsyncMasterNotifyFlag = cli.BoolFlag{
+		Name:  "async_master_notify",
+		Usage: "Send the headers of the new minor blocks to the master in the background, once their cross-shard tx lists are delivered to the neighbor slaves",
+	}
+	KeyStoreDirFlag = DirectoryFlag{
+		Name:  "keystore",
+		Usage: "Directory for the keystore (default = inside the datadir)",
+	}
+	LightKDFFlag = cli.BoolFlag{
+		Name:  "lightkdf",
+		Usage: "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
+	}
+	ExternalSignerFlag = cli.StringFlag{
+		Name:  "signer",
+		Usage: "External signer (IPC path or URL) signing the root blocks and transactions",
+	}
+	SlaveRestartTimeoutFlag = cli.UintFlag{
+		Name:  "slave_restart_timeout",
+		Usage: "Seconds the master waits for a slave which stopped answering to be restarted, 0 to shut down at once",
+	}
+	AuditLogFlag = cli.StringFlag{
+		Name:  "audit_log",
+		Usage: "File the critical actions of the cluster are appended to, relative to the data directory, empty to keep them in memory only",
+	}
+	TxWatcherFlag = cli.BoolFlag{
+		Name:  "tx_watcher",
+		Usage: "Watch the transactions submitted through the master for the txStatus websocket subscription",
+	}
+	TxWatcherWebhookFlag = cli.StringFlag{
+		Name:  "tx_watcher_webhook",
+		Usage: "URL the status changes of the transactions submitted through the master are posted to",
+	}
+	NonceManagerFlag = cli.BoolFlag{
+		Name:  "nonce_manager",
+		Usage: "Reserve the nonces of the senders on the master and reuse the ones of the dropped transactions",
+	}
+	DevFlag = cli.BoolFlag{
+		Name:  "dev",
+		Usage: "Run a dev cluster of the master and its slaves in one process, with simulated mining and prefunded accounts",
+	}
+	DevSlavesFlag = cli.IntFlag{
+		Name:  "dev.slaves",
+		Usage: "Number of slaves of the dev cluster, a power of 2",
+		Value: 1,
+	}
+	DevPeriodFlag = cli.UintFlag{
+		Name:  "dev.period",
+		Usage: "Block time of the dev cluster in seconds, 0 to mine a block as soon as there are transactions",
+	}
+	DevAccountsFlag = cli.IntFlag{
+		Name:  "dev.accounts",
+		Usage: "Number of prefunded accounts of the dev cluster",
+		Value: 10,
+	}
+	DevMnemonicFlag = cli.StringFlag{
+		Name:  "dev.mnemonic",
+		Usage: "Mnemonic of the prefunded accounts of the dev cluster",
+		Value: config.DevMnemonic,
+	}
 	CheckDBFlag = cli.BoolFlag{
 		Name:  "check_db",
 		Usage: "if true, will perform integrity check on db only",
@@ -191,6 +386,14 @@ var (
 		Usage: "public json rpc port",
 		Value: int(config.DefaultGrpcPort),
 	}
+	GRPCReflectionFlag = cli.BoolFlag{
+		Name:  "grpc_reflection",
+		Usage: "Serve the gRPC reflection service on the master and slave grpc endpoints, for tools like grpcurl",
+	}
+	HealthPortFlag = cli.IntFlag{
+		Name:  "health_port",
+		Usage: "port of the /health and /ready HTTP endpoints of the master or slave, overrides its HEALTH_PORT",
+	}
 	P2pPortFlag = cli.IntFlag{
 		Name:  "p2p_port",
 		Usage: "Network listening port",
@@ -210,8 +413,7 @@ var (
 	}
 	NATFlag = cli.StringFlag{
 		Name:  "nat",
-		Usage: "NAT port mapping mechanism (any|none|upnp|pmp|extip:<IP>)",
-		Value: "any",
+		Usage: "NAT port mapping mechanism (any|none|upnp|pmp|extip:<IP>), none unless --upnp is given",
 	}
 	NoDiscoverFlag = cli.BoolFlag{
 		Name:  "nodiscover",
@@ -219,7 +421,11 @@ var (
 	}
 	DiscoveryV5Flag = cli.BoolFlag{
 		Name:  "v5disc",
-		Usage: "Enables the experimental RLPx V5 (Topic Discovery) mechanism",
+		Usage: "Enables the V5 (Topic Discovery) mechanism, finding the clusters serving the same chains",
+	}
+	BootnodesV5Flag = cli.StringFlag{
+		Name:  "bootnodesv5",
+		Usage: "comma separated V5 discovery boot nodes in the format: enode://PUBKEY@IP:PORT",
 	}
 	WSEnableFlag = cli.BoolFlag{
 		Name:  "ws",
@@ -262,15 +468,54 @@ func setBootstrapNodes(ctx *cli.Context, cfg *p2p.Config, clstrCfg *config.Clust
 	}
 }
 
-// setNAT creates a port mapper from command line flags.
-func setNAT(ctx *cli.Context, cfg *p2p.Config) {
-	if ctx.GlobalIsSet(NATFlag.Name) {
-		natif, err := nat.Parse(ctx.GlobalString(NATFlag.Name))
-		if err != nil {
-			Fatalf("Option %s: %v", NATFlag.Name, err)
-		}
-		cfg.NAT = natif
+// setStaticTrustedNodes adds the static and trusted nodes of the cluster config
+// to the ones of the p2p config, exempting them from the blacklist.
+func setStaticTrustedNodes(cfg *p2p.Config, clstrCfg *config.ClusterConfig) {
+	static, err := clstrCfg.P2P.GetStaticNodes()
+	if err != nil {
+		Fatalf("Option %s: %v", StaticNodesFlag.Name, err)
+	}
+	trusted, err := clstrCfg.P2P.GetTrustedNodes()
+	if err != nil {
+		Fatalf("Option %s: %v", TrustedNodesFlag.Name, err)
+	}
+	cfg.StaticNodes = append(cfg.StaticNodes, static...)
+	cfg.TrustedNodes = append(cfg.TrustedNodes, trusted...)
+	if cfg.WhitelistNodes == nil {
+		cfg.WhitelistNodes = make(map[string]*enode.Node)
+	}
+	for _, node := range append(static, trusted...) {
+		cfg.WhitelistNodes[node.IP().String()] = node
+	}
+	cfg.AllowlistOnly = clstrCfg.P2P.AllowlistOnly
+}
+
+// setRateLimits sets the p2p bandwidth limits of the cluster config.
+func setRateLimits(cfg *p2p.Config, clstrCfg *config.ClusterConfig) {
+	cfg.MaxUploadRate = int(clstrCfg.P2P.MaxUploadKBps * 1024)
+	cfg.MaxDownloadRate = int(clstrCfg.P2P.MaxDownloadKBps * 1024)
+	cfg.PeerMaxUploadRate = int(clstrCfg.P2P.PeerMaxUploadKBps * 1024)
+	cfg.PeerMaxDownloadRate = int(clstrCfg.P2P.PeerMaxDownloadKBps * 1024)
+}
+
+// setHandshakeAudit sets the p2p handshake audit log and thresholds of the
+// cluster config.
+func setHandshakeAudit(cfg *p2p.Config, clstrCfg *config.ClusterConfig) {
+	cfg.HandshakeAuditLog = clstrCfg.P2P.HandshakeAuditLog
+	cfg.MinProtocolVersion = clstrCfg.P2P.MinProtocolVersion
+	cfg.RequireEIP8 = clstrCfg.P2P.RequireEIP8
+	if clstrCfg.P2P.MinQKCProtocolVersion > 0 {
+		cfg.MinCaps = []p2p.Cap{{Name: master.QKCProtocolName, Version: clstrCfg.P2P.MinQKCProtocolVersion}}
+	}
+}
+
+// setNAT creates the port mapper of the cluster config.
+func setNAT(cfg *p2p.Config, clstrCfg *config.ClusterConfig) {
+	natif, err := nat.Parse(clstrCfg.P2P.NATMechanism())
+	if err != nil {
+		Fatalf("Option %s: %v", NATFlag.Name, err)
 	}
+	cfg.NAT = natif
 }
 
 // splitAndTrim splits input separated by a comma
@@ -306,6 +551,20 @@ func setHTTP(ctx *cli.Context, cfg *service.Config, clstrCfg *config.ClusterConf
 		privateHost = ctx.GlobalString(PrivateRPCListenAddrFlag.Name)
 	}
 	cfg.HTTPPrivEndpoint = fmt.Sprintf("%s:%d", privateHost, privPort)
+	cfg.RPCAdmission = &rpc.AdmissionConfig{
+		MaxConcurrent: clstrCfg.JSONRPCMaxConcurrent,
+		MaxQueued:     clstrCfg.JSONRPCMaxQueued,
+		MethodLimits:  clstrCfg.JSONRPCMethodLimits,
+	}
+	cfg.RPCTimeouts = &rpc.TimeoutConfig{
+		Default: time.Duration(clstrCfg.JSONRPCTimeout) * time.Second,
+		Methods: make(map[string]time.Duration, len(clstrCfg.JSONRPCMethodTimeouts)),
+	}
+	for method, timeout := range clstrCfg.JSONRPCMethodTimeouts {
+		if timeout != 0 {
+			cfg.RPCTimeouts.Methods[method] = time.Duration(timeout) * time.Second
+		}
+	}
 }
 
 func setGRPC(ctx *cli.Context, cfg *service.Config, clstrCfg *config.ClusterConfig) {
@@ -316,6 +575,7 @@ func setGRPC(ctx *cli.Context, cfg *service.Config, clstrCfg *config.ClusterConf
 		clstrCfg.Quarkchain.GRPCHost = ctx.GlobalString(GRPCAddrFlag.Name)
 	}
 	cfg.GRPCEndpoint = fmt.Sprintf("%s:%d", clstrCfg.Quarkchain.GRPCHost, clstrCfg.Quarkchain.GRPCPort)
+	cfg.GRPCReflection = clstrCfg.GRPCReflection
 }
 
 // setIPC creates an IPC path configuration from the set command line flags,
@@ -332,9 +592,12 @@ func setIPC(ctx *cli.Context, cfg *service.Config) {
 
 func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config, clstrCfg *config.ClusterConfig) {
 	// setNodeKey(ctx, cfg)
-	setNAT(ctx, cfg)
+	setNAT(cfg, clstrCfg)
 	cfg.ListenAddr = fmt.Sprintf(":%d", clstrCfg.P2PPort)
 	setBootstrapNodes(ctx, cfg, clstrCfg)
+	setStaticTrustedNodes(cfg, clstrCfg)
+	setRateLimits(cfg, clstrCfg)
+	setHandshakeAudit(cfg, clstrCfg)
 
 	// load p2p privkey
 	priv := clstrCfg.P2P.PrivKey
@@ -361,12 +624,13 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config, clstrCfg *config.ClusterCon
 		cfg.NoDiscovery = true
 	}
 
-	// if we're running a light client or server, force enable the v5 peer discovery
-	// unless it is explicitly disabled with --nodiscover note that explicitly specifying
-	// --v5disc overrides --nodiscover, in which case the later only disables v4 discovery
-	if ctx.GlobalIsSet(DiscoveryV5Flag.Name) {
-		cfg.DiscoveryV5 = ctx.GlobalBool(DiscoveryV5Flag.Name)
+	// NoDiscovery only disables the V4 discovery if the V5 one is enabled
+	cfg.DiscoveryV5 = clstrCfg.P2P.DiscoveryV5
+	bootNodesV5, err := clstrCfg.P2P.GetBootNodesV5()
+	if err != nil {
+		Fatalf("Option %s: %v", BootnodesV5Flag.Name, err)
 	}
+	cfg.BootstrapNodesV5 = bootNodesV5
 }
 
 func SetClusterConfig(ctx *cli.Context, cfg *config.ClusterConfig) {
@@ -394,6 +658,31 @@ func SetClusterConfig(ctx *cli.Context, cfg *config.ClusterConfig) {
 	if ctx.GlobalIsSet(LogLevelFlag.Name) {
 		cfg.LogLevel = ctx.GlobalString(LogLevelFlag.Name)
 	}
+	if ctx.GlobalIsSet(LogModulesFlag.Name) {
+		levels, err := debug.ParseLogModules(ctx.GlobalString(LogModulesFlag.Name))
+		if err != nil {
+			Fatalf("Option %s: %v", LogModulesFlag.Name, err)
+		}
+		cfg.LogModules = levels
+	}
+
+	// cluster.monitoring
+	if ctx.GlobalIsSet(MetricsTypeFlag.Name) {
+		cfg.Monitoring.MetricsType = ctx.GlobalString(MetricsTypeFlag.Name)
+	}
+	if ctx.GlobalIsSet(MetricsEndpointFlag.Name) {
+		cfg.Monitoring.MetricsEndpoint = ctx.GlobalString(MetricsEndpointFlag.Name)
+	}
+	if ctx.GlobalIsSet(MetricsIntervalFlag.Name) {
+		cfg.Monitoring.MetricsInterval = uint32(ctx.GlobalUint(MetricsIntervalFlag.Name))
+	}
+	if ctx.GlobalIsSet(MetricsTagsFlag.Name) {
+		tags, err := parseTags(ctx.GlobalString(MetricsTagsFlag.Name))
+		if err != nil {
+			Fatalf("Option %s: %v", MetricsTagsFlag.Name, err)
+		}
+		cfg.Monitoring.MetricsTags = tags
+	}
 
 	// cluster.db_path_root
 	if ctx.GlobalIsSet(P2pPortFlag.Name) {
@@ -414,6 +703,81 @@ func SetClusterConfig(ctx *cli.Context, cfg *config.ClusterConfig) {
 	if ctx.GlobalBool(EnableTransactionHistoryFlag.Name) {
 		cfg.EnableTransactionHistory = true
 	}
+	if ctx.GlobalIsSet(DBBackendFlag.Name) {
+		cfg.DBBackend = ctx.GlobalString(DBBackendFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxJournalFlag.Name) {
+		cfg.TxJournal = ctx.GlobalString(TxJournalFlag.Name)
+	}
+	if ctx.GlobalIsSet(XShardJournalFlag.Name) {
+		cfg.XShardJournal = ctx.GlobalString(XShardJournalFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxIndexRetentionFlag.Name) {
+		cfg.TxIndexRetention = ctx.GlobalUint64(TxIndexRetentionFlag.Name)
+	}
+	if ctx.GlobalIsSet(AncientRootBlocksFlag.Name) {
+		cfg.AncientRootBlocks = ctx.GlobalUint64(AncientRootBlocksFlag.Name)
+	}
+	if ctx.GlobalIsSet(ParallelTxWorkersFlag.Name) {
+		cfg.ParallelTxWorkers = ctx.GlobalInt(ParallelTxWorkersFlag.Name)
+	}
+	if ctx.GlobalIsSet(GCModeFlag.Name) {
+		cfg.State.GCMode = ctx.GlobalString(GCModeFlag.Name)
+	}
+	if ctx.GlobalIsSet(TrieCacheFlag.Name) {
+		cfg.State.TrieCleanCache = ctx.GlobalInt(TrieCacheFlag.Name)
+	}
+	if ctx.GlobalIsSet(TrieDirtyCacheFlag.Name) {
+		cfg.State.TrieDirtyCache = ctx.GlobalInt(TrieDirtyCacheFlag.Name)
+	}
+	if ctx.GlobalBool(NoPrefetchFlag.Name) {
+		cfg.State.Prefetch = false
+	}
+	if ctx.GlobalIsSet(TrieTimeLimitFlag.Name) {
+		cfg.State.TrieTimeLimit = ctx.GlobalUint64(TrieTimeLimitFlag.Name)
+	}
+	if ctx.GlobalBool(SnapshotFlag.Name) {
+		cfg.State.Snapshot = true
+	}
+	if ctx.GlobalBool(BalanceHistoryFlag.Name) {
+		cfg.State.BalanceHistory = true
+	}
+	if ctx.GlobalIsSet(BalanceHistoryAddressesFlag.Name) {
+		cfg.State.BalanceHistoryAddresses = strings.Split(ctx.GlobalString(BalanceHistoryAddressesFlag.Name), ",")
+	}
+	if ctx.GlobalBool(SnapSyncFlag.Name) {
+		cfg.SnapSync = true
+	}
+	if ctx.GlobalBool(AsyncMasterNotifyFlag.Name) {
+		cfg.AsyncMasterNotify = true
+	}
+	if ctx.GlobalBool(GRPCReflectionFlag.Name) {
+		cfg.GRPCReflection = true
+	}
+	if ctx.GlobalIsSet(KeyStoreDirFlag.Name) {
+		cfg.KeyStoreDir = ctx.GlobalString(KeyStoreDirFlag.Name)
+	}
+	if ctx.GlobalBool(LightKDFFlag.Name) {
+		cfg.LightKDF = true
+	}
+	if ctx.GlobalIsSet(ExternalSignerFlag.Name) {
+		cfg.ExternalSigner = ctx.GlobalString(ExternalSignerFlag.Name)
+	}
+	if ctx.GlobalIsSet(SlaveRestartTimeoutFlag.Name) {
+		cfg.Master.SlaveRestartTimeout = uint32(ctx.GlobalUint(SlaveRestartTimeoutFlag.Name))
+	}
+	if ctx.GlobalIsSet(AuditLogFlag.Name) {
+		cfg.Master.AuditLog = ctx.GlobalString(AuditLogFlag.Name)
+	}
+	if ctx.GlobalBool(TxWatcherFlag.Name) {
+		cfg.Master.TxWatcher = true
+	}
+	if ctx.GlobalIsSet(TxWatcherWebhookFlag.Name) {
+		cfg.Master.TxWatcherWebhook = ctx.GlobalString(TxWatcherWebhookFlag.Name)
+	}
+	if ctx.GlobalBool(NonceManagerFlag.Name) {
+		cfg.Master.NonceManager = true
+	}
 	if ctx.GlobalIsSet(NetworkIdFlag.Name) {
 		cfg.Quarkchain.NetworkID = uint32(ctx.GlobalInt(NetworkIdFlag.Name))
 	}
@@ -422,6 +786,12 @@ func SetClusterConfig(ctx *cli.Context, cfg *config.ClusterConfig) {
 	if ctx.GlobalIsSet(BootnodesFlag.Name) {
 		cfg.P2P.BootNodes = ctx.GlobalString(BootnodesFlag.Name)
 	}
+	if ctx.GlobalBool(DiscoveryV5Flag.Name) {
+		cfg.P2P.DiscoveryV5 = true
+	}
+	if ctx.GlobalIsSet(BootnodesV5Flag.Name) {
+		cfg.P2P.BootNodesV5 = ctx.GlobalString(BootnodesV5Flag.Name)
+	}
 
 	if ctx.GlobalIsSet(PrivkeyFlag.Name) {
 		cfg.P2P.PrivKey = ctx.GlobalString(PrivkeyFlag.Name)
@@ -434,6 +804,42 @@ func SetClusterConfig(ctx *cli.Context, cfg *config.ClusterConfig) {
 	if ctx.GlobalBool(UpnpFlag.Name) {
 		cfg.P2P.UPnP = true
 	}
+	if ctx.GlobalIsSet(StaticNodesFlag.Name) {
+		cfg.P2P.StaticNodes = ctx.GlobalString(StaticNodesFlag.Name)
+	}
+	if ctx.GlobalIsSet(TrustedNodesFlag.Name) {
+		cfg.P2P.TrustedNodes = ctx.GlobalString(TrustedNodesFlag.Name)
+	}
+	if ctx.GlobalBool(AllowlistOnlyFlag.Name) {
+		cfg.P2P.AllowlistOnly = true
+	}
+	if ctx.GlobalIsSet(NATFlag.Name) {
+		cfg.P2P.NAT = ctx.GlobalString(NATFlag.Name)
+	}
+	if ctx.GlobalIsSet(MaxUploadFlag.Name) {
+		cfg.P2P.MaxUploadKBps = ctx.GlobalUint64(MaxUploadFlag.Name)
+	}
+	if ctx.GlobalIsSet(MaxDownloadFlag.Name) {
+		cfg.P2P.MaxDownloadKBps = ctx.GlobalUint64(MaxDownloadFlag.Name)
+	}
+	if ctx.GlobalIsSet(PeerMaxUploadFlag.Name) {
+		cfg.P2P.PeerMaxUploadKBps = ctx.GlobalUint64(PeerMaxUploadFlag.Name)
+	}
+	if ctx.GlobalIsSet(PeerMaxDownloadFlag.Name) {
+		cfg.P2P.PeerMaxDownloadKBps = ctx.GlobalUint64(PeerMaxDownloadFlag.Name)
+	}
+	if ctx.GlobalIsSet(HandshakeAuditLogFlag.Name) {
+		cfg.P2P.HandshakeAuditLog = ctx.GlobalString(HandshakeAuditLogFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinProtocolVersionFlag.Name) {
+		cfg.P2P.MinProtocolVersion = ctx.GlobalUint64(MinProtocolVersionFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinQKCProtocolVersionFlag.Name) {
+		cfg.P2P.MinQKCProtocolVersion = ctx.GlobalUint(MinQKCProtocolVersionFlag.Name)
+	}
+	if ctx.GlobalBool(RequireEIP8Flag.Name) {
+		cfg.P2P.RequireEIP8 = true
+	}
 }
 
 // SetNodeConfig applies node-related command line flags to the config.
@@ -464,6 +870,7 @@ func setDataDir(ctx *cli.Context, cfg *service.Config, clstrCfg *config.ClusterC
 	if ctx.GlobalIsSet(DataDirFlag.Name) {
 		cfg.DataDir = ctx.GlobalString(DataDirFlag.Name)
 	}
+	cfg.DBBackend = clstrCfg.DBBackend
 }
 
 // checkExclusive verifies that only a single instance of the provided flags was
@@ -563,3 +970,19 @@ func MakeDataDir(ctx *cli.Context) string {
 	Fatalf("Cannot determine default data directory, please set manually (--datadir)")
 	return ""
 }
+
+// parseTags parses the comma separated list of <tag>=<value>.
+func parseTags(spec string) (map[string]string, error) {
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(spec, ",") {
+		if tag = strings.TrimSpace(tag); tag == "" {
+			continue
+		}
+		parts := strings.SplitN(tag, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid tag %q, want <tag>=<value>", tag)
+		}
+		tags[parts[0]] = parts[1]
+	}
+	return tags, nil
+}
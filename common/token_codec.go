@@ -28,6 +28,20 @@ func TokenIDEncode(str string) uint64 {
 	return id
 }
 
+// TokenIDEncodeWithCheck is the same as TokenIDEncode but returns an error
+// instead of panic for invalid token names.
+func TokenIDEncodeWithCheck(str string) (uint64, error) {
+	if len(str) == 0 || len(str) > len(TOKENMAX) {
+		return 0, fmt.Errorf("invalid token %q", str)
+	}
+	for i := 0; i < len(str); i++ {
+		if !(str[i] >= 'A' && str[i] <= 'Z') && !(str[i] >= '0' && str[i] <= '9') {
+			return 0, fmt.Errorf("invalid token %q", str)
+		}
+	}
+	return TokenIDEncode(str), nil
+}
+
 func TokenIdDecode(id uint64) (string, error) {
 	if id > TOKENIDMAX {
 		return "", errors.New("it too big or negative")
@@ -134,19 +134,23 @@ func (c *CommonEngine) VerifyHeader(
 	header types.IHeader,
 	seal bool,
 ) error {
+	/*	if chain.GetHeader(header.Hash()) != nil {
+			fmt.Println(header.Hash())
+			//return nil
+		}
+	*/
+	return c.verifyHeader(chain, header, chain.GetHeader(header.GetParentHash()))
+}
+
+// verifyHeader checks whether a header conforms to the consensus rules with
+// the given parent header.
+func (c *CommonEngine) verifyHeader(chain ChainReader, header, parent types.IHeader) error {
 	// Short-circuit if the header is known, or parent not
 	number := header.NumberU64()
 	logger := log.New("engine")
 	if header.GetVersion() != 0 {
 		return errors.New("incorrect block's version")
 	}
-
-	/*	if chain.GetHeader(header.Hash()) != nil {
-			fmt.Println(header.Hash())
-			//return nil
-		}
-	*/
-	parent := chain.GetHeader(header.GetParentHash())
 	if parent == nil {
 		return ErrUnknownAncestor
 	}
@@ -206,17 +210,75 @@ func (c *CommonEngine) VerifyHeaders(
 	headers []types.IHeader,
 	seals []bool,
 ) (chan<- struct{}, <-chan error) {
-	abort := make(chan struct{})
+	// Spawn as many workers as allowed threads
+	workers := runtime.GOMAXPROCS(0)
+	if len(headers) < workers {
+		workers = len(headers)
+	}
+
+	// Create a task channel and spawn the verifiers
+	var (
+		inputs = make(chan int)
+		done   = make(chan int, workers)
+		errs   = make([]error, len(headers))
+		abort  = make(chan struct{})
+	)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for index := range inputs {
+				errs[index] = c.verifyHeaderWorker(chain, headers, index)
+				done <- index
+			}
+		}()
+	}
+
 	errorsOut := make(chan error, len(headers))
 	go func() {
-		for _, h := range headers {
-			err := c.VerifyHeader(chain, h, true /*seal flag not used*/)
-			errorsOut <- err
+		defer close(inputs)
+		var (
+			in, out = 0, 0
+			checked = make([]bool, len(headers))
+			inputs  = inputs
+		)
+		if len(headers) == 0 {
+			return
+		}
+		for {
+			select {
+			case inputs <- in:
+				if in++; in == len(headers) {
+					// Reached end of headers. Stop sending to workers.
+					inputs = nil
+				}
+			case index := <-done:
+				// Deliver results in the order of the input slice
+				for checked[index] = true; out < len(headers) && checked[out]; out++ {
+					errorsOut <- errs[out]
+				}
+				if out == len(headers) {
+					return
+				}
+			case <-abort:
+				return
+			}
 		}
 	}()
 	return abort, errorsOut
 }
 
+// verifyHeaderWorker verifies headers[index], the parent is taken from the
+// batch if it is the previous header, so that the result doesn't depend on
+// whether the previous block has been written to the chain yet.
+func (c *CommonEngine) verifyHeaderWorker(chain ChainReader, headers []types.IHeader, index int) error {
+	var parent types.IHeader
+	if index > 0 && headers[index-1].Hash() == headers[index].GetParentHash() {
+		parent = headers[index-1]
+	} else {
+		parent = chain.GetHeader(headers[index].GetParentHash())
+	}
+	return c.verifyHeader(chain, headers[index], parent)
+}
+
 // FindNonce finds the desired nonce and mixhash for a given block header.
 func (c *CommonEngine) FindNonce(
 	work MiningWork,
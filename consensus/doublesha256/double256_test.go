@@ -4,10 +4,13 @@ import (
 	"math/big"
 	"testing"
 
+	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/QuarkChain/goquarkchain/cluster/config"
 	"github.com/QuarkChain/goquarkchain/consensus"
 	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/mocks/mock_consensus"
 )
 
 func TestVerifySeal(t *testing.T) {
@@ -34,3 +37,45 @@ func TestVerifySeal(t *testing.T) {
 	err = d.VerifySeal(nil, header, big.NewInt(0))
 	assert.NoError(err, "should pass with 0 diff")
 }
+
+func TestVerifyHeadersInOrder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	assert := assert.New(t)
+	diffCalculator := consensus.EthDifficultyCalculator{AdjustmentCutoff: 7, AdjustmentFactor: 512, MinimumDifficulty: big.NewInt(100000)}
+	d := New(&diffCalculator, false, []byte{})
+
+	genesis := &types.RootBlockHeader{Number: 0, Difficulty: big.NewInt(1), Time: 42}
+	cr := mock_consensus.NewMockChainReader(ctrl)
+	cr.EXPECT().Config().Return(config.NewQuarkChainConfig()).AnyTimes()
+	// only genesis is known by the chain, the other parents come from the batch
+	cr.EXPECT().GetHeader(genesis.Hash()).Return(genesis).AnyTimes()
+	cr.EXPECT().SkipDifficultyCheck().Return(true).AnyTimes()
+	cr.EXPECT().GetAdjustedDifficulty(gomock.Any()).Return(big.NewInt(1), uint64(1), nil).AnyTimes()
+
+	var (
+		headers []types.IHeader
+		parent  = genesis
+		bad     = 37
+	)
+	for i := 1; i <= 100; i++ {
+		h := &types.RootBlockHeader{Number: uint32(i), Difficulty: big.NewInt(1), Time: parent.Time + 1, ParentHash: parent.Hash()}
+		if i == bad {
+			h.Version = 1
+		}
+		headers = append(headers, h)
+		parent = h
+	}
+
+	abort, errorCh := d.VerifyHeaders(cr, headers, nil)
+	defer close(abort)
+	for i := 1; i <= len(headers); i++ {
+		err := <-errorCh
+		if i == bad {
+			assert.Error(err, "header %d should fail", i)
+		} else {
+			assert.NoError(err, "header %d should pass", i)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package posw_test
 
 import (
+	"context"
 	"math/big"
 	"reflect"
 	"runtime/debug"
@@ -131,7 +132,7 @@ func TestPoSWCoinBaseSendUnderLimit(t *testing.T) {
 	// Try to send money from that account
 	tx0 := core.CreateTransferTx(blockchain, id1.GetKey().Bytes(), acc1, account.Address{},
 		new(big.Int).SetUint64(1), nil, nil, nil)
-	if _, err = blockchain.ExecuteTx(tx0, &acc1, nil); err != nil {
+	if _, err = blockchain.ExecuteTx(context.Background(), tx0, &acc1, nil, nil); err != nil {
 		t.Errorf("tx failed: %v", err)
 	}
 	//Create a block including that tx, receipt should also report error
@@ -169,7 +170,7 @@ func TestPoSWCoinBaseSendUnderLimit(t *testing.T) {
 	}
 	tx1 := core.CreateTransferTx(blockchain, id1.GetKey().Bytes(), acc1, account.Address{},
 		new(big.Int).SetUint64(2), nil, nil, nil)
-	if ret, _ := blockchain.ExecuteTx(tx1, &acc1, nil); ret != nil {
+	if ret, _ := blockchain.ExecuteTx(context.Background(), tx1, &acc1, nil, nil); ret != nil {
 		t.Error("tx should fail")
 	}
 	//Create a block including that tx, receipt should also report error
@@ -214,13 +215,13 @@ func TestPoSWCoinBaseSendUnderLimit(t *testing.T) {
 
 	tx2 := core.CreateTransferTx(blockchain, id2.GetKey().Bytes(), acc2, account.Address{},
 		new(big.Int).SetUint64(3), nil, nil, nil)
-	if ret, _ := blockchain.ExecuteTx(tx2, &acc2, nil); ret != nil {
+	if ret, _ := blockchain.ExecuteTx(context.Background(), tx2, &acc2, nil, nil); ret != nil {
 		t.Error("tx should fail")
 	}
 	//ok to transfer 1 because 1+2(disallow)<4(balance)
 	tx3 := core.CreateTransferTx(blockchain, id2.GetKey().Bytes(), acc2, account.Address{},
 		new(big.Int).SetUint64(1), nil, nil, nil)
-	if _, err := blockchain.ExecuteTx(tx3, &acc2, nil); err != nil {
+	if _, err := blockchain.ExecuteTx(context.Background(), tx3, &acc2, nil, nil); err != nil {
 		t.Errorf("tx should succeed but get: %v", err)
 	}
 }
@@ -0,0 +1,150 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/core/rawdb"
+	"github.com/QuarkChain/goquarkchain/core/state"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/qkcdb"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+var (
+	ErrBalanceHistoryDisabled   = errors.New("balance history is disabled")
+	ErrBalanceHistoryNotWatched = errors.New("balance history of the address is not indexed")
+)
+
+// balanceHistory indexes the balances of the accounts changed by each block
+// executed, for the balances to be read at any height without the state of the
+// block, e.g. by exchanges reconciling their accounts. Either the watched
+// recipients or all of them are indexed, the balances of the accounts not
+// changed since the first indexed block are then read from its state, which
+// needs the archive gc mode.
+//
+// A watched recipient is indexed from the first block executed once it's
+// watched, with its balance at that block.
+type balanceHistory struct {
+	db      ethdb.Database
+	all     bool
+	started bool                    // whether the index of all the recipients is started
+	watched map[common.Address]bool // whether the index of the recipient is started
+}
+
+func newBalanceHistory(db ethdb.Database, cacheConfig *CacheConfig) *balanceHistory {
+	if !cacheConfig.BalanceHistory {
+		return nil
+	}
+	h := &balanceHistory{
+		db:      db,
+		all:     len(cacheConfig.BalanceHistoryRecipients) == 0,
+		watched: make(map[common.Address]bool),
+	}
+	if h.all {
+		start, _ := rawdb.ReadBalanceHistoryStart(db, nil)
+		h.started = start != nil
+	}
+	for i := range cacheConfig.BalanceHistoryRecipients {
+		recipient := cacheConfig.BalanceHistoryRecipients[i]
+		start, _ := rawdb.ReadBalanceHistoryStart(db, &recipient)
+		h.watched[recipient] = start != nil
+	}
+	return h
+}
+
+// index stores the balances of the indexed accounts changed by the block, with
+// evmState its state not committed yet.
+func (h *balanceHistory) index(block *types.MinorBlock, evmState *state.StateDB) {
+	number, hash := block.NumberU64(), block.Hash()
+	if h.all && !h.started {
+		rawdb.WriteBalanceHistoryStart(h.db, nil, number, hash)
+		h.started = true
+	}
+	for recipient, started := range h.watched {
+		if !started {
+			recipient := recipient
+			rawdb.WriteBalanceHistory(h.db, recipient, number, hash, evmState.GetBalances(recipient))
+			rawdb.WriteBalanceHistoryStart(h.db, &recipient, number, hash)
+			h.watched[recipient] = true
+		}
+	}
+	for _, addr := range evmState.DirtyAccounts() {
+		if !h.all && !h.watched[addr] {
+			continue
+		}
+		rawdb.WriteBalanceHistory(h.db, addr, number, hash, evmState.GetBalances(addr))
+	}
+}
+
+// GetBalanceHistory returns the balances of the recipient at the canonical
+// block of the height, the head if height is nil, along with the height of the
+// block which changed them last. That height is the first indexed one if the
+// balances are read from its state.
+func (m *MinorBlockChain) GetBalanceHistory(recipient account.Recipient, height *uint64) (*types.TokenBalances, uint64, uint64, error) {
+	if m.balanceHistory == nil {
+		return nil, 0, 0, ErrBalanceHistoryDisabled
+	}
+	number := m.CurrentBlock().NumberU64()
+	if height != nil {
+		if *height > number {
+			return nil, 0, 0, fmt.Errorf("height %d above the head %d", *height, number)
+		}
+		number = *height
+	}
+	key := &recipient
+	if m.balanceHistory.all {
+		key = nil
+	} else if !m.isBalanceHistoryRecipient(recipient) {
+		return nil, 0, 0, ErrBalanceHistoryNotWatched
+	}
+	start, _ := rawdb.ReadBalanceHistoryStart(m.db, key)
+	if start == nil {
+		return nil, 0, 0, ErrBalanceHistoryNotWatched
+	}
+	if number < *start {
+		return nil, 0, 0, fmt.Errorf("balance history indexed from height %d", *start)
+	}
+
+	kvdb, ok := rawdb.KeyValueStore(m.db).(qkcdb.KeyValueStore)
+	if !ok {
+		return nil, 0, 0, errors.New("only support qkcdb now")
+	}
+	var found *rawdb.BalanceHistoryEntry
+	err := rawdb.IterateBalanceHistory(kvdb, recipient, number, func(entry *rawdb.BalanceHistoryEntry) bool {
+		if entry.Number < *start {
+			return false
+		}
+		// the blocks out of the canonical chain are indexed as well
+		if rawdb.ReadCanonicalHash(m.db, rawdb.ChainTypeMinor, entry.Number) != entry.Hash {
+			return true
+		}
+		found = entry
+		return false
+	})
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if found != nil {
+		return found.Balances, number, found.Number, nil
+	}
+	// not changed since the first indexed block, or changed by its canonical
+	// block executed before the index started
+	hash := rawdb.ReadCanonicalHash(m.db, rawdb.ChainTypeMinor, *start)
+	balances, err := m.GetBalance(recipient, &hash)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("balance at height %d not indexed: %v", *start, err)
+	}
+	return balances, number, *start, nil
+}
+
+func (m *MinorBlockChain) isBalanceHistoryRecipient(recipient account.Recipient) bool {
+	for _, r := range m.cacheConfig.BalanceHistoryRecipients {
+		if r == recipient {
+			return true
+		}
+	}
+	return false
+}
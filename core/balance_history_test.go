@@ -0,0 +1,104 @@
+package core
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/qkcdb"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBalanceHistory(t *testing.T) {
+	dirname, err := ioutil.TempDir(os.TempDir(), "balance_history_test_")
+	checkErr(err)
+	defer os.RemoveAll(dirname)
+	db, err := qkcdb.NewLDBDatabase(dirname, false, false)
+	checkErr(err)
+	defer db.Close()
+
+	id1, err := account.CreatRandomIdentity()
+	checkErr(err)
+	acc1 := account.CreatAddressFromIdentity(id1, 0)
+	acc2, err := account.CreatRandomAccountWithFullShardKey(0)
+	checkErr(err)
+	acc3, err := account.CreatRandomAccountWithFullShardKey(0)
+	checkErr(err)
+
+	fakeMoney := uint64(10000000)
+	env := setUp(&acc1, &fakeMoney, nil)
+	env.db = db
+	shardState := createDefaultShardState(env, nil, nil, nil, nil)
+	defer shardState.Stop()
+	_, _, _, err = shardState.GetBalanceHistory(acc2.Recipient, nil)
+	assert.Equal(t, ErrBalanceHistoryDisabled, err)
+	shardState.cacheConfig.BalanceHistory = true
+	shardState.cacheConfig.BalanceHistoryRecipients = []common.Address{acc2.Recipient, acc3.Recipient}
+	shardState.balanceHistory = newBalanceHistory(db, shardState.cacheConfig)
+
+	rootBlock := shardState.rootTip.CreateBlockToAppend(nil, nil, nil, nil, nil).Finalize(nil, nil, common.Hash{})
+	_, err = shardState.AddRootBlock(rootBlock)
+	checkErr(err)
+
+	fakeGas := uint64(50000)
+	for i := 0; i < 3; i++ {
+		tx := createTransferTransaction(shardState, id1.GetKey().Bytes(), acc1, acc2, big.NewInt(12345), &fakeGas, nil, nil, nil, nil, nil)
+		checkErr(shardState.AddTx(tx))
+		b, err := shardState.CreateBlockToMine(nil, &acc1, nil, nil, nil)
+		checkErr(err)
+		_, _, err = shardState.FinalizeAndAddBlock(b)
+		checkErr(err)
+	}
+
+	genesisToken := shardState.GetGenesisToken()
+	for height := uint64(1); height <= 3; height++ {
+		h := height
+		balances, number, changed, err := shardState.GetBalanceHistory(acc2.Recipient, &h)
+		checkErr(err)
+		assert.Equal(t, height, number)
+		assert.Equal(t, height, changed)
+		assert.Equal(t, big.NewInt(int64(12345*height)), balances.GetTokenBalance(genesisToken))
+	}
+	// the watched accounts are indexed from the first block executed
+	balances, number, changed, err := shardState.GetBalanceHistory(acc3.Recipient, nil)
+	checkErr(err)
+	assert.Equal(t, uint64(3), number)
+	assert.Equal(t, uint64(1), changed)
+	assert.Equal(t, 0, balances.GetTokenBalance(genesisToken).Sign())
+
+	zero, tooHigh := uint64(0), uint64(4)
+	_, _, _, err = shardState.GetBalanceHistory(acc2.Recipient, &zero)
+	assert.Error(t, err)
+	_, _, _, err = shardState.GetBalanceHistory(acc2.Recipient, &tooHigh)
+	assert.Error(t, err)
+	_, _, _, err = shardState.GetBalanceHistory(acc1.Recipient, nil)
+	assert.Equal(t, ErrBalanceHistoryNotWatched, err)
+
+	// all the accounts are indexed from the next block, the ones not changed
+	// since are read from its state
+	shardState.cacheConfig.BalanceHistoryRecipients = nil
+	shardState.balanceHistory = newBalanceHistory(db, shardState.cacheConfig)
+	tx := createTransferTransaction(shardState, id1.GetKey().Bytes(), acc1, acc3, big.NewInt(1), &fakeGas, nil, nil, nil, nil, nil)
+	checkErr(shardState.AddTx(tx))
+	b, err := shardState.CreateBlockToMine(nil, &acc1, nil, nil, nil)
+	checkErr(err)
+	_, _, err = shardState.FinalizeAndAddBlock(b)
+	checkErr(err)
+
+	balances, _, changed, err = shardState.GetBalanceHistory(acc3.Recipient, nil)
+	checkErr(err)
+	assert.Equal(t, uint64(4), changed)
+	assert.Equal(t, big.NewInt(1), balances.GetTokenBalance(genesisToken))
+	balances, _, changed, err = shardState.GetBalanceHistory(acc2.Recipient, nil)
+	checkErr(err)
+	assert.Equal(t, uint64(4), changed)
+	assert.Equal(t, big.NewInt(3*12345), balances.GetTokenBalance(genesisToken))
+	_, _, _, err = shardState.GetBalanceHistory(acc2.Recipient, &tooHigh)
+	checkErr(err)
+	three := uint64(3)
+	_, _, _, err = shardState.GetBalanceHistory(acc2.Recipient, &three)
+	assert.Error(t, err)
+}
@@ -0,0 +1,101 @@
+package core
+
+import (
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/core/rawdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var (
+	// freezerRecheckInterval is the frequency to check the minor chain for
+	// blocks to move into the ancient store.
+	freezerRecheckInterval = time.Minute
+
+	// freezerBatchLimit is the maximum number of blocks to freeze in one batch
+	// before checking the chain interrupt.
+	freezerBatchLimit uint64 = 1000
+)
+
+// blockFreezer moves the canonical minor blocks confirmed by the root blocks
+// older than threshold root blocks from the key-value store into the ancient
+// store, such blocks can no longer be reorged.
+type blockFreezer struct {
+	chain     *MinorBlockChain
+	threshold uint64
+}
+
+func newBlockFreezer(chain *MinorBlockChain, threshold uint64) *blockFreezer {
+	return &blockFreezer{
+		chain:     chain,
+		threshold: threshold,
+	}
+}
+
+// loop freezes the old blocks periodically until the chain is stopped.
+func (f *blockFreezer) loop() {
+	defer f.chain.wg.Done()
+
+	ticker := time.NewTicker(freezerRecheckInterval)
+	defer ticker.Stop()
+	for {
+		f.run()
+		select {
+		case <-ticker.C:
+		case <-f.chain.quit:
+			return
+		}
+	}
+}
+
+// limit returns the number of the last minor block which should be frozen.
+func (f *blockFreezer) limit() (uint64, bool) {
+	rootTip := f.chain.GetRootTip()
+	if rootTip == nil || rootTip.NumberU64() <= f.threshold {
+		return 0, false
+	}
+	rHeader := f.chain.GetRootBlockHeaderByHeight(rootTip.Hash(), rootTip.NumberU64()-f.threshold)
+	if rHeader == nil {
+		return 0, false
+	}
+	confirmed := f.chain.getLastConfirmedMinorBlockHeaderAtRootBlock(rHeader.Hash())
+	if confirmed == nil {
+		return 0, false
+	}
+	if rawdb.ReadCanonicalHash(f.chain.db, rawdb.ChainTypeMinor, confirmed.Number) != confirmed.Hash() {
+		log.Warn(f.chain.logInfo, "confirmed minor block is not canonical", confirmed.Number)
+		return 0, false
+	}
+	return confirmed.Number, true
+}
+
+// run freezes all the blocks up to the limit.
+func (f *blockFreezer) run() {
+	limit, ok := f.limit()
+	if !ok {
+		return
+	}
+	frozen, err := f.chain.db.(rawdb.AncientReader).Ancients()
+	if err != nil {
+		log.Error(f.chain.logInfo, "failed to read ancient store", err)
+		return
+	}
+	start := frozen
+	for frozen <= limit {
+		if f.chain.getProcInterrupt() {
+			break
+		}
+		to := frozen + freezerBatchLimit - 1
+		if to > limit {
+			to = limit
+		}
+		if err := rawdb.FreezeMinorBlocks(f.chain.db, to); err != nil {
+			log.Error(f.chain.logInfo, "failed to freeze blocks from", frozen, "to", to, "err", err)
+			break
+		}
+		frozen = to + 1
+	}
+	if frozen > start {
+		log.Info(f.chain.logInfo, "froze blocks from", start, "to", frozen-1)
+	}
+}
@@ -0,0 +1,84 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/core/rawdb"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockFreezer(t *testing.T) {
+	dirname, err := ioutil.TempDir(os.TempDir(), "ancient_test_")
+	checkErr(err)
+	defer os.RemoveAll(dirname)
+	defer func(interval time.Duration) {
+		freezerRecheckInterval = interval
+	}(freezerRecheckInterval)
+	freezerRecheckInterval = 10 * time.Millisecond
+
+	id1, err := account.CreatRandomIdentity()
+	checkErr(err)
+	acc1 := account.CreatAddressFromIdentity(id1, 0)
+	fakeMoney := uint64(10000000)
+	env := setUp(&acc1, &fakeMoney, nil)
+	env.clusterConfig.AncientRootBlocks = 1
+	env.db, err = rawdb.NewDatabaseWithFreezer(env.db, dirname)
+	checkErr(err)
+	shardState := createDefaultShardState(env, nil, nil, nil, nil)
+	defer shardState.Stop()
+
+	rootBlock := shardState.rootTip.CreateBlockToAppend(nil, nil, nil, nil, nil)
+	rootBlock.AddMinorBlockHeader(shardState.CurrentBlock().Header())
+	rootBlock.Finalize(nil, nil, common.Hash{})
+	_, err = shardState.AddRootBlock(rootBlock)
+	checkErr(err)
+
+	blocks := make([]*types.MinorBlock, 0)
+	for i := 0; i < 3; i++ {
+		b, err := shardState.CreateBlockToMine(nil, &acc1, nil, nil, nil)
+		checkErr(err)
+		b, _, err = shardState.FinalizeAndAddBlock(b)
+		checkErr(err)
+		blocks = append(blocks, b)
+	}
+	rootBlock = shardState.rootTip.CreateBlockToAppend(nil, nil, nil, nil, nil)
+	rootBlock.AddMinorBlockHeader(blocks[0].Header())
+	rootBlock.AddMinorBlockHeader(blocks[1].Header())
+	rootBlock.Finalize(nil, nil, common.Hash{})
+	_, err = shardState.AddRootBlock(rootBlock)
+	checkErr(err)
+
+	// blocks confirmed by the root tip are not frozen
+	rootBlock = rootBlock.Header().CreateBlockToAppend(nil, nil, nil, nil, nil)
+	rootBlock.AddMinorBlockHeader(blocks[2].Header())
+	rootBlock.Finalize(nil, nil, common.Hash{})
+	_, err = shardState.AddRootBlock(rootBlock)
+	checkErr(err)
+
+	ancients := env.db.(rawdb.AncientReader)
+	for i := 0; i < 100; i++ {
+		if frozen, _ := ancients.Ancients(); frozen == 3 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	frozen, _ := ancients.Ancients()
+	assert.Equal(t, uint64(3), frozen)
+	time.Sleep(50 * time.Millisecond)
+	frozen, _ = ancients.Ancients()
+	assert.Equal(t, uint64(3), frozen)
+
+	for i, b := range blocks {
+		assert.Equal(t, i == 2, rawdb.ReadMinorBlock(rawdb.KeyValueStore(env.db), b.Hash()) != nil)
+		block := rawdb.ReadMinorBlock(env.db, b.Hash())
+		assert.NotNil(t, block)
+		assert.Equal(t, b.Hash(), block.Hash())
+		assert.Equal(t, b.Hash(), shardState.GetBlockByNumber(b.NumberU64()).Hash())
+	}
+}
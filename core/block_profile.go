@@ -0,0 +1,104 @@
+package core
+
+import (
+	"sync"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/hashicorp/golang-lru"
+)
+
+const (
+	maxRecentBlockProfiles  = 128
+	maxPendingBlockProfiles = 16
+)
+
+// pendingBlockProfile is the profile of a block created to mine, waiting for
+// its sealed block.
+type pendingBlockProfile struct {
+	profile *rpc.BlockProfile
+	created time.Time
+}
+
+// blockProfiler times the steps of the production of the blocks created to
+// mine, from the tx selection to the commit of their sealed blocks, so miners
+// can tune their gas limits and tx pool sizes. The blocks are matched with
+// their sealed blocks by seal hash, and the ones never sealed are dropped once
+// maxPendingBlockProfiles newer blocks are created.
+type blockProfiler struct {
+	pending *lru.Cache // *pendingBlockProfile by seal hash
+
+	mu     sync.Mutex
+	recent []*rpc.BlockProfile // oldest first
+}
+
+func newBlockProfiler() *blockProfiler {
+	pending, _ := lru.New(maxPendingBlockProfiles)
+	return &blockProfiler{pending: pending}
+}
+
+// created records the profile of the block created to mine.
+func (p *blockProfiler) created(block *types.MinorBlock, start time.Time, txSelection, execution, stateRoot time.Duration) {
+	p.pending.Add(block.Header().SealHash(), &pendingBlockProfile{
+		profile: &rpc.BlockProfile{
+			Height:      block.NumberU64(),
+			TxCount:     uint32(len(block.GetTransactions())),
+			GasUsed:     block.GetMetaData().GasUsed.Value.Uint64(),
+			GasLimit:    block.GasLimit().Uint64(),
+			Time:        uint64(start.UnixNano() / int64(time.Millisecond)),
+			TxSelection: uint64(txSelection / time.Microsecond),
+			Execution:   uint64(execution / time.Microsecond),
+			StateRoot:   uint64(stateRoot / time.Microsecond),
+		},
+		created: time.Now(),
+	})
+}
+
+// inserted completes the profile of the block created to mine once its sealed
+// block is inserted, it is a no-op for the blocks not created by the node.
+func (p *blockProfiler) inserted(block *types.MinorBlock, start time.Time, commit time.Duration) {
+	sealHash := block.Header().SealHash()
+	value, ok := p.pending.Get(sealHash)
+	if !ok {
+		return
+	}
+	p.pending.Remove(sealHash)
+	pending := value.(*pendingBlockProfile)
+	profile := *pending.profile
+	profile.Hash = block.Hash()
+	if start.After(pending.created) {
+		profile.SealWait = uint64(start.Sub(pending.created) / time.Microsecond)
+	}
+	profile.Commit = uint64(commit / time.Microsecond)
+
+	p.mu.Lock()
+	p.recent = append(p.recent, &profile)
+	if len(p.recent) > maxRecentBlockProfiles {
+		p.recent = p.recent[len(p.recent)-maxRecentBlockProfiles:]
+	}
+	p.mu.Unlock()
+
+	log.Debug("Produced block", "number", profile.Height, "hash", profile.Hash, "txs", profile.TxCount,
+		"selection", microseconds(profile.TxSelection), "execution", microseconds(profile.Execution),
+		"root", microseconds(profile.StateRoot), "seal", microseconds(profile.SealWait),
+		"commit", microseconds(profile.Commit))
+}
+
+// profiles returns up to limit of the profiles of the latest blocks produced,
+// newest first.
+func (p *blockProfiler) profiles(limit uint32) []*rpc.BlockProfile {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	list := make([]*rpc.BlockProfile, 0)
+	for i := len(p.recent) - 1; i >= 0 && uint32(len(list)) < limit; i-- {
+		list = append(list, p.recent[i])
+	}
+	return list
+}
+
+func microseconds(us uint64) common.PrettyDuration {
+	return common.PrettyDuration(time.Duration(us) * time.Microsecond)
+}
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockProfiles(t *testing.T) {
+	id1, err := account.CreatRandomIdentity()
+	checkErr(err)
+	acc1 := account.CreatAddressFromIdentity(id1, 0)
+	acc2, err := account.CreatRandomAccountWithFullShardKey(0)
+	checkErr(err)
+
+	fakeMoney := uint64(10000000)
+	env := setUp(&acc1, &fakeMoney, nil)
+	shardState := createDefaultShardState(env, nil, nil, nil, nil)
+	defer shardState.Stop()
+	rootBlock := shardState.rootTip.CreateBlockToAppend(nil, nil, nil, nil, nil).Finalize(nil, nil, common.Hash{})
+	_, err = shardState.AddRootBlock(rootBlock)
+	checkErr(err)
+
+	nonce := uint64(0)
+	tx := createTransferTransaction(shardState, id1.GetKey().Bytes(), acc1, acc2, big.NewInt(1), nil, nil, &nonce, nil, nil, nil)
+	checkErr(shardState.AddTx(tx))
+	b1, err := shardState.CreateBlockToMine(nil, &acc2, nil, nil, nil)
+	checkErr(err)
+	// the block is not profiled until its sealed block is inserted
+	assert.Empty(t, shardState.GetBlockProfiles(10))
+	_, _, err = shardState.FinalizeAndAddBlock(b1)
+	checkErr(err)
+
+	profiles := shardState.GetBlockProfiles(10)
+	assert.Len(t, profiles, 1)
+	assert.Equal(t, b1.Hash(), profiles[0].Hash)
+	assert.Equal(t, uint64(1), profiles[0].Height)
+	assert.Equal(t, uint32(1), profiles[0].TxCount)
+	assert.Equal(t, b1.GetMetaData().GasUsed.Value.Uint64(), profiles[0].GasUsed)
+	assert.Equal(t, b1.GasLimit().Uint64(), profiles[0].GasLimit)
+	assert.NotZero(t, profiles[0].Time)
+
+	// the blocks created but never sealed are dropped, the ones not created
+	// by the node are not profiled
+	for i := 0; i < maxPendingBlockProfiles+1; i++ {
+		coinbase, err := account.CreatRandomAccountWithFullShardKey(0)
+		checkErr(err)
+		_, err = shardState.CreateBlockToMine(nil, &coinbase, nil, nil, nil)
+		checkErr(err)
+	}
+	assert.Equal(t, maxPendingBlockProfiles, shardState.blockProfiles.pending.Len())
+	b2 := shardState.CurrentBlock().CreateBlockToAppend(nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	_, _, err = shardState.FinalizeAndAddBlock(b2)
+	checkErr(err)
+	assert.Len(t, shardState.GetBlockProfiles(10), 1)
+
+	b3, err := shardState.CreateBlockToMine(nil, &acc2, nil, nil, nil)
+	checkErr(err)
+	_, _, err = shardState.FinalizeAndAddBlock(b3)
+	checkErr(err)
+	profiles = shardState.GetBlockProfiles(1)
+	assert.Len(t, profiles, 1)
+	assert.Equal(t, b3.Hash(), profiles[0].Hash)
+	assert.Equal(t, uint32(0), profiles[0].TxCount)
+}
@@ -0,0 +1,85 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	qkcCommon "github.com/QuarkChain/goquarkchain/common"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// ReplayBlocks re-executes the canonical blocks from first to last on the
+// states of their parents, and compares the state roots, the receipts and the
+// gas used to the ones stored with the blocks, to detect the corruption of the
+// database or consensus bugs after upgrades. Nothing is written to the
+// database, and a block whose parent state is missing, e.g. pruned, is
+// reported with its error. The genesis block is skipped as it has no parent.
+func (m *MinorBlockChain) ReplayBlocks(first, last uint64) ([]*rpc.BlockReplayResult, error) {
+	if first > last {
+		return nil, errors.New("invalid block range")
+	}
+	if head := m.CurrentBlock().NumberU64(); last > head {
+		last = head
+	}
+	if first == 0 {
+		first = 1
+	}
+	results := make([]*rpc.BlockReplayResult, 0)
+	for number := first; number <= last; number++ {
+		block, ok := m.GetBlockByNumber(number).(*types.MinorBlock)
+		if !ok || qkcCommon.IsNil(block) {
+			return nil, fmt.Errorf("block %d not found", number)
+		}
+		results = append(results, m.replayBlock(block))
+	}
+	return results, nil
+}
+
+func (m *MinorBlockChain) replayBlock(block *types.MinorBlock) *rpc.BlockReplayResult {
+	result := &rpc.BlockReplayResult{
+		Number:              block.NumberU64(),
+		Hash:                block.Hash(),
+		TxCount:             uint32(len(block.Transactions())),
+		ExpectedStateRoot:   block.Root(),
+		ExpectedReceiptHash: block.ReceiptHash(),
+		ExpectedGasUsed:     block.GasUsed().Uint64(),
+		BadReceipts:         make([]uint32, 0),
+	}
+	evmState, receipts, _, _, _, err := m.runBlock(block)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.StateRoot = evmState.IntermediateRoot(true)
+	result.ReceiptHash = types.DeriveSha(receipts)
+	result.GasUsed = evmState.GetGasUsed().Uint64()
+
+	stored := m.GetReceiptsByHash(block.Hash())
+	for i, receipt := range receipts {
+		if i >= len(stored) || !sameReceipt(receipt, stored[i]) {
+			result.BadReceipts = append(result.BadReceipts, uint32(i))
+		}
+	}
+	for i := len(receipts); i < len(stored); i++ {
+		result.BadReceipts = append(result.BadReceipts, uint32(i))
+	}
+	if !result.Matched() {
+		m.reportBlock(block, receipts, fmt.Errorf("replayed block mismatches (state root %x, receipt hash %x, gas used %d, bad receipts %v)",
+			result.StateRoot, result.ReceiptHash, result.GasUsed, result.BadReceipts))
+	}
+	return result
+}
+
+// sameReceipt reports whether the consensus fields of the receipts, and the tx
+// hashes and the gas used stored with them, are equal.
+func sameReceipt(a, b *types.Receipt) bool {
+	if a.TxHash != b.TxHash || a.GasUsed != b.GasUsed {
+		return false
+	}
+	ea, erra := rlp.EncodeToBytes(a)
+	eb, errb := rlp.EncodeToBytes(b)
+	return erra == nil && errb == nil && bytes.Equal(ea, eb)
+}
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/core/rawdb"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplayBlocks(t *testing.T) {
+	id1, err := account.CreatRandomIdentity()
+	checkErr(err)
+	acc1 := account.CreatAddressFromIdentity(id1, 0)
+	acc2, err := account.CreatRandomAccountWithFullShardKey(0)
+	checkErr(err)
+
+	fakeMoney := uint64(10000000)
+	env := setUp(&acc1, &fakeMoney, nil)
+	shardState := createDefaultShardState(env, nil, nil, nil, nil)
+	defer shardState.Stop()
+	rootBlock := shardState.rootTip.CreateBlockToAppend(nil, nil, nil, nil, nil).Finalize(nil, nil, common.Hash{})
+	_, err = shardState.AddRootBlock(rootBlock)
+	checkErr(err)
+
+	nonce := uint64(0)
+	for i := 0; i < 2; i++ {
+		tx := createTransferTransaction(shardState, id1.GetKey().Bytes(), acc1, acc2, big.NewInt(1), nil, nil, &nonce, nil, nil, nil)
+		checkErr(shardState.AddTx(tx))
+		b, err := shardState.CreateBlockToMine(nil, &acc2, nil, nil, nil)
+		checkErr(err)
+		_, _, err = shardState.FinalizeAndAddBlock(b)
+		checkErr(err)
+		nonce++
+	}
+
+	_, err = shardState.ReplayBlocks(2, 1)
+	assert.Error(t, err)
+	// the genesis block is skipped and the range ends at the head
+	results, err := shardState.ReplayBlocks(0, 10)
+	checkErr(err)
+	assert.Len(t, results, 2)
+	for i, result := range results {
+		block := shardState.GetBlockByNumber(uint64(i + 1)).(*types.MinorBlock)
+		assert.True(t, result.Matched())
+		assert.Equal(t, block.Hash(), result.Hash)
+		assert.Equal(t, uint32(1), result.TxCount)
+		assert.Equal(t, block.Root(), result.StateRoot)
+		assert.Empty(t, result.BadReceipts)
+	}
+
+	// a corrupted receipt is reported
+	b2 := shardState.CurrentBlock()
+	receipts := shardState.GetReceiptsByHash(b2.Hash())
+	corrupted := *receipts[0]
+	corrupted.GasUsed++
+	rawdb.WriteReceipts(shardState.db, b2.Hash(), types.Receipts{&corrupted})
+	shardState.receiptsCache.Purge()
+	results, err = shardState.ReplayBlocks(2, 2)
+	checkErr(err)
+	assert.Len(t, results, 1)
+	assert.False(t, results[0].Matched())
+	assert.Equal(t, []uint32{0}, results[0].BadReceipts)
+	assert.Equal(t, results[0].ExpectedStateRoot, results[0].StateRoot)
+}
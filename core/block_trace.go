@@ -0,0 +1,81 @@
+package core
+
+import (
+	"context"
+	"errors"
+
+	"github.com/QuarkChain/goquarkchain/common/hexutil"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/core/vm"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// TxTrace is the trace of the execution of a tx of a block.
+type TxTrace struct {
+	TxHash      common.Hash    `json:"txHash"`
+	Gas         uint64         `json:"gas"`
+	Failed      bool           `json:"failed"`
+	ReturnValue hexutil.Bytes  `json:"returnValue"`
+	StructLogs  []vm.StructLog `json:"structLogs"`
+	Error       string         `json:"error,omitempty"` // why the tx can't be applied
+}
+
+// TraceBlock executes the txs of the block again on the state of its parent,
+// after its cross-shard deposits, with a struct logger configured by logCfg,
+// and calls fn with the trace of each tx in order. The block may be one which
+// failed to be inserted lately. The tracing stops at the first tx which can't
+// be applied, whose trace has the error, or once ctx is done.
+func (m *MinorBlockChain) TraceBlock(ctx context.Context, hash common.Hash, logCfg *vm.LogConfig, fn func(*TxTrace) error) error {
+	block := m.GetMinorBlock(hash)
+	if block == nil {
+		if bad, ok := m.badBlocks.Get(hash); ok {
+			block = bad.(*types.MinorBlock)
+		}
+	}
+	if block == nil {
+		return errors.New("minor block not found")
+	}
+	if block.NumberU64() == 0 {
+		return nil
+	}
+	evmState, _, _, err := m.runCrossShardTxs(block)
+	if err != nil {
+		return err
+	}
+	evmState.SetQuarkChainConfig(m.clusterConfig.Quarkchain)
+	evmState.SetBlockCoinbase(block.Coinbase().Recipient)
+	evmState.SetGasLimit(block.GasLimit())
+	var (
+		usedGas = new(uint64)
+		header  = block.IHeader()
+		gp      = new(GasPool).AddGas(block.GasLimit().Uint64())
+		xGas    = block.GetXShardGasLimit().Uint64()
+	)
+	for i, tx := range block.GetTransactions() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		trace := &TxTrace{TxHash: tx.Hash(), StructLogs: make([]vm.StructLog, 0)}
+		evmTx, err := m.validateTx(tx, evmState, nil, nil, &xGas)
+		if err == nil {
+			evmState.Prepare(tx.Hash(), block.Hash(), i)
+			logger := vm.NewStructLogger(logCfg)
+			cfg := m.vmConfig
+			cfg.Debug, cfg.Tracer = true, logger
+			var receipt *types.Receipt
+			trace.ReturnValue, receipt, trace.Gas, err = ApplyTransaction(m.ethChainConfig, m, gp, evmState, header, evmTx, usedGas, cfg)
+			if err == nil {
+				trace.Failed = receipt.Status == types.ReceiptStatusFailed
+			}
+			trace.StructLogs = append(trace.StructLogs, logger.StructLogs()...)
+		}
+		if err != nil {
+			trace.Error = err.Error()
+			return fn(trace)
+		}
+		if err := fn(trace); err != nil {
+			return err
+		}
+	}
+	return nil
+}
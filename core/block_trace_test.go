@@ -0,0 +1,74 @@
+package core
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/core/vm"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTraceBlock(t *testing.T) {
+	id1, err := account.CreatRandomIdentity()
+	checkErr(err)
+	acc1 := account.CreatAddressFromIdentity(id1, 0)
+	acc2, err := account.CreatRandomAccountWithFullShardKey(0)
+	checkErr(err)
+	fakeMoney := uint64(10000000)
+	env := setUp(&acc1, &fakeMoney, nil)
+	shardState := createDefaultShardState(env, nil, nil, nil, nil)
+	defer shardState.Stop()
+	rootBlock := shardState.rootTip.CreateBlockToAppend(nil, nil, nil, nil, nil).Finalize(nil, nil, common.Hash{})
+	_, err = shardState.AddRootBlock(rootBlock)
+	checkErr(err)
+
+	tx, err := CreateContract(shardState, id1.GetKey(), acc1, 0, ContractCreationByteCode)
+	checkErr(err)
+	checkErr(shardState.AddTx(tx))
+	b, err := shardState.CreateBlockToMine(nil, &acc2, nil, nil, nil)
+	checkErr(err)
+	b, receipts, err := shardState.FinalizeAndAddBlock(b)
+	checkErr(err)
+
+	traces := make([]*TxTrace, 0)
+	collect := func(trace *TxTrace) error {
+		traces = append(traces, trace)
+		return nil
+	}
+	checkErr(shardState.TraceBlock(context.Background(), b.Hash(), &vm.LogConfig{}, collect))
+	if assert.Len(t, traces, 1) {
+		assert.Equal(t, tx.Hash(), traces[0].TxHash)
+		assert.Equal(t, receipts[0].GasUsed, traces[0].Gas)
+		assert.False(t, traces[0].Failed)
+		assert.Empty(t, traces[0].Error)
+		assert.NotEmpty(t, traces[0].StructLogs)
+	}
+	assert.Error(t, shardState.TraceBlock(context.Background(), common.Hash{1}, &vm.LogConfig{}, collect))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, shardState.TraceBlock(ctx, b.Hash(), &vm.LogConfig{}, collect))
+
+	// a block failing to be inserted can be traced
+	tx = createTransferTransaction(shardState, id1.GetKey().Bytes(), acc1, acc2, big.NewInt(1), nil, nil, nil, nil, nil, nil)
+	checkErr(shardState.AddTx(tx))
+	b, err = shardState.CreateBlockToMine(nil, &acc2, nil, nil, nil)
+	checkErr(err)
+	evmState, receipts, _, _, _, err := shardState.runBlock(b)
+	checkErr(err)
+	coinbaseAmount := shardState.getCoinbaseAmount(b.NumberU64())
+	coinbaseAmount.Add(evmState.GetBlockFee())
+	b.Finalize(receipts, common.Hash{1}, evmState.GetGasUsed(), evmState.GetXShardReceiveGasUsed(), coinbaseAmount, evmState.GetTxCursorInfo())
+	_, err = shardState.InsertChain([]types.IBlock{b}, false)
+	assert.Error(t, err)
+	traces = traces[:0]
+	checkErr(shardState.TraceBlock(context.Background(), b.Hash(), &vm.LogConfig{}, collect))
+	if assert.Len(t, traces, 1) {
+		assert.Equal(t, tx.Hash(), traces[0].TxHash)
+		assert.Equal(t, uint64(21000), traces[0].Gas)
+		assert.Empty(t, traces[0].StructLogs)
+	}
+}
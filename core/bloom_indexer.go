@@ -0,0 +1,124 @@
+package core
+
+import (
+	"errors"
+
+	qkcCommon "github.com/QuarkChain/goquarkchain/common"
+	"github.com/QuarkChain/goquarkchain/core/rawdb"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/bitutil"
+	"github.com/ethereum/go-ethereum/core/bloombits"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var (
+	// bloomBitsBlocks is the number of minor blocks a single bloom bit section
+	// vector contains.
+	bloomBitsBlocks uint64 = 4096
+
+	// bloomConfirms is the number of confirmation blocks before a bloom section
+	// is considered final and its bloom bits are generated.
+	bloomConfirms uint64 = 256
+)
+
+// bloomIndexer generates the rotated bloom bits of the minor chain for each
+// section of bloomBitsBlocks blocks in the background, so that log filters
+// can skip the sections and blocks whose blooms can not match.
+type bloomIndexer struct {
+	chain    *MinorBlockChain
+	size     uint64
+	confirms uint64
+}
+
+func newBloomIndexer(chain *MinorBlockChain) *bloomIndexer {
+	return &bloomIndexer{
+		chain:    chain,
+		size:     bloomBitsBlocks,
+		confirms: bloomConfirms,
+	}
+}
+
+// loop generates the bloom bits of new sections until the chain is stopped.
+func (b *bloomIndexer) loop() {
+	indexLoop(b.chain, b.run)
+}
+
+// sectionHead returns the canonical hash of the last block of the section.
+func (b *bloomIndexer) sectionHead(section uint64) common.Hash {
+	return rawdb.ReadCanonicalHash(b.chain.db, rawdb.ChainTypeMinor, (section+1)*b.size-1)
+}
+
+// sections returns the number of indexed sections which are still canonical.
+func (b *bloomIndexer) sections() uint64 {
+	sections := rawdb.ReadBloomBitsSections(b.chain.db)
+	for sections > 0 && rawdb.ReadBloomBitsSectionHead(b.chain.db, sections-1) != b.sectionHead(sections-1) {
+		sections--
+	}
+	return sections
+}
+
+// run generates the bloom bits of the sections confirmed by the given head.
+func (b *bloomIndexer) run(head uint64) {
+	if head+1 < b.confirms {
+		return
+	}
+	available := (head + 1 - b.confirms) / b.size
+	for section := b.sections(); section < available; section++ {
+		if b.chain.getProcInterrupt() {
+			return
+		}
+		if err := b.process(section); err != nil {
+			log.Error(b.chain.logInfo, "failed to generate bloom bits of section", section, "err", err)
+			return
+		}
+	}
+}
+
+// process generates and stores the bloom bits of the section.
+func (b *bloomIndexer) process(section uint64) error {
+	gen, err := bloombits.NewGenerator(uint(b.size))
+	if err != nil {
+		return err
+	}
+	var head common.Hash
+	for i := uint64(0); i < b.size; i++ {
+		header := b.chain.GetHeaderByNumber(section*b.size + i)
+		if qkcCommon.IsNil(header) {
+			return errors.New("canonical header missing")
+		}
+		if err := gen.AddBloom(uint(i), ethTypes.Bloom(header.(*types.MinorBlockHeader).Bloom)); err != nil {
+			return err
+		}
+		head = header.Hash()
+	}
+	batch := b.chain.db.NewBatch()
+	for bit := uint(0); bit < types.BloomBitLength; bit++ {
+		bits, err := gen.Bitset(bit)
+		if err != nil {
+			return err
+		}
+		rawdb.WriteBloomBits(batch, bit, section, head, bitutil.CompressBytes(bits))
+	}
+	rawdb.WriteBloomBitsSectionHead(batch, section, head)
+	rawdb.WriteBloomBitsSections(batch, section+1)
+	return batch.Write()
+}
+
+// BloomStatus returns the number of blocks of a bloom bits section and the
+// number of indexed sections.
+func (m *MinorBlockChain) BloomStatus() (uint64, uint64) {
+	return m.bloomIndexer.size, m.bloomIndexer.sections()
+}
+
+// GetBloomBits returns the bloom bits vector of the indexed section for bit.
+func (m *MinorBlockChain) GetBloomBits(bit uint, section uint64) ([]byte, error) {
+	head := m.bloomIndexer.sectionHead(section)
+	if rawdb.ReadBloomBitsSectionHead(m.db, section) != head {
+		return nil, errors.New("bloom bits section is not indexed")
+	}
+	// empty vectors are compressed to nothing and may be missing in db
+	compVector, _ := rawdb.ReadBloomBits(m.db, bit, section, head)
+	return bitutil.DecompressBytes(compVector, int(m.bloomIndexer.size/8))
+}
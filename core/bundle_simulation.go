@@ -0,0 +1,68 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/core/types"
+)
+
+// MaxBundleSize is the max number of txs of a simulated bundle.
+const MaxBundleSize = 256
+
+// SimulateBundle applies the signed txs in order on a copy of the state of the
+// block at the height, the head if nil, as the txs of the next block would be,
+// and returns the result of each tx, whose receipt has the cumulative gas used.
+// Nothing is broadcast or kept. The bundle is atomic: if a tx can't be applied,
+// e.g. its nonce or balance is wrong, the error names it and no result is
+// returned. The simulation is aborted once ctx is done.
+func (m *MinorBlockChain) SimulateBundle(ctx context.Context, txs []*types.Transaction, height *uint64) ([]*rpc.BundleTxResult, error) {
+	if len(txs) == 0 {
+		return nil, errors.New("empty bundle")
+	}
+	if len(txs) > MaxBundleSize {
+		return nil, fmt.Errorf("bundle of %d txs exceeds the limit of %d", len(txs), MaxBundleSize)
+	}
+	if height == nil {
+		temp := m.CurrentBlock().NumberU64()
+		height = &temp
+	}
+	mBlock, ok := m.GetBlockByNumber(*height).(*types.MinorBlock)
+	if !ok {
+		return nil, ErrMinorBlockIsNil
+	}
+	evmState, err := m.stateAtWithSenderDisallowMap(mBlock, nil)
+	if err != nil {
+		return nil, err
+	}
+	next := mBlock.CreateBlockToAppend(nil, nil, nil, nil, nil, mBlock.GetXShardGasLimit(), nil, nil, nil)
+	header := next.Header()
+	evmState = evmState.Copy()
+	m.setEvmStateWithHeader(evmState, header)
+	evmState.SetGasUsed(new(big.Int))
+	var (
+		usedGas = new(uint64)
+		gp      = new(GasPool).AddGas(next.GasLimit().Uint64())
+		xGas    = next.GetXShardGasLimit().Uint64()
+		results = make([]*rpc.BundleTxResult, 0, len(txs))
+	)
+	for i, tx := range txs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		evmTx, err := m.validateTx(tx, evmState, nil, nil, &xGas)
+		if err != nil {
+			return nil, fmt.Errorf("tx %d %x: %v", i, tx.Hash(), err)
+		}
+		evmState.Prepare(tx.Hash(), header.Hash(), i)
+		ret, receipt, _, err := ApplyTransaction(m.ethChainConfig, m, gp, evmState, header, evmTx, usedGas, m.vmConfig)
+		if err != nil {
+			return nil, fmt.Errorf("tx %d %x: %v", i, tx.Hash(), err)
+		}
+		results = append(results, &rpc.BundleTxResult{Receipt: receipt, ReturnValue: ret})
+	}
+	return results, nil
+}
@@ -0,0 +1,76 @@
+package core
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimulateBundle(t *testing.T) {
+	id1, err := account.CreatRandomIdentity()
+	checkErr(err)
+	acc1 := account.CreatAddressFromIdentity(id1, 0)
+	id2, err := account.CreatRandomIdentity()
+	checkErr(err)
+	acc2 := account.CreatAddressFromIdentity(id2, 0)
+	acc3, err := account.CreatRandomAccountWithFullShardKey(0)
+	checkErr(err)
+	fakeMoney := uint64(10000000)
+	env := setUp(&acc1, &fakeMoney, nil)
+	shardState := createDefaultShardState(env, nil, nil, nil, nil)
+	defer shardState.Stop()
+	rootBlock := shardState.rootTip.CreateBlockToAppend(nil, nil, nil, nil, nil).Finalize(nil, nil, common.Hash{})
+	_, err = shardState.AddRootBlock(rootBlock)
+	checkErr(err)
+
+	// the second tx spends what the first one transfers
+	fakeGas := uint64(50000)
+	tx1 := createTransferTransaction(shardState, id1.GetKey().Bytes(), acc1, acc2, big.NewInt(1000000), &fakeGas, nil, nil, nil, nil, nil)
+	tx2 := createTransferTransaction(shardState, id2.GetKey().Bytes(), acc2, acc3, big.NewInt(100), &fakeGas, nil, nil, nil, nil, nil)
+	results, err := shardState.SimulateBundle(context.Background(), []*types.Transaction{tx1, tx2}, nil)
+	checkErr(err)
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, tx1.Hash(), results[0].Receipt.TxHash)
+		assert.Equal(t, types.ReceiptStatusSuccessful, results[0].Receipt.Status)
+		assert.Equal(t, uint64(21000), results[0].Receipt.GasUsed)
+		assert.Equal(t, uint64(21000), results[0].Receipt.CumulativeGasUsed)
+		assert.Equal(t, tx2.Hash(), results[1].Receipt.TxHash)
+		assert.Equal(t, uint64(42000), results[1].Receipt.CumulativeGasUsed)
+	}
+	// nothing is kept
+	nonce, err := shardState.GetTransactionCount(acc1.Recipient, nil)
+	checkErr(err)
+	assert.Equal(t, uint64(0), nonce)
+	assert.Equal(t, 0, shardState.txPool.PendingCount())
+
+	// the bundle fails as a whole
+	_, err = shardState.SimulateBundle(context.Background(), []*types.Transaction{tx2, tx1}, nil)
+	assert.Error(t, err)
+	_, err = shardState.SimulateBundle(context.Background(), []*types.Transaction{tx1, tx1}, nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "tx 1")
+	}
+	_, err = shardState.SimulateBundle(context.Background(), nil, nil)
+	assert.Error(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = shardState.SimulateBundle(ctx, []*types.Transaction{tx1}, nil)
+	assert.Equal(t, context.Canceled, err)
+
+	// the bundle is applied on the state at the height
+	checkErr(shardState.AddTx(tx1))
+	b, err := shardState.CreateBlockToMine(nil, &acc3, nil, nil, nil)
+	checkErr(err)
+	_, _, err = shardState.FinalizeAndAddBlock(b)
+	checkErr(err)
+	_, err = shardState.SimulateBundle(context.Background(), []*types.Transaction{tx1}, nil)
+	assert.Error(t, err)
+	height := uint64(0)
+	_, err = shardState.SimulateBundle(context.Background(), []*types.Transaction{tx1}, &height)
+	assert.NoError(t, err)
+}
@@ -38,6 +38,9 @@ var (
 	// plus one.
 	ErrInvalidNumber = errors.New("invalid block number")
 
+	// ErrReorgTooDeep is returned if a reorg would replace a finalized root block.
+	ErrReorgTooDeep = errors.New("reorg too deep")
+
 	errNoGenesis                 = errors.New("genesis not found in chain")
 	ErrMinorBlockIsNil           = errors.New("minor block is nil")
 	ErrRootBlockIsNil            = errors.New("root block is nil")
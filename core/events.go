@@ -44,6 +44,28 @@ type RootChainSideEvent struct {
 
 type RootChainHeadEvent struct{ Block *types.RootBlock }
 
+// MinorChainReorgEvent is posted when the canonical minor chain is reorganized.
+// The old and the new chains are ordered from the head down to the block above
+// the common ancestor.
+type MinorChainReorgEvent struct {
+	CommonBlock *types.MinorBlock // nil if the chains do not share the genesis
+	OldChain    []*types.MinorBlock
+	NewChain    []*types.MinorBlock
+	Depth       uint64             // number of the dropped blocks
+	DroppedTxs  types.Transactions // txs of the old chain not included by the new one
+}
+
+// RootChainReorgEvent is posted when the canonical root chain is reorganized.
+// The old and the new chains are ordered from the head down to the block above
+// the common ancestor.
+type RootChainReorgEvent struct {
+	CommonBlock    *types.RootBlock
+	OldChain       []*types.RootBlock
+	NewChain       []*types.RootBlock
+	Depth          uint64                  // number of the dropped blocks
+	DroppedHeaders types.MinorBlockHeaders // minor blocks no longer confirmed by the root chain
+}
+
 type LoglistEvent struct {
 	Logs      [][]*types.Log
 	IsRemoved bool
@@ -34,11 +34,14 @@ type ChainContext interface {
 	Config() *config.QuarkChainConfig
 	// GetHeader returns the hash corresponding to their hash.
 	GetHeader(common.Hash) types.IHeader
+	// CheckXShardTarget returns an error if the contracts executed in the block
+	// can't send deposits to the full shard key.
+	CheckXShardTarget(header types.IHeader, fullShardKey uint32) error
 }
 
 func NewEVMContext(msg types.Message, mheader types.IHeader, chain ChainContext) vm.Context {
 	header := mheader.(*types.MinorBlockHeader)
-	return vm.Context{
+	ctx := vm.Context{
 		CanTransfer:     CanTransfer,
 		Transfer:        Transfer,
 		GetHash:         GetHashFn(header, chain),
@@ -52,7 +55,15 @@ func NewEVMContext(msg types.Message, mheader types.IHeader, chain ChainContext)
 		ToFullShardKey:  msg.ToFullShardKey(),
 		GasTokenID:      msg.GasTokenID(),
 		TransferTokenID: msg.TransferTokenID(),
+		ChainID:         header.Branch.GetChainID(),
+		TxHash:          msg.TxHash(),
 	}
+	if chain != nil {
+		ctx.CheckXShardTarget = func(fullShardKey uint32) error {
+			return chain.CheckXShardTarget(header, fullShardKey)
+		}
+	}
+	return ctx
 }
 
 // GetHashFn returns a GetHashFunc which retrieves header hashes by number
@@ -17,11 +17,15 @@
 package core
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"math/big"
 
 	"github.com/QuarkChain/goquarkchain/core/types"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/bitutil"
+	"github.com/ethereum/go-ethereum/crypto"
 )
 
 type Backend interface {
@@ -29,6 +33,8 @@ type Backend interface {
 	GetReceiptsByHash(hash common.Hash) types.Receipts
 	GetLogs(hash common.Hash) [][]*types.Log
 	CurrentBlock() *types.MinorBlock
+	BloomStatus() (uint64, uint64)
+	GetBloomBits(bit uint, section uint64) ([]byte, error)
 }
 
 // Filter can be used to retrieve and filter logs.
@@ -37,6 +43,7 @@ type Filter struct {
 
 	addresses []common.Address
 	topics    [][]common.Hash
+	filters   [][][]byte // flattened address and topic clauses to match bloom bits
 
 	block      common.Hash // Block hash if filtering a single block
 	begin, end uint64      // Range interval if filtering multiple blocks
@@ -67,6 +74,7 @@ func NewRangeFilter(backend Backend, begin, end uint64, addresses []common.Addre
 	// Create a generic filter and convert it into a range filter
 	filter := newFilter(backend, addresses, topics)
 
+	filter.filters = filters
 	filter.begin = begin
 	filter.end = end
 
@@ -85,24 +93,124 @@ func newFilter(backend Backend, addresses []common.Address, topics [][]common.Ha
 
 // Logs searches the blockchain for matching log entries, returning all from the
 // first block that contains matches, updating the start of the filter accordingly.
-func (f *Filter) Logs() ([]*types.Log, error) {
+// The search stops once ctx is done.
+func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	// Gather all indexed logs, and finish with non indexed ones
 	var (
 		logs []*types.Log
 		err  error
 	)
-
-	rest, err := f.unindexedLogs(f.end)
+	size, sections := f.backend.BloomStatus()
+	if indexed := sections * size; indexed > f.begin {
+		end := indexed - 1
+		if end > f.end {
+			end = f.end
+		}
+		logs, err = f.indexedLogs(ctx, size, end)
+		if err != nil {
+			return logs, err
+		}
+	}
+	rest, err := f.unindexedLogs(ctx, f.end)
 	logs = append(logs, rest...)
 	return logs, err
 }
 
+// indexedLogs returns the logs matching the filter criteria based on the bloom
+// bits of indexed sections, only the blocks whose blooms may match are checked.
+func (f *Filter) indexedLogs(ctx context.Context, size, end uint64) ([]*types.Log, error) {
+	var logs []*types.Log
+
+	for f.begin <= end {
+		section := f.begin / size
+		matches, err := f.matchSection(section, size)
+		if err != nil {
+			// the section is reorged out, check all of its blocks
+			matches = bytes.Repeat([]byte{0xff}, int(size/8))
+		}
+		sectionEnd := (section+1)*size - 1
+		if sectionEnd > end {
+			sectionEnd = end
+		}
+		for ; f.begin <= sectionEnd; f.begin++ {
+			offset := f.begin - section*size
+			if matches[offset/8]&(1<<(7-offset%8)) == 0 {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return logs, err
+			}
+			block, ok := f.backend.GetBlockByNumber(f.begin).(*types.MinorBlock)
+			if !ok {
+				return nil, errors.New("no such block")
+			}
+			found, err := f.blockLogs(block.Header())
+			if err != nil {
+				return logs, err
+			}
+			logs = append(logs, found...)
+		}
+	}
+	return logs, nil
+}
+
+// matchSection returns a bitset of the blocks in the section whose blooms may
+// match the filter criteria.
+func (f *Filter) matchSection(section, size uint64) ([]byte, error) {
+	vectors := make(map[uint][]byte)
+	result := bytes.Repeat([]byte{0xff}, int(size/8))
+	for _, clause := range f.filters {
+		var clauseMatches []byte
+		for _, data := range clause {
+			if data == nil {
+				// wildcard matches everything
+				clauseMatches = nil
+				break
+			}
+			matches := bytes.Repeat([]byte{0xff}, int(size/8))
+			for _, bit := range bloomIndexes(data) {
+				vector, ok := vectors[bit]
+				if !ok {
+					var err error
+					if vector, err = f.backend.GetBloomBits(bit, section); err != nil {
+						return nil, err
+					}
+					vectors[bit] = vector
+				}
+				bitutil.ANDBytes(matches, matches, vector)
+			}
+			if clauseMatches == nil {
+				clauseMatches = matches
+			} else {
+				bitutil.ORBytes(clauseMatches, clauseMatches, matches)
+			}
+		}
+		if clauseMatches != nil {
+			bitutil.ANDBytes(result, result, clauseMatches)
+		}
+	}
+	return result, nil
+}
+
+// bloomIndexes returns the bloom bits set by data, the same as bloom9.
+func bloomIndexes(data []byte) [3]uint {
+	hash := crypto.Keccak256(data)
+	var idxs [3]uint
+	for i := 0; i < len(idxs); i++ {
+		idxs[i] = (uint(hash[2*i])<<8)&2047 | uint(hash[2*i+1])
+	}
+	return idxs
+}
+
 // indexedLogs returns the logs matching the filter criteria based on raw block
 // iteration and bloom matching.
-func (f *Filter) unindexedLogs(end uint64) ([]*types.Log, error) {
+func (f *Filter) unindexedLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
 	var logs []*types.Log
 
 	for ; f.begin <= end; f.begin++ {
+		if err := ctx.Err(); err != nil {
+			return logs, err
+		}
 		block, ok := f.backend.GetBlockByNumber(f.begin).(*types.MinorBlock)
 		if !ok {
 			return nil, errors.New("no such block")
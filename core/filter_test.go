@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"encoding/hex"
 	"github.com/QuarkChain/goquarkchain/account"
 	qkcCommon "github.com/QuarkChain/goquarkchain/common"
@@ -10,6 +11,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"math/big"
 	"testing"
+	"time"
 )
 
 // contract code
@@ -114,7 +116,7 @@ func TestGetLog(t *testing.T) {
 	address := make([]common.Address, 0)
 	address = append(address, contractAddr)
 	filter := NewRangeFilter(shardState, 0, 2, address, nil) //address is match
-	logs, err := filter.Logs()
+	logs, err := filter.Logs(context.Background())
 	assert.NoError(t, err)
 	assert.Equal(t, len(logs), 1)
 
@@ -122,7 +124,7 @@ func TestGetLog(t *testing.T) {
 	topics := make([][]common.Hash, 0)
 	topics = append(topics, logs[0].Topics)
 	filter = NewRangeFilter(shardState, 0, 2, nil, topics) //topics is match
-	logs, err = filter.Logs()
+	logs, err = filter.Logs(context.Background())
 	assert.NoError(t, err)
 	assert.Equal(t, len(logs), 1)
 
@@ -131,7 +133,7 @@ func TestGetLog(t *testing.T) {
 	topics = make([][]common.Hash, 0)
 	topics = append(topics, topic)
 	filter = NewRangeFilter(shardState, 0, 2, nil, topics) // topics match one
-	logs, err = filter.Logs()
+	logs, err = filter.Logs(context.Background())
 	assert.NoError(t, err)
 	assert.Equal(t, len(logs), 1)
 
@@ -140,25 +142,100 @@ func TestGetLog(t *testing.T) {
 	topics = make([][]common.Hash, 0)
 	topics = append(topics, topic)
 	filter = NewRangeFilter(shardState, 0, 2, nil, topics) // topics not match
-	logs, err = filter.Logs()
+	logs, err = filter.Logs(context.Background())
 	assert.NoError(t, err)
 	assert.Equal(t, len(logs), 0)
 
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	filter = NewRangeFilter(shardState, 0, 2, address, nil) // caller gone
+	_, err = filter.Logs(ctx)
+	assert.Equal(t, context.Canceled, err)
+
 	address = make([]common.Address, 0)
 	address = append(address, acc1.Recipient)
 	filter = NewRangeFilter(shardState, 0, 2, address, nil) // address is not match
-	logs, err = filter.Logs()
+	logs, err = filter.Logs(context.Background())
 	assert.NoError(t, err)
 	assert.Equal(t, len(logs), 0)
 
 	address1 := make([]common.Address, 0)
 	filter = NewRangeFilter(shardState, 0, 2, address1, nil) // no limit
-	logs, err = filter.Logs()
+	logs, err = filter.Logs(context.Background())
 	assert.NoError(t, err)
 	assert.Equal(t, len(logs), 1)
 
 	filter = NewRangeFilter(shardState, 0, 2, nil, nil) // no limit
-	logs, err = filter.Logs()
+	logs, err = filter.Logs(context.Background())
 	assert.NoError(t, err)
 	assert.Equal(t, len(logs), 1)
 }
+
+func TestGetLogWithBloomBits(t *testing.T) {
+	defer func(size, confirms uint64) {
+		bloomBitsBlocks, bloomConfirms = size, confirms
+	}(bloomBitsBlocks, bloomConfirms)
+	bloomBitsBlocks, bloomConfirms = 8, 1
+
+	id1, err := account.CreatRandomIdentity()
+	checkErr(err)
+	acc1 := account.CreatAddressFromIdentity(id1, 0)
+	acc3, err := account.CreatRandomAccountWithFullShardKey(0)
+	checkErr(err)
+	fakeMoney := uint64(100000000000000000)
+	env := setUp(&acc1, &fakeMoney, nil)
+	shardState := createDefaultShardState(env, nil, nil, nil, nil)
+	defer shardState.Stop()
+	rootBlock := shardState.rootTip.CreateBlockToAppend(nil, nil, nil, nil, nil).Finalize(nil, nil, common.Hash{})
+	_, err = shardState.AddRootBlock(rootBlock)
+	checkErr(err)
+
+	addBlock := func(tx *types.Transaction) types.Receipts {
+		if tx != nil {
+			checkErr(shardState.AddTx(tx))
+		}
+		b, err := shardState.CreateBlockToMine(nil, &acc3, nil, nil, nil)
+		checkErr(err)
+		_, re, err := shardState.FinalizeAndAddBlock(b)
+		checkErr(err)
+		return re
+	}
+	// block 1 creates the contract emitting an event in constructor
+	tx, err := CreateContract(shardState, id1.GetKey(), acc1, acc1.FullShardKey, ContractCreationWithEventByteCode)
+	checkErr(err)
+	contractAddr := addBlock(tx)[0].ContractAddress
+	for i := 0; i < 7; i++ {
+		addBlock(nil)
+	}
+	// block 9 calls the contract to emit another event
+	gas := uint64(50000)
+	addBlock(CreateCallContractTx(shardState, id1.GetKey().Bytes(), acc1,
+		account.NewAddress(contractAddr, acc1.FullShardKey), new(big.Int), &gas, nil, nil, common.FromHex("26121ff0")))
+	assert.Equal(t, uint64(9), shardState.CurrentBlock().NumberU64())
+
+	// section of block 0~7 is confirmed by block 8
+	for i := 0; i < 100; i++ {
+		if _, sections := shardState.BloomStatus(); sections == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	size, sections := shardState.BloomStatus()
+	assert.Equal(t, uint64(8), size)
+	assert.Equal(t, uint64(1), sections)
+
+	filter := NewRangeFilter(shardState, 0, 9, []common.Address{contractAddr}, nil)
+	matches, err := filter.matchSection(0, size)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x40}, matches) // only block 1 may match
+	logs, err := filter.Logs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(logs))
+	assert.Equal(t, uint64(1), logs[0].BlockNumber)
+	assert.Equal(t, uint64(9), logs[1].BlockNumber)
+
+	filter = NewRangeFilter(shardState, 0, 9, nil, [][]common.Hash{{common.HexToHash("2324242424")}})
+	logs, err = filter.Logs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(logs))
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"github.com/QuarkChain/goquarkchain/cluster/config"
+	"github.com/QuarkChain/goquarkchain/core/types"
+)
+
+// RootForkChoice decides which of two competing root chain heads is canonical.
+type RootForkChoice interface {
+	// ReorgNeeded reports whether the extern head should replace the current one.
+	ReorgNeeded(current, extern types.IHeader) bool
+}
+
+// LongestChainRule prefers the head with the larger height.
+type LongestChainRule struct{}
+
+func (LongestChainRule) ReorgNeeded(current, extern types.IHeader) bool {
+	return extern.NumberU64() > current.NumberU64()
+}
+
+// HeaviestChainRule prefers the head with the larger total difficulty.
+type HeaviestChainRule struct{}
+
+func (HeaviestChainRule) ReorgNeeded(current, extern types.IHeader) bool {
+	if cmp := extern.GetTotalDifficulty().Cmp(current.GetTotalDifficulty()); cmp != 0 {
+		return cmp > 0
+	}
+	// Split same-difficulty blocks by number, the shorter chain did the
+	// same work with fewer blocks.
+	return extern.NumberU64() < current.NumberU64()
+}
+
+// forkHeightRule applies the longest chain rule before the fork height and
+// the heaviest chain rule from the fork height on.
+type forkHeightRule struct {
+	heaviestChainHeight uint64
+	longest             LongestChainRule
+	heaviest            HeaviestChainRule
+}
+
+func (r *forkHeightRule) ReorgNeeded(current, extern types.IHeader) bool {
+	if extern.NumberU64() < r.heaviestChainHeight {
+		return r.longest.ReorgNeeded(current, extern)
+	}
+	return r.heaviest.ReorgNeeded(current, extern)
+}
+
+// NewRootForkChoice returns the root fork choice rule defined by config.
+func NewRootForkChoice(cfg *config.QuarkChainConfig) RootForkChoice {
+	if cfg.RootHeaviestChainForkHeight == 0 {
+		return HeaviestChainRule{}
+	}
+	return &forkHeightRule{heaviestChainHeight: cfg.RootHeaviestChainForkHeight}
+}
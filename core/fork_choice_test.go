@@ -0,0 +1,41 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/cluster/config"
+	"github.com/QuarkChain/goquarkchain/core/types"
+)
+
+func TestRootForkChoice(t *testing.T) {
+	newHeader := func(number uint32, td int64) *types.RootBlockHeader {
+		return &types.RootBlockHeader{Number: number, ToTalDifficulty: big.NewInt(td)}
+	}
+	cfg := config.NewQuarkChainConfig()
+
+	// heaviest chain rule by default
+	forkChoice := NewRootForkChoice(cfg)
+	if forkChoice.ReorgNeeded(newHeader(10, 100), newHeader(11, 90)) {
+		t.Fatal("longer but lighter chain should not be chosen")
+	}
+	if !forkChoice.ReorgNeeded(newHeader(10, 100), newHeader(9, 110)) {
+		t.Fatal("heavier chain should be chosen")
+	}
+	if !forkChoice.ReorgNeeded(newHeader(10, 100), newHeader(9, 100)) {
+		t.Fatal("shorter chain should be chosen with same difficulty")
+	}
+
+	// longest chain rule before fork height
+	cfg.RootHeaviestChainForkHeight = 20
+	forkChoice = NewRootForkChoice(cfg)
+	if !forkChoice.ReorgNeeded(newHeader(10, 100), newHeader(11, 90)) {
+		t.Fatal("longer chain should be chosen before fork height")
+	}
+	if forkChoice.ReorgNeeded(newHeader(10, 100), newHeader(10, 110)) {
+		t.Fatal("chain with same height should not be chosen before fork height")
+	}
+	if forkChoice.ReorgNeeded(newHeader(20, 100), newHeader(21, 90)) {
+		t.Fatal("lighter chain should not be chosen after fork height")
+	}
+}
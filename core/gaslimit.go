@@ -0,0 +1,69 @@
+package core
+
+import (
+	"fmt"
+	"math/big"
+	"sync/atomic"
+
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/params"
+)
+
+// CalcGasLimit returns the gas limit of the block after the parent one with the
+// gas limit, which moves toward the target by less than 1/GasLimitBoundDivisor
+// of the parent one. The target is raised to MinBlockGasLimit.
+func CalcGasLimit(parent, target uint64) uint64 {
+	if target < params.MinBlockGasLimit {
+		target = params.MinBlockGasLimit
+	}
+	delta := parent / params.GasLimitBoundDivisor
+	if delta > 0 {
+		delta--
+	}
+	limit := parent
+	switch {
+	case limit < target:
+		limit += delta
+		if limit > target {
+			limit = target
+		}
+	case limit > target:
+		limit -= delta
+		if limit < target {
+			limit = target
+		}
+	}
+	return limit
+}
+
+// VerifyGasLimit checks the gas limit of a block voted by its miner against
+// the one of its parent, which it may not lower below MinBlockGasLimit.
+func VerifyGasLimit(parent, limit uint64) error {
+	diff := limit - parent
+	if limit < parent {
+		diff = parent - limit
+	}
+	if bound := parent / params.GasLimitBoundDivisor; diff != 0 && diff >= bound {
+		return fmt.Errorf("invalid gas limit %d, parent %d, bound %d", limit, parent, bound)
+	}
+	if limit < parent && limit < params.MinBlockGasLimit {
+		return fmt.Errorf("gas limit %d is below %d", limit, params.MinBlockGasLimit)
+	}
+	return nil
+}
+
+// nextGasLimits returns the gas limit and the xshard gas limit of the block
+// with the timestamp after the parent one. The gas limit of the chain moves
+// toward the target of the shard config once its miners vote on it, and is
+// fixed at the genesis one before.
+func (m *MinorBlockChain) nextGasLimits(parent *types.MinorBlockHeader, timestamp uint64) (*big.Int, *big.Int) {
+	if !m.clusterConfig.Quarkchain.IsBlockLimits(m.branch.GetChainID(), timestamp) {
+		return m.gasLimit, m.xShardGasLimit
+	}
+	target := atomic.LoadUint64(&m.shardConfig.GasLimitTarget)
+	if target == 0 {
+		target = m.gasLimit.Uint64()
+	}
+	gasLimit := CalcGasLimit(parent.GetGasLimit().Uint64(), target)
+	return new(big.Int).SetUint64(gasLimit), new(big.Int).SetUint64(gasLimit / 2)
+}
@@ -0,0 +1,26 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/params"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalcGasLimit(t *testing.T) {
+	parent := uint64(1024000)
+	assert.Equal(t, parent+999, CalcGasLimit(parent, 2*parent))
+	assert.Equal(t, parent+10, CalcGasLimit(parent, parent+10))
+	assert.Equal(t, parent-999, CalcGasLimit(parent, 0))
+	assert.Equal(t, parent, CalcGasLimit(parent, parent))
+	assert.Equal(t, params.MinBlockGasLimit, CalcGasLimit(params.MinBlockGasLimit+10, 0))
+
+	assert.NoError(t, VerifyGasLimit(parent, parent))
+	assert.NoError(t, VerifyGasLimit(parent, parent+999))
+	assert.NoError(t, VerifyGasLimit(parent, parent-999))
+	assert.Error(t, VerifyGasLimit(parent, parent+1000))
+	assert.Error(t, VerifyGasLimit(parent, parent-1000))
+	assert.Error(t, VerifyGasLimit(params.MinBlockGasLimit, params.MinBlockGasLimit-1))
+	// a gas limit below the minimum may still rise toward it
+	assert.NoError(t, VerifyGasLimit(params.MinBlockGasLimit/2, params.MinBlockGasLimit/2+1))
+}
@@ -2,8 +2,10 @@
 package core
 
 import (
+	"encoding/json"
 	"fmt"
 	"math/big"
+	"sort"
 
 	"github.com/QuarkChain/goquarkchain/account"
 	"github.com/QuarkChain/goquarkchain/cluster/config"
@@ -13,6 +15,7 @@ import (
 	"github.com/QuarkChain/goquarkchain/core/types"
 	"github.com/QuarkChain/goquarkchain/serialize"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
 )
@@ -128,6 +131,25 @@ func (g *Genesis) CreateMinorBlock(rootBlock *types.RootBlock, fullShardId uint3
 	return types.NewMinorBlock(&header, &meta, make(types.Transactions, 0, 0), make(types.Receipts, 0, 0), nil), nil
 }
 
+// ClusterGenesisHash returns the digest of the genesis of the cluster, which
+// covers the root genesis block and the genesis config of all the shards. Unlike
+// the genesis minor blocks of the shards created after the root genesis, it is
+// known before the chain starts, so the cluster members compare it on connecting.
+func (g *Genesis) ClusterGenesisHash() (common.Hash, error) {
+	fullShardIds := g.qkcConfig.GetGenesisShardIds()
+	sort.Slice(fullShardIds, func(i, j int) bool { return fullShardIds[i] < fullShardIds[j] })
+
+	data := [][]byte{g.CreateRootBlock().Hash().Bytes()}
+	for _, fullShardId := range fullShardIds {
+		enc, err := json.Marshal(g.qkcConfig.GetShardConfigByFullShardID(fullShardId).Genesis)
+		if err != nil {
+			return common.Hash{}, err
+		}
+		data = append(data, qkcCommon.Uint32ToBytes(fullShardId), enc)
+	}
+	return crypto.Keccak256Hash(data...), nil
+}
+
 // GenesisAccount is an account in the state of the genesis block.
 type GenesisAccount struct {
 	Code       []byte                      `json:"code,omitempty"`
@@ -147,7 +169,7 @@ type GenesisAccount struct {
 //
 // The stored chain configuration will be updated if it is compatible (i.e. does not
 // specify a fork block below the local head block). In case of a conflict, the
-// error is a *params.ConfigCompatError and the new, unwritten config is returned.
+// error is a *config.ForkCompatError and the new, unwritten config is returned.
 //
 // The returned chain configuration is never nil.
 func SetupGenesisRootBlock(db ethdb.Database, genesis *Genesis) (*config.QuarkChainConfig, common.Hash, error) {
@@ -182,11 +204,17 @@ func SetupGenesisRootBlock(db ethdb.Database, genesis *Genesis) (*config.QuarkCh
 
 	// Check config compatibility and write the config. Compatibility errors
 	// are returned to the caller unless we're already at block zero.
-	height := rawdb.ReadHeaderNumber(db, rawdb.ReadHeadHeaderHash(db))
-	if height == nil {
-		return storedcfg, stored, fmt.Errorf("missing block number for head header hash")
+	head := rawdb.ReadRootBlock(db, rawdb.ReadHeadBlockHash(db))
+	if head == nil {
+		return storedcfg, stored, fmt.Errorf("missing head root block")
 	}
-	return storedcfg, stored, nil
+	if head.NumberU64() > 0 {
+		if compatErr := storedcfg.CheckForkCompatible(genesis.qkcConfig, head.NumberU64()); compatErr != nil {
+			return genesis.qkcConfig, stored, compatErr
+		}
+	}
+	rawdb.WriteChainConfig(db, stored, genesis.qkcConfig)
+	return genesis.qkcConfig, stored, nil
 }
 func SetupGenesisMinorBlock(db ethdb.Database, genesis *Genesis, rootBlock *types.RootBlock, fullShardId uint32) (*config.QuarkChainConfig, common.Hash, error) {
 	if genesis == nil {
@@ -221,11 +249,21 @@ func SetupGenesisMinorBlock(db ethdb.Database, genesis *Genesis, rootBlock *type
 
 	// Check config compatibility and write the config. Compatibility errors
 	// are returned to the caller unless we're already at block zero.
-	height := rawdb.ReadHeaderNumber(db, rawdb.ReadHeadHeaderHash(db))
-	if height == nil {
-		return storedcfg, stored, fmt.Errorf("missing block number for head header hash")
+	head := rawdb.ReadMinorBlockHeader(db, rawdb.ReadHeadBlockHash(db))
+	if head == nil {
+		return storedcfg, stored, fmt.Errorf("missing head minor block")
+	}
+	if head.Number > 0 {
+		rootHeight := uint64(0)
+		if rootHeader := rawdb.ReadRootBlockHeader(db, head.PrevRootBlockHash); rootHeader != nil {
+			rootHeight = uint64(rootHeader.Number)
+		}
+		if compatErr := storedcfg.CheckShardForkCompatible(genesis.qkcConfig, fullShardId, rootHeight, head.Number, head.Time); compatErr != nil {
+			return genesis.qkcConfig, stored, compatErr
+		}
 	}
-	return storedcfg, stored, nil
+	rawdb.WriteChainConfig(db, stored, genesis.qkcConfig)
+	return genesis.qkcConfig, stored, nil
 }
 
 // CommitRootBlock writes the block and state of a genesis specification to the database.
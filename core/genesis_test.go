@@ -0,0 +1,118 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/cluster/config"
+	"github.com/QuarkChain/goquarkchain/consensus"
+	"github.com/QuarkChain/goquarkchain/core/rawdb"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestClusterGenesisHash(t *testing.T) {
+	newConfig := func() *config.QuarkChainConfig {
+		qkcconfig := config.NewQuarkChainConfig()
+		for _, id := range qkcconfig.GetGenesisShardIds() {
+			shardConfig := qkcconfig.GetShardConfigByFullShardID(id)
+			for i := byte(1); i <= 3; i++ {
+				addr := account.NewAddress(account.BytesToIdentityRecipient([]byte{i}), id)
+				shardConfig.Genesis.Alloc[addr] = config.Allocation{
+					Balances: map[string]*big.Int{"QKC": big.NewInt(int64(i) * 1000000)},
+				}
+			}
+		}
+		return qkcconfig
+	}
+	hash := func(qkcconfig *config.QuarkChainConfig) common.Hash {
+		h, err := NewGenesis(qkcconfig).ClusterGenesisHash()
+		if err != nil {
+			t.Fatalf("failed to compute cluster genesis hash: %v", err)
+		}
+		return h
+	}
+
+	want := hash(newConfig())
+	if got := hash(newConfig()); got != want {
+		t.Fatalf("cluster genesis hash not deterministic: have %x, want %x", got, want)
+	}
+
+	qkcconfig := newConfig()
+	shardConfig := qkcconfig.GetShardConfigByFullShardID(qkcconfig.GetGenesisShardIds()[0])
+	for addr, alloc := range shardConfig.Genesis.Alloc {
+		alloc.Storage = map[common.Hash]common.Hash{{1}: {2}}
+		shardConfig.Genesis.Alloc[addr] = alloc
+		break
+	}
+	if got := hash(qkcconfig); got == want {
+		t.Fatalf("cluster genesis hash not changed by the genesis storage")
+	}
+
+	qkcconfig = newConfig()
+	qkcconfig.GetShardConfigByFullShardID(qkcconfig.GetGenesisShardIds()[0]).Genesis.Timestamp++
+	if got := hash(qkcconfig); got == want {
+		t.Fatalf("cluster genesis hash not changed by the shard genesis timestamp")
+	}
+
+	qkcconfig = newConfig()
+	qkcconfig.Root.Genesis.Difficulty++
+	if got := hash(qkcconfig); got == want {
+		t.Fatalf("cluster genesis hash not changed by the root genesis difficulty")
+	}
+}
+
+func TestSetupGenesisForkCompatible(t *testing.T) {
+	db, blockchain, err := newCanonical(new(consensus.FakeEngine), 5)
+	if err != nil {
+		t.Fatalf("failed to create canonical chain: %v", err)
+	}
+	blockchain.Stop()
+
+	newcfg := config.NewQuarkChainConfig()
+	newcfg.XShardGasDDOSFixRootHeight = 1000
+	if _, _, err := SetupGenesisRootBlock(db, NewGenesis(newcfg)); err != nil {
+		t.Fatalf("failed to reschedule inactive fork: %v", err)
+	}
+	if stored := rawdb.ReadChainConfig(db, blockchain.Genesis().Hash()); stored.XShardGasDDOSFixRootHeight != 1000 {
+		t.Fatalf("stored fork not updated: have %d, want %d", stored.XShardGasDDOSFixRootHeight, 1000)
+	}
+
+	newcfg = config.NewQuarkChainConfig()
+	newcfg.XShardGasDDOSFixRootHeight = 3
+	_, _, err = SetupGenesisRootBlock(db, NewGenesis(newcfg))
+	if compatErr, ok := err.(*config.ForkCompatError); !ok || compatErr.Name != config.ForkXShardGasDDOSFix {
+		t.Fatalf("error mismatch: have %v, want fork compat error", err)
+	}
+}
+
+func TestSetupGenesisMinorForkCompatible(t *testing.T) {
+	db, blockchain, err := newMinorCanonical(nil, new(consensus.FakeEngine), 5, true)
+	if err != nil {
+		t.Fatalf("failed to create canonical chain: %v", err)
+	}
+	blockchain.Stop()
+	fullShardID := blockchain.branch.Value
+	storedcfg := config.NewQuarkChainConfig()
+	storedcfg.EnableQkcHashXHeight = 1000
+	rawdb.WriteChainConfig(db, blockchain.Genesis().Hash(), storedcfg)
+
+	newcfg := config.NewQuarkChainConfig()
+	newcfg.EnableQkcHashXHeight = 2000
+	gspec := NewGenesis(newcfg)
+	if _, _, err := SetupGenesisMinorBlock(db, gspec, gspec.CreateRootBlock(), fullShardID); err != nil {
+		t.Fatalf("failed to reschedule inactive fork: %v", err)
+	}
+	if stored := rawdb.ReadChainConfig(db, blockchain.Genesis().Hash()); stored.EnableQkcHashXHeight != 2000 {
+		t.Fatalf("stored fork not updated: have %d, want %d", stored.EnableQkcHashXHeight, 2000)
+	}
+
+	// the minor height of the shard, not the root height, activates the fork
+	newcfg = config.NewQuarkChainConfig()
+	newcfg.EnableQkcHashXHeight = 3
+	gspec = NewGenesis(newcfg)
+	_, _, err = SetupGenesisMinorBlock(db, gspec, gspec.CreateRootBlock(), fullShardID)
+	if compatErr, ok := err.(*config.ForkCompatError); !ok || compatErr.Name != config.ForkQkcHashX || compatErr.Height != 5 {
+		t.Fatalf("error mismatch: have %v, want fork compat error", err)
+	}
+}
@@ -0,0 +1,193 @@
+package core
+
+import (
+	"sync"
+
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// headerSkipListLimit is the number of the headers kept in the skip list of a
+// shard, beyond which the oldest half is dropped.
+const headerSkipListLimit = 1 << 16
+
+// skipNode is a header in the skip list, with the hash of its ancestor at
+// skipHeight of its number, or an empty hash if the ancestor was not known
+// when the header was added.
+type skipNode struct {
+	number uint64
+	parent common.Hash
+	skip   common.Hash
+}
+
+// headerSkipList keeps the recent minor block headers of a shard in memory,
+// each with a link to a far ancestor as in the skip list of the bitcoin block
+// index, so that the ancestor of a header at a height, and whether a header is
+// on the same chain as another one, are found in O(log n) steps instead of
+// reading each header in between from the db. The headers missing from the
+// list are read with getHeader and added.
+type headerSkipList struct {
+	getHeader func(hash common.Hash) *types.MinorBlockHeader
+
+	mu      sync.Mutex
+	nodes   map[common.Hash]*skipNode
+	highest uint64
+}
+
+func newHeaderSkipList(getHeader func(hash common.Hash) *types.MinorBlockHeader) *headerSkipList {
+	return &headerSkipList{
+		getHeader: getHeader,
+		nodes:     make(map[common.Hash]*skipNode),
+	}
+}
+
+// invertLowestOne clears the lowest set bit of n.
+func invertLowestOne(n uint64) uint64 {
+	return n & (n - 1)
+}
+
+// skipHeight returns the height of the ancestor a header at the height links
+// to, chosen as in bitcoin so that any ancestor is reached in O(log n) steps.
+func skipHeight(height uint64) uint64 {
+	if height < 2 {
+		return 0
+	}
+	if height&1 == 1 {
+		return invertLowestOne(invertLowestOne(height-1)) + 1
+	}
+	return invertLowestOne(height)
+}
+
+// add adds the header to the list if it is not there yet, linking it to its
+// ancestor at skipHeight if that is found in the list.
+func (l *headerSkipList) add(header *types.MinorBlockHeader) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.addLocked(header.Hash(), header)
+}
+
+func (l *headerSkipList) addLocked(hash common.Hash, header *types.MinorBlockHeader) *skipNode {
+	if node, ok := l.nodes[hash]; ok {
+		return node
+	}
+	node := &skipNode{number: header.Number, parent: header.ParentHash}
+	if header.Number > 0 {
+		node.skip, _ = l.ancestorLocked(header.ParentHash, header.Number-1, skipHeight(header.Number), false)
+	}
+	l.nodes[hash] = node
+	if node.number > l.highest {
+		l.highest = node.number
+	}
+	if len(l.nodes) > headerSkipListLimit {
+		l.pruneLocked()
+	}
+	return node
+}
+
+// pruneLocked drops the headers in the older half of the heights kept. The
+// links to the dropped headers are followed through the db again.
+func (l *headerSkipList) pruneLocked() {
+	var lowest uint64
+	if l.highest > headerSkipListLimit/2 {
+		lowest = l.highest - headerSkipListLimit/2
+	}
+	for hash, node := range l.nodes {
+		if node.number < lowest {
+			delete(l.nodes, hash)
+		}
+	}
+	// so many forks that the recent heights fill the list
+	if len(l.nodes) > headerSkipListLimit {
+		l.nodes = make(map[common.Hash]*skipNode)
+	}
+}
+
+// nodeLocked returns the node of the header, reading the header from the db
+// if it is not in the list and load is set, and whether it was read.
+func (l *headerSkipList) nodeLocked(hash common.Hash, load bool) (*skipNode, bool) {
+	if node, ok := l.nodes[hash]; ok {
+		return node, false
+	}
+	if !load {
+		return nil, false
+	}
+	header := l.getHeader(hash)
+	if header == nil {
+		return nil, false
+	}
+	return l.addLocked(hash, header), true
+}
+
+// ancestor returns the hash of the ancestor at the height of the header of
+// the hash and number, or an empty hash if the height is above the number or a
+// header in between is missing.
+func (l *headerSkipList) ancestor(hash common.Hash, number, height uint64) common.Hash {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ancestor, _ := l.ancestorLocked(hash, number, height, true)
+	return ancestor
+}
+
+// knownAncestor is ancestor without reading the headers missing from the list,
+// for the callers with a cheaper way to find the ancestor from the db.
+func (l *headerSkipList) knownAncestor(hash common.Hash, number, height uint64) common.Hash {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ancestor, _ := l.ancestorLocked(hash, number, height, false)
+	return ancestor
+}
+
+// ancestorLocked walks down from the header to the height, following the skip
+// links where they don't overshoot, and reports whether it got there. Without
+// load, it gives up at the first header not in the list.
+func (l *headerSkipList) ancestorLocked(hash common.Hash, number, height uint64, load bool) (common.Hash, bool) {
+	if height > number {
+		return common.Hash{}, false
+	}
+	// the headers read on the way are added before their ancestors, so they
+	// are linked once the walk is done
+	var loaded []*skipNode
+	defer func() {
+		for i := len(loaded) - 1; i >= 0; i-- {
+			if node := loaded[i]; node.skip == (common.Hash{}) {
+				node.skip, _ = l.ancestorLocked(node.parent, node.number-1, skipHeight(node.number), false)
+			}
+		}
+	}()
+	for number > height {
+		node, read := l.nodeLocked(hash, load)
+		if node == nil || node.number != number {
+			return common.Hash{}, false
+		}
+		if read {
+			loaded = append(loaded, node)
+		}
+		// take the skip link unless a shorter one from the parent gets closer
+		// to the height, as in CBlockIndex::GetAncestor of bitcoin
+		heightSkip, heightSkipPrev := skipHeight(number), skipHeight(number-1)
+		if node.skip != (common.Hash{}) && (heightSkip == height ||
+			(heightSkip > height && !(heightSkipPrev+2 < heightSkip && heightSkipPrev >= height))) {
+			hash, number = node.skip, heightSkip
+		} else {
+			hash, number = node.parent, number-1
+		}
+	}
+	return hash, true
+}
+
+// isSameChain reports whether the header of shortHash and shortNumber is the
+// header of longHash and longNumber or one of its ancestors.
+func (l *headerSkipList) isSameChain(longHash common.Hash, longNumber uint64, shortHash common.Hash, shortNumber uint64) bool {
+	if shortNumber > longNumber {
+		return false
+	}
+	return l.ancestor(longHash, longNumber, shortNumber) == shortHash
+}
+
+// purge drops all the headers, after the chain is rewound.
+func (l *headerSkipList) purge() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.nodes = make(map[common.Hash]*skipNode)
+	l.highest = 0
+}
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+// makeSkipListHeaders returns count headers on top of the parent, forked from
+// other chains by the time.
+func makeSkipListHeaders(parent *types.MinorBlockHeader, count int, time uint64) []*types.MinorBlockHeader {
+	headers := make([]*types.MinorBlockHeader, count)
+	for i := range headers {
+		headers[i] = &types.MinorBlockHeader{Number: parent.Number + 1, ParentHash: parent.Hash(), Time: time}
+		parent = headers[i]
+	}
+	return headers
+}
+
+func TestSkipHeight(t *testing.T) {
+	for height := uint64(2); height < 1<<12; height++ {
+		assert.True(t, skipHeight(height) < height)
+	}
+	assert.Equal(t, uint64(0), skipHeight(1))
+	assert.Equal(t, uint64(8), skipHeight(12))
+	assert.Equal(t, uint64(9), skipHeight(15))
+}
+
+func TestHeaderSkipList(t *testing.T) {
+	genesis := &types.MinorBlockHeader{}
+	main := append([]*types.MinorBlockHeader{genesis}, makeSkipListHeaders(genesis, 5000, 1)...)
+	fork := makeSkipListHeaders(main[3000], 1000, 2)
+	db := make(map[common.Hash]*types.MinorBlockHeader)
+	for _, header := range append(append([]*types.MinorBlockHeader{}, main...), fork...) {
+		db[header.Hash()] = header
+	}
+	reads := 0
+	list := newHeaderSkipList(func(hash common.Hash) *types.MinorBlockHeader {
+		reads++
+		return db[hash]
+	})
+
+	// the headers missing from the list are read once
+	tip := main[len(main)-1]
+	assert.Equal(t, genesis.Hash(), list.ancestor(tip.Hash(), tip.Number, 0))
+	assert.Equal(t, 5000, reads)
+	reads = 0
+	for i := 0; i < 1000; i++ {
+		from := main[rand.Intn(len(main))]
+		height := uint64(rand.Intn(int(from.Number) + 1))
+		assert.Equal(t, main[height].Hash(), list.ancestor(from.Hash(), from.Number, height))
+	}
+	assert.Equal(t, 0, reads)
+
+	// the headers added in order are linked without reading anything
+	for _, header := range fork {
+		list.add(header)
+	}
+	forkTip := fork[len(fork)-1]
+	assert.Equal(t, fork[499].Hash(), list.knownAncestor(forkTip.Hash(), forkTip.Number, fork[499].Number))
+	assert.Equal(t, main[20].Hash(), list.knownAncestor(forkTip.Hash(), forkTip.Number, 20))
+	assert.Equal(t, 0, reads)
+	assert.True(t, list.isSameChain(forkTip.Hash(), forkTip.Number, main[3000].Hash(), 3000))
+	assert.False(t, list.isSameChain(forkTip.Hash(), forkTip.Number, main[3001].Hash(), 3001))
+	assert.False(t, list.isSameChain(tip.Hash(), tip.Number, fork[0].Hash(), fork[0].Number))
+	assert.False(t, list.isSameChain(main[10].Hash(), 10, tip.Hash(), tip.Number))
+
+	// an ancestor is found in O(log n) links
+	steps := 0
+	list.getHeader = func(hash common.Hash) *types.MinorBlockHeader { return nil }
+	for hash, number := tip.Hash(), tip.Number; number > 1; steps++ {
+		node := list.nodes[hash]
+		if node.skip != (common.Hash{}) && skipHeight(number) >= 1 {
+			hash, number = node.skip, skipHeight(number)
+		} else {
+			hash, number = node.parent, number-1
+		}
+	}
+	assert.True(t, steps < 64, "%d steps", steps)
+
+	// an unknown header has no ancestor, and the list is dropped on rewind
+	assert.Equal(t, common.Hash{}, list.ancestor(common.Hash{1}, 10, 5))
+	list.purge()
+	assert.Equal(t, common.Hash{}, list.knownAncestor(tip.Hash(), tip.Number, 10))
+}
+
+func TestHeaderSkipListPrune(t *testing.T) {
+	genesis := &types.MinorBlockHeader{}
+	headers := makeSkipListHeaders(genesis, headerSkipListLimit+10, 1)
+	list := newHeaderSkipList(func(hash common.Hash) *types.MinorBlockHeader { return nil })
+	list.add(genesis)
+	for _, header := range headers {
+		list.add(header)
+	}
+	assert.True(t, len(list.nodes) <= headerSkipListLimit)
+	tip := headers[len(headers)-1]
+	assert.Equal(t, headers[len(headers)-100].Hash(), list.knownAncestor(tip.Hash(), tip.Number, tip.Number-99))
+	// the dropped headers are not found without the db
+	assert.Equal(t, common.Hash{}, list.knownAncestor(tip.Hash(), tip.Number, 10))
+}
@@ -128,19 +128,25 @@ func (v *MinorBlockValidator) ValidateBlock(mBlock types.IBlock, force bool) err
 		return ErrExtraLimit
 	}
 
-	if block.GasLimit().Cmp(v.bc.gasLimit) != 0 {
-		return fmt.Errorf("incorrect gas limit, expected %d, actual %d", v.bc.gasLimit.Uint64(),
-			block.GasLimit().Uint64())
-	}
+	if v.quarkChainConfig.IsBlockLimits(v.branch.GetChainID(), block.Time()) {
+		if err := v.validateBlockLimits(block, prevHeader.(*types.MinorBlockHeader)); err != nil {
+			return err
+		}
+	} else {
+		if block.GasLimit().Cmp(v.bc.gasLimit) != 0 {
+			return fmt.Errorf("incorrect gas limit, expected %d, actual %d", v.bc.gasLimit.Uint64(),
+				block.GasLimit().Uint64())
+		}
 
-	if block.GetXShardGasLimit().Cmp(block.GasLimit()) >= 0 {
-		return fmt.Errorf("xshard_gas_limit %d should not exceed total gas_limit %d",
-			block.GetXShardGasLimit(), block.GasLimit())
-	}
+		if block.GetXShardGasLimit().Cmp(block.GasLimit()) >= 0 {
+			return fmt.Errorf("xshard_gas_limit %d should not exceed total gas_limit %d",
+				block.GetXShardGasLimit(), block.GasLimit())
+		}
 
-	if block.GetXShardGasLimit().Cmp(v.bc.xShardGasLimit) != 0 {
-		return fmt.Errorf("incorrect xshard gas limit, expected %d, actual %d", v.bc.xShardGasLimit,
-			block.GetXShardGasLimit())
+		if block.GetXShardGasLimit().Cmp(v.bc.xShardGasLimit) != 0 {
+			return fmt.Errorf("incorrect xshard gas limit, expected %d, actual %d", v.bc.xShardGasLimit,
+				block.GetXShardGasLimit())
+		}
 	}
 
 	txHash := types.CalculateMerkleRoot(block.GetTransactions())
@@ -184,7 +190,7 @@ func (v *MinorBlockValidator) ValidateBlock(mBlock types.IBlock, force bool) err
 	}
 
 	prevConfirmedMinorHeader := v.bc.getLastConfirmedMinorBlockHeaderAtRootBlock(block.PrevRootBlockHash())
-	if prevConfirmedMinorHeader != nil && !isSameChain(v.bc.GetParentHashByHash, prevHeader, prevConfirmedMinorHeader) {
+	if prevConfirmedMinorHeader != nil && !v.bc.isSameMinorChain(prevHeader, prevConfirmedMinorHeader) {
 		errMustBeOneMinorChain := errors.New("prev root block's minor block is not in the same chain as the minor block")
 		log.Error(v.logInfo, "err", errMustBeOneMinorChain, "prevConfirmedMinor's height", prevConfirmedMinorHeader.Number, "prevConfirmedMinor's hash", prevConfirmedMinorHeader.Hash().String(),
 			"preHeader's height", prevHeader.NumberU64(), "preHeader's hash", prevHeader.Hash().String())
@@ -206,6 +212,33 @@ func (v *MinorBlockValidator) ValidateBlock(mBlock types.IBlock, force bool) err
 	return nil
 }
 
+// validateBlockLimits checks the gas limit voted by the miner of the block, and
+// its size and tx count against the limits of the shard.
+func (v *MinorBlockValidator) validateBlockLimits(block *types.MinorBlock, prevHeader *types.MinorBlockHeader) error {
+	if err := VerifyGasLimit(prevHeader.GetGasLimit().Uint64(), block.GasLimit().Uint64()); err != nil {
+		return err
+	}
+	xShardGasLimit := new(big.Int).Div(block.GasLimit(), big.NewInt(2))
+	if block.GetXShardGasLimit().Cmp(xShardGasLimit) != 0 {
+		return fmt.Errorf("incorrect xshard gas limit, expected %d, actual %d", xShardGasLimit,
+			block.GetXShardGasLimit())
+	}
+	txs := block.GetTransactions()
+	if limit := v.bc.shardConfig.BlockTxCountLimit; limit != 0 && len(txs) > int(limit) {
+		return fmt.Errorf("block has %d txs, limit %d", len(txs), limit)
+	}
+	if limit := v.bc.shardConfig.BlockSizeLimit; limit != 0 {
+		size := uint64(0)
+		for _, tx := range txs {
+			size += uint64(tx.Size())
+		}
+		if size > uint64(limit) {
+			return fmt.Errorf("block txs of %d bytes, limit %d", size, limit)
+		}
+	}
+	return nil
+}
+
 // ValidatorBlockSeal validate minor block seal when validate block
 func (v *MinorBlockValidator) ValidateSeal(mHeader types.IHeader, usePowsDiff bool) error {
 	header, ok := mHeader.(*types.MinorBlockHeader)
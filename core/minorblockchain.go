@@ -35,9 +35,11 @@ import (
 	"github.com/QuarkChain/goquarkchain/consensus"
 	"github.com/QuarkChain/goquarkchain/core/rawdb"
 	"github.com/QuarkChain/goquarkchain/core/state"
+	"github.com/QuarkChain/goquarkchain/core/state/snapshot"
 	"github.com/QuarkChain/goquarkchain/core/types"
 	"github.com/QuarkChain/goquarkchain/core/vm"
 	qkcParams "github.com/QuarkChain/goquarkchain/params"
+	"github.com/QuarkChain/goquarkchain/qkcdb"
 	"github.com/QuarkChain/goquarkchain/serialize"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/mclock"
@@ -55,6 +57,8 @@ const (
 	maxRootBlockLimit     = 128
 	maxLastConfirmLimit   = 256
 	maxGasPriceCacheLimit = 128
+	maxBadBlocks          = 16
+	snapshotLayers        = 128 // Number of diff layers kept on top of the persisted state snapshot
 )
 
 type gasPriceKey struct {
@@ -97,6 +101,9 @@ type MinorBlockChain struct {
 	chainHeadFeed event.Feed
 	logsFeed      event.Feed
 	subLogsFeed   event.Feed
+	reorgFeed     event.Feed
+	rootReorgFeed event.Feed
+	reorgs        *reorgQueue
 	scope         event.SubscriptionScope
 	genesisBlock  *types.MinorBlock
 
@@ -108,12 +115,14 @@ type MinorBlockChain struct {
 	currentBlock atomic.Value // Current head of the block chain
 
 	stateCache            state.Database // State database to reuse between imports (contains state cache)
+	snaps                 *snapshot.Tree // Snapshot tree for fast state reads, nil if disabled
 	receiptsCache         *lru.Cache     // Cache for the most recent receipts per block
 	blockCache            *lru.Cache     // Cache for the most recent entire blocks
 	futureBlocks          *lru.Cache     // future blocks are blocks added for later processing
 	crossShardTxListCache *lru.Cache
 	rootBlockCache        *lru.Cache
 	lastConfirmCache      *lru.Cache
+	badBlocks             *lru.Cache // the last blocks failing to be inserted, to be traced
 	coinbaseAmountCache   map[uint64]*types.TokenBalances
 
 	quit    chan struct{} // blockchain quit channel
@@ -145,6 +154,14 @@ type MinorBlockChain struct {
 	posw                     consensus.PoSWCalculator
 	gasLimit                 *big.Int
 	xShardGasLimit           *big.Int
+	staleBlocks              *staleBlockTracker
+	blockProfiles            *blockProfiler
+	pending                  *pendingState
+	regeneration             *stateRegeneration
+	balanceHistory           *balanceHistory // nil if disabled
+	xShardJournal            *xShardJournal  // nil if disabled
+	xShardJournalCursor      uint64          // root height of the cursor of the head block at the last rotation of the journal
+	bloomIndexer             *bloomIndexer
 }
 
 // NewMinorBlockChain returns a fully initialised block chain using information
@@ -179,6 +196,7 @@ func NewMinorBlockChain(
 	rootBlockCache, _ := lru.New(maxRootBlockLimit)
 	lastConfimCache, _ := lru.New(maxLastConfirmLimit)
 	gasPriceCache, _ := lru.New(maxGasPriceCacheLimit)
+	badBlocks, _ := lru.New(maxBadBlocks)
 	bc := &MinorBlockChain{
 		ethChainConfig:           chainConfig,
 		clusterConfig:            clusterConfig,
@@ -187,6 +205,7 @@ func NewMinorBlockChain(
 		triegc:                   prque.New(nil),
 		stateCache:               state.NewDatabaseWithCache(db, cacheConfig.TrieCleanLimit),
 		quit:                     make(chan struct{}),
+		reorgs:                   newReorgQueue(),
 		shouldPreserve:           shouldPreserve,
 		receiptsCache:            receiptsCache,
 		blockCache:               blockCache,
@@ -194,6 +213,7 @@ func NewMinorBlockChain(
 		crossShardTxListCache:    crossShardCache,
 		rootBlockCache:           rootBlockCache,
 		lastConfirmCache:         lastConfimCache,
+		badBlocks:                badBlocks,
 		coinbaseAmountCache:      make(map[uint64]*types.TokenBalances),
 		engine:                   engine,
 		vmConfig:                 vmConfig,
@@ -208,7 +228,12 @@ func NewMinorBlockChain(
 			CheckBlocks: 5,
 			Percentile:  50,
 		},
-		logInfo: fmt.Sprintf("shard:%d", fullShardID),
+		logInfo:        fmt.Sprintf("shard:%d", fullShardID),
+		staleBlocks:    newStaleBlockTracker(db),
+		blockProfiles:  newBlockProfiler(),
+		pending:        new(pendingState),
+		regeneration:   new(stateRegeneration),
+		balanceHistory: newBalanceHistory(db, cacheConfig),
 	}
 	var err error
 	bc.gasLimit, err = bc.clusterConfig.Quarkchain.GasLimit(bc.branch.Value)
@@ -236,11 +261,35 @@ func NewMinorBlockChain(
 	if err := bc.loadLastState(); err != nil {
 		return nil, err
 	}
+	if cacheConfig.Snapshot {
+		if kvdb, ok := rawdb.KeyValueStore(db).(qkcdb.KeyValueStore); ok {
+			bc.snaps = snapshot.New(kvdb, bc.stateCache.TrieDB(), bc.CurrentBlock().GetMetaData().Root)
+		} else {
+			log.Warn("State snapshot only supports qkcdb, disabled", "shard", fullShardID)
+		}
+	}
 	DefaultTxPoolConfig.NetWorkID = bc.clusterConfig.Quarkchain.NetworkID
 	bc.posw = consensus.CreatePoSWCalculator(bc, bc.shardConfig.PoswConfig)
-	bc.txPool = NewTxPool(DefaultTxPoolConfig, bc)
+	txPoolConfig := DefaultTxPoolConfig
+	if limits := clusterConfig.TxPool; limits != nil {
+		txPoolConfig.AccountSlots, txPoolConfig.GlobalSlots = limits.AccountSlots, limits.GlobalSlots
+		txPoolConfig.AccountQueue, txPoolConfig.GlobalQueue = limits.AccountQueue, limits.GlobalQueue
+		txPoolConfig.Policy = limits.GetPolicy(fullShardID)
+	}
+	bc.txPool = NewTxPool(txPoolConfig, bc)
 	// Take ownership of this particular state
 	go bc.update()
+	bc.wg.Add(1)
+	go bc.reorgs.loop(bc.quit, &bc.wg)
+	bc.wg.Add(1)
+	go newTxIndexer(bc, clusterConfig.TxIndexRetention).loop()
+	bc.bloomIndexer = newBloomIndexer(bc)
+	bc.wg.Add(1)
+	go bc.bloomIndexer.loop()
+	if _, ok := db.(rawdb.AncientReader); ok && clusterConfig.AncientRootBlocks != 0 {
+		bc.wg.Add(1)
+		go newBlockFreezer(bc, clusterConfig.AncientRootBlocks).loop()
+	}
 	return bc, nil
 }
 
@@ -408,7 +457,7 @@ func (m *MinorBlockChain) State() (*state.StateDB, error) {
 
 // StateAt returns a new mutable state based on a particular point in time.
 func (m *MinorBlockChain) StateAt(root common.Hash) (*state.StateDB, error) {
-	evmState, err := state.New(root, m.stateCache)
+	evmState, err := state.NewWithSnapshot(root, m.stateCache, m.snaps)
 	if err != nil {
 		return nil, err
 	}
@@ -586,6 +635,18 @@ func (m *MinorBlockChain) HasState(hash common.Hash) bool {
 	return err == nil
 }
 
+// CommitState writes the state trie with the root kept in memory in the full
+// gc mode to the database, e.g. before a snapshot of the database is taken.
+func (m *MinorBlockChain) CommitState(root common.Hash) error {
+	if m.cacheConfig.Disabled {
+		// the archive mode writes the state of every block
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stateCache.TrieDB().Commit(root, false)
+}
+
 // HasBlockAndState checks if a block and associated state trie is fully present
 // in the database or not, caching it if present.
 func (m *MinorBlockChain) HasBlockAndState(hash common.Hash) bool {
@@ -726,6 +787,18 @@ func (m *MinorBlockChain) Stop() {
 	atomic.StoreInt32(&m.procInterrupt, 1)
 
 	m.wg.Wait()
+	if m.xShardJournal != nil {
+		m.xShardJournal.close()
+	}
+
+	// Flatten all the diff layers so that the persisted snapshot matches the
+	// head state on the next start.
+	if m.snaps != nil {
+		if err := m.snaps.Cap(m.CurrentBlock().GetMetaData().Root, 0); err != nil {
+			log.Error("Failed to flatten state snapshot", "err", err)
+		}
+		m.snaps.Stop()
+	}
 
 	// Ensure the state of a recent block is also stored to disk before exiting.
 	// We're writing three different states to catch different restart scenarios:
@@ -943,6 +1016,7 @@ func (m *MinorBlockChain) WriteBlockWithoutState(block types.IBlock) (err error)
 
 	rawdb.WriteMinorBlock(m.db, block.(*types.MinorBlock))
 	m.blockCache.Add(block.Hash(), block.(*types.MinorBlock))
+	m.hc.skipList.add(block.(*types.MinorBlock).Header())
 
 	return nil
 }
@@ -961,6 +1035,9 @@ func (m *MinorBlockChain) WriteBlockWithState(block *types.MinorBlock, receipts
 	if err := m.putMinorBlock(block, xShardList); err != nil {
 		return NonStatTy, err
 	}
+	if m.balanceHistory != nil {
+		m.balanceHistory.index(block, state)
+	}
 
 	root, err := state.Commit(true)
 	if err != nil {
@@ -1023,6 +1100,7 @@ func (m *MinorBlockChain) WriteBlockWithState(block *types.MinorBlock, receipts
 	// Write other block data using a batch.
 	batch := m.db.NewBatch()
 	rawdb.WriteReceipts(batch, block.Hash(), receipts)
+	var stale *staleBlockUpdate
 
 	if updateTip {
 		// Reorganise the chain if the parent is not the head block
@@ -1036,25 +1114,48 @@ func (m *MinorBlockChain) WriteBlockWithState(block *types.MinorBlock, receipts
 			panic(err)
 		}
 		rawdb.WritePreimages(batch, state.Preimages())
+		// started after the reorg, which updates the stale table too
+		stale = m.staleBlocks.update(batch)
+		stale.markCanonical(block.Hash())
 		status = CanonStatTy
 
 	} else {
 		status = SideStatTy
+		stale = m.staleBlocks.update(batch)
+		stale.markStale(block.Hash())
 	}
 
 	if err := batch.Write(); err != nil {
 		return NonStatTy, err
 	}
+	stale.commit()
 
 	// Set new head.
 	if status == CanonStatTy {
 		m.insert(block)
+		m.updateSnapshot(root)
 	}
 	m.CommitMinorBlockByHash(block.Hash())
 	m.futureBlocks.Remove(block.Hash())
 	return status, nil
 }
 
+// updateSnapshot flattens the diff layers of the state snapshot beyond the
+// limit into the disk, or regenerates the snapshot if the new head state has
+// none, e.g. after a reorg deeper than the diff layers.
+func (m *MinorBlockChain) updateSnapshot(root common.Hash) {
+	if m.snaps == nil {
+		return
+	}
+	if m.snaps.Snapshot(root) == nil {
+		m.snaps.Rebuild(root)
+		return
+	}
+	if err := m.snaps.Cap(root, snapshotLayers); err != nil {
+		log.Warn("Failed to cap state snapshot", "root", root, "layers", snapshotLayers, "err", err)
+	}
+}
+
 // addFutureBlock checks if the block is within the max allowed window to get
 // accepted for future processing, and returns an error if the block is too far
 // ahead and was not added.
@@ -1239,10 +1340,12 @@ func (m *MinorBlockChain) insertChain(chain []types.IBlock, verifySeals bool, is
 			return it.index, events, coalescedLogs, xShardList, err
 		}
 		// Write the block to the chain and get the status.
+		writeStart := time.Now()
 		status, err := m.WriteBlockWithState(mBlock, receipts, state, xShardReceiveTxList, updateTip)
 		if err != nil {
 			return it.index, events, coalescedLogs, xShardList, err
 		}
+		m.blockProfiles.inserted(mBlock, start, time.Since(writeStart))
 		switch status {
 		case CanonStatTy:
 			log.Debug("Inserted new block", "number", mBlock.NumberU64(), "hash", mBlock.Hash(),
@@ -1335,6 +1438,9 @@ func (m *MinorBlockChain) insertSidechain(it *insertIterator, isCheckDB bool) (i
 				return it.index, nil, nil, nil, err
 			}
 			m.CommitMinorBlockByHash(block.Hash())
+			stale := m.staleBlocks.update(m.db)
+			stale.markStale(block.Hash())
+			stale.commit()
 			log.Debug("Inserted sidechain block", "number", block.NumberU64(), "hash", block.Hash(),
 				"diff", block.IHeader().GetDifficulty(), "elapsed", common.PrettyDuration(time.Since(start)),
 				"txs", len(block.(*types.MinorBlock).GetTransactions()), "gas", block.(*types.MinorBlock).GetMetaData().GasUsed,
@@ -1502,13 +1608,21 @@ func (m *MinorBlockChain) reorg(oldBlock, newBlock types.IBlock) error {
 	// When transactions get deleted from the database that means the
 	// receipts that were created in the fork must also be deleted
 	batch := m.db.NewBatch()
+	stale := m.staleBlocks.update(batch)
 	for i := len(oldChain) - 1; i >= 0; i-- {
 		if err := m.removeTxIndexFromBlock(batch, oldChain[i].(*types.MinorBlock)); err != nil {
 			return err
 		}
+		stale.markStale(oldChain[i].Hash())
+	}
+	for _, block := range newChain {
+		stale.markCanonical(block.Hash())
 	}
 
-	batch.Write()
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	stale.commit()
 
 	// Insert the new chain, taking care of the proper incremental order
 	for i := len(newChain) - 1; i >= 0; i-- {
@@ -1539,10 +1653,33 @@ func (m *MinorBlockChain) reorg(oldBlock, newBlock types.IBlock) error {
 	for _, iB := range newChain {
 		m.subLogsFeed.Send(LoglistEvent{Logs: m.GetLogs(iB.Hash()), IsRemoved: false})
 	}
+	if len(oldChain) > 0 {
+		m.reorgs.post(&m.reorgFeed, newMinorChainReorgEvent(commonBlock, oldChain, newChain))
+	}
 
 	return nil
 }
 
+// newMinorChainReorgEvent collects the details of a reorg for the subscribers,
+// the txs of the old chain which are not in the new chain are reported dropped.
+func newMinorChainReorgEvent(commonBlock types.IBlock, oldChain, newChain []types.IBlock) MinorChainReorgEvent {
+	ev := MinorChainReorgEvent{Depth: uint64(len(oldChain))}
+	if !qkcCommon.IsNil(commonBlock) {
+		ev.CommonBlock = commonBlock.(*types.MinorBlock)
+	}
+	var deletedTxs, addedTxs types.Transactions
+	for _, block := range oldChain {
+		ev.OldChain = append(ev.OldChain, block.(*types.MinorBlock))
+		deletedTxs = append(deletedTxs, block.(*types.MinorBlock).Transactions()...)
+	}
+	for _, block := range newChain {
+		ev.NewChain = append(ev.NewChain, block.(*types.MinorBlock))
+		addedTxs = append(addedTxs, block.(*types.MinorBlock).Transactions()...)
+	}
+	ev.DroppedTxs = types.TxDifference(deletedTxs, addedTxs)
+	return ev
+}
+
 // PostChainEvents iterates over the events generated by a chain insertion and
 // posts them into the event feed.
 // TODO: Should not expose PostChainEvents. The chain events should be posted in WriteBlock.
@@ -1578,8 +1715,11 @@ func (m *MinorBlockChain) update() {
 	}
 }
 
-// reportBlock logs a bad block error.
+// reportBlock logs a bad block error, and keeps the block to be traced.
 func (m *MinorBlockChain) reportBlock(block types.IBlock, receipts types.Receipts, err error) {
+	if mBlock, ok := block.(*types.MinorBlock); ok {
+		m.badBlocks.Add(mBlock.Hash(), mBlock)
+	}
 
 	var receiptString string
 	for i, receipt := range receipts {
@@ -1739,6 +1879,17 @@ func (m *MinorBlockChain) SubReorgLogsEvent(ch chan<- LoglistEvent) event.Subscr
 	return m.scope.Track(m.subLogsFeed.Subscribe(ch))
 }
 
+// SubscribeReorgEvent registers a subscription of MinorChainReorgEvent.
+func (m *MinorBlockChain) SubscribeReorgEvent(ch chan<- MinorChainReorgEvent) event.Subscription {
+	return m.scope.Track(m.reorgFeed.Subscribe(ch))
+}
+
+// SubscribeRootReorgEvent registers a subscription of RootChainReorgEvent, of
+// the reorgs of the root chain the shard follows.
+func (m *MinorBlockChain) SubscribeRootReorgEvent(ch chan<- RootChainReorgEvent) event.Subscription {
+	return m.scope.Track(m.rootReorgFeed.Subscribe(ch))
+}
+
 func (m *MinorBlockChain) SubscribeNewTxsEvent(ch chan<- NewTxsEvent) event.Subscription {
 	return m.txPool.SubscribeNewTxsEvent(ch)
 }
@@ -2,14 +2,17 @@ package core
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"math"
 	"math/big"
 	"sort"
+	"sync/atomic"
 	"time"
 
 	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/cluster/config"
 	"github.com/QuarkChain/goquarkchain/cluster/rpc"
 	qkcCommon "github.com/QuarkChain/goquarkchain/common"
 	"github.com/QuarkChain/goquarkchain/core/rawdb"
@@ -21,6 +24,7 @@ import (
 	"github.com/QuarkChain/goquarkchain/serialize"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
 )
 
 var (
@@ -96,6 +100,7 @@ func (m *MinorBlockChain) putMinorBlock(mBlock *types.MinorBlock, xShardReceiveT
 		rawdb.WriteMinorBlock(m.db, mBlock)
 		m.blockCache.Add(mBlock.Hash(), mBlock)
 	}
+	m.hc.skipList.add(mBlock.Header())
 	if err := m.putTotalTxCount(mBlock); err != nil {
 		return err
 	}
@@ -183,22 +188,11 @@ func (m *MinorBlockChain) validateTx(tx *types.Transaction, evmState *state.Stat
 	toBranch := account.Branch{Value: evmTx.ToFullShardId()}
 
 	if evmTx.IsCrossShard() {
-		initializedFullShardIDs := m.clusterConfig.Quarkchain.GetInitializedShardIdsBeforeRootHeight(m.rootTip.Number)
-		hasInit := false
-		for _, v := range initializedFullShardIDs {
-			if toBranch.GetFullShardID() == v {
-				hasInit = true
-			}
-		}
-		if !hasInit {
-			return nil, errors.New("shard is not initialized yet")
+		if err := m.checkXShardTarget(toBranch, m.rootTip.Number); err != nil {
+			return nil, err
 		}
 	}
 
-	if evmTx.IsCrossShard() && !m.isNeighbor(toBranch, nil) {
-		return nil, ErrNotNeighbor
-	}
-
 	if xShardGasLimit == nil {
 		x := m.xShardGasLimit.Uint64()
 		xShardGasLimit = &x
@@ -207,7 +201,7 @@ func (m *MinorBlockChain) validateTx(tx *types.Transaction, evmState *state.Stat
 		return nil, fmt.Errorf("xshard evm tx exceeds xshard gasLimit %v %v", evmTx.Gas(), xShardGasLimit)
 	}
 
-	if evmState.GetTimeStamp() < m.clusterConfig.Quarkchain.EnableEvmTimeStamp {
+	if !m.clusterConfig.Quarkchain.IsEvmEnabled(evmState.GetTimeStamp()) {
 		if evmTx.To() == nil || len(evmTx.Data()) != 0 {
 			return nil, errors.New("smart contract tx is not allowed before evm is enabled")
 		}
@@ -304,6 +298,23 @@ func (m *MinorBlockChain) GetParentHashByHash(hash common.Hash) common.Hash {
 	}
 }
 
+// isSameMinorChain reports whether short is long or one of its ancestors, found
+// through the header skip list of the shard.
+func (m *MinorBlockChain) isSameMinorChain(long types.IHeader, short types.IHeader) bool {
+	if long.NumberU64() < short.NumberU64() {
+		log.Crit("wrong parameter order", "long.Number", long.NumberU64(), "long.Hash", long.Hash().String(), "short.Number", short.NumberU64(), "short.hash", short.Hash().String())
+	}
+	if long.NumberU64() == short.NumberU64() {
+		return long.Hash() == short.Hash()
+	}
+	// long may not be written yet
+	ancestor := m.hc.skipList.ancestor(long.GetParentHash(), long.NumberU64()-1, short.NumberU64())
+	if ancestor == emptyHash {
+		log.Crit("mysteriously missing blocks", "long.Number", long.NumberU64(), "long.Hash", long.Hash().String(), "short.Number", short.NumberU64(), "short.hash", short.Hash().String())
+	}
+	return ancestor == short.Hash()
+}
+
 func (m *MinorBlockChain) isMinorBlockLinkedToRootTip(mBlock *types.MinorBlock) bool {
 	confirmed := m.confirmedHeaderTip
 	if confirmed == nil {
@@ -312,8 +323,46 @@ func (m *MinorBlockChain) isMinorBlockLinkedToRootTip(mBlock *types.MinorBlock)
 	if mBlock.NumberU64() <= confirmed.Number {
 		return false
 	}
-	return isSameChain(m.GetParentHashByHash, mBlock.Header(), confirmed)
+	return m.isSameMinorChain(mBlock.Header(), confirmed)
+}
+// checkXShardTarget returns an error if the deposits can't be sent to the
+// branch at the root height, i.e. the shard is not initialized yet or is not
+// a neighbor.
+func (m *MinorBlockChain) checkXShardTarget(toBranch account.Branch, rootHeight uint32) error {
+	initializedFullShardIDs := m.clusterConfig.Quarkchain.GetInitializedShardIdsBeforeRootHeight(rootHeight)
+	hasInit := false
+	for _, v := range initializedFullShardIDs {
+		if toBranch.GetFullShardID() == v {
+			hasInit = true
+		}
+	}
+	if !hasInit {
+		return errors.New("shard is not initialized yet")
+	}
+	if !m.isNeighbor(toBranch, &rootHeight) {
+		return ErrNotNeighbor
+	}
+	return nil
+}
+
+// CheckXShardTarget returns an error if the contracts executed in the block
+// can't send deposits to the full shard key, based on the root block the
+// block confirms.
+func (m *MinorBlockChain) CheckXShardTarget(header types.IHeader, fullShardKey uint32) error {
+	fullShardID, err := m.clusterConfig.Quarkchain.GetFullShardIdByFullShardKey(fullShardKey)
+	if err != nil {
+		return err
+	}
+	if fullShardID == m.branch.Value {
+		return errors.New("cross-shard call to the same shard")
+	}
+	rootHeader := m.getRootBlockHeaderByHash(header.(*types.MinorBlockHeader).PrevRootBlockHash)
+	if rootHeader == nil {
+		return ErrRootBlockIsNil
+	}
+	return m.checkXShardTarget(account.Branch{Value: fullShardID}, rootHeader.Number)
 }
+
 func (m *MinorBlockChain) isNeighbor(remoteBranch account.Branch, rootHeight *uint32) bool {
 	if rootHeight == nil {
 		rootHeight = &m.rootTip.Number
@@ -442,20 +491,42 @@ func (m *MinorBlockChain) setEvmStateWithHeader(evmState *state.StateDB, header
 func (m *MinorBlockChain) runBlock(block *types.MinorBlock) (*state.StateDB, types.Receipts, []*types.Log, uint64,
 	[]*types.CrossShardTransactionDeposit, error) {
 
+	evmState, xShardReceiveTxList, xShardReceipts, err := m.runCrossShardTxs(block)
+	if err != nil {
+		return nil, nil, nil, 0, nil, err
+	}
+	var interrupt uint32
+	if m.cacheConfig.Prefetch && len(block.Transactions()) > 0 {
+		go prefetchAccounts(block, evmState.Copy(), &interrupt)
+	}
+	receipts, logs, usedGas, err := m.processor.Process(block, evmState, m.vmConfig)
+	atomic.StoreUint32(&interrupt, 1)
+	if err != nil {
+		return nil, nil, nil, 0, nil, err
+	}
+	receipts = append(receipts, xShardReceipts...)
+	return evmState, receipts, logs, usedGas, xShardReceiveTxList, nil
+}
+
+// runCrossShardTxs applies the cross-shard deposits of the block on a copy of
+// the state of its parent, which is returned for the txs of the block to be
+// applied on.
+func (m *MinorBlockChain) runCrossShardTxs(block *types.MinorBlock) (*state.StateDB, []*types.CrossShardTransactionDeposit,
+	types.Receipts, error) {
 	parent := m.GetMinorBlock(block.ParentHash())
 	if qkcCommon.IsNil(parent) {
 		log.Error(m.logInfo, "err-runBlock", ErrRootBlockIsNil, "parentHash", block.ParentHash().String())
-		return nil, nil, nil, 0, nil, ErrRootBlockIsNil
+		return nil, nil, nil, ErrRootBlockIsNil
 	}
 	xShardReceiveTxList := make([]*types.CrossShardTransactionDeposit, 0)
 	preEvmState, err := m.getEvmStateForNewBlock(block.Header(), false)
 	if err != nil {
-		return nil, nil, nil, 0, nil, err
+		return nil, nil, nil, err
 	}
 	evmState := preEvmState.Copy()
 	xTxList, txCursorInfo, xShardReceipts, err := m.RunCrossShardTxWithCursor(evmState, block)
 	if err != nil {
-		return nil, nil, nil, 0, nil, err
+		return nil, nil, nil, err
 	}
 	evmState.SetTxCursorInfo(txCursorInfo)
 	xShardReceiveTxList = append(xShardReceiveTxList, xTxList...)
@@ -464,12 +535,7 @@ func (m *MinorBlockChain) runBlock(block *types.MinorBlock) (*state.StateDB, typ
 		left := new(big.Int).Sub(xShardGasLimit, evmState.GetGasUsed())
 		evmState.SetGasLimit(new(big.Int).Sub(evmState.GetGasLimit(), left))
 	}
-	receipts, logs, usedGas, err := m.processor.Process(block, evmState, m.vmConfig)
-	if err != nil {
-		return nil, nil, nil, 0, nil, err
-	}
-	receipts = append(receipts, xShardReceipts...)
-	return evmState, receipts, logs, usedGas, xShardReceiveTxList, nil
+	return evmState, xShardReceiveTxList, xShardReceipts, nil
 }
 
 // FinalizeAndAddBlock finalize minor block and add it to chain
@@ -492,6 +558,7 @@ func (m *MinorBlockChain) FinalizeAndAddBlock(block *types.MinorBlock) (*types.M
 
 // AddTx add tx to txPool
 func (m *MinorBlockChain) AddTx(tx *types.Transaction) error {
+	defer m.pending.txsAdded()
 	return m.txPool.AddLocal(tx)
 }
 
@@ -508,7 +575,8 @@ func (m *MinorBlockChain) getEvmStateByHash(hash *common.Hash) (*state.StateDB,
 
 	evmState, err := m.StateAt(mBlock.GetMetaData().Root)
 	if err != nil {
-		return nil, err
+		// pruned in the full gc mode, and regenerated with RegenerateState
+		return nil, fmt.Errorf("state of block %d is not available: %v", mBlock.NumberU64(), err)
 	}
 	return evmState, nil
 }
@@ -543,25 +611,71 @@ func (m *MinorBlockChain) GetStorageAt(recipient account.Recipient, key common.H
 	return evmState.GetState(recipient, key), nil
 }
 
-// ExecuteTx execute tx
-func (m *MinorBlockChain) ExecuteTx(tx *types.Transaction, fromAddress *account.Address, height *uint64) ([]byte, error) {
+// ExecuteTx executes the transaction on a copy of the state at the height, with
+// the state of the accounts overridden first, and returns the result. The
+// execution is aborted once ctx is done.
+func (m *MinorBlockChain) ExecuteTx(ctx context.Context, tx *types.Transaction, fromAddress *account.Address, height *uint64,
+	overrides []*rpc.AccountOverride) ([]byte, error) {
+	ret, _, _, err := m.executeTx(ctx, tx, fromAddress, height, overrides, nil)
+	return ret, err
+}
+
+// CreateAccessList executes the transaction on a copy of the state at the height,
+// and returns the addresses and the storage slots accessed by it, with the gas
+// used and whether the execution failed. The sender, the recipient and the
+// precompiled contracts are only listed with the storage slots accessed.
+func (m *MinorBlockChain) CreateAccessList(tx *types.Transaction, fromAddress *account.Address,
+	height *uint64) (types.AccessList, uint64, bool, error) {
+	var tracer *vm.AccessListTracer
+	newTracer := func(msg types.Message, state *state.StateDB) vm.Tracer {
+		isIstanbul := m.clusterConfig.Quarkchain.IsEvmIstanbul(m.branch.GetChainID(), state.GetTimeStamp())
+		excl := append(vm.ActivePrecompiles(state.GetTimeStamp(), isIstanbul), msg.From())
+		if msg.To() != nil {
+			excl = append(excl, *msg.To())
+		} else {
+			fromFullShardKey := msg.FromFullShardKey()
+			excl = append(excl, vm.CreateAddress(msg.From(), &fromFullShardKey, msg.Nonce()))
+		}
+		tracer = vm.NewAccessListTracer(excl)
+		return tracer
+	}
+	_, gasUsed, failed, err := m.executeTx(context.Background(), tx, fromAddress, height, nil, newTracer)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	return tracer.AccessList(), gasUsed, failed, nil
+}
+
+// executeTx executes the transaction on a copy of the state at the height, with
+// the tracer created by newTracer if it is not nil.
+func (m *MinorBlockChain) executeTx(ctx context.Context, tx *types.Transaction, fromAddress *account.Address, height *uint64,
+	overrides []*rpc.AccountOverride, newTracer func(types.Message, *state.StateDB) vm.Tracer) ([]byte, uint64, bool, error) {
 	if height == nil {
 		temp := m.CurrentBlock().NumberU64()
 		height = &temp
 	}
 	if fromAddress == nil {
-		return nil, errors.New("from address should not empty")
+		return nil, 0, false, errors.New("from address should not empty")
 	}
 	mBlock, ok := m.GetBlockByNumber(*height).(*types.MinorBlock)
 	if !ok {
-		return nil, ErrMinorBlockIsNil
+		return nil, 0, false, ErrMinorBlockIsNil
 	}
 	evmState, err := m.stateAtWithSenderDisallowMap(mBlock, nil)
 	if err != nil {
-		return nil, err
+		return nil, 0, false, err
 	}
+	return m.executeTxOnState(ctx, tx, fromAddress, mBlock, evmState, m.CurrentBlock().Header(), overrides, newTracer)
+}
+
+// executeTxOnState executes the transaction on a copy of the state of the
+// block, in the EVM context of the header.
+func (m *MinorBlockChain) executeTxOnState(ctx context.Context, tx *types.Transaction, fromAddress *account.Address,
+	mBlock *types.MinorBlock, evmState *state.StateDB, header *types.MinorBlockHeader,
+	overrides []*rpc.AccountOverride, newTracer func(types.Message, *state.StateDB) vm.Tracer) ([]byte, uint64, bool, error) {
 	state := evmState.Copy()
 	state.SetGasUsed(new(big.Int).SetUint64(0))
+	applyAccountOverrides(state, overrides)
 	var gas uint64
 	if tx.EvmTx.Gas() != 0 {
 		gas = tx.EvmTx.Gas()
@@ -570,7 +684,7 @@ func (m *MinorBlockChain) ExecuteTx(tx *types.Transaction, fromAddress *account.
 	}
 	evmTx, err := m.validateTx(tx, state, fromAddress, &gas, nil)
 	if err != nil {
-		return nil, err
+		return nil, 0, false, err
 	}
 	gp := new(GasPool).AddGas(mBlock.GasLimit().Uint64())
 
@@ -582,11 +696,56 @@ func (m *MinorBlockChain) ExecuteTx(tx *types.Transaction, fromAddress *account.
 	state.SetFullShardKey(tx.EvmTx.ToFullShardKey())
 	state.SetQuarkChainConfig(m.clusterConfig.Quarkchain)
 
-	context := NewEVMContext(msg, m.CurrentBlock().IHeader().(*types.MinorBlockHeader), m)
-	evmEnv := vm.NewEVM(context, state, m.ethChainConfig, m.vmConfig)
-	ret, _, _, err := ApplyMessage(evmEnv, msg, gp)
-	return ret, err
+	cfg := m.vmConfig
+	if newTracer != nil {
+		cfg.Debug, cfg.Tracer = true, newTracer(msg, state)
+	}
+	context := NewEVMContext(msg, header, m)
+	evmEnv := vm.NewEVM(context, state, m.ethChainConfig, cfg)
+	defer cancelEVMOnDone(ctx, evmEnv)()
+	ret, gasUsed, failed, err := ApplyMessage(evmEnv, msg, gp)
+	if ctx.Err() != nil {
+		// the result of the aborted execution is incomplete
+		return nil, 0, false, ctx.Err()
+	}
+	return ret, gasUsed, failed, err
+}
+
+// cancelEVMOnDone cancels the execution of the EVM once ctx is done, e.g. the
+// caller of the call has gone, until the returned func is called.
+func cancelEVMOnDone(ctx context.Context, evm *vm.EVM) func() {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			evm.Cancel()
+		case <-stop:
+		}
+	}()
+	return func() { close(stop) }
+}
 
+// applyAccountOverrides overrides the state of the accounts on the ephemeral state.
+func applyAccountOverrides(state *state.StateDB, overrides []*rpc.AccountOverride) {
+	for _, override := range overrides {
+		if override.Balances != nil {
+			for tokenID, balance := range override.Balances.GetBalanceMap() {
+				state.SetBalance(override.Address, balance, tokenID)
+			}
+		}
+		if override.Nonce != nil {
+			state.SetNonce(override.Address, *override.Nonce)
+		}
+		if override.Code != nil {
+			state.SetCode(override.Address, *override.Code)
+		}
+		for _, storage := range override.Storage {
+			state.SetState(override.Address, storage.Key, storage.Value)
+		}
+	}
 }
 
 func checkEqual(a, b types.IHeader) bool {
@@ -668,29 +827,50 @@ func (m *MinorBlockChain) GetUnconfirmedHeadersCoinbaseAmount() uint64 {
 	return amount
 }
 
-func (m *MinorBlockChain) addTransactionToBlock(block *types.MinorBlock, evmState *state.StateDB) (*types.MinorBlock, types.Receipts, error) {
+// addTransactionToBlock applies the pending txs to the block, and returns the
+// time spent applying them. The txs are selected by the tip they pay per gas,
+// their gas price converted from their gas token, while they fit in the gas
+// left for the in-shard txs. The cross-shard deposits are not interleaved with
+// them, they are always applied first, by RunCrossShardTxWithCursor, and the
+// gas they leave unused is not given to the txs. A tx failing on execution is
+// reverted. If it would fail whatever the block, only the tx is bad: it's
+// evicted from the pool and shifted like a tx whose nonce is too low, leaving
+// the later txs of its sender to their own checks. Otherwise it's skipped with
+// the later txs of its sender, which depend on its nonce.
+func (m *MinorBlockChain) addTransactionToBlock(block *types.MinorBlock, evmState *state.StateDB) (*types.MinorBlock, types.Receipts, time.Duration, error) {
 	// have locked by upper call
 	pending, err := m.txPool.Pending() // txpool already locked
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, 0, err
 	}
-	txs, err := types.NewTransactionsByPriceAndNonce(types.NewEIP155Signer(uint32(m.Config().NetworkID)), pending)
+	txs, err := types.NewTransactionsByTipAndNonce(types.NewEIP155Signer(uint32(m.Config().NetworkID)), pending, m.txTip)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, 0, err
 	}
 	gp := new(GasPool).AddGas(block.GasLimit().Uint64())
 	usedGas := new(uint64)
 
 	receipts := make([]*types.Receipt, 0)
 	txsInBlock := make([]*types.Transaction, 0)
+	evicted := make([]common.Hash, 0)
+
+	// the size and the tx count of the block are limited once the fork is active
+	var sizeLimit, countLimit, size uint64
+	if m.clusterConfig.Quarkchain.IsBlockLimits(m.branch.GetChainID(), block.Time()) {
+		sizeLimit, countLimit = uint64(m.shardConfig.BlockSizeLimit), uint64(m.shardConfig.BlockTxCountLimit)
+	}
 
 	stateT := evmState
 	txIndex := 0
+	var execution time.Duration
 	for stateT.GetGasUsed().Cmp(stateT.GetGasLimit()) < 0 {
+		if countLimit != 0 && uint64(len(txsInBlock)) >= countLimit {
+			break
+		}
 		tx := txs.Peek()
 		// Pop skip all txs about this account
 		//Shift skip this tx ,goto next tx about this account
-		if err := m.checkTxBeforeApply(stateT, tx, block.Header()); err != nil {
+		if err := m.checkTxBeforeApply(stateT, tx, txs.PeekTip(), block.Header()); err != nil {
 			if err == ErrorTxBreak {
 				break
 			} else if err == ErrorTxContinue {
@@ -699,51 +879,97 @@ func (m *MinorBlockChain) addTransactionToBlock(block *types.MinorBlock, evmStat
 			}
 
 		}
+		if sizeLimit != 0 && size+uint64(tx.Size()) > sizeLimit {
+			txs.Pop()
+			continue
+		}
 		stateT.Prepare(tx.Hash(), block.Hash(), txIndex)
+		snap, gas := stateT.Snapshot(), gp.Gas()
+		applyStart := time.Now()
 		_, receipt, _, err := ApplyTransaction(m.ethChainConfig, m, gp, stateT, block.IHeader().(*types.MinorBlockHeader), tx, usedGas, *m.GetVMConfig())
+		execution += time.Since(applyStart)
+		if err != nil {
+			// the tx is not in the block, so its partial changes are not either
+			stateT.RevertToSnapshot(snap)
+			*gp = GasPool(gas)
+		}
 		switch err {
 		case ErrGasLimitReached:
 			txs.Pop()
 		case ErrNonceTooLow:
 			// New head notification data race between the transaction pool and miner, shift
 			if err := txs.Shift(); err != nil {
-				return nil, nil, errors.New("txs.Shift error")
+				return nil, nil, 0, errors.New("txs.Shift error")
 			}
 		case ErrNonceTooHigh:
 			// Reorg notification data race between the transaction pool and miner, skip account =
 			txs.Pop()
 		case nil:
 			if err := txs.Shift(); err != nil {
-				return nil, nil, errors.New("txs.Shift error")
+				return nil, nil, 0, errors.New("txs.Shift error")
 			}
 			receipts = append(receipts, receipt)
 			txsInBlock = append(txsInBlock, tx)
+			size += uint64(tx.Size())
 			txIndex++
 		default:
-			// Strange error, discard the transaction and get the next in line (note, the
-			// nonce-too-high clause will prevent us from executing in vain).
+			if !isDeterministicTxFailure(err) {
+				// The nonce of the sender is not increased, so its later txs
+				// can't be applied either.
+				txs.Pop()
+				break
+			}
+			log.Debug("Evicting transaction failing on execution", "shard", m.branch.Value, "hash", tx.Hash(), "err", err)
+			evicted = append(evicted, tx.Hash())
 			if err := txs.Shift(); err != nil {
-				return nil, nil, errors.New("txs.Shift error")
+				return nil, nil, 0, errors.New("txs.Shift error")
 			}
 		}
 
 	}
+	if len(evicted) > 0 {
+		m.txPool.RemoveTxs(evicted)
+	}
 	bHeader := block.Header()
-	return types.NewMinorBlock(bHeader, block.Meta(), txsInBlock, receipts, nil), receipts, nil
+	return types.NewMinorBlock(bHeader, block.Meta(), txsInBlock, receipts, nil), receipts, execution, nil
+}
+
+// txTip returns the tip paid per gas by the tx to the miner, its gas price
+// converted from its gas token, by which the txs are selected.
+func (m *MinorBlockChain) txTip(tx *types.Transaction) *big.Int {
+	return m.clusterConfig.Quarkchain.ConvertGasPrice(tx.EvmTx.GasTokenID(), tx.EvmTx.GasPrice())
+}
+
+// isDeterministicTxFailure returns whether a tx failing on execution with err
+// would fail in any block, e.g. with too little gas or a bad signature, to be
+// evicted from the pool. The other failures may clear in a later block, e.g.
+// once the PoSW window moves or the sender is funded, so the tx is only skipped.
+func isDeterministicTxFailure(err error) bool {
+	switch err {
+	case ErrIntrinsicGas, types.ErrInvalidSig, types.ErrInvalidNetworkId:
+		return true
+	}
+	return false
 }
 
-func (m *MinorBlockChain) checkTxBeforeApply(stateT *state.StateDB, tx *types.Transaction, header *types.MinorBlockHeader) error {
+// checkTxBeforeApply returns ErrorTxContinue if the tx with the tip can't be
+// applied to the block, and ErrorTxBreak if no tx left can, as the txs are
+// selected by their tips.
+func (m *MinorBlockChain) checkTxBeforeApply(stateT *state.StateDB, tx *types.Transaction, tip *big.Int, header *types.MinorBlockHeader) error {
 	if tx == nil {
 		return ErrorTxBreak
 	}
 	diff := new(big.Int).Sub(stateT.GetGasLimit(), stateT.GetGasUsed())
+	if diff.Uint64() < params.TxGas {
+		return ErrorTxBreak
+	}
 	if tx.EvmTx.Gas() > diff.Uint64() {
 		return ErrorTxContinue
 	}
-	if tx.EvmTx.GasPrice().Cmp(m.clusterConfig.Quarkchain.MinMiningGasPrice) < 0 {
-		return ErrorTxContinue
+	if tip.Cmp(m.clusterConfig.Quarkchain.MinMiningGasPrice) < 0 {
+		return ErrorTxBreak
 	}
-	if header.Time < m.clusterConfig.Quarkchain.EnableEvmTimeStamp {
+	if !m.clusterConfig.Quarkchain.IsEvmEnabled(header.Time) {
 		if tx.EvmTx.To() == nil || len(tx.EvmTx.Data()) != 0 {
 			return ErrorTxContinue
 		}
@@ -754,11 +980,26 @@ func (m *MinorBlockChain) checkTxBeforeApply(stateT *state.StateDB, tx *types.Tr
 // CreateBlockToMine create block to mine
 func (m *MinorBlockChain) CreateBlockToMine(createTime *uint64, address *account.Address, gasLimit, xShardGasLimit *big.Int,
 	includeTx *bool) (*types.MinorBlock, error) {
+	work := createTime == nil && gasLimit == nil && xShardGasLimit == nil && (includeTx == nil || *includeTx)
+	head, root, version := m.CurrentBlock().Hash(), m.rootTip.Hash(), atomic.LoadUint64(&m.pending.txVersion)
+	block, evmState, err := m.createBlockToMine(createTime, address, gasLimit, xShardGasLimit, includeTx, true)
+	if err == nil && work {
+		// the work block of the miner is the pending block too
+		m.pending.set(head, root, version, true, block, evmState)
+	}
+	return block, err
+}
+
+// createBlockToMine returns the block to mine and its state, and profiles its
+// creation if profile is set.
+func (m *MinorBlockChain) createBlockToMine(createTime *uint64, address *account.Address, gasLimit, xShardGasLimit *big.Int,
+	includeTx *bool, profile bool) (*types.MinorBlock, *state.StateDB, error) {
 
 	if includeTx == nil {
 		t := true
 		includeTx = &t
 	}
+	start := time.Now()
 	realCreateTime := uint64(time.Now().Unix())
 	if createTime == nil {
 		if realCreateTime < m.CurrentBlock().Time()+1 {
@@ -769,14 +1010,15 @@ func (m *MinorBlockChain) CreateBlockToMine(createTime *uint64, address *account
 	}
 	difficulty, err := m.engine.CalcDifficulty(m, realCreateTime, m.CurrentBlock().Header())
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	prevBlock := m.CurrentBlock()
+	nextGasLimit, nextXShardGasLimit := m.nextGasLimits(prevBlock.Header(), realCreateTime)
 	if gasLimit == nil {
-		gasLimit = m.gasLimit
+		gasLimit = nextGasLimit
 	}
 	if xShardGasLimit == nil {
-		xShardGasLimit = m.xShardGasLimit
+		xShardGasLimit = nextXShardGasLimit
 	}
 	if address == nil {
 		t := account.CreatEmptyAddress(0)
@@ -785,7 +1027,7 @@ func (m *MinorBlockChain) CreateBlockToMine(createTime *uint64, address *account
 
 	fullShardID, err := m.clusterConfig.Quarkchain.GetFullShardIdByFullShardKey(address.FullShardKey)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if fullShardID != m.branch.Value {
 		t := address.AddressInBranch(m.branch)
@@ -796,16 +1038,18 @@ func (m *MinorBlockChain) CreateBlockToMine(createTime *uint64, address *account
 		nil, nil, &currRootTipHash)
 	evmState, err := m.getEvmStateForNewBlock(block.IHeader(), true)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	ancestorRootHeader := m.GetRootBlockByHash(m.CurrentBlock().PrevRootBlockHash()).Header()
 	if !m.isSameRootChain(m.rootTip, ancestorRootHeader) {
-		return nil, ErrNotSameRootChain
+		return nil, nil, ErrNotSameRootChain
 	}
+	xShardStart := time.Now()
 	_, txCursor, xShardReceipts, err := m.RunCrossShardTxWithCursor(evmState, block)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	execution := time.Since(xShardStart)
 	evmState.SetTxCursorInfo(txCursor)
 	//Adjust inshard tx limit if xshard gas limit is not exhausted
 	if evmState.GetGasUsed().Cmp(xShardGasLimit) == -1 {
@@ -814,11 +1058,16 @@ func (m *MinorBlockChain) CreateBlockToMine(createTime *uint64, address *account
 		evmState.SetGasLimit(new(big.Int).Sub(evmState.GetGasLimit(), left))
 	}
 	receipts := make(types.Receipts, 0)
+	var txSelection time.Duration
 	if *includeTx {
-		block, receipts, err = m.addTransactionToBlock(block, evmState)
+		txStart := time.Now()
+		var txExecution time.Duration
+		block, receipts, txExecution, err = m.addTransactionToBlock(block, evmState)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
+		txSelection = time.Since(txStart) - txExecution
+		execution += txExecution
 	}
 	receipts = append(receipts, xShardReceipts...)
 
@@ -828,18 +1077,93 @@ func (m *MinorBlockChain) CreateBlockToMine(createTime *uint64, address *account
 		evmState.AddBalance(evmState.GetBlockCoinbase(), v, k)
 	}
 	pureCoinbaseAmount.Add(evmState.GetBlockFee())
-	block.Finalize(receipts, evmState.IntermediateRoot(true), evmState.GetGasUsed(),
+	rootStart := time.Now()
+	root := evmState.IntermediateRoot(true)
+	stateRoot := time.Since(rootStart)
+	block.Finalize(receipts, root, evmState.GetGasUsed(),
 		evmState.GetXShardReceiveGasUsed(), pureCoinbaseAmount, evmState.GetTxCursorInfo())
-	return block, nil
+	if profile {
+		m.blockProfiles.created(block, start, txSelection, execution, stateRoot)
+	}
+	return block, evmState, nil
 }
 
 //Cross-Shard transaction handling
 
 // AddCrossShardTxListByMinorBlockHash add crossShardTxList by slave
 func (m *MinorBlockChain) AddCrossShardTxListByMinorBlockHash(h common.Hash, txList types.CrossShardTransactionDepositList) {
+	if m.xShardJournal != nil {
+		var rootHeight uint64
+		m.mu.RLock()
+		if m.rootTip != nil {
+			rootHeight = uint64(m.rootTip.Number)
+		}
+		m.mu.RUnlock()
+		if err := m.xShardJournal.insert(&xShardJournalEntry{Hash: h, RootHeight: rootHeight, TxList: txList}); err != nil {
+			log.Error("Failed to journal cross-shard tx list", "shard", m.branch.Value, "hash", h, "err", err)
+		}
+	}
 	rawdb.WriteCrossShardTxList(m.db, h, txList)
 }
 
+// SetXShardJournal journals the cross-shard tx lists received from the neighbor
+// shards at path until they are applied, disabled if path is empty. The lists
+// journaled before a crash but missing from the db are written to it again.
+func (m *MinorBlockChain) SetXShardJournal(path string) error {
+	if path == "" {
+		return nil
+	}
+	journal := newXShardJournal(path)
+	if err := journal.load(); err != nil {
+		return err
+	}
+	restored := 0
+	for hash, entry := range journal.entries {
+		// the lists of a hash are the same, so writing them again is harmless
+		if rawdb.ReadCrossShardTxList(m.db, hash) == nil {
+			rawdb.WriteCrossShardTxList(m.db, hash, entry.TxList)
+			restored++
+		}
+	}
+	log.Info("Loaded cross-shard tx list journal", "shard", m.branch.Value, "lists", len(journal.entries), "restored", restored)
+	m.xShardJournal = journal
+	return m.rotateXShardJournal()
+}
+
+// rotateXShardJournal drops the journaled lists applied by the head block, i.e.
+// the ones of the blocks confirmed by the root blocks below its cursor, and the
+// ones too old to be confirmed. Only the root blocks passed by the cursor since
+// the last rotation are read.
+func (m *MinorBlockChain) rotateXShardJournal() error {
+	if m.xShardJournal == nil {
+		return nil
+	}
+	head := m.CurrentBlock()
+	cursorHeight := head.Meta().XShardTxCursorInfo.RootBlockHeight
+	from := m.xShardJournalCursor
+	if from+xShardJournalRetention < cursorHeight {
+		from = cursorHeight - xShardJournalRetention
+	}
+	applied := make(map[common.Hash]bool)
+	if from < cursorHeight {
+		header := m.getRootBlockHeaderByHash(head.PrevRootBlockHash())
+		for header != nil && header.NumberU64() >= cursorHeight {
+			header = m.getRootBlockHeaderByHash(header.ParentHash)
+		}
+		for ; header != nil && header.NumberU64() >= from && header.Number != 0; header = m.getRootBlockHeaderByHash(header.ParentHash) {
+			if rBlock := m.GetRootBlockByHash(header.Hash()); rBlock != nil {
+				for _, mHeader := range rBlock.MinorBlockHeaders() {
+					applied[mHeader.Hash()] = true
+				}
+			}
+		}
+	}
+	m.xShardJournalCursor = cursorHeight
+	return m.xShardJournal.rotate(func(entry *xShardJournalEntry) bool {
+		return !applied[entry.Hash] && entry.RootHeight+xShardJournalRetention >= cursorHeight
+	})
+}
+
 // AddRootBlock add root block for minorBlockChain
 func (m *MinorBlockChain) AddRootBlock(rBlock *types.RootBlock) (bool, error) {
 	if rBlock.Number() <= uint32(m.clusterConfig.Quarkchain.GetGenesisRootHeight(m.branch.Value)) {
@@ -924,6 +1248,7 @@ func (m *MinorBlockChain) AddRootBlock(rBlock *types.RootBlock) (bool, error) {
 	}
 
 	m.mu.Lock()
+	oldRootTip := m.rootTip
 	m.rootTip = rBlock.Header()
 	m.confirmedHeaderTip = shardHeader
 	m.mu.Unlock()
@@ -979,9 +1304,46 @@ func (m *MinorBlockChain) AddRootBlock(rBlock *types.RootBlock) (bool, error) {
 			return false, err
 		}
 	}
+	if rBlock.ParentHash() != oldRootTip.Hash() {
+		if ev, ok := m.newRootReorgEvent(oldRootTip, rBlock); ok {
+			m.reorgs.post(&m.rootReorgFeed, ev)
+		}
+	}
+	if err := m.rotateXShardJournal(); err != nil {
+		log.Warn("Failed to rotate cross-shard tx list journal", "shard", m.branch.Value, "err", err)
+	}
 	return true, nil
 }
 
+// newRootReorgEvent collects the details of the switch of the root tip from
+// oldTip to newTip for the subscribers, and reports whether it replaced root
+// blocks, i.e. newTip is not a descendant of oldTip.
+func (m *MinorBlockChain) newRootReorgEvent(oldTip *types.RootBlockHeader, newTip *types.RootBlock) (RootChainReorgEvent, bool) {
+	var (
+		ev                           RootChainReorgEvent
+		deletedHeaders, addedHeaders types.MinorBlockHeaders
+	)
+	oldBlock, newBlock := m.GetRootBlockByHash(oldTip.Hash()), newTip
+	for oldBlock != nil && newBlock != nil && oldBlock.Hash() != newBlock.Hash() {
+		if oldBlock.Number() >= newBlock.Number() {
+			ev.OldChain = append(ev.OldChain, oldBlock)
+			deletedHeaders = append(deletedHeaders, oldBlock.MinorBlockHeaders()...)
+			oldBlock = m.GetRootBlockByHash(oldBlock.ParentHash())
+		} else {
+			ev.NewChain = append(ev.NewChain, newBlock)
+			addedHeaders = append(addedHeaders, newBlock.MinorBlockHeaders()...)
+			newBlock = m.GetRootBlockByHash(newBlock.ParentHash())
+		}
+	}
+	if oldBlock == nil || newBlock == nil || len(ev.OldChain) == 0 {
+		// the common ancestor is beyond the root blocks of the shard
+		return ev, false
+	}
+	ev.CommonBlock, ev.Depth = oldBlock, uint64(len(ev.OldChain))
+	ev.DroppedHeaders = types.MinorHeaderDifference(deletedHeaders, addedHeaders)
+	return ev, true
+}
+
 // GetTransactionByHash get tx by hash
 func (m *MinorBlockChain) GetTransactionByHash(hash common.Hash) (*types.MinorBlock, uint32) {
 	_, mHash, txIndex := rawdb.ReadTransaction(m.db, hash)
@@ -1012,6 +1374,28 @@ func (m *MinorBlockChain) GetTransactionReceipt(hash common.Hash) (*types.MinorB
 	return nil, 0, nil
 }
 
+// SetTxPoolJournal makes the tx pool load and persist local transactions at path.
+func (m *MinorBlockChain) SetTxPoolJournal(path string) error {
+	return m.txPool.SetJournal(path)
+}
+
+// SetTxPoolLimits updates the sizes and the policy of the tx pool.
+func (m *MinorBlockChain) SetTxPoolLimits(limits *config.TxPoolConfig) {
+	m.txPool.SetLimits(limits.AccountSlots, limits.GlobalSlots, limits.AccountQueue, limits.GlobalQueue)
+	m.txPool.SetPolicy(limits.GetPolicy(m.branch.Value))
+}
+
+// GetStaleBlocks returns the number of stale minor blocks and the latest ones.
+func (m *MinorBlockChain) GetStaleBlocks(limit uint32) (uint64, []*rpc.StaleBlock) {
+	return m.staleBlocks.staleBlocks(limit, m.GetHeader)
+}
+
+// GetBlockProfiles returns the time the latest blocks produced by the node
+// spent in each step of their production, newest first.
+func (m *MinorBlockChain) GetBlockProfiles(limit uint32) []*rpc.BlockProfile {
+	return m.blockProfiles.profiles(limit)
+}
+
 // GetShardStats show shardStatus
 func (m *MinorBlockChain) GetShardStats() (*rpc.ShardStatus, error) {
 	// getBlockCountByHeight have lock
@@ -1062,8 +1446,15 @@ func (m *MinorBlockChain) GetPendingCount() int {
 	return m.txPool.PendingCount()
 }
 
-// EstimateGas estimate gas for this tx
-func (m *MinorBlockChain) EstimateGas(tx *types.Transaction, fromAddress account.Address) (uint32, error) {
+// GetPoolTxCount returns the number of pending and queued txs sent by the
+// recipient in the tx pool.
+func (m *MinorBlockChain) GetPoolTxCount(recipient account.Recipient) (uint64, uint64) {
+	pending, queued := m.txPool.StatsFrom(recipient)
+	return uint64(pending), uint64(queued)
+}
+
+// EstimateGas estimate gas for this tx, the estimation is aborted once ctx is done
+func (m *MinorBlockChain) EstimateGas(ctx context.Context, tx *types.Transaction, fromAddress account.Address) (uint32, error) {
 	// no need to locks
 	if tx.EvmTx.Gas() > math.MaxUint32 {
 		return 0, errors.New("gas > maxInt31")
@@ -1119,6 +1510,7 @@ func (m *MinorBlockChain) EstimateGas(tx *types.Transaction, fromAddress account
 		evmState.SetFullShardKey(tx.EvmTx.ToFullShardKey())
 		context := NewEVMContext(msg, m.CurrentBlock().IHeader().(*types.MinorBlockHeader), m)
 		evmEnv := vm.NewEVM(context, evmState, m.ethChainConfig, m.vmConfig)
+		defer cancelEVMOnDone(ctx, evmEnv)()
 
 		_, _, _, err = ApplyMessage(evmEnv, msg, gp)
 		return err
@@ -1126,14 +1518,24 @@ func (m *MinorBlockChain) EstimateGas(tx *types.Transaction, fromAddress account
 
 	for lo+1 < hi {
 		mid := (lo + hi) / 2
-		if runTx(mid) == nil {
+		err := runTx(mid)
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		if err == nil {
 			hi = mid
 		} else {
 			lo = mid
 		}
 	}
-	if hi == cap && runTx(hi) == nil {
-		return 0, nil
+	if hi == cap {
+		err := runTx(hi)
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		if err == nil {
+			return 0, nil
+		}
 	}
 	return hi, nil
 }
@@ -1367,7 +1769,7 @@ func (m *MinorBlockChain) getPendingTxByAddress(address account.Address, transfe
 }
 
 func (m *MinorBlockChain) getTransactionDetails(start, end []byte, limit uint32, getTxType GetTxDetailType, skipCoinbaseRewards bool, transferTokenID *uint64) ([]*rpc.TransactionDetail, []byte, error) {
-	qkcDB, ok := m.db.(*qkcdb.RDBDatabase)
+	qkcDB, ok := rawdb.KeyValueStore(m.db).(qkcdb.KeyValueStore)
 	if !ok {
 		return nil, nil, errors.New("only support qkcdb now")
 	}
@@ -1396,13 +1798,13 @@ func (m *MinorBlockChain) getTransactionDetails(start, end []byte, limit uint32,
 
 	it.SeekForPrev(start)
 	for it.Valid() {
-		if bytes.Compare(it.Key().Data(), end) < 0 {
+		if bytes.Compare(it.Key(), end) < 0 {
 			break
 		}
 		if getTxType == GetTxFromAddress {
-			height, crossShard, index, err = decodeTxKey(it.Key().Data(), len(addressTxKey), 20)
+			height, crossShard, index, err = decodeTxKey(it.Key(), len(addressTxKey), 20)
 		} else if getTxType == GetAllTransaction {
-			height, crossShard, index, err = decodeTxKey(it.Key().Data(), len(allTxKey), 0)
+			height, crossShard, index, err = decodeTxKey(it.Key(), len(allTxKey), 0)
 		} else {
 			return nil, nil, errors.New("not support yet")
 		}
@@ -1480,7 +1882,7 @@ func (m *MinorBlockChain) getTransactionDetails(start, end []byte, limit uint32,
 			}
 
 		}
-		next = bytesSubOne(it.Key().Data())
+		next = bytesSubOne(it.Key())
 		if limit == 0 {
 			break
 		}
@@ -1525,9 +1927,9 @@ func (m *MinorBlockChain) GetAllTx(start []byte, limit uint32) ([]*rpc.Transacti
 	return m.getTransactionDetails(start, end, limit, GetAllTransaction, true, nil)
 }
 
-func (m *MinorBlockChain) GetLogsByFilterQuery(args *qrpc.FilterQuery) ([]*types.Log, error) {
+func (m *MinorBlockChain) GetLogsByFilterQuery(ctx context.Context, args *qrpc.FilterQuery) ([]*types.Log, error) {
 	filter := NewRangeFilter(m, args.FromBlock.Uint64(), args.ToBlock.Uint64(), args.Addresses, args.Topics)
-	return filter.Logs()
+	return filter.Logs(ctx)
 }
 
 func (m *MinorBlockChain) putTxIndexDB(key []byte) error {
@@ -1634,12 +2036,17 @@ func (m *MinorBlockChain) RunCrossShardTxWithCursor(evmState *state.StateDB,
 		if xShardDepositTx == nil {
 			break
 		}
-		checkIsFromRootChain := cursor.rBlock.Header().NumberU64() >= m.clusterConfig.Quarkchain.XShardGasDDOSFixRootHeight
+		checkIsFromRootChain := m.clusterConfig.Quarkchain.IsXShardGasDDOSFixed(cursor.rBlock.Header().NumberU64())
 		txIndex := 0
-		receipt, err := ApplyCrossShardDeposit(m.ethChainConfig, m, mBlock.Header(),
-			*m.GetVMConfig(), evmState, xShardDepositTx, gasUsed, checkIsFromRootChain, txIndex)
-		if err != nil {
-			return nil, nil, nil, err
+		var receipt *types.Receipt
+		if m.isXShardDepositExpired(mBlock, cursor, xShardDepositTx) {
+			receipt = RefundCrossShardDeposit(evmState, xShardDepositTx, gasUsed, checkIsFromRootChain)
+		} else {
+			receipt, err = ApplyCrossShardDeposit(m.ethChainConfig, m, mBlock.Header(),
+				*m.GetVMConfig(), evmState, xShardDepositTx, gasUsed, checkIsFromRootChain, txIndex)
+			if err != nil {
+				return nil, nil, nil, err
+			}
 		}
 		txIndex++
 		if receipt != nil {
@@ -1655,6 +2062,134 @@ func (m *MinorBlockChain) RunCrossShardTxWithCursor(evmState *state.StateDB,
 	return txList, cursor.getCursorInfo(), receipts, nil
 }
 
+// GetUnreceivedXShardDeposits returns up to limit of the cross-shard deposits
+// confirmed by the root tip which are not applied by the current tip yet.
+func (m *MinorBlockChain) GetUnreceivedXShardDeposits(limit uint32) ([]*rpc.UnreceivedXShardDeposit, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tip := m.CurrentBlock()
+	header := *tip.Header()
+	header.PrevRootBlockHash = m.rootTip.Hash()
+	cursor := NewXShardTxCursor(m, &header, tip.Meta().XShardTxCursorInfo)
+	refund := m.clusterConfig.Quarkchain.IsXShardDepositRefund(m.rootTip.NumberU64())
+	var builtOnRootHeight uint64
+	if builtOn := m.GetRootBlockByHash(tip.PrevRootBlockHash()); builtOn != nil {
+		builtOnRootHeight = builtOn.NumberU64()
+	}
+	deposits := make([]*rpc.UnreceivedXShardDeposit, 0)
+	for uint32(len(deposits)) < limit {
+		tx, err := cursor.getNextTx()
+		if err != nil {
+			return nil, err
+		}
+		if tx == nil {
+			break
+		}
+		if tx.IsFromRootChain {
+			continue
+		}
+		rootBlockHeight := cursor.rBlock.Header().NumberU64()
+		expired := refund && canRefundXShardDeposit(tx) &&
+			isXShardDepositExpiredAt(rootBlockHeight, builtOnRootHeight, m.clusterConfig.Quarkchain.XShardDepositRefundDepth)
+		deposits = append(deposits, &rpc.UnreceivedXShardDeposit{
+			RootBlockHeight: rootBlockHeight,
+			Expired:         expired,
+			Deposit:         tx,
+		})
+	}
+	return deposits, nil
+}
+
+// GetXShardQueue returns the queue of the cross-shard deposits confirmed by the
+// root tip which are not applied by the current tip yet, the root block
+// coinbases aside. The neighbor blocks confirmed whose xshard tx lists are not
+// received are counted instead of read, as they hold the queue back.
+func (m *MinorBlockChain) GetXShardQueue() *rpc.XShardQueue {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	cursorInfo := m.CurrentBlock().Meta().XShardTxCursorInfo
+	queue := &rpc.XShardQueue{
+		Branch:           m.branch.Value,
+		CursorRootHeight: cursorInfo.RootBlockHeight,
+		RootTipHeight:    m.rootTip.NumberU64(),
+		TotalValue:       types.NewEmptyTokenBalances(),
+	}
+	for height := cursorInfo.RootBlockHeight; height <= queue.RootTipHeight; height++ {
+		rHeader := m.GetRootBlockHeaderByHeight(m.rootTip.Hash(), height)
+		if rHeader == nil {
+			break
+		}
+		rBlock := m.GetRootBlockByHash(rHeader.Hash())
+		for i, mHeader := range rBlock.MinorBlockHeaders() {
+			// the cursor points at the last deposit applied
+			first, index := uint64(0), uint64(i+1)
+			if height == cursorInfo.RootBlockHeight && cursorInfo.MinorBlockIndex != 0 {
+				if index < cursorInfo.MinorBlockIndex {
+					continue
+				}
+				if index == cursorInfo.MinorBlockIndex {
+					first = cursorInfo.XShardDepositIndex + 1
+				}
+			}
+			if mHeader.Branch == m.branch || !m.isNeighbor(mHeader.Branch, &rHeader.Number) {
+				continue
+			}
+			prevRootBlock := m.GetRootBlockByHash(mHeader.PrevRootBlockHash)
+			if prevRootBlock == nil || prevRootBlock.Number() <= m.GetGenesisRootHeight() {
+				continue
+			}
+			txList := m.ReadCrossShardTxList(mHeader.Hash())
+			if txList == nil {
+				queue.MissingTxLists++
+				continue
+			}
+			for j := first; j < uint64(len(txList.TXList)); j++ {
+				tx := txList.TXList[j]
+				if queue.Count == 0 {
+					queue.OldestRootHeight = height
+				}
+				queue.Count++
+				queue.TotalValue.Add(map[uint64]*big.Int{tx.TransferTokenID: tx.Value.Value})
+			}
+		}
+	}
+	return queue
+}
+
+// isXShardDepositExpired returns whether the deposit, confirmed by the root
+// block of the cursor, was already XShardDepositRefundDepth root blocks deep when
+// the parent of the block was built, so it is refunded to its sender. The
+// expiry counts from the root block the chain had built on before the block,
+// not the one the block confirms, so a block catching up with the root chain
+// does not expire the deposits it sees for the first time. A deposit whose
+// remaining gas can't pay for its refund is applied, so a refund sent back and
+// forth is applied once its gas runs out.
+func (m *MinorBlockChain) isXShardDepositExpired(mBlock *types.MinorBlock, cursor *XShardTxCursor,
+	tx *types.CrossShardTransactionDeposit) bool {
+
+	if tx.IsFromRootChain || cursor.rBlock == nil || !canRefundXShardDeposit(tx) ||
+		!m.clusterConfig.Quarkchain.IsXShardDepositRefund(cursor.maxRootBlockHeader.NumberU64()) {
+		return false
+	}
+	parent := m.GetMinorBlock(mBlock.ParentHash())
+	if parent == nil {
+		return false
+	}
+	parentRoot := m.GetRootBlockByHash(parent.PrevRootBlockHash())
+	if parentRoot == nil {
+		return false
+	}
+	return isXShardDepositExpiredAt(cursor.rBlock.NumberU64(), parentRoot.NumberU64(), m.clusterConfig.Quarkchain.XShardDepositRefundDepth)
+}
+
+// isXShardDepositExpiredAt returns whether the deposit confirmed at rootHeight
+// is expired once the chain built on the root block at builtOnRootHeight.
+func isXShardDepositExpiredAt(rootHeight, builtOnRootHeight, depth uint64) bool {
+	return rootHeight+depth < builtOnRootHeight
+}
+
 func CountAddressFromSlice(lists []account.Recipient, recipient account.Recipient) uint64 {
 	cnt := uint64(0)
 	for _, v := range lists {
@@ -1708,5 +2243,6 @@ func (m *MinorBlockChain) GetMiningInfo(address account.Recipient, stake *types.
 
 func (m *MinorBlockChain) AddTxList(txs []*types.Transaction) []error {
 	errList := m.txPool.AddLocals(txs)
+	m.pending.txsAdded()
 	return errList
 }
@@ -847,9 +847,27 @@ func TestMinorChainTxReorgs(t *testing.T) {
 		}
 	})
 
+	reorgCh := make(chan MinorChainReorgEvent, 8)
+	reorgSub := blockchain.SubscribeReorgEvent(reorgCh)
+	defer reorgSub.Unsubscribe()
 	if _, err := blockchain.InsertChain(toMinorBlocks(chain), false); err != nil {
 		t.Fatalf("failed to insert forked chain: %v", err)
 	}
+	select {
+	case ev := <-reorgCh:
+		if ev.Depth != 3 || len(ev.OldChain) != 3 || ev.CommonBlock == nil || ev.CommonBlock.Hash() != genesis.Hash() {
+			t.Errorf("reorg event mismatch: depth %d, old chain %d, common block %v", ev.Depth, len(ev.OldChain), ev.CommonBlock)
+		}
+		dropped := make(map[common.Hash]bool)
+		for _, tx := range ev.DroppedTxs {
+			dropped[tx.Hash()] = true
+		}
+		if len(dropped) != 2 || !dropped[transEvmTxToTx(pastDrop).Hash()] || !dropped[transEvmTxToTx(freshDrop).Hash()] {
+			t.Errorf("dropped txs mismatch: have %d txs", len(ev.DroppedTxs))
+		}
+	case <-time.After(time.Second):
+		t.Errorf("no reorg event posted")
+	}
 
 	// removed tx
 	for i, tx := range (types.Transactions{transEvmTxToTx(pastDrop), transEvmTxToTx(freshDrop)}) {
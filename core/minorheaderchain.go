@@ -54,6 +54,8 @@ type HeaderChain struct {
 	tdCache     *lru.Cache // Cache for the most recent block total difficulties
 	numberCache *lru.Cache // Cache for the most recent block numbers
 
+	skipList *headerSkipList // Recent headers linked for fast ancestor lookups
+
 	procInterrupt func() bool
 
 	rand   *mrand.Rand
@@ -85,6 +87,12 @@ func NewMinorHeaderChain(chainDb ethdb.Database, config *config.QuarkChainConfig
 		rand:          mrand.New(mrand.NewSource(seed.Int64())),
 		engine:        engine,
 	}
+	hc.skipList = newHeaderSkipList(func(hash common.Hash) *types.MinorBlockHeader {
+		if header := hc.GetHeader(hash); header != nil {
+			return header.(*types.MinorBlockHeader)
+		}
+		return nil
+	})
 
 	genesisHeader := hc.GetHeaderByNumber(0)
 	if genesisHeader == nil {
@@ -177,6 +185,7 @@ func (hc *HeaderChain) WriteHeader(header *types.MinorBlockHeader) (status Write
 
 	hc.headerCache.Add(hash, header)
 	hc.numberCache.Add(hash, number)
+	hc.skipList.add(header)
 
 	return
 }
@@ -314,6 +323,9 @@ func (hc *HeaderChain) GetAncestor(hash common.Hash, number, ancestor uint64, ma
 	if ancestor > number {
 		return common.Hash{}, 0
 	}
+	if hash := hc.skipList.knownAncestor(hash, number, number-ancestor); hash != (common.Hash{}) {
+		return hash, number - ancestor
+	}
 	if ancestor == 1 {
 		// in this case it is cheaper to just read the header
 		if header := hc.GetHeader(hash); header != nil {
@@ -438,6 +450,7 @@ func (hc *HeaderChain) SetHead(head uint64, delFn DeleteCallback) {
 	hc.headerCache.Purge()
 	hc.tdCache.Purge()
 	hc.numberCache.Purge()
+	hc.skipList.purge()
 
 	if hc.CurrentHeader() == nil {
 		hc.currentHeader.Store(hc.genesisHeader)
@@ -0,0 +1,277 @@
+package core
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/QuarkChain/goquarkchain/core/state"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/core/vm"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var errUnexpectedTouch = errors.New("tx touched accounts out of its touch set")
+
+// parallelTx is a plain value transfer of a block which can be executed in
+// parallel with the other transfers not touching its sender and recipient.
+type parallelTx struct {
+	index  int
+	tx     *types.Transaction
+	sender common.Address
+	to     common.Address
+
+	// The result of the speculative execution on a copy of the state.
+	state   *state.StateDB
+	receipt *types.Receipt
+	gas     uint64
+	gasUsed *big.Int // the block gas used added by the tx
+	fee     *big.Int // the block fee added by the tx, in the gas token
+	err     error
+}
+
+// parallelExecutor executes the transactions of a minor block. The consecutive
+// plain value transfers with disjoint touch sets, i.e. senders and recipients,
+// are executed in parallel on copies of the state, and merged into the state in
+// the block order. A transfer touching accounts out of its touch set, or failing
+// the execution or the in-order checks, is re-executed serially together with
+// the rest of its batch, as are all the other transactions, so the result is
+// always the same as the serial execution.
+type parallelExecutor struct {
+	p       *StateProcessor
+	block   *types.MinorBlock
+	statedb *state.StateDB
+	cfg     vm.Config
+	gp      *GasPool
+	usedGas *uint64
+	xGas    *uint64
+	workers int
+
+	coinbase common.Address
+	receipts types.Receipts
+}
+
+func newParallelExecutor(p *StateProcessor, block *types.MinorBlock, statedb *state.StateDB, cfg vm.Config,
+	gp *GasPool, usedGas, xGas *uint64, workers int) *parallelExecutor {
+
+	return &parallelExecutor{
+		p:        p,
+		block:    block,
+		statedb:  statedb,
+		cfg:      cfg,
+		gp:       gp,
+		usedGas:  usedGas,
+		xGas:     xGas,
+		workers:  workers,
+		coinbase: block.Coinbase().Recipient,
+	}
+}
+
+func (e *parallelExecutor) run() (types.Receipts, error) {
+	txs := e.block.GetTransactions()
+	e.recoverSenders(txs)
+
+	var (
+		batch   []*parallelTx
+		touched = make(map[common.Address]struct{})
+	)
+	flush := func() error {
+		err := e.executeBatch(batch)
+		batch, touched = batch[:0], make(map[common.Address]struct{})
+		return err
+	}
+	for i, tx := range txs {
+		ptx := e.analyze(i, tx)
+		if ptx == nil {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			if err := e.applySerial(i, tx); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		_, senderTouched := touched[ptx.sender]
+		_, toTouched := touched[ptx.to]
+		if senderTouched || toTouched {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+		batch = append(batch, ptx)
+		touched[ptx.sender], touched[ptx.to] = struct{}{}, struct{}{}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return e.receipts, nil
+}
+
+// recoverSenders recovers and caches the senders of the transactions in parallel.
+func (e *parallelExecutor) recoverSenders(txs types.Transactions) {
+	signer := types.NewEIP155Signer(e.p.bc.clusterConfig.Quarkchain.NetworkID)
+	var wg sync.WaitGroup
+	for w := 0; w < e.workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < len(txs); i += e.workers {
+				if txs[i].TxType == types.EvmTx {
+					txs[i].Sender(signer)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+// analyze returns the transaction as a parallelTx if it is a valid plain value
+// transfer in the current state, or nil if it must be executed serially.
+func (e *parallelExecutor) analyze(i int, tx *types.Transaction) *parallelTx {
+	evmTx, err := e.p.bc.validateTx(tx, e.statedb, nil, nil, e.xGas)
+	if err != nil || evmTx.EvmTx.IsCrossShard() || evmTx.EvmTx.To() == nil {
+		return nil
+	}
+	sender, err := evmTx.Sender(types.NewEIP155Signer(e.p.bc.clusterConfig.Quarkchain.NetworkID))
+	if err != nil {
+		return nil
+	}
+	to := *evmTx.EvmTx.To()
+	if sender == e.coinbase || to == e.coinbase || vm.PrecompiledContractsByzantium[to] != nil ||
+		e.statedb.GetCodeSize(to) != 0 {
+		return nil
+	}
+	return &parallelTx{index: i, tx: evmTx, sender: sender, to: to}
+}
+
+func (e *parallelExecutor) executeBatch(batch []*parallelTx) error {
+	if len(batch) < 2 {
+		for _, ptx := range batch {
+			if err := e.applySerial(ptx.index, ptx.tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	workers := e.workers
+	if workers > len(batch) {
+		workers = len(batch)
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		statedb := e.statedb.Copy()
+		statedb.SetShardConfig(e.statedb.GetShardConfig())
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			allowed := map[common.Address]struct{}{e.coinbase: {}}
+			for i := w; i < len(batch); i += workers {
+				ptx := batch[i]
+				allowed[ptx.sender], allowed[ptx.to] = struct{}{}, struct{}{}
+				e.speculate(statedb, ptx, allowed)
+				if ptx.err != nil {
+					// the copy may be polluted, leave the rest to the serial execution
+					for j := i + workers; j < len(batch); j += workers {
+						batch[j].err = ptx.err
+					}
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	for i, ptx := range batch {
+		if err := e.merge(ptx); err != nil {
+			for _, rest := range batch[i:] {
+				if err := e.applySerial(rest.index, rest.tx); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// speculate executes the transfer on the copy of the state, and records the
+// changes of the block accumulators made by it.
+func (e *parallelExecutor) speculate(statedb *state.StateDB, ptx *parallelTx, allowed map[common.Address]struct{}) {
+	gasTokenID := ptx.tx.EvmTx.GasTokenID()
+	gasUsed, fee := statedb.GetGasUsed(), blockFeeOf(statedb, gasTokenID)
+
+	statedb.Prepare(ptx.tx.Hash(), e.block.Hash(), ptx.index)
+	gp := new(GasPool).AddGas(ptx.tx.EvmTx.Gas())
+	_, receipt, gas, err := ApplyTransaction(e.p.config, e.p.bc, gp, statedb, e.block.IHeader(), ptx.tx, new(uint64), e.cfg)
+	if err != nil {
+		ptx.err = err
+		return
+	}
+	for _, addr := range statedb.JournalDirties() {
+		if _, ok := allowed[addr]; !ok {
+			ptx.err = errUnexpectedTouch
+			return
+		}
+	}
+	ptx.state, ptx.receipt, ptx.gas = statedb, receipt, gas
+	ptx.gasUsed = new(big.Int).Sub(statedb.GetGasUsed(), gasUsed)
+	ptx.fee = new(big.Int).Sub(blockFeeOf(statedb, gasTokenID), fee)
+}
+
+// merge checks the transfer against the state in the block order and applies
+// its result to the state.
+func (e *parallelExecutor) merge(ptx *parallelTx) error {
+	if ptx.err != nil {
+		return ptx.err
+	}
+	evmTx, err := e.p.bc.validateTx(ptx.tx, e.statedb, nil, nil, e.xGas)
+	if err != nil {
+		return err
+	}
+	if err := e.gp.SubGas(evmTx.EvmTx.Gas()); err != nil {
+		return err
+	}
+	e.gp.AddGas(evmTx.EvmTx.Gas() - ptx.gas)
+
+	e.statedb.SetFullShardKey(evmTx.EvmTx.ToFullShardKey())
+	for _, addr := range []common.Address{ptx.sender, ptx.to} {
+		if !ptx.state.Exist(addr) {
+			continue
+		}
+		e.statedb.SetNonce(addr, ptx.state.GetNonce(addr))
+		for tokenID, balance := range ptx.state.GetBalances(addr).GetBalanceMap() {
+			e.statedb.SetBalance(addr, balance, tokenID)
+		}
+	}
+	gasTokenID := evmTx.EvmTx.GasTokenID()
+	e.statedb.AddBalance(e.coinbase, ptx.fee, gasTokenID)
+	e.statedb.AddBlockFee(map[uint64]*big.Int{gasTokenID: ptx.fee})
+	e.statedb.AddGasUsed(ptx.gasUsed)
+	*e.usedGas += ptx.gas
+
+	ptx.receipt.CumulativeGasUsed = e.statedb.GetGasUsed().Uint64()
+	e.receipts = append(e.receipts, ptx.receipt)
+	return nil
+}
+
+func (e *parallelExecutor) applySerial(i int, tx *types.Transaction) error {
+	evmTx, err := e.p.bc.validateTx(tx, e.statedb, nil, nil, e.xGas)
+	if err != nil {
+		return err
+	}
+	e.statedb.Prepare(tx.Hash(), e.block.Hash(), i)
+	_, receipt, _, err := ApplyTransaction(e.p.config, e.p.bc, e.gp, e.statedb, e.block.IHeader(), evmTx, e.usedGas, e.cfg)
+	if err != nil {
+		return err
+	}
+	e.receipts = append(e.receipts, receipt)
+	return nil
+}
+
+func blockFeeOf(statedb *state.StateDB, tokenID uint64) *big.Int {
+	if fee, ok := statedb.GetBlockFee()[tokenID]; ok {
+		return new(big.Int).Set(fee)
+	}
+	return new(big.Int)
+}
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParallelTxExecution(t *testing.T) {
+	ids := make([]account.Identity, 5)
+	accs := make([]account.Address, len(ids))
+	for i := range ids {
+		id, err := account.CreatRandomIdentity()
+		checkErr(err)
+		ids[i], accs[i] = id, account.CreatAddressFromIdentity(id, 0)
+	}
+	fakeQuarkHash := uint64(100000000)
+	env := setUp(&accs[0], &fakeQuarkHash, nil)
+	shardState := createDefaultShardState(env, nil, nil, nil, nil)
+	defer shardState.Stop()
+	coinbase, err := account.CreatRandomAccountWithFullShardKey(0)
+	checkErr(err)
+
+	// Add a root block to have all the shards initialized
+	rootBlock := shardState.rootTip.CreateBlockToAppend(nil, nil, nil, nil, nil).Finalize(nil, nil, common.Hash{})
+	_, err = shardState.AddRootBlock(rootBlock)
+	checkErr(err)
+
+	// Fund the other accounts
+	for i := 1; i < len(ids); i++ {
+		nonce := uint64(i - 1)
+		checkErr(shardState.AddTx(createTransferTransaction(shardState, ids[0].GetKey().Bytes(), accs[0], accs[i],
+			big.NewInt(10000000), nil, nil, &nonce, nil, nil, nil)))
+	}
+	b1, err := shardState.CreateBlockToMine(nil, &coinbase, nil, nil, nil)
+	checkErr(err)
+	assert.Equal(t, len(ids)-1, len(b1.Transactions()))
+	_, _, err = shardState.FinalizeAndAddBlock(b1)
+	checkErr(err)
+
+	// Disjoint transfers, transfers sharing the sender or the recipient with an
+	// earlier one, and a cross-shard transfer which is executed serially
+	newAcc := func(fullShardKey uint32) account.Address {
+		acc, err := account.CreatRandomAccountWithFullShardKey(fullShardKey)
+		checkErr(err)
+		return acc
+	}
+	nonces := make([]uint64, len(ids))
+	for i := range ids {
+		nonces[i], err = shardState.GetTransactionCount(accs[i].Recipient, nil)
+		checkErr(err)
+	}
+	transfer := func(from int, to account.Address, value int64, gas *uint64, data []byte) *types.Transaction {
+		tx := createTransferTransaction(shardState, ids[from].GetKey().Bytes(), accs[from], to,
+			big.NewInt(value), gas, nil, &nonces[from], data, nil, nil)
+		nonces[from]++
+		return tx
+	}
+	gas := uint64(50000)
+	txs := types.Transactions{
+		transfer(1, newAcc(0), 100, nil, nil),
+		transfer(2, newAcc(0), 200, nil, nil),
+		transfer(3, accs[4], 300, nil, nil),
+		transfer(4, accs[1], 400, nil, nil),
+		transfer(1, newAcc(0), 500, &gas, []byte{1, 2, 3}),
+		transfer(0, newAcc(1<<16), 600, &gas, nil),
+		transfer(2, accs[3], 700, nil, nil),
+	}
+
+	b2, err := shardState.CreateBlockToMine(nil, &coinbase, nil, nil, nil)
+	checkErr(err)
+	b2 = types.NewMinorBlock(b2.Header(), b2.Meta(), txs, nil, nil)
+
+	shardState.clusterConfig.ParallelTxWorkers = 0
+	serialState, serialReceipts, _, serialGas, _, err := shardState.runBlock(b2)
+	checkErr(err)
+	shardState.clusterConfig.ParallelTxWorkers = 4
+	defer func() { shardState.clusterConfig.ParallelTxWorkers = 0 }()
+	parallelState, parallelReceipts, _, parallelGas, _, err := shardState.runBlock(b2)
+	checkErr(err)
+
+	assert.Equal(t, serialGas, parallelGas)
+	assert.Equal(t, serialState.GetGasUsed(), parallelState.GetGasUsed())
+	assert.Equal(t, serialState.GetBlockFee(), parallelState.GetBlockFee())
+	assert.Equal(t, len(serialState.GetXShardList()), len(parallelState.GetXShardList()))
+	assert.Equal(t, serialState.IntermediateRoot(true), parallelState.IntermediateRoot(true))
+	assert.Equal(t, len(serialReceipts), len(parallelReceipts))
+	for i := range serialReceipts {
+		assert.Equal(t, serialReceipts[i].TxHash, parallelReceipts[i].TxHash)
+		assert.Equal(t, serialReceipts[i].Status, parallelReceipts[i].Status)
+		assert.Equal(t, serialReceipts[i].GasUsed, parallelReceipts[i].GasUsed)
+		assert.Equal(t, serialReceipts[i].CumulativeGasUsed, parallelReceipts[i].CumulativeGasUsed)
+	}
+
+	// The block executed in parallel is accepted
+	_, _, err = shardState.FinalizeAndAddBlock(b2)
+	checkErr(err)
+	assert.Equal(t, b2.Hash(), shardState.CurrentBlock().Hash())
+}
@@ -0,0 +1,104 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/core/state"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// pendingState is the block the shard would mine now, with the txs of the pool
+// applied on top of the head, and its state. It is the last work block of the
+// miner while the head and the root tip don't change, and is created again
+// on the next query once they do, or once txs are added to the pool of a
+// shard not mining.
+type pendingState struct {
+	mu      sync.Mutex
+	head    common.Hash
+	root    common.Hash
+	version uint64 // of the txs added to the pool
+	mined   bool   // the work block of the miner
+	block   *types.MinorBlock
+	state   *state.StateDB
+
+	txVersion uint64 // atomic, bumped when txs are added to the pool
+}
+
+// txsAdded makes the pending block be created again on the next query, unless
+// it is the work block of the miner, which the miner creates again itself.
+func (p *pendingState) txsAdded() {
+	atomic.AddUint64(&p.txVersion, 1)
+}
+
+// set makes the block created on top of head and root, with the txs of the
+// pool at version, the pending block, unless a block created after it is.
+func (p *pendingState) set(head, root common.Hash, version uint64, mined bool, block *types.MinorBlock, evmState *state.StateDB) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.block != nil && p.head == head && p.root == root && p.version > version {
+		return
+	}
+	p.head, p.root, p.version, p.mined, p.block, p.state = head, root, version, mined, block, evmState
+}
+
+// Pending returns the pending block and a copy of its state. The work block
+// of the miner is returned while it is on top of the head and the root tip,
+// otherwise the pending block is created without holding the lock of the
+// pending state.
+func (m *MinorBlockChain) Pending() (*types.MinorBlock, *state.StateDB, error) {
+	p := m.pending
+	head, root, version := m.CurrentBlock().Hash(), m.rootTip.Hash(), atomic.LoadUint64(&p.txVersion)
+	p.mu.Lock()
+	if p.block != nil && p.head == head && p.root == root && (p.mined || p.version == version) {
+		block, evmState := p.block, p.state.Copy()
+		p.mu.Unlock()
+		return block, evmState, nil
+	}
+	p.mu.Unlock()
+	block, evmState, err := m.createBlockToMine(nil, nil, nil, nil, nil, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	copied := evmState.Copy()
+	p.set(head, root, version, false, block, evmState)
+	return block, copied, nil
+}
+
+// GetPendingAccountData returns the nonce, the balances and whether the
+// recipient is a contract in the pending state.
+func (m *MinorBlockChain) GetPendingAccountData(recipient account.Recipient) (*rpc.AccountBranchData, error) {
+	_, evmState, err := m.Pending()
+	if err != nil {
+		return nil, err
+	}
+	data := &rpc.AccountBranchData{
+		Branch:           m.branch.Value,
+		TransactionCount: evmState.GetNonce(recipient),
+		Balance:          evmState.GetBalances(recipient).Copy(),
+		IsContract:       len(evmState.GetCode(recipient)) > 0,
+	}
+	data.PendingTxCount, data.QueuedTxCount = m.GetPoolTxCount(recipient)
+	return data, nil
+}
+
+// ExecutePendingTx executes the transaction on a copy of the pending state,
+// with the state of the accounts overridden first, and returns the result. The
+// execution is aborted once ctx is done.
+func (m *MinorBlockChain) ExecutePendingTx(ctx context.Context, tx *types.Transaction, fromAddress *account.Address,
+	overrides []*rpc.AccountOverride) ([]byte, error) {
+	if fromAddress == nil {
+		return nil, errors.New("from address should not empty")
+	}
+	block, evmState, err := m.Pending()
+	if err != nil {
+		return nil, err
+	}
+	ret, _, _, err := m.executeTxOnState(ctx, tx, fromAddress, block, evmState, block.Header(), overrides, nil)
+	return ret, err
+}
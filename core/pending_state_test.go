@@ -0,0 +1,85 @@
+package core
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPendingState(t *testing.T) {
+	id1, err := account.CreatRandomIdentity()
+	checkErr(err)
+	acc1 := account.CreatAddressFromIdentity(id1, 0)
+	id2, err := account.CreatRandomIdentity()
+	checkErr(err)
+	acc2 := account.CreatAddressFromIdentity(id2, 0)
+	acc3, err := account.CreatRandomAccountWithFullShardKey(0)
+	checkErr(err)
+	fakeMoney := uint64(10000000)
+	env := setUp(&acc1, &fakeMoney, nil)
+	shardState := createDefaultShardState(env, nil, nil, nil, nil)
+	defer shardState.Stop()
+	rootBlock := shardState.rootTip.CreateBlockToAppend(nil, nil, nil, nil, nil).Finalize(nil, nil, common.Hash{})
+	_, err = shardState.AddRootBlock(rootBlock)
+	checkErr(err)
+	genesisToken := shardState.GetGenesisToken()
+
+	fakeGas := uint64(50000)
+	tx := createTransferTransaction(shardState, id1.GetKey().Bytes(), acc1, acc2, big.NewInt(1000000), &fakeGas, nil, nil, nil, nil, nil)
+	checkErr(shardState.AddTx(tx))
+
+	// the tx of the pool is applied to the pending state only
+	data, err := shardState.GetPendingAccountData(acc1.Recipient)
+	checkErr(err)
+	assert.Equal(t, uint64(1), data.TransactionCount)
+	assert.Equal(t, uint64(1), data.PendingTxCount)
+	nonce, err := shardState.GetTransactionCount(acc1.Recipient, nil)
+	checkErr(err)
+	assert.Equal(t, uint64(0), nonce)
+	data, err = shardState.GetPendingAccountData(acc2.Recipient)
+	checkErr(err)
+	assert.Equal(t, big.NewInt(1000000), data.Balance.GetTokenBalance(genesisToken))
+
+	// the pending balance of acc2 can be spent in the pending state only
+	call := createTransferTransaction(shardState, id2.GetKey().Bytes(), acc2, acc3, big.NewInt(100), &fakeGas, nil, nil, nil, nil, nil)
+	_, err = shardState.ExecuteTx(context.Background(), call, &acc2, nil, nil)
+	assert.Error(t, err)
+	_, err = shardState.ExecutePendingTx(context.Background(), call, &acc2, nil)
+	assert.NoError(t, err)
+
+	// the pending block is created again once the txs are mined
+	b, err := shardState.CreateBlockToMine(nil, &acc1, nil, nil, nil)
+	checkErr(err)
+	_, _, err = shardState.FinalizeAndAddBlock(b)
+	checkErr(err)
+	data, err = shardState.GetPendingAccountData(acc1.Recipient)
+	checkErr(err)
+	assert.Equal(t, uint64(1), data.TransactionCount)
+	block, _, err := shardState.Pending()
+	checkErr(err)
+	assert.Equal(t, shardState.CurrentBlock().Hash(), block.ParentHash())
+	assert.Len(t, block.Transactions(), 0)
+
+	// the work block of the miner is the pending block, until the miner
+	// creates it again with the txs added to the pool
+	work, err := shardState.CreateBlockToMine(nil, &acc1, nil, nil, nil)
+	checkErr(err)
+	block, _, err = shardState.Pending()
+	checkErr(err)
+	assert.Equal(t, work.Hash(), block.Hash())
+	tx = createTransferTransaction(shardState, id1.GetKey().Bytes(), acc1, acc2, big.NewInt(1000000), &fakeGas, nil, nil, nil, nil, nil)
+	checkErr(shardState.AddTx(tx))
+	block, _, err = shardState.Pending()
+	checkErr(err)
+	assert.Equal(t, work.Hash(), block.Hash())
+	work, err = shardState.CreateBlockToMine(nil, &acc1, nil, nil, nil)
+	checkErr(err)
+	assert.Len(t, work.Transactions(), 1)
+	data, err = shardState.GetPendingAccountData(acc1.Recipient)
+	checkErr(err)
+	assert.Equal(t, uint64(2), data.TransactionCount)
+}
@@ -0,0 +1,97 @@
+package rawdb
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/qkcdb"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// BalanceHistoryEntry is the balances of a recipient after the block with
+// Number and Hash changed them.
+type BalanceHistoryEntry struct {
+	Number   uint64
+	Hash     common.Hash
+	Balances *types.TokenBalances
+}
+
+// WriteBalanceHistory stores the balances of the recipient after the block
+// with number and hash.
+func WriteBalanceHistory(db DatabaseWriter, recipient common.Address, number uint64, hash common.Hash, balances *types.TokenBalances) {
+	// RLP wrapped as the empty balances are serialized to nothing
+	data, err := balances.SerializeToBytes()
+	if err == nil {
+		data, err = rlp.EncodeToBytes(data)
+	}
+	if err != nil {
+		log.Crit("Failed to serialize balance history", "err", err)
+	}
+	if err := db.Put(balanceHistoryKey(recipient, number, hash), data); err != nil {
+		log.Crit("Failed to store balance history", "err", err)
+	}
+}
+
+// IterateBalanceHistory calls fn with the balances of the recipient stored at
+// or below the height number, from the highest block down, until fn returns
+// false. The entries of blocks out of the canonical chain are included.
+func IterateBalanceHistory(db qkcdb.KeyValueStore, recipient common.Address, number uint64, fn func(*BalanceHistoryEntry) bool) error {
+	prefix := append(append([]byte{}, balanceHistoryPrefix...), recipient.Bytes()...)
+	keyLen := len(prefix) + 8 + common.HashLength
+
+	it := db.NewIterator()
+	defer it.Close()
+	for it.SeekForPrev(balanceHistoryKey(recipient, number, maxHash)); it.Valid(); it.Prev() {
+		key := it.Key()
+		if !bytes.HasPrefix(key, prefix) {
+			break
+		}
+		// keys of other kinds may share the prefix, e.g. the blocks
+		if len(key) != keyLen {
+			continue
+		}
+		var data []byte
+		if err := rlp.DecodeBytes(it.Value(), &data); err != nil {
+			return err
+		}
+		balances, err := types.NewTokenBalances(data)
+		if err != nil {
+			return err
+		}
+		entry := &BalanceHistoryEntry{
+			Number:   binary.BigEndian.Uint64(key[len(prefix):]),
+			Hash:     common.BytesToHash(key[len(prefix)+8:]),
+			Balances: balances,
+		}
+		if !fn(entry) {
+			break
+		}
+	}
+	return it.Err()
+}
+
+var maxHash = common.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+
+// ReadBalanceHistoryStart retrieves the number and hash of the first block the
+// balance history of the recipient is indexed from, of all the recipients if
+// recipient is nil. Nil is returned if it's not indexed.
+func ReadBalanceHistoryStart(db DatabaseReader, recipient *common.Address) (*uint64, common.Hash) {
+	data, _ := db.Get(balanceHistoryStartKey(recipient))
+	if len(data) != 8+common.HashLength {
+		return nil, common.Hash{}
+	}
+	number := binary.BigEndian.Uint64(data[:8])
+	return &number, common.BytesToHash(data[8:])
+}
+
+// WriteBalanceHistoryStart stores the number and hash of the first block the
+// balance history of the recipient is indexed from, of all the recipients if
+// recipient is nil.
+func WriteBalanceHistoryStart(db DatabaseWriter, recipient *common.Address, number uint64, hash common.Hash) {
+	if err := db.Put(balanceHistoryStartKey(recipient), append(encodeBlockNumber(number), hash.Bytes()...)); err != nil {
+		log.Crit("Failed to store balance history start", "err", err)
+	}
+}
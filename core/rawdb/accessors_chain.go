@@ -103,6 +103,24 @@ func WriteHeadFastBlockHash(db DatabaseWriter, hash common.Hash) {
 	}
 }
 
+// ReadRestoredRootHash retrieves the hash of the root block the database was
+// backed up at, if it was restored from a backup.
+func ReadRestoredRootHash(db DatabaseReader) common.Hash {
+	data, _ := db.Get(restoredRootKey)
+	if len(data) == 0 {
+		return common.Hash{}
+	}
+	return common.BytesToHash(data)
+}
+
+// WriteRestoredRootHash stores the hash of the root block the restored backup
+// was taken at.
+func WriteRestoredRootHash(db DatabaseWriter, hash common.Hash) {
+	if err := db.Put(restoredRootKey, hash.Bytes()); err != nil {
+		log.Crit("Failed to store restored root block's hash", "err", err)
+	}
+}
+
 // ReadFastTrieProgress retrieves the number of tries nodes fast synced to allow
 // reporting correct numbers across restarts.
 func ReadFastTrieProgress(db DatabaseReader) uint64 {
@@ -124,7 +142,7 @@ func WriteFastTrieProgress(db DatabaseWriter, count uint64) {
 // HasHeader verifies the existence of a block header corresponding to the hash.
 func HasHeader(db DatabaseReader, hash common.Hash) bool {
 	if has, err := db.Has(headerKey(hash)); !has || err != nil {
-		return false
+		return len(readAncient(db, freezerHashTable, hash)) != 0
 	}
 	return true
 }
@@ -132,6 +150,9 @@ func HasHeader(db DatabaseReader, hash common.Hash) bool {
 // ReadMinorBlockHeader retrieves the block header corresponding to the hash.
 func ReadMinorBlockHeader(db DatabaseReader, hash common.Hash) *types.MinorBlockHeader {
 	data, _ := db.Get(headerKey(hash))
+	if len(data) == 0 {
+		data = readAncient(db, freezerHeaderTable, hash)
+	}
 	if len(data) == 0 {
 		return nil
 	}
@@ -257,7 +278,7 @@ func DeleteRootBlockHeader(db DatabaseDeleter, hash common.Hash) {
 // HasBlock verifies the existence of a block body corresponding to the hash.
 func HasBlock(db DatabaseReader, hash common.Hash) bool {
 	if has, err := db.Has(blockKey(hash)); !has || err != nil {
-		return false
+		return len(readAncient(db, freezerHashTable, hash)) != 0
 	}
 	return true
 }
@@ -265,6 +286,9 @@ func HasBlock(db DatabaseReader, hash common.Hash) bool {
 // ReadMinorBlock retrieves the block body corresponding to the hash.
 func ReadMinorBlock(db DatabaseReader, hash common.Hash) *types.MinorBlock {
 	data, _ := db.Get(blockKey(hash))
+	if len(data) == 0 {
+		data = readAncient(db, freezerBodiesTable, hash)
+	}
 	if len(data) == 0 {
 		return nil
 	}
@@ -326,7 +350,7 @@ func DeleteBlock(db DatabaseDeleter, hash common.Hash) {
 // to a block.
 func HasReceipts(db DatabaseReader, hash common.Hash) bool {
 	if has, err := db.Has(blockReceiptsKey(hash)); !has || err != nil {
-		return false
+		return len(readAncient(db, freezerReceiptTable, hash)) != 0
 	}
 	return true
 }
@@ -335,6 +359,9 @@ func HasReceipts(db DatabaseReader, hash common.Hash) bool {
 func ReadReceipts(db DatabaseReader, hash common.Hash) types.Receipts {
 	// Retrieve the flattened receipt slice
 	data, _ := db.Get(blockReceiptsKey(hash))
+	if len(data) == 0 {
+		data = readAncient(db, freezerReceiptTable, hash)
+	}
 	if len(data) == 0 {
 		return nil
 	}
@@ -409,6 +436,39 @@ func DeleteRbCommittingHash(db DatabaseDeleter) {
 	}
 }
 
+// ReadSlaveRootAck retrieves the hash of the last root block the slave
+// acknowledged, or an empty hash if unknown.
+func ReadSlaveRootAck(db DatabaseReader, slaveID string) common.Hash {
+	data, _ := db.Get(makeSlaveRootAckKey(slaveID))
+	if len(data) == 0 {
+		return common.Hash{}
+	}
+	return common.BytesToHash(data)
+}
+
+// WriteSlaveRootAck stores the hash of the last root block the slave
+// acknowledged.
+func WriteSlaveRootAck(db DatabaseWriter, slaveID string, hash common.Hash) {
+	if err := db.Put(makeSlaveRootAckKey(slaveID), hash.Bytes()); err != nil {
+		log.Crit("Failed to store slave root ack", "err", err)
+	}
+}
+
+// ReadMasterNotifyQueue retrieves the encoded headers of the minor blocks
+// not sent to the master yet.
+func ReadMasterNotifyQueue(db DatabaseReader) []byte {
+	data, _ := db.Get(masterNotifyQueue)
+	return data
+}
+
+// WriteMasterNotifyQueue stores the encoded headers of the minor blocks not
+// sent to the master yet.
+func WriteMasterNotifyQueue(db DatabaseWriter, data []byte) {
+	if err := db.Put(masterNotifyQueue, data); err != nil {
+		log.Crit("Failed to store master notify queue", "err", err)
+	}
+}
+
 // FindCommonMinorAncestor returns the last common ancestor of two block headers
 func FindCommonMinorAncestor(db DatabaseReader, a, b *types.MinorBlockHeader) *types.MinorBlockHeader {
 	for bn := b.Number; a.Number > bn; {
@@ -657,3 +717,64 @@ func DeleteMinorBlockCommitStatus(db DatabaseDeleter, h common.Hash) {
 		log.Crit("Failed to delete commit minor block", "err", err)
 	}
 }
+
+// WriteStaleBlock marks the block as one which lost fork choice, recording
+// the local time it was detected.
+func WriteStaleBlock(db DatabaseWriter, h common.Hash, detectTime uint64) {
+	if err := db.Put(makeStaleBlockKey(h), encodeBlockNumber(detectTime)); err != nil {
+		log.Crit("Failed to store stale block", "err", err)
+	}
+}
+
+// ReadStaleBlockTime returns the time the block was detected as stale, or
+// false if the block is not marked as stale.
+func ReadStaleBlockTime(db DatabaseReader, h common.Hash) (uint64, bool) {
+	data, _ := db.Get(makeStaleBlockKey(h))
+	if len(data) != 8 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(data), true
+}
+
+func DeleteStaleBlock(db DatabaseDeleter, h common.Hash) {
+	if err := db.Delete(makeStaleBlockKey(h)); err != nil {
+		log.Crit("Failed to delete stale block", "err", err)
+	}
+}
+
+func WriteStaleBlockCount(db DatabaseWriter, count uint64) {
+	if err := db.Put(staleBlockCount, encodeBlockNumber(count)); err != nil {
+		log.Crit("Failed to store stale block count", "err", err)
+	}
+}
+
+func ReadStaleBlockCount(db DatabaseReader) uint64 {
+	data, _ := db.Get(staleBlockCount)
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+func WriteRecentStaleBlocks(db DatabaseWriter, hList *HashList) {
+	data, err := serialize.SerializeToBytes(hList)
+	if err != nil {
+		log.Crit("can not serialize HashList")
+	}
+	if err := db.Put(recentStaleBlocks, data); err != nil {
+		log.Crit("Failed to store recent stale blocks", "err", err)
+	}
+}
+
+func ReadRecentStaleBlocks(db DatabaseReader) *HashList {
+	data, _ := db.Get(recentStaleBlocks)
+	hList := new(HashList)
+	if len(data) == 0 {
+		return hList
+	}
+	if err := serialize.DeserializeFromBytes(data, hList); err != nil {
+		log.Error("ReadRecentStaleBlocks", "DeserializeFromBytes err", err)
+		return new(HashList)
+	}
+	return hList
+}
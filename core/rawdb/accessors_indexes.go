@@ -3,6 +3,8 @@
 package rawdb
 
 import (
+	"encoding/binary"
+
 	"github.com/QuarkChain/goquarkchain/core/types"
 	"github.com/QuarkChain/goquarkchain/serialize"
 	"github.com/ethereum/go-ethereum/common"
@@ -64,6 +66,26 @@ func DeleteBlockContentLookupEntry(db DatabaseDeleter, hash common.Hash) {
 	db.Delete(lookupKey(hash))
 }
 
+// ReadTxIndexTail retrieves the number of the oldest block whose transactions
+// are indexed, nil is returned if the tail is never written which means the
+// whole chain is indexed.
+func ReadTxIndexTail(db DatabaseReader) *uint64 {
+	data, _ := db.Get(txIndexTailKey)
+	if len(data) != 8 {
+		return nil
+	}
+	number := binary.BigEndian.Uint64(data)
+	return &number
+}
+
+// WriteTxIndexTail stores the number of the oldest block whose transactions
+// are indexed.
+func WriteTxIndexTail(db DatabaseWriter, number uint64) {
+	if err := db.Put(txIndexTailKey, encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store transaction index tail", "err", err)
+	}
+}
+
 // ReadMinorHeader retrieves a specific MinorHeader from the database, along with
 // its added positional metadata.
 func ReadMinorHeaderFromRootBlock(db DatabaseReader, hash common.Hash) (*types.MinorBlockHeader, common.Hash, uint32) {
@@ -125,3 +147,34 @@ func WriteBloomBits(db DatabaseWriter, bit uint, section uint64, head common.Has
 		log.Crit("Failed to store bloom bits", "err", err)
 	}
 }
+
+// ReadBloomBitsSections retrieves the number of bloom bits sections indexed.
+func ReadBloomBitsSections(db DatabaseReader) uint64 {
+	data, _ := db.Get(bloomBitsSectionsKey)
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// WriteBloomBitsSections stores the number of bloom bits sections indexed.
+func WriteBloomBitsSections(db DatabaseWriter, sections uint64) {
+	if err := db.Put(bloomBitsSectionsKey, encodeBlockNumber(sections)); err != nil {
+		log.Crit("Failed to store bloom bits sections", "err", err)
+	}
+}
+
+// ReadBloomBitsSectionHead retrieves the hash of the last block of the bloom
+// bits section, which is empty if the section is not indexed.
+func ReadBloomBitsSectionHead(db DatabaseReader, section uint64) common.Hash {
+	data, _ := db.Get(bloomBitsSectionHeadKey(section))
+	return common.BytesToHash(data)
+}
+
+// WriteBloomBitsSectionHead stores the hash of the last block of the bloom
+// bits section.
+func WriteBloomBitsSectionHead(db DatabaseWriter, section uint64, head common.Hash) {
+	if err := db.Put(bloomBitsSectionHeadKey(section), head.Bytes()); err != nil {
+		log.Crit("Failed to store bloom bits section head", "err", err)
+	}
+}
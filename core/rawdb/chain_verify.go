@@ -0,0 +1,76 @@
+package rawdb
+
+import (
+	"fmt"
+
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// VerifyMinorChain cross checks the canonical minor blocks in [first, last]
+// in db: each block is stored with its header, links to its parent, matches
+// its meta and has a receipt for every transaction. The numbers of the blocks
+// whose state is missing are returned, which is expected for pruned states.
+func VerifyMinorChain(db DatabaseReader, first, last uint64) ([]uint64, error) {
+	var (
+		missing    []uint64
+		parentHash common.Hash
+	)
+	for number := first; number <= last; number++ {
+		hash := ReadCanonicalHash(db, ChainTypeMinor, number)
+		if hash == (common.Hash{}) {
+			return missing, fmt.Errorf("minor block %d: canonical hash missing", number)
+		}
+		header := ReadMinorBlockHeader(db, hash)
+		if header == nil || header.Hash() != hash || header.Number != number {
+			return missing, fmt.Errorf("minor block %d: header %x missing or mismatched", number, hash)
+		}
+		if number != first && header.ParentHash != parentHash {
+			return missing, fmt.Errorf("minor block %d: parent %x mismatch, want %x", number, header.ParentHash, parentHash)
+		}
+		block := ReadMinorBlock(db, hash)
+		if block == nil || block.Hash() != hash {
+			return missing, fmt.Errorf("minor block %d: body %x missing or mismatched", number, hash)
+		}
+		if block.MetaHash() != block.Meta().Hash() {
+			return missing, fmt.Errorf("minor block %d: meta hash mismatch", number)
+		}
+		// the receipts of the cross shard deposits follow those of the transactions
+		if len(block.Transactions()) != 0 {
+			if receipts := ReadReceipts(db, hash); len(receipts) < len(block.Transactions()) {
+				return missing, fmt.Errorf("minor block %d: %d receipts for %d transactions", number, len(receipts), len(block.Transactions()))
+			}
+		}
+		if root := block.Meta().Root; root != types.EmptyTrieHash {
+			if has, err := db.Has(root.Bytes()); !has || err != nil {
+				missing = append(missing, number)
+			}
+		}
+		parentHash = hash
+	}
+	return missing, nil
+}
+
+// VerifyRootChain cross checks the canonical root blocks in [first, last] in
+// db: each block is stored with its header and links to its parent.
+func VerifyRootChain(db DatabaseReader, first, last uint64) error {
+	var parentHash common.Hash
+	for number := first; number <= last; number++ {
+		hash := ReadCanonicalHash(db, ChainTypeRoot, number)
+		if hash == (common.Hash{}) {
+			return fmt.Errorf("root block %d: canonical hash missing", number)
+		}
+		header := ReadRootBlockHeader(db, hash)
+		if header == nil || header.Hash() != hash || header.NumberU64() != number {
+			return fmt.Errorf("root block %d: header %x missing or mismatched", number, hash)
+		}
+		if number != first && header.ParentHash != parentHash {
+			return fmt.Errorf("root block %d: parent %x mismatch, want %x", number, header.ParentHash, parentHash)
+		}
+		if block := ReadRootBlock(db, hash); block == nil || block.Hash() != hash {
+			return fmt.Errorf("root block %d: body %x missing or mismatched", number, hash)
+		}
+		parentHash = hash
+	}
+	return nil
+}
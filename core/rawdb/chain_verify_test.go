@@ -0,0 +1,64 @@
+package rawdb
+
+import (
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// Tests that the verification of the minor chain reports the missing states
+// and fails on the missing receipts and the broken links.
+func TestVerifyMinorChain(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+
+	var blocks []*types.MinorBlock
+	parentHash := common.Hash{}
+	for i := uint64(0); i < 4; i++ {
+		meta := &types.MinorBlockMeta{Root: common.BytesToHash([]byte{byte(i + 1)})}
+		if i == 0 {
+			meta.Root = types.EmptyTrieHash
+		}
+		header := &types.MinorBlockHeader{Number: i, ParentHash: parentHash, MetaHash: meta.Hash()}
+		block := types.NewMinorBlockWithHeader(header, meta).WithBody(txs, nil)
+		WriteMinorBlock(db, block)
+		WriteCanonicalHash(db, ChainTypeMinor, block.Hash(), i)
+		WriteReceipts(db, block.Hash(), types.Receipts{{}, {}, {}})
+		// the state of block 2 is pruned
+		if i != 2 {
+			db.Put(meta.Root.Bytes(), []byte{0x01})
+		}
+		blocks = append(blocks, block)
+		parentHash = block.Hash()
+	}
+	missing, err := VerifyMinorChain(db, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(missing) != 1 || missing[0] != 2 {
+		t.Fatalf("missing states mismatch: have %v, want [2]", missing)
+	}
+	if _, err := VerifyMinorChain(db, 0, 4); err == nil {
+		t.Fatalf("non existent block verified")
+	}
+
+	WriteReceipts(db, blocks[3].Hash(), types.Receipts{{}})
+	if _, err := VerifyMinorChain(db, 0, 3); err == nil {
+		t.Fatalf("block with missing receipts verified")
+	}
+	if _, err := VerifyMinorChain(db, 0, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	// a side chain block at height 1 breaks the link of block 2
+	side := types.NewMinorBlockWithHeader(&types.MinorBlockHeader{Number: 1, MetaHash: (&types.MinorBlockMeta{}).Hash()}, &types.MinorBlockMeta{})
+	WriteMinorBlock(db, side)
+	WriteCanonicalHash(db, ChainTypeMinor, side.Hash(), 1)
+	if _, err := VerifyMinorChain(db, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyMinorChain(db, 0, 2); err == nil {
+		t.Fatalf("broken chain verified")
+	}
+}
@@ -0,0 +1,251 @@
+package rawdb
+
+import (
+	"bytes"
+	"errors"
+	"sync/atomic"
+
+	"github.com/QuarkChain/goquarkchain/qkcdb"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// errNoAncientStore is returned if the database is not wrapped with a freezer.
+var errNoAncientStore = errors.New("no ancient store")
+
+// freezerdb is a database wrapper that enables freezer data retrievals.
+type freezerdb struct {
+	ethdb.Database
+	*freezer
+}
+
+// NewDatabaseWithFreezer creates a high level database on top of a given key-
+// value data store with a freezer moving immutable chain segments into cold
+// storage in the freezer directory.
+func NewDatabaseWithFreezer(db ethdb.Database, freezer string) (ethdb.Database, error) {
+	frdb, err := newFreezer(freezer)
+	if err != nil {
+		return nil, err
+	}
+	return &freezerdb{Database: db, freezer: frdb}, nil
+}
+
+// Close implements ethdb.Database, closing both the freezer and the key-value store.
+func (frdb *freezerdb) Close() {
+	if err := frdb.freezer.Close(); err != nil {
+		log.Error("Failed to close ancient database", "err", err)
+	}
+	frdb.Database.Close()
+}
+
+// KeyValueStore returns the key-value store of db without its ancient store.
+func KeyValueStore(db ethdb.Database) ethdb.Database {
+	if frdb, ok := db.(*freezerdb); ok {
+		return frdb.Database
+	}
+	return db
+}
+
+// readAncient retrieves the frozen data of kind belonging to the block with
+// the hash, nil if db has no ancient store or the block is not frozen.
+func readAncient(db DatabaseReader, kind string, hash common.Hash) []byte {
+	ancients, ok := db.(AncientReader)
+	if !ok {
+		return nil
+	}
+	number := ReadHeaderNumber(db, hash)
+	if number == nil {
+		return nil
+	}
+	// the block might be a side chain block with the same number
+	if data, _ := ancients.Ancient(freezerHashTable, *number); common.BytesToHash(data) != hash {
+		return nil
+	}
+	data, _ := ancients.Ancient(kind, *number)
+	return data
+}
+
+// FreezeMinorBlocks moves the headers, bodies and receipts of the canonical
+// minor blocks up to limit from the key-value store into the ancient store.
+func FreezeMinorBlocks(db ethdb.Database, limit uint64) error {
+	frdb, ok := db.(*freezerdb)
+	if !ok {
+		return errNoAncientStore
+	}
+	frozen, _ := frdb.Ancients()
+	if frozen > limit {
+		return nil
+	}
+	hashes := make([]common.Hash, 0, limit-frozen+1)
+	for number := frozen; number <= limit; number++ {
+		hash := ReadCanonicalHash(frdb.Database, ChainTypeMinor, number)
+		if hash == (common.Hash{}) {
+			return errors.New("canonical hash missing")
+		}
+		header, _ := frdb.Database.Get(headerKey(hash))
+		if len(header) == 0 {
+			return errors.New("block header missing")
+		}
+		body, _ := frdb.Database.Get(blockKey(hash))
+		if len(body) == 0 {
+			return errors.New("block body missing")
+		}
+		// blocks may have no receipts stored
+		receipts, _ := frdb.Database.Get(blockReceiptsKey(hash))
+		if err := frdb.AppendAncient(number, hash.Bytes(), header, body, receipts); err != nil {
+			return err
+		}
+		hashes = append(hashes, hash)
+	}
+	if err := frdb.Sync(); err != nil {
+		return err
+	}
+	// the hash to number mapping is kept to locate the frozen blocks
+	batch := frdb.Database.NewBatch()
+	for _, hash := range hashes {
+		batch.Delete(headerKey(hash))
+		batch.Delete(blockKey(hash))
+		batch.Delete(blockReceiptsKey(hash))
+	}
+	return batch.Write()
+}
+
+// ReadAncientBlock retrieves the hash, header, body and receipts of the frozen
+// block with the number from the ancient store of db.
+func ReadAncientBlock(db ethdb.Database, number uint64) (hash, header, body, receipts []byte, err error) {
+	frdb, ok := db.(*freezerdb)
+	if !ok {
+		return nil, nil, nil, nil, errNoAncientStore
+	}
+	if hash, err = frdb.Ancient(freezerHashTable, number); err != nil {
+		return
+	}
+	if header, err = frdb.Ancient(freezerHeaderTable, number); err != nil {
+		return
+	}
+	if body, err = frdb.Ancient(freezerBodiesTable, number); err != nil {
+		return
+	}
+	receipts, err = frdb.Ancient(freezerReceiptTable, number)
+	return
+}
+
+// AppendAncientBlock appends the frozen block with the number to the ancient
+// store of db, e.g. to restore a backup, it has to follow the frozen blocks.
+// The appended blocks are flushed by SyncAncients.
+func AppendAncientBlock(db ethdb.Database, number uint64, hash, header, body, receipts []byte) error {
+	frdb, ok := db.(*freezerdb)
+	if !ok {
+		return errNoAncientStore
+	}
+	return frdb.AppendAncient(number, hash, header, body, receipts)
+}
+
+// SyncAncients flushes the ancient store of db to disk.
+func SyncAncients(db ethdb.Database) error {
+	frdb, ok := db.(*freezerdb)
+	if !ok {
+		return errNoAncientStore
+	}
+	return frdb.Sync()
+}
+
+// DatabaseStat is the number and total size of the entries of a kind of data.
+type DatabaseStat struct {
+	Kind  string
+	Count uint64
+	Size  common.StorageSize
+}
+
+// databaseKinds lists the kinds of data told apart by InspectDatabase, a zero
+// key length matches keys of any length with the prefix.
+var databaseKinds = []struct {
+	kind   string
+	prefix []byte
+	keyLen int
+}{
+	{"Headers", headerPrefix, len(headerPrefix) + common.HashLength},
+	{"Bodies", blockPrefix, len(blockPrefix) + common.HashLength},
+	{"Receipts", blockReceiptsPrefix, len(blockReceiptsPrefix) + common.HashLength},
+	{"Hash to number", headerNumberPrefix, len(headerNumberPrefix) + common.HashLength},
+	{"Root canonical hashes", rootHashPrefix, len(rootHashPrefix) + 8},
+	{"Minor canonical hashes", minorHashPrefix, len(minorHashPrefix) + 8},
+	{"Latest minor headers", latestMHeaderPrefix, len(latestMHeaderPrefix) + common.HashLength},
+	{"Tx lookups", lookupPrefix, len(lookupPrefix) + common.HashLength},
+	{"Bloom bits", bloomBitsPrefix, len(bloomBitsPrefix) + 10 + common.HashLength},
+	{"Preimages", preimagePrefix, len(preimagePrefix) + common.HashLength},
+	{"Total tx counts", totalTxKey, len(totalTxKey) + common.HashLength},
+	{"Cross shard tx lists", xShardLists, len(xShardLists) + common.HashLength},
+	{"Confirmed cross shard tx lists", xConfirmedShardKey, len(xConfirmedShardKey) + common.HashLength},
+	{"Cross shard deposit hashes", xsHashList, len(xsHashList) + common.HashLength},
+	{"Last confirmed minor headers", rLastM, len(rLastM) + common.HashLength},
+	{"Minor block coinbases", mHeader, len(mHeader) + common.HashLength},
+	{"Committed minor blocks", commitBlockByHash, len(commitBlockByHash) + common.HashLength},
+	{"Minor block counts", countMinor, len(countMinor) + 8},
+	{"Genesis blocks", genesis, len(genesis) + common.HashLength},
+	{"Stale blocks", staleBlockPrefix, len(staleBlockPrefix) + common.HashLength},
+	{"Confirming root blocks", mConfiredByRoot, len(mConfiredByRoot) + common.HashLength + 4},
+	{"Balance histories", balanceHistoryPrefix, len(balanceHistoryPrefix) + common.AddressLength + 8 + common.HashLength},
+	{"Account snapshots", SnapshotAccountPrefix, len(SnapshotAccountPrefix) + common.HashLength},
+	{"Storage snapshots", SnapshotStoragePrefix, len(SnapshotStoragePrefix) + 2*common.HashLength},
+	{"Slave root acks", slaveRootAck, 0},
+	{"Chain indexes", BloomBitsIndexPrefix, 0},
+	{"Configs", configPrefix, 0},
+}
+
+// InspectDatabase traverses the key-value store of db and returns the number
+// and size of the entries of each kind of data, the state entries and the
+// entries of unknown kind are counted last.
+func InspectDatabase(db ethdb.Database) ([]DatabaseStat, error) {
+	kvdb, ok := KeyValueStore(db).(qkcdb.KeyValueStore)
+	if !ok {
+		return nil, errors.New("only support qkcdb now")
+	}
+	stats := make([]DatabaseStat, len(databaseKinds)+2)
+	for i, kind := range databaseKinds {
+		stats[i].Kind = kind.kind
+	}
+	trieNodes, unaccounted := &stats[len(databaseKinds)], &stats[len(databaseKinds)+1]
+	trieNodes.Kind, unaccounted.Kind = "State trie nodes and codes", "Unaccounted"
+
+	it := kvdb.NewIterator()
+	defer it.Close()
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		key, size := it.Key(), common.StorageSize(len(it.Key())+len(it.Value()))
+		// the trie nodes are keyed by their hash, no other kind has such keys
+		stat := unaccounted
+		if len(key) == common.HashLength {
+			stat = trieNodes
+		} else {
+			for i, kind := range databaseKinds {
+				if bytes.HasPrefix(key, kind.prefix) && (kind.keyLen == 0 || len(key) == kind.keyLen) {
+					stat = &stats[i]
+					break
+				}
+			}
+		}
+		stat.Count++
+		stat.Size += size
+	}
+	return stats, it.Err()
+}
+
+// InspectAncients returns the number of items and the size on disk of each
+// table in the ancient store of db.
+func InspectAncients(db ethdb.Database) ([]DatabaseStat, error) {
+	frdb, ok := db.(*freezerdb)
+	if !ok {
+		return nil, errNoAncientStore
+	}
+	var stats []DatabaseStat
+	for _, name := range []string{freezerHashTable, freezerHeaderTable, freezerBodiesTable, freezerReceiptTable} {
+		table := frdb.tables[name]
+		size, err := table.size()
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, DatabaseStat{Kind: name, Count: atomic.LoadUint64(&table.items), Size: common.StorageSize(size)})
+	}
+	return stats, nil
+}
@@ -0,0 +1,86 @@
+package rawdb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/qkcdb"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// Tests that the entries of the key-value store and the ancient store are
+// counted by their kind.
+func TestInspectDatabase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inspect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	kvdb, err := qkcdb.NewRDBDatabase(filepath.Join(dir, "db"), true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := NewDatabaseWithFreezer(kvdb, filepath.Join(dir, "ancient"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := InspectDatabase(ethdb.NewMemDatabase()); err == nil {
+		t.Fatalf("inspected unsupported database")
+	}
+
+	for i := uint64(0); i < 3; i++ {
+		block := types.NewMinorBlockWithHeader(&types.MinorBlockHeader{Number: i}, &types.MinorBlockMeta{}).WithBody(txs, nil)
+		WriteMinorBlock(db, block)
+		WriteCanonicalHash(db, ChainTypeMinor, block.Hash(), i)
+		WriteReceipts(db, block.Hash(), types.Receipts{{CumulativeGasUsed: i + 1}})
+	}
+	db.Put(common.BytesToHash([]byte{0x01}).Bytes(), []byte{0x01})
+	db.Put([]byte("unknown"), []byte{0x01})
+	if err := FreezeMinorBlocks(db, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := InspectDatabase(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]uint64)
+	for _, stat := range stats {
+		counts[stat.Kind] = stat.Count
+	}
+	for kind, count := range map[string]uint64{
+		"Headers":                    2,
+		"Bodies":                     2,
+		"Receipts":                   2,
+		"Hash to number":             3,
+		"Minor canonical hashes":     3,
+		"State trie nodes and codes": 1,
+		"Unaccounted":                1,
+	} {
+		if counts[kind] != count {
+			t.Fatalf("%s count mismatch: have %d, want %d", kind, counts[kind], count)
+		}
+	}
+
+	stats, err = InspectAncients(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 4 {
+		t.Fatalf("ancient tables mismatch: have %d, want %d", len(stats), 4)
+	}
+	for _, stat := range stats {
+		if stat.Count != 1 || stat.Size == 0 {
+			t.Fatalf("ancient table %s mismatch: have %d items of %v", stat.Kind, stat.Count, stat.Size)
+		}
+	}
+	if _, err := InspectAncients(kvdb); err != errNoAncientStore {
+		t.Fatalf("ancient store error mismatch: have %v, want %v", err, errNoAncientStore)
+	}
+}
@@ -0,0 +1,173 @@
+// Modified from go-ethereum under GNU Lesser General Public License
+package rawdb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	// freezerHashTable indicates the name of the freezer canonical hash table.
+	freezerHashTable = "hashes"
+
+	// freezerHeaderTable indicates the name of the freezer header table.
+	freezerHeaderTable = "headers"
+
+	// freezerBodiesTable indicates the name of the freezer block body table.
+	freezerBodiesTable = "bodies"
+
+	// freezerReceiptTable indicates the name of the freezer receipts table.
+	freezerReceiptTable = "receipts"
+)
+
+// freezerNoSnappy configures whether compression is disabled for the ancient tables.
+var freezerNoSnappy = map[string]bool{
+	freezerHashTable:    true,
+	freezerHeaderTable:  false,
+	freezerBodiesTable:  false,
+	freezerReceiptTable: false,
+}
+
+// errUnknownTable is returned if the user attempts to read from a table that is
+// not tracked by the freezer.
+var errUnknownTable = errors.New("unknown table")
+
+// freezer is an append-only store of the canonical minor blocks, keeping each
+// kind of block data in a flat file table indexed by the block number, so that
+// the cold block data doesn't burden the key-value store.
+type freezer struct {
+	frozen uint64 // Number of blocks already frozen (atomic)
+
+	tables map[string]*freezerTable // Data tables for storing everything
+}
+
+// newFreezer creates a freezer instance in datadir, repairing the tables to
+// the same length if a crash happened while appending.
+func newFreezer(datadir string) (*freezer, error) {
+	if info, err := os.Lstat(datadir); !os.IsNotExist(err) && err == nil && !info.IsDir() {
+		return nil, fmt.Errorf("ancient store %s is not a directory", datadir)
+	}
+	freezer := &freezer{
+		tables: make(map[string]*freezerTable),
+	}
+	for name, noSnappy := range freezerNoSnappy {
+		table, err := newTable(datadir, name, noSnappy)
+		if err != nil {
+			freezer.Close()
+			return nil, err
+		}
+		freezer.tables[name] = table
+	}
+	if err := freezer.repair(); err != nil {
+		freezer.Close()
+		return nil, err
+	}
+	log.Info("Opened ancient database", "database", datadir, "frozen", freezer.frozen)
+	return freezer, nil
+}
+
+// Close terminates the freezer and closes all the data files.
+func (f *freezer) Close() error {
+	var errs []error
+	for _, table := range f.tables {
+		if err := table.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if errs != nil {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// HasAncient returns an indicator whether the specified ancient data exists
+// in the freezer.
+func (f *freezer) HasAncient(kind string, number uint64) (bool, error) {
+	if table := f.tables[kind]; table != nil {
+		return table.has(number), nil
+	}
+	return false, nil
+}
+
+// Ancient retrieves an ancient binary blob from the append-only immutable files.
+func (f *freezer) Ancient(kind string, number uint64) ([]byte, error) {
+	if table := f.tables[kind]; table != nil {
+		return table.Retrieve(number)
+	}
+	return nil, errUnknownTable
+}
+
+// Ancients returns the number of blocks frozen in the freezer.
+func (f *freezer) Ancients() (uint64, error) {
+	return atomic.LoadUint64(&f.frozen), nil
+}
+
+// AppendAncient injects all binary blobs belonging to the block at the end of
+// the append-only immutable table files. The tables are rolled back to the
+// previous frozen blocks if any of them fails.
+func (f *freezer) AppendAncient(number uint64, hash, header, body, receipts []byte) (err error) {
+	if frozen := atomic.LoadUint64(&f.frozen); frozen != number {
+		return errOutOrderInsertion
+	}
+	defer func() {
+		if err != nil {
+			if rerr := f.truncate(number); rerr != nil {
+				log.Crit("Failed to rollback ancient store", "number", number, "err", rerr)
+			}
+		}
+	}()
+	if err = f.tables[freezerHashTable].Append(number, hash); err != nil {
+		return err
+	}
+	if err = f.tables[freezerHeaderTable].Append(number, header); err != nil {
+		return err
+	}
+	if err = f.tables[freezerBodiesTable].Append(number, body); err != nil {
+		return err
+	}
+	if err = f.tables[freezerReceiptTable].Append(number, receipts); err != nil {
+		return err
+	}
+	atomic.AddUint64(&f.frozen, 1)
+	return nil
+}
+
+// truncate discards any recent data above the provided threshold number.
+func (f *freezer) truncate(items uint64) error {
+	for _, table := range f.tables {
+		if err := table.truncate(items); err != nil {
+			return err
+		}
+	}
+	atomic.StoreUint64(&f.frozen, items)
+	return nil
+}
+
+// Sync flushes all data tables to disk.
+func (f *freezer) Sync() error {
+	var errs []error
+	for _, table := range f.tables {
+		if err := table.Sync(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if errs != nil {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// repair truncates all data tables to the same length.
+func (f *freezer) repair() error {
+	min := uint64(0)
+	for i, name := range []string{freezerHashTable, freezerHeaderTable, freezerBodiesTable, freezerReceiptTable} {
+		if items := atomic.LoadUint64(&f.tables[name].items); i == 0 || items < min {
+			min = items
+		}
+	}
+	return f.truncate(min)
+}
@@ -0,0 +1,437 @@
+// Modified from go-ethereum under GNU Lesser General Public License
+package rawdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/golang/snappy"
+)
+
+var (
+	// errClosed is returned if an operation attempts to read from or write to the
+	// freezer table after it has already been closed.
+	errClosed = errors.New("closed")
+
+	// errOutOfBounds is returned if the item requested is not contained within the
+	// freezer table.
+	errOutOfBounds = errors.New("out of bounds")
+
+	// errOutOrderInsertion is returned if the user attempts to inject out-of-order
+	// binary blobs into the freezer.
+	errOutOrderInsertion = errors.New("the append operation is out-order")
+)
+
+// indexEntrySize is the size of an index entry on disk.
+const indexEntrySize = 6
+
+// indexEntry contains the number of the data file the item resides in, as well
+// as the offset within the file to the end of the item.
+type indexEntry struct {
+	filenum uint32 // stored as uint16 ( 2 bytes)
+	offset  uint32 // stored as uint32 ( 4 bytes)
+}
+
+// unmarshalBinary deserializes binary b into the index entry.
+func (i *indexEntry) unmarshalBinary(b []byte) {
+	i.filenum = uint32(binary.BigEndian.Uint16(b[:2]))
+	i.offset = binary.BigEndian.Uint32(b[2:6])
+}
+
+// marshalBinary serializes the index entry into binary.
+func (i *indexEntry) marshalBinary() []byte {
+	b := make([]byte, indexEntrySize)
+	binary.BigEndian.PutUint16(b[:2], uint16(i.filenum))
+	binary.BigEndian.PutUint32(b[2:6], i.offset)
+	return b
+}
+
+// freezerTable represents a single chained data table within the freezer. The
+// items are appended to data files of at most maxFileSize bytes, an index file
+// keeps the end offset of each item so it can be retrieved by its number.
+type freezerTable struct {
+	items uint64 // Number of items stored in the table (atomic)
+
+	noCompression bool   // if true, disables snappy compression
+	maxFileSize   uint32 // Max file size for data files
+	name          string
+	path          string
+
+	head      *os.File            // File descriptor for the data head of the table
+	files     map[uint32]*os.File // open files
+	headId    uint32              // number of the currently active head file
+	headBytes uint32              // number of bytes written to the head file
+	index     *os.File            // File descriptor for the index file of the table
+
+	lock sync.RWMutex // Mutex protecting the data file descriptors
+}
+
+// newTable opens a freezer table with default settings - 2G files.
+func newTable(path string, name string, noCompression bool) (*freezerTable, error) {
+	return newCustomTable(path, name, 2*1000*1000*1000, noCompression)
+}
+
+// newCustomTable opens a freezer table, creating the data and index files if
+// they are non existent. Both files are truncated to the shortest common length
+// to ensure they don't go out of sync.
+func newCustomTable(path string, name string, maxFileSize uint32, noCompression bool) (*freezerTable, error) {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, err
+	}
+	idxName := fmt.Sprintf("%s.ridx", name)
+	if !noCompression {
+		idxName = fmt.Sprintf("%s.cidx", name)
+	}
+	index, err := os.OpenFile(filepath.Join(path, idxName), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	tab := &freezerTable{
+		noCompression: noCompression,
+		maxFileSize:   maxFileSize,
+		name:          name,
+		path:          path,
+		files:         make(map[uint32]*os.File),
+		index:         index,
+	}
+	if err := tab.repair(); err != nil {
+		tab.Close()
+		return nil, err
+	}
+	return tab, nil
+}
+
+// repair cross checks the head and the index file and truncates them to be in
+// sync with each other after a potential crash.
+func (t *freezerTable) repair() error {
+	buffer := make([]byte, indexEntrySize)
+
+	// If we've just created the files, initialize the index with the 0 entry
+	stat, err := t.index.Stat()
+	if err != nil {
+		return err
+	}
+	if stat.Size() == 0 {
+		if _, err := t.index.WriteAt(buffer, 0); err != nil {
+			return err
+		}
+	}
+	// Ensure the index is a multiple of indexEntrySize bytes
+	offsetsSize := stat.Size()
+	if offsetsSize == 0 {
+		offsetsSize = indexEntrySize
+	}
+	if overflow := offsetsSize % indexEntrySize; overflow != 0 {
+		offsetsSize -= overflow
+		if err := t.index.Truncate(offsetsSize); err != nil {
+			return err
+		}
+	}
+	// Open the head file and compare its size with the last index entry
+	var lastIndex indexEntry
+	if _, err := t.index.ReadAt(buffer, offsetsSize-indexEntrySize); err != nil {
+		return err
+	}
+	lastIndex.unmarshalBinary(buffer)
+
+	if t.head, err = t.openFile(lastIndex.filenum, 0); err != nil {
+		return err
+	}
+	if stat, err = t.head.Stat(); err != nil {
+		return err
+	}
+	contentSize := stat.Size()
+	contentExp := int64(lastIndex.offset)
+
+	for contentExp != contentSize {
+		// Truncate the head file to the last offset pointer
+		if contentExp < contentSize {
+			if err := t.head.Truncate(contentExp); err != nil {
+				return err
+			}
+			contentSize = contentExp
+		}
+		// Truncate the index to point within the head file
+		if contentExp > contentSize {
+			offsetsSize -= indexEntrySize
+			if err := t.index.Truncate(offsetsSize); err != nil {
+				return err
+			}
+			var newLastIndex indexEntry
+			if _, err := t.index.ReadAt(buffer, offsetsSize-indexEntrySize); err != nil {
+				return err
+			}
+			newLastIndex.unmarshalBinary(buffer)
+
+			// We might have slipped back into an earlier head-file here
+			if newLastIndex.filenum != lastIndex.filenum {
+				if t.head, err = t.openFile(newLastIndex.filenum, 0); err != nil {
+					return err
+				}
+				if stat, err = t.head.Stat(); err != nil {
+					return err
+				}
+				contentSize = stat.Size()
+			}
+			lastIndex = newLastIndex
+			contentExp = int64(lastIndex.offset)
+		}
+	}
+	// Ensure all reparation changes have been written to disk
+	if err := t.index.Sync(); err != nil {
+		return err
+	}
+	if err := t.head.Sync(); err != nil {
+		return err
+	}
+	t.items = uint64(offsetsSize/indexEntrySize - 1)
+	t.headId = lastIndex.filenum
+	t.headBytes = uint32(contentSize)
+
+	// Open all the files before the head for reads, remove the stale ones after it
+	for i := uint32(0); i < t.headId; i++ {
+		if _, err := t.openFile(i, 0); err != nil {
+			return err
+		}
+	}
+	t.removeFilesAfter(t.headId)
+	return t.seekEnd()
+}
+
+// seekEnd moves the write positions of the index and head files to their ends.
+func (t *freezerTable) seekEnd() error {
+	if _, err := t.index.Seek(0, io.SeekEnd); err != nil {
+		return err
+	}
+	_, err := t.head.Seek(0, io.SeekEnd)
+	return err
+}
+
+// dataFileName returns the name of the data file with the given number.
+func (t *freezerTable) dataFileName(num uint32) string {
+	if t.noCompression {
+		return fmt.Sprintf("%s.%04d.rdat", t.name, num)
+	}
+	return fmt.Sprintf("%s.%04d.cdat", t.name, num)
+}
+
+// openFile returns the data file with the given number, opening it with the
+// extra flag if it's not open yet.
+func (t *freezerTable) openFile(num uint32, flag int) (*os.File, error) {
+	if f, exist := t.files[num]; exist {
+		return f, nil
+	}
+	f, err := os.OpenFile(filepath.Join(t.path, t.dataFileName(num)), os.O_RDWR|os.O_CREATE|flag, 0644)
+	if err != nil {
+		return nil, err
+	}
+	t.files[num] = f
+	return f, nil
+}
+
+// removeFilesAfter closes and deletes all the data files after num.
+func (t *freezerTable) removeFilesAfter(num uint32) {
+	for fnum, f := range t.files {
+		if fnum > num {
+			f.Close()
+			delete(t.files, fnum)
+		}
+	}
+	// data files are numbered contiguously
+	for fnum := num + 1; ; fnum++ {
+		if err := os.Remove(filepath.Join(t.path, t.dataFileName(fnum))); err != nil {
+			return
+		}
+	}
+}
+
+// truncate discards any recent data above the provided threshold number.
+func (t *freezerTable) truncate(items uint64) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if atomic.LoadUint64(&t.items) <= items {
+		return nil
+	}
+	if err := t.index.Truncate(int64(items+1) * indexEntrySize); err != nil {
+		return err
+	}
+	buffer := make([]byte, indexEntrySize)
+	if _, err := t.index.ReadAt(buffer, int64(items*indexEntrySize)); err != nil {
+		return err
+	}
+	var expected indexEntry
+	expected.unmarshalBinary(buffer)
+
+	// We might need to truncate back to older files
+	if expected.filenum != t.headId {
+		head, err := t.openFile(expected.filenum, 0)
+		if err != nil {
+			return err
+		}
+		t.removeFilesAfter(expected.filenum)
+		t.head = head
+		t.headId = expected.filenum
+	}
+	if err := t.head.Truncate(int64(expected.offset)); err != nil {
+		return err
+	}
+	t.headBytes = expected.offset
+	atomic.StoreUint64(&t.items, items)
+	return t.seekEnd()
+}
+
+// Append injects a binary blob at the end of the freezer table. The item must
+// be the number of the next item, the blob is compressed unless it's disabled
+// for the table.
+func (t *freezerTable) Append(item uint64, blob []byte) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.index == nil {
+		return errClosed
+	}
+	if atomic.LoadUint64(&t.items) != item {
+		return errOutOrderInsertion
+	}
+	if !t.noCompression {
+		blob = snappy.Encode(nil, blob)
+	}
+	bLen := uint32(len(blob))
+	if t.headBytes > 0 && (t.headBytes+bLen < bLen || t.headBytes+bLen > t.maxFileSize) {
+		// The head file is full, open a new one
+		nextID := t.headId + 1
+		head, err := t.openFile(nextID, os.O_TRUNC)
+		if err != nil {
+			return err
+		}
+		if err := t.head.Sync(); err != nil {
+			return err
+		}
+		t.head = head
+		t.headBytes = 0
+		t.headId = nextID
+	}
+	if _, err := t.head.Write(blob); err != nil {
+		return err
+	}
+	t.headBytes += bLen
+	idx := indexEntry{filenum: t.headId, offset: t.headBytes}
+	if _, err := t.index.Write(idx.marshalBinary()); err != nil {
+		return err
+	}
+	atomic.AddUint64(&t.items, 1)
+	return nil
+}
+
+// Retrieve looks up the data offset of an item with the given number and
+// retrieves the raw binary blob from the data file.
+func (t *freezerTable) Retrieve(item uint64) ([]byte, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if t.index == nil {
+		return nil, errClosed
+	}
+	if atomic.LoadUint64(&t.items) <= item {
+		return nil, errOutOfBounds
+	}
+	buffer := make([]byte, 2*indexEntrySize)
+	if _, err := t.index.ReadAt(buffer, int64(item*indexEntrySize)); err != nil {
+		return nil, err
+	}
+	var startIdx, endIdx indexEntry
+	startIdx.unmarshalBinary(buffer[:indexEntrySize])
+	endIdx.unmarshalBinary(buffer[indexEntrySize:])
+
+	// The item starts at the beginning of a new data file
+	startOffset := startIdx.offset
+	if startIdx.filenum != endIdx.filenum {
+		startOffset = 0
+	}
+	dataFile, exist := t.files[endIdx.filenum]
+	if !exist {
+		return nil, fmt.Errorf("missing data file %d", endIdx.filenum)
+	}
+	blob := make([]byte, endIdx.offset-startOffset)
+	if _, err := dataFile.ReadAt(blob, int64(startOffset)); err != nil {
+		return nil, err
+	}
+	if t.noCompression {
+		return blob, nil
+	}
+	return snappy.Decode(nil, blob)
+}
+
+// has returns an indicator whether the specified item number data exists.
+func (t *freezerTable) has(number uint64) bool {
+	return atomic.LoadUint64(&t.items) > number
+}
+
+// size returns the total size of the index and data files of the table.
+func (t *freezerTable) size() (uint64, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if t.index == nil {
+		return 0, errClosed
+	}
+	stat, err := t.index.Stat()
+	if err != nil {
+		return 0, err
+	}
+	total := uint64(stat.Size())
+	for num := uint32(0); num <= t.headId; num++ {
+		stat, err := os.Stat(filepath.Join(t.path, t.dataFileName(num)))
+		if err != nil {
+			return 0, err
+		}
+		total += uint64(stat.Size())
+	}
+	return total, nil
+}
+
+// Sync pushes any pending data from memory out to disk.
+func (t *freezerTable) Sync() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.index == nil {
+		return errClosed
+	}
+	if err := t.index.Sync(); err != nil {
+		return err
+	}
+	return t.head.Sync()
+}
+
+// Close closes all opened files.
+func (t *freezerTable) Close() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var errs []error
+	if t.index != nil {
+		if err := t.index.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		t.index = nil
+	}
+	for _, f := range t.files {
+		if err := f.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	t.files = make(map[uint32]*os.File)
+	t.head = nil
+	if errs != nil {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package rawdb
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// getChunk returns a chunk of data of length size filled with b.
+func getChunk(size int, b byte) []byte {
+	return bytes.Repeat([]byte{b}, size)
+}
+
+// Tests that the freezer table items spread over several data files survive
+// reopening, truncation and a crash in the middle of an append.
+func TestFreezerTable(t *testing.T) {
+	for _, noCompression := range []bool{true, false} {
+		dir, err := ioutil.TempDir("", "freezer")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		// 15 bytes items in 50 bytes files
+		table, err := newCustomTable(dir, "test", 50, noCompression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 255; i++ {
+			if err := table.Append(uint64(i), getChunk(15, byte(i))); err != nil {
+				t.Fatalf("failed to append item %d: %v", i, err)
+			}
+		}
+		if err := table.Append(0, getChunk(15, 0)); err != errOutOrderInsertion {
+			t.Fatalf("out of order append error mismatch: have %v, want %v", err, errOutOrderInsertion)
+		}
+		table.Close()
+
+		table, err = newCustomTable(dir, "test", 50, noCompression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 255; i++ {
+			if blob, err := table.Retrieve(uint64(i)); err != nil || !bytes.Equal(blob, getChunk(15, byte(i))) {
+				t.Fatalf("item %d mismatch: %x, %v", i, blob, err)
+			}
+		}
+		if _, err := table.Retrieve(255); err != errOutOfBounds {
+			t.Fatalf("out of bounds error mismatch: have %v, want %v", err, errOutOfBounds)
+		}
+
+		// Truncate back into an earlier data file and append again
+		if err := table.truncate(100); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := table.Retrieve(100); err != errOutOfBounds {
+			t.Fatalf("truncated item returned: %v", err)
+		}
+		if err := table.Append(100, getChunk(15, 0xff)); err != nil {
+			t.Fatal(err)
+		}
+		if blob, _ := table.Retrieve(100); !bytes.Equal(blob, getChunk(15, 0xff)) {
+			t.Fatalf("item 100 mismatch: %x", blob)
+		}
+		table.Close()
+
+		// Cut the index in the middle of the last entry, the last item is dropped
+		idxName := filepath.Join(dir, "test.ridx")
+		if !noCompression {
+			idxName = filepath.Join(dir, "test.cidx")
+		}
+		stat, err := os.Stat(idxName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Truncate(idxName, stat.Size()-2); err != nil {
+			t.Fatal(err)
+		}
+		table, err = newCustomTable(dir, "test", 50, noCompression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if table.items != 100 {
+			t.Fatalf("item count mismatch after repair: have %d, want %d", table.items, 100)
+		}
+		if blob, err := table.Retrieve(99); err != nil || !bytes.Equal(blob, getChunk(15, 99)) {
+			t.Fatalf("item 99 mismatch: %x, %v", blob, err)
+		}
+		table.Close()
+	}
+}
+
+// Tests that the canonical minor blocks moved into the ancient store can still
+// be read by hash while their side chain siblings can not.
+func TestFreezeMinorBlocks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ancient")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDatabaseWithFreezer(ethdb.NewMemDatabase(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var blocks []*types.MinorBlock
+	for i := uint64(0); i < 4; i++ {
+		block := types.NewMinorBlockWithHeader(&types.MinorBlockHeader{
+			Number: i,
+			Extra:  limitedSizeByes,
+		}, &types.MinorBlockMeta{}).WithBody(txs, limitedSizeByes)
+		WriteMinorBlock(db, block)
+		WriteCanonicalHash(db, ChainTypeMinor, block.Hash(), i)
+		WriteReceipts(db, block.Hash(), types.Receipts{{CumulativeGasUsed: i + 1}})
+		blocks = append(blocks, block)
+	}
+	side := types.NewMinorBlockWithHeader(&types.MinorBlockHeader{Number: 1}, &types.MinorBlockMeta{})
+	WriteMinorBlock(db, side)
+
+	if err := FreezeMinorBlocks(db, 2); err != nil {
+		t.Fatal(err)
+	}
+	if frozen, _ := db.(AncientReader).Ancients(); frozen != 3 {
+		t.Fatalf("frozen blocks mismatch: have %d, want %d", frozen, 3)
+	}
+	for i, block := range blocks {
+		if has, _ := KeyValueStore(db).Has(blockKey(block.Hash())); has != (i > 2) {
+			t.Fatalf("block %d in key-value store: %v", i, has)
+		}
+		if entry := ReadMinorBlock(db, block.Hash()); entry == nil || entry.Hash() != block.Hash() {
+			t.Fatalf("block %d mismatch: have %v", i, entry)
+		}
+		if entry := ReadMinorBlockHeader(db, block.Hash()); entry == nil || entry.Hash() != block.Hash() {
+			t.Fatalf("header %d mismatch: have %v", i, entry)
+		}
+		if rs := ReadReceipts(db, block.Hash()); len(rs) != 1 || rs[0].CumulativeGasUsed != uint64(i+1) {
+			t.Fatalf("receipts %d mismatch: have %v", i, rs)
+		}
+		if !HasBlock(db, block.Hash()) || !HasHeader(db, block.Hash()) || !HasReceipts(db, block.Hash()) {
+			t.Fatalf("block %d not found", i)
+		}
+	}
+	if entry := ReadMinorBlock(db, side.Hash()); entry == nil || entry.Hash() != side.Hash() {
+		t.Fatalf("side chain block mismatch: have %v", entry)
+	}
+	DeleteMinorBlock(db, side.Hash())
+	if HasBlock(db, side.Hash()) || ReadMinorBlock(db, side.Hash()) != nil {
+		t.Fatalf("deleted side chain block returned")
+	}
+	if HasBlock(db, common.Hash{}) {
+		t.Fatalf("non existent block returned")
+	}
+	db.Close()
+
+	// The frozen blocks survive reopening the ancient store
+	db, err = NewDatabaseWithFreezer(KeyValueStore(db), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if entry := ReadMinorBlock(db, blocks[0].Hash()); entry == nil || entry.Hash() != blocks[0].Hash() {
+		t.Fatalf("block 0 mismatch after reopen: have %v", entry)
+	}
+}
@@ -31,3 +31,10 @@ type DatabaseWriter interface {
 type DatabaseDeleter interface {
 	Delete(key []byte) error
 }
+
+// AncientReader wraps the methods reading the frozen block data of an ancient store.
+type AncientReader interface {
+	HasAncient(kind string, number uint64) (bool, error)
+	Ancient(kind string, number uint64) ([]byte, error)
+	Ancients() (uint64, error)
+}
@@ -22,9 +22,21 @@ var (
 	headFastBlockKey = []byte("LastFast")
 	rbCommittingKey  = []byte("rbCommitting")
 
+	// txIndexTailKey tracks the oldest block whose transaction indexes are kept.
+	txIndexTailKey = []byte("TransactionIndexTail")
+
 	// fastTrieProgressKey tracks the number of trie entries imported during fast sync.
 	fastTrieProgressKey = []byte("TrieSync")
 
+	// snapshotRootKey tracks the state root of the persisted state snapshot.
+	snapshotRootKey = []byte("SnapshotRoot")
+
+	// snapshotGeneratorKey tracks the progress of the state snapshot generation.
+	snapshotGeneratorKey = []byte("SnapshotGenerator")
+
+	// restoredRootKey tracks the root block a database restored from a backup was taken at.
+	restoredRootKey = []byte("RestoredRoot")
+
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`, used for indexes).
 	headerPrefix        = []byte("h")   // headerPrefix + hash -> header
 	latestMHeaderPrefix = []byte("lmh") //latestMHeaderPrefix + hash -> latest minor header list
@@ -38,12 +50,17 @@ var (
 	lookupPrefix    = []byte("l") // lookupPrefix + hash -> transaction/receipt lookup metadata
 	bloomBitsPrefix = []byte("B") // bloomBitsPrefix + bit (uint16 big endian) + section (uint64 big endian) + hash -> bloom bits
 
+	SnapshotAccountPrefix = []byte("a") // SnapshotAccountPrefix + account hash -> account trie value
+	SnapshotStoragePrefix = []byte("o") // SnapshotStoragePrefix + account hash + storage hash -> storage trie value
+
 	preimagePrefix = []byte("secure-key-")      // preimagePrefix + hash -> preimage
 	configPrefix   = []byte("ethereum-config-") // config prefix for the db
 
 	// Chain index prefixes (use `i` + single byte to avoid mixing data types).
 	BloomBitsIndexPrefix = []byte("iB") // BloomBitsIndexPrefix is the data table of a chain indexer to track its progress
 
+	bloomBitsSectionsKey = append(append([]byte{}, BloomBitsIndexPrefix...), []byte("count")...) // number of indexed bloom bits sections
+
 	totalTxKey         = []byte("txC") // total tx count
 	xConfirmedShardKey = []byte("xr")  //ConfirmedCrossShardTxList
 	xShardLists        = []byte("xSL") // CrossShardTxList
@@ -53,7 +70,15 @@ var (
 	mHeader            = []byte("mhC")  //mHeader coinbase
 	commitBlockByHash  = []byte("cmB")  //CommittedMinorBlock
 	xsHashList         = []byte("xd")
-	mConfiredByRoot    = []byte("mr") //key:mHash value rHash
+	mConfiredByRoot    = []byte("mr")  //key:mHash value rHash
+	staleBlockPrefix   = []byte("sb")  //key:hash value detect time
+	staleBlockCount    = []byte("sbC") //number of blocks which lost fork choice
+	recentStaleBlocks  = []byte("sbR") //hash list of the latest stale blocks
+	slaveRootAck       = []byte("sRA") //key:slave id value hash of the last root block added by the slave
+	masterNotifyQueue  = []byte("mNQ") //headers of the minor blocks not sent to the master yet
+
+	balanceHistoryPrefix      = []byte("bH") // balanceHistoryPrefix + recipient + num (uint64 big endian) + hash -> token balances
+	balanceHistoryStartPrefix = []byte("bS") // balanceHistoryStartPrefix (+ recipient) -> num (uint64 big endian) + hash of the first indexed block
 )
 
 type ChainType byte
@@ -83,6 +108,37 @@ func encodeUint32(number uint32) []byte {
 	return enc
 }
 
+// accountSnapshotKey = SnapshotAccountPrefix + hash
+func accountSnapshotKey(hash common.Hash) []byte {
+	return append(append([]byte{}, SnapshotAccountPrefix...), hash.Bytes()...)
+}
+
+// storageSnapshotKey = SnapshotStoragePrefix + account hash + storage hash
+func storageSnapshotKey(accountHash, storageHash common.Hash) []byte {
+	return append(append(append([]byte{}, SnapshotStoragePrefix...), accountHash.Bytes()...), storageHash.Bytes()...)
+}
+
+// storageSnapshotsKey = SnapshotStoragePrefix + account hash
+func storageSnapshotsKey(accountHash common.Hash) []byte {
+	return append(append([]byte{}, SnapshotStoragePrefix...), accountHash.Bytes()...)
+}
+
+// balanceHistoryKey = balanceHistoryPrefix + recipient + num (uint64 big endian) + hash
+func balanceHistoryKey(recipient common.Address, number uint64, hash common.Hash) []byte {
+	key := append(append([]byte{}, balanceHistoryPrefix...), recipient.Bytes()...)
+	return append(append(key, encodeBlockNumber(number)...), hash.Bytes()...)
+}
+
+// balanceHistoryStartKey = balanceHistoryStartPrefix + recipient, the key of
+// all the recipients is the prefix alone
+func balanceHistoryStartKey(recipient *common.Address) []byte {
+	key := append([]byte{}, balanceHistoryStartPrefix...)
+	if recipient != nil {
+		key = append(key, recipient.Bytes()...)
+	}
+	return key
+}
+
 // headerKey = headerPrefix + hash
 func headerKey(hash common.Hash) []byte {
 	return append(headerPrefix, hash.Bytes()...)
@@ -122,6 +178,11 @@ func lookupKey(hash common.Hash) []byte {
 	return append(lookupPrefix, hash.Bytes()...)
 }
 
+// bloomBitsSectionHeadKey = BloomBitsIndexPrefix + "shead" + section (uint64 big endian)
+func bloomBitsSectionHeadKey(section uint64) []byte {
+	return append(append(append([]byte{}, BloomBitsIndexPrefix...), []byte("shead")...), encodeBlockNumber(section)...)
+}
+
 // bloomBitsKey = bloomBitsPrefix + bit (uint16 big endian) + section (uint64 big endian) + hash
 func bloomBitsKey(bit uint, section uint64, hash common.Hash) []byte {
 	key := append(append(bloomBitsPrefix, make([]byte, 10)...), hash.Bytes()...)
@@ -184,3 +245,11 @@ func makeCommitMinorBlock(h common.Hash) []byte {
 	data := append(commitBlockByHash, h.Bytes()...)
 	return data
 }
+
+func makeStaleBlockKey(h common.Hash) []byte {
+	return append(staleBlockPrefix, h.Bytes()...)
+}
+
+func makeSlaveRootAckKey(slaveID string) []byte {
+	return append(append([]byte{}, slaveRootAck...), slaveID...)
+}
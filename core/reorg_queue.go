@@ -0,0 +1,49 @@
+package core
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// reorgQueueSize is the number of the reorg events waiting for their
+// subscribers, beyond which the new ones are dropped.
+const reorgQueueSize = 64
+
+type reorgPost struct {
+	feed *event.Feed
+	ev   interface{}
+}
+
+// reorgQueue sends the reorg events to their feeds in order, in the background
+// so that the insertion of the blocks doesn't wait for the subscribers.
+type reorgQueue struct {
+	posts chan reorgPost
+}
+
+func newReorgQueue() *reorgQueue {
+	return &reorgQueue{posts: make(chan reorgPost, reorgQueueSize)}
+}
+
+// post queues the event for the feed without blocking.
+func (q *reorgQueue) post(feed *event.Feed, ev interface{}) {
+	select {
+	case q.posts <- reorgPost{feed: feed, ev: ev}:
+	default:
+		log.Warn("Reorg subscribers are behind, dropping reorg event")
+	}
+}
+
+// loop sends the events queued until quit is closed.
+func (q *reorgQueue) loop(quit <-chan struct{}, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for {
+		select {
+		case p := <-q.posts:
+			p.feed.Send(p.ev)
+		case <-quit:
+			return
+		}
+	}
+}
@@ -53,6 +53,11 @@ type CacheConfig struct {
 	TrieCleanLimit int           // Memory allowance (MB) to use for caching trie nodes in memory
 	TrieDirtyLimit int           // Memory limit (MB) at which to start flushing dirty trie nodes to disk
 	TrieTimeLimit  time.Duration // Time limit after which to flush the current in-memory trie to disk
+	Snapshot       bool          // Whether to maintain the flat state snapshot for the state reads
+	Prefetch       bool          // Whether to read the accounts of the txs of a block ahead of running them
+
+	BalanceHistory           bool             // Whether to index the balances of the accounts changed by each block
+	BalanceHistoryRecipients []common.Address // Recipients whose balance history is indexed, all of them if empty
 }
 
 // RootBlockChain represents the canonical chain given a database with a genesis
@@ -83,6 +88,8 @@ type RootBlockChain struct {
 	chainSideFeed            event.Feed
 	chainHeadFeed            event.Feed
 	logsFeed                 event.Feed
+	reorgFeed                event.Feed
+	reorgs                   *reorgQueue
 	scope                    event.SubscriptionScope
 	genesisBlock             *types.RootBlock
 
@@ -92,6 +99,7 @@ type RootBlockChain struct {
 
 	checkpoint   int          // checkpoint counts towards the new checkpoint
 	currentBlock atomic.Value // Current head of the block chain
+	finalized    uint64       // Height of the last finalized block, must be accessed atomically
 
 	blockCache          *lru.Cache // Cache for the most recent entire blocks
 	futureBlocks        *lru.Cache // future blocks are blocks added for later processing
@@ -103,14 +111,17 @@ type RootBlockChain struct {
 	procInterrupt int32          // interrupt signaler for block processing
 	wg            sync.WaitGroup // chain processing wait group for shutting down
 
-	engine    consensus.Engine
-	validator Validator // block and state validator interface
+	engine     consensus.Engine
+	validator  Validator      // block and state validator interface
+	forkChoice RootForkChoice // decides which head is canonical
 
 	countMinorBlocks    bool
 	addBlockAndBroad    func(block *types.RootBlock) error
 	isCheckDB           bool
 	posw                consensus.PoSWCalculator
 	rootChainStakesFunc func(address account.Address, lastMinor common.Hash) (*big.Int, *account.Recipient, error)
+	staleBlocks         *staleBlockTracker
+	rootBlockSigner     func(header *types.RootBlockHeader) ([]byte, error) // signs instead of RootSignerPrivateKey if set
 }
 
 // NewBlockChain returns a fully initialized block chain using information
@@ -126,14 +137,17 @@ func NewRootBlockChain(db ethdb.Database, chainConfig *config.QuarkChainConfig,
 		db:                       db,
 		triegc:                   prque.New(nil),
 		quit:                     make(chan struct{}),
+		reorgs:                   newReorgQueue(),
 		blockCache:               blockCache,
 		coinbaseAmountCache:      make(map[uint64]*big.Int),
 		futureBlocks:             futureBlocks,
 		engine:                   engine,
 		validatedMinorBlockCache: validatedMinorBlockHashCache,
 		isCheckDB:                false,
+		staleBlocks:              newStaleBlockTracker(db),
 	}
 	bc.SetValidator(NewRootBlockValidator(chainConfig, bc, engine))
+	bc.SetForkChoice(NewRootForkChoice(chainConfig))
 	bc.posw = posw.NewPoSW(bc, chainConfig.Root.PoSWConfig)
 	var err error
 	if err != nil {
@@ -150,6 +164,8 @@ func NewRootBlockChain(db ethdb.Database, chainConfig *config.QuarkChainConfig,
 	}
 	// Take ownership of this particular state
 	go bc.update()
+	bc.wg.Add(1)
+	go bc.reorgs.loop(bc.quit, &bc.wg)
 	return bc, nil
 }
 
@@ -185,6 +201,7 @@ func (bc *RootBlockChain) loadLastState() error {
 	}
 	// Everything seems to be fine, set as the head block
 	bc.currentBlock.Store(currentBlock)
+	atomic.StoreUint64(&bc.finalized, bc.finalizedNumberAt(currentBlock.NumberU64()))
 	return nil
 }
 
@@ -225,6 +242,28 @@ func (bc *RootBlockChain) CurrentBlock() *types.RootBlock {
 	return bc.currentBlock.Load().(*types.RootBlock)
 }
 
+// FinalizedNumber returns the height of the last finalized root block, the
+// canonical blocks up to this height can no longer be reorganized.
+func (bc *RootBlockChain) FinalizedNumber() uint64 {
+	return atomic.LoadUint64(&bc.finalized)
+}
+
+// finalizedNumberAt returns the finalized height for the tip of the height.
+func (bc *RootBlockChain) finalizedNumberAt(number uint64) uint64 {
+	maxDepth := bc.Config().Root.MaxReorgDepth
+	if maxDepth == 0 || number <= maxDepth {
+		return 0
+	}
+	return number - maxDepth
+}
+
+// SetForkChoice sets the rule which is used to choose the canonical chain.
+func (bc *RootBlockChain) SetForkChoice(forkChoice RootForkChoice) {
+	bc.procmu.Lock()
+	defer bc.procmu.Unlock()
+	bc.forkChoice = forkChoice
+}
+
 // SetValidator sets the validator which is used to validate incoming blocks.
 func (bc *RootBlockChain) SetValidator(validator Validator) {
 	bc.procmu.Lock()
@@ -328,6 +367,10 @@ func (bc *RootBlockChain) insert(block *types.RootBlock) {
 
 	rawdb.WriteHeadBlockHash(bc.db, block.Hash())
 	bc.currentBlock.Store(block)
+	// The finalized height never decreases, even if the new head is lower
+	if number := bc.finalizedNumberAt(block.NumberU64()); number > atomic.LoadUint64(&bc.finalized) {
+		atomic.StoreUint64(&bc.finalized, number)
+	}
 }
 
 // Genesis retrieves the chain's genesis block.
@@ -451,27 +494,15 @@ func (bc *RootBlockChain) WriteBlockWithState(block *types.RootBlock) (status Wr
 	defer bc.mu.Unlock()
 
 	currentBlock := bc.CurrentBlock()
-	localTd := currentBlock.TotalDifficulty()
-	externTd := block.TotalDifficulty()
 
 	rawdb.WriteRootBlock(bc.db, block)
 
 	// Write other block data using a batch.
 	batch := bc.db.NewBatch()
+	var stale *staleBlockUpdate
 
-	// If the total difficulty is higher than our known, add it to the canonical chain
-	// Second clause in the if statement reduces the vulnerability to selfish mining.
-	// Please refer to http://www.cs.cornell.edu/~ie53/publications/btcProcFC.pdf
-	reorg := externTd.Cmp(localTd) > 0
-	currentBlock = bc.CurrentBlock()
-	if !reorg && externTd.Cmp(localTd) == 0 {
-		// Split same-difficulty blocks by number, then preferentially select
-		// the block generated by the local miner as the canonical block.
-		if block.NumberU64() < currentBlock.NumberU64() {
-			reorg = true
-		}
-	}
-	if reorg {
+	// If the block is preferred by the fork choice rule, add it to the canonical chain
+	if bc.forkChoice.ReorgNeeded(currentBlock.Header(), block.Header()) {
 		// Reorganise the chain if the parent is not the head block
 		if block.ParentHash() != currentBlock.Hash() {
 			if err := bc.reorg(currentBlock, block); err != nil {
@@ -480,14 +511,20 @@ func (bc *RootBlockChain) WriteBlockWithState(block *types.RootBlock) (status Wr
 		}
 		// Write the positional metadata for transaction/receipt lookups and preimages
 		rawdb.WriteBlockContentLookupEntriesWithCrossShardHashList(batch, block, nil)
+		// started after the reorg, which updates the stale table too
+		stale = bc.staleBlocks.update(batch)
+		stale.markCanonical(block.Hash())
 
 		status = CanonStatTy
 	} else {
 		status = SideStatTy
+		stale = bc.staleBlocks.update(batch)
+		stale.markStale(block.Hash())
 	}
 	if err := batch.Write(); err != nil {
 		return NonStatTy, err
 	}
+	stale.commit()
 
 	// Set new head.
 	if status == CanonStatTy {
@@ -704,8 +741,8 @@ func (bc *RootBlockChain) insertChain(chain []types.IBlock, verifySeals bool) (i
 // switch over to the new chain if the TD exceeded the current chain.
 func (bc *RootBlockChain) insertSidechain(it *insertIterator) (int, []interface{}, error) {
 	var (
-		externTd *big.Int
-		current  = bc.CurrentBlock().NumberU64()
+		externHeader types.IHeader
+		current      = bc.CurrentBlock().NumberU64()
 	)
 	// The first sidechain block error is already verified to be ErrPrunedAncestor.
 	// Since we don't import them here, we expect ErrUnknownAncestor for the remaining
@@ -731,13 +768,16 @@ func (bc *RootBlockChain) insertSidechain(it *insertIterator) (int, []interface{
 				return it.index, nil, errors.New("sidechain ghost-state attack")
 			}
 		}
-		externTd = block.IHeader().GetTotalDifficulty()
+		externHeader = block.IHeader()
 
 		if !bc.HasBlock(block.Hash()) {
 			start := time.Now()
 			if err := bc.WriteBlockWithoutState(block); err != nil {
 				return it.index, nil, err
 			}
+			stale := bc.staleBlocks.update(bc.db)
+			stale.markStale(block.Hash())
+			stale.commit()
 			log.Debug("Inserted sidechain block", "number", block.NumberU64(), "hash", block.Hash(),
 				"diff", block.IHeader().GetDifficulty(), "elapsed", common.PrettyDuration(time.Since(start)),
 				"Headers", len(block.Content()))
@@ -747,11 +787,12 @@ func (bc *RootBlockChain) insertSidechain(it *insertIterator) (int, []interface{
 	// either on some other error or all were processed. If there was some other
 	// error, we can ignore the rest of those blocks.
 	//
-	// If the externTd was larger than our local TD, we now need to reimport the previous
-	// blocks to regenerate the required state
-	localTd := bc.CurrentBlock().TotalDifficulty()
-	if localTd.Cmp(externTd) > 0 {
-		log.Info("Sidechain written to disk", "start", it.first().NumberU64(), "end", it.previous().NumberU64(), "sidetd", externTd, "localtd", localTd)
+	// If the sidechain is preferred by the fork choice rule, we now need to reimport
+	// the previous blocks to regenerate the required state
+	localHeader := bc.CurrentBlock().Header()
+	if !bc.forkChoice.ReorgNeeded(localHeader, externHeader) {
+		log.Info("Sidechain written to disk", "start", it.first().NumberU64(), "end", it.previous().NumberU64(),
+			"sidetd", externHeader.GetTotalDifficulty(), "localtd", localHeader.GetTotalDifficulty())
 		return it.index, nil, err
 	}
 	// Gather all the sidechain hashes (full blocks may be memory heavy)
@@ -839,6 +880,7 @@ func (bc *RootBlockChain) reorg(oldBlock, newBlock types.IBlock) error {
 
 		oldChain = append(oldChain, oldBlock)
 		newChain = append(newChain, newBlock)
+		deletedHeaders = append(deletedHeaders, oldBlock.(*types.RootBlock).MinorBlockHeaders()...)
 
 		oldBlock, newBlock = bc.GetBlock(oldBlock.ParentHash()), bc.GetBlock(newBlock.ParentHash())
 		if oldBlock == nil {
@@ -848,6 +890,11 @@ func (bc *RootBlockChain) reorg(oldBlock, newBlock types.IBlock) error {
 			return fmt.Errorf("Invalid new chain")
 		}
 	}
+	if finalized := bc.FinalizedNumber(); commonBlock.NumberU64() < finalized {
+		log.Warn("Rejected deep reorg", "number", commonBlock.NumberU64(), "hash", commonBlock.Hash(),
+			"finalized", finalized, "drop", len(oldChain), "add", len(newChain))
+		return ErrReorgTooDeep
+	}
 	// Ensure the user sees large reorgs
 	if len(oldChain) > 0 && len(newChain) > 0 {
 		logFn := log.Debug
@@ -876,19 +923,44 @@ func (bc *RootBlockChain) reorg(oldBlock, newBlock types.IBlock) error {
 	for _, item := range diff {
 		rawdb.DeleteBlockContentLookupEntry(batch, item.Hash())
 	}
-	batch.Write()
-
+	stale := bc.staleBlocks.update(batch)
+	for _, block := range oldChain {
+		stale.markStale(block.Hash())
+	}
+	for _, block := range newChain {
+		stale.markCanonical(block.Hash())
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	stale.commit()
 	if len(oldChain) > 0 {
 		go func() {
 			for _, block := range oldChain {
 				bc.chainSideFeed.Send(RootChainSideEvent{Block: block.(*types.RootBlock)})
 			}
 		}()
+		bc.reorgs.post(&bc.reorgFeed, newRootChainReorgEvent(commonBlock, oldChain, newChain, diff))
 	}
 
 	return nil
 }
 
+// newRootChainReorgEvent collects the details of a reorg for the subscribers.
+func newRootChainReorgEvent(commonBlock types.IBlock, oldChain, newChain []types.IBlock, dropped types.MinorBlockHeaders) RootChainReorgEvent {
+	ev := RootChainReorgEvent{Depth: uint64(len(oldChain)), DroppedHeaders: dropped}
+	if commonBlock != nil {
+		ev.CommonBlock = commonBlock.(*types.RootBlock)
+	}
+	for _, block := range oldChain {
+		ev.OldChain = append(ev.OldChain, block.(*types.RootBlock))
+	}
+	for _, block := range newChain {
+		ev.NewChain = append(ev.NewChain, block.(*types.RootBlock))
+	}
+	return ev
+}
+
 // PostChainEvents iterates over the events generated by a chain insertion and
 // posts them into the event feed.
 // TODO: Should not expose PostChainEvents. The chain events should be posted in WriteBlock.
@@ -1202,6 +1274,11 @@ func (bc *RootBlockChain) SubscribeChainSideEvent(ch chan<- RootChainSideEvent)
 	return bc.scope.Track(bc.chainSideFeed.Subscribe(ch))
 }
 
+// SubscribeReorgEvent registers a subscription of RootChainReorgEvent.
+func (bc *RootBlockChain) SubscribeReorgEvent(ch chan<- RootChainReorgEvent) event.Subscription {
+	return bc.scope.Track(bc.reorgFeed.Subscribe(ch))
+}
+
 func (bc *RootBlockChain) CreateBlockToMine(mHeaderList []*types.MinorBlockHeader, address *account.Address, createTime *uint64) (*types.RootBlock, error) {
 	if address == nil {
 		a := account.CreatEmptyAddress(0)
@@ -1235,7 +1312,13 @@ func (bc *RootBlockChain) CreateBlockToMine(mHeaderList []*types.MinorBlockHeade
 		}
 	}
 	block.Finalize(coinbaseToken, address, common.Hash{})
-	if len(bc.chainConfig.RootSignerPrivateKey) > 0 {
+	if bc.rootBlockSigner != nil {
+		signature, err := bc.rootBlockSigner(block.Header())
+		if err != nil {
+			return nil, err
+		}
+		block.SetSignature(signature)
+	} else if len(bc.chainConfig.RootSignerPrivateKey) > 0 {
 		prvKey, err := crypto.ToECDSA(bc.chainConfig.RootSignerPrivateKey)
 		if err != nil {
 			return nil, err
@@ -1337,6 +1420,10 @@ func (bc *RootBlockChain) AddBlock(block types.IBlock) error {
 
 func (bc *RootBlockChain) PutRootBlockIndex(block *types.RootBlock) error {
 	rawdb.WriteCanonicalHash(bc.db, rawdb.ChainTypeRoot, block.Hash(), block.NumberU64())
+	for _, header := range block.MinorBlockHeaders() {
+		blockID := encoder.IDEncoder(header.Hash().Bytes(), header.Branch.GetFullShardID())
+		bc.PutRootBlockConfirmingMinorBlock(blockID, block.Hash())
+	}
 
 	if !bc.countMinorBlocks {
 		return nil
@@ -1360,8 +1447,6 @@ func (bc *RootBlockChain) PutRootBlockIndex(block *types.RootBlock) error {
 			shardRecipientCnt[fullShardID] = make(map[account.Recipient]uint32)
 		}
 		shardRecipientCnt[fullShardID][recipient] = newCount
-		blockID := encoder.IDEncoder(header.Hash().Bytes(), fullShardID)
-		bc.PutRootBlockConfirmingMinorBlock(blockID, block.Hash())
 	}
 	for fullShardID, infoList := range shardRecipientCnt {
 		dataToDb := new(account.CoinbaseStatses)
@@ -1427,6 +1512,28 @@ func (bc *RootBlockChain) GetRootBlockConfirmingMinorBlock(blockID []byte) commo
 	return rawdb.GetRootBlockConfirmingMinorBlock(bc.db, blockID)
 }
 
+// GetCanonicalRootBlockConfirmingMinorBlock returns the canonical root block
+// confirming the minor block, nil if none does yet. The root block stored for
+// the minor block is the last one confirming it made canonical, so the minor
+// block is not confirmed if it was reorged out since.
+func (bc *RootBlockChain) GetCanonicalRootBlockConfirmingMinorBlock(mHash common.Hash, fullShardID uint32) *types.RootBlock {
+	rHash := rawdb.GetRootBlockConfirmingMinorBlock(bc.db, encoder.IDEncoder(mHash.Bytes(), fullShardID))
+	if rHash == (common.Hash{}) {
+		return nil
+	}
+	block, ok := bc.GetBlock(rHash).(*types.RootBlock)
+	if !ok || rawdb.ReadCanonicalHash(bc.db, rawdb.ChainTypeRoot, block.NumberU64()) != rHash {
+		return nil
+	}
+	return block
+}
+
+// SetRootBlockSigner delegates the signing of the root blocks to mine, e.g. to
+// an external signer, so the root signer key is not in the node.
+func (bc *RootBlockChain) SetRootBlockSigner(signer func(header *types.RootBlockHeader) ([]byte, error)) {
+	bc.rootBlockSigner = signer
+}
+
 func (bc *RootBlockChain) SetRootChainStakesFunc(getRootChainStakes func(address account.Address,
 	lastMinor common.Hash) (*big.Int, *account.Recipient, error)) {
 	bc.rootChainStakesFunc = getRootChainStakes
@@ -1452,3 +1559,9 @@ func (bc *RootBlockChain) PoSWInfo(header *types.RootBlockHeader) (*rpc.PoSWInfo
 		PoswMineableBlocks:  mineable,
 	}, nil
 }
+
+// GetStaleBlocks returns the number of root blocks which lost fork choice and
+// up to limit of the latest ones, newest first.
+func (bc *RootBlockChain) GetStaleBlocks(limit uint32) (uint64, []*rpc.StaleBlock) {
+	return bc.staleBlocks.staleBlocks(limit, bc.GetHeader)
+}
@@ -304,6 +304,99 @@ func TestReorgShortBlocks(t *testing.T) {
 	testReorg(t, easy, diff, 9500000)
 }
 
+// Tests that a reorg event with the replaced blocks is posted when the root
+// chain switches to a heavier fork.
+func TestReorgEvent(t *testing.T) {
+	engine := new(consensus.FakeEngine)
+	_, blockchain, err := newCanonical(engine, 0)
+	if err != nil {
+		t.Fatalf("failed to create pristine chain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	genesis := blockchain.CurrentBlock()
+	easyBlocks := GenerateRootBlockChain(genesis, engine, 3, func(i int, b *RootBlockGen) {
+		b.SetDifficulty(10000)
+	})
+	diffBlocks := GenerateRootBlockChain(genesis, engine, 2, func(i int, b *RootBlockGen) {
+		b.SetDifficulty(20000)
+	})
+	if _, err := blockchain.InsertChain(ToBlocks(easyBlocks)); err != nil {
+		t.Fatalf("failed to insert easy chain: %v", err)
+	}
+	reorgCh := make(chan RootChainReorgEvent, 1)
+	reorgSub := blockchain.SubscribeReorgEvent(reorgCh)
+	defer reorgSub.Unsubscribe()
+	if _, err := blockchain.InsertChain(ToBlocks(diffBlocks)); err != nil {
+		t.Fatalf("failed to insert difficult chain: %v", err)
+	}
+	select {
+	case ev := <-reorgCh:
+		if ev.Depth != 3 || len(ev.OldChain) != 3 || len(ev.NewChain) != 2 {
+			t.Fatalf("reorg event mismatch: depth %d, old chain %d, new chain %d", ev.Depth, len(ev.OldChain), len(ev.NewChain))
+		}
+		if ev.OldChain[0].Hash() != easyBlocks[2].Hash() || ev.NewChain[0].Hash() != diffBlocks[1].Hash() {
+			t.Errorf("reorg event chains mismatch")
+		}
+		if ev.CommonBlock.Hash() != genesis.Hash() {
+			t.Errorf("common block mismatch: have %x, want %x", ev.CommonBlock.Hash(), genesis.Hash())
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("no reorg event posted")
+	}
+}
+
+// Tests that the reorgs replacing the finalized root blocks are rejected.
+func TestReorgDepthLimit(t *testing.T) {
+	// a config of its own, the shared one has no limit
+	cfg := config.NewQuarkChainConfig()
+	cfg.SkipRootCoinbaseCheck = true
+	cfg.Root.MaxReorgDepth = 2
+
+	engine := new(consensus.FakeEngine)
+	db := ethdb.NewMemDatabase()
+	NewGenesis(cfg).MustCommitRootBlock(db)
+	blockchain, err := NewRootBlockChain(db, cfg, engine)
+	if err != nil {
+		t.Fatalf("failed to create pristine chain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	genesis := blockchain.CurrentBlock()
+	easyBlocks := GenerateRootBlockChain(genesis, engine, 4, func(i int, b *RootBlockGen) {
+		b.SetDifficulty(10000)
+	})
+	if _, err := blockchain.InsertChain(ToBlocks(easyBlocks)); err != nil {
+		t.Fatalf("failed to insert easy chain: %v", err)
+	}
+	if have := blockchain.FinalizedNumber(); have != 2 {
+		t.Fatalf("finalized number mismatch: have %d, want 2", have)
+	}
+	// A heavier fork from the genesis replaces the finalized blocks
+	deepBlocks := GenerateRootBlockChain(genesis, engine, 2, func(i int, b *RootBlockGen) {
+		b.SetDifficulty(30000)
+	})
+	if _, err := blockchain.InsertChain(ToBlocks(deepBlocks)); err != ErrReorgTooDeep {
+		t.Fatalf("deep reorg not rejected: %v", err)
+	}
+	if blockchain.CurrentBlock().Hash() != easyBlocks[3].Hash() {
+		t.Fatalf("head changed by the rejected reorg")
+	}
+	// A heavier fork from the finalized block is accepted
+	shallowBlocks := GenerateRootBlockChain(easyBlocks[1], engine, 2, func(i int, b *RootBlockGen) {
+		b.SetDifficulty(30000)
+	})
+	if _, err := blockchain.InsertChain(ToBlocks(shallowBlocks)); err != nil {
+		t.Fatalf("failed to insert shallow fork: %v", err)
+	}
+	if blockchain.CurrentBlock().Hash() != shallowBlocks[1].Hash() {
+		t.Fatalf("head mismatch: have %x, want %x", blockchain.CurrentBlock().Hash(), shallowBlocks[1].Hash())
+	}
+	if have := blockchain.FinalizedNumber(); have != 2 {
+		t.Fatalf("finalized number mismatch: have %d, want 2", have)
+	}
+}
+
 func testReorg(t *testing.T, first, second []uint64, td int64) {
 	engine := new(consensus.FakeEngine)
 	// Create a pristine chain and database
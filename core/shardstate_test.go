@@ -1,23 +1,30 @@
 package core
 
 import (
+	"context"
 	"encoding/hex"
 	"errors"
 	"io/ioutil"
+	"math"
 	"math/big"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/QuarkChain/goquarkchain/account"
 	"github.com/QuarkChain/goquarkchain/cluster/config"
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
 	qkcCommon "github.com/QuarkChain/goquarkchain/common"
 	"github.com/QuarkChain/goquarkchain/consensus"
 	"github.com/QuarkChain/goquarkchain/core/rawdb"
+	"github.com/QuarkChain/goquarkchain/core/state"
+	"github.com/QuarkChain/goquarkchain/core/state/snapshot"
 	"github.com/QuarkChain/goquarkchain/core/types"
 	"github.com/QuarkChain/goquarkchain/core/vm"
 	"github.com/QuarkChain/goquarkchain/params"
+	"github.com/QuarkChain/goquarkchain/qkcdb"
 	"github.com/QuarkChain/goquarkchain/serialize"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -232,15 +239,23 @@ func TestEstimateGas(t *testing.T) {
 		return createTransferTransaction(shardState, id1.GetKey().Bytes(), acc1, acc2, new(big.Int).SetUint64(123456), nil, nil, nil, data, nil, nil)
 	}
 	tx := txGen([]byte{})
-	estimate, err := shardState.EstimateGas(tx, acc1)
+	estimate, err := shardState.EstimateGas(context.Background(), tx, acc1)
 	checkErr(err)
 
 	assert.Equal(t, estimate, uint32(21000))
 
 	newTx := txGen([]byte("12123478123412348125936583475758"))
-	estimate, err = shardState.EstimateGas(newTx, acc1)
+	estimate, err = shardState.EstimateGas(context.Background(), newTx, acc1)
 	checkErr(err)
 	assert.Equal(t, estimate, uint32(23176))
+
+	// the estimation is aborted once its caller has gone
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = shardState.EstimateGas(ctx, newTx, acc1)
+	assert.Equal(t, context.Canceled, err)
+	_, err = shardState.ExecuteTx(ctx, tx, &acc1, nil, nil)
+	assert.Equal(t, context.Canceled, err)
 }
 
 func TestExecuteTx(t *testing.T) {
@@ -264,8 +279,85 @@ func TestExecuteTx(t *testing.T) {
 
 	// adding this line to make sure `execute_tx` would reset `gas_used`
 	currentEvmState.SetGasUsed(currentEvmState.GetGasLimit())
-	_, err = shardState.ExecuteTx(tx, &acc1, nil)
+	_, err = shardState.ExecuteTx(context.Background(), tx, &acc1, nil, nil)
+	checkErr(err)
+}
+
+func TestExecuteTxWithOverrides(t *testing.T) {
+	id1, err := account.CreatRandomIdentity()
+	checkErr(err)
+	acc1 := account.CreatAddressFromIdentity(id1, 0)
+	fakeMoney := uint64(10000000)
+	env := setUp(&acc1, &fakeMoney, nil)
+	shardState := createDefaultShardState(env, nil, nil, nil, nil)
+	defer shardState.Stop()
+	rootBlock := shardState.rootTip.CreateBlockToAppend(nil, nil, nil, nil, nil).Finalize(nil, nil, common.Hash{})
+	_, err = shardState.AddRootBlock(rootBlock)
+	checkErr(err)
+
+	// The caller has no balance, and the contract returning its storage slot 0
+	// is not deployed
+	caller, err := account.CreatRandomAccountWithFullShardKey(0)
+	checkErr(err)
+	contract, err := account.CreatRandomAccountWithFullShardKey(0)
+	checkErr(err)
+	gas, gasPrice := uint64(50000), uint64(1)
+	tx := createTransferTransaction(shardState, id1.GetKey().Bytes(), caller, contract, big.NewInt(12345),
+		&gas, &gasPrice, nil, nil, nil, nil)
+	_, err = shardState.ExecuteTx(context.Background(), tx, &caller, nil, nil)
+	assert.Error(t, err)
+
+	code := common.FromHex("60005460005260206000f3")
+	overrides := []*rpc.AccountOverride{
+		{Address: caller.Recipient, Balances: types.NewTokenBalancesWithMap(map[uint64]*big.Int{testGenesisTokenID: big.NewInt(10000000)})},
+		{Address: contract.Recipient, Code: &code, Storage: []*rpc.StorageOverride{{Key: common.Hash{}, Value: common.BigToHash(big.NewInt(42))}}},
+	}
+	ret, err := shardState.ExecuteTx(context.Background(), tx, &caller, nil, overrides)
+	checkErr(err)
+	assert.Equal(t, common.BigToHash(big.NewInt(42)).Bytes(), ret)
+
+	// The overrides are not written to the chain state
+	value, err := shardState.GetStorageAt(contract.Recipient, common.Hash{}, nil)
+	checkErr(err)
+	assert.Equal(t, common.Hash{}, value)
+	balance, err := shardState.GetBalance(caller.Recipient, nil)
+	checkErr(err)
+	assert.Equal(t, 0, balance.GetTokenBalance(testGenesisTokenID).Sign())
+}
+
+func TestCreateAccessList(t *testing.T) {
+	id1, err := account.CreatRandomIdentity()
+	checkErr(err)
+	acc1 := account.CreatAddressFromIdentity(id1, 0)
+	fakeMoney := uint64(10000000)
+	env := setUp(&acc1, &fakeMoney, nil)
+	shardState := createDefaultShardState(env, nil, nil, nil, nil)
+	defer shardState.Stop()
+
+	// The contract loads its storage slot 1 and the balance of 0x11..11
+	tx, err := CreateContract(shardState, id1.GetKey(), acc1, 0,
+		"601c600c600039601c6000f36001545073"+strings.Repeat("11", 20)+"315000")
+	checkErr(err)
+	assert.NoError(t, shardState.AddTx(tx))
+	b, err := shardState.CreateBlockToMine(nil, nil, nil, nil, nil)
+	checkErr(err)
+	_, _, err = shardState.FinalizeAndAddBlock(b)
+	checkErr(err)
+	_, _, receipt := shardState.GetTransactionReceipt(tx.Hash())
+	assert.Equal(t, uint64(1), receipt.Status)
+	contract := account.NewAddress(receipt.ContractAddress, receipt.ContractFullShardKey)
+
+	gas, gasPrice := uint64(50000), uint64(1)
+	tx = createTransferTransaction(shardState, id1.GetKey().Bytes(), acc1, contract, new(big.Int),
+		&gas, &gasPrice, nil, nil, nil, nil)
+	accessList, gasUsed, failed, err := shardState.CreateAccessList(tx, &acc1, nil)
 	checkErr(err)
+	assert.False(t, failed)
+	assert.True(t, gasUsed > 21000)
+	assert.ElementsMatch(t, types.AccessList{
+		{Address: common.HexToAddress(strings.Repeat("11", 20)), StorageKeys: []common.Hash{}},
+		{Address: contract.Recipient, StorageKeys: []common.Hash{common.BigToHash(big.NewInt(1))}},
+	}, accessList)
 }
 
 func TestAddTxIncorrectFromShardID(t *testing.T) {
@@ -282,7 +374,7 @@ func TestAddTxIncorrectFromShardID(t *testing.T) {
 	tx := createTransferTransaction(shardState, id1.GetKey().Bytes(), acc1, acc2, new(big.Int).SetUint64(12345), nil, nil, nil, nil, nil, nil)
 	err = shardState.AddTx(tx)
 	assert.Error(t, err)
-	_, err = shardState.ExecuteTx(tx, &acc1, nil)
+	_, err = shardState.ExecuteTx(context.Background(), tx, &acc1, nil, nil)
 	assert.Error(t, err)
 }
 
@@ -948,12 +1040,20 @@ func TestXShardTxReceived(t *testing.T) {
 	rootBlock.Finalize(nil, nil, common.Hash{})
 	_, err = shardState0.AddRootBlock(rootBlock)
 	checkErr(err)
+	queue := shardState0.GetXShardQueue()
+	assert.Equal(t, uint32(1), queue.Count)
+	assert.Equal(t, value, queue.TotalValue.GetTokenBalance(tx.EvmTx.TransferTokenID()))
+	assert.Equal(t, rootBlock.NumberU64(), queue.OldestRootHeight)
+	assert.Equal(t, uint32(0), queue.MissingTxLists)
 
 	// Add b0 and make sure all x-shard tx's are added
 	b2, err := shardState0.CreateBlockToMine(nil, &acc3, nil, nil, nil)
 	checkErr(err)
 	b2, _, err = shardState0.FinalizeAndAddBlock(b2)
 	checkErr(err)
+	queue = shardState0.GetXShardQueue()
+	assert.Equal(t, uint32(0), queue.Count)
+	assert.Equal(t, uint64(0), queue.OldestRootHeight)
 	acc1Value := shardState0.currentEvmState.GetBalance(acc1.Recipient, shardState0.GetGenesisToken())
 	assert.Equal(t, int64(10000000+888888), acc1Value.Int64())
 
@@ -1309,12 +1409,28 @@ func TestShardStateAddRootBlock(t *testing.T) {
 
 	b5 := b1.CreateBlockToAppend(nil, nil, nil, nil, nil, nil, nil, nil, nil)
 
+	rootReorgCh := make(chan RootChainReorgEvent, 1)
+	rootReorgSub := shardState0.SubscribeRootReorgEvent(rootReorgCh)
+	defer rootReorgSub.Unsubscribe()
+	commonRoot := shardState0.GetRootBlockByHash(rootBlock.ParentHash())
 	_, err = shardState0.AddRootBlock(rootBlock1)
 
 	// Add one empty root block
 	emptyRoot = rootBlock1.Header().CreateBlockToAppend(nil, nil, nil, nil, nil).Finalize(nil, nil, common.Hash{})
 	_, err = shardState0.AddRootBlock(emptyRoot)
 	checkErr(err)
+	// the root tip switched to the fork of rootBlock1, no longer confirming b0
+	select {
+	case ev := <-rootReorgCh:
+		assert.Equal(t, uint64(1), ev.Depth)
+		assert.Equal(t, rootBlock.Hash(), ev.OldChain[0].Hash())
+		assert.Equal(t, []common.Hash{emptyRoot.Hash(), rootBlock1.Hash()}, []common.Hash{ev.NewChain[0].Hash(), ev.NewChain[1].Hash()})
+		assert.Equal(t, commonRoot.Hash(), ev.CommonBlock.Hash())
+		assert.Len(t, ev.DroppedHeaders, 1)
+		assert.Equal(t, b0.Hash(), ev.DroppedHeaders[0].Hash())
+	case <-time.After(time.Second):
+		t.Fatal("no root reorg event posted")
+	}
 	rootBlock2 := emptyRoot.Header().CreateBlockToAppend(nil, nil, nil, nil, nil)
 	rootBlock2.AddMinorBlockHeader(b3.Header())
 	rootBlock2.AddMinorBlockHeader(b4.Header())
@@ -2874,3 +2990,184 @@ func TestSigToAddr(t *testing.T) {
 	_, err = state0.AddRootBlock(rootBlock)
 	assert.NoError(t, err)
 }
+
+func TestStateSnapshot(t *testing.T) {
+	dirname, err := ioutil.TempDir(os.TempDir(), "snapshot_test_")
+	checkErr(err)
+	defer os.RemoveAll(dirname)
+	db, err := qkcdb.NewLDBDatabase(dirname, false, false)
+	checkErr(err)
+	defer db.Close()
+
+	id1, err := account.CreatRandomIdentity()
+	checkErr(err)
+	acc1 := account.CreatAddressFromIdentity(id1, 0)
+	acc2, err := account.CreatRandomAccountWithFullShardKey(0)
+	checkErr(err)
+	acc3, err := account.CreatRandomAccountWithFullShardKey(0)
+	checkErr(err)
+
+	fakeMoney := uint64(10000000)
+	env := setUp(&acc1, &fakeMoney, nil)
+	env.db = db
+	shardState := createDefaultShardState(env, nil, nil, nil, nil)
+	defer shardState.Stop()
+	shardState.snaps = snapshot.New(db, shardState.stateCache.TrieDB(), shardState.CurrentBlock().Root())
+
+	rootBlock := shardState.rootTip.CreateBlockToAppend(nil, nil, nil, nil, nil).Finalize(nil, nil, common.Hash{})
+	_, err = shardState.AddRootBlock(rootBlock)
+	checkErr(err)
+
+	fakeGas := uint64(50000)
+	for i := 0; i < 3; i++ {
+		tx := createTransferTransaction(shardState, id1.GetKey().Bytes(), acc1, acc2, new(big.Int).SetUint64(12345), &fakeGas, nil, nil, nil, nil, nil)
+		checkErr(shardState.AddTx(tx))
+		b, err := shardState.CreateBlockToMine(nil, &acc3, nil, nil, nil)
+		checkErr(err)
+		_, _, err = shardState.FinalizeAndAddBlock(b)
+		checkErr(err)
+	}
+	root := shardState.CurrentBlock().Root()
+	assert.NotNil(t, shardState.snaps.Snapshot(root))
+
+	snapState, err := shardState.StateAt(root)
+	checkErr(err)
+	trieState, err := state.New(root, shardState.stateCache)
+	checkErr(err)
+	genesisToken := shardState.GetGenesisToken()
+	for _, addr := range []account.Address{acc1, acc2, acc3} {
+		assert.Equal(t, trieState.GetBalance(addr.Recipient, genesisToken), snapState.GetBalance(addr.Recipient, genesisToken))
+		assert.Equal(t, trieState.GetNonce(addr.Recipient), snapState.GetNonce(addr.Recipient))
+	}
+
+	// The diff layers are flattened on stop
+	shardState.Stop()
+	assert.Equal(t, root, rawdb.ReadSnapshotRoot(db))
+}
+
+func TestXShardCallPrecompile(t *testing.T) {
+	precompile := vm.PrecompiledContractsByzantium[common.HexToAddress(vm.CrossShardCallAddr)]
+	precompile.SetEnableTime(0)
+	defer precompile.SetEnableTime(math.MaxUint64)
+
+	id1, err := account.CreatRandomIdentity()
+	checkErr(err)
+	acc1 := account.CreatAddressFromIdentity(id1, 0)
+	acc2 := account.CreatAddressFromIdentity(id1, 1)
+	acc3, err := account.CreatRandomAccountWithFullShardKey(0)
+	checkErr(err)
+	fakeMoney := uint64(10000000)
+	env := setUp(&acc1, &fakeMoney, nil)
+	id := uint32(0)
+	shardState := createDefaultShardState(env, &id, nil, nil, nil)
+	defer shardState.Stop()
+	env1 := setUp(&acc1, &fakeMoney, nil)
+	id = uint32(1)
+	shardState1 := createDefaultShardState(env1, &id, nil, nil, nil)
+	defer shardState1.Stop()
+
+	// Add a root block to have the target shard initialized
+	rootBlock := shardState.rootTip.CreateBlockToAppend(nil, nil, nil, nil, nil)
+	rootBlock.AddMinorBlockHeader(shardState.CurrentBlock().Header())
+	rootBlock.AddMinorBlockHeader(shardState1.CurrentBlock().Header())
+	rootBlock = rootBlock.Finalize(nil, nil, common.Hash{})
+	_, err = shardState.AddRootBlock(rootBlock)
+	checkErr(err)
+
+	input := func(to account.Address, remoteGas uint64, data []byte) []byte {
+		input := common.LeftPadBytes(to.Recipient.Bytes(), 32)
+		input = append(input, common.LeftPadBytes(new(big.Int).SetUint64(uint64(to.FullShardKey)).Bytes(), 32)...)
+		input = append(input, common.LeftPadBytes(new(big.Int).SetUint64(remoteGas).Bytes(), 32)...)
+		return append(input, data...)
+	}
+	precompileAddr := account.NewAddress(common.HexToAddress(vm.CrossShardCallAddr), 0)
+	gas, remoteGas := uint64(100000), uint64(30000)
+	tx := createTransferTransaction(shardState, id1.GetKey().Bytes(), acc1, precompileAddr,
+		big.NewInt(888888), &gas, nil, nil, input(acc2, remoteGas, []byte("hi")), nil, nil)
+	checkErr(shardState.AddTx(tx))
+	// The deposits can't be sent to the same shard
+	nonce := uint64(1)
+	failedTx := createTransferTransaction(shardState, id1.GetKey().Bytes(), acc1, precompileAddr,
+		big.NewInt(888888), &gas, nil, &nonce, input(acc3, remoteGas, nil), nil, nil)
+	checkErr(shardState.AddTx(failedTx))
+
+	b1, err := shardState.CreateBlockToMine(nil, &acc3, nil, nil, nil)
+	checkErr(err)
+	assert.Equal(t, 2, len(b1.Transactions()))
+	b1, receipts, err := shardState.FinalizeAndAddBlock(b1)
+	checkErr(err)
+	assert.Equal(t, uint64(1), receipts[0].Status)
+	assert.Equal(t, uint64(0), receipts[1].Status)
+
+	xShardList := shardState.currentEvmState.GetXShardList()
+	assert.Equal(t, 1, len(xShardList))
+	deposit := xShardList[0]
+	assert.Equal(t, crypto.Keccak256Hash(tx.Hash().Bytes(), []byte{0, 0, 0, 0}), deposit.TxHash)
+	assert.Equal(t, acc1.Recipient, deposit.From.Recipient)
+	assert.Equal(t, acc2.ToBytes(), deposit.To.ToBytes())
+	assert.Equal(t, uint64(888888), deposit.Value.Value.Uint64())
+	assert.Equal(t, remoteGas, deposit.GasRemained.Value.Uint64())
+	assert.Equal(t, []byte("hi"), deposit.MessageData)
+	assert.Equal(t, uint64(0), shardState.currentEvmState.GetBalance(precompileAddr.Recipient, testGenesisTokenID).Uint64())
+
+	// The gas reserved for the target shard is not used by the local block
+	xShardGas := params.GtxxShardCost.Uint64() + remoteGas
+	assert.Equal(t, receipts[0].GasUsed-xShardGas+receipts[1].GasUsed, shardState.currentEvmState.GetGasUsed().Uint64())
+}
+
+func TestGasLimitVoting(t *testing.T) {
+	id1, err := account.CreatRandomIdentity()
+	checkErr(err)
+	acc1 := account.CreatAddressFromIdentity(id1, 0)
+	acc2, err := account.CreatRandomAccountWithFullShardKey(0)
+	checkErr(err)
+
+	fakeMoney := uint64(10000000)
+	env := setUp(&acc1, &fakeMoney, nil)
+	genesisGasLimit := uint64(30000 * 400)
+	env.clusterConfig.Quarkchain.Chains[0].BlockLimitsTimeStamp = 1
+	shardConfig := env.clusterConfig.Quarkchain.GetShardConfigByFullShardID(2)
+	shardConfig.BlockLimitsTimeStamp = 1
+	shardConfig.GasLimitTarget = 2 * genesisGasLimit
+	shardConfig.BlockTxCountLimit = 1
+	shardState := createDefaultShardState(env, nil, nil, nil, nil)
+	defer shardState.Stop()
+	rootBlock := shardState.rootTip.CreateBlockToAppend(nil, nil, nil, nil, nil).Finalize(nil, nil, common.Hash{})
+	_, err = shardState.AddRootBlock(rootBlock)
+	checkErr(err)
+
+	// the gas limit moves toward the target by less than 1/1024 of the parent one
+	for nonce := uint64(0); nonce < 2; nonce++ {
+		tx := createTransferTransaction(shardState, id1.GetKey().Bytes(), acc1, acc2, big.NewInt(1), nil, nil, &nonce, nil, nil, nil)
+		checkErr(shardState.AddTx(tx))
+	}
+	b1, err := shardState.CreateBlockToMine(nil, &acc2, nil, nil, nil)
+	checkErr(err)
+	gasLimit := genesisGasLimit + genesisGasLimit/1024 - 1
+	assert.Equal(t, gasLimit, b1.GasLimit().Uint64())
+	assert.Equal(t, gasLimit/2, b1.GetXShardGasLimit().Uint64())
+	// a single tx fits the block
+	assert.Equal(t, 1, len(b1.Transactions()))
+	_, _, err = shardState.FinalizeAndAddBlock(b1)
+	checkErr(err)
+
+	// the gas limit may not jump to the target, and the block has too many txs
+	b2, err := shardState.CreateBlockToMine(nil, &acc2, new(big.Int).SetUint64(2*genesisGasLimit), new(big.Int).SetUint64(genesisGasLimit), nil)
+	checkErr(err)
+	_, _, err = shardState.FinalizeAndAddBlock(b2)
+	assert.Error(t, err)
+	b2, err = shardState.CreateBlockToMine(nil, &acc2, nil, nil, nil)
+	checkErr(err)
+	nonce := uint64(2)
+	b2.AddTx(createTransferTransaction(shardState, id1.GetKey().Bytes(), acc1, acc2, big.NewInt(1), nil, nil, &nonce, nil, nil, nil))
+	_, _, err = shardState.FinalizeAndAddBlock(b2)
+	assert.Error(t, err)
+
+	// the target is lowered while running, and reached within the bound
+	env.clusterConfig.Quarkchain.SetChainGasLimitTarget(0, genesisGasLimit)
+	b2, err = shardState.CreateBlockToMine(nil, &acc2, nil, nil, nil)
+	checkErr(err)
+	assert.Equal(t, genesisGasLimit, b2.GasLimit().Uint64())
+	_, _, err = shardState.FinalizeAndAddBlock(b2)
+	checkErr(err)
+}
@@ -0,0 +1,140 @@
+package core
+
+import (
+	"sync"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	qkcCommon "github.com/QuarkChain/goquarkchain/common"
+	"github.com/QuarkChain/goquarkchain/core/rawdb"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+const maxRecentStaleBlocks = 128
+
+// staleBlockWriter is the batch of the block, or the database if the block is
+// not written in a batch, the stale table is updated with.
+type staleBlockWriter interface {
+	rawdb.DatabaseWriter
+	rawdb.DatabaseDeleter
+}
+
+// staleBlockTracker records blocks which lost fork choice (side blocks and
+// blocks dropped by a reorg) in a side table, so miners can measure their
+// stale rate. A block which becomes canonical again is removed from the table.
+type staleBlockTracker struct {
+	db     ethdb.Database
+	mu     sync.Mutex
+	count  uint64
+	recent []common.Hash // oldest first
+}
+
+func newStaleBlockTracker(db ethdb.Database) *staleBlockTracker {
+	return &staleBlockTracker{
+		db:     db,
+		count:  rawdb.ReadStaleBlockCount(db),
+		recent: rawdb.ReadRecentStaleBlocks(db).HList,
+	}
+}
+
+// staleBlockUpdate marks the blocks stale or canonical again with the batch of
+// a block, or the database, and is applied to the tracker once committed after
+// the batch is written. The updates are made under the insertion lock of the
+// chain, one at a time.
+type staleBlockUpdate struct {
+	t      *staleBlockTracker
+	w      staleBlockWriter
+	stale  map[common.Hash]bool // the blocks marked with w, stale or not
+	count  uint64
+	recent []common.Hash
+}
+
+// update starts an update of the stale table with w.
+func (t *staleBlockTracker) update(w staleBlockWriter) *staleBlockUpdate {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return &staleBlockUpdate{
+		t:      t,
+		w:      w,
+		stale:  make(map[common.Hash]bool),
+		count:  t.count,
+		recent: append([]common.Hash(nil), t.recent...),
+	}
+}
+
+// isStale tells whether the block is stale with the blocks marked by the
+// update, which are not in the database until w is written.
+func (u *staleBlockUpdate) isStale(hash common.Hash) bool {
+	if stale, ok := u.stale[hash]; ok {
+		return stale
+	}
+	_, ok := rawdb.ReadStaleBlockTime(u.t.db, hash)
+	return ok
+}
+
+// markStale records the block as stale, it is a no-op if already recorded.
+func (u *staleBlockUpdate) markStale(hash common.Hash) {
+	if u.isStale(hash) {
+		return
+	}
+	u.stale[hash] = true
+	rawdb.WriteStaleBlock(u.w, hash, uint64(time.Now().Unix()))
+	u.count++
+	rawdb.WriteStaleBlockCount(u.w, u.count)
+	u.recent = append(u.recent, hash)
+	if len(u.recent) > maxRecentStaleBlocks {
+		u.recent = u.recent[len(u.recent)-maxRecentStaleBlocks:]
+	}
+	rawdb.WriteRecentStaleBlocks(u.w, &rawdb.HashList{HList: u.recent})
+}
+
+// markCanonical removes the block from the stale table if it was recorded.
+func (u *staleBlockUpdate) markCanonical(hash common.Hash) {
+	if u.count == 0 || !u.isStale(hash) {
+		return
+	}
+	u.stale[hash] = false
+	rawdb.DeleteStaleBlock(u.w, hash)
+	u.count--
+	rawdb.WriteStaleBlockCount(u.w, u.count)
+	for i, h := range u.recent {
+		if h == hash {
+			u.recent = append(u.recent[:i:i], u.recent[i+1:]...)
+			rawdb.WriteRecentStaleBlocks(u.w, &rawdb.HashList{HList: u.recent})
+			break
+		}
+	}
+}
+
+// commit applies the update to the tracker, once w is written.
+func (u *staleBlockUpdate) commit() {
+	u.t.mu.Lock()
+	defer u.t.mu.Unlock()
+	u.t.count = u.count
+	u.t.recent = u.recent
+}
+
+// staleBlocks returns the total number of stale blocks and up to limit of the
+// latest ones, newest first.
+func (t *staleBlockTracker) staleBlocks(limit uint32, getHeader func(common.Hash) types.IHeader) (uint64, []*rpc.StaleBlock) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	list := make([]*rpc.StaleBlock, 0)
+	for i := len(t.recent) - 1; i >= 0 && uint32(len(list)) < limit; i-- {
+		header := getHeader(t.recent[i])
+		if qkcCommon.IsNil(header) {
+			continue
+		}
+		detectTime, _ := rawdb.ReadStaleBlockTime(t.db, t.recent[i])
+		list = append(list, &rpc.StaleBlock{
+			Hash:       header.Hash(),
+			Height:     header.NumberU64(),
+			Coinbase:   header.GetCoinbase(),
+			CreateTime: header.GetTime(),
+			DetectTime: detectTime,
+		})
+	}
+	return t.count, list
+}
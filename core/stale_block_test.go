@@ -0,0 +1,88 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+func TestStaleBlockTracker(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	headers := make(map[common.Hash]types.IHeader)
+	getHeader := func(h common.Hash) types.IHeader {
+		if header, ok := headers[h]; ok {
+			return header
+		}
+		return nil
+	}
+	hashes := make([]common.Hash, 0)
+	for i := uint64(1); i <= 3; i++ {
+		header := &types.RootBlockHeader{Number: uint32(i), Time: i}
+		headers[header.Hash()] = header
+		hashes = append(hashes, header.Hash())
+	}
+
+	tracker := newStaleBlockTracker(db)
+	for _, h := range hashes {
+		stale := tracker.update(db)
+		stale.markStale(h)
+		stale.commit()
+	}
+	// marking twice should not be counted twice
+	stale := tracker.update(db)
+	stale.markStale(hashes[0])
+	stale.commit()
+	total, blocks := tracker.staleBlocks(2, getHeader)
+	if total != 3 || len(blocks) != 2 {
+		t.Fatalf("expected 3 stale blocks with 2 returned, got %d and %d", total, len(blocks))
+	}
+	if blocks[0].Hash != hashes[2] || blocks[1].Hash != hashes[1] {
+		t.Fatalf("stale blocks should be returned newest first")
+	}
+
+	// a block which becomes canonical again is no longer stale
+	stale = tracker.update(db)
+	stale.markCanonical(hashes[2])
+	stale.commit()
+	// reload from db
+	tracker = newStaleBlockTracker(db)
+	total, blocks = tracker.staleBlocks(10, getHeader)
+	if total != 2 || len(blocks) != 2 {
+		t.Fatalf("expected 2 stale blocks, got %d and %d", total, len(blocks))
+	}
+	if blocks[0].Hash != hashes[1] || blocks[0].Height != 2 || blocks[0].CreateTime != 2 {
+		t.Fatalf("unexpected stale block %v", blocks[0])
+	}
+
+	// the blocks marked with the batch of a block are written with it, and
+	// marking one twice before it's written is counted once
+	batch := db.NewBatch()
+	stale = tracker.update(batch)
+	stale.markStale(hashes[2])
+	stale.markStale(hashes[2])
+	if total, _ = newStaleBlockTracker(db).staleBlocks(10, getHeader); total != 2 {
+		t.Fatalf("expected 2 stale blocks before the batch is written, got %d", total)
+	}
+	if total, _ = tracker.staleBlocks(10, getHeader); total != 2 {
+		t.Fatalf("expected 2 stale blocks before the update is committed, got %d", total)
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatal(err)
+	}
+	stale.commit()
+	if total, _ = newStaleBlockTracker(db).staleBlocks(10, getHeader); total != 3 {
+		t.Fatalf("expected 3 stale blocks once the batch is written, got %d", total)
+	}
+	if total, _ = tracker.staleBlocks(10, getHeader); total != 3 {
+		t.Fatalf("expected 3 stale blocks once the update is committed, got %d", total)
+	}
+
+	// an update whose batch isn't written is not applied
+	stale = tracker.update(db.NewBatch())
+	stale.markCanonical(hashes[0])
+	if total, _ = tracker.staleBlocks(10, getHeader); total != 3 {
+		t.Fatalf("expected 3 stale blocks without the batch written, got %d", total)
+	}
+}
@@ -0,0 +1,144 @@
+package snapshot
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/core/rawdb"
+	"github.com/QuarkChain/goquarkchain/qkcdb"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// storageRoot returns the storage root of an account trie value. Both the full
+// and the mocked account keep the storage root as the third field.
+func storageRoot(account []byte) (common.Hash, error) {
+	content, _, err := rlp.SplitList(account)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	for i := 0; i < 2; i++ {
+		if _, _, content, err = rlp.Split(content); err != nil {
+			return common.Hash{}, err
+		}
+	}
+	_, root, _, err := rlp.Split(content)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return common.BytesToHash(root), nil
+}
+
+// generateAccount adds the account and all its storage slots to the batch.
+func (dl *diskLayer) generateAccount(batch qkcdb.Batch, accountHash common.Hash, account []byte) (int, error) {
+	root, err := storageRoot(account)
+	if err != nil {
+		return 0, err
+	}
+	storageTrie, err := trie.NewSecure(root, dl.triedb, 0)
+	if err != nil {
+		return 0, err
+	}
+	// Leftovers of an earlier generation of another root must not survive
+	deleteStorageSnapshots(dl.diskdb, batch, accountHash)
+
+	slots := 0
+	it := trie.NewIterator(storageTrie.NodeIterator(nil))
+	for it.Next() {
+		rawdb.WriteStorageSnapshot(batch, accountHash, common.BytesToHash(it.Key), it.Value)
+		slots++
+	}
+	if it.Err != nil {
+		return 0, it.Err
+	}
+	rawdb.WriteAccountSnapshot(batch, accountHash, account)
+	return slots, nil
+}
+
+// generate is a background thread that iterates over the state and storage tries
+// and constructs the state snapshot. All the writes are flushed at the account
+// boundaries together with the marker, so an account is either fully covered or
+// not covered at all.
+func (dl *diskLayer) generate(marker []byte, genAbort chan chan struct{}) {
+	var (
+		batch    = dl.diskdb.NewBatch()
+		start    = time.Now()
+		logged   = time.Now()
+		accounts uint64
+		slots    uint64
+		abort    chan struct{}
+	)
+	log.Debug("Generating state snapshot", "root", dl.root, "at", common.BytesToHash(marker))
+
+	flush := func() {
+		rawdb.WriteSnapshotGenerator(batch, marker)
+		if err := batch.Write(); err != nil {
+			log.Crit("Failed to write state snapshot", "err", err)
+		}
+		batch.Reset()
+
+		dl.lock.Lock()
+		dl.genMarker = marker
+		dl.lock.Unlock()
+	}
+	accTrie, err := trie.NewSecure(dl.root, dl.triedb, 0)
+	if err != nil {
+		log.Error("Snapshot generation failed", "root", dl.root, "err", err)
+		abort = <-genAbort
+		close(abort)
+		return
+	}
+	it := trie.NewIterator(accTrie.NodeIterator(marker))
+	for it.Next() {
+		if bytes.Equal(it.Key, marker) {
+			continue
+		}
+		accountHash := common.BytesToHash(it.Key)
+		n, err := dl.generateAccount(batch, accountHash, common.CopyBytes(it.Value))
+		if err != nil {
+			log.Error("Snapshot generation failed", "root", dl.root, "account", accountHash, "err", err)
+			abort = <-genAbort
+			close(abort)
+			return
+		}
+		marker = accountHash.Bytes()
+		accounts, slots = accounts+1, slots+uint64(n)
+
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			flush()
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Generating state snapshot", "root", dl.root, "at", accountHash, "accounts", accounts, "slots", slots, "elapsed", time.Since(start))
+			logged = time.Now()
+		}
+		select {
+		case abort = <-genAbort:
+			flush()
+			log.Debug("Aborted state snapshot generation", "root", dl.root, "at", accountHash, "accounts", accounts, "slots", slots)
+			close(abort)
+			return
+		default:
+		}
+	}
+	if it.Err != nil {
+		log.Error("Snapshot generation failed", "root", dl.root, "err", it.Err)
+		abort = <-genAbort
+		close(abort)
+		return
+	}
+	// Generation done, drop the marker and wait for the abort to exit
+	rawdb.DeleteSnapshotGenerator(batch)
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to write state snapshot", "err", err)
+	}
+	dl.lock.Lock()
+	dl.genMarker = nil
+	dl.lock.Unlock()
+	log.Info("Generated state snapshot", "root", dl.root, "accounts", accounts, "slots", slots, "elapsed", time.Since(start))
+
+	abort = <-genAbort
+	close(abort)
+}
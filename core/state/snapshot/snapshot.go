@@ -0,0 +1,348 @@
+// Package snapshot implements a flat key-value layer of the accounts and the
+// storage slots of a state, which serves the state reads without walking the
+// tries.
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/QuarkChain/goquarkchain/core/rawdb"
+	"github.com/QuarkChain/goquarkchain/qkcdb"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+var (
+	// ErrSnapshotStale is returned from data accessors if the underlying snapshot
+	// layer had been invalidated due to the chain progressing forward far enough
+	// to not maintain the layer's original state.
+	ErrSnapshotStale = errors.New("snapshot stale")
+
+	// ErrNotCoveredYet is returned from data accessors if the underlying snapshot
+	// is being generated currently and the requested data item is not yet in the
+	// range of accounts covered.
+	ErrNotCoveredYet = errors.New("not covered yet")
+
+	// errSnapshotCycle is returned if a snapshot is attempted to be inserted
+	// that forms a cycle in the snapshot tree.
+	errSnapshotCycle = errors.New("snapshot cycle")
+)
+
+// Snapshot represents the functionality supported by a snapshot storage layer.
+type Snapshot interface {
+	// Root returns the root hash of the state the snapshot belongs to.
+	Root() common.Hash
+
+	// Account directly retrieves the account trie value associated with a
+	// particular hash in the snapshot, nil is returned if the account does not
+	// exist.
+	Account(hash common.Hash) ([]byte, error)
+
+	// Storage directly retrieves the storage trie value associated with a
+	// particular hash within a particular account, nil is returned if the slot
+	// is empty.
+	Storage(accountHash, storageHash common.Hash) ([]byte, error)
+}
+
+// snapshot is the internal version of the snapshot data layer that supports
+// the tree maintenance.
+type snapshot interface {
+	Snapshot
+
+	// Parent returns the subsequent layer of a snapshot, or nil if the base was
+	// reached.
+	Parent() snapshot
+
+	// Stale returns whether this layer has become stale (was flattened across).
+	Stale() bool
+
+	// markStale invalidates the layer.
+	markStale()
+}
+
+// Tree is an Ethereum state snapshot tree. It consists of one persistent base
+// layer backed by the key-value store, on top of which arbitrarily many in-memory
+// diff layers are kept. Each diff layer holds the changes of a block, so the
+// diff layers of the sibling blocks form a tree, which keeps the snapshot usable
+// across the chain reorgs not deeper than the in-memory layers.
+//
+// The goal of a state snapshot is twofold: to allow direct access to the account
+// and storage data to avoid expensive multi-level trie lookups; and to keep the
+// cost of a lookup independent from the size of the state.
+type Tree struct {
+	diskdb qkcdb.KeyValueStore      // Persistent database to store the snapshot
+	triedb *trie.Database           // In-memory cache to access the trie through
+	layers map[common.Hash]snapshot // Collection of all known layers
+	lock   sync.RWMutex
+}
+
+// New attempts to load an already existing snapshot from a persistent key-value
+// store, ensuring that the head of the snapshot matches the expected one. If the snapshot is missing or does
+// not match the root, it is wiped and regenerated in the background, while the
+// accessors fall back to the trie for the accounts not covered yet.
+func New(diskdb qkcdb.KeyValueStore, triedb *trie.Database, root common.Hash) *Tree {
+	base := &diskLayer{
+		diskdb: diskdb,
+		triedb: triedb,
+		root:   root,
+	}
+	if stored := rawdb.ReadSnapshotRoot(diskdb); stored != root {
+		log.Info("Rebuilding state snapshot", "root", root, "stored", stored)
+		wipeSnapshot(diskdb, root)
+		base.genMarker = []byte{}
+	} else if base.genMarker = rawdb.ReadSnapshotGenerator(diskdb); base.genMarker != nil {
+		log.Info("Resuming state snapshot generation", "root", root, "at", common.BytesToHash(base.genMarker))
+	}
+	if base.genMarker != nil {
+		base.startGeneration()
+	}
+	return &Tree{
+		diskdb: diskdb,
+		triedb: triedb,
+		layers: map[common.Hash]snapshot{root: base},
+	}
+}
+
+// Snapshot retrieves a snapshot belonging to the given state root, or nil if
+// no snapshot is maintained for that state.
+func (t *Tree) Snapshot(root common.Hash) Snapshot {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if snap, ok := t.layers[root]; ok {
+		return snap
+	}
+	return nil
+}
+
+// Update adds a new snapshot into the tree, if that can be linked to an existing
+// old parent. It is disallowed to insert a disk layer (the origin of all).
+func (t *Tree) Update(blockRoot common.Hash, parentRoot common.Hash, destructs map[common.Hash]struct{}, accounts map[common.Hash][]byte, storage map[common.Hash]map[common.Hash][]byte) error {
+	if blockRoot == parentRoot {
+		return errSnapshotCycle
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// The same state may be committed more than once, e.g. by the miner and by
+	// the block import, the first layer is kept
+	if _, ok := t.layers[blockRoot]; ok {
+		return nil
+	}
+	parent, ok := t.layers[parentRoot]
+	if !ok {
+		return fmt.Errorf("parent [%#x] snapshot missing", parentRoot)
+	}
+	t.layers[blockRoot] = newDiffLayer(parent, blockRoot, destructs, accounts, storage)
+	return nil
+}
+
+// Cap traverses downwards the snapshot tree from a head block hash until the
+// number of allowed layers are crossed. All layers beyond the permitted number
+// are flattened downwards into the disk layer, and the layers which are not
+// descending from the new disk layer are dropped as stale.
+func (t *Tree) Cap(root common.Hash, layers int) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	snap, ok := t.layers[root]
+	if !ok {
+		return fmt.Errorf("snapshot [%#x] missing", root)
+	}
+	// Collect the diff layers from the head downwards
+	var diffs []*diffLayer
+	for s := snap; ; s = s.Parent() {
+		diff, ok := s.(*diffLayer)
+		if !ok {
+			break
+		}
+		diffs = append(diffs, diff)
+	}
+	if len(diffs) <= layers {
+		return nil
+	}
+	// Flatten the layers beyond the permitted number into the disk, bottom up
+	base := diffs[len(diffs)-1].Parent().(*diskLayer)
+	for i := len(diffs) - 1; i >= layers; i-- {
+		base = diffToDisk(base, diffs[i])
+		if i > 0 {
+			diffs[i-1].setParent(base)
+		}
+	}
+	// Relink the children of the flattened layer to the new disk layer, and drop
+	// the layers not descending from it
+	for _, snap := range t.layers {
+		if diff, ok := snap.(*diffLayer); ok && diff.Parent().Root() == base.root {
+			diff.setParent(base)
+		}
+	}
+	descending := make(map[snapshot]bool)
+	var descends func(s snapshot) bool
+	descends = func(s snapshot) bool {
+		if s == snapshot(base) {
+			return true
+		}
+		if ok, known := descending[s]; known {
+			return ok
+		}
+		diff, ok := s.(*diffLayer)
+		ok = ok && descends(diff.Parent())
+		descending[s] = ok
+		return ok
+	}
+	for root, snap := range t.layers {
+		if !descends(snap) {
+			snap.markStale()
+			delete(t.layers, root)
+		}
+	}
+	t.layers[base.root] = base
+	return nil
+}
+
+// Rebuild wipes all available snapshot data from the persistent database and
+// discards all caches and diff layers. Afterwards, it starts a new snapshot
+// generator with the given root hash.
+func (t *Tree) Rebuild(root common.Hash) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for _, snap := range t.layers {
+		if dl, ok := snap.(*diskLayer); ok {
+			dl.stopGeneration()
+		}
+		snap.markStale()
+	}
+	log.Info("Rebuilding state snapshot", "root", root)
+	wipeSnapshot(t.diskdb, root)
+
+	base := &diskLayer{
+		diskdb:    t.diskdb,
+		triedb:    t.triedb,
+		root:      root,
+		genMarker: []byte{},
+	}
+	base.startGeneration()
+	t.layers = map[common.Hash]snapshot{root: base}
+}
+
+// Stop aborts the snapshot generation, the progress is persisted so that the
+// generation resumes on the next start.
+func (t *Tree) Stop() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for _, snap := range t.layers {
+		if dl, ok := snap.(*diskLayer); ok {
+			dl.stopGeneration()
+		}
+	}
+}
+
+// diffToDisk merges a bottom-most diff into the persistent disk layer underneath
+// it. The method will panic if called onto a non-bottom-most diff layer. The
+// changes of the accounts not covered by a running generation are skipped, the
+// generator picks them up from the new root.
+func diffToDisk(base *diskLayer, bottom *diffLayer) *diskLayer {
+	if bottom.Parent() != snapshot(base) {
+		panic("parent of the flattened layer is not the disk layer")
+	}
+	// Stop the generation so that it does not race with the flattening
+	base.stopGeneration()
+
+	base.lock.Lock()
+	base.stale = true
+	marker := base.genMarker
+	base.lock.Unlock()
+
+	bottom.lock.Lock()
+	defer bottom.lock.Unlock()
+	bottom.stale = true
+
+	covered := func(hash common.Hash) bool {
+		return marker == nil || bytes.Compare(hash[:], marker) <= 0
+	}
+	batch := base.diskdb.NewBatch()
+	for hash := range bottom.destructSet {
+		if !covered(hash) {
+			continue
+		}
+		rawdb.DeleteAccountSnapshot(batch, hash)
+		deleteStorageSnapshots(base.diskdb, batch, hash)
+	}
+	for hash, data := range bottom.accountData {
+		if !covered(hash) {
+			continue
+		}
+		if len(data) == 0 {
+			rawdb.DeleteAccountSnapshot(batch, hash)
+		} else {
+			rawdb.WriteAccountSnapshot(batch, hash, data)
+		}
+	}
+	for accountHash, slots := range bottom.storageData {
+		if !covered(accountHash) {
+			continue
+		}
+		for storageHash, data := range slots {
+			if len(data) == 0 {
+				rawdb.DeleteStorageSnapshot(batch, accountHash, storageHash)
+			} else {
+				rawdb.WriteStorageSnapshot(batch, accountHash, storageHash, data)
+			}
+		}
+	}
+	rawdb.WriteSnapshotRoot(batch, bottom.root)
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to write flattened snapshot", "err", err)
+	}
+	res := &diskLayer{
+		diskdb:    base.diskdb,
+		triedb:    base.triedb,
+		root:      bottom.root,
+		genMarker: marker,
+	}
+	if marker != nil {
+		res.startGeneration()
+	}
+	return res
+}
+
+// wipeSnapshot deletes all the snapshot data of the database and marks the
+// snapshot of root to be generated from scratch.
+func wipeSnapshot(db qkcdb.KeyValueStore, root common.Hash) {
+	rawdb.DeleteSnapshotRoot(db)
+
+	batch := db.NewBatch()
+	deleteKeys(db, batch, rawdb.SnapshotAccountPrefix, len(rawdb.SnapshotAccountPrefix)+common.HashLength)
+	deleteKeys(db, batch, rawdb.SnapshotStoragePrefix, len(rawdb.SnapshotStoragePrefix)+2*common.HashLength)
+	rawdb.WriteSnapshotGenerator(batch, []byte{})
+	rawdb.WriteSnapshotRoot(batch, root)
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to wipe state snapshot", "err", err)
+	}
+}
+
+// deleteStorageSnapshots adds the deletion of all the storage snapshot entries
+// of an account to the batch.
+func deleteStorageSnapshots(db qkcdb.KeyValueStore, batch qkcdb.Batch, accountHash common.Hash) {
+	deleteKeys(db, batch, rawdb.StorageSnapshotsPrefix(accountHash), len(rawdb.SnapshotStoragePrefix)+2*common.HashLength)
+}
+
+// deleteKeys adds the deletion of the keys with the prefix and the length to the
+// batch, the length tells the snapshot entries apart from the trie nodes which
+// happen to start with the prefix.
+func deleteKeys(db qkcdb.KeyValueStore, batch qkcdb.Batch, prefix []byte, keyLen int) {
+	it := db.NewIteratorWithPrefix(prefix)
+	defer it.Close()
+
+	for ; it.Valid() && bytes.HasPrefix(it.Key(), prefix); it.Next() {
+		if key := it.Key(); len(key) == keyLen {
+			batch.Delete(common.CopyBytes(key))
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package snapshot
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/core/rawdb"
+	"github.com/QuarkChain/goquarkchain/qkcdb"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// testAccount has the layout of the mocked state account.
+type testAccount struct {
+	Nonce    uint64
+	Balance  *big.Int
+	Root     common.Hash
+	CodeHash []byte
+}
+
+func newTestDB() (*qkcdb.LDBDatabase, func()) {
+	dirname, err := ioutil.TempDir(os.TempDir(), "snapshot_test_")
+	if err != nil {
+		panic("failed to create test file: " + err.Error())
+	}
+	db, err := qkcdb.NewLDBDatabase(dirname, false, false)
+	if err != nil {
+		panic("failed to create test database: " + err.Error())
+	}
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dirname)
+	}
+}
+
+// newTestState commits a state of the accounts with the storage into the trie
+// database and returns its root together with the account trie values.
+func newTestState(t *testing.T, triedb *trie.Database, storage map[common.Hash]map[common.Hash][]byte, nonces ...uint64) (common.Hash, map[common.Hash][]byte) {
+	accTrie, _ := trie.NewSecure(common.Hash{}, triedb, 0)
+	accounts := make(map[common.Hash][]byte)
+	for i, nonce := range nonces {
+		key := []byte{byte(i + 1)}
+		stTrie, _ := trie.NewSecure(common.Hash{}, triedb, 0)
+		for slot, value := range storage[crypto.Keccak256Hash(key)] {
+			stTrie.Update(slot[:], value)
+		}
+		stRoot, err := stTrie.Commit(nil)
+		if err != nil {
+			t.Fatalf("failed to commit storage trie: %v", err)
+		}
+		enc, _ := rlp.EncodeToBytes(&testAccount{Nonce: nonce, Balance: big.NewInt(int64(i)), Root: stRoot, CodeHash: crypto.Keccak256(nil)})
+		accTrie.Update(key, enc)
+		accounts[crypto.Keccak256Hash(key)] = enc
+	}
+	root, err := accTrie.Commit(nil)
+	if err != nil {
+		t.Fatalf("failed to commit account trie: %v", err)
+	}
+	return root, accounts
+}
+
+// waitGeneration waits for the generation of the disk layer of the tree to end.
+func waitGeneration(t *testing.T, tree *Tree, root common.Hash) *diskLayer {
+	dl, ok := tree.Snapshot(root).(*diskLayer)
+	if !ok {
+		t.Fatalf("disk layer %x not found", root)
+	}
+	for i := 0; i < 100; i++ {
+		dl.lock.RLock()
+		done := dl.genMarker == nil
+		dl.lock.RUnlock()
+		if done {
+			return dl
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("snapshot generation of %x timed out", root)
+	return nil
+}
+
+func TestStorageRoot(t *testing.T) {
+	want := common.HexToHash("0x1234")
+	enc, _ := rlp.EncodeToBytes(&testAccount{Nonce: 1, Balance: big.NewInt(100), Root: want})
+	if root, err := storageRoot(enc); err != nil || root != want {
+		t.Fatalf("storage root mismatch: have %x, %v, want %x", root, err, want)
+	}
+	if _, err := storageRoot([]byte{0x01}); err == nil {
+		t.Fatal("expected error for the invalid account")
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	db, remove := newTestDB()
+	defer remove()
+
+	triedb := trie.NewDatabase(db)
+	accHash := crypto.Keccak256Hash([]byte{1})
+	value := []byte{0x2a}
+	root, accounts := newTestState(t, triedb, map[common.Hash]map[common.Hash][]byte{
+		accHash: {common.HexToHash("0x01"): value},
+	}, 1, 2, 3)
+	if err := triedb.Commit(root, false); err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	// The leftovers of another state are wiped
+	stale := crypto.Keccak256Hash([]byte("stale"))
+	rawdb.WriteAccountSnapshot(db, stale, []byte{0x01})
+
+	tree := New(db, triedb, root)
+	dl := waitGeneration(t, tree, root)
+	for hash, want := range accounts {
+		if have, err := dl.Account(hash); err != nil || !bytes.Equal(have, want) {
+			t.Errorf("account %x mismatch: have %x, %v, want %x", hash, have, err, want)
+		}
+	}
+	if have, err := dl.Account(stale); err != nil || have != nil {
+		t.Errorf("stale account not wiped: have %x, %v", have, err)
+	}
+	slot := crypto.Keccak256Hash(common.HexToHash("0x01").Bytes())
+	if have, err := dl.Storage(accHash, slot); err != nil || !bytes.Equal(have, value) {
+		t.Errorf("storage mismatch: have %x, %v, want %x", have, err, value)
+	}
+	if rawdb.ReadSnapshotRoot(db) != root {
+		t.Errorf("snapshot root not stored")
+	}
+	if marker := rawdb.ReadSnapshotGenerator(db); marker != nil {
+		t.Errorf("generator marker left: %x", marker)
+	}
+	tree.Stop()
+
+	// A matching snapshot is loaded without generation
+	tree = New(db, triedb, root)
+	if dl := tree.Snapshot(root).(*diskLayer); dl.genMarker != nil || dl.genAbort != nil {
+		t.Errorf("generation started for a complete snapshot")
+	}
+}
+
+func TestDiffLayers(t *testing.T) {
+	db, remove := newTestDB()
+	defer remove()
+
+	var (
+		r0, r1, r2, r2b, r3 = common.Hash{}, common.HexToHash("0x01"), common.HexToHash("0x02"), common.HexToHash("0x2b"), common.HexToHash("0x03")
+		a, b, s             = common.HexToHash("0xaa"), common.HexToHash("0xbb"), common.HexToHash("0x55")
+	)
+	tree := New(db, trie.NewDatabase(db), r0)
+	waitGeneration(t, tree, r0)
+
+	if err := tree.Update(r1, r1, nil, nil, nil); err != errSnapshotCycle {
+		t.Errorf("cycle not detected: %v", err)
+	}
+	if err := tree.Update(r2, r1, nil, nil, nil); err == nil {
+		t.Errorf("missing parent not detected")
+	}
+	tree.Update(r1, r0, nil, map[common.Hash][]byte{a: {0x01}}, map[common.Hash]map[common.Hash][]byte{a: {s: {0x02}}})
+	tree.Update(r2, r1, map[common.Hash]struct{}{a: {}}, map[common.Hash][]byte{b: {0x03}}, nil)
+	tree.Update(r2b, r1, nil, map[common.Hash][]byte{a: {0x04}}, nil)
+
+	check := func(root, accHash common.Hash, want []byte, wantErr error) {
+		snap := tree.Snapshot(root)
+		if snap == nil {
+			t.Fatalf("snapshot %x missing", root)
+		}
+		if have, err := snap.Account(accHash); err != wantErr || !bytes.Equal(have, want) {
+			t.Errorf("account %x at %x mismatch: have %x, %v, want %x, %v", accHash, root, have, err, want, wantErr)
+		}
+	}
+	check(r1, a, []byte{0x01}, nil)
+	check(r2, a, nil, nil)
+	check(r2, b, []byte{0x03}, nil)
+	check(r2b, a, []byte{0x04}, nil)
+	check(r2b, b, nil, nil)
+	if have, _ := tree.Snapshot(r1).Storage(a, s); !bytes.Equal(have, []byte{0x02}) {
+		t.Errorf("storage mismatch: have %x", have)
+	}
+	if have, _ := tree.Snapshot(r2).Storage(a, s); have != nil {
+		t.Errorf("storage of destructed account: have %x", have)
+	}
+
+	// Flatten r1, both children are kept on top of the disk
+	old := tree.Snapshot(r1)
+	if err := tree.Cap(r2, 1); err != nil {
+		t.Fatalf("failed to cap: %v", err)
+	}
+	if _, err := old.Account(a); err != ErrSnapshotStale {
+		t.Errorf("flattened layer not stale: %v", err)
+	}
+	if _, ok := tree.Snapshot(r1).(*diskLayer); !ok {
+		t.Errorf("r1 not flattened into disk")
+	}
+	if tree.Snapshot(r0) != nil {
+		t.Errorf("old disk layer not dropped")
+	}
+	if !bytes.Equal(rawdb.ReadAccountSnapshot(db, a), []byte{0x01}) || !bytes.Equal(rawdb.ReadStorageSnapshot(db, a, s), []byte{0x02}) {
+		t.Errorf("r1 not written into disk")
+	}
+	check(r2, a, nil, nil)
+	check(r2b, a, []byte{0x04}, nil)
+
+	// Flatten r2, the fork r2b is dropped
+	fork := tree.Snapshot(r2b)
+	tree.Update(r3, r2, nil, nil, nil)
+	if err := tree.Cap(r3, 1); err != nil {
+		t.Fatalf("failed to cap: %v", err)
+	}
+	if _, err := fork.Account(a); err != ErrSnapshotStale {
+		t.Errorf("dropped fork not stale: %v", err)
+	}
+	if tree.Snapshot(r2b) != nil {
+		t.Errorf("fork not dropped")
+	}
+	if rawdb.ReadAccountSnapshot(db, a) != nil || rawdb.ReadStorageSnapshot(db, a, s) != nil {
+		t.Errorf("destructed account left in disk")
+	}
+	if !bytes.Equal(rawdb.ReadAccountSnapshot(db, b), []byte{0x03}) {
+		t.Errorf("r2 not written into disk")
+	}
+	if rawdb.ReadSnapshotRoot(db) != r2 {
+		t.Errorf("snapshot root mismatch: have %x, want %x", rawdb.ReadSnapshotRoot(db), r2)
+	}
+	check(r3, b, []byte{0x03}, nil)
+}
+
+// Tests that flattening a layer during the generation only writes the covered
+// accounts, and the generation continues from the new root.
+func TestCapDuringGeneration(t *testing.T) {
+	db, remove := newTestDB()
+	defer remove()
+
+	triedb := trie.NewDatabase(db)
+	root, accounts := newTestState(t, triedb, nil, 1, 2, 3, 4)
+	var hashes []common.Hash
+	for hash := range accounts {
+		hashes = append(hashes, hash)
+	}
+	for i := range hashes {
+		for j := i + 1; j < len(hashes); j++ {
+			if bytes.Compare(hashes[j][:], hashes[i][:]) < 0 {
+				hashes[i], hashes[j] = hashes[j], hashes[i]
+			}
+		}
+	}
+	// Fake a base whose generation covered the first account only
+	base := &diskLayer{diskdb: db, triedb: triedb, root: common.HexToHash("0x01"), genMarker: hashes[0].Bytes()}
+	tree := &Tree{diskdb: db, triedb: triedb, layers: map[common.Hash]snapshot{base.root: base}}
+	// A bogus uncovered account is not written, the generator picks it up
+	diff := make(map[common.Hash][]byte)
+	for hash, data := range accounts {
+		diff[hash] = data
+	}
+	diff[hashes[len(hashes)-1]] = []byte{0x01}
+	if err := tree.Update(root, base.root, nil, diff, nil); err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+	if err := tree.Cap(root, 0); err != nil {
+		t.Fatalf("failed to cap: %v", err)
+	}
+	dl := waitGeneration(t, tree, root)
+	for hash, want := range accounts {
+		if have, err := dl.Account(hash); err != nil || !bytes.Equal(have, want) {
+			t.Errorf("account %x mismatch: have %x, %v, want %x", hash, have, err, want)
+		}
+	}
+}
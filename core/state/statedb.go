@@ -18,6 +18,7 @@
 package state
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"math/big"
@@ -28,6 +29,7 @@ import (
 
 	qkcaccount "github.com/QuarkChain/goquarkchain/account"
 	qkcCommon "github.com/QuarkChain/goquarkchain/common"
+	"github.com/QuarkChain/goquarkchain/core/state/snapshot"
 	"github.com/QuarkChain/goquarkchain/core/types"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -69,6 +71,14 @@ type StateDB struct {
 	stateObjects      map[common.Address]*stateObject
 	stateObjectsDirty map[common.Address]struct{}
 
+	// The snapshot of the state to read from and the changes to add to the
+	// snapshot tree on commit, the snapshot is nil if not maintained.
+	snaps         *snapshot.Tree
+	snap          snapshot.Snapshot
+	snapDestructs map[common.Hash]struct{}
+	snapAccounts  map[common.Hash][]byte
+	snapStorage   map[common.Hash]map[common.Hash][]byte
+
 	// DB error.
 	// State objects are used by the consensus core and VM which are
 	// unable to deal with database-level errors. Any error that occurs
@@ -86,6 +96,9 @@ type StateDB struct {
 
 	preimages map[common.Hash][]byte
 
+	// Per-transaction access list
+	accessList *accessList
+
 	// Journal of state modifications. This is the backbone of
 	// Snapshot and RevertToSnapshot.
 	journal        *journal
@@ -122,12 +135,37 @@ func New(root common.Hash, db Database) (*StateDB, error) {
 		logs:              make(map[common.Hash][]*types.Log),
 		preimages:         make(map[common.Hash][]byte),
 		journal:           newJournal(),
+		accessList:        newAccessList(),
 	}
 	stateDB.SetGasLimit(params.DefaultStateDBGasLimit)
 	return stateDB, nil
 
 }
 
+// NewWithSnapshot creates a new state from a given trie, the accounts and the
+// storage are read from the snapshot of the root if snaps maintains it.
+func NewWithSnapshot(root common.Hash, db Database, snaps *snapshot.Tree) (*StateDB, error) {
+	stateDB, err := New(root, db)
+	if err != nil {
+		return nil, err
+	}
+	stateDB.snaps = snaps
+	stateDB.resetSnapshot(root)
+	return stateDB, nil
+}
+
+// resetSnapshot points the state to the snapshot of root and clears the changes
+// recorded for the snapshot tree.
+func (s *StateDB) resetSnapshot(root common.Hash) {
+	if s.snaps == nil {
+		return
+	}
+	s.snap = s.snaps.Snapshot(root)
+	s.snapDestructs = make(map[common.Hash]struct{})
+	s.snapAccounts = make(map[common.Hash][]byte)
+	s.snapStorage = make(map[common.Hash]map[common.Hash][]byte)
+}
+
 // setError remembers the first non-nil error it is called with.
 func (s *StateDB) setError(err error) {
 	if s.dbErr == nil {
@@ -155,6 +193,7 @@ func (s *StateDB) Reset(root common.Hash) error {
 	s.logs = make(map[common.Hash][]*types.Log)
 	s.logSize = 0
 	s.preimages = make(map[common.Hash][]byte)
+	s.resetSnapshot(root)
 	s.clearJournalAndRefund()
 	return nil
 }
@@ -432,6 +471,22 @@ func (s *StateDB) updateStateObject(stateObject *stateObject) {
 		}
 	}
 	s.setError(s.trie.TryUpdate(addr[:], data))
+	if s.snap != nil {
+		if stateObject.created {
+			// The storage of the overwritten account is dropped, the new storage
+			// is all written since the creation and cached in originStorage
+			storage := make(map[common.Hash][]byte)
+			for key, value := range stateObject.originStorage {
+				if (value != common.Hash{}) {
+					storage[crypto.Keccak256Hash(key[:])], _ = rlp.EncodeToBytes(bytes.TrimLeft(value[:], "\x00"))
+				}
+			}
+			s.snapDestructs[stateObject.addrHash] = struct{}{}
+			s.snapStorage[stateObject.addrHash] = storage
+			stateObject.created = false
+		}
+		s.snapAccounts[stateObject.addrHash] = data
+	}
 }
 
 // deleteStateObject removes the given object from the state trie.
@@ -439,6 +494,11 @@ func (s *StateDB) deleteStateObject(stateObject *stateObject) {
 	stateObject.deleted = true
 	addr := stateObject.Address()
 	s.setError(s.trie.TryDelete(addr[:]))
+	if s.snap != nil {
+		s.snapDestructs[stateObject.addrHash] = struct{}{}
+		delete(s.snapAccounts, stateObject.addrHash)
+		delete(s.snapStorage, stateObject.addrHash)
+	}
 }
 
 // Retrieve a state object given by the address. Returns nil if not found.
@@ -451,8 +511,17 @@ func (s *StateDB) getStateObject(addr common.Address) (stateObject *stateObject)
 		return obj
 	}
 
-	// Load the object from the database.
-	enc, err := s.trie.TryGet(addr[:])
+	// Load the object from the snapshot if available, or from the database.
+	var (
+		enc []byte
+		err error
+	)
+	if s.snap != nil {
+		enc, err = s.snap.Account(crypto.Keccak256Hash(addr[:]))
+	}
+	if s.snap == nil || err != nil {
+		enc, err = s.trie.TryGet(addr[:])
+	}
 	if len(enc) == 0 {
 		s.setError(err)
 		return nil
@@ -510,6 +579,7 @@ func (s *StateDB) createObject(addr common.Address) (newobj, prev *stateObject)
 	if prev == nil {
 		s.journal.append(createObjectChange{account: &addr})
 	} else {
+		newobj.created = true
 		s.journal.append(resetObjectChange{prev: prev})
 	}
 	s.setStateObject(newobj)
@@ -565,6 +635,32 @@ func (s *StateDB) Copy() *StateDB {
 		journal:           newJournal(),
 		senderDisallowMap: make(map[qkcaccount.Recipient]*big.Int, len(s.senderDisallowMap)),
 	}
+	// Do we need to copy the access list? In practice: No. At the start of a
+	// transaction, the access list is empty. In practice, we only ever copy state
+	// _between_ transactions/blocks, never in the middle of a transaction.
+	// However, it doesn't cost us much to copy an empty list, so we do it anyway
+	// to not blow up if we ever decide copy it in the middle of a transaction
+	state.accessList = s.accessList.Copy()
+
+	if s.snaps != nil {
+		state.snaps, state.snap = s.snaps, s.snap
+		state.snapDestructs = make(map[common.Hash]struct{}, len(s.snapDestructs))
+		for hash := range s.snapDestructs {
+			state.snapDestructs[hash] = struct{}{}
+		}
+		state.snapAccounts = make(map[common.Hash][]byte, len(s.snapAccounts))
+		for hash, data := range s.snapAccounts {
+			state.snapAccounts[hash] = data
+		}
+		state.snapStorage = make(map[common.Hash]map[common.Hash][]byte, len(s.snapStorage))
+		for hash, slots := range s.snapStorage {
+			cpy := make(map[common.Hash][]byte, len(slots))
+			for key, data := range slots {
+				cpy[key] = data
+			}
+			state.snapStorage[hash] = cpy
+		}
+	}
 	// Copy the dirty states, logs, and preimages
 	for addr := range s.journal.dirties {
 		// As documented [here](https://github.com/ethereum/go-ethereum/pull/16485#issuecomment-380438527),
@@ -679,6 +775,82 @@ func (s *StateDB) Prepare(thash, bhash common.Hash, ti int) {
 	s.txIndex = ti
 }
 
+// PrepareAccessList clears the access list and adds the addresses which are warm
+// from the start of a transaction under the EIP-2929 gas metering:
+// - the sender
+// - the destination, or the created contract
+// - the precompiled contracts
+func (s *StateDB) PrepareAccessList(sender common.Address, dst *common.Address, precompiles []common.Address) {
+	s.accessList = newAccessList()
+	s.AddAddressToAccessList(sender)
+	if dst != nil {
+		s.AddAddressToAccessList(*dst)
+	}
+	for _, addr := range precompiles {
+		s.AddAddressToAccessList(addr)
+	}
+}
+
+// AddAddressToAccessList adds the given address to the access list
+func (s *StateDB) AddAddressToAccessList(addr common.Address) {
+	if s.accessList.AddAddress(addr) {
+		s.journal.append(accessListAddAccountChange{&addr})
+	}
+}
+
+// AddSlotToAccessList adds the given (address, slot)-tuple to the access list
+func (s *StateDB) AddSlotToAccessList(addr common.Address, slot common.Hash) {
+	addrMod, slotMod := s.accessList.AddSlot(addr, slot)
+	if addrMod {
+		// In practice, this should not happen, since there is no way to enter the
+		// scope of 'address' without having the 'address' become already added
+		// to the access list (via call-variant, create, etc).
+		// Better safe than sorry, though
+		s.journal.append(accessListAddAccountChange{&addr})
+	}
+	if slotMod {
+		s.journal.append(accessListAddSlotChange{
+			address: &addr,
+			slot:    &slot,
+		})
+	}
+}
+
+// AddressInAccessList returns true if the given address is in the access list.
+func (s *StateDB) AddressInAccessList(addr common.Address) bool {
+	return s.accessList.ContainsAddress(addr)
+}
+
+// SlotInAccessList returns true if the given (address, slot)-tuple is in the access list.
+func (s *StateDB) SlotInAccessList(addr common.Address, slot common.Hash) (addressPresent bool, slotPresent bool) {
+	return s.accessList.Contains(addr, slot)
+}
+
+// JournalDirties returns the accounts modified since the state was copied or
+// last finalised.
+func (s *StateDB) JournalDirties() []common.Address {
+	addrs := make([]common.Address, 0, len(s.journal.dirties))
+	for addr := range s.journal.dirties {
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
+// DirtyAccounts returns the accounts modified since the state was last
+// committed.
+func (s *StateDB) DirtyAccounts() []common.Address {
+	addrs := make([]common.Address, 0, len(s.stateObjectsDirty)+len(s.journal.dirties))
+	for addr := range s.stateObjectsDirty {
+		addrs = append(addrs, addr)
+	}
+	for addr := range s.journal.dirties {
+		if _, ok := s.stateObjectsDirty[addr]; !ok {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
 func (s *StateDB) clearJournalAndRefund() {
 	s.journal = newJournal()
 	s.validRevisions = s.validRevisions[:0]
@@ -730,6 +902,17 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (root common.Hash, err error)
 		}
 		return nil
 	})
+	if err == nil && s.snaps != nil {
+		// Add the changes as a diff layer on top of the parent snapshot
+		if s.snap != nil {
+			if parent := s.snap.Root(); parent != root {
+				if err := s.snaps.Update(root, parent, s.snapDestructs, s.snapAccounts, s.snapStorage); err != nil {
+					log.Warn("Failed to update snapshot tree", "from", parent, "to", root, "err", err)
+				}
+			}
+		}
+		s.resetSnapshot(root)
+	}
 	log.Debug("Trie cache stats after commit", "misses", trie.CacheMisses(), "unloads", trie.CacheUnloads())
 	return root, err
 }
@@ -748,6 +931,7 @@ func (s *StateDB) AppendXShardList(crossShardTxDeposit *types.CrossShardTransact
 	if s.xShardList == nil {
 		s.xShardList = make([]*types.CrossShardTransactionDeposit, 0)
 	}
+	s.journal.append(addXShardDepositChange{prev: len(s.xShardList)})
 	s.xShardList = append(s.xShardList, crossShardTxDeposit)
 }
 
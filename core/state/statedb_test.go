@@ -20,19 +20,25 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"io/ioutil"
 	"math"
 	"math/big"
 	"math/rand"
+	"os"
 	"reflect"
 	"strings"
 	"testing"
 	"testing/quick"
+	"time"
 
 	"gopkg.in/check.v1"
 
 	qkcaccount "github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/core/state/snapshot"
 	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/qkcdb"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
 )
 
@@ -436,3 +442,148 @@ func TestCopyOfCopy(t *testing.T) {
 		t.Fatalf("2nd copy fail, expected 42, got %v", got)
 	}
 }
+
+// TestSnapshotReads tests that the reads served by the state snapshot match the
+// trie, across the changes committed on top of the snapshot.
+func TestSnapshotReads(t *testing.T) {
+	dirname, err := ioutil.TempDir(os.TempDir(), "statedb_test_")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dirname)
+	db, err := qkcdb.NewLDBDatabase(dirname, false, false)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	var (
+		sdb   = NewDatabase(db)
+		addrs = make([]common.Address, 8)
+		keys  = []common.Hash{common.HexToHash("0x01"), common.HexToHash("0x02")}
+	)
+	state, _ := New(common.Hash{}, sdb)
+	for i := range addrs {
+		addrs[i] = common.BytesToAddress([]byte{byte(i + 1)})
+		state.SetBalance(addrs[i], big.NewInt(int64(i+1)), genesisTokenID)
+		state.SetNonce(addrs[i], uint64(i))
+		for j, key := range keys {
+			state.SetState(addrs[i], key, common.BigToHash(big.NewInt(int64(i*10+j+1))))
+		}
+	}
+	root, _ := state.Commit(false)
+	if err := sdb.TrieDB().Commit(root, false); err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	snaps := snapshot.New(db, sdb.TrieDB(), root)
+	defer snaps.Stop()
+	for _, addr := range addrs {
+		for {
+			if _, err := snaps.Snapshot(root).Account(crypto.Keccak256Hash(addr[:])); err != snapshot.ErrNotCoveredYet {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	compare := func(root common.Hash) {
+		snapState, _ := NewWithSnapshot(root, sdb, snaps)
+		if snapState.snap == nil {
+			t.Fatalf("snapshot of %x missing", root)
+		}
+		trieState, _ := New(root, sdb)
+		for _, addr := range addrs {
+			if have, want := snapState.Exist(addr), trieState.Exist(addr); have != want {
+				t.Errorf("%x existence mismatch: have %v, want %v", addr, have, want)
+			}
+			if have, want := snapState.GetBalance(addr, genesisTokenID), trieState.GetBalance(addr, genesisTokenID); have.Cmp(want) != 0 {
+				t.Errorf("%x balance mismatch: have %v, want %v", addr, have, want)
+			}
+			if have, want := snapState.GetNonce(addr), trieState.GetNonce(addr); have != want {
+				t.Errorf("%x nonce mismatch: have %v, want %v", addr, have, want)
+			}
+			for _, key := range keys {
+				if have, want := snapState.GetState(addr, key), trieState.GetState(addr, key); have != want {
+					t.Errorf("%x storage %x mismatch: have %x, want %x", addr, key, have, want)
+				}
+			}
+		}
+	}
+	compare(root)
+
+	// Commit a diff layer with updated, cleared, suicided and recreated accounts,
+	// the recreated account without changes is not written
+	state, _ = NewWithSnapshot(root, sdb, snaps)
+	state.AddBalance(addrs[0], big.NewInt(100), genesisTokenID)
+	state.SetState(addrs[1], keys[0], common.Hash{})
+	state.SetState(addrs[1], keys[1], common.HexToHash("0xff"))
+	state.Suicide(addrs[2])
+	state.Finalise(true)
+	state.CreateAccount(addrs[3])
+	state.SetState(addrs[3], keys[1], common.HexToHash("0xee"))
+	revision := state.Snapshot()
+	state.CreateAccount(addrs[4])
+	state.RevertToSnapshot(revision)
+	state.CreateAccount(addrs[5])
+	diffRoot, _ := state.Commit(true)
+	if snaps.Snapshot(diffRoot) == nil {
+		t.Fatalf("diff layer not added")
+	}
+	compare(diffRoot)
+
+	// Flatten the diff layer into the disk
+	if err := snaps.Cap(diffRoot, 0); err != nil {
+		t.Fatalf("failed to cap: %v", err)
+	}
+	compare(diffRoot)
+}
+
+// TestAccessListRevert tests that the additions to the access list are reverted
+// with the snapshots, and that the access list is reset for each transaction.
+func TestAccessListRevert(t *testing.T) {
+	var (
+		state, _ = New(common.Hash{}, NewDatabase(ethdb.NewMemDatabase()))
+		sender   = common.BytesToAddress([]byte{1})
+		dst      = common.BytesToAddress([]byte{2})
+		other    = common.BytesToAddress([]byte{3})
+		slot     = common.HexToHash("0x01")
+	)
+	state.PrepareAccessList(sender, &dst, nil)
+	if !state.AddressInAccessList(sender) || !state.AddressInAccessList(dst) {
+		t.Fatalf("sender and destination not in access list")
+	}
+	revision := state.Snapshot()
+	state.AddSlotToAccessList(other, slot)
+	state.AddSlotToAccessList(dst, slot)
+	if addrOk, slotOk := state.SlotInAccessList(other, slot); !addrOk || !slotOk {
+		t.Fatalf("slot not added: address %v slot %v", addrOk, slotOk)
+	}
+	cpy := state.Copy()
+	state.RevertToSnapshot(revision)
+	if state.AddressInAccessList(other) {
+		t.Fatalf("address addition not reverted")
+	}
+	if addrOk, slotOk := state.SlotInAccessList(dst, slot); !addrOk || slotOk {
+		t.Fatalf("slot addition not reverted: address %v slot %v", addrOk, slotOk)
+	}
+	if _, slotOk := cpy.SlotInAccessList(other, slot); !slotOk {
+		t.Fatalf("access list of the copy changed by the revert")
+	}
+
+	state.PrepareAccessList(other, nil, nil)
+	if state.AddressInAccessList(sender) || !state.AddressInAccessList(other) {
+		t.Fatalf("access list not reset for the next transaction")
+	}
+}
+
+func TestXShardListRevert(t *testing.T) {
+	state, _ := New(common.Hash{}, NewDatabase(ethdb.NewMemDatabase()))
+	state.AppendXShardList(&types.CrossShardTransactionDeposit{TxHash: common.HexToHash("0x01")})
+	revision := state.Snapshot()
+	state.AppendXShardList(&types.CrossShardTransactionDeposit{TxHash: common.HexToHash("0x02")})
+	state.AppendXShardList(&types.CrossShardTransactionDeposit{TxHash: common.HexToHash("0x03")})
+	state.RevertToSnapshot(revision)
+	if list := state.GetXShardList(); len(list) != 1 || list[0].TxHash != common.HexToHash("0x01") {
+		t.Fatalf("x-shard deposits not reverted: have %d deposits", len(list))
+	}
+}
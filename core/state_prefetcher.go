@@ -0,0 +1,36 @@
+package core
+
+import (
+	"sync/atomic"
+
+	"github.com/QuarkChain/goquarkchain/core/state"
+	"github.com/QuarkChain/goquarkchain/core/types"
+)
+
+// prefetchAccounts reads the senders and recipients of the txs of the block,
+// and the code of the recipients, from a copy of the state the block is run
+// on, ahead of the txs being applied. The reads only warm the trie node cache
+// of the shard and the db caches under it, so that the txs don't wait for the
+// disk. It stops once interrupt is set, when the block is run, and returns the
+// number of accounts read.
+func prefetchAccounts(block *types.MinorBlock, statedb *state.StateDB, interrupt *uint32) int {
+	read := 0
+	for _, tx := range block.Transactions() {
+		if atomic.LoadUint32(interrupt) == 1 {
+			break
+		}
+		if tx.TxType != types.EvmTx {
+			continue
+		}
+		// the senders are recovered once and cached in the txs
+		if sender, err := types.Sender(types.MakeSigner(tx.EvmTx.NetworkId()), tx.EvmTx); err == nil {
+			statedb.GetNonce(sender)
+			read++
+		}
+		if to := tx.EvmTx.To(); to != nil && !tx.EvmTx.IsCrossShard() {
+			statedb.GetCodeSize(*to)
+			read++
+		}
+	}
+	return read
+}
@@ -0,0 +1,52 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrefetchAccounts(t *testing.T) {
+	id1, err := account.CreatRandomIdentity()
+	checkErr(err)
+	acc1 := account.CreatAddressFromIdentity(id1, 0)
+	acc2, err := account.CreatRandomAccountWithFullShardKey(0)
+	checkErr(err)
+	acc3, err := account.CreatRandomAccountWithFullShardKey(0)
+	checkErr(err)
+
+	fakeMoney := uint64(10000000)
+	env := setUp(&acc1, &fakeMoney, nil)
+	shardState := createDefaultShardState(env, nil, nil, nil, nil)
+	defer shardState.Stop()
+	shardState.cacheConfig.Prefetch = true
+	rootBlock := shardState.rootTip.CreateBlockToAppend(nil, nil, nil, nil, nil).Finalize(nil, nil, common.Hash{})
+	_, err = shardState.AddRootBlock(rootBlock)
+	checkErr(err)
+
+	fakeGas := uint64(50000)
+	tx := createTransferTransaction(shardState, id1.GetKey().Bytes(), acc1, acc2, big.NewInt(12345), &fakeGas, nil, nil, nil, nil, nil)
+	checkErr(shardState.AddTx(tx))
+	block, err := shardState.CreateBlockToMine(nil, &acc3, nil, nil, nil)
+	checkErr(err)
+	assert.Len(t, block.Transactions(), 1)
+
+	// the sender and the recipient are read ahead
+	statedb, err := shardState.StateAt(shardState.CurrentBlock().GetMetaData().Root)
+	checkErr(err)
+	var interrupt uint32
+	assert.Equal(t, 2, prefetchAccounts(block, statedb, &interrupt))
+	interrupt = 1
+	assert.Equal(t, 0, prefetchAccounts(block, statedb, &interrupt))
+
+	// and the block runs the same with the prefetching
+	block, _, err = shardState.FinalizeAndAddBlock(block)
+	checkErr(err)
+	assert.Equal(t, block.Hash(), shardState.CurrentBlock().Hash())
+	state, err := shardState.State()
+	checkErr(err)
+	assert.Equal(t, uint64(12345), state.GetBalance(acc2.Recipient, testGenesisTokenID).Uint64())
+}
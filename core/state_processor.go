@@ -28,6 +28,8 @@ import (
 	"github.com/QuarkChain/goquarkchain/core/types"
 	"github.com/QuarkChain/goquarkchain/core/vm"
 	qkcParam "github.com/QuarkChain/goquarkchain/params"
+	"github.com/QuarkChain/goquarkchain/serialize"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/params"
 )
 
@@ -71,19 +73,31 @@ func (p *StateProcessor) Process(block *types.MinorBlock, statedb *state.StateDB
 		xGas     = block.GetXShardGasLimit().Uint64()
 	)
 
-	// Iterate over and process the individual transactions
-	for i, tx := range block.GetTransactions() {
-		evmTx, err := p.bc.validateTx(tx, statedb, nil, nil, &xGas)
+	// Execute the non-conflicting transactions in parallel if enabled
+	if workers := p.bc.clusterConfig.ParallelTxWorkers; workers > 1 && !cfg.Debug && len(block.GetTransactions()) > 1 {
+		var err error
+		receipts, err = newParallelExecutor(p, block, statedb, cfg, gp, usedGas, &xGas, workers).run()
 		if err != nil {
 			return nil, nil, 0, err
 		}
-		statedb.Prepare(tx.Hash(), block.Hash(), i)
-		_, receipt, _, err := ApplyTransaction(p.config, p.bc, gp, statedb, header, evmTx, usedGas, cfg)
-		if err != nil {
-			return nil, nil, 0, err
+		for _, receipt := range receipts {
+			allLogs = append(allLogs, receipt.Logs...)
+		}
+	} else {
+		// Iterate over and process the individual transactions
+		for i, tx := range block.GetTransactions() {
+			evmTx, err := p.bc.validateTx(tx, statedb, nil, nil, &xGas)
+			if err != nil {
+				return nil, nil, 0, err
+			}
+			statedb.Prepare(tx.Hash(), block.Hash(), i)
+			_, receipt, _, err := ApplyTransaction(p.config, p.bc, gp, statedb, header, evmTx, usedGas, cfg)
+			if err != nil {
+				return nil, nil, 0, err
+			}
+			receipts = append(receipts, receipt)
+			allLogs = append(allLogs, receipt.Logs...)
 		}
-		receipts = append(receipts, receipt)
-		allLogs = append(allLogs, receipt.Logs...)
 	}
 
 	// Finalize the block, applying any consensus engine specific extras (e.g. block rewards)
@@ -117,7 +131,8 @@ func ValidateTransaction(state vm.StateDB, tx *types.Transaction, fromAddress *a
 		return ErrNonceTooLow
 	}
 
-	totalGas, err := IntrinsicGas(tx.EvmTx.Data(), tx.EvmTx.To() == nil, tx.EvmTx.ToFullShardId() != tx.EvmTx.FromFullShardId())
+	isIstanbul := state.GetQuarkChainConfig().IsEvmIstanbul(tx.EvmTx.FromChainID(), state.GetTimeStamp())
+	totalGas, err := IntrinsicGas(tx.EvmTx.Data(), tx.EvmTx.To() == nil, tx.EvmTx.ToFullShardId() != tx.EvmTx.FromFullShardId(), isIstanbul)
 	if err != nil {
 		return err
 	}
@@ -193,6 +208,71 @@ func ApplyTransaction(config *params.ChainConfig, bc ChainContext, gp *GasPool,
 	return ret, receipt, gas, err
 }
 
+func xShardGasUsedStart(tx *types.CrossShardTransactionDeposit, checkIsFromRootChain bool) uint64 {
+	if checkIsFromRootChain {
+		if tx.IsFromRootChain {
+			return 0
+		}
+	} else {
+		if tx.GasPrice.Value.Cmp(big.NewInt(0)) == 0 {
+			return 0
+		}
+	}
+	return qkcParam.GtxxShardCost.Uint64()
+}
+
+// canRefundXShardDeposit returns whether the remaining gas of the deposit pays
+// for the x-shard cost of its refund.
+func canRefundXShardDeposit(tx *types.CrossShardTransactionDeposit) bool {
+	return tx.GasRemained.Value.Cmp(qkcParam.GtxxShardCost) >= 0
+}
+
+// RefundCrossShardDeposit returns the value and the remaining gas of an expired
+// cross-shard deposit to the sender on its source shard instead of applying it,
+// with a new deposit sent back from the block. The x-shard cost of the deposit
+// is charged here. The x-shard cost of the refund is taken out of the remaining
+// gas, as it is out of the gas of a tx sending a deposit, and is credited to the
+// coinbase of the source shard once it receives the refund. The remaining gas
+// of the deposit must pay for it, see canRefundXShardDeposit.
+func RefundCrossShardDeposit(evmState *state.StateDB, tx *types.CrossShardTransactionDeposit, usedGas *uint64,
+	checkIsFromRootChain bool) *types.Receipt {
+
+	gasUsedStart := xShardGasUsedStart(tx, checkIsFromRootChain)
+	quarkChainConfig := evmState.GetQuarkChainConfig()
+
+	gasRemained := new(big.Int).Sub(tx.GasRemained.Value, qkcParam.GtxxShardCost)
+	evmState.AppendXShardList(&types.CrossShardTransactionDeposit{
+		TxHash: crypto.Keccak256Hash(tx.TxHash.Bytes(), []byte("refund")),
+		From: account.Address{
+			Recipient:    tx.From.Recipient,
+			FullShardKey: tx.To.FullShardKey,
+		},
+		To:              tx.From,
+		Value:           &serialize.Uint256{Value: new(big.Int).Set(tx.Value.Value)},
+		GasPrice:        &serialize.Uint256{Value: new(big.Int).Set(tx.GasPrice.Value)},
+		GasRemained:     &serialize.Uint256{Value: gasRemained},
+		GasTokenID:      tx.GasTokenID,
+		TransferTokenID: tx.TransferTokenID,
+	})
+
+	xShardFee := new(big.Int).Mul(tx.GasPrice.Value, new(big.Int).SetUint64(gasUsedStart))
+	xShardFee = new(big.Int).Mul(xShardFee, quarkChainConfig.LocalFeeRate.Num())
+	xShardFee = new(big.Int).Div(xShardFee, quarkChainConfig.LocalFeeRate.Denom())
+	evmState.AddBlockFee(map[uint64]*big.Int{
+		tx.GasTokenID: xShardFee,
+	})
+	evmState.AddBalance(evmState.GetBlockCoinbase(), xShardFee, tx.GasTokenID)
+	evmState.AddGasUsed(new(big.Int).SetUint64(gasUsedStart))
+	*usedGas += gasUsedStart
+
+	receipt := types.NewReceipt(nil, true, *usedGas)
+	receipt.TxHash = tx.TxHash
+	receipt.GasUsed = gasUsedStart
+	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+	receipt.ContractFullShardKey = tx.To.FullShardKey
+	return receipt
+}
+
 func ApplyCrossShardDeposit(config *params.ChainConfig, bc ChainContext, header types.IHeader, cfg vm.Config,
 	evmState *state.StateDB, tx *types.CrossShardTransactionDeposit, usedGas *uint64,
 	checkIsFromRootChain bool, txIndex int) (*types.Receipt, error) {
@@ -202,19 +282,10 @@ func ApplyCrossShardDeposit(config *params.ChainConfig, bc ChainContext, header
 		fail bool
 		err  error
 	)
-	gasUsedStart := qkcParam.GtxxShardCost.Uint64()
-	if checkIsFromRootChain {
-		if tx.IsFromRootChain {
-			gasUsedStart = 0
-		}
-	} else {
-		if tx.GasPrice.Value.Cmp(big.NewInt(0)) == 0 {
-			gasUsedStart = 0
-		}
-	}
+	gasUsedStart := xShardGasUsedStart(tx, checkIsFromRootChain)
 
 	quarkChainConfig := evmState.GetQuarkChainConfig()
-	if evmState.GetTimeStamp() < quarkChainConfig.EnableEvmTimeStamp {
+	if !quarkChainConfig.IsEvmEnabled(evmState.GetTimeStamp()) {
 		//TODO:FIXME:full_shard_key is not set
 		evmState.AddBalance(tx.To.Recipient, tx.Value.Value, tx.TransferTokenID)
 		evmState.AddGasUsed(new(big.Int).SetUint64(gasUsedStart))
@@ -238,6 +309,7 @@ func ApplyCrossShardDeposit(config *params.ChainConfig, bc ChainContext, header
 		tx.From.FullShardKey, &tx.To.FullShardKey, tx.TransferTokenID, tx.GasTokenID)
 	context := NewEVMContext(msg, header, bc)
 	context.IsApplyXShard = true
+	context.TxHash = tx.TxHash
 	context.XShardGasUsedStart = gasUsedStart
 	if tx.CreateContract {
 		context.ContractAddress = &tx.To.Recipient
@@ -250,7 +322,7 @@ func ApplyCrossShardDeposit(config *params.ChainConfig, bc ChainContext, header
 		return nil, err
 	}
 	*usedGas += gas
-	if evmState.GetTimeStamp() >= quarkChainConfig.EnableEvmTimeStamp {
+	if quarkChainConfig.IsEvmEnabled(evmState.GetTimeStamp()) {
 		var root []byte
 		receipt := types.NewReceipt(root, fail, *usedGas)
 		receipt.TxHash = tx.TxHash
@@ -0,0 +1,142 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/cluster/config"
+	"github.com/QuarkChain/goquarkchain/core/state"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/core/vm"
+	qkcParam "github.com/QuarkChain/goquarkchain/params"
+	"github.com/QuarkChain/goquarkchain/serialize"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestRefundCrossShardDeposit(t *testing.T) {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	qkcConfig := config.NewQuarkChainConfig()
+	statedb.SetQuarkChainConfig(qkcConfig)
+	coinbase := account.BytesToIdentityRecipient([]byte{0x01})
+	statedb.SetBlockCoinbase(coinbase)
+
+	from := account.Address{Recipient: account.BytesToIdentityRecipient([]byte{0x12}), FullShardKey: 0}
+	to := account.Address{Recipient: account.BytesToIdentityRecipient([]byte{0x13}), FullShardKey: 1}
+	tokenID := qkcConfig.GetDefaultChainTokenID()
+	deposit := &types.CrossShardTransactionDeposit{
+		From:            from,
+		To:              to,
+		Value:           &serialize.Uint256{Value: big.NewInt(100)},
+		GasPrice:        &serialize.Uint256{Value: big.NewInt(2)},
+		GasRemained:     &serialize.Uint256{Value: big.NewInt(30000)},
+		GasTokenID:      tokenID,
+		TransferTokenID: tokenID,
+	}
+	usedGas := uint64(0)
+	receipt := RefundCrossShardDeposit(statedb, deposit, &usedGas, true)
+
+	xShardCost := qkcParam.GtxxShardCost.Uint64()
+	if receipt.Status != types.ReceiptStatusFailed || receipt.GasUsed != xShardCost || usedGas != xShardCost {
+		t.Fatalf("unexpected receipt %v with used gas %d", receipt, usedGas)
+	}
+	if balance := statedb.GetBalance(to.Recipient, tokenID); balance.Sign() != 0 {
+		t.Fatalf("recipient should not receive expired deposit, got %d", balance)
+	}
+	if balance := statedb.GetBalance(from.Recipient, tokenID); balance.Sign() != 0 {
+		t.Fatalf("sender should be refunded on its own shard, got %d", balance)
+	}
+	xShardList := statedb.GetXShardList()
+	if len(xShardList) != 1 {
+		t.Fatalf("expected 1 refund deposit, got %d", len(xShardList))
+	}
+	refund := xShardList[0]
+	if refund.From.Recipient != from.Recipient || refund.From.FullShardKey != to.FullShardKey || refund.To != from {
+		t.Fatalf("refund should be sent back to the sender on its shard, got %v -> %v", refund.From, refund.To)
+	}
+	if refund.Value.Value.Cmp(big.NewInt(100)) != 0 || refund.GasPrice.Value.Cmp(big.NewInt(2)) != 0 ||
+		refund.GasRemained.Value.Uint64() != 30000-xShardCost {
+		t.Fatalf("unexpected refund value %d gas price %d remaining gas %d",
+			refund.Value.Value, refund.GasPrice.Value, refund.GasRemained.Value)
+	}
+	if refund.TxHash == deposit.TxHash || refund.GasTokenID != tokenID || refund.TransferTokenID != tokenID {
+		t.Fatalf("unexpected refund %v", refund)
+	}
+	xShardFee := new(big.Int).SetUint64(xShardCost * 2)
+	xShardFee.Mul(xShardFee, qkcConfig.LocalFeeRate.Num())
+	xShardFee.Div(xShardFee, qkcConfig.LocalFeeRate.Denom())
+	if balance := statedb.GetBalance(coinbase, tokenID); balance.Cmp(xShardFee) != 0 {
+		t.Fatalf("coinbase should receive x-shard fee %d, got %d", xShardFee, balance)
+	}
+
+	// the x-shard cost taken out of the remaining gas is credited to the
+	// coinbase of the source shard receiving the refund
+	srcState, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	srcState.SetQuarkChainConfig(qkcConfig)
+	srcCoinbase := account.BytesToIdentityRecipient([]byte{0x04})
+	srcState.SetBlockCoinbase(srcCoinbase)
+	srcState.SetTimeStamp(qkcConfig.EnableEvmTimeStamp)
+	srcState.SetGasLimit(big.NewInt(1000000))
+	header := &types.MinorBlockHeader{
+		Coinbase:   account.Address{Recipient: srcCoinbase},
+		Time:       qkcConfig.EnableEvmTimeStamp,
+		Difficulty: big.NewInt(1),
+		GasLimit:   &serialize.Uint256{Value: srcState.GetGasLimit()},
+	}
+	usedGas = 0
+	_, err := ApplyCrossShardDeposit(params.TestChainConfig, nil, header, vm.Config{}, srcState, refund, &usedGas, true, 0)
+	if err != nil {
+		t.Fatalf("failed to receive refund: %v", err)
+	}
+	if usedGas != xShardCost {
+		t.Fatalf("refund should use the x-shard cost only, used %d", usedGas)
+	}
+	refundedGas := new(big.Int).SetUint64((30000 - xShardCost) * 2)
+	if balance := srcState.GetBalance(from.Recipient, tokenID); balance.Cmp(new(big.Int).Add(big.NewInt(100), refundedGas)) != 0 {
+		t.Fatalf("sender should get the value and the remaining gas back, got %d", balance)
+	}
+	srcFee := new(big.Int).SetUint64(xShardCost * 2)
+	srcFee.Mul(srcFee, qkcConfig.LocalFeeRate.Num())
+	srcFee.Div(srcFee, qkcConfig.LocalFeeRate.Denom())
+	if balance := srcState.GetBalance(srcCoinbase, tokenID); balance.Cmp(srcFee) != 0 {
+		t.Fatalf("source coinbase should receive x-shard fee %d, got %d", srcFee, balance)
+	}
+}
+
+func TestCanRefundXShardDeposit(t *testing.T) {
+	xShardCost := qkcParam.GtxxShardCost.Uint64()
+	for _, c := range []struct {
+		gasRemained uint64
+		refund      bool
+	}{
+		{gasRemained: 0, refund: false},
+		{gasRemained: xShardCost - 1, refund: false},
+		{gasRemained: xShardCost, refund: true},
+		{gasRemained: 30000, refund: true},
+	} {
+		deposit := &types.CrossShardTransactionDeposit{GasRemained: &serialize.Uint256{Value: new(big.Int).SetUint64(c.gasRemained)}}
+		if refund := canRefundXShardDeposit(deposit); refund != c.refund {
+			t.Errorf("gas remained %d: refund %v, want %v", c.gasRemained, refund, c.refund)
+		}
+	}
+}
+
+func TestXShardDepositExpiredAt(t *testing.T) {
+	for _, c := range []struct {
+		rootHeight, builtOnRootHeight, depth uint64
+		expired                              bool
+	}{
+		{rootHeight: 10, builtOnRootHeight: 10, depth: 5, expired: false},
+		{rootHeight: 10, builtOnRootHeight: 15, depth: 5, expired: false},
+		{rootHeight: 10, builtOnRootHeight: 16, depth: 5, expired: true},
+		// a block jumping to a new root block doesn't expire the deposits it confirms
+		{rootHeight: 100, builtOnRootHeight: 10, depth: 5, expired: false},
+	} {
+		if expired := isXShardDepositExpiredAt(c.rootHeight, c.builtOnRootHeight, c.depth); expired != c.expired {
+			t.Errorf("deposit at %d built on %d with depth %d: expired %v, want %v",
+				c.rootHeight, c.builtOnRootHeight, c.depth, expired, c.expired)
+		}
+	}
+}
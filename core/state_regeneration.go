@@ -0,0 +1,168 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	qkcCommon "github.com/QuarkChain/goquarkchain/common"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// MaxStateRegeneration bounds the blocks searched for the nearest state, and so
+// the blocks replayed to regenerate a state.
+const MaxStateRegeneration = 8192
+
+// StateAvailability tells whether the state of the canonical block at the
+// height is in the database, and if not, the nearest lower block with its
+// state within MaxStateRegeneration blocks, from which it can be regenerated.
+func (m *MinorBlockChain) StateAvailability(number uint64) (*rpc.StateAvailability, error) {
+	block, err := m.canonicalBlock(number)
+	if err != nil {
+		return nil, err
+	}
+	availability := &rpc.StateAvailability{
+		Number:    number,
+		Hash:      block.Hash(),
+		StateRoot: block.Root(),
+		Available: m.HasState(block.Root()),
+	}
+	if availability.Available {
+		availability.NearestAvailable, availability.NearestFound = number, true
+		return availability, nil
+	}
+	for n := number; n > 0 && number-n < MaxStateRegeneration; {
+		n--
+		parent, err := m.canonicalBlock(n)
+		if err != nil {
+			return nil, err
+		}
+		if m.HasState(parent.Root()) {
+			availability.NearestAvailable, availability.NearestFound = n, true
+			break
+		}
+	}
+	return availability, nil
+}
+
+// stateRegeneration is the regeneration of a state running in the background,
+// or the last one finished until its outcome is returned.
+type stateRegeneration struct {
+	mu  sync.Mutex
+	job *rpc.RegenerateStateResponse
+}
+
+// RegenerateState starts writing the missing state of the canonical block at
+// the height to the database in the background, by replaying the blocks on top
+// of the nearest state available, whose states are written too, and returns
+// its progress. A replayed block whose state root differs from the stored one
+// fails the regeneration. While it runs, calling it again with the same height
+// returns its progress, and with another height fails. Once it's done, the
+// next call with the same height returns its outcome, and the following ones
+// start over.
+func (m *MinorBlockChain) RegenerateState(number uint64) (*rpc.RegenerateStateResponse, error) {
+	r := m.regeneration
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if job := r.job; job != nil {
+		if job.Number != number {
+			if !job.Done {
+				return nil, fmt.Errorf("regenerating state of block %d", job.Number)
+			}
+		} else {
+			rsp := *job
+			if job.Done {
+				r.job = nil
+			}
+			return &rsp, nil
+		}
+	}
+
+	availability, err := m.StateAvailability(number)
+	if err != nil {
+		return nil, err
+	}
+	rsp := &rpc.RegenerateStateResponse{
+		Number:    number,
+		From:      availability.NearestAvailable,
+		StateRoot: availability.StateRoot,
+		Done:      availability.Available,
+	}
+	if rsp.Done {
+		return rsp, nil
+	}
+	if !availability.NearestFound {
+		return nil, fmt.Errorf("no state within %d blocks below block %d", MaxStateRegeneration, number)
+	}
+	job := *rsp
+	r.job = &job
+	m.wg.Add(1)
+	go m.regenerateState(&job)
+	return rsp, nil
+}
+
+// regenerateState replays the blocks of the job, updating its progress, until
+// it's done or the chain is stopped.
+func (m *MinorBlockChain) regenerateState(job *rpc.RegenerateStateResponse) {
+	defer m.wg.Done()
+
+	log.Info("Regenerating state", "branch", m.branch.Value, "number", job.Number, "from", job.From)
+	var err error
+	for n := job.From + 1; n <= job.Number && err == nil; n++ {
+		if m.getProcInterrupt() {
+			err = errors.New("chain stopped")
+		} else if err = m.regenerateBlockState(n); err == nil {
+			m.regeneration.mu.Lock()
+			job.Regenerated++
+			m.regeneration.mu.Unlock()
+		}
+	}
+
+	m.regeneration.mu.Lock()
+	defer m.regeneration.mu.Unlock()
+	job.Done = true
+	if err != nil {
+		job.Error = err.Error()
+		log.Error("Failed to regenerate state", "branch", m.branch.Value, "number", job.Number, "blocks", job.Regenerated, "err", err)
+		return
+	}
+	log.Info("Regenerated state", "branch", m.branch.Value, "number", job.Number, "blocks", job.Regenerated)
+}
+
+// regenerateBlockState re-executes the canonical block at the height on the
+// state of its parent, and writes the state to the database. The insertion
+// lock is held per block, so that the blocks keep being added meanwhile.
+func (m *MinorBlockChain) regenerateBlockState(number uint64) error {
+	m.chainmu.Lock()
+	defer m.chainmu.Unlock()
+
+	block, err := m.canonicalBlock(number)
+	if err != nil {
+		return err
+	}
+	if m.HasState(block.Root()) {
+		return nil
+	}
+	evmState, _, _, _, _, err := m.runBlock(block)
+	if err != nil {
+		return fmt.Errorf("failed to replay block %d: %v", number, err)
+	}
+	root, err := evmState.Commit(true)
+	if err != nil {
+		return err
+	}
+	if root != block.Root() {
+		return fmt.Errorf("replayed block %d to state root %x, want %x", number, root, block.Root())
+	}
+	return m.stateCache.TrieDB().Commit(root, false)
+}
+
+func (m *MinorBlockChain) canonicalBlock(number uint64) (*types.MinorBlock, error) {
+	block, ok := m.GetBlockByNumber(number).(*types.MinorBlock)
+	if !ok || qkcCommon.IsNil(block) {
+		return nil, fmt.Errorf("block %d not found", number)
+	}
+	return block, nil
+}
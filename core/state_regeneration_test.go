@@ -0,0 +1,114 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateRegeneration(t *testing.T) {
+	id1, err := account.CreatRandomIdentity()
+	checkErr(err)
+	acc1 := account.CreatAddressFromIdentity(id1, 0)
+	acc2, err := account.CreatRandomAccountWithFullShardKey(0)
+	checkErr(err)
+
+	fakeMoney := uint64(10000000)
+	env := setUp(&acc1, &fakeMoney, nil)
+	shardState := createDefaultShardState(env, nil, nil, nil, nil)
+	defer shardState.Stop()
+	// the states of the blocks older than triesInMemory are pruned
+	shardState.cacheConfig.Disabled = false
+	rootBlock := shardState.rootTip.CreateBlockToAppend(nil, nil, nil, nil, nil).Finalize(nil, nil, common.Hash{})
+	_, err = shardState.AddRootBlock(rootBlock)
+	checkErr(err)
+	for i := 0; i < triesInMemory+10; i++ {
+		createTime := shardState.CurrentBlock().Time() + 1
+		b, err := shardState.CreateBlockToMine(&createTime, &acc2, nil, nil, nil)
+		checkErr(err)
+		_, _, err = shardState.FinalizeAndAddBlock(b)
+		checkErr(err)
+	}
+
+	head := shardState.CurrentBlock().NumberU64()
+	availability, err := shardState.StateAvailability(head)
+	checkErr(err)
+	assert.True(t, availability.Available)
+	assert.Equal(t, head, availability.NearestAvailable)
+
+	block := shardState.GetBlockByNumber(5).(*types.MinorBlock)
+	blockHash := block.Hash()
+	availability, err = shardState.StateAvailability(5)
+	checkErr(err)
+	assert.False(t, availability.Available)
+	assert.Equal(t, block.Hash(), availability.Hash)
+	assert.Equal(t, block.Root(), availability.StateRoot)
+	assert.True(t, availability.NearestFound)
+	assert.Equal(t, uint64(0), availability.NearestAvailable)
+	_, err = shardState.getEvmStateByHash(&blockHash)
+	assert.Error(t, err)
+
+	// the states are regenerated from the genesis one in the background, with
+	// the insertion lock held it can't finish meanwhile
+	shardState.chainmu.Lock()
+	rsp, err := shardState.RegenerateState(5)
+	checkErr(err)
+	assert.False(t, rsp.Done)
+	assert.Equal(t, uint64(5), rsp.Number)
+	assert.Equal(t, uint64(0), rsp.From)
+	assert.Equal(t, block.Root(), rsp.StateRoot)
+	_, err = shardState.RegenerateState(8)
+	assert.Error(t, err)
+	rsp, err = shardState.RegenerateState(5)
+	checkErr(err)
+	assert.False(t, rsp.Done)
+	shardState.chainmu.Unlock()
+	rsp = waitStateRegeneration(t, shardState, 5)
+	assert.True(t, rsp.Done)
+	assert.Empty(t, rsp.Error)
+	assert.Equal(t, uint64(5), rsp.Regenerated)
+	for n := uint64(1); n <= 5; n++ {
+		availability, err = shardState.StateAvailability(n)
+		checkErr(err)
+		assert.True(t, availability.Available)
+	}
+	_, err = shardState.getEvmStateByHash(&blockHash)
+	assert.NoError(t, err)
+
+	// and from the nearest state available
+	_, err = shardState.RegenerateState(8)
+	checkErr(err)
+	rsp = waitStateRegeneration(t, shardState, 8)
+	assert.Equal(t, uint64(5), rsp.From)
+	assert.Equal(t, uint64(3), rsp.Regenerated)
+	rsp, err = shardState.RegenerateState(8)
+	checkErr(err)
+	assert.True(t, rsp.Done)
+	assert.Equal(t, uint64(0), rsp.Regenerated)
+
+	_, err = shardState.StateAvailability(head + 1)
+	assert.Error(t, err)
+	_, err = shardState.RegenerateState(head + 1)
+	assert.Error(t, err)
+}
+
+// waitStateRegeneration returns the outcome of the regeneration of the state
+// of the block at the height.
+func waitStateRegeneration(t *testing.T, shardState *MinorBlockChain, number uint64) *rpc.RegenerateStateResponse {
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		rsp, err := shardState.RegenerateState(number)
+		checkErr(err)
+		if rsp.Done {
+			return rsp
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("state of block %d not regenerated", number)
+	return nil
+}
@@ -87,7 +87,7 @@ type Message interface {
 }
 
 // IntrinsicGas computes the 'intrinsic gas' for a message with the given data.
-func IntrinsicGas(data []byte, contractCreation, isCrossShard bool) (uint64, error) {
+func IntrinsicGas(data []byte, contractCreation, isCrossShard, isIstanbul bool) (uint64, error) {
 	// Set the starting gas for the raw transaction
 	var gas uint64
 	if contractCreation {
@@ -105,10 +105,14 @@ func IntrinsicGas(data []byte, contractCreation, isCrossShard bool) (uint64, err
 			}
 		}
 		// Make sure we don't exceed uint64 for all data combinations
-		if (math.MaxUint64-gas)/params.TxDataNonZeroGas < nz {
+		nonZeroGas := params.TxDataNonZeroGas
+		if isIstanbul {
+			nonZeroGas = qkcParam.TxDataNonZeroGasEIP2028
+		}
+		if (math.MaxUint64-gas)/nonZeroGas < nz {
 			return 0, vm.ErrOutOfGas
 		}
-		gas += nz * params.TxDataNonZeroGas
+		gas += nz * nonZeroGas
 		z := uint64(len(data)) - nz
 		if (math.MaxUint64-gas)/params.TxDataZeroGas < z {
 			return 0, vm.ErrOutOfGas
@@ -214,7 +218,7 @@ func (st *StateTransition) TransitionDb() (ret []byte, usedGas uint64, failed bo
 		if err = st.preCheck(); err != nil {
 			return
 		}
-		gas, err = IntrinsicGas(st.data, contractCreation, msg.IsCrossShard())
+		gas, err = IntrinsicGas(st.data, contractCreation, msg.IsCrossShard(), evm.IsIstanbul)
 		if err != nil {
 			return nil, 0, false, err
 		}
@@ -222,12 +226,16 @@ func (st *StateTransition) TransitionDb() (ret []byte, usedGas uint64, failed bo
 	if err = st.useGas(gas); err != nil {
 		return nil, 0, false, err
 	}
+	if evm.IsBerlin {
+		st.state.PrepareAccessList(msg.From(), msg.To(), evm.ActivePrecompiles())
+	}
 
 	sender := vm.AccountRef(msg.From())
 	if msg.IsCrossShard() {
 		st.state.SetNonce(msg.From(), st.state.GetNonce(sender.Address())+1)
 		return st.AddCrossShardTxDeposit(gas)
 	}
+	depositStart := len(st.state.GetXShardList())
 	if contractCreation || evm.ContractAddress != nil {
 		ret, _, st.gas, vmerr = evm.Create(sender, st.data, st.gas, st.value, evm.ContractAddress)
 	} else {
@@ -252,18 +260,30 @@ func (st *StateTransition) TransitionDb() (ret []byte, usedGas uint64, failed bo
 			return nil, 0, false, vmerr
 		}
 	}
-	st.refundGas(vmerr)
-	st.chargeFee(st.gasUsed())
+	xShardCallGas := st.xShardCallGas(depositStart)
+	st.refundGas(vmerr, xShardCallGas)
+	st.chargeFee(st.gasUsed() - xShardCallGas)
 	if vmerr == vm.ErrPoSWSenderNotAllowed {
 		return nil, st.gasUsed(), true, nil
 	}
 	return ret, st.gasUsed(), vmerr != nil, err
 }
 
-func (st *StateTransition) refundGas(vmerr error) {
-	// Apply refund counter, capped to half of the used gas.
+// xShardCallGas returns the gas reserved for the target shards by the deposits
+// sent by the contracts from the start index of the x-shard list.
+func (st *StateTransition) xShardCallGas(depositStart int) uint64 {
+	gas := uint64(0)
+	for _, deposit := range st.state.GetXShardList()[depositStart:] {
+		gas += qkcParam.GtxxShardCost.Uint64() + deposit.GasRemained.Value.Uint64()
+	}
+	return gas
+}
+
+func (st *StateTransition) refundGas(vmerr error, reservedGas uint64) {
+	// Apply refund counter, capped to half of the used gas, excluding the gas
+	// reserved for the target shards.
 	if vmerr == nil {
-		refund := st.gasUsed() / 2
+		refund := (st.gasUsed() - reservedGas) / 2
 		if refund > st.state.GetRefund() {
 			refund = st.state.GetRefund()
 		}
@@ -346,7 +366,7 @@ func (st *StateTransition) AddCrossShardTxDeposit(intrinsicGas uint64) (ret []by
 		crossShardGas := new(serialize.Uint256)
 		crossShardGas.Value = new(big.Int)
 
-		if state.GetTimeStamp() >= state.GetQuarkChainConfig().EnableEvmTimeStamp {
+		if state.GetQuarkChainConfig().IsEvmEnabled(state.GetTimeStamp()) {
 			remoteGasReserved = msg.Gas() - intrinsicGas
 			crossShardGas.Value = new(big.Int).SetUint64(remoteGasReserved)
 		}
@@ -393,7 +413,7 @@ func (st *StateTransition) chargeFee(gasUsed uint64) {
 	blockFee := make(map[uint64]*big.Int)
 	blockFee[st.msg.GasTokenID()] = rateFee
 	st.state.AddBlockFee(blockFee)
-	if st.state.GetTimeStamp() >= st.state.GetQuarkChainConfig().EnableEvmTimeStamp {
+	if st.state.GetQuarkChainConfig().IsEvmEnabled(st.state.GetTimeStamp()) {
 		st.state.AddGasUsed(new(big.Int).SetUint64(gasUsed))
 		return
 	}
@@ -19,6 +19,7 @@ package core
 import (
 	"github.com/QuarkChain/goquarkchain/core/types"
 	"runtime"
+	"sync"
 )
 
 // senderCacher is a concurrent transaction sender recoverer anc cacher.
@@ -29,11 +30,13 @@ var senderCacher = newTxSenderCacher(runtime.NumCPU())
 //
 // The inc field defines the number of transactions to skip after each recovery,
 // which is used to feed the same underlying input array to different threads but
-// ensure they process the early transactions fast.
+// ensure they process the early transactions fast. The done field, if set, is
+// signalled once the request is processed.
 type txSenderCacherRequest struct {
 	signer types.Signer
 	txs    []*types.Transaction
 	inc    int
+	done   *sync.WaitGroup
 }
 
 // txSenderCacher is a helper structure to concurrently ecrecover transaction
@@ -63,6 +66,9 @@ func (cacher *txSenderCacher) cache() {
 		for i := 0; i < len(task.txs); i += task.inc {
 			types.Sender(task.signer, task.txs[i].EvmTx)
 		}
+		if task.done != nil {
+			task.done.Done()
+		}
 	}
 }
 
@@ -70,6 +76,26 @@ func (cacher *txSenderCacher) cache() {
 // back into the same data structures. There is no validation being done, nor
 // any reaction to invalid signatures. That is up to calling code later.
 func (cacher *txSenderCacher) recover(signer types.Signer, txs []*types.Transaction) {
+	cacher.schedule(signer, txs, nil)
+}
+
+// recoverSync recovers the senders from a batch of transactions in parallel like
+// recover, but waits for all of them to be cached before returning. It must not
+// be called from the cacher's own threads.
+func (cacher *txSenderCacher) recoverSync(signer types.Signer, txs []*types.Transaction) {
+	// A single transaction is not worth the hand-off
+	if len(txs) == 1 {
+		types.Sender(signer, txs[0].EvmTx)
+		return
+	}
+	var done sync.WaitGroup
+	cacher.schedule(signer, txs, &done)
+	done.Wait()
+}
+
+// schedule splits the recovery of the senders of a batch of transactions over
+// the threads, adding the tasks scheduled to done if it is set.
+func (cacher *txSenderCacher) schedule(signer types.Signer, txs []*types.Transaction, done *sync.WaitGroup) {
 	// If there's nothing to recover, abort
 	if len(txs) == 0 {
 		return
@@ -79,11 +105,15 @@ func (cacher *txSenderCacher) recover(signer types.Signer, txs []*types.Transact
 	if len(txs) < tasks*4 {
 		tasks = (len(txs) + 3) / 4
 	}
+	if done != nil {
+		done.Add(tasks)
+	}
 	for i := 0; i < tasks; i++ {
 		cacher.tasks <- &txSenderCacherRequest{
 			signer: signer,
 			txs:    txs[i:],
 			inc:    tasks,
+			done:   done,
 		}
 	}
 }
@@ -0,0 +1,35 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestSenderCacherRecoverSync(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	signer := types.MakeSigner(3)
+	txs := make([]*types.Transaction, 100)
+	for i := range txs {
+		txs[i] = transaction(uint64(i), 100000, key)
+	}
+	cacher := newTxSenderCacher(4)
+	cacher.recoverSync(signer, txs)
+
+	// all the senders are cached when it returns
+	_, misses, _ := types.SenderCacheStats()
+	for i, tx := range txs {
+		sender, err := types.Sender(signer, tx.EvmTx)
+		if err != nil {
+			t.Fatalf("tx %d: %v", i, err)
+		}
+		if sender != from {
+			t.Errorf("tx %d: got sender %x, want %x", i, sender, from)
+		}
+	}
+	if _, m, _ := types.SenderCacheStats(); m != misses {
+		t.Errorf("got %d senders recovered again, want none", m-misses)
+	}
+}
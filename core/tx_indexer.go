@@ -0,0 +1,185 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/QuarkChain/goquarkchain/core/rawdb"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// txIndexer maintains the transaction lookup and history indexes of the minor
+// chain in the background. Blocks are indexed when they become canonical, the
+// indexer only unindexes blocks falling out of the latest retention blocks and
+// indexes them again if the retention grows, 0 retention keeps all of them.
+type txIndexer struct {
+	chain     *MinorBlockChain
+	retention uint64
+}
+
+func newTxIndexer(chain *MinorBlockChain, retention uint64) *txIndexer {
+	return &txIndexer{
+		chain:     chain,
+		retention: retention,
+	}
+}
+
+// loop updates the indexes on every new head until the chain is stopped.
+func (indexer *txIndexer) loop() {
+	indexLoop(indexer.chain, indexer.run)
+}
+
+// indexLoop calls run with the chain head on start and every new head until
+// the chain is stopped. run is called in another goroutine so that a long run
+// never blocks the chain head feed, heads arriving during a run are merged
+// into the latest one.
+func indexLoop(chain *MinorBlockChain, run func(head uint64)) {
+	defer chain.wg.Done()
+
+	headCh := make(chan MinorChainHeadEvent, 10)
+	sub := chain.SubscribeChainHeadEvent(headCh)
+	if sub == nil {
+		// chain is stopped already
+		return
+	}
+	defer sub.Unsubscribe()
+
+	var (
+		done    chan struct{} // non-nil if a run is in progress
+		pending *uint64       // latest head arrived during the run
+	)
+	start := func(head uint64) {
+		ch := make(chan struct{})
+		go func() {
+			defer close(ch)
+			run(head)
+		}()
+		done = ch
+	}
+	if head := chain.CurrentBlock(); head != nil {
+		start(head.NumberU64())
+	}
+	for {
+		select {
+		case head := <-headCh:
+			number := head.Block.NumberU64()
+			if done == nil {
+				start(number)
+			} else {
+				pending = &number
+			}
+		case <-done:
+			done = nil
+			if pending != nil {
+				start(*pending)
+				pending = nil
+			}
+		case <-sub.Err():
+			if done != nil {
+				<-done
+			}
+			return
+		case <-chain.quit:
+			if done != nil {
+				<-done
+			}
+			return
+		}
+	}
+}
+
+// tail returns the oldest block which should be indexed with the given head,
+// genesis is never indexed as it has no transaction.
+func (indexer *txIndexer) tail(head uint64) uint64 {
+	if indexer.retention != 0 && head >= indexer.retention {
+		return head + 1 - indexer.retention
+	}
+	return 1
+}
+
+// run moves the indexed tail to match the retention for the given head.
+func (indexer *txIndexer) run(head uint64) {
+	target := indexer.tail(head)
+	tail := rawdb.ReadTxIndexTail(indexer.chain.db)
+	if tail == nil {
+		if target == 1 {
+			return
+		}
+		// the whole chain was indexed before the retention is set
+		tail = new(uint64)
+		*tail = 1
+	}
+	if *tail < target {
+		indexer.unindex(*tail, target)
+	} else if *tail > target {
+		indexer.index(target, *tail)
+	}
+}
+
+// index indexes the canonical blocks in [from, to) from the newest one.
+func (indexer *txIndexer) index(from, to uint64) {
+	for number := to; number > from; number-- {
+		if indexer.chain.getProcInterrupt() {
+			return
+		}
+		block, ok := indexer.chain.GetBlockByNumber(number - 1).(*types.MinorBlock)
+		if !ok {
+			log.Error(indexer.chain.logInfo, "failed to index txs of block", number-1)
+			return
+		}
+		if err := indexer.chain.putTxIndexFromBlock(indexer.chain.db, block); err != nil {
+			log.Error(indexer.chain.logInfo, "failed to index txs of block", number-1, "err", err)
+			return
+		}
+		rawdb.WriteTxIndexTail(indexer.chain.db, number-1)
+	}
+	log.Info(indexer.chain.logInfo, "indexed transactions from", from, "to", to)
+}
+
+// unindex removes the indexes of the canonical blocks in [from, to) from the oldest one.
+func (indexer *txIndexer) unindex(from, to uint64) {
+	for number := from; number < to; number++ {
+		if indexer.chain.getProcInterrupt() {
+			return
+		}
+		block, ok := indexer.chain.GetBlockByNumber(number).(*types.MinorBlock)
+		if !ok {
+			log.Error(indexer.chain.logInfo, "failed to unindex txs of block", number)
+			return
+		}
+		if err := indexer.chain.removeTxIndexFromBlock(indexer.chain.db, block); err != nil {
+			log.Error(indexer.chain.logInfo, "failed to unindex txs of block", number, "err", err)
+			return
+		}
+		rawdb.WriteTxIndexTail(indexer.chain.db, number+1)
+	}
+	log.Info(indexer.chain.logInfo, "unindexed transactions from", from, "to", to)
+}
+
+// ReindexTxs writes the tx indexes of the canonical blocks kept by the indexer
+// again, e.g. once they were lost or corrupted, and returns the number of
+// blocks reindexed.
+func (m *MinorBlockChain) ReindexTxs() (uint64, error) {
+	from := uint64(1)
+	if tail := rawdb.ReadTxIndexTail(m.db); tail != nil {
+		from = *tail
+	}
+	head := m.CurrentBlock().NumberU64()
+	count := uint64(0)
+	for number := from; number <= head; number++ {
+		if m.getProcInterrupt() {
+			return count, errors.New("minor chain stopped")
+		}
+		block, ok := m.GetBlockByNumber(number).(*types.MinorBlock)
+		if !ok {
+			return count, fmt.Errorf("canonical block %d not found", number)
+		}
+		if err := m.putTxIndexFromBlock(m.db, block); err != nil {
+			return count, err
+		}
+		count++
+	}
+	log.Info(m.logInfo, "reindexed transactions from", from, "to", head)
+	return count, nil
+}
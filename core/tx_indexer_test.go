@@ -0,0 +1,94 @@
+package core
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/core/rawdb"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxIndexerRetention(t *testing.T) {
+	dirname, err := ioutil.TempDir(os.TempDir(), "qkcdb_test_")
+	checkErr(err)
+	defer os.RemoveAll(dirname)
+	testDBPath[1] = dirname
+
+	id1, err := account.CreatRandomIdentity()
+	checkErr(err)
+	acc1 := account.CreatAddressFromIdentity(id1, 0)
+	acc2, err := account.CreatRandomAccountWithFullShardKey(0)
+	checkErr(err)
+	fakeMoney := uint64(10000000)
+	env := setUp(&acc1, &fakeMoney, nil)
+	env.clusterConfig.TxIndexRetention = 2
+	shardState := createDefaultShardState(env, nil, nil, nil, nil)
+	defer shardState.Stop()
+
+	rootBlock := shardState.rootTip.CreateBlockToAppend(nil, nil, nil, nil, nil).Finalize(nil, nil, common.Hash{})
+	_, err = shardState.AddRootBlock(rootBlock)
+	checkErr(err)
+
+	txs := make([]*types.Transaction, 0)
+	for i := 0; i < 3; i++ {
+		tx := createTransferTransaction(shardState, id1.GetKey().Bytes(), acc1, acc2, big.NewInt(100), nil, nil, nil, nil, nil, nil)
+		checkErr(shardState.AddTx(tx))
+		b, err := shardState.CreateBlockToMine(nil, &acc2, nil, nil, nil)
+		checkErr(err)
+		_, _, err = shardState.FinalizeAndAddBlock(b)
+		checkErr(err)
+		txs = append(txs, tx)
+	}
+
+	// only the latest 2 blocks are kept indexed
+	for i := 0; i < 100; i++ {
+		if tail := rawdb.ReadTxIndexTail(shardState.db); tail != nil && *tail == 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	block, _ := shardState.GetTransactionByHash(txs[0].Hash())
+	assert.Nil(t, block)
+	block, _ = shardState.GetTransactionByHash(txs[1].Hash())
+	assert.Equal(t, uint64(2), block.NumberU64())
+
+	txList, next, err := shardState.GetTransactionByAddress(acc1, nil, []byte{}, 1)
+	checkErr(err)
+	assert.Equal(t, 1, len(txList))
+	assert.Equal(t, txs[2].Hash(), txList[0].TxHash)
+	txList, next, err = shardState.GetTransactionByAddress(acc1, nil, next, 10)
+	checkErr(err)
+	assert.Equal(t, 1, len(txList))
+	assert.Equal(t, txs[1].Hash(), txList[0].TxHash)
+	txList, _, err = shardState.GetTransactionByAddress(acc1, nil, next, 10)
+	checkErr(err)
+	assert.Equal(t, 0, len(txList))
+
+	// indexes of the pruned blocks are rebuilt if the retention grows
+	newTxIndexer(shardState, 0).run(shardState.CurrentBlock().NumberU64())
+	assert.Equal(t, uint64(1), *rawdb.ReadTxIndexTail(shardState.db))
+	block, _ = shardState.GetTransactionByHash(txs[0].Hash())
+	assert.Equal(t, uint64(1), block.NumberU64())
+	txList, next, err = shardState.GetTransactionByAddress(acc1, nil, []byte{}, 10)
+	checkErr(err)
+	assert.Equal(t, 3, len(txList))
+	txList, _, err = shardState.GetTransactionByAddress(acc1, nil, next, 10)
+	checkErr(err)
+	assert.Equal(t, 0, len(txList))
+
+	// the lost indexes are written again by a reindex
+	rawdb.DeleteBlockContentLookupEntry(shardState.db, txs[2].Hash())
+	block, _ = shardState.GetTransactionByHash(txs[2].Hash())
+	assert.Nil(t, block)
+	count, err := shardState.ReindexTxs()
+	checkErr(err)
+	assert.Equal(t, uint64(3), count)
+	block, _ = shardState.GetTransactionByHash(txs[2].Hash())
+	assert.Equal(t, uint64(3), block.NumberU64())
+}
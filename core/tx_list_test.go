@@ -17,10 +17,15 @@
 package core
 
 import (
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/cluster/config"
+	qkcCommon "github.com/QuarkChain/goquarkchain/common"
 	"github.com/QuarkChain/goquarkchain/core/types"
+	"math/big"
 	"math/rand"
 	"testing"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 )
 
@@ -49,3 +54,41 @@ func TestStrictTxListAdd(t *testing.T) {
 		}
 	}
 }
+
+// Tests that the prices of the txs paying with different gas tokens are
+// compared once converted by the priced list.
+func TestPricedListGasTokens(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	qkcConfig := config.NewQuarkChainConfig()
+	qi := qkcCommon.TokenIDEncode("QI")
+	if err := qkcConfig.SetGasTokenExchangeRates(map[string]*big.Rat{"QI": big.NewRat(1, 10)}); err != nil {
+		t.Fatal(err)
+	}
+	tokenTx := func(nonce uint64, gasPrice int64, tokenID uint64) *types.Transaction {
+		tx, _ := types.SignTx(types.NewEvmTransaction(nonce, account.BytesToIdentityRecipient(common.Address{}.Bytes()), big.NewInt(100),
+			100000, big.NewInt(gasPrice), 0, 0, 3, 0, []byte{}, tokenID, tokenID), types.MakeSigner(3), key)
+		return &types.Transaction{TxType: types.EvmTx, EvmTx: tx}
+	}
+
+	all := newTxLookup()
+	list := newTxPricedList(all, func(tx *types.Transaction) *big.Int {
+		return qkcConfig.ConvertGasPrice(tx.EvmTx.GasTokenID(), tx.EvmTx.GasPrice())
+	})
+	// 50 QI is worth 5 QKC per gas
+	cheap, dear := tokenTx(0, 50, qi), tokenTx(1, 10, testGenesisTokenID)
+	for _, tx := range []*types.Transaction{dear, cheap} {
+		all.Add(tx)
+		list.Put(tx)
+	}
+	locals := newAccountSet(types.MakeSigner(3))
+	if !list.Underpriced(tokenTx(2, 40, qi), locals) {
+		t.Errorf("tx paying 4 per gas in QI should be underpriced")
+	}
+	if list.Underpriced(tokenTx(2, 6, testGenesisTokenID), locals) {
+		t.Errorf("tx paying 6 per gas should not be underpriced")
+	}
+	drop := list.Cap(big.NewInt(6), locals)
+	if len(drop) != 1 || drop[0] != cheap {
+		t.Errorf("tx paying in QI should be dropped, got %v", drop)
+	}
+}
@@ -0,0 +1,125 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/cluster/config"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// The reasons a tx is rejected for by the policy of the tx pool.
+const (
+	TxRejectGasPrice         = "gas_price_too_low"
+	TxRejectSize             = "tx_too_large"
+	TxRejectBlacklisted      = "sender_blacklisted"
+	TxRejectNotWhitelisted   = "sender_not_whitelisted"
+	TxRejectContractCreation = "contract_creation_disabled"
+)
+
+// defaultMaxTxSize is the size over which the txs are rejected to prevent DOS
+// attacks if the policy sets none.
+const defaultMaxTxSize = 32 * 1024
+
+// TxRejectedError is returned if a tx is rejected by the policy of the tx pool.
+type TxRejectedError struct {
+	Reason string
+	Err    error
+}
+
+func (e *TxRejectedError) Error() string {
+	return fmt.Sprintf("tx rejected (%s): %v", e.Reason, e.Err)
+}
+
+func (e *TxRejectedError) Unwrap() error {
+	return e.Err
+}
+
+var txRejectedPattern = regexp.MustCompile(`tx rejected \(([a-z_]+)\)`)
+
+// TxRejectionReason returns the reason of the TxRejectedError in err, which
+// may have been turned into text by the RPC between the slave and the master,
+// or "" if none.
+func TxRejectionReason(err error) string {
+	if err == nil {
+		return ""
+	}
+	if match := txRejectedPattern.FindStringSubmatch(err.Error()); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// txPolicy is the policy of a shard on the txs its pool accepts.
+type txPolicy struct {
+	minGasPrice             *big.Int // the one of the cluster only if nil
+	maxTxSize               uint64
+	blacklist               map[account.Recipient]bool
+	whitelist               map[account.Recipient]bool // any sender if empty
+	disableContractCreation bool
+}
+
+// newTxPolicy returns the policy of the config, the default size limit only if
+// nil.
+func newTxPolicy(cfg *config.TxPolicyConfig) *txPolicy {
+	p := &txPolicy{
+		maxTxSize: defaultMaxTxSize,
+		blacklist: make(map[account.Recipient]bool),
+		whitelist: make(map[account.Recipient]bool),
+	}
+	if cfg == nil {
+		return p
+	}
+	p.minGasPrice = cfg.MinGasPrice
+	if cfg.MaxTxSize != 0 {
+		p.maxTxSize = cfg.MaxTxSize
+	}
+	p.disableContractCreation = cfg.DisableContractCreation
+	// the lists are checked by the validation of the config
+	blacklist, whitelist, err := cfg.GetSenderLists()
+	if err != nil {
+		log.Error("Invalid tx policy", "err", err)
+		return p
+	}
+	for _, recipient := range blacklist {
+		p.blacklist[recipient] = true
+	}
+	for _, recipient := range whitelist {
+		p.whitelist[recipient] = true
+	}
+	return p
+}
+
+// checkGasPrice rejects the tx if its gas price, converted to QKC, is below the
+// minimum gas price of the policy or of the cluster.
+func (p *txPolicy) checkGasPrice(gasPrice, clusterMinGasPrice *big.Int) error {
+	minGasPrice := clusterMinGasPrice
+	if p.minGasPrice != nil && p.minGasPrice.Cmp(minGasPrice) > 0 {
+		minGasPrice = p.minGasPrice
+	}
+	if gasPrice.Cmp(minGasPrice) < 0 {
+		return &TxRejectedError{TxRejectGasPrice, fmt.Errorf("invalid gasprice: tx min gas price is %d", minGasPrice)}
+	}
+	return nil
+}
+
+// check rejects the tx of the sender if the policy doesn't accept it.
+func (p *txPolicy) check(tx *types.Transaction, sender account.Recipient) error {
+	if uint64(tx.EvmTx.Size()) > p.maxTxSize {
+		return &TxRejectedError{TxRejectSize, ErrOversizedData}
+	}
+	if p.blacklist[sender] {
+		return &TxRejectedError{TxRejectBlacklisted, fmt.Errorf("sender %x is blacklisted", sender)}
+	}
+	if len(p.whitelist) > 0 && !p.whitelist[sender] {
+		return &TxRejectedError{TxRejectNotWhitelisted, fmt.Errorf("sender %x is not whitelisted", sender)}
+	}
+	if p.disableContractCreation && tx.EvmTx.To() == nil {
+		return &TxRejectedError{TxRejectContractCreation, errors.New("contract creation is disabled")}
+	}
+	return nil
+}
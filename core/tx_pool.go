@@ -107,8 +107,10 @@ type minorBlockChain interface {
 
 // TxPoolConfig are the configuration parameters of the transaction pool.
 type TxPoolConfig struct {
-	Locals   []common.Address // Addresses that should be treated by default as local
-	NoLocals bool             // Whether local transaction handling should be disabled
+	Locals    []common.Address // Addresses that should be treated by default as local
+	NoLocals  bool             // Whether local transaction handling should be disabled
+	Journal   string           // Journal of local transactions to survive node restarts
+	Rejournal time.Duration    // Time interval to regenerate the local transaction journal
 
 	PriceLimit uint64 // Minimum gas price to enforce for acceptance into the pool
 	PriceBump  uint64 // Minimum price bump percentage to replace an already existing transaction (nonce)
@@ -120,11 +122,14 @@ type TxPoolConfig struct {
 
 	Lifetime  time.Duration // Maximum amount of time non-executable transaction are queued
 	NetWorkID uint32
+
+	Policy *config.TxPolicyConfig // Local policy on the transactions accepted, if any
 }
 
 // DefaultTxPoolConfig contains the default configurations for the transaction
 // pool.
 var DefaultTxPoolConfig = TxPoolConfig{
+	Rejournal: time.Hour,
 
 	PriceLimit: 1,
 	PriceBump:  10,
@@ -138,6 +143,17 @@ var DefaultTxPoolConfig = TxPoolConfig{
 	NetWorkID: 3,
 }
 
+// sanitize checks the provided user configurations and changes anything that's
+// unreasonable or unworkable.
+func (config *TxPoolConfig) sanitize() TxPoolConfig {
+	conf := *config
+	if conf.Rejournal < time.Second {
+		log.Warn("Sanitizing invalid txpool journal time", "provided", conf.Rejournal, "updated", time.Second)
+		conf.Rejournal = time.Second
+	}
+	return conf
+}
+
 // TxPool contains all currently known transactions. Transactions
 // enter the pool when they are received from the network or submitted
 // locally. They exit the pool when they are included in the blockchain.
@@ -148,6 +164,7 @@ var DefaultTxPoolConfig = TxPoolConfig{
 type TxPool struct {
 	config      TxPoolConfig
 	quarkConfig *config.QuarkChainConfig
+	policy      *txPolicy
 	chain       minorBlockChain
 	gasPrice    *big.Int
 	txFeed      event.Feed
@@ -159,7 +176,8 @@ type TxPool struct {
 	pendingNonces *txNoncer      // Pending state tracking virtual nonces
 	currentMaxGas uint64         // Current gas limit for transaction caps
 
-	locals *accountSet // Set of local transaction to exempt from eviction rules
+	locals  *accountSet // Set of local transaction to exempt from eviction rules
+	journal *txJournal  // Journal of local transaction to back up to disk
 
 	pending map[common.Address]*txList   // All currently processable transactions
 	queue   map[common.Address]*txList   // Queued but non-processable transactions
@@ -185,6 +203,8 @@ type txpoolResetRequest struct {
 // NewTxPool creates a new transaction pool to gather, sort and filter inbound
 // transactions from the network.
 func NewTxPool(config TxPoolConfig, chain minorBlockChain) *TxPool {
+	// Sanitize the input to ensure the journal interval is workable
+	config = (&config).sanitize()
 
 	// Create the transaction pool with its initial settings
 	pool := &TxPool{
@@ -203,18 +223,26 @@ func NewTxPool(config TxPoolConfig, chain minorBlockChain) *TxPool {
 		reorgShutdownCh: make(chan struct{}),
 		gasPrice:        new(big.Int).SetUint64(config.PriceLimit),
 		quarkConfig:     chain.Config(),
+		policy:          newTxPolicy(config.Policy),
 	}
 	pool.locals = newAccountSet(pool.signer)
 	for _, addr := range config.Locals {
 		pool.locals.add(addr)
 	}
-	pool.priced = newTxPricedList(pool.all)
+	pool.priced = newTxPricedList(pool.all, func(tx *types.Transaction) *big.Int {
+		return pool.quarkConfig.ConvertGasPrice(tx.EvmTx.GasTokenID(), tx.EvmTx.GasPrice())
+	})
 	pool.reset(nil, chain.CurrentBlock())
 
 	// Start the reorg loop early so it can handle requests generated during journal loading.
 	pool.wg.Add(1)
 	go pool.scheduleReorgLoop()
 
+	// If local transactions and journaling is enabled, load from disk
+	if err := pool.SetJournal(config.Journal); err != nil {
+		log.Warn("Failed to set transaction journal", "err", err)
+	}
+
 	// Subscribe events from blockchain and start the main event loop.
 	pool.chainHeadSub = pool.chain.SubscribeChainHeadEvent(pool.chainHeadCh)
 	pool.wg.Add(1)
@@ -232,13 +260,15 @@ func (pool *TxPool) loop() {
 	var (
 		prevPending, prevQueued, prevStales int
 		// Start the stats reporting and transaction eviction tickers
-		report = time.NewTicker(statsReportInterval)
-		evict  = time.NewTicker(evictionInterval)
+		report  = time.NewTicker(statsReportInterval)
+		evict   = time.NewTicker(evictionInterval)
+		journal = time.NewTicker(pool.config.Rejournal)
 		// Track the previous head headers for transaction reorgs
 		head = pool.chain.CurrentBlock()
 	)
 	defer report.Stop()
 	defer evict.Stop()
+	defer journal.Stop()
 
 	for {
 		select {
@@ -282,6 +312,16 @@ func (pool *TxPool) loop() {
 				}
 			}
 			pool.mu.Unlock()
+
+		// Handle local transaction journal rotation
+		case <-journal.C:
+			pool.mu.Lock()
+			if pool.journal != nil {
+				if err := pool.journal.rotate(pool.local()); err != nil {
+					log.Warn("Failed to rotate local tx journal", "err", err)
+				}
+			}
+			pool.mu.Unlock()
 		}
 	}
 }
@@ -295,9 +335,37 @@ func (pool *TxPool) Stop() {
 	pool.chainHeadSub.Unsubscribe()
 	pool.wg.Wait()
 
+	if pool.journal != nil {
+		pool.journal.close()
+	}
 	log.Info("Transaction pool stopped")
 }
 
+// SetJournal loads the local transactions journaled at path and keeps
+// journaling local transactions into it, replacing the journal set before.
+// Journaling is disabled if path is empty or local transaction handling is
+// disabled.
+func (pool *TxPool) SetJournal(path string) error {
+	if pool.config.NoLocals || path == "" {
+		return nil
+	}
+	// The journal is loaded before it's used by the pool, so the writer it
+	// swaps while loading is not written concurrently.
+	journal := newTxJournal(path)
+	if err := journal.load(pool.AddLocals); err != nil {
+		log.Warn("Failed to load transaction journal", "err", err)
+	}
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	if pool.journal != nil {
+		if err := pool.journal.close(); err != nil {
+			log.Warn("Failed to close transaction journal", "path", pool.journal.path, "err", err)
+		}
+	}
+	pool.journal = journal
+	return journal.rotate(pool.local())
+}
+
 // SubscribeNewTxsEvent registers a subscription of NewTxsEvent and
 // starts sending event to the given channel.
 func (pool *TxPool) SubscribeNewTxsEvent(ch chan<- NewTxsEvent) event.Subscription {
@@ -325,6 +393,30 @@ func (pool *TxPool) SetGasPrice(price *big.Int) {
 	log.Info("Transaction pool price threshold updated", "price", price)
 }
 
+// SetLimits updates the numbers of the executable and non-executable transaction
+// slots, the transactions exceeding them are evicted on the next reorg run.
+func (pool *TxPool) SetLimits(accountSlots, globalSlots, accountQueue, globalQueue uint64) {
+	pool.mu.Lock()
+	pool.config.AccountSlots, pool.config.GlobalSlots = accountSlots, globalSlots
+	pool.config.AccountQueue, pool.config.GlobalQueue = accountQueue, globalQueue
+	pool.mu.Unlock()
+
+	<-pool.requestPromoteExecutables(newAccountSet(pool.signer))
+	log.Info("Transaction pool limits updated", "accountslots", accountSlots, "globalslots", globalSlots,
+		"accountqueue", accountQueue, "globalqueue", globalQueue)
+}
+
+// SetPolicy updates the local policy on the transactions accepted, the ones
+// already in the pool are kept.
+func (pool *TxPool) SetPolicy(policy *config.TxPolicyConfig) {
+	pool.mu.Lock()
+	pool.config.Policy = policy
+	pool.policy = newTxPolicy(policy)
+	pool.mu.Unlock()
+
+	log.Info("Transaction pool policy updated")
+}
+
 // Nonce returns the next nonce of an account, with all transactions executable
 // by the pool already applied on top.
 func (pool *TxPool) Nonce(addr common.Address) uint64 {
@@ -357,6 +449,22 @@ func (pool *TxPool) stats() (int, int) {
 	return pending, queued
 }
 
+// StatsFrom retrieves the number of pending and the number of queued
+// (non-executable) transactions sent by the account.
+func (pool *TxPool) StatsFrom(addr common.Address) (int, int) {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	pending, queued := 0, 0
+	if list, ok := pool.pending[addr]; ok {
+		pending = list.Len()
+	}
+	if list, ok := pool.queue[addr]; ok {
+		queued = list.Len()
+	}
+	return pending, queued
+}
+
 // Content retrieves the data content of the transaction pool, returning all the
 // pending as well as queued transactions, grouped by account and sorted by nonce.
 func (pool *TxPool) Content() (map[common.Address]types.Transactions, map[common.Address]types.Transactions) {
@@ -415,8 +523,9 @@ func (pool *TxPool) local() map[common.Address]types.Transactions {
 // validateTx checks whether a transaction is valid according to the consensus
 // rules and adheres to some heuristic limits of the local node (price and size).
 func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
-	if tx.EvmTx.GasPrice().Cmp(pool.quarkConfig.MinTXPoolGasPrice) < 0 {
-		return errors.New(fmt.Sprintf("invalid gasprice: tx min gas price is %d", pool.quarkConfig.MinTXPoolGasPrice.Uint64()))
+	gasPrice := pool.quarkConfig.ConvertGasPrice(tx.EvmTx.GasTokenID(), tx.EvmTx.GasPrice())
+	if err := pool.policy.checkGasPrice(gasPrice, pool.quarkConfig.MinTXPoolGasPrice); err != nil {
+		return err
 	}
 	if pool.all.Count() > int(pool.quarkConfig.TransactionQueueSizeLimitPerShard) {
 		return errors.New("txpool queue full")
@@ -426,10 +535,6 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if err != nil {
 		return err
 	}
-	// Heuristic limit, reject transactions over 32KB to prevent DOS attacks
-	if tx.EvmTx.Size() > 32*1024 {
-		return ErrOversizedData
-	}
 	// Transactions can't be negative. This may never happen using RLP decoded
 	// transactions but may occur if you create a transaction using the RPC.
 	if tx.EvmTx.Value().Sign() < 0 {
@@ -440,11 +545,16 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 		return ErrGasLimit
 	}
 	// Make sure the transaction is signed properly
-	_, err = types.Sender(pool.signer, tx.EvmTx)
+	sender, err := types.Sender(pool.signer, tx.EvmTx)
 	if err != nil {
 		fmt.Println("err", err)
 		return ErrInvalidSender
 	}
+	// Local policy of the shard, rejecting e.g. the transactions over 32KB to
+	// prevent DOS attacks
+	if err := pool.policy.check(tx, sender); err != nil {
+		return err
+	}
 	return ValidateTransaction(pool.currentState, tx, nil)
 }
 
@@ -496,6 +606,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 		}
 		pool.all.Add(tx)
 		pool.priced.Put(tx)
+		pool.journalTx(from, tx)
 		pool.queueTxEvent(tx)
 		log.Trace("Pooled new executable transaction", "hash", hash, "from", from, "to", tx.EvmTx.To())
 		return old != nil, nil
@@ -512,6 +623,8 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 			pool.locals.add(from)
 		}
 	}
+	pool.journalTx(from, tx)
+
 	log.Trace("Pooled new future transaction", "hash", hash, "from", from, "to", tx.EvmTx.To())
 	return replaced, nil
 }
@@ -542,6 +655,18 @@ func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction) (bool, er
 	return old != nil, nil
 }
 
+// journalTx adds the specified transaction to the local disk journal if it is
+// deemed to have been sent from a local account.
+func (pool *TxPool) journalTx(from common.Address, tx *types.Transaction) {
+	// Only journal if it's enabled and the transaction is local
+	if pool.journal == nil || !pool.locals.contains(from) {
+		return
+	}
+	if err := pool.journal.insert(tx); err != nil {
+		log.Warn("Failed to journal local transaction", "err", err)
+	}
+}
+
 // promoteTx adds a transaction to the pending (processable) list of transactions
 // and returns whether it was inserted or an older was better.
 //
@@ -642,10 +767,8 @@ func (pool *TxPool) addTxs(txs []*types.Transaction, local, sync bool) []error {
 	if len(news) == 0 {
 		return errs
 	}
-	// Cache senders in transactions before obtaining lock (pool.signer is immutable)
-	for _, tx := range news {
-		types.Sender(pool.signer, tx.EvmTx)
-	}
+	// Cache senders in transactions in parallel before obtaining lock (pool.signer is immutable)
+	senderCacher.recoverSync(pool.signer, news)
 	// Process all the new transaction and merge any errors into the original slice
 	pool.mu.Lock()
 	newErrs, dirtyAddrs := pool.addTxsLocked(news, local)
@@ -709,6 +832,17 @@ func (pool *TxPool) Get(hash common.Hash) *types.Transaction {
 	return pool.all.Get(hash)
 }
 
+// RemoveTxs removes the transactions from the pool, moving the subsequent
+// transactions of their senders back to the future queue.
+func (pool *TxPool) RemoveTxs(hashes []common.Hash) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	for _, hash := range hashes {
+		pool.removeTx(hash, true)
+	}
+}
+
 // removeTx removes a single transaction from the queue, moving all subsequent
 // transactions back to the future queue.
 func (pool *TxPool) removeTx(hash common.Hash, outofbound bool) {
@@ -18,11 +18,13 @@ package core
 
 import (
 	"crypto/ecdsa"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"math/big"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -37,6 +39,7 @@ import (
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
 )
 
 // testTxPoolConfig is a transaction pool configuration without stateful disk
@@ -280,6 +283,47 @@ func TestInvalidTransactions(t *testing.T) {
 	}
 }
 
+func TestTransactionPolicy(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+	other, _ := crypto.GenerateKey()
+	from, _ := deriveSender(transaction(0, 100000, key))
+	pool.currentState.AddBalance(from, big.NewInt(0xffffffffffffff), genesisTokenID)
+
+	reason := func(err error) string {
+		if rejected, ok := err.(*TxRejectedError); ok {
+			return rejected.Reason
+		}
+		return fmt.Sprintf("%v", err)
+	}
+	pool.SetPolicy(&config.TxPolicyConfig{
+		MinGasPrice:             big.NewInt(2),
+		MaxTxSize:               200,
+		SenderWhitelist:         []string{from.Hex()},
+		DisableContractCreation: true,
+	})
+	assert.Equal(t, TxRejectGasPrice, reason(pool.AddRemote(transaction(0, 100000, key))))
+	assert.Equal(t, TxRejectNotWhitelisted, reason(pool.AddRemote(pricedTransaction(0, 100000, big.NewInt(2), other))))
+	evmTx := types.NewEvmContractCreation(0, new(big.Int), 100000, big.NewInt(2), 0, 0, 3, 0, nil, testGenesisTokenID, testGenesisTokenID)
+	evmTx, _ = types.SignTx(evmTx, types.MakeSigner(3), key)
+	assert.Equal(t, TxRejectContractCreation, reason(pool.AddRemote(&types.Transaction{TxType: types.EvmTx, EvmTx: evmTx})))
+	evmTx = types.NewEvmTransaction(0, account.Recipient{}, big.NewInt(100), 100000, big.NewInt(2), 0, 0, 3, 0, make([]byte, 200), testGenesisTokenID, testGenesisTokenID)
+	evmTx, _ = types.SignTx(evmTx, types.MakeSigner(3), key)
+	assert.Equal(t, TxRejectSize, reason(pool.AddRemote(&types.Transaction{TxType: types.EvmTx, EvmTx: evmTx})))
+	assert.NoError(t, pool.AddRemote(pricedTransaction(0, 100000, big.NewInt(2), key)))
+
+	// the reason is found in the error turned into text
+	pool.SetPolicy(&config.TxPolicyConfig{SenderBlacklist: []string{from.Hex()}})
+	err := pool.AddRemote(pricedTransaction(1, 100000, big.NewInt(2), key))
+	assert.Equal(t, TxRejectBlacklisted, TxRejectionReason(errors.New("slave S1: "+err.Error())))
+	assert.Equal(t, "", TxRejectionReason(ErrNonceTooLow))
+
+	pool.SetPolicy(nil)
+	assert.NoError(t, pool.AddRemote(pricedTransaction(1, 100000, big.NewInt(2), key)))
+}
+
 func TestTransactionQueue(t *testing.T) {
 	t.Parallel()
 
@@ -1599,7 +1643,7 @@ func TestTransactionReplacement(t *testing.T) {
 
 // Tests that local transactions are journaled to disk, but remote transactions
 // get discarded between restarts.
-//func TestTransactionJournaling(t *testing.T)         { testTransactionJournaling(t, false) }
+func TestTransactionJournaling(t *testing.T)         { testTransactionJournaling(t, false) }
 func TestTransactionJournalingNoLocals(t *testing.T) { testTransactionJournaling(t, true) }
 
 func testTransactionJournaling(t *testing.T, nolocals bool) {
@@ -1623,6 +1667,8 @@ func testTransactionJournaling(t *testing.T, nolocals bool) {
 
 	config := testTxPoolConfig
 	config.NoLocals = nolocals
+	config.Journal = journal
+	config.Rejournal = time.Second
 
 	pool := NewTxPool(config, blockchain)
 
@@ -1682,6 +1728,7 @@ func testTransactionJournaling(t *testing.T, nolocals bool) {
 	// Bump the nonce temporarily and ensure the newly invalidated transaction is removed
 	statedb.SetNonce(crypto.PubkeyToAddress(local.PublicKey), 2)
 	<-pool.requestReset(nil, nil)
+	time.Sleep(2 * config.Rejournal)
 	pool.Stop()
 
 	statedb.SetNonce(crypto.PubkeyToAddress(local.PublicKey), 1)
@@ -1707,6 +1754,52 @@ func testTransactionJournaling(t *testing.T, nolocals bool) {
 	pool.Stop()
 }
 
+// Tests that setting the journal again replaces the one the pool was created
+// with, which is closed, and journals the local transactions into the new one.
+func TestTransactionJournalReplace(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed), nil}
+	config := testTxPoolConfig
+	config.Journal = filepath.Join(dir, "first.rlp")
+	pool := NewTxPool(config, blockchain)
+
+	local, _ := crypto.GenerateKey()
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(local.PublicKey), big.NewInt(1000000000), genesisTokenID)
+	if err := pool.AddLocal(pricedTransaction(0, 100000, big.NewInt(1), local)); err != nil {
+		t.Fatalf("failed to add local transaction: %v", err)
+	}
+	first := pool.journal
+	if err := pool.SetJournal(filepath.Join(dir, "second.rlp")); err != nil {
+		t.Fatalf("failed to set journal: %v", err)
+	}
+	if first.writer != nil {
+		t.Fatalf("the first journal is not closed")
+	}
+	if pool.journal.path != filepath.Join(dir, "second.rlp") {
+		t.Fatalf("journal mismatched: have %s", pool.journal.path)
+	}
+	if err := pool.AddLocal(pricedTransaction(1, 100000, big.NewInt(1), local)); err != nil {
+		t.Fatalf("failed to add local transaction: %v", err)
+	}
+	pool.Stop()
+
+	// Both local transactions survive in the second journal
+	config.Journal = filepath.Join(dir, "second.rlp")
+	pool = NewTxPool(config, &testBlockChain{statedb, 1000000, new(event.Feed), nil})
+	defer pool.Stop()
+	if pending, _ := pool.Stats(); pending != 2 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, 2)
+	}
+}
+
 // TestTransactionStatusCheck tests that the pool can correctly retrieve the
 // pending status of individual transactions.
 func TestTransactionStatusCheck(t *testing.T) {
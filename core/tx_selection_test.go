@@ -0,0 +1,78 @@
+package core
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/core/vm"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxSelection(t *testing.T) {
+	id1, err := account.CreatRandomIdentity()
+	checkErr(err)
+	acc1 := account.CreatAddressFromIdentity(id1, 0)
+	id2, err := account.CreatRandomIdentity()
+	checkErr(err)
+	acc2 := account.CreatAddressFromIdentity(id2, 0)
+	acc3, err := account.CreatRandomAccountWithFullShardKey(0)
+	checkErr(err)
+	fakeMoney := uint64(10000000)
+	env := setUp(&acc1, &fakeMoney, nil)
+	shardState := createDefaultShardState(env, nil, nil, nil, nil)
+	defer shardState.Stop()
+	rootBlock := shardState.rootTip.CreateBlockToAppend(nil, nil, nil, nil, nil).Finalize(nil, nil, common.Hash{})
+	_, err = shardState.AddRootBlock(rootBlock)
+	checkErr(err)
+
+	checkErr(shardState.AddTx(createTransferTransaction(shardState, id1.GetKey().Bytes(), acc1, acc2, big.NewInt(1000000), nil, nil, nil, nil, nil, nil)))
+	genesis := shardState.CurrentBlock()
+	b, err := shardState.CreateBlockToMine(nil, &acc1, nil, nil, nil)
+	checkErr(err)
+	_, _, err = shardState.FinalizeAndAddBlock(b)
+	checkErr(err)
+	<-shardState.txPool.requestReset(genesis, shardState.CurrentBlock())
+
+	// the second tx of acc2 can't pay its value once the first one is applied
+	low, high := uint64(1), uint64(2)
+	nonce0, nonce1 := uint64(0), uint64(1)
+	tx1 := createTransferTransaction(shardState, id1.GetKey().Bytes(), acc1, acc3, big.NewInt(1), nil, &low, nil, nil, nil, nil)
+	tx2 := createTransferTransaction(shardState, id2.GetKey().Bytes(), acc2, acc3, big.NewInt(900000), nil, &high, &nonce0, nil, nil, nil)
+	tx3 := createTransferTransaction(shardState, id2.GetKey().Bytes(), acc2, acc3, big.NewInt(900000), nil, &high, &nonce1, nil, nil, nil)
+	checkErr(shardState.AddTx(tx1))
+	checkErr(shardState.AddTx(tx2))
+	checkErr(shardState.AddTx(tx3))
+
+	// the tx paying the highest tip is selected first when only one fits
+	b, err = shardState.CreateBlockToMine(nil, &acc1, big.NewInt(60000), big.NewInt(30000), nil)
+	checkErr(err)
+	assert.Len(t, b.Transactions(), 1)
+	assert.Equal(t, tx2.Hash(), b.Transactions()[0].Hash())
+
+	// the failing tx is reverted, so the block mined is valid, and kept in the
+	// pool as it may be paid later
+	b, err = shardState.CreateBlockToMine(nil, &acc1, nil, nil, nil)
+	checkErr(err)
+	assert.Len(t, b.Transactions(), 2)
+	assert.Equal(t, tx2.Hash(), b.Transactions()[0].Hash())
+	assert.Equal(t, tx1.Hash(), b.Transactions()[1].Hash())
+	assert.NotNil(t, shardState.txPool.Get(tx3.Hash()))
+	_, err = shardState.InsertChain([]types.IBlock{b}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, b.Hash(), shardState.CurrentBlock().Hash())
+}
+
+func TestIsDeterministicTxFailure(t *testing.T) {
+	assert.False(t, isDeterministicTxFailure(ErrNonceTooHigh))
+	assert.False(t, isDeterministicTxFailure(ErrGasLimitReached))
+	assert.False(t, isDeterministicTxFailure(errInsufficientBalanceForGas))
+	assert.False(t, isDeterministicTxFailure(vm.ErrPoSWSenderNotAllowed))
+	assert.False(t, isDeterministicTxFailure(errors.New("money is low")))
+	assert.True(t, isDeterministicTxFailure(ErrIntrinsicGas))
+	assert.True(t, isDeterministicTxFailure(types.ErrInvalidSig))
+	assert.True(t, isDeterministicTxFailure(types.ErrInvalidNetworkId))
+}
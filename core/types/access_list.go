@@ -0,0 +1,15 @@
+package types
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// AccessList is the list of the addresses and the storage slots a transaction
+// accesses, as defined by EIP-2930.
+type AccessList []AccessTuple
+
+// AccessTuple is an address and the storage slots of it in an access list.
+type AccessTuple struct {
+	Address     common.Address `json:"address"`
+	StorageKeys []common.Hash  `json:"storageKeys"`
+}
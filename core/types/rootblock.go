@@ -322,6 +322,12 @@ func (b *RootBlock) SignWithPrivateKey(prv *ecdsa.PrivateKey) error {
 	return nil
 }
 
+// SetSignature sets the signature of the seal hash, e.g. signed by an external
+// signer.
+func (b *RootBlock) SetSignature(signature []byte) {
+	copy(b.header.Signature[:], signature)
+}
+
 // WithSeal returns a new block with the data from b but the header replaced with
 // the sealed one.
 func (b *RootBlock) WithSeal(header *RootBlockHeader) *RootBlock {
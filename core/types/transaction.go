@@ -412,6 +412,15 @@ func (tx *Transaction) Hash() (h common.Hash) {
 	return *new(common.Hash)
 }
 
+// Size returns the serialized size of the transaction, which is the type byte
+// and the length prefixed RLP encoding of the EVM transaction.
+func (tx *Transaction) Size() common.StorageSize {
+	if tx.TxType == EvmTx {
+		return 1 + 4 + tx.EvmTx.Size()
+	}
+	return 0
+}
+
 func (tx *Transaction) getNonce() uint64 {
 	if tx.TxType == EvmTx {
 		return tx.EvmTx.data.AccountNonce
@@ -507,13 +516,40 @@ func (s *TxByPrice) Pop() interface{} {
 	return x
 }
 
+// txWithTip is a transaction with the tip it pays to the miner per gas.
+type txWithTip struct {
+	tx  *Transaction
+	tip *big.Int
+}
+
+// txsByTip implements the heap interface, ordering the transactions by their
+// tips, the highest first.
+type txsByTip []*txWithTip
+
+func (s txsByTip) Len() int           { return len(s) }
+func (s txsByTip) Less(i, j int) bool { return s[i].tip.Cmp(s[j].tip) > 0 }
+func (s txsByTip) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+func (s *txsByTip) Push(x interface{}) {
+	*s = append(*s, x.(*txWithTip))
+}
+
+func (s *txsByTip) Pop() interface{} {
+	old := *s
+	n := len(old)
+	x := old[n-1]
+	*s = old[0 : n-1]
+	return x
+}
+
 // TransactionsByPriceAndNonce represents a set of transactions that can return
 // transactions in a profit-maximizing sorted order, while supporting removing
 // entire batches of transactions for non-executable accounts.
 type TransactionsByPriceAndNonce struct {
 	txs    map[account.Recipient]Transactions // Per account nonce-sorted list of transactions
-	heads  TxByPrice                          // Next transaction for each unique account (price heap)
+	heads  txsByTip                           // Next transaction for each unique account (tip heap)
 	signer Signer                             // Signer for the set of transactions
+	tip    func(*Transaction) *big.Int        // Tip paid per gas by a transaction
 }
 
 // NewTransactionsByPriceAndNonce creates a transaction set that can retrieve
@@ -522,10 +558,20 @@ type TransactionsByPriceAndNonce struct {
 // Note, the input map is reowned so the caller should not interact any more with
 // if after providing it to the constructor.
 func NewTransactionsByPriceAndNonce(signer Signer, txs map[account.Recipient]Transactions) (*TransactionsByPriceAndNonce, error) {
-	// Initialize a price based heap with the head transactions
-	heads := make(TxByPrice, 0, len(txs))
+	return NewTransactionsByTipAndNonce(signer, txs, (*Transaction).getPrice)
+}
+
+// NewTransactionsByTipAndNonce creates a transaction set that can retrieve the
+// transactions sorted by the tip they pay per gas, e.g. their gas price
+// converted from their gas token, in a nonce-honouring way.
+//
+// Note, the input map is reowned so the caller should not interact any more with
+// if after providing it to the constructor.
+func NewTransactionsByTipAndNonce(signer Signer, txs map[account.Recipient]Transactions, tip func(*Transaction) *big.Int) (*TransactionsByPriceAndNonce, error) {
+	// Initialize a tip based heap with the head transactions
+	heads := make(txsByTip, 0, len(txs))
 	for from, accTxs := range txs {
-		heads = append(heads, accTxs[0])
+		heads = append(heads, &txWithTip{tx: accTxs[0], tip: tip(accTxs[0])})
 		// Ensure the sender address is from the signer
 		acc, err := accTxs[0].Sender(signer)
 		if err != nil {
@@ -543,6 +589,7 @@ func NewTransactionsByPriceAndNonce(signer Signer, txs map[account.Recipient]Tra
 		txs:    txs,
 		heads:  heads,
 		signer: signer,
+		tip:    tip,
 	}, nil
 }
 
@@ -551,17 +598,25 @@ func (t *TransactionsByPriceAndNonce) Peek() *Transaction {
 	if len(t.heads) == 0 {
 		return nil
 	}
-	return t.heads[0]
+	return t.heads[0].tx
+}
+
+// PeekTip returns the tip of the next transaction, nil if there is none.
+func (t *TransactionsByPriceAndNonce) PeekTip() *big.Int {
+	if len(t.heads) == 0 {
+		return nil
+	}
+	return t.heads[0].tip
 }
 
 // Shift replaces the current best head with the next one from the same account.
 func (t *TransactionsByPriceAndNonce) Shift() error {
-	acc, err := t.heads[0].Sender(t.signer)
+	acc, err := t.heads[0].tx.Sender(t.signer)
 	if err != nil {
 		return err
 	}
 	if txs, ok := t.txs[acc]; ok && len(txs) > 0 {
-		t.heads[0], t.txs[acc] = txs[0], txs[1:]
+		t.heads[0], t.txs[acc] = &txWithTip{tx: txs[0], tip: t.tip(txs[0])}, txs[1:]
 		heap.Fix(&t.heads, 0)
 	} else {
 		heap.Pop(&t.heads)
@@ -9,13 +9,35 @@ import (
 	"github.com/QuarkChain/goquarkchain/account"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
+	lru "github.com/hashicorp/golang-lru"
 	"math/big"
+	"sync/atomic"
 )
 
 var (
 	ErrInvalidNetworkId = errors.New("invalid network id for signer")
 )
 
+// senderCacheSize is the number of tx senders kept by hash, which covers the
+// txs of the pools and of the blocks being synced.
+const senderCacheSize = 65536
+
+var (
+	// senderCache keeps the senders recovered by the hash of the signed tx, so
+	// the copies of a tx decoded separately, e.g. when it is received by the
+	// pool and then in a block, are recovered once.
+	senderCache, _ = lru.New(senderCacheSize)
+
+	senderCacheHits   uint64
+	senderCacheMisses uint64
+)
+
+// SenderCacheStats returns the number of the senders found in and missing from
+// the cache by hash since the start, and the number of the senders cached.
+func SenderCacheStats() (hits, misses uint64, entries int) {
+	return atomic.LoadUint64(&senderCacheHits), atomic.LoadUint64(&senderCacheMisses), senderCache.Len()
+}
+
 // sigCache is used to cache the derived sender and contains
 // the signer used to derive it.
 type sigCache struct {
@@ -44,7 +66,9 @@ func SignTx(tx *EvmTransaction, s Signer, prv *ecdsa.PrivateKey) (*EvmTransactio
 //
 // Sender may cache the address, allowing it to be used regardless of
 // signing method. The cache is invalidated if the cached signer does
-// not match the signer used in the current call.
+// not match the signer used in the current call. The senders are also
+// cached by the hash of the tx, which includes its signature, so another copy
+// of the same tx does not recover it again.
 func Sender(signer Signer, tx *EvmTransaction) (account.Recipient, error) {
 	if sc := tx.from.Load(); sc != nil {
 		sigCache := sc.(sigCache)
@@ -55,12 +79,21 @@ func Sender(signer Signer, tx *EvmTransaction) (account.Recipient, error) {
 			return sigCache.from, nil
 		}
 	}
+	hash := tx.Hash()
+	if sc, ok := senderCache.Get(hash); ok && sc.(sigCache).signer.Equal(signer) {
+		atomic.AddUint64(&senderCacheHits, 1)
+		tx.from.Store(sc)
+		return sc.(sigCache).from, nil
+	}
+	atomic.AddUint64(&senderCacheMisses, 1)
 
 	addr, err := signer.Sender(tx)
 	if err != nil {
 		return account.Recipient{}, err
 	}
-	tx.from.Store(sigCache{signer: signer, from: addr})
+	sc := sigCache{signer: signer, from: addr}
+	tx.from.Store(sc)
+	senderCache.Add(hash, sc)
 	return addr, nil
 }
 
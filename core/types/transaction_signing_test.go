@@ -21,6 +21,7 @@ import (
 	"testing"
 
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 func TestEIP155Signing(t *testing.T) {
@@ -41,3 +42,47 @@ func TestEIP155Signing(t *testing.T) {
 		t.Errorf("exected from and address to be equal. Got %x want %x", from, recipient)
 	}
 }
+
+func TestSenderCache(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	recipient := publicKey2Recipient(&key.PublicKey)
+
+	signer := NewEIP155Signer(1)
+	tx, err := SignTx(NewEvmTransaction(0, recipient, new(big.Int), 0, new(big.Int), 0, 0, 1, 0, nil, 0, 0), signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hits, misses, _ := SenderCacheStats()
+	if _, err := Sender(signer, tx); err != nil {
+		t.Fatal(err)
+	}
+	if h, m, _ := SenderCacheStats(); h != hits || m != misses+1 {
+		t.Errorf("first recovery: got %d hits and %d misses, want %d and %d", h-hits, m-misses, 0, 1)
+	}
+
+	// another copy of the tx finds its sender by hash
+	dup, err := decodeTx(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	from, err := Sender(signer, dup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != recipient {
+		t.Errorf("exected from and address to be equal. Got %x want %x", from, recipient)
+	}
+	if h, m, _ := SenderCacheStats(); h != hits+1 || m != misses+1 {
+		t.Errorf("copy recovery: got %d hits and %d misses, want %d and %d", h-hits, m-misses, 1, 1)
+	}
+
+	// the sender cached is not used for another signer
+	dup, _ = decodeTx(data)
+	if _, err := Sender(NewEIP155Signer(2), dup); err != ErrInvalidNetworkId {
+		t.Errorf("got error %v, want %v", err, ErrInvalidNetworkId)
+	}
+}
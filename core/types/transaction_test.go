@@ -185,3 +185,38 @@ func TestTransactionPriceNonceSort(t *testing.T) {
 		}
 	}
 }
+
+func TestTransactionTipNonceSort(t *testing.T) {
+	keys := make([]*ecdsa.PrivateKey, 2)
+	for i := 0; i < len(keys); i++ {
+		keys[i], _ = crypto.GenerateKey()
+	}
+	signer := NewEIP155Signer(1)
+	// the tx paying in token 1 has a lower gas price but a higher tip
+	groups := map[account.Recipient]Transactions{}
+	for i, key := range keys {
+		recipient := publicKey2Recipient(&key.PublicKey)
+		tx, _ := SignTx(NewEvmTransaction(0, account.Recipient{}, big.NewInt(100), 100, big.NewInt(int64(5-4*i)), 0, 0, 1, 0, nil, uint64(i), 0), signer, key)
+		groups[recipient] = append(groups[recipient], &Transaction{TxType: EvmTx, EvmTx: tx})
+	}
+	txset, err := NewTransactionsByTipAndNonce(signer, groups, func(tx *Transaction) *big.Int {
+		return new(big.Int).Mul(tx.EvmTx.GasPrice(), big.NewInt(int64(1+9*tx.EvmTx.GasTokenID())))
+	})
+	if err != nil {
+		t.Fatalf("NewTransactionsByTipAndNonce err %v", err)
+	}
+	if tip := txset.PeekTip(); tip.Cmp(big.NewInt(10)) != 0 {
+		t.Errorf("got tip %v, want 10", tip)
+	}
+	if tx := txset.Peek(); tx.EvmTx.GasTokenID() != 1 {
+		t.Errorf("got tx paying in token %d first, want 1", tx.EvmTx.GasTokenID())
+	}
+	txset.Shift()
+	if tip := txset.PeekTip(); tip.Cmp(big.NewInt(5)) != 0 {
+		t.Errorf("got tip %v, want 5", tip)
+	}
+	txset.Shift()
+	if tx := txset.Peek(); tx != nil {
+		t.Errorf("got tx %x, want none", tx.Hash())
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// AccessListTracer is a tracer that accumulates the touched accounts and the
+// storage slots into an access list.
+type AccessListTracer struct {
+	excl map[common.Address]struct{} // the addresses to exclude from the list
+	list map[common.Address]map[common.Hash]struct{}
+}
+
+// NewAccessListTracer creates a new tracer that can generate the access list
+// of a transaction, leaving out the excluded addresses without storage slots
+// accessed, which are usually the sender, the recipient and the precompiled
+// contracts.
+func NewAccessListTracer(excl []common.Address) *AccessListTracer {
+	tracer := &AccessListTracer{
+		excl: make(map[common.Address]struct{}),
+		list: make(map[common.Address]map[common.Hash]struct{}),
+	}
+	for _, addr := range excl {
+		tracer.excl[addr] = struct{}{}
+	}
+	return tracer
+}
+
+func (a *AccessListTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	return nil
+}
+
+// CaptureState captures all opcodes that touch the storage or the addresses and
+// adds them to the access list.
+func (a *AccessListTracer) CaptureState(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	stackLen := len(stack.Data())
+	switch {
+	case (op == SLOAD || op == SSTORE) && stackLen >= 1:
+		a.addSlot(contract.Address(), common.BigToHash(stack.Back(0)))
+	case (op == EXTCODECOPY || op == EXTCODEHASH || op == EXTCODESIZE || op == BALANCE || op == SELFDESTRUCT) && stackLen >= 1:
+		a.addAddress(common.BigToAddress(stack.Back(0)))
+	case (op == DELEGATECALL || op == CALL || op == STATICCALL || op == CALLCODE) && stackLen >= 5:
+		a.addAddress(common.BigToAddress(stack.Back(1)))
+	}
+	return nil
+}
+
+func (a *AccessListTracer) CaptureFault(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	return nil
+}
+
+func (a *AccessListTracer) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error {
+	return nil
+}
+
+func (a *AccessListTracer) addAddress(addr common.Address) {
+	if _, ok := a.excl[addr]; ok {
+		return
+	}
+	if _, ok := a.list[addr]; !ok {
+		a.list[addr] = make(map[common.Hash]struct{})
+	}
+}
+
+// addSlot adds the storage slot even if the address is excluded, as the slots
+// of the recipient are not warm without the access list.
+func (a *AccessListTracer) addSlot(addr common.Address, slot common.Hash) {
+	if _, ok := a.list[addr]; !ok {
+		a.list[addr] = make(map[common.Hash]struct{})
+	}
+	a.list[addr][slot] = struct{}{}
+}
+
+// AccessList returns the current access list, sorted by the addresses and the
+// storage slots.
+func (a *AccessListTracer) AccessList() types.AccessList {
+	acl := make(types.AccessList, 0, len(a.list))
+	for addr, slots := range a.list {
+		tuple := types.AccessTuple{Address: addr, StorageKeys: make([]common.Hash, 0, len(slots))}
+		for slot := range slots {
+			tuple.StorageKeys = append(tuple.StorageKeys, slot)
+		}
+		sort.Slice(tuple.StorageKeys, func(i, j int) bool {
+			return bytes.Compare(tuple.StorageKeys[i][:], tuple.StorageKeys[j][:]) < 0
+		})
+		acl = append(acl, tuple)
+	}
+	sort.Slice(acl, func(i, j int) bool {
+		return bytes.Compare(acl[i].Address[:], acl[j].Address[:]) < 0
+	})
+	return acl
+}
@@ -22,6 +22,10 @@ import (
 	"errors"
 	"math/big"
 
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	qkcParams "github.com/QuarkChain/goquarkchain/params"
+	"github.com/QuarkChain/goquarkchain/serialize"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -36,6 +40,7 @@ var (
 	currentMntIDAddr                       = "000000000000000000000000000000514b430001"
 	transferMntAddr                        = "000000000000000000000000000000514b430002"
 	deployRootChainPoSWStakingContractAddr = "000000000000000000000000000000514b430003"
+	CrossShardCallAddr                     = "000000000000000000000000000000514b430004"
 
 	currentMntIDGas                       = uint64(3)
 	transferMntGas                        = uint64(3)
@@ -53,6 +58,12 @@ const (
 	ROOT_CHAIN_POSW = iota
 )
 
+var (
+	errXShardCallInput     = errors.New("cross-shard call input should be at least 96 bytes")
+	errXShardCallDelegated = errors.New("cross-shard call should not be delegated")
+	errXShardCallDisabled  = errors.New("cross-shard call is not supported by the chain")
+)
+
 type SystemContract struct {
 	address  common.Address
 	bytecode []byte
@@ -72,6 +83,13 @@ type PrecompiledContract interface {
 	SetEnableTime(data uint64)
 }
 
+// statefulPrecompiledContract is a precompiled contract modifying the state by
+// itself, which is not allowed in a static call.
+type statefulPrecompiledContract interface {
+	PrecompiledContract
+	stateful()
+}
+
 // PrecompiledContractsHomestead contains the default set of pre-compiled Ethereum
 // contracts used in the Frontier and Homestead releases.
 var PrecompiledContractsHomestead = map[common.Address]PrecompiledContract{
@@ -95,11 +113,65 @@ var PrecompiledContractsByzantium = map[common.Address]PrecompiledContract{
 	common.HexToAddress(currentMntIDAddr):                       &currentMntID{},
 	common.HexToAddress(transferMntAddr):                        &transferMnt{},
 	common.HexToAddress(deployRootChainPoSWStakingContractAddr): &deployRootChainPoSWStakingContract{},
+	common.HexToAddress(CrossShardCallAddr):                     &crossShardCall{enableTime: math.MaxUint64},
+}
+
+// PrecompiledContractsIstanbul contains the pre-compiled Ethereum contracts
+// added by the Istanbul rule set to the Byzantium ones.
+var PrecompiledContractsIstanbul = map[common.Address]PrecompiledContract{
+	common.BytesToAddress([]byte{9}): &blake2F{},
+}
+
+// precompile returns the precompiled contract at addr under the rule sets of
+// the EVM, whatever its enable time.
+func (evm *EVM) precompile(addr common.Address) PrecompiledContract {
+	if p := PrecompiledContractsByzantium[addr]; p != nil {
+		return p
+	}
+	if evm.IsIstanbul {
+		return PrecompiledContractsIstanbul[addr]
+	}
+	return nil
+}
+
+// ActivePrecompiles returns the addresses of the precompiled contracts enabled
+// for the block being executed.
+func (evm *EVM) ActivePrecompiles() []common.Address {
+	return ActivePrecompiles(evm.StateDB.GetTimeStamp(), evm.IsIstanbul)
+}
+
+// ActivePrecompiles returns the addresses of the precompiled contracts enabled
+// for the blocks with the timestamp, under the Istanbul rule set or not.
+func ActivePrecompiles(timestamp uint64, isIstanbul bool) []common.Address {
+	addrs := make([]common.Address, 0, len(PrecompiledContractsByzantium)+len(PrecompiledContractsIstanbul))
+	for addr, p := range PrecompiledContractsByzantium {
+		if timestamp > p.GetEnableTime() {
+			addrs = append(addrs, addr)
+		}
+	}
+	if isIstanbul {
+		for addr, p := range PrecompiledContractsIstanbul {
+			if timestamp > p.GetEnableTime() {
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	return addrs
+}
+
+// repricedPrecompiledContract is a precompiled contract the gas cost of which
+// is changed by the rule sets of the EVM.
+type repricedPrecompiledContract interface {
+	PrecompiledContract
+	requiredGasByRules(input []byte, isIstanbul, isBerlin bool) uint64
 }
 
 // RunPrecompiledContract runs and evaluates the output of a precompiled contract.
 func RunPrecompiledContract(p PrecompiledContract, input []byte, contract *Contract, evm *EVM) (ret []byte, err error) {
 	gas := p.RequiredGas(input)
+	if r, ok := p.(repricedPrecompiledContract); ok && evm != nil {
+		gas = r.requiredGasByRules(input, evm.IsIstanbul, evm.IsBerlin)
+	}
 	if contract.UseGas(gas) {
 		return p.Run(input, evm, contract)
 	}
@@ -228,6 +300,7 @@ type bigModExp struct{ enableTime uint64 }
 var (
 	big1      = big.NewInt(1)
 	big4      = big.NewInt(4)
+	big7      = big.NewInt(7)
 	big8      = big.NewInt(8)
 	big16     = big.NewInt(16)
 	big32     = big.NewInt(32)
@@ -249,6 +322,12 @@ func (c *bigModExp) SetEnableTime(data uint64) {
 
 // RequiredGas returns the gas required to execute the pre-compiled contract.
 func (c *bigModExp) RequiredGas(input []byte) uint64 {
+	return c.requiredGasByRules(input, false, false)
+}
+
+// requiredGasByRules returns the gas of EIP-198, or the one of EIP-2565 under
+// the Berlin rule set.
+func (c *bigModExp) requiredGasByRules(input []byte, isIstanbul, isBerlin bool) uint64 {
 	var (
 		baseLen = new(big.Int).SetBytes(getData(input, 0, 32))
 		expLen  = new(big.Int).SetBytes(getData(input, 32, 32))
@@ -282,6 +361,23 @@ func (c *bigModExp) RequiredGas(input []byte) uint64 {
 	}
 	adjExpLen.Add(adjExpLen, big.NewInt(int64(msb)))
 
+	if isBerlin {
+		// EIP-2565: the multiplication complexity is the square of the number
+		// of 8 bytes words of the longest operand
+		words := new(big.Int).Set(math.BigMax(modLen, baseLen))
+		words.Add(words, big7)
+		words.Div(words, big8)
+		gas := new(big.Int).Mul(words, words)
+		gas.Mul(gas, math.BigMax(adjExpLen, big1))
+		gas.Div(gas, new(big.Int).SetUint64(qkcParams.ModExpQuadCoeffDivEIP2565))
+		if gas.BitLen() > 64 {
+			return math.MaxUint64
+		}
+		if gas.Uint64() < qkcParams.ModExpMinGasEIP2565 {
+			return qkcParams.ModExpMinGasEIP2565
+		}
+		return gas.Uint64()
+	}
 	// Calculate the gas cost of the operation
 	gas := new(big.Int).Set(math.BigMax(modLen, baseLen))
 	switch {
@@ -371,6 +467,15 @@ func (c *bn256Add) RequiredGas(input []byte) uint64 {
 	return params.Bn256AddGas
 }
 
+// requiredGasByRules returns the gas lowered by EIP-1108 under the Istanbul
+// rule set.
+func (c *bn256Add) requiredGasByRules(input []byte, isIstanbul, isBerlin bool) uint64 {
+	if isIstanbul {
+		return qkcParams.Bn256AddGasEIP1108
+	}
+	return c.RequiredGas(input)
+}
+
 func (c *bn256Add) Run(input []byte, evm *EVM, contract *Contract) ([]byte, error) {
 	x, err := newCurvePoint(getData(input, 0, 64))
 	if err != nil {
@@ -401,6 +506,15 @@ func (c *bn256ScalarMul) RequiredGas(input []byte) uint64 {
 	return params.Bn256ScalarMulGas
 }
 
+// requiredGasByRules returns the gas lowered by EIP-1108 under the Istanbul
+// rule set.
+func (c *bn256ScalarMul) requiredGasByRules(input []byte, isIstanbul, isBerlin bool) uint64 {
+	if isIstanbul {
+		return qkcParams.Bn256ScalarMulGasEIP1108
+	}
+	return c.RequiredGas(input)
+}
+
 func (c *bn256ScalarMul) Run(input []byte, evm *EVM, contract *Contract) ([]byte, error) {
 	p, err := newCurvePoint(getData(input, 0, 64))
 	if err != nil {
@@ -438,6 +552,15 @@ func (c *bn256Pairing) RequiredGas(input []byte) uint64 {
 	return params.Bn256PairingBaseGas + uint64(len(input)/192)*params.Bn256PairingPerPointGas
 }
 
+// requiredGasByRules returns the gas lowered by EIP-1108 under the Istanbul
+// rule set.
+func (c *bn256Pairing) requiredGasByRules(input []byte, isIstanbul, isBerlin bool) uint64 {
+	if isIstanbul {
+		return qkcParams.Bn256PairingBaseGasEIP1108 + uint64(len(input)/192)*qkcParams.Bn256PairingPerPointGasEIP1108
+	}
+	return c.RequiredGas(input)
+}
+
 func (c *bn256Pairing) Run(input []byte, evm *EVM, contract *Contract) ([]byte, error) {
 	// Handle some corner cases cheaply
 	if len(input)%192 > 0 {
@@ -561,3 +684,76 @@ func (r *deployRootChainPoSWStakingContract) Run(input []byte, evm *EVM, contrac
 	contract.Gas = leftover
 	return addr.Bytes(), nil
 }
+
+// crossShardCall sends a value and a message from the calling contract to an
+// account of another shard, as a cross-shard deposit sent along with the ones
+// of the cross-shard transactions. The input is the recipient, the full shard
+// key of the recipient and the gas reserved for the target shard in 32 bytes
+// each, followed by the message data. It returns the hash of the deposit.
+type crossShardCall struct {
+	enableTime uint64
+}
+
+func (c *crossShardCall) GetEnableTime() uint64 {
+	return c.enableTime
+}
+
+func (c *crossShardCall) SetEnableTime(data uint64) {
+	c.enableTime = data
+}
+
+func (c *crossShardCall) stateful() {}
+
+// RequiredGas charges the deposit cost and the gas reserved for the target
+// shard, which are paid to the target shard instead of the local one.
+func (c *crossShardCall) RequiredGas(input []byte) uint64 {
+	remoteGas := new(big.Int).SetBytes(getData(input, 64, 32))
+	if !remoteGas.IsUint64() || remoteGas.Uint64() > math.MaxUint64-qkcParams.GtxxShardCost.Uint64() {
+		return math.MaxUint64
+	}
+	return qkcParams.GtxxShardCost.Uint64() + remoteGas.Uint64()
+}
+
+func (c *crossShardCall) Run(input []byte, evm *EVM, contract *Contract) ([]byte, error) {
+	if len(input) < 96 {
+		return nil, errXShardCallInput
+	}
+	if contract.CodeAddr == nil || *contract.CodeAddr != contract.Address() {
+		return nil, errXShardCallDelegated
+	}
+	if evm.CheckXShardTarget == nil {
+		return nil, errXShardCallDisabled
+	}
+	toFullShardKey := new(big.Int).SetBytes(getData(input, 32, 32))
+	if !toFullShardKey.IsUint64() || toFullShardKey.Uint64() > math.MaxUint32 {
+		return nil, errors.New("invalid full shard key")
+	}
+	if err := evm.CheckXShardTarget(uint32(toFullShardKey.Uint64())); err != nil {
+		return nil, err
+	}
+
+	// The value transferred to the contract leaves the shard with the deposit
+	state := evm.StateDB
+	state.SubBalance(contract.Address(), contract.Value(), evm.TransferTokenID)
+	index := make([]byte, 4)
+	binary.BigEndian.PutUint32(index, uint32(len(state.GetXShardList())))
+	deposit := &types.CrossShardTransactionDeposit{
+		TxHash: crypto.Keccak256Hash(evm.TxHash.Bytes(), index),
+		From: account.Address{
+			Recipient:    contract.Caller(),
+			FullShardKey: state.GetFullShardKey(contract.Caller()),
+		},
+		To: account.Address{
+			Recipient:    common.BytesToAddress(getData(input, 0, 32)),
+			FullShardKey: uint32(toFullShardKey.Uint64()),
+		},
+		Value:           &serialize.Uint256{Value: new(big.Int).Set(contract.Value())},
+		GasPrice:        &serialize.Uint256{Value: new(big.Int).Set(evm.GasPrice)},
+		GasRemained:     &serialize.Uint256{Value: new(big.Int).SetBytes(getData(input, 64, 32))},
+		GasTokenID:      evm.GasTokenID,
+		TransferTokenID: evm.TransferTokenID,
+		MessageData:     common.CopyBytes(input[96:]),
+	}
+	state.AppendXShardList(deposit)
+	return deposit.TxHash.Bytes(), nil
+}
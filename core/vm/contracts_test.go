@@ -338,8 +338,32 @@ var bn256PairingTests = []precompiledTest{
 	},
 }
 
+// blake2FTests are the test vectors of EIP-152.
+var blake2FTests = []precompiledTest{
+	{
+		input:    "0000000048c9bdf267e6096a3ba7ca8485ae67bb2bf894fe72f36e3cf1361d5f3af54fa5d182e6ad7f520e511f6c3e2b8c68059b6bbd41fbabd9831f79217e1319cde05b61626300000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000300000000000000000000000000000001",
+		expected: "08c9bcf367e6096a3ba7ca8485ae67bb2bf894fe72f36e3cf1361d5f3af54fa5d282e6ad7f520e511f6c3e2b8c68059b9442be0454267ce079217e1319cde05b",
+		name:     "vector 4",
+	}, {
+		input:    "0000000c48c9bdf267e6096a3ba7ca8485ae67bb2bf894fe72f36e3cf1361d5f3af54fa5d182e6ad7f520e511f6c3e2b8c68059b6bbd41fbabd9831f79217e1319cde05b61626300000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000300000000000000000000000000000001",
+		expected: "ba80a53f981c4d0d6a2797b69f12f6e94c212f14685ac4b74b12bb6fdbffa2d17d87c5392aab792dc252d5de4533cc9518d38aa8dbf1925ab92386edd4009923",
+		name:     "vector 5",
+	}, {
+		input:    "0000000c48c9bdf267e6096a3ba7ca8485ae67bb2bf894fe72f36e3cf1361d5f3af54fa5d182e6ad7f520e511f6c3e2b8c68059b6bbd41fbabd9831f79217e1319cde05b61626300000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000300000000000000000000000000000000",
+		expected: "75ab69d3190a562c51aef8d88f1c2775876944407270c42c9844252c26d2875298743e7f6d5ea2f2d3e8d226039cd31b4e426ac4f2d3d666a610c2116fde4735",
+		name:     "vector 6",
+	}, {
+		input:    "0000000148c9bdf267e6096a3ba7ca8485ae67bb2bf894fe72f36e3cf1361d5f3af54fa5d182e6ad7f520e511f6c3e2b8c68059b6bbd41fbabd9831f79217e1319cde05b61626300000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000300000000000000000000000000000001",
+		expected: "b63a380cb2897d521994a85234ee2c181b5f844d2c624c002677e9703449d2fba551b3a8333bcdf5f2f7e08993d53923de3d64fcc68c034e717b9293fed7a421",
+		name:     "vector 7",
+	},
+}
+
 func testPrecompiled(addr string, test precompiledTest, t *testing.T) {
 	p := PrecompiledContractsByzantium[common.HexToAddress(addr)]
+	if p == nil {
+		p = PrecompiledContractsIstanbul[common.HexToAddress(addr)]
+	}
 	in := common.Hex2Bytes(test.input)
 	contract := NewContract(AccountRef(common.HexToAddress("1337")),
 		nil, new(big.Int), p.RequiredGas(in))
@@ -484,6 +508,66 @@ func BenchmarkPrecompiledBn256Pairing(bench *testing.B) {
 	}
 }
 
+// Tests the sample inputs of the BLAKE2 compression function EIP 152.
+func TestPrecompiledBlake2F(t *testing.T) {
+	for _, test := range blake2FTests {
+		testPrecompiled("09", test, t)
+	}
+}
+
+func TestPrecompiledBlake2FInvalidInput(t *testing.T) {
+	p := PrecompiledContractsIstanbul[common.BytesToAddress([]byte{9})]
+	valid := common.Hex2Bytes(blake2FTests[1].input)
+	for _, test := range []struct {
+		input []byte
+		err   error
+	}{
+		{nil, errBlake2FInvalidInputLength},
+		{valid[:blake2FInputLength-1], errBlake2FInvalidInputLength},
+		{append(append([]byte{}, valid...), 0), errBlake2FInvalidInputLength},
+		{append(append([]byte{}, valid[:blake2FInputLength-1]...), 2), errBlake2FInvalidFinalFlag},
+	} {
+		contract := NewContract(AccountRef(common.HexToAddress("1337")), nil, new(big.Int), p.RequiredGas(test.input))
+		_, err := RunPrecompiledContract(p, test.input, contract, nil)
+		assert.Equal(t, test.err, err)
+	}
+}
+
+func TestPrecompiledGasByRules(t *testing.T) {
+	istanbul := &EVM{IsIstanbul: true}
+	berlin := &EVM{IsIstanbul: true, IsBerlin: true}
+	requiredGas := func(addr byte, input string, evm *EVM) uint64 {
+		p := PrecompiledContractsByzantium[common.BytesToAddress([]byte{addr})]
+		contract := NewContract(AccountRef(common.HexToAddress("1337")), nil, new(big.Int), math.MaxUint64)
+		_, err := RunPrecompiledContract(p, common.Hex2Bytes(input), contract, evm)
+		assert.NoError(t, err)
+		return math.MaxUint64 - contract.Gas
+	}
+
+	// EIP-1108 lowers the bn256 prices from Istanbul on
+	assert.Equal(t, uint64(500), requiredGas(6, bn256AddTests[0].input, nil))
+	assert.Equal(t, uint64(150), requiredGas(6, bn256AddTests[0].input, istanbul))
+	assert.Equal(t, uint64(40000), requiredGas(7, bn256ScalarMulTests[0].input, nil))
+	assert.Equal(t, uint64(6000), requiredGas(7, bn256ScalarMulTests[0].input, istanbul))
+	pairing := bn256PairingTests[0].input
+	assert.Equal(t, uint64(100000+80000*len(pairing)/384), requiredGas(8, pairing, nil))
+	assert.Equal(t, uint64(45000+34000*len(pairing)/384), requiredGas(8, pairing, berlin))
+
+	// EIP-2565 reprices modexp from Berlin on, with a minimum of 200
+	assert.Equal(t, uint64(13056), requiredGas(5, modexpTests[0].input, istanbul))
+	assert.Equal(t, uint64(1360), requiredGas(5, modexpTests[0].input, berlin))
+	assert.Equal(t, uint64(204), requiredGas(5, modexpTests[2].input, istanbul))
+	assert.Equal(t, uint64(200), requiredGas(5, modexpTests[2].input, berlin))
+}
+
+func TestPrecompiledBlake2FActivation(t *testing.T) {
+	blake2F := common.BytesToAddress([]byte{9})
+	assert.NotContains(t, ActivePrecompiles(1, false), blake2F)
+	assert.Contains(t, ActivePrecompiles(1, true), blake2F)
+	assert.Nil(t, (&EVM{}).precompile(blake2F))
+	assert.NotNil(t, (&EVM{IsIstanbul: true}).precompile(blake2F))
+}
+
 func TestPrecompiledCurrentMntID(t *testing.T) {
 	evm := NewEVM(Context{}, nil, &params.DefaultConstantinople, Config{})
 	p := PrecompiledContractsByzantium[common.HexToAddress("000000000000000000000000000000514b430001")]
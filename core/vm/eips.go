@@ -0,0 +1,91 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	qkcParams "github.com/QuarkChain/goquarkchain/params"
+	"github.com/ethereum/go-ethereum/common/math"
+)
+
+// enable1884 applies EIP-1884 to the given jump table:
+// - Increase cost of BALANCE to 700
+// - Increase cost of EXTCODEHASH to 700
+// - Increase cost of SLOAD to 800
+// - Define SELFBALANCE, with cost GasFastStep (5)
+func enable1884(jt *[256]operation) {
+	// Gas cost changes
+	jt[SLOAD].gasCost = constGasFunc(qkcParams.SloadGasEIP1884)
+	jt[BALANCE].gasCost = constGasFunc(qkcParams.BalanceGasEIP1884)
+	jt[EXTCODEHASH].gasCost = constGasFunc(qkcParams.ExtcodeHashGasEIP1884)
+
+	// New opcode
+	jt[SELFBALANCE] = operation{
+		execute:       opSelfBalance,
+		gasCost:       constGasFunc(qkcParams.SelfBalanceGas),
+		validateStack: makeStackFunc(0, 1),
+		valid:         true,
+	}
+}
+
+func opSelfBalance(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
+	balance := interpreter.evm.StateDB.GetBalance(contract.Address(), interpreter.evm.StateDB.GetQuarkChainConfig().GetDefaultChainTokenID())
+	stack.push(interpreter.intPool.get().Set(balance))
+	return nil, nil
+}
+
+// enable1344 applies EIP-1344 (ChainID Opcode)
+// - Adds an opcode that returns the current chain’s EIP-155 unique identifier,
+// which is the network ID of QuarkChain
+func enable1344(jt *[256]operation) {
+	// New opcode
+	jt[CHAINID] = operation{
+		execute:       opChainID,
+		gasCost:       constGasFunc(qkcParams.ChainIDGas),
+		validateStack: makeStackFunc(0, 1),
+		valid:         true,
+	}
+}
+
+// opChainID implements CHAINID opcode
+func opChainID(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
+	chainID := uint64(interpreter.evm.StateDB.GetQuarkChainConfig().NetworkID)
+	stack.push(math.U256(interpreter.intPool.get().SetUint64(chainID)))
+	return nil, nil
+}
+
+// enable2200 applies EIP-2200 (Rebalance net-metered SSTORE)
+func enable2200(jt *[256]operation) {
+	jt[SSTORE].gasCost = gasSStoreEIP2200
+}
+
+// enable2929 enables "EIP-2929: Gas cost increases for state access opcodes"
+// https://eips.ethereum.org/EIPS/eip-2929
+func enable2929(jt *[256]operation) {
+	jt[SSTORE].gasCost = gasSStoreEIP2929
+	jt[SLOAD].gasCost = gasSLoadEIP2929
+
+	jt[EXTCODECOPY].gasCost = gasExtCodeCopyEIP2929
+	jt[EXTCODESIZE].gasCost = gasEip2929AccountCheck
+	jt[EXTCODEHASH].gasCost = gasEip2929AccountCheck
+	jt[BALANCE].gasCost = gasEip2929AccountCheck
+
+	jt[CALL].gasCost = gasCallEIP2929
+	jt[CALLCODE].gasCost = gasCallCodeEIP2929
+	jt[STATICCALL].gasCost = gasStaticCallEIP2929
+	jt[DELEGATECALL].gasCost = gasDelegateCallEIP2929
+	jt[SELFDESTRUCT].gasCost = gasSelfdestructEIP2929
+}
@@ -44,9 +44,10 @@ type (
 // run runs the given contract and takes care of running precompiles with a fallback to the byte code interpreter.
 func run(evm *EVM, contract *Contract, input []byte, readOnly bool) ([]byte, error) {
 	if contract.CodeAddr != nil {
-		precompiles := PrecompiledContractsByzantium
-
-		if p := precompiles[*contract.CodeAddr]; p != nil && evm.StateDB.GetTimeStamp() > p.GetEnableTime() {
+		if p := evm.precompile(*contract.CodeAddr); p != nil && evm.StateDB.GetTimeStamp() > p.GetEnableTime() {
+			if _, ok := p.(statefulPrecompiledContract); ok && (readOnly || evm.inStaticCall()) {
+				return nil, errWriteProtection
+			}
 			return RunPrecompiledContract(p, input, contract, evm)
 		}
 	}
@@ -66,6 +67,13 @@ func run(evm *EVM, contract *Contract, input []byte, readOnly bool) ([]byte, err
 	return nil, ErrNoCompatibleInterpreter
 }
 
+// inStaticCall returns whether the current call is made in the context of a
+// static call, in which the state is not allowed to be modified.
+func (evm *EVM) inStaticCall() bool {
+	in, ok := evm.interpreter.(*EVMInterpreter)
+	return ok && in.readOnly
+}
+
 // Context provides the EVM with auxiliary information. Once provided
 // it shouldn't be modified.
 type Context struct {
@@ -93,6 +101,11 @@ type Context struct {
 	IsApplyXShard      bool
 	XShardGasUsedStart uint64
 	ContractAddress    *common.Address
+	ChainID            uint32 // QuarkChain chain of the block, which schedules the EVM rule sets
+	TxHash             common.Hash
+	// CheckXShardTarget returns an error if the deposits can't be sent to the
+	// full shard key from the block
+	CheckXShardTarget func(fullShardKey uint32) error
 }
 
 // EVM is the Ethereum Virtual Machine base object and provides
@@ -116,6 +129,10 @@ type EVM struct {
 	chainConfig *params.ChainConfig
 	// chain rules contains the chain rules for the current epoch
 	chainRules params.Rules
+	// IsIstanbul and IsBerlin tell the EVM rule sets scheduled by QuarkChain
+	// for the chain of the block
+	IsIstanbul bool
+	IsBerlin   bool
 	// virtual machine configuration options used to initialise the
 	// evm.
 	vmConfig Config
@@ -143,6 +160,11 @@ func NewEVM(ctx Context, statedb StateDB, chainConfig *params.ChainConfig, vmCon
 		chainRules:   chainConfig.Rules(ctx.BlockNumber),
 		interpreters: make([]Interpreter, 0, 1),
 	}
+	if statedb != nil && statedb.GetQuarkChainConfig() != nil && ctx.Time != nil {
+		qkcConfig := statedb.GetQuarkChainConfig()
+		evm.IsIstanbul = qkcConfig.IsEvmIstanbul(ctx.ChainID, ctx.Time.Uint64())
+		evm.IsBerlin = qkcConfig.IsEvmBerlin(ctx.ChainID, ctx.Time.Uint64())
+	}
 
 	if chainConfig.IsEWASM(ctx.BlockNumber) {
 		// to be implemented by EVM-C and Wagon PRs.
@@ -215,7 +237,8 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 			precompiles = PrecompiledContractsByzantium
 		}
 
-		if precompiles[addr] == nil && evm.ChainConfig().IsEIP158(evm.BlockNumber) && value.Sign() == 0 {
+		isPrecompile := precompiles[addr] != nil || evm.IsIstanbul && PrecompiledContractsIstanbul[addr] != nil
+		if !isPrecompile && evm.ChainConfig().IsEIP158(evm.BlockNumber) && value.Sign() == 0 {
 			// Calling a non existing account, don't do anything, but ping the tracer
 			if evm.vmConfig.Debug && evm.depth == 0 {
 				evm.vmConfig.Tracer.CaptureStart(caller.Address(), addr, false, input, gas, value)
@@ -409,6 +432,11 @@ func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gas uint64,
 		nonce := evm.StateDB.GetNonce(caller.Address())
 		evm.StateDB.SetNonce(caller.Address(), nonce+1)
 	}
+	// We add this to the access list _before_ taking a snapshot. Even if the creation fails,
+	// the access-list change should not be rolled back
+	if evm.IsBerlin {
+		evm.StateDB.AddAddressToAccessList(address)
+	}
 
 	// Ensure there's no existing contract already at the designated address
 	contractHash := evm.StateDB.GetCodeHash(address)
@@ -16,7 +16,18 @@
 
 package vm
 
-import "testing"
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/cluster/config"
+	"github.com/QuarkChain/goquarkchain/core/state"
+	qkcParams "github.com/QuarkChain/goquarkchain/params"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
 
 func TestMemoryGasCost(t *testing.T) {
 	//size := uint64(math.MaxUint64 - 64)
@@ -34,3 +45,123 @@ func TestMemoryGasCost(t *testing.T) {
 		t.Error("expected error")
 	}
 }
+
+func newForkTestEVM(t *testing.T, code []byte, original byte, istanbul, berlin uint64) (*EVM, common.Address) {
+	qkcConfig := config.NewQuarkChainConfig()
+	qkcConfig.Chains[0].EvmIstanbulTimeStamp = istanbul
+	qkcConfig.Chains[0].EvmBerlinTimeStamp = berlin
+
+	address := common.BytesToAddress([]byte("contract"))
+	statedb, err := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	if err != nil {
+		t.Fatalf("failed to create state: %v", err)
+	}
+	statedb.SetQuarkChainConfig(qkcConfig)
+	statedb.CreateAccount(address)
+	statedb.SetCode(address, code)
+	statedb.SetState(address, common.Hash{}, common.BytesToHash([]byte{original}))
+	statedb.Finalise(true) // Push the state into the "original" slot
+
+	ctx := Context{
+		CanTransfer: func(StateDB, common.Address, *big.Int, uint64) bool { return true },
+		Transfer:    func(StateDB, common.Address, common.Address, *big.Int, uint64) {},
+		BlockNumber: new(big.Int),
+		Time:        big.NewInt(100),
+	}
+	return NewEVM(ctx, statedb, &qkcParams.DefaultConstantinople, Config{}), address
+}
+
+var eip2200Tests = []struct {
+	original byte
+	gaspool  uint64
+	input    string
+	used     uint64
+	refund   uint64
+	failure  error
+}{
+	{0, math.MaxUint64, "0x60006000556000600055", 1612, 0, nil},                // 0 -> 0 -> 0
+	{0, math.MaxUint64, "0x60006000556001600055", 20812, 0, nil},               // 0 -> 0 -> 1
+	{0, math.MaxUint64, "0x60016000556000600055", 20812, 19200, nil},           // 0 -> 1 -> 0
+	{0, math.MaxUint64, "0x60016000556002600055", 20812, 0, nil},               // 0 -> 1 -> 2
+	{0, math.MaxUint64, "0x60016000556001600055", 20812, 0, nil},               // 0 -> 1 -> 1
+	{1, math.MaxUint64, "0x60006000556000600055", 5812, 15000, nil},            // 1 -> 0 -> 0
+	{1, math.MaxUint64, "0x60006000556001600055", 5812, 4200, nil},             // 1 -> 0 -> 1
+	{1, math.MaxUint64, "0x60006000556002600055", 5812, 0, nil},                // 1 -> 0 -> 2
+	{1, math.MaxUint64, "0x60026000556000600055", 5812, 15000, nil},            // 1 -> 2 -> 0
+	{1, math.MaxUint64, "0x60026000556003600055", 5812, 0, nil},                // 1 -> 2 -> 3
+	{1, math.MaxUint64, "0x60026000556001600055", 5812, 4200, nil},             // 1 -> 2 -> 1
+	{1, math.MaxUint64, "0x60026000556002600055", 5812, 0, nil},                // 1 -> 2 -> 2
+	{1, math.MaxUint64, "0x60016000556000600055", 5812, 15000, nil},            // 1 -> 1 -> 0
+	{1, math.MaxUint64, "0x60016000556002600055", 5812, 0, nil},                // 1 -> 1 -> 2
+	{1, math.MaxUint64, "0x60016000556001600055", 1612, 0, nil},                // 1 -> 1 -> 1
+	{0, math.MaxUint64, "0x600160005560006000556001600055", 40818, 19200, nil}, // 0 -> 1 -> 0 -> 1
+	{1, math.MaxUint64, "0x600060005560016000556000600055", 10818, 19200, nil}, // 1 -> 0 -> 1 -> 0
+	{1, 2306, "0x6001600055", 2306, 0, ErrOutOfGas},                            // 1 -> 1 (2300 sentry + 2xPUSH)
+	{1, 2307, "0x6001600055", 806, 0, nil},                                     // 1 -> 1 (2301 sentry + 2xPUSH)
+}
+
+func TestEIP2200(t *testing.T) {
+	for i, tt := range eip2200Tests {
+		evm, address := newForkTestEVM(t, hexutil.MustDecode(tt.input), tt.original, 100, 0)
+		if !evm.IsIstanbul || evm.IsBerlin {
+			t.Fatalf("test %d: rule sets mismatch: istanbul %v berlin %v", i, evm.IsIstanbul, evm.IsBerlin)
+		}
+		_, gas, err := evm.Call(AccountRef(common.Address{}), address, nil, tt.gaspool, new(big.Int))
+		if err != tt.failure {
+			t.Errorf("test %d: failure mismatch: have %v, want %v", i, err, tt.failure)
+		}
+		if used := tt.gaspool - gas; used != tt.used {
+			t.Errorf("test %d: gas used mismatch: have %v, want %v", i, used, tt.used)
+		}
+		if refund := evm.StateDB.GetRefund(); refund != tt.refund {
+			t.Errorf("test %d: gas refund mismatch: have %v, want %v", i, refund, tt.refund)
+		}
+	}
+}
+
+func TestEIP2929(t *testing.T) {
+	// SLOAD the same slot twice, then BALANCE of a cold account twice and of the
+	// contract itself, which is warm
+	code := hexutil.MustDecode("0x60005450600054507300000000000000000000000000000000000000ff31507300000000000000000000000000000000000000ff3150303150")
+	for i, tt := range []struct {
+		istanbul, berlin uint64
+		used             uint64
+	}{
+		{0, 0, 200 + 200 + 400 + 400 + 400 + 4*3 + 5*2 + 2},
+		{100, 0, 800 + 800 + 700 + 700 + 700 + 4*3 + 5*2 + 2},
+		{0, 100, 2100 + 100 + 2600 + 100 + 100 + 4*3 + 5*2 + 2},
+		{100, 101, 800 + 800 + 700 + 700 + 700 + 4*3 + 5*2 + 2},
+	} {
+		evm, address := newForkTestEVM(t, code, 0, tt.istanbul, tt.berlin)
+		evm.StateDB.PrepareAccessList(common.Address{}, &address, nil)
+		gaspool := uint64(100000)
+		_, gas, err := evm.Call(AccountRef(common.Address{}), address, nil, gaspool, new(big.Int))
+		if err != nil {
+			t.Fatalf("test %d: call failed: %v", i, err)
+		}
+		if used := gaspool - gas; used != tt.used {
+			t.Errorf("test %d: gas used mismatch: have %v, want %v", i, used, tt.used)
+		}
+	}
+}
+
+func TestIstanbulOpcodes(t *testing.T) {
+	// CHAINID SELFBALANCE ADD PUSH1 0 MSTORE PUSH1 32 PUSH1 0 RETURN
+	code := hexutil.MustDecode("0x46470160005260206000f3")
+	evm, address := newForkTestEVM(t, code, 0, 0, 0)
+	if _, _, err := evm.Call(AccountRef(common.Address{}), address, nil, 100000, new(big.Int)); err == nil {
+		t.Fatalf("CHAINID executed before Istanbul")
+	}
+
+	evm, address = newForkTestEVM(t, code, 0, 100, 0)
+	tokenID := evm.StateDB.GetQuarkChainConfig().GetDefaultChainTokenID()
+	evm.StateDB.AddBalance(address, big.NewInt(1000), tokenID)
+	ret, _, err := evm.Call(AccountRef(common.Address{}), address, nil, 100000, new(big.Int))
+	if err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	want := uint64(evm.StateDB.GetQuarkChainConfig().NetworkID) + 1000
+	if have := new(big.Int).SetBytes(ret); have.Uint64() != want {
+		t.Fatalf("CHAINID + SELFBALANCE mismatch: have %v, want %v", have, want)
+	}
+}
@@ -0,0 +1,212 @@
+package core
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/serialize"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// xShardJournalRetention is the number of root blocks after which a journaled
+// list still not applied is dropped, as its block is not confirmed, e.g. it
+// was forked out of the neighbor shard.
+const xShardJournalRetention = 256
+
+// xShardJournalCompactSize is the size of the journal file in bytes from which
+// it's regenerated without the dropped lists. Below it, the dropped lists stay
+// in the file, and are dropped again when it's loaded.
+const xShardJournalCompactSize = 16 * 1024 * 1024
+
+// xShardJournalEntry is a cross-shard tx list received from a neighbor shard,
+// with the root tip height of the shard when it was received, below the one of
+// the root block confirming the block.
+type xShardJournalEntry struct {
+	Hash       common.Hash
+	RootHeight uint64
+	TxList     types.CrossShardTransactionDepositList
+}
+
+// xShardJournal is a rotating log of the cross-shard tx lists received from the
+// neighbor shards and not applied yet by the shard. The lists are written to
+// the db, which doesn't sync them to disk, once the journal has, so that the
+// lists acknowledged to the neighbors survive a crash of the slave or of its
+// host, and the shard doesn't depend on the master to send them again.
+type xShardJournal struct {
+	path    string
+	mu      sync.Mutex
+	writer  *os.File
+	size    int64 // bytes written to the file, including the dropped lists
+	limit   int64 // size from which the file is regenerated
+	entries map[common.Hash]*xShardJournalEntry
+}
+
+func newXShardJournal(path string) *xShardJournal {
+	return &xShardJournal{
+		path:    path,
+		limit:   xShardJournalCompactSize,
+		entries: make(map[common.Hash]*xShardJournalEntry),
+	}
+}
+
+// writeJournalEntry writes a length prefixed serialized entry into w, and
+// returns the number of bytes written.
+func writeJournalEntry(w io.Writer, entry *xShardJournalEntry) (int, error) {
+	data, err := serialize.SerializeToBytes(entry)
+	if err != nil {
+		return 0, err
+	}
+	size := make([]byte, 4)
+	binary.BigEndian.PutUint32(size, uint32(len(data)))
+	if _, err := w.Write(size); err != nil {
+		return 0, err
+	}
+	if _, err = w.Write(data); err != nil {
+		return 0, err
+	}
+	return len(size) + len(data), nil
+}
+
+// readJournalEntry reads an entry written by writeJournalEntry, io.EOF is
+// returned if there is no more entry in r.
+func readJournalEntry(r io.Reader) (*xShardJournalEntry, error) {
+	size := make([]byte, 4)
+	if _, err := io.ReadFull(r, size); err != nil {
+		return nil, err
+	}
+	data := make([]byte, binary.BigEndian.Uint32(size))
+	if _, err := io.ReadFull(r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	entry := new(xShardJournalEntry)
+	if err := serialize.DeserializeFromBytes(data, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// load reads the entries of the journal on disk. An entry cut by a crash while
+// being written ends the journal, as it was not acknowledged.
+func (journal *xShardJournal) load() error {
+	input, err := os.Open(journal.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	reader := bufio.NewReader(input)
+	for {
+		entry, err := readJournalEntry(reader)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			log.Warn("Truncated cross-shard tx list journal", "path", journal.path, "entries", len(journal.entries), "err", err)
+			return nil
+		}
+		journal.entries[entry.Hash] = entry
+	}
+}
+
+// insert appends the list to the journal and syncs it to disk.
+func (journal *xShardJournal) insert(entry *xShardJournalEntry) error {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+	if journal.writer == nil {
+		return errNoActiveJournal
+	}
+	if _, ok := journal.entries[entry.Hash]; ok {
+		return nil
+	}
+	n, err := writeJournalEntry(journal.writer, entry)
+	if err != nil {
+		return err
+	}
+	if err := journal.writer.Sync(); err != nil {
+		return err
+	}
+	journal.entries[entry.Hash] = entry
+	journal.size += int64(n)
+	return nil
+}
+
+// rotate drops the entries for which keep returns false. The journal is
+// regenerated with the other ones if it's not open yet or if its file reached
+// the limit, which is then raised to twice the size of the regenerated file so
+// that a journal of lists kept long enough is not regenerated on every
+// rotation.
+func (journal *xShardJournal) rotate(keep func(entry *xShardJournalEntry) bool) error {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+
+	dropped := 0
+	for hash, entry := range journal.entries {
+		if !keep(entry) {
+			delete(journal.entries, hash)
+			dropped++
+		}
+	}
+	if journal.writer != nil && journal.size < journal.limit {
+		return nil
+	}
+	if journal.writer != nil {
+		if err := journal.writer.Close(); err != nil {
+			return err
+		}
+		journal.writer = nil
+	}
+	replacement, err := os.OpenFile(journal.path+".new", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	var size int64
+	for _, entry := range journal.entries {
+		n, err := writeJournalEntry(replacement, entry)
+		if err != nil {
+			replacement.Close()
+			return err
+		}
+		size += int64(n)
+	}
+	if err = replacement.Sync(); err != nil {
+		replacement.Close()
+		return err
+	}
+	replacement.Close()
+
+	if err = os.Rename(journal.path+".new", journal.path); err != nil {
+		return err
+	}
+	sink, err := os.OpenFile(journal.path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	journal.writer, journal.size = sink, size
+	if journal.limit = 2 * size; journal.limit < xShardJournalCompactSize {
+		journal.limit = xShardJournalCompactSize
+	}
+	log.Debug("Regenerated cross-shard tx list journal", "lists", len(journal.entries), "dropped", dropped, "size", size)
+	return nil
+}
+
+func (journal *xShardJournal) close() error {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+	var err error
+	if journal.writer != nil {
+		err = journal.writer.Close()
+		journal.writer = nil
+	}
+	return err
+}
@@ -0,0 +1,128 @@
+package core
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/serialize"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXShardJournal(t *testing.T) {
+	dirname, err := ioutil.TempDir(os.TempDir(), "xshard_journal_test_")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dirname)
+	path := filepath.Join(dirname, "xshard_lists.dat")
+
+	id1, err := account.CreatRandomIdentity()
+	assert.NoError(t, err)
+	acc1 := account.CreatAddressFromIdentity(id1, 0)
+	acc2 := account.CreatAddressFromIdentity(id1, 16)
+	acc3, err := account.CreatRandomAccountWithFullShardKey(0)
+	assert.NoError(t, err)
+	genesisBalance := uint64(10000000)
+	shardSize := uint32(64)
+	env0 := setUp(&acc1, &genesisBalance, &shardSize)
+	env1 := setUp(&acc1, &genesisBalance, &shardSize)
+	id := uint32(0)
+	shardState0 := createDefaultShardState(env0, &id, nil, nil, nil)
+	assert.NoError(t, shardState0.SetXShardJournal(path))
+	id = uint32(16)
+	shardState1 := createDefaultShardState(env1, &id, nil, nil, nil)
+
+	rootBlock := shardState0.rootTip.CreateBlockToAppend(nil, nil, nil, nil, nil)
+	rootBlock.AddMinorBlockHeader(shardState0.CurrentBlock().Header())
+	rootBlock.AddMinorBlockHeader(shardState1.CurrentBlock().Header())
+	rootBlock.Finalize(nil, nil, common.Hash{})
+	_, err = shardState0.AddRootBlock(rootBlock)
+	assert.NoError(t, err)
+
+	b1 := shardState1.CurrentBlock().CreateBlockToAppend(nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	b1Header := b1.Header()
+	b1Header.PrevRootBlockHash = rootBlock.Hash()
+	b1 = types.NewMinorBlock(b1Header, b1.Meta(), b1.Transactions(), nil, nil)
+	gas, gasPrice, value := uint64(30000), uint64(2), big.NewInt(888888)
+	tx := createTransferTransaction(shardState1, id1.GetKey().Bytes(), acc2, acc1, value, &gas, &gasPrice, nil, nil, nil, nil)
+	b1.AddTx(tx)
+	txList := types.CrossShardTransactionDepositList{TXList: []*types.CrossShardTransactionDeposit{{
+		TxHash:          tx.Hash(),
+		From:            acc2,
+		To:              acc1,
+		Value:           &serialize.Uint256{Value: value},
+		GasPrice:        &serialize.Uint256{Value: new(big.Int).SetUint64(gasPrice)},
+		GasRemained:     &serialize.Uint256{Value: big.NewInt(0)},
+		TransferTokenID: tx.EvmTx.TransferTokenID(),
+		GasTokenID:      tx.EvmTx.GasTokenID(),
+	}}}
+	shardState0.AddCrossShardTxListByMinorBlockHash(b1.Hash(), txList)
+	// a list received again is journaled once
+	shardState0.AddCrossShardTxListByMinorBlockHash(b1.Hash(), txList)
+
+	// a shard which lost the list from its db gets it back from the journal
+	env2 := setUp(&acc1, &genesisBalance, &shardSize)
+	id = uint32(0)
+	restarted := createDefaultShardState(env2, &id, nil, nil, nil)
+	assert.Nil(t, restarted.ReadCrossShardTxList(b1.Hash()))
+	assert.NoError(t, restarted.SetXShardJournal(path))
+	restored := restarted.ReadCrossShardTxList(b1.Hash())
+	if assert.NotNil(t, restored) {
+		assert.Equal(t, txList.TXList[0].TxHash, restored.TXList[0].TxHash)
+		assert.Equal(t, value, restored.TXList[0].Value.Value)
+	}
+	assert.Len(t, restarted.xShardJournal.entries, 1)
+	restarted.xShardJournal.close()
+
+	// the list is kept until it's applied
+	rootBlock = shardState0.rootTip.CreateBlockToAppend(nil, nil, nil, nil, nil)
+	rootBlock.AddMinorBlockHeader(b1.Header())
+	rootBlock.Finalize(nil, nil, common.Hash{})
+	_, err = shardState0.AddRootBlock(rootBlock)
+	assert.NoError(t, err)
+	assert.Len(t, shardState0.xShardJournal.entries, 1)
+
+	b2, err := shardState0.CreateBlockToMine(nil, &acc3, nil, nil, nil)
+	assert.NoError(t, err)
+	b2, _, err = shardState0.FinalizeAndAddBlock(b2)
+	assert.NoError(t, err)
+	balance := shardState0.currentEvmState.GetBalance(acc1.Recipient, shardState0.GetGenesisToken())
+	assert.Equal(t, new(big.Int).Add(new(big.Int).SetUint64(genesisBalance), value), balance)
+
+	// and dropped by the rotation of the next root block
+	rootBlock = shardState0.rootTip.CreateBlockToAppend(nil, nil, nil, nil, nil)
+	rootBlock.AddMinorBlockHeader(b2.Header())
+	rootBlock.Finalize(nil, nil, common.Hash{})
+	_, err = shardState0.AddRootBlock(rootBlock)
+	assert.NoError(t, err)
+	assert.Len(t, shardState0.xShardJournal.entries, 0)
+
+	// the file keeps the dropped list until it reaches the limit
+	journal := newXShardJournal(path)
+	assert.NoError(t, journal.load())
+	assert.Len(t, journal.entries, 1)
+	shardState0.xShardJournal.limit = shardState0.xShardJournal.size
+	assert.NoError(t, shardState0.xShardJournal.rotate(func(*xShardJournalEntry) bool { return true }))
+	assert.Equal(t, int64(xShardJournalCompactSize), shardState0.xShardJournal.limit)
+	shardState0.xShardJournal.close()
+
+	journal = newXShardJournal(path)
+	assert.NoError(t, journal.load())
+	assert.Len(t, journal.entries, 0)
+
+	// an entry cut by a crash ends the journal
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	assert.NoError(t, err)
+	_, err = writeJournalEntry(f, &xShardJournalEntry{Hash: b1.Hash(), RootHeight: 1, TxList: txList})
+	assert.NoError(t, err)
+	_, err = f.Write([]byte{0, 0, 1, 0, 1, 2})
+	assert.NoError(t, err)
+	f.Close()
+	journal = newXShardJournal(path)
+	assert.NoError(t, journal.load())
+	assert.Len(t, journal.entries, 1)
+}
@@ -128,8 +128,10 @@ func (x *XShardTxCursor) getNextTx() (*types.CrossShardTransactionDeposit, error
 			continue
 		}
 		// Check if the neighbor has the permission to send tx to local shard
+		// The root block is unknown to a shard created after it, and no deposit
+		// from before the genesis of the shard is received
 		prevRootHeader := x.bc.GetRootBlockByHash(mBlockHeader.PrevRootBlockHash)
-		if prevRootHeader.Number() <= x.bc.GetGenesisRootHeight() {
+		if prevRootHeader == nil || prevRootHeader.Number() <= x.bc.GetGenesisRootHeight() {
 			if x.xShardDepositIndex != 0 {
 				return nil, errors.New("should 0")
 			}
@@ -4,6 +4,7 @@ package debug
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"os/user"
@@ -21,6 +22,15 @@ import (
 // Handler is the global debugging handler.
 var Handler = new(HandlerT)
 
+// The profiling actions run by Profile.
+const (
+	ProfileStartCPU         = "startCPUProfile"
+	ProfileStopCPU          = "stopCPUProfile"
+	ProfileWriteHeap        = "writeHeapProfile"
+	ProfileSetBlockRate     = "setBlockProfileRate"
+	ProfileSetMutexFraction = "setMutexProfileFraction"
+)
+
 // HandlerT implements the debugging API.
 // Do not create values of this type, use the one
 // in the Handler variable instead.
@@ -188,6 +198,26 @@ func (*HandlerT) SetGCPercent(v int) int {
 	return debug.SetGCPercent(v)
 }
 
+// Profile runs the profiling action by name, with the file written to or the
+// rate set. It lets the master relay the profiling requests to the slaves.
+func (h *HandlerT) Profile(action, file string, rate int) error {
+	switch action {
+	case ProfileStartCPU:
+		return h.StartCPUProfile(file)
+	case ProfileStopCPU:
+		return h.StopCPUProfile()
+	case ProfileWriteHeap:
+		return h.WriteMemProfile(file)
+	case ProfileSetBlockRate:
+		h.SetBlockProfileRate(rate)
+	case ProfileSetMutexFraction:
+		h.SetMutexProfileFraction(rate)
+	default:
+		return fmt.Errorf("unknown profiling action %q", action)
+	}
+	return nil
+}
+
 func writeProfile(name, file string) error {
 	p := pprof.Lookup(name)
 	log.Info("Writing profile records", "count", p.Count(), "type", name, "dump", file)
@@ -11,14 +11,11 @@ import (
 	"runtime"
 
 	"github.com/ethereum/go-ethereum/log"
-	"github.com/fjl/memsize/memsizeui"
 	"github.com/mattn/go-colorable"
 	"github.com/mattn/go-isatty"
 	"gopkg.in/urfave/cli.v1"
 )
 
-var Memsize memsizeui.Handler
-
 var (
 	verbosityFlag = cli.IntFlag{
 		Name:  "verbosity",
@@ -35,6 +32,11 @@ var (
 		Usage: "Request a stack trace at a specific logging statement (e.g. \"block.go:271\")",
 		Value: "",
 	}
+	logFormatFlag = cli.StringFlag{
+		Name:  "log_format",
+		Usage: "Format of the logs written to stderr: terminal or json",
+		Value: "terminal",
+	}
 	debugFlag = cli.BoolFlag{
 		Name:  "debug",
 		Usage: "Prepends log messages with call-site location (file and line number)",
@@ -74,7 +76,7 @@ var (
 
 // Flags holds all command-line flags required for debugging.
 var Flags = []cli.Flag{
-	verbosityFlag, vmoduleFlag, backtraceAtFlag, debugFlag,
+	verbosityFlag, vmoduleFlag, backtraceAtFlag, logFormatFlag, debugFlag,
 	pprofFlag, pprofAddrFlag, pprofPortFlag,
 	memprofilerateFlag, blockprofilerateFlag, cpuprofileFlag, traceFlag,
 }
@@ -82,6 +84,7 @@ var Flags = []cli.Flag{
 var (
 	ostream log.Handler
 	glogger *log.GlogHandler
+	modules *moduleHandler
 )
 
 func init() {
@@ -92,6 +95,7 @@ func init() {
 	}
 	ostream = log.StreamHandler(output, log.TerminalFormat(usecolor))
 	glogger = log.NewGlogHandler(ostream)
+	modules = newModuleHandler(glogger, ostream)
 }
 
 // Setup initializes profiling and logging based on the CLI flags.
@@ -99,6 +103,14 @@ func init() {
 func Setup(ctx *cli.Context, logdir string) error {
 	// logging
 	log.PrintOrigins(ctx.GlobalBool(debugFlag.Name))
+	switch format := ctx.GlobalString(logFormatFlag.Name); format {
+	case "terminal":
+	case "json":
+		ostream = log.StreamHandler(os.Stderr, log.JSONFormat())
+	default:
+		return fmt.Errorf("unknown log format %q", format)
+	}
+	out := ostream
 	if logdir != "" {
 		rfh, err := log.RotatingFileHandler(
 			logdir,
@@ -108,12 +120,13 @@ func Setup(ctx *cli.Context, logdir string) error {
 		if err != nil {
 			return err
 		}
-		glogger.SetHandler(log.MultiHandler(ostream, rfh))
+		out = log.MultiHandler(ostream, rfh)
 	}
+	modules.setHandler(out)
 	glogger.Verbosity(log.Lvl(ctx.GlobalInt(verbosityFlag.Name)))
 	glogger.Vmodule(ctx.GlobalString(vmoduleFlag.Name))
 	glogger.BacktraceAt(ctx.GlobalString(backtraceAtFlag.Name))
-	log.Root().SetHandler(glogger)
+	log.Root().SetHandler(modules)
 
 	// profiling, tracing
 	runtime.MemProfileRate = ctx.GlobalInt(memprofilerateFlag.Name)
@@ -138,9 +151,6 @@ func Setup(ctx *cli.Context, logdir string) error {
 }
 
 func StartPProf(address string) {
-	// Hook go-metrics into expvar on any /debug/metrics request, load all vars
-	// from the registry into expvar, and execute regular expvar handler.
-	http.Handle("/memsize/", http.StripPrefix("/memsize", &Memsize))
 	log.Info("Starting pprof server", "addr", fmt.Sprintf("http://%s/debug/pprof", address))
 	go func() {
 		if err := http.ListenAndServe(address, nil); err != nil {
@@ -149,6 +159,11 @@ func StartPProf(address string) {
 	}()
 }
 
+// PProfEnabled reports whether the pprof HTTP server is enabled by the flags.
+func PProfEnabled(ctx *cli.Context) bool {
+	return ctx.GlobalBool(pprofFlag.Name)
+}
+
 // Exit stops all running profiles, flushing their output to the
 // respective file.
 func Exit() {
@@ -0,0 +1,216 @@
+package debug
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const repoPath = "github.com/QuarkChain/goquarkchain/"
+
+// LogModules maps the modules whose log level can be set apart from the
+// global verbosity to the packages of the repo they log from, which include
+// their sub packages.
+var LogModules = map[string][]string{
+	"rpc":   {"rpc", "cluster/rpc", "internal/qkcapi"},
+	"sync":  {"cluster/sync"},
+	"p2p":   {"p2p"},
+	"core":  {"core"},
+	"miner": {"cluster/miner"},
+}
+
+// moduleHandler filters the records of the modules with a log level by that
+// level, and the other records by the glog handler.
+type moduleHandler struct {
+	glog *log.GlogHandler
+
+	mu     sync.RWMutex
+	out    log.Handler
+	levels map[string]log.Lvl // log level of the modules set apart
+	sites  map[uintptr]string // module of the call sites seen
+}
+
+func newModuleHandler(glog *log.GlogHandler, out log.Handler) *moduleHandler {
+	return &moduleHandler{
+		glog:   glog,
+		out:    out,
+		levels: make(map[string]log.Lvl),
+		sites:  make(map[uintptr]string),
+	}
+}
+
+func (h *moduleHandler) setHandler(out log.Handler) {
+	h.mu.Lock()
+	h.out = out
+	h.mu.Unlock()
+	h.glog.SetHandler(out)
+}
+
+// Log implements log.Handler.
+func (h *moduleHandler) Log(r *log.Record) error {
+	h.mu.RLock()
+	if len(h.levels) == 0 {
+		h.mu.RUnlock()
+		return h.glog.Log(r)
+	}
+	module, seen := h.sites[r.Call.PC()]
+	h.mu.RUnlock()
+	if !seen {
+		module = moduleOf(r.Call.Frame().Function)
+		h.mu.Lock()
+		h.sites[r.Call.PC()] = module
+		h.mu.Unlock()
+	}
+
+	h.mu.RLock()
+	lvl, ok := h.levels[module]
+	out := h.out
+	h.mu.RUnlock()
+	if !ok {
+		return h.glog.Log(r)
+	}
+	if r.Lvl > lvl {
+		return nil
+	}
+	return out.Log(r)
+}
+
+// moduleOf returns the module of the function logged from, or "" if it is
+// not in a package of a module.
+func moduleOf(function string) string {
+	if !strings.HasPrefix(function, repoPath) {
+		return ""
+	}
+	pkg := strings.TrimPrefix(function, repoPath)
+	// the package name ends at the first dot after the last slash
+	slash := strings.LastIndex(pkg, "/")
+	if dot := strings.Index(pkg[slash+1:], "."); dot >= 0 {
+		pkg = pkg[:slash+1+dot]
+	}
+	for module, pkgs := range LogModules {
+		for _, p := range pkgs {
+			if pkg == p || strings.HasPrefix(pkg, p+"/") {
+				return module
+			}
+		}
+	}
+	return ""
+}
+
+// ParseLogModules parses the module levels of a comma separated list of
+// <module>=<level>, e.g. "p2p=debug,sync=warn".
+func ParseLogModules(spec string) (map[string]string, error) {
+	levels := make(map[string]string)
+	for _, rule := range strings.Split(spec, ",") {
+		if rule = strings.TrimSpace(rule); rule == "" {
+			continue
+		}
+		parts := strings.Split(rule, "=")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid log module %q, want <module>=<level>", rule)
+		}
+		levels[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return levels, nil
+}
+
+// ValidateLogModules checks the modules and the levels of the module levels.
+func ValidateLogModules(levels map[string]string) error {
+	for module, level := range levels {
+		if _, ok := LogModules[module]; !ok {
+			return unknownModule(module)
+		}
+		if _, err := log.LvlFromString(level); err != nil {
+			return fmt.Errorf("log module %s: %v", module, err)
+		}
+	}
+	return nil
+}
+
+// SetLogLevel sets the global verbosity of the logs.
+func SetLogLevel(level string) error {
+	lvl, err := log.LvlFromString(level)
+	if err != nil {
+		return err
+	}
+	glogger.Verbosity(lvl)
+	return nil
+}
+
+// SetLogModules replaces the log levels of the modules, the modules missing
+// log at the global verbosity.
+func SetLogModules(levels map[string]string) error {
+	if err := ValidateLogModules(levels); err != nil {
+		return err
+	}
+	lvls := make(map[string]log.Lvl, len(levels))
+	for module, level := range levels {
+		lvls[module], _ = log.LvlFromString(level)
+	}
+	modules.mu.Lock()
+	modules.levels = lvls
+	modules.mu.Unlock()
+	return nil
+}
+
+// SetModuleLogLevel sets the log level of the module, or makes it log at the
+// global verbosity if level is empty.
+func SetModuleLogLevel(module, level string) error {
+	if _, ok := LogModules[module]; !ok {
+		return unknownModule(module)
+	}
+	modules.mu.Lock()
+	defer modules.mu.Unlock()
+	if level == "" {
+		delete(modules.levels, module)
+		return nil
+	}
+	lvl, err := log.LvlFromString(level)
+	if err != nil {
+		return err
+	}
+	modules.levels[module] = lvl
+	return nil
+}
+
+// LogModuleLevels returns the log levels of the modules set apart.
+func LogModuleLevels() map[string]string {
+	modules.mu.RLock()
+	defer modules.mu.RUnlock()
+	levels := make(map[string]string, len(modules.levels))
+	for module, lvl := range modules.levels {
+		levels[module] = lvlName(lvl)
+	}
+	return levels
+}
+
+func unknownModule(module string) error {
+	names := make([]string, 0, len(LogModules))
+	for name := range LogModules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return fmt.Errorf("unknown log module %q, want one of %s", module, strings.Join(names, ", "))
+}
+
+// lvlName returns the name of the level accepted by log.LvlFromString, in
+// full rather than the abbreviation of Lvl.String.
+func lvlName(lvl log.Lvl) string {
+	switch lvl {
+	case log.LvlTrace:
+		return "trace"
+	case log.LvlDebug:
+		return "debug"
+	case log.LvlInfo:
+		return "info"
+	case log.LvlWarn:
+		return "warn"
+	case log.LvlError:
+		return "error"
+	default:
+		return "crit"
+	}
+}
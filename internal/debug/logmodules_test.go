@@ -0,0 +1,87 @@
+package debug
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/go-stack/stack"
+)
+
+func TestModuleOf(t *testing.T) {
+	tests := map[string]string{
+		repoPath + "p2p.(*Server).run":                        "p2p",
+		repoPath + "p2p/discover.(*udp).loop":                 "p2p",
+		repoPath + "cluster/sync.(*synchronizer).loop.func1":  "sync",
+		repoPath + "cluster/rpc.(*rpcClient).Call":            "rpc",
+		repoPath + "rpc.(*Server).ServeCodec":                 "rpc",
+		repoPath + "core/state.(*StateDB).Commit":             "core",
+		repoPath + "cluster/miner.(*Miner).mainLoop":          "miner",
+		repoPath + "cluster/master.(*QKCMasterBackend).Start": "",
+		repoPath + "cluster/slave.New":                        "",
+		"github.com/ethereum/go-ethereum/p2p.(*Server).run":   "",
+	}
+	for function, want := range tests {
+		if got := moduleOf(function); got != want {
+			t.Errorf("moduleOf(%q) = %q, want %q", function, got, want)
+		}
+	}
+}
+
+func TestModuleHandler(t *testing.T) {
+	var logged []string
+	out := log.FuncHandler(func(r *log.Record) error {
+		logged = append(logged, r.Msg)
+		return nil
+	})
+	glog := log.NewGlogHandler(out)
+	glog.Verbosity(log.LvlInfo)
+	h := newModuleHandler(glog, out)
+
+	call := stack.Caller(0)
+	h.sites[call.PC()] = "p2p"
+	record := func(lvl log.Lvl, msg string) {
+		h.Log(&log.Record{Lvl: lvl, Msg: msg, Call: call})
+	}
+	record(log.LvlDebug, "global debug")
+	record(log.LvlInfo, "global info")
+	h.levels["p2p"] = log.LvlDebug
+	record(log.LvlDebug, "module debug")
+	record(log.LvlTrace, "module trace")
+	h.levels["p2p"] = log.LvlError
+	record(log.LvlInfo, "module info")
+	h.levels["sync"] = log.LvlTrace
+	delete(h.levels, "p2p")
+	record(log.LvlInfo, "global info again")
+
+	want := []string{"global info", "module debug", "global info again"}
+	if len(logged) != len(want) {
+		t.Fatalf("logged %v, want %v", logged, want)
+	}
+	for i := range want {
+		if logged[i] != want[i] {
+			t.Fatalf("logged %v, want %v", logged, want)
+		}
+	}
+}
+
+func TestParseLogModules(t *testing.T) {
+	levels, err := ParseLogModules("p2p=debug, sync=warn,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(levels) != 2 || levels["p2p"] != "debug" || levels["sync"] != "warn" {
+		t.Errorf("wrong levels %v", levels)
+	}
+	if err := ValidateLogModules(levels); err != nil {
+		t.Error(err)
+	}
+	if _, err := ParseLogModules("p2p"); err == nil {
+		t.Error("parsed a module without level")
+	}
+	if err := ValidateLogModules(map[string]string{"eth": "debug"}); err == nil {
+		t.Error("validated an unknown module")
+	}
+	if err := ValidateLogModules(map[string]string{"p2p": "loud"}); err == nil {
+		t.Error("validated an unknown level")
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"github.com/QuarkChain/goquarkchain/core/types"
 	"github.com/QuarkChain/goquarkchain/serialize"
 	ethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+	"sort"
 )
 
 func IDEncoder(hashByte []byte, fullShardKey uint32) hexutil.Bytes {
@@ -49,7 +51,317 @@ func BalancesEncoder(balances *types.TokenBalances) []map[string]interface{} {
 	return balanceList
 }
 
+func StaleBlockEncoder(block *rpc.StaleBlock) (map[string]interface{}, error) {
+	minerData, err := serialize.SerializeToBytes(block.Coinbase)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"hash":       block.Hash,
+		"height":     hexutil.Uint64(block.Height),
+		"miner":      DataEncoder(minerData),
+		"timestamp":  hexutil.Uint64(block.CreateTime),
+		"detectTime": hexutil.Uint64(block.DetectTime),
+	}, nil
+}
+
+func BlockProfileEncoder(profile *rpc.BlockProfile) map[string]interface{} {
+	return map[string]interface{}{
+		"hash":          profile.Hash,
+		"height":        hexutil.Uint64(profile.Height),
+		"txCount":       hexutil.Uint64(profile.TxCount),
+		"gasUsed":       hexutil.Uint64(profile.GasUsed),
+		"gasLimit":      hexutil.Uint64(profile.GasLimit),
+		"time":          hexutil.Uint64(profile.Time),
+		"txSelectionUs": hexutil.Uint64(profile.TxSelection),
+		"executionUs":   hexutil.Uint64(profile.Execution),
+		"stateRootUs":   hexutil.Uint64(profile.StateRoot),
+		"sealWaitUs":    hexutil.Uint64(profile.SealWait),
+		"commitUs":      hexutil.Uint64(profile.Commit),
+	}
+}
+
+// BlockReplayResultEncoder encodes the result of a re-executed block with the
+// values stored with it, which it matched if matched is true.
+func BlockReplayResultEncoder(result *rpc.BlockReplayResult) map[string]interface{} {
+	badReceipts := make([]hexutil.Uint, 0, len(result.BadReceipts))
+	for _, index := range result.BadReceipts {
+		badReceipts = append(badReceipts, hexutil.Uint(index))
+	}
+	return map[string]interface{}{
+		"number":              hexutil.Uint64(result.Number),
+		"hash":                result.Hash,
+		"txCount":             hexutil.Uint64(result.TxCount),
+		"matched":             result.Matched(),
+		"stateRoot":           result.StateRoot,
+		"expectedStateRoot":   result.ExpectedStateRoot,
+		"receiptHash":         result.ReceiptHash,
+		"expectedReceiptHash": result.ExpectedReceiptHash,
+		"gasUsed":             hexutil.Uint64(result.GasUsed),
+		"expectedGasUsed":     hexutil.Uint64(result.ExpectedGasUsed),
+		"badReceipts":         badReceipts,
+		"error":               result.Error,
+	}
+}
+
+// BackupShardEncoder encodes the shard database backed up to the archive at
+// path.
+func BackupShardEncoder(fullShardId uint32, path string, rsp *rpc.BackupShardResponse) map[string]interface{} {
+	return map[string]interface{}{
+		"fullShardId": hexutil.Uint(fullShardId),
+		"path":        path,
+		"height":      hexutil.Uint64(rsp.Height),
+		"hash":        rsp.Hash,
+		"rootHeight":  hexutil.Uint64(rsp.RootHeight),
+		"rootHash":    rsp.RootHash,
+		"ancients":    hexutil.Uint64(rsp.Ancients),
+	}
+}
+
+// ShardMaintenanceEncoder encodes the state of the shard on each slave running
+// it after a maintenance action.
+func ShardMaintenanceEncoder(fullShardId uint32, rsps map[string]*rpc.ShardMaintenanceResponse) map[string]interface{} {
+	slaves := make(map[string]interface{}, len(rsps))
+	for id, rsp := range rsps {
+		slaves[id] = map[string]interface{}{
+			"paused":          rsp.Paused,
+			"reindexedBlocks": hexutil.Uint64(rsp.ReindexedBlocks),
+		}
+	}
+	return map[string]interface{}{
+		"fullShardId": hexutil.Uint(fullShardId),
+		"slaves":      slaves,
+	}
+}
+
+// TxStatusEventEncoder encodes a change of the status of a tx watched, with
+// the block including it and the root block confirming that block, null if
+// none.
+func TxStatusEventEncoder(ev *rpc.TxStatusEvent) map[string]interface{} {
+	fields := map[string]interface{}{
+		"txId":            IDEncoder(ev.TxHash.Bytes(), ev.FullShardKey),
+		"txHash":          ev.TxHash,
+		"status":          ev.Status,
+		"blockId":         nil,
+		"blockHeight":     nil,
+		"rootBlockHash":   nil,
+		"rootBlockHeight": nil,
+	}
+	if ev.BlockHash != (ethCommon.Hash{}) {
+		fields["blockId"] = IDEncoder(ev.BlockHash.Bytes(), ev.FullShardID)
+		fields["blockHeight"] = hexutil.Uint64(ev.BlockHeight)
+	}
+	if ev.RootBlockHash != (ethCommon.Hash{}) {
+		fields["rootBlockHash"] = ev.RootBlockHash
+		fields["rootBlockHeight"] = hexutil.Uint64(ev.RootBlockHeight)
+	}
+	return fields
+}
+
+// TotalBalanceEncoder encodes the balances of an account summed over the
+// shards, with the errors of the shards which failed to be read.
+func TotalBalanceEncoder(balance *rpc.TotalBalance, cached bool) map[string]interface{} {
+	errs := make([]map[string]interface{}, 0, len(balance.Errors))
+	for id, err := range balance.Errors {
+		errs = append(errs, map[string]interface{}{"fullShardId": hexutil.Uint(id), "error": err})
+	}
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i]["fullShardId"].(hexutil.Uint) < errs[j]["fullShardId"].(hexutil.Uint)
+	})
+	return map[string]interface{}{
+		"balances":   BalancesEncoder(balance.Balances),
+		"shardCount": hexutil.Uint(len(balance.Shards)),
+		"errors":     errs,
+		"cached":     cached,
+	}
+}
+
+// ExportedBlockEncoder encodes a minor block as stored, serialized, with the
+// RLP of its receipts if includeReceipts, and the RLP of each of its txs, as
+// sent by sendRawTransaction, if includeTxs.
+func ExportedBlockEncoder(block *rpc.ExportedBlock, includeReceipts, includeTxs bool) (map[string]interface{}, error) {
+	fields := map[string]interface{}{
+		"height": hexutil.Uint64(block.Number),
+		"hash":   block.Hash,
+		"block":  hexutil.Bytes(block.Block),
+	}
+	if includeReceipts {
+		fields["receipts"] = hexutil.Bytes(block.Receipts)
+	}
+	if includeTxs {
+		minorBlock := new(types.MinorBlock)
+		if err := serialize.DeserializeFromBytes(block.Block, minorBlock); err != nil {
+			return nil, err
+		}
+		txs := make([]hexutil.Bytes, 0, len(minorBlock.Transactions()))
+		for _, tx := range minorBlock.Transactions() {
+			data, err := rlp.EncodeToBytes(tx.EvmTx)
+			if err != nil {
+				return nil, err
+			}
+			txs = append(txs, data)
+		}
+		fields["transactions"] = txs
+	}
+	return fields, nil
+}
+
+// NonceReservationEncoder encodes the nonces reserved for an account in its
+// shard.
+func NonceReservationEncoder(fullShardId uint32, reservation *rpc.NonceReservation) map[string]interface{} {
+	return map[string]interface{}{
+		"fullShardId": hexutil.Uint(fullShardId),
+		"from":        hexutil.Uint64(reservation.From),
+		"count":       hexutil.Uint64(reservation.Count),
+	}
+}
+
+// NonceStateEncoder encodes what the nonce manager knows of the nonces of an
+// account in its shard.
+func NonceStateEncoder(fullShardId uint32, state *rpc.NonceState) map[string]interface{} {
+	nonces := func(list []uint64) []hexutil.Uint64 {
+		encoded := make([]hexutil.Uint64, 0, len(list))
+		for _, nonce := range list {
+			encoded = append(encoded, hexutil.Uint64(nonce))
+		}
+		return encoded
+	}
+	return map[string]interface{}{
+		"fullShardId": hexutil.Uint(fullShardId),
+		"confirmed":   hexutil.Uint64(state.Confirmed),
+		"next":        hexutil.Uint64(state.Next),
+		"reserved":    nonces(state.Reserved),
+		"inFlight":    nonces(state.InFlight),
+		"free":        nonces(state.Free),
+	}
+}
+
+// StateAvailabilityEncoder encodes whether the state of a minor block is
+// available, and the nearest lower block with its state if not.
+func StateAvailabilityEncoder(fullShardId uint32, availability *rpc.StateAvailability) map[string]interface{} {
+	fields := map[string]interface{}{
+		"fullShardId": hexutil.Uint(fullShardId),
+		"height":      hexutil.Uint64(availability.Number),
+		"hash":        availability.Hash,
+		"stateRoot":   availability.StateRoot,
+		"available":   availability.Available,
+	}
+	if availability.NearestFound {
+		fields["nearestAvailableHeight"] = hexutil.Uint64(availability.NearestAvailable)
+	} else {
+		fields["nearestAvailableHeight"] = nil
+	}
+	return fields
+}
+
+// RegenerateStateEncoder encodes the progress of each slave running the shard
+// regenerating the state of the block at the height, with the error of the
+// failed ones.
+func RegenerateStateEncoder(fullShardId uint32, height uint64, rsps map[string]*rpc.RegenerateStateResponse) map[string]interface{} {
+	slaves := make(map[string]interface{}, len(rsps))
+	for id, rsp := range rsps {
+		fields := map[string]interface{}{
+			"fromHeight":        hexutil.Uint64(rsp.From),
+			"totalBlocks":       hexutil.Uint64(rsp.Number - rsp.From),
+			"regeneratedBlocks": hexutil.Uint64(rsp.Regenerated),
+			"stateRoot":         rsp.StateRoot,
+			"done":              rsp.Done,
+			"error":             nil,
+		}
+		if rsp.Error != "" {
+			fields["error"] = rsp.Error
+		}
+		slaves[id] = fields
+	}
+	return map[string]interface{}{
+		"fullShardId": hexutil.Uint(fullShardId),
+		"height":      hexutil.Uint64(height),
+		"slaves":      slaves,
+	}
+}
+
+// TxBenchmarkReportEncoder encodes the report of the tx benchmark of a shard
+// with the TPS it achieved, the latencies are in milliseconds.
+func TxBenchmarkReportEncoder(report *rpc.TxBenchmarkReport) map[string]interface{} {
+	branch := account.Branch{Value: report.Branch}
+	tps := float64(0)
+	if report.Elapsed > 0 {
+		tps = float64(report.Confirmed) * 1000 / float64(report.Elapsed)
+	}
+	return map[string]interface{}{
+		"fullShardId":  branch.GetFullShardID(),
+		"chainId":      branch.GetChainID(),
+		"shardId":      branch.GetShardID(),
+		"running":      report.Running,
+		"targetTps":    report.TPS,
+		"tps":          tps,
+		"start":        report.Start,
+		"elapsedMs":    report.Elapsed,
+		"sent":         report.Sent,
+		"confirmed":    report.Confirmed,
+		"latencyP50Ms": report.LatencyP50,
+		"latencyP90Ms": report.LatencyP90,
+		"latencyP99Ms": report.LatencyP99,
+		"latencyMaxMs": report.LatencyMax,
+	}
+}
+
+func UnreceivedXShardDepositEncoder(deposit *rpc.UnreceivedXShardDeposit) map[string]interface{} {
+	tx := deposit.Deposit
+	return map[string]interface{}{
+		"hash":            tx.TxHash,
+		"from":            DataEncoder(tx.From.ToBytes()),
+		"to":              DataEncoder(tx.To.ToBytes()),
+		"value":           (*hexutil.Big)(tx.Value.Value),
+		"gasPrice":        (*hexutil.Big)(tx.GasPrice.Value),
+		"gasRemained":     (*hexutil.Big)(tx.GasRemained.Value),
+		"gasTokenId":      hexutil.Uint64(tx.GasTokenID),
+		"transferTokenId": hexutil.Uint64(tx.TransferTokenID),
+		"data":            DataEncoder(tx.MessageData),
+		"createContract":  tx.CreateContract,
+		"rootBlockHeight": hexutil.Uint64(deposit.RootBlockHeight),
+		"expired":         deposit.Expired,
+	}
+}
+
+func XShardQueueEncoder(queue *rpc.XShardQueue) map[string]interface{} {
+	branch := account.Branch{Value: queue.Branch}
+	return map[string]interface{}{
+		"fullShardId":      hexutil.Uint(queue.Branch),
+		"chainId":          hexutil.Uint(branch.GetChainID()),
+		"shardId":          hexutil.Uint(branch.GetShardID()),
+		"cursorRootHeight": hexutil.Uint64(queue.CursorRootHeight),
+		"rootTipHeight":    hexutil.Uint64(queue.RootTipHeight),
+		"count":            hexutil.Uint(queue.Count),
+		"totalValue":       BalancesEncoder(queue.TotalValue),
+		"oldestRootHeight": hexutil.Uint64(queue.OldestRootHeight),
+		"missingTxLists":   hexutil.Uint(queue.MissingTxLists),
+	}
+}
+
+func AccessListResultEncoder(res *rpc.CreateAccessListResponse) map[string]interface{} {
+	accessList := make(types.AccessList, 0, len(res.AccessList))
+	for _, tuple := range res.AccessList {
+		if tuple.StorageKeys == nil {
+			tuple.StorageKeys = []ethCommon.Hash{}
+		}
+		accessList = append(accessList, tuple)
+	}
+	return map[string]interface{}{
+		"accessList": accessList,
+		"gasUsed":    hexutil.Uint64(res.GasUsed),
+		"failed":     res.Failed,
+	}
+}
+
 func RootBlockEncoder(rootBlock *types.RootBlock, extraInfo *rpc.PoSWInfo) (map[string]interface{}, error) {
+	return RootBlockPageEncoder(rootBlock, extraInfo, 0, 0)
+}
+
+// RootBlockPageEncoder encodes the root block with its minor block headers from
+// offset, at most limit of them if limit isn't 0. The offset of the next page
+// is returned if headers are left.
+func RootBlockPageEncoder(rootBlock *types.RootBlock, extraInfo *rpc.PoSWInfo, offset, limit int) (map[string]interface{}, error) {
 	serData, err := serialize.SerializeToBytes(rootBlock)
 	if err != nil {
 		return nil, err
@@ -85,8 +397,14 @@ func RootBlockEncoder(rootBlock *types.RootBlock, extraInfo *rpc.PoSWInfo) (map[
 		fields["stakingApplied"] = extraInfo.EffectiveDifficulty.Cmp(header.Difficulty) < 0
 	}
 
-	minorHeaders := make([]map[string]interface{}, 0)
-	for _, header := range rootBlock.MinorBlockHeaders() {
+	headers := rootBlock.MinorBlockHeaders()
+	start, end := pageRange(len(headers), offset, limit)
+	fields["minorBlockHeaderCount"] = hexutil.Uint64(len(headers))
+	if end < len(headers) {
+		fields["nextMinorBlockHeaderOffset"] = hexutil.Uint64(end)
+	}
+	minorHeaders := make([]map[string]interface{}, 0, end-start)
+	for _, header := range headers[start:end] {
 		minerData, err := serialize.SerializeToBytes(header.Coinbase)
 		if err != nil {
 			return nil, err
@@ -140,7 +458,111 @@ func MinorBlockHeaderEncoder(header *types.MinorBlockHeader) (map[string]interfa
 	}, nil
 }
 
+// MinorChainReorgEncoder encodes a reorg of the minor chain with the headers of
+// the replaced blocks, ordered from the head down, and the ids of the txs
+// which are no longer in the chain.
+func MinorChainReorgEncoder(commonBlock *types.MinorBlock, oldChain, newChain []*types.MinorBlock, droppedTxs types.Transactions) (map[string]interface{}, error) {
+	headers := func(blocks []*types.MinorBlock) ([]map[string]interface{}, error) {
+		fields := make([]map[string]interface{}, 0, len(blocks))
+		for _, block := range blocks {
+			field, err := MinorBlockHeaderEncoder(block.Header())
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, field)
+		}
+		return fields, nil
+	}
+	oldHeaders, err := headers(oldChain)
+	if err != nil {
+		return nil, err
+	}
+	newHeaders, err := headers(newChain)
+	if err != nil {
+		return nil, err
+	}
+	field := map[string]interface{}{
+		"chain":      "minor",
+		"depth":      hexutil.Uint64(len(oldChain)),
+		"oldChain":   oldHeaders,
+		"newChain":   newHeaders,
+		"droppedTxs": make([]hexutil.Bytes, 0, len(droppedTxs)),
+	}
+	if commonBlock != nil {
+		if field["commonBlock"], err = MinorBlockHeaderEncoder(commonBlock.Header()); err != nil {
+			return nil, err
+		}
+	}
+	if len(oldChain) > 0 {
+		fullShardID := oldChain[0].Branch().Value
+		txIDs := make([]hexutil.Bytes, 0, len(droppedTxs))
+		for _, tx := range droppedTxs {
+			txIDs = append(txIDs, IDEncoder(tx.Hash().Bytes(), fullShardID))
+		}
+		field["droppedTxs"] = txIDs
+	}
+	return field, nil
+}
+
+// RootChainReorgEncoder encodes a reorg of the root chain with the replaced
+// root blocks, ordered from the head down, without their minor block headers,
+// and the ids of the minor blocks which are no longer confirmed.
+func RootChainReorgEncoder(commonBlock *types.RootBlock, oldChain, newChain []*types.RootBlock, droppedHeaders types.MinorBlockHeaders) map[string]interface{} {
+	header := func(block *types.RootBlock) map[string]interface{} {
+		return map[string]interface{}{
+			"id":                    block.Hash(),
+			"height":                hexutil.Uint64(block.NumberU64()),
+			"hash":                  block.Hash(),
+			"hashPrevBlock":         block.ParentHash(),
+			"timestamp":             hexutil.Uint64(block.Time()),
+			"minorBlockHeaderCount": hexutil.Uint64(len(block.MinorBlockHeaders())),
+		}
+	}
+	headers := func(blocks []*types.RootBlock) []map[string]interface{} {
+		fields := make([]map[string]interface{}, 0, len(blocks))
+		for _, block := range blocks {
+			fields = append(fields, header(block))
+		}
+		return fields
+	}
+	droppedIDs := make([]hexutil.Bytes, 0, len(droppedHeaders))
+	for _, h := range droppedHeaders {
+		droppedIDs = append(droppedIDs, IDEncoder(h.Hash().Bytes(), h.Branch.GetFullShardID()))
+	}
+	field := map[string]interface{}{
+		"chain":              "root",
+		"depth":              hexutil.Uint64(len(oldChain)),
+		"oldChain":           headers(oldChain),
+		"newChain":           headers(newChain),
+		"droppedMinorBlocks": droppedIDs,
+	}
+	if commonBlock != nil {
+		field["commonBlock"] = header(commonBlock)
+	}
+	return field
+}
+
+// pageRange returns the range of the page of the items from offset, at most
+// limit of them if limit isn't 0.
+func pageRange(count, offset, limit int) (int, int) {
+	if offset > count {
+		offset = count
+	}
+	if limit > 0 && limit < count-offset {
+		return offset, offset + limit
+	}
+	return offset, count
+}
+
 func MinorBlockEncoder(block *types.MinorBlock, includeTransaction bool, extraInfo *rpc.PoSWInfo) (map[string]interface{}, error) {
+	return MinorBlockPageEncoder(block, includeTransaction, extraInfo, 0, 0, nil)
+}
+
+// MinorBlockPageEncoder encodes the minor block with its txs from offset, at
+// most limit of them if limit isn't 0, and the receipts of those txs if
+// receipts isn't nil. The offset of the next page is returned if txs are left.
+func MinorBlockPageEncoder(block *types.MinorBlock, includeTransaction bool, extraInfo *rpc.PoSWInfo, offset, limit int,
+	receipts types.Receipts) (map[string]interface{}, error) {
 	serData, err := serialize.SerializeToBytes(block)
 	if err != nil {
 		return nil, err
@@ -174,9 +596,15 @@ func MinorBlockEncoder(block *types.MinorBlock, includeTransaction bool, extraIn
 		"size":               hexutil.Uint64(len(serData)),
 	}
 
+	txs := block.Transactions()
+	start, end := pageRange(len(txs), offset, limit)
+	field["transactionCount"] = hexutil.Uint64(len(txs))
+	if end < len(txs) {
+		field["nextTransactionOffset"] = hexutil.Uint64(end)
+	}
 	if includeTransaction {
 		txForDisplay := make([]map[string]interface{}, 0)
-		for txIndex, _ := range block.Transactions() {
+		for txIndex := start; txIndex < end; txIndex++ {
 			temp, err := TxEncoder(block, txIndex)
 			if err != nil {
 				return nil, err
@@ -186,11 +614,26 @@ func MinorBlockEncoder(block *types.MinorBlock, includeTransaction bool, extraIn
 		field["transactions"] = txForDisplay
 	} else {
 		txHashForDisplay := make([]hexutil.Bytes, 0)
-		for _, tx := range block.Transactions() {
+		for _, tx := range txs[start:end] {
 			txHashForDisplay = append(txHashForDisplay, IDEncoder(tx.Hash().Bytes(), block.Branch().Value))
 		}
 		field["transactions"] = txHashForDisplay
 	}
+	if receipts != nil {
+		// the receipts of the cross-shard deposits follow the ones of the txs
+		if len(receipts) < len(txs) {
+			return nil, errors.New("receipts of the block not found")
+		}
+		receiptForDisplay := make([]map[string]interface{}, 0, end-start)
+		for txIndex := start; txIndex < end; txIndex++ {
+			temp, err := ReceiptEncoder(block, txIndex, receipts[txIndex])
+			if err != nil {
+				return nil, err
+			}
+			receiptForDisplay = append(receiptForDisplay, temp)
+		}
+		field["receipts"] = receiptForDisplay
+	}
 	if extraInfo != nil && !extraInfo.IsNil() {
 		field["effectiveDifficulty"] = (*hexutil.Big)(extraInfo.EffectiveDifficulty)
 		field["poswMineableBlocks"] = (hexutil.Uint64)(extraInfo.PoswMineableBlocks)
@@ -283,6 +726,29 @@ func LogListEncoder(logList []*types.Log, isRemoved bool) []map[string]interface
 	return fields
 }
 
+// BundleTxResultEncoder encodes the result of a tx of a simulated bundle.
+func BundleTxResultEncoder(tx *types.Transaction, result *rpc.BundleTxResult) map[string]interface{} {
+	receipt := result.Receipt
+	field := map[string]interface{}{
+		"transactionId":     IDEncoder(tx.Hash().Bytes(), tx.EvmTx.FromFullShardKey()),
+		"transactionHash":   tx.Hash(),
+		"status":            hexutil.Uint64(receipt.Status),
+		"gasUsed":           hexutil.Uint64(receipt.GasUsed),
+		"cumulativeGasUsed": hexutil.Uint64(receipt.CumulativeGasUsed),
+		"returnValue":       hexutil.Bytes(result.ReturnValue),
+		"logs":              LogListEncoder(receipt.Logs, false),
+		"contractAddress":   nil,
+	}
+	if receipt.ContractAddress.Big().Uint64() != 0 {
+		addr := account.Address{
+			Recipient:    receipt.ContractAddress,
+			FullShardKey: receipt.ContractFullShardKey,
+		}
+		field["contractAddress"] = DataEncoder(addr.ToBytes())
+	}
+	return field
+}
+
 func ReceiptEncoder(block *types.MinorBlock, i int, receipt *types.Receipt) (map[string]interface{}, error) {
 	if block == nil {
 		return nil, errors.New("block is nil")
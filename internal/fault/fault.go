@@ -0,0 +1,100 @@
+// Package fault injects faults in the cluster RPC ops between the master and
+// the slaves and in the p2p commands sent to the peers, so the integration
+// tests can verify how the cluster handles the failures deterministically.
+//
+// The faults are only injected by the binaries built with the faultinject
+// build tag, the rules are rejected otherwise and the hooks cost nothing.
+// The rules are set by the fault JSON RPCs of the master, or at startup by the
+// QUARKCHAIN_FAULTS environment variable holding a JSON list of rules.
+package fault
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The layers where the faults are injected.
+const (
+	LayerRPC = "rpc" // cluster RPC op called by the master or a slave
+	LayerP2P = "p2p" // p2p command sent to a peer
+)
+
+// The actions of the faults.
+const (
+	ActionDrop    = "drop"    // the op fails without being called, the command is not sent
+	ActionDelay   = "delay"   // the op is called, the command is sent, after the delay
+	ActionCorrupt = "corrupt" // the bits of the response of the op, or of the command, are flipped
+)
+
+var (
+	// ErrDisabled is returned when setting rules in a binary built without the
+	// faultinject build tag.
+	ErrDisabled = errors.New("fault injection is not built in, build with -tags faultinject")
+	// ErrDropped is returned by the cluster RPC ops dropped by a rule.
+	ErrDropped = errors.New("dropped by fault injection")
+)
+
+// Rule injects the fault of the action in the ops of the layer matching the
+// op and the peer. The ops skipped by a rule, or matching it once it faulted
+// count ops, are left to the next rules.
+type Rule struct {
+	Layer  string `json:"layer"`
+	Op     string `json:"op,omitempty"`   // name of the cluster RPC op or of the p2p command, any if empty
+	Peer   string `json:"peer,omitempty"` // master, slave ID or endpoint called, or node ID or address sent to, any if empty
+	Action string `json:"action"`
+	Delay  uint64 `json:"delay,omitempty"` // milliseconds, for the delay action
+	Skip   uint64 `json:"skip,omitempty"`  // number of the first matching ops let through
+	Count  uint64 `json:"count,omitempty"` // number of the matching ops faulted after the skipped ones, all if 0
+	Hits   uint64 `json:"hits"`            // number of the ops faulted so far, ignored when set
+}
+
+// Validate checks the layer and the action of the rule.
+func (r *Rule) Validate() error {
+	if r.Layer != LayerRPC && r.Layer != LayerP2P {
+		return fmt.Errorf("unknown fault layer %q", r.Layer)
+	}
+	switch r.Action {
+	case ActionDrop, ActionCorrupt:
+	case ActionDelay:
+		if r.Delay == 0 {
+			return errors.New("delay fault without delay")
+		}
+	default:
+		return fmt.Errorf("unknown fault action %q", r.Action)
+	}
+	return nil
+}
+
+// matches reports whether the rule applies to the op of the layer called on,
+// or sent to, the peer known by any of the names.
+func (r *Rule) matches(layer, op string, peers []string) bool {
+	if r.Layer != layer || (r.Op != "" && r.Op != op) {
+		return false
+	}
+	if r.Peer == "" {
+		return true
+	}
+	for _, peer := range peers {
+		if peer == r.Peer {
+			return true
+		}
+	}
+	return false
+}
+
+// Fault is the fault injected in an op, a zero Fault injects nothing.
+type Fault struct {
+	Action string
+	Delay  time.Duration
+}
+
+// Corrupt returns a copy of the data with the bits flipped from offset on.
+func Corrupt(data []byte, offset int) []byte {
+	corrupted := make([]byte, len(data))
+	copy(corrupted, data)
+	for i := offset; i < len(corrupted); i++ {
+		corrupted[i] ^= 0xff
+	}
+	return corrupted
+}
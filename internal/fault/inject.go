@@ -0,0 +1,108 @@
+// +build faultinject
+
+package fault
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Enabled reports whether the binary is built with the faultinject build tag.
+const Enabled = true
+
+type ruleState struct {
+	Rule
+	seen uint64 // number of the matching ops
+}
+
+var (
+	mu    sync.Mutex
+	rules []*ruleState
+)
+
+func init() {
+	env := os.Getenv("QUARKCHAIN_FAULTS")
+	if env == "" {
+		return
+	}
+	var list []Rule
+	if err := json.Unmarshal([]byte(env), &list); err != nil {
+		log.Error("Failed to parse QUARKCHAIN_FAULTS", "err", err)
+		return
+	}
+	if err := Set(list); err != nil {
+		log.Error("Invalid QUARKCHAIN_FAULTS", "err", err)
+	}
+}
+
+// Set replaces the rules.
+func Set(list []Rule) error {
+	states := make([]*ruleState, 0, len(list))
+	for _, rule := range list {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+		rule.Hits = 0
+		states = append(states, &ruleState{Rule: rule})
+	}
+	mu.Lock()
+	rules = states
+	mu.Unlock()
+	log.Warn("Fault injection rules set", "rules", len(states))
+	return nil
+}
+
+// Add appends the rule to the rules.
+func Add(rule Rule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	rule.Hits = 0
+	mu.Lock()
+	rules = append(rules, &ruleState{Rule: rule})
+	mu.Unlock()
+	log.Warn("Fault injection rule added", "layer", rule.Layer, "op", rule.Op, "peer", rule.Peer, "action", rule.Action)
+	return nil
+}
+
+// Clear removes all the rules.
+func Clear() {
+	mu.Lock()
+	rules = nil
+	mu.Unlock()
+}
+
+// Rules returns the rules with the number of the ops they faulted.
+func Rules() []Rule {
+	mu.Lock()
+	defer mu.Unlock()
+	list := make([]Rule, 0, len(rules))
+	for _, state := range rules {
+		list = append(list, state.Rule)
+	}
+	return list
+}
+
+// Inject returns the fault to inject in the op of the layer called on, or sent
+// to, the peer known by any of the names.
+func Inject(layer, op string, peers ...string) Fault {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, state := range rules {
+		if !state.matches(layer, op, peers) {
+			continue
+		}
+		state.seen++
+		if state.seen <= state.Skip || (state.Count != 0 && state.Hits >= state.Count) {
+			continue
+		}
+		state.Hits++
+		log.Debug("Injected fault", "layer", layer, "op", op, "peers", peers, "action", state.Action)
+		return Fault{Action: state.Action, Delay: time.Duration(state.Delay) * time.Millisecond}
+	}
+	return Fault{}
+}
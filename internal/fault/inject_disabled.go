@@ -0,0 +1,21 @@
+// +build !faultinject
+
+package fault
+
+// Enabled reports whether the binary is built with the faultinject build tag.
+const Enabled = false
+
+// Set fails, the faults are not built in.
+func Set(list []Rule) error { return ErrDisabled }
+
+// Add fails, the faults are not built in.
+func Add(rule Rule) error { return ErrDisabled }
+
+// Clear does nothing, the faults are not built in.
+func Clear() {}
+
+// Rules returns no rules, the faults are not built in.
+func Rules() []Rule { return []Rule{} }
+
+// Inject injects no fault, the faults are not built in.
+func Inject(layer, op string, peers ...string) Fault { return Fault{} }
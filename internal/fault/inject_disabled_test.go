@@ -0,0 +1,16 @@
+// +build !faultinject
+
+package fault
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInjectDisabled(t *testing.T) {
+	assert.Equal(t, ErrDisabled, Set([]Rule{{Layer: LayerRPC, Action: ActionDrop}}))
+	assert.Equal(t, ErrDisabled, Add(Rule{Layer: LayerRPC, Action: ActionDrop}))
+	assert.Empty(t, Rules())
+	assert.Equal(t, Fault{}, Inject(LayerRPC, "Ping", "S0"))
+}
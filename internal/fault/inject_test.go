@@ -0,0 +1,52 @@
+// +build faultinject
+
+package fault
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInject(t *testing.T) {
+	defer Clear()
+	assert.Error(t, Set([]Rule{{Layer: "disk", Action: ActionDrop}}))
+	assert.Error(t, Set([]Rule{{Layer: LayerRPC, Action: ActionDelay}}))
+	assert.Error(t, Add(Rule{Layer: LayerP2P, Action: "reorder"}))
+
+	assert.NoError(t, Set([]Rule{
+		{Layer: LayerRPC, Op: "Ping", Peer: "S1", Action: ActionDrop, Skip: 1, Count: 2},
+		{Layer: LayerRPC, Peer: "S1", Action: ActionDelay, Delay: 100},
+	}))
+	assert.NoError(t, Add(Rule{Layer: LayerP2P, Op: "Tip", Action: ActionCorrupt, Hits: 10}))
+
+	delay := Fault{Action: ActionDelay, Delay: 100 * time.Millisecond}
+	assert.Equal(t, Fault{}, Inject(LayerRPC, "Ping", "S0", "127.0.0.1:38000"))
+	// the first ping is skipped, the next two are dropped, and the others are
+	// left to the next rule
+	assert.Equal(t, delay, Inject(LayerRPC, "Ping", "S1", "127.0.0.1:38001"))
+	assert.Equal(t, Fault{Action: ActionDrop}, Inject(LayerRPC, "Ping", "S1", "127.0.0.1:38001"))
+	assert.Equal(t, Fault{Action: ActionDrop}, Inject(LayerRPC, "Ping", "S1"))
+	assert.Equal(t, delay, Inject(LayerRPC, "Ping", "S1"))
+	assert.Equal(t, Fault{}, Inject(LayerRPC, "Ping", "127.0.0.1:38001"))
+	assert.Equal(t, delay, Inject(LayerRPC, "GetMinorBlock", "S1"))
+	assert.Equal(t, Fault{Action: ActionCorrupt}, Inject(LayerP2P, "Tip", "abcd"))
+	assert.Equal(t, Fault{}, Inject(LayerP2P, "NewRootBlockCommand", "abcd"))
+
+	rules := Rules()
+	assert.Len(t, rules, 3)
+	assert.Equal(t, uint64(2), rules[0].Hits)
+	assert.Equal(t, uint64(3), rules[1].Hits)
+	assert.Equal(t, uint64(1), rules[2].Hits)
+
+	Clear()
+	assert.Empty(t, Rules())
+	assert.Equal(t, Fault{}, Inject(LayerP2P, "Tip", "abcd"))
+}
+
+func TestCorrupt(t *testing.T) {
+	data := []byte{0x00, 0x0f, 0xf0}
+	assert.Equal(t, []byte{0x00, 0xf0, 0x0f}, Corrupt(data, 1))
+	assert.Equal(t, []byte{0x00, 0x0f, 0xf0}, data)
+}
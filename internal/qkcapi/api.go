@@ -2,20 +2,34 @@ package qkcapi
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"math/big"
 	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/cluster/audit"
+	"github.com/QuarkChain/goquarkchain/cluster/config"
 	qrpc "github.com/QuarkChain/goquarkchain/cluster/rpc"
 	qcom "github.com/QuarkChain/goquarkchain/common"
 	"github.com/QuarkChain/goquarkchain/common/hexutil"
+	"github.com/QuarkChain/goquarkchain/core"
 	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/internal/debug"
 	"github.com/QuarkChain/goquarkchain/internal/encoder"
+	"github.com/QuarkChain/goquarkchain/internal/fault"
+	"github.com/QuarkChain/goquarkchain/p2p"
 	"github.com/QuarkChain/goquarkchain/rpc"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
 	"github.com/ethereum/go-ethereum/rlp"
 )
 
@@ -23,7 +37,7 @@ type CommonAPI struct {
 	b Backend
 }
 
-func (c *CommonAPI) callOrEstimateGas(args *CallArgs, height *uint64, isCall bool) (hexutil.Bytes, error) {
+func (c *CommonAPI) callOrEstimateGas(ctx context.Context, args *CallArgs, height *uint64, overrides StateOverride, isCall bool) (hexutil.Bytes, error) {
 	if args.To == nil {
 		return nil, errors.New("missing to")
 	}
@@ -37,20 +51,82 @@ func (c *CommonAPI) callOrEstimateGas(args *CallArgs, height *uint64, isCall boo
 		if !isSameChain {
 			return nil, fmt.Errorf("Call cross-shard tx not supported yet\n")
 		}
-		res, err := c.b.ExecuteTransaction(tx, args.From, height)
+		res, err := c.b.ExecuteTransaction(ctx, tx, args.From, height, overrides.toAccountOverrides())
 		if err != nil {
 			return nil, err
 		}
 		return (hexutil.Bytes)(res), nil
 	}
-	data, err := c.b.EstimateGas(tx, args.From)
+	data, err := c.b.EstimateGas(ctx, tx, args.From)
 	if err != nil {
 		return nil, err
 	}
 	return qcom.Uint32ToBytes(data), nil
 }
 
-func (c *CommonAPI) SendRawTransaction(encodedTx hexutil.Bytes) (hexutil.Bytes, error) {
+// callPending executes the call on the pending state of the shard, with the
+// txs of the pool applied.
+func (c *CommonAPI) callPending(ctx context.Context, args *CallArgs, overrides StateOverride) (hexutil.Bytes, error) {
+	if args.To == nil {
+		return nil, errors.New("missing to")
+	}
+	args.setDefaults()
+	if !clusterCfg.Quarkchain.IsSameFullShard(args.From.FullShardKey, args.To.FullShardKey) {
+		return nil, fmt.Errorf("Call cross-shard tx not supported yet\n")
+	}
+	tx, err := args.toTx(c.b.GetClusterConfig().Quarkchain)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.b.ExecutePendingTransaction(ctx, tx, args.From, overrides.toAccountOverrides())
+	if err != nil {
+		return nil, err
+	}
+	return (hexutil.Bytes)(res), nil
+}
+
+func (c *CommonAPI) createAccessList(ctx context.Context, args *CallArgs, height *uint64) (map[string]interface{}, error) {
+	if args.To == nil {
+		return nil, errors.New("missing to")
+	}
+	args.setDefaults()
+	if !clusterCfg.Quarkchain.IsSameFullShard(args.From.FullShardKey, args.To.FullShardKey) {
+		return nil, fmt.Errorf("Access list of cross-shard tx not supported yet\n")
+	}
+	tx, err := args.toTx(c.b.GetClusterConfig().Quarkchain)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.b.CreateAccessList(ctx, tx, args.From, height)
+	if err != nil {
+		return nil, err
+	}
+	return encoder.AccessListResultEncoder(res), nil
+}
+
+// txRejectedError passes the reason a tx was rejected for by the policy of the
+// tx pool of its shard to the caller, in the data of the JSON RPC error.
+type txRejectedError struct {
+	err    error
+	reason string
+}
+
+func (e *txRejectedError) Error() string { return e.err.Error() }
+
+func (e *txRejectedError) ErrorData() interface{} {
+	return map[string]string{"reason": e.reason}
+}
+
+// addTransactionError returns the error of the tx added with the reason it was
+// rejected for if any.
+func addTransactionError(err error) error {
+	if reason := core.TxRejectionReason(err); reason != "" {
+		return &txRejectedError{err, reason}
+	}
+	return err
+}
+
+func (c *CommonAPI) SendRawTransaction(ctx context.Context, encodedTx hexutil.Bytes) (hexutil.Bytes, error) {
 	evmTx := new(types.EvmTransaction)
 	if err := rlp.DecodeBytes(encodedTx, evmTx); err != nil {
 		return nil, err
@@ -60,13 +136,13 @@ func (c *CommonAPI) SendRawTransaction(encodedTx hexutil.Bytes) (hexutil.Bytes,
 		TxType: types.EvmTx,
 	}
 
-	if err := c.b.AddTransaction(tx); err != nil {
-		return EmptyTxID, err
+	if err := c.b.AddTransaction(ctx, tx); err != nil {
+		return EmptyTxID, addTransactionError(err)
 	}
 	return encoder.IDEncoder(tx.Hash().Bytes(), tx.EvmTx.FromFullShardKey()), nil
 }
 
-func (c *CommonAPI) GetTransactionReceipt(txID hexutil.Bytes) (map[string]interface{}, error) {
+func (c *CommonAPI) GetTransactionReceipt(ctx context.Context, txID hexutil.Bytes) (map[string]interface{}, error) {
 	txHash, fullShardKey, err := encoder.IDDecoder(txID)
 	if err != nil {
 		return nil, err
@@ -77,7 +153,7 @@ func (c *CommonAPI) GetTransactionReceipt(txID hexutil.Bytes) (map[string]interf
 		return nil, err
 	}
 	branch := account.Branch{Value: fullShardId}
-	minorBlock, index, receipt, err := c.b.GetTransactionReceipt(txHash, branch)
+	minorBlock, index, receipt, err := c.b.GetTransactionReceipt(ctx, txHash, branch)
 	if err != nil {
 		return nil, err
 	}
@@ -89,29 +165,41 @@ func (c *CommonAPI) GetTransactionReceipt(txID hexutil.Bytes) (map[string]interf
 	return ret, err
 }
 
-func (c *CommonAPI) GetLogs(args *rpc.FilterQuery, fullShardKey *hexutil.Uint) ([]map[string]interface{}, error) {
+func (c *CommonAPI) GetLogs(ctx context.Context, args *rpc.FilterQuery, fullShardKey *hexutil.Uint) ([]map[string]interface{}, error) {
 	fullShardID, err := getFullShardId(fullShardKey)
 	if err != nil {
 		return nil, err
 	}
-	lastBlockHeight, err := c.b.GetLastMinorBlockByFullShardID(fullShardID)
-	if err != nil {
+	if args.FromBlock, err = c.resolveLogsBlock(fullShardID, args.FromBlock); err != nil {
 		return nil, err
 	}
-	if args.FromBlock == nil || args.FromBlock.Int64() == rpc.LatestBlockNumber.Int64() {
-		args.FromBlock = new(big.Int).SetUint64(lastBlockHeight)
-	}
-
-	if args.ToBlock == nil || args.ToBlock.Int64() == rpc.LatestBlockNumber.Int64() {
-		args.ToBlock = new(big.Int).SetUint64(lastBlockHeight)
+	if args.ToBlock, err = c.resolveLogsBlock(fullShardID, args.ToBlock); err != nil {
+		return nil, err
 	}
 
 	args.FullShardId = fullShardID
 
-	log, err := c.b.GetLogs(args)
+	log, err := c.b.GetLogs(ctx, args)
 	return encoder.LogListEncoder(log, false), nil
 }
 
+// resolveLogsBlock returns the height of the block named by the block number
+// of a logs filter, the latest block if nil.
+func (c *CommonAPI) resolveLogsBlock(fullShardID uint32, number *big.Int) (*big.Int, error) {
+	blockNr := rpc.LatestBlockNumber
+	if number != nil {
+		if number.Sign() >= 0 {
+			return number, nil
+		}
+		blockNr = rpc.BlockNumber(number.Int64())
+	}
+	height, err := c.b.ResolveMinorBlockNumber(fullShardID, blockNr)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetUint64(height), nil
+}
+
 // It offers only methods that operate on public data that is freely available to anyone.
 type PublicBlockChainAPI struct {
 	CommonAPI
@@ -160,30 +248,33 @@ func (p *PublicBlockChainAPI) NetworkInfo() map[string]interface{} {
 
 }
 
-func (p *PublicBlockChainAPI) getPrimaryAccountData(address account.Address, blockNr *rpc.BlockNumber) (data *qrpc.AccountBranchData, err error) {
+func (p *PublicBlockChainAPI) getPrimaryAccountData(ctx context.Context, address account.Address, blockNr *rpc.BlockNumber) (data *qrpc.AccountBranchData, err error) {
 	if blockNr == nil {
-		data, err = p.b.GetPrimaryAccountData(&address, nil)
+		data, err = p.b.GetPrimaryAccountData(ctx, &address, nil)
 		return
 	}
+	if *blockNr == rpc.PendingBlockNumber {
+		return p.b.GetPendingAccountData(ctx, &address)
+	}
 
-	blockNumber, err := decodeBlockNumberToUint64(p.b, blockNr)
+	blockNumber, err := decodeBlockNumberOfAddress(p.b, &address, blockNr)
 	if err != nil {
 		return nil, err
 	}
 
-	return p.b.GetPrimaryAccountData(&address, blockNumber)
+	return p.b.GetPrimaryAccountData(ctx, &address, blockNumber)
 }
 
-func (p *PublicBlockChainAPI) GetTransactionCount(address account.Address, blockNr *rpc.BlockNumber) (hexutil.Uint64, error) {
-	data, err := p.getPrimaryAccountData(address, blockNr)
+func (p *PublicBlockChainAPI) GetTransactionCount(ctx context.Context, address account.Address, blockNr *rpc.BlockNumber) (hexutil.Uint64, error) {
+	data, err := p.getPrimaryAccountData(ctx, address, blockNr)
 	if err != nil {
 		return 0, err
 	}
 	return hexutil.Uint64(data.TransactionCount), nil
 }
 
-func (p *PublicBlockChainAPI) GetBalances(address account.Address, blockNr *rpc.BlockNumber) (map[string]interface{}, error) {
-	data, err := p.getPrimaryAccountData(address, blockNr)
+func (p *PublicBlockChainAPI) GetBalances(ctx context.Context, address account.Address, blockNr *rpc.BlockNumber) (map[string]interface{}, error) {
+	data, err := p.getPrimaryAccountData(ctx, address, blockNr)
 	if err != nil {
 		return nil, err
 	}
@@ -199,7 +290,114 @@ func (p *PublicBlockChainAPI) GetBalances(address account.Address, blockNr *rpc.
 	return fields, nil
 }
 
-func (p *PublicBlockChainAPI) GetAccountData(args GetAccountDataArgs) (map[string]interface{}, error) {
+// GetBalanceHistory returns the balances of the address at the block height of
+// its shard from the balance history index, which needs no archive state, and
+// the height of the block which changed them last.
+func (p *PublicBlockChainAPI) GetBalanceHistory(ctx context.Context, address account.Address, blockNr *rpc.BlockNumber) (map[string]interface{}, error) {
+	blockNumber, err := decodeBlockNumberOfAddress(p.b, &address, blockNr)
+	if err != nil {
+		return nil, err
+	}
+	data, err := p.b.GetBalanceHistory(ctx, &address, blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	branch := account.Branch{Value: data.Branch}
+	fields := map[string]interface{}{
+		"branch":        hexutil.Uint64(branch.Value),
+		"fullShardId":   hexutil.Uint64(branch.GetFullShardID()),
+		"shardId":       hexutil.Uint64(branch.GetShardID()),
+		"chainId":       hexutil.Uint64(branch.GetChainID()),
+		"height":        hexutil.Uint64(data.Height),
+		"changedHeight": hexutil.Uint64(data.ChangedHeight),
+		"balances":      encoder.BalancesEncoder(data.Balances),
+	}
+	return fields, nil
+}
+
+// GetStateAvailability tells whether the state of the canonical minor block of
+// the shard at the height is available to the calls on it, and if not, the
+// nearest lower block with its state, from which admin_regenerateState can
+// regenerate it.
+func (p *PublicBlockChainAPI) GetStateAvailability(ctx context.Context, fullShardKey hexutil.Uint, height hexutil.Uint64) (map[string]interface{}, error) {
+	fullShardId, err := getFullShardId(&fullShardKey)
+	if err != nil {
+		return nil, err
+	}
+	availability, err := p.b.GetStateAvailability(ctx, account.Branch{Value: fullShardId}, uint64(height))
+	if err != nil {
+		return nil, err
+	}
+	return encoder.StateAvailabilityEncoder(fullShardId, availability), nil
+}
+
+// GetTokenBalances returns the balance of token held by address in each shard.
+func (p *PublicBlockChainAPI) GetTokenBalances(ctx context.Context, address account.Address, token string) ([]map[string]interface{}, error) {
+	tokenID, err := qcom.TokenIDEncodeWithCheck(token)
+	if err != nil {
+		return nil, err
+	}
+	branchToAccountBranchData, err := p.b.GetAccountData(ctx, &address, nil)
+	if err != nil {
+		return nil, err
+	}
+	balances := make([]map[string]interface{}, 0, len(branchToAccountBranchData))
+	for branch, accountBranchData := range branchToAccountBranchData {
+		branch := account.Branch{Value: branch}
+		balance := map[string]interface{}{
+			"fullShardId": hexutil.Uint(branch.GetFullShardID()),
+			"shardId":     hexutil.Uint(branch.GetShardID()),
+			"chainId":     hexutil.Uint(branch.GetChainID()),
+			"tokenId":     hexutil.Uint64(tokenID),
+			"tokenStr":    token,
+		}
+		if accountBranchData.Error != "" {
+			balance["error"] = accountBranchData.Error
+		} else {
+			balance["balance"] = (*hexutil.Big)(accountBranchData.Balance.GetTokenBalance(tokenID))
+		}
+		balances = append(balances, balance)
+	}
+	sort.Slice(balances, func(i, j int) bool {
+		return balances[i]["fullShardId"].(hexutil.Uint) < balances[j]["fullShardId"].(hexutil.Uint)
+	})
+	return balances, nil
+}
+
+// GetTotalBalance returns the balances of the account summed over all the
+// shards by token, so that the wallets don't query each shard. The shards
+// which failed to be read are listed with their error and not summed.
+func (p *PublicBlockChainAPI) GetTotalBalance(ctx context.Context, address account.Address) (map[string]interface{}, error) {
+	balance, cached, err := p.b.GetTotalBalance(ctx, &address)
+	if err != nil {
+		return nil, err
+	}
+	return encoder.TotalBalanceEncoder(balance, cached), nil
+}
+
+// GasTokenExchangeRates returns the price in genesis token of every token
+// which can be used to pay gas.
+func (p *PublicBlockChainAPI) GasTokenExchangeRates() []map[string]interface{} {
+	genesisToken := clusterCfg.Quarkchain.GenesisToken
+	rates := []map[string]interface{}{{
+		"tokenId":  hexutil.Uint64(clusterCfg.Quarkchain.GetDefaultChainTokenID()),
+		"tokenStr": genesisToken,
+		"rate":     "1",
+	}}
+	for token, rate := range clusterCfg.Quarkchain.GasTokenExchangeRates {
+		if token == genesisToken {
+			continue
+		}
+		rates = append(rates, map[string]interface{}{
+			"tokenId":  hexutil.Uint64(qcom.TokenIDEncode(token)),
+			"tokenStr": token,
+			"rate":     rate.FloatString(6),
+		})
+	}
+	return rates
+}
+
+func (p *PublicBlockChainAPI) GetAccountData(ctx context.Context, args GetAccountDataArgs) (map[string]interface{}, error) {
 	address, blockNr, includeShards := args.Address, args.BlockHeight, args.IncludeShards
 	if includeShards != nil && blockNr != nil {
 		return nil, errors.New("do not allow specify height if client wants info on all shards")
@@ -209,47 +407,59 @@ func (p *PublicBlockChainAPI) GetAccountData(args GetAccountDataArgs) (map[strin
 		includeShards = &t
 	}
 	if !(*includeShards) {
-		accountBranchData, err := p.getPrimaryAccountData(address, blockNr)
+		accountBranchData, err := p.getPrimaryAccountData(ctx, address, blockNr)
 		if err != nil {
 			return nil, err
 		}
 		branch := account.Branch{Value: accountBranchData.Branch}
 		primary := map[string]interface{}{
-			"fullShardId":        hexutil.Uint(branch.GetFullShardID()),
-			"shardId":            hexutil.Uint(branch.GetShardID()),
-			"chainId":            hexutil.Uint(branch.GetChainID()),
-			"balances":           encoder.BalancesEncoder(accountBranchData.Balance),
-			"transactionCount":   hexutil.Uint64(accountBranchData.TransactionCount),
-			"isContract":         accountBranchData.IsContract,
-			"minedBlocks":        hexutil.Uint64(accountBranchData.MinedBlocks),
-			"poswMineableBlocks": hexutil.Uint64(accountBranchData.PoswMineableBlocks),
+			"fullShardId":             hexutil.Uint(branch.GetFullShardID()),
+			"shardId":                 hexutil.Uint(branch.GetShardID()),
+			"chainId":                 hexutil.Uint(branch.GetChainID()),
+			"balances":                encoder.BalancesEncoder(accountBranchData.Balance),
+			"transactionCount":        hexutil.Uint64(accountBranchData.TransactionCount),
+			"pendingTransactionCount": hexutil.Uint64(accountBranchData.PendingTxCount),
+			"queuedTransactionCount":  hexutil.Uint64(accountBranchData.QueuedTxCount),
+			"isContract":              accountBranchData.IsContract,
+			"minedBlocks":             hexutil.Uint64(accountBranchData.MinedBlocks),
+			"poswMineableBlocks":      hexutil.Uint64(accountBranchData.PoswMineableBlocks),
 		}
 		return map[string]interface{}{
 			"primary": primary,
 		}, nil
 	}
-	branchToAccountBranchData, err := p.b.GetAccountData(&address, nil)
+	branchToAccountBranchData, err := p.b.GetAccountData(ctx, &address, nil)
 	if err != nil {
 		return nil, err
 	}
 
+	fullShardIDByConfig, err := clusterCfg.Quarkchain.GetFullShardIdByFullShardKey(address.FullShardKey)
+	if err != nil {
+		return nil, err
+	}
+	// the shards failed to be read are returned with their error only
 	shards := make([]map[string]interface{}, 0)
 	primary := make(map[string]interface{})
 	for branch, accountBranchData := range branchToAccountBranchData {
 		branch := account.Branch{Value: branch}
 		shardData := map[string]interface{}{
-			"fullShardId":      hexutil.Uint(branch.GetFullShardID()),
-			"shardId":          hexutil.Uint(branch.GetShardID()),
-			"chainId":          hexutil.Uint(branch.GetChainID()),
-			"balances":         encoder.BalancesEncoder(accountBranchData.Balance),
-			"transactionCount": hexutil.Uint(accountBranchData.TransactionCount),
-			"isContract":       accountBranchData.IsContract,
+			"fullShardId": hexutil.Uint(branch.GetFullShardID()),
+			"shardId":     hexutil.Uint(branch.GetShardID()),
+			"chainId":     hexutil.Uint(branch.GetChainID()),
 		}
 		shards = append(shards, shardData)
-		fullShardIDByConfig, err := clusterCfg.Quarkchain.GetFullShardIdByFullShardKey(address.FullShardKey)
-		if err != nil {
-			return nil, err
+		if accountBranchData.Error != "" {
+			shardData["error"] = accountBranchData.Error
+			if branch.GetFullShardID() == fullShardIDByConfig {
+				primary = shardData
+			}
+			continue
 		}
+		shardData["balances"] = encoder.BalancesEncoder(accountBranchData.Balance)
+		shardData["transactionCount"] = hexutil.Uint(accountBranchData.TransactionCount)
+		shardData["pendingTransactionCount"] = hexutil.Uint64(accountBranchData.PendingTxCount)
+		shardData["queuedTransactionCount"] = hexutil.Uint64(accountBranchData.QueuedTxCount)
+		shardData["isContract"] = accountBranchData.IsContract
 		if branch.GetFullShardID() == fullShardIDByConfig {
 			primary = shardData
 			primary["minedBlocks"] = hexutil.Uint64(accountBranchData.MinedBlocks)
@@ -263,7 +473,7 @@ func (p *PublicBlockChainAPI) GetAccountData(args GetAccountDataArgs) (map[strin
 
 }
 
-func (p *PublicBlockChainAPI) SendTransaction(args SendTxArgs) (hexutil.Bytes, error) {
+func (p *PublicBlockChainAPI) SendTransaction(ctx context.Context, args SendTxArgs) (hexutil.Bytes, error) {
 	if err := args.setDefaults(clusterCfg.Quarkchain); err != nil {
 		return nil, err
 	}
@@ -271,13 +481,25 @@ func (p *PublicBlockChainAPI) SendTransaction(args SendTxArgs) (hexutil.Bytes, e
 	if err != nil {
 		return nil, err
 	}
-	if err := p.b.AddTransaction(tx); err != nil {
-		return EmptyTxID, err
+	if err := p.b.AddTransaction(ctx, tx); err != nil {
+		return EmptyTxID, addTransactionError(err)
 	}
 	return encoder.IDEncoder(tx.Hash().Bytes(), tx.EvmTx.FromFullShardKey()), nil
 }
 
-func (p *PublicBlockChainAPI) GetRootBlockById(hash common.Hash, needExtraInfo *bool) (map[string]interface{}, error) {
+// encodeRootBlock encodes the root block with the page of its minor block
+// headers, all of them if page is nil.
+func encodeRootBlock(rootBlock *types.RootBlock, poswInfo *qrpc.PoSWInfo, page *BlockPageArgs) (map[string]interface{}, error) {
+	if page == nil {
+		return encoder.RootBlockEncoder(rootBlock, poswInfo)
+	}
+	if page.IncludeReceipts {
+		return nil, errors.New("root blocks have no receipts")
+	}
+	return encoder.RootBlockPageEncoder(rootBlock, poswInfo, int(page.Offset), int(page.Limit))
+}
+
+func (p *PublicBlockChainAPI) GetRootBlockById(hash common.Hash, needExtraInfo *bool, page *BlockPageArgs) (map[string]interface{}, error) {
 	if needExtraInfo == nil {
 		temp := true
 		needExtraInfo = &temp
@@ -286,11 +508,11 @@ func (p *PublicBlockChainAPI) GetRootBlockById(hash common.Hash, needExtraInfo *
 	if err != nil {
 		return nil, err
 	}
-	return encoder.RootBlockEncoder(rootBlock, poswInfo)
+	return encodeRootBlock(rootBlock, poswInfo, page)
 }
 
-func (p *PublicBlockChainAPI) GetRootBlockByHeight(heightInput *hexutil.Uint64, needExtraInfo *bool) (map[string]interface{}, error) {
-	blockHeight, err := transHexutilUint64ToUint64(heightInput)
+func (p *PublicBlockChainAPI) GetRootBlockByHeight(heightInput *rpc.BlockNumber, needExtraInfo *bool, page *BlockPageArgs) (map[string]interface{}, error) {
+	blockHeight, err := decodeRootBlockNumberToUint64(p.b, heightInput)
 	if err != nil {
 		return nil, err
 	}
@@ -302,14 +524,79 @@ func (p *PublicBlockChainAPI) GetRootBlockByHeight(heightInput *hexutil.Uint64,
 	if err != nil {
 		return nil, err
 	}
-	response, err := encoder.RootBlockEncoder(rootBlock, poswInfo)
+	response, err := encodeRootBlock(rootBlock, poswInfo, page)
 	if err != nil {
 		return nil, err
 	}
 	return response, nil
 }
 
-func (p *PublicBlockChainAPI) GetMinorBlockById(blockID hexutil.Bytes, includeTxs *bool, needExtraInfo *bool) (map[string]interface{}, error) {
+// GetFinalizedRootBlockHeight returns the height of the last root block which can
+// no longer be reorganized, 0 if the reorg depth is not limited.
+func (p *PublicBlockChainAPI) GetFinalizedRootBlockHeight() hexutil.Uint64 {
+	return hexutil.Uint64(p.b.FinalizedRootBlockNumber())
+}
+
+// GetForkSchedule returns the forks of the cluster and whether they are active:
+// at the root chain tip for the forks activated by root block height, at the
+// tips of every shard they apply to for the others, with the shards they are
+// active on.
+func (p *PublicBlockChainAPI) GetForkSchedule() []map[string]interface{} {
+	tip := p.b.CurrentBlock().Header()
+	statuses := p.b.GetShardStatuses()
+	forks := clusterCfg.Quarkchain.ForkSchedule()
+	fields := make([]map[string]interface{}, 0, len(forks))
+	for _, fork := range forks {
+		field := map[string]interface{}{
+			"name":       fork.Name,
+			"activateBy": fork.ActivateBy.String(),
+			"activation": hexutil.Uint64(fork.Activation),
+		}
+		if fork.ChainID != nil {
+			field["chainId"] = hexutil.Uint(*fork.ChainID)
+		}
+		if fork.ActivateBy == config.ActivateByRootHeight {
+			field["active"] = fork.Active(tip.NumberU64(), 0, 0)
+			fields = append(fields, field)
+			continue
+		}
+		applied, shards := 0, make([]hexutil.Uint, 0)
+		for _, status := range statuses {
+			if fork.ChainID != nil && status.Branch.GetChainID() != *fork.ChainID {
+				continue
+			}
+			applied++
+			if fork.Active(tip.NumberU64(), status.Height, status.Timestamp) {
+				shards = append(shards, hexutil.Uint(status.Branch.Value))
+			}
+		}
+		field["active"], field["activeShards"] = applied > 0 && len(shards) == applied, shards
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// encodeMinorBlock encodes the minor block with the page of its txs, all of them
+// if page is nil, and their receipts if the page includes them.
+func (p *PublicBlockChainAPI) encodeMinorBlock(ctx context.Context, minorBlock *types.MinorBlock, includeTxs bool, extraInfo *qrpc.PoSWInfo,
+	page *BlockPageArgs) (map[string]interface{}, error) {
+	if page == nil {
+		return encoder.MinorBlockEncoder(minorBlock, includeTxs, extraInfo)
+	}
+	var receipts types.Receipts
+	if page.IncludeReceipts {
+		var err error
+		if receipts, err = p.b.GetMinorBlockReceipts(ctx, minorBlock.Hash(), minorBlock.Branch()); err != nil {
+			return nil, err
+		}
+		if receipts == nil {
+			receipts = types.Receipts{}
+		}
+	}
+	return encoder.MinorBlockPageEncoder(minorBlock, includeTxs, extraInfo, int(page.Offset), int(page.Limit), receipts)
+}
+
+func (p *PublicBlockChainAPI) GetMinorBlockById(ctx context.Context, blockID hexutil.Bytes, includeTxs *bool, needExtraInfo *bool, page *BlockPageArgs) (map[string]interface{}, error) {
 	if includeTxs == nil {
 		temp := false
 		includeTxs = &temp
@@ -328,19 +615,24 @@ func (p *PublicBlockChainAPI) GetMinorBlockById(blockID hexutil.Bytes, includeTx
 		return nil, err
 	}
 	branch := account.Branch{Value: fullShardId}
-	minorBlock, extra, err := p.b.GetMinorBlockByHash(blockHash, branch, *needExtraInfo)
+	minorBlock, extra, err := p.b.GetMinorBlockByHash(ctx, blockHash, branch, *needExtraInfo)
 	if err != nil {
 		return nil, err
 	}
 	if minorBlock == nil {
 		return nil, errors.New("minor block is nil")
 	}
-	return encoder.MinorBlockEncoder(minorBlock, *includeTxs, extra)
+	return p.encodeMinorBlock(ctx, minorBlock, *includeTxs, extra, page)
 
 }
 
-func (p *PublicBlockChainAPI) GetMinorBlockByHeight(fullShardKey hexutil.Uint, heightInput *hexutil.Uint64, includeTxs *bool, needExtraInfo *bool) (map[string]interface{}, error) {
-	height, err := transHexutilUint64ToUint64(heightInput)
+func (p *PublicBlockChainAPI) GetMinorBlockByHeight(ctx context.Context, fullShardKey hexutil.Uint, heightInput *rpc.BlockNumber, includeTxs *bool, needExtraInfo *bool,
+	page *BlockPageArgs) (map[string]interface{}, error) {
+	fullShardId, err := getFullShardId(&fullShardKey)
+	if err != nil {
+		return nil, err
+	}
+	height, err := decodeBlockNumberToUint64(p.b, fullShardId, heightInput)
 	if err != nil {
 		return nil, err
 	}
@@ -352,22 +644,82 @@ func (p *PublicBlockChainAPI) GetMinorBlockByHeight(fullShardKey hexutil.Uint, h
 		temp := false
 		includeTxs = &temp
 	}
+	minorBlock, extraData, err := p.b.GetMinorBlockByHeight(ctx, height, account.Branch{Value: fullShardId}, *needExtraInfo)
+	if err != nil {
+		return nil, err
+	}
+	if minorBlock == nil {
+		return nil, errors.New("minor block is nil")
+	}
+	return p.encodeMinorBlock(ctx, minorBlock, *includeTxs, extraData, page)
+}
+
+const (
+	defaultRawBlocks = 100
+	maxRawBlocks     = 1000
+	// maxRawBlocksSize bounds the bytes of the blocks, receipts and txs
+	// returned at once, beyond the first block.
+	maxRawBlocksSize = 16 * 1024 * 1024
+)
 
+// GetRawBlocks returns the canonical minor blocks of the shard from the height
+// up as stored, serialized, with the RLP of their receipts and of their txs if
+// requested, for the indexers to ingest a shard in bulk. The blocks are
+// streamed from a slave until the limit or 16MB, and nextHeight is where the
+// next call starts, null once the tip is reached.
+func (p *PublicBlockChainAPI) GetRawBlocks(ctx context.Context, fullShardKey hexutil.Uint, from hexutil.Uint64,
+	args *RawBlocksArgs) (map[string]interface{}, error) {
 	fullShardId, err := getFullShardId(&fullShardKey)
 	if err != nil {
 		return nil, err
 	}
-	minorBlock, extraData, err := p.b.GetMinorBlockByHeight(height, account.Branch{Value: fullShardId}, *needExtraInfo)
+	if args == nil {
+		args = new(RawBlocksArgs)
+	}
+	limit := uint64(args.Limit)
+	if limit == 0 {
+		limit = defaultRawBlocks
+	}
+	if limit > maxRawBlocks {
+		return nil, fmt.Errorf("limit must be at most %d", maxRawBlocks)
+	}
+	var (
+		blocks = make([]map[string]interface{}, 0)
+		next   interface{}
+		size   int
+		encErr error
+	)
+	err = p.b.ExportBlocks(ctx, account.Branch{Value: fullShardId}, uint64(from), limit, args.IncludeReceipts,
+		func(block *qrpc.ExportedBlock) bool {
+			var fields map[string]interface{}
+			if fields, encErr = encoder.ExportedBlockEncoder(block, args.IncludeReceipts, args.IncludeTransactions); encErr != nil {
+				return false
+			}
+			blocks = append(blocks, fields)
+			size += len(block.Block) + len(block.Receipts)
+			if args.IncludeTransactions {
+				size += len(block.Block)
+			}
+			if uint64(len(blocks)) == limit || size >= maxRawBlocksSize {
+				next = hexutil.Uint64(block.Number + 1)
+				return false
+			}
+			return true
+		})
+	if err == nil {
+		err = encErr
+	}
 	if err != nil {
 		return nil, err
 	}
-	if minorBlock == nil {
-		return nil, errors.New("minor block is nil")
-	}
-	return encoder.MinorBlockEncoder(minorBlock, *includeTxs, extraData)
+	return map[string]interface{}{
+		"fullShardId": hexutil.Uint(fullShardId),
+		"blocks":      blocks,
+		"nextHeight":  next,
+	}, nil
 }
 
-func (p *PublicBlockChainAPI) GetTransactionById(txID hexutil.Bytes) (map[string]interface{}, error) {
+func (p *PublicBlockChainAPI) GetTransactionById(ctx context.Context, txID hexutil.Bytes) (map[string]interface{}, error) {
 	txHash, fullShardKey, err := encoder.IDDecoder(txID)
 	if err != nil {
 		return nil, err
@@ -377,7 +729,7 @@ func (p *PublicBlockChainAPI) GetTransactionById(txID hexutil.Bytes) (map[string
 		return nil, err
 	}
 	branch := account.Branch{Value: fullShardIDByConfig}
-	minorBlock, index, err := p.b.GetTransactionByHash(txHash, branch)
+	minorBlock, index, err := p.b.GetTransactionByHash(ctx, txHash, branch)
 	if err != nil {
 		return nil, err
 	}
@@ -387,50 +739,78 @@ func (p *PublicBlockChainAPI) GetTransactionById(txID hexutil.Bytes) (map[string
 	return encoder.TxEncoder(minorBlock, int(index))
 }
 
-func (p *PublicBlockChainAPI) Call(data CallArgs, blockNr *rpc.BlockNumber) (hexutil.Bytes, error) {
+// Call executes the call on the state of the block, with the state of the
+// accounts in overrides replaced first.
+func (p *PublicBlockChainAPI) Call(ctx context.Context, data CallArgs, blockNr *rpc.BlockNumber, overrides *StateOverride) (hexutil.Bytes, error) {
+	var stateOverride StateOverride
+	if overrides != nil {
+		stateOverride = *overrides
+	}
 	if blockNr == nil {
-		return p.CommonAPI.callOrEstimateGas(&data, nil, true)
+		return p.CommonAPI.callOrEstimateGas(ctx, &data, nil, stateOverride, true)
 	}
-	blockNumber, err := decodeBlockNumberToUint64(p.b, blockNr)
+	if *blockNr == rpc.PendingBlockNumber {
+		return p.CommonAPI.callPending(ctx, &data, stateOverride)
+	}
+	if data.To == nil {
+		return nil, errors.New("missing to")
+	}
+	blockNumber, err := decodeBlockNumberOfAddress(p.b, data.To, blockNr)
 	if err != nil {
 		return nil, err
 	}
-	return p.CommonAPI.callOrEstimateGas(&data, blockNumber, true)
+	return p.CommonAPI.callOrEstimateGas(ctx, &data, blockNumber, stateOverride, true)
+
+}
 
+// CreateAccessList executes the call at the block and returns the addresses and
+// the storage slots it accesses, with the gas used.
+func (p *PublicBlockChainAPI) CreateAccessList(ctx context.Context, data CallArgs, blockNr *rpc.BlockNumber) (map[string]interface{}, error) {
+	if blockNr == nil {
+		return p.CommonAPI.createAccessList(ctx, &data, nil)
+	}
+	if data.To == nil {
+		return nil, errors.New("missing to")
+	}
+	blockNumber, err := decodeBlockNumberOfAddress(p.b, data.To, blockNr)
+	if err != nil {
+		return nil, err
+	}
+	return p.CommonAPI.createAccessList(ctx, &data, blockNumber)
 }
 
-func (p *PublicBlockChainAPI) EstimateGas(data CallArgs) ([]byte, error) {
-	return p.CommonAPI.callOrEstimateGas(&data, nil, false)
+func (p *PublicBlockChainAPI) EstimateGas(ctx context.Context, data CallArgs) ([]byte, error) {
+	return p.CommonAPI.callOrEstimateGas(ctx, &data, nil, nil, false)
 }
 
-func (p *PublicBlockChainAPI) GetLogs(args *rpc.FilterQuery, fullShardKey hexutil.Uint) ([]map[string]interface{}, error) {
-	return p.CommonAPI.GetLogs(args, &fullShardKey)
+func (p *PublicBlockChainAPI) GetLogs(ctx context.Context, args *rpc.FilterQuery, fullShardKey hexutil.Uint) ([]map[string]interface{}, error) {
+	return p.CommonAPI.GetLogs(ctx, args, &fullShardKey)
 }
 
-func (p *PublicBlockChainAPI) GetStorageAt(address account.Address, key common.Hash, blockNr *rpc.BlockNumber) (hexutil.Bytes, error) {
-	blockNumber, err := decodeBlockNumberToUint64(p.b, blockNr)
+func (p *PublicBlockChainAPI) GetStorageAt(ctx context.Context, address account.Address, key common.Hash, blockNr *rpc.BlockNumber) (hexutil.Bytes, error) {
+	blockNumber, err := decodeBlockNumberOfAddress(p.b, &address, blockNr)
 	if err != nil {
 		return nil, err
 	}
-	hash, err := p.b.GetStorageAt(&address, key, blockNumber)
+	hash, err := p.b.GetStorageAt(ctx, &address, key, blockNumber)
 	return hash.Bytes(), err
 }
 
-func (p *PublicBlockChainAPI) GetCode(address account.Address, blockNr *rpc.BlockNumber) (hexutil.Bytes, error) {
-	blockNumber, err := decodeBlockNumberToUint64(p.b, blockNr)
+func (p *PublicBlockChainAPI) GetCode(ctx context.Context, address account.Address, blockNr *rpc.BlockNumber) (hexutil.Bytes, error) {
+	blockNumber, err := decodeBlockNumberOfAddress(p.b, &address, blockNr)
 	if err != nil {
 		return nil, err
 	}
-	return p.b.GetCode(&address, blockNumber)
+	return p.b.GetCode(ctx, &address, blockNumber)
 }
 
-func (p *PublicBlockChainAPI) GetTransactionsByAddress(address account.Address, start *hexutil.Bytes, limit *hexutil.Uint, transferTokenID *hexutil.Uint64) (map[string]interface{}, error) {
+func (p *PublicBlockChainAPI) GetTransactionsByAddress(ctx context.Context, address account.Address, start *hexutil.Bytes, limit *hexutil.Uint, transferTokenID *hexutil.Uint64) (map[string]interface{}, error) {
 	limitValue := uint32(0)
 	if limit != nil {
 		limitValue = uint32(*limit)
 	}
-	if limitValue > 20 {
-		limitValue = 20
+	if maxLimit := atomic.LoadUint32(&clusterCfg.RPCTxListLimit); limitValue > maxLimit {
+		limitValue = maxLimit
 	}
 	startValue := make([]byte, 0)
 	if start != nil {
@@ -445,7 +825,7 @@ func (p *PublicBlockChainAPI) GetTransactionsByAddress(address account.Address,
 		transferTokenIDValue = &t
 	}
 
-	txs, next, err := p.b.GetTransactionsByAddress(&address, startValue, limitValue, transferTokenIDValue)
+	txs, next, err := p.b.GetTransactionsByAddress(ctx, &address, startValue, limitValue, transferTokenIDValue)
 	if err != nil {
 		return nil, err
 	}
@@ -483,7 +863,7 @@ func txDetailEncode(tx *qrpc.TransactionDetail) (map[string]interface{}, error)
 	}, nil
 }
 
-func (p *PublicBlockChainAPI) GetAllTransaction(fullShardKey hexutil.Uint, start *hexutil.Bytes, limit *hexutil.Uint) (map[string]interface{}, error) {
+func (p *PublicBlockChainAPI) GetAllTransaction(ctx context.Context, fullShardKey hexutil.Uint, start *hexutil.Bytes, limit *hexutil.Uint) (map[string]interface{}, error) {
 	var (
 		err        error
 		startValue = make([]byte, 0)
@@ -500,8 +880,8 @@ func (p *PublicBlockChainAPI) GetAllTransaction(fullShardKey hexutil.Uint, start
 		limitValue = uint32(*limit)
 	}
 
-	if limitValue > 20 {
-		limitValue = 20
+	if maxLimit := atomic.LoadUint32(&clusterCfg.RPCTxListLimit); limitValue > maxLimit {
+		limitValue = maxLimit
 	}
 
 	fullShardID, err := getFullShardId(&fullShardKey)
@@ -509,7 +889,7 @@ func (p *PublicBlockChainAPI) GetAllTransaction(fullShardKey hexutil.Uint, start
 		return nil, err
 	}
 	branch := account.Branch{Value: fullShardID}
-	txs, next, err := p.b.GetAllTx(branch, startValue, limitValue)
+	txs, next, err := p.b.GetAllTx(ctx, branch, startValue, limitValue)
 	if err != nil {
 		return nil, err
 	}
@@ -532,7 +912,7 @@ func makeGetTransactionRes(txs []*qrpc.TransactionDetail, next []byte) (map[stri
 	}, nil
 }
 
-func (p *PublicBlockChainAPI) GasPrice(fullShardKey hexutil.Uint, tokenID *hexutil.Uint64) (hexutil.Uint64, error) {
+func (p *PublicBlockChainAPI) GasPrice(ctx context.Context, fullShardKey hexutil.Uint, tokenID *hexutil.Uint64) (hexutil.Uint64, error) {
 	fullShardId, err := getFullShardId(&fullShardKey)
 	if err != nil {
 		return hexutil.Uint64(0), err
@@ -541,11 +921,11 @@ func (p *PublicBlockChainAPI) GasPrice(fullShardKey hexutil.Uint, tokenID *hexut
 	if tokenID != nil {
 		tokenIDValue = uint64(*tokenID)
 	}
-	data, err := p.b.GasPrice(account.Branch{Value: fullShardId}, tokenIDValue)
+	data, err := p.b.GasPrice(ctx, account.Branch{Value: fullShardId}, tokenIDValue)
 	return hexutil.Uint64(data), err
 }
 
-func (p *PublicBlockChainAPI) SubmitWork(fullShardKey *hexutil.Uint, headHash common.Hash, nonce hexutil.Uint64, mixHash common.Hash, signature *hexutil.Bytes) (bool, error) {
+func (p *PublicBlockChainAPI) SubmitWork(ctx context.Context, fullShardKey *hexutil.Uint, headHash common.Hash, nonce hexutil.Uint64, mixHash common.Hash, signature *hexutil.Bytes) (bool, error) {
 	var fullShardId *uint32
 	if fullShardKey != nil {
 		id, err := getFullShardId(fullShardKey)
@@ -565,15 +945,15 @@ func (p *PublicBlockChainAPI) SubmitWork(fullShardKey *hexutil.Uint, headHash co
 		copy(sig[:], *signature)
 	}
 
-	submit, err := p.b.SubmitWork(fullShardId, headHash, uint64(nonce), mixHash, sig)
+	submit, err := p.b.SubmitWork(ctx, fullShardId, headHash, uint64(nonce), mixHash, sig)
 	if err != nil {
-		log.Error("Submit remote minered block", "err", err)
+		rpc.LoggerFromContext(ctx).Error("Submit remote minered block", "err", err)
 		return false, err
 	}
 	return submit, nil
 }
 
-func (p *PublicBlockChainAPI) GetWork(fullShardKey *hexutil.Uint, coinbaseAddress *common.Address) ([]common.Hash, error) {
+func (p *PublicBlockChainAPI) GetWork(ctx context.Context, fullShardKey *hexutil.Uint, coinbaseAddress *common.Address) ([]common.Hash, error) {
 	var fullShardId *uint32
 	if fullShardKey != nil {
 		id, err := getFullShardId(fullShardKey)
@@ -583,7 +963,7 @@ func (p *PublicBlockChainAPI) GetWork(fullShardKey *hexutil.Uint, coinbaseAddres
 		fullShardId = &id
 	}
 
-	work, err := p.b.GetWork(fullShardId, coinbaseAddress)
+	work, err := p.b.GetWork(ctx, fullShardId, coinbaseAddress)
 	if err != nil {
 		return nil, err
 	}
@@ -607,7 +987,7 @@ func (p *PublicBlockChainAPI) GetRootHashConfirmingMinorBlockById(mBlockID hexut
 	return &hash
 }
 
-func (p *PublicBlockChainAPI) GetTransactionConfirmedByNumberRootBlocks(txID hexutil.Bytes) (hexutil.Uint, error) {
+func (p *PublicBlockChainAPI) GetTransactionConfirmedByNumberRootBlocks(ctx context.Context, txID hexutil.Bytes) (hexutil.Uint, error) {
 	txHash, fullShardKey, err := encoder.IDDecoder(txID)
 	if err != nil {
 		return hexutil.Uint(0), err
@@ -617,7 +997,7 @@ func (p *PublicBlockChainAPI) GetTransactionConfirmedByNumberRootBlocks(txID hex
 		return hexutil.Uint(0), err
 	}
 
-	mBlock, _, err := p.b.GetTransactionByHash(txHash, account.Branch{Value: fullShardID})
+	mBlock, _, err := p.b.GetTransactionByHash(ctx, txHash, account.Branch{Value: fullShardID})
 	if err != nil {
 		return hexutil.Uint(0), err
 	}
@@ -654,10 +1034,184 @@ func (p *PublicBlockChainAPI) GetTransactionConfirmedByNumberRootBlocks(txID hex
 
 }
 
+// GetMinorBlockConfirmation returns the canonical root block confirming the
+// minor block, null if none does yet, the number of root blocks above it and
+// whether they are at least the CONFIRMATION_DEPTH of the master.
+func (p *PublicBlockChainAPI) GetMinorBlockConfirmation(blockID hexutil.Bytes) (map[string]interface{}, error) {
+	mHash, fullShardKey, err := encoder.IDDecoder(blockID)
+	if err != nil {
+		return nil, err
+	}
+	fullShardID, err := clusterCfg.Quarkchain.GetFullShardIdByFullShardKey(fullShardKey)
+	if err != nil {
+		return nil, err
+	}
+	depth := clusterCfg.Master.ConfirmationDepth
+	rBlock, confirmations := p.b.GetMinorBlockConfirmation(mHash, fullShardID)
+	if rBlock == nil {
+		return map[string]interface{}{
+			"rootBlock":         nil,
+			"confirmations":     hexutil.Uint64(0),
+			"finalized":         false,
+			"confirmationDepth": hexutil.Uint64(depth),
+		}, nil
+	}
+	return map[string]interface{}{
+		"rootBlock": map[string]interface{}{
+			"hash":   rBlock.Hash(),
+			"height": hexutil.Uint64(rBlock.NumberU64()),
+		},
+		"confirmations":     hexutil.Uint64(confirmations),
+		"finalized":         confirmations >= depth,
+		"confirmationDepth": hexutil.Uint64(depth),
+	}, nil
+}
+
+// SimulateBundle applies the signed raw txs, all from the same shard, in order
+// on the state of the minor block at the height, the latest if nil, as the txs
+// of the next block, without broadcasting them. It returns the result of each
+// tx with its logs and the cumulative gas used, or the error of the first tx
+// which can't be applied, since the bundle is applied as a whole.
+func (p *PublicBlockChainAPI) SimulateBundle(ctx context.Context, rawTxs []hexutil.Bytes, blockNr *rpc.BlockNumber) (map[string]interface{}, error) {
+	if len(rawTxs) > core.MaxBundleSize {
+		return nil, fmt.Errorf("bundle of %d txs exceeds the limit of %d", len(rawTxs), core.MaxBundleSize)
+	}
+	var (
+		txs         = make([]*types.Transaction, 0, len(rawTxs))
+		fullShardID uint32
+	)
+	for i, encodedTx := range rawTxs {
+		evmTx := new(types.EvmTransaction)
+		if err := rlp.DecodeBytes(encodedTx, evmTx); err != nil {
+			return nil, fmt.Errorf("tx %d: %v", i, err)
+		}
+		id, err := clusterCfg.Quarkchain.GetFullShardIdByFullShardKey(evmTx.FromFullShardKey())
+		if err != nil {
+			return nil, fmt.Errorf("tx %d: %v", i, err)
+		}
+		if i == 0 {
+			fullShardID = id
+		} else if id != fullShardID {
+			return nil, errors.New("the txs of the bundle should be from the same shard")
+		}
+		txs = append(txs, &types.Transaction{EvmTx: evmTx, TxType: types.EvmTx})
+	}
+	height, err := decodeBlockNumberToUint64(p.b, fullShardID, blockNr)
+	if err != nil {
+		return nil, err
+	}
+	results, err := p.b.SimulateBundle(ctx, txs, account.Branch{Value: fullShardID}, height)
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]map[string]interface{}, 0, len(results))
+	for i, result := range results {
+		fields = append(fields, encoder.BundleTxResultEncoder(txs[i], result))
+	}
+	var gasUsed uint64
+	if len(results) > 0 {
+		gasUsed = results[len(results)-1].Receipt.CumulativeGasUsed
+	}
+	return map[string]interface{}{
+		"results": fields,
+		"gasUsed": hexutil.Uint64(gasUsed),
+	}, nil
+}
+
 func (p *PublicBlockChainAPI) NetVersion() hexutil.Uint {
 	return hexutil.Uint(clusterCfg.Quarkchain.NetworkID)
 }
 
+// EcRecover returns the address whose key signed the message by personal_sign,
+// on the full shard key if given, or the default full shard key of the
+// recipient otherwise, since the full shard key is not signed.
+func (p *PublicBlockChainAPI) EcRecover(data hexutil.Bytes, sig hexutil.Bytes, fullShardKey *hexutil.Uint) (account.Address, error) {
+	return ecRecover(account.TextHash(data), sig, fullShardKey)
+}
+
+// EcRecoverTypedData returns the address whose key signed the typed data by
+// personal_signTypedData, with the full shard key as in EcRecover.
+func (p *PublicBlockChainAPI) EcRecoverTypedData(typedData account.TypedData, sig hexutil.Bytes, fullShardKey *hexutil.Uint) (account.Address, error) {
+	hash, err := typedData.Hash()
+	if err != nil {
+		return account.Address{}, err
+	}
+	return ecRecover(hash, sig, fullShardKey)
+}
+
+func ecRecover(hash []byte, sig hexutil.Bytes, fullShardKey *hexutil.Uint) (account.Address, error) {
+	if len(sig) != 65 {
+		return account.Address{}, errors.New("signature must be 65 bytes long")
+	}
+	if sig[64] != 27 && sig[64] != 28 {
+		return account.Address{}, errors.New("invalid signature recovery id, expected 27 or 28")
+	}
+	recoverable := make([]byte, 65)
+	copy(recoverable, sig)
+	recoverable[64] -= 27
+	pubKey, err := crypto.SigToPub(hash, recoverable)
+	if err != nil {
+		return account.Address{}, err
+	}
+	recipient := crypto.PubkeyToAddress(*pubKey)
+	if fullShardKey != nil {
+		return account.NewAddress(recipient, uint32(*fullShardKey)), nil
+	}
+	identity := account.NewIdentity(recipient, account.Key{})
+	defaultFullShardKey, err := identity.GetDefaultFullShardKey()
+	if err != nil {
+		return account.Address{}, err
+	}
+	return account.NewAddress(recipient, defaultFullShardKey), nil
+}
+
+// ToQKCAddress returns the QuarkChain address of the 20-byte Ethereum address
+// on the full shard key if given, or the default full shard key of the
+// recipient otherwise.
+func (p *PublicBlockChainAPI) ToQKCAddress(ethAddress common.Address, fullShardKey *hexutil.Uint) (account.Address, error) {
+	if fullShardKey != nil {
+		return account.NewAddress(ethAddress, uint32(*fullShardKey)), nil
+	}
+	identity := account.NewIdentity(ethAddress, account.Key{})
+	defaultFullShardKey, err := identity.GetDefaultFullShardKey()
+	if err != nil {
+		return account.Address{}, err
+	}
+	return account.NewAddress(ethAddress, defaultFullShardKey), nil
+}
+
+// ToEthAddress returns the 20-byte Ethereum address of the QuarkChain address,
+// which is its recipient.
+func (p *PublicBlockChainAPI) ToEthAddress(address account.Address) common.Address {
+	return address.Recipient
+}
+
+// GetAddressShard returns the chain and the shard the full shard key of the
+// address maps to under the shard sizes of the network.
+func (p *PublicBlockChainAPI) GetAddressShard(address account.Address) (map[string]interface{}, error) {
+	fullShardID, err := clusterCfg.Quarkchain.GetFullShardIdByFullShardKey(address.FullShardKey)
+	if err != nil {
+		return nil, err
+	}
+	branch := account.NewBranch(fullShardID)
+	return map[string]interface{}{
+		"fullShardKey": hexutil.Uint(address.FullShardKey),
+		"fullShardId":  hexutil.Uint(fullShardID),
+		"chainId":      hexutil.Uint(branch.GetChainID()),
+		"shardSize":    hexutil.Uint(branch.GetShardSize()),
+		"shardId":      hexutil.Uint(branch.GetShardID()),
+	}, nil
+}
+
+// GetAddressInShard returns the address of the same recipient whose full shard
+// key maps to the full shard id, e.g. to receive in a given shard.
+func (p *PublicBlockChainAPI) GetAddressInShard(address account.Address, fullShardID hexutil.Uint) (account.Address, error) {
+	if clusterCfg.Quarkchain.GetShardConfigByFullShardID(uint32(fullShardID)) == nil {
+		return account.Address{}, fmt.Errorf("no such full shard id %#x", uint32(fullShardID))
+	}
+	return address.AddressInBranch(account.NewBranch(uint32(fullShardID))), nil
+}
+
 type PrivateBlockChainAPI struct {
 	b Backend
 }
@@ -709,37 +1263,793 @@ func (p *PrivateBlockChainAPI) GetBlockCount() (map[string]interface{}, error) {
 	}, nil
 }
 
-//TODO txGenerate implement
-func (p *PrivateBlockChainAPI) CreateTransactions(args CreateTxArgs) error {
-	config := clusterCfg.Quarkchain
-	if err := args.setDefaults(config); err != nil {
-		return err
+// GetStaleBlocks returns the number of blocks which lost fork choice and the
+// latest ones, for root chain if fullShardKey is nil.
+func (p *PrivateBlockChainAPI) GetStaleBlocks(ctx context.Context, fullShardKey *hexutil.Uint, limit *hexutil.Uint) (map[string]interface{}, error) {
+	var fullShardId *uint32
+	if fullShardKey != nil {
+		id, err := getFullShardId(fullShardKey)
+		if err != nil {
+			return nil, err
+		}
+		fullShardId = &id
+	}
+	limitValue := uint32(20)
+	if limit != nil {
+		limitValue = uint32(*limit)
 	}
-	tx := args.toTx(config)
-	return p.b.CreateTransactions(*args.NumTxPreShard, *args.XShardPrecent, tx)
-}
-
-func (p *PrivateBlockChainAPI) SetTargetBlockTime(rootBlockTime *uint32, minorBlockTime *uint32) error {
-	return p.b.SetTargetBlockTime(rootBlockTime, minorBlockTime)
-}
-
-func (p *PrivateBlockChainAPI) SetMining(flag bool) {
-	p.b.SetMining(flag)
-}
-
-//TODO ?? necessary?
-func (p *PrivateBlockChainAPI) GetJrpcCalls() { panic("not implemented") }
 
-func (p *PrivateBlockChainAPI) GetKadRoutingTableSize() (hexutil.Uint, error) {
-	urls, err := p.b.GetKadRoutingTable()
+	total, blocks, err := p.b.GetStaleBlocks(ctx, fullShardId, limitValue)
 	if err != nil {
-		return hexutil.Uint(0), err
+		return nil, err
 	}
-	return hexutil.Uint(len(urls)), nil
-}
-
-func (p *PrivateBlockChainAPI) GetKadRoutingTable() ([]string, error) {
-	return p.b.GetKadRoutingTable()
+	blockList := make([]map[string]interface{}, 0, len(blocks))
+	for _, block := range blocks {
+		fields, err := encoder.StaleBlockEncoder(block)
+		if err != nil {
+			return nil, err
+		}
+		blockList = append(blockList, fields)
+	}
+	return map[string]interface{}{
+		"total":       hexutil.Uint64(total),
+		"staleBlocks": blockList,
+	}, nil
+}
+
+// GetBlockProfiles returns the time the latest minor blocks of the shard
+// produced by the cluster spent in tx selection, execution, state root
+// hashing, sealing and commit, in microseconds, newest first.
+func (p *PrivateBlockChainAPI) GetBlockProfiles(ctx context.Context, fullShardKey hexutil.Uint, limit *hexutil.Uint) ([]map[string]interface{}, error) {
+	fullShardId, err := getFullShardId(&fullShardKey)
+	if err != nil {
+		return nil, err
+	}
+	limitValue := uint32(20)
+	if limit != nil {
+		limitValue = uint32(*limit)
+	}
+	profiles, err := p.b.GetBlockProfiles(ctx, account.Branch{Value: fullShardId}, limitValue)
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]map[string]interface{}, 0, len(profiles))
+	for _, profile := range profiles {
+		fields = append(fields, encoder.BlockProfileEncoder(profile))
+	}
+	return fields, nil
+}
+
+// GetUnreceivedXShardDeposits returns the cross-shard deposits confirmed by
+// root chain which are not applied by the shard yet.
+func (p *PrivateBlockChainAPI) GetUnreceivedXShardDeposits(ctx context.Context, fullShardKey hexutil.Uint, limit *hexutil.Uint) ([]map[string]interface{}, error) {
+	fullShardId, err := getFullShardId(&fullShardKey)
+	if err != nil {
+		return nil, err
+	}
+	limitValue := uint32(20)
+	if limit != nil {
+		limitValue = uint32(*limit)
+	}
+
+	deposits, err := p.b.GetUnreceivedXShardDeposits(ctx, account.Branch{Value: fullShardId}, limitValue)
+	if err != nil {
+		return nil, err
+	}
+	depositList := make([]map[string]interface{}, 0, len(deposits))
+	for _, deposit := range deposits {
+		depositList = append(depositList, encoder.UnreceivedXShardDepositEncoder(deposit))
+	}
+	return depositList, nil
+}
+
+// GetXShardQueues returns for each shard the count, the total value and the
+// oldest root block of the cross-shard deposits confirmed by root chain which
+// are not applied yet, with the count of the xshard tx lists missing, to find
+// the shards stuck receiving deposits.
+func (p *PrivateBlockChainAPI) GetXShardQueues(ctx context.Context) ([]map[string]interface{}, error) {
+	queues, err := p.b.GetXShardQueues(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]map[string]interface{}, 0, len(queues))
+	for _, queue := range queues {
+		fields = append(fields, encoder.XShardQueueEncoder(queue))
+	}
+	return fields, nil
+}
+
+//TODO txGenerate implement
+func (p *PrivateBlockChainAPI) CreateTransactions(ctx context.Context, args CreateTxArgs) error {
+	config := clusterCfg.Quarkchain
+	if args.NumTxPreShard == nil {
+		return errors.New("must set numTxPerShard")
+	}
+	if err := args.setDefaults(config); err != nil {
+		return err
+	}
+	req := args.toGenTxRequest(config)
+	req.TPS, req.Duration = 0, 0
+	return p.b.CreateTransactions(ctx, req)
+}
+
+// StartBenchmark makes the shards send the txs like the sample tx of the args
+// at the TPS of the cluster for the duration of the args, to be reported by
+// GetBenchmarkReport.
+func (p *PrivateBlockChainAPI) StartBenchmark(ctx context.Context, args CreateTxArgs) error {
+	config := clusterCfg.Quarkchain
+	if args.TPS == nil || *args.TPS == 0 {
+		return errors.New("must set tps")
+	}
+	if args.Duration == nil {
+		return errors.New("must set duration")
+	}
+	if err := args.setDefaults(config); err != nil {
+		return err
+	}
+	return p.b.CreateTransactions(ctx, args.toGenTxRequest(config))
+}
+
+// GetBenchmarkReport returns the TPS achieved by the last benchmark of each
+// shard and of the cluster, with the latencies from the txs sent to them
+// included in the shard chains, in milliseconds.
+func (p *PrivateBlockChainAPI) GetBenchmarkReport(ctx context.Context) (map[string]interface{}, error) {
+	reports, err := p.b.GetTxBenchmarkReports(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		shards    = make([]map[string]interface{}, 0, len(reports))
+		running   bool
+		targetTPS uint32
+		tps       float64
+		sent      uint64
+		confirmed uint64
+	)
+	for _, report := range reports {
+		fields := encoder.TxBenchmarkReportEncoder(report)
+		shards = append(shards, fields)
+		running = running || report.Running
+		targetTPS += report.TPS
+		tps += fields["tps"].(float64)
+		sent += report.Sent
+		confirmed += report.Confirmed
+	}
+	return map[string]interface{}{
+		"running":   running,
+		"targetTps": targetTPS,
+		"tps":       tps,
+		"sent":      sent,
+		"confirmed": confirmed,
+		"shards":    shards,
+	}, nil
+}
+
+func (p *PrivateBlockChainAPI) SetTargetBlockTime(rootBlockTime *uint32, minorBlockTime *uint32) error {
+	err := p.b.SetTargetBlockTime(rootBlockTime, minorBlockTime)
+	p.b.Audit("qkc_setTargetBlockTime", map[string]interface{}{"rootBlockTime": rootBlockTime, "minorBlockTime": minorBlockTime}, err)
+	return err
+}
+
+func (p *PrivateBlockChainAPI) SetMining(ctx context.Context, flag bool) {
+	p.b.SetMining(ctx, flag)
+	p.b.Audit("qkc_setMining", map[string]interface{}{"mining": flag}, nil)
+}
+
+//TODO ?? necessary?
+// ReloadConfig reloads the cluster config of the master and the slaves, and
+// returns the settings changed on the master without a restart.
+func (p *PrivateBlockChainAPI) ReloadConfig(ctx context.Context) ([]config.ConfigChange, error) {
+	return p.b.ReloadConfig(ctx)
+}
+
+// ReserveNonces reserves count consecutive nonces of the account in its shard
+// for the txs of a sender signing them concurrently, or fewer to reuse first
+// the nonces of the txs dropped and of the reservations released or expired.
+// The txs must be sent through the master for their nonces to be followed.
+func (p *PrivateBlockChainAPI) ReserveNonces(ctx context.Context, address account.Address, count hexutil.Uint64) (map[string]interface{}, error) {
+	reservation, err := p.b.ReserveNonces(ctx, &address, uint64(count))
+	if err != nil {
+		return nil, err
+	}
+	fullShardId, err := clusterCfg.Quarkchain.GetFullShardIdByFullShardKey(address.FullShardKey)
+	if err != nil {
+		return nil, err
+	}
+	return encoder.NonceReservationEncoder(fullShardId, reservation), nil
+}
+
+// ReleaseNonces frees the nonces of the account reserved and not to be used,
+// and returns the number freed.
+func (p *PrivateBlockChainAPI) ReleaseNonces(address account.Address, from, count hexutil.Uint64) (hexutil.Uint64, error) {
+	released, err := p.b.ReleaseNonces(&address, uint64(from), uint64(count))
+	return hexutil.Uint64(released), err
+}
+
+// GetNonceState returns the nonces of the account in its shard reserved, in
+// flight and freed.
+func (p *PrivateBlockChainAPI) GetNonceState(address account.Address) (map[string]interface{}, error) {
+	state, err := p.b.GetNonceState(&address)
+	if err != nil {
+		return nil, err
+	}
+	fullShardId, err := clusterCfg.Quarkchain.GetFullShardIdByFullShardKey(address.FullShardKey)
+	if err != nil {
+		return nil, err
+	}
+	return encoder.NonceStateEncoder(fullShardId, state), nil
+}
+
+func (p *PrivateBlockChainAPI) GetJrpcCalls() { panic("not implemented") }
+
+func (p *PrivateBlockChainAPI) GetKadRoutingTableSize() (hexutil.Uint, error) {
+	urls, err := p.b.GetKadRoutingTable()
+	if err != nil {
+		return hexutil.Uint(0), err
+	}
+	return hexutil.Uint(len(urls)), nil
+}
+
+func (p *PrivateBlockChainAPI) GetKadRoutingTable() ([]string, error) {
+	return p.b.GetKadRoutingTable()
+}
+
+// PrivateAdminAPI manages the peers of the p2p network of the cluster, and
+// profiles and tunes the logs of its processes.
+type PrivateAdminAPI struct {
+	b Backend
+}
+
+func NewPrivateAdminAPI(b Backend) *PrivateAdminAPI {
+	return &PrivateAdminAPI{b}
+}
+
+func (a *PrivateAdminAPI) server() (*p2p.Server, error) {
+	if srv := a.b.P2PServer(); srv != nil {
+		return srv, nil
+	}
+	return nil, errors.New("p2p network is not running")
+}
+
+// parseNode parses an enode URL, or a node ID for the methods which only need
+// to identify the node.
+func parseNode(node string, needURL bool) (*enode.Node, error) {
+	if strings.HasPrefix(node, "enode://") {
+		n, err := enode.ParseV4(node)
+		if err != nil {
+			return nil, fmt.Errorf("invalid enode: %v", err)
+		}
+		return n, nil
+	}
+	if needURL {
+		return nil, errors.New("enode URL required")
+	}
+	b, err := hexutil.Decode(node)
+	if err != nil {
+		b, err = hexutil.Decode("0x" + node)
+	}
+	var id enode.ID
+	if err != nil || len(b) != len(id) {
+		return nil, fmt.Errorf("invalid node ID %q", node)
+	}
+	copy(id[:], b)
+	return enode.SignNull(new(enr.Record), id), nil
+}
+
+// audited records the call of the admin method in the audit log of the
+// cluster, and returns its result.
+func (a *PrivateAdminAPI) audited(method string, detail map[string]interface{}, ok bool, err error) (bool, error) {
+	a.b.Audit("admin_"+method, detail, err)
+	return ok, err
+}
+
+func (a *PrivateAdminAPI) nodeOp(method, node string, needURL bool, op func(*p2p.Server, *enode.Node)) (bool, error) {
+	detail := map[string]interface{}{"node": node}
+	srv, err := a.server()
+	if err != nil {
+		return a.audited(method, detail, false, err)
+	}
+	n, err := parseNode(node, needURL)
+	if err != nil {
+		return a.audited(method, detail, false, err)
+	}
+	op(srv, n)
+	return a.audited(method, detail, true, nil)
+}
+
+// AddPeer connects to the node of the enode URL and keeps it connected.
+func (a *PrivateAdminAPI) AddPeer(url string) (bool, error) {
+	return a.nodeOp("addPeer", url, true, (*p2p.Server).AddPeer)
+}
+
+// RemovePeer disconnects the node of the enode URL or ID, which is no longer
+// kept connected.
+func (a *PrivateAdminAPI) RemovePeer(node string) (bool, error) {
+	return a.nodeOp("removePeer", node, false, (*p2p.Server).RemovePeer)
+}
+
+// AddTrustedPeer allows the node of the enode URL or ID to connect above the
+// max peers.
+func (a *PrivateAdminAPI) AddTrustedPeer(node string) (bool, error) {
+	return a.nodeOp("addTrustedPeer", node, false, (*p2p.Server).AddTrustedPeer)
+}
+
+// RemoveTrustedPeer removes the node of the enode URL or ID from the trusted
+// peers.
+func (a *PrivateAdminAPI) RemoveTrustedPeer(node string) (bool, error) {
+	return a.nodeOp("removeTrustedPeer", node, false, (*p2p.Server).RemoveTrustedPeer)
+}
+
+// BanPeer disconnects the node of the enode URL or ID and refuses its
+// connections until it is unbanned, also after a restart.
+func (a *PrivateAdminAPI) BanPeer(node string) (bool, error) {
+	return a.nodeOp("banPeer", node, false, (*p2p.Server).BanPeer)
+}
+
+// UnbanPeer allows the node of the enode URL or ID to connect again.
+func (a *PrivateAdminAPI) UnbanPeer(node string) (bool, error) {
+	return a.nodeOp("unbanPeer", node, false, (*p2p.Server).UnbanPeer)
+}
+
+// DisconnectPeer disconnects the connected node of the enode URL or ID, which
+// may connect again. It returns false if the node is not connected.
+func (a *PrivateAdminAPI) DisconnectPeer(node string) (bool, error) {
+	detail := map[string]interface{}{"node": node}
+	srv, err := a.server()
+	if err != nil {
+		return a.audited("disconnectPeer", detail, false, err)
+	}
+	n, err := parseNode(node, false)
+	if err != nil {
+		return a.audited("disconnectPeer", detail, false, err)
+	}
+	for _, peer := range srv.Peers() {
+		if peer.ID() == n.ID() {
+			peer.Disconnect(p2p.DiscRequested)
+			return a.audited("disconnectPeer", detail, true, nil)
+		}
+	}
+	return a.audited("disconnectPeer", detail, false, nil)
+}
+
+// Peers returns the connected peers, with their capabilities, shards, root
+// head and traffic in the quarkchain protocol metadata.
+func (a *PrivateAdminAPI) Peers() ([]*p2p.PeerInfo, error) {
+	srv, err := a.server()
+	if err != nil {
+		return nil, err
+	}
+	return srv.PeersInfo(), nil
+}
+
+// NodeInfo returns the p2p metadata of the cluster.
+func (a *PrivateAdminAPI) NodeInfo() (*p2p.NodeInfo, error) {
+	srv, err := a.server()
+	if err != nil {
+		return nil, err
+	}
+	return srv.NodeInfo(), nil
+}
+
+// profile runs the profiling action on the master, or on the slave of the ID
+// if given. The files are written by the profiled process, relative to its
+// working directory.
+func (a *PrivateAdminAPI) profile(ctx context.Context, slaveID *string, method, action, file string, rate int) (bool, error) {
+	id := ""
+	if slaveID != nil {
+		id = *slaveID
+	}
+	detail := map[string]interface{}{"slave": id, "file": file, "rate": rate}
+	if rate < 0 {
+		return a.audited(method, detail, false, errors.New("negative profiling rate"))
+	}
+	if err := a.b.Profile(ctx, id, action, file, rate); err != nil {
+		return a.audited(method, detail, false, err)
+	}
+	return a.audited(method, detail, true, nil)
+}
+
+// StartCPUProfile starts writing a CPU profile to the file.
+func (a *PrivateAdminAPI) StartCPUProfile(ctx context.Context, file string, slaveID *string) (bool, error) {
+	return a.profile(ctx, slaveID, "startCPUProfile", debug.ProfileStartCPU, file, 0)
+}
+
+// StopCPUProfile stops the CPU profile and closes its file.
+func (a *PrivateAdminAPI) StopCPUProfile(ctx context.Context, slaveID *string) (bool, error) {
+	return a.profile(ctx, slaveID, "stopCPUProfile", debug.ProfileStopCPU, "", 0)
+}
+
+// WriteHeapProfile writes a heap profile to the file.
+func (a *PrivateAdminAPI) WriteHeapProfile(ctx context.Context, file string, slaveID *string) (bool, error) {
+	return a.profile(ctx, slaveID, "writeHeapProfile", debug.ProfileWriteHeap, file, 0)
+}
+
+// SetBlockProfileRate samples one goroutine blocking event every rate
+// nanoseconds blocked for the block profile, 0 disables it.
+func (a *PrivateAdminAPI) SetBlockProfileRate(ctx context.Context, rate int, slaveID *string) (bool, error) {
+	return a.profile(ctx, slaveID, "setBlockProfileRate", debug.ProfileSetBlockRate, "", rate)
+}
+
+// SetMutexProfileFraction samples one in fraction mutex contention events
+// for the mutex profile, 0 disables it.
+func (a *PrivateAdminAPI) SetMutexProfileFraction(ctx context.Context, fraction int, slaveID *string) (bool, error) {
+	return a.profile(ctx, slaveID, "setMutexProfileFraction", debug.ProfileSetMutexFraction, "", fraction)
+}
+
+// SetLogLevel sets the verbosity of the logs of the master and the slaves, or
+// the log level of the module if given, one of rpc, sync, p2p, core and
+// miner. An empty level makes the module log at the verbosity again. A config
+// reload restores the levels of the config file.
+func (a *PrivateAdminAPI) SetLogLevel(ctx context.Context, level string, module *string) (bool, error) {
+	name := ""
+	if module != nil {
+		name = *module
+	}
+	detail := map[string]interface{}{"level": level, "module": name}
+	if name == "" && level == "" {
+		return a.audited("setLogLevel", detail, false, errors.New("missing log level"))
+	}
+	if err := a.b.SetLogLevel(ctx, name, level); err != nil {
+		return a.audited("setLogLevel", detail, false, err)
+	}
+	return a.audited("setLogLevel", detail, true, nil)
+}
+
+// SlowOps returns the last count cluster RPC ops between the master and the
+// slaves which took at least the SLOW_RPC_THRESHOLD, 100 by default, of the
+// process calling them if given, master or a slave ID, the oldest first.
+func (a *PrivateAdminAPI) SlowOps(ctx context.Context, count *int, process *string) ([]*qrpc.SlowOp, error) {
+	n, p := 100, ""
+	if count != nil {
+		n = *count
+	}
+	if process != nil {
+		p = *process
+	}
+	if n < 0 {
+		return nil, errors.New("negative count")
+	}
+	return a.b.SlowOps(ctx, p, n)
+}
+
+// AuditLog returns the last count entries of the audit log of the cluster,
+// 100 by default, of the type if given, one of admin, slave, reorg, config
+// and ban, the oldest first.
+func (a *PrivateAdminAPI) AuditLog(count *int, typ *string) ([]audit.Entry, error) {
+	n, t := 100, ""
+	if count != nil {
+		n = *count
+	}
+	if typ != nil {
+		t = *typ
+	}
+	if n < 0 {
+		return nil, errors.New("negative count")
+	}
+	return a.b.AuditLog(n, t), nil
+}
+
+// maxReplayBlocks bounds the blocks replayed by a call, so the call ends within
+// the timeout of the cluster RPC ops.
+const maxReplayBlocks = 1000
+
+// ReplayBlocks re-executes the canonical minor blocks of the shard from first
+// to last, at most maxReplayBlocks, on the states of their parents, and
+// compares the state roots, the receipts and the gas used to the stored ones.
+// The blocks whose parent state is pruned fail with an error.
+func (a *PrivateAdminAPI) ReplayBlocks(ctx context.Context, fullShardKey hexutil.Uint, first, last hexutil.Uint64) ([]map[string]interface{}, error) {
+	fullShardId, err := getFullShardId(&fullShardKey)
+	if err != nil {
+		return nil, err
+	}
+	if first > last {
+		return nil, errors.New("invalid block range")
+	}
+	if last-first >= maxReplayBlocks {
+		return nil, fmt.Errorf("at most %d blocks can be replayed at once", maxReplayBlocks)
+	}
+	results, err := a.b.ReplayBlocks(ctx, account.Branch{Value: fullShardId}, uint64(first), uint64(last))
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]map[string]interface{}, 0, len(results))
+	for _, result := range results {
+		fields = append(fields, encoder.BlockReplayResultEncoder(result))
+	}
+	return fields, nil
+}
+
+// BackupShard writes a consistent snapshot of the database of the shard, with
+// the height of its head block, to a tar archive at path on the host of the
+// slave serving the shard, gzipped if path ends with .gz. The archive is
+// restored with the db restore command.
+func (a *PrivateAdminAPI) BackupShard(ctx context.Context, fullShardKey hexutil.Uint, path string) (map[string]interface{}, error) {
+	fullShardId, err := getFullShardId(&fullShardKey)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return nil, errors.New("empty path")
+	}
+	rsp, err := a.b.BackupShard(ctx, account.Branch{Value: fullShardId}, path)
+	a.b.Audit("admin_backupShard", map[string]interface{}{"fullShardId": fullShardId, "path": path}, err)
+	if err != nil {
+		return nil, err
+	}
+	return encoder.BackupShardEncoder(fullShardId, path, rsp), nil
+}
+
+func (a *PrivateAdminAPI) shardMaintenance(ctx context.Context, method string, fullShardKey hexutil.Uint, action string) (map[string]interface{}, error) {
+	fullShardId, err := getFullShardId(&fullShardKey)
+	if err != nil {
+		return nil, err
+	}
+	rsps, err := a.b.ShardMaintenance(ctx, account.Branch{Value: fullShardId}, action)
+	a.b.Audit("admin_"+method, map[string]interface{}{"fullShardId": fullShardId}, err)
+	if err != nil {
+		return nil, err
+	}
+	return encoder.ShardMaintenanceEncoder(fullShardId, rsps), nil
+}
+
+// PauseShard pauses the block processing and the mining of the shard on the
+// slaves running it, once the blocks being added are. The new minor blocks are
+// rejected with a retryable error and the root blocks are added on resume.
+func (a *PrivateAdminAPI) PauseShard(ctx context.Context, fullShardKey hexutil.Uint) (map[string]interface{}, error) {
+	return a.shardMaintenance(ctx, "pauseShard", fullShardKey, qrpc.MaintenancePause)
+}
+
+// ResumeShard adds the root blocks deferred while the shard was paused and
+// resumes its block processing and mining.
+func (a *PrivateAdminAPI) ResumeShard(ctx context.Context, fullShardKey hexutil.Uint) (map[string]interface{}, error) {
+	return a.shardMaintenance(ctx, "resumeShard", fullShardKey, qrpc.MaintenanceResume)
+}
+
+// CompactShard compacts the database of the paused shard.
+func (a *PrivateAdminAPI) CompactShard(ctx context.Context, fullShardKey hexutil.Uint) (map[string]interface{}, error) {
+	return a.shardMaintenance(ctx, "compactShard", fullShardKey, qrpc.MaintenanceCompact)
+}
+
+// ReindexShard writes the tx indexes of the canonical blocks of the paused
+// shard again.
+func (a *PrivateAdminAPI) ReindexShard(ctx context.Context, fullShardKey hexutil.Uint) (map[string]interface{}, error) {
+	return a.shardMaintenance(ctx, "reindexShard", fullShardKey, qrpc.MaintenanceReindex)
+}
+
+// RegenerateState starts writing the state of the canonical minor block of the
+// shard at the height, pruned in the full gc mode, on the slaves running the
+// shard, by replaying the blocks from the nearest state available in the
+// background, and returns the progress of each slave. At most
+// core.MaxStateRegeneration blocks are replayed. It's called again with the
+// same height for the progress until all the slaves are done.
+func (a *PrivateAdminAPI) RegenerateState(ctx context.Context, fullShardKey hexutil.Uint, height hexutil.Uint64) (map[string]interface{}, error) {
+	fullShardId, err := getFullShardId(&fullShardKey)
+	if err != nil {
+		return nil, err
+	}
+	rsps, err := a.b.RegenerateState(ctx, account.Branch{Value: fullShardId}, uint64(height))
+	a.b.Audit("admin_regenerateState", map[string]interface{}{"fullShardId": fullShardId, "height": uint64(height)}, err)
+	if err != nil {
+		return nil, err
+	}
+	return encoder.RegenerateStateEncoder(fullShardId, uint64(height), rsps), nil
+}
+
+// PrivateDebugAPI traces the execution of the blocks of the shards.
+type PrivateDebugAPI struct {
+	b Backend
+}
+
+func NewPrivateDebugAPI(b Backend) *PrivateDebugAPI {
+	return &PrivateDebugAPI{b}
+}
+
+// TraceBlockByHash executes the txs of the minor block again with a struct
+// logger and returns the trace of each tx, up to the first one which can't be
+// applied. The block may be one the shard failed to insert lately.
+func (d *PrivateDebugAPI) TraceBlockByHash(ctx context.Context, blockID hexutil.Bytes, config *qrpc.TraceConfig) ([]json.RawMessage, error) {
+	blockHash, fullShardKey, err := encoder.IDDecoder(blockID)
+	if err != nil {
+		return nil, err
+	}
+	fullShardID, err := clusterCfg.Quarkchain.GetFullShardIdByFullShardKey(fullShardKey)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		config = new(qrpc.TraceConfig)
+	}
+	return d.b.TraceBlock(ctx, blockHash, account.Branch{Value: fullShardID}, config)
+}
+
+// PrivateFaultAPI sets the rules injecting faults in the cluster RPC ops and
+// the p2p commands of the process, for the integration tests. It is only
+// served by the binaries built with the faultinject build tag.
+type PrivateFaultAPI struct{}
+
+func NewPrivateFaultAPI() *PrivateFaultAPI {
+	return &PrivateFaultAPI{}
+}
+
+// SetRules replaces the fault rules.
+func (f *PrivateFaultAPI) SetRules(rules []fault.Rule) (bool, error) {
+	if err := fault.Set(rules); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// AddRule appends the rule to the fault rules.
+func (f *PrivateFaultAPI) AddRule(rule fault.Rule) (bool, error) {
+	if err := fault.Add(rule); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ClearRules removes all the fault rules.
+func (f *PrivateFaultAPI) ClearRules() bool {
+	fault.Clear()
+	return true
+}
+
+// Rules returns the fault rules with the number of the ops they faulted.
+func (f *PrivateFaultAPI) Rules() []fault.Rule {
+	return fault.Rules()
+}
+
+// PrivateAccountAPI manages the accounts in the keystore of the node, and sends
+// the transactions signed by them.
+type PrivateAccountAPI struct {
+	b Backend
+}
+
+func NewPrivateAccountAPI(b Backend) *PrivateAccountAPI {
+	return &PrivateAccountAPI{b}
+}
+
+// ListAccounts returns the addresses of the accounts in the keystore.
+func (p *PrivateAccountAPI) ListAccounts() []account.Address {
+	accounts := p.b.AccountManager().Accounts()
+	addresses := make([]account.Address, 0, len(accounts))
+	for _, a := range accounts {
+		addresses = append(addresses, a.Address)
+	}
+	return addresses
+}
+
+// NewAccount creates an account encrypted with the password, on the default full
+// shard key of the new key if fullShardKey is not given.
+func (p *PrivateAccountAPI) NewAccount(password string, fullShardKey *hexutil.Uint) (account.Address, error) {
+	a, err := p.b.AccountManager().NewAccount(password, toFullShardKey(fullShardKey))
+	if err != nil {
+		return account.Address{}, err
+	}
+	return a.Address, nil
+}
+
+// ImportRawKey stores the hex encoded private key encrypted with the password.
+func (p *PrivateAccountAPI) ImportRawKey(privateKey string, password string, fullShardKey *hexutil.Uint) (account.Address, error) {
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(privateKey, "0x"))
+	if err != nil {
+		return account.Address{}, err
+	}
+	a, err := p.b.AccountManager().ImportECDSA(key, password, toFullShardKey(fullShardKey))
+	if err != nil {
+		return account.Address{}, err
+	}
+	return a.Address, nil
+}
+
+// UnlockAccount unlocks the account for duration seconds, 300 by default, or
+// until the node exits if duration is 0.
+func (p *PrivateAccountAPI) UnlockAccount(address account.Address, password string, duration *uint64) (bool, error) {
+	const max = uint64(time.Duration(math.MaxInt64) / time.Second)
+	d := time.Duration(300) * time.Second
+	if duration != nil {
+		if *duration > max {
+			return false, errors.New("unlock duration too large")
+		}
+		d = time.Duration(*duration) * time.Second
+	}
+	if err := p.b.AccountManager().TimedUnlock(address.Recipient, password, d); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// LockAccount locks the account.
+func (p *PrivateAccountAPI) LockAccount(address account.Address) bool {
+	return p.b.AccountManager().Lock(address.Recipient) == nil
+}
+
+// Sign signs the message by the address with the key in the keystore decrypted
+// with the password, for the login of the dApps. The message is prefixed as in
+// TextHash, and the signature is in the [R || S || V] format with V of 27 or 28
+// as in Ethereum.
+func (p *PrivateAccountAPI) Sign(data hexutil.Bytes, address account.Address, password string) (hexutil.Bytes, error) {
+	return p.signHash(account.TextHash(data), address, password)
+}
+
+// SignTypedData signs the typed data of EIP-712 by the address like Sign.
+func (p *PrivateAccountAPI) SignTypedData(address account.Address, typedData account.TypedData, password string) (hexutil.Bytes, error) {
+	hash, err := typedData.Hash()
+	if err != nil {
+		return nil, err
+	}
+	return p.signHash(hash, address, password)
+}
+
+func (p *PrivateAccountAPI) signHash(hash []byte, address account.Address, password string) (hexutil.Bytes, error) {
+	sig, err := p.b.AccountManager().SignHashWithPassphrase(address.Recipient, password, hash)
+	if err != nil {
+		return nil, err
+	}
+	sig[64] += 27
+	return sig, nil
+}
+
+// SignTransaction signs the transaction like SendTransaction, and returns the
+// RLP of the signed transaction for sendRawTransaction instead of adding it to
+// the transaction pool.
+func (p *PrivateAccountAPI) SignTransaction(ctx context.Context, args SendTxArgs, password string) (hexutil.Bytes, error) {
+	tx, err := p.signTransaction(ctx, args, password)
+	if err != nil {
+		return nil, err
+	}
+	return rlp.EncodeToBytes(tx.EvmTx)
+}
+
+// SendTransaction signs the transaction by the from address, and adds it to
+// the transaction pool. The transaction is signed by the external signer
+// holding the account if any, or with the key in the keystore decrypted with
+// the password. The nonce is the transaction count of the from address if it
+// is not given.
+func (p *PrivateAccountAPI) SendTransaction(ctx context.Context, args SendTxArgs, password string) (hexutil.Bytes, error) {
+	tx, err := p.signTransaction(ctx, args, password)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.b.AddTransaction(ctx, tx); err != nil {
+		return EmptyTxID, addTransactionError(err)
+	}
+	return encoder.IDEncoder(tx.Hash().Bytes(), tx.EvmTx.FromFullShardKey()), nil
+}
+
+func (p *PrivateAccountAPI) signTransaction(ctx context.Context, args SendTxArgs, password string) (*types.Transaction, error) {
+	if args.From == nil {
+		return nil, errors.New("from is missing")
+	}
+	if args.FromFullShardKey == nil {
+		t := hexutil.Uint(args.From.FullShardKey)
+		args.FromFullShardKey = &t
+	}
+	if args.Nonce == nil {
+		from := account.NewAddress(args.From.Recipient, uint32(*args.FromFullShardKey))
+		data, err := p.b.GetPrimaryAccountData(ctx, &from, nil)
+		if err != nil {
+			return nil, err
+		}
+		nonce := hexutil.Uint64(data.TransactionCount)
+		args.Nonce = &nonce
+	}
+	if err := args.setTxDefaults(clusterCfg.Quarkchain); err != nil {
+		return nil, err
+	}
+	tx, err := args.toTransaction()
+	if err != nil {
+		return nil, err
+	}
+	if signer := p.b.ExternalSigner(); signer != nil && signer.Contains(args.From.Recipient) {
+		if tx.EvmTx, err = signer.SignTx(args.From.Recipient, tx.EvmTx); err != nil {
+			return nil, err
+		}
+		return tx, nil
+	}
+	if tx.EvmTx, err = p.b.AccountManager().SignTxWithPassphrase(args.From.Recipient, password, tx.EvmTx); err != nil {
+		return nil, err
+	}
+	return tx, nil
 }
 
 type EthBlockChainAPI struct {
@@ -751,21 +2061,21 @@ func NewEthAPI(b Backend) *EthBlockChainAPI {
 	return &EthBlockChainAPI{b: b, CommonAPI: CommonAPI{b}}
 }
 
-func (e *EthBlockChainAPI) GasPrice(fullShardKey *hexutil.Uint) (hexutil.Uint64, error) {
+func (e *EthBlockChainAPI) GasPrice(ctx context.Context, fullShardKey *hexutil.Uint) (hexutil.Uint64, error) {
 	fullShardId, err := getFullShardId(fullShardKey)
 	if err != nil {
 		return hexutil.Uint64(0), err
 	}
-	data, err := e.b.GasPrice(account.Branch{Value: fullShardId}, qcom.TokenIDEncode(DefaultTokenID))
+	data, err := e.b.GasPrice(ctx, account.Branch{Value: fullShardId}, qcom.TokenIDEncode(DefaultTokenID))
 	return hexutil.Uint64(data), nil
 }
 
-func (e *EthBlockChainAPI) GetBlockByNumber(heightInput *hexutil.Uint64) (map[string]interface{}, error) {
+func (e *EthBlockChainAPI) GetBlockByNumber(ctx context.Context, heightInput *hexutil.Uint64) (map[string]interface{}, error) {
 	height, err := transHexutilUint64ToUint64(heightInput)
 	if err != nil {
 		return nil, err
 	}
-	minorBlock, extraData, err := e.b.GetMinorBlockByHeight(height, account.Branch{Value: 0}, false)
+	minorBlock, extraData, err := e.b.GetMinorBlockByHeight(ctx, height, account.Branch{Value: 0}, false)
 	if err != nil {
 		return nil, err
 	}
@@ -775,14 +2085,14 @@ func (e *EthBlockChainAPI) GetBlockByNumber(heightInput *hexutil.Uint64) (map[st
 	return encoder.MinorBlockEncoder(minorBlock, true, extraData)
 }
 
-func (e *EthBlockChainAPI) GetBalance(address common.Address, fullShardKey *hexutil.Uint) (*hexutil.Big, error) {
+func (e *EthBlockChainAPI) GetBalance(ctx context.Context, address common.Address, fullShardKey *hexutil.Uint) (*hexutil.Big, error) {
 	fullShardId, err := getFullShardId(fullShardKey)
 	if err != nil {
 		return nil, err
 	}
 
 	addr := account.NewAddress(address, fullShardId)
-	data, err := e.b.GetPrimaryAccountData(&addr, nil)
+	data, err := e.b.GetPrimaryAccountData(ctx, &addr, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -790,50 +2100,62 @@ func (e *EthBlockChainAPI) GetBalance(address common.Address, fullShardKey *hexu
 	return (*hexutil.Big)(balance), nil
 }
 
-func (e *EthBlockChainAPI) GetTransactionCount(address common.Address, fullShardKey *hexutil.Uint) (hexutil.Uint64, error) {
+func (e *EthBlockChainAPI) GetTransactionCount(ctx context.Context, address common.Address, fullShardKey *hexutil.Uint) (hexutil.Uint64, error) {
 	fullShardId, err := getFullShardId(fullShardKey)
 	if err != nil {
 		return hexutil.Uint64(0), err
 	}
 	addr := account.NewAddress(address, fullShardId)
-	data, err := e.b.GetPrimaryAccountData(&addr, nil)
+	data, err := e.b.GetPrimaryAccountData(ctx, &addr, nil)
 	if err != nil {
 		return 0, err
 	}
 	return hexutil.Uint64(data.TransactionCount), nil
 }
 
-func (e *EthBlockChainAPI) GetCode(address common.Address, fullShardKey *hexutil.Uint) (hexutil.Bytes, error) {
+func (e *EthBlockChainAPI) GetCode(ctx context.Context, address common.Address, fullShardKey *hexutil.Uint) (hexutil.Bytes, error) {
 	fullShardId, err := getFullShardId(fullShardKey)
 	if err != nil {
 		return nil, err
 	}
 	addr := account.NewAddress(address, fullShardId)
-	return e.b.GetCode(&addr, nil)
+	return e.b.GetCode(ctx, &addr, nil)
+}
+
+func (e *EthBlockChainAPI) Call(ctx context.Context, data EthCallArgs, fullShardKey *hexutil.Uint, overrides *StateOverride) (hexutil.Bytes, error) {
+	args, err := convertEthCallData(&data)
+	if err != nil {
+		return nil, err
+	}
+	var stateOverride StateOverride
+	if overrides != nil {
+		stateOverride = *overrides
+	}
+	return e.CommonAPI.callOrEstimateGas(ctx, args, nil, stateOverride, true)
 }
 
-func (e *EthBlockChainAPI) Call(data EthCallArgs, fullShardKey *hexutil.Uint) (hexutil.Bytes, error) {
+func (e *EthBlockChainAPI) CreateAccessList(ctx context.Context, data EthCallArgs, fullShardKey *hexutil.Uint) (map[string]interface{}, error) {
 	args, err := convertEthCallData(&data)
 	if err != nil {
 		return nil, err
 	}
-	return e.CommonAPI.callOrEstimateGas(args, nil, true)
+	return e.CommonAPI.createAccessList(ctx, args, nil)
 }
 
-func (e *EthBlockChainAPI) EstimateGas(data EthCallArgs, fullShardKey *hexutil.Uint) ([]byte, error) {
+func (e *EthBlockChainAPI) EstimateGas(ctx context.Context, data EthCallArgs, fullShardKey *hexutil.Uint) ([]byte, error) {
 	args, err := convertEthCallData(&data)
 	if err != nil {
 		return nil, err
 	}
-	return e.CommonAPI.callOrEstimateGas(args, nil, false)
+	return e.CommonAPI.callOrEstimateGas(ctx, args, nil, nil, false)
 }
 
-func (e *EthBlockChainAPI) GetStorageAt(address common.Address, key common.Hash, fullShardKey *hexutil.Uint) (hexutil.Bytes, error) {
+func (e *EthBlockChainAPI) GetStorageAt(ctx context.Context, address common.Address, key common.Hash, fullShardKey *hexutil.Uint) (hexutil.Bytes, error) {
 	fullShardId, err := getFullShardId(fullShardKey)
 	if err != nil {
 		return nil, err
 	}
 	addr := account.NewAddress(address, fullShardId)
-	hash, err := e.b.GetStorageAt(&addr, key, nil)
+	hash, err := e.b.GetStorageAt(ctx, &addr, key, nil)
 	return hash.Bytes(), err
 }
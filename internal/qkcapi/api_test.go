@@ -0,0 +1,130 @@
+package qkcapi
+
+import (
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/cluster/config"
+	"github.com/QuarkChain/goquarkchain/common/hexutil"
+	"github.com/QuarkChain/goquarkchain/rpc"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+// dialAddressAPI serves the public API of a network of chainSize chains of
+// shardSize shards each, and restores the cluster config on cleanup.
+func dialAddressAPI(t *testing.T, chainSize, shardSize uint32) (*rpc.Client, func()) {
+	cfg := config.NewClusterConfig()
+	cfg.Quarkchain.Update(chainSize, shardSize, 10, 1)
+	orig := clusterCfg
+	clusterCfg = cfg
+
+	server := rpc.NewServer()
+	assert.NoError(t, server.RegisterName("qkc", NewPublicBlockChainAPI(nil)))
+	client := rpc.DialInProc(server)
+	return client, func() {
+		client.Close()
+		server.Stop()
+		clusterCfg = orig
+	}
+}
+
+func TestAddressConversion(t *testing.T) {
+	client, cleanup := dialAddressAPI(t, 2, 4)
+	defer cleanup()
+
+	recipient := common.HexToAddress("0x1a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d")
+	tests := []struct {
+		fullShardKey interface{} // nil for the default one
+		expected     uint32
+	}{
+		// the default full shard key is made of the bytes 0 and 10 of the recipient
+		{nil, 0x00001ab4},
+		{hexutil.Uint(0), 0},
+		{hexutil.Uint(0x0001000e), 0x0001000e},
+	}
+	for _, test := range tests {
+		var address account.Address
+		assert.NoError(t, client.Call(&address, "qkc_toQKCAddress", recipient, test.fullShardKey))
+		assert.Equal(t, recipient, address.Recipient)
+		assert.Equal(t, test.expected, address.FullShardKey)
+
+		var ethAddress common.Address
+		assert.NoError(t, client.Call(&ethAddress, "qkc_toEthAddress", address))
+		assert.Equal(t, recipient, ethAddress)
+
+		// the round trip keeps the full shard key given
+		var back account.Address
+		assert.NoError(t, client.Call(&back, "qkc_toQKCAddress", ethAddress, hexutil.Uint(address.FullShardKey)))
+		assert.Equal(t, address, back)
+	}
+}
+
+func TestAddressShard(t *testing.T) {
+	recipient := common.HexToAddress("0x1a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d")
+	tests := []struct {
+		chainSize, shardSize uint32
+		fullShardKey         uint32
+		fullShardID          uint32
+		chainID, shardID     uint32
+	}{
+		{1, 1, 0x0000abcd, 0x00000001, 0, 0},
+		{2, 4, 0x0001000e, 0x00010006, 1, 2},
+		{2, 4, 0x00000003, 0x00000007, 0, 3},
+		{4, 8, 0x0003ffff, 0x0003000f, 3, 7},
+		{4, 8, 0x00021230, 0x00020008, 2, 0},
+	}
+	for _, test := range tests {
+		client, cleanup := dialAddressAPI(t, test.chainSize, test.shardSize)
+
+		var shard map[string]hexutil.Uint
+		address := account.NewAddress(recipient, test.fullShardKey)
+		assert.NoError(t, client.Call(&shard, "qkc_getAddressShard", address))
+		assert.Equal(t, map[string]hexutil.Uint{
+			"fullShardKey": hexutil.Uint(test.fullShardKey),
+			"fullShardId":  hexutil.Uint(test.fullShardID),
+			"chainId":      hexutil.Uint(test.chainID),
+			"shardSize":    hexutil.Uint(test.shardSize),
+			"shardId":      hexutil.Uint(test.shardID),
+		}, shard)
+
+		// each shard of the network has an address of the recipient mapping to it
+		for chainID := uint32(0); chainID < test.chainSize; chainID++ {
+			for shardID := uint32(0); shardID < test.shardSize; shardID++ {
+				fullShardID := chainID<<16 | test.shardSize | shardID
+				var inShard account.Address
+				assert.NoError(t, client.Call(&inShard, "qkc_getAddressInShard", address, hexutil.Uint(fullShardID)))
+				assert.Equal(t, recipient, inShard.Recipient)
+				assert.NoError(t, client.Call(&shard, "qkc_getAddressShard", inShard))
+				assert.Equal(t, hexutil.Uint(fullShardID), shard["fullShardId"])
+			}
+		}
+		cleanup()
+	}
+}
+
+func TestAddressInvalidInput(t *testing.T) {
+	client, cleanup := dialAddressAPI(t, 2, 4)
+	defer cleanup()
+
+	recipient := common.HexToAddress("0x1a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d")
+	var result interface{}
+	// a chain out of the network
+	assert.Error(t, client.Call(&result, "qkc_getAddressShard", account.NewAddress(recipient, 0x00020000)))
+	// a full shard id out of the network, or with a wrong shard size
+	for _, fullShardID := range []uint32{0x00020004, 0x00000008, 0x00000001, 0} {
+		assert.Error(t, client.Call(&result, "qkc_getAddressInShard", account.NewAddress(recipient, 0), hexutil.Uint(fullShardID)))
+	}
+	// addresses not of 24 bytes or not prefixed by 0x
+	for _, address := range []string{
+		"0x1a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d",
+		"0x1a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d0000000000",
+		"1a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d00000000",
+	} {
+		assert.Error(t, client.Call(&result, "qkc_toEthAddress", address))
+		assert.Error(t, client.Call(&result, "qkc_getAddressShard", address))
+	}
+	for _, ethAddress := range []string{"0x1a2b", "0xzz2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d"} {
+		assert.Error(t, client.Call(&result, "qkc_toQKCAddress", ethAddress))
+	}
+}
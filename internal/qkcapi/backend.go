@@ -1,58 +1,104 @@
 package qkcapi
 
 import (
+	"context"
+	"encoding/json"
+
 	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/account/external"
+	"github.com/QuarkChain/goquarkchain/account/keystore"
+	"github.com/QuarkChain/goquarkchain/cluster/audit"
 	"github.com/QuarkChain/goquarkchain/cluster/config"
 	qrpc "github.com/QuarkChain/goquarkchain/cluster/rpc"
 	"github.com/QuarkChain/goquarkchain/consensus"
 	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/internal/fault"
+	"github.com/QuarkChain/goquarkchain/p2p"
 	"github.com/QuarkChain/goquarkchain/rpc"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/event"
 )
 
 type Backend interface {
-	AddTransaction(tx *types.Transaction) error
-	ExecuteTransaction(tx *types.Transaction, address *account.Address, height *uint64) ([]byte, error)
-	GetMinorBlockByHash(blockHash common.Hash, branch account.Branch, needExtraInfo bool) (*types.MinorBlock, *qrpc.PoSWInfo, error)
-	GetMinorBlockByHeight(height *uint64, branch account.Branch, needExtraInfo bool) (*types.MinorBlock, *qrpc.PoSWInfo, error)
-	GetTransactionByHash(txHash common.Hash, branch account.Branch) (*types.MinorBlock, uint32, error)
-	GetTransactionReceipt(txHash common.Hash, branch account.Branch) (*types.MinorBlock, uint32, *types.Receipt, error)
-	GetTransactionsByAddress(address *account.Address, start []byte, limit uint32, transferTokenID *uint64) ([]*qrpc.TransactionDetail, []byte, error)
-	GetAllTx(branch account.Branch, start []byte, limit uint32) ([]*qrpc.TransactionDetail, []byte, error)
-	GetLogs(args *rpc.FilterQuery) ([]*types.Log, error)
-	EstimateGas(tx *types.Transaction, address *account.Address) (uint32, error)
-	GetStorageAt(address *account.Address, key common.Hash, height *uint64) (common.Hash, error)
-	GetCode(address *account.Address, height *uint64) ([]byte, error)
-	GasPrice(branch account.Branch, tokenID uint64) (uint64, error)
-	GetWork(fullShardId *uint32, address *common.Address) (*consensus.MiningWork, error)
-	SubmitWork(fullShardId *uint32, headerHash common.Hash, nonce uint64, mixHash common.Hash, signature *[65]byte) (bool, error)
+	AddTransaction(ctx context.Context, tx *types.Transaction) error
+	SubscribeTxStatus(ch chan<- *qrpc.TxStatusEvent) (event.Subscription, error)
+	ExecuteTransaction(ctx context.Context, tx *types.Transaction, address *account.Address, height *uint64, overrides []*qrpc.AccountOverride) ([]byte, error)
+	ExecutePendingTransaction(ctx context.Context, tx *types.Transaction, address *account.Address, overrides []*qrpc.AccountOverride) ([]byte, error)
+	CreateAccessList(ctx context.Context, tx *types.Transaction, address *account.Address, height *uint64) (*qrpc.CreateAccessListResponse, error)
+	AccountManager() *keystore.KeyStore
+	ExternalSigner() *external.ExternalSigner // nil if no external signer is configured
+	GetMinorBlockByHash(ctx context.Context, blockHash common.Hash, branch account.Branch, needExtraInfo bool) (*types.MinorBlock, *qrpc.PoSWInfo, error)
+	GetMinorBlockByHeight(ctx context.Context, height *uint64, branch account.Branch, needExtraInfo bool) (*types.MinorBlock, *qrpc.PoSWInfo, error)
+	GetTransactionByHash(ctx context.Context, txHash common.Hash, branch account.Branch) (*types.MinorBlock, uint32, error)
+	GetTransactionReceipt(ctx context.Context, txHash common.Hash, branch account.Branch) (*types.MinorBlock, uint32, *types.Receipt, error)
+	GetMinorBlockReceipts(ctx context.Context, blockHash common.Hash, branch account.Branch) (types.Receipts, error)
+	ExportBlocks(ctx context.Context, branch account.Branch, from, count uint64, includeReceipts bool, fn func(*qrpc.ExportedBlock) bool) error
+	TraceBlock(ctx context.Context, blockHash common.Hash, branch account.Branch, config *qrpc.TraceConfig) ([]json.RawMessage, error)
+	SimulateBundle(ctx context.Context, txs []*types.Transaction, branch account.Branch, height *uint64) ([]*qrpc.BundleTxResult, error)
+	GetTransactionsByAddress(ctx context.Context, address *account.Address, start []byte, limit uint32, transferTokenID *uint64) ([]*qrpc.TransactionDetail, []byte, error)
+	GetAllTx(ctx context.Context, branch account.Branch, start []byte, limit uint32) ([]*qrpc.TransactionDetail, []byte, error)
+	GetLogs(ctx context.Context, args *rpc.FilterQuery) ([]*types.Log, error)
+	EstimateGas(ctx context.Context, tx *types.Transaction, address *account.Address) (uint32, error)
+	GetStorageAt(ctx context.Context, address *account.Address, key common.Hash, height *uint64) (common.Hash, error)
+	GetCode(ctx context.Context, address *account.Address, height *uint64) ([]byte, error)
+	GetBalanceHistory(ctx context.Context, address *account.Address, height *uint64) (*qrpc.GetBalanceHistoryResponse, error)
+	GasPrice(ctx context.Context, branch account.Branch, tokenID uint64) (uint64, error)
+	GetWork(ctx context.Context, fullShardId *uint32, address *common.Address) (*consensus.MiningWork, error)
+	SubmitWork(ctx context.Context, fullShardId *uint32, headerHash common.Hash, nonce uint64, mixHash common.Hash, signature *[65]byte) (bool, error)
+	GetStaleBlocks(ctx context.Context, fullShardId *uint32, limit uint32) (uint64, []*qrpc.StaleBlock, error)
+	GetBlockProfiles(ctx context.Context, branch account.Branch, limit uint32) ([]*qrpc.BlockProfile, error)
+	ReplayBlocks(ctx context.Context, branch account.Branch, first, last uint64) ([]*qrpc.BlockReplayResult, error)
+	BackupShard(ctx context.Context, branch account.Branch, path string) (*qrpc.BackupShardResponse, error)
+	ShardMaintenance(ctx context.Context, branch account.Branch, action string) (map[string]*qrpc.ShardMaintenanceResponse, error)
+	GetStateAvailability(ctx context.Context, branch account.Branch, number uint64) (*qrpc.StateAvailability, error)
+	RegenerateState(ctx context.Context, branch account.Branch, number uint64) (map[string]*qrpc.RegenerateStateResponse, error)
+	GetUnreceivedXShardDeposits(ctx context.Context, branch account.Branch, limit uint32) ([]*qrpc.UnreceivedXShardDeposit, error)
+	GetXShardQueues(ctx context.Context) ([]*qrpc.XShardQueue, error)
 	GetRootBlockByNumber(blockNr *uint64, needExtraInfo bool) (*types.RootBlock, *qrpc.PoSWInfo, error)
 	GetRootBlockByHash(hash common.Hash, needExtraInfo bool) (*types.RootBlock, *qrpc.PoSWInfo, error)
 	NetWorkInfo() map[string]interface{}
-	GetPrimaryAccountData(address *account.Address, blockHeight *uint64) (*qrpc.AccountBranchData, error)
+	GetPrimaryAccountData(ctx context.Context, address *account.Address, blockHeight *uint64) (*qrpc.AccountBranchData, error)
+	GetPendingAccountData(ctx context.Context, address *account.Address) (*qrpc.AccountBranchData, error)
 	CurrentBlock() *types.RootBlock
-	GetAccountData(address *account.Address, height *uint64) (map[uint32]*qrpc.AccountBranchData, error)
+	FinalizedRootBlockNumber() uint64
+	ResolveRootBlockNumber(blockNr rpc.BlockNumber) (uint64, error)
+	ResolveMinorBlockNumber(fullShardId uint32, blockNr rpc.BlockNumber) (uint64, error)
+	GetAccountData(ctx context.Context, address *account.Address, height *uint64) (map[uint32]*qrpc.AccountBranchData, error)
+	GetTotalBalance(ctx context.Context, address *account.Address) (*qrpc.TotalBalance, bool, error)
+	ReserveNonces(ctx context.Context, address *account.Address, count uint64) (*qrpc.NonceReservation, error)
+	ReleaseNonces(address *account.Address, from, count uint64) (uint64, error)
+	GetNonceState(address *account.Address) (*qrpc.NonceState, error)
 	GetClusterConfig() *config.ClusterConfig
+	ReloadConfig(ctx context.Context) ([]config.ConfigChange, error)
 	GetPeerInfolist() []qrpc.PeerInfoForDisPlay
 	GetStats() (map[string]interface{}, error)
+	GetShardStatuses() []*qrpc.ShardStatus
 	GetBlockCount() (map[uint32]map[account.Recipient]uint32, error)
 	SetTargetBlockTime(rootBlockTime *uint32, minorBlockTime *uint32) error
-	SetMining(mining bool)
-	CreateTransactions(numTxPerShard, xShardPercent uint32, tx *types.Transaction) error
+	SetMining(ctx context.Context, mining bool)
+	CreateTransactions(ctx context.Context, req *qrpc.GenTxRequest) error
+	GetTxBenchmarkReports(ctx context.Context) ([]*qrpc.TxBenchmarkReport, error)
 	IsSyncing() bool
 	IsMining() bool
 	GetSlavePoolLen() int
 	GetLastMinorBlockByFullShardID(fullShardId uint32) (uint64, error)
 	GetRootHashConfirmingMinorBlock(mBlockID []byte) common.Hash
+	GetMinorBlockConfirmation(mHash common.Hash, fullShardID uint32) (*types.RootBlock, uint64)
 	// p2p discovery healty nodes
 	GetKadRoutingTable() ([]string, error)
+	P2PServer() *p2p.Server // nil if the p2p network is not running
+	Profile(ctx context.Context, slaveID, action, file string, rate int) error
+	SetLogLevel(ctx context.Context, module, level string) error
+	Audit(action string, detail map[string]interface{}, err error)
+	AuditLog(count int, typ string) []audit.Entry
+	SlowOps(ctx context.Context, process string, count int) ([]*qrpc.SlowOp, error)
 }
 
 func GetAPIs(apiBackend Backend) []rpc.API {
 	once.Do(func() {
 		clusterCfg = apiBackend.GetClusterConfig()
 	})
-	return []rpc.API{
+	apis := []rpc.API{
 		{
 			Namespace: "qkc",
 			Version:   "1.0",
@@ -71,5 +117,44 @@ func GetAPIs(apiBackend Backend) []rpc.API {
 			Service:   NewEthAPI(apiBackend),
 			Public:    true,
 		},
+		{
+			Namespace: "personal",
+			Version:   "1.0",
+			Service:   NewPrivateAccountAPI(apiBackend),
+			Public:    false,
+		},
+		{
+			Namespace: "admin",
+			Version:   "1.0",
+			Service:   NewPrivateAdminAPI(apiBackend),
+			Public:    false,
+		},
+		{
+			Namespace: "debug",
+			Version:   "1.0",
+			Service:   NewPrivateDebugAPI(apiBackend),
+			Public:    false,
+		},
+		{
+			Namespace: "ws",
+			Version:   "1.0",
+			Service:   NewPublicStatsAPI(apiBackend),
+			Public:    true,
+		},
+		{
+			Namespace: "ws",
+			Version:   "1.0",
+			Service:   NewPublicTxWatcherAPI(apiBackend),
+			Public:    true,
+		},
+	}
+	if fault.Enabled {
+		apis = append(apis, rpc.API{
+			Namespace: "fault",
+			Version:   "1.0",
+			Service:   NewPrivateFaultAPI(),
+			Public:    false,
+		})
 	}
+	return apis
 }
@@ -1,7 +1,6 @@
 package qkcapi
 
 import (
-	"errors"
 	"github.com/QuarkChain/goquarkchain/account"
 	"github.com/QuarkChain/goquarkchain/cluster/config"
 	"github.com/QuarkChain/goquarkchain/common/hexutil"
@@ -27,6 +26,15 @@ func getFullShardId(fullShardKey *hexutil.Uint) (fullShardId uint32, err error)
 	return 1, nil
 }
 
+// toFullShardKey returns nil if the full shard key is not given.
+func toFullShardKey(fullShardKey *hexutil.Uint) *uint32 {
+	if fullShardKey == nil {
+		return nil
+	}
+	key := uint32(*fullShardKey)
+	return &key
+}
+
 func convertEthCallData(data *EthCallArgs) (*CallArgs, error) {
 	args := &CallArgs{
 		From:     &data.From,
@@ -45,22 +53,40 @@ func convertEthCallData(data *EthCallArgs) (*CallArgs, error) {
 	return args, nil
 }
 
-func decodeBlockNumberToUint64(b Backend, blockNumber *rpc.BlockNumber) (*uint64, error) {
-	if blockNumber == nil {
+// decodeBlockNumberToUint64 returns the height of the minor block of the shard
+// named by the block number, resolved by the backend, or nil for the latest
+// one, which the slave reads from the tip of the shard itself.
+func decodeBlockNumberToUint64(b Backend, fullShardId uint32, blockNumber *rpc.BlockNumber) (*uint64, error) {
+	if blockNumber == nil || *blockNumber == rpc.LatestBlockNumber {
 		return nil, nil
 	}
-	if *blockNumber == rpc.LatestBlockNumber {
-		return nil, nil
+	tBlock, err := b.ResolveMinorBlockNumber(fullShardId, *blockNumber)
+	if err != nil {
+		return nil, err
 	}
-	if *blockNumber == rpc.EarliestBlockNumber {
-		tBlock := uint64(0)
-		return &tBlock, nil
+	return &tBlock, nil
+}
+
+// decodeBlockNumberOfAddress is decodeBlockNumberToUint64 for the shard of the
+// address.
+func decodeBlockNumberOfAddress(b Backend, address *account.Address, blockNumber *rpc.BlockNumber) (*uint64, error) {
+	fullShardId, err := clusterCfg.Quarkchain.GetFullShardIdByFullShardKey(address.FullShardKey)
+	if err != nil {
+		return nil, err
 	}
+	return decodeBlockNumberToUint64(b, fullShardId, blockNumber)
+}
 
-	if *blockNumber < 0 {
-		return nil, errors.New("invalid block Num")
+// decodeRootBlockNumberToUint64 returns the height of the root block named by
+// the block number, or nil for the tip.
+func decodeRootBlockNumberToUint64(b Backend, blockNumber *rpc.BlockNumber) (*uint64, error) {
+	if blockNumber == nil || *blockNumber == rpc.LatestBlockNumber {
+		return nil, nil
+	}
+	tBlock, err := b.ResolveRootBlockNumber(*blockNumber)
+	if err != nil {
+		return nil, err
 	}
-	tBlock := uint64(blockNumber.Int64())
 	return &tBlock, nil
 }
 
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"github.com/QuarkChain/goquarkchain/account"
 	"github.com/QuarkChain/goquarkchain/cluster/config"
+	qrpc "github.com/QuarkChain/goquarkchain/cluster/rpc"
 	"github.com/QuarkChain/goquarkchain/common/hexutil"
 	"github.com/QuarkChain/goquarkchain/core/types"
 	"github.com/QuarkChain/goquarkchain/params"
@@ -48,6 +49,22 @@ type CallArgs struct {
 	TransferTokenID *hexutil.Uint64  `json:"transferTokenId"`
 }
 
+// BlockPageArgs pages the txs of a minor block, or the minor block headers of a
+// root block, for the large blocks to be returned in several responses.
+type BlockPageArgs struct {
+	Offset          hexutil.Uint `json:"offset"`
+	Limit           hexutil.Uint `json:"limit"` // to the end if 0
+	IncludeReceipts bool         `json:"includeReceipts"`
+}
+
+// RawBlocksArgs limits the minor blocks exported by getRawBlocks, and adds
+// their receipts and txs.
+type RawBlocksArgs struct {
+	Limit               hexutil.Uint `json:"limit"` // defaultRawBlocks if 0
+	IncludeReceipts     bool         `json:"includeReceipts"`
+	IncludeTransactions bool         `json:"includeTransactions"`
+}
+
 type GetAccountDataArgs struct {
 	Address       account.Address  `json:"address"`
 	IncludeShards *bool            `json:"include_shards"`
@@ -78,9 +95,51 @@ func (c *CallArgs) toTx(config *config.QuarkChainConfig) (*types.Transaction, er
 	return tx, nil
 }
 
+// OverrideAccount is the state of an account overridden for a call, the missing
+// fields are not overridden.
+type OverrideAccount struct {
+	Balances map[hexutil.Uint64]*hexutil.Big `json:"balances"` // by token id
+	Nonce    *hexutil.Uint64                 `json:"nonce"`
+	Code     *hexutil.Bytes                  `json:"code"`
+	Storage  map[common.Hash]common.Hash     `json:"storage"`
+}
+
+// StateOverride is the set of the accounts overridden on the state a call is
+// executed on.
+type StateOverride map[common.Address]OverrideAccount
+
+func (s StateOverride) toAccountOverrides() []*qrpc.AccountOverride {
+	overrides := make([]*qrpc.AccountOverride, 0, len(s))
+	for addr, account := range s {
+		override := &qrpc.AccountOverride{Address: addr}
+		if account.Balances != nil {
+			override.Balances = types.NewEmptyTokenBalances()
+			for tokenID, balance := range account.Balances {
+				override.Balances.SetValue(balance.ToInt(), uint64(tokenID))
+			}
+		}
+		if account.Nonce != nil {
+			nonce := uint64(*account.Nonce)
+			override.Nonce = &nonce
+		}
+		if account.Code != nil {
+			code := []byte(*account.Code)
+			override.Code = &code
+		}
+		for key, value := range account.Storage {
+			override.Storage = append(override.Storage, &qrpc.StorageOverride{Key: key, Value: value})
+		}
+		overrides = append(overrides, override)
+	}
+	return overrides
+}
+
 type CreateTxArgs struct {
 	NumTxPreShard    *uint32         `json:"numTxPerShard"`
 	XShardPrecent    *uint32         `json:"xShardPercent"`
+	TPS              *uint32         `json:"tps"`      // target of the cluster for the benchmarks
+	Duration         *uint32         `json:"duration"` // seconds of the benchmarks
+	Seed             *uint64         `json:"seed"`     // repeats the random txs of the same seed if set
 	To               *common.Address `json:"to"`
 	Gas              *hexutil.Big    `json:"gas"`
 	GasPrice         *hexutil.Big    `json:"gasPrice"`
@@ -92,9 +151,6 @@ type CreateTxArgs struct {
 }
 
 func (c *CreateTxArgs) setDefaults(config *config.QuarkChainConfig) error {
-	if c.NumTxPreShard == nil {
-		return errors.New("must set numTxPerShard")
-	}
 	if c.XShardPrecent == nil {
 		t := uint32(0)
 		c.XShardPrecent = &t
@@ -125,8 +181,34 @@ func (c *CreateTxArgs) setDefaults(config *config.QuarkChainConfig) error {
 		t := hexutil.Uint64(config.GetDefaultChainTokenID())
 		c.TransferTokenID = &t
 	}
+	if c.Seed == nil {
+		t := uint64(0)
+		c.Seed = &t
+	}
 	return nil
 }
+
+// toGenTxRequest returns the request to generate the txs like the sample tx,
+// the TPS target of the cluster being split among the shards.
+func (c *CreateTxArgs) toGenTxRequest(config *config.QuarkChainConfig) *qrpc.GenTxRequest {
+	req := &qrpc.GenTxRequest{
+		XShardPercent: *c.XShardPrecent,
+		Tx:            c.toTx(config),
+		Seed:          *c.Seed,
+	}
+	if c.NumTxPreShard != nil {
+		req.NumTxPerShard = *c.NumTxPreShard
+	}
+	if c.TPS != nil {
+		shards := uint32(len(config.GetGenesisShardIds()))
+		req.TPS = (*c.TPS + shards - 1) / shards
+	}
+	if c.Duration != nil {
+		req.Duration = *c.Duration
+	}
+	return req
+}
+
 func (c *CreateTxArgs) toTx(config *config.QuarkChainConfig) *types.Transaction {
 	var (
 		evmTx *types.EvmTransaction
@@ -147,11 +229,12 @@ func (c *CreateTxArgs) toTx(config *config.QuarkChainConfig) *types.Transaction
 
 // SendTxArgs represents the arguments to sumbit a new transaction into the transaction pool.
 type SendTxArgs struct {
-	To       *common.Address `json:"to"`
-	Gas      *hexutil.Big    `json:"gas"`
-	GasPrice *hexutil.Big    `json:"gasPrice"`
-	Value    *hexutil.Big    `json:"value"`
-	Nonce    *hexutil.Uint64 `json:"nonce"`
+	From     *account.Address `json:"from"` // signer of the transactions sent by personal_sendTransaction
+	To       *common.Address  `json:"to"`
+	Gas      *hexutil.Big     `json:"gas"`
+	GasPrice *hexutil.Big     `json:"gasPrice"`
+	Value    *hexutil.Big     `json:"value"`
+	Nonce    *hexutil.Uint64  `json:"nonce"`
 	// We accept "data" and "input" for backwards-compatibility reasons. "input" is the
 	// newer name and should be preferred by clients.
 	Data             *hexutil.Bytes  `json:"data"`
@@ -167,6 +250,18 @@ type SendTxArgs struct {
 
 // setDefaults is a helper function that fills in default values for unspecified tx fields.
 func (args *SendTxArgs) setDefaults(config *config.QuarkChainConfig) error {
+	if err := args.setTxDefaults(config); err != nil {
+		return err
+	}
+	if args.V == nil || args.R == nil || args.S == nil {
+		return errors.New("missing v r s")
+	}
+	return nil
+}
+
+// setTxDefaults fills in default values for unspecified tx fields except the
+// signature.
+func (args *SendTxArgs) setTxDefaults(config *config.QuarkChainConfig) error {
 	if args.Gas == nil {
 		args.Gas = (*hexutil.Big)(params.DefaultStartGas)
 	}
@@ -202,10 +297,6 @@ func (args *SendTxArgs) setDefaults(config *config.QuarkChainConfig) error {
 		t := hexutil.Uint64(config.GetDefaultChainTokenID())
 		args.TransferTokenID = &t
 	}
-
-	if args.V == nil || args.R == nil || args.S == nil {
-		return errors.New("missing v r s")
-	}
 	return nil
 }
 
@@ -221,7 +312,9 @@ func (args *SendTxArgs) toTransaction() (*types.Transaction, error) {
 			uint32(*args.ToFullShardKey), uint32(*args.NetWorkID), 0, *args.Data, uint64(*args.GasTokenID), uint64(*args.TransferTokenID))
 	}
 
-	evmTx.SetVRS(args.V.ToInt(), args.R.ToInt(), args.S.ToInt())
+	if args.V != nil && args.R != nil && args.S != nil {
+		evmTx.SetVRS(args.V.ToInt(), args.R.ToInt(), args.S.ToInt())
+	}
 
 	return &types.Transaction{
 		EvmTx:  evmTx,
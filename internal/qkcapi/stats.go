@@ -0,0 +1,95 @@
+package qkcapi
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	qrpc "github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/common/hexutil"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/rpc"
+)
+
+const (
+	defaultStatsInterval = 5 * time.Second
+	maxStatsInterval     = time.Hour
+)
+
+// PublicStatsAPI pushes the stats of the cluster to the websocket clients, for
+// the explorers and the dashboards to update live without polling getStats.
+type PublicStatsAPI struct {
+	b Backend
+}
+
+func NewPublicStatsAPI(b Backend) *PublicStatsAPI {
+	return &PublicStatsAPI{b}
+}
+
+// Stats creates a subscription pushing the stats of the root chain and of each
+// shard at once and then every interval seconds, 5 by default.
+func (api *PublicStatsAPI) Stats(ctx context.Context, interval *hexutil.Uint) (*rpc.Subscription, error) {
+	period := defaultStatsInterval
+	if interval != nil {
+		period = time.Duration(*interval) * time.Second
+		if period < time.Second || period > maxStatsInterval {
+			return nil, fmt.Errorf("stats interval should be from 1 to %d seconds", maxStatsInterval/time.Second)
+		}
+	}
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+		for {
+			notifier.Notify(rpcSub.ID, statsEncoder(api.b.CurrentBlock(), api.b.GetShardStatuses()))
+			select {
+			case <-ticker.C:
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
+// statsEncoder encodes the stats pushed, the fields of the shards are named as
+// in getStats with the tps over the last 60 seconds added.
+func statsEncoder(rootTip *types.RootBlock, statuses []*qrpc.ShardStatus) map[string]interface{} {
+	var (
+		shards         = make([]map[string]interface{}, 0, len(statuses))
+		txCount60s     uint32
+		pendingTxCount uint32
+	)
+	for _, status := range statuses {
+		shards = append(shards, map[string]interface{}{
+			"fullShardId":    status.Branch.GetFullShardID(),
+			"chainId":        status.Branch.GetChainID(),
+			"shardId":        status.Branch.GetShardID(),
+			"height":         status.Height,
+			"difficulty":     status.Difficulty,
+			"timestamp":      status.Timestamp,
+			"lastBlockTime":  status.LastBlockTime,
+			"txCount60s":     status.TxCount60s,
+			"tps":            float64(status.TxCount60s) / 60,
+			"pendingTxCount": status.PendingTxCount,
+		})
+		txCount60s += status.TxCount60s
+		pendingTxCount += status.PendingTxCount
+	}
+	return map[string]interface{}{
+		"rootHeight":     rootTip.Number(),
+		"rootDifficulty": rootTip.Difficulty(),
+		"rootTimestamp":  rootTip.Time(),
+		"txCount60s":     txCount60s,
+		"tps":            float64(txCount60s) / 60,
+		"pendingTxCount": pendingTxCount,
+		"shards":         shards,
+	}
+}
@@ -0,0 +1,64 @@
+package qkcapi
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	qrpc "github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/common/hexutil"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+type statsBackend struct {
+	Backend
+	rootTip  *types.RootBlock
+	statuses []*qrpc.ShardStatus
+}
+
+func (b *statsBackend) CurrentBlock() *types.RootBlock { return b.rootTip }
+
+func (b *statsBackend) GetShardStatuses() []*qrpc.ShardStatus { return b.statuses }
+
+func TestStatsSubscription(t *testing.T) {
+	backend := &statsBackend{
+		rootTip: types.NewRootBlock(&types.RootBlockHeader{Number: 7, Difficulty: big.NewInt(100)}, nil, nil),
+		statuses: []*qrpc.ShardStatus{
+			{Branch: account.Branch{Value: 1}, Height: 10, Difficulty: big.NewInt(5), TxCount60s: 120, PendingTxCount: 3},
+			{Branch: account.Branch{Value: 65537}, Height: 20, Difficulty: big.NewInt(6), TxCount60s: 60, PendingTxCount: 4},
+		},
+	}
+	server := rpc.NewServer()
+	defer server.Stop()
+	assert.NoError(t, server.RegisterName("ws", NewPublicStatsAPI(backend)))
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	ch := make(chan map[string]interface{})
+	sub, err := client.Subscribe(context.Background(), "ws", ch, "stats", hexutil.Uint(1))
+	assert.NoError(t, err)
+	defer sub.Unsubscribe()
+	for i := 0; i < 2; i++ {
+		select {
+		case stats := <-ch:
+			assert.Equal(t, float64(7), stats["rootHeight"])
+			assert.Equal(t, float64(3), stats["tps"])
+			assert.Equal(t, float64(7), stats["pendingTxCount"])
+			shards := stats["shards"].([]interface{})
+			assert.Len(t, shards, 2)
+			assert.Equal(t, float64(65537), shards[1].(map[string]interface{})["fullShardId"])
+			assert.Equal(t, float64(2), shards[0].(map[string]interface{})["tps"])
+		case err := <-sub.Err():
+			t.Fatal(err)
+		case <-time.After(3 * time.Second):
+			t.Fatal("stats not pushed")
+		}
+	}
+
+	_, err = client.Subscribe(context.Background(), "ws", ch, "stats", hexutil.Uint(0))
+	assert.Error(t, err)
+}
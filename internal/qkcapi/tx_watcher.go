@@ -0,0 +1,68 @@
+package qkcapi
+
+import (
+	"context"
+
+	qrpc "github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/common/hexutil"
+	"github.com/QuarkChain/goquarkchain/internal/encoder"
+	"github.com/QuarkChain/goquarkchain/rpc"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// PublicTxWatcherAPI pushes the status changes of the txs submitted through
+// the master to the websocket clients, for the wallets and the exchanges to
+// follow their txs without polling the receipts.
+type PublicTxWatcherAPI struct {
+	b Backend
+}
+
+func NewPublicTxWatcherAPI(b Backend) *PublicTxWatcherAPI {
+	return &PublicTxWatcherAPI{b}
+}
+
+// TxStatus creates a subscription pushing each time a tx submitted through the
+// master is included in a minor block, confirmed by a root block, or dropped
+// from the pool, for the txs of the ids only if given.
+func (api *PublicTxWatcherAPI) TxStatus(ctx context.Context, txIDs *[]hexutil.Bytes) (*rpc.Subscription, error) {
+	var filter map[common.Hash]bool
+	if txIDs != nil {
+		filter = make(map[common.Hash]bool, len(*txIDs))
+		for _, txID := range *txIDs {
+			txHash, _, err := encoder.IDDecoder(txID)
+			if err != nil {
+				return nil, err
+			}
+			filter[txHash] = true
+		}
+	}
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	events := make(chan *qrpc.TxStatusEvent, 128)
+	sub, err := api.b.SubscribeTxStatus(events)
+	if err != nil {
+		return nil, err
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case ev := <-events:
+				if filter == nil || filter[ev.TxHash] {
+					notifier.Notify(rpcSub.ID, encoder.TxStatusEventEncoder(ev))
+				}
+			case <-sub.Err():
+				return
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
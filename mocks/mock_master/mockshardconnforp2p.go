@@ -5,6 +5,8 @@
 package mock_master
 
 import (
+	context "context"
+	json "encoding/json"
 	account "github.com/QuarkChain/goquarkchain/account"
 	rpc "github.com/QuarkChain/goquarkchain/cluster/rpc"
 	consensus "github.com/QuarkChain/goquarkchain/consensus"
@@ -12,6 +14,7 @@ import (
 	rpc0 "github.com/QuarkChain/goquarkchain/rpc"
 	common "github.com/ethereum/go-ethereum/common"
 	gomock "github.com/golang/mock/gomock"
+	io "io"
 	big "math/big"
 	reflect "reflect"
 )
@@ -119,23 +122,23 @@ func (m *MockISlaveConn) EXPECT() *MockISlaveConnMockRecorder {
 }
 
 // AddTransactions mocks base method
-func (m *MockISlaveConn) AddTransactions(request *rpc.P2PRedirectRequest) error {
+func (m *MockISlaveConn) AddTransactions(ctx context.Context, request *rpc.P2PRedirectRequest) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddTransactions", request)
+	ret := m.ctrl.Call(m, "AddTransactions", ctx, request)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // AddTransactions indicates an expected call of AddTransactions
-func (mr *MockISlaveConnMockRecorder) AddTransactions(request interface{}) *gomock.Call {
+func (mr *MockISlaveConnMockRecorder) AddTransactions(ctx, request interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddTransactions", reflect.TypeOf((*MockISlaveConn)(nil).AddTransactions), request)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddTransactions", reflect.TypeOf((*MockISlaveConn)(nil).AddTransactions), ctx, request)
 }
 
 // GetMinorBlockByHash mocks base method
-func (m *MockISlaveConn) GetMinorBlockByHash(blockHash common.Hash, branch account.Branch, needExtraInfo bool) (*types.MinorBlock, *rpc.PoSWInfo, error) {
+func (m *MockISlaveConn) GetMinorBlockByHash(ctx context.Context, blockHash common.Hash, branch account.Branch, needExtraInfo bool) (*types.MinorBlock, *rpc.PoSWInfo, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetMinorBlockByHash", blockHash, branch, needExtraInfo)
+	ret := m.ctrl.Call(m, "GetMinorBlockByHash", ctx, blockHash, branch, needExtraInfo)
 	ret0, _ := ret[0].(*types.MinorBlock)
 	ret1, _ := ret[1].(*rpc.PoSWInfo)
 	ret2, _ := ret[2].(error)
@@ -143,15 +146,15 @@ func (m *MockISlaveConn) GetMinorBlockByHash(blockHash common.Hash, branch accou
 }
 
 // GetMinorBlockByHash indicates an expected call of GetMinorBlockByHash
-func (mr *MockISlaveConnMockRecorder) GetMinorBlockByHash(blockHash, branch, needExtraInfo interface{}) *gomock.Call {
+func (mr *MockISlaveConnMockRecorder) GetMinorBlockByHash(ctx, blockHash, branch, needExtraInfo interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMinorBlockByHash", reflect.TypeOf((*MockISlaveConn)(nil).GetMinorBlockByHash), blockHash, branch, needExtraInfo)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMinorBlockByHash", reflect.TypeOf((*MockISlaveConn)(nil).GetMinorBlockByHash), ctx, blockHash, branch, needExtraInfo)
 }
 
 // GetMinorBlockByHeight mocks base method
-func (m *MockISlaveConn) GetMinorBlockByHeight(height *uint64, branch account.Branch, needExtraInfo bool) (*types.MinorBlock, *rpc.PoSWInfo, error) {
+func (m *MockISlaveConn) GetMinorBlockByHeight(ctx context.Context, height *uint64, branch account.Branch, needExtraInfo bool) (*types.MinorBlock, *rpc.PoSWInfo, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetMinorBlockByHeight", height, branch, needExtraInfo)
+	ret := m.ctrl.Call(m, "GetMinorBlockByHeight", ctx, height, branch, needExtraInfo)
 	ret0, _ := ret[0].(*types.MinorBlock)
 	ret1, _ := ret[1].(*rpc.PoSWInfo)
 	ret2, _ := ret[2].(error)
@@ -159,98 +162,98 @@ func (m *MockISlaveConn) GetMinorBlockByHeight(height *uint64, branch account.Br
 }
 
 // GetMinorBlockByHeight indicates an expected call of GetMinorBlockByHeight
-func (mr *MockISlaveConnMockRecorder) GetMinorBlockByHeight(height, branch, needExtraInfo interface{}) *gomock.Call {
+func (mr *MockISlaveConnMockRecorder) GetMinorBlockByHeight(ctx, height, branch, needExtraInfo interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMinorBlockByHeight", reflect.TypeOf((*MockISlaveConn)(nil).GetMinorBlockByHeight), height, branch, needExtraInfo)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMinorBlockByHeight", reflect.TypeOf((*MockISlaveConn)(nil).GetMinorBlockByHeight), ctx, height, branch, needExtraInfo)
 }
 
 // GetMinorBlocks mocks base method
-func (m *MockISlaveConn) GetMinorBlocks(request *rpc.P2PRedirectRequest) ([]byte, error) {
+func (m *MockISlaveConn) GetMinorBlocks(ctx context.Context, request *rpc.P2PRedirectRequest) ([]byte, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetMinorBlocks", request)
+	ret := m.ctrl.Call(m, "GetMinorBlocks", ctx, request)
 	ret0, _ := ret[0].([]byte)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetMinorBlocks indicates an expected call of GetMinorBlocks
-func (mr *MockISlaveConnMockRecorder) GetMinorBlocks(request interface{}) *gomock.Call {
+func (mr *MockISlaveConnMockRecorder) GetMinorBlocks(ctx, request interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMinorBlocks", reflect.TypeOf((*MockISlaveConn)(nil).GetMinorBlocks), request)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMinorBlocks", reflect.TypeOf((*MockISlaveConn)(nil).GetMinorBlocks), ctx, request)
 }
 
 // GetMinorBlockHeaderList mocks base method
-func (m *MockISlaveConn) GetMinorBlockHeaderList(req *rpc.P2PRedirectRequest) ([]byte, error) {
+func (m *MockISlaveConn) GetMinorBlockHeaderList(ctx context.Context, req *rpc.P2PRedirectRequest) ([]byte, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetMinorBlockHeaderList", req)
+	ret := m.ctrl.Call(m, "GetMinorBlockHeaderList", ctx, req)
 	ret0, _ := ret[0].([]byte)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetMinorBlockHeaderList indicates an expected call of GetMinorBlockHeaderList
-func (mr *MockISlaveConnMockRecorder) GetMinorBlockHeaderList(req interface{}) *gomock.Call {
+func (mr *MockISlaveConnMockRecorder) GetMinorBlockHeaderList(ctx, req interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMinorBlockHeaderList", reflect.TypeOf((*MockISlaveConn)(nil).GetMinorBlockHeaderList), req)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMinorBlockHeaderList", reflect.TypeOf((*MockISlaveConn)(nil).GetMinorBlockHeaderList), ctx, req)
 }
 
 // GetMinorBlockHeaderListWithSkip mocks base method
-func (m *MockISlaveConn) GetMinorBlockHeaderListWithSkip(req *rpc.P2PRedirectRequest) ([]byte, error) {
+func (m *MockISlaveConn) GetMinorBlockHeaderListWithSkip(ctx context.Context, req *rpc.P2PRedirectRequest) ([]byte, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetMinorBlockHeaderListWithSkip", req)
+	ret := m.ctrl.Call(m, "GetMinorBlockHeaderListWithSkip", ctx, req)
 	ret0, _ := ret[0].([]byte)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetMinorBlockHeaderListWithSkip indicates an expected call of GetMinorBlockHeaderListWithSkip
-func (mr *MockISlaveConnMockRecorder) GetMinorBlockHeaderListWithSkip(req interface{}) *gomock.Call {
+func (mr *MockISlaveConnMockRecorder) GetMinorBlockHeaderListWithSkip(ctx, req interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMinorBlockHeaderListWithSkip", reflect.TypeOf((*MockISlaveConn)(nil).GetMinorBlockHeaderListWithSkip), req)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMinorBlockHeaderListWithSkip", reflect.TypeOf((*MockISlaveConn)(nil).GetMinorBlockHeaderListWithSkip), ctx, req)
 }
 
 // HandleNewTip mocks base method
-func (m *MockISlaveConn) HandleNewTip(request *rpc.HandleNewTipRequest) (bool, error) {
+func (m *MockISlaveConn) HandleNewTip(ctx context.Context, request *rpc.HandleNewTipRequest) (bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "HandleNewTip", request)
+	ret := m.ctrl.Call(m, "HandleNewTip", ctx, request)
 	ret0, _ := ret[0].(bool)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // HandleNewTip indicates an expected call of HandleNewTip
-func (mr *MockISlaveConnMockRecorder) HandleNewTip(request interface{}) *gomock.Call {
+func (mr *MockISlaveConnMockRecorder) HandleNewTip(ctx, request interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HandleNewTip", reflect.TypeOf((*MockISlaveConn)(nil).HandleNewTip), request)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HandleNewTip", reflect.TypeOf((*MockISlaveConn)(nil).HandleNewTip), ctx, request)
 }
 
 // HandleNewMinorBlock mocks base method
-func (m *MockISlaveConn) HandleNewMinorBlock(request *rpc.P2PRedirectRequest) error {
+func (m *MockISlaveConn) HandleNewMinorBlock(ctx context.Context, request *rpc.P2PRedirectRequest) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "HandleNewMinorBlock", request)
+	ret := m.ctrl.Call(m, "HandleNewMinorBlock", ctx, request)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // HandleNewMinorBlock indicates an expected call of HandleNewMinorBlock
-func (mr *MockISlaveConnMockRecorder) HandleNewMinorBlock(request interface{}) *gomock.Call {
+func (mr *MockISlaveConnMockRecorder) HandleNewMinorBlock(ctx, request interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HandleNewMinorBlock", reflect.TypeOf((*MockISlaveConn)(nil).HandleNewMinorBlock), request)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HandleNewMinorBlock", reflect.TypeOf((*MockISlaveConn)(nil).HandleNewMinorBlock), ctx, request)
 }
 
 // AddBlockListForSync mocks base method
-func (m *MockISlaveConn) AddBlockListForSync(request *rpc.AddBlockListForSyncRequest) (*rpc.ShardStatus, error) {
+func (m *MockISlaveConn) AddBlockListForSync(ctx context.Context, request *rpc.AddBlockListForSyncRequest) (*rpc.ShardStatus, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddBlockListForSync", request)
+	ret := m.ctrl.Call(m, "AddBlockListForSync", ctx, request)
 	ret0, _ := ret[0].(*rpc.ShardStatus)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // AddBlockListForSync indicates an expected call of AddBlockListForSync
-func (mr *MockISlaveConnMockRecorder) AddBlockListForSync(request interface{}) *gomock.Call {
+func (mr *MockISlaveConnMockRecorder) AddBlockListForSync(ctx, request interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddBlockListForSync", reflect.TypeOf((*MockISlaveConn)(nil).AddBlockListForSync), request)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddBlockListForSync", reflect.TypeOf((*MockISlaveConn)(nil).AddBlockListForSync), ctx, request)
 }
 
 // GetSlaveID mocks base method
@@ -325,6 +328,20 @@ func (mr *MockISlaveConnMockRecorder) SendPing() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendPing", reflect.TypeOf((*MockISlaveConn)(nil).SendPing))
 }
 
+// SupportsOp mocks base method
+func (m *MockISlaveConn) SupportsOp(op uint32) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SupportsOp", op)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// SupportsOp indicates an expected call of SupportsOp
+func (mr *MockISlaveConnMockRecorder) SupportsOp(op interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SupportsOp", reflect.TypeOf((*MockISlaveConn)(nil).SupportsOp), op)
+}
+
 // HeartBeat mocks base method
 func (m *MockISlaveConn) HeartBeat() bool {
 	m.ctrl.T.Helper()
@@ -340,110 +357,125 @@ func (mr *MockISlaveConnMockRecorder) HeartBeat() *gomock.Call {
 }
 
 // GetUnconfirmedHeaders mocks base method
-func (m *MockISlaveConn) GetUnconfirmedHeaders() (*rpc.GetUnconfirmedHeadersResponse, error) {
+func (m *MockISlaveConn) GetUnconfirmedHeaders(ctx context.Context) (*rpc.GetUnconfirmedHeadersResponse, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUnconfirmedHeaders")
+	ret := m.ctrl.Call(m, "GetUnconfirmedHeaders", ctx)
 	ret0, _ := ret[0].(*rpc.GetUnconfirmedHeadersResponse)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetUnconfirmedHeaders indicates an expected call of GetUnconfirmedHeaders
-func (mr *MockISlaveConnMockRecorder) GetUnconfirmedHeaders() *gomock.Call {
+func (mr *MockISlaveConnMockRecorder) GetUnconfirmedHeaders(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUnconfirmedHeaders", reflect.TypeOf((*MockISlaveConn)(nil).GetUnconfirmedHeaders))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUnconfirmedHeaders", reflect.TypeOf((*MockISlaveConn)(nil).GetUnconfirmedHeaders), ctx)
 }
 
 // GetAccountData mocks base method
-func (m *MockISlaveConn) GetAccountData(address *account.Address, height *uint64) (*rpc.GetAccountDataResponse, error) {
+func (m *MockISlaveConn) GetAccountData(ctx context.Context, address *account.Address, height *uint64) (*rpc.GetAccountDataResponse, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAccountData", address, height)
+	ret := m.ctrl.Call(m, "GetAccountData", ctx, address, height)
 	ret0, _ := ret[0].(*rpc.GetAccountDataResponse)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetAccountData indicates an expected call of GetAccountData
-func (mr *MockISlaveConnMockRecorder) GetAccountData(address, height interface{}) *gomock.Call {
+func (mr *MockISlaveConnMockRecorder) GetAccountData(ctx, address, height interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccountData", reflect.TypeOf((*MockISlaveConn)(nil).GetAccountData), address, height)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccountData", reflect.TypeOf((*MockISlaveConn)(nil).GetAccountData), ctx, address, height)
 }
 
 // AddRootBlock mocks base method
-func (m *MockISlaveConn) AddRootBlock(rootBlock *types.RootBlock, expectSwitch bool) error {
+func (m *MockISlaveConn) AddRootBlock(ctx context.Context, rootBlock *types.RootBlock, expectSwitch bool) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddRootBlock", rootBlock, expectSwitch)
+	ret := m.ctrl.Call(m, "AddRootBlock", ctx, rootBlock, expectSwitch)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // AddRootBlock indicates an expected call of AddRootBlock
-func (mr *MockISlaveConnMockRecorder) AddRootBlock(rootBlock, expectSwitch interface{}) *gomock.Call {
+func (mr *MockISlaveConnMockRecorder) AddRootBlock(ctx, rootBlock, expectSwitch interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddRootBlock", reflect.TypeOf((*MockISlaveConn)(nil).AddRootBlock), rootBlock, expectSwitch)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddRootBlock", reflect.TypeOf((*MockISlaveConn)(nil).AddRootBlock), ctx, rootBlock, expectSwitch)
 }
 
 // GenTx mocks base method
-func (m *MockISlaveConn) GenTx(numTxPerShard, xShardPercent uint32, tx *types.Transaction) error {
+func (m *MockISlaveConn) GenTx(ctx context.Context, req *rpc.GenTxRequest) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GenTx", numTxPerShard, xShardPercent, tx)
+	ret := m.ctrl.Call(m, "GenTx", ctx, req)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // GenTx indicates an expected call of GenTx
-func (mr *MockISlaveConnMockRecorder) GenTx(numTxPerShard, xShardPercent, tx interface{}) *gomock.Call {
+func (mr *MockISlaveConnMockRecorder) GenTx(ctx, req interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GenTx", reflect.TypeOf((*MockISlaveConn)(nil).GenTx), numTxPerShard, xShardPercent, tx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GenTx", reflect.TypeOf((*MockISlaveConn)(nil).GenTx), ctx, req)
 }
 
 // SendMiningConfigToSlaves mocks base method
-func (m *MockISlaveConn) SendMiningConfigToSlaves(artificialTxConfig *rpc.ArtificialTxConfig, mining bool) error {
+func (m *MockISlaveConn) SendMiningConfigToSlaves(ctx context.Context, artificialTxConfig *rpc.ArtificialTxConfig, mining bool) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SendMiningConfigToSlaves", artificialTxConfig, mining)
+	ret := m.ctrl.Call(m, "SendMiningConfigToSlaves", ctx, artificialTxConfig, mining)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // SendMiningConfigToSlaves indicates an expected call of SendMiningConfigToSlaves
-func (mr *MockISlaveConnMockRecorder) SendMiningConfigToSlaves(artificialTxConfig, mining interface{}) *gomock.Call {
+func (mr *MockISlaveConnMockRecorder) SendMiningConfigToSlaves(ctx, artificialTxConfig, mining interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendMiningConfigToSlaves", reflect.TypeOf((*MockISlaveConn)(nil).SendMiningConfigToSlaves), artificialTxConfig, mining)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendMiningConfigToSlaves", reflect.TypeOf((*MockISlaveConn)(nil).SendMiningConfigToSlaves), ctx, artificialTxConfig, mining)
 }
 
 // AddTransaction mocks base method
-func (m *MockISlaveConn) AddTransaction(tx *types.Transaction) error {
+func (m *MockISlaveConn) AddTransaction(ctx context.Context, tx *types.Transaction) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddTransaction", tx)
+	ret := m.ctrl.Call(m, "AddTransaction", ctx, tx)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // AddTransaction indicates an expected call of AddTransaction
-func (mr *MockISlaveConnMockRecorder) AddTransaction(tx interface{}) *gomock.Call {
+func (mr *MockISlaveConnMockRecorder) AddTransaction(ctx, tx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddTransaction", reflect.TypeOf((*MockISlaveConn)(nil).AddTransaction), tx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddTransaction", reflect.TypeOf((*MockISlaveConn)(nil).AddTransaction), ctx, tx)
 }
 
 // ExecuteTransaction mocks base method
-func (m *MockISlaveConn) ExecuteTransaction(tx *types.Transaction, fromAddress *account.Address, height *uint64) ([]byte, error) {
+func (m *MockISlaveConn) ExecuteTransaction(ctx context.Context, tx *types.Transaction, fromAddress *account.Address, height *uint64, overrides []*rpc.AccountOverride) ([]byte, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ExecuteTransaction", tx, fromAddress, height)
+	ret := m.ctrl.Call(m, "ExecuteTransaction", ctx, tx, fromAddress, height, overrides)
 	ret0, _ := ret[0].([]byte)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ExecuteTransaction indicates an expected call of ExecuteTransaction
-func (mr *MockISlaveConnMockRecorder) ExecuteTransaction(tx, fromAddress, height interface{}) *gomock.Call {
+func (mr *MockISlaveConnMockRecorder) ExecuteTransaction(ctx, tx, fromAddress, height, overrides interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExecuteTransaction", reflect.TypeOf((*MockISlaveConn)(nil).ExecuteTransaction), ctx, tx, fromAddress, height, overrides)
+}
+
+// CreateAccessList mocks base method
+func (m *MockISlaveConn) CreateAccessList(ctx context.Context, tx *types.Transaction, fromAddress *account.Address, height *uint64) (*rpc.CreateAccessListResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAccessList", ctx, tx, fromAddress, height)
+	ret0, _ := ret[0].(*rpc.CreateAccessListResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAccessList indicates an expected call of CreateAccessList
+func (mr *MockISlaveConnMockRecorder) CreateAccessList(ctx, tx, fromAddress, height interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExecuteTransaction", reflect.TypeOf((*MockISlaveConn)(nil).ExecuteTransaction), tx, fromAddress, height)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAccessList", reflect.TypeOf((*MockISlaveConn)(nil).CreateAccessList), ctx, tx, fromAddress, height)
 }
 
 // GetTransactionByHash mocks base method
-func (m *MockISlaveConn) GetTransactionByHash(txHash common.Hash, branch account.Branch) (*types.MinorBlock, uint32, error) {
+func (m *MockISlaveConn) GetTransactionByHash(ctx context.Context, txHash common.Hash, branch account.Branch) (*types.MinorBlock, uint32, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetTransactionByHash", txHash, branch)
+	ret := m.ctrl.Call(m, "GetTransactionByHash", ctx, txHash, branch)
 	ret0, _ := ret[0].(*types.MinorBlock)
 	ret1, _ := ret[1].(uint32)
 	ret2, _ := ret[2].(error)
@@ -451,15 +483,15 @@ func (m *MockISlaveConn) GetTransactionByHash(txHash common.Hash, branch account
 }
 
 // GetTransactionByHash indicates an expected call of GetTransactionByHash
-func (mr *MockISlaveConnMockRecorder) GetTransactionByHash(txHash, branch interface{}) *gomock.Call {
+func (mr *MockISlaveConnMockRecorder) GetTransactionByHash(ctx, txHash, branch interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransactionByHash", reflect.TypeOf((*MockISlaveConn)(nil).GetTransactionByHash), txHash, branch)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransactionByHash", reflect.TypeOf((*MockISlaveConn)(nil).GetTransactionByHash), ctx, txHash, branch)
 }
 
 // GetTransactionReceipt mocks base method
-func (m *MockISlaveConn) GetTransactionReceipt(txHash common.Hash, branch account.Branch) (*types.MinorBlock, uint32, *types.Receipt, error) {
+func (m *MockISlaveConn) GetTransactionReceipt(ctx context.Context, txHash common.Hash, branch account.Branch) (*types.MinorBlock, uint32, *types.Receipt, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetTransactionReceipt", txHash, branch)
+	ret := m.ctrl.Call(m, "GetTransactionReceipt", ctx, txHash, branch)
 	ret0, _ := ret[0].(*types.MinorBlock)
 	ret1, _ := ret[1].(uint32)
 	ret2, _ := ret[2].(*types.Receipt)
@@ -468,15 +500,74 @@ func (m *MockISlaveConn) GetTransactionReceipt(txHash common.Hash, branch accoun
 }
 
 // GetTransactionReceipt indicates an expected call of GetTransactionReceipt
-func (mr *MockISlaveConnMockRecorder) GetTransactionReceipt(txHash, branch interface{}) *gomock.Call {
+func (mr *MockISlaveConnMockRecorder) GetTransactionReceipt(ctx, txHash, branch interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransactionReceipt", reflect.TypeOf((*MockISlaveConn)(nil).GetTransactionReceipt), ctx, txHash, branch)
+}
+
+// GetMinorBlockReceipts mocks base method
+func (m *MockISlaveConn) GetMinorBlockReceipts(ctx context.Context, blockHash common.Hash, branch account.Branch) (types.Receipts, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMinorBlockReceipts", ctx, blockHash, branch)
+	ret0, _ := ret[0].(types.Receipts)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMinorBlockReceipts indicates an expected call of GetMinorBlockReceipts
+func (mr *MockISlaveConnMockRecorder) GetMinorBlockReceipts(ctx, blockHash, branch interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMinorBlockReceipts", reflect.TypeOf((*MockISlaveConn)(nil).GetMinorBlockReceipts), ctx, blockHash, branch)
+}
+
+// TraceBlock mocks base method
+func (m *MockISlaveConn) TraceBlock(ctx context.Context, blockHash common.Hash, branch account.Branch, config *rpc.TraceConfig) ([]json.RawMessage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TraceBlock", ctx, blockHash, branch, config)
+	ret0, _ := ret[0].([]json.RawMessage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TraceBlock indicates an expected call of TraceBlock
+func (mr *MockISlaveConnMockRecorder) TraceBlock(ctx, blockHash, branch, config interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TraceBlock", reflect.TypeOf((*MockISlaveConn)(nil).TraceBlock), ctx, blockHash, branch, config)
+}
+
+// SimulateBundle mocks base method
+func (m *MockISlaveConn) SimulateBundle(ctx context.Context, txs []*types.Transaction, branch account.Branch, height *uint64) ([]*rpc.BundleTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SimulateBundle", ctx, txs, branch, height)
+	ret0, _ := ret[0].([]*rpc.BundleTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SimulateBundle indicates an expected call of SimulateBundle
+func (mr *MockISlaveConnMockRecorder) SimulateBundle(ctx, txs, branch, height interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SimulateBundle", reflect.TypeOf((*MockISlaveConn)(nil).SimulateBundle), ctx, txs, branch, height)
+}
+
+// ExportBlocks mocks base method
+func (m *MockISlaveConn) ExportBlocks(ctx context.Context, branch account.Branch, from, count uint64, includeReceipts bool, fn func(*rpc.ExportedBlock) bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExportBlocks", ctx, branch, from, count, includeReceipts, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExportBlocks indicates an expected call of ExportBlocks
+func (mr *MockISlaveConnMockRecorder) ExportBlocks(ctx, branch, from, count, includeReceipts, fn interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransactionReceipt", reflect.TypeOf((*MockISlaveConn)(nil).GetTransactionReceipt), txHash, branch)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportBlocks", reflect.TypeOf((*MockISlaveConn)(nil).ExportBlocks), ctx, branch, from, count, includeReceipts, fn)
 }
 
 // GetTransactionsByAddress mocks base method
-func (m *MockISlaveConn) GetTransactionsByAddress(address *account.Address, start []byte, limit uint32, transferTokenID *uint64) ([]*rpc.TransactionDetail, []byte, error) {
+func (m *MockISlaveConn) GetTransactionsByAddress(ctx context.Context, address *account.Address, start []byte, limit uint32, transferTokenID *uint64) ([]*rpc.TransactionDetail, []byte, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetTransactionsByAddress", address, start, limit, transferTokenID)
+	ret := m.ctrl.Call(m, "GetTransactionsByAddress", ctx, address, start, limit, transferTokenID)
 	ret0, _ := ret[0].([]*rpc.TransactionDetail)
 	ret1, _ := ret[1].([]byte)
 	ret2, _ := ret[2].(error)
@@ -484,15 +575,15 @@ func (m *MockISlaveConn) GetTransactionsByAddress(address *account.Address, star
 }
 
 // GetTransactionsByAddress indicates an expected call of GetTransactionsByAddress
-func (mr *MockISlaveConnMockRecorder) GetTransactionsByAddress(address, start, limit, transferTokenID interface{}) *gomock.Call {
+func (mr *MockISlaveConnMockRecorder) GetTransactionsByAddress(ctx, address, start, limit, transferTokenID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransactionsByAddress", reflect.TypeOf((*MockISlaveConn)(nil).GetTransactionsByAddress), address, start, limit, transferTokenID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransactionsByAddress", reflect.TypeOf((*MockISlaveConn)(nil).GetTransactionsByAddress), ctx, address, start, limit, transferTokenID)
 }
 
 // GetAllTx mocks base method
-func (m *MockISlaveConn) GetAllTx(branch account.Branch, start []byte, limit uint32) ([]*rpc.TransactionDetail, []byte, error) {
+func (m *MockISlaveConn) GetAllTx(ctx context.Context, branch account.Branch, start []byte, limit uint32) ([]*rpc.TransactionDetail, []byte, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAllTx", branch, start, limit)
+	ret := m.ctrl.Call(m, "GetAllTx", ctx, branch, start, limit)
 	ret0, _ := ret[0].([]*rpc.TransactionDetail)
 	ret1, _ := ret[1].([]byte)
 	ret2, _ := ret[2].(error)
@@ -500,134 +591,134 @@ func (m *MockISlaveConn) GetAllTx(branch account.Branch, start []byte, limit uin
 }
 
 // GetAllTx indicates an expected call of GetAllTx
-func (mr *MockISlaveConnMockRecorder) GetAllTx(branch, start, limit interface{}) *gomock.Call {
+func (mr *MockISlaveConnMockRecorder) GetAllTx(ctx, branch, start, limit interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllTx", reflect.TypeOf((*MockISlaveConn)(nil).GetAllTx), branch, start, limit)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllTx", reflect.TypeOf((*MockISlaveConn)(nil).GetAllTx), ctx, branch, start, limit)
 }
 
 // GetLogs mocks base method
-func (m *MockISlaveConn) GetLogs(args *rpc0.FilterQuery) ([]*types.Log, error) {
+func (m *MockISlaveConn) GetLogs(ctx context.Context, args *rpc0.FilterQuery) ([]*types.Log, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetLogs", args)
+	ret := m.ctrl.Call(m, "GetLogs", ctx, args)
 	ret0, _ := ret[0].([]*types.Log)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetLogs indicates an expected call of GetLogs
-func (mr *MockISlaveConnMockRecorder) GetLogs(args interface{}) *gomock.Call {
+func (mr *MockISlaveConnMockRecorder) GetLogs(ctx, args interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLogs", reflect.TypeOf((*MockISlaveConn)(nil).GetLogs), args)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLogs", reflect.TypeOf((*MockISlaveConn)(nil).GetLogs), ctx, args)
 }
 
 // EstimateGas mocks base method
-func (m *MockISlaveConn) EstimateGas(tx *types.Transaction, fromAddress *account.Address) (uint32, error) {
+func (m *MockISlaveConn) EstimateGas(ctx context.Context, tx *types.Transaction, fromAddress *account.Address) (uint32, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "EstimateGas", tx, fromAddress)
+	ret := m.ctrl.Call(m, "EstimateGas", ctx, tx, fromAddress)
 	ret0, _ := ret[0].(uint32)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // EstimateGas indicates an expected call of EstimateGas
-func (mr *MockISlaveConnMockRecorder) EstimateGas(tx, fromAddress interface{}) *gomock.Call {
+func (mr *MockISlaveConnMockRecorder) EstimateGas(ctx, tx, fromAddress interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EstimateGas", reflect.TypeOf((*MockISlaveConn)(nil).EstimateGas), tx, fromAddress)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EstimateGas", reflect.TypeOf((*MockISlaveConn)(nil).EstimateGas), ctx, tx, fromAddress)
 }
 
 // GetStorageAt mocks base method
-func (m *MockISlaveConn) GetStorageAt(address *account.Address, key common.Hash, height *uint64) (common.Hash, error) {
+func (m *MockISlaveConn) GetStorageAt(ctx context.Context, address *account.Address, key common.Hash, height *uint64) (common.Hash, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetStorageAt", address, key, height)
+	ret := m.ctrl.Call(m, "GetStorageAt", ctx, address, key, height)
 	ret0, _ := ret[0].(common.Hash)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetStorageAt indicates an expected call of GetStorageAt
-func (mr *MockISlaveConnMockRecorder) GetStorageAt(address, key, height interface{}) *gomock.Call {
+func (mr *MockISlaveConnMockRecorder) GetStorageAt(ctx, address, key, height interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStorageAt", reflect.TypeOf((*MockISlaveConn)(nil).GetStorageAt), address, key, height)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStorageAt", reflect.TypeOf((*MockISlaveConn)(nil).GetStorageAt), ctx, address, key, height)
 }
 
 // GetCode mocks base method
-func (m *MockISlaveConn) GetCode(address *account.Address, height *uint64) ([]byte, error) {
+func (m *MockISlaveConn) GetCode(ctx context.Context, address *account.Address, height *uint64) ([]byte, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetCode", address, height)
+	ret := m.ctrl.Call(m, "GetCode", ctx, address, height)
 	ret0, _ := ret[0].([]byte)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetCode indicates an expected call of GetCode
-func (mr *MockISlaveConnMockRecorder) GetCode(address, height interface{}) *gomock.Call {
+func (mr *MockISlaveConnMockRecorder) GetCode(ctx, address, height interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCode", reflect.TypeOf((*MockISlaveConn)(nil).GetCode), address, height)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCode", reflect.TypeOf((*MockISlaveConn)(nil).GetCode), ctx, address, height)
 }
 
 // GasPrice mocks base method
-func (m *MockISlaveConn) GasPrice(branch account.Branch, tokenID uint64) (uint64, error) {
+func (m *MockISlaveConn) GasPrice(ctx context.Context, branch account.Branch, tokenID uint64) (uint64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GasPrice", branch, tokenID)
+	ret := m.ctrl.Call(m, "GasPrice", ctx, branch, tokenID)
 	ret0, _ := ret[0].(uint64)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GasPrice indicates an expected call of GasPrice
-func (mr *MockISlaveConnMockRecorder) GasPrice(branch, tokenID interface{}) *gomock.Call {
+func (mr *MockISlaveConnMockRecorder) GasPrice(ctx, branch, tokenID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GasPrice", reflect.TypeOf((*MockISlaveConn)(nil).GasPrice), branch, tokenID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GasPrice", reflect.TypeOf((*MockISlaveConn)(nil).GasPrice), ctx, branch, tokenID)
 }
 
 // GetWork mocks base method
-func (m *MockISlaveConn) GetWork(branch account.Branch, address *account.Address) (*consensus.MiningWork, error) {
+func (m *MockISlaveConn) GetWork(ctx context.Context, branch account.Branch, address *account.Address) (*consensus.MiningWork, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetWork", branch, address)
+	ret := m.ctrl.Call(m, "GetWork", ctx, branch, address)
 	ret0, _ := ret[0].(*consensus.MiningWork)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetWork indicates an expected call of GetWork
-func (mr *MockISlaveConnMockRecorder) GetWork(branch, address interface{}) *gomock.Call {
+func (mr *MockISlaveConnMockRecorder) GetWork(ctx, branch, address interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWork", reflect.TypeOf((*MockISlaveConn)(nil).GetWork), branch, address)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWork", reflect.TypeOf((*MockISlaveConn)(nil).GetWork), ctx, branch, address)
 }
 
 // SubmitWork mocks base method
-func (m *MockISlaveConn) SubmitWork(work *rpc.SubmitWorkRequest) (bool, error) {
+func (m *MockISlaveConn) SubmitWork(ctx context.Context, work *rpc.SubmitWorkRequest) (bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SubmitWork", work)
+	ret := m.ctrl.Call(m, "SubmitWork", ctx, work)
 	ret0, _ := ret[0].(bool)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // SubmitWork indicates an expected call of SubmitWork
-func (mr *MockISlaveConnMockRecorder) SubmitWork(work interface{}) *gomock.Call {
+func (mr *MockISlaveConnMockRecorder) SubmitWork(ctx, work interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubmitWork", reflect.TypeOf((*MockISlaveConn)(nil).SubmitWork), work)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubmitWork", reflect.TypeOf((*MockISlaveConn)(nil).SubmitWork), ctx, work)
 }
 
 // SetMining mocks base method
-func (m *MockISlaveConn) SetMining(mining bool) error {
+func (m *MockISlaveConn) SetMining(ctx context.Context, mining bool) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SetMining", mining)
+	ret := m.ctrl.Call(m, "SetMining", ctx, mining)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // SetMining indicates an expected call of SetMining
-func (mr *MockISlaveConnMockRecorder) SetMining(mining interface{}) *gomock.Call {
+func (mr *MockISlaveConnMockRecorder) SetMining(ctx, mining interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetMining", reflect.TypeOf((*MockISlaveConn)(nil).SetMining), mining)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetMining", reflect.TypeOf((*MockISlaveConn)(nil).SetMining), ctx, mining)
 }
 
 // GetRootChainStakes mocks base method
-func (m *MockISlaveConn) GetRootChainStakes(address account.Address, lastMinor common.Hash) (*big.Int, *account.Recipient, error) {
+func (m *MockISlaveConn) GetRootChainStakes(ctx context.Context, address account.Address, lastMinor common.Hash) (*big.Int, *account.Recipient, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetRootChainStakes", address, lastMinor)
+	ret := m.ctrl.Call(m, "GetRootChainStakes", ctx, address, lastMinor)
 	ret0, _ := ret[0].(*big.Int)
 	ret1, _ := ret[1].(*account.Recipient)
 	ret2, _ := ret[2].(error)
@@ -635,21 +726,342 @@ func (m *MockISlaveConn) GetRootChainStakes(address account.Address, lastMinor c
 }
 
 // GetRootChainStakes indicates an expected call of GetRootChainStakes
-func (mr *MockISlaveConnMockRecorder) GetRootChainStakes(address, lastMinor interface{}) *gomock.Call {
+func (mr *MockISlaveConnMockRecorder) GetRootChainStakes(ctx, address, lastMinor interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRootChainStakes", reflect.TypeOf((*MockISlaveConn)(nil).GetRootChainStakes), address, lastMinor)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRootChainStakes", reflect.TypeOf((*MockISlaveConn)(nil).GetRootChainStakes), ctx, address, lastMinor)
 }
 
 // CheckMinorBlocksInRoot mocks base method
-func (m *MockISlaveConn) CheckMinorBlocksInRoot(rootBlock *types.RootBlock) error {
+func (m *MockISlaveConn) CheckMinorBlocksInRoot(ctx context.Context, rootBlock *types.RootBlock) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CheckMinorBlocksInRoot", rootBlock)
+	ret := m.ctrl.Call(m, "CheckMinorBlocksInRoot", ctx, rootBlock)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // CheckMinorBlocksInRoot indicates an expected call of CheckMinorBlocksInRoot
-func (mr *MockISlaveConnMockRecorder) CheckMinorBlocksInRoot(rootBlock interface{}) *gomock.Call {
+func (mr *MockISlaveConnMockRecorder) CheckMinorBlocksInRoot(ctx, rootBlock interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckMinorBlocksInRoot", reflect.TypeOf((*MockISlaveConn)(nil).CheckMinorBlocksInRoot), ctx, rootBlock)
+}
+
+// GetStaleBlocks mocks base method
+func (m *MockISlaveConn) GetStaleBlocks(ctx context.Context, branch account.Branch, limit uint32) (uint64, []*rpc.StaleBlock, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStaleBlocks", ctx, branch, limit)
+	ret0, _ := ret[0].(uint64)
+	ret1, _ := ret[1].([]*rpc.StaleBlock)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetStaleBlocks indicates an expected call of GetStaleBlocks
+func (mr *MockISlaveConnMockRecorder) GetStaleBlocks(ctx, branch, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStaleBlocks", reflect.TypeOf((*MockISlaveConn)(nil).GetStaleBlocks), ctx, branch, limit)
+}
+
+// GetUnreceivedXShardDeposits mocks base method
+func (m *MockISlaveConn) GetUnreceivedXShardDeposits(ctx context.Context, branch account.Branch, limit uint32) ([]*rpc.UnreceivedXShardDeposit, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUnreceivedXShardDeposits", ctx, branch, limit)
+	ret0, _ := ret[0].([]*rpc.UnreceivedXShardDeposit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUnreceivedXShardDeposits indicates an expected call of GetUnreceivedXShardDeposits
+func (mr *MockISlaveConnMockRecorder) GetUnreceivedXShardDeposits(ctx, branch, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUnreceivedXShardDeposits", reflect.TypeOf((*MockISlaveConn)(nil).GetUnreceivedXShardDeposits), ctx, branch, limit)
+}
+
+// GetBlockProfiles mocks base method
+func (m *MockISlaveConn) GetBlockProfiles(ctx context.Context, branch account.Branch, limit uint32) ([]*rpc.BlockProfile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBlockProfiles", ctx, branch, limit)
+	ret0, _ := ret[0].([]*rpc.BlockProfile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBlockProfiles indicates an expected call of GetBlockProfiles
+func (mr *MockISlaveConnMockRecorder) GetBlockProfiles(ctx, branch, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBlockProfiles", reflect.TypeOf((*MockISlaveConn)(nil).GetBlockProfiles), ctx, branch, limit)
+}
+
+// GetTxBenchmarkReports mocks base method
+func (m *MockISlaveConn) GetTxBenchmarkReports(ctx context.Context) ([]*rpc.TxBenchmarkReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTxBenchmarkReports", ctx)
+	ret0, _ := ret[0].([]*rpc.TxBenchmarkReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTxBenchmarkReports indicates an expected call of GetTxBenchmarkReports
+func (mr *MockISlaveConnMockRecorder) GetTxBenchmarkReports(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTxBenchmarkReports", reflect.TypeOf((*MockISlaveConn)(nil).GetTxBenchmarkReports), ctx)
+}
+
+// ReplayBlocks mocks base method
+func (m *MockISlaveConn) ReplayBlocks(ctx context.Context, branch account.Branch, first, last uint64) ([]*rpc.BlockReplayResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplayBlocks", ctx, branch, first, last)
+	ret0, _ := ret[0].([]*rpc.BlockReplayResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReplayBlocks indicates an expected call of ReplayBlocks
+func (mr *MockISlaveConnMockRecorder) ReplayBlocks(ctx, branch, first, last interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplayBlocks", reflect.TypeOf((*MockISlaveConn)(nil).ReplayBlocks), ctx, branch, first, last)
+}
+
+// BackupShard mocks base method
+func (m *MockISlaveConn) BackupShard(ctx context.Context, branch account.Branch, path string) (*rpc.BackupShardResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BackupShard", ctx, branch, path)
+	ret0, _ := ret[0].(*rpc.BackupShardResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BackupShard indicates an expected call of BackupShard
+func (mr *MockISlaveConnMockRecorder) BackupShard(ctx, branch, path interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BackupShard", reflect.TypeOf((*MockISlaveConn)(nil).BackupShard), ctx, branch, path)
+}
+
+// GetShardRootTips mocks base method
+func (m *MockISlaveConn) GetShardRootTips(ctx context.Context) ([]*rpc.ShardRootTip, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetShardRootTips", ctx)
+	ret0, _ := ret[0].([]*rpc.ShardRootTip)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetShardRootTips indicates an expected call of GetShardRootTips
+func (mr *MockISlaveConnMockRecorder) GetShardRootTips(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetShardRootTips", reflect.TypeOf((*MockISlaveConn)(nil).GetShardRootTips), ctx)
+}
+
+// ResendXshardTxList mocks base method
+func (m *MockISlaveConn) ResendXshardTxList(ctx context.Context, branch account.Branch, hashList []common.Hash) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResendXshardTxList", ctx, branch, hashList)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResendXshardTxList indicates an expected call of ResendXshardTxList
+func (mr *MockISlaveConnMockRecorder) ResendXshardTxList(ctx, branch, hashList interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResendXshardTxList", reflect.TypeOf((*MockISlaveConn)(nil).ResendXshardTxList), ctx, branch, hashList)
+}
+
+// GetShardSnapshot mocks base method
+func (m *MockISlaveConn) GetShardSnapshot(branch account.Branch, rootHash common.Hash) (io.ReadCloser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetShardSnapshot", branch, rootHash)
+	ret0, _ := ret[0].(io.ReadCloser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetShardSnapshot indicates an expected call of GetShardSnapshot
+func (mr *MockISlaveConnMockRecorder) GetShardSnapshot(branch, rootHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetShardSnapshot", reflect.TypeOf((*MockISlaveConn)(nil).GetShardSnapshot), branch, rootHash)
+}
+
+// GetBalanceHistory mocks base method
+func (m *MockISlaveConn) GetBalanceHistory(ctx context.Context, address *account.Address, height *uint64) (*rpc.GetBalanceHistoryResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBalanceHistory", ctx, address, height)
+	ret0, _ := ret[0].(*rpc.GetBalanceHistoryResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBalanceHistory indicates an expected call of GetBalanceHistory
+func (mr *MockISlaveConnMockRecorder) GetBalanceHistory(ctx, address, height interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalanceHistory", reflect.TypeOf((*MockISlaveConn)(nil).GetBalanceHistory), ctx, address, height)
+}
+
+// GetXShardQueues mocks base method
+func (m *MockISlaveConn) GetXShardQueues(ctx context.Context) ([]*rpc.XShardQueue, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetXShardQueues", ctx)
+	ret0, _ := ret[0].([]*rpc.XShardQueue)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetXShardQueues indicates an expected call of GetXShardQueues
+func (mr *MockISlaveConnMockRecorder) GetXShardQueues(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetXShardQueues", reflect.TypeOf((*MockISlaveConn)(nil).GetXShardQueues), ctx)
+}
+
+// ShardMaintenance mocks base method
+func (m *MockISlaveConn) ShardMaintenance(ctx context.Context, branch account.Branch, action string) (*rpc.ShardMaintenanceResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ShardMaintenance", ctx, branch, action)
+	ret0, _ := ret[0].(*rpc.ShardMaintenanceResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ShardMaintenance indicates an expected call of ShardMaintenance
+func (mr *MockISlaveConnMockRecorder) ShardMaintenance(ctx, branch, action interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ShardMaintenance", reflect.TypeOf((*MockISlaveConn)(nil).ShardMaintenance), ctx, branch, action)
+}
+
+// GetStateAvailability mocks base method
+func (m *MockISlaveConn) GetStateAvailability(ctx context.Context, branch account.Branch, number uint64) (*rpc.StateAvailability, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStateAvailability", ctx, branch, number)
+	ret0, _ := ret[0].(*rpc.StateAvailability)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStateAvailability indicates an expected call of GetStateAvailability
+func (mr *MockISlaveConnMockRecorder) GetStateAvailability(ctx, branch, number interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStateAvailability", reflect.TypeOf((*MockISlaveConn)(nil).GetStateAvailability), ctx, branch, number)
+}
+
+// RegenerateState mocks base method
+func (m *MockISlaveConn) RegenerateState(ctx context.Context, branch account.Branch, number uint64) (*rpc.RegenerateStateResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RegenerateState", ctx, branch, number)
+	ret0, _ := ret[0].(*rpc.RegenerateStateResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RegenerateState indicates an expected call of RegenerateState
+func (mr *MockISlaveConnMockRecorder) RegenerateState(ctx, branch, number interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegenerateState", reflect.TypeOf((*MockISlaveConn)(nil).RegenerateState), ctx, branch, number)
+}
+
+// GetPendingAccountData mocks base method
+func (m *MockISlaveConn) GetPendingAccountData(ctx context.Context, address *account.Address) (*rpc.AccountBranchData, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPendingAccountData", ctx, address)
+	ret0, _ := ret[0].(*rpc.AccountBranchData)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPendingAccountData indicates an expected call of GetPendingAccountData
+func (mr *MockISlaveConnMockRecorder) GetPendingAccountData(ctx, address interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPendingAccountData", reflect.TypeOf((*MockISlaveConn)(nil).GetPendingAccountData), ctx, address)
+}
+
+// ExecutePendingTransaction mocks base method
+func (m *MockISlaveConn) ExecutePendingTransaction(ctx context.Context, tx *types.Transaction, fromAddress *account.Address, overrides []*rpc.AccountOverride) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExecutePendingTransaction", ctx, tx, fromAddress, overrides)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExecutePendingTransaction indicates an expected call of ExecutePendingTransaction
+func (mr *MockISlaveConnMockRecorder) ExecutePendingTransaction(ctx, tx, fromAddress, overrides interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExecutePendingTransaction", reflect.TypeOf((*MockISlaveConn)(nil).ExecutePendingTransaction), ctx, tx, fromAddress, overrides)
+}
+
+// ReloadConfig mocks base method
+func (m *MockISlaveConn) ReloadConfig(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReloadConfig", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReloadConfig indicates an expected call of ReloadConfig
+func (mr *MockISlaveConnMockRecorder) ReloadConfig(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReloadConfig", reflect.TypeOf((*MockISlaveConn)(nil).ReloadConfig), ctx)
+}
+
+// SetLogLevel mocks base method
+func (m *MockISlaveConn) SetLogLevel(ctx context.Context, module, level string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetLogLevel", ctx, module, level)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetLogLevel indicates an expected call of SetLogLevel
+func (mr *MockISlaveConnMockRecorder) SetLogLevel(ctx, module, level interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetLogLevel", reflect.TypeOf((*MockISlaveConn)(nil).SetLogLevel), ctx, module, level)
+}
+
+// Profile mocks base method
+func (m *MockISlaveConn) Profile(ctx context.Context, action, file string, rate int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Profile", ctx, action, file, rate)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Profile indicates an expected call of Profile
+func (mr *MockISlaveConnMockRecorder) Profile(ctx, action, file, rate interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Profile", reflect.TypeOf((*MockISlaveConn)(nil).Profile), ctx, action, file, rate)
+}
+
+// GetSlowOps mocks base method
+func (m *MockISlaveConn) GetSlowOps(ctx context.Context, count int) ([]*rpc.SlowOp, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSlowOps", ctx, count)
+	ret0, _ := ret[0].([]*rpc.SlowOp)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSlowOps indicates an expected call of GetSlowOps
+func (mr *MockISlaveConnMockRecorder) GetSlowOps(ctx, count interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSlowOps", reflect.TypeOf((*MockISlaveConn)(nil).GetSlowOps), ctx, count)
+}
+
+// SetTarget mocks base method
+func (m *MockISlaveConn) SetTarget(target string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetTarget", target)
+}
+
+// SetTarget indicates an expected call of SetTarget
+func (mr *MockISlaveConnMockRecorder) SetTarget(target interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTarget", reflect.TypeOf((*MockISlaveConn)(nil).SetTarget), target)
+}
+
+// Close mocks base method
+func (m *MockISlaveConn) Close() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Close")
+}
+
+// Close indicates an expected call of Close
+func (mr *MockISlaveConnMockRecorder) Close() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckMinorBlocksInRoot", reflect.TypeOf((*MockISlaveConn)(nil).CheckMinorBlocksInRoot), rootBlock)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockISlaveConn)(nil).Close))
 }
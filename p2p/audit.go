@@ -0,0 +1,160 @@
+package p2p
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// rlpxCipherSuite is the only cipher suite of the RLPx transport: ECIES on
+// secp256k1 for the key agreement, AES-256 in CTR mode for the frames and
+// Keccak-256 based MACs for their authentication.
+const rlpxCipherSuite = "ECIES-secp256k1/AES-256-CTR/Keccak-256-MAC"
+
+// encFormatter is implemented by the transports reporting the format of the
+// encryption handshake of the remote side.
+type encFormatter interface {
+	encFormat() encFormat
+}
+
+// handshakeAudit holds the parameters a connection was negotiated with.
+type handshakeAudit struct {
+	Time            time.Time `json:"time"`
+	ID              string    `json:"id"`
+	Addr            string    `json:"addr"`
+	Inbound         bool      `json:"inbound"`
+	Cipher          string    `json:"cipher"`
+	AuthVersion     uint      `json:"authVersion"`     // version of the remote auth message
+	LegacyHandshake bool      `json:"legacyHandshake"` // remote used the pre-EIP-8 format
+	ProtocolVersion uint64    `json:"protocolVersion"` // remote devp2p base protocol version
+	Snappy          bool      `json:"snappy"`
+	SnappyFlag      bool      `json:"snappyFlag"` // only the messages snappy shrinks are compressed
+	Name            string    `json:"name,omitempty"`
+	Caps            []string  `json:"caps,omitempty"`
+	Accepted        bool      `json:"accepted"`
+	Error           string    `json:"error,omitempty"`
+}
+
+func newHandshakeAudit(c *conn) *handshakeAudit {
+	a := &handshakeAudit{
+		Time:    time.Now(),
+		ID:      c.node.ID().String(),
+		Addr:    c.fd.RemoteAddr().String(),
+		Inbound: c.is(inboundConn),
+		Cipher:  rlpxCipherSuite,
+	}
+	if f, ok := c.transport.(encFormatter); ok {
+		format := f.encFormat()
+		a.AuthVersion, a.LegacyHandshake = format.Version, format.Legacy
+	}
+	return a
+}
+
+// setProtoHandshake records the parameters of the protocol handshake.
+func (a *handshakeAudit) setProtoHandshake(our, their *protoHandshake) {
+	a.ProtocolVersion = their.Version
+	a.Snappy = our.Version >= snappyProtocolVersion && their.Version >= snappyProtocolVersion
+	a.SnappyFlag = our.Version >= snappyFlagProtocolVersion && their.Version >= snappyFlagProtocolVersion
+	a.Name = their.Name
+	for _, cap := range their.Caps {
+		a.Caps = append(a.Caps, cap.String())
+	}
+}
+
+// handshakeAuditLog appends the handshake audits to a file as JSON lines.
+// A nil handshakeAuditLog writes nothing.
+type handshakeAuditLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// openHandshakeAuditLog opens the audit log at path, or returns nil if path
+// is empty.
+func openHandshakeAuditLog(path string) (*handshakeAuditLog, error) {
+	if path == "" {
+		return nil, nil
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &handshakeAuditLog{file: file}, nil
+}
+
+func (l *handshakeAuditLog) write(a *handshakeAudit) error {
+	if l == nil {
+		return nil
+	}
+	blob, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	_, err = l.file.Write(append(blob, '\n'))
+	return err
+}
+
+func (l *handshakeAuditLog) close() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file != nil {
+		l.file.Close()
+		l.file = nil
+	}
+}
+
+// auditHandshake exports the parameters of a handshake ended with err to the
+// audit log.
+func (srv *Server) auditHandshake(a *handshakeAudit, err error) {
+	if srv.auditLog == nil {
+		return
+	}
+	a.Accepted = err == nil
+	if err != nil {
+		a.Error = err.Error()
+	}
+	srv.log.Info("Audited peer handshake", "id", a.ID, "addr", a.Addr, "inbound", a.Inbound,
+		"authVersion", a.AuthVersion, "legacy", a.LegacyHandshake, "version", a.ProtocolVersion,
+		"snappy", a.Snappy, "caps", a.Caps, "accepted", a.Accepted, "err", err)
+	if err := srv.auditLog.write(a); err != nil {
+		srv.log.Error("Failed to write handshake audit", "err", err)
+	}
+}
+
+// checkEncHandshake refuses the encryption handshakes below the required
+// format.
+func (srv *Server) checkEncHandshake(a *handshakeAudit) error {
+	if srv.RequireEIP8 && a.LegacyHandshake {
+		return DiscIncompatibleVersion
+	}
+	return nil
+}
+
+// checkProtoHandshake refuses the peers running a base protocol older than
+// MinProtocolVersion or not offering the protocols of MinCaps.
+func (srv *Server) checkProtoHandshake(phs *protoHandshake) error {
+	if phs.Version < srv.MinProtocolVersion {
+		return DiscIncompatibleVersion
+	}
+	for _, min := range srv.MinCaps {
+		offered := false
+		for _, cap := range phs.Caps {
+			if cap.Name == min.Name && cap.Version >= min.Version {
+				offered = true
+				break
+			}
+		}
+		if !offered {
+			return DiscIncompatibleVersion
+		}
+	}
+	return nil
+}
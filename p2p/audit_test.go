@@ -0,0 +1,138 @@
+package p2p
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// auditTransport is a setupTransport reporting the format of the remote
+// encryption handshake.
+type auditTransport struct {
+	*setupTransport
+	format encFormat
+}
+
+func (c *auditTransport) encFormat() encFormat { return c.format }
+
+func TestServerHandshakePolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	auditPath := filepath.Join(dir, "handshakes.log")
+
+	var (
+		clientkey = newkey()
+		clientpub = &clientkey.PublicKey
+		clientID  = crypto.FromECDSAPub(clientpub)[1:]
+	)
+	tests := []struct {
+		format    encFormat
+		phs       protoHandshake
+		wantCalls string
+	}{
+		{
+			// pre-EIP-8 encryption handshake
+			format:    encFormat{Version: 4, Legacy: true},
+			wantCalls: "doEncHandshake,close,",
+		},
+		{
+			// old base protocol
+			format:    encFormat{Version: 4},
+			phs:       protoHandshake{Version: 4, ID: clientID, Caps: []Cap{{"discard", 2}}},
+			wantCalls: "doEncHandshake,doProtoHandshake,close,",
+		},
+		{
+			// old discard protocol
+			format:    encFormat{Version: 4},
+			phs:       protoHandshake{Version: 5, ID: clientID, Caps: []Cap{{"discard", 1}}},
+			wantCalls: "doEncHandshake,doProtoHandshake,close,",
+		},
+	}
+	srv := &Server{
+		Config: Config{
+			PrivateKey:         newkey(),
+			MaxPeers:           10,
+			NoDial:             true,
+			Protocols:          []Protocol{discard},
+			HandshakeAuditLog:  auditPath,
+			MinProtocolVersion: 5,
+			RequireEIP8:        true,
+			MinCaps:            []Cap{{"discard", 2}},
+		},
+		log: log.New(),
+	}
+	var tt *auditTransport
+	srv.newTransport = func(fd net.Conn) transport { return tt }
+	if err := srv.Start(); err != nil {
+		t.Fatalf("couldn't start server: %v", err)
+	}
+	for i, test := range tests {
+		tt = &auditTransport{&setupTransport{pubkey: clientpub, phs: test.phs}, test.format}
+		fd, _ := net.Pipe()
+		srv.SetupConn(fd, inboundConn, nil)
+		if tt.closeErr != DiscIncompatibleVersion {
+			t.Errorf("test %d: wrong close error %v", i, tt.closeErr)
+		}
+		if tt.calls != test.wantCalls {
+			t.Errorf("test %d: calls mismatch: got %q, want %q", i, tt.calls, test.wantCalls)
+		}
+	}
+	srv.Stop()
+
+	file, err := os.Open(auditPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var audits []handshakeAudit
+	for scanner := bufio.NewScanner(file); scanner.Scan(); {
+		var a handshakeAudit
+		if err := json.Unmarshal(scanner.Bytes(), &a); err != nil {
+			t.Fatal(err)
+		}
+		audits = append(audits, a)
+	}
+	if len(audits) != len(tests) {
+		t.Fatalf("got %d audits, want %d", len(audits), len(tests))
+	}
+	for i, a := range audits {
+		if a.Accepted || a.Error != DiscIncompatibleVersion.Error() || !a.Inbound || a.Cipher != rlpxCipherSuite {
+			t.Errorf("audit %d: wrong outcome %+v", i, a)
+		}
+		if a.LegacyHandshake != tests[i].format.Legacy || a.ProtocolVersion != tests[i].phs.Version {
+			t.Errorf("audit %d: wrong parameters %+v", i, a)
+		}
+	}
+	if a := audits[2]; len(a.Caps) != 1 || a.Caps[0] != "discard/1" || !a.Snappy || a.SnappyFlag {
+		t.Errorf("wrong protocol audit %+v", a)
+	}
+}
+
+func TestCheckProtoHandshake(t *testing.T) {
+	srv := &Server{Config: Config{MinProtocolVersion: 5, MinCaps: []Cap{{"a", 2}, {"b", 1}}}}
+	tests := []struct {
+		phs  protoHandshake
+		want error
+	}{
+		{protoHandshake{Version: 5, Caps: []Cap{{"a", 1}, {"a", 2}, {"b", 1}}}, nil},
+		{protoHandshake{Version: 6, Caps: []Cap{{"b", 3}, {"a", 3}}}, nil},
+		{protoHandshake{Version: 4, Caps: []Cap{{"a", 2}, {"b", 1}}}, DiscIncompatibleVersion},
+		{protoHandshake{Version: 5, Caps: []Cap{{"a", 1}, {"b", 1}}}, DiscIncompatibleVersion},
+		{protoHandshake{Version: 5, Caps: []Cap{{"a", 2}}}, DiscIncompatibleVersion},
+	}
+	for i, test := range tests {
+		if err := srv.checkProtoHandshake(&test.phs); err != test.want {
+			t.Errorf("test %d: got %v, want %v", i, err, test.want)
+		}
+	}
+}
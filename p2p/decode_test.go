@@ -139,6 +139,26 @@ func verifySerializeData(t *testing.T, decodeMsg QKCMsg, v codeC) error {
 		if err := serialize.DeserializeFromBytes(decodeMsg.Data, &cmd); err != nil {
 			t.Fatal("deserialize from Bytes err", err)
 		}
+	case NewTransactionHashesMsg:
+		cmd := new(NewTransactionHashes)
+		if err := serialize.DeserializeFromBytes(decodeMsg.Data, &cmd); err != nil {
+			t.Fatal("deserialize from Bytes err", err)
+		}
+	case GetTransactionListRequestMsg:
+		cmd := new(GetTransactionListRequest)
+		if err := serialize.DeserializeFromBytes(decodeMsg.Data, &cmd); err != nil {
+			t.Fatal("deserialize from Bytes err", err)
+		}
+	case GetTransactionListResponseMsg:
+		cmd := new(GetTransactionListResponse)
+		if err := serialize.DeserializeFromBytes(decodeMsg.Data, &cmd); err != nil {
+			t.Fatal("deserialize from Bytes err", err)
+		}
+	case NewCompactBlockMinorMsg:
+		cmd := new(NewCompactBlockMinor)
+		if err := serialize.DeserializeFromBytes(decodeMsg.Data, &cmd); err != nil {
+			t.Fatal("deserialize from Bytes err", err)
+		}
 	default:
 		t.Fatal("unexcepted decodeMsg op")
 	}
@@ -89,6 +89,7 @@ type discoverTable interface {
 	Resolve(*enode.Node) *enode.Node
 	LookupRandom() []*enode.Node
 	ReadRandomNodes([]*enode.Node) int
+	RequestENR(*enode.Node) (*enode.Node, error)
 	SetChkBlackListFunc(chkDialOutFunc func(string) bool)
 	GetKadRoutingTable() []string
 }
@@ -153,6 +154,17 @@ func (s *dialstate) addStatic(n *enode.Node) {
 	s.static[n.ID()] = &dialTask{flags: staticDialedConn, dest: n}
 }
 
+// addCandidate queues a node found by a discovery V5 topic search to be dialed
+// along with the lookup results.
+func (s *dialstate) addCandidate(n *enode.Node) {
+	for _, queued := range s.lookupBuf {
+		if queued.ID() == n.ID() {
+			return
+		}
+	}
+	s.lookupBuf = append(s.lookupBuf, n)
+}
+
 func (s *dialstate) removeStatic(n *enode.Node) {
 	// This removes a task so future attempts to connect will not be made.
 	delete(s.static, n.ID())
@@ -220,7 +232,7 @@ func (s *dialstate) newTasks(nRunning int, peers map[enode.ID]*Peer, now time.Ti
 	// Use random nodes from the table for half of the necessary
 	// dynamic dials.
 	randomCandidates := needDynDials / 2
-	if randomCandidates > 0 {
+	if randomCandidates > 0 && s.ntab != nil {
 		n := s.ntab.ReadRandomNodes(s.randomNodes)
 		for i := 0; i < randomCandidates && i < n; i++ {
 			if addDial(dynDialedConn, s.randomNodes[i]) {
@@ -237,8 +249,9 @@ func (s *dialstate) newTasks(nRunning int, peers map[enode.ID]*Peer, now time.Ti
 		}
 	}
 	s.lookupBuf = s.lookupBuf[:copy(s.lookupBuf, s.lookupBuf[i:])]
-	// Launch a discovery lookup if more candidates are needed.
-	if len(s.lookupBuf) < needDynDials && !s.lookupRunning {
+	// Launch a discovery lookup if more candidates are needed, the nodes
+	// found by the V5 topic searches only being added if V4 is disabled.
+	if len(s.lookupBuf) < needDynDials && !s.lookupRunning && s.ntab != nil {
 		s.lookupRunning = true
 		newtasks = append(newtasks, &discoverTask{})
 	}
@@ -296,6 +309,9 @@ func (t *dialTask) Do(srv *Server) {
 			return
 		}
 	}
+	if t.flags&dynDialedConn != 0 && !t.filter(srv) {
+		return
+	}
 	err := t.dial(srv, t.dest)
 	if err != nil {
 		log.Trace("Dial error", "task", t, "err", err)
@@ -342,6 +358,36 @@ func (t *dialTask) resolve(srv *Server) bool {
 	return true
 }
 
+// filter reports whether the dial filters of the protocols accept the node.
+// The record of a node known only by its endpoint, as the ones found by
+// discovery lookups, is requested from the node first.
+func (t *dialTask) filter(srv *Server) bool {
+	var filters []func(*enode.Node) bool
+	for _, p := range srv.Protocols {
+		if p.DialFilter != nil {
+			filters = append(filters, p.DialFilter)
+		}
+	}
+	if len(filters) == 0 {
+		return true
+	}
+	if t.dest.Seq() == 0 && srv.ntab != nil {
+		record, err := srv.ntab.RequestENR(t.dest)
+		if err != nil {
+			log.Trace("Can't request node record", "id", t.dest.ID(), "err", err)
+		} else {
+			t.dest = record
+		}
+	}
+	for _, filter := range filters {
+		if !filter(t.dest) {
+			log.Trace("Skipping dial candidate rejected by protocol", "id", t.dest.ID(), "addr", &net.TCPAddr{IP: t.dest.IP(), Port: t.dest.TCP()})
+			return false
+		}
+	}
+	return true
+}
+
 type dialError struct {
 	error
 }
@@ -85,6 +85,7 @@ func (t fakeTable) Close()                                           {}
 func (t fakeTable) LookupRandom() []*enode.Node                      { return nil }
 func (t fakeTable) Resolve(*enode.Node) *enode.Node                  { return nil }
 func (t fakeTable) ReadRandomNodes(buf []*enode.Node) int            { return copy(buf, t) }
+func (t fakeTable) RequestENR(n *enode.Node) (*enode.Node, error)     { return n, nil }
 func (t fakeTable) SetChkBlackListFunc(chkDialOutFunc func(string) bool) {}
 func (t fakeTable) GetKadRoutingTable() []string                     { return nil }
 
@@ -667,6 +668,36 @@ func TestDialResolve(t *testing.T) {
 	}
 }
 
+func TestDialFilter(t *testing.T) {
+	var r enr.Record
+	r.SetSeq(1)
+	r.Set(enr.IP{127, 0, 55, 234})
+	r.Set(enr.WithEntry("shard", uint(1)))
+	record := enode.SignNull(&r, uintID(1))
+	table := &resolveMock{record: record}
+	filter := func(n *enode.Node) bool {
+		var shard uint
+		return n.Load(enr.WithEntry("shard", &shard)) == nil && shard == 1
+	}
+	srv := &Server{ntab: table, Config: Config{Protocols: []Protocol{{DialFilter: filter}}}}
+
+	// The record of a node found by a lookup is requested for the filter.
+	task := &dialTask{flags: dynDialedConn, dest: newNode(uintID(1), net.IP{127, 0, 55, 234})}
+	if !task.filter(srv) {
+		t.Fatalf("node serving the shard rejected")
+	}
+	if task.dest != record {
+		t.Fatalf("dest not updated to the record")
+	}
+
+	// A node without the entry is rejected by this filter.
+	table.record = nil
+	task = &dialTask{flags: dynDialedConn, dest: newNode(uintID(2), net.IP{127, 0, 55, 235})}
+	if task.filter(srv) {
+		t.Fatalf("node without the shard accepted")
+	}
+}
+
 // compares task lists but doesn't care about the order.
 func sametasks(a, b []task) bool {
 	if len(a) != len(b) {
@@ -694,6 +725,7 @@ func uintID(i uint32) enode.ID {
 type resolveMock struct {
 	resolveCalls []*enode.Node
 	answer       *enode.Node
+	record       *enode.Node
 }
 
 func (t *resolveMock) Resolve(n *enode.Node) *enode.Node {
@@ -705,5 +737,31 @@ func (t *resolveMock) Self() *enode.Node                                { return
 func (t *resolveMock) Close()                                           {}
 func (t *resolveMock) LookupRandom() []*enode.Node                      { return nil }
 func (t *resolveMock) ReadRandomNodes(buf []*enode.Node) int            { return 0 }
+func (t *resolveMock) RequestENR(n *enode.Node) (*enode.Node, error) {
+	if t.record != nil {
+		return t.record, nil
+	}
+	return n, nil
+}
 func (t *resolveMock) SetChkBlackListFunc(chkDialOutFunc func(string) bool) {}
 func (t *resolveMock) GetKadRoutingTable() []string                     { return nil }
+
+// The nodes found by the topic searches are dialed without discovery V4.
+func TestDialTopicCandidates(t *testing.T) {
+	state := newDialState(enode.ID{}, nil, nil, nil, 4, nil)
+	n1 := newNode(uintID(1), net.IP{127, 0, 55, 234})
+	n2 := newNode(uintID(2), net.IP{127, 0, 55, 235})
+	state.addCandidate(n1)
+	state.addCandidate(n1)
+	state.addCandidate(n2)
+
+	// no lookup is launched for the missing candidates without discovery V4
+	tasks := state.newTasks(0, nil, time.Time{})
+	want := []task{
+		&dialTask{flags: dynDialedConn, dest: n1},
+		&dialTask{flags: dynDialedConn, dest: n2},
+	}
+	if !reflect.DeepEqual(tasks, want) {
+		t.Fatalf("expected dial tasks of the candidates, got %#v", tasks)
+	}
+}
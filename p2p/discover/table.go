@@ -92,6 +92,7 @@ type transport interface {
 	self() *enode.Node
 	ping(enode.ID, *net.UDPAddr) error
 	findnode(toid enode.ID, addr *net.UDPAddr, target encPubkey) ([]*node, error)
+	requestENR(*enode.Node) (*enode.Node, error)
 	close()
 }
 
@@ -254,6 +255,12 @@ func (tab *Table) Resolve(n *enode.Node) *enode.Node {
 	return nil
 }
 
+// RequestENR asks the node for its current record, which is returned if it is
+// newer than the one of the node.
+func (tab *Table) RequestENR(n *enode.Node) (*enode.Node, error) {
+	return tab.net.requestENR(n)
+}
+
 // LookupRandom finds random nodes in the network.
 func (tab *Table) LookupRandom() []*enode.Node {
 	var target encPubkey
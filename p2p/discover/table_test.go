@@ -533,6 +533,7 @@ func (tn *preminedTestnet) findnode(toid enode.ID, toaddr *net.UDPAddr, target e
 func (*preminedTestnet) close()                                        {}
 func (*preminedTestnet) waitping(from enode.ID) error                  { return nil }
 func (*preminedTestnet) ping(toid enode.ID, toaddr *net.UDPAddr) error { return nil }
+func (*preminedTestnet) requestENR(n *enode.Node) (*enode.Node, error) { return n, nil }
 
 // mine generates a testnet struct literal with nodes at
 // various distances to the given target.
@@ -26,6 +26,7 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
 	"github.com/ethereum/go-ethereum/p2p/netutil"
 	"github.com/ethereum/go-ethereum/rlp"
 	"net"
@@ -63,6 +64,8 @@ const (
 	pongPacket
 	findnodePacket
 	neighborsPacket
+	enrRequestPacket
+	enrResponsePacket
 
 	qkcIdStringTemplate = "qkc%d discovery"
 )
@@ -111,6 +114,21 @@ type (
 		Rest []rlp.RawValue `rlp:"tail"`
 	}
 
+	// enrRequest is a query for the node record of the recipient.
+	enrRequest struct {
+		Expiration uint64
+		// Ignore additional fields (for forward compatibility).
+		Rest []rlp.RawValue `rlp:"tail"`
+	}
+
+	// enrResponse is the reply to enrRequest.
+	enrResponse struct {
+		ReplyTok []byte // Hash of the enrRequest packet.
+		Record   enr.Record
+		// Ignore additional fields (for forward compatibility).
+		Rest []rlp.RawValue `rlp:"tail"`
+	}
+
 	rpcNode struct {
 		IP  net.IP // len 4 for IPv4 or 16 for IPv6
 		UDP uint16 // for discovery protocol
@@ -188,6 +206,8 @@ type udp struct {
 	addpending chan *pending
 	gotreply   chan reply
 	closing    chan struct{}
+
+	unhandled chan<- ReadPacket // nil if the packets of other protocols are dropped
 }
 
 // pending represents a pending reply.
@@ -268,6 +288,7 @@ func newUDP(c conn, ln *enode.LocalNode, cfg Config) (*Table, *udp, error) {
 		closing:     make(chan struct{}),
 		gotreply:    make(chan reply),
 		addpending:  make(chan *pending),
+		unhandled:   cfg.Unhandled,
 	}
 	tab, err := newTable(udp, ln.Database(), cfg.Bootnodes)
 	if err != nil {
@@ -365,6 +386,46 @@ func (t *udp) findnode(toid enode.ID, toaddr *net.UDPAddr, target encPubkey) ([]
 	return nodes, <-errc
 }
 
+// requestENR sends an enrRequest to the given node and waits for its record,
+// which is returned if it is newer than the one of the node.
+func (t *udp) requestENR(n *enode.Node) (*enode.Node, error) {
+	toaddr := &net.UDPAddr{IP: n.IP(), Port: n.UDP()}
+	// The node answers only after an endpoint proof, as for findnode.
+	if time.Since(t.db.LastPingReceived(n.ID())) > bondExpiration {
+		t.ping(n.ID(), toaddr)
+		t.waitping(n.ID())
+	}
+
+	req := &enrRequest{Expiration: uint64(time.Now().Add(expiration).Unix())}
+	packet, hash, err := encodePacket(t.priv, enrRequestPacket, req)
+	if err != nil {
+		return nil, err
+	}
+	var resp *enrResponse
+	errc := t.pending(n.ID(), enrResponsePacket, func(r interface{}) bool {
+		ok := bytes.Equal(r.(*enrResponse).ReplyTok, hash)
+		if ok {
+			resp = r.(*enrResponse)
+		}
+		return ok
+	})
+	t.write(toaddr, req.name(), packet)
+	if err := <-errc; err != nil {
+		return nil, err
+	}
+	record, err := enode.New(enode.ValidSchemes, &resp.Record)
+	if err != nil {
+		return nil, err
+	}
+	if record.ID() != n.ID() {
+		return nil, errors.New("invalid ID in response record")
+	}
+	if record.Seq() < n.Seq() {
+		return n, nil
+	}
+	return record, nil
+}
+
 // pending adds a reply callback to the pending reply queue.
 // see the documentation of type pending for a detailed explanation.
 func (t *udp) pending(id enode.ID, ptype byte, callback func(interface{}) bool) <-chan error {
@@ -559,6 +620,9 @@ func encodePacket(priv *ecdsa.PrivateKey, ptype byte, req interface{}) (packet,
 // readLoop runs in its own goroutine. it handles incoming UDP packets.
 func (t *udp) readLoop(filter nodefilter.BlackFilter) {
 	defer t.wg.Done()
+	if t.unhandled != nil {
+		defer close(t.unhandled)
+	}
 
 	// Discovery packets are defined to be no larger than 1280 bytes.
 	// Packets larger than this size will be cut at the end and treated
@@ -581,6 +645,14 @@ func (t *udp) readLoop(filter nodefilter.BlackFilter) {
 		}
 		err = t.handlePacket(from, buf[:nbytes])
 		if err == errDontMatchPreFix {
+			if t.unhandled != nil {
+				// e.g. a discovery v5 packet, the socket being shared
+				select {
+				case t.unhandled <- ReadPacket{Data: append([]byte(nil), buf[:nbytes]...), Addr: from}:
+				default:
+				}
+				continue
+			}
 			filter.AddDialoutBlacklist(from.IP.String())
 		}
 	}
@@ -625,6 +697,10 @@ func decodePacket(buf []byte) (packet, encPubkey, []byte, error) {
 		req = new(findnode)
 	case neighborsPacket:
 		req = new(neighbors)
+	case enrRequestPacket:
+		req = new(enrRequest)
+	case enrResponsePacket:
+		req = new(enrResponse)
 	default:
 		return nil, fromKey, hash, fmt.Errorf("unknown type: %d", ptype)
 	}
@@ -731,3 +807,29 @@ func (req *neighbors) name() string { return "NEIGHBORS/v4" }
 func expired(ts uint64) bool {
 	return time.Unix(int64(ts), 0).Before(time.Now())
 }
+
+func (req *enrRequest) handle(t *udp, from *net.UDPAddr, fromKey encPubkey, mac []byte) error {
+	if expired(req.Expiration) {
+		return errExpired
+	}
+	if time.Since(t.db.LastPongReceived(fromKey.id())) > bondExpiration {
+		// No endpoint proof pong exists, see findnode.
+		return errUnknownNode
+	}
+	t.send(from, enrResponsePacket, &enrResponse{
+		ReplyTok: mac,
+		Record:   *t.localNode.Node().Record(),
+	})
+	return nil
+}
+
+func (req *enrRequest) name() string { return "ENRREQUEST/v4" }
+
+func (req *enrResponse) handle(t *udp, from *net.UDPAddr, fromKey encPubkey, mac []byte) error {
+	if !t.handleReply(fromKey.id(), enrResponsePacket, req) {
+		return errUnsolicitedReply
+	}
+	return nil
+}
+
+func (req *enrResponse) name() string { return "ENRRESPONSE/v4" }
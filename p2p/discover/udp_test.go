@@ -33,10 +33,12 @@ import (
 	"testing"
 	"time"
 
+	"github.com/QuarkChain/goquarkchain/p2p/nodefilter"
 	"github.com/davecgh/go-spew/spew"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
 	"github.com/ethereum/go-ethereum/rlp"
 )
 
@@ -134,6 +136,7 @@ func TestUDP_packetErrors(t *testing.T) {
 	test.packetIn(errUnsolicitedReply, pongPacket, &pong{ReplyTok: []byte{}, Expiration: futureExp})
 	test.packetIn(errUnknownNode, findnodePacket, &findnode{Expiration: futureExp})
 	test.packetIn(errUnsolicitedReply, neighborsPacket, &neighbors{Expiration: futureExp})
+	test.packetIn(errUnknownNode, enrRequestPacket, &enrRequest{Expiration: futureExp})
 }
 
 func TestUDP_pingTimeout(t *testing.T) {
@@ -332,6 +335,115 @@ func TestUDP_findnodeMultiReply(t *testing.T) {
 	}
 }
 
+func TestUDP_ENRRequest(t *testing.T) {
+	test := newUDPTest(t)
+	defer test.table.Close()
+
+	// the record is sent after the endpoint proof only
+	remoteID := encodePubkey(&test.remotekey.PublicKey).id()
+	test.table.db.UpdateLastPongReceived(remoteID, time.Now())
+	test.udp.localNode.Set(enr.WithEntry("qkc", uint(7)))
+
+	enc, hash, _ := encodePacket(test.remotekey, enrRequestPacket, &enrRequest{Expiration: futureExp})
+	if err := test.udp.handlePacket(test.remoteaddr, enc); err != nil {
+		t.Fatalf("enrRequest error: %v", err)
+	}
+	test.waitPacketOut(func(p *enrResponse) {
+		if !bytes.Equal(p.ReplyTok, hash) {
+			t.Errorf("wrong reply token: got %x, want %x", p.ReplyTok, hash)
+		}
+		n, err := enode.New(enode.ValidSchemes, &p.Record)
+		if err != nil {
+			t.Fatalf("invalid record: %v", err)
+		}
+		var value uint
+		if err := n.Load(enr.WithEntry("qkc", &value)); err != nil || value != 7 {
+			t.Errorf("wrong qkc entry: %d, %v", value, err)
+		}
+	})
+}
+
+func TestUDP_requestENR(t *testing.T) {
+	test := newUDPTest(t)
+	defer test.table.Close()
+
+	rid := enode.PubkeyToIDV4(&test.remotekey.PublicKey)
+	test.table.db.UpdateLastPingReceived(rid, time.Now())
+	remote := enode.NewV4(&test.remotekey.PublicKey, test.remoteaddr.IP, 0, test.remoteaddr.Port)
+
+	resultc, errc := make(chan *enode.Node), make(chan error)
+	go func() {
+		n, err := test.udp.requestENR(remote)
+		if err != nil {
+			errc <- err
+		} else {
+			resultc <- n
+		}
+	}()
+	hash, _ := test.waitPacketOut(func(p *enrRequest) {})
+
+	db, _ := enode.OpenDB("")
+	ln := enode.NewLocalNode(db, test.remotekey)
+	ln.Set(enr.WithEntry("qkc", uint(7)))
+	test.packetIn(nil, enrResponsePacket, &enrResponse{ReplyTok: hash, Record: *ln.Node().Record()})
+
+	select {
+	case n := <-resultc:
+		var value uint
+		if n.ID() != rid || n.Load(enr.WithEntry("qkc", &value)) != nil || value != 7 {
+			t.Errorf("wrong record %v", n)
+		}
+	case err := <-errc:
+		t.Errorf("requestENR error: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Error("requestENR did not return within 5 seconds")
+	}
+}
+
+func TestUDP_unhandled(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IP{127, 0, 0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := newkey()
+	db, _ := enode.OpenDB("")
+	unhandled := make(chan ReadPacket, 1)
+	filter := nodefilter.NewBlackList(nil)
+	_, udp, err := newUDP(conn, enode.NewLocalNode(db, key), Config{PrivateKey: key, Unhandled: unhandled, BlackListFilter: filter})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// e.g. a discovery v5 packet
+	remote, err := net.DialUDP("udp", nil, conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer remote.Close()
+	if _, err := remote.Write([]byte("temporary discovery v5")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case packet := <-unhandled:
+		if string(packet.Data) != "temporary discovery v5" {
+			t.Errorf("wrong unhandled packet: %q", packet.Data)
+		}
+		if packet.Addr.String() != remote.LocalAddr().String() {
+			t.Errorf("wrong unhandled packet sender: %v", packet.Addr)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("packet not unhandled within 5 seconds")
+	}
+	if filter.ChkDialoutBlacklist("127.0.0.1") {
+		t.Error("sender of unhandled packet blacklisted")
+	}
+
+	udp.close()
+	if _, ok := <-unhandled; ok {
+		t.Error("unhandled channel not closed")
+	}
+}
+
 func TestUDP_successfulPing(t *testing.T) {
 	test := newUDPTest(t)
 	added := make(chan *node, 1)
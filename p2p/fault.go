@@ -0,0 +1,57 @@
+package p2p
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strconv"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/internal/fault"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// faultRW wraps the MsgReadWriter of a protocol to inject the faults of the
+// p2p rules in the commands sent to the peer, in the binaries built with the
+// faultinject build tag.
+type faultRW struct {
+	MsgReadWriter
+	peerID     enode.ID
+	remoteAddr string
+}
+
+func newFaultRW(rw MsgReadWriter, peerID enode.ID, remoteAddr string) *faultRW {
+	return &faultRW{MsgReadWriter: rw, peerID: peerID, remoteAddr: remoteAddr}
+}
+
+// WriteMsg drops, delays or corrupts the command of the message if a rule
+// matches it.
+func (rw *faultRW) WriteMsg(msg Msg) error {
+	payload, err := ioutil.ReadAll(msg.Payload)
+	if err != nil {
+		return err
+	}
+	msg.Payload = bytes.NewReader(payload)
+	if len(payload) < PreP2PLength {
+		return rw.MsgReadWriter.WriteMsg(msg)
+	}
+	flt := fault.Inject(fault.LayerP2P, commandName(P2PCommandOp(payload[MetadataLength])),
+		rw.peerID.String(), rw.remoteAddr)
+	switch flt.Action {
+	case fault.ActionDrop:
+		return nil
+	case fault.ActionDelay:
+		time.Sleep(flt.Delay)
+	case fault.ActionCorrupt:
+		// keep the op readable, so the data of the command is corrupted
+		msg.Payload = bytes.NewReader(fault.Corrupt(payload, PreP2PLength))
+	}
+	return rw.MsgReadWriter.WriteMsg(msg)
+}
+
+// commandName returns the name of the command, or its number if unknown.
+func commandName(op P2PCommandOp) string {
+	if _, ok := OPSerializerMap[op]; !ok {
+		return strconv.Itoa(int(op))
+	}
+	return op.String()
+}
@@ -0,0 +1,43 @@
+// +build faultinject
+
+package p2p
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/internal/fault"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFaultRW(t *testing.T) {
+	defer fault.Clear()
+	assert.NoError(t, fault.Set([]fault.Rule{
+		{Layer: fault.LayerP2P, Op: "Tip", Action: fault.ActionDrop, Count: 1},
+		{Layer: fault.LayerP2P, Op: "Tip", Action: fault.ActionCorrupt, Count: 1},
+	}))
+	rw1, rw2 := MsgPipe()
+	defer rw1.Close()
+	frw := newFaultRW(rw1, enode.ID{1}, "127.0.0.1:38291")
+	payload, err := Encrypt(Metadata{Branch: 1}, NewTipMsg, 0, []byte{1, 2, 3})
+	assert.NoError(t, err)
+
+	send := func() {
+		go frw.WriteMsg(Msg{Code: 0, Size: uint32(len(payload)), Payload: bytes.NewReader(payload)})
+	}
+	read := func() []byte {
+		msg, err := rw2.ReadMsg()
+		assert.NoError(t, err)
+		data, err := ioutil.ReadAll(msg.Payload)
+		assert.NoError(t, err)
+		return data
+	}
+	// the first tip is dropped, the second corrupted
+	assert.NoError(t, frw.WriteMsg(Msg{Code: 0, Size: uint32(len(payload)), Payload: bytes.NewReader(payload)}))
+	send()
+	assert.Equal(t, fault.Corrupt(payload, PreP2PLength), read())
+	send()
+	assert.Equal(t, payload, read())
+}
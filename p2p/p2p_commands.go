@@ -37,6 +37,10 @@ const (
 	NewRootBlockMsg
 	GetMinorBlockHeaderListWithSkipRequestMsg
 	GetMinorBlockHeaderListWithSkipResponseMsg
+	NewTransactionHashesMsg
+	GetTransactionListRequestMsg
+	GetTransactionListResponseMsg
+	NewCompactBlockMinorMsg
 	MaxOPNum
 )
 
@@ -63,6 +67,10 @@ var OPSerializerMap = map[P2PCommandOp]interface{}{
 	NewRootBlockMsg:                            NewRootBlockCommand{},
 	GetMinorBlockHeaderListWithSkipRequestMsg:  GetMinorBlockHeaderListWithSkipRequest{},
 	GetMinorBlockHeaderListWithSkipResponseMsg: GetMinorBlockHeaderListResponse{},
+	NewTransactionHashesMsg:                    NewTransactionHashes{},
+	GetTransactionListRequestMsg:               GetTransactionListRequest{},
+	GetTransactionListResponseMsg:              GetTransactionListResponse{},
+	NewCompactBlockMinorMsg:                    NewCompactBlockMinor{},
 }
 
 func (p P2PCommandOp) String() string {
@@ -112,6 +120,41 @@ type NewTransactionList struct {
 	TransactionList []*types.Transaction `bytesizeofslicelen:"4"`
 }
 
+// NewTransactionHashes announces the hashes of new transactions, which are
+// retrieved with GetTransactionListRequest.
+type NewTransactionHashes struct {
+	TransactionHashList []common.Hash `bytesizeofslicelen:"4"`
+}
+
+// GetTransactionListRequest requests the announced transactions of the hashes.
+type GetTransactionListRequest struct {
+	TransactionHashList []common.Hash `bytesizeofslicelen:"4"`
+}
+
+// GetTransactionListResponse returns the requested transactions the peer still
+// has, in the order of the request.
+type GetTransactionListResponse struct {
+	TransactionList []*types.Transaction `bytesizeofslicelen:"4"`
+}
+
+// PrefilledTransaction is a transaction sent along with a compact block, at
+// its index in the block.
+type PrefilledTransaction struct {
+	Index uint32
+	Tx    *types.Transaction
+}
+
+// NewCompactBlockMinor propagates a minor block with the short IDs of its
+// transactions, from which the peer rebuilds the block with the transactions
+// it has. The transactions the peer is not known to have are prefilled.
+type NewCompactBlockMinor struct {
+	Header       *types.MinorBlockHeader
+	Meta         *types.MinorBlockMeta
+	ShortIDs     []uint64                `bytesizeofslicelen:"4"` // of the transactions not prefilled, in block order
+	PrefilledTxs []*PrefilledTransaction `bytesizeofslicelen:"4"`
+	TrackingData []byte                  `bytesizeofslicelen:"2"`
+}
+
 // GetPeerListRequest get peer list request
 type GetPeerListRequest struct {
 	MaxPeers uint32
@@ -25,6 +25,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/QuarkChain/goquarkchain/internal/fault"
 	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
@@ -38,11 +39,14 @@ var (
 )
 
 const (
-	baseProtocolVersion    = 5
+	baseProtocolVersion    = 6
 	baseProtocolLength     = uint64(16)
 	baseProtocolMaxMsgSize = 2 * 1024
 
 	snappyProtocolVersion = 5
+	// since which each QKC message tells whether it is compressed, the ones
+	// snappy doesn't shrink being sent as is
+	snappyFlagProtocolVersion = 6
 
 	pingInterval = 15 * time.Second
 )
@@ -355,6 +359,9 @@ func (p *Peer) startProtocols(writeStart <-chan struct{}, writeErr chan<- error)
 		if p.events != nil {
 			rw = newMsgEventer(rw, p.events, p.ID(), proto.Name)
 		}
+		if fault.Enabled {
+			rw = newFaultRW(rw, p.ID(), p.RemoteAddr().String())
+		}
 		p.log.Trace(fmt.Sprintf("Starting protocol %s/%d", proto.Name, proto.Version))
 		go func() {
 			err := proto.Run(p, rw)
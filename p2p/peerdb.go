@@ -0,0 +1,209 @@
+package p2p
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+const (
+	// goodSessionDuration is how long a peer must stay connected for its
+	// session to raise its score.
+	goodSessionDuration = 10 * time.Minute
+
+	// misbehaviorPenalty lowers the score of a peer breaking the protocol,
+	// blacklistPenalty that of a peer failing the handshake.
+	misbehaviorPenalty = 1
+	blacklistPenalty   = 10
+
+	// peers with a score down to banScore are banned for reputationBanDuration.
+	banScore              = -10
+	reputationBanDuration = 24 * time.Hour
+
+	// goodPeerMaxAge is how long ago a peer may have been seen to be dialed
+	// first on startup.
+	goodPeerMaxAge = 7 * 24 * time.Hour
+
+	// banForever is the ban expiry of the peers banned until they are unbanned.
+	banForever int64 = math.MaxInt64
+)
+
+var peerDBPrefix = []byte("peer:")
+
+// peerRecord is the reputation of a peer.
+type peerRecord struct {
+	Node      string `json:"node"`      // enode URL to dial the peer again
+	Score     int64  `json:"score"`     // good sessions minus penalties
+	BanExpiry int64  `json:"banExpiry"` // unix time the ban ends, 0 if not banned
+	LastSeen  int64  `json:"lastSeen"`  // unix time the peer was last connected
+}
+
+// The reasons of the bans of the nodes.
+const (
+	BanReasonAdmin      = "admin"      // banned or unbanned through the admin API
+	BanReasonReputation = "reputation" // banned for a score down to banScore
+)
+
+// PeerBanEvent is posted when a node is banned or unbanned.
+type PeerBanEvent struct {
+	ID     enode.ID
+	Banned bool
+	Until  time.Time // zero if banned until it is unbanned
+	Reason string
+	Score  int64 // reputation of the node banned for its reputation
+}
+
+func (r *peerRecord) banned(now time.Time) bool {
+	return r.BanExpiry > now.Unix()
+}
+
+// peerDB persists the reputation of the peers across restarts, so that known
+// bad peers stay banned and good ones are dialed first. A nil peerDB keeps
+// nothing.
+type peerDB struct {
+	lvl *leveldb.DB
+}
+
+// openPeerDB opens the peer database at path, or an in-memory one if path is
+// empty.
+func openPeerDB(path string) (*peerDB, error) {
+	var (
+		lvl *leveldb.DB
+		err error
+	)
+	if path == "" {
+		lvl, err = leveldb.Open(storage.NewMemStorage(), nil)
+	} else {
+		lvl, err = leveldb.OpenFile(path, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &peerDB{lvl: lvl}, nil
+}
+
+func peerKey(id enode.ID) []byte {
+	return append(append([]byte{}, peerDBPrefix...), id[:]...)
+}
+
+// get returns the reputation of a peer, empty if it is unknown.
+func (db *peerDB) get(id enode.ID) *peerRecord {
+	rec := new(peerRecord)
+	if db == nil {
+		return rec
+	}
+	blob, err := db.lvl.Get(peerKey(id), nil)
+	if err != nil {
+		return rec
+	}
+	if err := json.Unmarshal(blob, rec); err != nil {
+		return new(peerRecord)
+	}
+	return rec
+}
+
+func (db *peerDB) put(id enode.ID, rec *peerRecord) error {
+	if db == nil {
+		return nil
+	}
+	blob, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return db.lvl.Put(peerKey(id), blob, nil)
+}
+
+// update changes the reputation of a peer with fn and stores it.
+func (db *peerDB) update(id enode.ID, fn func(*peerRecord)) (*peerRecord, error) {
+	rec := db.get(id)
+	fn(rec)
+	return rec, db.put(id, rec)
+}
+
+// iterate calls fn with all the peers of the database.
+func (db *peerDB) iterate(fn func(id enode.ID, rec *peerRecord)) {
+	if db == nil {
+		return
+	}
+	it := db.lvl.NewIterator(util.BytesPrefix(peerDBPrefix), nil)
+	defer it.Release()
+	for it.Next() {
+		var (
+			id  enode.ID
+			rec peerRecord
+		)
+		if len(it.Key()) != len(peerDBPrefix)+len(id) || json.Unmarshal(it.Value(), &rec) != nil {
+			continue
+		}
+		copy(id[:], it.Key()[len(peerDBPrefix):])
+		fn(id, &rec)
+	}
+}
+
+// bans returns the peers banned at now with the unix time their ban ends.
+func (db *peerDB) bans(now time.Time) map[enode.ID]int64 {
+	bans := make(map[enode.ID]int64)
+	db.iterate(func(id enode.ID, rec *peerRecord) {
+		if rec.banned(now) {
+			bans[id] = rec.BanExpiry
+		}
+	})
+	return bans
+}
+
+// best returns up to n peers with a positive score seen within goodPeerMaxAge
+// of now and not banned, the best ones first.
+func (db *peerDB) best(n int, now time.Time) []*enode.Node {
+	type candidate struct {
+		node  *enode.Node
+		score int64
+	}
+	var candidates []candidate
+	db.iterate(func(id enode.ID, rec *peerRecord) {
+		if rec.Score <= 0 || rec.banned(now) || now.Sub(time.Unix(rec.LastSeen, 0)) > goodPeerMaxAge {
+			return
+		}
+		node, err := enode.ParseV4(rec.Node)
+		if err != nil || node.ID() != id {
+			return
+		}
+		candidates = append(candidates, candidate{node, rec.Score})
+	})
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].score > candidates[j].score })
+	nodes := make([]*enode.Node, 0, n)
+	for i := 0; i < len(candidates) && i < n; i++ {
+		nodes = append(nodes, candidates[i].node)
+	}
+	return nodes
+}
+
+// misbehaved reports whether a session ended because the peer broke the
+// protocol, rather than on a network error, on the close of the transport or on
+// the request of either side.
+func misbehaved(pd peerDrop) bool {
+	if pd.requested {
+		return false
+	}
+	switch err := pd.err.(type) {
+	case DiscReason:
+		return err == DiscProtocolError
+	case net.Error:
+		return false
+	}
+	return pd.err != io.EOF && pd.err != errProtocolReturned && pd.err != errTransportClosed
+}
+
+func (db *peerDB) close() {
+	if db == nil {
+		return
+	}
+	db.lvl.Close()
+}
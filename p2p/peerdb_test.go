@@ -0,0 +1,137 @@
+package p2p
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+)
+
+func TestPeerDB(t *testing.T) {
+	db, err := openPeerDB("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.close()
+	now := time.Now()
+
+	nodes := make([]*enode.Node, 4)
+	for i := range nodes {
+		key, _ := crypto.GenerateKey()
+		nodes[i] = enode.NewV4(&key.PublicKey, net.IP{10, 0, 0, byte(i)}, 30303, 30303)
+	}
+	update := func(n *enode.Node, score int64, banExpiry int64, lastSeen time.Time) {
+		if _, err := db.update(n.ID(), func(rec *peerRecord) {
+			rec.Node = n.String()
+			rec.Score = score
+			rec.BanExpiry = banExpiry
+			rec.LastSeen = lastSeen.Unix()
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	update(nodes[0], 2, 0, now)
+	update(nodes[1], 5, 0, now.Add(-time.Hour))
+	update(nodes[2], 9, 0, now.Add(-goodPeerMaxAge-time.Hour))
+	update(nodes[3], 7, now.Add(time.Hour).Unix(), now)
+
+	if rec := db.get(nodes[1].ID()); rec.Score != 5 || rec.Node != nodes[1].String() {
+		t.Errorf("wrong record %+v", rec)
+	}
+	if rec := db.get(randomID()); *rec != (peerRecord{}) {
+		t.Errorf("unknown peer has record %+v", rec)
+	}
+
+	// too old or banned peers are not dialed first
+	best := db.best(3, now)
+	if len(best) != 2 || best[0].ID() != nodes[1].ID() || best[1].ID() != nodes[0].ID() {
+		t.Errorf("wrong best peers %v", best)
+	}
+	if best := db.best(1, now); len(best) != 1 || best[0].ID() != nodes[1].ID() {
+		t.Errorf("wrong best peer %v", best)
+	}
+
+	bans := db.bans(now)
+	if len(bans) != 1 || bans[nodes[3].ID()] != now.Add(time.Hour).Unix() {
+		t.Errorf("wrong bans %v", bans)
+	}
+	if bans := db.bans(now.Add(2 * time.Hour)); len(bans) != 0 {
+		t.Errorf("expired bans %v", bans)
+	}
+}
+
+func TestMisbehaved(t *testing.T) {
+	tests := []struct {
+		pd   peerDrop
+		want bool
+	}{
+		{peerDrop{err: DiscProtocolError, requested: true}, false},
+		{peerDrop{err: DiscProtocolError}, true},
+		{peerDrop{err: DiscQuitting}, false},
+		{peerDrop{err: io.EOF}, false},
+		{peerDrop{err: &net.OpError{Op: "read", Err: os.ErrClosed}}, false},
+		{peerDrop{err: errProtocolReturned}, false},
+		{peerDrop{err: errTransportClosed}, false},
+		{peerDrop{err: errors.New("invalid message")}, true},
+	}
+	for _, test := range tests {
+		if got := misbehaved(test.pd); got != test.want {
+			t.Errorf("misbehaved(%v) = %v, want %v", test.pd.err, got, test.want)
+		}
+	}
+}
+
+func TestServerReputationPersists(t *testing.T) {
+	dir, err := ioutil.TempDir("", "peerdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key := newkey()
+	newServer := func() *Server {
+		srv := &Server{
+			Config: Config{
+				PrivateKey:   newkey(),
+				MaxPeers:     10,
+				NoDial:       true,
+				PeerDatabase: filepath.Join(dir, "peers"),
+			},
+		}
+		if err := srv.Start(); err != nil {
+			t.Fatalf("could not start: %v", err)
+		}
+		return srv
+	}
+	newconn := func(srv *Server, id enode.ID) error {
+		fd, _ := net.Pipe()
+		tx := newTestTransport(&key.PublicKey, fd)
+		node := enode.SignNull(new(enr.Record), id)
+		return srv.checkpoint(&conn{fd: fd, transport: tx, flags: inboundConn, node: node, cont: make(chan error)}, srv.posthandshake)
+	}
+
+	bannedID, unbannedID := randomID(), randomID()
+	srv := newServer()
+	srv.BanPeer(newNode(bannedID, nil))
+	srv.BanPeer(newNode(unbannedID, nil))
+	srv.UnbanPeer(newNode(unbannedID, nil))
+	srv.Stop()
+
+	// the ban outlives the restart
+	srv = newServer()
+	defer srv.Stop()
+	if err := newconn(srv, bannedID); err != DiscUselessPeer {
+		t.Error("wrong error for banned conn:", err)
+	}
+	if err := newconn(srv, unbannedID); err != nil {
+		t.Error("unexpected error for unbanned conn:", err)
+	}
+}
@@ -19,6 +19,7 @@ package p2p
 import (
 	"fmt"
 
+	"github.com/ethereum/go-ethereum/p2p/discv5"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/enr"
 )
@@ -56,6 +57,17 @@ type Protocol struct {
 
 	// Attributes contains protocol specific information for the node record.
 	Attributes []enr.Entry
+
+	// DialFilter is an optional function reporting whether a node found by
+	// discovery is worth dialing for the protocol, given its record. Nodes
+	// rejected by the filter of any protocol are not dialed, though they may
+	// still connect to the host node.
+	DialFilter func(n *enode.Node) bool
+
+	// Topics are the discovery V5 topics the host node registers itself
+	// under, and the nodes registered under which are dialed, if discovery V5
+	// is enabled.
+	Topics []discv5.Topic
 }
 
 func (p Protocol) cap() Cap {
@@ -9,6 +9,7 @@ import (
 	"encoding/binary"
 	"encoding/hex"
 	"errors"
+	"github.com/QuarkChain/goquarkchain/cluster/config"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/golang/snappy"
 	"io"
@@ -20,6 +21,15 @@ import (
 
 var (
 	msgHandleLog = "qkcMsgHandle"
+
+	errQKCMessageTooLarge = errors.New("message length exceeds the command size limit")
+	errUnknownSnappyFlag  = errors.New("unknown message compression flag")
+)
+
+// compression flags of the QKC messages, if negotiated
+const (
+	msgUncompressed byte = iota
+	msgSnappy
 )
 
 func GetPrivateKeyFromConfig(configKey string) (*ecdsa.PrivateKey, error) {
@@ -113,16 +123,26 @@ func (q *qkcRlp) readQKCMsg() (msg Msg, err error) {
 		if err != nil {
 			return msg, err
 		}
-		size, err := snappy.DecodedLen(payload)
-		if err != nil {
-			return msg, err
+		compressed := true
+		if q.rw.snappyFlag {
+			if len(payload) == 0 || payload[0] > msgSnappy {
+				return msg, errUnknownSnappyFlag
+			}
+			compressed, payload = payload[0] == msgSnappy, payload[1:]
 		}
-		if size > int(maxUint24) {
-			return msg, errPlainMessageTooLarge
+		size := len(payload)
+		if compressed {
+			if size, err = snappy.DecodedLen(payload); err != nil {
+				return msg, err
+			}
 		}
-		payload, err = snappy.Decode(nil, payload)
-		if err != nil {
-			return msg, err
+		if size > int(config.DefaultP2PCmddSizeLimit) {
+			return msg, errQKCMessageTooLarge
+		}
+		if compressed {
+			if payload, err = snappy.Decode(nil, payload); err != nil {
+				return msg, err
+			}
 		}
 		msg.Size, msg.Payload = uint32(size), bytes.NewReader(payload)
 	}
@@ -133,11 +153,22 @@ func (q *qkcRlp) readQKCMsg() (msg Msg, err error) {
 func (q *qkcRlp) writeQKCMsg(msg Msg) error {
 	// if snappy is enabled, compress message now
 	if q.rw.snappy {
-		if msg.Size > maxUint24 {
-			return errPlainMessageTooLarge
+		if msg.Size > config.DefaultP2PCmddSizeLimit {
+			return errQKCMessageTooLarge
 		}
 		payload, _ := ioutil.ReadAll(msg.Payload)
-		payload = snappy.Encode(nil, payload)
+		compressed := snappy.Encode(nil, payload)
+		if q.rw.snappyFlag {
+			// the messages snappy doesn't shrink, e.g. of hashes and
+			// signatures, are sent as is
+			if len(compressed) < len(payload) {
+				payload = append([]byte{msgSnappy}, compressed...)
+			} else {
+				payload = append([]byte{msgUncompressed}, payload...)
+			}
+		} else {
+			payload = compressed
+		}
 
 		msg.Payload = bytes.NewReader(payload)
 		msg.Size = uint32(len(payload))
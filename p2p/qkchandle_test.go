@@ -0,0 +1,127 @@
+package p2p
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/simulations/pipes"
+)
+
+// countingConn counts the bytes written to the connection.
+type countingConn struct {
+	net.Conn
+	written int64
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddInt64(&c.written, int64(n))
+	return n, err
+}
+
+func TestQKCRlpCompression(t *testing.T) {
+	tests := []struct {
+		dialVersion, listenVersion uint64
+		snappy, snappyFlag         bool
+	}{
+		{baseProtocolVersion, baseProtocolVersion, true, true},
+		{baseProtocolVersion, snappyProtocolVersion, true, false},
+		{snappyProtocolVersion, baseProtocolVersion, true, false},
+		{snappyProtocolVersion, snappyProtocolVersion, true, false},
+		{baseProtocolVersion, snappyProtocolVersion - 1, false, false},
+		{snappyProtocolVersion - 1, baseProtocolVersion, false, false},
+	}
+	payload := bytes.Repeat([]byte("quarkchain"), 10000)
+	random := make([]byte, 10000)
+	rand.Read(random)
+	for _, test := range tests {
+		fd0, fd1, err := pipes.TCPPipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var (
+			prv0, _ = crypto.GenerateKey()
+			prv1, _ = crypto.GenerateKey()
+			conn0   = &countingConn{Conn: fd0}
+			q0      = NewQKCRlp(conn0).(*qkcRlp)
+			q1      = NewQKCRlp(fd1).(*qkcRlp)
+			wg      sync.WaitGroup
+		)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := q0.doEncHandshake(prv0, &prv1.PublicKey); err != nil {
+				t.Errorf("dial side enc handshake failed: %v", err)
+				return
+			}
+			hs := &protoHandshake{Version: test.dialVersion, ID: crypto.FromECDSAPub(&prv0.PublicKey)[1:]}
+			if _, err := q0.doProtoHandshake(hs); err != nil {
+				t.Errorf("dial side proto handshake error: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := q1.doEncHandshake(prv1, nil); err != nil {
+				t.Errorf("listen side enc handshake failed: %v", err)
+				return
+			}
+			hs := &protoHandshake{Version: test.listenVersion, ID: crypto.FromECDSAPub(&prv1.PublicKey)[1:]}
+			if _, err := q1.doProtoHandshake(hs); err != nil {
+				t.Errorf("listen side proto handshake error: %v", err)
+			}
+		}()
+		wg.Wait()
+		if t.Failed() {
+			return
+		}
+		if q0.rw.snappy != test.snappy || q1.rw.snappy != test.snappy {
+			t.Errorf("versions %d/%d: snappy %v/%v, want %v", test.dialVersion, test.listenVersion,
+				q0.rw.snappy, q1.rw.snappy, test.snappy)
+		}
+		if q0.rw.snappyFlag != test.snappyFlag || q1.rw.snappyFlag != test.snappyFlag {
+			t.Errorf("versions %d/%d: snappy flag %v/%v, want %v", test.dialVersion, test.listenVersion,
+				q0.rw.snappyFlag, q1.rw.snappyFlag, test.snappyFlag)
+		}
+
+		// sends the payload, returning the bytes written
+		send := func(payload []byte) int64 {
+			written := atomic.LoadInt64(&conn0.written)
+			errc := make(chan error, 1)
+			go func() {
+				errc <- q0.WriteMsg(Msg{Size: uint32(len(payload)), Payload: bytes.NewReader(payload)})
+			}()
+			msg, err := q1.ReadMsg()
+			if err != nil {
+				t.Fatalf("read error: %v", err)
+			}
+			if err := <-errc; err != nil {
+				t.Fatalf("write error: %v", err)
+			}
+			content, _ := ioutil.ReadAll(msg.Payload)
+			if !bytes.Equal(content, payload) || msg.Size != uint32(len(payload)) {
+				t.Errorf("versions %d/%d: payload mismatch", test.dialVersion, test.listenVersion)
+			}
+			// without the header and the MAC of the frame
+			return atomic.LoadInt64(&conn0.written) - written - 48
+		}
+		sent := send(payload)
+		if compressed := sent < int64(len(payload)); compressed != test.snappy {
+			t.Errorf("versions %d/%d: %d bytes sent for a payload of %d", test.dialVersion, test.listenVersion,
+				sent, len(payload))
+		}
+		// only the compression flag is added to an incompressible payload
+		sent = send(random)
+		if flagged := sent == int64(len(random))+1; flagged != test.snappyFlag {
+			t.Errorf("versions %d/%d: %d bytes sent for a random payload of %d", test.dialVersion, test.listenVersion,
+				sent, len(random))
+		}
+		fd0.Close()
+		fd1.Close()
+	}
+}
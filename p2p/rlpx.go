@@ -81,6 +81,7 @@ type rlpx struct {
 
 	rmu, wmu sync.Mutex
 	rw       *rlpxFrameRW
+	remoteHS encFormat // format of the remote encryption handshake message
 }
 
 func newRLPX(fd net.Conn) transport {
@@ -135,8 +136,10 @@ func (t *rlpx) doProtoHandshake(our *protoHandshake) (their *protoHandshake, err
 	if err := <-werr; err != nil {
 		return nil, fmt.Errorf("write error: %v", err)
 	}
-	// If the protocol version supports Snappy encoding, upgrade immediately
-	t.rw.snappy = their.Version >= snappyProtocolVersion
+	// If both protocol versions support Snappy encoding, upgrade immediately.
+	// Checking ours too keeps both ends agreeing when talking to older peers.
+	t.rw.snappy = our.Version >= snappyProtocolVersion && their.Version >= snappyProtocolVersion
+	t.rw.snappyFlag = our.Version >= snappyFlagProtocolVersion && their.Version >= snappyFlagProtocolVersion
 
 	return their, nil
 }
@@ -190,10 +193,19 @@ func (t *rlpx) doEncHandshake(prv *ecdsa.PrivateKey, dial *ecdsa.PublicKey) (*ec
 	}
 	t.wmu.Lock()
 	t.rw = newRLPXFrameRW(t.fd, sec)
+	t.remoteHS = sec.RemoteFormat
 	t.wmu.Unlock()
 	return sec.Remote.ExportECDSA(), nil
 }
 
+// encFormat returns the format of the encryption handshake message of the
+// remote side, valid after doEncHandshake.
+func (t *rlpx) encFormat() encFormat {
+	t.wmu.Lock()
+	defer t.wmu.Unlock()
+	return t.remoteHS
+}
+
 // encHandshake contains the state of the encryption handshake.
 type encHandshake struct {
 	initiator            bool
@@ -210,6 +222,13 @@ type secrets struct {
 	AES, MAC              []byte
 	EgressMAC, IngressMAC hash.Hash
 	Token                 []byte
+	RemoteFormat          encFormat
+}
+
+// encFormat is the format of an encryption handshake message.
+type encFormat struct {
+	Version uint // version announced in the message
+	Legacy  bool // pre-EIP-8 plain format
 }
 
 // RLPx v4 handshake auth (defined in EIP-8).
@@ -227,6 +246,8 @@ type authMsgV4 struct {
 
 // RLPx v4 handshake response (defined in EIP-8).
 type authRespV4 struct {
+	gotPlain bool // whether read packet had plain format.
+
 	RandomPubkey [pubLen]byte
 	Nonce        [shaLen]byte
 	Version      uint
@@ -300,7 +321,11 @@ func initiatorEncHandshake(conn io.ReadWriter, prv *ecdsa.PrivateKey, remote *ec
 	if err := h.handleAuthResp(authRespMsg); err != nil {
 		return s, err
 	}
-	return h.secrets(authPacket, authRespPacket)
+	if s, err = h.secrets(authPacket, authRespPacket); err != nil {
+		return s, err
+	}
+	s.RemoteFormat = encFormat{Version: authRespMsg.Version, Legacy: authRespMsg.gotPlain}
+	return s, nil
 }
 
 // makeAuthMsg creates the initiator handshake message.
@@ -373,7 +398,11 @@ func receiverEncHandshake(conn io.ReadWriter, prv *ecdsa.PrivateKey) (s secrets,
 	if _, err = conn.Write(authRespPacket); err != nil {
 		return s, err
 	}
-	return h.secrets(authPacket, authRespPacket)
+	if s, err = h.secrets(authPacket, authRespPacket); err != nil {
+		return s, err
+	}
+	s.RemoteFormat = encFormat{Version: authMsg.Version, Legacy: authMsg.gotPlain}
+	return s, nil
 }
 
 func (h *encHandshake) handleAuthMsg(msg *authMsgV4, prv *ecdsa.PrivateKey) error {
@@ -452,6 +481,7 @@ func (msg *authRespV4) decodePlain(input []byte) {
 	n := copy(msg.RandomPubkey[:], input)
 	copy(msg.Nonce[:], input[n:])
 	msg.Version = 4
+	msg.gotPlain = true
 }
 
 var padSpace = make([]byte, 300)
@@ -564,7 +594,8 @@ type rlpxFrameRW struct {
 	egressMAC  hash.Hash
 	ingressMAC hash.Hash
 
-	snappy bool
+	snappy     bool
+	snappyFlag bool // the QKC messages are preceded by their compression flag
 }
 
 func newRLPXFrameRW(conn io.ReadWriter, s secrets) *rlpxFrameRW {
@@ -440,6 +440,7 @@ var eip8HandshakeAuthTests = []handshakeAuthTest{
 
 type handshakeAckTest struct {
 	input       string
+	isPlain     bool
 	wantVersion uint
 	wantRest    []rlp.RawValue
 }
@@ -455,6 +456,7 @@ var eip8HandshakeRespTests = []handshakeAckTest{
 			dca6505b7196532e5f85b259a20c45e1979491683fee108e9660edbf38f3add489ae73e3dda2c71b
 			d1497113d5c755e942d1
 		`,
+		isPlain:     true,
 		wantVersion: 4,
 	},
 	// (Ack₂) EIP-8 encoding
@@ -523,7 +525,7 @@ func TestHandshakeForwardCompatibility(t *testing.T) {
 		return msg
 	}
 	makeAck := func(test handshakeAckTest) *authRespV4 {
-		msg := &authRespV4{Version: test.wantVersion, Rest: test.wantRest}
+		msg := &authRespV4{Version: test.wantVersion, Rest: test.wantRest, gotPlain: test.isPlain}
 		copy(msg.RandomPubkey[:], ephPubB)
 		copy(msg.Nonce[:], nonceB)
 		return msg
@@ -114,6 +114,30 @@ type Config struct {
 	// allowed to connect, even above the peer limit.
 	TrustedNodes []*enode.Node
 
+	// AllowlistOnly refuses the connections of all nodes but the static and
+	// trusted ones, which are the only nodes dialed.
+	AllowlistOnly bool
+
+	// MaxUploadRate and MaxDownloadRate limit the bytes per second sent to and
+	// received from all the peers together, PeerMaxUploadRate and
+	// PeerMaxDownloadRate those of each peer. Zero means unlimited.
+	MaxUploadRate       int `toml:",omitempty"`
+	MaxDownloadRate     int `toml:",omitempty"`
+	PeerMaxUploadRate   int `toml:",omitempty"`
+	PeerMaxDownloadRate int `toml:",omitempty"`
+
+	// HandshakeAuditLog is the path of the file the parameters of each peer
+	// handshake are appended to as JSON lines, and logged. Empty disables it.
+	HandshakeAuditLog string `toml:",omitempty"`
+
+	// The peers whose handshake is below these thresholds are refused:
+	// MinProtocolVersion is the lowest devp2p base protocol version allowed,
+	// RequireEIP8 refuses the pre-EIP-8 encryption handshake and MinCaps
+	// lists the protocols the peers must offer, at least at their version.
+	MinProtocolVersion uint64 `toml:",omitempty"`
+	RequireEIP8        bool   `toml:",omitempty"`
+	MinCaps            []Cap  `toml:",omitempty"`
+
 	// Connectivity can be restricted to certain IP networks.
 	// If this option is set to a non-nil value, only hosts which match one of the
 	// IP networks contained in the list are considered.
@@ -123,6 +147,10 @@ type Config struct {
 	// live nodes in the network.
 	NodeDatabase string `toml:",omitempty"`
 
+	// PeerDatabase is the path to the database containing the reputation of
+	// the peers: their score, ban and when they were last seen.
+	PeerDatabase string `toml:",omitempty"`
+
 	// Protocols should contain the protocols supported
 	// by the server. Matching protocols are launched for
 	// each peer.
@@ -170,6 +198,8 @@ type Server struct {
 	running bool
 
 	nodedb       *enode.DB
+	peerdb       *peerDB
+	auditLog     *handshakeAuditLog
 	localnode    *enode.LocalNode
 	ntab         discoverTable
 	listener     net.Listener
@@ -183,16 +213,24 @@ type Server struct {
 
 	blackNodeFilter nodefilter.BlackFilter
 
+	// shared by all the peers, nil if unlimited
+	uploadLimiter   *rateLimiter
+	downloadLimiter *rateLimiter
+
 	quit          chan struct{}
 	addstatic     chan *enode.Node
 	removestatic  chan *enode.Node
 	addtrusted    chan *enode.Node
 	removetrusted chan *enode.Node
+	banpeer       chan *enode.Node
+	unbanpeer     chan *enode.Node
+	topicnode     chan *enode.Node // found by the discovery V5 topic searches
 	posthandshake chan *conn
 	addpeer       chan *conn
 	delpeer       chan peerDrop
 	loopWG        sync.WaitGroup // loop, listenLoop
 	peerFeed      event.Feed
+	banFeed       event.Feed
 	log           log.Logger
 }
 
@@ -352,11 +390,44 @@ func (srv *Server) RemoveTrustedPeer(node *enode.Node) {
 	}
 }
 
+// BanPeer disconnects the given node and refuses its connections until it is
+// unbanned. It is removed from the static nodes so that it is no longer dialed.
+func (srv *Server) BanPeer(node *enode.Node) {
+	select {
+	case srv.banpeer <- node:
+	case <-srv.quit:
+	}
+}
+
+// UnbanPeer allows the given node to connect again.
+func (srv *Server) UnbanPeer(node *enode.Node) {
+	select {
+	case srv.unbanpeer <- node:
+	case <-srv.quit:
+	}
+}
+
+// updateReputation changes the reputation of a peer with fn and returns it,
+// or nil if it can't be stored.
+func (srv *Server) updateReputation(id enode.ID, fn func(*peerRecord)) *peerRecord {
+	rec, err := srv.peerdb.update(id, fn)
+	if err != nil {
+		srv.log.Warn("Failed to store peer reputation", "id", id, "err", err)
+		return nil
+	}
+	return rec
+}
+
 // SubscribePeers subscribes the given channel to peer events
 func (srv *Server) SubscribeEvents(ch chan *PeerEvent) event.Subscription {
 	return srv.peerFeed.Subscribe(ch)
 }
 
+// SubscribeBans subscribes the given channel to the bans and unbans of nodes.
+func (srv *Server) SubscribeBans(ch chan<- *PeerBanEvent) event.Subscription {
+	return srv.banFeed.Subscribe(ch)
+}
+
 // Self returns the local node's endpoint information.
 func (srv *Server) Self() *enode.Node {
 	srv.lock.Lock()
@@ -449,13 +520,24 @@ func (srv *Server) Start() (err error) {
 	srv.removestatic = make(chan *enode.Node)
 	srv.addtrusted = make(chan *enode.Node)
 	srv.removetrusted = make(chan *enode.Node)
+	srv.banpeer = make(chan *enode.Node)
+	srv.unbanpeer = make(chan *enode.Node)
+	srv.topicnode = make(chan *enode.Node)
 	srv.peerOp = make(chan peerOpFunc)
 	srv.peerOpDone = make(chan struct{})
 	srv.blackNodeFilter = nodefilter.NewBlackList(srv.WhitelistNodes)
+	srv.uploadLimiter = newRateLimiter(srv.MaxUploadRate)
+	srv.downloadLimiter = newRateLimiter(srv.MaxDownloadRate)
 
 	if err := srv.setupLocalNode(); err != nil {
 		return err
 	}
+	if srv.peerdb, err = openPeerDB(srv.PeerDatabase); err != nil {
+		return err
+	}
+	if srv.auditLog, err = openHandshakeAuditLog(srv.HandshakeAuditLog); err != nil {
+		return err
+	}
 	if srv.ListenAddr != "" {
 		if err := srv.setupListening(); err != nil {
 			return err
@@ -464,9 +546,14 @@ func (srv *Server) Start() (err error) {
 	if err := srv.setupDiscovery(); err != nil {
 		return err
 	}
+	if srv.DiscV5 != nil {
+		srv.discoverTopics()
+	}
 
 	dynPeers := srv.maxDialedConns()
 	dialer := newDialState(srv.localnode.ID(), srv.StaticNodes, srv.BootstrapNodes, srv.ntab, dynPeers, srv.NetRestrict)
+	// dial the peers that were good in the past before the ones discovered
+	dialer.lookupBuf = srv.peerdb.best(dynPeers, time.Now())
 	srv.loopWG.Add(1)
 	go srv.run(dialer)
 	return nil
@@ -509,9 +596,13 @@ func (srv *Server) setupLocalNode() error {
 		srv.loopWG.Add(1)
 		go func() {
 			defer srv.loopWG.Done()
-			if ip, err := srv.NAT.ExternalIP(); err == nil {
-				srv.localnode.SetStaticIP(ip)
+			ip, err := srv.NAT.ExternalIP()
+			if err != nil {
+				srv.log.Debug("Can't get external IP", "nat", srv.NAT, "err", err)
+				return
 			}
+			srv.log.Info("External IP found", "nat", srv.NAT, "ip", ip)
+			srv.localnode.SetStaticIP(ip)
 		}()
 	}
 	return nil
@@ -534,18 +625,25 @@ func (srv *Server) setupDiscovery() error {
 	srv.log.Debug("UDP listener up", "addr", realaddr)
 	if srv.NAT != nil {
 		if !realaddr.IP.IsLoopback() {
-			go nat.Map(srv.NAT, srv.quit, "udp", realaddr.Port, realaddr.Port, "ethereum discovery")
+			go nat.Map(srv.NAT, srv.quit, "udp", realaddr.Port, realaddr.Port, "quarkchain discovery")
 		}
 	}
 	srv.localnode.SetFallbackUDP(realaddr.Port)
 
 	// Discovery V4
+	var unhandled chan discover.ReadPacket
 	var sconn *sharedUDPConn
 	if !srv.NoDiscovery {
+		if srv.DiscoveryV5 {
+			// the V5 packets are handed over by the V4 listener
+			unhandled = make(chan discover.ReadPacket, 100)
+			sconn = &sharedUDPConn{conn, unhandled}
+		}
 		cfg := discover.Config{
 			PrivateKey:      srv.PrivateKey,
 			NetRestrict:     srv.NetRestrict,
 			Bootnodes:       srv.BootstrapNodes,
+			Unhandled:       unhandled,
 			NetworkId:       srv.NetWorkId,
 			BlackListFilter: srv.blackNodeFilter,
 		}
@@ -593,7 +691,7 @@ func (srv *Server) setupListening() error {
 	if !laddr.IP.IsLoopback() && srv.NAT != nil {
 		srv.loopWG.Add(1)
 		go func() {
-			nat.Map(srv.NAT, srv.quit, "tcp", laddr.Port, laddr.Port, "ethereum p2p")
+			nat.Map(srv.NAT, srv.quit, "tcp", laddr.Port, laddr.Port, "quarkchain p2p")
 			srv.loopWG.Done()
 		}()
 	}
@@ -605,17 +703,22 @@ type dialer interface {
 	taskDone(task, time.Time)
 	addStatic(*enode.Node)
 	removeStatic(*enode.Node)
+	addCandidate(*enode.Node)
 }
 
 func (srv *Server) run(dialstate dialer) {
 	srv.log.Info("Started P2P networking", "self", srv.localnode.Node())
 	defer srv.loopWG.Done()
 	defer srv.nodedb.Close()
+	defer srv.peerdb.close()
+	defer srv.auditLog.close()
 
 	var (
 		peers        = make(map[enode.ID]*Peer)
 		inboundCount = 0
 		trusted      = make(map[enode.ID]bool, len(srv.TrustedNodes))
+		static       = make(map[enode.ID]bool, len(srv.StaticNodes))
+		banned       = srv.peerdb.bans(time.Now()) // node ID to unix time the ban ends
 		taskdone     = make(chan task, maxActiveDialTasks)
 		runningTasks []task
 		queuedTasks  []task // tasks that can't run yet
@@ -628,6 +731,9 @@ func (srv *Server) run(dialstate dialer) {
 	for _, n := range srv.TrustedNodes {
 		trusted[n.ID()] = true
 	}
+	for _, n := range srv.StaticNodes {
+		static[n.ID()] = true
+	}
 
 	// removes t from runningTasks
 	delTask := func(t task) {
@@ -684,12 +790,19 @@ running:
 			// ephemeral static peer list. Add it to the dialer,
 			// it will keep the node connected.
 			srv.log.Trace("Adding static node", "node", n)
+			static[n.ID()] = true
 			dialstate.addStatic(n)
+		case n := <-srv.topicnode:
+			// A node serving a protocol was found by a topic search.
+			// Add it to the dialer as a dynamic dial candidate.
+			srv.log.Trace("Adding dial candidate", "node", n)
+			dialstate.addCandidate(n)
 		case n := <-srv.removestatic:
 			// This channel is used by RemovePeer to send a
 			// disconnect request to a peer and begin the
 			// stop keeping the node connected.
 			srv.log.Trace("Removing static node", "node", n)
+			delete(static, n.ID())
 			dialstate.removeStatic(n)
 			if p, ok := peers[n.ID()]; ok {
 				p.Disconnect(DiscRequested)
@@ -714,6 +827,30 @@ running:
 			if p, ok := peers[n.ID()]; ok {
 				p.rw.set(trustedConn, false)
 			}
+		case n := <-srv.banpeer:
+			// This channel is used by BanPeer to refuse the connections
+			// of an enode, which is no longer dialed either.
+			srv.log.Debug("Banning node", "node", n)
+			banned[n.ID()] = banForever
+			srv.updateReputation(n.ID(), func(rec *peerRecord) { rec.BanExpiry = banForever })
+			srv.banFeed.Send(&PeerBanEvent{ID: n.ID(), Banned: true, Reason: BanReasonAdmin})
+			delete(static, n.ID())
+			dialstate.removeStatic(n)
+			if p, ok := peers[n.ID()]; ok {
+				p.Disconnect(DiscRequested)
+			}
+		case n := <-srv.unbanpeer:
+			// This channel is used by UnbanPeer to allow an enode to
+			// connect again.
+			srv.log.Debug("Unbanning node", "node", n)
+			delete(banned, n.ID())
+			srv.updateReputation(n.ID(), func(rec *peerRecord) {
+				rec.BanExpiry = 0
+				if rec.Score < 0 {
+					rec.Score = 0
+				}
+			})
+			srv.banFeed.Send(&PeerBanEvent{ID: n.ID(), Reason: BanReasonAdmin})
 		case op := <-srv.peerOp:
 			// This channel is used by Peers and PeerCount.
 			op(peers)
@@ -733,8 +870,19 @@ running:
 				c.flags |= trustedConn
 			}
 			// TODO: track in-progress inbound node IDs (pre-Peer) to avoid dialing them.
+			err := srv.encHandshakeChecks(peers, inboundCount, c)
+			if expiry, ok := banned[c.node.ID()]; ok && err == nil {
+				if expiry > time.Now().Unix() {
+					err = DiscUselessPeer
+				} else {
+					delete(banned, c.node.ID())
+				}
+			}
+			if err == nil && srv.AllowlistOnly && !c.is(trustedConn) && !static[c.node.ID()] {
+				err = DiscUselessPeer
+			}
 			select {
-			case c.cont <- srv.encHandshakeChecks(peers, inboundCount, c):
+			case c.cont <- err:
 			case <-srv.quit:
 				break running
 			}
@@ -754,6 +902,13 @@ running:
 				srv.log.Info("Adding p2p peer", "name", name, "addr", c.fd.RemoteAddr(), "peers", len(peers)+1)
 				go srv.runPeer(p)
 				peers[c.node.ID()] = p
+				srv.updateReputation(c.node.ID(), func(rec *peerRecord) {
+					// the port of inbound peers is not their listening port
+					if !p.Inbound() || rec.Node == "" {
+						rec.Node = c.node.String()
+					}
+					rec.LastSeen = time.Now().Unix()
+				})
 				if p.Inbound() {
 					inboundCount++
 				}
@@ -773,6 +928,29 @@ running:
 				srv.blackNodeFilter.AddDialoutBlacklist(pd.Node().IP().String())
 				pd.log.Warn("Add this peer to black list", "peer id", pd.Peer.ID().String(), "remote ip", pd.Node().IP().String(), "err", pd.err)
 			}
+			var reputationBan *PeerBanEvent
+			rec := srv.updateReputation(pd.ID(), func(rec *peerRecord) {
+				if _, ok := (pd.err).(*nodefilter.BlackErr); ok {
+					rec.Score -= blacklistPenalty
+				} else if misbehaved(pd) {
+					rec.Score -= misbehaviorPenalty
+				} else if time.Duration(mclock.Now()-pd.created) >= goodSessionDuration {
+					rec.Score++
+				}
+				if rec.Score <= banScore && !rec.banned(time.Now()) {
+					rec.BanExpiry = time.Now().Add(reputationBanDuration).Unix()
+					pd.log.Warn("Banning peer of bad reputation", "score", rec.Score, "until", time.Unix(rec.BanExpiry, 0))
+					reputationBan = &PeerBanEvent{ID: pd.ID(), Banned: true, Until: time.Unix(rec.BanExpiry, 0),
+						Reason: BanReasonReputation, Score: rec.Score}
+				}
+				rec.LastSeen = time.Now().Unix()
+			})
+			if rec != nil && rec.banned(time.Now()) {
+				banned[pd.ID()] = rec.BanExpiry
+			}
+			if reputationBan != nil {
+				srv.banFeed.Send(reputationBan)
+			}
 			d := common.PrettyDuration(mclock.Now() - pd.created)
 			pd.log.Debug("Removing p2p peer", "duration", d, "peers", len(peers)-1, "req", pd.requested, "err", pd.err)
 			delete(peers, pd.ID())
@@ -834,7 +1012,7 @@ func (srv *Server) maxInboundConns() int {
 	return srv.MaxPeers - srv.maxDialedConns()
 }
 func (srv *Server) maxDialedConns() int {
-	if srv.NoDiscovery || srv.NoDial {
+	if (srv.NoDiscovery && !srv.DiscoveryV5) || srv.NoDial || srv.AllowlistOnly {
 		return 0
 	}
 	r := srv.DialRatio
@@ -901,7 +1079,7 @@ func (srv *Server) listenLoop() {
 // as a peer. It returns when the connection has been added as a peer
 // or the handshakes have failed.
 func (srv *Server) SetupConn(fd net.Conn, flags connFlag, dialDest *enode.Node) error {
-	c := &conn{fd: fd, transport: srv.newTransport(fd), flags: flags, cont: make(chan error)}
+	c := &conn{fd: fd, transport: srv.throttle(srv.newTransport(fd)), flags: flags, cont: make(chan error)}
 	err := srv.setupConn(c, flags, dialDest)
 	if err != nil {
 		c.close(err)
@@ -910,7 +1088,7 @@ func (srv *Server) SetupConn(fd net.Conn, flags connFlag, dialDest *enode.Node)
 	return err
 }
 
-func (srv *Server) setupConn(c *conn, flags connFlag, dialDest *enode.Node) error {
+func (srv *Server) setupConn(c *conn, flags connFlag, dialDest *enode.Node) (err error) {
 	// Prevent leftover pending conns from entering the handshake.
 	srv.lock.Lock()
 	running := srv.running
@@ -942,6 +1120,12 @@ func (srv *Server) setupConn(c *conn, flags connFlag, dialDest *enode.Node) erro
 		c.node = nodeFromConn(remotePubkey, c.fd)
 	}
 	clog := srv.log.New("id", c.node.ID(), "addr", c.fd.RemoteAddr(), "conn", c.flags)
+	audit := newHandshakeAudit(c)
+	defer func() { srv.auditHandshake(audit, err) }()
+	if err = srv.checkEncHandshake(audit); err != nil {
+		clog.Trace("Rejected encryption handshake", "auth", audit.AuthVersion, "legacy", audit.LegacyHandshake)
+		return err
+	}
 	err = srv.checkpoint(c, srv.posthandshake)
 	if err != nil {
 		clog.Trace("Rejected peer before protocol handshake", "err", err)
@@ -957,6 +1141,11 @@ func (srv *Server) setupConn(c *conn, flags connFlag, dialDest *enode.Node) erro
 		clog.Trace("Wrong devp2p handshake identity", "phsid", hex.EncodeToString(phs.ID))
 		return DiscUnexpectedIdentity
 	}
+	audit.setProtoHandshake(srv.ourHandshake, phs)
+	if err = srv.checkProtoHandshake(phs); err != nil {
+		clog.Trace("Rejected protocol handshake", "version", phs.Version, "caps", phs.Caps)
+		return err
+	}
 	c.caps, c.name = phs.Caps, phs.Name
 	err = srv.checkpoint(c, srv.addpeer)
 	if err != nil {
@@ -30,6 +30,7 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/crypto/sha3"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/discv5"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/enr"
 )
@@ -355,6 +356,8 @@ func (tg taskgen) addStatic(*enode.Node) {
 }
 func (tg taskgen) removeStatic(*enode.Node) {
 }
+func (tg taskgen) addCandidate(*enode.Node) {
+}
 
 type testTask struct {
 	index  int
@@ -431,6 +434,157 @@ func TestServerAtCap(t *testing.T) {
 	}
 }
 
+func TestServerAllowlistOnly(t *testing.T) {
+	key := newkey()
+	staticID, trustedID := randomID(), randomID()
+	srv := &Server{
+		Config: Config{
+			PrivateKey:    newkey(),
+			MaxPeers:      10,
+			StaticNodes:   []*enode.Node{newNode(staticID, nil)},
+			TrustedNodes:  []*enode.Node{newNode(trustedID, nil)},
+			AllowlistOnly: true,
+			NoDiscovery:   true,
+		},
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start: %v", err)
+	}
+	defer srv.Stop()
+	if srv.maxDialedConns() != 0 {
+		t.Error("dynamic dials in allowlist mode")
+	}
+
+	newconn := func(id enode.ID) *conn {
+		fd, _ := net.Pipe()
+		tx := newTestTransport(&key.PublicKey, fd)
+		node := enode.SignNull(new(enr.Record), id)
+		return &conn{fd: fd, transport: tx, flags: inboundConn, node: node, cont: make(chan error)}
+	}
+
+	if err := srv.checkpoint(newconn(randomID()), srv.posthandshake); err != DiscUselessPeer {
+		t.Error("wrong error for other conn:", err)
+	}
+	if err := srv.checkpoint(newconn(staticID), srv.posthandshake); err != nil {
+		t.Error("unexpected error for static conn:", err)
+	}
+	if err := srv.checkpoint(newconn(trustedID), srv.posthandshake); err != nil {
+		t.Error("unexpected error for trusted conn:", err)
+	}
+
+	// Nodes added at runtime are allowed until they are removed.
+	otherID := randomID()
+	srv.AddPeer(newNode(otherID, nil))
+	if err := srv.checkpoint(newconn(otherID), srv.posthandshake); err != nil {
+		t.Error("unexpected error for added static conn:", err)
+	}
+	srv.RemovePeer(newNode(staticID, nil))
+	if err := srv.checkpoint(newconn(staticID), srv.posthandshake); err != DiscUselessPeer {
+		t.Error("wrong error for removed static conn:", err)
+	}
+}
+
+// The nodes registered under the topics of the protocols dial each other, with
+// discovery V4 running on the same socket or disabled.
+func TestServerTopicDiscovery(t *testing.T) {
+	for _, noV4 := range []bool{false, true} {
+		start := func(bootnodes []*discv5.Node) *Server {
+			srv := &Server{
+				Config: Config{
+					PrivateKey:       newkey(),
+					MaxPeers:         10,
+					ListenAddr:       "127.0.0.1:0",
+					NoDiscovery:      noV4,
+					DiscoveryV5:      true,
+					BootstrapNodesV5: bootnodes,
+					Protocols: []Protocol{{
+						Name:   "test",
+						Length: 1,
+						Topics: []discv5.Topic{"test-topic"},
+						Run: func(p *Peer, rw MsgReadWriter) error {
+							for {
+								if _, err := rw.ReadMsg(); err != nil {
+									return err
+								}
+							}
+						},
+					}},
+				},
+			}
+			if err := srv.Start(); err != nil {
+				t.Fatalf("could not start: %v", err)
+			}
+			return srv
+		}
+		// the boot node knows no other node to dial
+		boot := start(nil)
+		srv1 := start([]*discv5.Node{boot.DiscV5.Self()})
+		srv2 := start([]*discv5.Node{boot.DiscV5.Self()})
+
+		deadline := time.Now().Add(30 * time.Second)
+		for srv1.PeerCount() == 0 && time.Now().Before(deadline) {
+			time.Sleep(100 * time.Millisecond)
+		}
+		if srv1.PeerCount() == 0 {
+			t.Errorf("no peer found by the topic search (V4 disabled: %v)", noV4)
+		}
+		srv2.Stop()
+		srv1.Stop()
+		boot.Stop()
+	}
+}
+
+func TestServerBanPeer(t *testing.T) {
+	key := newkey()
+	staticID := randomID()
+	srv := &Server{
+		Config: Config{
+			PrivateKey:  newkey(),
+			MaxPeers:    10,
+			StaticNodes: []*enode.Node{newNode(staticID, nil)},
+			NoDial:      true,
+		},
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start: %v", err)
+	}
+	defer srv.Stop()
+
+	newconn := func(id enode.ID) *conn {
+		fd, _ := net.Pipe()
+		tx := newTestTransport(&key.PublicKey, fd)
+		node := enode.SignNull(new(enr.Record), id)
+		return &conn{fd: fd, transport: tx, flags: inboundConn, node: node, cont: make(chan error)}
+	}
+
+	bans := make(chan *PeerBanEvent, 3)
+	sub := srv.SubscribeBans(bans)
+	defer sub.Unsubscribe()
+
+	bannedID := randomID()
+	srv.BanPeer(newNode(bannedID, nil))
+	srv.BanPeer(newNode(staticID, nil))
+	if err := srv.checkpoint(newconn(bannedID), srv.posthandshake); err != DiscUselessPeer {
+		t.Error("wrong error for banned conn:", err)
+	}
+	if err := srv.checkpoint(newconn(staticID), srv.posthandshake); err != DiscUselessPeer {
+		t.Error("wrong error for banned static conn:", err)
+	}
+	srv.UnbanPeer(newNode(staticID, nil))
+	if err := srv.checkpoint(newconn(staticID), srv.posthandshake); err != nil {
+		t.Error("unexpected error for unbanned conn:", err)
+	}
+	for _, want := range []PeerBanEvent{
+		{ID: bannedID, Banned: true, Reason: BanReasonAdmin},
+		{ID: staticID, Banned: true, Reason: BanReasonAdmin},
+		{ID: staticID, Banned: false, Reason: BanReasonAdmin},
+	} {
+		if ev := <-bans; *ev != want {
+			t.Errorf("wrong ban event: got %+v, want %+v", *ev, want)
+		}
+	}
+}
+
 func TestServerPeerLimits(t *testing.T) {
 	srvkey := newkey()
 	clientkey := newkey()
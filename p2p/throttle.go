@@ -0,0 +1,140 @@
+package p2p
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var errTransportClosed = errors.New("transport closed")
+
+// rateLimiter is a token bucket limiting the bytes per second going through
+// it. A message larger than the bucket is let through on credit, which the
+// following messages pay back by waiting.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // bytes per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns a limiter of rate bytes per second, or nil if rate is
+// not positive, which means unlimited.
+func newRateLimiter(rate int) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &rateLimiter{
+		rate:   float64(rate),
+		burst:  float64(rate),
+		tokens: float64(rate),
+		last:   time.Now(),
+	}
+}
+
+// reserve takes n bytes from the bucket and returns how long to wait before
+// they may go through.
+func (l *rateLimiter) reserve(n uint32) time.Duration {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// throttledTransport limits the rate of the messages read and written by a
+// transport, both per peer and together with all the other peers sharing the
+// global limiters. Messages are throttled as a whole before the write deadline
+// is set, so the limits should stay well above the largest message size.
+type throttledTransport struct {
+	transport
+	up, down []*rateLimiter
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func (t *throttledTransport) encFormat() encFormat {
+	if f, ok := t.transport.(encFormatter); ok {
+		return f.encFormat()
+	}
+	return encFormat{}
+}
+
+func (t *throttledTransport) ReadMsg() (Msg, error) {
+	msg, err := t.transport.ReadMsg()
+	if err != nil {
+		return msg, err
+	}
+	// delaying the next read leaves the sender blocked by TCP flow control
+	t.wait(t.down, msg.Size)
+	return msg, nil
+}
+
+func (t *throttledTransport) WriteMsg(msg Msg) error {
+	if !t.wait(t.up, msg.Size) {
+		return errTransportClosed
+	}
+	return t.transport.WriteMsg(msg)
+}
+
+func (t *throttledTransport) close(err error) {
+	t.closeOnce.Do(func() { close(t.closed) })
+	t.transport.close(err)
+}
+
+// wait blocks until size bytes are allowed through all the limiters, and
+// returns false if the transport is closed in the meantime.
+func (t *throttledTransport) wait(limiters []*rateLimiter, size uint32) bool {
+	var delay time.Duration
+	for _, l := range limiters {
+		if d := l.reserve(size); d > delay {
+			delay = d
+		}
+	}
+	if delay == 0 {
+		return true
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-t.closed:
+		return false
+	}
+}
+
+// throttle wraps the transport of a new connection with the rate limits of
+// the server, if any.
+func (srv *Server) throttle(t transport) transport {
+	up := limiters(srv.uploadLimiter, newRateLimiter(srv.PeerMaxUploadRate))
+	down := limiters(srv.downloadLimiter, newRateLimiter(srv.PeerMaxDownloadRate))
+	if len(up) == 0 && len(down) == 0 {
+		return t
+	}
+	return &throttledTransport{transport: t, up: up, down: down, closed: make(chan struct{})}
+}
+
+func limiters(ls ...*rateLimiter) []*rateLimiter {
+	var res []*rateLimiter
+	for _, l := range ls {
+		if l != nil {
+			res = append(res, l)
+		}
+	}
+	return res
+}
@@ -0,0 +1,125 @@
+package p2p
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"testing"
+	"time"
+)
+
+type pipeTransport struct {
+	*MsgPipeRW
+}
+
+func (t pipeTransport) doEncHandshake(prv *ecdsa.PrivateKey, dialDest *ecdsa.PublicKey) (*ecdsa.PublicKey, error) {
+	panic("not implemented")
+}
+
+func (t pipeTransport) doProtoHandshake(our *protoHandshake) (*protoHandshake, error) {
+	panic("not implemented")
+}
+
+func (t pipeTransport) close(err error) {
+	t.Close()
+}
+
+func TestRateLimiter(t *testing.T) {
+	if newRateLimiter(0) != nil {
+		t.Fatal("limiter of rate 0 should be nil")
+	}
+	var unlimited *rateLimiter
+	if d := unlimited.reserve(1 << 20); d != 0 {
+		t.Errorf("unlimited limiter delay %v", d)
+	}
+
+	l := newRateLimiter(1000)
+	if d := l.reserve(1000); d != 0 {
+		t.Errorf("burst delay %v, want 0", d)
+	}
+	if d := l.reserve(500); !approx(d, 500*time.Millisecond) {
+		t.Errorf("delay %v, want 500ms", d)
+	}
+	// the credit is paid back by the next reservations
+	if d := l.reserve(2000); !approx(d, 2500*time.Millisecond) {
+		t.Errorf("delay %v, want 2.5s", d)
+	}
+}
+
+func testMsg(size uint32) Msg {
+	return Msg{Code: 1, Size: size, Payload: bytes.NewReader(make([]byte, size))}
+}
+
+func approx(d, want time.Duration) bool {
+	return d > want-50*time.Millisecond && d <= want
+}
+
+func TestServerThrottle(t *testing.T) {
+	srv := &Server{Config: Config{PeerMaxUploadRate: 1000}}
+	srv.uploadLimiter = newRateLimiter(srv.MaxUploadRate)
+	srv.downloadLimiter = newRateLimiter(srv.MaxDownloadRate)
+	rw1, rw2 := MsgPipe()
+	tr := srv.throttle(pipeTransport{rw1})
+	tt, ok := tr.(*throttledTransport)
+	if !ok {
+		t.Fatal("transport is not throttled")
+	}
+	if len(tt.up) != 1 || len(tt.down) != 0 {
+		t.Fatalf("%d upload and %d download limiters, want 1 and 0", len(tt.up), len(tt.down))
+	}
+	go func() {
+		for {
+			msg, err := rw2.ReadMsg()
+			if err != nil {
+				return
+			}
+			msg.Discard()
+		}
+	}()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := tr.WriteMsg(testMsg(200)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("writes within the burst took %v", elapsed)
+	}
+	if err := tr.WriteMsg(testMsg(600)); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("writes over the burst took %v, want at least 200ms", elapsed)
+	}
+
+	// closing the transport aborts a pending write
+	errc := make(chan error, 1)
+	go func() { errc <- tr.WriteMsg(testMsg(10000)) }()
+	time.Sleep(50 * time.Millisecond)
+	tr.close(DiscQuitting)
+	select {
+	case err := <-errc:
+		if err != errTransportClosed {
+			t.Errorf("write error %v, want %v", err, errTransportClosed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("write not aborted by close")
+	}
+}
+
+func TestServerThrottleShared(t *testing.T) {
+	srv := &Server{Config: Config{MaxDownloadRate: 1000}}
+	if tr := srv.throttle(pipeTransport{}); tr != (pipeTransport{}) {
+		t.Fatal("transport throttled before the server is started")
+	}
+	srv.uploadLimiter = newRateLimiter(srv.MaxUploadRate)
+	srv.downloadLimiter = newRateLimiter(srv.MaxDownloadRate)
+	t1 := srv.throttle(pipeTransport{}).(*throttledTransport)
+	t2 := srv.throttle(pipeTransport{}).(*throttledTransport)
+	if len(t1.down) != 1 || t1.down[0] != t2.down[0] {
+		t.Fatal("download limiter not shared by the peers")
+	}
+	if len(t1.up) != 0 {
+		t.Fatal("upload throttled without limit")
+	}
+}
@@ -0,0 +1,66 @@
+package p2p
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/discv5"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+const (
+	topicSearchInterval = time.Minute // between the lookups of a topic search
+	topicSearchBuffer   = 64          // nodes found and not handed to the dialer yet
+)
+
+// discoverTopics registers the host node under the discovery V5 topics of the
+// protocols, and searches the nodes registered under them until the server
+// stops, handing them to the dialer. The versions of a protocol may share
+// their topics.
+func (srv *Server) discoverTopics() {
+	topics := make(map[discv5.Topic]bool)
+	for _, p := range srv.Protocols {
+		for _, topic := range p.Topics {
+			if topics[topic] {
+				continue
+			}
+			topics[topic] = true
+			srv.loopWG.Add(2)
+			go func(topic discv5.Topic) {
+				defer srv.loopWG.Done()
+				srv.DiscV5.RegisterTopic(topic, srv.quit)
+			}(topic)
+			go func(topic discv5.Topic) {
+				defer srv.loopWG.Done()
+				srv.searchTopic(topic)
+			}(topic)
+		}
+	}
+}
+
+// searchTopic hands the nodes registered under the topic to the dialer.
+func (srv *Server) searchTopic(topic discv5.Topic) {
+	period := make(chan time.Duration, 1)
+	period <- topicSearchInterval
+	// the nodes are dropped by discovery V5 rather than waited for
+	found := make(chan *discv5.Node, topicSearchBuffer)
+	go srv.DiscV5.SearchTopic(topic, period, found, nil)
+	defer close(period)
+
+	for {
+		select {
+		case n := <-found:
+			pubkey, err := n.ID.Pubkey()
+			if err != nil {
+				srv.log.Debug("Invalid node found by topic search", "topic", topic, "id", n.ID, "err", err)
+				continue
+			}
+			select {
+			case srv.topicnode <- enode.NewV4(pubkey, n.IP, int(n.TCP), int(n.UDP)):
+			case <-srv.quit:
+				return
+			}
+		case <-srv.quit:
+			return
+		}
+	}
+}
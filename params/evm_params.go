@@ -26,6 +26,15 @@ var (
 	DefaultCrossShardTxGasLimit = new(big.Int).SetUint64(30000)
 )
 
+const (
+	// GasLimitBoundDivisor bounds the change of the gas limit voted by the miner
+	// of a block to less than 1/GasLimitBoundDivisor of the parent one.
+	GasLimitBoundDivisor uint64 = 1024
+	// MinBlockGasLimit is the lowest gas limit voted by the miners, which leaves
+	// room for a cross-shard tx in each half of a block.
+	MinBlockGasLimit uint64 = 2 * 30000
+)
+
 type Denoms struct {
 	Wei   *big.Int
 	GWei  *big.Int
@@ -52,3 +61,32 @@ var (
 		common.HexToAddress("000000000000000000000000000000514b430003"),
 	}
 )
+
+// Gas costs of the Istanbul and Berlin rule sets of the EVM, which are newer than
+// the go-ethereum params.
+const (
+	TxDataNonZeroGasEIP2028 uint64 = 16 // Per byte of non zero data attached to a transaction after Istanbul
+
+	SloadGasEIP1884       uint64 = 800 // Cost of SLOAD after Istanbul
+	BalanceGasEIP1884     uint64 = 700 // Cost of BALANCE after Istanbul
+	ExtcodeHashGasEIP1884 uint64 = 700 // Cost of EXTCODEHASH after Istanbul
+	SelfBalanceGas        uint64 = 5   // Cost of SELFBALANCE
+	ChainIDGas            uint64 = 2   // Cost of CHAINID
+
+	SstoreSentryGasEIP2200            uint64 = 2300  // Minimum gas required to be present for an SSTORE call, not consumed
+	SstoreSetGasEIP2200               uint64 = 20000 // Once per SSTORE operation from clean zero to non-zero
+	SstoreResetGasEIP2200             uint64 = 5000  // Once per SSTORE operation from clean non-zero to something else
+	SstoreClearsScheduleRefundEIP2200 uint64 = 15000 // Once per SSTORE operation for clearing an originally existing storage slot
+
+	ColdAccountAccessCostEIP2929 uint64 = 2600 // Cost of the first access to an account in a transaction
+	ColdSloadCostEIP2929         uint64 = 2100 // Cost of the first access to a storage slot in a transaction
+	WarmStorageReadCostEIP2929   uint64 = 100  // Cost of reading a warm account or storage slot
+
+	Bn256AddGasEIP1108             uint64 = 150   // Gas needed for an elliptic curve addition after Istanbul
+	Bn256ScalarMulGasEIP1108       uint64 = 6000  // Gas needed for an elliptic curve scalar multiplication after Istanbul
+	Bn256PairingBaseGasEIP1108     uint64 = 45000 // Base price for an elliptic curve pairing check after Istanbul
+	Bn256PairingPerPointGasEIP1108 uint64 = 34000 // Per-point price for an elliptic curve pairing check after Istanbul
+
+	ModExpQuadCoeffDivEIP2565 uint64 = 3   // Divisor of the complexity of the big int modular exponentiation after Berlin
+	ModExpMinGasEIP2565       uint64 = 200 // Minimum gas of the big int modular exponentiation after Berlin
+)
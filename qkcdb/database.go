@@ -120,17 +120,34 @@ func (db *RDBDatabase) Delete(key []byte) error {
 	return db.db.Delete(db.wo, key)
 }
 
-func (db *RDBDatabase) NewIterator() *gorocksdb.Iterator {
-	return db.db.NewIterator(db.ro)
+func (db *RDBDatabase) NewIterator() Iterator {
+	return &rdbIterator{it: db.db.NewIterator(db.ro)}
 }
 
 // NewIteratorWithPrefix returns a iterator to iterate over subset of database content with a particular prefix.
-func (db *RDBDatabase) NewIteratorWithPrefix(prefix []byte) *gorocksdb.Iterator {
+func (db *RDBDatabase) NewIteratorWithPrefix(prefix []byte) Iterator {
 	it := db.NewIterator()
 	it.Seek(prefix)
 	return it
 }
 
+// Compact flattens the underlying data store for the given key range, a nil
+// start is treated as a key before all keys and a nil limit as a key after
+// all keys.
+func (db *RDBDatabase) Compact(start []byte, limit []byte) error {
+	db.db.CompactRange(gorocksdb.Range{Start: start, Limit: limit})
+	return nil
+}
+
+// NewSnapshot returns a view of the current content of the database.
+func (db *RDBDatabase) NewSnapshot() (Snapshot, error) {
+	snap := db.db.NewSnapshot()
+	ro := gorocksdb.NewDefaultReadOptions()
+	ro.SetFillCache(false)
+	ro.SetSnapshot(snap)
+	return &rdbSnapshot{db: db.db, snap: snap, ro: ro}, nil
+}
+
 func (db *RDBDatabase) Close() {
 	db.closeOnce.Do(func() {
 		db.db.Close()
@@ -169,3 +186,57 @@ func (b *rdbBatch) ValueSize() int {
 func (b *rdbBatch) Reset() {
 	b.w.Clear()
 }
+
+// rdbSnapshot adapts a rocksdb snapshot to Snapshot.
+type rdbSnapshot struct {
+	db   *gorocksdb.DB
+	snap *gorocksdb.Snapshot
+	ro   *gorocksdb.ReadOptions
+}
+
+func (s *rdbSnapshot) Has(key []byte) (bool, error) {
+	_, err := s.Get(key)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *rdbSnapshot) Get(key []byte) ([]byte, error) {
+	dat, err := s.db.Get(s.ro, key)
+	if err != nil {
+		return nil, err
+	}
+	defer dat.Free()
+	if dat.Size() == 0 {
+		return nil, errors.New("failed to get data from rocksdb, return empty data")
+	}
+	result := make([]byte, dat.Size())
+	copy(result, dat.Data())
+	return result, nil
+}
+
+func (s *rdbSnapshot) NewIterator() Iterator {
+	return &rdbIterator{it: s.db.NewIterator(s.ro)}
+}
+
+func (s *rdbSnapshot) Release() {
+	s.ro.Destroy()
+	s.db.ReleaseSnapshot(s.snap)
+}
+
+// rdbIterator adapts a rocksdb iterator to Iterator.
+type rdbIterator struct {
+	it *gorocksdb.Iterator
+}
+
+func (it *rdbIterator) SeekToFirst()           { it.it.SeekToFirst() }
+func (it *rdbIterator) Seek(key []byte)        { it.it.Seek(key) }
+func (it *rdbIterator) SeekForPrev(key []byte) { it.it.SeekForPrev(key) }
+func (it *rdbIterator) Valid() bool            { return it.it.Valid() }
+func (it *rdbIterator) Next()                  { it.it.Next() }
+func (it *rdbIterator) Prev()                  { it.it.Prev() }
+func (it *rdbIterator) Key() []byte            { return it.it.Key().Data() }
+func (it *rdbIterator) Value() []byte          { return it.it.Value().Data() }
+func (it *rdbIterator) Err() error             { return it.it.Err() }
+func (it *rdbIterator) Close()                 { it.it.Close() }
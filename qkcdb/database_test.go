@@ -2,14 +2,17 @@ package qkcdb_test
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
 
 	"github.com/QuarkChain/goquarkchain/qkcdb"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -29,6 +32,22 @@ func newTestRDB() (*qkcdb.RDBDatabase, func()) {
 	}
 }
 
+func newTestLDB() (*qkcdb.LDBDatabase, func()) {
+	dirname, err := ioutil.TempDir(os.TempDir(), "qkcdb_test_")
+	if err != nil {
+		panic("failed to create test file: " + err.Error())
+	}
+	db, err := qkcdb.NewLDBDatabase(dirname, false, false)
+	if err != nil {
+		panic("failed to create test database: " + err.Error())
+	}
+
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dirname)
+	}
+}
+
 func testData() map[string]string {
 	var testValues = make(map[string]string)
 	testValues["1"] = "a"
@@ -46,6 +65,12 @@ func TestRDB_PutGet(t *testing.T) {
 	testPutGet(db, t)
 }
 
+func TestLDB_PutGet(t *testing.T) {
+	db, remove := newTestLDB()
+	defer remove()
+	testPutGet(db, t)
+}
+
 func testPutGet(db qkcdb.Database, t *testing.T) {
 	t.Parallel()
 
@@ -109,6 +134,13 @@ func Test_batch(t *testing.T) {
 	testBatchPutGet(db, batch, t)
 }
 
+func TestLDB_batch(t *testing.T) {
+	db, remove := newTestLDB()
+	defer remove()
+	batch := db.NewBatch()
+	testBatchPutGet(db, batch, t)
+}
+
 func testBatchPutGet(db qkcdb.Database, batch qkcdb.Batch, t *testing.T) {
 	t.Parallel()
 	testValues := testData()
@@ -147,6 +179,12 @@ func TestRDB_ParallelPutGet(t *testing.T) {
 	testParallelPutGet(db, t)
 }
 
+func TestLDB_ParallelPutGet(t *testing.T) {
+	db, remove := newTestLDB()
+	defer remove()
+	testParallelPutGet(db, t)
+}
+
 func testParallelPutGet(db qkcdb.Database, t *testing.T) {
 	const n = 8
 	var pending sync.WaitGroup
@@ -203,6 +241,193 @@ func testParallelPutGet(db qkcdb.Database, t *testing.T) {
 	pending.Wait()
 }
 
+func TestRDB_Iterator(t *testing.T) {
+	db, remove := newTestRDB()
+	defer remove()
+	testIterator(db, t)
+}
+
+func TestLDB_Iterator(t *testing.T) {
+	db, remove := newTestLDB()
+	defer remove()
+	testIterator(db, t)
+}
+
+func testIterator(db qkcdb.KeyValueStore, t *testing.T) {
+	for _, k := range []string{"a1", "a3", "b1", "b3"} {
+		assert.NoError(t, db.Put([]byte(k), []byte("v"+k)))
+	}
+	keys := func(it qkcdb.Iterator, next func()) []string {
+		var keys []string
+		for ; it.Valid(); next() {
+			keys = append(keys, string(it.Key()))
+		}
+		return keys
+	}
+
+	it := db.NewIterator()
+	it.SeekToFirst()
+	assert.Equal(t, "va1", string(it.Value()))
+	assert.Equal(t, []string{"a1", "a3", "b1", "b3"}, keys(it, it.Next))
+	assert.NoError(t, it.Err())
+	it.Close()
+
+	it = db.NewIteratorWithPrefix([]byte("a2"))
+	assert.Equal(t, []string{"a3", "b1", "b3"}, keys(it, it.Next))
+	it.Close()
+
+	it = db.NewIterator()
+	it.SeekForPrev([]byte("b2"))
+	assert.Equal(t, []string{"b1", "a3", "a1"}, keys(it, it.Prev))
+	it.SeekForPrev([]byte("b3"))
+	assert.Equal(t, "b3", string(it.Key()))
+	it.SeekForPrev([]byte("c"))
+	assert.Equal(t, "b3", string(it.Key()))
+	it.SeekForPrev([]byte("a"))
+	assert.False(t, it.Valid())
+	it.Close()
+
+	assert.NoError(t, db.Compact(nil, nil))
+	data, err := db.Get([]byte("b1"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("vb1"), data)
+}
+
+func TestRDB_Snapshot(t *testing.T) {
+	db, remove := newTestRDB()
+	defer remove()
+	testSnapshot(db, t)
+}
+
+func TestLDB_Snapshot(t *testing.T) {
+	db, remove := newTestLDB()
+	defer remove()
+	testSnapshot(db, t)
+}
+
+func testSnapshot(db qkcdb.KeyValueStore, t *testing.T) {
+	assert.NoError(t, db.Put([]byte("a"), []byte("va")))
+	assert.NoError(t, db.Put([]byte("b"), []byte("vb")))
+	snap, err := db.NewSnapshot()
+	assert.NoError(t, err)
+	defer snap.Release()
+
+	// the writes after the snapshot are not seen
+	assert.NoError(t, db.Put([]byte("a"), []byte("va2")))
+	assert.NoError(t, db.Put([]byte("c"), []byte("vc")))
+	assert.NoError(t, db.Delete([]byte("b")))
+	data, err := snap.Get([]byte("a"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("va"), data)
+	has, _ := snap.Has([]byte("b"))
+	assert.True(t, has)
+	has, _ = snap.Has([]byte("c"))
+	assert.False(t, has)
+
+	it := snap.NewIterator()
+	defer it.Close()
+	var keys []string
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		keys = append(keys, string(it.Key()))
+	}
+	assert.NoError(t, it.Err())
+	assert.Equal(t, []string{"a", "b"}, keys)
+}
+
+func TestNewDatabase(t *testing.T) {
+	dirname, err := ioutil.TempDir(os.TempDir(), "qkcdb_test_")
+	if err != nil {
+		panic("failed to create test file: " + err.Error())
+	}
+	defer os.RemoveAll(dirname)
+
+	db, err := qkcdb.NewDatabase(qkcdb.LevelDB, dirname, false, false)
+	assert.NoError(t, err)
+	assert.IsType(t, &qkcdb.LDBDatabase{}, db)
+	db.Close()
+
+	_, err = qkcdb.NewDatabase("unknown", dirname, false, false)
+	assert.Error(t, err)
+}
+
+// benchmarkBatchWrite writes b.N batches of 1000 entries with 32 byte keys and
+// 100 byte values, similar to the trie nodes committed while syncing.
+func benchmarkBatchWrite(b *testing.B, db qkcdb.Database) {
+	value := bytes.Repeat([]byte{0x01}, 100)
+	key := make([]byte, 32)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		batch := db.NewBatch()
+		for j := 0; j < 1000; j++ {
+			binary.BigEndian.PutUint64(key, uint64(i*1000+j))
+			batch.Put(crypto.Keccak256(key), value)
+		}
+		if err := batch.Write(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRDB_BatchWrite(b *testing.B) {
+	db, remove := newTestRDB()
+	defer remove()
+	benchmarkBatchWrite(b, db)
+}
+
+func BenchmarkLDB_BatchWrite(b *testing.B) {
+	db, remove := newTestLDB()
+	defer remove()
+	benchmarkBatchWrite(b, db)
+}
+
+// processBytesWritten returns the bytes the process has written to files so
+// far, all of which are written by the database while benchmarking.
+func processBytesWritten(b *testing.B) uint64 {
+	dat, err := ioutil.ReadFile("/proc/self/io")
+	if err != nil {
+		b.Skip("write amplification is measured with /proc/self/io:", err)
+	}
+	for _, line := range strings.Split(string(dat), "\n") {
+		if strings.HasPrefix(line, "wchar: ") {
+			n, err := strconv.ParseUint(strings.TrimPrefix(line, "wchar: "), 10, 64)
+			if err != nil {
+				b.Fatal(err)
+			}
+			return n
+		}
+	}
+	b.Skip("no wchar in /proc/self/io")
+	return 0
+}
+
+// benchmarkWriteAmplification runs the batch writes of benchmarkBatchWrite and
+// reports the write amplification, i.e. the bytes written to the disk by the
+// WAL, the flushes and the compactions divided by the bytes of the keys and
+// values written, as the wamp metric. It needs enough writes for compactions
+// to kick in, e.g. with -benchtime=5000x.
+func benchmarkWriteAmplification(b *testing.B, db qkcdb.Database, closeDB func()) {
+	start := processBytesWritten(b)
+	benchmarkBatchWrite(b, db)
+	// closing flushes the pending writes
+	closeDB()
+	b.StopTimer()
+	written := processBytesWritten(b) - start
+	userBytes := uint64(b.N) * 1000 * (32 + 100)
+	b.ReportMetric(float64(written)/float64(userBytes), "wamp")
+}
+
+func BenchmarkRDB_WriteAmplification(b *testing.B) {
+	db, remove := newTestRDB()
+	defer remove()
+	benchmarkWriteAmplification(b, db, db.Close)
+}
+
+func BenchmarkLDB_WriteAmplification(b *testing.B) {
+	db, remove := newTestLDB()
+	defer remove()
+	benchmarkWriteAmplification(b, db, db.Close)
+}
+
 func TestNewDBWithClean(t *testing.T) {
 	dirname, err := ioutil.TempDir(os.TempDir(), "qkcdb_test_")
 	if err != nil {
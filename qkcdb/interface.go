@@ -1,9 +1,21 @@
 package qkcdb
 
 import (
+	"fmt"
+
 	"github.com/ethereum/go-ethereum/ethdb"
 )
 
+const (
+	// RocksDB is the default storage backend.
+	RocksDB = "rocksdb"
+	// LevelDB is the pure Go storage backend, which needs no native library.
+	LevelDB = "leveldb"
+	// Pebble is the pure Go storage backend with a smaller write
+	// amplification than leveldb.
+	Pebble = "pebble"
+)
+
 // Putter wraps the database write operation supported by both batches and regular databases.
 type Putter = ethdb.Putter
 
@@ -16,3 +28,75 @@ type Database = ethdb.Database
 // Batch is a write-only database that commits changes to its host database
 // when Write is called. Batch cannot be used concurrently.
 type Batch = ethdb.Batch
+
+// Iterator iterates over the key / value pairs of a KeyValueStore in key order,
+// it has to be positioned by one of the seek methods first. The returned key
+// and value are only valid until the iterator is moved.
+type Iterator interface {
+	// SeekToFirst moves the iterator to the first key.
+	SeekToFirst()
+	// Seek moves the iterator to the first key not less than key.
+	Seek(key []byte)
+	// SeekForPrev moves the iterator to the last key not greater than key.
+	SeekForPrev(key []byte)
+	Valid() bool
+	Next()
+	Prev()
+	Key() []byte
+	Value() []byte
+	Err() error
+	Close()
+}
+
+// Snapshot is a read-only view of a KeyValueStore at the time it was taken,
+// the writes done since are not seen. It has to be released once done.
+type Snapshot interface {
+	Has(key []byte) (bool, error)
+	Get(key []byte) ([]byte, error)
+	NewIterator() Iterator
+	Release()
+}
+
+// KeyValueStore is a persistent Database which can be iterated and compacted,
+// every storage backend implements it.
+type KeyValueStore interface {
+	Database
+	// Path returns the path to the database directory.
+	Path() string
+	NewIterator() Iterator
+	// NewIteratorWithPrefix returns an iterator positioned at the first key
+	// with the prefix, it's not limited to the keys with the prefix.
+	NewIteratorWithPrefix(prefix []byte) Iterator
+	// Compact flattens the data store for the key range [start, limit), nil
+	// start and limit stand for the first and the last key respectively.
+	Compact(start []byte, limit []byte) error
+	// NewSnapshot returns a consistent view of the current content of the
+	// store, e.g. to back it up while it's written.
+	NewSnapshot() (Snapshot, error)
+}
+
+// NewDatabase opens the key-value store in file with the storage backend, the
+// default backend is used if it's empty.
+func NewDatabase(backend, file string, clean, isReadOnly bool) (KeyValueStore, error) {
+	switch backend {
+	case RocksDB, "":
+		db, err := NewRDBDatabase(file, clean, isReadOnly)
+		if err != nil {
+			return nil, err
+		}
+		return db, nil
+	case LevelDB:
+		db, err := NewLDBDatabase(file, clean, isReadOnly)
+		if err != nil {
+			return nil, err
+		}
+		return db, nil
+	case Pebble:
+		db, err := NewPDBDatabase(file, clean, isReadOnly)
+		if err != nil {
+			return nil, err
+		}
+		return db, nil
+	}
+	return nil, fmt.Errorf("unknown database backend %q", backend)
+}
@@ -0,0 +1,222 @@
+package qkcdb
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/syndtr/goleveldb/leveldb"
+	lerrors "github.com/syndtr/goleveldb/leveldb/errors"
+	"github.com/syndtr/goleveldb/leveldb/filter"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+type LDBDatabase struct {
+	fn         string      // filename for reporting
+	db         *leveldb.DB // LevelDB instance
+	isReadOnly bool
+	closeOnce  sync.Once
+	log        log.Logger // Contextual logger tracking the database path
+}
+
+// NewLDBDatabase returns a leveldb wrapped object, it behaves the same as the
+// rocksdb one.
+func NewLDBDatabase(file string, clean, isReadOnly bool) (*LDBDatabase, error) {
+	logger := log.New("database", file)
+
+	if clean {
+		if err := os.RemoveAll(file); err != nil {
+			return nil, err
+		}
+	}
+	// Open the db and recover any potential corruptions
+	db, err := leveldb.OpenFile(file, &opt.Options{
+		OpenFilesCacheCapacity: handles,
+		BlockCacheCapacity:     int(cache/2) * opt.MiB,
+		WriteBuffer:            int(cache/4) * opt.MiB, // Two of these are used internally
+		Filter:                 filter.NewBloomFilter(10),
+	})
+	if _, corrupted := err.(*lerrors.ErrCorrupted); corrupted {
+		db, err = leveldb.RecoverFile(file, nil)
+	}
+	// check for errors and abort if opening of the db failed
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Allocated cache and file handles", "cache", cache, "handles", handles)
+
+	return &LDBDatabase{
+		fn:         file,
+		db:         db,
+		isReadOnly: isReadOnly,
+		log:        logger,
+	}, nil
+}
+
+// Path returns the path to the database directory.
+func (db *LDBDatabase) Path() string {
+	return db.fn
+}
+
+// Put puts the given key / value to the queue
+func (db *LDBDatabase) Put(key []byte, value []byte) error {
+	if db.isReadOnly {
+		return nil
+	}
+	if len(key) == 0 || len(value) == 0 {
+		return errors.New("failed to put data, key or value can't be empty")
+	}
+	return db.db.Put(key, value, nil)
+}
+
+func (db *LDBDatabase) Has(key []byte) (bool, error) {
+	_, err := db.Get(key)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Get returns the given key if it's present.
+func (db *LDBDatabase) Get(key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, errors.New("failed to get data from database, key can't be empty")
+	}
+	dat, err := db.db.Get(key, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(dat) == 0 {
+		return nil, errors.New("failed to get data from leveldb, return empty data")
+	}
+	return dat, nil
+}
+
+// Delete deletes the key from the queue and database
+func (db *LDBDatabase) Delete(key []byte) error {
+	return db.db.Delete(key, nil)
+}
+
+func (db *LDBDatabase) NewIterator() Iterator {
+	return &ldbIterator{it: db.db.NewIterator(nil, nil)}
+}
+
+// NewIteratorWithPrefix returns a iterator to iterate over subset of database content with a particular prefix.
+func (db *LDBDatabase) NewIteratorWithPrefix(prefix []byte) Iterator {
+	it := db.NewIterator()
+	it.Seek(prefix)
+	return it
+}
+
+// Compact flattens the underlying data store for the given key range, a nil
+// start is treated as a key before all keys and a nil limit as a key after
+// all keys.
+func (db *LDBDatabase) Compact(start []byte, limit []byte) error {
+	return db.db.CompactRange(util.Range{Start: start, Limit: limit})
+}
+
+// NewSnapshot returns a view of the current content of the database.
+func (db *LDBDatabase) NewSnapshot() (Snapshot, error) {
+	snap, err := db.db.GetSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &ldbSnapshot{snap: snap}, nil
+}
+
+func (db *LDBDatabase) Close() {
+	db.closeOnce.Do(func() {
+		if err := db.db.Close(); err != nil {
+			db.log.Error("Failed to close database", "err", err)
+		}
+	})
+}
+
+func (db *LDBDatabase) NewBatch() Batch {
+	return &ldbBatch{db: db.db, b: new(leveldb.Batch)}
+}
+
+type ldbBatch struct {
+	db *leveldb.DB
+	b  *leveldb.Batch
+}
+
+func (b *ldbBatch) Put(key, value []byte) error {
+	b.b.Put(key, value)
+	return nil
+}
+
+func (b *ldbBatch) Delete(key []byte) error {
+	b.b.Delete(key)
+	return nil
+}
+
+func (b *ldbBatch) Write() error {
+	return b.db.Write(b.b, nil)
+}
+
+func (b *ldbBatch) ValueSize() int {
+	return b.b.Len()
+}
+
+func (b *ldbBatch) Reset() {
+	b.b.Reset()
+}
+
+// ldbSnapshot adapts a leveldb snapshot to Snapshot.
+type ldbSnapshot struct {
+	snap *leveldb.Snapshot
+}
+
+func (s *ldbSnapshot) Has(key []byte) (bool, error) {
+	_, err := s.Get(key)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *ldbSnapshot) Get(key []byte) ([]byte, error) {
+	dat, err := s.snap.Get(key, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(dat) == 0 {
+		return nil, errors.New("failed to get data from leveldb, return empty data")
+	}
+	return dat, nil
+}
+
+func (s *ldbSnapshot) NewIterator() Iterator {
+	return &ldbIterator{it: s.snap.NewIterator(nil, nil)}
+}
+
+func (s *ldbSnapshot) Release() { s.snap.Release() }
+
+// ldbIterator adapts a leveldb iterator to Iterator.
+type ldbIterator struct {
+	it iterator.Iterator
+}
+
+func (it *ldbIterator) SeekToFirst()    { it.it.First() }
+func (it *ldbIterator) Seek(key []byte) { it.it.Seek(key) }
+func (it *ldbIterator) Valid() bool     { return it.it.Valid() }
+func (it *ldbIterator) Next()           { it.it.Next() }
+func (it *ldbIterator) Prev()           { it.it.Prev() }
+func (it *ldbIterator) Key() []byte     { return it.it.Key() }
+func (it *ldbIterator) Value() []byte   { return it.it.Value() }
+func (it *ldbIterator) Err() error      { return it.it.Error() }
+func (it *ldbIterator) Close()          { it.it.Release() }
+
+func (it *ldbIterator) SeekForPrev(key []byte) {
+	if !it.it.Seek(key) {
+		it.it.Last()
+	} else if bytes.Compare(it.it.Key(), key) > 0 {
+		it.it.Prev()
+	}
+}
@@ -0,0 +1,275 @@
+package qkcdb
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/bloom"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// PDBDatabase is the Pebble storage backend. Pebble is a pure Go LSM store
+// which compacts with smaller write amplification than leveldb.
+type PDBDatabase struct {
+	fn         string     // filename for reporting
+	db         *pebble.DB // Pebble instance
+	isReadOnly bool
+	closeOnce  sync.Once
+	log        log.Logger // Contextual logger tracking the database path
+}
+
+// NewPDBDatabase returns a pebble wrapped object, it behaves the same as the
+// rocksdb one.
+func NewPDBDatabase(file string, clean, isReadOnly bool) (*PDBDatabase, error) {
+	logger := log.New("database", file)
+
+	if clean {
+		if err := os.RemoveAll(file); err != nil {
+			return nil, err
+		}
+	}
+	blockCache := pebble.NewCache(int64(cache/2) * 1024 * 1024)
+	defer blockCache.Unref()
+	db, err := pebble.Open(file, &pebble.Options{
+		Cache:        blockCache,
+		MaxOpenFiles: handles,
+		MemTableSize: uint64(cache/4) * 1024 * 1024,
+		Levels: []pebble.LevelOptions{
+			{FilterPolicy: bloom.FilterPolicy(10)},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Allocated cache and file handles", "cache", cache, "handles", handles)
+
+	return &PDBDatabase{
+		fn:         file,
+		db:         db,
+		isReadOnly: isReadOnly,
+		log:        logger,
+	}, nil
+}
+
+// Path returns the path to the database directory.
+func (db *PDBDatabase) Path() string {
+	return db.fn
+}
+
+// Put puts the given key / value to the queue
+func (db *PDBDatabase) Put(key []byte, value []byte) error {
+	if db.isReadOnly {
+		return nil
+	}
+	if len(key) == 0 || len(value) == 0 {
+		return errors.New("failed to put data, key or value can't be empty")
+	}
+	return db.db.Set(key, value, pebble.Sync)
+}
+
+func (db *PDBDatabase) Has(key []byte) (bool, error) {
+	_, err := db.Get(key)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Get returns the given key if it's present.
+func (db *PDBDatabase) Get(key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, errors.New("failed to get data from database, key can't be empty")
+	}
+	return pdbGet(db.db, key)
+}
+
+// Delete deletes the key from the queue and database
+func (db *PDBDatabase) Delete(key []byte) error {
+	return db.db.Delete(key, pebble.Sync)
+}
+
+func (db *PDBDatabase) NewIterator() Iterator {
+	it, err := db.db.NewIter(nil)
+	return &pdbIterator{it: it, err: err}
+}
+
+// NewIteratorWithPrefix returns a iterator to iterate over subset of database content with a particular prefix.
+func (db *PDBDatabase) NewIteratorWithPrefix(prefix []byte) Iterator {
+	it := db.NewIterator()
+	it.Seek(prefix)
+	return it
+}
+
+// Compact flattens the underlying data store for the given key range, a nil
+// start is treated as a key before all keys and a nil limit as a key after
+// all keys.
+func (db *PDBDatabase) Compact(start []byte, limit []byte) error {
+	// pebble needs both bounds, the one past the last key is the last key
+	// followed by a zero byte
+	if start == nil || limit == nil {
+		it, err := db.db.NewIter(nil)
+		if err != nil {
+			return err
+		}
+		if start == nil && it.First() {
+			start = append([]byte{}, it.Key()...)
+		}
+		if limit == nil && it.Last() {
+			limit = append(append([]byte{}, it.Key()...), 0x00)
+		}
+		if err := it.Close(); err != nil {
+			return err
+		}
+		if start == nil || limit == nil {
+			return nil
+		}
+	}
+	return db.db.Compact(start, limit, true)
+}
+
+// NewSnapshot returns a view of the current content of the database.
+func (db *PDBDatabase) NewSnapshot() (Snapshot, error) {
+	return &pdbSnapshot{snap: db.db.NewSnapshot()}, nil
+}
+
+// WriteAmplification returns the bytes written to the disk by the flushes and
+// the compactions divided by the bytes written to the WAL.
+func (db *PDBDatabase) WriteAmplification() float64 {
+	m := db.db.Metrics()
+	if m.WAL.BytesIn == 0 {
+		return 0
+	}
+	total := m.Total()
+	return float64(m.WAL.BytesWritten+total.BytesFlushed+total.BytesCompacted) / float64(m.WAL.BytesIn)
+}
+
+func (db *PDBDatabase) Close() {
+	db.closeOnce.Do(func() {
+		if err := db.db.Close(); err != nil {
+			db.log.Error("Failed to close database", "err", err)
+		}
+	})
+}
+
+func (db *PDBDatabase) NewBatch() Batch {
+	return &pdbBatch{db: db.db, b: db.db.NewBatch()}
+}
+
+type pdbBatch struct {
+	db *pebble.DB
+	b  *pebble.Batch
+}
+
+func (b *pdbBatch) Put(key, value []byte) error {
+	return b.b.Set(key, value, nil)
+}
+
+func (b *pdbBatch) Delete(key []byte) error {
+	return b.b.Delete(key, nil)
+}
+
+func (b *pdbBatch) Write() error {
+	return b.b.Commit(pebble.Sync)
+}
+
+func (b *pdbBatch) ValueSize() int {
+	return int(b.b.Count())
+}
+
+func (b *pdbBatch) Reset() {
+	b.b.Reset()
+}
+
+// pdbReader is implemented by both pebble.DB and pebble.Snapshot.
+type pdbReader interface {
+	Get(key []byte) ([]byte, io.Closer, error)
+}
+
+func pdbGet(r pdbReader, key []byte) ([]byte, error) {
+	dat, closer, err := r.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+	if len(dat) == 0 {
+		return nil, errors.New("failed to get data from pebble, return empty data")
+	}
+	// the value is only valid until the closer is closed
+	return append([]byte{}, dat...), nil
+}
+
+// pdbSnapshot adapts a pebble snapshot to Snapshot.
+type pdbSnapshot struct {
+	snap *pebble.Snapshot
+}
+
+func (s *pdbSnapshot) Has(key []byte) (bool, error) {
+	_, err := s.Get(key)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *pdbSnapshot) Get(key []byte) ([]byte, error) {
+	return pdbGet(s.snap, key)
+}
+
+func (s *pdbSnapshot) NewIterator() Iterator {
+	it, err := s.snap.NewIter(nil)
+	return &pdbIterator{it: it, err: err}
+}
+
+func (s *pdbSnapshot) Release() { s.snap.Close() }
+
+// pdbIterator adapts a pebble iterator to Iterator, err is set if the
+// iterator couldn't be created.
+type pdbIterator struct {
+	it  *pebble.Iterator
+	err error
+}
+
+func (it *pdbIterator) SeekToFirst() {
+	if it.it != nil {
+		it.it.First()
+	}
+}
+
+func (it *pdbIterator) Seek(key []byte) {
+	if it.it != nil {
+		it.it.SeekGE(key)
+	}
+}
+
+func (it *pdbIterator) SeekForPrev(key []byte) {
+	if it.it == nil {
+		return
+	}
+	if !it.it.SeekGE(key) || !bytes.Equal(it.it.Key(), key) {
+		it.it.SeekLT(key)
+	}
+}
+
+func (it *pdbIterator) Valid() bool   { return it.it != nil && it.it.Valid() }
+func (it *pdbIterator) Next()         { it.it.Next() }
+func (it *pdbIterator) Prev()         { it.it.Prev() }
+func (it *pdbIterator) Key() []byte   { return it.it.Key() }
+func (it *pdbIterator) Value() []byte { return it.it.Value() }
+
+func (it *pdbIterator) Err() error {
+	if it.it == nil {
+		return it.err
+	}
+	return it.it.Error()
+}
+
+func (it *pdbIterator) Close() {
+	if it.it != nil {
+		it.it.Close()
+	}
+}
@@ -0,0 +1,81 @@
+package qkcdb_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/qkcdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPDB() (*qkcdb.PDBDatabase, func()) {
+	dirname, err := ioutil.TempDir(os.TempDir(), "qkcdb_test_")
+	if err != nil {
+		panic("failed to create test file: " + err.Error())
+	}
+	db, err := qkcdb.NewPDBDatabase(dirname, false, false)
+	if err != nil {
+		panic("failed to create test database: " + err.Error())
+	}
+
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dirname)
+	}
+}
+
+func TestPDB_PutGet(t *testing.T) {
+	db, remove := newTestPDB()
+	defer remove()
+	testPutGet(db, t)
+}
+
+func TestPDB_batch(t *testing.T) {
+	db, remove := newTestPDB()
+	defer remove()
+	batch := db.NewBatch()
+	testBatchPutGet(db, batch, t)
+}
+
+func TestPDB_ParallelPutGet(t *testing.T) {
+	db, remove := newTestPDB()
+	defer remove()
+	testParallelPutGet(db, t)
+}
+
+func TestPDB_Iterator(t *testing.T) {
+	db, remove := newTestPDB()
+	defer remove()
+	testIterator(db, t)
+}
+
+func TestPDB_Snapshot(t *testing.T) {
+	db, remove := newTestPDB()
+	defer remove()
+	testSnapshot(db, t)
+}
+
+func TestPDB_Compact(t *testing.T) {
+	db, remove := newTestPDB()
+	defer remove()
+	assert.NoError(t, db.Compact(nil, nil))
+	assert.NoError(t, db.Put([]byte("a"), []byte("va")))
+	assert.NoError(t, db.Put([]byte("b"), []byte("vb")))
+	assert.NoError(t, db.Compact(nil, nil))
+	data, err := db.Get([]byte("b"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("vb"), data)
+}
+
+func BenchmarkPDB_BatchWrite(b *testing.B) {
+	db, remove := newTestPDB()
+	defer remove()
+	benchmarkBatchWrite(b, db)
+}
+
+func BenchmarkPDB_WriteAmplification(b *testing.B) {
+	db, remove := newTestPDB()
+	defer remove()
+	benchmarkWriteAmplification(b, db, db.Close)
+}
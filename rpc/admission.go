@@ -0,0 +1,124 @@
+package rpc
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// AdmissionConfig bounds the calls a server runs, for an overload of calls to
+// be rejected at once rather than slowing down the node and the processing of
+// its blocks. A call of a method at its limit, or once MaxConcurrent calls are
+// running, waits for its turn unless MaxQueued calls are already waiting, in
+// which case it's rejected.
+type AdmissionConfig struct {
+	MaxConcurrent int            // calls running at once, 0 for no limit
+	MaxQueued     int            // calls waiting to run, beyond which they're rejected
+	MethodLimits  map[string]int // calls of a method, e.g. qkc_call, running at once, -1 not to limit the method at all
+}
+
+// overloadedError is returned for the calls rejected by the admission.
+type overloadedError struct{}
+
+func (e *overloadedError) ErrorCode() int { return -32005 }
+
+func (e *overloadedError) Error() string { return "too many requests, retry later" }
+
+type admission struct {
+	workers   chan struct{}            // nil if not limited
+	methods   map[string]chan struct{} // nil if the method is not limited
+	exempt    map[string]bool
+	queued    int32 // atomic
+	maxQueued int32
+}
+
+func newAdmission(cfg *AdmissionConfig) *admission {
+	a := &admission{
+		methods:   make(map[string]chan struct{}),
+		exempt:    make(map[string]bool),
+		maxQueued: int32(cfg.MaxQueued),
+	}
+	if cfg.MaxConcurrent > 0 {
+		a.workers = make(chan struct{}, cfg.MaxConcurrent)
+	}
+	for method, limit := range cfg.MethodLimits {
+		switch {
+		case limit < 0:
+			a.exempt[method] = true
+		case limit > 0:
+			a.methods[method] = make(chan struct{}, limit)
+		}
+	}
+	return a
+}
+
+// full reports whether the calls which can't run at once are rejected.
+func (a *admission) full() bool {
+	return atomic.LoadInt32(&a.queued) >= a.maxQueued && (a.workers == nil || len(a.workers) == cap(a.workers))
+}
+
+// acquire waits for the turn of the call of the method, until ctx is done, or
+// fails at once if too many calls are waiting. The returned func must be called
+// once the call is done.
+func (a *admission) acquire(ctx context.Context, method string) (func(), error) {
+	if a.exempt[method] {
+		return func() {}, nil
+	}
+	slots := make([]chan struct{}, 0, 2)
+	// the method first, for the calls of the other methods not to wait for
+	// the ones holding a worker while they wait for their method
+	if ch := a.methods[method]; ch != nil {
+		slots = append(slots, ch)
+	}
+	if a.workers != nil {
+		slots = append(slots, a.workers)
+	}
+	release := func(n int) {
+		for _, ch := range slots[:n] {
+			<-ch
+		}
+	}
+
+	queued := false
+	defer func() {
+		if queued {
+			atomic.AddInt32(&a.queued, -1)
+		}
+	}()
+	for i, ch := range slots {
+		select {
+		case ch <- struct{}{}:
+			continue
+		default:
+		}
+		if !queued {
+			if atomic.AddInt32(&a.queued, 1) > a.maxQueued {
+				atomic.AddInt32(&a.queued, -1)
+				LoggerFromContext(ctx).Debug("Rejected call of overloaded RPC server", "method", method)
+				release(i)
+				return nil, &overloadedError{}
+			}
+			queued = true
+		}
+		select {
+		case ch <- struct{}{}:
+		case <-ctx.Done():
+			release(i)
+			return nil, ctx.Err()
+		}
+	}
+	return func() { release(len(slots)) }, nil
+}
+
+// SetAdmission bounds the calls the server runs, nil for no bound.
+func (s *Server) SetAdmission(cfg *AdmissionConfig) {
+	if cfg == nil {
+		s.admission.Store((*admission)(nil))
+		return
+	}
+	s.admission.Store(newAdmission(cfg))
+}
+
+func (s *Server) getAdmission() *admission {
+	a, _ := s.admission.Load().(*admission)
+	return a
+}
@@ -0,0 +1,88 @@
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAdmission(t *testing.T) {
+	server := NewServer()
+	if err := server.RegisterName("test", new(Service)); err != nil {
+		t.Fatal(err)
+	}
+	server.SetAdmission(&AdmissionConfig{
+		MaxConcurrent: 1,
+		MaxQueued:     1,
+		MethodLimits:  map[string]int{"test_sleep": 1, "test_echo": -1},
+	})
+	client := DialInProc(server)
+	defer client.Close()
+	a := server.getAdmission()
+
+	// the second sleep waits for the first one, and the third one is rejected
+	sleep := func(errs chan<- error) {
+		errs <- client.Call(nil, "test_sleep", 300*time.Millisecond)
+	}
+	errs := make(chan error, 2)
+	go sleep(errs)
+	time.Sleep(50 * time.Millisecond)
+	go sleep(errs)
+	for i := 0; atomic.LoadInt32(&a.queued) != 1; i++ {
+		if i == 100 {
+			t.Fatal("call not queued")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	err := client.Call(nil, "test_sleep", time.Millisecond)
+	if e, ok := err.(Error); !ok || e.ErrorCode() != -32005 {
+		t.Fatalf("expected overloaded error, got %v", err)
+	}
+
+	// the HTTP calls are rejected with 429 while the queue is full
+	body := `{"jsonrpc":"2.0","id":1,"method":"test_rets","params":[]}`
+	request := httptest.NewRequest(http.MethodPost, "http://url.com", strings.NewReader(body))
+	request.Header.Set("content-type", contentType)
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Fatalf("response code should be %d not %d", http.StatusTooManyRequests, recorder.Code)
+	}
+
+	// the exempt methods are not limited
+	var result Result
+	if err := client.Call(&result, "test_echo", "x", 1, &Args{"y"}); err != nil {
+		t.Fatalf("exempt call failed: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("admitted call failed: %v", err)
+		}
+	}
+	if err := client.Call(nil, "test_sleep", time.Millisecond); err != nil {
+		t.Fatalf("call failed once the load is gone: %v", err)
+	}
+
+	// the calls waiting give up once their context is done
+	go sleep(errs)
+	time.Sleep(50 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := client.CallContext(ctx, nil, "test_sleep", time.Millisecond); err == nil {
+		t.Fatal("expected deadline error")
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("admitted call failed: %v", err)
+	}
+	for i := 0; atomic.LoadInt32(&a.queued) != 0 || len(a.workers) != 0; i++ {
+		if i == 100 {
+			t.Fatal("calls left queued or running")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
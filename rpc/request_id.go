@@ -0,0 +1,63 @@
+package rpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// RequestIDHeader is the HTTP header of the ID of a request, taken from the
+// request if set by the client, e.g. a proxy, and returned in the response.
+const RequestIDHeader = "X-Request-Id"
+
+// maxRequestIDLength bounds the IDs taken from the clients, which end up in
+// the logs of all the processes of the cluster.
+const maxRequestIDLength = 64
+
+type requestIDKey struct{}
+
+// NewRequestID returns a random request ID.
+func NewRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// WithRequestID returns a copy of ctx carrying the request ID, which the
+// cluster RPC sends along to the slaves for the log lines of a request to be
+// correlated across the processes.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID carried by ctx, "" if none.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// LoggerFromContext returns the logger of the lines logged for the request
+// whose ID ctx carries, the root logger if none.
+func LoggerFromContext(ctx context.Context) log.Logger {
+	if id := RequestIDFromContext(ctx); id != "" {
+		return log.New("reqid", id)
+	}
+	return log.Root()
+}
+
+// validRequestID reports whether the ID sent by a client can be logged as is.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= '0' && c <= '9', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -298,6 +298,17 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 		return codec.CreateErrorResponse(&req.id, rpcErr), nil
 	}
 
+	// the calls not sent over HTTP get an ID each
+	if RequestIDFromContext(ctx) == "" {
+		ctx = WithRequestID(ctx, NewRequestID())
+	}
+	method := req.svcname + serviceMethodSeparator + formatName(req.callb.method.Name)
+	timeout := s.getTimeouts().timeout(method)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 	arguments := []reflect.Value{req.callb.rcvr}
 	if req.callb.hasCtx {
 		arguments = append(arguments, reflect.ValueOf(ctx))
@@ -306,14 +317,59 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 		arguments = append(arguments, req.args...)
 	}
 
-	// execute RPC method and return result
-	reply := req.callb.method.Func.Call(arguments)
+	release := func() {}
+	if a := s.getAdmission(); a != nil {
+		r, err := a.acquire(ctx, method)
+		if err != nil {
+			if timeout > 0 && err == context.DeadlineExceeded {
+				return codec.CreateErrorResponse(&req.id, &timeoutError{timeout}), nil
+			}
+			if e, ok := err.(Error); ok {
+				return codec.CreateErrorResponse(&req.id, e), nil
+			}
+			return codec.CreateErrorResponse(&req.id, &callbackError{err.Error()}), nil
+		}
+		release = r
+	}
+
+	// execute RPC method and return result, a call past its timeout is answered
+	// with a timeout error and left running, holding its admission, until it
+	// stops at its cancelled context
+	var reply []reflect.Value
+	if timeout > 0 {
+		done := make(chan []reflect.Value, 1)
+		go func() {
+			defer release()
+			done <- req.callb.method.Func.Call(arguments)
+		}()
+		select {
+		case reply = <-done:
+		case <-ctx.Done():
+			LoggerFromContext(ctx).Debug("RPC call cancelled", "method", method, "err", ctx.Err())
+			if ctx.Err() == context.DeadlineExceeded {
+				return codec.CreateErrorResponse(&req.id, &timeoutError{timeout}), nil
+			}
+			return codec.CreateErrorResponse(&req.id, &callbackError{ctx.Err().Error()}), nil
+		}
+	} else {
+		defer release()
+		reply = req.callb.method.Func.Call(arguments)
+	}
 	if len(reply) == 0 {
 		return codec.CreateResponse(req.id, nil), nil
 	}
 	if req.callb.errPos >= 0 { // test if method returned an error
 		if !reply[req.callb.errPos].IsNil() {
 			e := reply[req.callb.errPos].Interface().(error)
+			LoggerFromContext(ctx).Debug("RPC call failed", "method", method, "err", e)
+			// the error of a call cancelled at its timeout, e.g. the context
+			// error returned by a slave, is reported as a timeout
+			if timeout > 0 && ctx.Err() == context.DeadlineExceeded {
+				return codec.CreateErrorResponse(&req.id, &timeoutError{timeout}), nil
+			}
+			if de, ok := e.(DataError); ok {
+				return codec.CreateErrorResponseWithInfo(&req.id, &callbackError{e.Error()}, de.ErrorData()), nil
+			}
 			res := codec.CreateErrorResponse(&req.id, &callbackError{e.Error()})
 			return res, nil
 		}
@@ -0,0 +1,48 @@
+package rpc
+
+import (
+	"fmt"
+	"time"
+)
+
+// TimeoutConfig bounds the time the calls of a server run, for the slow calls,
+// e.g. of getLogs over many blocks, not to hold their goroutine and their
+// admission indefinitely. The context of a call is done at its timeout, which
+// cancels the calls to the slaves made with it as their gRPC deadline.
+type TimeoutConfig struct {
+	Default time.Duration            // of the calls, 0 for no timeout
+	Methods map[string]time.Duration // of the calls of a method, e.g. qkc_getLogs, negative for no timeout
+}
+
+func (c *TimeoutConfig) timeout(method string) time.Duration {
+	if c == nil {
+		return 0
+	}
+	if timeout, ok := c.Methods[method]; ok {
+		if timeout < 0 {
+			return 0
+		}
+		return timeout
+	}
+	return c.Default
+}
+
+// timeoutError is returned for the calls which failed once their timeout was
+// reached.
+type timeoutError struct {
+	timeout time.Duration
+}
+
+func (e *timeoutError) ErrorCode() int { return -32002 }
+
+func (e *timeoutError) Error() string { return fmt.Sprintf("request timed out after %v", e.timeout) }
+
+// SetTimeouts bounds the time the calls of the server run, nil for no bound.
+func (s *Server) SetTimeouts(cfg *TimeoutConfig) {
+	s.timeouts.Store(cfg)
+}
+
+func (s *Server) getTimeouts() *TimeoutConfig {
+	cfg, _ := s.timeouts.Load().(*TimeoutConfig)
+	return cfg
+}
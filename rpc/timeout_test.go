@@ -0,0 +1,77 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type TimeoutService struct{}
+
+// Wait returns once ctx is done, or after the duration.
+func (s *TimeoutService) Wait(ctx context.Context, duration time.Duration) error {
+	select {
+	case <-time.After(duration):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Sleep returns after the duration, ignoring the timeout of the call.
+func (s *TimeoutService) Sleep(duration time.Duration) error {
+	time.Sleep(duration)
+	return nil
+}
+
+func TestTimeouts(t *testing.T) {
+	server := NewServer()
+	if err := server.RegisterName("test", new(TimeoutService)); err != nil {
+		t.Fatal(err)
+	}
+	server.SetTimeouts(&TimeoutConfig{
+		Default: 50 * time.Millisecond,
+		Methods: map[string]time.Duration{"test_wait": -1},
+	})
+	client := DialInProc(server)
+	defer client.Close()
+
+	// the methods not bounded run to their end
+	if err := client.Call(nil, "test_wait", 100*time.Millisecond); err != nil {
+		t.Fatalf("call without timeout failed: %v", err)
+	}
+
+	// the calls are cancelled at the default timeout
+	server.SetTimeouts(&TimeoutConfig{Default: 50 * time.Millisecond})
+	start := time.Now()
+	err := client.Call(nil, "test_wait", time.Second)
+	if e, ok := err.(Error); !ok || e.ErrorCode() != -32002 {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Fatalf("call not cancelled at its timeout, took %v", elapsed)
+	}
+	if err := client.Call(nil, "test_wait", time.Millisecond); err != nil {
+		t.Fatalf("call within its timeout failed: %v", err)
+	}
+
+	// even if they don't stop at their context
+	start = time.Now()
+	err = client.Call(nil, "test_sleep", time.Second)
+	if e, ok := err.(Error); !ok || e.ErrorCode() != -32002 {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Fatalf("call not cut off at its timeout, took %v", elapsed)
+	}
+
+	// or of their method
+	server.SetTimeouts(&TimeoutConfig{Default: time.Second, Methods: map[string]time.Duration{"test_wait": 20 * time.Millisecond}})
+	if err := client.Call(nil, "test_wait", 200*time.Millisecond); err == nil {
+		t.Fatal("expected method timeout error")
+	}
+	server.SetTimeouts(nil)
+	if err := client.Call(nil, "test_wait", 100*time.Millisecond); err != nil {
+		t.Fatalf("call without timeouts failed: %v", err)
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/deckarep/golang-set"
 	"github.com/ethereum/go-ethereum"
@@ -79,6 +80,9 @@ type Server struct {
 	run      int32
 	codecsMu sync.Mutex
 	codecs   mapset.Set
+
+	admission atomic.Value // *admission, bounding the calls
+	timeouts  atomic.Value // *TimeoutConfig
 }
 
 // rpcRequest represents a raw incoming RPC request
@@ -97,6 +101,13 @@ type Error interface {
 	ErrorCode() int // returns the code
 }
 
+// DataError is an error returned by a callback with additional information in
+// the data of the JSON RPC error.
+type DataError interface {
+	Error() string          // returns the message
+	ErrorData() interface{} // returns the data
+}
+
 // ServerCodec implements reading, parsing and writing RPC messages for the server side of
 // a RPC session. Implementations must be go-routine safe since the codec can be called in
 // multiple go-routines concurrently.
@@ -124,13 +135,19 @@ type ServerCodec interface {
 type BlockNumber int64
 
 const (
-	// PendingBlockNumber  = BlockNumber(-2)
-	LatestBlockNumber   = BlockNumber(-1)
-	EarliestBlockNumber = BlockNumber(0)
+	// FinalizedBlockNumber is the last minor block confirmed by the root block
+	// CONFIRMATION_DEPTH below the root tip, or that root block itself.
+	FinalizedBlockNumber = BlockNumber(-4)
+	// ConfirmedBlockNumber is the last minor block confirmed by the root tip,
+	// or the root tip itself.
+	ConfirmedBlockNumber = BlockNumber(-3)
+	PendingBlockNumber   = BlockNumber(-2)
+	LatestBlockNumber    = BlockNumber(-1)
+	EarliestBlockNumber  = BlockNumber(0)
 )
 
 // UnmarshalJSON parses the given JSON fragment into a BlockNumber. It supports:
-// - "latest", "earliest" or "pending" as string arguments
+// - "latest", "earliest", "pending", "confirmed" or "finalized" as string arguments
 // - the block number
 // Returned errors:
 // - an invalid block number error when the given argument isn't a known strings
@@ -149,7 +166,14 @@ func (bn *BlockNumber) UnmarshalJSON(data []byte) error {
 		*bn = LatestBlockNumber
 		return nil
 	case "pending":
-		return errors.New("not support pending")
+		*bn = PendingBlockNumber
+		return nil
+	case "confirmed":
+		*bn = ConfirmedBlockNumber
+		return nil
+	case "finalized":
+		*bn = FinalizedBlockNumber
+		return nil
 	}
 
 	blckNum, err := hexutil.DecodeUint64(input)
@@ -199,6 +223,10 @@ func (args *FilterQuery) UnmarshalJSON(data []byte) error {
 		}
 		args.BlockHash = raw.BlockHash
 	} else {
+		if (raw.FromBlock != nil && *raw.FromBlock == PendingBlockNumber) ||
+			(raw.ToBlock != nil && *raw.ToBlock == PendingBlockNumber) {
+			return errors.New("not support pending")
+		}
 		if raw.FromBlock != nil {
 			args.FromBlock = big.NewInt(raw.FromBlock.Int64())
 		}
@@ -40,12 +40,14 @@ func TestBlockNumberJSONUnmarshal(t *testing.T) {
 		8:  {`"0x8000000000000000"`, true, BlockNumber(0)},
 		9:  {"0", true, BlockNumber(0)},
 		10: {`"ff"`, true, BlockNumber(0)},
-		// 11: {`"pending"`, false, PendingBlockNumber},
 		11: {`"latest"`, false, LatestBlockNumber},
 		12: {`"earliest"`, false, EarliestBlockNumber},
 		13: {`someString`, true, BlockNumber(0)},
 		14: {`""`, true, BlockNumber(0)},
 		15: {``, true, BlockNumber(0)},
+		16: {`"pending"`, false, PendingBlockNumber},
+		17: {`"confirmed"`, false, ConfirmedBlockNumber},
+		18: {`"finalized"`, false, FinalizedBlockNumber},
 	}
 
 	for i, test := range tests {
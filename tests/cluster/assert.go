@@ -0,0 +1,35 @@
+package cluster
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/core/types"
+)
+
+// AssertBalance fails the test if the balance of the genesis token of the
+// address is not the expected one.
+func (c *Cluster) AssertBalance(t testing.TB, addr account.Address, expected *big.Int) {
+	t.Helper()
+	balance, err := c.Balance(addr)
+	if err != nil {
+		t.Fatalf("failed to get balance of %x: %v", addr.ToBytes(), err)
+	}
+	if balance.Cmp(expected) != 0 {
+		t.Errorf("balance of %x: expected %v, got %v", addr.ToBytes(), expected, balance)
+	}
+}
+
+// AssertSuccess fails the test if the transaction is not mined, or failed.
+func (c *Cluster) AssertSuccess(t testing.TB, tx *types.Transaction) *types.Receipt {
+	t.Helper()
+	receipt, err := c.Receipt(tx)
+	if err != nil {
+		t.Fatalf("failed to get receipt of %x: %v", tx.Hash(), err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		t.Errorf("transaction %x failed", tx.Hash())
+	}
+	return receipt
+}
@@ -0,0 +1,252 @@
+// Package cluster runs a master and its slaves in the test process, so the Go
+// tests can exercise a full cluster end to end without docker.
+//
+// The databases are kept in memory, the blocks are simulated and only mined
+// when the test asks for them, and the p2p network is not started: the master
+// and the slaves only talk over their cluster RPC on the loopback interface.
+// The accounts of the dev mnemonic are funded in every shard at genesis, while
+// the coinbases are the empty addresses, so the balances of the accounts only
+// change by the transactions of the test.
+package cluster
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/cluster/config"
+	"github.com/QuarkChain/goquarkchain/cluster/master"
+	"github.com/QuarkChain/goquarkchain/cluster/service"
+	"github.com/QuarkChain/goquarkchain/cluster/shard"
+	"github.com/QuarkChain/goquarkchain/cluster/slave"
+)
+
+const localhost = "127.0.0.1"
+
+// Options are the shape of the cluster.
+type Options struct {
+	Slaves    int    // number of the slaves, a power of 2, 1 if 0
+	ChainSize uint32 // number of the chains, 2 if 0
+	ShardSize uint32 // number of the shards of each chain, a power of 2, 1 if 0
+	Accounts  int    // number of the prefunded accounts, 2 if 0
+
+	// Config, if set, is called with the cluster config before the cluster is
+	// created, to change what the options don't cover.
+	Config func(cfg *config.ClusterConfig)
+}
+
+// Cluster is a master and its slaves running in the test process.
+type Cluster struct {
+	cfg        *config.ClusterConfig
+	accounts   []account.Account
+	masterNode *service.Node
+	slaveNodes []*service.Node
+	master     *master.QKCMasterBackend
+	slaves     []*slave.SlaveBackend
+
+	mu     sync.Mutex
+	nonces map[account.Address]uint64 // next nonce of the senders
+}
+
+// New starts a cluster of the options, which must be stopped by Stop. The
+// first root block is mined, so the cross-shard transfers can be sent at once.
+func New(opts Options) (*Cluster, error) {
+	if opts.Slaves == 0 {
+		opts.Slaves = 1
+	}
+	if opts.ChainSize == 0 {
+		opts.ChainSize = 2
+	}
+	if opts.ShardSize == 0 {
+		opts.ShardSize = 1
+	}
+	if opts.Accounts == 0 {
+		opts.Accounts = 2
+	}
+	cfg, accounts, err := newConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Cluster{cfg: cfg, accounts: accounts, nonces: make(map[account.Address]uint64)}
+	for _, slv := range cfg.SlaveList {
+		slv := slv
+		node, err := newNode(slv.ID, fmt.Sprintf("%s:%d", slv.IP, slv.Port))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create slave %s: %v", slv.ID, err)
+		}
+		node.SetIsMaster(false)
+		if err := node.Register(func(ctx *service.ServiceContext) (service.Service, error) {
+			return slave.New(ctx, cfg, slv)
+		}); err != nil {
+			return nil, err
+		}
+		c.slaveNodes = append(c.slaveNodes, node)
+	}
+	c.masterNode, err = newNode("master", fmt.Sprintf("%s:%d", cfg.Quarkchain.GRPCHost, cfg.Quarkchain.GRPCPort))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create master: %v", err)
+	}
+	c.masterNode.SetIsMaster(true)
+	if err := c.masterNode.Register(func(ctx *service.ServiceContext) (service.Service, error) {
+		return master.New(ctx, cfg)
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := c.start(); err != nil {
+		c.Stop()
+		return nil, err
+	}
+	// the shards can't receive deposits until a root block confirms their
+	// genesis blocks
+	if _, err := c.MineRootBlock(); err != nil {
+		c.Stop()
+		return nil, err
+	}
+	return c, nil
+}
+
+// newConfig returns the config of the dev cluster of the options on free
+// local ports, with the prefunded accounts.
+func newConfig(opts Options) (*config.ClusterConfig, []account.Account, error) {
+	accounts, err := config.DevAccounts(config.DevMnemonic, opts.Accounts)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg := config.NewClusterConfig()
+	cfg.Quarkchain.Update(opts.ChainSize, opts.ShardSize, 1, 1)
+	if err := cfg.SetDevMode(opts.Slaves, 1, accounts); err != nil {
+		return nil, nil, err
+	}
+	// the rewards and the fees go nowhere
+	for _, fullShardID := range cfg.Quarkchain.GetGenesisShardIds() {
+		cfg.Quarkchain.GetShardConfigByFullShardID(fullShardID).CoinbaseAddress = account.CreatEmptyAddress(fullShardID)
+	}
+	cfg.Quarkchain.Root.CoinbaseAddress = account.CreatEmptyAddress(0)
+	cfg.DbPathRoot = ""
+	cfg.TxJournal = ""
+	cfg.XShardJournal = ""
+	cfg.Clean = true
+	if opts.Config != nil {
+		opts.Config(cfg)
+	}
+
+	ports, err := freePorts(len(cfg.SlaveList) + 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg.Quarkchain.GRPCHost = localhost
+	cfg.Quarkchain.GRPCPort = ports[0]
+	for i, slv := range cfg.SlaveList {
+		slv.IP = localhost
+		slv.Port = ports[i+1]
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return cfg, accounts, nil
+}
+
+// newNode returns a node without data directory, p2p network or JSON RPC
+// serving the cluster RPC on the endpoint.
+func newNode(name, endpoint string) (*service.Node, error) {
+	nodeCfg := service.DefaultConfig
+	nodeCfg.Name = name
+	nodeCfg.DataDir = ""
+	nodeCfg.IPCPath = ""
+	nodeCfg.GRPCEndpoint = endpoint
+	return service.New(&nodeCfg)
+}
+
+// freePorts returns count ports free on the loopback interface.
+func freePorts(count int) ([]uint16, error) {
+	listeners := make([]net.Listener, 0, count)
+	defer func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+	}()
+	ports := make([]uint16, 0, count)
+	for i := 0; i < count; i++ {
+		l, err := net.Listen("tcp", localhost+":0")
+		if err != nil {
+			return nil, err
+		}
+		listeners = append(listeners, l)
+		ports = append(ports, uint16(l.Addr().(*net.TCPAddr).Port))
+	}
+	return ports, nil
+}
+
+// start starts the slaves, then the master which connects to them.
+func (c *Cluster) start() error {
+	for i, node := range c.slaveNodes {
+		if err := node.Start(); err != nil {
+			return fmt.Errorf("failed to start slave %s: %v", c.cfg.SlaveList[i].ID, err)
+		}
+		var slv *slave.SlaveBackend
+		if err := node.Service(&slv); err != nil {
+			return err
+		}
+		c.slaves = append(c.slaves, slv)
+	}
+	if err := c.masterNode.Start(); err != nil {
+		return fmt.Errorf("failed to start master: %v", err)
+	}
+	if err := c.masterNode.Service(&c.master); err != nil {
+		return err
+	}
+	return c.master.Start()
+}
+
+// Stop stops the master, then the slaves.
+func (c *Cluster) Stop() {
+	if c.masterNode != nil {
+		c.masterNode.Stop()
+	}
+	for _, node := range c.slaveNodes {
+		node.Stop()
+	}
+}
+
+// Config returns the config of the cluster.
+func (c *Cluster) Config() *config.ClusterConfig {
+	return c.cfg
+}
+
+// Accounts returns the prefunded accounts, funded with config.DevBalance of
+// the genesis token in every shard.
+func (c *Cluster) Accounts() []account.Account {
+	return c.accounts
+}
+
+// Master returns the master.
+func (c *Cluster) Master() *master.QKCMasterBackend {
+	return c.master
+}
+
+// Slaves returns the slaves in the order of the config.
+func (c *Cluster) Slaves() []*slave.SlaveBackend {
+	return c.slaves
+}
+
+// FullShardIDs returns the full shard IDs of the cluster in increasing order.
+func (c *Cluster) FullShardIDs() []uint32 {
+	fullShardIDs := c.cfg.Quarkchain.GetGenesisShardIds()
+	sort.Slice(fullShardIDs, func(i, j int) bool { return fullShardIDs[i] < fullShardIDs[j] })
+	return fullShardIDs
+}
+
+// Shard returns the shard of the full shard ID, or an error if no slave runs
+// it.
+func (c *Cluster) Shard(fullShardID uint32) (*shard.ShardBackend, error) {
+	for _, slv := range c.slaves {
+		if shrd := slv.GetShard(fullShardID); shrd != nil {
+			return shrd, nil
+		}
+	}
+	return nil, fmt.Errorf("shard %d not found", fullShardID)
+}
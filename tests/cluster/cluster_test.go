@@ -0,0 +1,609 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/cluster/config"
+	"github.com/QuarkChain/goquarkchain/cluster/eventsink"
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/common/hexutil"
+	"github.com/QuarkChain/goquarkchain/core"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/internal/encoder"
+	"github.com/QuarkChain/goquarkchain/internal/qkcapi"
+	qrpc "github.com/QuarkChain/goquarkchain/rpc"
+	"github.com/QuarkChain/goquarkchain/serialize"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCrossShardTransfer(t *testing.T) {
+	c, err := New(Options{Slaves: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+
+	fullShardIDs := c.FullShardIDs()
+	assert.Len(t, fullShardIDs, 2)
+	assert.NotEqual(t, c.Slaves()[0].GetShard(fullShardIDs[0]) == nil, c.Slaves()[1].GetShard(fullShardIDs[0]) == nil)
+	src, dst := fullShardIDs[0], fullShardIDs[1]
+	acc0, acc1 := c.Accounts()[0], c.Accounts()[1]
+
+	tx1, err := c.Transfer(acc0, src, acc1.QKCAddress.AddressInShard(src), big.NewInt(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx2, err := c.Transfer(acc0, src, acc1.QKCAddress.AddressInShard(dst), big.NewInt(2000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootBlock, err := c.Mine()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uint32(2), rootBlock.Number())
+	assert.Len(t, rootBlock.MinorBlockHeaders(), 2)
+	r1 := c.AssertSuccess(t, tx1)
+	c.AssertSuccess(t, tx2)
+
+	// the deposit is received by the first block of the destination shard on
+	// top of the root block
+	c.AssertBalance(t, acc1.QKCAddress.AddressInShard(dst), config.DevBalance)
+	_, err = c.MineMinorBlock(dst)
+	assert.NoError(t, err)
+
+	// the start gas of the cross-shard transfer is spent in the source shard
+	fees := new(big.Int).SetUint64(r1.GasUsed + TransferGas)
+	fees.Mul(fees, TransferGasPrice)
+	spent := new(big.Int).Add(big.NewInt(3000), fees)
+	c.AssertBalance(t, acc0.QKCAddress.AddressInShard(src), new(big.Int).Sub(config.DevBalance, spent))
+	c.AssertBalance(t, acc1.QKCAddress.AddressInShard(src), new(big.Int).Add(config.DevBalance, big.NewInt(1000)))
+	c.AssertBalance(t, acc1.QKCAddress.AddressInShard(dst), new(big.Int).Add(config.DevBalance, big.NewInt(2000)))
+}
+
+func TestShardMaintenance(t *testing.T) {
+	c, err := New(Options{Slaves: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+
+	fullShardID := c.FullShardIDs()[0]
+	branch := account.Branch{Value: fullShardID}
+	shrd, err := c.Shard(fullShardID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.MineMinorBlock(fullShardID); err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.Master().ShardMaintenance(context.Background(), branch, rpc.MaintenanceReindex)
+	assert.Error(t, err)
+
+	rsps, err := c.Master().ShardMaintenance(context.Background(), branch, rpc.MaintenancePause)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rsp := range rsps {
+		assert.True(t, rsp.Paused)
+	}
+	_, err = c.MineMinorBlock(fullShardID)
+	assert.EqualError(t, err, fmt.Sprintf("failed to add minor block of shard %d: %v", fullShardID, rpc.ErrShardPaused))
+
+	// the root blocks are added once resumed
+	rootTip := shrd.MinorBlockChain.GetRootTip().Number
+	rootBlock, err := c.MineRootBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, rootTip, shrd.MinorBlockChain.GetRootTip().Number)
+	rsps, err = c.Master().ShardMaintenance(context.Background(), branch, rpc.MaintenanceReindex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rsp := range rsps {
+		assert.Equal(t, uint64(1), rsp.ReindexedBlocks)
+	}
+	// the in-memory database can't be compacted
+	_, err = c.Master().ShardMaintenance(context.Background(), branch, rpc.MaintenanceCompact)
+	assert.Error(t, err)
+
+	rsps, err = c.Master().ShardMaintenance(context.Background(), branch, rpc.MaintenanceResume)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rsp := range rsps {
+		assert.False(t, rsp.Paused)
+	}
+	assert.Equal(t, rootBlock.Hash(), shrd.MinorBlockChain.GetRootTip().Hash())
+	_, err = c.MineMinorBlock(fullShardID)
+	assert.NoError(t, err)
+}
+
+func TestMinorBlockConfirmation(t *testing.T) {
+	c, err := New(Options{Slaves: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+
+	fullShardID := c.FullShardIDs()[0]
+	mBlock, err := c.MineMinorBlock(fullShardID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rBlock, _ := c.Master().GetMinorBlockConfirmation(mBlock.Hash(), fullShardID)
+	assert.Nil(t, rBlock)
+
+	// a fork confirming the minor block too doesn't replace the canonical
+	// root block
+	iBlock, _, _, err := c.Master().CreateBlockToMine(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	canonical := iBlock.(*types.RootBlock)
+	coinbase := c.Accounts()[0].QKCAddress
+	iBlock, _, _, err = c.Master().CreateBlockToMine(&coinbase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fork := iBlock.(*types.RootBlock)
+	assert.NotEqual(t, canonical.Hash(), fork.Hash())
+	assert.NoError(t, c.Master().AddRootBlock(canonical))
+	assert.NoError(t, c.Master().AddRootBlock(fork))
+	assert.Equal(t, canonical.Hash(), c.Master().CurrentBlock().Hash())
+
+	rBlock, confirmations := c.Master().GetMinorBlockConfirmation(mBlock.Hash(), fullShardID)
+	if assert.NotNil(t, rBlock) {
+		assert.Equal(t, canonical.Hash(), rBlock.Hash())
+	}
+	assert.Equal(t, uint64(0), confirmations)
+	if _, err = c.MineRootBlock(); err != nil {
+		t.Fatal(err)
+	}
+	_, confirmations = c.Master().GetMinorBlockConfirmation(mBlock.Hash(), fullShardID)
+	assert.Equal(t, uint64(1), confirmations)
+}
+
+func TestBlockTags(t *testing.T) {
+	c, err := New(Options{Slaves: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+	c.Config().Master.ConfirmationDepth = 1
+
+	fullShardID := c.FullShardIDs()[0]
+	for i := 0; i < 2; i++ {
+		if _, err = c.MineMinorBlock(fullShardID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rBlock, err := c.MineRootBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.MineMinorBlock(fullShardID); err != nil {
+		t.Fatal(err)
+	}
+
+	api := qkcapi.NewPublicBlockChainAPI(c.Master())
+	needExtraInfo := false
+	heightOf := func(blockNr qrpc.BlockNumber) (uint64, error) {
+		block, err := api.GetMinorBlockByHeight(context.Background(), hexutil.Uint(fullShardID), &blockNr, nil, &needExtraInfo, nil)
+		if err != nil {
+			return 0, err
+		}
+		return uint64(block["height"].(hexutil.Uint64)), nil
+	}
+	// the latest block, and the last one confirmed by the root tip
+	height, err := heightOf(qrpc.LatestBlockNumber)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), height)
+	height, err = heightOf(qrpc.ConfirmedBlockNumber)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), height)
+	// the root block below the tip confirms the genesis block of the shard
+	height, err = heightOf(qrpc.FinalizedBlockNumber)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), height)
+
+	if _, err = c.MineRootBlock(); err != nil {
+		t.Fatal(err)
+	}
+	height, err = heightOf(qrpc.FinalizedBlockNumber)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), height)
+	height, err = heightOf(qrpc.ConfirmedBlockNumber)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), height)
+
+	finalized := qrpc.FinalizedBlockNumber
+	block, err := api.GetRootBlockByHeight(&finalized, &needExtraInfo, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, rBlock.Hash(), block["hash"])
+	_, err = api.GetMinorBlockByHeight(context.Background(), hexutil.Uint(fullShardID), new(qrpc.BlockNumber), nil, &needExtraInfo, nil)
+	assert.NoError(t, err)
+	pending := qrpc.PendingBlockNumber
+	_, err = api.GetMinorBlockByHeight(context.Background(), hexutil.Uint(fullShardID), &pending, nil, &needExtraInfo, nil)
+	assert.Error(t, err)
+}
+
+func TestPendingState(t *testing.T) {
+	c, err := New(Options{Slaves: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+
+	fullShardID := c.FullShardIDs()[0]
+	acc0, acc1 := c.Accounts()[0], c.Accounts()[1]
+	from, to := acc0.QKCAddress.AddressInShard(fullShardID), acc1.QKCAddress.AddressInShard(fullShardID)
+	if _, err = c.Transfer(acc0, fullShardID, to, big.NewInt(1000)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the tx in the pool is applied to the pending state only
+	data, err := c.Master().GetPrimaryAccountData(context.Background(), &from, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uint64(0), data.TransactionCount)
+	data, err = c.Master().GetPendingAccountData(context.Background(), &from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uint64(1), data.TransactionCount)
+	data, err = c.Master().GetPendingAccountData(context.Background(), &to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenID := c.Config().Quarkchain.GetDefaultChainTokenID()
+	assert.Equal(t, new(big.Int).Add(config.DevBalance, big.NewInt(1000)), data.Balance.GetTokenBalance(tokenID))
+}
+
+func TestBlockPages(t *testing.T) {
+	c, err := New(Options{Slaves: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+
+	fullShardID := c.FullShardIDs()[0]
+	acc0, acc1 := c.Accounts()[0], c.Accounts()[1]
+	txs := make([]*types.Transaction, 3)
+	for i := range txs {
+		if txs[i], err = c.Transfer(acc0, fullShardID, acc1.QKCAddress.AddressInShard(fullShardID), big.NewInt(1000)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mBlock, err := c.MineMinorBlock(fullShardID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, mBlock.Transactions(), 3)
+
+	api := qkcapi.NewPublicBlockChainAPI(c.Master())
+	includeTxs, needExtraInfo := true, false
+	page := &qkcapi.BlockPageArgs{Offset: 1, Limit: 1, IncludeReceipts: true}
+	block, err := api.GetMinorBlockByHeight(context.Background(), hexutil.Uint(fullShardID), nil, &includeTxs, &needExtraInfo, page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, hexutil.Uint64(3), block["transactionCount"])
+	assert.Equal(t, hexutil.Uint64(2), block["nextTransactionOffset"])
+	if pageTxs := block["transactions"].([]map[string]interface{}); assert.Len(t, pageTxs, 1) {
+		assert.Equal(t, txs[1].Hash(), pageTxs[0]["hash"])
+	}
+	if receipts := block["receipts"].([]map[string]interface{}); assert.Len(t, receipts, 1) {
+		assert.Equal(t, txs[1].Hash().String(), receipts[0]["transactionHash"])
+		assert.Equal(t, hexutil.Uint64(1), receipts[0]["status"])
+	}
+
+	// the last page
+	page = &qkcapi.BlockPageArgs{Offset: 2}
+	block, err = api.GetMinorBlockByHeight(context.Background(), hexutil.Uint(fullShardID), nil, nil, &needExtraInfo, page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, block["transactions"], 1)
+	assert.NotContains(t, block, "nextTransactionOffset")
+	assert.NotContains(t, block, "receipts")
+
+	rBlock, err := c.MineRootBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	height := qrpc.BlockNumber(rBlock.Number())
+	block, err = api.GetRootBlockByHeight(&height, &needExtraInfo, &qkcapi.BlockPageArgs{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, hexutil.Uint64(len(rBlock.MinorBlockHeaders())), block["minorBlockHeaderCount"])
+	assert.Len(t, block["minorBlockHeaders"], 1)
+	_, err = api.GetRootBlockByHeight(&height, &needExtraInfo, &qkcapi.BlockPageArgs{IncludeReceipts: true})
+	assert.Error(t, err)
+}
+
+func TestTraceBlock(t *testing.T) {
+	c, err := New(Options{Slaves: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+
+	fullShardID := c.FullShardIDs()[0]
+	acc0, acc1 := c.Accounts()[0], c.Accounts()[1]
+	tx, err := c.Transfer(acc0, fullShardID, acc1.QKCAddress.AddressInShard(fullShardID), big.NewInt(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mBlock, err := c.MineMinorBlock(fullShardID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api := qkcapi.NewPrivateDebugAPI(c.Master())
+	blockID := encoder.IDEncoder(mBlock.Hash().Bytes(), fullShardID)
+	traces, err := api.TraceBlockByHash(context.Background(), blockID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, traces, 1) {
+		var trace core.TxTrace
+		assert.NoError(t, json.Unmarshal(traces[0], &trace))
+		assert.Equal(t, tx.Hash(), trace.TxHash)
+		assert.Equal(t, uint64(21000), trace.Gas)
+		assert.False(t, trace.Failed)
+	}
+	_, err = api.TraceBlockByHash(context.Background(), encoder.IDEncoder(common.Hash{1}.Bytes(), fullShardID), nil)
+	assert.Error(t, err)
+}
+
+func TestSimulateBundle(t *testing.T) {
+	c, err := New(Options{Slaves: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+
+	fullShardID := c.FullShardIDs()[0]
+	acc0, acc1 := c.Accounts()[0], c.Accounts()[1]
+	to := acc1.QKCAddress.AddressInShard(fullShardID)
+	rawTxs := make([]hexutil.Bytes, 0)
+	for nonce := uint64(0); nonce < 2; nonce++ {
+		tx, err := c.SignTransfer(acc0, fullShardID, to, big.NewInt(1000), nonce)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := rlp.EncodeToBytes(tx.EvmTx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rawTxs = append(rawTxs, raw)
+	}
+
+	api := qkcapi.NewPublicBlockChainAPI(c.Master())
+	res, err := api.SimulateBundle(context.Background(), rawTxs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, hexutil.Uint64(42000), res["gasUsed"])
+	results := res["results"].([]map[string]interface{})
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, hexutil.Uint64(1), results[1]["status"])
+		assert.Equal(t, hexutil.Uint64(21000), results[1]["gasUsed"])
+		assert.Equal(t, hexutil.Uint64(42000), results[1]["cumulativeGasUsed"])
+	}
+	// the txs are not sent
+	mBlock, err := c.MineMinorBlock(fullShardID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, mBlock.Transactions(), 0)
+
+	// a tx which can't be applied fails the bundle
+	_, err = api.SimulateBundle(context.Background(), []hexutil.Bytes{rawTxs[1]}, nil)
+	assert.Error(t, err)
+}
+
+func TestEventSinks(t *testing.T) {
+	events := make(chan *eventsink.Event, 64)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ev := new(eventsink.Event)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(ev))
+		events <- ev
+	}))
+	defer server.Close()
+	// the accounts of the cluster are the dev accounts
+	accounts, err := config.DevAccounts(config.DevMnemonic, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	watched := accounts[1]
+	c, err := New(Options{Slaves: 1, Accounts: 3, Config: func(cfg *config.ClusterConfig) {
+		cfg.Master.EventSinks = []*config.EventSinkConfig{{Type: config.EventSinkHTTP, URL: server.URL}}
+		cfg.Master.EventWatchAddresses = []string{hexutil.Encode(watched.QKCAddress.Recipient.Bytes())}
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+
+	// the events of the blocks mined so far are not checked
+	next := func(typ string) *eventsink.Event {
+		for {
+			select {
+			case ev := <-events:
+				if ev.Type == typ {
+					return ev
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("no %s event", typ)
+				return nil
+			}
+		}
+	}
+
+	fullShardID := c.FullShardIDs()[0]
+	tx, err := c.Transfer(c.Accounts()[0], fullShardID, watched.QKCAddress.AddressInShard(fullShardID), big.NewInt(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Transfer(c.Accounts()[0], fullShardID, c.Accounts()[2].QKCAddress.AddressInShard(fullShardID), big.NewInt(1000)); err != nil {
+		t.Fatal(err)
+	}
+	mBlock, err := c.MineMinorBlock(fullShardID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev := next(config.EventShardTip)
+	for ev.Data["hash"] != mBlock.Hash().Hex() {
+		ev = next(config.EventShardTip)
+	}
+	assert.Equal(t, "0x2", ev.Data["txCount"])
+	ev = next(config.EventAddress)
+	assert.Equal(t, tx.Hash().Hex(), ev.Data["hash"])
+	assert.Equal(t, "in", ev.Data["direction"])
+	assert.Equal(t, hexutil.Encode(watched.QKCAddress.Recipient.Bytes()), ev.Data["address"])
+
+	rBlock, err := c.MineRootBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev = next(config.EventRootBlock)
+	for ev.Data["hash"] != rBlock.Hash().Hex() {
+		ev = next(config.EventRootBlock)
+	}
+	assert.Equal(t, hexutil.EncodeUint64(rBlock.NumberU64()), ev.Data["height"])
+}
+
+func TestTotalBalance(t *testing.T) {
+	c, err := New(Options{Slaves: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+
+	acc0, acc1 := c.Accounts()[0], c.Accounts()[1]
+	tokenID := c.Config().Quarkchain.GetDefaultChainTokenID()
+	sum := func(acc account.Account) *big.Int {
+		total := new(big.Int)
+		for _, id := range c.FullShardIDs() {
+			balance, err := c.Balance(acc.QKCAddress.AddressInShard(id))
+			if err != nil {
+				t.Fatal(err)
+			}
+			total.Add(total, balance)
+		}
+		return total
+	}
+	api := qkcapi.NewPublicBlockChainAPI(c.Master())
+
+	// the total balance is the same for any full shard key of the address
+	addr := acc1.QKCAddress.AddressInShard(c.FullShardIDs()[1])
+	balance, cached, err := c.Master().GetTotalBalance(context.Background(), &addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, cached)
+	assert.Equal(t, sum(acc1), balance.Balances.GetTokenBalance(tokenID))
+	assert.Equal(t, c.FullShardIDs(), balance.Shards)
+	assert.Empty(t, balance.Errors)
+	fields, err := api.GetTotalBalance(context.Background(), acc1.QKCAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, true, fields["cached"])
+	assert.Equal(t, hexutil.Uint(len(c.FullShardIDs())), fields["shardCount"])
+
+	// a new block of a shard refreshes it
+	fullShardID := c.FullShardIDs()[0]
+	if _, err = c.Transfer(acc0, fullShardID, acc1.QKCAddress.AddressInShard(fullShardID), big.NewInt(1000)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.MineMinorBlock(fullShardID); err != nil {
+		t.Fatal(err)
+	}
+	updated, cached, err := c.Master().GetTotalBalance(context.Background(), &addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, cached)
+	assert.Equal(t, new(big.Int).Add(balance.Balances.GetTokenBalance(tokenID), big.NewInt(1000)), updated.Balances.GetTokenBalance(tokenID))
+	assert.Equal(t, sum(acc1), updated.Balances.GetTokenBalance(tokenID))
+}
+
+func TestRawBlocks(t *testing.T) {
+	c, err := New(Options{Slaves: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+
+	fullShardID := c.FullShardIDs()[0]
+	acc0, acc1 := c.Accounts()[0], c.Accounts()[1]
+	tx, err := c.Transfer(acc0, fullShardID, acc1.QKCAddress.AddressInShard(fullShardID), big.NewInt(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mBlock, err := c.MineMinorBlock(fullShardID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.MineMinorBlock(fullShardID); err != nil {
+		t.Fatal(err)
+	}
+	api := qkcapi.NewPublicBlockChainAPI(c.Master())
+
+	// the blocks decode to the ones mined, with their receipts and txs
+	fields, err := api.GetRawBlocks(context.Background(), hexutil.Uint(fullShardID), 0,
+		&qkcapi.RawBlocksArgs{Limit: 2, IncludeReceipts: true, IncludeTransactions: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, hexutil.Uint64(2), fields["nextHeight"])
+	blocks := fields["blocks"].([]map[string]interface{})
+	if assert.Len(t, blocks, 2) {
+		assert.Equal(t, hexutil.Uint64(1), blocks[1]["height"])
+		assert.Equal(t, mBlock.Hash(), blocks[1]["hash"])
+		block := new(types.MinorBlock)
+		assert.NoError(t, serialize.DeserializeFromBytes(blocks[1]["block"].(hexutil.Bytes), block))
+		assert.Equal(t, mBlock.Hash(), block.Hash())
+		var receipts types.Receipts
+		assert.NoError(t, rlp.DecodeBytes(blocks[1]["receipts"].(hexutil.Bytes), &receipts))
+		assert.Equal(t, block.Meta().ReceiptHash, types.DeriveSha(receipts))
+		raw, err := rlp.EncodeToBytes(tx.EvmTx)
+		assert.NoError(t, err)
+		assert.Equal(t, []hexutil.Bytes{raw}, blocks[1]["transactions"])
+	}
+
+	// the last page ends at the tip
+	fields, err = api.GetRawBlocks(context.Background(), hexutil.Uint(fullShardID), 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, fields["nextHeight"])
+	blocks = fields["blocks"].([]map[string]interface{})
+	if assert.Len(t, blocks, 2) {
+		assert.Equal(t, hexutil.Uint64(2), blocks[1]["height"])
+		assert.NotContains(t, blocks[1], "receipts")
+		assert.NotContains(t, blocks[1], "transactions")
+	}
+
+	_, err = api.GetRawBlocks(context.Background(), hexutil.Uint(fullShardID), 0, &qkcapi.RawBlocksArgs{Limit: 1001})
+	assert.Error(t, err)
+}
@@ -0,0 +1,80 @@
+package cluster
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/core/types"
+)
+
+// MineMinorBlock mines a block on the tip of the shard with its pending
+// transactions and deposits, and adds it like the miner of the shard does.
+func (c *Cluster) MineMinorBlock(fullShardID uint32) (*types.MinorBlock, error) {
+	shrd, err := c.Shard(fullShardID)
+	if err != nil {
+		return nil, err
+	}
+	iBlock, _, _, err := shrd.CreateBlockToMine(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create minor block of shard %d: %v", fullShardID, err)
+	}
+	block := iBlock.(*types.MinorBlock)
+	if err := shrd.AddMinorBlock(block); err != nil {
+		return nil, fmt.Errorf("failed to add minor block of shard %d: %v", fullShardID, err)
+	}
+	return block, nil
+}
+
+// MineRootBlock mines a root block confirming the minor blocks not confirmed
+// yet, and adds it like the miner of the master does.
+func (c *Cluster) MineRootBlock() (*types.RootBlock, error) {
+	// the root block can't be older than the minor blocks it confirms, the
+	// simulated blocks may be ahead of the clock when mined back to back
+	var latest uint64
+	for _, fullShardID := range c.FullShardIDs() {
+		shrd, err := c.Shard(fullShardID)
+		if err != nil {
+			return nil, err
+		}
+		if t := shrd.MinorBlockChain.CurrentBlock().Time(); t > latest {
+			latest = t
+		}
+	}
+	if wait := time.Until(time.Unix(int64(latest), 0)); wait > 0 {
+		time.Sleep(wait)
+	}
+
+	iBlock, _, _, err := c.master.CreateBlockToMine(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create root block: %v", err)
+	}
+	block := iBlock.(*types.RootBlock)
+	if err := c.master.AddRootBlock(block); err != nil {
+		return nil, fmt.Errorf("failed to add root block: %v", err)
+	}
+	return block, nil
+}
+
+// Mine mines a block in every shard, then a root block confirming them, so
+// the transactions pending in the shards are mined and the cross-shard ones
+// are received by their destination shards on the next call.
+func (c *Cluster) Mine() (*types.RootBlock, error) {
+	for _, fullShardID := range c.FullShardIDs() {
+		if _, err := c.MineMinorBlock(fullShardID); err != nil {
+			return nil, err
+		}
+	}
+	return c.MineRootBlock()
+}
+
+// WaitFor calls cond until it is true, or fails once the timeout elapsed.
+func (c *Cluster) WaitFor(cond func() bool, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("condition not met after %v", timeout)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/params"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	// TransferGas is the start gas of the transfers, enough for the
+	// cross-shard ones, while the ones in the shard use 21000 gas.
+	TransferGas = params.DefaultCrossShardTxGasLimit.Uint64()
+	// TransferGasPrice is the gas price of the transfers.
+	TransferGasPrice = new(big.Int).SetUint64(1e9 + 1)
+)
+
+// Transfer sends the value of the genesis token from the account in the shard
+// of the full shard key to the address, which is a cross-shard transfer if the
+// address is in another shard. The nonces of the senders are counted by the
+// cluster, so the transfers of a sender can be sent before they are mined.
+func (c *Cluster) Transfer(from account.Account, fromFullShardKey uint32, to account.Address, value *big.Int) (*types.Transaction, error) {
+	fromAddr := from.QKCAddress.AddressInShard(fromFullShardKey)
+	nonce, err := c.nextNonce(fromAddr)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := c.SignTransfer(from, fromFullShardKey, to, value, nonce)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.master.AddTransaction(context.Background(), tx); err != nil {
+		return nil, fmt.Errorf("failed to add transaction: %v", err)
+	}
+	c.mu.Lock()
+	c.nonces[fromAddr] = nonce + 1
+	c.mu.Unlock()
+	return tx, nil
+}
+
+// SignTransfer returns the transfer of the value of the genesis token from the
+// account in the shard of the full shard key to the address with the nonce,
+// signed but not sent.
+func (c *Cluster) SignTransfer(from account.Account, fromFullShardKey uint32, to account.Address, value *big.Int,
+	nonce uint64) (*types.Transaction, error) {
+	fromAddr := from.QKCAddress.AddressInShard(fromFullShardKey)
+	tokenID := c.cfg.Quarkchain.GetDefaultChainTokenID()
+	evmTx := types.NewEvmTransaction(nonce, to.Recipient, value, TransferGas, TransferGasPrice,
+		fromAddr.FullShardKey, to.FullShardKey, c.cfg.Quarkchain.NetworkID, 0, []byte{}, tokenID, tokenID)
+	key := from.Identity.GetKey()
+	prvKey, err := crypto.ToECDSA(key[:])
+	if err != nil {
+		return nil, err
+	}
+	evmTx, err = types.SignTx(evmTx, types.MakeSigner(evmTx.NetworkId()), prvKey)
+	if err != nil {
+		return nil, err
+	}
+	return &types.Transaction{EvmTx: evmTx, TxType: types.EvmTx}, nil
+}
+
+// nextNonce returns the nonce of the next transaction of the address, which is
+// its transaction count for its first transaction.
+func (c *Cluster) nextNonce(addr account.Address) (uint64, error) {
+	c.mu.Lock()
+	nonce, ok := c.nonces[addr]
+	c.mu.Unlock()
+	if ok {
+		return nonce, nil
+	}
+	data, err := c.master.GetPrimaryAccountData(context.Background(), &addr, nil)
+	if err != nil {
+		return 0, err
+	}
+	return data.TransactionCount, nil
+}
+
+// Balance returns the balance of the genesis token of the address in the shard
+// of its full shard key.
+func (c *Cluster) Balance(addr account.Address) (*big.Int, error) {
+	data, err := c.master.GetPrimaryAccountData(context.Background(), &addr, nil)
+	if err != nil {
+		return nil, err
+	}
+	return data.Balance.GetTokenBalance(c.cfg.Quarkchain.GetDefaultChainTokenID()), nil
+}
+
+// Receipt returns the receipt of the transaction, or an error if it is not
+// mined in its source shard yet.
+func (c *Cluster) Receipt(tx *types.Transaction) (*types.Receipt, error) {
+	fullShardID, err := c.cfg.Quarkchain.GetFullShardIdByFullShardKey(tx.EvmTx.FromFullShardKey())
+	if err != nil {
+		return nil, err
+	}
+	_, _, receipt, err := c.master.GetTransactionReceipt(context.Background(), tx.Hash(), account.NewBranch(fullShardID))
+	if err != nil {
+		return nil, err
+	}
+	if receipt == nil {
+		return nil, fmt.Errorf("transaction %x not mined", tx.Hash())
+	}
+	return receipt, nil
+}